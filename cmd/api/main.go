@@ -2,6 +2,8 @@ package main
 
 import (
 	"os"
+	// the time zones of the theaters are loaded even where the system has no time zone database
+	_ "time/tzdata"
 
 	"github.com/metinatakli/movie-reservation-system/internal/app"
 )
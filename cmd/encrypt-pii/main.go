@@ -0,0 +1,67 @@
+// Command encrypt-pii encrypts personal data that is still stored in plaintext, and re-encrypts
+// values sealed with retired keys after a key rotation. It is safe to run repeatedly.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"os"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/metinatakli/movie-reservation-system/internal/envelope"
+	"github.com/metinatakli/movie-reservation-system/internal/repository"
+)
+
+func main() {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	err := run(logger)
+	if err != nil {
+		logger.Error("failed to encrypt personal data", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run(logger *slog.Logger) error {
+	dsn := flag.String("db-dsn", "", "PostgreSQL DSN")
+	keys := flag.String("pii-keys", "", "Comma separated id:base64 keys, the first key is the primary key")
+	batchSize := flag.Int("batch-size", 500, "Number of rows processed per transaction")
+
+	flag.Parse()
+
+	keyring, err := envelope.ParseKeyring(*keys)
+	if err != nil {
+		return err
+	}
+
+	if keyring == nil {
+		return errors.New("at least one key must be provided with -pii-keys")
+	}
+
+	if *batchSize <= 0 {
+		return errors.New("batch size must be positive")
+	}
+
+	ctx := context.Background()
+
+	db, err := pgxpool.New(ctx, *dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	userRepo := repository.NewPostgresUserRepository(db, keyring)
+
+	logger.Info("encrypting birth dates", "primary_key", keyring.PrimaryKeyID(), "batch_size", *batchSize)
+
+	updated, err := userRepo.EncryptBirthDates(ctx, *batchSize)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("encrypted birth dates", "rows", updated)
+
+	return nil
+}
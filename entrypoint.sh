@@ -8,4 +8,5 @@
   -smtp-password="$SMTP_PASSWORD" \
   -stripe-key="$STRIPE_KEY" \
   -stripe-webhook-secret="$STRIPE_WEBHOOK_SECRET" \
+  -pii-keys="$PII_KEYS" \
   -otel-collector-url="$OTEL_COLLECTOR_URL"
@@ -0,0 +1,106 @@
+package analytics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+// flushTimeout bounds the final flush on shutdown, when the run context is already canceled.
+const flushTimeout = 5 * time.Second
+
+// Buffer collects analytics events in memory and writes them to the repository in batches, so
+// recording an event never waits for the database. Events are dropped when the buffer is full.
+type Buffer struct {
+	repo      domain.AnalyticsEventRepository
+	logger    *slog.Logger
+	events    chan domain.AnalyticsEvent
+	batchSize int
+	interval  time.Duration
+}
+
+// NewBuffer creates a buffer holding up to capacity events. A batch is written once batchSize events
+// are collected or interval has passed since the last write, whichever comes first.
+func NewBuffer(
+	repo domain.AnalyticsEventRepository,
+	logger *slog.Logger,
+	capacity, batchSize int,
+	interval time.Duration) *Buffer {
+
+	return &Buffer{
+		repo:      repo,
+		logger:    logger,
+		events:    make(chan domain.AnalyticsEvent, capacity),
+		batchSize: batchSize,
+		interval:  interval,
+	}
+}
+
+// Add queues an event without blocking. It reports false when the buffer is full and the event is dropped.
+func (b *Buffer) Add(event domain.AnalyticsEvent) bool {
+	select {
+	case b.events <- event:
+		return true
+	default:
+		return false
+	}
+}
+
+// Run writes the queued events until the context is canceled. The events still queued then are
+// written before it returns.
+func (b *Buffer) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	batch := make([]domain.AnalyticsEvent, 0, b.batchSize)
+
+	for {
+		select {
+		case event := <-b.events:
+			batch = append(batch, event)
+			if len(batch) >= b.batchSize {
+				batch = b.flush(ctx, batch)
+			}
+		case <-ticker.C:
+			batch = b.flush(ctx, batch)
+		case <-ctx.Done():
+			b.drain(batch)
+			return
+		}
+	}
+}
+
+func (b *Buffer) drain(batch []domain.AnalyticsEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+
+	for {
+		select {
+		case event := <-b.events:
+			batch = append(batch, event)
+			if len(batch) >= b.batchSize {
+				batch = b.flush(ctx, batch)
+			}
+		default:
+			b.flush(ctx, batch)
+			return
+		}
+	}
+}
+
+// flush writes the batch and returns it emptied. A failed batch is dropped, analytics are best effort
+// and retrying would hold back the events queued behind it.
+func (b *Buffer) flush(ctx context.Context, batch []domain.AnalyticsEvent) []domain.AnalyticsEvent {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	err := b.repo.CreateBatch(ctx, batch)
+	if err != nil {
+		b.logger.Error("failed to write analytics events", "count", len(batch), "error", err)
+	}
+
+	return batch[:0]
+}
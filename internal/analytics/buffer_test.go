@@ -0,0 +1,133 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+type fakeRepo struct {
+	mu      sync.Mutex
+	batches [][]domain.AnalyticsEvent
+	err     error
+}
+
+func (r *fakeRepo) CreateBatch(ctx context.Context, events []domain.AnalyticsEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.batches = append(r.batches, append([]domain.AnalyticsEvent(nil), events...))
+	return r.err
+}
+
+func (r *fakeRepo) batchSizes() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sizes := make([]int, len(r.batches))
+	for i, b := range r.batches {
+		sizes[i] = len(b)
+	}
+
+	return sizes
+}
+
+func newTestBuffer(repo *fakeRepo, capacity, batchSize int, interval time.Duration) *Buffer {
+	return NewBuffer(repo, slog.New(slog.NewTextHandler(io.Discard, nil)), capacity, batchSize, interval)
+}
+
+func TestBufferDropsEventsWhenFull(t *testing.T) {
+	b := newTestBuffer(&fakeRepo{}, 2, 10, time.Hour)
+
+	for i, want := range []bool{true, true, false} {
+		if got := b.Add(domain.AnalyticsEvent{Name: domain.EventSeatMapViewed}); got != want {
+			t.Errorf("Add() #%d = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestBufferWritesFullBatches(t *testing.T) {
+	repo := &fakeRepo{}
+	b := newTestBuffer(repo, 10, 2, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		b.Run(ctx)
+		close(done)
+	}()
+
+	for range 3 {
+		b.Add(domain.AnalyticsEvent{Name: domain.EventCartAbandoned})
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(repo.batchSizes()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+
+	// the full batch is written right away, the remaining event on shutdown
+	sizes := repo.batchSizes()
+	if len(sizes) != 2 || sizes[0] != 2 || sizes[1] != 1 {
+		t.Errorf("expected batches of 2 and 1 events, got %v", sizes)
+	}
+}
+
+func TestBufferWritesOnInterval(t *testing.T) {
+	repo := &fakeRepo{}
+	b := newTestBuffer(repo, 10, 100, 10*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go b.Run(ctx)
+
+	b.Add(domain.AnalyticsEvent{Name: domain.EventSeatMapViewed})
+
+	deadline := time.Now().Add(time.Second)
+	for len(repo.batchSizes()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if sizes := repo.batchSizes(); len(sizes) != 1 || sizes[0] != 1 {
+		t.Errorf("expected a single batch of 1 event, got %v", sizes)
+	}
+}
+
+func TestBufferDropsFailedBatches(t *testing.T) {
+	repo := &fakeRepo{err: errors.New("db error")}
+	b := newTestBuffer(repo, 10, 1, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		b.Run(ctx)
+		close(done)
+	}()
+
+	b.Add(domain.AnalyticsEvent{Name: domain.EventSeatMapViewed})
+	b.Add(domain.AnalyticsEvent{Name: domain.EventSeatMapViewed})
+
+	deadline := time.Now().Add(time.Second)
+	for len(repo.batchSizes()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+
+	if sizes := repo.batchSizes(); len(sizes) != 2 {
+		t.Errorf("expected each event to be written once, got batches %v", sizes)
+	}
+}
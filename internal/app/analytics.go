@@ -0,0 +1,242 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	heatmapMonthLayout = "2006-01"
+	// sales of past months don't change anymore, so they can be cached for long
+	closedMonthHeatmapTTL = 24 * time.Hour
+	openMonthHeatmapTTL   = 10 * time.Minute
+
+	attendanceDayLayout = "2006-01-02"
+)
+
+func (app *Application) GetHallSeatHeatmap(
+	w http.ResponseWriter,
+	r *http.Request,
+	hallID int,
+	params api.GetHallSeatHeatmapParams) {
+
+	if hallID < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("hall ID must be greater than zero"))
+		return
+	}
+
+	err := app.validator.Struct(params)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	from, err := time.Parse(heatmapMonthLayout, params.From)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	to, err := time.Parse(heatmapMonthLayout, params.To)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	var channel domain.SalesChannel
+	if params.Channel != nil {
+		channel = domain.SalesChannel(*params.Channel)
+	}
+
+	heatmap, err := app.hallSeatHeatmap(r.Context(), hallID, from, to, channel)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if len(heatmap.Seats) == 0 {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	resp := api.SeatHeatmapResponse{
+		HallId:        hallID,
+		From:          params.From,
+		To:            params.To,
+		ShowtimeCount: heatmap.ShowtimeCount,
+		Seats:         make([]api.SeatHeatmapSeat, len(heatmap.Seats)),
+	}
+
+	for i, seat := range heatmap.Seats {
+		resp.Seats[i] = api.SeatHeatmapSeat{
+			SeatId:    seat.SeatID,
+			Row:       seat.Row,
+			Column:    seat.Col,
+			Type:      api.SeatType(seat.Type),
+			SoldCount: seat.SoldCount,
+			SellRate:  heatmap.SellRate(seat),
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// GetTheaterAttendance reports the check-ins, late arrivals and no-shows of the showtimes of the theater
+// starting within the days from and to, both inclusive. Days are taken in UTC.
+func (app *Application) GetTheaterAttendance(
+	w http.ResponseWriter,
+	r *http.Request,
+	theaterID int,
+	params api.GetTheaterAttendanceParams) {
+
+	if theaterID < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("theater ID must be greater than zero"))
+		return
+	}
+
+	err := app.validator.Struct(params)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	from, err := time.Parse(attendanceDayLayout, params.From)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	to, err := time.Parse(attendanceDayLayout, params.To)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	attendance, err := app.reservationRepo.GetAttendanceByTheater(r.Context(), theaterID, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	resp := api.TheaterAttendanceResponse{
+		TheaterId: theaterID,
+		From:      params.From,
+		To:        params.To,
+		Showtimes: make([]api.ShowtimeAttendance, len(attendance)),
+	}
+
+	for i, a := range attendance {
+		resp.Showtimes[i] = api.ShowtimeAttendance{
+			ShowtimeId:   a.ShowtimeID,
+			StartTime:    a.StartTime,
+			MovieTitle:   a.MovieTitle,
+			HallName:     a.HallName,
+			Reservations: a.Reservations,
+			CheckedIn:    a.CheckedIn,
+			LateArrivals: a.LateArrivals,
+			NoShows:      a.NoShows,
+			Released:     a.Released,
+			NoShowRate:   a.NoShowRate(),
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// hallSeatHeatmap builds the heatmap of the months between from and to (both inclusive) out of monthly heatmaps.
+// An empty channel counts the sales of all channels.
+func (app *Application) hallSeatHeatmap(
+	ctx context.Context,
+	hallID int,
+	from, to time.Time,
+	channel domain.SalesChannel) (*domain.SeatHeatmap, error) {
+
+	heatmap := &domain.SeatHeatmap{
+		HallID: hallID,
+		From:   from,
+		To:     to.AddDate(0, 1, 0),
+	}
+
+	for month := from; !month.After(to); month = month.AddDate(0, 1, 0) {
+		monthly, err := app.monthlySeatHeatmap(ctx, hallID, month, channel)
+		if err != nil {
+			return nil, err
+		}
+
+		heatmap.Merge(*monthly)
+	}
+
+	return heatmap, nil
+}
+
+func (app *Application) monthlySeatHeatmap(
+	ctx context.Context,
+	hallID int,
+	month time.Time,
+	channel domain.SalesChannel) (*domain.SeatHeatmap, error) {
+
+	key := seatHeatmapKey(hallID, month, channel)
+
+	cached, err := app.redis.Get(ctx, key).Bytes()
+	if err == nil {
+		var heatmap domain.SeatHeatmap
+
+		err = json.Unmarshal(cached, &heatmap)
+		if err == nil {
+			return &heatmap, nil
+		}
+	}
+
+	if err != nil && !errors.Is(err, redis.Nil) {
+		app.logger.Warn("failed to read seat heatmap from cache", "key", key, "error", err)
+	}
+
+	end := month.AddDate(0, 1, 0)
+
+	heatmap, err := app.seatRepo.GetSeatSalesByHall(ctx, hallID, month, end, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := openMonthHeatmapTTL
+	if end.Before(time.Now()) {
+		ttl = closedMonthHeatmapTTL
+	}
+
+	heatmapBytes, err := json.Marshal(heatmap)
+	if err != nil {
+		return nil, err
+	}
+
+	err = app.redis.Set(ctx, key, heatmapBytes, ttl).Err()
+	if err != nil {
+		app.logger.Warn("failed to cache seat heatmap", "key", key, "error", err)
+	}
+
+	return heatmap, nil
+}
+
+// seatHeatmapKey keeps the key of the heatmap of all channels unchanged, heatmaps of a single channel are
+// cached apart.
+func seatHeatmapKey(hallID int, month time.Time, channel domain.SalesChannel) string {
+	key := fmt.Sprintf("seat_heatmap:%d:%s", hallID, month.Format(heatmapMonthLayout))
+	if channel != "" {
+		key += ":" + string(channel)
+	}
+
+	return key
+}
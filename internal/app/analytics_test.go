@@ -0,0 +1,298 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/metinatakli/movie-reservation-system/internal/validator"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type AnalyticsTestSuite struct {
+	suite.Suite
+	app         *Application
+	seatRepo    *mocks.MockSeatRepo
+	redisClient *mocks.MockRedisClient
+}
+
+func (s *AnalyticsTestSuite) SetupTest() {
+	s.seatRepo = new(mocks.MockSeatRepo)
+	s.redisClient = new(mocks.MockRedisClient)
+
+	s.app = newTestApplication(func(a *Application) {
+		a.seatRepo = s.seatRepo
+		a.redis = s.redisClient
+	})
+}
+
+func TestAnalyticsSuite(t *testing.T) {
+	suite.Run(t, new(AnalyticsTestSuite))
+}
+
+func (s *AnalyticsTestSuite) TestGetHallSeatHeatmap() {
+	january := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	february := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	march := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	januaryHeatmap := domain.SeatHeatmap{
+		HallID:        1,
+		ShowtimeCount: 4,
+		Seats: []domain.SeatSales{
+			{SeatID: 1, Row: 1, Col: 1, Type: "Standard", SoldCount: 1},
+			{SeatID: 2, Row: 1, Col: 2, Type: "VIP", SoldCount: 4},
+		},
+	}
+	januaryBytes, _ := json.Marshal(januaryHeatmap)
+
+	tests := []struct {
+		name           string
+		hallID         int
+		params         api.GetHallSeatHeatmapParams
+		setupMocks     func()
+		wantStatus     int
+		wantErrMessage string
+		wantResponse   *api.SeatHeatmapResponse
+	}{
+		{
+			name:           "should fail when hall ID is zero or negative",
+			hallID:         0,
+			params:         api.GetHallSeatHeatmapParams{From: "2024-01", To: "2024-02"},
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "hall ID must be greater than zero",
+		},
+		{
+			name:           "should fail when month format is invalid",
+			hallID:         1,
+			params:         api.GetHallSeatHeatmapParams{From: "2024-01-01", To: "2024-02"},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: validator.ErrDefaultInvalid,
+		},
+		{
+			name:           "should fail when range is reversed",
+			hallID:         1,
+			params:         api.GetHallSeatHeatmapParams{From: "2024-03", To: "2024-02"},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: fmt.Sprintf(validator.ErrRangeEnd, "From"),
+		},
+		{
+			name:           "should fail when range is too long",
+			hallID:         1,
+			params:         api.GetHallSeatHeatmapParams{From: "2022-01", To: "2024-01"},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: fmt.Sprintf(validator.ErrRangeLength, "24 months"),
+		},
+		{
+			name:   "should fail when channel is unknown",
+			hallID: 1,
+			params: api.GetHallSeatHeatmapParams{
+				From:    "2024-01",
+				To:      "2024-01",
+				Channel: ptr(api.SalesChannel("fax")),
+			},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: fmt.Sprintf(validator.ErrOneOf, "web mobile kiosk box-office partner"),
+		},
+		{
+			name:   "should count only the sales of the channel",
+			hallID: 1,
+			params: api.GetHallSeatHeatmapParams{
+				From:    "2024-01",
+				To:      "2024-01",
+				Channel: ptr(api.Kiosk),
+			},
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, seatHeatmapKey(1, january, domain.SalesChannelKiosk)).
+					Return(redis.NewStringResult("", redis.Nil))
+				s.seatRepo.On("GetSeatSalesByHall", mock.Anything, 1, january, february, domain.SalesChannelKiosk).
+					Return(&januaryHeatmap, nil)
+				s.redisClient.On("Set", mock.Anything, "seat_heatmap:1:2024-01:kiosk", mock.Anything, closedMonthHeatmapTTL).
+					Return(redis.NewStatusResult("OK", nil))
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: &api.SeatHeatmapResponse{
+				HallId:        1,
+				From:          "2024-01",
+				To:            "2024-01",
+				ShowtimeCount: 4,
+				Seats: []api.SeatHeatmapSeat{
+					{SeatId: 1, Row: 1, Column: 1, Type: api.Standard, SoldCount: 1, SellRate: 0.25},
+					{SeatId: 2, Row: 1, Column: 2, Type: api.VIP, SoldCount: 4, SellRate: 1},
+				},
+			},
+		},
+		{
+			name:   "should fail when database error occurs",
+			hallID: 1,
+			params: api.GetHallSeatHeatmapParams{From: "2024-01", To: "2024-01"},
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, seatHeatmapKey(1, january, "")).Return(redis.NewStringResult("", redis.Nil))
+				s.seatRepo.On("GetSeatSalesByHall", mock.Anything, 1, january, february, domain.SalesChannel("")).Return(nil, fmt.Errorf("database error"))
+			},
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+		{
+			name:   "should return not found when hall has no seats",
+			hallID: 99,
+			params: api.GetHallSeatHeatmapParams{From: "2024-01", To: "2024-01"},
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, seatHeatmapKey(99, january, "")).Return(redis.NewStringResult("", redis.Nil))
+				s.seatRepo.On("GetSeatSalesByHall", mock.Anything, 99, january, february, domain.SalesChannel("")).Return(&domain.SeatHeatmap{HallID: 99}, nil)
+				s.redisClient.On("Set", mock.Anything, seatHeatmapKey(99, january, ""), mock.Anything, closedMonthHeatmapTTL).
+					Return(redis.NewStatusResult("OK", nil))
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+		},
+		{
+			name:   "should merge cached and computed months",
+			hallID: 1,
+			params: api.GetHallSeatHeatmapParams{From: "2024-01", To: "2024-02"},
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, seatHeatmapKey(1, january, "")).Return(redis.NewStringResult(string(januaryBytes), nil))
+				s.redisClient.On("Get", mock.Anything, seatHeatmapKey(1, february, "")).Return(redis.NewStringResult("", redis.Nil))
+				s.seatRepo.On("GetSeatSalesByHall", mock.Anything, 1, february, march, domain.SalesChannel("")).Return(&domain.SeatHeatmap{
+					HallID:        1,
+					ShowtimeCount: 4,
+					Seats: []domain.SeatSales{
+						{SeatID: 1, Row: 1, Col: 1, Type: "Standard", SoldCount: 1},
+						{SeatID: 2, Row: 1, Col: 2, Type: "VIP", SoldCount: 2},
+					},
+				}, nil)
+				s.redisClient.On("Set", mock.Anything, seatHeatmapKey(1, february, ""), mock.Anything, closedMonthHeatmapTTL).
+					Return(redis.NewStatusResult("OK", nil)).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: &api.SeatHeatmapResponse{
+				HallId:        1,
+				From:          "2024-01",
+				To:            "2024-02",
+				ShowtimeCount: 8,
+				Seats: []api.SeatHeatmapSeat{
+					{SeatId: 1, Row: 1, Column: 1, Type: api.Standard, SoldCount: 2, SellRate: 0.25},
+					{SeatId: 2, Row: 1, Column: 2, Type: api.VIP, SoldCount: 6, SellRate: 0.75},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			w, r := executeRequest(s.T(), http.MethodGet, "/admin/halls/1/seat-heatmap", nil)
+
+			s.app.GetHallSeatHeatmap(w, r, tt.hallID, tt.params)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantResponse != nil {
+				var response api.SeatHeatmapResponse
+				err := json.NewDecoder(w.Body).Decode(&response)
+				s.Require().NoError(err)
+
+				diff := cmp.Diff(tt.wantResponse, &response)
+				s.Empty(diff, "Response mismatch (-want +got):\n%s", diff)
+			}
+
+			checkErrorResponse(s.T(), w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+
+			s.redisClient.AssertExpectations(s.T())
+		})
+	}
+}
+
+func TestGetTheaterAttendance(t *testing.T) {
+	from := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		params     api.GetTheaterAttendanceParams
+		wantStatus int
+	}{
+		{
+			name:       "should fail when a day is malformed",
+			params:     api.GetTheaterAttendanceParams{From: "2025-06", To: "2025-06-30"},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "should fail when the range ends before it starts",
+			params:     api.GetTheaterAttendanceParams{From: "2025-06-30", To: "2025-06-01"},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "should fail when the range is too long",
+			params:     api.GetTheaterAttendanceParams{From: "2025-01-01", To: "2025-06-30"},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "should report the no-show rates of the showtimes",
+			params:     api.GetTheaterAttendanceParams{From: "2025-06-01", To: "2025-06-30"},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reservationRepo := new(mocks.MockReservationRepo)
+			reservationRepo.On("GetAttendanceByTheater", mock.Anything, 3, from, from.AddDate(0, 0, 30)).
+				Return([]domain.ShowtimeAttendance{
+					{ShowtimeID: 5, Reservations: 8, CheckedIn: 6, LateArrivals: 1, NoShows: 2, Released: 1},
+					{ShowtimeID: 6},
+				}, nil).Maybe()
+
+			app := newTestApplication(func(a *Application) {
+				a.reservationRepo = reservationRepo
+			})
+
+			w, r := executeRequest(t, http.MethodGet, "/admin/theaters/3/attendance", nil)
+			app.GetTheaterAttendance(w, r, 3, tt.params)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status code = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var resp api.TheaterAttendanceResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+
+			if len(resp.Showtimes) != 2 {
+				t.Fatalf("got %d showtimes, want 2", len(resp.Showtimes))
+			}
+
+			if got := resp.Showtimes[0].NoShowRate; got != 0.25 {
+				t.Errorf("no-show rate = %v, want 0.25", got)
+			}
+
+			if got := resp.Showtimes[1].NoShowRate; got != 0 {
+				t.Errorf("no-show rate without reservations = %v, want 0", got)
+			}
+
+			reservationRepo.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,303 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mailer"
+	"github.com/metinatakli/movie-reservation-system/internal/scheduler"
+)
+
+const (
+	// a user in digest mode gets at most one digest within this period
+	notificationDigestPeriod = 24 * time.Hour
+	// maxDigestsPerRun bounds the number of digests sent by a single run of the job
+	maxDigestsPerRun = 100
+	// maxAnnouncementEmailsPerRun bounds the number of announcement emails sent by a single run of the job
+	maxAnnouncementEmailsPerRun  = 500
+	maxAnnouncementEmailAttempts = 5
+)
+
+func (app *Application) CreateAnnouncement(w http.ResponseWriter, r *http.Request) {
+	var input api.CreateAnnouncementRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	announcement := &domain.Announcement{
+		Title:       input.Title,
+		Body:        input.Body,
+		Audience:    domain.AnnouncementAudience(input.Audience),
+		TheaterID:   input.TheaterId,
+		SendEmail:   input.SendEmail != nil && *input.SendEmail,
+		ScheduledAt: time.Now(),
+		CreatedBy:   app.contextGetUserId(r),
+	}
+
+	if input.ScheduledAt != nil {
+		announcement.ScheduledAt = *input.ScheduledAt
+	}
+
+	err = app.announcementRepo.Create(r.Context(), announcement)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrTheaterNotFound):
+			app.notFoundResponseWithErr(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, toApiAnnouncement(announcement), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) CancelAnnouncement(w http.ResponseWriter, r *http.Request, announcementId int) {
+	if announcementId < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("announcement ID must be greater than zero"))
+		return
+	}
+
+	announcement, err := app.announcementRepo.Cancel(r.Context(), announcementId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, domain.ErrAnnouncementNotCancellable):
+			app.editConflictResponseWithErr(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, toApiAnnouncement(announcement), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) GetNotificationsOfUser(
+	w http.ResponseWriter,
+	r *http.Request,
+	params api.GetNotificationsOfUserParams) {
+
+	err := app.validator.Struct(params)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	userId := app.contextGetUserId(r)
+	pagination := toPagination(params.Page, params.PageSize)
+
+	notifications, metadata, err := app.announcementRepo.GetNotificationsByUserId(r.Context(), userId, pagination)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	resp := api.NotificationsResponse{
+		Notifications: make([]api.Notification, len(notifications)),
+		Metadata:      *toApiMetadata(r, metadata),
+	}
+
+	for i, notification := range notifications {
+		resp.Notifications[i] = api.Notification{
+			Id:        notification.ID,
+			Title:     notification.Title,
+			Body:      notification.Body,
+			CreatedAt: notification.CreatedAt,
+			ReadAt:    notification.ReadAt,
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) MarkNotificationRead(w http.ResponseWriter, r *http.Request, notificationId int) {
+	if notificationId < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("notification ID must be greater than zero"))
+		return
+	}
+
+	err := app.announcementRepo.MarkNotificationRead(r.Context(), app.contextGetUserId(r), notificationId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deliverAnnouncements delivers due announcements to the notification centers of their audience, which
+// queues the emails of announcements that ask for it, and then sends the queued emails.
+func (app *Application) deliverAnnouncements(ctx context.Context) error {
+	if err := scheduler.CheckFence(ctx); err != nil {
+		return err
+	}
+
+	delivered, err := app.announcementRepo.DeliverDue(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, announcement := range delivered {
+		app.logger.Info("delivered announcement",
+			"announcement_id", announcement.ID,
+			"recipient_count", *announcement.RecipientCount)
+	}
+
+	return app.sendAnnouncementEmails(ctx)
+}
+
+// sendAnnouncementEmails sends the due announcement emails. An email is only marked as sent once it was
+// sent, a failed one is retried with a growing backoff until its attempts run out. Notifications are the
+// source of truth, so an email is given up after that.
+func (app *Application) sendAnnouncementEmails(ctx context.Context) error {
+	emails, err := app.announcementRepo.GetDueEmails(ctx, time.Now(), maxAnnouncementEmailsPerRun)
+	if err != nil {
+		return err
+	}
+
+	for _, email := range emails {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err := scheduler.CheckFence(ctx); err != nil {
+			return err
+		}
+
+		data := mailer.AnnouncementEmail{
+			FirstName: email.FirstName,
+			Title:     email.Title,
+			Body:      email.Body,
+		}
+
+		err := app.mailer.Send(ctx, email.Email, data)
+		if err != nil {
+			email.Failed(err, time.Now(), maxAnnouncementEmailAttempts)
+
+			app.logger.Error("failed to send announcement email",
+				"notification_id", email.NotificationID,
+				"userId", email.UserID,
+				"attempt", email.Attempts,
+				"next_attempt_at", email.NextAttemptAt,
+				"error", err)
+
+			err = app.announcementRepo.RecordEmailFailure(ctx, email)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		err = app.announcementRepo.MarkEmailSent(ctx, email.NotificationID, time.Now())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sendNotificationDigests emails users in digest mode the notifications that wait for their digest, once a
+// period. A digest that fails to send is retried by the next run, its notifications stay pending until then.
+func (app *Application) sendNotificationDigests(ctx context.Context) error {
+	now := time.Now()
+
+	digests, err := app.announcementRepo.GetDueDigests(ctx, now.Add(-notificationDigestPeriod), maxDigestsPerRun)
+	if err != nil {
+		return err
+	}
+
+	for _, digest := range digests {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err := scheduler.CheckFence(ctx); err != nil {
+			return err
+		}
+
+		data := mailer.NotificationDigestEmail{
+			FirstName:     digest.FirstName,
+			Notifications: make([]mailer.DigestNotification, len(digest.Notifications)),
+		}
+
+		notificationIDs := make([]int, len(digest.Notifications))
+
+		for i, notification := range digest.Notifications {
+			data.Notifications[i] = mailer.DigestNotification{
+				Title: notification.Title,
+				Body:  notification.Body,
+				Date:  notification.CreatedAt.Format("January 2, 2006"),
+			}
+			notificationIDs[i] = notification.ID
+		}
+
+		err = app.mailer.Send(ctx, digest.Email, data)
+		if err != nil {
+			app.logger.Error("failed to send notification digest", "userId", digest.UserID, "error", err)
+			continue
+		}
+
+		err = app.announcementRepo.MarkDigestSent(ctx, digest.UserID, notificationIDs, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(digests) > 0 {
+		app.logger.Info("sent notification digests", "count", len(digests))
+	}
+
+	return nil
+}
+
+func toApiAnnouncement(announcement *domain.Announcement) api.Announcement {
+	return api.Announcement{
+		Id:             announcement.ID,
+		Title:          announcement.Title,
+		Body:           announcement.Body,
+		Audience:       api.AnnouncementAudience(announcement.Audience),
+		TheaterId:      announcement.TheaterID,
+		SendEmail:      announcement.SendEmail,
+		Status:         api.AnnouncementStatus(announcement.Status),
+		ScheduledAt:    announcement.ScheduledAt,
+		RecipientCount: announcement.RecipientCount,
+		CreatedAt:      announcement.CreatedAt,
+		SentAt:         announcement.SentAt,
+		CancelledAt:    announcement.CancelledAt,
+	}
+}
@@ -0,0 +1,329 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mailer"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type AnnouncementsTestSuite struct {
+	suite.Suite
+	app              *Application
+	announcementRepo *mocks.MockAnnouncementRepo
+}
+
+func (s *AnnouncementsTestSuite) SetupTest() {
+	s.announcementRepo = new(mocks.MockAnnouncementRepo)
+
+	s.app = newTestApplication(func(a *Application) {
+		a.announcementRepo = s.announcementRepo
+	})
+}
+
+func TestAnnouncementsSuite(t *testing.T) {
+	suite.Run(t, new(AnnouncementsTestSuite))
+}
+
+func (s *AnnouncementsTestSuite) TestCreateAnnouncement() {
+	scheduledAt := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		input          api.CreateAnnouncementRequest
+		setupMocks     func()
+		wantStatus     int
+		wantErrMessage string
+	}{
+		{
+			name: "should fail when theater is missing for theater audience",
+			input: api.CreateAnnouncementRequest{
+				Title:    "Hall closed",
+				Body:     "Hall 3 is closed for maintenance.",
+				Audience: api.TheaterUpcoming,
+			},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: "is required when Audience is theater-upcoming",
+		},
+		{
+			name: "should fail when theater is given for all users",
+			input: api.CreateAnnouncementRequest{
+				Title:     "New app",
+				Body:      "Try our new app.",
+				Audience:  api.All,
+				TheaterId: ptr(1),
+			},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: "must be empty unless Audience is theater-upcoming",
+		},
+		{
+			name: "should fail when theater does not exist",
+			input: api.CreateAnnouncementRequest{
+				Title:     "Hall closed",
+				Body:      "Hall 3 is closed for maintenance.",
+				Audience:  api.TheaterUpcoming,
+				TheaterId: ptr(99),
+			},
+			setupMocks: func() {
+				s.announcementRepo.On("Create", mock.Anything, mock.Anything).Return(domain.ErrTheaterNotFound)
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: domain.ErrTheaterNotFound.Error(),
+		},
+		{
+			name: "should schedule announcement",
+			input: api.CreateAnnouncementRequest{
+				Title:       "Hall closed",
+				Body:        "Hall 3 is closed for maintenance.",
+				Audience:    api.TheaterUpcoming,
+				TheaterId:   ptr(1),
+				SendEmail:   ptr(true),
+				ScheduledAt: &scheduledAt,
+			},
+			setupMocks: func() {
+				s.announcementRepo.On("Create", mock.Anything, mock.MatchedBy(func(a *domain.Announcement) bool {
+					return a.Audience == domain.AudienceTheaterUpcoming &&
+						*a.TheaterID == 1 &&
+						a.SendEmail &&
+						a.ScheduledAt.Equal(scheduledAt) &&
+						a.CreatedBy == 7
+				})).Run(func(args mock.Arguments) {
+					a := args.Get(1).(*domain.Announcement)
+					a.ID = 5
+					a.Status = domain.AnnouncementScheduled
+				}).Return(nil)
+			},
+			wantStatus: http.StatusCreated,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			w, r := executeRequest(s.T(), http.MethodPost, "/admin/announcements", tt.input)
+			r = r.WithContext(context.WithValue(r.Context(), SessionKeyUserId, 7))
+
+			s.app.CreateAnnouncement(w, r)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantStatus == http.StatusCreated {
+				var response api.Announcement
+				s.Require().NoError(json.NewDecoder(w.Body).Decode(&response))
+
+				s.Equal(5, response.Id)
+				s.Equal(api.Scheduled, response.Status)
+			}
+
+			checkErrorResponse(s.T(), w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+
+			s.announcementRepo.AssertExpectations(s.T())
+		})
+	}
+}
+
+func (s *AnnouncementsTestSuite) TestCancelAnnouncement() {
+	tests := []struct {
+		name           string
+		announcementID int
+		setupMocks     func()
+		wantStatus     int
+		wantErrMessage string
+	}{
+		{
+			name:           "should fail when announcement ID is invalid",
+			announcementID: 0,
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "announcement ID must be greater than zero",
+		},
+		{
+			name:           "should return not found",
+			announcementID: 1,
+			setupMocks: func() {
+				s.announcementRepo.On("Cancel", mock.Anything, 1).Return(nil, domain.ErrRecordNotFound)
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+		},
+		{
+			name:           "should conflict when announcement is already sent",
+			announcementID: 1,
+			setupMocks: func() {
+				s.announcementRepo.On("Cancel", mock.Anything, 1).Return(nil, domain.ErrAnnouncementNotCancellable)
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: domain.ErrAnnouncementNotCancellable.Error(),
+		},
+		{
+			name:           "should cancel scheduled announcement",
+			announcementID: 1,
+			setupMocks: func() {
+				now := time.Now()
+				s.announcementRepo.On("Cancel", mock.Anything, 1).Return(&domain.Announcement{
+					ID:          1,
+					Audience:    domain.AudienceAll,
+					Status:      domain.AnnouncementCancelled,
+					CancelledAt: &now,
+				}, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			w, r := executeRequest(s.T(), http.MethodPost, "/admin/announcements/1/cancel", nil)
+
+			s.app.CancelAnnouncement(w, r, tt.announcementID)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			checkErrorResponse(s.T(), w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+
+			s.announcementRepo.AssertExpectations(s.T())
+		})
+	}
+}
+
+func (s *AnnouncementsTestSuite) TestDeliverAnnouncements() {
+	recipientCount := 2
+
+	s.announcementRepo.On("DeliverDue", mock.Anything, mock.Anything).Return([]domain.Announcement{
+		{ID: 1, Title: "Hall closed", SendEmail: true, RecipientCount: &recipientCount},
+	}, nil)
+
+	s.announcementRepo.On("GetDueEmails", mock.Anything, mock.Anything, maxAnnouncementEmailsPerRun).
+		Return([]domain.AnnouncementEmail{
+			{NotificationID: 10, UserID: 1, FirstName: "Freddie", Email: "freddie@example.com", Title: "Hall closed"},
+			{NotificationID: 11, UserID: 2, FirstName: "Brian", Email: "brian@example.com", Title: "Hall closed",
+				Attempts: maxAnnouncementEmailAttempts - 1},
+			{NotificationID: 12, UserID: 3, FirstName: "Roger", Email: "roger@example.com", Title: "Hall closed"},
+		}, nil)
+
+	// only the email which was sent is marked so, the failed ones are retried or given up
+	s.announcementRepo.On("RecordEmailFailure", mock.Anything, mock.MatchedBy(func(e domain.AnnouncementEmail) bool {
+		return e.NotificationID == 10 && e.Attempts == 1 && e.LastError == "smtp error" &&
+			e.NextAttemptAt != nil && time.Until(*e.NextAttemptAt) > 0
+	})).Return(nil).Once()
+	s.announcementRepo.On("RecordEmailFailure", mock.Anything, mock.MatchedBy(func(e domain.AnnouncementEmail) bool {
+		return e.NotificationID == 11 && e.Attempts == maxAnnouncementEmailAttempts && e.NextAttemptAt == nil
+	})).Return(nil).Once()
+	s.announcementRepo.On("MarkEmailSent", mock.Anything, 12, mock.Anything).Return(nil).Once()
+
+	var sentTo []string
+	s.app.mailer = &MockMailer{sendFunc: func(recipient, template string, data any) error {
+		s.Equal("announcement.tmpl", template)
+		s.Equal("Hall closed", data.(mailer.AnnouncementEmail).Title)
+		sentTo = append(sentTo, recipient)
+
+		if recipient != "roger@example.com" {
+			return errors.New("smtp error")
+		}
+		return nil
+	}}
+
+	err := s.app.deliverAnnouncements(context.Background())
+
+	s.Require().NoError(err)
+	s.Equal([]string{"freddie@example.com", "brian@example.com", "roger@example.com"}, sentTo)
+	s.announcementRepo.AssertExpectations(s.T())
+}
+
+func (s *AnnouncementsTestSuite) TestSendNotificationDigests() {
+	createdAt := time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC)
+
+	s.announcementRepo.On("GetDueDigests", mock.Anything, mock.MatchedBy(func(sentBefore time.Time) bool {
+		return time.Until(sentBefore) < -notificationDigestPeriod+time.Minute
+	}), maxDigestsPerRun).Return([]domain.NotificationDigest{
+		{
+			UserID:    1,
+			FirstName: "Freddie",
+			Email:     "freddie@example.com",
+			Notifications: []domain.Notification{
+				{ID: 10, UserID: 1, Title: "Hall closed", Body: "Hall 2 is closed", CreatedAt: createdAt},
+			},
+		},
+		{
+			UserID:    2,
+			FirstName: "Brian",
+			Email:     "brian@example.com",
+			Notifications: []domain.Notification{
+				{ID: 11, UserID: 2, Title: "Hall closed", Body: "Hall 2 is closed", CreatedAt: createdAt},
+				{ID: 12, UserID: 2, Title: "New snacks", Body: "Try the nachos", CreatedAt: createdAt},
+			},
+		},
+	}, nil)
+
+	// the failed digest of Freddie stays pending for the next run
+	s.announcementRepo.On("MarkDigestSent", mock.Anything, 2, []int{11, 12}, mock.Anything).Return(nil).Once()
+
+	var sentTo []string
+	s.app.mailer = &MockMailer{sendFunc: func(recipient, template string, data any) error {
+		s.Equal("notification_digest.tmpl", template)
+		sentTo = append(sentTo, recipient)
+
+		if recipient == "freddie@example.com" {
+			return errors.New("smtp error")
+		}
+
+		digest := data.(mailer.NotificationDigestEmail)
+		s.Len(digest.Notifications, 2)
+		s.Equal("June 1, 2025", digest.Notifications[0].Date)
+
+		return nil
+	}}
+
+	err := s.app.sendNotificationDigests(context.Background())
+
+	s.Require().NoError(err)
+	s.Equal([]string{"freddie@example.com", "brian@example.com"}, sentTo)
+	s.announcementRepo.AssertExpectations(s.T())
+}
+
+func (s *AnnouncementsTestSuite) TestMarkNotificationRead() {
+	s.announcementRepo.On("MarkNotificationRead", mock.Anything, 7, 3).Return(domain.ErrRecordNotFound).Once()
+	s.announcementRepo.On("MarkNotificationRead", mock.Anything, 7, 4).Return(nil).Once()
+
+	for notificationID, wantStatus := range map[int]int{3: http.StatusNotFound, 4: http.StatusNoContent} {
+		w, r := executeRequest(s.T(), http.MethodPost, "/users/me/notifications/1/read", nil)
+		r = r.WithContext(context.WithValue(r.Context(), SessionKeyUserId, 7))
+
+		s.app.MarkNotificationRead(w, r, notificationID)
+
+		s.Equal(wantStatus, w.Code)
+	}
+
+	s.announcementRepo.AssertExpectations(s.T())
+}
@@ -7,9 +7,11 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -17,19 +19,28 @@ import (
 	"github.com/alexedwards/scs/v2"
 	"github.com/exaring/otelpgx"
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5/multitracer"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/analytics"
+	"github.com/metinatakli/movie-reservation-system/internal/crm"
 	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/envelope"
+	"github.com/metinatakli/movie-reservation-system/internal/fulfillment"
+	"github.com/metinatakli/movie-reservation-system/internal/geocoding"
 	"github.com/metinatakli/movie-reservation-system/internal/mailer"
 	"github.com/metinatakli/movie-reservation-system/internal/payment"
 	"github.com/metinatakli/movie-reservation-system/internal/repository"
+	"github.com/metinatakli/movie-reservation-system/internal/scheduler"
+	"github.com/metinatakli/movie-reservation-system/internal/ticketsign"
 	appvalidator "github.com/metinatakli/movie-reservation-system/internal/validator"
 	"github.com/metinatakli/movie-reservation-system/internal/vcs"
+	"github.com/metinatakli/movie-reservation-system/internal/walletpass"
 	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 	"github.com/riandyrn/otelchi"
+	"github.com/shopspring/decimal"
 	"github.com/stripe/stripe-go/v82"
 	"go.opentelemetry.io/contrib/bridges/otelslog"
 	"go.opentelemetry.io/otel/log/global"
@@ -42,27 +53,59 @@ var (
 type Application struct {
 	config         Config
 	logger         *slog.Logger
-	db             *pgxpool.Pool
+	db             *ResizablePool
 	redis          redis.UniversalClient
 	validator      *validator.Validate
 	mailer         mailer.Mailer
 	sessionManager *scs.SessionManager
-
-	userRepo        domain.UserRepository
-	tokenRepo       domain.TokenRepository
-	movieRepo       domain.MovieRepository
-	theaterRepo     domain.TheaterRepository
-	seatRepo        domain.SeatRepository
-	paymentRepo     domain.PaymentRepository
-	reservationRepo domain.ReservationRepository
+	// encrypt the session token in the cookie, nil when the token is sent in plain
+	sessionCookieKeys *envelope.Keyring
+	// sign the ticket codes of wallet passes, nil when only the legacy codes are issued
+	ticketKeys *ticketsign.Keyring
+
+	userRepo           domain.UserRepository
+	tokenRepo          domain.TokenRepository
+	movieRepo          domain.MovieRepository
+	theaterRepo        domain.TheaterRepository
+	seatRepo           domain.SeatRepository
+	paymentRepo        domain.PaymentRepository
+	reservationRepo    domain.ReservationRepository
+	announcementRepo   domain.AnnouncementRepository
+	searchRepo         domain.SearchRepository
+	disputeRepo        domain.DisputeRepository
+	deviceSessionRepo  domain.DeviceSessionRepository
+	webhookEventRepo   domain.WebhookEventRepository
+	emailCampaignRepo  domain.EmailCampaignRepository
+	lifecycleEventRepo domain.LifecycleEventRepository
+	blockHoldRepo      domain.BlockHoldRepository
+
+	// brands served by the deployment, loaded at startup
+	tenants []domain.Tenant
+
+	analytics    *analytics.Buffer
+	fulfillments fulfillment.Queue
 
 	paymentProvider domain.PaymentProvider
+	geocoder        domain.Geocoder
+	walletPasses    domain.WalletPassIssuer
+	// the CRM lifecycle events are delivered to, nil when none is configured
+	crm domain.LifecycleEventTarget
+
+	jobRuns scheduler.RunStore
+
+	webhookMetrics webhookMetrics
 }
 
 type DBConfig struct {
 	DSN          string
 	MaxOpenConns int
-	MaxIdleTime  time.Duration
+	// connections opened at startup and kept open while idle
+	MinConns    int
+	MaxIdleTime time.Duration
+	// queries taking at least this long are logged, zero disables the logging
+	SlowQueryThreshold time.Duration
+	// how long an instance waits for another one applying the migrations with -migrate
+	MigrationLockTimeout time.Duration
 }
 
 type RedisConfig struct {
@@ -85,33 +128,222 @@ type StripeConfig struct {
 	WebhookSecret string
 	SuccessURL    string
 	FailureURL    string
+	// ApplicationFeePercent of a checkout is kept by the platform when the theater is paid out through
+	// Stripe Connect
+	ApplicationFeePercent decimal.Decimal
+	// WebhookAllowedIPs are the only sources the webhook is accepted from, any source when it's empty
+	WebhookAllowedIPs []netip.Prefix
+	// WebhookRateLimit is the number of webhook requests a source may send per minute, 0 disables the limit
+	WebhookRateLimit int
+}
+
+type GeocoderConfig struct {
+	Provider  string
+	URL       string
+	APIKey    string
+	UserAgent string
+	// minimum time between two requests toward the provider
+	RateLimit time.Duration
+	// longest time a search waits for its turn toward the provider before it's turned away
+	MaxWait  time.Duration
+	CacheTTL time.Duration
+}
+
+type WalletConfig struct {
+	// secret used to derive the ticket codes printed on passes
+	TicketSecret string
+	// Ed25519 keys signing the ticket codes scanners verify offline, see ticketsign.ParseKeyring
+	TicketSigningKeys        string
+	ApplePassTypeID          string
+	AppleTeamID              string
+	AppleCertFile            string
+	AppleKeyFile             string
+	AppleWWDRFile            string
+	GoogleIssuerID           string
+	GoogleClassSuffix        string
+	GoogleServiceAccountFile string
+	GoogleOrigins            string
+}
+
+type DisputesConfig struct {
+	// comma separated addresses notified when a chargeback is opened
+	FinanceEmails string
+	RevokeTickets bool
+}
+
+type SessionConfig struct {
+	IdleTimeout time.Duration
+	// absolute lifetime of a session, counted from login for authenticated sessions
+	Lifetime           time.Duration
+	RememberMeLifetime time.Duration
+	// how long after proving their identity a user may perform sensitive actions
+	ReauthWindow time.Duration
+	// attributes of the session cookie, the cookie is sent over HTTPS only outside of development unless
+	// configured otherwise
+	CookieSecure   bool
+	CookieSameSite string
+	CookieDomain   string
+	// keys encrypting the session token in the cookie, as comma separated id:base64 pairs like the PII
+	// keys. The token is sent in plain when empty.
+	CookieKeys string
+	// prefix of the session keys in Redis, so applications sharing a Redis don't read each other's sessions
+	RedisKeyPrefix string
+	// secret signing the cookie guest sessions are kept in while Redis is unavailable. Sessions fail along
+	// with Redis when empty.
+	FallbackKey string
+}
+
+// TokensConfig configures the bearer token authentication of mobile clients.
+type TokensConfig struct {
+	AccessTTL time.Duration
+	// refresh tokens are rotated on every use, the lifetime restarts with each rotation
+	RefreshTTL time.Duration
+}
+
+type JobsConfig struct {
+	Interval                 time.Duration
+	ActivationReminderWindow time.Duration
+	UnactivatedAccountTTL    time.Duration
+	VenuePaymentCutoff       time.Duration
+	// FulfillmentMaxAttempts bounds the retries of a reservation whose write failed after the payment
+	FulfillmentMaxAttempts int
+}
+
+// CampaignsConfig configures how email campaigns are sent.
+type CampaignsConfig struct {
+	// emails sent per second at most
+	RateLimit float64
+	// emails sent by a single run of the dispatcher, it must fit into the jobs interval at the rate limit
+	BatchSize int
+}
+
+// CRMConfig configures the delivery of the lifecycle events of users to the CRM.
+type CRMConfig struct {
+	// webhook the events are posted to, the events are kept undelivered when empty
+	URL string
+	// value of the Authorization header sent with the events
+	Authorization string
+	// an event is dead lettered after this many failed deliveries
+	MaxAttempts int
+	// events delivered by a single run of the dispatcher
+	BatchSize int
+}
+
+type RetentionConfig struct {
+	Interval                time.Duration
+	ExpiredTokenGracePeriod time.Duration
+	PaymentRetention        time.Duration
+	LifecycleEventRetention time.Duration
+}
+
+// InventoryAuditConfig configures the audit of the sold seats and seat locks of the upcoming showtimes.
+type InventoryAuditConfig struct {
+	Interval time.Duration
+	// showtimes starting within this period from now are audited
+	Horizon time.Duration
+}
+
+// ReconciliationConfig configures the daily reconciliation of the payments with the Stripe balance.
+type ReconciliationConfig struct {
+	Interval time.Duration
+	// the days before today which are reconciled again on every run, as Stripe transactions and webhooks
+	// of a day may arrive late
+	LookbackDays int
+}
+
+// AnalyticsConfig configures how client analytics events are sampled and written.
+type AnalyticsConfig struct {
+	// share of the sessions whose events are kept, between 0 and 1
+	SampleRate    float64
+	BufferSize    int
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+// CatalogCacheConfig is how long the public catalog responses may be cached by browsers and CDNs. A zero
+// TTL disables caching of the endpoint.
+type CatalogCacheConfig struct {
+	MoviesTTL       time.Duration
+	MovieDetailsTTL time.Duration
 }
 
 type Config struct {
-	Port             int
-	Env              string
+	Port int
+	Env  string
+	// public URL of the API, used for links served to clients outside of a request, e.g. in emails
+	BaseURL          string
 	DB               DBConfig
 	Redis            RedisConfig
 	SMTP             SMTPConfig
+	Session          SessionConfig
+	Tokens           TokensConfig
 	Stripe           StripeConfig
+	Geocoder         GeocoderConfig
+	Wallet           WalletConfig
+	Disputes         DisputesConfig
+	Jobs             JobsConfig
+	Retention        RetentionConfig
+	InventoryAudit   InventoryAuditConfig
+	Reconciliation   ReconciliationConfig
+	Campaigns        CampaignsConfig
+	CRM              CRMConfig
+	Analytics        AnalyticsConfig
+	CatalogCache     CatalogCacheConfig
+	PIIKeys          string
 	OtelCollectorUrl string
+	// showtimes can't be listed for dates further ahead than this
+	SchedulingHorizon time.Duration
+	// rules of the passwords chosen by users, served to clients to render hints
+	PasswordPolicy appvalidator.PasswordPolicy
+	// proxies in front of the API, the client address of their X-Forwarded-For and their X-Request-ID
+	// headers are trusted, the headers of other peers are ignored
+	TrustedProxies []netip.Prefix
+	// API keys of the ticket partners reading the inventory feed, mapped to the partner name
+	PartnerAPIKeys map[string]string
+	// fee of the flexible ticket per seat
+	FlexibleTicketFee decimal.Decimal
+	// how long before the showtime reservations without the flexible ticket can still be moved, 0 means
+	// they can't be moved
+	RescheduleCutoff time.Duration
+	// check the dependencies and exit instead of starting the server
+	Check bool
+	// apply the pending migrations before starting the server
+	Migrate bool
 }
 
 func loadFlags() Config {
 	var cfg Config
 
 	flag.IntVar(&cfg.Port, "port", 3000, "server port")
+	flag.StringVar(&cfg.BaseURL, "base-url", "http://localhost:3000", "Public URL of the API used in links, e.g. in emails and the sitemap")
 	flag.StringVar(&cfg.Env, "env", "dev", "Environment (dev|staging|prod)")
 
 	flag.StringVar(&cfg.DB.DSN, "db-dsn", "", "PostgreSQL DSN")
 	flag.IntVar(&cfg.DB.MaxOpenConns, "db-max-open-conns", 25, "PostgreSQL max open connections")
+	flag.IntVar(&cfg.DB.MinConns, "db-min-conns", 5, "PostgreSQL connections opened at startup and kept open while idle")
 	flag.DurationVar(&cfg.DB.MaxIdleTime, "db-max-idle-time", 15*time.Minute, "PostgreSQL max idle time for connections")
+	flag.DurationVar(&cfg.DB.SlowQueryThreshold, "db-slow-query-threshold", 500*time.Millisecond, "Log the queries taking at least this long (0 to disable)")
+	flag.DurationVar(&cfg.DB.MigrationLockTimeout, "db-migration-lock-timeout", 5*time.Minute, "How long to wait for another instance applying the migrations")
 
 	flag.StringVar(&cfg.Redis.URL, "redis-url", "", "Redis URL")
 	flag.IntVar(&cfg.Redis.MaxOpenConns, "redis-max-open-conns", 25, "Redis max open connections")
 	flag.IntVar(&cfg.Redis.MaxIdleConns, "redis-max-idle-conns", 10, "Redis max idle connections")
 	flag.DurationVar(&cfg.Redis.MaxIdleTime, "redis-max-idle-time", 2*time.Minute, "Redis max idle time for connections")
 
+	flag.DurationVar(&cfg.Session.IdleTimeout, "session-idle-timeout", 20*time.Minute, "Expire sessions after this period of inactivity, unless the user asked to be remembered")
+	flag.DurationVar(&cfg.Session.Lifetime, "session-lifetime", 12*time.Hour, "Absolute lifetime of a session")
+	flag.DurationVar(&cfg.Session.RememberMeLifetime, "session-remember-me-lifetime", 30*24*time.Hour, "Absolute lifetime of a session when the user asked to be remembered")
+	flag.DurationVar(&cfg.Session.ReauthWindow, "session-reauth-window", 15*time.Minute, "Require the password again for sensitive actions when the last login is older than this, 0 disables the check")
+	flag.BoolVar(&cfg.Session.CookieSecure, "session-cookie-secure", false, "Send the session cookie over HTTPS only, defaults to true outside of the dev environment")
+	flag.StringVar(&cfg.Session.CookieSameSite, "session-cookie-same-site", "lax", "SameSite attribute of the session cookie (lax|strict|none)")
+	flag.StringVar(&cfg.Session.CookieDomain, "session-cookie-domain", "", "Domain attribute of the session cookie, empty for the host of the API only")
+	flag.StringVar(&cfg.Session.CookieKeys, "session-cookie-keys", "", "Comma separated id:base64 keys encrypting the session token in the cookie, the first one encrypts new cookies. Enabling it logs out existing sessions")
+	flag.StringVar(&cfg.Session.RedisKeyPrefix, "session-redis-prefix", defaultSessionKeyPrefix, "Prefix of the session keys in Redis")
+	flag.StringVar(&cfg.Session.FallbackKey, "session-fallback-key", "", "Secret signing the cookie guest sessions are kept in while Redis is unavailable, the fallback is disabled when empty")
+
+	flag.DurationVar(&cfg.Tokens.AccessTTL, "access-token-ttl", 15*time.Minute, "Lifetime of the access tokens of mobile devices")
+	flag.DurationVar(&cfg.Tokens.RefreshTTL, "refresh-token-ttl", 60*24*time.Hour, "Lifetime of the refresh tokens of mobile devices")
+
 	flag.StringVar(&cfg.SMTP.Host, "smtp-host", "sandbox.smtp.mailtrap.io", "SMTP host")
 	flag.IntVar(&cfg.SMTP.Port, "smtp-port", 2525, "SMTP port")
 	flag.StringVar(&cfg.SMTP.Username, "smtp-username", "", "SMTP username")
@@ -122,9 +354,134 @@ func loadFlags() Config {
 	flag.StringVar(&cfg.Stripe.WebhookSecret, "stripe-webhook-secret", "", "Stripe webhook secret")
 	flag.StringVar(&cfg.Stripe.SuccessURL, "stripe-success-url", "https://example.com/success.html", "Stripe payment success page")
 	flag.StringVar(&cfg.Stripe.FailureURL, "stripe-failure-url", "https://example.com/failure.html", "Stripe payment failure page")
+	flag.Func("stripe-webhook-allowed-ips", `Comma separated CIDRs the webhook is accepted from, "stripe" for the addresses published by Stripe`, func(value string) error {
+		prefixes, err := parseWebhookAllowedIPs(value)
+		if err != nil {
+			return err
+		}
+
+		cfg.Stripe.WebhookAllowedIPs = prefixes
+		return nil
+	})
+	flag.IntVar(&cfg.Stripe.WebhookRateLimit, "stripe-webhook-rate-limit", 300, "Webhook requests a source may send per minute, 0 disables the limit")
+
+	flag.DurationVar(&cfg.SchedulingHorizon, "scheduling-horizon", appvalidator.DefaultSchedulingHorizon, "Reject showtime listings for dates further ahead than this")
+
+	defaultPasswordPolicy := appvalidator.DefaultPasswordPolicy()
+	flag.IntVar(&cfg.PasswordPolicy.MinLength, "password-min-length", defaultPasswordPolicy.MinLength, "Minimum length of passwords")
+	flag.IntVar(&cfg.PasswordPolicy.MaxLength, "password-max-length", defaultPasswordPolicy.MaxLength, "Maximum length of passwords, at most 72")
+	flag.BoolVar(&cfg.PasswordPolicy.RequireUpper, "password-require-upper", defaultPasswordPolicy.RequireUpper, "Require an uppercase letter in passwords")
+	flag.BoolVar(&cfg.PasswordPolicy.RequireLower, "password-require-lower", defaultPasswordPolicy.RequireLower, "Require a lowercase letter in passwords")
+	flag.BoolVar(&cfg.PasswordPolicy.RequireDigit, "password-require-digit", defaultPasswordPolicy.RequireDigit, "Require a digit in passwords")
+	flag.BoolVar(&cfg.PasswordPolicy.RequireSpecial, "password-require-special", defaultPasswordPolicy.RequireSpecial, "Require a special character in passwords")
+	flag.BoolVar(&cfg.PasswordPolicy.BanCommon, "password-ban-common", defaultPasswordPolicy.BanCommon, "Reject commonly used passwords")
+
+	flag.StringVar(&cfg.Geocoder.Provider, "geocoder", "", "Geocoding provider used for address search (nominatim|google), disabled when empty")
+	flag.StringVar(&cfg.Geocoder.URL, "geocoder-url", "", "Base URL of the geocoding provider, defaults to the public endpoint")
+	flag.StringVar(&cfg.Geocoder.APIKey, "geocoder-api-key", "", "API key of the geocoding provider")
+	flag.StringVar(&cfg.Geocoder.UserAgent, "geocoder-user-agent", "cinex-api", "User agent sent to the geocoding provider")
+	flag.DurationVar(&cfg.Geocoder.RateLimit, "geocoder-rate-limit", time.Second, "Minimum time between two requests toward the geocoding provider")
+	flag.DurationVar(&cfg.Geocoder.MaxWait, "geocoder-max-wait", 3*time.Second, "Longest time an address search waits for its turn toward the geocoding provider, 0 waits without a bound")
+	flag.DurationVar(&cfg.Geocoder.CacheTTL, "geocoder-cache-ttl", 30*24*time.Hour, "How long resolved addresses are cached")
+
+	flag.StringVar(&cfg.Disputes.FinanceEmails, "finance-emails", "", "Comma separated addresses notified about payment disputes")
+	flag.BoolVar(&cfg.Disputes.RevokeTickets, "dispute-revoke-tickets", false, "Revoke the tickets of a reservation once its payment is disputed")
+
+	flag.StringVar(&cfg.Wallet.TicketSecret, "ticket-secret", "", "Secret used to derive ticket codes, required when a wallet is configured and to accept unsigned codes at check-in")
+	flag.StringVar(&cfg.Wallet.TicketSigningKeys, "ticket-signing-keys", "", "Comma separated id:base64 Ed25519 seeds signing ticket codes, the first key signs, codes are unsigned when empty")
+	flag.StringVar(&cfg.Wallet.ApplePassTypeID, "wallet-apple-pass-type-id", "", "Apple Wallet pass type identifier, Apple passes are disabled when empty")
+	flag.StringVar(&cfg.Wallet.AppleTeamID, "wallet-apple-team-id", "", "Apple developer team identifier")
+	flag.StringVar(&cfg.Wallet.AppleCertFile, "wallet-apple-cert", "", "PEM file of the Apple pass type certificate")
+	flag.StringVar(&cfg.Wallet.AppleKeyFile, "wallet-apple-key", "", "PEM file of the Apple pass type certificate key")
+	flag.StringVar(&cfg.Wallet.AppleWWDRFile, "wallet-apple-wwdr", "", "PEM file of the Apple WWDR intermediate certificate")
+	flag.StringVar(&cfg.Wallet.GoogleIssuerID, "wallet-google-issuer-id", "", "Google Wallet issuer id, Google passes are disabled when empty")
+	flag.StringVar(&cfg.Wallet.GoogleClassSuffix, "wallet-google-class", "movie_ticket", "Suffix of the Google Wallet event ticket class")
+	flag.StringVar(&cfg.Wallet.GoogleServiceAccountFile, "wallet-google-service-account", "", "JSON key file of the Google Wallet service account")
+	flag.StringVar(&cfg.Wallet.GoogleOrigins, "wallet-google-origins", "", "Comma separated origins allowed to render the Save to Google Wallet button")
+
+	flag.DurationVar(&cfg.Jobs.Interval, "jobs-interval", time.Minute, "Interval between background job runs")
+	flag.DurationVar(&cfg.Jobs.ActivationReminderWindow, "activation-reminder-window", 3*time.Minute, "Send an activation reminder when the activation token expires within this window")
+	flag.DurationVar(&cfg.Jobs.UnactivatedAccountTTL, "unactivated-account-ttl", 24*time.Hour, "Delete accounts that are not activated within this period")
+	flag.IntVar(&cfg.Jobs.FulfillmentMaxAttempts, "fulfillment-max-attempts", 10, "Give up creating a paid reservation whose write failed after this many attempts")
+	flag.DurationVar(&cfg.Jobs.VenuePaymentCutoff, "venue-payment-cutoff", 30*time.Minute, "Cancel unpaid pay-at-venue reservations this long before the showtime starts")
+
+	flag.Float64Var(&cfg.Campaigns.RateLimit, "campaign-rate-limit", 10, "Maximum number of campaign emails sent per second")
+	flag.IntVar(&cfg.Campaigns.BatchSize, "campaign-batch-size", 500, "Number of campaign emails sent by a single dispatcher run")
+
+	flag.StringVar(&cfg.CRM.URL, "crm-url", "", "Webhook URL of the CRM the lifecycle events of users are posted to, the events are kept undelivered when empty")
+	flag.StringVar(&cfg.CRM.Authorization, "crm-authorization", "", "Authorization header sent to the CRM, e.g. \"Bearer <token>\"")
+	flag.IntVar(&cfg.CRM.MaxAttempts, "crm-max-attempts", 12, "Dead letter a lifecycle event after this many failed deliveries to the CRM")
+	flag.IntVar(&cfg.CRM.BatchSize, "crm-batch-size", 100, "Number of lifecycle events delivered to the CRM by a single dispatcher run")
+
+	flag.DurationVar(&cfg.Retention.Interval, "retention-interval", 24*time.Hour, "Interval between data retention runs")
+	flag.DurationVar(&cfg.Retention.ExpiredTokenGracePeriod, "retention-expired-token-grace", 24*time.Hour, "Purge tokens which expired longer ago than this")
+	flag.DurationVar(&cfg.Retention.PaymentRetention, "retention-payment-window", 2*365*24*time.Hour, "Anonymize settled payments older than this")
+	flag.DurationVar(&cfg.Retention.LifecycleEventRetention, "retention-lifecycle-event-window", 30*24*time.Hour, "Purge lifecycle events delivered to the CRM longer ago than this")
+
+	flag.DurationVar(&cfg.InventoryAudit.Interval, "inventory-audit-interval", 24*time.Hour, "Interval between inventory audit runs")
+	flag.DurationVar(&cfg.InventoryAudit.Horizon, "inventory-audit-horizon", 7*24*time.Hour, "Audit the showtimes starting within this period")
+
+	flag.DurationVar(&cfg.Reconciliation.Interval, "reconciliation-interval", 6*time.Hour, "Interval between reconciliation runs of the payments with Stripe")
+	flag.IntVar(&cfg.Reconciliation.LookbackDays, "reconciliation-lookback-days", 3, "Number of days before today reconciled on every run")
+
+	flag.Float64Var(&cfg.Analytics.SampleRate, "analytics-sample-rate", 1, "Share of the sessions whose analytics events are kept, between 0 and 1")
+	flag.IntVar(&cfg.Analytics.BufferSize, "analytics-buffer-size", 10000, "Maximum number of analytics events waiting to be written, further events are dropped")
+	flag.IntVar(&cfg.Analytics.BatchSize, "analytics-batch-size", 500, "Number of analytics events written at once")
+	flag.DurationVar(&cfg.Analytics.FlushInterval, "analytics-flush-interval", 5*time.Second, "Maximum time analytics events wait before being written")
+
+	flag.DurationVar(&cfg.CatalogCache.MoviesTTL, "cache-movies-ttl", 5*time.Minute, "How long the movie list may be cached by clients and CDNs, 0 disables caching")
+	flag.DurationVar(&cfg.CatalogCache.MovieDetailsTTL, "cache-movie-details-ttl", 15*time.Minute, "How long the details of a movie may be cached by clients and CDNs, 0 disables caching")
+
+	flag.StringVar(&cfg.PIIKeys, "pii-keys", "", "Comma separated id:base64 keys used to encrypt personal data at rest, the first key is the primary key")
 
 	flag.StringVar(&cfg.OtelCollectorUrl, "otel-collector-url", "", "OpenTelemetry collector URL")
 
+	flag.Func("trusted-proxies", "Comma separated CIDRs of the proxies in front of the API", func(value string) error {
+		prefixes, err := parsePrefixes(value)
+		if err != nil {
+			return err
+		}
+
+		cfg.TrustedProxies = prefixes
+		return nil
+	})
+
+	flag.Func("partner-api-keys", "Comma separated name:key pairs of the ticket partners allowed to read the inventory feed", func(value string) error {
+		keys, err := parsePartnerAPIKeys(value)
+		if err != nil {
+			return err
+		}
+
+		cfg.PartnerAPIKeys = keys
+		return nil
+	})
+
+	flag.Func("stripe-application-fee-percent", "Percentage of a checkout kept by the platform when the theater is paid out through Stripe Connect (default 0)", func(value string) error {
+		percent, err := parseFeePercent(value)
+		if err != nil {
+			return err
+		}
+
+		cfg.Stripe.ApplicationFeePercent = percent
+		return nil
+	})
+
+	cfg.FlexibleTicketFee = decimal.RequireFromString("1.50")
+	flag.Func("flexible-ticket-fee", "Fee per seat of the flexible ticket, which allows free date changes (default 1.50)", func(value string) error {
+		fee, err := decimal.NewFromString(strings.TrimSpace(value))
+		if err != nil || fee.IsNegative() {
+			return errors.New("fee must be a non-negative amount")
+		}
+
+		cfg.FlexibleTicketFee = fee.Round(2)
+		return nil
+	})
+
+	flag.DurationVar(&cfg.RescheduleCutoff, "reschedule-cutoff", domain.DefaultRescheduleCutoff, "Allow moving reservations without the flexible ticket until this long before the showtime, paying the price difference, 0 disables it")
+
+	flag.BoolVar(&cfg.Migrate, "migrate", false, "Apply the pending database migrations before starting the server")
+	flag.BoolVar(&cfg.Check, "check", false, "Check the database schema, Redis scripts, email templates and Stripe key, print a report and exit")
+
 	displayVersion := flag.Bool("version", false, "Display version and exit")
 
 	flag.Parse()
@@ -134,41 +491,200 @@ func loadFlags() Config {
 		os.Exit(0)
 	}
 
+	if !isFlagSet("session-cookie-secure") {
+		cfg.Session.CookieSecure = cfg.Env != "dev"
+	}
+
 	return cfg
 }
 
+// isFlagSet reports whether the flag was given on the command line, as opposed to having its default value.
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+
+	return set
+}
+
+// parsePartnerAPIKeys parses comma separated name:key pairs into a map from key to partner name.
+func parsePartnerAPIKeys(value string) (map[string]string, error) {
+	keys := make(map[string]string)
+
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, key, ok := strings.Cut(part, ":")
+		if !ok || name == "" || key == "" {
+			// the value may be a bare key, it's kept out of the error
+			return nil, errors.New("invalid partner API key, expected name:key")
+		}
+
+		if _, exists := keys[key]; exists {
+			return nil, fmt.Errorf("partner API key of %q is used by another partner", name)
+		}
+
+		keys[key] = name
+	}
+
+	return keys, nil
+}
+
+// parseFeePercent parses a percentage between 0 and 100.
+func parseFeePercent(value string) (decimal.Decimal, error) {
+	percent, err := decimal.NewFromString(strings.TrimSpace(value))
+	if err != nil {
+		return decimal.Zero, errors.New("invalid percentage")
+	}
+
+	if percent.IsNegative() || percent.GreaterThan(decimal.NewFromInt(100)) {
+		return decimal.Zero, errors.New("percentage must be between 0 and 100")
+	}
+
+	return percent, nil
+}
+
+// parsePrefixes parses comma separated CIDRs. Bare addresses are accepted as single host prefixes.
+func parsePrefixes(value string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		if !strings.Contains(part, "/") {
+			addr, err := netip.ParseAddr(part)
+			if err != nil {
+				return nil, err
+			}
+
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(part)
+		if err != nil {
+			return nil, err
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
 func newApp(cfg Config, logHandler slog.Handler) (*Application, error) {
 	stripe.Key = cfg.Stripe.SecretKey
 
 	logger := slog.New(logHandler)
 
-	validator := appvalidator.NewValidator()
+	err := cfg.PasswordPolicy.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	validator := appvalidator.NewValidator(cfg.SchedulingHorizon, cfg.PasswordPolicy)
+
+	// a template referring to a field its message doesn't have would only fail when the email is sent
+	err = mailer.ValidateTemplates()
+	if err != nil {
+		return nil, fmt.Errorf("invalid email templates: %w", err)
+	}
 
 	mailer := mailer.NewSMTPMailer(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.Username, cfg.SMTP.Password, cfg.SMTP.Sender)
 
-	db, err := NewDatabasePool(cfg)
+	keyring, err := envelope.ParseKeyring(cfg.PIIKeys)
 	if err != nil {
 		return nil, err
 	}
 
+	sessionCookieKeys, err := envelope.ParseKeyring(cfg.Session.CookieKeys)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session cookie keys: %w", err)
+	}
+
+	ticketKeys, err := ticketsign.ParseKeyring(cfg.Wallet.TicketSigningKeys)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ticket signing keys: %w", err)
+	}
+
+	pool, err := NewDatabasePool(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	db := NewResizablePool(pool)
+
+	// recorded through the resizable pool, so the metrics follow it to the new pool on resizes
+	if err := otelpgx.RecordStats(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to record database stats: %w", err)
+	}
+
+	recordPoolSaturation(db, logger)
+
 	redisClient, err := NewRedisClient(cfg)
 	if err != nil {
 		db.Close()
 		return nil, err
 	}
 
-	sessionManager := NewSessionManager(redisClient)
+	sessionManager, err := NewSessionManager(redisClient, cfg.Session)
+	if err != nil {
+		db.Close()
+		redisClient.Close()
+		return nil, err
+	}
 
-	userRepo := repository.NewPostgresUserRepository(db)
+	userRepo := repository.NewPostgresUserRepository(db, keyring)
 	tokenRepo := repository.NewPostgresTokenRepository(db)
 	movieRepo := repository.NewPostgresMovieRepository(db)
 	theaterRepo := repository.NewPostgresTheaterRepository(db)
 	seatRepo := repository.NewPostgresSeatRepository(db)
 	paymentRepo := repository.NewPostgresPaymentRepository(db)
 	reservationRepo := repository.NewPostgresReservationRepository(db)
+	announcementRepo := repository.NewPostgresAnnouncementRepository(db)
+	searchRepo := repository.NewPostgresSearchRepository(db)
+	disputeRepo := repository.NewPostgresDisputeRepository(db)
+	deviceSessionRepo := repository.NewPostgresDeviceSessionRepository(db)
+	analyticsEventRepo := repository.NewPostgresAnalyticsEventRepository(db)
+	webhookEventRepo := repository.NewPostgresWebhookEventRepository(db)
+	emailCampaignRepo := repository.NewPostgresEmailCampaignRepository(db)
+	lifecycleEventRepo := repository.NewPostgresLifecycleEventRepository(db)
+	blockHoldRepo := repository.NewPostgresBlockHoldRepository(db)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tenants, err := repository.NewPostgresTenantRepository(db).GetAll(ctx)
+	if err != nil {
+		db.Close()
+		redisClient.Close()
+		return nil, fmt.Errorf("failed to load tenants: %w", err)
+	}
 
 	stripeProvider := payment.NewStripePaymentProvider(cfg.Stripe.FailureURL, cfg.Stripe.SuccessURL)
 
+	geocoder, err := NewGeocoder(cfg, redisClient, logger)
+	if err != nil {
+		db.Close()
+		redisClient.Close()
+		return nil, err
+	}
+
+	walletPasses, err := NewWalletPassIssuer(cfg)
+	if err != nil {
+		db.Close()
+		redisClient.Close()
+		return nil, err
+	}
+
 	app := NewApp(
 		cfg,
 		logger,
@@ -184,16 +700,32 @@ func newApp(cfg Config, logHandler slog.Handler) (*Application, error) {
 		seatRepo,
 		paymentRepo,
 		reservationRepo,
+		announcementRepo,
+		searchRepo,
+		disputeRepo,
+		deviceSessionRepo,
+		analyticsEventRepo,
+		webhookEventRepo,
+		emailCampaignRepo,
+		lifecycleEventRepo,
+		blockHoldRepo,
+		tenants,
 		stripeProvider,
+		geocoder,
+		walletPasses,
+		NewCRMTarget(cfg),
 	)
 
+	app.sessionCookieKeys = sessionCookieKeys
+	app.ticketKeys = ticketKeys
+
 	return app, nil
 }
 
 func NewApp(
 	cfg Config,
 	logger *slog.Logger,
-	db *pgxpool.Pool,
+	db *ResizablePool,
 	redisClient redis.UniversalClient,
 	validator *validator.Validate,
 	mailer mailer.Mailer,
@@ -205,33 +737,79 @@ func NewApp(
 	seatRepo domain.SeatRepository,
 	paymentRepo domain.PaymentRepository,
 	reservationRepo domain.ReservationRepository,
+	announcementRepo domain.AnnouncementRepository,
+	searchRepo domain.SearchRepository,
+	disputeRepo domain.DisputeRepository,
+	deviceSessionRepo domain.DeviceSessionRepository,
+	analyticsEventRepo domain.AnalyticsEventRepository,
+	webhookEventRepo domain.WebhookEventRepository,
+	emailCampaignRepo domain.EmailCampaignRepository,
+	lifecycleEventRepo domain.LifecycleEventRepository,
+	blockHoldRepo domain.BlockHoldRepository,
+	tenants []domain.Tenant,
 	paymentProvider domain.PaymentProvider,
+	geocoder domain.Geocoder,
+	walletPasses domain.WalletPassIssuer,
+	crm domain.LifecycleEventTarget,
 ) *Application {
 
 	return &Application{
-		config:          cfg,
-		logger:          logger,
-		db:              db,
-		redis:           redisClient,
-		validator:       validator,
-		mailer:          mailer,
-		sessionManager:  sessionManager,
-		userRepo:        userRepo,
-		tokenRepo:       tokenRepo,
-		movieRepo:       movieRepo,
-		theaterRepo:     theaterRepo,
-		seatRepo:        seatRepo,
-		paymentRepo:     paymentRepo,
-		reservationRepo: reservationRepo,
+		config:             cfg,
+		logger:             logger,
+		db:                 db,
+		redis:              redisClient,
+		validator:          validator,
+		mailer:             mailer,
+		sessionManager:     sessionManager,
+		userRepo:           userRepo,
+		tokenRepo:          tokenRepo,
+		movieRepo:          movieRepo,
+		theaterRepo:        theaterRepo,
+		seatRepo:           seatRepo,
+		paymentRepo:        paymentRepo,
+		reservationRepo:    reservationRepo,
+		announcementRepo:   announcementRepo,
+		searchRepo:         searchRepo,
+		disputeRepo:        disputeRepo,
+		deviceSessionRepo:  deviceSessionRepo,
+		webhookEventRepo:   webhookEventRepo,
+		emailCampaignRepo:  emailCampaignRepo,
+		lifecycleEventRepo: lifecycleEventRepo,
+		blockHoldRepo:      blockHoldRepo,
+		tenants:            tenants,
+		analytics: analytics.NewBuffer(
+			analyticsEventRepo,
+			logger,
+			cfg.Analytics.BufferSize,
+			cfg.Analytics.BatchSize,
+			cfg.Analytics.FlushInterval,
+		),
+		fulfillments:    fulfillment.NewRedisQueue(redisClient),
 		paymentProvider: paymentProvider,
+		geocoder:        geocoder,
+		walletPasses:    walletPasses,
+		crm:             crm,
+		jobRuns:         scheduler.NewRedisRunStore(redisClient),
+		webhookMetrics:  newWebhookMetrics(logger),
 	}
 }
 
 func Run() error {
 	cfg := loadFlags()
 
+	if cfg.Check {
+		return runSelfCheck(cfg, os.Stdout)
+	}
+
 	jsonHandler := slog.NewJSONHandler(os.Stdout, nil)
 
+	if cfg.Migrate {
+		err := migrateDatabase(cfg, slog.New(jsonHandler))
+		if err != nil {
+			return err
+		}
+	}
+
 	app, err := newApp(cfg, jsonHandler)
 	if err != nil {
 		return err
@@ -264,14 +842,145 @@ func Run() error {
 	return app.run()
 }
 
-func NewSessionManager(client *redis.Client) *scs.SessionManager {
+// defaultSessionKeyPrefix is the prefix scs uses for the sessions in Redis.
+const defaultSessionKeyPrefix = "scs:session:"
+
+func NewSessionManager(client *redis.Client, cfg SessionConfig) (*scs.SessionManager, error) {
+	sameSite, err := parseSameSite(cfg.CookieSameSite)
+	if err != nil {
+		return nil, err
+	}
+
+	// browsers drop cookies with SameSite=None which aren't secure
+	if sameSite == http.SameSiteNoneMode && !cfg.CookieSecure {
+		return nil, errors.New("a session cookie with SameSite=None must be secure")
+	}
+
 	sessionManager := scs.New()
 
-	sessionManager.Store = goredisstore.New(client)
-	sessionManager.IdleTimeout = 20 * time.Minute
+	prefix := cfg.RedisKeyPrefix
+	if prefix == "" {
+		prefix = defaultSessionKeyPrefix
+	}
+
+	store := goredisstore.NewWithPrefix(client, prefix)
+	if cfg.FallbackKey != "" {
+		sessionManager.Store = newFailoverSessionStore(store, sessionManager.Codec, []byte(cfg.FallbackKey))
+	} else {
+		sessionManager.Store = store
+	}
+
+	// the idle timeout is enforced by the enforceIdleTimeout middleware, remembered sessions are exempt
+	sessionManager.Lifetime = cfg.Lifetime
 	sessionManager.Cookie.Name = "session_id"
+	sessionManager.Cookie.Secure = cfg.CookieSecure
+	sessionManager.Cookie.SameSite = sameSite
+	sessionManager.Cookie.Domain = cfg.CookieDomain
+	// only remembered logins outlive the browser session
+	sessionManager.Cookie.Persist = false
+
+	return sessionManager, nil
+}
+
+func parseSameSite(value string) (http.SameSite, error) {
+	switch value {
+	case "", "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	default:
+		return 0, fmt.Errorf("unknown SameSite mode %q", value)
+	}
+}
+
+// NewGeocoder creates the configured geocoding provider with a Redis cache in front of it. It returns
+// nil when no provider is configured, which disables address search.
+func NewGeocoder(cfg Config, redisClient redis.UniversalClient, logger *slog.Logger) (domain.Geocoder, error) {
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	var geocoder domain.Geocoder
+
+	switch cfg.Geocoder.Provider {
+	case "":
+		return nil, nil
+	case "nominatim":
+		geocoder = geocoding.NewNominatimGeocoder(client, cfg.Geocoder.URL, cfg.Geocoder.UserAgent,
+			cfg.Geocoder.RateLimit, cfg.Geocoder.MaxWait)
+	case "google":
+		if cfg.Geocoder.APIKey == "" {
+			return nil, errors.New("the google geocoder requires an API key")
+		}
+		geocoder = geocoding.NewGoogleGeocoder(client, cfg.Geocoder.URL, cfg.Geocoder.APIKey,
+			cfg.Geocoder.RateLimit, cfg.Geocoder.MaxWait)
+	default:
+		return nil, fmt.Errorf("unknown geocoder %q", cfg.Geocoder.Provider)
+	}
+
+	return geocoding.NewCachedGeocoder(geocoder, redisClient, cfg.Geocoder.CacheTTL, logger), nil
+}
+
+// NewCRMTarget creates the client of the CRM webhook. It returns nil when no webhook is configured, the
+// lifecycle events are kept until one is.
+func NewCRMTarget(cfg Config) domain.LifecycleEventTarget {
+	if cfg.CRM.URL == "" {
+		return nil
+	}
+
+	return crm.NewClient(&http.Client{Timeout: 10 * time.Second}, cfg.CRM.URL, cfg.CRM.Authorization)
+}
+
+func NewWalletPassIssuer(cfg Config) (domain.WalletPassIssuer, error) {
+	var apple *walletpass.AppleSigner
+	var google *walletpass.GoogleSigner
+
+	if cfg.Wallet.ApplePassTypeID != "" {
+		files := make([][]byte, 3)
+		for i, name := range []string{cfg.Wallet.AppleCertFile, cfg.Wallet.AppleKeyFile, cfg.Wallet.AppleWWDRFile} {
+			data, err := os.ReadFile(name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read Apple Wallet credentials: %w", err)
+			}
+			files[i] = data
+		}
+
+		var err error
+		apple, err = walletpass.NewAppleSigner(
+			cfg.Wallet.ApplePassTypeID,
+			cfg.Wallet.AppleTeamID,
+			"CineX",
+			files[0],
+			files[1],
+			files[2],
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Wallet.GoogleIssuerID != "" {
+		data, err := os.ReadFile(cfg.Wallet.GoogleServiceAccountFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Google Wallet service account: %w", err)
+		}
+
+		var origins []string
+		if cfg.Wallet.GoogleOrigins != "" {
+			origins = strings.Split(cfg.Wallet.GoogleOrigins, ",")
+		}
+
+		google, err = walletpass.NewGoogleSigner(cfg.Wallet.GoogleIssuerID, cfg.Wallet.GoogleClassSuffix, data, origins)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if (apple != nil || google != nil) && cfg.Wallet.TicketSecret == "" {
+		return nil, errors.New("a ticket secret is required when wallet passes are enabled")
+	}
 
-	return sessionManager
+	return walletpass.NewIssuer(apple, google), nil
 }
 
 func NewRedisClient(cfg Config) (*redis.Client, error) {
@@ -297,7 +1006,7 @@ func NewRedisClient(cfg Config) (*redis.Client, error) {
 	return rdb, nil
 }
 
-func NewDatabasePool(cfg Config) (*pgxpool.Pool, error) {
+func NewDatabasePool(cfg Config, logger *slog.Logger) (*pgxpool.Pool, error) {
 	config, err := pgxpool.ParseConfig(cfg.DB.DSN)
 	if err != nil {
 		return nil, err
@@ -305,17 +1014,17 @@ func NewDatabasePool(cfg Config) (*pgxpool.Pool, error) {
 
 	config.MaxConnIdleTime = cfg.DB.MaxIdleTime
 	config.MaxConns = int32(cfg.DB.MaxOpenConns)
-	config.ConnConfig.Tracer = otelpgx.NewTracer()
+	config.MinConns = int32(min(cfg.DB.MinConns, cfg.DB.MaxOpenConns))
+	config.ConnConfig.Tracer = multitracer.New(
+		otelpgx.NewTracer(),
+		repository.NewQueryTracer(logger, cfg.DB.SlowQueryThreshold),
+	)
 
 	db, err := pgxpool.NewWithConfig(context.Background(), config)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := otelpgx.RecordStats(db); err != nil {
-		return nil, fmt.Errorf("unable to record database stats: %w", err)
-	}
-
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
@@ -325,6 +1034,15 @@ func NewDatabasePool(cfg Config) (*pgxpool.Pool, error) {
 		return nil, err
 	}
 
+	// the pool opens its minimum connections in the background, they are waited for so the first requests
+	// don't pay for them. The pool fills up on demand if it fails, so it doesn't stop the startup.
+	warmUpCtx, warmUpCancel := context.WithTimeout(context.Background(), poolWarmUpTimeout)
+	defer warmUpCancel()
+
+	if err := warmUpPool(warmUpCtx, db, int(config.MinConns)); err != nil {
+		logger.Warn("database pool not warmed up", "error", err)
+	}
+
 	return db, nil
 }
 
@@ -338,6 +1056,27 @@ func (app *Application) run() error {
 		ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelDebug),
 	}
 
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+	defer cancelJobs()
+
+	jobScheduler := scheduler.New(app.logger, scheduler.NewRedisLocker(app.redis), app.jobRuns)
+	for _, job := range app.backgroundJobs() {
+		jobScheduler.Add(job)
+	}
+	jobScheduler.Start(jobsCtx)
+
+	go app.listenCatalogChanges(jobsCtx)
+
+	// the analytics buffer is stopped after the server, so events of in-flight requests are still written
+	analyticsCtx, stopAnalytics := context.WithCancel(context.Background())
+	defer stopAnalytics()
+
+	analyticsDone := make(chan struct{})
+	go func() {
+		app.analytics.Run(analyticsCtx)
+		close(analyticsDone)
+	}()
+
 	shutdownError := make(chan error)
 
 	go func() {
@@ -350,7 +1089,16 @@ func (app *Application) run() error {
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
+		app.logger.Info("stopping background jobs")
+		cancelJobs()
+		jobScheduler.Wait()
+
 		err := srv.Shutdown(ctx)
+
+		app.logger.Info("writing remaining analytics events")
+		stopAnalytics()
+		<-analyticsDone
+
 		if err != nil {
 			shutdownError <- err
 		}
@@ -376,32 +1124,150 @@ func (app *Application) run() error {
 }
 
 func (app *Application) Routes() http.Handler {
-	r := chi.NewRouter()
+	return app.routes().router
+}
+
+// routes registers every route of the API with its access policy.
+func (app *Application) routes() *policyRouter {
+	mux := chi.NewRouter()
+
+	mux.Use(app.requestID)
+	mux.Use(app.keepPeerAddr)
+	mux.Use(app.realIP)
+	mux.Use(app.recoverPanic)
+	mux.Use(otelchi.Middleware("movie-reservation-api", otelchi.WithChiRoutes(mux)))
+	mux.Use(app.loadAndSaveSession)
+	mux.Use(app.enforceIdleTimeout)
+	mux.Use(app.ensureGuestUserSession)
+	mux.Use(app.loggingMiddleware)
+	mux.Use(app.resolveTenant)
+	mux.NotFound(app.notFoundResponse)
+
+	public := app.publicAccess()
+	authenticated := app.authenticatedAccess()
+	recentlyAuthenticated := app.recentlyAuthenticatedAccess()
+	admin := app.roleAccess(domain.RoleAdmin)
+	partner := app.partnerAccess()
+
+	r := newPolicyRouter(mux)
+
+	// the generated wrappers parse the parameters of the routes which need no handwritten parsing
+	gen := &api.ServerInterfaceWrapper{
+		Handler: app,
+		ErrorHandlerFunc: func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		},
+	}
+
+	r.Get("/healthcheck", public, gen.GetHealth)
+	r.Get("/openapi.json", public, gen.GetOpenApiSpec)
+	if app.config.Env != "prod" {
+		r.Get("/docs", public, app.serveApiDocs)
+	}
+	r.Get("/branding", public, gen.GetBranding)
+	r.Get("/sitemap.xml", public, gen.GetSitemap)
+	r.Get("/feeds/showtimes.json", public, gen.GetShowtimesFeed)
+	r.Get("/search/suggest", public, gen.GetSearchSuggestions)
+	r.Get("/shares/{token}", public, gen.GetSharedScreening)
+	r.Post("/events", public, gen.RecordEvents)
+
+	r.Get("/movies", public, gen.GetMovies)
+	r.Get("/movies/{id}", public, gen.ShowMovieDetails)
+	r.Get("/movies/{id}/next-showtime", public, gen.GetMovieNextShowtime)
+	r.Get("/movies/{id}/showtimes", public, gen.GetMovieShowtimes)
+	r.Get("/theaters/{theater_id}/now-playing-board", public, gen.GetNowPlayingBoard)
+
+	r.Get("/showtimes/{showtime_id}/seat-map", public, gen.GetSeatMapByShowtime)
+	r.Get("/showtimes/{showtime_id}/seat-map.svg", public, gen.GetSeatMapSvg)
+	r.Get("/showtimes/{showtime_id}/seat-map/changes", public, gen.GetSeatMapChanges)
+	r.Post("/showtimes/{showtime_id}/price-quote", public, gen.QuoteShowtimePrice)
+
+	// carts belong to the session, guests included
+	r.Post("/showtimes/{showtime_id}/cart", public, gen.CreateCartHandler)
+	r.Delete("/showtimes/{showtime_id}/cart", public, gen.DeleteCartHandler)
+	r.Get("/cart/price-breakdown", public, gen.GetCartPriceBreakdown)
+
+	r.Post("/users", public, gen.RegisterUser)
+	r.Post("/users/availability", public, gen.CheckEmailAvailability)
+	r.Put("/users/activation", public, gen.ActivateUser)
+	r.Put("/users/pending-changes", public, gen.VerifyPendingChanges)
+	r.Post("/users/password-reset", public, gen.RequestPasswordReset)
+	r.Put("/users/password-reset", public, gen.ResetPassword)
+	r.Get("/auth/password-policy", public, gen.GetPasswordPolicy)
+
+	r.Post("/sessions", public, gen.Login)
+	r.Delete("/sessions", public, gen.Logout)
+	r.Post("/sessions/magic-link", public, gen.RequestMagicLink)
+	r.Put("/sessions/magic-link", public, gen.CompleteMagicLinkLogin)
+
+	// device tokens are exchanged for credentials in the body
+	r.Post("/tokens", public, gen.CreateDeviceTokens)
+	r.Post("/tokens/refresh", public, gen.RefreshDeviceTokens)
+	r.Post("/tokens/revoke", public, gen.RevokeDeviceTokens)
+
+	// the links in campaign emails carry a signed token of the recipient
+	r.Get("/email-campaigns/open", public, gen.TrackEmailCampaignOpen)
+	r.Get("/email-campaigns/unsubscribe", public, gen.UnsubscribeFromEmailCampaigns)
+
+	r.Route("/users/me", func(r *policyRouter) {
+		r.Get("/", authenticated, app.GetCurrentUser)
+		r.Patch("/", authenticated, app.UpdateUser)
+	})
 
-	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
-	r.Use(app.recoverPanic)
-	r.Use(otelchi.Middleware("movie-reservation-api", otelchi.WithChiRoutes(r)))
-	r.Use(app.sessionManager.LoadAndSave)
-	r.Use(app.ensureGuestUserSession)
-	r.Use(app.loggingMiddleware)
+	r.Route("/users/me/preferences", func(r *policyRouter) {
+		r.Patch("/", authenticated, app.UpdateUserPreferences)
+	})
 
-	h := api.HandlerFromMux(app, chi.NewRouter())
+	r.Route("/users/me/notifications", func(r *policyRouter) {
+		r.Get("/", authenticated, func(w http.ResponseWriter, r *http.Request) {
+			params := api.GetNotificationsOfUserParams{}
 
-	r.Mount("/", h)
+			if page := r.URL.Query().Get("page"); page != "" {
+				if pageNum, err := strconv.Atoi(page); err == nil {
+					params.Page = &pageNum
+				}
+			}
 
-	r.With(app.requireAuthentication).Route("/users/me", func(r chi.Router) {
-		r.Get("/", app.GetCurrentUser)
-		r.Patch("/", app.UpdateUser)
+			if pageSize := r.URL.Query().Get("pageSize"); pageSize != "" {
+				if pageSizeNum, err := strconv.Atoi(pageSize); err == nil {
+					params.PageSize = &pageSizeNum
+				}
+			}
+			app.GetNotificationsOfUser(w, r, params)
+		})
+
+		r.Post("/{notificationId}/read", authenticated, func(w http.ResponseWriter, r *http.Request) {
+			notificationId, err := strconv.Atoi(chi.URLParam(r, "notificationId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid notification ID"))
+				return
+			}
+			app.MarkNotificationRead(w, r, notificationId)
+		})
+	})
+
+	r.Post("/sessions/reauthentication", authenticated, app.Reauthenticate)
+
+	r.Route("/users/me/calendar-feed", func(r *policyRouter) {
+		r.Post("/", authenticated, app.RotateCalendarFeed)
+		r.Delete("/", authenticated, app.DisableCalendarFeed)
+	})
+
+	// calendar apps fetching the feed are authenticated by the token of its URL instead of a session
+	r.Get("/users/me/reservations/feed.ics", public, gen.GetReservationsCalendarFeed)
+
+	r.Route("/users/me/deletion-request", func(r *policyRouter) {
+		r.Post("/", recentlyAuthenticated, app.InitiateUserDeletion)
+		r.Put("/", recentlyAuthenticated, app.CompleteUserDeletion)
 	})
 
-	r.With(app.requireAuthentication).Route("/users/me/deletion-request", func(r chi.Router) {
-		r.Post("/", app.InitiateUserDeletion)
-		r.Put("/", app.CompleteUserDeletion)
+	r.Route("/users/me/email", func(r *policyRouter) {
+		r.Patch("/", recentlyAuthenticated, app.RequestEmailChange)
+		r.Put("/confirm", authenticated, app.ConfirmEmailChange)
 	})
 
-	r.With(app.requireAuthentication).Route("/users/me/reservations", func(r chi.Router) {
-		r.Get("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	r.Route("/users/me/reservations", func(r *policyRouter) {
+		r.Get("/", authenticated, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			params := api.GetReservationsOfUserHandlerParams{}
 
 			if page := r.URL.Query().Get("page"); page != "" {
@@ -419,9 +1285,28 @@ func (app *Application) Routes() http.Handler {
 		}))
 	})
 
+	r.Route("/users/me/payments", func(r *policyRouter) {
+		r.Get("/", authenticated, func(w http.ResponseWriter, r *http.Request) {
+			params := api.GetPaymentsOfUserParams{}
+
+			if page := r.URL.Query().Get("page"); page != "" {
+				if pageNum, err := strconv.Atoi(page); err == nil {
+					params.Page = &pageNum
+				}
+			}
+
+			if pageSize := r.URL.Query().Get("pageSize"); pageSize != "" {
+				if pageSizeNum, err := strconv.Atoi(pageSize); err == nil {
+					params.PageSize = &pageSizeNum
+				}
+			}
+			app.GetPaymentsOfUser(w, r, params)
+		})
+	})
+
 	// TODO: Search for a better way to handle these middlewares
-	r.With(app.requireAuthentication).Route("/users/me/reservations/{reservationId}", func(r chi.Router) {
-		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+	r.Route("/users/me/reservations/{reservationId}", func(r *policyRouter) {
+		r.Get("/", authenticated, func(w http.ResponseWriter, r *http.Request) {
 			reservationIdStr := chi.URLParam(r, "reservationId")
 			reservationId, err := strconv.Atoi(reservationIdStr)
 			if err != nil {
@@ -430,17 +1315,525 @@ func (app *Application) Routes() http.Handler {
 			}
 			app.GetUserReservationById(w, r, reservationId)
 		})
+
+		r.Post("/reschedule", authenticated, func(w http.ResponseWriter, r *http.Request) {
+			reservationId, err := strconv.Atoi(chi.URLParam(r, "reservationId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid reservation ID"))
+				return
+			}
+			app.RescheduleReservation(w, r, reservationId)
+		})
+
+		r.Delete("/seats", authenticated, func(w http.ResponseWriter, r *http.Request) {
+			reservationId, err := strconv.Atoi(chi.URLParam(r, "reservationId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid reservation ID"))
+				return
+			}
+			app.CancelReservationSeats(w, r, reservationId)
+		})
+
+		r.Get("/reschedule-options", authenticated, func(w http.ResponseWriter, r *http.Request) {
+			reservationId, err := strconv.Atoi(chi.URLParam(r, "reservationId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid reservation ID"))
+				return
+			}
+			app.GetRescheduleOptions(w, r, reservationId)
+		})
+
+		r.Get("/calendar.ics", authenticated, func(w http.ResponseWriter, r *http.Request) {
+			reservationId, err := strconv.Atoi(chi.URLParam(r, "reservationId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid reservation ID"))
+				return
+			}
+			app.GetUserReservationCalendar(w, r, reservationId)
+		})
+
+		r.Post("/share", authenticated, func(w http.ResponseWriter, r *http.Request) {
+			reservationId, err := strconv.Atoi(chi.URLParam(r, "reservationId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid reservation ID"))
+				return
+			}
+			app.ShareReservation(w, r, reservationId)
+		})
+
+		r.Delete("/share", authenticated, func(w http.ResponseWriter, r *http.Request) {
+			reservationId, err := strconv.Atoi(chi.URLParam(r, "reservationId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid reservation ID"))
+				return
+			}
+			app.RevokeReservationShare(w, r, reservationId)
+		})
+
+		r.Get("/wallet-pass", authenticated, func(w http.ResponseWriter, r *http.Request) {
+			reservationId, err := strconv.Atoi(chi.URLParam(r, "reservationId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid reservation ID"))
+				return
+			}
+
+			params := api.GetUserReservationWalletPassParams{}
+			if format := r.URL.Query().Get("format"); format != "" {
+				walletFormat := api.GetUserReservationWalletPassParamsFormat(format)
+				params.Format = &walletFormat
+			}
+
+			app.GetUserReservationWalletPass(w, r, reservationId, params)
+		})
+	})
+
+	r.Route("/checkout/session", func(r *policyRouter) {
+		r.Post("/", authenticated, app.CreateCheckoutSessionHandler)
+	})
+
+	// seat holds are owned by the authenticated kiosk or staff account, the theater is checked by the handler
+	r.Route("/showtimes/{showtimeId}/holds", func(r *policyRouter) {
+		r.Post("/", authenticated, func(w http.ResponseWriter, r *http.Request) {
+			showtimeId, err := strconv.Atoi(chi.URLParam(r, "showtimeId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid showtime ID"))
+				return
+			}
+			app.CreateSeatHold(w, r, showtimeId)
+		})
+
+		r.Delete("/{holdReference}", authenticated, func(w http.ResponseWriter, r *http.Request) {
+			showtimeId, err := strconv.Atoi(chi.URLParam(r, "showtimeId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid showtime ID"))
+				return
+			}
+			app.DeleteSeatHold(w, r, showtimeId, chi.URLParam(r, "holdReference"))
+		})
 	})
 
-	r.With(app.requireAuthentication).Route("/checkout/session", func(r chi.Router) {
-		r.Post("/", app.CreateCheckoutSessionHandler)
+	// theater staff export the manifest too, access is checked against the theater of the showtime
+	r.Get("/admin/showtimes/{showtimeId}/manifest", authenticated, func(w http.ResponseWriter, r *http.Request) {
+		showtimeId, err := strconv.Atoi(chi.URLParam(r, "showtimeId"))
+		if err != nil {
+			app.badRequestResponse(w, r, fmt.Errorf("invalid showtime ID"))
+			return
+		}
+		app.GetShowtimeManifest(w, r, showtimeId)
 	})
 
-	r.Route("/webhook", func(r chi.Router) {
-		r.Post("/", app.StripeWebhookHandler)
+	// box-office staff book for the showtimes of their theater
+	r.Post("/admin/showtimes/{showtimeId}/phone-bookings", authenticated, func(w http.ResponseWriter, r *http.Request) {
+		showtimeId, err := strconv.Atoi(chi.URLParam(r, "showtimeId"))
+		if err != nil {
+			app.badRequestResponse(w, r, fmt.Errorf("invalid showtime ID"))
+			return
+		}
+		app.CreatePhoneBooking(w, r, showtimeId)
 	})
 
-	r.NotFound(app.notFoundResponse)
+	// staff give away tickets for the showtimes of their theater, access is checked against the showtime
+	r.Post("/admin/reservations", authenticated, app.CreateCompReservation)
+
+	// staff check in the tickets of their theater at the door, access is checked against the showtime
+	r.Post("/admin/check-ins", authenticated, app.CheckInTicket)
+
+	r.Route("/admin", func(r *policyRouter) {
+		// scanners verify signed ticket codes offline with these keys, they're provisioned by admins
+		r.Get("/ticket-keys", admin, app.GetTicketKeys)
+
+		r.Get("/showtimes/{showtimeId}/occupancy/stream", admin, func(w http.ResponseWriter, r *http.Request) {
+			showtimeId, err := strconv.Atoi(chi.URLParam(r, "showtimeId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid showtime ID"))
+				return
+			}
+			app.StreamShowtimeOccupancy(w, r, showtimeId)
+		})
+
+		r.Get("/showtimes/{showtimeId}/check-in-list", admin, func(w http.ResponseWriter, r *http.Request) {
+			showtimeId, err := strconv.Atoi(chi.URLParam(r, "showtimeId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid showtime ID"))
+				return
+			}
+			app.GetShowtimeCheckInList(w, r, showtimeId)
+		})
+
+		r.Post("/showtimes/{showtimeId}/reservations/lookup", admin, func(w http.ResponseWriter, r *http.Request) {
+			showtimeId, err := strconv.Atoi(chi.URLParam(r, "showtimeId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid showtime ID"))
+				return
+			}
+			app.LookupShowtimeReservations(w, r, showtimeId)
+		})
+
+		r.Get("/halls/{hallId}/seat-heatmap", admin, func(w http.ResponseWriter, r *http.Request) {
+			hallId, err := strconv.Atoi(chi.URLParam(r, "hallId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid hall ID"))
+				return
+			}
+
+			params := api.GetHallSeatHeatmapParams{
+				From: r.URL.Query().Get("from"),
+				To:   r.URL.Query().Get("to"),
+			}
+
+			if channel := r.URL.Query().Get("channel"); channel != "" {
+				salesChannel := api.SalesChannel(channel)
+				params.Channel = &salesChannel
+			}
+
+			app.GetHallSeatHeatmap(w, r, hallId, params)
+		})
+
+		r.Post("/showtimes/price-adjustment", admin, app.AdjustShowtimePrices)
+
+		r.Put("/showtimes/{showtimeId}/format", admin, func(w http.ResponseWriter, r *http.Request) {
+			showtimeId, err := strconv.Atoi(chi.URLParam(r, "showtimeId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid showtime ID"))
+				return
+			}
+			app.UpdateShowtimeFormat(w, r, showtimeId)
+		})
+
+		r.Put("/showtimes/{showtimeId}/sales-window", admin, func(w http.ResponseWriter, r *http.Request) {
+			showtimeId, err := strconv.Atoi(chi.URLParam(r, "showtimeId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid showtime ID"))
+				return
+			}
+			app.UpdateShowtimeSalesWindow(w, r, showtimeId)
+		})
+
+		r.Put("/showtimes/{showtimeId}/pre-show", admin, func(w http.ResponseWriter, r *http.Request) {
+			showtimeId, err := strconv.Atoi(chi.URLParam(r, "showtimeId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid showtime ID"))
+				return
+			}
+			app.UpdateShowtimePreShow(w, r, showtimeId)
+		})
+
+		r.Get("/theaters/{theaterId}/attendance", admin, func(w http.ResponseWriter, r *http.Request) {
+			theaterId, err := strconv.Atoi(chi.URLParam(r, "theaterId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid theater ID"))
+				return
+			}
+
+			params := api.GetTheaterAttendanceParams{
+				From: r.URL.Query().Get("from"),
+				To:   r.URL.Query().Get("to"),
+			}
+
+			app.GetTheaterAttendance(w, r, theaterId, params)
+		})
+
+		r.Put("/theaters/{theaterId}/check-in-policy", admin, func(w http.ResponseWriter, r *http.Request) {
+			theaterId, err := strconv.Atoi(chi.URLParam(r, "theaterId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid theater ID"))
+				return
+			}
+			app.UpdateTheaterCheckInPolicy(w, r, theaterId)
+		})
+
+		r.Put("/theaters/{theaterId}/pre-show", admin, func(w http.ResponseWriter, r *http.Request) {
+			theaterId, err := strconv.Atoi(chi.URLParam(r, "theaterId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid theater ID"))
+				return
+			}
+			app.UpdateTheaterPreShow(w, r, theaterId)
+		})
+
+		r.Put("/theaters/{theaterId}/payout-account", admin, func(w http.ResponseWriter, r *http.Request) {
+			theaterId, err := strconv.Atoi(chi.URLParam(r, "theaterId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid theater ID"))
+				return
+			}
+			app.UpdateTheaterPayoutAccount(w, r, theaterId)
+		})
+
+		r.Get("/screening-formats", admin, app.GetScreeningFormats)
+
+		r.Put("/screening-formats/{format}", admin, func(w http.ResponseWriter, r *http.Request) {
+			app.UpdateScreeningFormat(w, r, api.ScreeningFormat(chi.URLParam(r, "format")))
+		})
+
+		r.Route("/halls/{hallId}/seat-prices", func(r *policyRouter) {
+			r.Get("/", admin, func(w http.ResponseWriter, r *http.Request) {
+				hallId, err := strconv.Atoi(chi.URLParam(r, "hallId"))
+				if err != nil {
+					app.badRequestResponse(w, r, fmt.Errorf("invalid hall ID"))
+					return
+				}
+				app.GetSeatPriceVersions(w, r, hallId)
+			})
+
+			r.Post("/", admin, func(w http.ResponseWriter, r *http.Request) {
+				hallId, err := strconv.Atoi(chi.URLParam(r, "hallId"))
+				if err != nil {
+					app.badRequestResponse(w, r, fmt.Errorf("invalid hall ID"))
+					return
+				}
+				app.CreateSeatPriceVersion(w, r, hallId)
+			})
+
+			r.Get("/sales", admin, func(w http.ResponseWriter, r *http.Request) {
+				hallId, err := strconv.Atoi(chi.URLParam(r, "hallId"))
+				if err != nil {
+					app.badRequestResponse(w, r, fmt.Errorf("invalid hall ID"))
+					return
+				}
+
+				params := api.GetSeatPriceSalesParams{}
+				if channel := r.URL.Query().Get("channel"); channel != "" {
+					salesChannel := api.SalesChannel(channel)
+					params.Channel = &salesChannel
+				}
+
+				app.GetSeatPriceSales(w, r, hallId, params)
+			})
+		})
+
+		r.Route("/hall-templates", func(r *policyRouter) {
+			r.Get("/", admin, app.GetHallTemplates)
+			r.Post("/", admin, app.CreateHallTemplate)
+
+			r.Get("/{templateId}", admin, func(w http.ResponseWriter, r *http.Request) {
+				templateId, err := strconv.Atoi(chi.URLParam(r, "templateId"))
+				if err != nil {
+					app.badRequestResponse(w, r, fmt.Errorf("invalid template ID"))
+					return
+				}
+				app.GetHallTemplate(w, r, templateId)
+			})
+
+			r.Put("/{templateId}", admin, func(w http.ResponseWriter, r *http.Request) {
+				templateId, err := strconv.Atoi(chi.URLParam(r, "templateId"))
+				if err != nil {
+					app.badRequestResponse(w, r, fmt.Errorf("invalid template ID"))
+					return
+				}
+				app.UpdateHallTemplate(w, r, templateId)
+			})
+
+			r.Delete("/{templateId}", admin, func(w http.ResponseWriter, r *http.Request) {
+				templateId, err := strconv.Atoi(chi.URLParam(r, "templateId"))
+				if err != nil {
+					app.badRequestResponse(w, r, fmt.Errorf("invalid template ID"))
+					return
+				}
+				app.DeleteHallTemplate(w, r, templateId)
+			})
+		})
+
+		r.Post("/import/theaters", admin, func(w http.ResponseWriter, r *http.Request) {
+			params := api.ImportTheatersParams{}
+
+			if dryRun := r.URL.Query().Get("dryRun"); dryRun != "" {
+				enabled, err := strconv.ParseBool(dryRun)
+				if err != nil {
+					app.badRequestResponse(w, r, fmt.Errorf("invalid dryRun parameter"))
+					return
+				}
+				params.DryRun = &enabled
+			}
+
+			app.ImportTheaters(w, r, params)
+		})
+
+		r.Post("/theaters/{theaterId}/halls", admin, func(w http.ResponseWriter, r *http.Request) {
+			theaterId, err := strconv.Atoi(chi.URLParam(r, "theaterId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid theater ID"))
+				return
+			}
+			app.CreateHallFromTemplate(w, r, theaterId)
+		})
+
+		r.Post("/movies", admin, func(w http.ResponseWriter, r *http.Request) {
+			params := api.CreateMovieParams{}
+
+			if force := r.URL.Query().Get("force"); force != "" {
+				forced, err := strconv.ParseBool(force)
+				if err != nil {
+					app.badRequestResponse(w, r, fmt.Errorf("invalid force parameter"))
+					return
+				}
+				params.Force = &forced
+			}
+
+			app.CreateMovie(w, r, params)
+		})
+
+		r.Delete("/movies/{movieId}", admin, func(w http.ResponseWriter, r *http.Request) {
+			movieId, err := strconv.Atoi(chi.URLParam(r, "movieId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid movie ID"))
+				return
+			}
+			app.ArchiveMovie(w, r, movieId)
+		})
+
+		r.Post("/movies/{movieId}/restore", admin, func(w http.ResponseWriter, r *http.Request) {
+			movieId, err := strconv.Atoi(chi.URLParam(r, "movieId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid movie ID"))
+				return
+			}
+			app.RestoreMovie(w, r, movieId)
+		})
+
+		r.Post("/seat-blocks", admin, app.CreateSeatBlocks)
+
+		r.Delete("/seat-blocks/{seatBlockId}", admin, func(w http.ResponseWriter, r *http.Request) {
+			seatBlockId, err := strconv.Atoi(chi.URLParam(r, "seatBlockId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid seat block ID"))
+				return
+			}
+			app.DeleteSeatBlock(w, r, seatBlockId)
+		})
+
+		r.Get("/showtimes/{showtimeId}/seat-blocks", admin, func(w http.ResponseWriter, r *http.Request) {
+			showtimeId, err := strconv.Atoi(chi.URLParam(r, "showtimeId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid showtime ID"))
+				return
+			}
+			app.GetSeatBlocksByShowtime(w, r, showtimeId)
+		})
+
+		r.Post("/showtimes/{showtimeId}/block-holds", admin, func(w http.ResponseWriter, r *http.Request) {
+			showtimeId, err := strconv.Atoi(chi.URLParam(r, "showtimeId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid showtime ID"))
+				return
+			}
+			app.CreateBlockHold(w, r, showtimeId)
+		})
+
+		r.Delete("/block-holds/{blockHoldId}", admin, func(w http.ResponseWriter, r *http.Request) {
+			blockHoldId, err := strconv.Atoi(chi.URLParam(r, "blockHoldId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid block hold ID"))
+				return
+			}
+			app.ReleaseBlockHold(w, r, blockHoldId)
+		})
+
+		r.Post("/block-holds/{blockHoldId}/confirm", admin, func(w http.ResponseWriter, r *http.Request) {
+			blockHoldId, err := strconv.Atoi(chi.URLParam(r, "blockHoldId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid block hold ID"))
+				return
+			}
+			app.ConfirmBlockHold(w, r, blockHoldId)
+		})
+
+		r.Post("/email-campaigns", admin, app.CreateEmailCampaign)
+
+		r.Get("/email-campaigns/{campaignId}/stats", admin, func(w http.ResponseWriter, r *http.Request) {
+			campaignId, err := strconv.Atoi(chi.URLParam(r, "campaignId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid campaign ID"))
+				return
+			}
+			app.GetEmailCampaignStats(w, r, campaignId)
+		})
+
+		r.Get("/ops/status", admin, app.GetOpsStatus)
+
+		r.Post("/database/pool/warm-up", admin, app.WarmUpDatabasePool)
+		r.Put("/database/pool", admin, app.ResizeDatabasePool)
+
+		r.Get("/migrations/status", admin, app.GetMigrationStatus)
+
+		r.Post("/retention/runs", admin, app.RunDataRetention)
+
+		r.Get("/inventory-audit", admin, app.GetInventoryAudit)
+
+		r.Get("/reconciliation/{date}", admin, func(w http.ResponseWriter, r *http.Request) {
+			app.GetReconciliationReport(w, r, chi.URLParam(r, "date"))
+		})
+
+		r.Get("/requests/{requestId}/trace", admin, func(w http.ResponseWriter, r *http.Request) {
+			app.GetRequestTrace(w, r, chi.URLParam(r, "requestId"))
+		})
+
+		r.Get("/disputes", admin, func(w http.ResponseWriter, r *http.Request) {
+			params := api.GetDisputesParams{}
+
+			if status := r.URL.Query().Get("status"); status != "" {
+				params.Status = &status
+			}
+
+			if page := r.URL.Query().Get("page"); page != "" {
+				if pageNum, err := strconv.Atoi(page); err == nil {
+					params.Page = &pageNum
+				}
+			}
+
+			if pageSize := r.URL.Query().Get("pageSize"); pageSize != "" {
+				if pageSizeNum, err := strconv.Atoi(pageSize); err == nil {
+					params.PageSize = &pageSizeNum
+				}
+			}
+			app.GetDisputes(w, r, params)
+		})
+
+		r.Post("/webhooks/stripe/replay", admin, app.ReplayStripeWebhookEvent)
+
+		r.Get("/fulfillments", admin, app.GetPendingFulfillments)
+
+		r.Post("/announcements", admin, app.CreateAnnouncement)
+
+		r.Post("/announcements/{announcementId}/cancel", admin, func(w http.ResponseWriter, r *http.Request) {
+			announcementId, err := strconv.Atoi(chi.URLParam(r, "announcementId"))
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("invalid announcement ID"))
+				return
+			}
+			app.CancelAnnouncement(w, r, announcementId)
+		})
+	})
+
+	r.Get("/partner/feed/showtimes", partner, func(w http.ResponseWriter, r *http.Request) {
+		params := api.GetPartnerShowtimesFeedParams{}
+
+		if updatedSince := r.URL.Query().Get("updatedSince"); updatedSince != "" {
+			since, err := time.Parse(time.RFC3339, updatedSince)
+			if err != nil {
+				app.badRequestResponse(w, r, fmt.Errorf("updatedSince must be an RFC 3339 timestamp"))
+				return
+			}
+			params.UpdatedSince = &since
+		}
+
+		if page := r.URL.Query().Get("page"); page != "" {
+			if pageNum, err := strconv.Atoi(page); err == nil {
+				params.Page = &pageNum
+			}
+		}
+
+		if pageSize := r.URL.Query().Get("pageSize"); pageSize != "" {
+			if pageSizeNum, err := strconv.Atoi(pageSize); err == nil {
+				params.PageSize = &pageSizeNum
+			}
+		}
+		app.GetPartnerShowtimesFeed(w, r, params)
+	})
+
+	r.Route("/webhook", func(r *policyRouter) {
+		r.Post("/", public, app.guardStripeWebhook(app.StripeWebhookHandler))
+	})
 
 	return r
 }
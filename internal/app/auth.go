@@ -10,10 +10,14 @@ import (
 
 	"github.com/metinatakli/movie-reservation-system/api"
 	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mailer"
+	appvalidator "github.com/metinatakli/movie-reservation-system/internal/validator"
 	"github.com/oapi-codegen/runtime/types"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const activationTokenTTL = 10 * time.Minute
+
 func (app *Application) RegisterUser(w http.ResponseWriter, r *http.Request) {
 	logger := app.contextGetLogger(r)
 
@@ -46,7 +50,7 @@ func (app *Application) RegisterUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	token, err := app.userRepo.CreateWithToken(r.Context(), &user, func(user *domain.User) (*domain.Token, error) {
-		return domain.GenerateToken(int64(user.ID), 10*time.Minute, domain.UserActivationScope)
+		return domain.GenerateToken(int64(user.ID), activationTokenTTL, domain.UserActivationScope)
 	})
 	if err != nil {
 		switch {
@@ -62,6 +66,23 @@ func (app *Application) RegisterUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the opt-in only takes effect once it's confirmed from the user's address, a failure to request it
+	// doesn't fail the registration since it can be requested again from the preferences
+	var consentChanges domain.PendingChanges
+	var consentToken *domain.Token
+
+	if input.MarketingEmails != nil && *input.MarketingEmails {
+		consentChanges = domain.PendingChanges{
+			MarketingEmails: input.MarketingEmails,
+			Source:          domain.ConsentSourceRegistration,
+		}
+
+		consentToken, err = app.savePendingChanges(r.Context(), user.ID, consentChanges)
+		if err != nil {
+			logger.Error("failed to request marketing emails opt-in", "error", err)
+		}
+	}
+
 	go func(ctx context.Context) {
 		// new logger for this goroutine, inheriting context from the request
 		// important for tracing across async boundaries
@@ -73,17 +94,21 @@ func (app *Application) RegisterUser(w http.ResponseWriter, r *http.Request) {
 			}
 		}()
 
-		data := map[string]any{
-			"activationToken": token.Plaintext,
-			"userID":          user.ID,
+		data := mailer.WelcomeEmail{
+			ActivationToken: token.Plaintext,
+			UserID:          user.ID,
 		}
 
-		err = app.mailer.Send(user.Email, "user_welcome.tmpl", data)
+		err = app.mailer.Send(ctx, user.Email, data)
 		if err != nil {
 			gLogger.Error("failed to send activation email", "error", err)
 		} else {
 			gLogger.Info("activation email sent successfully")
 		}
+
+		if consentToken != nil {
+			app.sendPendingChangesEmail(ctx, gLogger, &user, consentChanges, consentToken)
+		}
 	}(r.Context())
 
 	resp := api.UserResponse{
@@ -105,6 +130,28 @@ func (app *Application) RegisterUser(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// GetPasswordPolicy returns the rules new passwords are validated against.
+func (app *Application) GetPasswordPolicy(w http.ResponseWriter, r *http.Request) {
+	policy := app.config.PasswordPolicy
+
+	resp := api.PasswordPolicy{
+		MinLength:           policy.MinLength,
+		MaxLength:           policy.MaxLength,
+		RequireUppercase:    policy.RequireUpper,
+		RequireLowercase:    policy.RequireLower,
+		RequireDigit:        policy.RequireDigit,
+		RequireSpecial:      policy.RequireSpecial,
+		SpecialCharacters:   appvalidator.PasswordSpecialCharacters,
+		BansCommonPasswords: policy.BanCommon,
+		Description:         policy.Message(),
+	}
+
+	err := app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
 func (app *Application) ActivateUser(w http.ResponseWriter, r *http.Request) {
 	logger := app.contextGetLogger(r)
 
@@ -214,14 +261,37 @@ func (app *Application) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = app.logIn(r, user.ID, input.RememberMe != nil && *input.RememberMe)
+	if err != nil {
+		switch {
+		case errors.Is(err, errSessionStoreUnavailable):
+			app.sessionStoreUnavailableResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// logIn turns the guest session of the request into an authenticated session of the user, keeping the
+// cart of the guest. It returns errSessionStoreUnavailable while sessions can't be stored in Redis.
+func (app *Application) logIn(r *http.Request, userId int, rememberMe bool) error {
+	logger := app.contextGetLogger(r)
+
+	if !app.sessionStoreAvailable(r) {
+		return errSessionStoreUnavailable
+	}
+
 	oldSessionId := app.sessionManager.Token(r.Context())
 
 	// To help prevent session fixation attacks we should renew the session token after any privilege level change.
 	// https://github.com/OWASP/CheatSheetSeries/blob/master/cheatsheets/Session_Management_Cheat_Sheet.md#renew-the-session-id-after-any-privilege-level-change
-	err = app.sessionManager.RenewToken(r.Context())
+	err := app.sessionManager.RenewToken(r.Context())
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
-		return
+		return err
 	}
 
 	newSessionId := app.sessionManager.Token(r.Context())
@@ -235,9 +305,15 @@ func (app *Application) Login(w http.ResponseWriter, r *http.Request) {
 		)
 	}
 
-	app.sessionManager.Put(r.Context(), SessionKeyUserId.String(), user.ID)
+	// indexed before the session is authenticated, so a failure never leaves a session that can't be ended
+	err = app.indexUserSession(r, userId)
+	if err != nil {
+		return err
+	}
 
-	w.WriteHeader(http.StatusNoContent)
+	app.startAuthenticatedSession(r, userId, rememberMe)
+
+	return nil
 }
 
 func (app *Application) Logout(w http.ResponseWriter, r *http.Request) {
@@ -251,3 +327,47 @@ func (app *Application) Logout(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+func (app *Application) Reauthenticate(w http.ResponseWriter, r *http.Request) {
+	logger := app.contextGetLogger(r)
+
+	var input api.ReauthenticationRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		logger.Warn("reauthentication validation failed")
+		app.invalidCredentialsResponse(w, r)
+		return
+	}
+
+	userId := app.contextGetUserId(r)
+
+	user, err := app.userRepo.GetById(r.Context(), userId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.unauthorizedAccessResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = bcrypt.CompareHashAndPassword(user.Password.Hash, []byte(input.Password))
+	if err != nil {
+		logger.Warn("reauthentication failed due to incorrect password")
+		app.invalidCredentialsResponse(w, r)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), SessionKeyAuthenticatedAt.String(), time.Now().Unix())
+
+	w.WriteHeader(http.StatusNoContent)
+}
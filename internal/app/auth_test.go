@@ -5,12 +5,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/alexedwards/scs/v2"
 	"github.com/metinatakli/movie-reservation-system/api"
 	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mailer"
 	"github.com/metinatakli/movie-reservation-system/internal/mocks"
 	"github.com/metinatakli/movie-reservation-system/internal/validator"
 	"github.com/oapi-codegen/runtime/types"
@@ -20,12 +22,15 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// passwords longer than bcrypt can hash are rejected before they're compared
+var tooLongPassword = strings.Repeat("a", 73)
+
 type MockMailer struct {
 	sendFunc func(recipient, template string, data any) error
 }
 
-func (m *MockMailer) Send(recipient, template string, data any) error {
-	return m.sendFunc(recipient, template, data)
+func (m *MockMailer) Send(ctx context.Context, recipient string, data mailer.Message, attachments ...mailer.Attachment) error {
+	return m.sendFunc(recipient, data.Template(), data)
 }
 
 func TestRegisterUser(t *testing.T) {
@@ -48,7 +53,7 @@ func TestRegisterUser(t *testing.T) {
 				Gender:    api.M,
 			},
 			wantStatus:     http.StatusUnprocessableEntity,
-			wantErrMessage: validator.ErrInvalidPassword,
+			wantErrMessage: validator.DefaultPasswordPolicy().Message(),
 		},
 		{
 			name: "underage user",
@@ -158,6 +163,95 @@ func TestRegisterUser(t *testing.T) {
 	}
 }
 
+func TestRegisterUserMarketingOptIn(t *testing.T) {
+	var savedChanges domain.PendingChanges
+	templates := make(chan string, 2)
+
+	app := newTestApplication(func(a *Application) {
+		a.userRepo = &mocks.MockUserRepo{
+			CreateWithTokenFunc: func(ctx context.Context, u *domain.User, tp func(*domain.User) (*domain.Token, error)) (*domain.Token, error) {
+				u.ID = 1
+				return tp(u)
+			},
+			SavePendingChangesFunc: func(ctx context.Context, userID int, changes domain.PendingChanges, token *domain.Token) error {
+				savedChanges = changes
+				return nil
+			},
+		}
+		a.mailer = &MockMailer{sendFunc: func(recipient, template string, data any) error {
+			templates <- template
+			return nil
+		}}
+	})
+
+	input := api.RegisterRequest{
+		FirstName:       "Freddie",
+		LastName:        "Mercury",
+		Email:           "freddie@example.com",
+		Password:        "Pass123!@#",
+		BirthDate:       types.Date{Time: time.Now().AddDate(-20, 0, 0)},
+		Gender:          api.M,
+		MarketingEmails: ptr(true),
+	}
+
+	w, r := executeRequest(t, http.MethodPost, "/users", input)
+
+	app.RegisterUser(w, r)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("RegisterUser() status = %v, want %v", w.Code, http.StatusAccepted)
+	}
+
+	if savedChanges.MarketingEmails == nil || !*savedChanges.MarketingEmails {
+		t.Errorf("pending changes = %+v, want a marketing emails opt-in", savedChanges)
+	}
+
+	if savedChanges.Source != domain.ConsentSourceRegistration {
+		t.Errorf("consent source = %q, want %q", savedChanges.Source, domain.ConsentSourceRegistration)
+	}
+
+	for _, want := range []string{"user_welcome.tmpl", "pending_changes.tmpl"} {
+		select {
+		case got := <-templates:
+			if got != want {
+				t.Errorf("email template = %q, want %q", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("email %q was not sent", want)
+		}
+	}
+}
+
+func TestGetPasswordPolicy(t *testing.T) {
+	app := newTestApplication(func(a *Application) {
+		a.config.PasswordPolicy = validator.PasswordPolicy{MinLength: 12, MaxLength: 64, RequireDigit: true}
+	})
+
+	w, r := executeRequest(t, http.MethodGet, "/auth/password-policy", nil)
+	app.GetPasswordPolicy(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	var got api.PasswordPolicy
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := api.PasswordPolicy{
+		MinLength:         12,
+		MaxLength:         64,
+		RequireDigit:      true,
+		SpecialCharacters: validator.PasswordSpecialCharacters,
+		Description:       "must be between 12 and 64 characters long and include at least one number.",
+	}
+
+	if got != want {
+		t.Errorf("Response = %+v, want %+v", got, want)
+	}
+}
+
 func TestActivateUser(t *testing.T) {
 	tests := []struct {
 		name               string
@@ -273,6 +367,8 @@ func (s *LoginTestSuite) SetupTest() {
 	s.app = newTestApplication(func(a *Application) {
 		a.redis = s.redisClient
 		a.sessionManager = scs.New()
+		a.config.Session.Lifetime = 12 * time.Hour
+		a.config.Session.RememberMeLifetime = 30 * 24 * time.Hour
 	})
 }
 
@@ -291,6 +387,8 @@ func (s *LoginTestSuite) TestLogin() {
 		wantStatus     int
 		wantErrMessage string
 		wantResponse   *api.AlreadyLoggedInResponse
+		wantLifetime   time.Duration
+		wantGuest      bool
 	}{
 		{
 			name: "user already is logged in",
@@ -306,7 +404,7 @@ func (s *LoginTestSuite) TestLogin() {
 			name: "invalid password format",
 			input: api.LoginRequest{
 				Email:    "freddie@example.com",
-				Password: "weak",
+				Password: tooLongPassword,
 			},
 			wantStatus:     http.StatusUnauthorized,
 			wantErrMessage: ErrInvalidCredentials,
@@ -378,10 +476,72 @@ func (s *LoginTestSuite) TestLogin() {
 
 				s.redisClient.On("TxPipeline").Return(s.redisPipeline)
 				s.redisPipeline.On("Expire", mock.Anything, mock.Anything, mock.Anything).Return(redis.NewBoolResult(true, nil))
+				s.redisPipeline.On("SAdd", mock.Anything, userSessionsKey(1), mock.Anything).Return(redis.NewIntResult(1, nil)).Once()
 				s.redisPipeline.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(redis.NewStatusResult("OK", nil))
 				s.redisPipeline.On("Exec", mock.Anything).Return([]redis.Cmder{}, nil)
 			},
-			wantStatus: http.StatusNoContent,
+			wantStatus:   http.StatusNoContent,
+			wantLifetime: 12 * time.Hour,
+		},
+		{
+			name: "successful login with remember me",
+			input: api.LoginRequest{
+				Email:      "freddie@example.com",
+				Password:   "Pass123!@#",
+				RememberMe: ptr(true),
+			},
+			password: "Pass123!@#",
+			getByEmailFunc: func(ctx context.Context, email string) (*domain.User, error) {
+				hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("Pass123!@#"), 12)
+				user := &domain.User{}
+
+				user.ID = 1
+				user.Password.Hash = hashedPassword
+
+				return user, nil
+			},
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, mock.Anything).Return(redis.NewStringResult(cartID, nil)).Once()
+				s.redisClient.On("Get", mock.Anything, mock.Anything).Return(redis.NewStringResult(cartDataStr, nil)).Once()
+				s.redisClient.On("TTL", mock.Anything, mock.Anything).Return(redis.NewDurationResult(2*time.Minute, nil))
+				s.redisClient.On("Watch", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+				s.redisClient.On("TxPipeline").Return(s.redisPipeline)
+				s.redisPipeline.On("Expire", mock.Anything, mock.Anything, mock.Anything).Return(redis.NewBoolResult(true, nil))
+				s.redisPipeline.On("SAdd", mock.Anything, userSessionsKey(1), mock.Anything).Return(redis.NewIntResult(1, nil)).Once()
+				s.redisPipeline.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(redis.NewStatusResult("OK", nil))
+				s.redisPipeline.On("Exec", mock.Anything).Return([]redis.Cmder{}, nil)
+			},
+			wantStatus:   http.StatusNoContent,
+			wantLifetime: 30 * 24 * time.Hour,
+		},
+		{
+			name: "session index unavailable",
+			input: api.LoginRequest{
+				Email:    "freddie@example.com",
+				Password: "Pass123!@#",
+			},
+			password: "Pass123!@#",
+			getByEmailFunc: func(ctx context.Context, email string) (*domain.User, error) {
+				hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("Pass123!@#"), 12)
+				user := &domain.User{}
+
+				user.ID = 1
+				user.Password.Hash = hashedPassword
+
+				return user, nil
+			},
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, mock.Anything).Return(redis.NewStringResult("", redis.Nil))
+
+				s.redisClient.On("TxPipeline").Return(s.redisPipeline)
+				s.redisPipeline.On("SAdd", mock.Anything, userSessionsKey(1), mock.Anything).Return(redis.NewIntResult(0, nil)).Once()
+				s.redisPipeline.On("Expire", mock.Anything, userSessionsKey(1), mock.Anything).Return(redis.NewBoolResult(false, nil)).Once()
+				s.redisPipeline.On("Exec", mock.Anything).Return([]redis.Cmder(nil), fmt.Errorf("redis unavailable")).Once()
+			},
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+			wantGuest:      true,
 		},
 	}
 
@@ -434,6 +594,29 @@ func (s *LoginTestSuite) TestLogin() {
 				if userId != 1 {
 					s.T().Errorf("Expected userId=1 in session, got %v", userId)
 				}
+
+				authenticatedAt := s.app.sessionManager.GetInt64(ctx, SessionKeyAuthenticatedAt.String())
+				s.WithinDuration(time.Now(), time.Unix(authenticatedAt, 0), time.Minute)
+				s.WithinDuration(time.Now().Add(tt.wantLifetime), s.app.sessionManager.Deadline(ctx), time.Minute)
+
+				rememberMe := tt.input.RememberMe != nil && *tt.input.RememberMe
+				s.Equal(rememberMe, s.app.sessionManager.GetBool(ctx, SessionKeyRememberMe.String()))
+			}
+
+			// a session which isn't indexed couldn't be ended, so it mustn't be authenticated
+			if tt.wantGuest {
+				for _, cookie := range w.Result().Cookies() {
+					if cookie.Name != s.app.sessionManager.Cookie.Name {
+						continue
+					}
+
+					ctx, err := s.app.sessionManager.Load(r.Context(), cookie.Value)
+					if err != nil {
+						s.T().Fatalf("Failed to load session: %v", err)
+					}
+
+					s.Zero(s.app.sessionManager.GetInt(ctx, SessionKeyUserId.String()))
+				}
 			}
 
 			checkErrorResponse(s.T(), w, struct {
@@ -503,3 +686,87 @@ func TestLogout(t *testing.T) {
 		})
 	}
 }
+
+func TestReauthenticate(t *testing.T) {
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("Pass123!@#"), 12)
+
+	tests := []struct {
+		name           string
+		input          api.ReauthenticationRequest
+		getByIdFunc    func(context.Context, int) (*domain.User, error)
+		wantStatus     int
+		wantErrMessage string
+	}{
+		{
+			name:           "invalid password format",
+			input:          api.ReauthenticationRequest{Password: tooLongPassword},
+			wantStatus:     http.StatusUnauthorized,
+			wantErrMessage: ErrInvalidCredentials,
+		},
+		{
+			name:  "incorrect password",
+			input: api.ReauthenticationRequest{Password: "WrongPass123!@#"},
+			getByIdFunc: func(ctx context.Context, id int) (*domain.User, error) {
+				user := &domain.User{ID: id}
+				user.Password.Hash = hashedPassword
+				return user, nil
+			},
+			wantStatus:     http.StatusUnauthorized,
+			wantErrMessage: ErrInvalidCredentials,
+		},
+		{
+			name:  "database error",
+			input: api.ReauthenticationRequest{Password: "Pass123!@#"},
+			getByIdFunc: func(ctx context.Context, id int) (*domain.User, error) {
+				return nil, fmt.Errorf("database connection error")
+			},
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+		{
+			name:  "password confirmed",
+			input: api.ReauthenticationRequest{Password: "Pass123!@#"},
+			getByIdFunc: func(ctx context.Context, id int) (*domain.User, error) {
+				user := &domain.User{ID: id}
+				user.Password.Hash = hashedPassword
+				return user, nil
+			},
+			wantStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(func(a *Application) {
+				a.sessionManager = scs.New()
+				a.userRepo = &mocks.MockUserRepo{GetByIdFunc: tt.getByIdFunc}
+			})
+
+			w, r := executeRequest(t, http.MethodPost, "/sessions/reauthentication", tt.input)
+			r = setupTestSession(t, app, r, 1)
+
+			staleLogin := time.Now().Add(-time.Hour).Unix()
+			app.sessionManager.Put(r.Context(), SessionKeyAuthenticatedAt.String(), staleLogin)
+
+			handler := app.requireAuthentication(http.HandlerFunc(app.Reauthenticate))
+			handler.ServeHTTP(w, r)
+
+			if got := w.Code; got != tt.wantStatus {
+				t.Fatalf("Reauthenticate() status = %v, want %v", got, tt.wantStatus)
+			}
+
+			authenticatedAt := app.sessionManager.GetInt64(r.Context(), SessionKeyAuthenticatedAt.String())
+			if refreshed := authenticatedAt != staleLogin; refreshed != (tt.wantStatus == http.StatusNoContent) {
+				t.Errorf("authentication time refreshed = %v", refreshed)
+			}
+
+			checkErrorResponse(t, w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+		})
+	}
+}
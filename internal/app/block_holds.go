@@ -0,0 +1,313 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/scheduler"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	errSeatsNotContiguous = errors.New("the seats must be side by side in rows which follow each other")
+	errBlockHoldExpiry    = errors.New("the hold must expire in the future and before the showtime starts")
+)
+
+// CreateBlockHold holds a block of seats for an event. The seats are locked under a hold of the admin while
+// the hold is written, like the seats of a comp reservation, and the seat blocks of the hold keep them out
+// of sale afterwards.
+func (app *Application) CreateBlockHold(w http.ResponseWriter, r *http.Request, showtimeId int) {
+	if showtimeId < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("showtime ID must be greater than zero"))
+		return
+	}
+
+	var input api.CreateBlockHoldRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	adminId := app.contextGetUserId(r)
+	seatIds := input.SeatIds
+	logger := app.contextGetLogger(r).With("showtime_id", showtimeId, "admin_id", adminId)
+
+	showtimeSeats, err := app.selectableSeats(r.Context(), showtimeId, seatIds)
+	if err != nil {
+		switch {
+		case errors.Is(err, errSeatsAlreadyReserved):
+			logger.Warn("block hold conflict: an already reserved seat was selected", "requested_seats", seatIds)
+			app.editConflictResponseWithErr(w, r, err)
+		case errors.Is(err, errSeatsBlocked):
+			logger.Warn("block hold conflict: a blocked seat was selected", "requested_seats", seatIds)
+			app.editConflictResponseWithErr(w, r, err)
+		case errors.Is(err, domain.ErrRecordNotFound):
+			logger.Warn("block hold failed: one or more requested seat IDs do not exist for the showtime", "requested_seats", seatIds)
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	if !domain.ContiguousSeats(showtimeSeats.Seats) {
+		app.badRequestResponse(w, r, errSeatsNotContiguous)
+		return
+	}
+
+	if !input.ExpiresAt.After(time.Now()) || !input.ExpiresAt.Before(showtimeSeats.Date) {
+		app.badRequestResponse(w, r, errBlockHoldExpiry)
+		return
+	}
+
+	err = app.tryLockSeats(r.Context(), seatIds, showtimeId, seatHoldKey(adminId, uuid.NewString()))
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrSeatAlreadyReserved):
+			logger.Warn("block hold conflict: an already locked seat was selected")
+			app.editConflictResponseWithErr(w, r, errSeatsAlreadyLocked)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	hold := domain.BlockHold{
+		ShowtimeID: showtimeId,
+		SeatIDs:    seatIds,
+		Reason:     input.Reason,
+		ExpiresAt:  input.ExpiresAt,
+		CreatedBy:  &adminId,
+	}
+
+	if input.Note != nil {
+		hold.Note = sanitizeNote(*input.Note)
+	}
+
+	err = app.blockHoldRepo.Create(r.Context(), &hold)
+
+	// the locks are dropped either way, the seats are blocked now or stay free
+	app.rollbackSeatLocks(r.Context(), showtimeId, seatIds)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	app.publishSeatEvent(r.Context(), showtimeId, seatEventLocked, seatIds)
+
+	logger.Info("seats held for an event",
+		"block_hold_id", hold.ID,
+		"seat_ids", seatIds,
+		"reason", hold.Reason,
+		"expires_at", hold.ExpiresAt)
+
+	err = app.writeJSON(w, http.StatusCreated, toApiBlockHold(hold), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// ConfirmBlockHold books the held seats as a comp reservation of the customer. The reservation is created,
+// and the seats unblocked, in the same transaction the hold is closed in.
+func (app *Application) ConfirmBlockHold(w http.ResponseWriter, r *http.Request, blockHoldId int) {
+	if blockHoldId < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("block hold ID must be greater than zero"))
+		return
+	}
+
+	var input api.ConfirmBlockHoldRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	logger := app.contextGetLogger(r).With("block_hold_id", blockHoldId, "admin_id", app.contextGetUserId(r))
+
+	hold, err := app.blockHoldRepo.Get(r.Context(), blockHoldId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	now := time.Now()
+
+	if !hold.Active(now) {
+		app.editConflictResponseWithErr(w, r, domain.ErrBlockHoldNotActive)
+		return
+	}
+
+	showtimeSeats, err := app.seatRepo.GetSeatsByShowtimeAndSeatIds(r.Context(), hold.ShowtimeID, hold.SeatIDs)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	customer, err := app.phoneBookingCustomer(r.Context(), input.CustomerEmail)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	reservation := domain.Reservation{
+		UserID:           customer.ID,
+		ShowtimeID:       hold.ShowtimeID,
+		ReservationSeats: showtimeSeats.PriceBreakdown().CompReservationSeats(hold.ShowtimeID),
+		SalesChannel:     domain.SalesChannelBoxOffice,
+	}
+
+	if customer.ID == 0 {
+		reservation.GuestEmail = customer.Email
+	}
+
+	if input.Note != nil {
+		reservation.Note = sanitizeNote(*input.Note)
+	}
+
+	payment := &domain.Payment{
+		UserID:       customer.ID,
+		Amount:       decimal.Zero,
+		Currency:     domain.DefaultCurrency,
+		Status:       domain.PaymentStatusComp,
+		SalesChannel: domain.SalesChannelBoxOffice,
+	}
+
+	err = app.blockHoldRepo.Confirm(r.Context(), hold.ID, now, &reservation, payment)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, domain.ErrBlockHoldNotActive):
+			app.editConflictResponseWithErr(w, r, err)
+		case errors.Is(err, domain.ErrSeatAlreadyReserved):
+			// the seat blocks of the hold were deleted and the seat sold meanwhile
+			logger.Warn("block hold confirmation conflict: a seat was sold meanwhile", "seat_ids", hold.SeatIDs)
+			app.editConflictResponseWithErr(w, r, errSeatsAlreadyReserved)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	hold.Status = domain.BlockHoldConfirmed
+	hold.ReservationID = &reservation.ID
+
+	app.publishSeatEvent(r.Context(), hold.ShowtimeID, seatEventReserved, hold.SeatIDs)
+
+	logger = logger.With("reservation_id", reservation.ID, "payment_id", payment.ID)
+	logger.Info("block hold confirmed", "guest", customer.ID == 0, "seat_ids", hold.SeatIDs)
+
+	// the confirmation outlives the request, so it must not be cancelled along with it
+	go app.sendReservationConfirmation(context.WithoutCancel(r.Context()), logger, reservation)
+
+	err = app.writeJSON(w, http.StatusOK, toApiBlockHold(*hold), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) ReleaseBlockHold(w http.ResponseWriter, r *http.Request, blockHoldId int) {
+	if blockHoldId < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("block hold ID must be greater than zero"))
+		return
+	}
+
+	hold, err := app.blockHoldRepo.Release(r.Context(), blockHoldId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, domain.ErrBlockHoldNotActive):
+			app.editConflictResponseWithErr(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	app.publishSeatEvent(r.Context(), hold.ShowtimeID, seatEventReleased, hold.SeatIDs)
+
+	app.contextGetLogger(r).Info("block hold released",
+		"block_hold_id", hold.ID,
+		"showtime_id", hold.ShowtimeID,
+		"seat_ids", hold.SeatIDs)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// expireBlockHolds puts the seats of the holds which weren't confirmed or released by their expiry back
+// on sale.
+func (app *Application) expireBlockHolds(ctx context.Context) error {
+	if err := scheduler.CheckFence(ctx); err != nil {
+		return err
+	}
+
+	holds, err := app.blockHoldRepo.Expire(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, hold := range holds {
+		app.publishSeatEvent(ctx, hold.ShowtimeID, seatEventReleased, hold.SeatIDs)
+
+		app.logger.Info("block hold expired",
+			"block_hold_id", hold.ID,
+			"showtime_id", hold.ShowtimeID,
+			"seat_count", len(hold.SeatIDs))
+	}
+
+	return nil
+}
+
+func toApiBlockHold(hold domain.BlockHold) api.BlockHold {
+	return api.BlockHold{
+		Id:            hold.ID,
+		ShowtimeId:    hold.ShowtimeID,
+		SeatIds:       hold.SeatIDs,
+		Reason:        hold.Reason,
+		Note:          hold.Note,
+		Status:        api.BlockHoldStatus(hold.Status),
+		ExpiresAt:     hold.ExpiresAt,
+		ReservationId: hold.ReservationID,
+		CreatedBy:     hold.CreatedBy,
+		CreatedAt:     hold.CreatedAt,
+	}
+}
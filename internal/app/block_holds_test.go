@@ -0,0 +1,361 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+const testBlockHoldAdminID = 9
+
+type BlockHoldTestSuite struct {
+	suite.Suite
+	app             *Application
+	seatRepo        *mocks.MockSeatRepo
+	reservationRepo *mocks.MockReservationRepo
+	blockHoldRepo   *mocks.MockBlockHoldRepo
+	userRepo        *mocks.MockUserRepo
+	redisClient     *mocks.MockRedisClient
+	redisPipeline   *mocks.MockTxPipeline
+}
+
+func (s *BlockHoldTestSuite) SetupTest() {
+	s.seatRepo = new(mocks.MockSeatRepo)
+	s.reservationRepo = new(mocks.MockReservationRepo)
+	s.blockHoldRepo = new(mocks.MockBlockHoldRepo)
+	s.redisClient = new(mocks.MockRedisClient)
+	s.redisPipeline = new(mocks.MockTxPipeline)
+
+	s.userRepo = &mocks.MockUserRepo{
+		GetByEmailFunc: func(ctx context.Context, email string) (*domain.User, error) {
+			return nil, domain.ErrRecordNotFound
+		},
+	}
+
+	s.app = newTestApplication(func(a *Application) {
+		a.seatRepo = s.seatRepo
+		a.reservationRepo = s.reservationRepo
+		a.blockHoldRepo = s.blockHoldRepo
+		a.userRepo = s.userRepo
+		a.redis = s.redisClient
+	})
+}
+
+func TestBlockHoldSuite(t *testing.T) {
+	suite.Run(t, new(BlockHoldTestSuite))
+}
+
+func (s *BlockHoldTestSuite) expectSelectableSeats(seats []domain.Seat, startsAt time.Time) {
+	s.reservationRepo.On("GetSeatsByShowtimeId", mock.Anything, 1).Return([]domain.ReservationSeat{}, nil)
+	s.seatRepo.On("GetSeatBlocksByShowtime", mock.Anything, 1).Return([]domain.SeatBlock{}, nil)
+	s.seatRepo.On("GetSeatsByShowtimeAndSeatIds", mock.Anything, 1, testSeatIDs).
+		Return(&domain.ShowtimeSeats{Price: 10, Seats: seats, Date: startsAt}, nil)
+}
+
+// expectSeatsLocked mocks the seats being locked for the admin and the locks being dropped once the hold
+// is written.
+func (s *BlockHoldTestSuite) expectSeatsLocked() {
+	lockKeys := []string{seatLockKey(1, 1), seatLockKey(1, 2), seatLockKey(1, 3)}
+
+	s.redisClient.On("EvalSha", mock.Anything, mock.Anything, lockKeys, mock.Anything, int(seatLockTTL.Seconds())).
+		Return(redis.NewCmdResult("OK", nil)).Once()
+
+	s.redisClient.On("TxPipeline").Return(s.redisPipeline).Once()
+	s.redisPipeline.On("Del", mock.Anything, lockKeys).Return(redis.NewIntResult(3, nil)).Once()
+	s.redisPipeline.On("SRem", mock.Anything, seatSetKey(1), []interface{}{1, 2, 3}).Return(redis.NewIntResult(0, nil)).Once()
+	s.redisPipeline.On("Exec", mock.Anything).Return([]redis.Cmder{}, nil).Once()
+}
+
+func (s *BlockHoldTestSuite) expectSeatEvent() {
+	s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatMapChangeKeys(1), seatEventsChannel(1), mock.Anything, mock.Anything).
+		Return(redis.NewCmdResult(int64(1), nil)).Once()
+}
+
+func (s *BlockHoldTestSuite) TestCreateBlockHold() {
+	startsAt := time.Now().Add(72 * time.Hour)
+
+	validInput := api.CreateBlockHoldRequest{
+		SeatIds:   testSeatIDs,
+		Reason:    "Premiere: cast and crew",
+		ExpiresAt: startsAt.Add(-24 * time.Hour),
+	}
+
+	// two rows side by side, with the third seat in a row of its own
+	blockSeats := []domain.Seat{{ID: 1, Row: 4, Col: 5}, {ID: 2, Row: 4, Col: 6}, {ID: 3, Row: 5, Col: 6}}
+	gapSeats := []domain.Seat{{ID: 1, Row: 4, Col: 5}, {ID: 2, Row: 4, Col: 6}, {ID: 3, Row: 4, Col: 8}}
+
+	tests := []struct {
+		name           string
+		input          api.CreateBlockHoldRequest
+		setupMocks     func()
+		wantStatus     int
+		wantErrMessage string
+		wantErrCode    api.ErrorCode
+	}{
+		{
+			name: "should fail when the reason is missing",
+			input: api.CreateBlockHoldRequest{
+				SeatIds:   testSeatIDs,
+				ExpiresAt: validInput.ExpiresAt,
+			},
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantErrCode: api.VALIDATIONFAILED,
+		},
+		{
+			name:  "should fail when a seat is blocked",
+			input: validInput,
+			setupMocks: func() {
+				s.reservationRepo.On("GetSeatsByShowtimeId", mock.Anything, 1).Return([]domain.ReservationSeat{}, nil)
+				s.seatRepo.On("GetSeatBlocksByShowtime", mock.Anything, 1).
+					Return([]domain.SeatBlock{{SeatID: 2, Reason: domain.SeatBlockEvent}}, nil)
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: errSeatsBlocked.Error(),
+			wantErrCode:    api.SEATBLOCKED,
+		},
+		{
+			name:  "should fail when the seats have a gap",
+			input: validInput,
+			setupMocks: func() {
+				s.expectSelectableSeats(gapSeats, startsAt)
+			},
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: errSeatsNotContiguous.Error(),
+			wantErrCode:    api.BADREQUEST,
+		},
+		{
+			name: "should fail when the hold outlasts the showtime start",
+			input: api.CreateBlockHoldRequest{
+				SeatIds:   testSeatIDs,
+				Reason:    validInput.Reason,
+				ExpiresAt: startsAt.Add(time.Hour),
+			},
+			setupMocks: func() {
+				s.expectSelectableSeats(blockSeats, startsAt)
+			},
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: errBlockHoldExpiry.Error(),
+			wantErrCode:    api.BADREQUEST,
+		},
+		{
+			name:  "should hold the seats",
+			input: validInput,
+			setupMocks: func() {
+				s.expectSelectableSeats(blockSeats, startsAt)
+				s.expectSeatsLocked()
+				s.blockHoldRepo.On("Create", mock.Anything, mock.MatchedBy(func(h *domain.BlockHold) bool {
+					return h.ShowtimeID == 1 && h.Reason == validInput.Reason && *h.CreatedBy == testBlockHoldAdminID
+				})).Run(func(args mock.Arguments) {
+					hold := args.Get(1).(*domain.BlockHold)
+					hold.ID = 12
+					hold.Status = domain.BlockHoldActive
+				}).Return(nil).Once()
+				s.expectSeatEvent()
+			},
+			wantStatus: http.StatusCreated,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			w, r := executeRequest(s.T(), http.MethodPost, "/admin/showtimes/1/block-holds", tt.input)
+			r = r.WithContext(context.WithValue(r.Context(), SessionKeyUserId, testBlockHoldAdminID))
+
+			s.app.CreateBlockHold(w, r, 1)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantErrCode != "" {
+				checkErrorCode(s.T(), w, tt.wantErrCode)
+			}
+
+			if tt.wantStatus == http.StatusCreated {
+				var response api.BlockHold
+				s.Require().NoError(json.NewDecoder(w.Body).Decode(&response))
+
+				s.Equal(12, response.Id)
+				s.Equal(api.BlockHoldStatusActive, response.Status)
+				s.Equal(testSeatIDs, response.SeatIds)
+			}
+
+			if tt.wantErrMessage != "" {
+				checkErrorResponse(s.T(), w, struct {
+					wantStatus     int
+					wantErrMessage string
+				}{
+					wantStatus:     tt.wantStatus,
+					wantErrMessage: tt.wantErrMessage,
+				})
+			}
+
+			s.redisClient.AssertExpectations(s.T())
+			s.redisPipeline.AssertExpectations(s.T())
+			s.blockHoldRepo.AssertExpectations(s.T())
+		})
+	}
+}
+
+func (s *BlockHoldTestSuite) TestConfirmBlockHold() {
+	input := api.ConfirmBlockHoldRequest{CustomerEmail: testCustomerEmail}
+
+	activeHold := func() *domain.BlockHold {
+		return &domain.BlockHold{
+			ID:         12,
+			ShowtimeID: 1,
+			SeatIDs:    testSeatIDs,
+			Reason:     "Premiere",
+			Status:     domain.BlockHoldActive,
+			ExpiresAt:  time.Now().Add(time.Hour),
+		}
+	}
+
+	tests := []struct {
+		name        string
+		setupMocks  func()
+		wantStatus  int
+		wantErrCode api.ErrorCode
+	}{
+		{
+			name: "should fail when the hold doesn't exist",
+			setupMocks: func() {
+				s.blockHoldRepo.On("Get", mock.Anything, 12).Return(nil, domain.ErrRecordNotFound).Once()
+			},
+			wantStatus:  http.StatusNotFound,
+			wantErrCode: api.NOTFOUND,
+		},
+		{
+			name: "should fail when the hold has expired",
+			setupMocks: func() {
+				hold := activeHold()
+				hold.ExpiresAt = time.Now().Add(-time.Minute)
+				s.blockHoldRepo.On("Get", mock.Anything, 12).Return(hold, nil).Once()
+			},
+			wantStatus:  http.StatusConflict,
+			wantErrCode: api.BLOCKHOLDNOTACTIVE,
+		},
+		{
+			name: "should fail when a seat was sold meanwhile",
+			setupMocks: func() {
+				s.blockHoldRepo.On("Get", mock.Anything, 12).Return(activeHold(), nil).Once()
+				s.seatRepo.On("GetSeatsByShowtimeAndSeatIds", mock.Anything, 1, testSeatIDs).
+					Return(&domain.ShowtimeSeats{Price: 10, Seats: testSeats}, nil)
+				s.blockHoldRepo.On("Confirm", mock.Anything, 12, mock.Anything, mock.Anything, mock.Anything).
+					Return(domain.ErrSeatAlreadyReserved).Once()
+			},
+			wantStatus:  http.StatusConflict,
+			wantErrCode: api.SEATALREADYRESERVED,
+		},
+		{
+			name: "should book the held seats for the customer",
+			setupMocks: func() {
+				s.blockHoldRepo.On("Get", mock.Anything, 12).Return(activeHold(), nil).Once()
+				s.seatRepo.On("GetSeatsByShowtimeAndSeatIds", mock.Anything, 1, testSeatIDs).
+					Return(&domain.ShowtimeSeats{Price: 10, Seats: testSeats}, nil)
+				s.blockHoldRepo.On("Confirm", mock.Anything, 12, mock.Anything, mock.MatchedBy(func(r *domain.Reservation) bool {
+					return r.GuestEmail == testCustomerEmail && len(r.ReservationSeats) == len(testSeatIDs)
+				}), mock.MatchedBy(func(p *domain.Payment) bool {
+					return p.Status == domain.PaymentStatusComp && p.Amount.IsZero()
+				})).Run(func(args mock.Arguments) {
+					args.Get(3).(*domain.Reservation).ID = 55
+				}).Return(nil).Once()
+				s.expectSeatEvent()
+				// the confirmation is sent in the background
+				s.reservationRepo.On("GetByReservationIdAndUserId", mock.Anything, 55, 0).
+					Return(nil, domain.ErrRecordNotFound).Maybe()
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+			tt.setupMocks()
+
+			w, r := executeRequest(s.T(), http.MethodPost, "/admin/block-holds/12/confirm", input)
+			r = r.WithContext(context.WithValue(r.Context(), SessionKeyUserId, testBlockHoldAdminID))
+
+			s.app.ConfirmBlockHold(w, r, 12)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantErrCode != "" {
+				checkErrorCode(s.T(), w, tt.wantErrCode)
+			}
+
+			if tt.wantStatus == http.StatusOK {
+				var response api.BlockHold
+				s.Require().NoError(json.NewDecoder(w.Body).Decode(&response))
+
+				s.Equal(api.BlockHoldStatusConfirmed, response.Status)
+				s.Require().NotNil(response.ReservationId)
+				s.Equal(55, *response.ReservationId)
+			}
+
+			s.blockHoldRepo.AssertExpectations(s.T())
+			s.redisClient.AssertExpectations(s.T())
+		})
+	}
+}
+
+func (s *BlockHoldTestSuite) TestReleaseBlockHold() {
+	tests := []struct {
+		name        string
+		setupMocks  func()
+		wantStatus  int
+		wantErrCode api.ErrorCode
+	}{
+		{
+			name: "should fail when the hold is no longer active",
+			setupMocks: func() {
+				s.blockHoldRepo.On("Release", mock.Anything, 12).Return(nil, domain.ErrBlockHoldNotActive).Once()
+			},
+			wantStatus:  http.StatusConflict,
+			wantErrCode: api.BLOCKHOLDNOTACTIVE,
+		},
+		{
+			name: "should put the seats back on sale",
+			setupMocks: func() {
+				s.blockHoldRepo.On("Release", mock.Anything, 12).
+					Return(&domain.BlockHold{ID: 12, ShowtimeID: 1, SeatIDs: testSeatIDs, Status: domain.BlockHoldReleased}, nil).Once()
+				s.expectSeatEvent()
+			},
+			wantStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+			tt.setupMocks()
+
+			w, r := executeRequest(s.T(), http.MethodDelete, "/admin/block-holds/12", nil)
+
+			s.app.ReleaseBlockHold(w, r, 12)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantErrCode != "" {
+				checkErrorCode(s.T(), w, tt.wantErrCode)
+			}
+
+			s.blockHoldRepo.AssertExpectations(s.T())
+			s.redisClient.AssertExpectations(s.T())
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mailer"
+)
+
+const (
+	icsProductID   = "-//CineX//Reservations//EN"
+	icsUIDDomain   = "cinex.metinatakli.net"
+	icsContentType = "text/calendar; charset=utf-8; method=PUBLISH"
+	icsTimeFormat  = "20060102T150405Z"
+	// RFC 5545 limits content lines to 75 octets, excluding the line break
+	icsMaxLineOctets = 75
+)
+
+var icsTextEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+)
+
+func (app *Application) GetUserReservationCalendar(w http.ResponseWriter, r *http.Request, reservationId int) {
+	logger := app.contextGetLogger(r)
+
+	if reservationId <= 0 {
+		app.badRequestResponse(w, r, fmt.Errorf("reservation id must be greater than zero"))
+		return
+	}
+
+	userId := app.contextGetUserId(r)
+
+	reservationDetail, err := app.reservationRepo.GetByReservationIdAndUserId(r.Context(), reservationId, userId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			logger.Warn(
+				"user attempt to export non-existent or unauthorized reservation",
+				"reservation_id", reservationId,
+			)
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	w.Header().Set("Content-Type", icsContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, reservationICSFilename(reservationId)))
+	w.WriteHeader(http.StatusOK)
+
+	_, err = w.Write(renderReservationICS(reservationDetail, time.Now()))
+	if err != nil {
+		logger.Error("failed to write calendar response", "error", err)
+	}
+}
+
+// sendReservationConfirmation emails the user, or the guest the reservation is booked for, a summary of
+// a newly created reservation with the calendar event attached. It is meant to be run in its own goroutine
+// after the reservation is stored.
+func (app *Application) sendReservationConfirmation(
+	ctx context.Context,
+	logger *slog.Logger,
+	reservation domain.Reservation) {
+
+	logger = logger.With("reservation_id", reservation.ID)
+
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Error("panic occurred during sending reservation confirmation mail", "panic", err)
+		}
+	}()
+
+	recipient := domain.User{Email: reservation.GuestEmail}
+
+	if reservation.UserID != 0 {
+		user, err := app.userRepo.GetById(ctx, reservation.UserID)
+		if err != nil {
+			logger.Error("failed to get user for reservation confirmation", "error", err)
+			return
+		}
+
+		recipient = *user
+	}
+
+	reservationDetail, err := app.reservationRepo.GetByReservationIdAndUserId(ctx, reservation.ID, reservation.UserID)
+	if err != nil {
+		logger.Error("failed to get reservation for confirmation", "error", err)
+		return
+	}
+
+	seats := make([]string, len(reservationDetail.Seats))
+	for i, s := range reservationDetail.Seats {
+		seats[i] = formatReservationSeat(s)
+	}
+
+	data := mailer.ReservationConfirmation{
+		FirstName:       recipient.FirstName,
+		ReservationID:   reservationDetail.ReservationID,
+		MovieTitle:      reservationDetail.MovieTitle,
+		TheaterName:     reservationDetail.TheaterName,
+		HallName:        reservationDetail.HallName,
+		Showtime:        reservationDetail.ShowtimeDate.In(domain.TheaterLocation(reservationDetail.TheaterTimeZone)).Format("Mon, 02 Jan 2006 15:04 MST"),
+		Seats:           seats,
+		Note:            reservationDetail.Note,
+		SpecialRequests: describeSpecialRequests(reservationDetail.SpecialRequests),
+	}
+
+	attachment := mailer.Attachment{
+		Filename:    reservationICSFilename(reservationDetail.ReservationID),
+		ContentType: icsContentType,
+		Data:        renderReservationICS(reservationDetail, time.Now()),
+	}
+
+	err = app.mailer.Send(ctx, recipient.Email, data, attachment)
+	if err != nil {
+		logger.Error("failed to send reservation confirmation email", "error", err)
+	} else {
+		logger.Info("reservation confirmation email sent successfully")
+	}
+}
+
+var specialRequestDescriptions = map[domain.SpecialRequest]string{
+	domain.SpecialRequestWheelchairAssistance: "Wheelchair assistance",
+	domain.SpecialRequestChildBoosterSeat:     "Child booster seat",
+	domain.SpecialRequestHearingAssistance:    "Hearing assistance",
+	domain.SpecialRequestVisualAssistance:     "Visual assistance",
+}
+
+func describeSpecialRequests(requests []domain.SpecialRequest) []string {
+	descriptions := make([]string, len(requests))
+	for i, request := range requests {
+		description, ok := specialRequestDescriptions[request]
+		if !ok {
+			description = string(request)
+		}
+
+		descriptions[i] = description
+	}
+
+	return descriptions
+}
+
+func reservationICSFilename(reservationId int) string {
+	return fmt.Sprintf("reservation-%d.ics", reservationId)
+}
+
+// renderReservationICS builds an iCalendar document with a single event spanning the screening.
+// stamp is used as DTSTAMP, i.e. the time the calendar object was created.
+func renderReservationICS(detail *domain.ReservationDetail, stamp time.Time) []byte {
+	var buf bytes.Buffer
+	writeICSCalendarStart(&buf)
+	writeReservationEvent(&buf, detail, stamp)
+	writeICSLine(&buf, "END:VCALENDAR")
+
+	return buf.Bytes()
+}
+
+func writeICSCalendarStart(buf *bytes.Buffer) {
+	writeICSLine(buf, "BEGIN:VCALENDAR")
+	writeICSLine(buf, "VERSION:2.0")
+	writeICSLine(buf, "PRODID:"+icsProductID)
+	writeICSLine(buf, "CALSCALE:GREGORIAN")
+	writeICSLine(buf, "METHOD:PUBLISH")
+}
+
+// writeReservationEvent writes the event of the reservation. Its UID only depends on the reservation, so a
+// calendar replaces the event instead of adding another one when it's imported again.
+func writeReservationEvent(buf *bytes.Buffer, detail *domain.ReservationDetail, stamp time.Time) {
+	start := detail.ShowtimeDate.UTC()
+	end := start.Add(time.Duration(detail.MovieDuration) * time.Minute)
+
+	seats := make([]string, len(detail.Seats))
+	for i, s := range detail.Seats {
+		seats[i] = formatReservationSeat(s)
+	}
+
+	location := strings.Join(nonEmpty(
+		detail.TheaterName,
+		detail.TheaterAddress,
+		detail.TheaterDistrict,
+		detail.TheaterCity,
+	), ", ")
+
+	description := fmt.Sprintf(
+		"Reservation #%d\nTheater: %s\nHall: %s\nSeats: %s",
+		detail.ReservationID,
+		detail.TheaterName,
+		detail.HallName,
+		strings.Join(seats, ", "),
+	)
+
+	writeICSLine(buf, "BEGIN:VEVENT")
+	writeICSLine(buf, fmt.Sprintf("UID:reservation-%d@%s", detail.ReservationID, icsUIDDomain))
+	writeICSLine(buf, "DTSTAMP:"+stamp.UTC().Format(icsTimeFormat))
+	writeICSLine(buf, "DTSTART:"+start.Format(icsTimeFormat))
+	writeICSLine(buf, "DTEND:"+end.Format(icsTimeFormat))
+	writeICSLine(buf, "SUMMARY:"+icsTextEscaper.Replace(detail.MovieTitle))
+	writeICSLine(buf, "LOCATION:"+icsTextEscaper.Replace(location))
+	writeICSLine(buf, fmt.Sprintf("GEO:%.6f;%.6f", detail.TheaterLocation.Latitude, detail.TheaterLocation.Longitude))
+	writeICSLine(buf, "DESCRIPTION:"+icsTextEscaper.Replace(description))
+	writeICSLine(buf, "STATUS:CONFIRMED")
+	writeICSLine(buf, "TRANSP:OPAQUE")
+	writeICSLine(buf, "END:VEVENT")
+}
+
+// writeICSLine writes a CRLF terminated content line, folding it into continuation lines starting
+// with a single space when it exceeds the octet limit. Multi-byte characters are never split.
+func writeICSLine(buf *bytes.Buffer, line string) {
+	limit := icsMaxLineOctets
+
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+
+		buf.WriteString(line[:cut])
+		buf.WriteString("\r\n ")
+		line = line[cut:]
+
+		// the leading space of a continuation line counts towards its length
+		limit = icsMaxLineOctets - 1
+	}
+
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
+
+func formatReservationSeat(s domain.ReservationDetailSeat) string {
+	return fmt.Sprintf("Row %d Seat %d (%s)", s.Row, s.Col, s.Type)
+}
+
+func nonEmpty(values ...string) []string {
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != "" {
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,125 @@
+package app
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+// calendar apps are asked to refresh the feed hourly, most of them poll on their own schedule anyway
+const calendarFeedRefreshInterval = "PT1H"
+
+func (app *Application) RotateCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	logger := app.contextGetLogger(r)
+	userId := app.contextGetUserId(r)
+
+	token, tokenHash, err := domain.GenerateCalendarFeedToken()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.reservationRepo.SaveCalendarFeed(r.Context(), userId, tokenHash)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	logger.Info("calendar feed rotated", "user_id", userId)
+
+	resp := api.CalendarFeedResponse{
+		Url: app.publicURL("/users/me/reservations/feed.ics?token=" + url.QueryEscape(token)),
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) DisableCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	logger := app.contextGetLogger(r)
+	userId := app.contextGetUserId(r)
+
+	err := app.reservationRepo.DeleteCalendarFeed(r.Context(), userId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	logger.Info("calendar feed disabled", "user_id", userId)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetReservationsCalendarFeed serves the calendar feed of the upcoming reservations. Calendar apps fetch it
+// without a session, the token of the URL is what authenticates them.
+func (app *Application) GetReservationsCalendarFeed(
+	w http.ResponseWriter,
+	r *http.Request,
+	params api.GetReservationsCalendarFeedParams) {
+
+	logger := app.contextGetLogger(r)
+
+	hash := sha256.Sum256([]byte(params.Token))
+	now := time.Now()
+
+	reservations, err := app.reservationRepo.GetCalendarFeed(r.Context(), hash[:], now)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	siteName := defaultBrandName
+	if tenant := domain.TenantFromContext(r.Context()); tenant != nil {
+		siteName = tenant.Name
+	}
+
+	// a rotated URL must stop serving the reservations right away
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Content-Type", icsContentType)
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	w.WriteHeader(http.StatusOK)
+
+	_, err = w.Write(renderCalendarFeedICS(siteName+" reservations", reservations, now))
+	if err != nil {
+		logger.Error("failed to write calendar feed", "error", err)
+	}
+}
+
+// renderCalendarFeedICS builds an iCalendar document with an event for every reservation. A reservation
+// left out of the feed is removed from the subscribed calendars on their next refresh.
+func renderCalendarFeedICS(name string, reservations []domain.ReservationDetail, stamp time.Time) []byte {
+	var buf bytes.Buffer
+	writeICSCalendarStart(&buf)
+	writeICSLine(&buf, "X-WR-CALNAME:"+icsTextEscaper.Replace(name))
+	writeICSLine(&buf, "REFRESH-INTERVAL;VALUE=DURATION:"+calendarFeedRefreshInterval)
+	writeICSLine(&buf, "X-PUBLISHED-TTL:"+calendarFeedRefreshInterval)
+
+	for i := range reservations {
+		writeReservationEvent(&buf, &reservations[i], stamp)
+	}
+
+	writeICSLine(&buf, "END:VCALENDAR")
+
+	return buf.Bytes()
+}
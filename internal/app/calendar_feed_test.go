@@ -0,0 +1,200 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRotateCalendarFeed(t *testing.T) {
+	reservationRepo := new(mocks.MockReservationRepo)
+
+	var storedHash []byte
+	reservationRepo.On("SaveCalendarFeed", mock.Anything, 1, mock.Anything).
+		Run(func(args mock.Arguments) { storedHash = args.Get(2).([]byte) }).
+		Return(nil).Once()
+
+	app := newTestApplication(func(a *Application) {
+		a.reservationRepo = reservationRepo
+		a.config.BaseURL = "https://cinex.example/"
+	})
+
+	w, r := executeRequest(t, http.MethodPost, "/users/me/calendar-feed", nil)
+	r = r.WithContext(context.WithValue(r.Context(), SessionKeyUserId, 1))
+
+	app.RotateCalendarFeed(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status code = %d, want %d", w.Code, http.StatusCreated)
+	}
+
+	var resp api.CalendarFeedResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	feedURL, err := url.Parse(resp.Url)
+	if err != nil || feedURL.Host != "cinex.example" || feedURL.Path != "/users/me/reservations/feed.ics" {
+		t.Fatalf("unexpected feed URL %q", resp.Url)
+	}
+
+	// only the hash of the token is stored
+	hash := sha256.Sum256([]byte(feedURL.Query().Get("token")))
+	if string(storedHash) != string(hash[:]) {
+		t.Errorf("stored hash doesn't match the token of the URL")
+	}
+
+	reservationRepo.AssertExpectations(t)
+}
+
+func TestDisableCalendarFeed(t *testing.T) {
+	tests := []struct {
+		name           string
+		deleteErr      error
+		wantStatus     int
+		wantErrMessage string
+	}{
+		{
+			name:           "no feed",
+			deleteErr:      domain.ErrRecordNotFound,
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+		},
+		{
+			name:           "database error",
+			deleteErr:      errors.New("db error"),
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+		{
+			name:       "disabled",
+			wantStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reservationRepo := new(mocks.MockReservationRepo)
+			reservationRepo.On("DeleteCalendarFeed", mock.Anything, 1).Return(tt.deleteErr).Once()
+
+			app := newTestApplication(func(a *Application) {
+				a.reservationRepo = reservationRepo
+			})
+
+			w, r := executeRequest(t, http.MethodDelete, "/users/me/calendar-feed", nil)
+			r = r.WithContext(context.WithValue(r.Context(), SessionKeyUserId, 1))
+
+			app.DisableCalendarFeed(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status code = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			checkErrorResponse(t, w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{tt.wantStatus, tt.wantErrMessage})
+
+			reservationRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetReservationsCalendarFeed(t *testing.T) {
+	token := "feed-token"
+	hash := sha256.Sum256([]byte(token))
+
+	t.Run("feed not found", func(t *testing.T) {
+		reservationRepo := new(mocks.MockReservationRepo)
+		reservationRepo.On("GetCalendarFeed", mock.Anything, hash[:], mock.Anything).
+			Return(nil, domain.ErrRecordNotFound).Once()
+
+		app := newTestApplication(func(a *Application) {
+			a.reservationRepo = reservationRepo
+		})
+
+		w, r := executeRequest(t, http.MethodGet, "/users/me/reservations/feed.ics?token="+token, nil)
+		app.GetReservationsCalendarFeed(w, r, api.GetReservationsCalendarFeedParams{Token: token})
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("status code = %d, want %d", w.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("renders the upcoming reservations", func(t *testing.T) {
+		reservations := []domain.ReservationDetail{
+			{
+				ReservationSummary: domain.ReservationSummary{
+					ReservationID: 7,
+					MovieTitle:    "Dune",
+					ShowtimeDate:  time.Date(2025, 6, 1, 17, 30, 0, 0, time.UTC),
+					TheaterName:   "Kadıköy Cinema",
+					HallName:      "Hall 1",
+				},
+				MovieDuration: 155,
+				Seats:         []domain.ReservationDetailSeat{{Row: 3, Col: 4, Type: "Standard"}},
+			},
+			{
+				ReservationSummary: domain.ReservationSummary{
+					ReservationID: 9,
+					MovieTitle:    "Alien",
+					ShowtimeDate:  time.Date(2025, 6, 3, 20, 0, 0, 0, time.UTC),
+					TheaterName:   "Kadıköy Cinema",
+					HallName:      "Hall 2",
+				},
+				MovieDuration: 117,
+			},
+		}
+
+		reservationRepo := new(mocks.MockReservationRepo)
+		reservationRepo.On("GetCalendarFeed", mock.Anything, hash[:], mock.Anything).
+			Return(reservations, nil).Once()
+
+		app := newTestApplication(func(a *Application) {
+			a.reservationRepo = reservationRepo
+		})
+
+		w, r := executeRequest(t, http.MethodGet, "/users/me/reservations/feed.ics?token="+token, nil)
+		app.GetReservationsCalendarFeed(w, r, api.GetReservationsCalendarFeedParams{Token: token})
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("status code = %d, want %d", w.Code, http.StatusOK)
+		}
+
+		if ct := w.Header().Get("Content-Type"); ct != icsContentType {
+			t.Errorf("Content-Type = %q, want %q", ct, icsContentType)
+		}
+
+		body := w.Body.String()
+
+		if got := strings.Count(body, "BEGIN:VEVENT\r\n"); got != len(reservations) {
+			t.Errorf("feed has %d events, want %d", got, len(reservations))
+		}
+
+		for _, want := range []string{
+			"X-WR-CALNAME:CineX reservations\r\n",
+			"REFRESH-INTERVAL;VALUE=DURATION:PT1H\r\n",
+			"UID:reservation-7@" + icsUIDDomain + "\r\n",
+			"UID:reservation-9@" + icsUIDDomain + "\r\n",
+			"DTSTART:20250601T173000Z\r\n",
+			"DTEND:20250601T200500Z\r\n",
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("feed doesn't contain %q:\n%s", want, body)
+			}
+		}
+
+		reservationRepo.AssertExpectations(t)
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/metinatakli/movie-reservation-system/api"
@@ -18,12 +19,23 @@ const (
 	cartTTL     = 10 * time.Minute
 )
 
+var (
+	errCartAlreadyExists = errors.New("cannot create new cart if a cart already exists in session")
+	errNoCartInSession   = errors.New("there is no cart bound to the current session")
+	// sold seats and seats held in other carts are reported alike, only their error codes differ
+	errSeatsAlreadyReserved = errors.New("some of the selected seats are already reserved")
+	errSeatsAlreadyLocked   = errors.New("some of the selected seats are already reserved")
+	errSeatsBlocked         = errors.New("some of the selected seats are not available")
+)
+
 var lockSeatsScript = redis.NewScript(`
     -- KEYS = seat lock keys (e.g., seat_lock:123:1, seat_lock:123:2 etc.)
-    -- ARGV = [sessionID, ttl]
+    -- ARGV = [owner, ttl], the owner is a session ID or a seat hold
 
+    -- seats locked by the same owner are locked again, so a retried seat hold succeeds
     for i=1, #KEYS do
-        if redis.call("EXISTS", KEYS[i]) == 1 then
+        local owner = redis.call("GET", KEYS[i])
+        if owner and owner ~= ARGV[1] then
             return {err = "seat already locked"} -- Return an error indicator
         end
     end
@@ -35,6 +47,25 @@ var lockSeatsScript = redis.NewScript(`
     return "OK"
 `)
 
+var releaseSeatsScript = redis.NewScript(`
+    -- KEYS = seat lock keys
+    -- ARGV = [owner]
+    -- Returns 1 for every released lock, 0 for the locks that expired or were taken by another owner
+
+    local released = {}
+
+    for i=1, #KEYS do
+        if redis.call("GET", KEYS[i]) == ARGV[1] then
+            redis.call("DEL", KEYS[i])
+            released[i] = 1
+        else
+            released[i] = 0
+        end
+    end
+
+    return released
+`)
+
 func (app *Application) CreateCartHandler(w http.ResponseWriter, r *http.Request, showtimeID int) {
 	logger := app.contextGetLogger(r)
 
@@ -67,41 +98,35 @@ func (app *Application) CreateCartHandler(w http.ResponseWriter, r *http.Request
 
 	if cartId != "" {
 		logger.Warn("cart creation attempt rejected: a cart already exists for this session")
-		app.badRequestResponse(w, r, fmt.Errorf("cannot create new cart if a cart already exists in session"))
+		app.badRequestResponse(w, r, errCartAlreadyExists)
 		return
 	}
 
-	// TODO: Reserved seats can be moved to Redis as well until showtime start time is passed.
-	reservedSeats, err := app.reservationRepo.GetSeatsByShowtimeId(r.Context(), showtimeID)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
-		return
-	}
-
-	reservedSeatIds := make(map[int]bool, len(reservedSeats))
-	for _, rs := range reservedSeats {
-		reservedSeatIds[rs.SeatID] = true
-	}
-
 	seatIds := input.SeatIdList
 
-	for _, seatID := range seatIds {
-		if reservedSeatIds[seatID] {
-			logger.Warn("cart creation conflict: user selected an already reserved seat", "seat_id", seatID)
-			app.editConflictResponseWithErr(w, r, fmt.Errorf("some of the selected seats are already reserved"))
-			return
+	showtimeSeats, err := app.selectableSeats(r.Context(), showtimeID, seatIds)
+	if err != nil {
+		switch {
+		case errors.Is(err, errSeatsAlreadyReserved):
+			logger.Warn("cart creation conflict: user selected an already reserved seat", "requested_seats", seatIds)
+			app.editConflictResponseWithErr(w, r, err)
+		case errors.Is(err, errSeatsBlocked):
+			logger.Warn("cart creation conflict: user selected a blocked seat", "requested_seats", seatIds)
+			app.editConflictResponseWithErr(w, r, err)
+		case errors.Is(err, domain.ErrRecordNotFound):
+			logger.Warn("cart creation failed: one or more requested seat IDs do not exist for the showtime", "requested_seats", seatIds)
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
 		}
-	}
 
-	showtimeSeats, err := app.seatRepo.GetSeatsByShowtimeAndSeatIds(r.Context(), showtimeID, seatIds)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	if len(seatIds) != len(showtimeSeats.Seats) {
-		logger.Warn("cart creation failed: one or more requested seat IDs do not exist for the showtime", "requested_seats", seatIds)
-		app.notFoundResponse(w, r)
+	err = showtimeSeats.SalesWindow.Check(time.Now())
+	if err != nil {
+		logger.Warn("cart creation rejected: tickets of the showtime are not on sale", "showtime_id", showtimeID)
+		app.editConflictResponseWithErr(w, r, err)
 		return
 	}
 
@@ -110,7 +135,7 @@ func (app *Application) CreateCartHandler(w http.ResponseWriter, r *http.Request
 		switch {
 		case errors.Is(err, domain.ErrSeatAlreadyReserved):
 			logger.Warn("cart creation conflict due to race condition: user selected an already locked seat")
-			app.editConflictResponseWithErr(w, r, fmt.Errorf("some of the selected seats are already reserved"))
+			app.editConflictResponseWithErr(w, r, errSeatsAlreadyLocked)
 		default:
 			app.serverErrorResponse(w, r, fmt.Errorf("seats couldn't be acquired: %w", err))
 		}
@@ -118,7 +143,7 @@ func (app *Application) CreateCartHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	cart, err := app.createCart(r.Context(), seatIds, showtimeID, sessionID, showtimeSeats)
+	cart, seatMapVersion, err := app.createCart(r.Context(), seatIds, showtimeID, sessionID, showtimeSeats, app.salesChannel(r, nil))
 	if err != nil {
 		logger.Error("cart creation process failed", "error", err)
 		app.serverErrorResponse(w, r, fmt.Errorf("cart couldn't be created: %w", err))
@@ -129,7 +154,7 @@ func (app *Application) CreateCartHandler(w http.ResponseWriter, r *http.Request
 		Cart: toApiCart(cart),
 	}
 
-	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	err = app.writeJSON(w, http.StatusOK, resp, seatMapVersionHeaders(seatMapVersion))
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -137,16 +162,19 @@ func (app *Application) CreateCartHandler(w http.ResponseWriter, r *http.Request
 
 func toApiCart(cart *domain.Cart) api.Cart {
 	return api.Cart{
-		CartId:       cart.Id,
-		ShowtimeId:   cart.ShowtimeID,
-		MovieName:    cart.MovieName,
-		TheaterName:  cart.TheaterName,
-		HallName:     cart.HallName,
-		ShowtimeDate: cart.Date.Format(time.RFC1123),
-		Seats:        toApiCartSeats(cart.Seats),
-		HoldTime:     int(cartTTL.Seconds()),
-		BasePrice:    cart.BasePrice,
-		TotalPrice:   cart.TotalPrice,
+		CartId:            cart.Id,
+		ShowtimeId:        cart.ShowtimeID,
+		MovieName:         cart.MovieName,
+		TheaterName:       cart.TheaterName,
+		HallName:          cart.HallName,
+		ShowtimeDate:      cart.Date.In(domain.TheaterLocation(cart.TheaterTimeZone)).Format(time.RFC1123),
+		Seats:             toApiCartSeats(cart.Seats),
+		HoldTime:          int(cartTTL.Seconds()),
+		BasePrice:         cart.BasePrice,
+		Format:            api.ScreeningFormat(cart.Format),
+		FormatSurcharge:   cart.FormatSurcharge,
+		FlexibleTicketFee: cart.FlexibleTicketFee,
+		TotalPrice:        cart.TotalPrice,
 	}
 }
 
@@ -168,13 +196,58 @@ func toApiCartSeats(cartSeats []domain.CartSeat) []api.CartSeat {
 	return apiCartSeats
 }
 
-func (app *Application) tryLockSeats(ctx context.Context, seatIDs []int, showtimeID int, sessionID string) error {
+// selectableSeats returns the requested seats of the showtime. Sold seats are reported with
+// errSeatsAlreadyReserved, seats blocked by the theater with errSeatsBlocked and seats that don't belong to
+// the showtime with domain.ErrRecordNotFound.
+func (app *Application) selectableSeats(ctx context.Context, showtimeID int, seatIDs []int) (*domain.ShowtimeSeats, error) {
+	// TODO: Reserved seats can be moved to Redis as well until showtime start time is passed.
+	reservedSeats, err := app.reservationRepo.GetSeatsByShowtimeId(ctx, showtimeID)
+	if err != nil {
+		return nil, err
+	}
+
+	reservedSeatIds := make(map[int]bool, len(reservedSeats))
+	for _, rs := range reservedSeats {
+		reservedSeatIds[rs.SeatID] = true
+	}
+
+	for _, seatID := range seatIDs {
+		if reservedSeatIds[seatID] {
+			return nil, errSeatsAlreadyReserved
+		}
+	}
+
+	blockedSeatIds, err := app.blockedSeatIDs(ctx, showtimeID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, seatID := range seatIDs {
+		if blockedSeatIds[seatID] {
+			return nil, errSeatsBlocked
+		}
+	}
+
+	showtimeSeats, err := app.seatRepo.GetSeatsByShowtimeAndSeatIds(ctx, showtimeID, seatIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(seatIDs) != len(showtimeSeats.Seats) {
+		return nil, domain.ErrRecordNotFound
+	}
+
+	return showtimeSeats, nil
+}
+
+// tryLockSeats locks the seats for the owner, a session ID or a seat hold.
+func (app *Application) tryLockSeats(ctx context.Context, seatIDs []int, showtimeID int, owner string) error {
 	keys := make([]string, len(seatIDs))
 	for i, seatID := range seatIDs {
 		keys[i] = seatLockKey(showtimeID, seatID)
 	}
 
-	err := lockSeatsScript.Run(ctx, app.redis, keys, sessionID, int(seatLockTTL.Seconds())).Err()
+	err := lockSeatsScript.Run(ctx, app.redis, keys, owner, int(seatLockTTL.Seconds())).Err()
 	if err != nil {
 		if redis.HasErrorPrefix(err, "seat already locked") {
 			return domain.ErrSeatAlreadyReserved
@@ -191,13 +264,16 @@ func (app *Application) createCart(
 	seatIDs []int,
 	showtimeID int,
 	sessionID string,
-	showtimeSeats *domain.ShowtimeSeats) (*domain.Cart, error) {
+	showtimeSeats *domain.ShowtimeSeats,
+	channel domain.SalesChannel) (*domain.Cart, int, error) {
 
 	cart := domain.NewCart(showtimeID, showtimeSeats)
+	cart.FlexibleTicketFee = app.config.FlexibleTicketFee
+	cart.SalesChannel = channel
 	cartBytes, err := json.Marshal(cart)
 	if err != nil {
 		app.rollbackSeatLocks(ctx, showtimeID, seatIDs)
-		return nil, err
+		return nil, 0, err
 	}
 
 	cartPipe := app.redis.TxPipeline()
@@ -214,10 +290,12 @@ func (app *Application) createCart(
 	_, err = cartPipe.Exec(ctx)
 	if err != nil {
 		app.rollbackSeatLocks(ctx, showtimeID, seatIDs)
-		return nil, err
+		return nil, 0, err
 	}
 
-	return &cart, nil
+	seatMapVersion := app.publishSeatEvent(ctx, showtimeID, seatEventLocked, seatIDs)
+
+	return &cart, seatMapVersion, nil
 }
 
 func (app *Application) rollbackSeatLocks(ctx context.Context, showtimeID int, seatIDs []int) {
@@ -305,6 +383,21 @@ func (app *Application) DeleteCartHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// a checkout session left open could still be paid for seats that are no longer held
+	if cart.CheckoutSessionID != "" {
+		err = app.paymentProvider.ExpireCheckoutSession(r.Context(), cart.CheckoutSessionID)
+		if err != nil {
+			switch {
+			case errors.Is(err, domain.ErrCartAlreadyPaid):
+				logger.Warn("cart deletion attempt after its checkout was paid", "cart_id", cartId)
+				app.editConflictResponseWithErr(w, r, err)
+			default:
+				app.serverErrorResponse(w, r, fmt.Errorf("failed to expire checkout session of the cart: %w", err))
+			}
+			return
+		}
+	}
+
 	pipe := app.redis.TxPipeline()
 
 	for _, seat := range cart.Seats {
@@ -321,6 +414,12 @@ func (app *Application) DeleteCartHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	seatMapVersion := app.publishSeatEvent(r.Context(), showtimeID, seatEventReleased, cart.SeatIDs())
+
+	if seatMapVersion > 0 {
+		w.Header().Set(seatMapVersionHeader, strconv.Itoa(seatMapVersion))
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -409,3 +508,153 @@ func (app *Application) migrateSessionData(ctx context.Context, oldSessionId, ne
 
 	return nil
 }
+
+func (app *Application) GetCartPriceBreakdown(w http.ResponseWriter, r *http.Request) {
+	logger := app.contextGetLogger(r)
+
+	sessionId := app.sessionManager.Token(r.Context())
+	cartId, err := app.redis.Get(r.Context(), cartSessionKey(sessionId)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			app.notFoundResponseWithErr(w, r, errNoCartInSession)
+			return
+		}
+
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	cart, err := app.getAndVerifyCart(r.Context(), cartId, sessionId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrCartNotFound):
+			logger.Warn("price breakdown failed: cart has expired or was not found", "cart_id", cartId)
+			app.notFoundResponseWithErr(w, r, err)
+		case errors.Is(err, domain.ErrSeatLockExpired), errors.Is(err, domain.ErrSeatConflict):
+			logger.Warn("price breakdown failed: seat locks of the cart are no longer valid", "cart_id", cartId)
+			app.editConflictResponseWithErr(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	resp := toPriceBreakdownResponse(cart.Id, cart.PriceBreakdown())
+
+	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// QuoteShowtimePrice prices seats with the rules of the checkout without locking them, so it can be called
+// on every click of the seat selection.
+func (app *Application) QuoteShowtimePrice(w http.ResponseWriter, r *http.Request, showtimeID int) {
+	if showtimeID < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("showtime ID must be greater than zero"))
+		return
+	}
+
+	var input api.PriceQuoteRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	showtimeSeats, err := app.selectableSeats(r.Context(), showtimeID, input.SeatIdList)
+	if err != nil {
+		switch {
+		case errors.Is(err, errSeatsAlreadyReserved), errors.Is(err, errSeatsBlocked):
+			app.editConflictResponseWithErr(w, r, err)
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = showtimeSeats.SalesWindow.Check(time.Now())
+	if err != nil {
+		app.editConflictResponseWithErr(w, r, err)
+		return
+	}
+
+	breakdown := showtimeSeats.PriceBreakdown()
+
+	resp := api.PriceQuoteResponse{
+		ShowtimeId:      showtimeID,
+		Currency:        breakdown.Currency,
+		Seats:           toPriceBreakdownSeats(breakdown.Seats),
+		BaseTotal:       breakdown.BaseTotal,
+		SurchargesTotal: breakdown.SurchargesTotal,
+		ExtrasTotal:     breakdown.ExtrasTotal,
+		Subtotal:        breakdown.Subtotal,
+		Discounts:       toPriceAdjustments(breakdown.Discounts),
+		Taxes:           toPriceAdjustments(breakdown.Taxes),
+		Total:           breakdown.Total,
+	}
+
+	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func toPriceBreakdownResponse(cartId string, breakdown domain.PriceBreakdown) api.PriceBreakdownResponse {
+	return api.PriceBreakdownResponse{
+		CartId:          cartId,
+		Currency:        breakdown.Currency,
+		Seats:           toPriceBreakdownSeats(breakdown.Seats),
+		BaseTotal:       breakdown.BaseTotal,
+		SurchargesTotal: breakdown.SurchargesTotal,
+		ExtrasTotal:     breakdown.ExtrasTotal,
+		AddOns:          toPriceAdjustments(breakdown.AddOns),
+		Subtotal:        breakdown.Subtotal,
+		Discounts:       toPriceAdjustments(breakdown.Discounts),
+		Taxes:           toPriceAdjustments(breakdown.Taxes),
+		Total:           breakdown.Total,
+	}
+}
+
+func toPriceBreakdownSeats(seatPrices []domain.SeatPrice) []api.PriceBreakdownSeat {
+	seats := make([]api.PriceBreakdownSeat, len(seatPrices))
+
+	for i, s := range seatPrices {
+		seats[i] = api.PriceBreakdownSeat{
+			SeatId:          s.SeatID,
+			Row:             s.Row,
+			Column:          s.Col,
+			Type:            api.SeatType(s.SeatType),
+			BasePrice:       s.BasePrice,
+			FormatSurcharge: s.FormatSurcharge,
+			ExtraPrice:      s.ExtraPrice,
+			Price:           s.Price,
+		}
+	}
+
+	return seats
+}
+
+func toPriceAdjustments(adjustments []domain.PriceAdjustment) []api.PriceAdjustment {
+	apiAdjustments := make([]api.PriceAdjustment, len(adjustments))
+
+	for i, a := range adjustments {
+		apiAdjustments[i] = api.PriceAdjustment{
+			Code:        a.Code,
+			Description: a.Description,
+			Amount:      a.Amount,
+		}
+	}
+
+	return apiAdjustments
+}
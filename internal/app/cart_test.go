@@ -1,10 +1,13 @@
 package app
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
@@ -47,7 +50,11 @@ const (
 				"SeatType": "Standard",
 				"ExtraPrice": "5.00"
 			}
-  		]
+  		],
+		"SeatPrices": [
+			{"SeatID": 1, "Row": 5, "Col": 7, "SeatType": "VIP", "ExtraPrice": "10.00", "Price": "10.00"},
+			{"SeatID": 2, "Row": 5, "Col": 8, "SeatType": "Standard", "ExtraPrice": "5.00", "Price": "5.00"}
+		]
 	}`
 )
 
@@ -68,6 +75,7 @@ type CartTestSuite struct {
 	reservationRepo *mocks.MockReservationRepo
 	redisClient     *mocks.MockRedisClient
 	redisPipeline   *mocks.MockTxPipeline
+	paymentProvider *mocks.MockPaymentProvider
 }
 
 func (s *CartTestSuite) SetupTest() {
@@ -75,8 +83,10 @@ func (s *CartTestSuite) SetupTest() {
 	s.reservationRepo = new(mocks.MockReservationRepo)
 	s.redisClient = new(mocks.MockRedisClient)
 	s.redisPipeline = new(mocks.MockTxPipeline)
+	s.paymentProvider = new(mocks.MockPaymentProvider)
 
 	s.app = newTestApplication(func(a *Application) {
+		a.paymentProvider = s.paymentProvider
 		a.seatRepo = s.seatRepo
 		a.reservationRepo = s.reservationRepo
 		a.sessionManager = scs.New()
@@ -96,6 +106,7 @@ func (s *CartTestSuite) TestCreateCartHandler() {
 		setupMocks     func()
 		wantStatus     int
 		wantErrMessage string
+		wantErrCode    api.ErrorCode
 		wantResponse   *api.CartResponse
 	}{
 		{
@@ -103,6 +114,7 @@ func (s *CartTestSuite) TestCreateCartHandler() {
 			showtimeID:     0,
 			wantStatus:     http.StatusBadRequest,
 			wantErrMessage: "showtime ID must be greater than zero",
+			wantErrCode:    api.BADREQUEST,
 		},
 		{
 			name:       "should fail when seat list is empty",
@@ -112,6 +124,7 @@ func (s *CartTestSuite) TestCreateCartHandler() {
 			},
 			wantStatus:     http.StatusUnprocessableEntity,
 			wantErrMessage: fmt.Sprintf(validator.ErrArrayMinLength, "1"),
+			wantErrCode:    api.VALIDATIONFAILED,
 		},
 		{
 			name:       "should fail when seat IDs contain negative numbers",
@@ -142,6 +155,7 @@ func (s *CartTestSuite) TestCreateCartHandler() {
 			},
 			wantStatus:     http.StatusBadRequest,
 			wantErrMessage: "cannot create new cart if a cart already exists in session",
+			wantErrCode:    api.CARTALREADYEXISTS,
 		},
 		{
 			name:       "should fail when database error occurs while fetching reserved seats",
@@ -155,6 +169,7 @@ func (s *CartTestSuite) TestCreateCartHandler() {
 			},
 			wantStatus:     http.StatusInternalServerError,
 			wantErrMessage: ErrInternalServer,
+			wantErrCode:    api.INTERNALERROR,
 		},
 		{
 			name:       "should fail when some of requested seatIds are already reserved",
@@ -174,6 +189,24 @@ func (s *CartTestSuite) TestCreateCartHandler() {
 			},
 			wantStatus:     http.StatusConflict,
 			wantErrMessage: "some of the selected seats are already reserved",
+			wantErrCode:    api.SEATALREADYRESERVED,
+		},
+		{
+			name:       "should fail when some of requested seatIds are blocked",
+			showtimeID: 1,
+			input: api.CreateCartRequest{
+				SeatIdList: testSeatIDs,
+			},
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, mock.Anything).Return(redis.NewStringCmd(context.Background(), ""))
+				s.reservationRepo.On("GetSeatsByShowtimeId", mock.Anything, 1).Return([]domain.ReservationSeat{}, nil)
+				s.seatRepo.On("GetSeatBlocksByShowtime", mock.Anything, 1).Return([]domain.SeatBlock{
+					{ID: 1, SeatID: 2, ShowtimeID: ptr(1), Reason: domain.SeatBlockHouse},
+				}, nil)
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: "some of the selected seats are not available",
+			wantErrCode:    api.SEATBLOCKED,
 		},
 		{
 			name:       "should fail when database error occurs while fetching seats by showtime",
@@ -190,10 +223,12 @@ func (s *CartTestSuite) TestCreateCartHandler() {
 						SeatID:        4,
 					},
 				}, nil)
+				s.seatRepo.On("GetSeatBlocksByShowtime", mock.Anything, 1).Return([]domain.SeatBlock{}, nil)
 				s.seatRepo.On("GetSeatsByShowtimeAndSeatIds", mock.Anything, 1, testSeatIDs).Return(nil, fmt.Errorf("database error"))
 			},
 			wantStatus:     http.StatusInternalServerError,
 			wantErrMessage: ErrInternalServer,
+			wantErrCode:    api.INTERNALERROR,
 		},
 		{
 			name:       "should fail when requested seats are not available for showtime",
@@ -210,12 +245,14 @@ func (s *CartTestSuite) TestCreateCartHandler() {
 						SeatID:        4,
 					},
 				}, nil)
+				s.seatRepo.On("GetSeatBlocksByShowtime", mock.Anything, 1).Return([]domain.SeatBlock{}, nil)
 				s.seatRepo.On("GetSeatsByShowtimeAndSeatIds", mock.Anything, 1, testSeatIDs).Return(&domain.ShowtimeSeats{
 					Seats: testSeats[:1],
 				}, nil)
 			},
 			wantStatus:     http.StatusNotFound,
 			wantErrMessage: ErrNotFound,
+			wantErrCode:    api.NOTFOUND,
 		},
 		{
 			name:       "should handle concurrent seat locking failures",
@@ -232,6 +269,7 @@ func (s *CartTestSuite) TestCreateCartHandler() {
 						SeatID:        4,
 					},
 				}, nil)
+				s.seatRepo.On("GetSeatBlocksByShowtime", mock.Anything, 1).Return([]domain.SeatBlock{}, nil)
 				s.seatRepo.On("GetSeatsByShowtimeAndSeatIds", mock.Anything, 1, testSeatIDs).Return(&domain.ShowtimeSeats{
 					Seats: testSeats,
 				}, nil)
@@ -240,6 +278,7 @@ func (s *CartTestSuite) TestCreateCartHandler() {
 			},
 			wantStatus:     http.StatusConflict,
 			wantErrMessage: "some of the selected seats are already reserved",
+			wantErrCode:    api.SEATALREADYLOCKED,
 		},
 		{
 			name:       "should handle Redis pipeline execution failures during cart creation",
@@ -256,6 +295,7 @@ func (s *CartTestSuite) TestCreateCartHandler() {
 						SeatID:        4,
 					},
 				}, nil)
+				s.seatRepo.On("GetSeatBlocksByShowtime", mock.Anything, 1).Return([]domain.SeatBlock{}, nil)
 				s.seatRepo.On("GetSeatsByShowtimeAndSeatIds", mock.Anything, 1, testSeatIDs).Return(&domain.ShowtimeSeats{
 					Seats: testSeats,
 				}, nil)
@@ -281,6 +321,7 @@ func (s *CartTestSuite) TestCreateCartHandler() {
 			},
 			wantStatus:     http.StatusInternalServerError,
 			wantErrMessage: ErrInternalServer,
+			wantErrCode:    api.INTERNALERROR,
 		},
 		{
 			name:       "should successfully create cart with valid input",
@@ -297,6 +338,7 @@ func (s *CartTestSuite) TestCreateCartHandler() {
 						SeatID:        4,
 					},
 				}, nil)
+				s.seatRepo.On("GetSeatBlocksByShowtime", mock.Anything, 1).Return([]domain.SeatBlock{}, nil)
 				s.seatRepo.On("GetSeatsByShowtimeAndSeatIds", mock.Anything, 1, testSeatIDs).Return(&domain.ShowtimeSeats{
 					Seats:       testSeats,
 					Price:       testBasePrice,
@@ -318,6 +360,8 @@ func (s *CartTestSuite) TestCreateCartHandler() {
 					redis.NewBoolResult(true, nil),
 					redis.NewBoolResult(true, nil),
 				}, nil)
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatMapChangeKeys(1), seatEventsChannel(1), mock.Anything, mock.Anything).
+					Return(redis.NewCmdResult(int64(1), nil)).Once()
 			},
 			wantStatus: http.StatusOK,
 			wantResponse: &api.CartResponse{
@@ -338,6 +382,69 @@ func (s *CartTestSuite) TestCreateCartHandler() {
 				},
 			},
 		},
+		{
+			name:       "should apply the surcharge of the showtime format to every seat",
+			showtimeID: 1,
+			input: api.CreateCartRequest{
+				SeatIdList: testSeatIDs,
+			},
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, mock.Anything).Return(redis.NewStringCmd(context.Background(), ""))
+				s.reservationRepo.On("GetSeatsByShowtimeId", mock.Anything, 1).Return([]domain.ReservationSeat{
+					{
+						ReservationID: 1,
+						ShowtimeID:    1,
+						SeatID:        4,
+					},
+				}, nil)
+				s.seatRepo.On("GetSeatBlocksByShowtime", mock.Anything, 1).Return([]domain.SeatBlock{}, nil)
+				s.seatRepo.On("GetSeatsByShowtimeAndSeatIds", mock.Anything, 1, testSeatIDs).Return(&domain.ShowtimeSeats{
+					Seats:           testSeats,
+					Price:           testBasePrice,
+					MovieName:       movieName,
+					TheaterName:     theaterName,
+					HallName:        hallName,
+					Date:            showtimeDate,
+					Format:          domain.FormatIMAX,
+					FormatSurcharge: decimal.RequireFromString("5.00"),
+				}, nil)
+
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, []string{seatLockKey(1, 1), seatLockKey(1, 2), seatLockKey(1, 3)}, mock.Anything, mock.Anything).
+					Return(redis.NewCmdResult(nil, nil)).Once()
+
+				s.redisClient.On("TxPipeline").Return(s.redisPipeline)
+				s.redisPipeline.On("SAdd", mock.Anything, "seat_locks:1", []interface{}{1, 2, 3}).Return(redis.NewIntCmd(context.Background(), 1))
+				s.redisPipeline.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(redis.NewStatusCmd(context.Background(), "OK"))
+				s.redisPipeline.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(redis.NewStatusCmd(context.Background(), "OK"))
+				s.redisPipeline.On("Exec", mock.Anything).Return([]redis.Cmder{
+					redis.NewBoolResult(true, nil),
+					redis.NewBoolResult(true, nil),
+					redis.NewBoolResult(true, nil),
+				}, nil)
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatMapChangeKeys(1), seatEventsChannel(1), mock.Anything, mock.Anything).
+					Return(redis.NewCmdResult(int64(1), nil)).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: &api.CartResponse{
+				Cart: api.Cart{
+					ShowtimeId: 1,
+					Seats: []api.CartSeat{
+						{Id: 1, Row: 1, Column: 1, Type: api.Standard, Price: decimal.NewFromFloat(0)},
+						{Id: 2, Row: 1, Column: 2, Type: api.VIP, Price: decimal.NewFromFloat(15)},
+						{Id: 3, Row: 1, Column: 3, Type: api.Recliner, Price: decimal.NewFromFloat(10)},
+					},
+					HoldTime:        int(cartTTL.Seconds()),
+					TotalPrice:      decimal.NewFromFloat(190),
+					BasePrice:       decimal.NewFromFloat(testBasePrice),
+					Format:          api.IMAX,
+					FormatSurcharge: decimal.RequireFromString("5.00"),
+					MovieName:       movieName,
+					TheaterName:     theaterName,
+					HallName:        hallName,
+					ShowtimeDate:    showtimeDate.Format(time.RFC1123),
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -363,7 +470,14 @@ func (s *CartTestSuite) TestCreateCartHandler() {
 
 			s.Equal(tt.wantStatus, w.Code)
 
+			if tt.wantErrCode != "" {
+				checkErrorCode(s.T(), w, tt.wantErrCode)
+			}
+
 			if tt.wantResponse != nil {
+				// the version of the seat event recorded by the mock
+				s.Equal("1", w.Header().Get(seatMapVersionHeader))
+
 				var response api.CartResponse
 				err := json.NewDecoder(w.Body).Decode(&response)
 				s.Require().NoError(err, "Failed to decode response")
@@ -385,18 +499,22 @@ func (s *CartTestSuite) TestCreateCartHandler() {
 }
 
 func (s *CartTestSuite) TestDeleteCartHandler() {
+	checkoutCartDataStr := strings.Replace(cartDataStr, `"ShowtimeID": 1,`, `"ShowtimeID": 1, "CheckoutSessionID": "cs_123",`, 1)
+
 	tests := []struct {
 		name           string
 		showtimeID     int
 		setupMocks     func()
 		wantStatus     int
 		wantErrMessage string
+		wantErrCode    api.ErrorCode
 	}{
 		{
 			name:           "should fail when showtime ID is zero or negative",
 			showtimeID:     0,
 			wantStatus:     http.StatusBadRequest,
 			wantErrMessage: "showtime ID must be greater than zero",
+			wantErrCode:    api.BADREQUEST,
 		},
 		{
 			name:       "should fail when Redis get operation for retrieving cartId fails",
@@ -406,6 +524,7 @@ func (s *CartTestSuite) TestDeleteCartHandler() {
 			},
 			wantStatus:     http.StatusInternalServerError,
 			wantErrMessage: ErrInternalServer,
+			wantErrCode:    api.INTERNALERROR,
 		},
 		{
 			name:       "should fail when there is no cart bound to the current session",
@@ -415,6 +534,7 @@ func (s *CartTestSuite) TestDeleteCartHandler() {
 			},
 			wantStatus:     http.StatusNotFound,
 			wantErrMessage: ErrNotFound,
+			wantErrCode:    api.NOTFOUND,
 		},
 		{
 			name:       "should fail when Redis get operation for retrieving cart data fails",
@@ -425,6 +545,7 @@ func (s *CartTestSuite) TestDeleteCartHandler() {
 			},
 			wantStatus:     http.StatusInternalServerError,
 			wantErrMessage: ErrInternalServer,
+			wantErrCode:    api.INTERNALERROR,
 		},
 		{
 			name:       "should fail when sent showtimeID is not matched with the cart's showtimeID",
@@ -435,6 +556,7 @@ func (s *CartTestSuite) TestDeleteCartHandler() {
 			},
 			wantStatus:     http.StatusNotFound,
 			wantErrMessage: ErrNotFound,
+			wantErrCode:    api.NOTFOUND,
 		},
 		{
 			name:       "should fail when Redis pipeline responsible for deleting cart and seat related data fails",
@@ -455,6 +577,7 @@ func (s *CartTestSuite) TestDeleteCartHandler() {
 			},
 			wantStatus:     http.StatusInternalServerError,
 			wantErrMessage: ErrInternalServer,
+			wantErrCode:    api.INTERNALERROR,
 		},
 		{
 			name:       "should delete cart and seat related data successfully with valid input",
@@ -472,6 +595,49 @@ func (s *CartTestSuite) TestDeleteCartHandler() {
 				s.redisPipeline.On("Del", mock.Anything, cartID).Return(redis.NewIntResult(1, nil))
 				s.redisPipeline.On("Del", mock.Anything, mock.Anything).Return(redis.NewIntResult(1, nil))
 				s.redisPipeline.On("Exec", mock.Anything).Return([]redis.Cmder{}, nil)
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatMapChangeKeys(testShowtimeID), seatEventsChannel(testShowtimeID), mock.Anything, mock.Anything).
+					Return(redis.NewCmdResult(int64(1), nil)).Once()
+			},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "should fail when the checkout session of the cart is already paid",
+			showtimeID: testShowtimeID,
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, mock.Anything).Return(redis.NewStringResult(cartID, nil)).Once()
+				s.redisClient.On("Get", mock.Anything, cartID).Return(redis.NewStringResult(checkoutCartDataStr, nil)).Once()
+				s.paymentProvider.On("ExpireCheckoutSession", "cs_123").Return(domain.ErrCartAlreadyPaid).Once()
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: domain.ErrCartAlreadyPaid.Error(),
+			wantErrCode:    api.CARTALREADYPAID,
+		},
+		{
+			name:       "should keep the cart when its checkout session fails to be expired",
+			showtimeID: testShowtimeID,
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, mock.Anything).Return(redis.NewStringResult(cartID, nil)).Once()
+				s.redisClient.On("Get", mock.Anything, cartID).Return(redis.NewStringResult(checkoutCartDataStr, nil)).Once()
+				s.paymentProvider.On("ExpireCheckoutSession", "cs_123").Return(errors.New("stripe unavailable")).Once()
+			},
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+			wantErrCode:    api.INTERNALERROR,
+		},
+		{
+			name:       "should expire the checkout session of the cart before deleting it",
+			showtimeID: testShowtimeID,
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, mock.Anything).Return(redis.NewStringResult(cartID, nil)).Once()
+				s.redisClient.On("Get", mock.Anything, cartID).Return(redis.NewStringResult(checkoutCartDataStr, nil)).Once()
+				s.paymentProvider.On("ExpireCheckoutSession", "cs_123").Return(nil).Once()
+				s.redisClient.On("TxPipeline", mock.Anything, mock.Anything).Return(s.redisPipeline)
+
+				s.redisPipeline.On("Del", mock.Anything, mock.Anything).Return(redis.NewIntResult(1, nil))
+				s.redisPipeline.On("SRem", mock.Anything, seatSetKey(testShowtimeID), mock.Anything).Return(redis.NewIntResult(1, nil))
+				s.redisPipeline.On("Exec", mock.Anything).Return([]redis.Cmder{}, nil)
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatMapChangeKeys(testShowtimeID), seatEventsChannel(testShowtimeID), mock.Anything, mock.Anything).
+					Return(redis.NewCmdResult(int64(1), nil)).Once()
 			},
 			wantStatus: http.StatusNoContent,
 		},
@@ -482,6 +648,7 @@ func (s *CartTestSuite) TestDeleteCartHandler() {
 			s.SetupTest()
 
 			defer s.redisClient.AssertExpectations(s.T())
+			defer s.paymentProvider.AssertExpectations(s.T())
 
 			if tt.setupMocks != nil {
 				tt.setupMocks()
@@ -498,6 +665,410 @@ func (s *CartTestSuite) TestDeleteCartHandler() {
 
 			s.Equal(tt.wantStatus, w.Code)
 
+			if tt.wantStatus == http.StatusNoContent {
+				s.Equal("1", w.Header().Get(seatMapVersionHeader))
+			}
+
+			if tt.wantErrCode != "" {
+				checkErrorCode(s.T(), w, tt.wantErrCode)
+			}
+
+			checkErrorResponse(s.T(), w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+		})
+	}
+}
+
+func (s *CartTestSuite) TestGetCartPriceBreakdown() {
+	tests := []struct {
+		name           string
+		setupMocks     func(string)
+		wantStatus     int
+		wantErrMessage string
+		wantErrCode    api.ErrorCode
+		wantSeatLocks  *api.SeatLocksConflict
+		wantResponse   *api.PriceBreakdownResponse
+	}{
+		{
+			name: "should fail when there is no cart bound to the current session",
+			setupMocks: func(sessionId string) {
+				s.redisClient.On("Get", mock.Anything, cartSessionKey(sessionId)).Return(redis.NewStringResult("", redis.Nil)).Once()
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: "there is no cart bound to the current session",
+			wantErrCode:    api.CARTNOTFOUND,
+		},
+		{
+			name: "should fail when cart has expired",
+			setupMocks: func(sessionId string) {
+				s.redisClient.On("Get", mock.Anything, cartSessionKey(sessionId)).Return(redis.NewStringResult(cartID, nil)).Once()
+				s.redisClient.On("Get", mock.Anything, cartID).Return(redis.NewStringResult("", redis.Nil)).Once()
+				s.redisClient.On("Del", mock.Anything, []string{cartSessionKey(sessionId)}).Return(redis.NewIntResult(1, nil)).Once()
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: domain.ErrCartNotFound.Error(),
+			wantErrCode:    api.CARTEXPIRED,
+		},
+		{
+			name: "should fail when seat locks of the cart have expired",
+			setupMocks: func(sessionId string) {
+				s.redisClient.On("Get", mock.Anything, cartSessionKey(sessionId)).Return(redis.NewStringResult(cartID, nil)).Once()
+				s.redisClient.On("Get", mock.Anything, cartID).Return(redis.NewStringResult(cartDataStr, nil)).Once()
+				s.redisClient.On("Get", mock.Anything, seatLockKey(testShowtimeID, 1)).Return(redis.NewStringResult("", redis.Nil)).Once()
+				s.redisClient.On("Get", mock.Anything, seatLockKey(testShowtimeID, 2)).Return(redis.NewStringResult(sessionId, nil)).Once()
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: domain.ErrSeatLockExpired.Error(),
+			wantErrCode:    api.CARTEXPIRED,
+			wantSeatLocks: &api.SeatLocksConflict{
+				ExpiredSeatIds:     []int{1},
+				ConflictingSeatIds: []int{},
+				HeldSeatIds:        []int{2},
+				SuggestedAction:    api.SELECTSEATSAGAIN,
+			},
+		},
+		{
+			name: "should tell which seats of the cart are held by another session",
+			setupMocks: func(sessionId string) {
+				s.redisClient.On("Get", mock.Anything, cartSessionKey(sessionId)).Return(redis.NewStringResult(cartID, nil)).Once()
+				s.redisClient.On("Get", mock.Anything, cartID).Return(redis.NewStringResult(cartDataStr, nil)).Once()
+				s.redisClient.On("Get", mock.Anything, seatLockKey(testShowtimeID, 1)).Return(redis.NewStringResult("", redis.Nil)).Once()
+				s.redisClient.On("Get", mock.Anything, seatLockKey(testShowtimeID, 2)).Return(redis.NewStringResult("other-session", nil)).Once()
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: domain.ErrSeatConflict.Error(),
+			wantErrCode:    api.SEATCONFLICT,
+			wantSeatLocks: &api.SeatLocksConflict{
+				ExpiredSeatIds:     []int{1},
+				ConflictingSeatIds: []int{2},
+				HeldSeatIds:        []int{},
+				SuggestedAction:    api.CHOOSEOTHERSEATS,
+			},
+		},
+		{
+			name: "should return the price breakdown of the cart",
+			setupMocks: func(sessionId string) {
+				s.redisClient.On("Get", mock.Anything, cartSessionKey(sessionId)).Return(redis.NewStringResult(cartID, nil)).Once()
+				s.redisClient.On("Get", mock.Anything, cartID).Return(redis.NewStringResult(cartDataStr, nil)).Once()
+				s.redisClient.On("Get", mock.Anything, seatLockKey(testShowtimeID, 1)).Return(redis.NewStringResult(sessionId, nil)).Once()
+				s.redisClient.On("Get", mock.Anything, seatLockKey(testShowtimeID, 2)).Return(redis.NewStringResult(sessionId, nil)).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: &api.PriceBreakdownResponse{
+				CartId:   cartID,
+				Currency: domain.DefaultCurrency,
+				Seats: []api.PriceBreakdownSeat{
+					{
+						SeatId:     1,
+						Row:        5,
+						Column:     7,
+						Type:       api.VIP,
+						BasePrice:  decimal.Zero,
+						ExtraPrice: decimal.RequireFromString("10.00"),
+						Price:      decimal.RequireFromString("10.00"),
+					},
+					{
+						SeatId:     2,
+						Row:        5,
+						Column:     8,
+						Type:       api.Standard,
+						BasePrice:  decimal.Zero,
+						ExtraPrice: decimal.RequireFromString("5.00"),
+						Price:      decimal.RequireFromString("5.00"),
+					},
+				},
+				BaseTotal:   decimal.Zero,
+				ExtrasTotal: decimal.RequireFromString("15.00"),
+				AddOns:      []api.PriceAdjustment{},
+				Subtotal:    decimal.RequireFromString("15.00"),
+				Discounts:   []api.PriceAdjustment{},
+				Taxes:       []api.PriceAdjustment{},
+				Total:       decimal.RequireFromString("15.00"),
+			},
+		},
+		{
+			name: "should keep the prices the cart was created with",
+			setupMocks: func(sessionId string) {
+				repricedCart := `{"ShowtimeID": 1, "BasePrice": "20.00",
+					"Seats": [{"Id": 1, "ExtraPrice": "10.00"}],
+					"SeatPrices": [{"SeatID": 1, "BasePrice": "12.00", "ExtraPrice": "10.00", "Price": "22.00"}]}`
+
+				s.redisClient.On("Get", mock.Anything, cartSessionKey(sessionId)).Return(redis.NewStringResult(cartID, nil)).Once()
+				s.redisClient.On("Get", mock.Anything, cartID).Return(redis.NewStringResult(repricedCart, nil)).Once()
+				s.redisClient.On("Get", mock.Anything, seatLockKey(testShowtimeID, 1)).Return(redis.NewStringResult(sessionId, nil)).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: &api.PriceBreakdownResponse{
+				CartId:   cartID,
+				Currency: domain.DefaultCurrency,
+				Seats: []api.PriceBreakdownSeat{
+					{
+						SeatId:     1,
+						BasePrice:  decimal.RequireFromString("12.00"),
+						ExtraPrice: decimal.RequireFromString("10.00"),
+						Price:      decimal.RequireFromString("22.00"),
+					},
+				},
+				BaseTotal:   decimal.RequireFromString("12.00"),
+				ExtrasTotal: decimal.RequireFromString("10.00"),
+				AddOns:      []api.PriceAdjustment{},
+				Subtotal:    decimal.RequireFromString("22.00"),
+				Discounts:   []api.PriceAdjustment{},
+				Taxes:       []api.PriceAdjustment{},
+				Total:       decimal.RequireFromString("22.00"),
+			},
+		},
+		{
+			name: "should add the flexible ticket fee of every seat",
+			setupMocks: func(sessionId string) {
+				flexibleCart := `{"ShowtimeID": 1, "FlexibleTicket": true, "FlexibleTicketFee": "1.50",
+					"Seats": [{"Id": 1, "ExtraPrice": "10.00"}, {"Id": 2, "ExtraPrice": "5.00"}]}`
+
+				s.redisClient.On("Get", mock.Anything, cartSessionKey(sessionId)).Return(redis.NewStringResult(cartID, nil)).Once()
+				s.redisClient.On("Get", mock.Anything, cartID).Return(redis.NewStringResult(flexibleCart, nil)).Once()
+				s.redisClient.On("Get", mock.Anything, seatLockKey(testShowtimeID, 1)).Return(redis.NewStringResult(sessionId, nil)).Once()
+				s.redisClient.On("Get", mock.Anything, seatLockKey(testShowtimeID, 2)).Return(redis.NewStringResult(sessionId, nil)).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: &api.PriceBreakdownResponse{
+				CartId:   cartID,
+				Currency: "USD",
+				Seats: []api.PriceBreakdownSeat{
+					{
+						SeatId:     1,
+						BasePrice:  decimal.Zero,
+						ExtraPrice: decimal.RequireFromString("10.00"),
+						Price:      decimal.RequireFromString("10.00"),
+					},
+					{
+						SeatId:     2,
+						BasePrice:  decimal.Zero,
+						ExtraPrice: decimal.RequireFromString("5.00"),
+						Price:      decimal.RequireFromString("5.00"),
+					},
+				},
+				BaseTotal:   decimal.Zero,
+				ExtrasTotal: decimal.RequireFromString("15.00"),
+				AddOns: []api.PriceAdjustment{
+					{
+						Code:        domain.AddOnFlexibleTicket,
+						Description: "Flexible ticket",
+						Amount:      decimal.RequireFromString("3.00"),
+					},
+				},
+				Subtotal:  decimal.RequireFromString("18.00"),
+				Discounts: []api.PriceAdjustment{},
+				Taxes:     []api.PriceAdjustment{},
+				Total:     decimal.RequireFromString("18.00"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			defer s.redisClient.AssertExpectations(s.T())
+
+			w, r := executeRequest(s.T(), http.MethodGet, "/cart/price-breakdown", nil)
+			r = setupTestSession(s.T(), s.app, r, 1)
+
+			if tt.setupMocks != nil {
+				tt.setupMocks(s.app.sessionManager.Token(r.Context()))
+			}
+
+			handler := http.Handler(http.HandlerFunc(s.app.GetCartPriceBreakdown))
+			handler = s.app.sessionManager.LoadAndSave(handler)
+			handler.ServeHTTP(w, r)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantErrCode != "" {
+				checkErrorCode(s.T(), w, tt.wantErrCode)
+			}
+
+			if tt.wantSeatLocks != nil {
+				var errorResp api.ErrorResponse
+				err := json.NewDecoder(bytes.NewReader(w.Body.Bytes())).Decode(&errorResp)
+				s.Require().NoError(err, "Failed to decode error response")
+
+				diff := cmp.Diff(tt.wantSeatLocks, errorResp.SeatLocks)
+				s.Empty(diff, "Seat locks mismatch (-want +got):\n%s", diff)
+			}
+
+			if tt.wantResponse != nil {
+				var response api.PriceBreakdownResponse
+				err := json.NewDecoder(w.Body).Decode(&response)
+				s.Require().NoError(err, "Failed to decode response")
+
+				diff := cmp.Diff(tt.wantResponse, &response)
+				s.Empty(diff, "Response mismatch (-want +got):\n%s", diff)
+			}
+
+			checkErrorResponse(s.T(), w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+		})
+	}
+}
+
+func (s *CartTestSuite) TestQuoteShowtimePrice() {
+	tests := []struct {
+		name           string
+		showtimeID     int
+		input          api.PriceQuoteRequest
+		setupMocks     func()
+		wantStatus     int
+		wantErrMessage string
+		wantErrCode    api.ErrorCode
+		wantResponse   *api.PriceQuoteResponse
+	}{
+		{
+			name:           "should fail when showtime ID is zero or negative",
+			showtimeID:     0,
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "showtime ID must be greater than zero",
+		},
+		{
+			name:           "should fail when seat list is empty",
+			showtimeID:     1,
+			input:          api.PriceQuoteRequest{SeatIdList: []int{}},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: fmt.Sprintf(validator.ErrArrayMinLength, "1"),
+		},
+		{
+			name:       "should fail when some of requested seatIds are already reserved",
+			showtimeID: 1,
+			input:      api.PriceQuoteRequest{SeatIdList: []int{1, 2}},
+			setupMocks: func() {
+				s.reservationRepo.On("GetSeatsByShowtimeId", mock.Anything, 1).Return([]domain.ReservationSeat{
+					{ReservationID: 1, ShowtimeID: 1, SeatID: 2},
+				}, nil)
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: "some of the selected seats are already reserved",
+			wantErrCode:    api.SEATALREADYRESERVED,
+		},
+		{
+			name:       "should fail when requested seats don't belong to the showtime",
+			showtimeID: 1,
+			input:      api.PriceQuoteRequest{SeatIdList: []int{1, 2}},
+			setupMocks: func() {
+				s.reservationRepo.On("GetSeatsByShowtimeId", mock.Anything, 1).Return([]domain.ReservationSeat{}, nil)
+				s.seatRepo.On("GetSeatBlocksByShowtime", mock.Anything, 1).Return([]domain.SeatBlock{}, nil)
+				s.seatRepo.On("GetSeatsByShowtimeAndSeatIds", mock.Anything, 1, []int{1, 2}).Return(&domain.ShowtimeSeats{
+					Seats: testSeats[:1],
+				}, nil)
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+		},
+		{
+			name:       "should fail when ticket sales of the showtime are closed",
+			showtimeID: 1,
+			input:      api.PriceQuoteRequest{SeatIdList: []int{1, 2}},
+			setupMocks: func() {
+				s.reservationRepo.On("GetSeatsByShowtimeId", mock.Anything, 1).Return([]domain.ReservationSeat{}, nil)
+				s.seatRepo.On("GetSeatBlocksByShowtime", mock.Anything, 1).Return([]domain.SeatBlock{}, nil)
+				s.seatRepo.On("GetSeatsByShowtimeAndSeatIds", mock.Anything, 1, []int{1, 2}).Return(&domain.ShowtimeSeats{
+					Seats:       testSeats[:2],
+					SalesWindow: domain.SalesWindow{CloseAt: ptr(time.Now().Add(-time.Hour))},
+				}, nil)
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: domain.ErrSalesClosed.Error(),
+			wantErrCode:    api.SALESCLOSED,
+		},
+		{
+			name:       "should quote the seats without locking them",
+			showtimeID: 1,
+			input:      api.PriceQuoteRequest{SeatIdList: []int{1, 2}},
+			setupMocks: func() {
+				s.reservationRepo.On("GetSeatsByShowtimeId", mock.Anything, 1).Return([]domain.ReservationSeat{}, nil)
+				s.seatRepo.On("GetSeatBlocksByShowtime", mock.Anything, 1).Return([]domain.SeatBlock{}, nil)
+				s.seatRepo.On("GetSeatsByShowtimeAndSeatIds", mock.Anything, 1, []int{1, 2}).Return(&domain.ShowtimeSeats{
+					Seats:           testSeats[:2],
+					Price:           testBasePrice,
+					Format:          domain.Format3D,
+					FormatSurcharge: decimal.RequireFromString("3.50"),
+				}, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: &api.PriceQuoteResponse{
+				ShowtimeId: 1,
+				Currency:   domain.DefaultCurrency,
+				Seats: []api.PriceBreakdownSeat{
+					{
+						SeatId:          1,
+						Row:             1,
+						Column:          1,
+						Type:            api.Standard,
+						BasePrice:       decimal.NewFromInt(50),
+						FormatSurcharge: decimal.RequireFromString("3.50"),
+						ExtraPrice:      decimal.Zero,
+						Price:           decimal.RequireFromString("53.50"),
+					},
+					{
+						SeatId:          2,
+						Row:             1,
+						Column:          2,
+						Type:            api.VIP,
+						BasePrice:       decimal.NewFromInt(50),
+						FormatSurcharge: decimal.RequireFromString("3.50"),
+						ExtraPrice:      decimal.NewFromInt(15),
+						Price:           decimal.RequireFromString("68.50"),
+					},
+				},
+				BaseTotal:       decimal.NewFromInt(100),
+				SurchargesTotal: decimal.NewFromInt(7),
+				ExtrasTotal:     decimal.NewFromInt(15),
+				Subtotal:        decimal.NewFromInt(122),
+				Discounts:       []api.PriceAdjustment{},
+				Taxes:           []api.PriceAdjustment{},
+				Total:           decimal.NewFromInt(122),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			// no seat is locked, so the quote never touches Redis
+			defer s.redisClient.AssertExpectations(s.T())
+			defer s.seatRepo.AssertExpectations(s.T())
+
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			w, r := executeRequest(s.T(), http.MethodPost, fmt.Sprintf("/showtimes/%d/price-quote", tt.showtimeID), tt.input)
+			s.app.QuoteShowtimePrice(w, r, tt.showtimeID)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantErrCode != "" {
+				checkErrorCode(s.T(), w, tt.wantErrCode)
+			}
+
+			if tt.wantResponse != nil {
+				var response api.PriceQuoteResponse
+				err := json.NewDecoder(w.Body).Decode(&response)
+				s.Require().NoError(err, "Failed to decode response")
+
+				diff := cmp.Diff(tt.wantResponse, &response)
+				s.Empty(diff, "Response mismatch (-want +got):\n%s", diff)
+			}
+
 			checkErrorResponse(s.T(), w, struct {
 				wantStatus     int
 				wantErrMessage string
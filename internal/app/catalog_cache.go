@@ -0,0 +1,93 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// catalogModifiedKey holds when the catalog was last changed, in Unix seconds like the HTTP dates
+const catalogModifiedKey = "catalog:last_modified"
+
+// catalogNotModified answers a request for a public catalog resource with 304 Not Modified when the copy of
+// the client is still current. Otherwise it returns the caching headers of the response, which are only
+// meant to be sent with a successful one. CDNs may serve a copy until its TTL is over, changes to the
+// catalog are picked up when they revalidate it.
+func (app *Application) catalogNotModified(w http.ResponseWriter, r *http.Request, ttl time.Duration) (http.Header, bool) {
+	if ttl <= 0 {
+		return nil, false
+	}
+
+	lastModified := app.catalogLastModified(r)
+	headers := catalogCacheHeaders(ttl, lastModified)
+
+	if lastModified.IsZero() {
+		return headers, false
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || lastModified.After(since) {
+		return headers, false
+	}
+
+	for key, value := range headers {
+		w.Header()[key] = value
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+
+	return nil, true
+}
+
+func catalogCacheHeaders(ttl time.Duration, lastModified time.Time) http.Header {
+	headers := http.Header{}
+	headers.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(ttl.Seconds())))
+	// every tenant has its own catalog, the tenant is chosen by the header or the host
+	headers.Set("Vary", "X-Tenant")
+
+	if !lastModified.IsZero() {
+		headers.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	return headers
+}
+
+// catalogLastModified returns when the catalog was last changed. The time is started by the first request
+// when it isn't known yet. A zero time is returned when it can't be read, the responses are still cached
+// but can't be revalidated then.
+func (app *Application) catalogLastModified(r *http.Request) time.Time {
+	ctx := r.Context()
+	logger := app.contextGetLogger(r)
+
+	seconds, err := app.redis.Get(ctx, catalogModifiedKey).Int64()
+	if err == nil {
+		return time.Unix(seconds, 0)
+	}
+
+	if !errors.Is(err, redis.Nil) {
+		logger.Warn("failed to read the last modification of the catalog", "error", err)
+		return time.Time{}
+	}
+
+	now := time.Now().Truncate(time.Second)
+
+	err = app.redis.Set(ctx, catalogModifiedKey, now.Unix(), 0).Err()
+	if err != nil {
+		logger.Warn("failed to store the last modification of the catalog", "error", err)
+		return time.Time{}
+	}
+
+	return now
+}
+
+// touchCatalog records a change of the catalog, so the cached copies are replaced once they are revalidated.
+// A failure is only logged, the copies expire with their TTL anyway.
+func (app *Application) touchCatalog(r *http.Request) {
+	err := app.redis.Set(r.Context(), catalogModifiedKey, time.Now().Unix(), 0).Err()
+	if err != nil {
+		app.contextGetLogger(r).Error("failed to record the modification of the catalog", "error", err)
+	}
+}
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCatalogCacheHeaders(t *testing.T) {
+	const modifiedAt = 1700000000
+	lastModified := time.Unix(modifiedAt, 0).UTC().Format(http.TimeFormat)
+
+	tests := []struct {
+		name             string
+		ttl              time.Duration
+		ifModifiedSince  string
+		movieErr         error
+		setupMocks       func(*mocks.MockRedisClient)
+		wantStatus       int
+		wantCacheControl string
+		wantLastModified string
+		wantMovieRead    bool
+	}{
+		{
+			name:          "should not cache when the TTL is zero",
+			wantStatus:    http.StatusOK,
+			wantMovieRead: true,
+		},
+		{
+			name: "should send the caching headers",
+			ttl:  15 * time.Minute,
+			setupMocks: func(c *mocks.MockRedisClient) {
+				c.On("Get", mock.Anything, catalogModifiedKey).Return(redis.NewStringResult("1700000000", nil)).Once()
+			},
+			wantStatus:       http.StatusOK,
+			wantCacheControl: "public, max-age=900",
+			wantLastModified: lastModified,
+			wantMovieRead:    true,
+		},
+		{
+			name:            "should answer a current copy with not modified",
+			ttl:             15 * time.Minute,
+			ifModifiedSince: lastModified,
+			setupMocks: func(c *mocks.MockRedisClient) {
+				c.On("Get", mock.Anything, catalogModifiedKey).Return(redis.NewStringResult("1700000000", nil)).Once()
+			},
+			wantStatus:       http.StatusNotModified,
+			wantCacheControl: "public, max-age=900",
+			wantLastModified: lastModified,
+		},
+		{
+			name:            "should send a copy older than the last change",
+			ttl:             15 * time.Minute,
+			ifModifiedSince: time.Unix(modifiedAt-1, 0).UTC().Format(http.TimeFormat),
+			setupMocks: func(c *mocks.MockRedisClient) {
+				c.On("Get", mock.Anything, catalogModifiedKey).Return(redis.NewStringResult("1700000000", nil)).Once()
+			},
+			wantStatus:       http.StatusOK,
+			wantCacheControl: "public, max-age=900",
+			wantLastModified: lastModified,
+			wantMovieRead:    true,
+		},
+		{
+			name: "should start the modification time when it isn't known",
+			ttl:  15 * time.Minute,
+			setupMocks: func(c *mocks.MockRedisClient) {
+				c.On("Get", mock.Anything, catalogModifiedKey).Return(redis.NewStringResult("", redis.Nil)).Once()
+				c.On("Set", mock.Anything, catalogModifiedKey, mock.Anything, time.Duration(0)).
+					Return(redis.NewStatusResult("OK", nil)).Once()
+			},
+			wantStatus:       http.StatusOK,
+			wantCacheControl: "public, max-age=900",
+			wantMovieRead:    true,
+		},
+		{
+			name: "should cache without validation when the modification time can't be read",
+			ttl:  15 * time.Minute,
+			setupMocks: func(c *mocks.MockRedisClient) {
+				c.On("Get", mock.Anything, catalogModifiedKey).Return(redis.NewStringResult("", errors.New("redis unavailable"))).Once()
+			},
+			wantStatus:       http.StatusOK,
+			wantCacheControl: "public, max-age=900",
+			wantMovieRead:    true,
+		},
+		{
+			name:     "should not cache a movie which isn't found",
+			ttl:      15 * time.Minute,
+			movieErr: domain.ErrRecordNotFound,
+			setupMocks: func(c *mocks.MockRedisClient) {
+				c.On("Get", mock.Anything, catalogModifiedKey).Return(redis.NewStringResult("1700000000", nil)).Once()
+			},
+			wantStatus:    http.StatusNotFound,
+			wantMovieRead: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisClient := new(mocks.MockRedisClient)
+			if tt.setupMocks != nil {
+				tt.setupMocks(redisClient)
+			}
+
+			var movieRead bool
+
+			app := newTestApplication(func(a *Application) {
+				a.redis = redisClient
+				a.config.CatalogCache.MovieDetailsTTL = tt.ttl
+				a.movieRepo = &mocks.MockMovieRepo{
+					GetByIdFunc: func(ctx context.Context, id int) (*domain.Movie, error) {
+						movieRead = true
+						if tt.movieErr != nil {
+							return nil, tt.movieErr
+						}
+
+						return &domain.Movie{ID: id, Title: "Dune"}, nil
+					},
+				}
+			})
+
+			w, r := executeRequest(t, http.MethodGet, "/movies/1", nil)
+			if tt.ifModifiedSince != "" {
+				r.Header.Set("If-Modified-Since", tt.ifModifiedSince)
+			}
+
+			app.ShowMovieDetails(w, r, 1)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status code = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			if got := w.Header().Get("Cache-Control"); got != tt.wantCacheControl {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.wantCacheControl)
+			}
+
+			if tt.wantLastModified != "" {
+				if got := w.Header().Get("Last-Modified"); got != tt.wantLastModified {
+					t.Errorf("Last-Modified = %q, want %q", got, tt.wantLastModified)
+				}
+			}
+
+			if movieRead != tt.wantMovieRead {
+				t.Errorf("movie read = %v, want %v", movieRead, tt.wantMovieRead)
+			}
+
+			redisClient.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// catalogChangesChannel is the channel the triggers of the catalog tables notify, see the migration
+// adding them
+const catalogChangesChannel = "catalog_changes"
+
+// a lost connection is retried after a pause, so a database restart doesn't flood the log
+const catalogListenRetryDelay = 5 * time.Second
+
+// catalogChange is the payload of a notification. The tenant is missing when the row was deleted along
+// with its theater, the theater and the hall are only set for the tables below them.
+type catalogChange struct {
+	Table     string `json:"table"`
+	TenantID  *int   `json:"tenantId"`
+	TheaterID *int   `json:"theaterId"`
+	HallID    *int   `json:"hallId"`
+}
+
+// listenCatalogChanges purges the cached copies of the catalog when it is changed in the database, so
+// changes written by other tools or by hand are picked up too. Every instance listens and purges the
+// shared keys, which is harmless. Notifications sent while the connection is lost are missed, the copies
+// expire with their TTL then. It returns once ctx is done.
+func (app *Application) listenCatalogChanges(ctx context.Context) {
+	for {
+		err := app.waitForCatalogChanges(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		app.logger.Error("lost the connection listening to catalog changes", "error", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(catalogListenRetryDelay):
+		}
+	}
+}
+
+func (app *Application) waitForCatalogChanges(ctx context.Context) error {
+	pooled, err := app.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	// the connection keeps listening until it is closed, it isn't given back to the pool
+	conn := pooled.Hijack()
+	defer conn.Close(context.WithoutCancel(ctx))
+
+	_, err = conn.Exec(ctx, "LISTEN "+catalogChangesChannel)
+	if err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		app.handleCatalogNotification(ctx, notification)
+	}
+}
+
+func (app *Application) handleCatalogNotification(ctx context.Context, notification *pgconn.Notification) {
+	var change catalogChange
+
+	err := json.Unmarshal([]byte(notification.Payload), &change)
+	if err != nil {
+		app.logger.Error("failed to decode catalog change", "payload", notification.Payload, "error", err)
+		return
+	}
+
+	keys := catalogChangeKeys(change)
+	if len(keys) > 0 {
+		err = app.redis.Del(ctx, keys...).Err()
+		if err != nil && !errors.Is(err, context.Canceled) {
+			app.logger.Error("failed to purge cached catalog", "table", change.Table, "keys", keys, "error", err)
+		}
+	}
+
+	err = app.redis.Set(ctx, catalogModifiedKey, time.Now().Unix(), 0).Err()
+	if err != nil && !errors.Is(err, context.Canceled) {
+		app.logger.Error("failed to record the modification of the catalog", "table", change.Table, "error", err)
+	}
+}
+
+// catalogChangeKeys returns the cache keys holding the changed row. The search suggestions are cached by
+// the typed prefix, they can't be found from the row and expire with their short TTL instead.
+func catalogChangeKeys(change catalogChange) []string {
+	var keys []string
+
+	if change.TenantID != nil {
+		keys = append(keys,
+			feedCacheKey(sitemapFeedName, *change.TenantID),
+			feedCacheKey(showtimesFeedName, *change.TenantID))
+
+		if change.TheaterID != nil {
+			keys = append(keys, feedCacheKey(nowPlayingBoardFeedName(*change.TheaterID), *change.TenantID))
+		}
+	}
+
+	// the layout shows the seats and the name of the hall only
+	if change.HallID != nil && (change.Table == "halls" || change.Table == "seats") {
+		keys = append(keys, seatMapLayoutKey(*change.HallID))
+	}
+
+	return keys
+}
@@ -0,0 +1,111 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCatalogChangeKeys(t *testing.T) {
+	id := func(n int) *int { return &n }
+
+	tests := []struct {
+		name   string
+		change catalogChange
+		want   []string
+	}{
+		{
+			name:   "movie",
+			change: catalogChange{Table: "movies", TenantID: id(2)},
+			want:   []string{"feeds:sitemap:2", "feeds:showtimes:2"},
+		},
+		{
+			name:   "theater",
+			change: catalogChange{Table: "theaters", TenantID: id(2), TheaterID: id(5)},
+			want:   []string{"feeds:sitemap:2", "feeds:showtimes:2", "feeds:now_playing_board:5:2"},
+		},
+		{
+			name:   "seats of a hall",
+			change: catalogChange{Table: "seats", TenantID: id(2), TheaterID: id(5), HallID: id(7)},
+			want:   []string{"feeds:sitemap:2", "feeds:showtimes:2", "feeds:now_playing_board:5:2", "seat_map_svg:7"},
+		},
+		{
+			name:   "showtime",
+			change: catalogChange{Table: "showtimes", TenantID: id(2), TheaterID: id(5), HallID: id(7)},
+			want:   []string{"feeds:sitemap:2", "feeds:showtimes:2", "feeds:now_playing_board:5:2"},
+		},
+		{
+			name:   "hall deleted with its theater",
+			change: catalogChange{Table: "halls", TheaterID: id(5), HallID: id(7)},
+			want:   []string{"seat_map_svg:7"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := catalogChangeKeys(tt.change); !slices.Equal(got, tt.want) {
+				t.Errorf("catalogChangeKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleCatalogNotification(t *testing.T) {
+	tests := []struct {
+		name       string
+		payload    string
+		setupMocks func(*mocks.MockRedisClient)
+	}{
+		{
+			name:    "should purge the cached copies of the changed row",
+			payload: `{"table":"halls","tenantId":1,"theaterId":3,"hallId":4}`,
+			setupMocks: func(c *mocks.MockRedisClient) {
+				c.On("Del", mock.Anything, []string{"feeds:sitemap:1", "feeds:showtimes:1", "feeds:now_playing_board:3:1", "seat_map_svg:4"}).
+					Return(redis.NewIntResult(2, nil)).Once()
+				c.On("Set", mock.Anything, catalogModifiedKey, mock.Anything, time.Duration(0)).
+					Return(redis.NewStatusResult("OK", nil)).Once()
+			},
+		},
+		{
+			name:    "should record the change when the cache can't be purged",
+			payload: `{"table":"movies","tenantId":1,"theaterId":null,"hallId":null}`,
+			setupMocks: func(c *mocks.MockRedisClient) {
+				c.On("Del", mock.Anything, []string{"feeds:sitemap:1", "feeds:showtimes:1"}).
+					Return(redis.NewIntResult(0, errors.New("redis unavailable"))).Once()
+				c.On("Set", mock.Anything, catalogModifiedKey, mock.Anything, time.Duration(0)).
+					Return(redis.NewStatusResult("OK", nil)).Once()
+			},
+		},
+		{
+			name:    "should ignore a payload which can't be decoded",
+			payload: `not json`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisClient := new(mocks.MockRedisClient)
+			if tt.setupMocks != nil {
+				tt.setupMocks(redisClient)
+			}
+
+			app := newTestApplication(func(a *Application) {
+				a.redis = redisClient
+			})
+
+			app.handleCatalogNotification(context.Background(), &pgconn.Notification{
+				Channel: catalogChangesChannel,
+				Payload: tt.payload,
+			})
+
+			redisClient.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/metinatakli/movie-reservation-system/internal/mailer"
+	"github.com/metinatakli/movie-reservation-system/internal/payment"
+	"github.com/metinatakli/movie-reservation-system/internal/scheduler"
+	"github.com/metinatakli/movie-reservation-system/migrations"
+	"github.com/redis/go-redis/v9"
+	"github.com/stripe/stripe-go/v82"
+)
+
+const checkTimeout = 10 * time.Second
+
+// luaScripts are the Lua scripts the application runs on Redis.
+var luaScripts = []*redis.Script{
+	rateLimitScript,
+	lockSeatsScript,
+	releaseSeatsScript,
+	filterValidLockSeats,
+	recordSeatEvent,
+	seatMapChangesScript,
+	claimSeatHoldScript,
+}
+
+// selfCheck is one of the checks of the -check mode. It returns a short description of what it found.
+type selfCheck struct {
+	name string
+	run  func(ctx context.Context) (string, error)
+}
+
+type selfCheckResult struct {
+	Name       string `json:"name"`
+	OK         bool   `json:"ok"`
+	Detail     string `json:"detail,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+type selfCheckReport struct {
+	Version string            `json:"version"`
+	OK      bool              `json:"ok"`
+	Checks  []selfCheckResult `json:"checks"`
+}
+
+// runSelfCheck checks that the dependencies of the application are usable with the given configuration
+// without starting the server, so a deployment can be stopped before it serves traffic. The report is
+// written to w and an error is returned if any of the checks failed.
+func runSelfCheck(cfg Config, w io.Writer) error {
+	stripe.Key = cfg.Stripe.SecretKey
+
+	checks := []selfCheck{
+		{name: "database", run: func(ctx context.Context) (string, error) { return checkDatabase(ctx, cfg) }},
+		{name: "redis", run: func(ctx context.Context) (string, error) { return checkRedis(ctx, cfg) }},
+		{name: "templates", run: checkTemplates},
+		{name: "stripe", run: func(ctx context.Context) (string, error) { return checkStripe(ctx, cfg) }},
+	}
+
+	return runSelfChecks(context.Background(), checks, w)
+}
+
+func runSelfChecks(ctx context.Context, checks []selfCheck, w io.Writer) error {
+	report := selfCheckReport{
+		Version: version,
+		OK:      true,
+	}
+
+	for _, check := range checks {
+		start := time.Now()
+
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		detail, err := check.run(checkCtx)
+		cancel()
+
+		result := selfCheckResult{
+			Name:       check.name,
+			OK:         err == nil,
+			Detail:     detail,
+			DurationMs: time.Since(start).Milliseconds(),
+		}
+
+		if err != nil {
+			result.Error = err.Error()
+			report.OK = false
+		}
+
+		report.Checks = append(report.Checks, result)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	err := enc.Encode(report)
+	if err != nil {
+		return err
+	}
+
+	if !report.OK {
+		return errors.New("self-check failed")
+	}
+
+	return nil
+}
+
+// checkDatabase connects to the database and compares the version of its schema with the newest
+// migration the binary was built with.
+func checkDatabase(ctx context.Context, cfg Config) (string, error) {
+	// the self-check reports on its output only, the queries it runs are not logged
+	db, err := NewDatabasePool(cfg, slog.New(slog.DiscardHandler))
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	latest, err := migrations.Latest()
+	if err != nil {
+		return "", err
+	}
+
+	version, dirty, err := schemaVersion(ctx, db)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case dirty:
+		return "", fmt.Errorf("migration %d failed and left the schema dirty", version)
+	case version < latest:
+		return "", fmt.Errorf("schema is at version %d, %d pending migrations up to version %d", version, latest-version, latest)
+	case version > latest:
+		return "", fmt.Errorf("schema is at version %d, newer than the latest known migration %d", version, latest)
+	}
+
+	return fmt.Sprintf("schema is at version %d", version), nil
+}
+
+// schemaVersion returns the version of the last migration applied to the database, and whether it failed
+// half way.
+func schemaVersion(ctx context.Context, db *pgxpool.Pool) (uint, bool, error) {
+	var version int64
+	var dirty bool
+
+	err := db.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("failed to read the schema version: %w", err)
+	}
+
+	return uint(version), dirty, nil
+}
+
+// checkRedis connects to Redis and loads the Lua scripts into its script cache, which compiles them.
+func checkRedis(ctx context.Context, cfg Config) (string, error) {
+	client, err := NewRedisClient(cfg)
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+
+	scripts := slices.Concat(luaScripts, scheduler.Scripts())
+
+	for _, script := range scripts {
+		err := script.Load(ctx, client).Err()
+		if err != nil {
+			return "", fmt.Errorf("failed to load script %s: %w", script.Hash(), err)
+		}
+	}
+
+	return fmt.Sprintf("loaded %d scripts", len(scripts)), nil
+}
+
+func checkTemplates(ctx context.Context) (string, error) {
+	return "", mailer.ValidateTemplates()
+}
+
+// checkStripe makes a read-only request with the secret key. A test key is rejected in production, as
+// no payment would be real.
+func checkStripe(ctx context.Context, cfg Config) (string, error) {
+	key := cfg.Stripe.SecretKey
+	if key == "" {
+		return "", errors.New("no secret key configured")
+	}
+
+	mode := "test"
+	if strings.Contains(key, "_live_") {
+		mode = "live"
+	}
+
+	if cfg.Env == "prod" && mode != "live" {
+		return "", errors.New("a test mode key is configured in production")
+	}
+
+	provider := payment.NewStripePaymentProvider(cfg.Stripe.FailureURL, cfg.Stripe.SuccessURL)
+
+	err := provider.CheckKey(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s mode key accepted", mode), nil
+}
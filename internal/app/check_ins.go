@@ -0,0 +1,282 @@
+package app
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/ticketsign"
+)
+
+// errInvalidTicket is returned for ticket codes which weren't issued, or were replaced since.
+var errInvalidTicket = errors.New("the ticket code is not valid")
+
+func (app *Application) UpdateTheaterCheckInPolicy(w http.ResponseWriter, r *http.Request, theaterID int) {
+	logger := app.contextGetLogger(r)
+
+	if theaterID < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("theater ID must be greater than zero"))
+		return
+	}
+
+	var input api.UpdateTheaterCheckInPolicyRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	policy := domain.CheckInPolicy{
+		OpensBefore: input.OpensBefore,
+		LateAfter:   input.LateAfter,
+		ClosesAfter: input.ClosesAfter,
+	}
+
+	err = app.theaterRepo.UpdateCheckInPolicy(r.Context(), theaterID, policy)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, domain.ErrInvalidCheckInPolicy):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	logger.Info("theater check-in policy changed",
+		"theater_id", theaterID,
+		"opens_before", policy.OpensBefore,
+		"late_after", policy.LateAfter,
+		"closes_after", policy.ClosesAfter)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CheckInTicket lets the guests of a reservation in by the code of their ticket. Staff check in the
+// tickets of their theater only, access is checked against the theater of the showtime.
+func (app *Application) CheckInTicket(w http.ResponseWriter, r *http.Request) {
+	var input api.CheckInTicketRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	staffId := app.contextGetUserId(r)
+	logger := app.contextGetLogger(r).With("staff_id", staffId)
+
+	ticket, err := app.findTicket(r, input.TicketCode)
+	if err != nil {
+		switch {
+		case errors.Is(err, errInvalidTicket):
+			logger.Warn("check-in rejected: invalid ticket code")
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	logger = logger.With("reservation_id", ticket.ReservationID, "showtime_id", ticket.ShowtimeID)
+
+	allowed, err := app.canManageTheater(r.Context(), staffId, ticket.TheaterID)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.unauthorizedAccessResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	if !allowed {
+		logger.Warn("check-in denied: user is not staff of the theater", "theater_id", ticket.TheaterID)
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	if ticket.TicketsRevokedAt != nil {
+		app.editConflictResponseWithErr(w, r, errTicketsRevoked)
+		return
+	}
+
+	if ticket.CheckedInAt != nil {
+		app.editConflictResponseWithErr(w, r, domain.ErrAlreadyCheckedIn)
+		return
+	}
+
+	now := time.Now()
+
+	featureStart := domain.FeatureStart(ticket.StartTime, ticket.PreShowMinutes)
+
+	minutesLate, err := ticket.Policy.Check(featureStart, now)
+	if err != nil {
+		logger.Warn("check-in rejected: outside the check-in window of the theater", "error", err)
+		app.editConflictResponseWithErr(w, r, err)
+		return
+	}
+
+	checkIn := &domain.CheckIn{
+		ReservationID: ticket.ReservationID,
+		ShowtimeID:    ticket.ShowtimeID,
+		CheckedInAt:   now,
+		MinutesLate:   minutesLate,
+		CheckedInBy:   staffId,
+	}
+
+	err = app.reservationRepo.CreateCheckIn(r.Context(), checkIn)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrAlreadyCheckedIn):
+			app.editConflictResponseWithErr(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	logger.Info("guests checked in", "minutes_late", minutesLate)
+
+	resp := api.CheckInResponse{
+		ReservationId: checkIn.ReservationID,
+		ShowtimeId:    checkIn.ShowtimeID,
+		CheckedInAt:   checkIn.CheckedInAt,
+		MinutesLate:   checkIn.MinutesLate,
+	}
+
+	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// GetTicketKeys lists the public keys scanners verify signed ticket codes with, the list is empty when
+// codes aren't signed.
+func (app *Application) GetTicketKeys(w http.ResponseWriter, r *http.Request) {
+	resp := api.TicketKeysResponse{Keys: []api.TicketKey{}}
+
+	if app.ticketKeys != nil {
+		for _, key := range app.ticketKeys.PublicKeys() {
+			resp.Keys = append(resp.Keys, api.TicketKey{
+				Id:        key.ID,
+				Algorithm: "Ed25519",
+				PublicKey: base64.StdEncoding.EncodeToString(key.Key),
+				Primary:   key.Primary,
+			})
+		}
+	}
+
+	err := app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// findTicket returns the reservation the ticket code was issued for. Cancelled reservations and codes of
+// replaced tickets are reported as errInvalidTicket, like codes which were never issued. Unsigned codes
+// are only accepted with a ticket secret, anyone could derive them without one.
+func (app *Application) findTicket(r *http.Request, code string) (*domain.TicketCheckIn, error) {
+	if ticketsign.IsSigned(code) {
+		return app.findSignedTicket(r, code)
+	}
+
+	if app.config.Wallet.TicketSecret == "" {
+		return nil, errInvalidTicket
+	}
+
+	id, _, ok := strings.Cut(code, "-")
+	if !ok {
+		return nil, errInvalidTicket
+	}
+
+	reservationId, err := strconv.Atoi(id)
+	if err != nil || reservationId < 1 {
+		return nil, errInvalidTicket
+	}
+
+	ticket, err := app.getTicketCheckIn(r, reservationId)
+	if err != nil {
+		return nil, err
+	}
+
+	issued := app.ticketCode(ticket.ReservationID, ticket.TicketVersion)
+	if subtle.ConstantTimeCompare([]byte(issued), []byte(code)) != 1 {
+		return nil, errInvalidTicket
+	}
+
+	if ticket.Status == domain.ReservationCancelled {
+		return nil, errInvalidTicket
+	}
+
+	return ticket, nil
+}
+
+// findSignedTicket verifies a signed code and looks its reservation up, the code must be of the current
+// ticket version and showtime of the reservation. Expired codes and codes signed with a removed key are
+// invalid.
+func (app *Application) findSignedTicket(r *http.Request, code string) (*domain.TicketCheckIn, error) {
+	if app.ticketKeys == nil {
+		return nil, errInvalidTicket
+	}
+
+	payload, err := app.ticketKeys.Verify(code, time.Now())
+	if err != nil {
+		return nil, errInvalidTicket
+	}
+
+	ticket, err := app.getTicketCheckIn(r, payload.ReservationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if ticket.TicketVersion != payload.TicketVersion || ticket.ShowtimeID != payload.ShowtimeID {
+		return nil, errInvalidTicket
+	}
+
+	if ticket.Status == domain.ReservationCancelled {
+		return nil, errInvalidTicket
+	}
+
+	return ticket, nil
+}
+
+func (app *Application) getTicketCheckIn(r *http.Request, reservationId int) (*domain.TicketCheckIn, error) {
+	ticket, err := app.reservationRepo.GetTicketCheckIn(r.Context(), reservationId)
+	if err != nil {
+		if errors.Is(err, domain.ErrRecordNotFound) {
+			return nil, errInvalidTicket
+		}
+
+		return nil, err
+	}
+
+	return ticket, nil
+}
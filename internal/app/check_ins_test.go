@@ -0,0 +1,404 @@
+package app
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/metinatakli/movie-reservation-system/internal/ticketsign"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCheckInTicket(t *testing.T) {
+	policy := domain.CheckInPolicy{OpensBefore: 60, LateAfter: 10, ClosesAfter: 30}
+	revokedAt := time.Now().Add(-24 * time.Hour)
+	checkedInAt := time.Now().Add(-5 * time.Minute)
+
+	ticketKeys, err := ticketsign.ParseKeyring("k1:" + base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signedCode := func(version, showtimeID int, expiresAt time.Time) func(app *Application) string {
+		return func(app *Application) string {
+			code, err := ticketKeys.Sign(ticketsign.Payload{
+				ReservationID: 12,
+				TicketVersion: version,
+				ShowtimeID:    showtimeID,
+				Seats:         [][2]int{{3, 5}},
+				ExpiresAt:     expiresAt,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return code
+		}
+	}
+
+	ticketStartingIn := func(d time.Duration) *domain.TicketCheckIn {
+		return &domain.TicketCheckIn{
+			ReservationID: 12,
+			Status:        domain.ReservationConfirmed,
+			TicketVersion: 1,
+			ShowtimeID:    5,
+			StartTime:     time.Now().Add(d),
+			TheaterID:     3,
+			Policy:        policy,
+		}
+	}
+
+	tests := []struct {
+		name            string
+		staffID         int
+		code            func(app *Application) string
+		noTicketSecret  bool
+		ticket          *domain.TicketCheckIn
+		createErr       error
+		wantStatus      int
+		wantErrCode     api.ErrorCode
+		wantMinutesLate int
+	}{
+		{
+			name:        "should reject a code which wasn't issued",
+			staffID:     7,
+			code:        func(app *Application) string { return "12-AAAAAAAAAAAAAAAA" },
+			ticket:      ticketStartingIn(30 * time.Minute),
+			wantStatus:  http.StatusNotFound,
+			wantErrCode: api.NOTFOUND,
+		},
+		{
+			name:        "should reject the code of a replaced ticket",
+			staffID:     7,
+			code:        func(app *Application) string { return app.ticketCode(12, 0) },
+			ticket:      ticketStartingIn(30 * time.Minute),
+			wantStatus:  http.StatusNotFound,
+			wantErrCode: api.NOTFOUND,
+		},
+		{
+			name:           "should reject unsigned codes without a ticket secret",
+			staffID:        7,
+			noTicketSecret: true,
+			ticket:         ticketStartingIn(30 * time.Minute),
+			wantStatus:     http.StatusNotFound,
+			wantErrCode:    api.NOTFOUND,
+		},
+		{
+			name:           "should check in guests with a signed code without a ticket secret",
+			staffID:        7,
+			code:           signedCode(1, 5, time.Now().Add(3*time.Hour)),
+			noTicketSecret: true,
+			ticket:         ticketStartingIn(30 * time.Minute),
+			wantStatus:     http.StatusOK,
+		},
+		{
+			name:        "should reject a user who isn't staff of the theater",
+			staffID:     8,
+			ticket:      ticketStartingIn(30 * time.Minute),
+			wantStatus:  http.StatusForbidden,
+			wantErrCode: api.FORBIDDEN,
+		},
+		{
+			name:    "should reject revoked tickets",
+			staffID: 7,
+			ticket: func() *domain.TicketCheckIn {
+				ticket := ticketStartingIn(30 * time.Minute)
+				ticket.TicketsRevokedAt = &revokedAt
+				return ticket
+			}(),
+			wantStatus:  http.StatusConflict,
+			wantErrCode: api.TICKETSREVOKED,
+		},
+		{
+			name:       "should check in guests with a signed code",
+			staffID:    7,
+			code:       signedCode(1, 5, time.Now().Add(3*time.Hour)),
+			ticket:     ticketStartingIn(30 * time.Minute),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:        "should reject the signed code of a replaced ticket",
+			staffID:     7,
+			code:        signedCode(0, 5, time.Now().Add(3*time.Hour)),
+			ticket:      ticketStartingIn(30 * time.Minute),
+			wantStatus:  http.StatusNotFound,
+			wantErrCode: api.NOTFOUND,
+		},
+		{
+			name:        "should reject a signed code of another showtime",
+			staffID:     7,
+			code:        signedCode(1, 6, time.Now().Add(3*time.Hour)),
+			ticket:      ticketStartingIn(30 * time.Minute),
+			wantStatus:  http.StatusNotFound,
+			wantErrCode: api.NOTFOUND,
+		},
+		{
+			name:        "should reject an expired signed code",
+			staffID:     7,
+			code:        signedCode(1, 5, time.Now().Add(-time.Minute)),
+			ticket:      ticketStartingIn(30 * time.Minute),
+			wantStatus:  http.StatusNotFound,
+			wantErrCode: api.NOTFOUND,
+		},
+		{
+			name:    "should reject a tampered signed code",
+			staffID: 7,
+			code: func(app *Application) string {
+				parts := strings.Split(signedCode(1, 5, time.Now().Add(3*time.Hour))(app), ".")
+				other := strings.Split(signedCode(2, 5, time.Now().Add(3*time.Hour))(app), ".")
+				return parts[0] + "." + other[1] + "." + parts[2]
+			},
+			ticket:      ticketStartingIn(30 * time.Minute),
+			wantStatus:  http.StatusNotFound,
+			wantErrCode: api.NOTFOUND,
+		},
+		{
+			name:    "should reject guests who already checked in",
+			staffID: 7,
+			ticket: func() *domain.TicketCheckIn {
+				ticket := ticketStartingIn(30 * time.Minute)
+				ticket.CheckedInAt = &checkedInAt
+				return ticket
+			}(),
+			wantStatus:  http.StatusConflict,
+			wantErrCode: api.ALREADYCHECKEDIN,
+		},
+		{
+			name:        "should reject guests before check-in opens",
+			staffID:     7,
+			ticket:      ticketStartingIn(2 * time.Hour),
+			wantStatus:  http.StatusConflict,
+			wantErrCode: api.CHECKINNOTOPEN,
+		},
+		{
+			name:        "should reject guests after check-in closes",
+			staffID:     7,
+			ticket:      ticketStartingIn(-45 * time.Minute),
+			wantStatus:  http.StatusConflict,
+			wantErrCode: api.CHECKINCLOSED,
+		},
+		{
+			name:        "should reject guests checked in at another entrance meanwhile",
+			staffID:     7,
+			ticket:      ticketStartingIn(30 * time.Minute),
+			createErr:   domain.ErrAlreadyCheckedIn,
+			wantStatus:  http.StatusConflict,
+			wantErrCode: api.ALREADYCHECKEDIN,
+		},
+		{
+			name:       "should check in guests arriving on time",
+			staffID:    7,
+			ticket:     ticketStartingIn(30 * time.Minute),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "should not record guests within the grace period as late",
+			staffID:    7,
+			ticket:     ticketStartingIn(-5 * time.Minute),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:            "should record late guests",
+			staffID:         7,
+			ticket:          ticketStartingIn(-20*time.Minute - 30*time.Second),
+			wantStatus:      http.StatusOK,
+			wantMinutesLate: 20,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reservationRepo := new(mocks.MockReservationRepo)
+			reservationRepo.On("GetTicketCheckIn", mock.Anything, 12).Return(tt.ticket, nil).Maybe()
+
+			var created *domain.CheckIn
+			reservationRepo.On("CreateCheckIn", mock.Anything, mock.Anything).
+				Run(func(args mock.Arguments) { created = args.Get(1).(*domain.CheckIn) }).
+				Return(tt.createErr).Maybe()
+
+			app := newTestApplication(func(a *Application) {
+				a.reservationRepo = reservationRepo
+				a.config.Wallet.TicketSecret = "ticket-secret"
+				a.ticketKeys = ticketKeys
+				a.userRepo = &mocks.MockUserRepo{
+					GetByIdFunc: func(ctx context.Context, id int) (*domain.User, error) {
+						return &domain.User{ID: id, Role: domain.RoleUser}, nil
+					},
+				}
+				a.theaterRepo = &mocks.MockTheaterRepo{
+					IsTheaterStaffFunc: func(ctx context.Context, theaterID, userID int) (bool, error) {
+						return theaterID == 3 && userID == 7, nil
+					},
+				}
+			})
+
+			if tt.noTicketSecret {
+				app.config.Wallet.TicketSecret = ""
+			}
+
+			code := app.ticketCode(12, 1)
+			if tt.code != nil {
+				code = tt.code(app)
+			}
+
+			input := api.CheckInTicketRequest{TicketCode: code}
+
+			w, r := executeRequest(t, http.MethodPost, "/admin/check-ins", input)
+			r = r.WithContext(context.WithValue(r.Context(), SessionKeyUserId, tt.staffID))
+
+			app.CheckInTicket(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status code = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				var resp api.ErrorResponse
+				if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+
+				if resp.Code != tt.wantErrCode {
+					t.Errorf("error code = %s, want %s", resp.Code, tt.wantErrCode)
+				}
+
+				return
+			}
+
+			var resp api.CheckInResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+
+			if resp.MinutesLate != tt.wantMinutesLate {
+				t.Errorf("minutes late = %d, want %d", resp.MinutesLate, tt.wantMinutesLate)
+			}
+
+			if created == nil || created.ReservationID != 12 || created.ShowtimeID != 5 || created.CheckedInBy != 7 ||
+				created.MinutesLate != tt.wantMinutesLate {
+				t.Errorf("unexpected check-in recorded: %+v", created)
+			}
+		})
+	}
+}
+
+func TestUpdateTheaterCheckInPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       api.UpdateTheaterCheckInPolicyRequest
+		updateErr   error
+		wantStatus  int
+		wantUpdated bool
+	}{
+		{
+			name:       "should fail when check-in closes before guests are late",
+			input:      api.UpdateTheaterCheckInPolicyRequest{OpensBefore: 60, LateAfter: 20, ClosesAfter: 10},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "should fail when the theater doesn't exist",
+			input:       api.UpdateTheaterCheckInPolicyRequest{OpensBefore: 60, LateAfter: 10, ClosesAfter: 30},
+			updateErr:   domain.ErrRecordNotFound,
+			wantStatus:  http.StatusNotFound,
+			wantUpdated: true,
+		},
+		{
+			name:        "should change the policy",
+			input:       api.UpdateTheaterCheckInPolicyRequest{OpensBefore: 45, LateAfter: 0, ClosesAfter: 15},
+			wantStatus:  http.StatusNoContent,
+			wantUpdated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var updated *domain.CheckInPolicy
+
+			app := newTestApplication(func(a *Application) {
+				a.theaterRepo = &mocks.MockTheaterRepo{
+					UpdateCheckInPolicyFunc: func(ctx context.Context, theaterID int, policy domain.CheckInPolicy) error {
+						updated = &policy
+						return tt.updateErr
+					},
+				}
+			})
+
+			w, r := executeRequest(t, http.MethodPut, "/admin/theaters/3/check-in-policy", tt.input)
+			app.UpdateTheaterCheckInPolicy(w, r, 3)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status code = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			if (updated != nil) != tt.wantUpdated {
+				t.Fatalf("policy updated = %v, want %v", updated != nil, tt.wantUpdated)
+			}
+
+			want := domain.CheckInPolicy{
+				OpensBefore: tt.input.OpensBefore,
+				LateAfter:   tt.input.LateAfter,
+				ClosesAfter: tt.input.ClosesAfter,
+			}
+			if updated != nil && *updated != want {
+				t.Errorf("updated policy = %+v, want %+v", *updated, want)
+			}
+		})
+	}
+}
+
+func TestGetTicketKeys(t *testing.T) {
+	ticketKeys, err := ticketsign.ParseKeyring(
+		"k2:" + base64.StdEncoding.EncodeToString(make([]byte, 32)) +
+			",k1:" + base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		keys     *ticketsign.Keyring
+		wantKeys []string
+	}{
+		{name: "should list no keys when codes aren't signed", wantKeys: []string{}},
+		{name: "should list the primary key first", keys: ticketKeys, wantKeys: []string{"k2", "k1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(func(a *Application) {
+				a.ticketKeys = tt.keys
+			})
+
+			w, r := executeRequest(t, http.MethodGet, "/admin/ticket-keys", nil)
+
+			app.GetTicketKeys(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status code = %d, want %d", w.Code, http.StatusOK)
+			}
+
+			var resp api.TicketKeysResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+
+			if len(resp.Keys) != len(tt.wantKeys) {
+				t.Fatalf("got %d keys, want %d", len(resp.Keys), len(tt.wantKeys))
+			}
+
+			for i, key := range resp.Keys {
+				publicKey, err := base64.StdEncoding.DecodeString(key.PublicKey)
+				if key.Id != tt.wantKeys[i] || key.Algorithm != "Ed25519" || key.Primary != (i == 0) ||
+					err != nil || len(publicKey) != 32 {
+					t.Errorf("unexpected key %+v", key)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunSelfChecks(t *testing.T) {
+	ok := selfCheck{name: "ok", run: func(ctx context.Context) (string, error) { return "all good", nil }}
+	failing := selfCheck{name: "failing", run: func(ctx context.Context) (string, error) { return "", errors.New("unreachable") }}
+
+	tests := []struct {
+		name        string
+		checks      []selfCheck
+		wantErr     bool
+		wantResults []selfCheckResult
+	}{
+		{
+			name:        "all checks pass",
+			checks:      []selfCheck{ok},
+			wantResults: []selfCheckResult{{Name: "ok", OK: true, Detail: "all good"}},
+		},
+		{
+			name:    "a check fails",
+			checks:  []selfCheck{failing, ok},
+			wantErr: true,
+			wantResults: []selfCheckResult{
+				{Name: "failing", Error: "unreachable"},
+				{Name: "ok", OK: true, Detail: "all good"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			err := runSelfChecks(context.Background(), tt.checks, &out)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			var report selfCheckReport
+			if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+				t.Fatal(err)
+			}
+
+			for i := range report.Checks {
+				report.Checks[i].DurationMs = 0
+			}
+
+			assert.Equal(t, !tt.wantErr, report.OK)
+			assert.Equal(t, tt.wantResults, report.Checks)
+		})
+	}
+}
+
+func TestCheckStripeRejectsKeysBeforeCallingStripe(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{
+			name:    "no key",
+			cfg:     Config{Env: "dev"},
+			wantErr: "no secret key configured",
+		},
+		{
+			name:    "test key in production",
+			cfg:     Config{Env: "prod", Stripe: StripeConfig{SecretKey: "sk_test_123"}},
+			wantErr: "a test mode key is configured in production",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := checkStripe(context.Background(), tt.cfg)
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/redis/go-redis/v9"
+	"github.com/stripe/stripe-go/v82"
+)
+
+// minCheckoutSessionTTL is the shortest lifetime Stripe accepts for a checkout session.
+const minCheckoutSessionTTL = 30 * time.Minute
+
+// maxCheckoutHold is the longest seats are held for checkouts from when they were first held. It's enough
+// to extend the holds once to the lifetime of a checkout session, repeated checkouts don't extend them
+// again.
+const maxCheckoutHold = seatLockTTL + minCheckoutSessionTTL
+
+var errCheckoutHoldExhausted = errors.New("the seats can't be held any longer for another checkout, complete the open checkout or select the seats again")
+
+// holdForCheckout returns when a checkout session of the locked seats has to expire, which is when their
+// locks expire. Locks expiring before a checkout session can, and the given keys stored along with them,
+// are extended to the shortest lifetime of a checkout session, unless that holds the seats longer than
+// maxCheckoutHold from heldSince. A zero heldSince, of carts created before it was kept, is capped from now.
+func (app *Application) holdForCheckout(
+	ctx context.Context,
+	showtimeID int,
+	seatIDs []int,
+	heldSince time.Time,
+	keys ...string) (time.Time, error) {
+
+	ttl, err := app.redis.PTTL(ctx, seatLockKey(showtimeID, seatIDs[0])).Result()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// negative values report a missing key or a key without expiry
+	if ttl <= 0 {
+		return time.Time{}, domain.ErrSeatLockExpired
+	}
+
+	now := time.Now()
+	if ttl >= minCheckoutSessionTTL {
+		return now.Add(ttl), nil
+	}
+
+	if heldSince.IsZero() {
+		heldSince = now
+	}
+
+	expiresAt := now.Add(minCheckoutSessionTTL)
+	if expiresAt.After(heldSince.Add(maxCheckoutHold)) {
+		return time.Time{}, errCheckoutHoldExhausted
+	}
+
+	pipe := app.redis.TxPipeline()
+
+	for _, seatID := range seatIDs {
+		pipe.ExpireAt(ctx, seatLockKey(showtimeID, seatID), expiresAt)
+	}
+
+	for _, key := range keys {
+		pipe.ExpireAt(ctx, key, expiresAt)
+	}
+
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to extend seat locks for checkout: %w", err)
+	}
+
+	return expiresAt, nil
+}
+
+// replaceCheckoutSession keeps the new checkout session in the cart and expires the one it replaces. The
+// new session can be paid either way, so failures are only logged.
+func (app *Application) replaceCheckoutSession(
+	ctx context.Context,
+	logger *slog.Logger,
+	cart *domain.Cart,
+	checkoutSessionID string) {
+
+	previous := cart.CheckoutSessionID
+	cart.CheckoutSessionID = checkoutSessionID
+
+	cartBytes, err := json.Marshal(cart)
+	if err == nil {
+		err = app.redis.Set(ctx, cart.Id, cartBytes, redis.KeepTTL).Err()
+	}
+
+	if err != nil {
+		logger.Error("failed to keep the checkout session in the cart", "cart_id", cart.Id, "error", err)
+	}
+
+	if previous == "" || previous == checkoutSessionID {
+		return
+	}
+
+	err = app.paymentProvider.ExpireCheckoutSession(ctx, previous)
+	if err != nil {
+		logger.Warn("failed to expire the replaced checkout session", "checkout_session_id", previous, "error", err)
+	}
+}
+
+// handleCheckoutSessionExpired cancels the payment of a checkout session that can't be paid anymore and
+// releases the seats held for it, unless a newer checkout holds them now.
+func (app *Application) handleCheckoutSessionExpired(
+	w http.ResponseWriter,
+	r *http.Request,
+	checkoutSession stripe.CheckoutSession) {
+
+	ctx := r.Context()
+
+	paymentId, err := strconv.Atoi(checkoutSession.Metadata[domain.CheckoutMetadataPaymentID])
+	if err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("%w: payment_id is missing or not in the expected format", errInvalidCheckoutMetadata))
+		return
+	}
+
+	logger := app.contextGetLogger(r).With("payment_id", paymentId)
+
+	err = app.paymentRepo.MarkCanceled(ctx, paymentId, checkoutSession.ID, "checkout session expired")
+	if err != nil {
+		if !errors.Is(err, domain.ErrRecordNotFound) {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		logger.Info("payment of the expired checkout session isn't pending, nothing to cancel")
+	}
+
+	if checkoutSession.Metadata[domain.CheckoutMetadataReservationID] != "" {
+		err = app.releaseExpiredShowtimeChange(ctx, logger, paymentId)
+	} else {
+		err = app.releaseExpiredCart(
+			ctx,
+			logger,
+			checkoutSession.ID,
+			checkoutSession.Metadata[domain.CheckoutMetadataCartID],
+			checkoutSession.Metadata[domain.CheckoutMetadataSessionID])
+	}
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	logger.Info("checkout session expired, its seats are released")
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// releaseExpiredCart drops the cart of an expired checkout session, unless the cart moved on to another
+// checkout session.
+func (app *Application) releaseExpiredCart(
+	ctx context.Context,
+	logger *slog.Logger,
+	checkoutSessionId,
+	cartId,
+	sessionId string) error {
+
+	if cartId == "" {
+		return nil
+	}
+
+	cartBytes, err := app.redis.Get(ctx, cartId).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+
+		return err
+	}
+
+	var cart domain.Cart
+
+	err = json.Unmarshal(cartBytes, &cart)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal cart %s: %w", cartId, err)
+	}
+
+	if cart.CheckoutSessionID != checkoutSessionId {
+		return nil
+	}
+
+	app.releaseCartSeats(ctx, logger, cart.ShowtimeID, cart.SeatIDs(), cartId, sessionId)
+
+	return nil
+}
+
+// releaseExpiredShowtimeChange drops the showtime change of an expired checkout session.
+func (app *Application) releaseExpiredShowtimeChange(ctx context.Context, logger *slog.Logger, paymentId int) error {
+	change, err := app.getShowtimeChange(ctx, paymentId)
+	if err != nil {
+		if errors.Is(err, errShowtimeChangeExpired) {
+			return nil
+		}
+
+		return err
+	}
+
+	app.releaseShowtimeChange(ctx, logger, paymentId, change)
+
+	return nil
+}
+
+// releaseShowtimeChange drops the change and releases the seats it still holds.
+func (app *Application) releaseShowtimeChange(
+	ctx context.Context,
+	logger *slog.Logger,
+	paymentId int,
+	change *domain.ShowtimeChange) {
+
+	lockKeys := make([]string, len(change.Seats))
+	for i, seat := range change.Seats {
+		lockKeys[i] = seatLockKey(change.ToShowtimeID, seat.SeatID)
+	}
+
+	// only the locks still owned by the change are released, the seats may be locked by others
+	released, err := releaseSeatsScript.Run(ctx, app.redis, lockKeys, rescheduleLockOwner(change.ReservationID)).Int64Slice()
+	if err != nil {
+		logger.Error("failed to release seat locks of a showtime change", "error", err)
+	}
+
+	var releasedSeatIds []int
+	pipe := app.redis.TxPipeline()
+
+	for i, seat := range change.Seats {
+		if i < len(released) && released[i] == 1 {
+			releasedSeatIds = append(releasedSeatIds, seat.SeatID)
+			pipe.SRem(ctx, seatSetKey(change.ToShowtimeID), seat.SeatID)
+		}
+	}
+
+	pipe.Del(ctx, showtimeChangeKey(paymentId))
+
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		logger.Error("failed to clean up showtime change from redis", "error", err)
+	}
+
+	if len(releasedSeatIds) > 0 {
+		app.publishSeatEvent(ctx, change.ToShowtimeID, seatEventReleased, releasedSeatIds)
+	}
+}
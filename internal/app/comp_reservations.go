@@ -0,0 +1,154 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/shopspring/decimal"
+)
+
+// CreateCompReservation issues complimentary tickets. The seats are locked under a hold of the staff member
+// while the reservation is written, so they can't be put in a cart meanwhile, and the reservation is created
+// along with a zero amount payment in a single transaction. A seat sold in between fails the whole
+// reservation like it fails a paid one.
+func (app *Application) CreateCompReservation(w http.ResponseWriter, r *http.Request) {
+	var input api.CreateCompReservationRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	staffId := app.contextGetUserId(r)
+	showtimeId := input.ShowtimeId
+	seatIds := input.SeatIdList
+	logger := app.contextGetLogger(r).With("showtime_id", showtimeId, "staff_id", staffId)
+
+	showtimeSeats, err := app.selectableSeats(r.Context(), showtimeId, seatIds)
+	if err != nil {
+		switch {
+		case errors.Is(err, errSeatsAlreadyReserved):
+			logger.Warn("comp reservation conflict: an already reserved seat was selected", "requested_seats", seatIds)
+			app.editConflictResponseWithErr(w, r, err)
+		case errors.Is(err, errSeatsBlocked):
+			logger.Warn("comp reservation conflict: a blocked seat was selected", "requested_seats", seatIds)
+			app.editConflictResponseWithErr(w, r, err)
+		case errors.Is(err, domain.ErrRecordNotFound):
+			logger.Warn("comp reservation failed: one or more requested seat IDs do not exist for the showtime", "requested_seats", seatIds)
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	allowed, err := app.canManageTheater(r.Context(), staffId, showtimeSeats.TheaterID)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.unauthorizedAccessResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	if !allowed {
+		logger.Warn("comp reservation denied: user is not staff of the theater", "theater_id", showtimeSeats.TheaterID)
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	customer, err := app.phoneBookingCustomer(r.Context(), input.CustomerEmail)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.tryLockSeats(r.Context(), seatIds, showtimeId, seatHoldKey(staffId, uuid.NewString()))
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrSeatAlreadyReserved):
+			logger.Warn("comp reservation conflict: an already locked seat was selected")
+			app.editConflictResponseWithErr(w, r, errSeatsAlreadyLocked)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	reservation := domain.Reservation{
+		UserID:           customer.ID,
+		ShowtimeID:       showtimeId,
+		ReservationSeats: showtimeSeats.PriceBreakdown().CompReservationSeats(showtimeId),
+		SalesChannel:     domain.SalesChannelBoxOffice,
+	}
+
+	if customer.ID == 0 {
+		reservation.GuestEmail = customer.Email
+	}
+
+	if input.Note != nil {
+		reservation.Note = sanitizeNote(*input.Note)
+	}
+
+	payment := &domain.Payment{
+		UserID:       customer.ID,
+		Amount:       decimal.Zero,
+		Currency:     domain.DefaultCurrency,
+		Status:       domain.PaymentStatusComp,
+		SalesChannel: domain.SalesChannelBoxOffice,
+	}
+
+	err = app.reservationRepo.CreateComp(r.Context(), &reservation, payment)
+
+	// the locks are dropped either way, the seats are reserved now or stay free
+	app.rollbackSeatLocks(r.Context(), showtimeId, seatIds)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrSeatAlreadyReserved):
+			logger.Warn("comp reservation conflict: a seat was sold meanwhile", "requested_seats", seatIds)
+			app.editConflictResponseWithErr(w, r, errSeatsAlreadyReserved)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	app.publishSeatEvent(r.Context(), showtimeId, seatEventReserved, seatIds)
+
+	logger = logger.With("reservation_id", reservation.ID, "payment_id", payment.ID)
+	logger.Info("complimentary reservation created", "guest", customer.ID == 0, "seat_ids", seatIds)
+
+	// the confirmation outlives the request, so it must not be cancelled along with it
+	go app.sendReservationConfirmation(context.WithoutCancel(r.Context()), logger, reservation)
+
+	resp := api.CompReservationResponse{
+		ReservationId: reservation.ID,
+		ShowtimeId:    showtimeId,
+		CustomerEmail: customer.Email,
+		Guest:         customer.ID == 0,
+		SeatIdList:    seatIds,
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
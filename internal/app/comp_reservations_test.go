@@ -0,0 +1,199 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/mock"
+)
+
+// expectCompSeatsLocked mocks the seats being locked for the staff member and the locks being dropped once
+// the reservation is written.
+func (s *PhoneBookingTestSuite) expectCompSeatsLocked() {
+	lockKeys := []string{seatLockKey(1, 1), seatLockKey(1, 2), seatLockKey(1, 3)}
+
+	s.redisClient.On("EvalSha", mock.Anything, mock.Anything, lockKeys, mock.MatchedBy(isStaffHold), int(seatLockTTL.Seconds())).
+		Return(redis.NewCmdResult("OK", nil)).Once()
+
+	s.redisClient.On("TxPipeline").Return(s.redisPipeline).Once()
+	s.redisPipeline.On("Del", mock.Anything, lockKeys).Return(redis.NewIntResult(3, nil)).Once()
+	s.redisPipeline.On("SRem", mock.Anything, seatSetKey(1), []interface{}{1, 2, 3}).Return(redis.NewIntResult(0, nil)).Once()
+	s.redisPipeline.On("Exec", mock.Anything).Return([]redis.Cmder{}, nil).Once()
+}
+
+func (s *PhoneBookingTestSuite) TestCreateCompReservation() {
+	validInput := api.CreateCompReservationRequest{
+		ShowtimeId:    1,
+		CustomerEmail: testCustomerEmail,
+		SeatIdList:    testSeatIDs,
+	}
+
+	isComp := mock.MatchedBy(func(p *domain.Payment) bool {
+		return p.Status == domain.PaymentStatusComp && p.Amount.IsZero() && p.SalesChannel == domain.SalesChannelBoxOffice
+	})
+
+	tests := []struct {
+		name           string
+		staffID        int
+		input          api.CreateCompReservationRequest
+		setupMocks     func()
+		wantStatus     int
+		wantErrMessage string
+		wantErrCode    api.ErrorCode
+		wantGuest      bool
+	}{
+		{
+			name:    "should fail when the showtime is missing",
+			staffID: testBoxOfficeStaffID,
+			input: api.CreateCompReservationRequest{
+				CustomerEmail: testCustomerEmail,
+				SeatIdList:    testSeatIDs,
+			},
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantErrCode: api.VALIDATIONFAILED,
+		},
+		{
+			name:    "should fail when a seat is already reserved",
+			staffID: testBoxOfficeStaffID,
+			input:   validInput,
+			setupMocks: func() {
+				s.reservationRepo.On("GetSeatsByShowtimeId", mock.Anything, 1).
+					Return([]domain.ReservationSeat{{ReservationID: 1, ShowtimeID: 1, SeatID: 2}}, nil)
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: errSeatsAlreadyReserved.Error(),
+			wantErrCode:    api.SEATALREADYRESERVED,
+		},
+		{
+			name:    "should fail when the user is not staff of the theater",
+			staffID: 8,
+			input:   validInput,
+			setupMocks: func() {
+				s.expectSelectableSeats()
+			},
+			wantStatus:     http.StatusForbidden,
+			wantErrMessage: ErrForbiddenAccess,
+			wantErrCode:    api.FORBIDDEN,
+		},
+		{
+			name:    "should fail when a seat is locked by someone else",
+			staffID: testBoxOfficeStaffID,
+			input:   validInput,
+			setupMocks: func() {
+				s.expectSelectableSeats()
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, mock.Anything, mock.MatchedBy(isStaffHold), mock.Anything).
+					Return(redis.NewCmdResult(nil, mocks.MockRedisError{Msg: "seat already locked"})).Once()
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: errSeatsAlreadyLocked.Error(),
+			wantErrCode:    api.SEATALREADYLOCKED,
+		},
+		{
+			name:    "should release the seats when one was sold meanwhile",
+			staffID: testBoxOfficeStaffID,
+			input:   validInput,
+			setupMocks: func() {
+				s.expectSelectableSeats()
+				s.expectCompSeatsLocked()
+				s.reservationRepo.On("CreateComp", mock.Anything, mock.Anything, isComp).
+					Return(domain.ErrSeatAlreadyReserved).Once()
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: errSeatsAlreadyReserved.Error(),
+			wantErrCode:    api.SEATALREADYRESERVED,
+		},
+		{
+			name:    "should release the seats when the reservation can't be stored",
+			staffID: testBoxOfficeStaffID,
+			input:   validInput,
+			setupMocks: func() {
+				s.expectSelectableSeats()
+				s.expectCompSeatsLocked()
+				s.reservationRepo.On("CreateComp", mock.Anything, mock.Anything, isComp).
+					Return(errors.New("db error")).Once()
+			},
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+			wantErrCode:    api.INTERNALERROR,
+		},
+		{
+			name:    "should give free seats to a guest",
+			staffID: testBoxOfficeStaffID,
+			input:   validInput,
+			setupMocks: func() {
+				s.expectSelectableSeats()
+				s.expectCompSeatsLocked()
+				s.reservationRepo.On("CreateComp", mock.Anything, mock.MatchedBy(func(r *domain.Reservation) bool {
+					for _, seat := range r.ReservationSeats {
+						if !seat.Price.IsZero() {
+							return false
+						}
+					}
+
+					return r.UserID == 0 && r.GuestEmail == testCustomerEmail && len(r.ReservationSeats) == len(testSeatIDs)
+				}), isComp).Run(func(args mock.Arguments) {
+					args.Get(1).(*domain.Reservation).ID = 55
+				}).Return(nil).Once()
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatMapChangeKeys(1), seatEventsChannel(1), mock.Anything, mock.Anything).
+					Return(redis.NewCmdResult(int64(1), nil)).Once()
+				// the confirmation is sent in the background
+				s.reservationRepo.On("GetByReservationIdAndUserId", mock.Anything, 55, 0).
+					Return(nil, domain.ErrRecordNotFound).Maybe()
+			},
+			wantStatus: http.StatusCreated,
+			wantGuest:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			w, r := executeRequest(s.T(), http.MethodPost, "/admin/reservations", tt.input)
+			r = r.WithContext(context.WithValue(r.Context(), SessionKeyUserId, tt.staffID))
+
+			s.app.CreateCompReservation(w, r)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantErrCode != "" {
+				checkErrorCode(s.T(), w, tt.wantErrCode)
+			}
+
+			if tt.wantStatus == http.StatusCreated {
+				var response api.CompReservationResponse
+				s.Require().NoError(json.NewDecoder(w.Body).Decode(&response))
+
+				s.Equal(55, response.ReservationId)
+				s.Equal(testCustomerEmail, response.CustomerEmail)
+				s.Equal(tt.wantGuest, response.Guest)
+				s.Equal(testSeatIDs, response.SeatIdList)
+			}
+
+			if tt.wantErrMessage != "" {
+				checkErrorResponse(s.T(), w, struct {
+					wantStatus     int
+					wantErrMessage string
+				}{
+					wantStatus:     tt.wantStatus,
+					wantErrMessage: tt.wantErrMessage,
+				})
+			}
+
+			s.redisClient.AssertExpectations(s.T())
+			s.redisPipeline.AssertExpectations(s.T())
+			s.reservationRepo.AssertExpectations(s.T())
+			s.seatRepo.AssertExpectations(s.T())
+		})
+	}
+}
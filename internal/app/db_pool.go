@@ -0,0 +1,282 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/metinatakli/movie-reservation-system/api"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	poolWarmUpTimeout = 10 * time.Second
+	// a replaced pool is closed after a delay, so the queries which picked it right before it was replaced
+	// still get their connections. Closing it waits for the connections acquired from it to be released.
+	poolCloseDelay = 5 * time.Second
+)
+
+// ResizablePool is the database pool of the application. The size of a pgxpool is fixed when it's created,
+// so resizing replaces the pool, while the repositories keep querying through the ResizablePool.
+type ResizablePool struct {
+	current atomic.Pointer[pgxpool.Pool]
+	// serializes resizes, so a replaced pool is never stored again
+	mu sync.Mutex
+}
+
+func NewResizablePool(db *pgxpool.Pool) *ResizablePool {
+	p := &ResizablePool{}
+	p.current.Store(db)
+
+	return p
+}
+
+// Pool returns the pool queries are currently run on.
+func (p *ResizablePool) Pool() *pgxpool.Pool {
+	return p.current.Load()
+}
+
+func (p *ResizablePool) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	return p.Pool().Exec(ctx, sql, arguments...)
+}
+
+func (p *ResizablePool) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return p.Pool().Query(ctx, sql, args...)
+}
+
+func (p *ResizablePool) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return p.Pool().QueryRow(ctx, sql, args...)
+}
+
+func (p *ResizablePool) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	return p.Pool().BeginTx(ctx, txOptions)
+}
+
+func (p *ResizablePool) CopyFrom(
+	ctx context.Context,
+	tableName pgx.Identifier,
+	columnNames []string,
+	rowSrc pgx.CopyFromSource) (int64, error) {
+
+	return p.Pool().CopyFrom(ctx, tableName, columnNames, rowSrc)
+}
+
+func (p *ResizablePool) Acquire(ctx context.Context) (*pgxpool.Conn, error) {
+	return p.Pool().Acquire(ctx)
+}
+
+func (p *ResizablePool) Ping(ctx context.Context) error {
+	return p.Pool().Ping(ctx)
+}
+
+func (p *ResizablePool) Stat() *pgxpool.Stat {
+	return p.Pool().Stat()
+}
+
+func (p *ResizablePool) Config() *pgxpool.Config {
+	return p.Pool().Config()
+}
+
+func (p *ResizablePool) Close() {
+	p.Pool().Close()
+}
+
+// Resize replaces the pool with one of the given size, configured like the current pool otherwise. The new
+// pool is warmed up to its minimum before queries are moved to it, the current pool is kept if that fails.
+// The statistics of the pool start over with the new pool.
+func (p *ResizablePool) Resize(ctx context.Context, maxConns, minConns int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	old := p.Pool()
+
+	config := old.Config()
+	config.MaxConns = int32(maxConns)
+	config.MinConns = int32(min(minConns, maxConns))
+
+	db, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		return err
+	}
+
+	err = db.Ping(ctx)
+	if err == nil {
+		err = warmUpPool(ctx, db, int(config.MinConns))
+	}
+
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	p.current.Store(db)
+	time.AfterFunc(poolCloseDelay, old.Close)
+
+	return nil
+}
+
+// warmUpPool opens connections until the pool holds n of them, so the first requests don't pay for
+// establishing them. The connections are acquired at the same time, an idle one would be handed out again
+// otherwise, and released together once all of them were acquired. n is capped at the maximum of the pool.
+func warmUpPool(ctx context.Context, db *pgxpool.Pool, n int) error {
+	n = min(n, int(db.Config().MaxConns))
+
+	type result struct {
+		conn *pgxpool.Conn
+		err  error
+	}
+
+	results := make(chan result, n)
+
+	for range n {
+		go func() {
+			conn, err := db.Acquire(ctx)
+			results <- result{conn, err}
+		}()
+	}
+
+	var firstErr error
+
+	for range n {
+		res := <-results
+		if res.err != nil {
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			continue
+		}
+
+		defer res.conn.Release()
+	}
+
+	if firstErr != nil {
+		return fmt.Errorf("unable to warm up the database pool: %w", firstErr)
+	}
+
+	return nil
+}
+
+// poolSaturation returns the share of the connections of the pool in use. Requests start waiting for a
+// connection once it reaches 1.
+func poolSaturation(stat *pgxpool.Stat) float64 {
+	if stat.MaxConns() == 0 {
+		return 0
+	}
+
+	return float64(stat.AcquiredConns()) / float64(stat.MaxConns())
+}
+
+// recordPoolSaturation exports the saturation of the pool next to the connection counts exported by
+// otelpgx, as the counts alone don't tell how close the pool is to making requests wait.
+func recordPoolSaturation(db *ResizablePool, logger *slog.Logger) {
+	meter := otel.Meter("github.com/metinatakli/movie-reservation-system/internal/app")
+
+	_, err := meter.Float64ObservableGauge(
+		"db.client.connection.saturation",
+		metric.WithDescription("Share of the connections of the database pool in use"),
+		metric.WithUnit("1"),
+		metric.WithFloat64Callback(func(_ context.Context, o metric.Float64Observer) error {
+			o.Observe(poolSaturation(db.Stat()))
+			return nil
+		}),
+	)
+	if err != nil {
+		logger.Error("failed to create pool saturation metric", "error", err)
+	}
+}
+
+// WarmUpDatabasePool opens connections ahead of an expected traffic peak, up to the maximum size of the
+// pool. ResizeDatabasePool changes the maximum.
+func (app *Application) WarmUpDatabasePool(w http.ResponseWriter, r *http.Request) {
+	logger := app.contextGetLogger(r)
+
+	var input api.WarmUpDatabasePoolRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), poolWarmUpTimeout)
+	defer cancel()
+
+	err = warmUpPool(ctx, app.db.Pool(), input.Connections)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	status := app.databaseStatus(r.Context())
+
+	logger.Info("database pool warmed up",
+		"requested_conns", input.Connections,
+		"total_conns", status.TotalConns,
+		"max_conns", status.MaxConns)
+
+	err = app.writeJSON(w, http.StatusOK, status, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// ResizeDatabasePool changes the size of the pool of the instance serving the request, e.g. when traffic
+// patterns change. Queries move to a new pool of that size, the connections of the old pool are closed
+// once they are released.
+func (app *Application) ResizeDatabasePool(w http.ResponseWriter, r *http.Request) {
+	logger := app.contextGetLogger(r)
+
+	var input api.ResizeDatabasePoolRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	minConns := int(app.db.Config().MinConns)
+	if input.MinConns != nil {
+		minConns = *input.MinConns
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), poolWarmUpTimeout)
+	defer cancel()
+
+	err = app.db.Resize(ctx, input.MaxConns, minConns)
+	if err != nil {
+		app.serverErrorResponse(w, r, fmt.Errorf("unable to resize the database pool: %w", err))
+		return
+	}
+
+	status := app.databaseStatus(r.Context())
+
+	logger.Info("database pool resized",
+		"max_conns", status.MaxConns,
+		"min_conns", status.MinConns,
+		"total_conns", status.TotalConns)
+
+	err = app.writeJSON(w, http.StatusOK, status, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
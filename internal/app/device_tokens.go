@@ -0,0 +1,267 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	accessTokenKeyPrefix = "access_token:"
+	bearerTokenType      = "Bearer"
+)
+
+func (app *Application) CreateDeviceTokens(w http.ResponseWriter, r *http.Request) {
+	logger := app.contextGetLogger(r)
+
+	var input api.DeviceLoginRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		logger.Warn("device login validation failed")
+		app.invalidCredentialsResponse(w, r)
+		return
+	}
+
+	user, err := app.userRepo.GetByEmail(r.Context(), input.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			logger.Warn("device login attempt for non-existent user")
+			app.invalidCredentialsResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = bcrypt.CompareHashAndPassword(user.Password.Hash, []byte(input.Password))
+	if err != nil {
+		logger.Warn("device login failed due to incorrect password")
+		app.invalidCredentialsResponse(w, r)
+		return
+	}
+
+	tokens, session, err := app.newDeviceTokens(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	session.DeviceID = input.DeviceId
+	if input.DeviceName != nil {
+		session.DeviceName = *input.DeviceName
+	}
+
+	replaced, err := app.deviceSessionRepo.Create(r.Context(), session)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if replaced != nil {
+		app.deleteAccessToken(r.Context(), replaced.AccessTokenHash)
+	}
+
+	err = app.storeAccessToken(r.Context(), session.AccessTokenHash, user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, tokens, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) RefreshDeviceTokens(w http.ResponseWriter, r *http.Request) {
+	logger := app.contextGetLogger(r)
+
+	var input api.RefreshTokenRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.unauthorizedAccessResponse(w, r)
+		return
+	}
+
+	// the user of the token is not known yet, it is taken from the session being rotated
+	tokens, next, err := app.newDeviceTokens(0)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	hash := sha256.Sum256([]byte(input.RefreshToken))
+
+	previous, err := app.deviceSessionRepo.Rotate(r.Context(), hash[:], next)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRefreshTokenReused):
+			logger.Warn("reused refresh token, device is signed out", "userId", previous.UserID, "deviceId", previous.DeviceID)
+			app.deleteAccessToken(r.Context(), previous.AccessTokenHash)
+			app.unauthorizedAccessResponse(w, r)
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.unauthorizedAccessResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	app.deleteAccessToken(r.Context(), previous.AccessTokenHash)
+
+	err = app.storeAccessToken(r.Context(), next.AccessTokenHash, previous.UserID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, tokens, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) RevokeDeviceTokens(w http.ResponseWriter, r *http.Request) {
+	var input api.RefreshTokenRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	hash := sha256.Sum256([]byte(input.RefreshToken))
+
+	session, err := app.deviceSessionRepo.Revoke(r.Context(), hash[:])
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	app.deleteAccessToken(r.Context(), session.AccessTokenHash)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// newDeviceTokens generates an access and refresh token pair. The returned session holds their hashes
+// and the expiry of the refresh token.
+func (app *Application) newDeviceTokens(userId int) (*api.DeviceTokensResponse, *domain.DeviceSession, error) {
+	access, err := domain.GenerateToken(int64(userId), app.config.Tokens.AccessTTL, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refresh, err := domain.GenerateToken(int64(userId), app.config.Tokens.RefreshTTL, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tokens := &api.DeviceTokensResponse{
+		TokenType:             bearerTokenType,
+		AccessToken:           access.Plaintext,
+		AccessTokenExpiresAt:  access.Expiry,
+		RefreshToken:          refresh.Plaintext,
+		RefreshTokenExpiresAt: refresh.Expiry,
+	}
+
+	session := &domain.DeviceSession{
+		UserID:           userId,
+		RefreshTokenHash: refresh.Hash,
+		AccessTokenHash:  access.Hash,
+		ExpiresAt:        refresh.Expiry,
+	}
+
+	return tokens, session, nil
+}
+
+func accessTokenKey(hash []byte) string {
+	return accessTokenKeyPrefix + hex.EncodeToString(hash)
+}
+
+func (app *Application) storeAccessToken(ctx context.Context, hash []byte, userId int) error {
+	return app.redis.Set(ctx, accessTokenKey(hash), userId, app.config.Tokens.AccessTTL).Err()
+}
+
+// deleteAccessToken invalidates the access token before it expires. A failure is only logged, the token
+// expires on its own shortly.
+func (app *Application) deleteAccessToken(ctx context.Context, hash []byte) {
+	if len(hash) == 0 {
+		return
+	}
+
+	err := app.redis.Del(ctx, accessTokenKey(hash)).Err()
+	if err != nil {
+		app.logger.Error("failed to delete access token", "error", err)
+	}
+}
+
+// bearerUserId returns the user of the access token in the Authorization header. The boolean result
+// reports whether the request carried a bearer token at all; a token that is invalid or expired yields
+// a zero user ID.
+func (app *Application) bearerUserId(r *http.Request) (int, bool, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return 0, false, nil
+	}
+
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, bearerTokenType) || token == "" {
+		return 0, true, nil
+	}
+
+	hash := sha256.Sum256([]byte(token))
+
+	value, err := app.redis.Get(r.Context(), accessTokenKey(hash[:])).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, true, nil
+		}
+
+		return 0, true, err
+	}
+
+	userId, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, true, err
+	}
+
+	return userId, true, nil
+}
@@ -0,0 +1,280 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testRefreshToken = "Xq3kR9vT1mB7nC2pL8wE5yH4jF6gD0sA1zU3iO7aK2c"
+
+func newDeviceTokensTestApp(deviceRepo *mocks.MockDeviceSessionRepo, redisClient *mocks.MockRedisClient) *Application {
+	return newTestApplication(func(a *Application) {
+		a.deviceSessionRepo = deviceRepo
+		a.redis = redisClient
+		a.config.Tokens = TokensConfig{AccessTTL: 15 * time.Minute, RefreshTTL: 24 * time.Hour}
+	})
+}
+
+func TestCreateDeviceTokens(t *testing.T) {
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("Pass123!@#"), 12)
+	user := &domain.User{ID: 7, Email: "freddie@example.com"}
+	user.Password.Hash = hashedPassword
+
+	tests := []struct {
+		name           string
+		input          api.DeviceLoginRequest
+		replaced       *domain.DeviceSession
+		wantStatus     int
+		wantErrMessage string
+	}{
+		{
+			name:           "wrong password",
+			input:          api.DeviceLoginRequest{Email: user.Email, Password: "Wrong123!@#", DeviceId: "iphone-1"},
+			wantStatus:     http.StatusUnauthorized,
+			wantErrMessage: ErrInvalidCredentials,
+		},
+		{
+			name:           "missing device",
+			input:          api.DeviceLoginRequest{Email: user.Email, Password: "Pass123!@#"},
+			wantStatus:     http.StatusUnauthorized,
+			wantErrMessage: ErrInvalidCredentials,
+		},
+		{
+			name:       "first login of the device",
+			input:      api.DeviceLoginRequest{Email: user.Email, Password: "Pass123!@#", DeviceId: "iphone-1"},
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "login again from the device revokes the old access token",
+			input:      api.DeviceLoginRequest{Email: user.Email, Password: "Pass123!@#", DeviceId: "iphone-1"},
+			replaced:   &domain.DeviceSession{AccessTokenHash: []byte{0xab}},
+			wantStatus: http.StatusCreated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deviceRepo := new(mocks.MockDeviceSessionRepo)
+			redisClient := new(mocks.MockRedisClient)
+			app := newDeviceTokensTestApp(deviceRepo, redisClient)
+			app.userRepo = &mocks.MockUserRepo{
+				GetByEmailFunc: func(ctx context.Context, email string) (*domain.User, error) {
+					return user, nil
+				},
+			}
+
+			if tt.wantStatus == http.StatusCreated {
+				deviceRepo.On("Create", mock.Anything, mock.MatchedBy(func(s *domain.DeviceSession) bool {
+					return s.UserID == user.ID && s.DeviceID == "iphone-1" && len(s.RefreshTokenHash) == sha256.Size
+				})).Return(tt.replaced, nil)
+				redisClient.On("Set", mock.Anything, mock.Anything, user.ID, 15*time.Minute).
+					Return(redis.NewStatusResult("OK", nil))
+			}
+
+			if tt.replaced != nil {
+				redisClient.On("Del", mock.Anything, []string{"access_token:ab"}).Return(redis.NewIntResult(1, nil))
+			}
+
+			w, r := executeRequest(t, http.MethodPost, "/tokens", tt.input)
+			app.CreateDeviceTokens(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+
+			checkErrorResponse(t, w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{tt.wantStatus, tt.wantErrMessage})
+
+			if tt.wantStatus == http.StatusCreated {
+				var resp api.DeviceTokensResponse
+				if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+					t.Fatal(err)
+				}
+
+				if resp.TokenType != "Bearer" || resp.AccessToken == "" || resp.RefreshToken == "" {
+					t.Errorf("unexpected tokens %+v", resp)
+				}
+			}
+
+			deviceRepo.AssertExpectations(t)
+			redisClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRefreshDeviceTokens(t *testing.T) {
+	hash := sha256.Sum256([]byte(testRefreshToken))
+	previous := &domain.DeviceSession{ID: 1, UserID: 7, DeviceID: "iphone-1", AccessTokenHash: []byte{0xab}}
+
+	tests := []struct {
+		name       string
+		rotateErr  error
+		wantStatus int
+	}{
+		{
+			name:       "rotated",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "unknown token",
+			rotateErr:  domain.ErrRecordNotFound,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "reused token signs the device out",
+			rotateErr:  domain.ErrRefreshTokenReused,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "database error",
+			rotateErr:  errors.New("boom"),
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deviceRepo := new(mocks.MockDeviceSessionRepo)
+			redisClient := new(mocks.MockRedisClient)
+			app := newDeviceTokensTestApp(deviceRepo, redisClient)
+
+			switch {
+			case tt.rotateErr == nil, errors.Is(tt.rotateErr, domain.ErrRefreshTokenReused):
+				deviceRepo.On("Rotate", mock.Anything, hash[:], mock.Anything).Return(previous, tt.rotateErr)
+				redisClient.On("Del", mock.Anything, []string{"access_token:ab"}).Return(redis.NewIntResult(1, nil))
+			default:
+				deviceRepo.On("Rotate", mock.Anything, hash[:], mock.Anything).Return(nil, tt.rotateErr)
+			}
+
+			if tt.wantStatus == http.StatusOK {
+				redisClient.On("Set", mock.Anything, mock.Anything, previous.UserID, 15*time.Minute).
+					Return(redis.NewStatusResult("OK", nil))
+			}
+
+			w, r := executeRequest(t, http.MethodPost, "/tokens/refresh", api.RefreshTokenRequest{RefreshToken: testRefreshToken})
+			app.RefreshDeviceTokens(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+
+			deviceRepo.AssertExpectations(t)
+			redisClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRevokeDeviceTokens(t *testing.T) {
+	hash := sha256.Sum256([]byte(testRefreshToken))
+
+	t.Run("revokes both tokens", func(t *testing.T) {
+		deviceRepo := new(mocks.MockDeviceSessionRepo)
+		redisClient := new(mocks.MockRedisClient)
+		app := newDeviceTokensTestApp(deviceRepo, redisClient)
+
+		deviceRepo.On("Revoke", mock.Anything, hash[:]).Return(&domain.DeviceSession{AccessTokenHash: []byte{0xab}}, nil)
+		redisClient.On("Del", mock.Anything, []string{"access_token:ab"}).Return(redis.NewIntResult(1, nil))
+
+		w, r := executeRequest(t, http.MethodPost, "/tokens/revoke", api.RefreshTokenRequest{RefreshToken: testRefreshToken})
+		app.RevokeDeviceTokens(w, r)
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d", http.StatusNoContent, w.Code)
+		}
+
+		redisClient.AssertExpectations(t)
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		deviceRepo := new(mocks.MockDeviceSessionRepo)
+		app := newDeviceTokensTestApp(deviceRepo, new(mocks.MockRedisClient))
+
+		deviceRepo.On("Revoke", mock.Anything, hash[:]).Return(nil, domain.ErrRecordNotFound)
+
+		w, r := executeRequest(t, http.MethodPost, "/tokens/revoke", api.RefreshTokenRequest{RefreshToken: testRefreshToken})
+		app.RevokeDeviceTokens(w, r)
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d", http.StatusNoContent, w.Code)
+		}
+	})
+}
+
+func TestRequireAuthenticationWithBearerToken(t *testing.T) {
+	accessHash := sha256.Sum256([]byte("access"))
+	key := accessTokenKey(accessHash[:])
+
+	tests := []struct {
+		name       string
+		header     string
+		setupRedis func(*mocks.MockRedisClient)
+		wantStatus int
+	}{
+		{
+			name:   "valid token",
+			header: "Bearer access",
+			setupRedis: func(c *mocks.MockRedisClient) {
+				c.On("Get", mock.Anything, key).Return(redis.NewStringResult("7", nil))
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:   "expired token",
+			header: "Bearer access",
+			setupRedis: func(c *mocks.MockRedisClient) {
+				c.On("Get", mock.Anything, key).Return(redis.NewStringResult("", redis.Nil))
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "other scheme",
+			header:     "Basic access",
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisClient := new(mocks.MockRedisClient)
+			if tt.setupRedis != nil {
+				tt.setupRedis(redisClient)
+			}
+
+			app := newDeviceTokensTestApp(new(mocks.MockDeviceSessionRepo), redisClient)
+
+			var gotUserId int
+			handler := app.requireAuthentication(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotUserId = app.contextGetUserId(r)
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+			r.Header.Set("Authorization", tt.header)
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+
+			if tt.wantStatus == http.StatusOK && gotUserId != 7 {
+				t.Errorf("expected user 7, got %d", gotUserId)
+			}
+		})
+	}
+}
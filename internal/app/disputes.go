@@ -0,0 +1,163 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mailer"
+	"github.com/shopspring/decimal"
+	"github.com/stripe/stripe-go/v82"
+)
+
+// handleDisputeEvent records a dispute reported by Stripe. Finance staff are notified the first time a
+// dispute is seen, follow-up events only update its status.
+func (app *Application) handleDisputeEvent(w http.ResponseWriter, r *http.Request, stripeDispute stripe.Dispute) {
+	logger := app.contextGetLogger(r).With("stripe_dispute_id", stripeDispute.ID)
+
+	if stripeDispute.PaymentIntent == nil || stripeDispute.PaymentIntent.ID == "" {
+		app.badRequestResponse(w, r, fmt.Errorf("dispute %s has no payment intent", stripeDispute.ID))
+		return
+	}
+
+	dispute := toDomainDispute(stripeDispute)
+
+	created, err := app.disputeRepo.Record(
+		r.Context(),
+		stripeDispute.PaymentIntent.ID,
+		dispute,
+		app.config.Disputes.RevokeTickets,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponseWithErr(
+				w, r, fmt.Errorf("payment not found for payment intent %s", stripeDispute.PaymentIntent.ID))
+		default:
+			app.serverErrorResponse(w, r, fmt.Errorf("failed to record dispute: %w", err))
+		}
+
+		return
+	}
+
+	logger.Info("dispute recorded", "dispute_status", dispute.Status, "new_dispute", created)
+
+	if created {
+		go app.notifyFinanceOfDispute(context.WithoutCancel(r.Context()), logger, *dispute)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func toDomainDispute(dispute stripe.Dispute) *domain.Dispute {
+	var evidenceDueBy *time.Time
+	if dispute.EvidenceDetails != nil && dispute.EvidenceDetails.DueBy > 0 {
+		dueBy := time.Unix(dispute.EvidenceDetails.DueBy, 0).UTC()
+		evidenceDueBy = &dueBy
+	}
+
+	return &domain.Dispute{
+		StripeDisputeID: dispute.ID,
+		Amount:          decimal.New(dispute.Amount, -2),
+		Currency:        strings.ToUpper(string(dispute.Currency)),
+		Reason:          string(dispute.Reason),
+		Status:          string(dispute.Status),
+		EvidenceDueBy:   evidenceDueBy,
+	}
+}
+
+func (app *Application) notifyFinanceOfDispute(ctx context.Context, logger *slog.Logger, dispute domain.Dispute) {
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Error("panic occurred during sending dispute notification", "panic", err)
+		}
+	}()
+
+	if app.config.Disputes.FinanceEmails == "" {
+		logger.Warn("no finance addresses configured, dispute notification skipped")
+		return
+	}
+
+	data := mailer.DisputeCreatedEmail{
+		DisputeID:      dispute.StripeDisputeID,
+		PaymentID:      dispute.PaymentID,
+		Amount:         dispute.Amount.StringFixed(2),
+		Currency:       dispute.Currency,
+		Reason:         dispute.Reason,
+		Status:         dispute.Status,
+		TicketsRevoked: app.config.Disputes.RevokeTickets && dispute.ReservationID != nil,
+		ReservationID:  dispute.ReservationID,
+	}
+
+	if dispute.EvidenceDueBy != nil {
+		data.EvidenceDueBy = dispute.EvidenceDueBy.Format(time.RFC1123)
+	}
+
+	for _, recipient := range strings.Split(app.config.Disputes.FinanceEmails, ",") {
+		recipient = strings.TrimSpace(recipient)
+		if recipient == "" {
+			continue
+		}
+
+		err := app.mailer.Send(ctx, recipient, data)
+		if err != nil {
+			logger.Error("failed to send dispute notification", "error", err, "recipient", recipient)
+		}
+	}
+}
+
+func (app *Application) GetDisputes(w http.ResponseWriter, r *http.Request, params api.GetDisputesParams) {
+	err := app.validator.Struct(params)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	var status string
+	if params.Status != nil {
+		status = *params.Status
+	}
+
+	pagination := toPagination(params.Page, params.PageSize)
+
+	disputes, metadata, err := app.disputeRepo.List(r.Context(), status, pagination)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	resp := api.DisputesResponse{
+		Disputes: make([]api.Dispute, len(disputes)),
+		Metadata: *toApiMetadata(r, metadata),
+	}
+
+	for i, dispute := range disputes {
+		resp.Disputes[i] = api.Dispute{
+			Id:              dispute.ID,
+			PaymentId:       dispute.PaymentID,
+			ReservationId:   dispute.ReservationID,
+			StripeDisputeId: dispute.StripeDisputeID,
+			Amount:          dispute.Amount,
+			Currency:        dispute.Currency,
+			Reason:          dispute.Reason,
+			Status:          dispute.Status,
+			EvidenceDueBy:   dispute.EvidenceDueBy,
+			CreatedAt:       dispute.CreatedAt,
+			UpdatedAt:       dispute.UpdatedAt,
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// errTicketsRevoked is returned for reservations whose tickets must no longer be honored.
+var errTicketsRevoked = errors.New("the tickets of this reservation have been revoked")
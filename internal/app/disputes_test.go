@@ -0,0 +1,259 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mailer"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/metinatakli/movie-reservation-system/internal/validator"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type sentEmail struct {
+	recipient string
+	template  string
+	data      any
+}
+
+type DisputesTestSuite struct {
+	suite.Suite
+	app         *Application
+	disputeRepo *mocks.MockDisputeRepo
+	emails      chan sentEmail
+}
+
+func (s *DisputesTestSuite) SetupTest() {
+	s.disputeRepo = new(mocks.MockDisputeRepo)
+	s.emails = make(chan sentEmail, 10)
+	s.app = newTestApplication(func(a *Application) {
+		a.config.Stripe.WebhookSecret = testWebhookSecret
+		a.config.Disputes.FinanceEmails = "finance@cinex.net, audit@cinex.net"
+		a.config.Disputes.RevokeTickets = true
+		a.disputeRepo = s.disputeRepo
+		a.sessionManager = scs.New()
+		a.mailer = &MockMailer{sendFunc: func(recipient, template string, data any) error {
+			s.emails <- sentEmail{recipient: recipient, template: template, data: data}
+			return nil
+		}}
+	})
+}
+
+func TestDisputesSuite(t *testing.T) {
+	suite.Run(t, new(DisputesTestSuite))
+}
+
+func (s *DisputesTestSuite) TestDisputeWebhooks() {
+	disputeObject := map[string]any{
+		"id":             "dp_1",
+		"object":         "dispute",
+		"amount":         2550,
+		"currency":       "usd",
+		"reason":         "fraudulent",
+		"status":         "needs_response",
+		"payment_intent": "pi_1",
+		"evidence_details": map[string]any{
+			"due_by": 1710529200,
+		},
+	}
+
+	tests := []struct {
+		name       string
+		eventType  string
+		object     any
+		setupMock  func()
+		wantStatus int
+		wantEmails []string
+	}{
+		{
+			name:      "new dispute notifies finance",
+			eventType: "charge.dispute.created",
+			object:    disputeObject,
+			setupMock: func() {
+				s.disputeRepo.On("Record", mock.Anything, "pi_1", mock.MatchedBy(func(d *domain.Dispute) bool {
+					return d.StripeDisputeID == "dp_1" &&
+						d.Amount.Equal(decimal.RequireFromString("25.50")) &&
+						d.Currency == "USD" &&
+						d.Reason == "fraudulent" &&
+						d.Status == "needs_response" &&
+						d.EvidenceDueBy != nil && d.EvidenceDueBy.Equal(time.Unix(1710529200, 0))
+				}), true).Run(func(args mock.Arguments) {
+					d := args.Get(2).(*domain.Dispute)
+					d.PaymentID = 3
+					d.ReservationID = ptr(9)
+				}).Return(true, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantEmails: []string{"finance@cinex.net", "audit@cinex.net"},
+		},
+		{
+			name:      "follow-up event only updates the status",
+			eventType: "charge.dispute.closed",
+			object:    disputeObject,
+			setupMock: func() {
+				s.disputeRepo.On("Record", mock.Anything, "pi_1", mock.Anything, true).Return(false, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:      "dispute without payment intent",
+			eventType: "charge.dispute.created",
+			object: map[string]any{
+				"id":     "dp_2",
+				"object": "dispute",
+				"status": "needs_response",
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:      "unknown payment intent",
+			eventType: "charge.dispute.created",
+			object:    disputeObject,
+			setupMock: func() {
+				s.disputeRepo.On("Record", mock.Anything, "pi_1", mock.Anything, true).Return(false, domain.ErrRecordNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:      "database error",
+			eventType: "charge.dispute.updated",
+			object:    disputeObject,
+			setupMock: func() {
+				s.disputeRepo.On("Record", mock.Anything, "pi_1", mock.Anything, true).Return(false, errors.New("database error"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			if tt.setupMock != nil {
+				tt.setupMock()
+			}
+
+			w := httptest.NewRecorder()
+			r := signedWebhookRequest(s.T(), tt.eventType, tt.object)
+
+			s.app.StripeWebhookHandler(w, r)
+
+			s.Equal(tt.wantStatus, w.Code)
+			s.disputeRepo.AssertExpectations(s.T())
+
+			for _, want := range tt.wantEmails {
+				select {
+				case email := <-s.emails:
+					s.Equal(want, email.recipient)
+					s.Equal("dispute_created.tmpl", email.template)
+
+					data := email.data.(mailer.DisputeCreatedEmail)
+					s.Equal(ptr(9), data.ReservationID)
+					s.True(data.TicketsRevoked)
+				case <-time.After(time.Second):
+					s.Fail("dispute notification was not sent", want)
+				}
+			}
+
+			select {
+			case email := <-s.emails:
+				s.Fail("unexpected email", email.recipient)
+			default:
+			}
+		})
+	}
+}
+
+func (s *DisputesTestSuite) TestGetDisputes() {
+	createdAt := time.Date(2024, 3, 10, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		params         api.GetDisputesParams
+		setupMock      func()
+		wantStatus     int
+		wantErrMessage string
+		wantDisputes   int
+	}{
+		{
+			name:           "unknown status",
+			params:         api.GetDisputesParams{Status: ptr("open")},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: fmt.Sprintf(validator.ErrOneOf, "warning_needs_response warning_under_review warning_closed needs_response under_review won lost prevented"),
+		},
+		{
+			name: "database error",
+			setupMock: func() {
+				s.disputeRepo.On("List", mock.Anything, "", domain.Pagination{Page: 1, PageSize: 10}).
+					Return(nil, nil, errors.New("database error"))
+			},
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+		{
+			name:   "filtered by status",
+			params: api.GetDisputesParams{Status: ptr("needs_response")},
+			setupMock: func() {
+				s.disputeRepo.On("List", mock.Anything, "needs_response", domain.Pagination{Page: 1, PageSize: 10}).
+					Return([]domain.Dispute{{
+						ID:              1,
+						PaymentID:       3,
+						ReservationID:   ptr(9),
+						StripeDisputeID: "dp_1",
+						Amount:          decimal.RequireFromString("25.50"),
+						Currency:        "USD",
+						Reason:          "fraudulent",
+						Status:          "needs_response",
+						CreatedAt:       createdAt,
+						UpdatedAt:       createdAt,
+					}}, domain.NewMetadata(1, 1, 10), nil)
+			},
+			wantStatus:   http.StatusOK,
+			wantDisputes: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			if tt.setupMock != nil {
+				tt.setupMock()
+			}
+
+			w, r := executeRequest(s.T(), http.MethodGet, "/admin/disputes", nil)
+
+			s.app.GetDisputes(w, r, tt.params)
+
+			s.Equal(tt.wantStatus, w.Code)
+			s.disputeRepo.AssertExpectations(s.T())
+
+			if tt.wantStatus != http.StatusOK {
+				checkErrorResponse(s.T(), w, struct {
+					wantStatus     int
+					wantErrMessage string
+				}{
+					wantStatus:     tt.wantStatus,
+					wantErrMessage: tt.wantErrMessage,
+				})
+				return
+			}
+
+			var resp api.DisputesResponse
+			s.Require().NoError(json.NewDecoder(w.Body).Decode(&resp))
+			s.Require().Len(resp.Disputes, tt.wantDisputes)
+			s.Equal("dp_1", resp.Disputes[0].StripeDisputeId)
+			s.Equal(ptr(9), resp.Disputes[0].ReservationId)
+		})
+	}
+}
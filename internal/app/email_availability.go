@@ -0,0 +1,53 @@
+package app
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+)
+
+var (
+	// the answer tells whether an account exists, the limit keeps a client from checking address lists
+	emailAvailabilityClientLimit = rateLimit{name: "email_availability_client", limit: 10, window: 15 * time.Minute}
+
+	// every answer takes at least this long, so the time of the lookup doesn't tell anything either
+	emailAvailabilityResponseTime = 300 * time.Millisecond
+)
+
+// CheckEmailAvailability tells the signup form whether an address can still be registered.
+func (app *Application) CheckEmailAvailability(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	var input api.EmailAvailabilityRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	if !app.allowRequest(w, r, emailAvailabilityClientLimit, clientIP(r)) {
+		return
+	}
+
+	exists, err := app.userRepo.ExistsByEmail(r.Context(), strings.ToLower(input.Email))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	time.Sleep(time.Until(start.Add(emailAvailabilityResponseTime)))
+
+	err = app.writeJSON(w, http.StatusOK, api.EmailAvailabilityResponse{Available: !exists}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,134 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/metinatakli/movie-reservation-system/internal/validator"
+)
+
+func TestCheckEmailAvailability(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          api.EmailAvailabilityRequest
+		setupRedis     func(*mocks.MockRedisClient)
+		exists         bool
+		existsErr      error
+		wantStatus     int
+		wantErrMessage string
+		wantAvailable  bool
+	}{
+		{
+			name:           "invalid email",
+			input:          api.EmailAvailabilityRequest{Email: "not-an-email"},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: validator.ErrInvalidEmail,
+		},
+		{
+			name:  "too many checks from the client",
+			input: api.EmailAvailabilityRequest{Email: "freddie@example.com"},
+			setupRedis: func(c *mocks.MockRedisClient) {
+				allowRateLimit(c, "rate_limit:email_availability_client:192.0.2.1", 60000)
+			},
+			wantStatus:     http.StatusTooManyRequests,
+			wantErrMessage: ErrRateLimitExceeded,
+		},
+		{
+			name:  "database error",
+			input: api.EmailAvailabilityRequest{Email: "freddie@example.com"},
+			setupRedis: func(c *mocks.MockRedisClient) {
+				allowRateLimit(c, "rate_limit:email_availability_client:192.0.2.1", 0)
+			},
+			existsErr:      errors.New("database error"),
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+		{
+			name:  "address is taken",
+			input: api.EmailAvailabilityRequest{Email: "Freddie@Example.com"},
+			setupRedis: func(c *mocks.MockRedisClient) {
+				allowRateLimit(c, "rate_limit:email_availability_client:192.0.2.1", 0)
+			},
+			exists:     true,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:  "address is available",
+			input: api.EmailAvailabilityRequest{Email: "nobody@example.com"},
+			setupRedis: func(c *mocks.MockRedisClient) {
+				allowRateLimit(c, "rate_limit:email_availability_client:192.0.2.1", 0)
+			},
+			wantStatus:    http.StatusOK,
+			wantAvailable: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisClient := new(mocks.MockRedisClient)
+			if tt.setupRedis != nil {
+				tt.setupRedis(redisClient)
+			}
+
+			var lookedUpEmail string
+
+			app := newTestApplication(func(a *Application) {
+				a.redis = redisClient
+				a.userRepo = &mocks.MockUserRepo{
+					ExistsByEmailFunc: func(ctx context.Context, email string) (bool, error) {
+						lookedUpEmail = email
+						return tt.exists, tt.existsErr
+					},
+				}
+			})
+
+			w, r := executeRequest(t, http.MethodPost, "/users/availability", tt.input)
+			r.RemoteAddr = "192.0.2.1:51234"
+
+			start := time.Now()
+			app.CheckEmailAvailability(w, r)
+			elapsed := time.Since(start)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			redisClient.AssertExpectations(t)
+
+			checkErrorResponse(t, w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			if lookedUpEmail != "freddie@example.com" && lookedUpEmail != "nobody@example.com" {
+				t.Errorf("looked up email = %q, want a lowercase address", lookedUpEmail)
+			}
+
+			if elapsed < emailAvailabilityResponseTime {
+				t.Errorf("answered after %s, want at least %s", elapsed, emailAvailabilityResponseTime)
+			}
+
+			var resp api.EmailAvailabilityResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.Available != tt.wantAvailable {
+				t.Errorf("available = %v, want %v", resp.Available, tt.wantAvailable)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mailer"
+	"github.com/metinatakli/movie-reservation-system/internal/scheduler"
+)
+
+// trackingPixel is a transparent 1x1 GIF.
+var trackingPixel = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+func (app *Application) CreateEmailCampaign(w http.ResponseWriter, r *http.Request) {
+	var input api.CreateEmailCampaignRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	campaign := &domain.EmailCampaign{
+		MovieID: input.MovieId,
+		Subject: input.Subject,
+		Body:    input.Body,
+		Filter: domain.CampaignFilter{
+			FavoriteTheaterID: input.FavoriteTheaterId,
+			Latitude:          input.Latitude,
+			Longitude:         input.Longitude,
+			RadiusKm:          input.RadiusKm,
+		},
+		CreatedBy: app.contextGetUserId(r),
+	}
+
+	err = app.emailCampaignRepo.Create(r.Context(), campaign)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrMovieNotFound), errors.Is(err, domain.ErrTheaterNotFound):
+			app.notFoundResponseWithErr(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	app.contextGetLogger(r).Info("email campaign queued",
+		"campaign_id", campaign.ID,
+		"movie_id", campaign.MovieID,
+		"recipient_count", campaign.RecipientCount)
+
+	resp := api.EmailCampaign{
+		Id:                campaign.ID,
+		MovieId:           campaign.MovieID,
+		Subject:           campaign.Subject,
+		Body:              campaign.Body,
+		FavoriteTheaterId: campaign.Filter.FavoriteTheaterID,
+		Latitude:          campaign.Filter.Latitude,
+		Longitude:         campaign.Filter.Longitude,
+		RadiusKm:          campaign.Filter.RadiusKm,
+		RecipientCount:    campaign.RecipientCount,
+		CreatedAt:         campaign.CreatedAt,
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) GetEmailCampaignStats(w http.ResponseWriter, r *http.Request, campaignId int) {
+	if campaignId < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("campaign ID must be greater than zero"))
+		return
+	}
+
+	stats, err := app.emailCampaignRepo.GetStats(r.Context(), campaignId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	resp := api.EmailCampaignStats{
+		CampaignId:   stats.CampaignID,
+		Recipients:   stats.Recipients,
+		Pending:      stats.Pending,
+		Sent:         stats.Sent,
+		Failed:       stats.Failed,
+		Skipped:      stats.Skipped,
+		Opened:       stats.Opened,
+		Unsubscribed: stats.Unsubscribed,
+	}
+
+	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) UnsubscribeFromEmailCampaigns(
+	w http.ResponseWriter,
+	r *http.Request,
+	params api.UnsubscribeFromEmailCampaignsParams) {
+
+	err := app.validator.Struct(params)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	hash := sha256.Sum256([]byte(params.Token))
+
+	err = app.emailCampaignRepo.Unsubscribe(r.Context(), hash[:])
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, api.UnsubscribeResponse{Unsubscribed: true}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// TrackEmailCampaignOpen always answers with the pixel, an email client has nothing to do with an error.
+func (app *Application) TrackEmailCampaignOpen(
+	w http.ResponseWriter,
+	r *http.Request,
+	params api.TrackEmailCampaignOpenParams) {
+
+	if params.Token != "" {
+		hash := sha256.Sum256([]byte(params.Token))
+
+		err := app.emailCampaignRepo.RecordOpen(r.Context(), hash[:])
+		if err != nil && !errors.Is(err, domain.ErrRecordNotFound) {
+			app.contextGetLogger(r).Error("failed to record email campaign open", "error", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "image/gif")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(trackingPixel)
+}
+
+// dispatchEmailCampaigns sends a batch of pending campaign emails, no faster than the configured rate so
+// the SMTP provider doesn't throttle or flag the sender. A failed email is recorded and not retried.
+func (app *Application) dispatchEmailCampaigns(ctx context.Context) error {
+	deliveries, err := app.emailCampaignRepo.GetPendingDeliveries(ctx, app.config.Campaigns.BatchSize)
+	if err != nil {
+		return err
+	}
+
+	var throttle <-chan time.Time
+	if app.config.Campaigns.RateLimit > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / app.config.Campaigns.RateLimit))
+		defer ticker.Stop()
+
+		throttle = ticker.C
+	}
+
+	sent := 0
+
+	for i, delivery := range deliveries {
+		if i > 0 && throttle != nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-throttle:
+			}
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err := scheduler.CheckFence(ctx); err != nil {
+			return err
+		}
+
+		token, tokenHash, err := domain.GenerateCampaignToken()
+		if err != nil {
+			return err
+		}
+
+		data := mailer.CampaignNewReleaseEmail{
+			FirstName:      delivery.FirstName,
+			Subject:        delivery.Subject,
+			Body:           delivery.Body,
+			MovieTitle:     delivery.MovieTitle,
+			ReleaseDate:    delivery.ReleaseDate.Format("January 2, 2006"),
+			PosterUrl:      delivery.PosterUrl,
+			UnsubscribeUrl: app.campaignLink("/email-campaigns/unsubscribe", token),
+			OpenUrl:        app.campaignLink("/email-campaigns/open", token),
+		}
+
+		err = app.mailer.Send(ctx, delivery.Email, data)
+		if err != nil {
+			app.logger.Error("failed to send campaign email",
+				"campaign_id", delivery.CampaignID,
+				"userId", delivery.UserID,
+				"error", err)
+
+			err = app.emailCampaignRepo.MarkDeliveryFailed(ctx, delivery.CampaignID, delivery.UserID, err.Error())
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		err = app.emailCampaignRepo.MarkDeliverySent(ctx, delivery.CampaignID, delivery.UserID, tokenHash)
+		if err != nil {
+			return err
+		}
+
+		sent++
+	}
+
+	if len(deliveries) > 0 {
+		app.logger.Info("dispatched campaign emails", "sent", sent, "failed", len(deliveries)-sent)
+	}
+
+	return nil
+}
+
+func (app *Application) campaignLink(path, token string) string {
+	return app.publicURL(path) + "?token=" + url.QueryEscape(token)
+}
@@ -0,0 +1,280 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mailer"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+const testCampaignToken = "Zm9vYmFyZm9vYmFyZm9vYmFyZm9vYmFyZm9vYmFyZm9"
+
+type EmailCampaignsTestSuite struct {
+	suite.Suite
+	app               *Application
+	emailCampaignRepo *mocks.MockEmailCampaignRepo
+}
+
+func (s *EmailCampaignsTestSuite) SetupTest() {
+	s.emailCampaignRepo = new(mocks.MockEmailCampaignRepo)
+
+	s.app = newTestApplication(func(a *Application) {
+		a.emailCampaignRepo = s.emailCampaignRepo
+		a.config.BaseURL = "https://api.example.com/"
+		a.config.Campaigns = CampaignsConfig{BatchSize: 100}
+	})
+}
+
+func TestEmailCampaignsSuite(t *testing.T) {
+	suite.Run(t, new(EmailCampaignsTestSuite))
+}
+
+func (s *EmailCampaignsTestSuite) TestCreateEmailCampaign() {
+	tests := []struct {
+		name           string
+		input          api.CreateEmailCampaignRequest
+		setupMocks     func()
+		wantStatus     int
+		wantErrMessage string
+	}{
+		{
+			name:           "should fail without a subject",
+			input:          api.CreateEmailCampaignRequest{MovieId: 1, Body: "Out now"},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: "is required",
+		},
+		{
+			name: "should fail when the radius is given without coordinates",
+			input: api.CreateEmailCampaignRequest{
+				MovieId:  1,
+				Subject:  "Dune is out",
+				Body:     "Out now",
+				RadiusKm: ptr(10.0),
+			},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: "is required when Longitude RadiusKm is provided",
+		},
+		{
+			name:  "should fail when the movie does not exist",
+			input: api.CreateEmailCampaignRequest{MovieId: 99, Subject: "Dune is out", Body: "Out now"},
+			setupMocks: func() {
+				s.emailCampaignRepo.On("Create", mock.Anything, mock.Anything).Return(domain.ErrMovieNotFound)
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: domain.ErrMovieNotFound.Error(),
+		},
+		{
+			name:  "should fail when database error occurs",
+			input: api.CreateEmailCampaignRequest{MovieId: 1, Subject: "Dune is out", Body: "Out now"},
+			setupMocks: func() {
+				s.emailCampaignRepo.On("Create", mock.Anything, mock.Anything).Return(errors.New("database error"))
+			},
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+		{
+			name: "should queue the campaign",
+			input: api.CreateEmailCampaignRequest{
+				MovieId:           1,
+				Subject:           "Dune is out",
+				Body:              "Out now",
+				FavoriteTheaterId: ptr(3),
+				Latitude:          ptr(41.0),
+				Longitude:         ptr(29.0),
+				RadiusKm:          ptr(25.0),
+			},
+			setupMocks: func() {
+				s.emailCampaignRepo.On("Create", mock.Anything, mock.MatchedBy(func(c *domain.EmailCampaign) bool {
+					return c.MovieID == 1 &&
+						*c.Filter.FavoriteTheaterID == 3 &&
+						*c.Filter.RadiusKm == 25 &&
+						c.CreatedBy == 7
+				})).Run(func(args mock.Arguments) {
+					campaign := args.Get(1).(*domain.EmailCampaign)
+					campaign.ID = 5
+					campaign.RecipientCount = 120
+				}).Return(nil)
+			},
+			wantStatus: http.StatusCreated,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			w, r := executeRequest(s.T(), http.MethodPost, "/admin/email-campaigns", tt.input)
+			r = r.WithContext(context.WithValue(r.Context(), SessionKeyUserId, 7))
+
+			s.app.CreateEmailCampaign(w, r)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantStatus == http.StatusCreated {
+				var response api.EmailCampaign
+				s.Require().NoError(json.NewDecoder(w.Body).Decode(&response))
+
+				s.Equal(5, response.Id)
+				s.Equal(120, response.RecipientCount)
+			}
+
+			checkErrorResponse(s.T(), w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+
+			s.emailCampaignRepo.AssertExpectations(s.T())
+		})
+	}
+}
+
+func (s *EmailCampaignsTestSuite) TestGetEmailCampaignStats() {
+	s.emailCampaignRepo.On("GetStats", mock.Anything, 9).Return(nil, domain.ErrRecordNotFound).Once()
+	s.emailCampaignRepo.On("GetStats", mock.Anything, 5).Return(&domain.CampaignStats{
+		CampaignID: 5,
+		Recipients: 10,
+		Pending:    2,
+		Sent:       7,
+		Failed:     1,
+		Opened:     4,
+	}, nil).Once()
+
+	w, r := executeRequest(s.T(), http.MethodGet, "/admin/email-campaigns/9/stats", nil)
+	s.app.GetEmailCampaignStats(w, r, 9)
+	s.Equal(http.StatusNotFound, w.Code)
+
+	w, r = executeRequest(s.T(), http.MethodGet, "/admin/email-campaigns/5/stats", nil)
+	s.app.GetEmailCampaignStats(w, r, 5)
+	s.Equal(http.StatusOK, w.Code)
+
+	var response api.EmailCampaignStats
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&response))
+
+	s.Equal(api.EmailCampaignStats{CampaignId: 5, Recipients: 10, Pending: 2, Sent: 7, Failed: 1, Opened: 4}, response)
+	s.emailCampaignRepo.AssertExpectations(s.T())
+}
+
+func (s *EmailCampaignsTestSuite) TestUnsubscribeFromEmailCampaigns() {
+	hash := sha256.Sum256([]byte(testCampaignToken))
+
+	tests := []struct {
+		name       string
+		token      string
+		setupMocks func()
+		wantStatus int
+	}{
+		{
+			name:       "should fail with a malformed token",
+			token:      "short",
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:  "should fail with an unknown token",
+			token: testCampaignToken,
+			setupMocks: func() {
+				s.emailCampaignRepo.On("Unsubscribe", mock.Anything, hash[:]).Return(domain.ErrRecordNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:  "should unsubscribe the recipient",
+			token: testCampaignToken,
+			setupMocks: func() {
+				s.emailCampaignRepo.On("Unsubscribe", mock.Anything, hash[:]).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			w, r := executeRequest(s.T(), http.MethodGet, "/email-campaigns/unsubscribe", nil)
+			s.app.UnsubscribeFromEmailCampaigns(w, r, api.UnsubscribeFromEmailCampaignsParams{Token: tt.token})
+
+			s.Equal(tt.wantStatus, w.Code)
+			s.emailCampaignRepo.AssertExpectations(s.T())
+		})
+	}
+}
+
+func (s *EmailCampaignsTestSuite) TestTrackEmailCampaignOpen() {
+	s.emailCampaignRepo.On("RecordOpen", mock.Anything, mock.Anything).Return(domain.ErrRecordNotFound).Once()
+	s.emailCampaignRepo.On("RecordOpen", mock.Anything, mock.Anything).Return(errors.New("database error")).Once()
+
+	for range 2 {
+		w, r := executeRequest(s.T(), http.MethodGet, "/email-campaigns/open", nil)
+		s.app.TrackEmailCampaignOpen(w, r, api.TrackEmailCampaignOpenParams{Token: testCampaignToken})
+
+		s.Equal(http.StatusOK, w.Code)
+		s.Equal("image/gif", w.Header().Get("Content-Type"))
+		s.Equal(trackingPixel, w.Body.Bytes())
+	}
+
+	s.emailCampaignRepo.AssertExpectations(s.T())
+}
+
+func (s *EmailCampaignsTestSuite) TestDispatchEmailCampaigns() {
+	release := time.Date(2030, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	s.emailCampaignRepo.On("GetPendingDeliveries", mock.Anything, 100).Return([]domain.CampaignDelivery{
+		{CampaignID: 5, UserID: 1, Email: "freddie@example.com", FirstName: "Freddie", MovieTitle: "Dune", ReleaseDate: release},
+		{CampaignID: 5, UserID: 2, Email: "brian@example.com", FirstName: "Brian", MovieTitle: "Dune", ReleaseDate: release},
+	}, nil)
+
+	var unsubscribeUrl string
+
+	s.app.mailer = &MockMailer{sendFunc: func(recipient, template string, data any) error {
+		s.Equal("campaign_new_release.tmpl", template)
+
+		if recipient == "freddie@example.com" {
+			return errors.New("smtp error")
+		}
+
+		email := data.(mailer.CampaignNewReleaseEmail)
+		unsubscribeUrl = email.UnsubscribeUrl
+		s.Equal("March 1, 2030", email.ReleaseDate)
+
+		return nil
+	}}
+
+	s.emailCampaignRepo.On("MarkDeliveryFailed", mock.Anything, 5, 1, "smtp error").Return(nil)
+	s.emailCampaignRepo.On("MarkDeliverySent", mock.Anything, 5, 2, mock.Anything).Return(nil)
+
+	err := s.app.dispatchEmailCampaigns(context.Background())
+	s.Require().NoError(err)
+
+	s.True(strings.HasPrefix(unsubscribeUrl, "https://api.example.com/email-campaigns/unsubscribe?token="))
+
+	// the link carries the token whose hash is stored for the delivery
+	link, err := url.Parse(unsubscribeUrl)
+	s.Require().NoError(err)
+
+	hash := sha256.Sum256([]byte(link.Query().Get("token")))
+	s.emailCampaignRepo.AssertCalled(s.T(), "MarkDeliverySent", mock.Anything, 5, 2, hash[:])
+	s.emailCampaignRepo.AssertExpectations(s.T())
+}
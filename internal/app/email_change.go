@@ -0,0 +1,137 @@
+package app
+
+import (
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+var (
+	errEmailUnchanged   = errors.New("the new email address must differ from the current one")
+	errEmailUnavailable = errors.New("the email address is used by another account")
+)
+
+// RequestEmailChange keeps the new address with the pending changes of the user and sends the token
+// verifying them to that address, so an address is only taken into use once its owner proves they receive
+// its emails. The current address stays in use meanwhile.
+func (app *Application) RequestEmailChange(w http.ResponseWriter, r *http.Request) {
+	logger := app.contextGetLogger(r)
+
+	var input api.EmailChangeRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	email := strings.ToLower(input.Email)
+	userId := app.contextGetUserId(r)
+
+	user, err := app.userRepo.GetById(r.Context(), userId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	if strings.EqualFold(user.Email, email) {
+		app.badRequestResponse(w, r, errEmailUnchanged)
+		return
+	}
+
+	// a pending marketing opt-in is kept and verified along with the new address
+	var changes domain.PendingChanges
+	if user.PendingChanges != nil {
+		changes = *user.PendingChanges
+	}
+
+	changes.Email = &email
+
+	// requesting another change replaces the pending one and invalidates its token
+	token, err := app.savePendingChanges(r.Context(), userId, changes)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrUserAlreadyExists):
+			logger.Warn("email change rejected: the address is used by another account")
+			app.editConflictResponseWithErr(w, r, errEmailUnavailable)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	// the change stays pending if the email can't be sent, requesting it again sends a new token
+	app.sendPendingChangesEmail(r.Context(), logger, user, changes, token)
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ConfirmEmailChange applies the pending changes of the user, replacing their address with the pending
+// one. The token must belong to the signed in user, a token forwarded to someone else doesn't change
+// their account.
+func (app *Application) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	logger := app.contextGetLogger(r)
+
+	var input api.EmailChangeConfirmationRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	userId := app.contextGetUserId(r)
+	hash := sha256.Sum256([]byte(input.Token))
+
+	err = app.userRepo.ApplyPendingEmailChange(r.Context(), userId, hash[:])
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			logger.Warn("email change confirmation with invalid token")
+			app.notFoundResponse(w, r)
+		case errors.Is(err, domain.ErrUserAlreadyExists):
+			logger.Warn("email change rejected: the address was taken by another account meanwhile")
+			app.editConflictResponseWithErr(w, r, errEmailUnavailable)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	logger.Info("email address of user is changed", "user_id", userId)
+
+	user, err := app.userRepo.GetById(r.Context(), userId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, toApiUserResponse(user), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
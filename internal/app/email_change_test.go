@@ -0,0 +1,242 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mailer"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/metinatakli/movie-reservation-system/internal/validator"
+)
+
+const testEmailChangeToken = "Qw7eR2tY9uI4oP1aS6dF3gH8jK5lZ0xC2vB7nM4qW9e"
+
+func TestRequestEmailChange(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          api.EmailChangeRequest
+		pending        *domain.PendingChanges
+		saveErr        error
+		wantStatus     int
+		wantErrMessage string
+		wantEmail      bool
+		wantMarketing  bool
+	}{
+		{
+			name:           "invalid email",
+			input:          api.EmailChangeRequest{Email: "not-an-email"},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: validator.ErrInvalidEmail,
+		},
+		{
+			name:           "current address",
+			input:          api.EmailChangeRequest{Email: "Freddie@Example.com"},
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: errEmailUnchanged.Error(),
+		},
+		{
+			name:           "address of another account",
+			input:          api.EmailChangeRequest{Email: "brian@example.com"},
+			saveErr:        domain.ErrUserAlreadyExists,
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: errEmailUnavailable.Error(),
+		},
+		{
+			name:       "sends the token to the new address",
+			input:      api.EmailChangeRequest{Email: "Mercury@Example.com"},
+			wantStatus: http.StatusAccepted,
+			wantEmail:  true,
+		},
+		{
+			name:  "keeps a pending opt-in to marketing emails",
+			input: api.EmailChangeRequest{Email: "Mercury@Example.com"},
+			pending: &domain.PendingChanges{
+				MarketingEmails: ptr(true),
+				Email:           ptr("queen@example.com"),
+				Source:          domain.ConsentSourcePreferences,
+			},
+			wantStatus:    http.StatusAccepted,
+			wantEmail:     true,
+			wantMarketing: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var saved domain.PendingChanges
+			var savedToken *domain.Token
+			var emails []sentEmail
+
+			app := newTestApplication(func(a *Application) {
+				a.userRepo = &mocks.MockUserRepo{
+					GetByIdFunc: func(ctx context.Context, id int) (*domain.User, error) {
+						return &domain.User{
+							ID:             id,
+							FirstName:      "Freddie",
+							Email:          "freddie@example.com",
+							PendingChanges: tt.pending,
+						}, nil
+					},
+					SavePendingChangesFunc: func(
+						ctx context.Context,
+						userID int,
+						changes domain.PendingChanges,
+						token *domain.Token) error {
+
+						saved, savedToken = changes, token
+						return tt.saveErr
+					},
+				}
+				a.mailer = &MockMailer{sendFunc: func(recipient, template string, data any) error {
+					emails = append(emails, sentEmail{recipient: recipient, template: template, data: data})
+					return nil
+				}}
+			})
+
+			w, r := executeRequest(t, http.MethodPatch, "/users/me/email", tt.input)
+			r = r.WithContext(context.WithValue(r.Context(), SessionKeyUserId, 1))
+
+			app.RequestEmailChange(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			checkErrorResponse(t, w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+
+			if !tt.wantEmail {
+				if len(emails) != 0 {
+					t.Errorf("unexpected emails %+v", emails)
+				}
+
+				return
+			}
+
+			if saved.Email == nil || *saved.Email != "mercury@example.com" {
+				t.Errorf("pending email = %v, want the lower-cased new address", saved.Email)
+			}
+
+			if (saved.MarketingEmails != nil) != tt.wantMarketing {
+				t.Errorf("pending marketing emails = %v, want kept = %v", saved.MarketingEmails, tt.wantMarketing)
+			}
+
+			if savedToken == nil || savedToken.Scope != domain.PendingChangesScope || savedToken.UserId != 1 {
+				t.Fatalf("unexpected token %+v", savedToken)
+			}
+
+			if len(emails) != 1 || emails[0].recipient != "mercury@example.com" {
+				t.Fatalf("emails = %+v, want one to the new address", emails)
+			}
+
+			data, ok := emails[0].data.(mailer.EmailChangeEmail)
+			if !ok || data.ConfirmationToken != savedToken.Plaintext || data.TTLMinutes != 30 ||
+				data.MarketingEmails != tt.wantMarketing {
+				t.Errorf("unexpected email data %+v", emails[0].data)
+			}
+		})
+	}
+}
+
+func TestConfirmEmailChange(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          api.EmailChangeConfirmationRequest
+		applyErr       error
+		wantStatus     int
+		wantErrMessage string
+	}{
+		{
+			name:           "malformed token",
+			input:          api.EmailChangeConfirmationRequest{Token: "short"},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: validator.ErrDefaultInvalid,
+		},
+		{
+			name:           "unknown, expired or foreign token",
+			input:          api.EmailChangeConfirmationRequest{Token: testEmailChangeToken},
+			applyErr:       domain.ErrRecordNotFound,
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+		},
+		{
+			name:           "address taken meanwhile",
+			input:          api.EmailChangeConfirmationRequest{Token: testEmailChangeToken},
+			applyErr:       domain.ErrUserAlreadyExists,
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: errEmailUnavailable.Error(),
+		},
+		{
+			name:       "changes the address",
+			input:      api.EmailChangeConfirmationRequest{Token: testEmailChangeToken},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var appliedHash []byte
+
+			app := newTestApplication(func(a *Application) {
+				a.userRepo = &mocks.MockUserRepo{
+					ApplyPendingEmailChangeFunc: func(ctx context.Context, userID int, tokenHash []byte) error {
+						if userID != 1 {
+							t.Errorf("token applied for user %d, want the signed in user", userID)
+						}
+
+						appliedHash = tokenHash
+						return tt.applyErr
+					},
+					GetByIdFunc: func(ctx context.Context, id int) (*domain.User, error) {
+						return &domain.User{ID: id, FirstName: "Freddie", Email: "mercury@example.com", Gender: domain.Male}, nil
+					},
+				}
+			})
+
+			w, r := executeRequest(t, http.MethodPut, "/users/me/email/confirm", tt.input)
+			r = r.WithContext(context.WithValue(r.Context(), SessionKeyUserId, 1))
+
+			app.ConfirmEmailChange(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			checkErrorResponse(t, w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			hash := sha256.Sum256([]byte(testEmailChangeToken))
+			if string(appliedHash) != string(hash[:]) {
+				t.Error("the hash of the token wasn't applied")
+			}
+
+			var resp api.UserResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.Email != "mercury@example.com" || resp.PendingEmail != nil {
+				t.Errorf("unexpected user %+v", resp)
+			}
+		})
+	}
+}
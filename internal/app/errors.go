@@ -1,13 +1,17 @@
 package app
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-playground/validator/v10"
 	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
 	appvalidator "github.com/metinatakli/movie-reservation-system/internal/validator"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -17,6 +21,11 @@ const (
 	ErrInvalidCredentials = "Invalid email or password"
 	ErrUnauthorizedAccess = "You must be authenticated to access this resource"
 	ErrForbiddenAccess    = "You do not have permission to perform this action"
+	ErrReauthRequired     = "Please confirm your password to perform this action"
+	ErrRateLimitExceeded  = "Too many requests, please try again later"
+	ErrInvalidMagicLink   = "The login link is invalid or has expired"
+
+	ErrInvalidPasswordResetToken = "The password reset token is invalid or has expired"
 )
 
 func (app *Application) logError(r *http.Request, err error) {
@@ -29,15 +38,123 @@ func (app *Application) logClientError(r *http.Request, message string) {
 	logger.Warn(message, "method", r.Method, "uri", r.URL.RequestURI())
 }
 
+// errorCodes is the registry of the error codes of domain errors, documented with the ErrorCode schema of
+// the API. Errors are matched with errors.Is in order, errors without a code get the code of their status.
+var errorCodes = []struct {
+	err  error
+	code api.ErrorCode
+}{
+	{errSeatsAlreadyReserved, api.SEATALREADYRESERVED},
+	{errSeatsAlreadyLocked, api.SEATALREADYLOCKED},
+	{errSeatsBlocked, api.SEATBLOCKED},
+	{domain.ErrSeatAlreadyReserved, api.SEATALREADYLOCKED},
+	{domain.ErrSeatConflict, api.SEATCONFLICT},
+	{errNoCartInSession, api.CARTNOTFOUND},
+	{errCartAlreadyExists, api.CARTALREADYEXISTS},
+	{domain.ErrCartNotFound, api.CARTEXPIRED},
+	{domain.ErrSeatLockExpired, api.CARTEXPIRED},
+	{errCheckoutHoldExhausted, api.CHECKOUTHOLDEXHAUSTED},
+	{errSeatHoldNotFound, api.HOLDNOTFOUND},
+	{errHoldReferenceConflict, api.HOLDREFERENCECONFLICT},
+	{errTooManySeatHolds, api.TOOMANYHOLDS},
+	{domain.ErrBlockHoldNotActive, api.BLOCKHOLDNOTACTIVE},
+	{domain.ErrCartAlreadyPaid, api.CARTALREADYPAID},
+	{domain.ErrCartPricesMissing, api.CARTPRICESMISSING},
+	{errPaymentNotPending, api.PAYMENTNOTPENDING},
+	{errInvalidCheckoutMetadata, api.INVALIDCHECKOUTMETADATA},
+	{errTicketsRevoked, api.TICKETSREVOKED},
+	{errTicketNotFlexible, api.TICKETNOTFLEXIBLE},
+	{errSeatsNotCancellable, api.SEATSNOTCANCELLABLE},
+	{errChangeWindowClosed, api.CHANGEWINDOWCLOSED},
+	{domain.ErrCheckInNotOpen, api.CHECKINNOTOPEN},
+	{domain.ErrCheckInClosed, api.CHECKINCLOSED},
+	{domain.ErrAlreadyCheckedIn, api.ALREADYCHECKEDIN},
+	{domain.ErrSalesNotOpen, api.SALESNOTOPEN},
+	{domain.ErrSalesClosed, api.SALESCLOSED},
+	{domain.ErrWalletPassUnavailable, api.WALLETPASSUNAVAILABLE},
+	{errNoDefaultLocation, api.LOCATIONREQUIRED},
+	{domain.ErrAddressNotFound, api.ADDRESSNOTFOUND},
+	{errGeocodingUnavailable, api.GEOCODINGUNAVAILABLE},
+	{domain.ErrTheaterNotFound, api.THEATERNOTFOUND},
+	{domain.ErrHallNotFound, api.HALLNOTFOUND},
+	{domain.ErrAnnouncementNotCancellable, api.ANNOUNCEMENTNOTCANCELLABLE},
+	{errSessionStoreUnavailable, api.SESSIONSTOREUNAVAILABLE},
+}
+
+// errorCode returns the code registered for the error, or fallback if there is none.
+func errorCode(err error, fallback api.ErrorCode) api.ErrorCode {
+	for _, c := range errorCodes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+
+	return fallback
+}
+
+func statusErrorCode(status int) api.ErrorCode {
+	switch status {
+	case http.StatusBadRequest:
+		return api.BADREQUEST
+	case http.StatusUnauthorized:
+		return api.UNAUTHORIZED
+	case http.StatusForbidden:
+		return api.FORBIDDEN
+	case http.StatusNotFound:
+		return api.NOTFOUND
+	case http.StatusConflict:
+		return api.EDITCONFLICT
+	case http.StatusUnprocessableEntity:
+		return api.VALIDATIONFAILED
+	case http.StatusTooManyRequests:
+		return api.RATELIMITED
+	case http.StatusNotImplemented:
+		return api.NOTIMPLEMENTED
+	default:
+		return api.INTERNALERROR
+	}
+}
+
 // The errorResponse() method is a generic helper for sending JSON-formatted error
 // messages to the client with a given status code.
 func (app *Application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message string) {
-	resp := api.ErrorResponse{
+	app.errorResponseWithCode(w, r, status, statusErrorCode(status), message)
+}
+
+// errorResponseWithErr sends the message of the error, with the code registered for it. The details
+// some errors carry for the client to recover, like the seats a cart lost, are sent along.
+func (app *Application) errorResponseWithErr(w http.ResponseWriter, r *http.Request, status int, err error) {
+	resp := newErrorResponse(r, errorCode(err, statusErrorCode(status)), err.Error())
+
+	var seatLocks *domain.SeatLocksError
+	if errors.As(err, &seatLocks) {
+		resp.SeatLocks = toApiSeatLocks(seatLocks)
+	}
+
+	app.writeErrorResponse(w, r, status, resp)
+}
+
+func (app *Application) errorResponseWithCode(
+	w http.ResponseWriter,
+	r *http.Request,
+	status int,
+	code api.ErrorCode,
+	message string) {
+
+	app.writeErrorResponse(w, r, status, newErrorResponse(r, code, message))
+}
+
+func newErrorResponse(r *http.Request, code api.ErrorCode, message string) api.ErrorResponse {
+	return api.ErrorResponse{
+		Code:      code,
 		Message:   message,
 		RequestId: middleware.GetReqID(r.Context()),
+		TraceId:   traceID(r),
 		Timestamp: time.Now(),
 	}
+}
 
+func (app *Application) writeErrorResponse(w http.ResponseWriter, r *http.Request, status int, resp api.ErrorResponse) {
 	err := app.writeJSON(w, status, resp, nil)
 	if err != nil {
 		app.logError(r, err)
@@ -45,6 +162,34 @@ func (app *Application) errorResponse(w http.ResponseWriter, r *http.Request, st
 	}
 }
 
+// toApiSeatLocks suggests choosing other seats once a seat is locked by someone else, the expired seats
+// may still be free and can be selected again otherwise.
+func toApiSeatLocks(err *domain.SeatLocksError) *api.SeatLocksConflict {
+	action := api.SELECTSEATSAGAIN
+	if len(err.ConflictingSeatIDs) > 0 {
+		action = api.CHOOSEOTHERSEATS
+	}
+
+	return &api.SeatLocksConflict{
+		ExpiredSeatIds:     append([]int{}, err.ExpiredSeatIDs...),
+		ConflictingSeatIds: append([]int{}, err.ConflictingSeatIDs...),
+		HeldSeatIds:        append([]int{}, err.HeldSeatIDs...),
+		SuggestedAction:    action,
+	}
+}
+
+// traceID returns the id of the trace of the request, or nil when the request isn't traced.
+func traceID(r *http.Request) *string {
+	spanContext := trace.SpanContextFromContext(r.Context())
+	if !spanContext.HasTraceID() {
+		return nil
+	}
+
+	id := spanContext.TraceID().String()
+
+	return &id
+}
+
 func (app *Application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
 	app.logError(r, err)
 	app.errorResponse(w, r, http.StatusInternalServerError, ErrInternalServer)
@@ -57,12 +202,12 @@ func (app *Application) notFoundResponse(w http.ResponseWriter, r *http.Request)
 
 func (app *Application) notFoundResponseWithErr(w http.ResponseWriter, r *http.Request, err error) {
 	app.logClientError(r, err.Error())
-	app.errorResponse(w, r, http.StatusNotFound, err.Error())
+	app.errorResponseWithErr(w, r, http.StatusNotFound, err)
 }
 
 func (app *Application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
 	app.logClientError(r, err.Error())
-	app.errorResponse(w, r, http.StatusBadRequest, err.Error())
+	app.errorResponseWithErr(w, r, http.StatusBadRequest, err)
 }
 
 func (app *Application) failedValidationResponse(w http.ResponseWriter, r *http.Request, err error) {
@@ -71,15 +216,22 @@ func (app *Application) failedValidationResponse(w http.ResponseWriter, r *http.
 	var validationErrs []api.ValidationError
 
 	for _, err := range err.(validator.ValidationErrors) {
+		issue := appvalidator.ValidationMessage(err)
+		if err.Tag() == "password" {
+			issue = app.config.PasswordPolicy.Message()
+		}
+
 		validationErrs = append(validationErrs, api.ValidationError{
 			Field: err.StructField(),
-			Issue: appvalidator.ValidationMessage(err),
+			Issue: issue,
 		})
 	}
 
 	resp := api.ValidationErrorResponse{
+		Code:             api.VALIDATIONFAILED,
 		Message:          "One or more fields have invalid values",
 		RequestId:        middleware.GetReqID(r.Context()),
+		TraceId:          traceID(r),
 		Timestamp:        time.Now(),
 		ValidationErrors: validationErrs,
 	}
@@ -98,11 +250,11 @@ func (app *Application) editConflictResponse(w http.ResponseWriter, r *http.Requ
 
 func (app *Application) editConflictResponseWithErr(w http.ResponseWriter, r *http.Request, err error) {
 	app.logClientError(r, err.Error())
-	app.errorResponse(w, r, http.StatusConflict, err.Error())
+	app.errorResponseWithErr(w, r, http.StatusConflict, err)
 }
 
 func (app *Application) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
-	app.errorResponse(w, r, http.StatusUnauthorized, ErrInvalidCredentials)
+	app.errorResponseWithCode(w, r, http.StatusUnauthorized, api.INVALIDCREDENTIALS, ErrInvalidCredentials)
 }
 
 func (app *Application) unauthorizedAccessResponse(w http.ResponseWriter, r *http.Request) {
@@ -110,6 +262,17 @@ func (app *Application) unauthorizedAccessResponse(w http.ResponseWriter, r *htt
 	app.errorResponse(w, r, http.StatusUnauthorized, ErrUnauthorizedAccess)
 }
 
+func (app *Application) reauthenticationRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	app.logClientError(r, ErrReauthRequired)
+	app.errorResponseWithCode(w, r, http.StatusUnauthorized, api.REAUTHREQUIRED, ErrReauthRequired)
+}
+
+func (app *Application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	app.logClientError(r, ErrRateLimitExceeded)
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+	app.errorResponse(w, r, http.StatusTooManyRequests, ErrRateLimitExceeded)
+}
+
 func (app *Application) forbiddenResponse(w http.ResponseWriter, r *http.Request) {
 	app.logClientError(r, ErrForbiddenAccess)
 	app.errorResponse(w, r, http.StatusForbidden, ErrForbiddenAccess)
@@ -0,0 +1,197 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		fallback api.ErrorCode
+		want     api.ErrorCode
+	}{
+		{
+			name:     "registered error",
+			err:      domain.ErrSeatConflict,
+			fallback: api.EDITCONFLICT,
+			want:     api.SEATCONFLICT,
+		},
+		{
+			name:     "wrapped registered error",
+			err:      fmt.Errorf("cart %s: %w", "cart-1", domain.ErrCartNotFound),
+			fallback: api.NOTFOUND,
+			want:     api.CARTEXPIRED,
+		},
+		{
+			name:     "seats of a cart locked by another session",
+			err:      &domain.SeatLocksError{ExpiredSeatIDs: []int{1}, ConflictingSeatIDs: []int{2}},
+			fallback: api.EDITCONFLICT,
+			want:     api.SEATCONFLICT,
+		},
+		{
+			name:     "expired seats of a cart",
+			err:      &domain.SeatLocksError{ExpiredSeatIDs: []int{1}, HeldSeatIDs: []int{2}},
+			fallback: api.EDITCONFLICT,
+			want:     api.CARTEXPIRED,
+		},
+		{
+			name:     "seat lock race of the repository",
+			err:      domain.ErrSeatAlreadyReserved,
+			fallback: api.EDITCONFLICT,
+			want:     api.SEATALREADYLOCKED,
+		},
+		{
+			name:     "unregistered error falls back",
+			err:      domain.ErrRecordNotFound,
+			fallback: api.NOTFOUND,
+			want:     api.NOTFOUND,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorCode(tt.err, tt.fallback); got != tt.want {
+				t.Errorf("errorCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorResponseCodes(t *testing.T) {
+	app := newTestApplication()
+
+	tests := []struct {
+		name        string
+		respond     func(w http.ResponseWriter, r *http.Request)
+		wantStatus  int
+		wantCode    api.ErrorCode
+		wantMessage string
+	}{
+		{
+			name: "status code without a registered error",
+			respond: func(w http.ResponseWriter, r *http.Request) {
+				app.errorResponse(w, r, http.StatusTooManyRequests, "slow down")
+			},
+			wantStatus:  http.StatusTooManyRequests,
+			wantCode:    api.RATELIMITED,
+			wantMessage: "slow down",
+		},
+		{
+			name: "registered error",
+			respond: func(w http.ResponseWriter, r *http.Request) {
+				app.editConflictResponseWithErr(w, r, domain.ErrSeatLockExpired)
+			},
+			wantStatus:  http.StatusConflict,
+			wantCode:    api.CARTEXPIRED,
+			wantMessage: domain.ErrSeatLockExpired.Error(),
+		},
+		{
+			name: "unregistered error",
+			respond: func(w http.ResponseWriter, r *http.Request) {
+				app.notFoundResponseWithErr(w, r, errors.New("webhook event evt_1 was not received"))
+			},
+			wantStatus:  http.StatusNotFound,
+			wantCode:    api.NOTFOUND,
+			wantMessage: "webhook event evt_1 was not received",
+		},
+		{
+			name: "server error",
+			respond: func(w http.ResponseWriter, r *http.Request) {
+				app.serverErrorResponse(w, r, errors.New("connection refused"))
+			},
+			wantStatus:  http.StatusInternalServerError,
+			wantCode:    api.INTERNALERROR,
+			wantMessage: ErrInternalServer,
+		},
+		{
+			name: "invalid credentials",
+			respond: func(w http.ResponseWriter, r *http.Request) {
+				app.invalidCredentialsResponse(w, r)
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   api.INVALIDCREDENTIALS,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, r := executeRequest(t, http.MethodGet, "/", nil)
+
+			tt.respond(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			var resp api.ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode error response: %v", err)
+			}
+
+			if resp.Code != tt.wantCode {
+				t.Errorf("Error code = %v, want %v", resp.Code, tt.wantCode)
+			}
+
+			if tt.wantMessage != "" && resp.Message != tt.wantMessage {
+				t.Errorf("Error message = %v, want %v", resp.Message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestErrorResponseTraceID(t *testing.T) {
+	app := newTestApplication()
+
+	traceId := trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36}
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceId,
+		SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	tests := []struct {
+		name        string
+		traced      bool
+		wantTraceId *string
+	}{
+		{
+			name:        "traced request",
+			traced:      true,
+			wantTraceId: ptr("4bf92f3577b34da6a3ce929d0e0e4736"),
+		},
+		{
+			name:   "untraced request",
+			traced: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, r := executeRequest(t, http.MethodGet, "/", nil)
+			if tt.traced {
+				r = r.WithContext(trace.ContextWithSpanContext(r.Context(), spanContext))
+			}
+
+			app.notFoundResponse(w, r)
+
+			var resp api.ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode error response: %v", err)
+			}
+
+			if !reflect.DeepEqual(resp.TraceId, tt.wantTraceId) {
+				t.Errorf("TraceId = %v, want %v", resp.TraceId, tt.wantTraceId)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+// client clocks can't be trusted, events claiming a time outside of this window are recorded
+// at the time they're received
+const maxEventAge = 24 * time.Hour
+
+func (app *Application) RecordEvents(w http.ResponseWriter, r *http.Request) {
+	logger := app.contextGetLogger(r)
+
+	var input api.RecordEventsRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	// a session is created with the first response, events sent before that can't be grouped
+	sessionId := app.sessionManager.Token(r.Context())
+	if sessionId == "" || !sessionSampled(sessionId, app.config.Analytics.SampleRate) {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	sessionHash := sha256.Sum256([]byte(sessionId))
+	now := time.Now()
+	dropped := 0
+
+	for _, e := range input.Events {
+		occurredAt := now
+		if e.OccurredAt != nil && e.OccurredAt.Before(now) && now.Sub(*e.OccurredAt) <= maxEventAge {
+			occurredAt = *e.OccurredAt
+		}
+
+		event := domain.AnalyticsEvent{
+			Name:        domain.EventName(e.Name),
+			SessionHash: sessionHash[:],
+			ShowtimeID:  e.ShowtimeId,
+			OccurredAt:  occurredAt,
+		}
+
+		if !app.analytics.Add(event) {
+			dropped++
+		}
+	}
+
+	if dropped > 0 {
+		logger.Warn("analytics buffer is full, events are dropped", "count", dropped)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// sessionSampled decides whether the events of a session are kept. The decision only depends on the
+// session, so the events of a sampled session are kept together.
+func sessionSampled(sessionId string, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+
+	if rate <= 0 {
+		return false
+	}
+
+	hash := sha256.Sum256([]byte("analytics:" + sessionId))
+
+	return binary.BigEndian.Uint64(hash[:8]) < uint64(rate*math.MaxUint64)
+}
@@ -0,0 +1,158 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/metinatakli/movie-reservation-system/internal/analytics"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/metinatakli/movie-reservation-system/internal/validator"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRecordEvents(t *testing.T) {
+	now := time.Now()
+	anHourAgo := now.Add(-time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name           string
+		input          map[string]any
+		sampleRate     float64
+		wantStatus     int
+		wantErrMessage string
+		wantEvents     []domain.AnalyticsEvent
+	}{
+		{
+			name:           "event not allowed",
+			input:          map[string]any{"events": []map[string]any{{"name": "page_viewed"}}},
+			sampleRate:     1,
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: fmt.Sprintf(validator.ErrOneOf, "seat_map_viewed cart_abandoned"),
+		},
+		{
+			name:           "no events",
+			input:          map[string]any{"events": []map[string]any{}},
+			sampleRate:     1,
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: fmt.Sprintf(validator.ErrArrayMinLength, "1"),
+		},
+		{
+			name: "events are recorded",
+			input: map[string]any{"events": []map[string]any{
+				{"name": "seat_map_viewed", "showtimeId": 3, "occurredAt": anHourAgo},
+				{"name": "cart_abandoned", "showtimeId": 3},
+			}},
+			sampleRate: 1,
+			wantStatus: http.StatusAccepted,
+			wantEvents: []domain.AnalyticsEvent{
+				{Name: domain.EventSeatMapViewed, ShowtimeID: ptr(3), OccurredAt: anHourAgo},
+				{Name: domain.EventCartAbandoned, ShowtimeID: ptr(3)},
+			},
+		},
+		{
+			name: "time in the future is replaced",
+			input: map[string]any{"events": []map[string]any{
+				{"name": "seat_map_viewed", "occurredAt": now.Add(time.Hour)},
+			}},
+			sampleRate: 1,
+			wantStatus: http.StatusAccepted,
+			wantEvents: []domain.AnalyticsEvent{
+				{Name: domain.EventSeatMapViewed},
+			},
+		},
+		{
+			name:       "session not sampled",
+			input:      map[string]any{"events": []map[string]any{{"name": "seat_map_viewed"}}},
+			sampleRate: 0,
+			wantStatus: http.StatusAccepted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(mocks.MockAnalyticsEventRepo)
+			buffer := analytics.NewBuffer(repo, slog.New(slog.NewTextHandler(io.Discard, nil)), 10, 10, time.Hour)
+
+			app := newTestApplication(func(a *Application) {
+				a.sessionManager = scs.New()
+				a.analytics = buffer
+				a.config.Analytics.SampleRate = tt.sampleRate
+			})
+
+			w, r := executeRequest(t, http.MethodPost, "/events", tt.input)
+			r = setupTestSession(t, app, r, 0)
+			sessionHash := sha256.Sum256([]byte(app.sessionManager.Token(r.Context())))
+
+			if tt.wantEvents != nil {
+				repo.On("CreateBatch", mock.Anything, mock.MatchedBy(func(events []domain.AnalyticsEvent) bool {
+					if len(events) != len(tt.wantEvents) {
+						return false
+					}
+
+					for i, e := range events {
+						want := tt.wantEvents[i]
+						if e.Name != want.Name || string(e.SessionHash) != string(sessionHash[:]) {
+							return false
+						}
+
+						if (want.ShowtimeID == nil) != (e.ShowtimeID == nil) ||
+							(want.ShowtimeID != nil && *want.ShowtimeID != *e.ShowtimeID) {
+							return false
+						}
+
+						// events without a valid time are recorded at the time they're received
+						if want.OccurredAt.IsZero() && e.OccurredAt.Before(now) ||
+							!want.OccurredAt.IsZero() && !e.OccurredAt.Equal(want.OccurredAt) {
+							return false
+						}
+					}
+
+					return true
+				})).Return(nil).Once()
+			}
+
+			app.RecordEvents(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+
+			checkErrorResponse(t, w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{tt.wantStatus, tt.wantErrMessage})
+
+			// a stopped buffer writes the queued events right away
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			buffer.Run(ctx)
+
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSessionSampled(t *testing.T) {
+	sampled := 0
+	for i := range 1000 {
+		if sessionSampled(fmt.Sprintf("session-%d", i), 0.25) {
+			sampled++
+		}
+	}
+
+	if sampled < 200 || sampled > 300 {
+		t.Errorf("expected about a quarter of the sessions to be sampled, got %d of 1000", sampled)
+	}
+
+	if sessionSampled("session", 0.25) != sessionSampled("session", 0.25) {
+		t.Error("expected the decision to be stable for a session")
+	}
+}
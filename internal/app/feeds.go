@@ -0,0 +1,238 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	sitemapFeedName = "sitemap"
+	sitemapCacheTTL = time.Hour
+	// the sitemaps.org protocol allows at most 50,000 URLs per sitemap, one is taken by the catalog
+	sitemapMaxMovies = 49_999
+
+	showtimesFeedName     = "showtimes"
+	showtimesFeedCacheTTL = 10 * time.Minute
+	showtimesFeedWindow   = 14 * 24 * time.Hour
+)
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type screeningEventFeed struct {
+	Context string           `json:"@context"`
+	Graph   []screeningEvent `json:"@graph"`
+}
+
+type screeningEvent struct {
+	Type          string       `json:"@type"`
+	ID            string       `json:"@id"`
+	Name          string       `json:"name"`
+	URL           string       `json:"url"`
+	StartDate     string       `json:"startDate"`
+	EndDate       string       `json:"endDate"`
+	VideoFormat   string       `json:"videoFormat"`
+	WorkPresented movieWork    `json:"workPresented"`
+	Location      movieTheater `json:"location"`
+	Offers        eventOffer   `json:"offers"`
+}
+
+type movieWork struct {
+	Type     string `json:"@type"`
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Image    string `json:"image,omitempty"`
+	Duration string `json:"duration"`
+}
+
+type movieTheater struct {
+	Type    string        `json:"@type"`
+	Name    string        `json:"name"`
+	Address postalAddress `json:"address"`
+}
+
+type postalAddress struct {
+	Type            string `json:"@type"`
+	StreetAddress   string `json:"streetAddress"`
+	AddressLocality string `json:"addressLocality"`
+	AddressRegion   string `json:"addressRegion"`
+}
+
+type eventOffer struct {
+	Type          string `json:"@type"`
+	URL           string `json:"url"`
+	Price         string `json:"price"`
+	PriceCurrency string `json:"priceCurrency"`
+}
+
+func (app *Application) GetSitemap(w http.ResponseWriter, r *http.Request) {
+	sitemap, err := app.cachedFeed(r.Context(), sitemapFeedName, sitemapCacheTTL, app.renderSitemap)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(sitemapCacheTTL.Seconds())))
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(sitemap))
+}
+
+func (app *Application) GetShowtimesFeed(w http.ResponseWriter, r *http.Request) {
+	feed, err := app.cachedFeed(r.Context(), showtimesFeedName, showtimesFeedCacheTTL, app.renderShowtimesFeed)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/ld+json; charset=utf-8")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(showtimesFeedCacheTTL.Seconds())))
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(feed))
+}
+
+// cachedFeed returns the cached document, or renders and caches it. The cache is only an optimization,
+// the document is served even if it can't be read from or written to the cache. Every tenant has its
+// own copy, since the catalog differs between tenants.
+func (app *Application) cachedFeed(
+	ctx context.Context,
+	name string,
+	ttl time.Duration,
+	render func(context.Context) (string, error)) (string, error) {
+
+	key := feedCacheKey(name, domain.TenantIDFromContext(ctx))
+
+	cached, err := app.redis.Get(ctx, key).Result()
+	if err == nil {
+		return cached, nil
+	}
+
+	if !errors.Is(err, redis.Nil) {
+		app.logger.Warn("failed to read feed from cache", "key", key, "error", err)
+	}
+
+	document, err := render(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	err = app.redis.Set(ctx, key, document, ttl).Err()
+	if err != nil {
+		app.logger.Warn("failed to cache feed", "key", key, "error", err)
+	}
+
+	return document, nil
+}
+
+func (app *Application) renderSitemap(ctx context.Context) (string, error) {
+	movies, err := app.movieRepo.GetSitemapMovies(ctx, sitemapMaxMovies)
+	if err != nil {
+		return "", err
+	}
+
+	urlSet := sitemapURLSet{
+		Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9",
+		URLs:  make([]sitemapURL, 0, len(movies)+1),
+	}
+
+	urlSet.URLs = append(urlSet.URLs, sitemapURL{Loc: app.publicURL("/movies")})
+
+	for _, movie := range movies {
+		urlSet.URLs = append(urlSet.URLs, sitemapURL{
+			Loc:     app.publicURL(fmt.Sprintf("/movies/%d", movie.ID)),
+			LastMod: movie.LastModified.UTC().Format(time.DateOnly),
+		})
+	}
+
+	out, err := xml.Marshal(urlSet)
+	if err != nil {
+		return "", err
+	}
+
+	return xml.Header + string(out), nil
+}
+
+func (app *Application) renderShowtimesFeed(ctx context.Context) (string, error) {
+	now := time.Now().UTC()
+
+	screenings, err := app.theaterRepo.GetUpcomingScreenings(ctx, now, now.Add(showtimesFeedWindow))
+	if err != nil {
+		return "", err
+	}
+
+	feed := screeningEventFeed{
+		Context: "https://schema.org",
+		Graph:   make([]screeningEvent, len(screenings)),
+	}
+
+	for i, s := range screenings {
+		showtimeURL := app.publicURL(fmt.Sprintf("/showtimes/%d/seat-map", s.ShowtimeID))
+		duration := time.Duration(s.MovieDuration) * time.Minute
+		startTime := s.StartTime.In(domain.TheaterLocation(s.TheaterTimeZone))
+
+		feed.Graph[i] = screeningEvent{
+			Type:        "ScreeningEvent",
+			ID:          showtimeURL,
+			Name:        fmt.Sprintf("%s at %s", s.MovieTitle, s.TheaterName),
+			URL:         showtimeURL,
+			StartDate:   startTime.Format(time.RFC3339),
+			EndDate:     startTime.Add(duration).Format(time.RFC3339),
+			VideoFormat: string(s.Format),
+			WorkPresented: movieWork{
+				Type:     "Movie",
+				Name:     s.MovieTitle,
+				URL:      app.publicURL(fmt.Sprintf("/movies/%d", s.MovieID)),
+				Image:    s.MoviePosterUrl,
+				Duration: fmt.Sprintf("PT%dM", s.MovieDuration),
+			},
+			Location: movieTheater{
+				Type: "MovieTheater",
+				Name: s.TheaterName,
+				Address: postalAddress{
+					Type:            "PostalAddress",
+					StreetAddress:   s.TheaterAddress,
+					AddressLocality: s.TheaterDistrict,
+					AddressRegion:   s.TheaterCity,
+				},
+			},
+			Offers: eventOffer{
+				Type:          "Offer",
+				URL:           showtimeURL,
+				Price:         s.Price.StringFixed(2),
+				PriceCurrency: domain.DefaultCurrency,
+			},
+		}
+	}
+
+	out, err := json.Marshal(feed)
+	if err != nil {
+		return "", err
+	}
+
+	return string(out), nil
+}
+
+func feedCacheKey(name string, tenantID int) string {
+	return fmt.Sprintf("feeds:%s:%d", name, tenantID)
+}
+
+func (app *Application) publicURL(path string) string {
+	return strings.TrimRight(app.config.BaseURL, "/") + path
+}
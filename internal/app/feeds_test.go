@@ -0,0 +1,191 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type FeedsTestSuite struct {
+	suite.Suite
+	app         *Application
+	movieRepo   *mocks.MockMovieRepo
+	theaterRepo *mocks.MockTheaterRepo
+	redisClient *mocks.MockRedisClient
+}
+
+func (s *FeedsTestSuite) SetupTest() {
+	s.movieRepo = &mocks.MockMovieRepo{}
+	s.theaterRepo = &mocks.MockTheaterRepo{}
+	s.redisClient = new(mocks.MockRedisClient)
+
+	s.app = newTestApplication(func(a *Application) {
+		a.movieRepo = s.movieRepo
+		a.theaterRepo = s.theaterRepo
+		a.redis = s.redisClient
+		a.config.BaseURL = "https://api.example.com/"
+	})
+}
+
+func TestFeedsSuite(t *testing.T) {
+	suite.Run(t, new(FeedsTestSuite))
+}
+
+func (s *FeedsTestSuite) TestGetSitemap() {
+	s.Run("should serve the cached sitemap", func() {
+		s.SetupTest()
+
+		s.redisClient.On("Get", mock.Anything, "feeds:sitemap:0").Return(redis.NewStringResult("<urlset/>", nil))
+
+		w, r := executeRequest(s.T(), http.MethodGet, "/sitemap.xml", nil)
+		s.app.GetSitemap(w, r)
+
+		s.Equal(http.StatusOK, w.Code)
+		s.Equal("<urlset/>", w.Body.String())
+		s.Equal("public, max-age=3600", w.Header().Get("Cache-Control"))
+	})
+
+	s.Run("should render and cache the sitemap on a cache miss", func() {
+		s.SetupTest()
+
+		s.movieRepo.GetSitemapMoviesFunc = func(ctx context.Context, limit int) ([]domain.SitemapMovie, error) {
+			s.Equal(sitemapMaxMovies, limit)
+			return []domain.SitemapMovie{
+				{ID: 2, LastModified: time.Date(2025, time.May, 3, 22, 0, 0, 0, time.UTC)},
+				{ID: 1, LastModified: time.Date(2025, time.January, 9, 8, 0, 0, 0, time.UTC)},
+			}, nil
+		}
+
+		s.redisClient.On("Get", mock.Anything, "feeds:sitemap:0").Return(redis.NewStringResult("", redis.Nil))
+		s.redisClient.On("Set", mock.Anything, "feeds:sitemap:0", mock.Anything, sitemapCacheTTL).
+			Return(redis.NewStatusResult("", errors.New("redis down")))
+
+		w, r := executeRequest(s.T(), http.MethodGet, "/sitemap.xml", nil)
+		s.app.GetSitemap(w, r)
+
+		s.Equal(http.StatusOK, w.Code)
+		s.Equal("application/xml; charset=utf-8", w.Header().Get("Content-Type"))
+
+		var urlSet sitemapURLSet
+		s.Require().NoError(xml.Unmarshal(w.Body.Bytes(), &urlSet))
+
+		s.Equal("http://www.sitemaps.org/schemas/sitemap/0.9", urlSet.Xmlns)
+		s.Equal([]sitemapURL{
+			{Loc: "https://api.example.com/movies"},
+			{Loc: "https://api.example.com/movies/2", LastMod: "2025-05-03"},
+			{Loc: "https://api.example.com/movies/1", LastMod: "2025-01-09"},
+		}, urlSet.URLs)
+
+		s.redisClient.AssertExpectations(s.T())
+	})
+
+	s.Run("should fail when database error occurs", func() {
+		s.SetupTest()
+
+		s.movieRepo.GetSitemapMoviesFunc = func(ctx context.Context, limit int) ([]domain.SitemapMovie, error) {
+			return nil, errors.New("database error")
+		}
+
+		s.redisClient.On("Get", mock.Anything, "feeds:sitemap:0").Return(redis.NewStringResult("", redis.Nil))
+
+		w, r := executeRequest(s.T(), http.MethodGet, "/sitemap.xml", nil)
+		s.app.GetSitemap(w, r)
+
+		s.Equal(http.StatusInternalServerError, w.Code)
+		s.redisClient.AssertNotCalled(s.T(), "Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func (s *FeedsTestSuite) TestGetShowtimesFeed() {
+	s.Run("should serve the cached feed", func() {
+		s.SetupTest()
+
+		s.redisClient.On("Get", mock.Anything, "feeds:showtimes:0").Return(redis.NewStringResult(`{"@graph":[]}`, nil))
+
+		w, r := executeRequest(s.T(), http.MethodGet, "/feeds/showtimes.json", nil)
+		s.app.GetShowtimesFeed(w, r)
+
+		s.Equal(http.StatusOK, w.Code)
+		s.Equal(`{"@graph":[]}`, w.Body.String())
+		s.Equal("public, max-age=600", w.Header().Get("Cache-Control"))
+	})
+
+	s.Run("should render and cache the feed on a cache miss", func() {
+		s.SetupTest()
+
+		startTime := time.Date(2030, time.March, 1, 19, 30, 0, 0, time.UTC)
+
+		s.theaterRepo.GetUpcomingScreeningsFunc = func(ctx context.Context, from, to time.Time) ([]domain.Screening, error) {
+			s.Equal(showtimesFeedWindow, to.Sub(from))
+			return []domain.Screening{
+				{
+					ShowtimeID:      5,
+					StartTime:       startTime,
+					Format:          domain.FormatIMAX,
+					Price:           decimal.RequireFromString("17.5"),
+					HallName:        "Hall 1",
+					TheaterID:       3,
+					TheaterName:     "CineX Kadikoy",
+					TheaterAddress:  "Bahariye Cd. 10",
+					TheaterCity:     "Istanbul",
+					TheaterDistrict: "Kadikoy",
+					TheaterTimeZone: "Europe/Istanbul",
+					MovieID:         2,
+					MovieTitle:      "Dune",
+					MovieDuration:   155,
+					MoviePosterUrl:  "https://img.example.com/dune.jpg",
+				},
+			}, nil
+		}
+
+		s.redisClient.On("Get", mock.Anything, "feeds:showtimes:0").Return(redis.NewStringResult("", redis.Nil))
+		s.redisClient.On("Set", mock.Anything, "feeds:showtimes:0", mock.Anything, showtimesFeedCacheTTL).
+			Return(redis.NewStatusResult("OK", nil))
+
+		w, r := executeRequest(s.T(), http.MethodGet, "/feeds/showtimes.json", nil)
+		s.app.GetShowtimesFeed(w, r)
+
+		s.Equal(http.StatusOK, w.Code)
+		s.Equal("application/ld+json; charset=utf-8", w.Header().Get("Content-Type"))
+
+		var feed screeningEventFeed
+		s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &feed))
+
+		s.Equal("https://schema.org", feed.Context)
+		s.Require().Len(feed.Graph, 1)
+
+		event := feed.Graph[0]
+		s.Equal("ScreeningEvent", event.Type)
+		s.Equal("Dune at CineX Kadikoy", event.Name)
+		s.Equal("2030-03-01T22:30:00+03:00", event.StartDate)
+		s.Equal("2030-03-02T01:05:00+03:00", event.EndDate)
+		s.Equal("IMAX", event.VideoFormat)
+		s.Equal(movieWork{
+			Type:     "Movie",
+			Name:     "Dune",
+			URL:      "https://api.example.com/movies/2",
+			Image:    "https://img.example.com/dune.jpg",
+			Duration: "PT155M",
+		}, event.WorkPresented)
+		s.Equal("Kadikoy", event.Location.Address.AddressLocality)
+		s.Equal(eventOffer{
+			Type:          "Offer",
+			URL:           "https://api.example.com/showtimes/5/seat-map",
+			Price:         "17.50",
+			PriceCurrency: "USD",
+		}, event.Offers)
+
+		s.redisClient.AssertExpectations(s.T())
+	})
+}
@@ -0,0 +1,162 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/fulfillment"
+	"github.com/metinatakli/movie-reservation-system/internal/scheduler"
+)
+
+// fulfillmentBatchSize bounds the fulfillments retried in a single job run
+const fulfillmentBatchSize = 50
+
+// queueFulfillment keeps a paid reservation whose write failed, for retryPendingFulfillments. Its seat
+// locks are kept until the showtime starts, so the seats aren't sold again while it is pending.
+func (app *Application) queueFulfillment(
+	ctx context.Context,
+	logger *slog.Logger,
+	reservation domain.Reservation,
+	cart *domain.Cart,
+	sessionId string,
+	createErr error) error {
+
+	now := time.Now()
+
+	pending := fulfillment.Pending{
+		Reservation: reservation,
+		CartID:      cart.Id,
+		SessionID:   sessionId,
+		CreatedAt:   now,
+	}
+	pending.Failed(createErr, now, app.config.Jobs.FulfillmentMaxAttempts)
+
+	err := app.fulfillments.Save(ctx, pending)
+	if err != nil {
+		return err
+	}
+
+	pipe := app.redis.TxPipeline()
+
+	for _, seat := range cart.Seats {
+		pipe.ExpireAt(ctx, seatLockKey(cart.ShowtimeID, seat.Id), cart.Date)
+	}
+
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		logger.Error("fulfillment queued but failed to extend its seat locks", "error", err)
+	}
+
+	return nil
+}
+
+// retryPendingFulfillments creates the reservations of the due fulfillments. Failed attempts are
+// rescheduled with a growing backoff until the attempts run out, stuck fulfillments need an admin.
+func (app *Application) retryPendingFulfillments(ctx context.Context) error {
+	due, err := app.fulfillments.Due(ctx, time.Now(), fulfillmentBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, pending := range due {
+		if err := scheduler.CheckFence(ctx); err != nil {
+			return err
+		}
+
+		err := app.retryFulfillment(ctx, pending)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// retryFulfillment makes a single attempt. Only errors of the queue itself are returned.
+func (app *Application) retryFulfillment(ctx context.Context, pending fulfillment.Pending) error {
+	reservation := pending.Reservation
+	logger := app.logger.With("payment_id", reservation.PaymentID, "attempt", pending.Attempts+1)
+
+	payment, err := app.paymentRepo.GetById(ctx, reservation.PaymentID)
+	if err == nil && payment.Status == domain.PaymentStatusCompleted {
+		// a redelivered webhook created the reservation, or the failed write was committed after all
+		logger.Info("payment is already completed, dropping the pending fulfillment")
+		return app.fulfillments.Remove(ctx, reservation.PaymentID)
+	}
+
+	if err == nil {
+		err = app.reservationRepo.Create(ctx, &reservation)
+	}
+
+	if errors.Is(err, domain.ErrSeatAlreadyReserved) {
+		err = app.refundDoubleBooking(ctx, logger, reservation, pending.CartID, pending.SessionID)
+		if err == nil {
+			return app.fulfillments.Remove(ctx, reservation.PaymentID)
+		}
+	}
+
+	if err != nil {
+		pending.Failed(err, time.Now(), app.config.Jobs.FulfillmentMaxAttempts)
+
+		if pending.Stuck() {
+			logger.Error("giving up creating the reservation of a completed payment", "error", err)
+		} else {
+			logger.Warn("failed to create the reservation of a completed payment", "error", err,
+				"next_attempt_at", pending.NextAttemptAt)
+		}
+
+		return app.fulfillments.Save(ctx, pending)
+	}
+
+	logger.Info("reservation of a pending fulfillment created", "reservation_id", reservation.ID)
+
+	err = app.fulfillments.Remove(ctx, reservation.PaymentID)
+	if err != nil {
+		return err
+	}
+
+	app.completeFulfillment(ctx, logger, reservation, pending.CartID, pending.SessionID)
+
+	return nil
+}
+
+func (app *Application) GetPendingFulfillments(w http.ResponseWriter, r *http.Request) {
+	pending, err := app.fulfillments.List(r.Context())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	resp := api.PendingFulfillmentsResponse{
+		Fulfillments: make([]api.PendingFulfillment, len(pending)),
+	}
+
+	for i, p := range pending {
+		seatIds := make([]int, len(p.Reservation.ReservationSeats))
+		for j, seat := range p.Reservation.ReservationSeats {
+			seatIds[j] = seat.SeatID
+		}
+
+		resp.Fulfillments[i] = api.PendingFulfillment{
+			PaymentId:     p.Reservation.PaymentID,
+			UserId:        p.Reservation.UserID,
+			ShowtimeId:    p.Reservation.ShowtimeID,
+			SeatIds:       seatIds,
+			Attempts:      p.Attempts,
+			LastError:     p.LastError,
+			Stuck:         p.Stuck(),
+			NextAttemptAt: p.NextAttemptAt,
+			CreatedAt:     p.CreatedAt,
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,496 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/fulfillment"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/stripe/stripe-go/v82"
+)
+
+type FulfillmentTestSuite struct {
+	suite.Suite
+	app             *Application
+	fulfillments    *mocks.MockFulfillmentQueue
+	paymentRepo     *mocks.MockPaymentRepo
+	reservationRepo *mocks.MockReservationRepo
+	paymentProvider *mocks.MockPaymentProvider
+	redisClient     *mocks.MockRedisClient
+	redisPipeline   *mocks.MockTxPipeline
+}
+
+func (s *FulfillmentTestSuite) SetupTest() {
+	s.fulfillments = new(mocks.MockFulfillmentQueue)
+	s.paymentRepo = new(mocks.MockPaymentRepo)
+	s.reservationRepo = new(mocks.MockReservationRepo)
+	s.paymentProvider = new(mocks.MockPaymentProvider)
+	s.redisClient = new(mocks.MockRedisClient)
+	s.redisPipeline = new(mocks.MockTxPipeline)
+	s.app = newTestApplication(func(a *Application) {
+		a.config.Stripe.WebhookSecret = testWebhookSecret
+		a.config.Jobs.FulfillmentMaxAttempts = 3
+		a.fulfillments = s.fulfillments
+		a.paymentRepo = s.paymentRepo
+		a.reservationRepo = s.reservationRepo
+		a.paymentProvider = s.paymentProvider
+		a.redis = s.redisClient
+	})
+}
+
+func TestFulfillmentSuite(t *testing.T) {
+	suite.Run(t, new(FulfillmentTestSuite))
+}
+
+func (s *FulfillmentTestSuite) assertExpectations() {
+	s.fulfillments.AssertExpectations(s.T())
+	s.paymentRepo.AssertExpectations(s.T())
+	s.reservationRepo.AssertExpectations(s.T())
+	s.paymentProvider.AssertExpectations(s.T())
+	s.redisClient.AssertExpectations(s.T())
+	s.redisPipeline.AssertExpectations(s.T())
+}
+
+func (s *FulfillmentTestSuite) TestCheckoutCompletionQueuesFailedReservation() {
+	showtimeDate := time.Date(2030, 1, 1, 20, 0, 0, 0, time.UTC)
+
+	cart := domain.Cart{
+		ShowtimeID: 1,
+		BasePrice:  decimal.NewFromInt(10),
+		Date:       showtimeDate,
+		Seats: []domain.CartSeat{
+			{Id: 1, Row: 1, Col: 1, SeatType: "VIP", ExtraPrice: decimal.RequireFromString("2.50")},
+			{Id: 2, Row: 1, Col: 2},
+		},
+	}
+
+	cartJSON, err := json.Marshal(cart)
+	s.Require().NoError(err)
+
+	checkoutSession := map[string]any{
+		"id":     "cs_1",
+		"object": "checkout.session",
+		"metadata": map[string]string{
+			domain.CheckoutMetadataCartID:    "cart-1",
+			domain.CheckoutMetadataSessionID: "session-1",
+			domain.CheckoutMetadataUserID:    "7",
+			domain.CheckoutMetadataPaymentID: "3",
+		},
+	}
+
+	tests := []struct {
+		name       string
+		saveErr    error
+		wantStatus int
+	}{
+		{
+			name:       "reservation is queued and stripe gets a success",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "stripe retries when the reservation can't be queued either",
+			saveErr:    errors.New("redis error"),
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			s.paymentRepo.On("GetById", mock.Anything, 3).
+				Return(&domain.Payment{ID: 3, Status: domain.PaymentStatusPending}, nil)
+			s.redisClient.On("Get", mock.Anything, "cart-1").Return(redis.NewStringResult(string(cartJSON), nil))
+			s.redisClient.On("Get", mock.Anything, seatLockKey(1, 1)).Return(redis.NewStringResult("session-1", nil))
+			s.redisClient.On("Get", mock.Anything, seatLockKey(1, 2)).Return(redis.NewStringResult("session-1", nil))
+			// the seats are created with the prices they were charged with
+			s.reservationRepo.On("Create", mock.Anything, mock.MatchedBy(func(r *domain.Reservation) bool {
+				seats := r.ReservationSeats
+				return len(seats) == 2 &&
+					seats[0].BasePrice.Equal(decimal.NewFromInt(10)) &&
+					seats[0].ExtraPrice.Equal(decimal.RequireFromString("2.50")) &&
+					seats[0].Price.Equal(decimal.RequireFromString("12.50")) &&
+					seats[1].Price.Equal(decimal.NewFromInt(10)) &&
+					seats[1].Discount.IsZero() && seats[1].Tax.IsZero()
+			})).Return(errors.New("connection refused"))
+
+			s.fulfillments.On("Save", mock.Anything, mock.MatchedBy(func(p fulfillment.Pending) bool {
+				return p.Reservation.PaymentID == 3 &&
+					p.Reservation.UserID == 7 &&
+					len(p.Reservation.ReservationSeats) == 2 &&
+					p.CartID == "cart-1" &&
+					p.SessionID == "session-1" &&
+					p.Attempts == 1 &&
+					p.LastError == "connection refused" &&
+					!p.Stuck()
+			})).Return(tt.saveErr)
+
+			if tt.saveErr == nil {
+				s.redisClient.On("TxPipeline").Return(s.redisPipeline)
+				s.redisPipeline.On("ExpireAt", mock.Anything, seatLockKey(1, 1), showtimeDate).
+					Return(redis.NewBoolResult(true, nil))
+				s.redisPipeline.On("ExpireAt", mock.Anything, seatLockKey(1, 2), showtimeDate).
+					Return(redis.NewBoolResult(true, nil))
+				s.redisPipeline.On("Exec", mock.Anything).Return([]redis.Cmder{}, nil)
+			}
+
+			w := httptest.NewRecorder()
+			r := signedWebhookRequest(s.T(), "checkout.session.completed", checkoutSession)
+
+			s.app.StripeWebhookHandler(w, r)
+
+			s.Equal(tt.wantStatus, w.Code)
+			s.assertExpectations()
+		})
+	}
+}
+
+func (s *FulfillmentTestSuite) TestCheckoutCompletionRefundsDoubleBooking() {
+	cart := domain.Cart{
+		ShowtimeID: 1,
+		BasePrice:  decimal.NewFromInt(10),
+		Seats: []domain.CartSeat{
+			{Id: 1, Row: 1, Col: 1},
+			{Id: 2, Row: 1, Col: 2},
+		},
+	}
+
+	cartJSON, err := json.Marshal(cart)
+	s.Require().NoError(err)
+
+	checkoutSession := map[string]any{
+		"id":             "cs_1",
+		"object":         "checkout.session",
+		"payment_intent": "pi_1",
+		"metadata": map[string]string{
+			domain.CheckoutMetadataCartID:    "cart-1",
+			domain.CheckoutMetadataSessionID: "session-1",
+			domain.CheckoutMetadataUserID:    "7",
+			domain.CheckoutMetadataPaymentID: "3",
+		},
+	}
+
+	tests := []struct {
+		name       string
+		refundErr  error
+		wantStatus int
+	}{
+		{
+			name:       "payment is refunded and stripe gets a conflict",
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "stripe retries when the refund fails",
+			refundErr:  errors.New("stripe is down"),
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			s.paymentRepo.On("GetById", mock.Anything, 3).
+				Return(&domain.Payment{ID: 3, Status: domain.PaymentStatusPending}, nil)
+			s.redisClient.On("Get", mock.Anything, "cart-1").Return(redis.NewStringResult(string(cartJSON), nil))
+			s.redisClient.On("Get", mock.Anything, seatLockKey(1, 1)).Return(redis.NewStringResult("session-1", nil))
+			s.redisClient.On("Get", mock.Anything, seatLockKey(1, 2)).Return(redis.NewStringResult("session-1", nil))
+			s.reservationRepo.On("Create", mock.Anything, mock.Anything).Return(domain.ErrSeatAlreadyReserved)
+
+			if tt.refundErr != nil {
+				s.paymentProvider.On("RefundPayment", "pi_1", "double-booking-3").Return(nil, tt.refundErr)
+			} else {
+				s.paymentProvider.On("RefundPayment", "pi_1", "double-booking-3").
+					Return(&stripe.Refund{ID: "re_1"}, nil)
+				s.paymentRepo.On("MarkUnfulfilled", mock.Anything, 3, "cs_1", "pi_1", domain.ErrSeatAlreadyReserved.Error()).
+					Return(nil)
+
+				// the second seat was sold, its lock isn't owned by the session anymore
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, []string{seatLockKey(1, 1), seatLockKey(1, 2)}, "session-1").
+					Return(redis.NewCmdResult([]interface{}{int64(1), int64(0)}, nil))
+				s.redisClient.On("TxPipeline").Return(s.redisPipeline)
+				s.redisPipeline.On("SRem", mock.Anything, seatSetKey(1), []interface{}{1}).Return(redis.NewIntResult(1, nil))
+				s.redisPipeline.On("Del", mock.Anything, []string{"cart-1"}).Return(redis.NewIntResult(1, nil))
+				s.redisPipeline.On("Del", mock.Anything, []string{cartSessionKey("session-1")}).
+					Return(redis.NewIntResult(1, nil))
+				s.redisPipeline.On("Exec", mock.Anything).Return([]redis.Cmder{}, nil)
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatMapChangeKeys(1), seatEventsChannel(1), mock.Anything, mock.Anything).
+					Return(redis.NewCmdResult(int64(1), nil))
+			}
+
+			w := httptest.NewRecorder()
+			r := signedWebhookRequest(s.T(), "checkout.session.completed", checkoutSession)
+
+			s.app.StripeWebhookHandler(w, r)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantStatus == http.StatusConflict {
+				checkErrorCode(s.T(), w, api.SEATALREADYRESERVED)
+			}
+
+			s.assertExpectations()
+		})
+	}
+}
+
+func (s *FulfillmentTestSuite) TestRetryPendingFulfillments() {
+	nextAttemptAt := time.Now().Add(-time.Minute)
+
+	newPending := func(attempts int) fulfillment.Pending {
+		return fulfillment.Pending{
+			Reservation: domain.Reservation{
+				UserID:     7,
+				ShowtimeID: 1,
+				PaymentID:  3,
+				ReservationSeats: []domain.ReservationSeat{
+					{ShowtimeID: 1, SeatID: 1},
+					{ShowtimeID: 1, SeatID: 2},
+				},
+			},
+			CartID:        "cart-1",
+			SessionID:     "session-1",
+			Attempts:      attempts,
+			LastError:     "connection refused",
+			NextAttemptAt: &nextAttemptAt,
+		}
+	}
+
+	tests := []struct {
+		name       string
+		setupMocks func()
+		wantErr    bool
+	}{
+		{
+			name: "queue can't be read",
+			setupMocks: func() {
+				s.fulfillments.On("Due", mock.Anything, mock.Anything, fulfillmentBatchSize).
+					Return(nil, errors.New("redis error"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "creates the reservation and cleans up the cart",
+			setupMocks: func() {
+				s.fulfillments.On("Due", mock.Anything, mock.Anything, fulfillmentBatchSize).
+					Return([]fulfillment.Pending{newPending(1)}, nil)
+				s.paymentRepo.On("GetById", mock.Anything, 3).
+					Return(&domain.Payment{ID: 3, Status: domain.PaymentStatusPending}, nil)
+				s.reservationRepo.On("Create", mock.Anything, mock.MatchedBy(func(r *domain.Reservation) bool {
+					return r.PaymentID == 3 && len(r.ReservationSeats) == 2
+				})).Run(func(args mock.Arguments) {
+					args.Get(1).(*domain.Reservation).ID = 11
+				}).Return(nil)
+				s.fulfillments.On("Remove", mock.Anything, 3).Return(nil)
+
+				s.redisClient.On("TxPipeline").Return(s.redisPipeline)
+				s.redisPipeline.On("Del", mock.Anything, []string{seatLockKey(1, 1)}).Return(redis.NewIntResult(1, nil))
+				s.redisPipeline.On("Del", mock.Anything, []string{seatLockKey(1, 2)}).Return(redis.NewIntResult(1, nil))
+				s.redisPipeline.On("SRem", mock.Anything, seatSetKey(1), mock.Anything).Return(redis.NewIntResult(1, nil))
+				s.redisPipeline.On("Del", mock.Anything, []string{"cart-1"}).Return(redis.NewIntResult(1, nil))
+				s.redisPipeline.On("Del", mock.Anything, []string{cartSessionKey("session-1")}).
+					Return(redis.NewIntResult(1, nil))
+				s.redisPipeline.On("Exec", mock.Anything).Return([]redis.Cmder{}, nil)
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatMapChangeKeys(1), seatEventsChannel(1), mock.Anything, mock.Anything).
+					Return(redis.NewCmdResult(int64(1), nil))
+			},
+		},
+		{
+			name: "drops the fulfillment of an already completed payment",
+			setupMocks: func() {
+				s.fulfillments.On("Due", mock.Anything, mock.Anything, fulfillmentBatchSize).
+					Return([]fulfillment.Pending{newPending(1)}, nil)
+				s.paymentRepo.On("GetById", mock.Anything, 3).
+					Return(&domain.Payment{ID: 3, Status: domain.PaymentStatusCompleted}, nil)
+				s.fulfillments.On("Remove", mock.Anything, 3).Return(nil)
+			},
+		},
+		{
+			name: "reschedules a failed attempt",
+			setupMocks: func() {
+				s.fulfillments.On("Due", mock.Anything, mock.Anything, fulfillmentBatchSize).
+					Return([]fulfillment.Pending{newPending(1)}, nil)
+				s.paymentRepo.On("GetById", mock.Anything, 3).
+					Return(&domain.Payment{ID: 3, Status: domain.PaymentStatusPending}, nil)
+				s.reservationRepo.On("Create", mock.Anything, mock.Anything).Return(errors.New("deadlock detected"))
+				s.fulfillments.On("Save", mock.Anything, mock.MatchedBy(func(p fulfillment.Pending) bool {
+					return p.Attempts == 2 &&
+						p.LastError == "deadlock detected" &&
+						!p.Stuck() &&
+						p.NextAttemptAt.After(time.Now())
+				})).Return(nil)
+			},
+		},
+		{
+			name: "refunds the payment when the seats were sold meanwhile",
+			setupMocks: func() {
+				pending := newPending(1)
+				pending.Reservation.CheckoutSessionID = "cs_1"
+				pending.Reservation.PaymentIntentID = "pi_1"
+
+				s.fulfillments.On("Due", mock.Anything, mock.Anything, fulfillmentBatchSize).
+					Return([]fulfillment.Pending{pending}, nil)
+				s.paymentRepo.On("GetById", mock.Anything, 3).
+					Return(&domain.Payment{ID: 3, Status: domain.PaymentStatusPending}, nil)
+				s.reservationRepo.On("Create", mock.Anything, mock.Anything).Return(domain.ErrSeatAlreadyReserved)
+				s.paymentProvider.On("RefundPayment", "pi_1", "double-booking-3").Return(&stripe.Refund{ID: "re_1"}, nil)
+				s.paymentRepo.On("MarkUnfulfilled", mock.Anything, 3, "cs_1", "pi_1", domain.ErrSeatAlreadyReserved.Error()).
+					Return(nil)
+				s.fulfillments.On("Remove", mock.Anything, 3).Return(nil)
+
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, []string{seatLockKey(1, 1), seatLockKey(1, 2)}, "session-1").
+					Return(redis.NewCmdResult([]interface{}{int64(0), int64(0)}, nil))
+				s.redisClient.On("TxPipeline").Return(s.redisPipeline)
+				s.redisPipeline.On("Del", mock.Anything, []string{"cart-1"}).Return(redis.NewIntResult(1, nil))
+				s.redisPipeline.On("Del", mock.Anything, []string{cartSessionKey("session-1")}).
+					Return(redis.NewIntResult(1, nil))
+				s.redisPipeline.On("Exec", mock.Anything).Return([]redis.Cmder{}, nil)
+			},
+		},
+		{
+			name: "reschedules a double booking whose refund fails",
+			setupMocks: func() {
+				s.fulfillments.On("Due", mock.Anything, mock.Anything, fulfillmentBatchSize).
+					Return([]fulfillment.Pending{newPending(1)}, nil)
+				s.paymentRepo.On("GetById", mock.Anything, 3).
+					Return(&domain.Payment{ID: 3, Status: domain.PaymentStatusPending}, nil)
+				s.reservationRepo.On("Create", mock.Anything, mock.Anything).Return(domain.ErrSeatAlreadyReserved)
+				s.fulfillments.On("Save", mock.Anything, mock.MatchedBy(func(p fulfillment.Pending) bool {
+					return p.Attempts == 2 && strings.Contains(p.LastError, "without a payment intent")
+				})).Return(nil)
+			},
+		},
+		{
+			name: "gives up after the last attempt",
+			setupMocks: func() {
+				s.fulfillments.On("Due", mock.Anything, mock.Anything, fulfillmentBatchSize).
+					Return([]fulfillment.Pending{newPending(2)}, nil)
+				s.paymentRepo.On("GetById", mock.Anything, 3).Return(nil, errors.New("db error"))
+				s.fulfillments.On("Save", mock.Anything, mock.MatchedBy(func(p fulfillment.Pending) bool {
+					return p.Attempts == 3 && p.LastError == "db error" && p.Stuck()
+				})).Return(nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+			tt.setupMocks()
+
+			err := s.app.retryPendingFulfillments(context.Background())
+
+			s.Equal(tt.wantErr, err != nil, "unexpected error: %v", err)
+			s.assertExpectations()
+		})
+	}
+}
+
+func (s *FulfillmentTestSuite) TestGetPendingFulfillments() {
+	createdAt := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	nextAttemptAt := createdAt.Add(4 * time.Minute)
+
+	tests := []struct {
+		name       string
+		setupMocks func()
+		wantStatus int
+		wantResp   *api.PendingFulfillmentsResponse
+	}{
+		{
+			name: "lists pending and stuck fulfillments",
+			setupMocks: func() {
+				s.fulfillments.On("List", mock.Anything).Return([]fulfillment.Pending{
+					{
+						Reservation: domain.Reservation{
+							UserID:           7,
+							ShowtimeID:       1,
+							PaymentID:        3,
+							ReservationSeats: []domain.ReservationSeat{{SeatID: 1}, {SeatID: 2}},
+						},
+						Attempts:      3,
+						LastError:     "connection refused",
+						CreatedAt:     createdAt,
+						NextAttemptAt: &nextAttemptAt,
+					},
+					{
+						Reservation: domain.Reservation{
+							UserID:           8,
+							ShowtimeID:       2,
+							PaymentID:        4,
+							ReservationSeats: []domain.ReservationSeat{{SeatID: 5}},
+						},
+						Attempts:  10,
+						LastError: "duplicate key value violates unique constraint",
+						CreatedAt: createdAt,
+					},
+				}, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantResp: &api.PendingFulfillmentsResponse{
+				Fulfillments: []api.PendingFulfillment{
+					{
+						PaymentId:     3,
+						UserId:        7,
+						ShowtimeId:    1,
+						SeatIds:       []int{1, 2},
+						Attempts:      3,
+						LastError:     "connection refused",
+						NextAttemptAt: &nextAttemptAt,
+						CreatedAt:     createdAt,
+					},
+					{
+						PaymentId:  4,
+						UserId:     8,
+						ShowtimeId: 2,
+						SeatIds:    []int{5},
+						Attempts:   10,
+						LastError:  "duplicate key value violates unique constraint",
+						Stuck:      true,
+						CreatedAt:  createdAt,
+					},
+				},
+			},
+		},
+		{
+			name: "queue can't be read",
+			setupMocks: func() {
+				s.fulfillments.On("List", mock.Anything).Return(nil, errors.New("redis error"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+			tt.setupMocks()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/admin/fulfillments", nil)
+
+			s.app.GetPendingFulfillments(w, r)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantResp != nil {
+				var got api.PendingFulfillmentsResponse
+				s.Require().NoError(json.NewDecoder(w.Body).Decode(&got))
+				s.Equal(*tt.wantResp, got)
+			}
+
+			s.fulfillments.AssertExpectations(s.T())
+		})
+	}
+}
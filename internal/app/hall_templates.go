@@ -0,0 +1,278 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+func (app *Application) GetHallTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := app.seatRepo.GetHallTemplates(r.Context())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	resp := api.HallTemplatesResponse{
+		Templates: make([]api.HallTemplate, len(templates)),
+	}
+
+	for i, template := range templates {
+		resp.Templates[i] = toApiHallTemplate(template)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) GetHallTemplate(w http.ResponseWriter, r *http.Request, templateId int) {
+	if templateId < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("template ID must be greater than zero"))
+		return
+	}
+
+	template, err := app.seatRepo.GetHallTemplate(r.Context(), templateId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, toApiHallTemplate(*template), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) CreateHallTemplate(w http.ResponseWriter, r *http.Request) {
+	template, ok := app.readHallTemplate(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.seatRepo.CreateHallTemplate(r.Context(), template)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrEditConflict):
+			app.editConflictResponseWithErr(w, r, fmt.Errorf("a hall template with this name already exists"))
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	app.contextGetLogger(r).Info("hall template created", "template_id", template.ID, "seats", len(template.Seats))
+
+	err = app.writeJSON(w, http.StatusCreated, toApiHallTemplate(*template), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) UpdateHallTemplate(w http.ResponseWriter, r *http.Request, templateId int) {
+	if templateId < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("template ID must be greater than zero"))
+		return
+	}
+
+	template, ok := app.readHallTemplate(w, r)
+	if !ok {
+		return
+	}
+
+	template.ID = templateId
+
+	err := app.seatRepo.UpdateHallTemplate(r.Context(), template)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, domain.ErrEditConflict):
+			app.editConflictResponseWithErr(w, r, fmt.Errorf("a hall template with this name already exists"))
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	app.contextGetLogger(r).Info("hall template updated", "template_id", template.ID, "seats", len(template.Seats))
+
+	err = app.writeJSON(w, http.StatusOK, toApiHallTemplate(*template), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) DeleteHallTemplate(w http.ResponseWriter, r *http.Request, templateId int) {
+	if templateId < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("template ID must be greater than zero"))
+		return
+	}
+
+	err := app.seatRepo.DeleteHallTemplate(r.Context(), templateId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *Application) CreateHallFromTemplate(w http.ResponseWriter, r *http.Request, theaterId int) {
+	if theaterId < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("theater ID must be greater than zero"))
+		return
+	}
+
+	var input api.CreateHallRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	hall := domain.Hall{
+		TheaterID: theaterId,
+		Name:      input.Name,
+	}
+
+	seatCount, err := app.seatRepo.CreateHallFromTemplate(r.Context(), &hall, input.TemplateId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrTheaterNotFound):
+			app.notFoundResponseWithErr(w, r, err)
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponseWithErr(w, r, fmt.Errorf("hall template not found"))
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	app.contextGetLogger(r).Info(
+		"hall created from template",
+		"hall_id", hall.ID,
+		"theater_id", theaterId,
+		"template_id", input.TemplateId,
+		"seats", seatCount,
+	)
+
+	resp := api.CreatedHall{
+		Id:         hall.ID,
+		TheaterId:  hall.TheaterID,
+		Name:       hall.Name,
+		TemplateId: input.TemplateId,
+		SeatCount:  seatCount,
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readHallTemplate reads and validates the template of the request. The error response is written if the
+// template is invalid.
+func (app *Application) readHallTemplate(w http.ResponseWriter, r *http.Request) (*domain.HallTemplate, bool) {
+	var input api.HallTemplateRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, false
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return nil, false
+	}
+
+	template := &domain.HallTemplate{
+		Name:  input.Name,
+		Seats: make([]domain.HallTemplateSeat, len(input.Seats)),
+	}
+
+	if input.Description != nil {
+		template.Description = strings.TrimSpace(*input.Description)
+	}
+
+	positions := make(map[[2]int]bool, len(input.Seats))
+
+	for i, seat := range input.Seats {
+		position := [2]int{seat.Row, seat.Col}
+		if positions[position] {
+			app.badRequestResponse(w, r, fmt.Errorf("seat at row %d, column %d appears more than once", seat.Row, seat.Col))
+			return nil, false
+		}
+		positions[position] = true
+
+		if seat.ExtraPrice.IsNegative() || seat.ExtraPrice.GreaterThan(maxSeatExtraPrice) {
+			app.badRequestResponse(w, r, fmt.Errorf("extra price must be between 0 and %s", maxSeatExtraPrice))
+			return nil, false
+		}
+
+		template.Seats[i] = domain.HallTemplateSeat{
+			Row:        seat.Row,
+			Col:        seat.Col,
+			Type:       string(seat.SeatType),
+			ExtraPrice: seat.ExtraPrice.Round(2),
+		}
+	}
+
+	return template, true
+}
+
+func toApiHallTemplate(template domain.HallTemplate) api.HallTemplate {
+	resp := api.HallTemplate{
+		Id:          template.ID,
+		Name:        template.Name,
+		Description: template.Description,
+		SeatCount:   len(template.Seats),
+		Seats:       make([]api.HallTemplateSeat, len(template.Seats)),
+		CreatedAt:   template.CreatedAt,
+		UpdatedAt:   template.UpdatedAt,
+	}
+
+	for i, seat := range template.Seats {
+		resp.Seats[i] = api.HallTemplateSeat{
+			Row:        seat.Row,
+			Col:        seat.Col,
+			SeatType:   api.SeatType(seat.Type),
+			ExtraPrice: seat.ExtraPrice,
+		}
+	}
+
+	return resp
+}
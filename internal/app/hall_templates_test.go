@@ -0,0 +1,241 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type HallTemplatesTestSuite struct {
+	suite.Suite
+	app      *Application
+	seatRepo *mocks.MockSeatRepo
+}
+
+func (s *HallTemplatesTestSuite) SetupTest() {
+	s.seatRepo = new(mocks.MockSeatRepo)
+
+	s.app = newTestApplication(func(a *Application) {
+		a.seatRepo = s.seatRepo
+	})
+}
+
+func TestHallTemplatesSuite(t *testing.T) {
+	suite.Run(t, new(HallTemplatesTestSuite))
+}
+
+func (s *HallTemplatesTestSuite) TestCreateHallTemplate() {
+	seats := []map[string]any{
+		{"row": 1, "col": 1, "seatType": "Standard", "extraPrice": "0"},
+		{"row": 2, "col": 1, "seatType": "Recliner", "extraPrice": "7.50"},
+	}
+
+	tests := []struct {
+		name           string
+		input          map[string]any
+		setupMocks     func()
+		wantStatus     int
+		wantErrMessage string
+	}{
+		{
+			name:       "no seats",
+			input:      map[string]any{"name": "Standard 12x10", "seats": []map[string]any{}},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "seat appears twice",
+			input: map[string]any{"name": "Standard 12x10", "seats": []map[string]any{
+				{"row": 1, "col": 1, "seatType": "Standard", "extraPrice": "0"},
+				{"row": 1, "col": 1, "seatType": "VIP", "extraPrice": "5"},
+			}},
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "seat at row 1, column 1 appears more than once",
+		},
+		{
+			name: "negative price",
+			input: map[string]any{"name": "Standard 12x10", "seats": []map[string]any{
+				{"row": 1, "col": 1, "seatType": "Standard", "extraPrice": "-1"},
+			}},
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "extra price must be between 0 and 9999.99",
+		},
+		{
+			name:  "name taken",
+			input: map[string]any{"name": "Standard 12x10", "seats": seats},
+			setupMocks: func() {
+				s.seatRepo.On("CreateHallTemplate", mock.Anything, mock.Anything).Return(domain.ErrEditConflict)
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: "a hall template with this name already exists",
+		},
+		{
+			name:  "created",
+			input: map[string]any{"name": "  IMAX 20x18 ", "description": "Recliners in the back", "seats": seats},
+			setupMocks: func() {
+				s.seatRepo.On("CreateHallTemplate", mock.Anything, mock.MatchedBy(func(t *domain.HallTemplate) bool {
+					return t.Name == "IMAX 20x18" &&
+						t.Description == "Recliners in the back" &&
+						len(t.Seats) == 2 &&
+						t.Seats[1].Type == "Recliner" &&
+						t.Seats[1].ExtraPrice.Equal(decimal.RequireFromString("7.50"))
+				})).Run(func(args mock.Arguments) {
+					args.Get(1).(*domain.HallTemplate).ID = 4
+				}).Return(nil)
+			},
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:  "database error",
+			input: map[string]any{"name": "Standard 12x10", "seats": seats},
+			setupMocks: func() {
+				s.seatRepo.On("CreateHallTemplate", mock.Anything, mock.Anything).Return(errors.New("db error"))
+			},
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			w, r := executeRequest(s.T(), http.MethodPost, "/admin/hall-templates", tt.input)
+			s.app.CreateHallTemplate(w, r)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantStatus == http.StatusCreated {
+				var resp api.HallTemplate
+				s.Require().NoError(json.NewDecoder(w.Body).Decode(&resp))
+				s.Equal(4, resp.Id)
+				s.Equal(2, resp.SeatCount)
+			}
+
+			if tt.wantErrMessage != "" {
+				checkErrorResponse(s.T(), w, struct {
+					wantStatus     int
+					wantErrMessage string
+				}{tt.wantStatus, tt.wantErrMessage})
+			}
+
+			s.seatRepo.AssertExpectations(s.T())
+		})
+	}
+}
+
+func (s *HallTemplatesTestSuite) TestUpdateHallTemplateNotFound() {
+	s.seatRepo.On("UpdateHallTemplate", mock.Anything, mock.MatchedBy(func(t *domain.HallTemplate) bool {
+		return t.ID == 9
+	})).Return(domain.ErrRecordNotFound)
+
+	w, r := executeRequest(s.T(), http.MethodPut, "/admin/hall-templates/9", map[string]any{
+		"name":  "Standard 12x10",
+		"seats": []map[string]any{{"row": 1, "col": 1, "seatType": "Standard", "extraPrice": "0"}},
+	})
+	s.app.UpdateHallTemplate(w, r, 9)
+
+	s.Equal(http.StatusNotFound, w.Code)
+	s.seatRepo.AssertExpectations(s.T())
+}
+
+func (s *HallTemplatesTestSuite) TestCreateHallFromTemplate() {
+	tests := []struct {
+		name           string
+		theaterID      int
+		input          map[string]any
+		setupMocks     func()
+		wantStatus     int
+		wantErrMessage string
+	}{
+		{
+			name:           "invalid theater ID",
+			theaterID:      0,
+			input:          map[string]any{"name": "Hall 4", "templateId": 2},
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "theater ID must be greater than zero",
+		},
+		{
+			name:       "missing template",
+			theaterID:  1,
+			input:      map[string]any{"name": "Hall 4"},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:      "theater not found",
+			theaterID: 99,
+			input:     map[string]any{"name": "Hall 4", "templateId": 2},
+			setupMocks: func() {
+				s.seatRepo.On("CreateHallFromTemplate", mock.Anything, mock.Anything, 2).
+					Return(0, domain.ErrTheaterNotFound)
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: domain.ErrTheaterNotFound.Error(),
+		},
+		{
+			name:      "template not found",
+			theaterID: 1,
+			input:     map[string]any{"name": "Hall 4", "templateId": 2},
+			setupMocks: func() {
+				s.seatRepo.On("CreateHallFromTemplate", mock.Anything, mock.Anything, 2).
+					Return(0, domain.ErrRecordNotFound)
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: "hall template not found",
+		},
+		{
+			name:      "created",
+			theaterID: 1,
+			input:     map[string]any{"name": "Hall 4", "templateId": 2},
+			setupMocks: func() {
+				s.seatRepo.On("CreateHallFromTemplate", mock.Anything, mock.MatchedBy(func(h *domain.Hall) bool {
+					return h.TheaterID == 1 && h.Name == "Hall 4"
+				}), 2).Run(func(args mock.Arguments) {
+					args.Get(1).(*domain.Hall).ID = 12
+				}).Return(120, nil)
+			},
+			wantStatus: http.StatusCreated,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			w, r := executeRequest(s.T(), http.MethodPost, "/admin/theaters/1/halls", tt.input)
+			s.app.CreateHallFromTemplate(w, r, tt.theaterID)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantStatus == http.StatusCreated {
+				var resp api.CreatedHall
+				s.Require().NoError(json.NewDecoder(w.Body).Decode(&resp))
+				s.Equal(api.CreatedHall{Id: 12, TheaterId: 1, Name: "Hall 4", TemplateId: 2, SeatCount: 120}, resp)
+			}
+
+			if tt.wantErrMessage != "" {
+				checkErrorResponse(s.T(), w, struct {
+					wantStatus     int
+					wantErrMessage string
+				}{tt.wantStatus, tt.wantErrMessage})
+			}
+
+			s.seatRepo.AssertExpectations(s.T())
+		})
+	}
+}
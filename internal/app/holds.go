@@ -0,0 +1,393 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+// maxActiveSeatHolds caps the holds an account has at once, so a misbehaving or leaked kiosk can't lock
+// every seat of a showtime.
+const maxActiveSeatHolds = 10
+
+var (
+	errSeatHoldNotFound      = errors.New("there is no seat hold with the given reference for the showtime")
+	errHoldReferenceConflict = errors.New("the hold reference is already used for other seats")
+	errTooManySeatHolds      = fmt.Errorf("at most %d seat holds can be active at once, release one first", maxActiveSeatHolds)
+)
+
+var claimSeatHoldScript = redis.NewScript(`
+    -- KEYS[1] = active seat holds of the user (e.g., seat_holds:7), scored by their expiry
+    -- ARGV = [hold reference, expiry in unix milliseconds, now in unix milliseconds, max holds]
+    -- Returns 1 when the hold is claimed, 0 when the user already has the maximum number of holds
+
+    redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", ARGV[3])
+
+    -- a hold that is placed again keeps its place
+    if not redis.call("ZSCORE", KEYS[1], ARGV[1]) and redis.call("ZCARD", KEYS[1]) >= tonumber(ARGV[4]) then
+        return 0
+    end
+
+    redis.call("ZADD", KEYS[1], ARGV[2], ARGV[1])
+    redis.call("PEXPIREAT", KEYS[1], ARGV[2])
+
+    return 1
+`)
+
+func (app *Application) CreateSeatHold(w http.ResponseWriter, r *http.Request, showtimeId int) {
+	if showtimeId < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("showtime ID must be greater than zero"))
+		return
+	}
+
+	var input api.CreateSeatHoldRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	hold := domain.SeatHold{
+		Reference:  input.HoldReference,
+		UserID:     app.contextGetUserId(r),
+		ShowtimeID: showtimeId,
+		SeatIDs:    input.SeatIdList,
+	}
+
+	logger := app.contextGetLogger(r).With("hold_reference", hold.Reference, "showtime_id", showtimeId)
+
+	existing, err := app.getSeatHold(r.Context(), hold.UserID, hold.Reference)
+	switch {
+	case err == nil:
+		if !existing.Holds(showtimeId, hold.SeatIDs) {
+			logger.Warn("seat hold rejected: the reference is already used for other seats")
+			app.editConflictResponseWithErr(w, r, errHoldReferenceConflict)
+			return
+		}
+
+		expiresAt, err := app.seatHoldExpiry(r.Context(), existing)
+		if err == nil {
+			// a retry of a hold that was already placed
+			app.writeSeatHold(w, r, http.StatusOK, existing, expiresAt)
+			return
+		}
+
+		if !errors.Is(err, errSeatHoldNotFound) {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		// the seat locks expired right before the hold, it's placed again
+	case errors.Is(err, errSeatHoldNotFound):
+	default:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	showtimeSeats, err := app.selectableSeats(r.Context(), showtimeId, hold.SeatIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, errSeatsAlreadyReserved):
+			logger.Warn("seat hold conflict: an already reserved seat was selected", "requested_seats", hold.SeatIDs)
+			app.editConflictResponseWithErr(w, r, err)
+		case errors.Is(err, errSeatsBlocked):
+			logger.Warn("seat hold conflict: a blocked seat was selected", "requested_seats", hold.SeatIDs)
+			app.editConflictResponseWithErr(w, r, err)
+		case errors.Is(err, domain.ErrRecordNotFound):
+			logger.Warn("seat hold failed: one or more requested seat IDs do not exist for the showtime", "requested_seats", hold.SeatIDs)
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	allowed, err := app.canHoldSeats(r.Context(), hold.UserID, showtimeSeats.TheaterID)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.unauthorizedAccessResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	if !allowed {
+		logger.Warn("seat hold denied: user is neither a kiosk nor staff of the theater", "theater_id", showtimeSeats.TheaterID)
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	claimed, err := app.claimSeatHold(r.Context(), hold)
+	if err != nil {
+		app.serverErrorResponse(w, r, fmt.Errorf("seat hold couldn't be claimed: %w", err))
+		return
+	}
+
+	if !claimed {
+		logger.Warn("seat hold rejected: too many active holds")
+		app.editConflictResponseWithErr(w, r, errTooManySeatHolds)
+		return
+	}
+
+	err = app.tryLockSeats(r.Context(), hold.SeatIDs, showtimeId, seatHoldKey(hold.UserID, hold.Reference))
+	if err != nil {
+		app.unclaimSeatHold(r.Context(), hold)
+
+		switch {
+		case errors.Is(err, domain.ErrSeatAlreadyReserved):
+			logger.Warn("seat hold conflict: an already locked seat was selected")
+			app.editConflictResponseWithErr(w, r, errSeatsAlreadyLocked)
+		default:
+			app.serverErrorResponse(w, r, fmt.Errorf("seats couldn't be acquired: %w", err))
+		}
+
+		return
+	}
+
+	err = app.saveSeatHold(r.Context(), hold)
+	if err != nil {
+		app.rollbackSeatLocks(r.Context(), showtimeId, hold.SeatIDs)
+		app.unclaimSeatHold(r.Context(), hold)
+		app.serverErrorResponse(w, r, fmt.Errorf("seat hold couldn't be saved: %w", err))
+		return
+	}
+
+	app.publishSeatEvent(r.Context(), showtimeId, seatEventLocked, hold.SeatIDs)
+
+	// the hold is saved, a failure from here on is fixed by retrying with the same reference
+	expiresAt, err := app.seatHoldExpiry(r.Context(), &hold)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	logger.Info("seats held", "seat_ids", hold.SeatIDs, "expires_at", expiresAt)
+
+	app.writeSeatHold(w, r, http.StatusCreated, &hold, expiresAt)
+}
+
+func (app *Application) DeleteSeatHold(w http.ResponseWriter, r *http.Request, showtimeId int, holdReference string) {
+	if showtimeId < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("showtime ID must be greater than zero"))
+		return
+	}
+
+	hold, err := app.getSeatHold(r.Context(), app.contextGetUserId(r), holdReference)
+	if err != nil {
+		switch {
+		case errors.Is(err, errSeatHoldNotFound):
+			app.notFoundResponseWithErr(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	if hold.ShowtimeID != showtimeId {
+		app.notFoundResponseWithErr(w, r, errSeatHoldNotFound)
+		return
+	}
+
+	key := seatHoldKey(hold.UserID, hold.Reference)
+	lockKeys := make([]string, len(hold.SeatIDs))
+
+	for i, seatID := range hold.SeatIDs {
+		lockKeys[i] = seatLockKey(showtimeId, seatID)
+	}
+
+	// only the locks still owned by the hold are released, an expired lock may belong to someone else now
+	released, err := releaseSeatsScript.Run(r.Context(), app.redis, lockKeys, key).Int64Slice()
+	if err != nil {
+		app.serverErrorResponse(w, r, fmt.Errorf("seats couldn't be released: %w", err))
+		return
+	}
+
+	var releasedSeatIDs []int
+	for i, seatID := range hold.SeatIDs {
+		if i < len(released) && released[i] == 1 {
+			releasedSeatIDs = append(releasedSeatIDs, seatID)
+		}
+	}
+
+	pipe := app.redis.TxPipeline()
+
+	for _, seatID := range releasedSeatIDs {
+		pipe.SRem(r.Context(), seatSetKey(showtimeId), seatID)
+	}
+
+	pipe.Del(r.Context(), key)
+	pipe.ZRem(r.Context(), activeSeatHoldsKey(hold.UserID), hold.Reference)
+
+	_, err = pipe.Exec(r.Context())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if len(releasedSeatIDs) > 0 {
+		app.publishSeatEvent(r.Context(), showtimeId, seatEventReleased, releasedSeatIDs)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *Application) getSeatHold(ctx context.Context, userId int, reference string) (*domain.SeatHold, error) {
+	holdBytes, err := app.redis.Get(ctx, seatHoldKey(userId, reference)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, errSeatHoldNotFound
+		}
+
+		return nil, err
+	}
+
+	var hold domain.SeatHold
+
+	err = json.Unmarshal(holdBytes, &hold)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal seat hold %s: %w", reference, err)
+	}
+
+	return &hold, nil
+}
+
+// canHoldSeats reports whether the user may hold seats of the theater. Kiosks hold seats for their
+// customers, admins and the staff of the theater at the box office. It returns ErrRecordNotFound if the
+// user doesn't exist.
+func (app *Application) canHoldSeats(ctx context.Context, userId, theaterId int) (bool, error) {
+	user, err := app.userRepo.GetById(ctx, userId)
+	if err != nil {
+		return false, err
+	}
+
+	if user.Role == domain.RoleKiosk || user.IsAdmin() {
+		return true, nil
+	}
+
+	return app.theaterRepo.IsTheaterStaff(ctx, theaterId, userId)
+}
+
+// claimSeatHold counts the hold against the active holds of the user until its seat locks expire. It
+// returns false when the user already has the maximum number of holds.
+func (app *Application) claimSeatHold(ctx context.Context, hold domain.SeatHold) (bool, error) {
+	now := time.Now()
+
+	claimed, err := claimSeatHoldScript.Run(ctx, app.redis,
+		[]string{activeSeatHoldsKey(hold.UserID)},
+		hold.Reference,
+		now.Add(seatLockTTL).UnixMilli(),
+		now.UnixMilli(),
+		maxActiveSeatHolds).Int()
+	if err != nil {
+		return false, err
+	}
+
+	return claimed == 1, nil
+}
+
+// unclaimSeatHold gives back the place of a hold that couldn't be placed. A failure is only logged, the
+// place is freed anyway when the claim expires.
+func (app *Application) unclaimSeatHold(ctx context.Context, hold domain.SeatHold) {
+	err := app.redis.ZRem(ctx, activeSeatHoldsKey(hold.UserID), hold.Reference).Err()
+	if err != nil {
+		app.logger.Error("failed to unclaim seat hold", "hold_reference", hold.Reference, "error", err)
+	}
+}
+
+// saveSeatHold keeps the hold as long as its seat locks, so a retry can find it.
+func (app *Application) saveSeatHold(ctx context.Context, hold domain.SeatHold) error {
+	holdBytes, err := json.Marshal(hold)
+	if err != nil {
+		return err
+	}
+
+	seatIdInterfaces := make([]interface{}, len(hold.SeatIDs))
+	for i, seatID := range hold.SeatIDs {
+		seatIdInterfaces[i] = seatID
+	}
+
+	pipe := app.redis.TxPipeline()
+	pipe.SAdd(ctx, seatSetKey(hold.ShowtimeID), seatIdInterfaces...)
+	pipe.Set(ctx, seatHoldKey(hold.UserID, hold.Reference), holdBytes, seatLockTTL)
+
+	_, err = pipe.Exec(ctx)
+
+	return err
+}
+
+// seatHoldExpiry returns when the seat locks of the hold expire, to the millisecond. The seats are locked
+// together, the lock of the first seat stands for all of them.
+func (app *Application) seatHoldExpiry(ctx context.Context, hold *domain.SeatHold) (time.Time, error) {
+	lockKey := seatLockKey(hold.ShowtimeID, hold.SeatIDs[0])
+
+	owner, err := app.redis.Get(ctx, lockKey).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return time.Time{}, err
+	}
+
+	if owner != seatHoldKey(hold.UserID, hold.Reference) {
+		return time.Time{}, errSeatHoldNotFound
+	}
+
+	ttl, err := app.redis.PTTL(ctx, lockKey).Result()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// negative values report a missing key or a key without expiry
+	if ttl <= 0 {
+		return time.Time{}, errSeatHoldNotFound
+	}
+
+	return time.Now().Add(ttl).Truncate(time.Millisecond), nil
+}
+
+func (app *Application) writeSeatHold(
+	w http.ResponseWriter,
+	r *http.Request,
+	status int,
+	hold *domain.SeatHold,
+	expiresAt time.Time) {
+
+	resp := api.SeatHoldResponse{
+		HoldReference: hold.Reference,
+		ShowtimeId:    hold.ShowtimeID,
+		SeatIds:       hold.SeatIDs,
+		ExpiresAt:     expiresAt,
+	}
+
+	err := app.writeJSON(w, status, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// seatHoldKey is the key of the hold and the owner of its seat locks. References are chosen by the
+// clients, they are scoped to the user so clients can't collide or release each other's holds.
+func seatHoldKey(userId int, reference string) string {
+	return fmt.Sprintf("seat_hold:%d:%s", userId, reference)
+}
+
+// activeSeatHoldsKey is the key of the sorted set of the active hold references of the user.
+func activeSeatHoldsKey(userId int) string {
+	return fmt.Sprintf("seat_holds:%d", userId)
+}
@@ -0,0 +1,439 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/metinatakli/movie-reservation-system/internal/validator"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	testKioskUserID   = 7
+	testHoldReference = "kiosk-3f9a1c"
+)
+
+type SeatHoldTestSuite struct {
+	suite.Suite
+	app             *Application
+	seatRepo        *mocks.MockSeatRepo
+	reservationRepo *mocks.MockReservationRepo
+	userRepo        *mocks.MockUserRepo
+	theaterRepo     *mocks.MockTheaterRepo
+	redisClient     *mocks.MockRedisClient
+	redisPipeline   *mocks.MockTxPipeline
+	userRole        domain.Role
+	theaterStaff    bool
+}
+
+func (s *SeatHoldTestSuite) SetupTest() {
+	s.seatRepo = new(mocks.MockSeatRepo)
+	s.reservationRepo = new(mocks.MockReservationRepo)
+	s.redisClient = new(mocks.MockRedisClient)
+	s.redisPipeline = new(mocks.MockTxPipeline)
+	s.userRole = domain.RoleKiosk
+	s.theaterStaff = false
+
+	s.userRepo = &mocks.MockUserRepo{
+		GetByIdFunc: func(ctx context.Context, id int) (*domain.User, error) {
+			return &domain.User{ID: id, Role: s.userRole}, nil
+		},
+	}
+	s.theaterRepo = &mocks.MockTheaterRepo{
+		IsTheaterStaffFunc: func(ctx context.Context, theaterID, userID int) (bool, error) {
+			return s.theaterStaff, nil
+		},
+	}
+
+	s.app = newTestApplication(func(a *Application) {
+		a.seatRepo = s.seatRepo
+		a.reservationRepo = s.reservationRepo
+		a.userRepo = s.userRepo
+		a.theaterRepo = s.theaterRepo
+		a.redis = s.redisClient
+	})
+}
+
+func (s *SeatHoldTestSuite) expectClaim(claimed int64) {
+	s.redisClient.On("EvalSha", mock.Anything, mock.Anything, []string{activeSeatHoldsKey(testKioskUserID)},
+		testHoldReference, mock.Anything, mock.Anything, maxActiveSeatHolds).
+		Return(redis.NewCmdResult(claimed, nil)).Once()
+}
+
+func TestSeatHoldSuite(t *testing.T) {
+	suite.Run(t, new(SeatHoldTestSuite))
+}
+
+func (s *SeatHoldTestSuite) storedHold(showtimeID int, seatIDs []int) *redis.StringCmd {
+	holdBytes, err := json.Marshal(domain.SeatHold{
+		Reference:  testHoldReference,
+		UserID:     testKioskUserID,
+		ShowtimeID: showtimeID,
+		SeatIDs:    seatIDs,
+	})
+	s.Require().NoError(err)
+
+	return redis.NewStringResult(string(holdBytes), nil)
+}
+
+func (s *SeatHoldTestSuite) TestCreateSeatHold() {
+	holdKey := seatHoldKey(testKioskUserID, testHoldReference)
+	lockKeys := []string{seatLockKey(1, 1), seatLockKey(1, 2), seatLockKey(1, 3)}
+
+	tests := []struct {
+		name           string
+		showtimeID     int
+		input          api.CreateSeatHoldRequest
+		setupMocks     func()
+		wantStatus     int
+		wantErrMessage string
+		wantErrCode    api.ErrorCode
+		wantTTL        time.Duration
+	}{
+		{
+			name:           "should fail when showtime ID is zero or negative",
+			showtimeID:     0,
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "showtime ID must be greater than zero",
+			wantErrCode:    api.BADREQUEST,
+		},
+		{
+			name:       "should fail when the hold reference is too short",
+			showtimeID: 1,
+			input: api.CreateSeatHoldRequest{
+				HoldReference: "abc",
+				SeatIdList:    testSeatIDs,
+			},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: fmt.Sprintf(validator.ErrMinLength, "6"),
+			wantErrCode:    api.VALIDATIONFAILED,
+		},
+		{
+			name:       "should fail when the reference is already used for other seats",
+			showtimeID: 1,
+			input: api.CreateSeatHoldRequest{
+				HoldReference: testHoldReference,
+				SeatIdList:    testSeatIDs,
+			},
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, holdKey).Return(s.storedHold(1, []int{4, 5})).Once()
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: errHoldReferenceConflict.Error(),
+			wantErrCode:    api.HOLDREFERENCECONFLICT,
+		},
+		{
+			name:       "should return the existing hold when the request is retried",
+			showtimeID: 1,
+			input: api.CreateSeatHoldRequest{
+				HoldReference: testHoldReference,
+				SeatIdList:    []int{3, 2, 1},
+			},
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, holdKey).Return(s.storedHold(1, testSeatIDs)).Once()
+				s.redisClient.On("Get", mock.Anything, seatLockKey(1, 1)).Return(redis.NewStringResult(holdKey, nil)).Once()
+				s.redisClient.On("PTTL", mock.Anything, seatLockKey(1, 1)).Return(redis.NewDurationResult(90*time.Second, nil)).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantTTL:    90 * time.Second,
+		},
+		{
+			name:       "should fail when a seat is already reserved",
+			showtimeID: 1,
+			input: api.CreateSeatHoldRequest{
+				HoldReference: testHoldReference,
+				SeatIdList:    testSeatIDs,
+			},
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, holdKey).Return(redis.NewStringResult("", redis.Nil)).Once()
+				s.reservationRepo.On("GetSeatsByShowtimeId", mock.Anything, 1).
+					Return([]domain.ReservationSeat{{ReservationID: 1, ShowtimeID: 1, SeatID: 2}}, nil)
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: errSeatsAlreadyReserved.Error(),
+			wantErrCode:    api.SEATALREADYRESERVED,
+		},
+		{
+			name:       "should fail when a seat is blocked",
+			showtimeID: 1,
+			input: api.CreateSeatHoldRequest{
+				HoldReference: testHoldReference,
+				SeatIdList:    testSeatIDs,
+			},
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, holdKey).Return(redis.NewStringResult("", redis.Nil)).Once()
+				s.reservationRepo.On("GetSeatsByShowtimeId", mock.Anything, 1).Return([]domain.ReservationSeat{}, nil)
+				s.seatRepo.On("GetSeatBlocksByShowtime", mock.Anything, 1).
+					Return([]domain.SeatBlock{{ID: 1, SeatID: 1, ShowtimeID: ptr(1), Reason: domain.SeatBlockBroken}}, nil)
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: errSeatsBlocked.Error(),
+			wantErrCode:    api.SEATBLOCKED,
+		},
+		{
+			name:       "should fail when a seat is locked by someone else",
+			showtimeID: 1,
+			input: api.CreateSeatHoldRequest{
+				HoldReference: testHoldReference,
+				SeatIdList:    testSeatIDs,
+			},
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, holdKey).Return(redis.NewStringResult("", redis.Nil)).Once()
+				s.reservationRepo.On("GetSeatsByShowtimeId", mock.Anything, 1).Return([]domain.ReservationSeat{}, nil)
+				s.seatRepo.On("GetSeatBlocksByShowtime", mock.Anything, 1).Return([]domain.SeatBlock{}, nil)
+				s.seatRepo.On("GetSeatsByShowtimeAndSeatIds", mock.Anything, 1, testSeatIDs).
+					Return(&domain.ShowtimeSeats{Seats: testSeats}, nil)
+				s.expectClaim(1)
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, lockKeys, holdKey, mock.Anything).
+					Return(redis.NewCmdResult(nil, mocks.MockRedisError{Msg: "seat already locked"})).Once()
+				s.redisClient.On("ZRem", mock.Anything, activeSeatHoldsKey(testKioskUserID), []interface{}{testHoldReference}).
+					Return(redis.NewIntResult(1, nil)).Once()
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: errSeatsAlreadyLocked.Error(),
+			wantErrCode:    api.SEATALREADYLOCKED,
+		},
+		{
+			name:       "should fail when the user is neither a kiosk nor staff of the theater",
+			showtimeID: 1,
+			input: api.CreateSeatHoldRequest{
+				HoldReference: testHoldReference,
+				SeatIdList:    testSeatIDs,
+			},
+			setupMocks: func() {
+				s.userRole = domain.RoleUser
+				s.redisClient.On("Get", mock.Anything, holdKey).Return(redis.NewStringResult("", redis.Nil)).Once()
+				s.reservationRepo.On("GetSeatsByShowtimeId", mock.Anything, 1).Return([]domain.ReservationSeat{}, nil)
+				s.seatRepo.On("GetSeatBlocksByShowtime", mock.Anything, 1).Return([]domain.SeatBlock{}, nil)
+				s.seatRepo.On("GetSeatsByShowtimeAndSeatIds", mock.Anything, 1, testSeatIDs).
+					Return(&domain.ShowtimeSeats{TheaterID: 1, Seats: testSeats}, nil)
+			},
+			wantStatus:     http.StatusForbidden,
+			wantErrMessage: ErrForbiddenAccess,
+			wantErrCode:    api.FORBIDDEN,
+		},
+		{
+			name:       "should fail when the account has too many holds",
+			showtimeID: 1,
+			input: api.CreateSeatHoldRequest{
+				HoldReference: testHoldReference,
+				SeatIdList:    testSeatIDs,
+			},
+			setupMocks: func() {
+				s.userRole = domain.RoleUser
+				s.theaterStaff = true
+				s.redisClient.On("Get", mock.Anything, holdKey).Return(redis.NewStringResult("", redis.Nil)).Once()
+				s.reservationRepo.On("GetSeatsByShowtimeId", mock.Anything, 1).Return([]domain.ReservationSeat{}, nil)
+				s.seatRepo.On("GetSeatBlocksByShowtime", mock.Anything, 1).Return([]domain.SeatBlock{}, nil)
+				s.seatRepo.On("GetSeatsByShowtimeAndSeatIds", mock.Anything, 1, testSeatIDs).
+					Return(&domain.ShowtimeSeats{TheaterID: 1, Seats: testSeats}, nil)
+				s.expectClaim(0)
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: errTooManySeatHolds.Error(),
+			wantErrCode:    api.TOOMANYHOLDS,
+		},
+		{
+			name:       "should release the seat locks when the hold can't be saved",
+			showtimeID: 1,
+			input: api.CreateSeatHoldRequest{
+				HoldReference: testHoldReference,
+				SeatIdList:    testSeatIDs,
+			},
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, holdKey).Return(redis.NewStringResult("", redis.Nil)).Once()
+				s.reservationRepo.On("GetSeatsByShowtimeId", mock.Anything, 1).Return([]domain.ReservationSeat{}, nil)
+				s.seatRepo.On("GetSeatBlocksByShowtime", mock.Anything, 1).Return([]domain.SeatBlock{}, nil)
+				s.seatRepo.On("GetSeatsByShowtimeAndSeatIds", mock.Anything, 1, testSeatIDs).
+					Return(&domain.ShowtimeSeats{Seats: testSeats}, nil)
+				s.expectClaim(1)
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, lockKeys, holdKey, mock.Anything).
+					Return(redis.NewCmdResult("OK", nil)).Once()
+
+				s.redisClient.On("TxPipeline").Return(s.redisPipeline)
+				s.redisPipeline.On("SAdd", mock.Anything, seatSetKey(1), []interface{}{1, 2, 3}).Return(redis.NewIntResult(3, nil))
+				s.redisPipeline.On("Set", mock.Anything, holdKey, mock.Anything, seatLockTTL).Return(redis.NewStatusResult("OK", nil))
+				s.redisPipeline.On("Exec", mock.Anything).Return(nil, fmt.Errorf("redis pipeline execution failed")).Once()
+
+				s.redisPipeline.On("Del", mock.Anything, lockKeys).Return(redis.NewIntResult(3, nil)).Once()
+				s.redisPipeline.On("SRem", mock.Anything, seatSetKey(1), []interface{}{1, 2, 3}).Return(redis.NewIntResult(3, nil)).Once()
+				s.redisPipeline.On("Exec", mock.Anything).Return([]redis.Cmder{}, nil).Once()
+				s.redisClient.On("ZRem", mock.Anything, activeSeatHoldsKey(testKioskUserID), []interface{}{testHoldReference}).
+					Return(redis.NewIntResult(1, nil)).Once()
+			},
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+			wantErrCode:    api.INTERNALERROR,
+		},
+		{
+			name:       "should hold the seats",
+			showtimeID: 1,
+			input: api.CreateSeatHoldRequest{
+				HoldReference: testHoldReference,
+				SeatIdList:    testSeatIDs,
+			},
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, holdKey).Return(redis.NewStringResult("", redis.Nil)).Once()
+				s.reservationRepo.On("GetSeatsByShowtimeId", mock.Anything, 1).Return([]domain.ReservationSeat{}, nil)
+				s.seatRepo.On("GetSeatBlocksByShowtime", mock.Anything, 1).Return([]domain.SeatBlock{}, nil)
+				s.seatRepo.On("GetSeatsByShowtimeAndSeatIds", mock.Anything, 1, testSeatIDs).
+					Return(&domain.ShowtimeSeats{Seats: testSeats}, nil)
+				s.expectClaim(1)
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, lockKeys, holdKey, int(seatLockTTL.Seconds())).
+					Return(redis.NewCmdResult("OK", nil)).Once()
+
+				s.redisClient.On("TxPipeline").Return(s.redisPipeline).Once()
+				s.redisPipeline.On("SAdd", mock.Anything, seatSetKey(1), []interface{}{1, 2, 3}).Return(redis.NewIntResult(3, nil)).Once()
+				s.redisPipeline.On("Set", mock.Anything, holdKey, mock.Anything, seatLockTTL).Return(redis.NewStatusResult("OK", nil)).Once()
+				s.redisPipeline.On("Exec", mock.Anything).Return([]redis.Cmder{}, nil).Once()
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatMapChangeKeys(1), seatEventsChannel(1), mock.Anything, mock.Anything).
+					Return(redis.NewCmdResult(int64(1), nil)).Once()
+
+				s.redisClient.On("Get", mock.Anything, seatLockKey(1, 1)).Return(redis.NewStringResult(holdKey, nil)).Once()
+				s.redisClient.On("PTTL", mock.Anything, seatLockKey(1, 1)).Return(redis.NewDurationResult(seatLockTTL, nil)).Once()
+			},
+			wantStatus: http.StatusCreated,
+			wantTTL:    seatLockTTL,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			w, r := executeRequest(s.T(), http.MethodPost, fmt.Sprintf("/showtimes/%d/holds", tt.showtimeID), tt.input)
+			r = r.WithContext(context.WithValue(r.Context(), SessionKeyUserId, testKioskUserID))
+
+			requestedAt := time.Now()
+			s.app.CreateSeatHold(w, r, tt.showtimeID)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantErrCode != "" {
+				checkErrorCode(s.T(), w, tt.wantErrCode)
+			}
+
+			if tt.wantTTL != 0 {
+				var response api.SeatHoldResponse
+				s.Require().NoError(json.NewDecoder(w.Body).Decode(&response))
+
+				s.Equal(testHoldReference, response.HoldReference)
+				s.Equal(tt.showtimeID, response.ShowtimeId)
+				s.ElementsMatch(testSeatIDs, response.SeatIds)
+				s.WithinDuration(requestedAt.Add(tt.wantTTL), response.ExpiresAt, time.Second)
+			}
+
+			checkErrorResponse(s.T(), w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+
+			s.redisClient.AssertExpectations(s.T())
+			s.redisPipeline.AssertExpectations(s.T())
+			s.reservationRepo.AssertExpectations(s.T())
+			s.seatRepo.AssertExpectations(s.T())
+		})
+	}
+}
+
+func (s *SeatHoldTestSuite) TestDeleteSeatHold() {
+	holdKey := seatHoldKey(testKioskUserID, testHoldReference)
+
+	tests := []struct {
+		name           string
+		showtimeID     int
+		setupMocks     func()
+		wantStatus     int
+		wantErrMessage string
+		wantErrCode    api.ErrorCode
+	}{
+		{
+			name:       "should fail when the hold doesn't exist",
+			showtimeID: 1,
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, holdKey).Return(redis.NewStringResult("", redis.Nil)).Once()
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: errSeatHoldNotFound.Error(),
+			wantErrCode:    api.HOLDNOTFOUND,
+		},
+		{
+			name:       "should fail when the hold is for another showtime",
+			showtimeID: 2,
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, holdKey).Return(s.storedHold(1, []int{1, 2})).Once()
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: errSeatHoldNotFound.Error(),
+			wantErrCode:    api.HOLDNOTFOUND,
+		},
+		{
+			name:       "should release only the locks the hold still owns",
+			showtimeID: 1,
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, holdKey).Return(s.storedHold(1, []int{1, 2})).Once()
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, []string{seatLockKey(1, 1), seatLockKey(1, 2)}, holdKey).
+					Return(redis.NewCmdResult([]interface{}{int64(1), int64(0)}, nil)).Once()
+
+				s.redisClient.On("TxPipeline").Return(s.redisPipeline).Once()
+				s.redisPipeline.On("SRem", mock.Anything, seatSetKey(1), []interface{}{1}).Return(redis.NewIntResult(1, nil)).Once()
+				s.redisPipeline.On("Del", mock.Anything, []string{holdKey}).Return(redis.NewIntResult(1, nil)).Once()
+				s.redisPipeline.On("ZRem", mock.Anything, activeSeatHoldsKey(testKioskUserID), []interface{}{testHoldReference}).
+					Return(redis.NewIntResult(1, nil)).Once()
+				s.redisPipeline.On("Exec", mock.Anything).Return([]redis.Cmder{}, nil).Once()
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatMapChangeKeys(1), seatEventsChannel(1), mock.Anything, mock.Anything).
+					Return(redis.NewCmdResult(int64(1), nil)).Once()
+			},
+			wantStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			url := fmt.Sprintf("/showtimes/%d/holds/%s", tt.showtimeID, testHoldReference)
+			w, r := executeRequest(s.T(), http.MethodDelete, url, nil)
+			r = r.WithContext(context.WithValue(r.Context(), SessionKeyUserId, testKioskUserID))
+
+			s.app.DeleteSeatHold(w, r, tt.showtimeID, testHoldReference)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantErrCode != "" {
+				checkErrorCode(s.T(), w, tt.wantErrCode)
+			}
+
+			checkErrorResponse(s.T(), w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+
+			s.redisClient.AssertExpectations(s.T())
+			s.redisPipeline.AssertExpectations(s.T())
+		})
+	}
+}
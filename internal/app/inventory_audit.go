@@ -0,0 +1,210 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/scheduler"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// the report of the last audit is kept until the next one replaces it
+const inventoryAuditReportKey = "inventory_audit:report"
+
+// GetInventoryAudit returns the report of the last inventory audit.
+func (app *Application) GetInventoryAudit(w http.ResponseWriter, r *http.Request) {
+	reportBytes, err := app.redis.Get(r.Context(), inventoryAuditReportKey).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			app.notFoundResponse(w, r)
+			return
+		}
+
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	var report domain.InventoryAuditReport
+
+	err = json.Unmarshal(reportBytes, &report)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, toApiInventoryAuditReport(report), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// auditInventory is the background job which cross-checks the sold seats of the upcoming showtimes with
+// their halls, payments and seat locks. Anomalies are reported, nothing is repaired.
+func (app *Application) auditInventory(ctx context.Context) error {
+	now := time.Now()
+
+	report := domain.InventoryAuditReport{
+		CheckedAt: now,
+		From:      now,
+		To:        now.Add(app.config.InventoryAudit.Horizon),
+	}
+
+	anomalies, err := app.reservationRepo.GetInventoryAnomalies(ctx, report.From, report.To)
+	if err != nil {
+		return err
+	}
+
+	reservedSeats, err := app.reservationRepo.GetReservedSeatsByShowtime(ctx, report.From, report.To)
+	if err != nil {
+		return err
+	}
+
+	report.ShowtimeCount = len(reservedSeats)
+	report.Anomalies = anomalies
+
+	showtimeIds := slices.Sorted(maps.Keys(reservedSeats))
+
+	for _, showtimeId := range showtimeIds {
+		orphanLocks, err := app.orphanSeatLocks(ctx, showtimeId, reservedSeats[showtimeId])
+		if err != nil {
+			return err
+		}
+
+		report.Anomalies = append(report.Anomalies, orphanLocks...)
+	}
+
+	reportBytes, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	if err := scheduler.CheckFence(ctx); err != nil {
+		return err
+	}
+
+	err = app.redis.Set(ctx, inventoryAuditReportKey, reportBytes, 0).Err()
+	if err != nil {
+		return err
+	}
+
+	app.recordInventoryAnomalies(ctx, report)
+
+	for kind, count := range report.CountByKind() {
+		if count > 0 {
+			app.logger.Warn("inventory audit found anomalies", "kind", kind, "count", count)
+		}
+	}
+
+	return nil
+}
+
+// orphanSeatLocks finds the locks of the showtime which are never released: locks of sold seats and locks
+// without an expiry. Seats listed as locked whose lock expired are not reported, they are dropped when
+// the seat map is read.
+func (app *Application) orphanSeatLocks(ctx context.Context, showtimeId int, reservedSeatIds []int) ([]domain.InventoryAnomaly, error) {
+	members, err := app.redis.SMembers(ctx, seatSetKey(showtimeId)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	reserved := make(map[int]bool, len(reservedSeatIds))
+	for _, seatId := range reservedSeatIds {
+		reserved[seatId] = true
+	}
+
+	var anomalies []domain.InventoryAnomaly
+
+	for _, member := range members {
+		seatId, err := strconv.Atoi(member)
+		if err != nil {
+			return nil, fmt.Errorf("invalid seat id %q in the locks of showtime %d: %w", member, showtimeId, err)
+		}
+
+		ttl, err := app.redis.PTTL(ctx, seatLockKey(showtimeId, seatId)).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		// the lock is gone
+		if ttl == -2 {
+			continue
+		}
+
+		switch {
+		case reserved[seatId]:
+			anomalies = append(anomalies, domain.InventoryAnomaly{
+				Kind:       domain.AnomalyOrphanLock,
+				ShowtimeID: showtimeId,
+				SeatID:     seatId,
+				Detail:     "sold seat is still locked",
+			})
+		case ttl == -1:
+			anomalies = append(anomalies, domain.InventoryAnomaly{
+				Kind:       domain.AnomalyOrphanLock,
+				ShowtimeID: showtimeId,
+				SeatID:     seatId,
+				Detail:     "seat lock never expires",
+			})
+		}
+	}
+
+	return anomalies, nil
+}
+
+// recordInventoryAnomalies exports the number of anomalies of every kind found by the last audit.
+func (app *Application) recordInventoryAnomalies(ctx context.Context, report domain.InventoryAuditReport) {
+	meter := otel.Meter("github.com/metinatakli/movie-reservation-system/internal/app")
+
+	gauge, err := meter.Int64Gauge(
+		"inventory.audit.anomalies",
+		metric.WithDescription("Number of anomalies found by the last inventory audit by kind"),
+	)
+	if err != nil {
+		app.logger.Error("failed to create inventory audit metric", "error", err)
+		return
+	}
+
+	for kind, count := range report.CountByKind() {
+		gauge.Record(ctx, int64(count), metric.WithAttributes(attribute.String("kind", string(kind))))
+	}
+}
+
+func toApiInventoryAuditReport(report domain.InventoryAuditReport) api.InventoryAuditReport {
+	resp := api.InventoryAuditReport{
+		CheckedAt:     report.CheckedAt,
+		From:          report.From,
+		To:            report.To,
+		ShowtimeCount: report.ShowtimeCount,
+		Anomalies:     make([]api.InventoryAnomaly, len(report.Anomalies)),
+	}
+
+	for i, anomaly := range report.Anomalies {
+		resp.Anomalies[i] = api.InventoryAnomaly{
+			Kind:       api.InventoryAnomalyKind(anomaly.Kind),
+			ShowtimeId: anomaly.ShowtimeID,
+			Detail:     anomaly.Detail,
+		}
+
+		if anomaly.SeatID != 0 {
+			resp.Anomalies[i].SeatId = &anomaly.SeatID
+		}
+
+		if anomaly.ReservationID != 0 {
+			resp.Anomalies[i].ReservationId = &anomaly.ReservationID
+		}
+	}
+
+	return resp
+}
@@ -0,0 +1,181 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type InventoryAuditTestSuite struct {
+	suite.Suite
+	app             *Application
+	reservationRepo *mocks.MockReservationRepo
+	redisClient     *mocks.MockRedisClient
+}
+
+func (s *InventoryAuditTestSuite) SetupTest() {
+	s.reservationRepo = new(mocks.MockReservationRepo)
+	s.redisClient = new(mocks.MockRedisClient)
+
+	s.app = newTestApplication(func(a *Application) {
+		a.reservationRepo = s.reservationRepo
+		a.redis = s.redisClient
+		a.config.InventoryAudit.Horizon = 7 * 24 * time.Hour
+	})
+}
+
+func TestInventoryAuditSuite(t *testing.T) {
+	suite.Run(t, new(InventoryAuditTestSuite))
+}
+
+func (s *InventoryAuditTestSuite) TestAuditInventory() {
+	s.reservationRepo.On("GetInventoryAnomalies", mock.Anything, mock.Anything, mock.Anything).Return([]domain.InventoryAnomaly{
+		{Kind: domain.AnomalyUnpaidReservation, ShowtimeID: 1, ReservationID: 5, Detail: "payment is pending"},
+	}, nil)
+	s.reservationRepo.On("GetReservedSeatsByShowtime", mock.Anything, mock.Anything, mock.Anything).Return(map[int][]int{
+		1: {1, 2},
+		2: {},
+	}, nil)
+
+	s.redisClient.On("SMembers", mock.Anything, seatSetKey(1)).Return(redis.NewStringSliceResult([]string{"2", "3", "4"}, nil))
+	s.redisClient.On("SMembers", mock.Anything, seatSetKey(2)).Return(redis.NewStringSliceResult([]string{}, nil))
+
+	// the sold seat is still locked, seat 3 is locked for a checkout and the lock of seat 4 expired
+	s.redisClient.On("PTTL", mock.Anything, seatLockKey(1, 2)).Return(redis.NewDurationResult(time.Minute, nil))
+	s.redisClient.On("PTTL", mock.Anything, seatLockKey(1, 3)).Return(redis.NewDurationResult(time.Minute, nil))
+	s.redisClient.On("PTTL", mock.Anything, seatLockKey(1, 4)).Return(redis.NewDurationResult(-2, nil))
+
+	var saved domain.InventoryAuditReport
+
+	s.redisClient.On("Set", mock.Anything, inventoryAuditReportKey, mock.MatchedBy(func(value []byte) bool {
+		return json.Unmarshal(value, &saved) == nil
+	}), time.Duration(0)).Return(redis.NewStatusResult("OK", nil))
+
+	err := s.app.auditInventory(context.Background())
+	s.Require().NoError(err)
+
+	s.Equal(2, saved.ShowtimeCount)
+	s.Equal([]domain.InventoryAnomaly{
+		{Kind: domain.AnomalyUnpaidReservation, ShowtimeID: 1, ReservationID: 5, Detail: "payment is pending"},
+		{Kind: domain.AnomalyOrphanLock, ShowtimeID: 1, SeatID: 2, Detail: "sold seat is still locked"},
+	}, saved.Anomalies)
+
+	s.redisClient.AssertExpectations(s.T())
+}
+
+func (s *InventoryAuditTestSuite) TestAuditInventoryReportsLocksWithoutExpiry() {
+	s.reservationRepo.On("GetInventoryAnomalies", mock.Anything, mock.Anything, mock.Anything).Return([]domain.InventoryAnomaly{}, nil)
+	s.reservationRepo.On("GetReservedSeatsByShowtime", mock.Anything, mock.Anything, mock.Anything).Return(map[int][]int{1: {}}, nil)
+
+	s.redisClient.On("SMembers", mock.Anything, seatSetKey(1)).Return(redis.NewStringSliceResult([]string{"7"}, nil))
+	s.redisClient.On("PTTL", mock.Anything, seatLockKey(1, 7)).Return(redis.NewDurationResult(-1, nil))
+
+	var saved domain.InventoryAuditReport
+
+	s.redisClient.On("Set", mock.Anything, inventoryAuditReportKey, mock.MatchedBy(func(value []byte) bool {
+		return json.Unmarshal(value, &saved) == nil
+	}), time.Duration(0)).Return(redis.NewStatusResult("OK", nil))
+
+	err := s.app.auditInventory(context.Background())
+	s.Require().NoError(err)
+
+	s.Equal([]domain.InventoryAnomaly{
+		{Kind: domain.AnomalyOrphanLock, ShowtimeID: 1, SeatID: 7, Detail: "seat lock never expires"},
+	}, saved.Anomalies)
+}
+
+func (s *InventoryAuditTestSuite) TestAuditInventoryFailsWhenDatabaseFails() {
+	s.reservationRepo.On("GetInventoryAnomalies", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("database error"))
+
+	err := s.app.auditInventory(context.Background())
+	s.Error(err)
+
+	s.redisClient.AssertNotCalled(s.T(), "Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *InventoryAuditTestSuite) TestGetInventoryAudit() {
+	seatId := 2
+
+	tests := []struct {
+		name           string
+		setupMocks     func()
+		wantStatus     int
+		wantErrMessage string
+		wantResponse   *api.InventoryAuditReport
+	}{
+		{
+			name: "should return not found when no audit has run yet",
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, inventoryAuditReportKey).Return(redis.NewStringResult("", redis.Nil))
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+		},
+		{
+			name: "should fail when redis fails",
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, inventoryAuditReportKey).Return(redis.NewStringResult("", errors.New("redis error")))
+			},
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+		{
+			name: "should return the report of the last audit",
+			setupMocks: func() {
+				report, _ := json.Marshal(domain.InventoryAuditReport{
+					ShowtimeCount: 3,
+					Anomalies: []domain.InventoryAnomaly{
+						{Kind: domain.AnomalyOrphanLock, ShowtimeID: 1, SeatID: 2, Detail: "sold seat is still locked"},
+					},
+				})
+
+				s.redisClient.On("Get", mock.Anything, inventoryAuditReportKey).Return(redis.NewStringResult(string(report), nil))
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: &api.InventoryAuditReport{
+				ShowtimeCount: 3,
+				Anomalies: []api.InventoryAnomaly{
+					{Kind: api.OrphanLock, ShowtimeId: 1, SeatId: &seatId, Detail: "sold seat is still locked"},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+			tt.setupMocks()
+
+			w, r := executeRequest(s.T(), http.MethodGet, "/admin/inventory-audit", nil)
+			s.app.GetInventoryAudit(w, r)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantResponse != nil {
+				var response api.InventoryAuditReport
+				s.Require().NoError(json.NewDecoder(w.Body).Decode(&response))
+
+				s.Equal(tt.wantResponse.ShowtimeCount, response.ShowtimeCount)
+				s.Equal(tt.wantResponse.Anomalies, response.Anomalies)
+			}
+
+			checkErrorResponse(s.T(), w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mailer"
+	"github.com/metinatakli/movie-reservation-system/internal/scheduler"
+)
+
+func (app *Application) backgroundJobs() []scheduler.Job {
+	return []scheduler.Job{
+		{
+			Name:     "activation_reminder",
+			Interval: app.config.Jobs.Interval,
+			Run:      app.sendActivationReminders,
+		},
+		{
+			Name:     "unactivated_account_purge",
+			Interval: app.config.Jobs.Interval,
+			Run:      app.purgeUnactivatedAccounts,
+		},
+		{
+			Name:     "unpaid_venue_reservation_expiry",
+			Interval: app.config.Jobs.Interval,
+			Run:      app.cancelUnpaidVenueReservations,
+		},
+		{
+			Name:     "no_show_release",
+			Interval: app.config.Jobs.Interval,
+			Run:      app.releaseNoShows,
+		},
+		{
+			Name:     "announcement_delivery",
+			Interval: app.config.Jobs.Interval,
+			Run:      app.deliverAnnouncements,
+		},
+		{
+			Name:     "notification_digest",
+			Interval: app.config.Jobs.Interval,
+			Run:      app.sendNotificationDigests,
+		},
+		{
+			Name:     "email_campaign_dispatch",
+			Interval: app.config.Jobs.Interval,
+			Run:      app.dispatchEmailCampaigns,
+		},
+		{
+			Name:     "reservation_fulfillment_retry",
+			Interval: app.config.Jobs.Interval,
+			Run:      app.retryPendingFulfillments,
+		},
+		{
+			Name:     "pending_refund_retry",
+			Interval: app.config.Jobs.Interval,
+			Run:      app.sendPendingRefunds,
+		},
+		{
+			Name:     "data_retention",
+			Interval: app.config.Retention.Interval,
+			Run:      app.enforceDataRetention,
+		},
+		{
+			Name:     "inventory_audit",
+			Interval: app.config.InventoryAudit.Interval,
+			Run:      app.auditInventory,
+		},
+		{
+			Name:     "payment_reconciliation",
+			Interval: app.config.Reconciliation.Interval,
+			Run:      app.reconcilePayments,
+		},
+		{
+			Name:     "crm_event_delivery",
+			Interval: app.config.Jobs.Interval,
+			Run:      app.deliverLifecycleEvents,
+		},
+		{
+			Name:     "block_hold_expiry",
+			Interval: app.config.Jobs.Interval,
+			Run:      app.expireBlockHolds,
+		},
+	}
+}
+
+// sendActivationReminders sends a single reminder to users whose activation token is about to expire.
+// Only token hashes are stored, so every reminder carries a freshly issued token which replaces the old one.
+func (app *Application) sendActivationReminders(ctx context.Context) error {
+	expiresBefore := time.Now().Add(app.config.Jobs.ActivationReminderWindow)
+
+	users, err := app.userRepo.GetPendingActivationReminders(ctx, expiresBefore)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		if err := scheduler.CheckFence(ctx); err != nil {
+			return err
+		}
+
+		token, err := domain.GenerateToken(int64(user.ID), activationTokenTTL, domain.UserActivationScope)
+		if err != nil {
+			return err
+		}
+
+		err = app.tokenRepo.Create(ctx, token)
+		if err != nil {
+			return err
+		}
+
+		data := mailer.ActivationReminderEmail{
+			ActivationToken: token.Plaintext,
+			UserID:          user.ID,
+			FirstName:       user.FirstName,
+		}
+
+		err = app.mailer.Send(ctx, user.Email, data)
+		if err != nil {
+			app.logger.Error("failed to send activation reminder email", "userId", user.ID, "error", err)
+			continue
+		}
+
+		err = app.userRepo.MarkActivationReminderSent(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// purgeUnactivatedAccounts deletes accounts that were never activated within the configured period
+// so that their email addresses can be registered again.
+func (app *Application) purgeUnactivatedAccounts(ctx context.Context) error {
+	cutoff := time.Now().Add(-app.config.Jobs.UnactivatedAccountTTL)
+
+	if err := scheduler.CheckFence(ctx); err != nil {
+		return err
+	}
+
+	deleted, err := app.userRepo.DeleteUnactivatedCreatedBefore(ctx, cutoff)
+	if err != nil {
+		return err
+	}
+
+	if deleted > 0 {
+		app.logger.Info("purged unactivated accounts", "count", deleted)
+	}
+
+	return nil
+}
+
+// cancelUnpaidVenueReservations cancels pay-at-venue reservations which are still unpaid shortly before
+// the showtime starts, so that their seats can be sold again.
+func (app *Application) cancelUnpaidVenueReservations(ctx context.Context) error {
+	startsBefore := time.Now().Add(app.config.Jobs.VenuePaymentCutoff)
+
+	if err := scheduler.CheckFence(ctx); err != nil {
+		return err
+	}
+
+	reservations, err := app.reservationRepo.CancelUnpaidVenueReservations(ctx, startsBefore)
+	if err != nil {
+		return err
+	}
+
+	for _, reservation := range reservations {
+		seatIDs := make([]int, len(reservation.ReservationSeats))
+		for i, seat := range reservation.ReservationSeats {
+			seatIDs[i] = seat.SeatID
+		}
+
+		app.publishSeatEvent(ctx, reservation.ShowtimeID, seatEventReleased, seatIDs)
+
+		app.logger.Info("cancelled unpaid pay-at-venue reservation",
+			"reservation_id", reservation.ID,
+			"showtime_id", reservation.ShowtimeID,
+			"seat_count", len(seatIDs))
+	}
+
+	return nil
+}
+
+// showtimes which started longer ago are left alone by the no-show tracking, so that reservations made
+// before check-ins were recorded aren't taken for no-shows
+const noShowLookback = 24 * time.Hour
+
+// releaseNoShows marks the reservations whose guests didn't check in before the check-in of the showtime
+// closed as no-shows. Unclaimed comp and pay-at-venue reservations are cancelled, their seats can be held
+// by the kiosks and sold at the door.
+func (app *Application) releaseNoShows(ctx context.Context) error {
+	now := time.Now()
+
+	if err := scheduler.CheckFence(ctx); err != nil {
+		return err
+	}
+
+	reservations, err := app.reservationRepo.MarkNoShows(ctx, now, now.Add(-noShowLookback))
+	if err != nil {
+		return err
+	}
+
+	for _, reservation := range reservations {
+		if reservation.Status != domain.ReservationCancelled {
+			continue
+		}
+
+		seatIDs := make([]int, len(reservation.ReservationSeats))
+		for i, seat := range reservation.ReservationSeats {
+			seatIDs[i] = seat.SeatID
+		}
+
+		if len(seatIDs) > 0 {
+			app.publishSeatEvent(ctx, reservation.ShowtimeID, seatEventReleased, seatIDs)
+		}
+
+		app.logger.Info("released the seats of a no-show reservation",
+			"reservation_id", reservation.ID,
+			"showtime_id", reservation.ShowtimeID,
+			"seat_count", len(seatIDs))
+	}
+
+	if len(reservations) > 0 {
+		app.logger.Info("marked no-show reservations", "count", len(reservations))
+	}
+
+	return nil
+}
@@ -0,0 +1,214 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSendActivationReminders(t *testing.T) {
+	tests := []struct {
+		name         string
+		users        []*domain.User
+		getErr       error
+		mailerErr    error
+		wantErr      bool
+		wantSent     int
+		wantReminded []int
+	}{
+		{
+			name:    "fails when pending users cannot be fetched",
+			getErr:  errors.New("db error"),
+			wantErr: true,
+		},
+		{
+			name: "no pending users",
+		},
+		{
+			name: "sends reminder with a fresh token and marks users as reminded",
+			users: []*domain.User{
+				{ID: 1, FirstName: "Freddie", Email: "freddie@example.com"},
+				{ID: 2, FirstName: "Brian", Email: "brian@example.com"},
+			},
+			wantSent:     2,
+			wantReminded: []int{1, 2},
+		},
+		{
+			name: "does not mark users as reminded when mail fails",
+			users: []*domain.User{
+				{ID: 1, FirstName: "Freddie", Email: "freddie@example.com"},
+			},
+			mailerErr: errors.New("smtp error"),
+			wantSent:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var createdTokens []*domain.Token
+			var reminded []int
+			sent := 0
+
+			app := newTestApplication(func(a *Application) {
+				a.config.Jobs.ActivationReminderWindow = 3 * time.Minute
+				a.userRepo = &mocks.MockUserRepo{
+					GetPendingActivationRemindersFunc: func(ctx context.Context, expiresBefore time.Time) ([]*domain.User, error) {
+						return tt.users, tt.getErr
+					},
+					MarkActivationReminderSentFunc: func(ctx context.Context, userID int) error {
+						reminded = append(reminded, userID)
+						return nil
+					},
+				}
+				a.tokenRepo = &mocks.MockTokenRepo{
+					CreateFunc: func(ctx context.Context, token *domain.Token) error {
+						createdTokens = append(createdTokens, token)
+						return nil
+					},
+				}
+				a.mailer = &MockMailer{sendFunc: func(recipient, template string, data any) error {
+					sent++
+
+					if template != "user_activation_reminder.tmpl" {
+						t.Errorf("unexpected template %q", template)
+					}
+
+					return tt.mailerErr
+				}}
+			})
+
+			err := app.sendActivationReminders(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sendActivationReminders() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if sent != tt.wantSent {
+				t.Errorf("sent emails = %d, want %d", sent, tt.wantSent)
+			}
+
+			if len(createdTokens) != len(tt.users) {
+				t.Errorf("created tokens = %d, want %d", len(createdTokens), len(tt.users))
+			}
+
+			for _, token := range createdTokens {
+				if token.Scope != domain.UserActivationScope {
+					t.Errorf("token scope = %q, want %q", token.Scope, domain.UserActivationScope)
+				}
+			}
+
+			if len(reminded) != len(tt.wantReminded) {
+				t.Fatalf("reminded users = %v, want %v", reminded, tt.wantReminded)
+			}
+
+			for i := range reminded {
+				if reminded[i] != tt.wantReminded[i] {
+					t.Errorf("reminded users = %v, want %v", reminded, tt.wantReminded)
+				}
+			}
+		})
+	}
+}
+
+func TestPurgeUnactivatedAccounts(t *testing.T) {
+	var gotCutoff time.Time
+
+	app := newTestApplication(func(a *Application) {
+		a.config.Jobs.UnactivatedAccountTTL = 24 * time.Hour
+		a.userRepo = &mocks.MockUserRepo{
+			DeleteUnactivatedCreatedBeforeFunc: func(ctx context.Context, cutoff time.Time) (int64, error) {
+				gotCutoff = cutoff
+				return 3, nil
+			},
+		}
+	})
+
+	err := app.purgeUnactivatedAccounts(context.Background())
+	if err != nil {
+		t.Fatalf("purgeUnactivatedAccounts() error = %v", err)
+	}
+
+	wantCutoff := time.Now().Add(-24 * time.Hour)
+	if diff := wantCutoff.Sub(gotCutoff); diff < 0 || diff > time.Minute {
+		t.Errorf("cutoff = %v, want about %v", gotCutoff, wantCutoff)
+	}
+}
+
+func TestCancelUnpaidVenueReservations(t *testing.T) {
+	reservationRepo := new(mocks.MockReservationRepo)
+	redisClient := new(mocks.MockRedisClient)
+
+	app := newTestApplication(func(a *Application) {
+		a.config.Jobs.VenuePaymentCutoff = 30 * time.Minute
+		a.reservationRepo = reservationRepo
+		a.redis = redisClient
+	})
+
+	reservationRepo.On("CancelUnpaidVenueReservations", mock.Anything, mock.MatchedBy(func(startsBefore time.Time) bool {
+		return startsBefore.Sub(time.Now().Add(30*time.Minute)).Abs() < time.Minute
+	})).Return([]domain.Reservation{
+		{
+			ID:         1,
+			ShowtimeID: 5,
+			Status:     domain.ReservationCancelled,
+			ReservationSeats: []domain.ReservationSeat{
+				{ReservationID: 1, ShowtimeID: 5, SeatID: 10},
+				{ReservationID: 1, ShowtimeID: 5, SeatID: 11},
+			},
+		},
+	}, nil)
+
+	redisClient.On("EvalSha", mock.Anything, mock.Anything, seatMapChangeKeys(5), seatEventsChannel(5), mock.MatchedBy(func(payload []byte) bool {
+		return string(payload) == `{"type":"released","seatIds":[10,11]}`
+	}), mock.Anything).Return(redis.NewCmdResult(int64(1), nil)).Once()
+
+	err := app.cancelUnpaidVenueReservations(context.Background())
+	if err != nil {
+		t.Fatalf("cancelUnpaidVenueReservations() error = %v", err)
+	}
+
+	reservationRepo.AssertExpectations(t)
+	redisClient.AssertExpectations(t)
+}
+
+func TestReleaseNoShows(t *testing.T) {
+	reservationRepo := new(mocks.MockReservationRepo)
+	redisClient := new(mocks.MockRedisClient)
+
+	app := newTestApplication(func(a *Application) {
+		a.reservationRepo = reservationRepo
+		a.redis = redisClient
+	})
+
+	reservationRepo.On("MarkNoShows", mock.Anything, mock.Anything, mock.MatchedBy(func(startedAfter time.Time) bool {
+		return startedAfter.Sub(time.Now().Add(-noShowLookback)).Abs() < time.Minute
+	})).Return([]domain.Reservation{
+		// a paid reservation keeps its seats
+		{ID: 1, ShowtimeID: 5, Status: domain.ReservationConfirmed},
+		{
+			ID:         2,
+			ShowtimeID: 5,
+			Status:     domain.ReservationCancelled,
+			ReservationSeats: []domain.ReservationSeat{
+				{ReservationID: 2, ShowtimeID: 5, SeatID: 12},
+			},
+		},
+	}, nil)
+
+	redisClient.On("EvalSha", mock.Anything, mock.Anything, seatMapChangeKeys(5), seatEventsChannel(5), mock.MatchedBy(func(payload []byte) bool {
+		return string(payload) == `{"type":"released","seatIds":[12]}`
+	}), mock.Anything).Return(redis.NewCmdResult(int64(1), nil)).Once()
+
+	err := app.releaseNoShows(context.Background())
+	if err != nil {
+		t.Fatalf("releaseNoShows() error = %v", err)
+	}
+
+	reservationRepo.AssertExpectations(t)
+	redisClient.AssertExpectations(t)
+}
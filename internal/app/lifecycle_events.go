@@ -0,0 +1,57 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/scheduler"
+)
+
+// deliverLifecycleEvents posts the due lifecycle events to the CRM. Failed deliveries are retried with a
+// growing backoff until the attempts run out, the event is dead lettered then. Events are kept while no
+// CRM is configured.
+func (app *Application) deliverLifecycleEvents(ctx context.Context) error {
+	if app.crm == nil {
+		return nil
+	}
+
+	due, err := app.lifecycleEventRepo.Due(ctx, time.Now(), app.config.CRM.BatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, event := range due {
+		if err := scheduler.CheckFence(ctx); err != nil {
+			return err
+		}
+
+		err := app.deliverLifecycleEvent(ctx, event)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deliverLifecycleEvent makes a single attempt. Only errors of the repository are returned.
+func (app *Application) deliverLifecycleEvent(ctx context.Context, event domain.LifecycleEvent) error {
+	logger := app.logger.With("event_id", event.ID, "event_type", event.Type, "attempt", event.Attempts+1)
+
+	err := app.crm.Deliver(ctx, event)
+	if err != nil {
+		event.Failed(err, time.Now(), app.config.CRM.MaxAttempts)
+
+		if event.DeadLettered() {
+			logger.Error("giving up delivering a lifecycle event to the CRM", "error", err)
+		} else {
+			logger.Warn("failed to deliver a lifecycle event to the CRM", "error", err,
+				"next_attempt_at", event.NextAttemptAt)
+		}
+
+		return app.lifecycleEventRepo.RecordFailure(ctx, event)
+	}
+
+	return app.lifecycleEventRepo.MarkDelivered(ctx, event.ID, time.Now())
+}
@@ -0,0 +1,96 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/stretchr/testify/mock"
+)
+
+type fakeLifecycleEventTarget struct {
+	delivered []int
+	err       error
+}
+
+func (f *fakeLifecycleEventTarget) Deliver(ctx context.Context, event domain.LifecycleEvent) error {
+	if f.err != nil {
+		return f.err
+	}
+
+	f.delivered = append(f.delivered, event.ID)
+	return nil
+}
+
+func TestDeliverLifecycleEvents(t *testing.T) {
+	events := []domain.LifecycleEvent{
+		{ID: 1, Type: domain.EventUserRegistered, UserID: 7},
+		{ID: 2, Type: domain.EventUserActivated, UserID: 7, Attempts: 4},
+	}
+
+	t.Run("events are kept while no CRM is configured", func(t *testing.T) {
+		repo := &mocks.MockLifecycleEventRepo{}
+
+		app := newTestApplication(func(a *Application) {
+			a.lifecycleEventRepo = repo
+		})
+
+		if err := app.deliverLifecycleEvents(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		repo.AssertNotCalled(t, "Due", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("delivered events are marked", func(t *testing.T) {
+		repo := &mocks.MockLifecycleEventRepo{}
+		repo.On("Due", mock.Anything, mock.Anything, 100).Return(events, nil)
+		repo.On("MarkDelivered", mock.Anything, 1, mock.Anything).Return(nil)
+		repo.On("MarkDelivered", mock.Anything, 2, mock.Anything).Return(nil)
+
+		target := &fakeLifecycleEventTarget{}
+
+		app := newTestApplication(func(a *Application) {
+			a.config.CRM.BatchSize = 100
+			a.config.CRM.MaxAttempts = 5
+			a.lifecycleEventRepo = repo
+			a.crm = target
+		})
+
+		if err := app.deliverLifecycleEvents(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(target.delivered) != 2 {
+			t.Errorf("delivered = %v, want both events", target.delivered)
+		}
+
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("failed events are retried until they are dead lettered", func(t *testing.T) {
+		repo := &mocks.MockLifecycleEventRepo{}
+		repo.On("Due", mock.Anything, mock.Anything, 100).Return(events, nil)
+		repo.On("RecordFailure", mock.Anything, mock.MatchedBy(func(e domain.LifecycleEvent) bool {
+			return e.ID == 1 && e.Attempts == 1 && e.NextAttemptAt != nil && e.LastError == "crm is down"
+		})).Return(nil).Once()
+		repo.On("RecordFailure", mock.Anything, mock.MatchedBy(func(e domain.LifecycleEvent) bool {
+			return e.ID == 2 && e.Attempts == 5 && e.DeadLettered()
+		})).Return(nil).Once()
+
+		app := newTestApplication(func(a *Application) {
+			a.config.CRM.BatchSize = 100
+			a.config.CRM.MaxAttempts = 5
+			a.lifecycleEventRepo = repo
+			a.crm = &fakeLifecycleEventTarget{err: errors.New("crm is down")}
+		})
+
+		if err := app.deliverLifecycleEvents(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		repo.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,165 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mailer"
+)
+
+const magicLinkTokenTTL = 15 * time.Minute
+
+var (
+	// limits how often a mailbox can be flooded with login links
+	magicLinkEmailLimit  = rateLimit{name: "magic_link_email", limit: 3, window: 15 * time.Minute}
+	magicLinkClientLimit = rateLimit{name: "magic_link_client", limit: 10, window: 15 * time.Minute}
+)
+
+func (app *Application) RequestMagicLink(w http.ResponseWriter, r *http.Request) {
+	var input api.MagicLinkRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	email := strings.ToLower(input.Email)
+
+	if !app.allowRequest(w, r, magicLinkClientLimit, clientIP(r)) ||
+		!app.allowRequest(w, r, magicLinkEmailLimit, email) {
+		return
+	}
+
+	// the account is looked up in the background, so neither the response nor its timing tells whether
+	// the address is registered
+	go app.sendMagicLink(context.WithoutCancel(r.Context()), app.contextGetLogger(r), email)
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (app *Application) sendMagicLink(ctx context.Context, logger *slog.Logger, email string) {
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Error("panic occurred during sending magic link mail", "panic", err)
+		}
+	}()
+
+	user, err := app.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		if !errors.Is(err, domain.ErrRecordNotFound) {
+			logger.Error("failed to get user by email for magic link", "error", err)
+		}
+
+		return
+	}
+
+	if !user.Activated {
+		logger.Warn("magic link requested for unactivated user", "userId", user.ID)
+		return
+	}
+
+	token, err := domain.GenerateToken(int64(user.ID), magicLinkTokenTTL, domain.MagicLinkScope)
+	if err != nil {
+		logger.Error("failed to generate magic link token", "error", err)
+		return
+	}
+
+	// a user has a single token per scope, so requesting a new link invalidates the previous one
+	err = app.tokenRepo.Create(ctx, token)
+	if err != nil {
+		logger.Error("failed to store magic link token", "error", err)
+		return
+	}
+
+	data := mailer.MagicLinkEmail{
+		LoginToken: token.Plaintext,
+		FirstName:  user.FirstName,
+		TTLMinutes: int(magicLinkTokenTTL.Minutes()),
+	}
+
+	err = app.mailer.Send(ctx, user.Email, data)
+	if err != nil {
+		logger.Error("failed to send magic link email", "userId", user.ID, "error", err)
+		return
+	}
+
+	logger.Info("magic link email sent successfully", "userId", user.ID)
+}
+
+func (app *Application) CompleteMagicLinkLogin(w http.ResponseWriter, r *http.Request) {
+	logger := app.contextGetLogger(r)
+
+	userId := app.sessionManager.GetInt(r.Context(), SessionKeyUserId.String())
+	if userId != 0 {
+		resp := api.AlreadyLoggedInResponse{
+			Message: "You are already logged in",
+		}
+
+		err := app.writeJSON(w, http.StatusOK, resp, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	var input api.MagicLinkLoginRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	if !app.allowRequest(w, r, magicLinkClientLimit, clientIP(r)) {
+		return
+	}
+
+	hash := sha256.Sum256([]byte(input.Token))
+	userId, err = app.tokenRepo.Consume(r.Context(), hash[:], domain.MagicLinkScope)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			logger.Warn("login attempt with invalid magic link")
+			app.errorResponseWithCode(w, r, http.StatusUnauthorized, api.INVALIDMAGICLINK, ErrInvalidMagicLink)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.logIn(r, userId, input.RememberMe != nil && *input.RememberMe)
+	if err != nil {
+		switch {
+		case errors.Is(err, errSessionStoreUnavailable):
+			app.sessionStoreUnavailableResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,326 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mailer"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/metinatakli/movie-reservation-system/internal/validator"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/mock"
+)
+
+const testMagicLinkToken = "Xq3kR9vT1mB7nC2pL8wE5yH4jF6gD0sA1zU3iO7aK2c"
+
+func allowRateLimit(redisClient *mocks.MockRedisClient, key string, retryAfter int64) {
+	redisClient.On("EvalSha", mock.Anything, mock.Anything, []string{key}, mock.Anything, mock.Anything).
+		Return(redis.NewCmdResult(retryAfter, nil)).Once()
+}
+
+func TestRequestMagicLink(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          api.MagicLinkRequest
+		setupRedis     func(*mocks.MockRedisClient)
+		user           *domain.User
+		getErr         error
+		wantStatus     int
+		wantErrMessage string
+		wantEmail      bool
+	}{
+		{
+			name:           "invalid email",
+			input:          api.MagicLinkRequest{Email: "not-an-email"},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: validator.ErrInvalidEmail,
+		},
+		{
+			name:  "too many requests from the client",
+			input: api.MagicLinkRequest{Email: "freddie@example.com"},
+			setupRedis: func(c *mocks.MockRedisClient) {
+				allowRateLimit(c, "rate_limit:magic_link_client:192.0.2.1", 60000)
+			},
+			wantStatus:     http.StatusTooManyRequests,
+			wantErrMessage: ErrRateLimitExceeded,
+		},
+		{
+			name:  "too many links for the address",
+			input: api.MagicLinkRequest{Email: "Freddie@Example.com"},
+			setupRedis: func(c *mocks.MockRedisClient) {
+				allowRateLimit(c, "rate_limit:magic_link_client:192.0.2.1", 0)
+				allowRateLimit(c, "rate_limit:magic_link_email:freddie@example.com", 60000)
+			},
+			wantStatus:     http.StatusTooManyRequests,
+			wantErrMessage: ErrRateLimitExceeded,
+		},
+		{
+			name:  "unknown address",
+			input: api.MagicLinkRequest{Email: "nobody@example.com"},
+			setupRedis: func(c *mocks.MockRedisClient) {
+				allowRateLimit(c, "rate_limit:magic_link_client:192.0.2.1", 0)
+				allowRateLimit(c, "rate_limit:magic_link_email:nobody@example.com", 0)
+			},
+			getErr:     domain.ErrRecordNotFound,
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:  "unactivated account",
+			input: api.MagicLinkRequest{Email: "freddie@example.com"},
+			setupRedis: func(c *mocks.MockRedisClient) {
+				allowRateLimit(c, "rate_limit:magic_link_client:192.0.2.1", 0)
+				allowRateLimit(c, "rate_limit:magic_link_email:freddie@example.com", 0)
+			},
+			user:       &domain.User{ID: 1, Email: "freddie@example.com"},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:  "sends the link",
+			input: api.MagicLinkRequest{Email: "freddie@example.com"},
+			setupRedis: func(c *mocks.MockRedisClient) {
+				allowRateLimit(c, "rate_limit:magic_link_client:192.0.2.1", 0)
+				allowRateLimit(c, "rate_limit:magic_link_email:freddie@example.com", 0)
+			},
+			user:       &domain.User{ID: 1, FirstName: "Freddie", Email: "freddie@example.com", Activated: true},
+			wantStatus: http.StatusAccepted,
+			wantEmail:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisClient := new(mocks.MockRedisClient)
+			if tt.setupRedis != nil {
+				tt.setupRedis(redisClient)
+			}
+
+			lookedUp := make(chan struct{}, 1)
+			emails := make(chan sentEmail, 1)
+			var storedToken *domain.Token
+
+			app := newTestApplication(func(a *Application) {
+				a.redis = redisClient
+				a.userRepo = &mocks.MockUserRepo{
+					GetByEmailFunc: func(ctx context.Context, email string) (*domain.User, error) {
+						defer func() { lookedUp <- struct{}{} }()
+						return tt.user, tt.getErr
+					},
+				}
+				a.tokenRepo = &mocks.MockTokenRepo{
+					CreateFunc: func(ctx context.Context, token *domain.Token) error {
+						storedToken = token
+						return nil
+					},
+				}
+				a.mailer = &MockMailer{sendFunc: func(recipient, template string, data any) error {
+					emails <- sentEmail{recipient: recipient, template: template, data: data}
+					return nil
+				}}
+			})
+
+			w, r := executeRequest(t, http.MethodPost, "/sessions/magic-link", tt.input)
+			r.RemoteAddr = "192.0.2.1:51234"
+
+			app.RequestMagicLink(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			redisClient.AssertExpectations(t)
+
+			checkErrorResponse(t, w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+
+			if tt.wantStatus == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "60" {
+				t.Errorf("Retry-After = %q, want %q", w.Header().Get("Retry-After"), "60")
+			}
+
+			if tt.wantStatus != http.StatusAccepted {
+				return
+			}
+
+			select {
+			case <-lookedUp:
+			case <-time.After(time.Second):
+				t.Fatal("account was not looked up")
+			}
+
+			if !tt.wantEmail {
+				select {
+				case email := <-emails:
+					t.Errorf("unexpected email to %s", email.recipient)
+				case <-time.After(50 * time.Millisecond):
+				}
+				return
+			}
+
+			select {
+			case email := <-emails:
+				if email.recipient != "freddie@example.com" || email.template != "magic_link.tmpl" {
+					t.Errorf("unexpected email %+v", email)
+				}
+
+				data := email.data.(mailer.MagicLinkEmail)
+				if storedToken == nil || data.LoginToken != storedToken.Plaintext {
+					t.Errorf("emailed token does not match the stored one")
+				}
+
+				if storedToken.Scope != domain.MagicLinkScope || time.Until(storedToken.Expiry) > magicLinkTokenTTL {
+					t.Errorf("unexpected token %+v", storedToken)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("magic link email was not sent")
+			}
+		})
+	}
+}
+
+func TestCompleteMagicLinkLogin(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          api.MagicLinkLoginRequest
+		loggedIn       bool
+		setupRedis     func(*mocks.MockRedisClient)
+		consumeErr     error
+		wantStatus     int
+		wantErrMessage string
+	}{
+		{
+			name:       "already logged in",
+			input:      api.MagicLinkLoginRequest{Token: testMagicLinkToken},
+			loggedIn:   true,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:           "malformed token",
+			input:          api.MagicLinkLoginRequest{Token: "short"},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: validator.ErrDefaultInvalid,
+		},
+		{
+			name:  "too many attempts",
+			input: api.MagicLinkLoginRequest{Token: testMagicLinkToken},
+			setupRedis: func(c *mocks.MockRedisClient) {
+				allowRateLimit(c, "rate_limit:magic_link_client:192.0.2.1", 1000)
+			},
+			wantStatus:     http.StatusTooManyRequests,
+			wantErrMessage: ErrRateLimitExceeded,
+		},
+		{
+			name:  "used or expired token",
+			input: api.MagicLinkLoginRequest{Token: testMagicLinkToken},
+			setupRedis: func(c *mocks.MockRedisClient) {
+				allowRateLimit(c, "rate_limit:magic_link_client:192.0.2.1", 0)
+			},
+			consumeErr:     domain.ErrRecordNotFound,
+			wantStatus:     http.StatusUnauthorized,
+			wantErrMessage: ErrInvalidMagicLink,
+		},
+		{
+			name:  "database error",
+			input: api.MagicLinkLoginRequest{Token: testMagicLinkToken},
+			setupRedis: func(c *mocks.MockRedisClient) {
+				allowRateLimit(c, "rate_limit:magic_link_client:192.0.2.1", 0)
+			},
+			consumeErr:     errors.New("database error"),
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+		{
+			name:  "successful login",
+			input: api.MagicLinkLoginRequest{Token: testMagicLinkToken, RememberMe: ptr(true)},
+			setupRedis: func(c *mocks.MockRedisClient) {
+				allowRateLimit(c, "rate_limit:magic_link_client:192.0.2.1", 0)
+				// the guest session has no cart to migrate
+				c.On("Get", mock.Anything, mock.Anything).Return(redis.NewStringResult("", redis.Nil)).Once()
+
+				// the session is indexed as long as a remembered session lasts
+				pipe := new(mocks.MockTxPipeline)
+				c.On("TxPipeline").Return(pipe).Once()
+				pipe.On("SAdd", mock.Anything, userSessionsKey(7), mock.Anything).Return(redis.NewIntResult(1, nil)).Once()
+				pipe.On("Expire", mock.Anything, userSessionsKey(7), 30*24*time.Hour).Return(redis.NewBoolResult(true, nil)).Once()
+				pipe.On("Exec", mock.Anything).Return([]redis.Cmder{}, nil).Once()
+			},
+			wantStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisClient := new(mocks.MockRedisClient)
+			if tt.setupRedis != nil {
+				tt.setupRedis(redisClient)
+			}
+
+			app := newTestApplication(func(a *Application) {
+				a.redis = redisClient
+				a.sessionManager = scs.New()
+				a.config.Session.RememberMeLifetime = 30 * 24 * time.Hour
+				a.tokenRepo = &mocks.MockTokenRepo{
+					ConsumeFunc: func(ctx context.Context, tokenHash []byte, tokenScope string) (int, error) {
+						hash := sha256.Sum256([]byte(testMagicLinkToken))
+						if string(tokenHash) != string(hash[:]) || tokenScope != domain.MagicLinkScope {
+							t.Errorf("unexpected token lookup")
+						}
+
+						return 7, tt.consumeErr
+					},
+				}
+			})
+
+			w, r := executeRequest(t, http.MethodPut, "/sessions/magic-link", tt.input)
+			r.RemoteAddr = "192.0.2.1:51234"
+
+			if tt.loggedIn {
+				r = setupTestSession(t, app, r, 1)
+			}
+
+			var userId int
+			var rememberMe bool
+			handler := app.sessionManager.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				app.CompleteMagicLinkLogin(w, r)
+				userId = app.sessionManager.GetInt(r.Context(), SessionKeyUserId.String())
+				rememberMe = app.sessionManager.GetBool(r.Context(), SessionKeyRememberMe.String())
+			}))
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			redisClient.AssertExpectations(t)
+
+			if tt.wantStatus == http.StatusNoContent {
+				if userId != 7 || !rememberMe {
+					t.Errorf("session user = %d, remember me = %v", userId, rememberMe)
+				}
+
+				if !strings.Contains(w.Header().Get("Set-Cookie"), "session=") {
+					t.Errorf("renewed session cookie was not written")
+				}
+			}
+
+			checkErrorResponse(t, w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package app
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+var manifestHeader = []string{
+	"row", "column", "seat_type", "reservation_id", "purchaser_name", "purchaser_email", "status", "ticket_code",
+}
+
+// manifestRevokedStatus replaces the reservation status of seats whose tickets were revoked, they must not be
+// let in even if the reservation is confirmed.
+const manifestRevokedStatus = "revoked"
+
+func (app *Application) GetShowtimeManifest(w http.ResponseWriter, r *http.Request, showtimeId int) {
+	if showtimeId < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("showtime ID must be greater than zero"))
+		return
+	}
+
+	logger := app.contextGetLogger(r)
+	userId := app.contextGetUserId(r)
+
+	manifest, err := app.reservationRepo.GetManifestByShowtime(r.Context(), showtimeId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	allowed, err := app.canManageTheater(r.Context(), userId, manifest.TheaterID)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.unauthorizedAccessResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	if !allowed {
+		logger.Warn("showtime manifest denied: user is not staff of the theater",
+			"showtime_id", showtimeId,
+			"theater_id", manifest.TheaterID)
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	// the manifest holds personal data of the guests, exports are kept track of
+	logger.Info("showtime manifest exported", "showtime_id", showtimeId, "seats", len(manifest.Seats))
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="showtime-%d-manifest.csv"`, showtimeId))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	cw := csv.NewWriter(w)
+	cw.Write(manifestHeader)
+
+	for _, seat := range manifest.Seats {
+		status := string(seat.Status)
+		if seat.TicketsRevokedAt != nil {
+			status = manifestRevokedStatus
+		}
+
+		cw.Write([]string{
+			strconv.Itoa(seat.Row),
+			strconv.Itoa(seat.Col),
+			seat.Type,
+			strconv.Itoa(seat.ReservationID),
+			csvSafe(strings.TrimSpace(seat.FirstName + " " + seat.LastName)),
+			csvSafe(seat.Email),
+			status,
+			app.ticketCode(seat.ReservationID, seat.TicketVersion),
+		})
+	}
+
+	cw.Flush()
+
+	if err := cw.Error(); err != nil {
+		// the status is already sent, the download is cut short
+		logger.Error("failed to write showtime manifest", "showtime_id", showtimeId, "error", err)
+	}
+}
+
+// canManageTheater reports whether the user is an admin or works at the theater. It returns
+// ErrRecordNotFound if the user doesn't exist.
+func (app *Application) canManageTheater(ctx context.Context, userId, theaterId int) (bool, error) {
+	user, err := app.userRepo.GetById(ctx, userId)
+	if err != nil {
+		return false, err
+	}
+
+	if user.IsAdmin() {
+		return true, nil
+	}
+
+	return app.theaterRepo.IsTheaterStaff(ctx, theaterId, userId)
+}
+
+// csvSafe keeps user provided values from being run as formulas when the export is opened in a spreadsheet.
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+
+	return value
+}
@@ -0,0 +1,187 @@
+package app
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type ManifestTestSuite struct {
+	suite.Suite
+	app             *Application
+	reservationRepo *mocks.MockReservationRepo
+	userRepo        *mocks.MockUserRepo
+	theaterRepo     *mocks.MockTheaterRepo
+}
+
+func (s *ManifestTestSuite) SetupTest() {
+	s.reservationRepo = new(mocks.MockReservationRepo)
+	s.userRepo = &mocks.MockUserRepo{
+		GetByIdFunc: func(ctx context.Context, id int) (*domain.User, error) {
+			return &domain.User{ID: id, Role: domain.RoleUser}, nil
+		},
+	}
+	s.theaterRepo = &mocks.MockTheaterRepo{
+		IsTheaterStaffFunc: func(ctx context.Context, theaterID, userID int) (bool, error) {
+			return theaterID == 3 && userID == 7, nil
+		},
+	}
+
+	s.app = newTestApplication(func(a *Application) {
+		a.reservationRepo = s.reservationRepo
+		a.userRepo = s.userRepo
+		a.theaterRepo = s.theaterRepo
+	})
+}
+
+func TestManifestSuite(t *testing.T) {
+	suite.Run(t, new(ManifestTestSuite))
+}
+
+func (s *ManifestTestSuite) TestGetShowtimeManifest() {
+	revokedAt := time.Date(2025, time.April, 1, 10, 0, 0, 0, time.UTC)
+
+	manifest := &domain.ShowtimeManifest{
+		ShowtimeID: 5,
+		TheaterID:  3,
+		Seats: []domain.ManifestSeat{
+			{
+				Row:           1,
+				Col:           2,
+				Type:          "VIP",
+				ReservationID: 10,
+				Status:        domain.ReservationConfirmed,
+				FirstName:     "Ada",
+				LastName:      "Lovelace",
+				Email:         "ada@example.com",
+			},
+			{
+				Row:              1,
+				Col:              3,
+				Type:             "Standard",
+				ReservationID:    11,
+				Status:           domain.ReservationConfirmed,
+				TicketsRevokedAt: &revokedAt,
+				TicketVersion:    2,
+				FirstName:        "=HYPERLINK(\"http://evil\")",
+				LastName:         "",
+				Email:            "alan@example.com",
+			},
+		},
+	}
+
+	tests := []struct {
+		name           string
+		showtimeId     int
+		userId         int
+		setupMocks     func()
+		wantStatus     int
+		wantErrMessage string
+	}{
+		{
+			name:       "should fail with invalid showtime ID",
+			showtimeId: 0,
+			userId:     7,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should fail when showtime does not exist",
+			showtimeId: 5,
+			userId:     7,
+			setupMocks: func() {
+				s.reservationRepo.On("GetManifestByShowtime", mock.Anything, 5).Return(nil, domain.ErrRecordNotFound)
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+		},
+		{
+			name:       "should fail when database error occurs",
+			showtimeId: 5,
+			userId:     7,
+			setupMocks: func() {
+				s.reservationRepo.On("GetManifestByShowtime", mock.Anything, 5).Return(nil, errors.New("database error"))
+			},
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+		{
+			name:       "should forbid users who are not staff of the theater",
+			showtimeId: 5,
+			userId:     8,
+			setupMocks: func() {
+				s.reservationRepo.On("GetManifestByShowtime", mock.Anything, 5).Return(manifest, nil)
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "should export the manifest to admins",
+			showtimeId: 5,
+			userId:     1,
+			setupMocks: func() {
+				s.userRepo.GetByIdFunc = func(ctx context.Context, id int) (*domain.User, error) {
+					return &domain.User{ID: id, Role: domain.RoleAdmin}, nil
+				}
+				s.reservationRepo.On("GetManifestByShowtime", mock.Anything, 5).Return(manifest, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "should export the manifest to theater staff",
+			showtimeId: 5,
+			userId:     7,
+			setupMocks: func() {
+				s.reservationRepo.On("GetManifestByShowtime", mock.Anything, 5).Return(manifest, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			w, r := executeRequest(s.T(), http.MethodGet, "/admin/showtimes/5/manifest", nil)
+			r = r.WithContext(context.WithValue(r.Context(), SessionKeyUserId, tt.userId))
+
+			s.app.GetShowtimeManifest(w, r, tt.showtimeId)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantStatus == http.StatusOK {
+				s.Equal("text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+				s.Equal(`attachment; filename="showtime-5-manifest.csv"`, w.Header().Get("Content-Disposition"))
+
+				records, err := csv.NewReader(w.Body).ReadAll()
+				s.Require().NoError(err)
+
+				s.Equal([][]string{
+					manifestHeader,
+					{"1", "2", "VIP", "10", "Ada Lovelace", "ada@example.com", "confirmed", s.app.ticketCode(10, 0)},
+					{"1", "3", "Standard", "11", "'=HYPERLINK(\"http://evil\")", "alan@example.com", "revoked", s.app.ticketCode(11, 2)},
+				}, records)
+			}
+
+			checkErrorResponse(s.T(), w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+
+			s.reservationRepo.AssertExpectations(s.T())
+		})
+	}
+}
@@ -2,18 +2,135 @@ package app
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
 	"go.opentelemetry.io/otel/trace"
 )
 
 type contextKey string
 
-const loggerContextKey = contextKey("logger")
+const (
+	loggerContextKey   = contextKey("logger")
+	partnerContextKey  = contextKey("partner")
+	peerAddrContextKey = contextKey("peer_addr")
+)
+
+// keepPeerAddr remembers the address of the direct peer of the request, realIP replaces it with the address
+// of the client when the peer is a trusted proxy.
+func (app *Application) keepPeerAddr(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), peerAddrContextKey, r.RemoteAddr)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// peerAddr returns the address of the direct peer of the request, whether realIP has run or not.
+func peerAddr(r *http.Request) string {
+	if addr, ok := r.Context().Value(peerAddrContextKey).(string); ok {
+		return addr
+	}
+
+	return r.RemoteAddr
+}
+
+// realIP replaces the address of the request with the address of the client when the request came through
+// a trusted proxy, the headers of any other peer are ignored since the client could have set them.
+func (app *Application) realIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.fromTrustedProxy(r) {
+			if addr, ok := app.forwardedClient(r.Header); ok {
+				r.RemoteAddr = addr.String()
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// forwardedClient returns the address of the client from the headers of the proxies. Every proxy appends
+// the address of its peer to X-Forwarded-For, so the chain is read from the right and the first address
+// which isn't a trusted proxy is the client, the addresses left of it were sent by the client. X-Real-IP
+// is only read when no X-Forwarded-For is sent.
+func (app *Application) forwardedClient(header http.Header) (netip.Addr, bool) {
+	var hops []string
+	for _, value := range header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+
+	if len(hops) == 0 {
+		addr, err := netip.ParseAddr(strings.TrimSpace(header.Get("X-Real-IP")))
+		if err != nil {
+			return netip.Addr{}, false
+		}
+
+		return addr.Unmap(), true
+	}
+
+	var addr netip.Addr
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// the chain is broken, the client can't be told apart from what it sent
+			return netip.Addr{}, false
+		}
+
+		addr = hop.Unmap()
+		if !containsAddr(app.config.TrustedProxies, addr) {
+			return addr, true
+		}
+	}
+
+	// the request came from within the network of the proxies
+	return addr, true
+}
+
+// requestID assigns every request an ID and returns it in the X-Request-ID response header. An ID sent
+// by the client is only kept when the request comes from a trusted proxy, otherwise anyone could make
+// their requests blend into someone else's logs.
+func (app *Application) requestID(next http.Handler) http.Handler {
+	withID := middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(middleware.RequestIDHeader, middleware.GetReqID(r.Context()))
+		next.ServeHTTP(w, r)
+	}))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.fromTrustedProxy(r) {
+			r.Header.Del(middleware.RequestIDHeader)
+		}
+
+		withID.ServeHTTP(w, r)
+	})
+}
+
+// fromTrustedProxy reports whether the direct peer of the request is one of the configured proxies.
+func (app *Application) fromTrustedProxy(r *http.Request) bool {
+	if len(app.config.TrustedProxies) == 0 {
+		return false
+	}
+
+	host, _, err := net.SplitHostPort(peerAddr(r))
+	if err != nil {
+		return false
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+
+	return containsAddr(app.config.TrustedProxies, addr.Unmap())
+}
 
 func (app *Application) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -29,6 +146,34 @@ func (app *Application) recoverPanic(next http.Handler) http.Handler {
 	})
 }
 
+// enforceIdleTimeout destroys sessions which were inactive for longer than the idle timeout, unless the
+// user asked to be remembered. The session store only enforces the absolute lifetime, because the idle
+// timeout of the store cannot differ between sessions.
+func (app *Application) enforceIdleTimeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.config.Session.IdleTimeout <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		now := time.Now()
+		lastActiveAt := app.sessionManager.GetInt64(r.Context(), SessionKeyLastActiveAt.String())
+		rememberMe := app.sessionManager.GetBool(r.Context(), SessionKeyRememberMe.String())
+
+		if lastActiveAt != 0 && !rememberMe && now.Sub(time.Unix(lastActiveAt, 0)) > app.config.Session.IdleTimeout {
+			err := app.sessionManager.Destroy(r.Context())
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+		}
+
+		app.sessionManager.Put(r.Context(), SessionKeyLastActiveAt.String(), now.Unix())
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (app *Application) ensureGuestUserSession(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		sessionId := app.sessionManager.Token(r.Context())
@@ -47,9 +192,26 @@ func (app *Application) ensureGuestUserSession(next http.Handler) http.Handler {
 	})
 }
 
+// requireAuthentication accepts either a bearer access token of a mobile device or the session cookie.
+// A request carrying an Authorization header never falls back to the session.
 func (app *Application) requireAuthentication(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		userId := app.sessionManager.GetInt(r.Context(), SessionKeyUserId.String())
+		userId, hasBearer, err := app.bearerUserId(r)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !hasBearer {
+			userId = app.sessionManager.GetInt(r.Context(), SessionKeyUserId.String())
+		}
+
+		// without Redis the session of a signed in user can't be loaded, they aren't told to sign in again
+		if userId == 0 && !hasBearer && !app.sessionStoreAvailable(r) {
+			app.sessionStoreUnavailableResponse(w, r)
+			return
+		}
+
 		if userId == 0 {
 			app.unauthorizedAccessResponse(w, r)
 			return
@@ -62,6 +224,109 @@ func (app *Application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+// requireRecentAuthentication guards sensitive actions. It must be chained after requireAuthentication
+// and rejects sessions whose user has not proved their identity within the reauthentication window.
+func (app *Application) requireRecentAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authenticatedAt := time.Unix(app.sessionManager.GetInt64(r.Context(), SessionKeyAuthenticatedAt.String()), 0)
+
+		if app.config.Session.ReauthWindow > 0 && time.Since(authenticatedAt) > app.config.Session.ReauthWindow {
+			app.reauthenticationRequiredResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireRole must be chained after requireAuthentication, it relies on the user ID put into the context.
+func (app *Application) requireRole(role domain.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userId := app.contextGetUserId(r)
+
+			user, err := app.userRepo.GetById(r.Context(), userId)
+			if err != nil {
+				switch {
+				case errors.Is(err, domain.ErrRecordNotFound):
+					app.unauthorizedAccessResponse(w, r)
+				default:
+					app.serverErrorResponse(w, r, err)
+				}
+
+				return
+			}
+
+			if user.Role != role {
+				app.forbiddenResponse(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requirePartnerKey authenticates ticket partners by the API key in the X-API-Key header.
+func (app *Application) requirePartnerKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		partner, ok := app.partnerByKey(r.Header.Get("X-API-Key"))
+		if !ok {
+			app.unauthorizedAccessResponse(w, r)
+			return
+		}
+
+		logger := app.contextGetLogger(r).With("partner", partner)
+		ctx := context.WithValue(r.Context(), loggerContextKey, logger)
+		r = r.WithContext(context.WithValue(ctx, partnerContextKey, partner))
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// partnerByKey compares the key with every configured key in constant time, so the response time
+// doesn't tell how much of a guessed key is right.
+func (app *Application) partnerByKey(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+
+	hash := sha256.Sum256([]byte(key))
+
+	var partner string
+	found := false
+
+	for candidate, name := range app.config.PartnerAPIKeys {
+		candidateHash := sha256.Sum256([]byte(candidate))
+
+		if subtle.ConstantTimeCompare(hash[:], candidateHash[:]) == 1 {
+			partner = name
+			found = true
+		}
+	}
+
+	return partner, found
+}
+
+// salesChannel attributes a sale to the channel of the principal making the request: a partner
+// authenticated by its API key, a kiosk account, a mobile device authenticated by a bearer token, or the
+// web. The user is nil when it isn't known yet, e.g. when an anonymous cart is created.
+func (app *Application) salesChannel(r *http.Request, user *domain.User) domain.SalesChannel {
+	if _, ok := r.Context().Value(partnerContextKey).(string); ok {
+		return domain.SalesChannelPartner
+	}
+
+	if user != nil && user.Role == domain.RoleKiosk {
+		return domain.SalesChannelKiosk
+	}
+
+	if r.Header.Get("Authorization") != "" {
+		return domain.SalesChannelMobile
+	}
+
+	return domain.SalesChannelWeb
+}
+
 type loggingResponseWriter struct {
 	http.ResponseWriter
 	statusCode int
@@ -88,7 +353,14 @@ func (lrw *loggingResponseWriter) Write(b []byte) (int, error) {
 	return size, err
 }
 
+// Unwrap exposes the underlying ResponseWriter to http.ResponseController, which is needed for streaming responses.
+func (lrw *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lrw.ResponseWriter
+}
+
 func (app *Application) loggingMiddleware(next http.Handler) http.Handler {
+	metrics := app.newHTTPMetrics()
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		span := trace.SpanFromContext(r.Context())
 
@@ -115,6 +387,12 @@ func (app *Application) loggingMiddleware(next http.Handler) http.Handler {
 
 		duration := time.Since(start)
 
+		metrics.record(r, lrw.statusCode, duration)
+
+		if lrw.statusCode >= 400 {
+			app.storeRequestTrace(r.Context(), middleware.GetReqID(r.Context()))
+		}
+
 		switch {
 		case lrw.statusCode >= 500:
 			requestLogger.Error("request completed", "status", lrw.statusCode, "bytes", lrw.bytes, "duration", duration.String(), "remote_addr", r.RemoteAddr)
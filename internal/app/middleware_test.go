@@ -0,0 +1,473 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+func TestRequestID(t *testing.T) {
+	trustedProxies, err := parsePrefixes("10.0.0.0/8, 192.168.1.10")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		incomingID string
+		wantKept   bool
+	}{
+		{
+			name:       "generates an ID when none is sent",
+			remoteAddr: "10.1.2.3:4567",
+		},
+		{
+			name:       "keeps the ID sent by a trusted proxy",
+			remoteAddr: "10.1.2.3:4567",
+			incomingID: "lb-4f2a9c",
+			wantKept:   true,
+		},
+		{
+			name:       "keeps the ID sent by a trusted proxy address",
+			remoteAddr: "192.168.1.10:4567",
+			incomingID: "lb-4f2a9c",
+			wantKept:   true,
+		},
+		{
+			name:       "keeps the ID sent by a trusted proxy over IPv4-mapped IPv6",
+			remoteAddr: "[::ffff:10.1.2.3]:4567",
+			incomingID: "lb-4f2a9c",
+			wantKept:   true,
+		},
+		{
+			name:       "replaces the ID sent by an untrusted client",
+			remoteAddr: "203.0.113.7:4567",
+			incomingID: "lb-4f2a9c",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(func(a *Application) {
+				a.config.TrustedProxies = trustedProxies
+			})
+
+			var seenID string
+			handler := app.requestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seenID = middleware.GetReqID(r.Context())
+				w.WriteHeader(http.StatusNoContent)
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/health", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.incomingID != "" {
+				r.Header.Set("X-Request-ID", tt.incomingID)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if seenID == "" {
+				t.Fatal("request has no ID")
+			}
+
+			if got := w.Header().Get("X-Request-ID"); got != seenID {
+				t.Errorf("X-Request-ID header = %q, want %q", got, seenID)
+			}
+
+			if kept := seenID == tt.incomingID; kept != tt.wantKept {
+				t.Errorf("request ID = %q, incoming ID kept = %v, want %v", seenID, kept, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestRealIP(t *testing.T) {
+	trustedProxies, err := parsePrefixes("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		realIP       string
+		wantAddr     string
+	}{
+		{
+			name:       "keeps the address of a direct client",
+			remoteAddr: "203.0.113.7:4567",
+			wantAddr:   "203.0.113.7:4567",
+		},
+		{
+			name:         "ignores the headers of an untrusted peer",
+			remoteAddr:   "203.0.113.7:4567",
+			forwardedFor: []string{"198.51.100.1"},
+			realIP:       "198.51.100.1",
+			wantAddr:     "203.0.113.7:4567",
+		},
+		{
+			name:         "takes the client forwarded by a trusted proxy",
+			remoteAddr:   "10.0.0.2:4567",
+			forwardedFor: []string{"198.51.100.1"},
+			wantAddr:     "198.51.100.1",
+		},
+		{
+			name:         "skips the addresses prepended by the client",
+			remoteAddr:   "10.0.0.2:4567",
+			forwardedFor: []string{"192.0.2.99, 198.51.100.1"},
+			wantAddr:     "198.51.100.1",
+		},
+		{
+			name:         "skips the trusted proxies of the chain",
+			remoteAddr:   "10.0.0.2:4567",
+			forwardedFor: []string{"192.0.2.99, 198.51.100.1", "10.0.0.3"},
+			wantAddr:     "198.51.100.1",
+		},
+		{
+			name:         "takes the leftmost proxy when every hop is trusted",
+			remoteAddr:   "10.0.0.2:4567",
+			forwardedFor: []string{"10.0.0.4, 10.0.0.3"},
+			wantAddr:     "10.0.0.4",
+		},
+		{
+			name:         "keeps the peer when the chain is malformed",
+			remoteAddr:   "10.0.0.2:4567",
+			forwardedFor: []string{"198.51.100.1, unknown"},
+			wantAddr:     "10.0.0.2:4567",
+		},
+		{
+			name:       "falls back to X-Real-IP of a trusted proxy",
+			remoteAddr: "10.0.0.2:4567",
+			realIP:     "198.51.100.1",
+			wantAddr:   "198.51.100.1",
+		},
+		{
+			name:         "unmaps IPv4-mapped IPv6 addresses",
+			remoteAddr:   "[::ffff:10.0.0.2]:4567",
+			forwardedFor: []string{"::ffff:198.51.100.1"},
+			wantAddr:     "198.51.100.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(func(a *Application) {
+				a.config.TrustedProxies = trustedProxies
+			})
+
+			var gotAddr, gotPeer string
+			handler := app.keepPeerAddr(app.realIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotAddr, gotPeer = r.RemoteAddr, peerAddr(r)
+			})))
+
+			r := httptest.NewRequest(http.MethodGet, "/health", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwardedFor {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			if gotAddr != tt.wantAddr {
+				t.Errorf("RemoteAddr = %q, want %q", gotAddr, tt.wantAddr)
+			}
+
+			if gotPeer != tt.remoteAddr {
+				t.Errorf("peer address = %q, want %q", gotPeer, tt.remoteAddr)
+			}
+		})
+	}
+}
+
+func TestParsePrefixes(t *testing.T) {
+	prefixes, err := parsePrefixes("10.0.0.1/8,, ::1 ,fd00::/8")
+	if err != nil {
+		t.Fatalf("parsePrefixes() error = %v", err)
+	}
+
+	want := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("::1/128"),
+		netip.MustParsePrefix("fd00::/8"),
+	}
+
+	if len(prefixes) != len(want) {
+		t.Fatalf("got %v, want %v", prefixes, want)
+	}
+
+	for i := range want {
+		if prefixes[i] != want[i] {
+			t.Errorf("prefix %d = %v, want %v", i, prefixes[i], want[i])
+		}
+	}
+
+	if _, err := parsePrefixes("10.0.0.0/33"); err == nil {
+		t.Error("expected an error for an invalid prefix")
+	}
+}
+
+func TestParsePartnerAPIKeys(t *testing.T) {
+	keys, err := parsePartnerAPIKeys("acme:k3y-1,, tix : k3y-2 ")
+	if err != nil {
+		t.Fatalf("parsePartnerAPIKeys() error = %v", err)
+	}
+
+	if len(keys) != 2 || keys["k3y-1"] != "acme" || keys[" k3y-2"] != "tix " {
+		t.Errorf("got %v", keys)
+	}
+
+	for _, value := range []string{"k3y-1", "acme:", "acme:k3y-1,tix:k3y-1"} {
+		if _, err := parsePartnerAPIKeys(value); err == nil {
+			t.Errorf("expected an error for %q", value)
+		}
+	}
+}
+
+func TestRequirePartnerKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		key        string
+		wantStatus int
+	}{
+		{
+			name:       "rejects a request without a key",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "rejects an unknown key",
+			key:        "guessed",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "accepts a configured key",
+			key:        "k3y-2",
+			wantStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(func(a *Application) {
+				a.config.PartnerAPIKeys = map[string]string{"k3y-1": "acme", "k3y-2": "tix"}
+			})
+
+			handler := app.requirePartnerKey(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/partner/feed/showtimes", nil)
+			if tt.key != "" {
+				r.Header.Set("X-API-Key", tt.key)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestSalesChannel(t *testing.T) {
+	app := newTestApplication()
+
+	partnerRequest := func() *http.Request {
+		var captured *http.Request
+
+		app := newTestApplication(func(a *Application) {
+			a.config.PartnerAPIKeys = map[string]string{"k3y-1": "acme"}
+		})
+
+		handler := app.requirePartnerKey(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			captured = r
+		}))
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-API-Key", "k3y-1")
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+
+		return captured
+	}
+
+	bearerRequest := httptest.NewRequest(http.MethodGet, "/", nil)
+	bearerRequest.Header.Set("Authorization", "Bearer token")
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		user    *domain.User
+		want    domain.SalesChannel
+	}{
+		{
+			name:    "attributes anonymous requests to the web",
+			request: httptest.NewRequest(http.MethodGet, "/", nil),
+			want:    domain.SalesChannelWeb,
+		},
+		{
+			name:    "attributes users of the session to the web",
+			request: httptest.NewRequest(http.MethodGet, "/", nil),
+			user:    &domain.User{ID: 1, Role: domain.RoleUser},
+			want:    domain.SalesChannelWeb,
+		},
+		{
+			name:    "attributes bearer tokens to mobile devices",
+			request: bearerRequest,
+			user:    &domain.User{ID: 1, Role: domain.RoleUser},
+			want:    domain.SalesChannelMobile,
+		},
+		{
+			name:    "attributes kiosk accounts to the kiosk",
+			request: bearerRequest,
+			user:    &domain.User{ID: 1, Role: domain.RoleKiosk},
+			want:    domain.SalesChannelKiosk,
+		},
+		{
+			name:    "attributes requests with a partner API key to the partner",
+			request: partnerRequest(),
+			want:    domain.SalesChannelPartner,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := app.salesChannel(tt.request, tt.user); got != tt.want {
+				t.Errorf("salesChannel() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnforceIdleTimeout(t *testing.T) {
+	tests := []struct {
+		name         string
+		lastActiveAt time.Duration
+		rememberMe   bool
+		wantLoggedIn bool
+	}{
+		{
+			name:         "new session",
+			wantLoggedIn: true,
+		},
+		{
+			name:         "active session",
+			lastActiveAt: 5 * time.Minute,
+			wantLoggedIn: true,
+		},
+		{
+			name:         "idle session",
+			lastActiveAt: 30 * time.Minute,
+		},
+		{
+			name:         "idle remembered session",
+			lastActiveAt: 48 * time.Hour,
+			rememberMe:   true,
+			wantLoggedIn: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(func(a *Application) {
+				a.sessionManager = scs.New()
+				a.config.Session.IdleTimeout = 20 * time.Minute
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+			r = setupTestSession(t, app, r, 1)
+
+			if tt.lastActiveAt != 0 {
+				app.sessionManager.Put(r.Context(), SessionKeyLastActiveAt.String(), time.Now().Add(-tt.lastActiveAt).Unix())
+			}
+			app.sessionManager.Put(r.Context(), SessionKeyRememberMe.String(), tt.rememberMe)
+
+			var userId int
+			var lastActiveAt int64
+			handler := app.enforceIdleTimeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				userId = app.sessionManager.GetInt(r.Context(), SessionKeyUserId.String())
+				lastActiveAt = app.sessionManager.GetInt64(r.Context(), SessionKeyLastActiveAt.String())
+			}))
+
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			if loggedIn := userId == 1; loggedIn != tt.wantLoggedIn {
+				t.Errorf("logged in = %v, want %v", loggedIn, tt.wantLoggedIn)
+			}
+
+			if time.Since(time.Unix(lastActiveAt, 0)) > time.Minute {
+				t.Errorf("last activity of the session was not updated")
+			}
+		})
+	}
+}
+
+func TestRequireRecentAuthentication(t *testing.T) {
+	tests := []struct {
+		name            string
+		authenticatedAt time.Duration
+		reauthWindow    time.Duration
+		wantStatus      int
+	}{
+		{
+			name:            "recent login",
+			authenticatedAt: 5 * time.Minute,
+			reauthWindow:    15 * time.Minute,
+			wantStatus:      http.StatusNoContent,
+		},
+		{
+			name:            "stale login",
+			authenticatedAt: time.Hour,
+			reauthWindow:    15 * time.Minute,
+			wantStatus:      http.StatusUnauthorized,
+		},
+		{
+			name:            "check disabled",
+			authenticatedAt: time.Hour,
+			wantStatus:      http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(func(a *Application) {
+				a.sessionManager = scs.New()
+				a.config.Session.ReauthWindow = tt.reauthWindow
+			})
+
+			w, r := executeRequest(t, http.MethodPut, "/users/me/deletion-request", nil)
+			r = setupTestSession(t, app, r, 1)
+			app.sessionManager.Put(r.Context(), SessionKeyAuthenticatedAt.String(), time.Now().Add(-tt.authenticatedAt).Unix())
+
+			handler := app.requireRecentAuthentication(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			}))
+
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			checkErrorResponse(t, w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: ErrReauthRequired,
+			})
+		})
+	}
+}
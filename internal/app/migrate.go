@@ -0,0 +1,118 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	pgxmigrate "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jackc/pgx/v5"
+	pgxstd "github.com/jackc/pgx/v5/stdlib"
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/migrations"
+)
+
+// migrateDatabase applies the pending migrations embedded in the binary. golang-migrate holds a Postgres
+// advisory lock while migrating, so when several replicas start at once one of them applies the
+// migrations and the others wait for it, then find nothing left to do.
+func migrateDatabase(cfg Config, logger *slog.Logger) error {
+	source, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return err
+	}
+
+	connConfig, err := pgx.ParseConfig(cfg.DB.DSN)
+	if err != nil {
+		return err
+	}
+
+	// closed along with the migration driver
+	db := pgxstd.OpenDB(*connConfig)
+
+	driver, err := pgxmigrate.WithInstance(db, &pgxmigrate.Config{})
+	if err != nil {
+		db.Close()
+		return fmt.Errorf("failed to connect to the database: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "pgx5", driver)
+	if err != nil {
+		driver.Close()
+		return err
+	}
+	defer m.Close()
+
+	m.LockTimeout = cfg.DB.MigrationLockTimeout
+	m.Log = migrateLogger{logger: logger}
+
+	err = m.Up()
+	switch {
+	case errors.Is(err, migrate.ErrNoChange):
+		logger.Info("database schema is up to date")
+	case err != nil:
+		return fmt.Errorf("failed to migrate the database: %w", err)
+	}
+
+	version, _, err := m.Version()
+	if err != nil {
+		return err
+	}
+
+	logger.Info("database migrated", "version", version)
+
+	return nil
+}
+
+// migrateLogger passes the progress of golang-migrate to the application logger.
+type migrateLogger struct {
+	logger *slog.Logger
+}
+
+func (l migrateLogger) Printf(format string, v ...any) {
+	l.logger.Info(strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
+
+func (l migrateLogger) Verbose() bool {
+	return false
+}
+
+func (app *Application) GetMigrationStatus(w http.ResponseWriter, r *http.Request) {
+	list, err := migrations.List()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	version, dirty, err := schemaVersion(r.Context(), app.db.Pool())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	resp := api.MigrationStatus{
+		CurrentVersion: int(version),
+		Dirty:          dirty,
+		Pending:        []api.Migration{},
+	}
+
+	for _, migration := range list {
+		resp.LatestVersion = int(migration.Version)
+
+		if migration.Version > version {
+			resp.Pending = append(resp.Pending, api.Migration{
+				Version: int(migration.Version),
+				Name:    migration.Name,
+			})
+		}
+	}
+
+	resp.UpToDate = !dirty && len(resp.Pending) == 0
+
+	if err := app.writeJSON(w, http.StatusOK, resp, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
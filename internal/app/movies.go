@@ -1,19 +1,23 @@
 package app
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/metinatakli/movie-reservation-system/api"
 	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/validator"
 	"github.com/oapi-codegen/runtime/types"
 )
 
 const (
 	DefaultPage     = 1
-	DefaultPageSize = 10
+	DefaultPageSize = validator.DefaultPageSize
 	DefaultSort     = "id"
 )
 
@@ -24,6 +28,11 @@ func (app *Application) GetMovies(w http.ResponseWriter, r *http.Request, params
 		return
 	}
 
+	headers, notModified := app.catalogNotModified(w, r, app.config.CatalogCache.MoviesTTL)
+	if notModified {
+		return
+	}
+
 	filters := toMovieFilters(params)
 
 	movies, metadata, err := app.movieRepo.GetAll(r.Context(), filters)
@@ -33,14 +42,14 @@ func (app *Application) GetMovies(w http.ResponseWriter, r *http.Request, params
 	}
 
 	movieSummaries := toMovieSummaries(movies)
-	apiMetadata := toApiMetadata(metadata)
+	apiMetadata := toApiMetadata(r, metadata)
 
 	resp := api.MovieListResponse{
 		Movies:   movieSummaries,
 		Metadata: apiMetadata,
 	}
 
-	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	err = app.writeJSON(w, http.StatusOK, resp, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -102,26 +111,17 @@ func toMovieSummary(movie *domain.Movie) api.MovieSummary {
 	}
 }
 
-func toApiMetadata(metadata *domain.Metadata) *api.Metadata {
-	if metadata == nil {
-		return nil
-	}
-
-	return &api.Metadata{
-		CurrentPage:  metadata.CurrentPage,
-		FirstPage:    metadata.FirstPage,
-		LastPage:     metadata.LastPage,
-		PageSize:     metadata.PageSize,
-		TotalRecords: metadata.TotalRecords,
-	}
-}
-
 func (app *Application) ShowMovieDetails(w http.ResponseWriter, r *http.Request, id int) {
 	if id < 1 {
 		app.badRequestResponse(w, r, fmt.Errorf("movie ID must be greater than zero"))
 		return
 	}
 
+	headers, notModified := app.catalogNotModified(w, r, app.config.CatalogCache.MovieDetailsTTL)
+	if notModified {
+		return
+	}
+
 	movie, err := app.movieRepo.GetById(r.Context(), id)
 	if err != nil {
 		switch {
@@ -136,28 +136,145 @@ func (app *Application) ShowMovieDetails(w http.ResponseWriter, r *http.Request,
 
 	resp := toMovieDetailsResponse(movie)
 
-	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	err = app.writeJSON(w, http.StatusOK, resp, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// CreateMovie adds a movie to the catalog. Staff add movies by hand, so the movie isn't created when similar
+// movies released the same year exist, unless force is set.
+func (app *Application) CreateMovie(w http.ResponseWriter, r *http.Request, params api.CreateMovieParams) {
+	var input api.CreateMovieRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	movie := toDomainMovie(input)
+
+	if params.Force == nil || !*params.Force {
+		duplicates, err := app.movieRepo.FindDuplicates(r.Context(), movie.Title, movie.ReleaseDate.Year())
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if len(duplicates) > 0 {
+			app.duplicateMoviesResponse(w, r, duplicates)
+			return
+		}
+	}
+
+	err = app.movieRepo.Create(r.Context(), movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.contextGetLogger(r).Info("movie created", "movie_id", movie.ID, "forced", params.Force != nil && *params.Force)
+
+	app.touchCatalog(r)
+
+	err = app.writeJSON(w, http.StatusCreated, toMovieDetailsResponse(movie), nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
+func (app *Application) duplicateMoviesResponse(w http.ResponseWriter, r *http.Request, duplicates []domain.MovieDuplicate) {
+	app.logClientError(r, "possible duplicate movie")
+
+	resp := api.DuplicateMoviesResponse{
+		Code:       api.POSSIBLEDUPLICATEMOVIE,
+		Message:    "Similar movies released the same year exist, send force=true to create the movie anyway",
+		RequestId:  middleware.GetReqID(r.Context()),
+		TraceId:    traceID(r),
+		Timestamp:  time.Now(),
+		Duplicates: make([]api.DuplicateMovie, len(duplicates)),
+	}
+
+	for i, d := range duplicates {
+		resp.Duplicates[i] = api.DuplicateMovie{
+			Id:          d.ID,
+			Name:        d.Title,
+			ReleaseDate: types.Date{Time: d.ReleaseDate},
+			Similarity:  d.Similarity,
+			Archived:    d.Archived,
+		}
+	}
+
+	err := app.writeJSON(w, http.StatusConflict, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func toDomainMovie(input api.CreateMovieRequest) *domain.Movie {
+	movie := &domain.Movie{
+		Title:             strings.TrimSpace(input.Name),
+		Description:       input.Description,
+		Genres:            input.Genres,
+		Language:          input.Language,
+		ReleaseDate:       input.ReleaseDate.Time,
+		Duration:          input.Runtime,
+		PosterUrl:         input.PosterUrl,
+		Director:          input.Director,
+		CastMembers:       input.Cast,
+		ContentWarnings:   []string{},
+		SubtitleLanguages: []string{},
+	}
+
+	if input.ContentWarnings != nil {
+		for _, warning := range *input.ContentWarnings {
+			movie.ContentWarnings = append(movie.ContentWarnings, string(warning))
+		}
+	}
+
+	if input.SubtitleLanguages != nil {
+		movie.SubtitleLanguages = *input.SubtitleLanguages
+	}
+
+	if input.AudioDescription != nil {
+		movie.AudioDescription = *input.AudioDescription
+	}
+
+	return movie
+}
+
 func toMovieDetailsResponse(movie *domain.Movie) api.MovieDetailsResponse {
 	if movie == nil {
 		return api.MovieDetailsResponse{}
 	}
 
 	resp := api.MovieDetailsResponse{
-		Id:          movie.ID,
-		Name:        movie.Title,
-		PosterUrl:   movie.PosterUrl,
-		ReleaseDate: types.Date{Time: movie.ReleaseDate},
-		Description: movie.Description,
-		Runtime:     movie.Duration,
-		Genres:      movie.Genres,
-		Language:    movie.Language,
-		Director:    movie.Director,
-		Cast:        movie.CastMembers,
+		Id:                movie.ID,
+		Name:              movie.Title,
+		PosterUrl:         movie.PosterUrl,
+		ReleaseDate:       types.Date{Time: movie.ReleaseDate},
+		Description:       movie.Description,
+		Runtime:           movie.Duration,
+		Genres:            movie.Genres,
+		Language:          movie.Language,
+		Director:          movie.Director,
+		Cast:              movie.CastMembers,
+		SubtitleLanguages: movie.SubtitleLanguages,
+		AudioDescription:  movie.AudioDescription,
+	}
+
+	if movie.ContentWarnings != nil {
+		resp.ContentWarnings = make([]api.ContentWarning, len(movie.ContentWarnings))
+		for i, warning := range movie.ContentWarnings {
+			resp.ContentWarnings[i] = api.ContentWarning(warning)
+		}
 	}
 
 	if movie.Rating.Valid {
@@ -222,12 +339,43 @@ func (app *Application) GetMovieShowtimes(
 		return
 	}
 
+	if params.Latitude == nil || params.Longitude == nil {
+		lat, long, err := app.searchLocation(r, params.Address)
+		if err != nil {
+			switch {
+			case errors.Is(err, errNoDefaultLocation),
+				errors.Is(err, errGeocodingUnavailable),
+				errors.Is(err, domain.ErrAddressNotFound):
+				app.badRequestResponse(w, r, err)
+			case errors.Is(err, domain.ErrGeocoderBusy):
+				app.rateLimitExceededResponse(w, r, max(app.config.Geocoder.MaxWait, time.Second))
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+
+			return
+		}
+
+		params.Latitude = &lat
+		params.Longitude = &long
+	}
+
+	var filter domain.ShowtimeFilter
+	if params.Accessibility != nil {
+		filter.Accessibility = domain.AccessibilityFeature(*params.Accessibility)
+	}
+
+	if params.Format != nil {
+		filter.Format = domain.ScreeningFormat(*params.Format)
+	}
+
 	theaters, metadata, err := app.theaterRepo.GetTheatersByMovieAndLocationAndDate(
 		r.Context(),
 		movieId,
 		date,
 		*params.Longitude,
 		*params.Latitude,
+		filter,
 		pagination,
 	)
 	if err != nil {
@@ -235,8 +383,8 @@ func (app *Application) GetMovieShowtimes(
 		return
 	}
 
-	theaterShowtimes := toTheaterShowtimes(theaters)
-	apiMetadata := toApiMetadata(metadata)
+	theaterShowtimes := toTheaterShowtimes(theaters, app.occupancyBands(r.Context(), theaters))
+	apiMetadata := toApiMetadata(r, metadata)
 
 	resp := api.MovieShowtimesResponse{
 		Date:     types.Date{Time: date},
@@ -250,25 +398,145 @@ func (app *Application) GetMovieShowtimes(
 	}
 }
 
-func toTheaterShowtimes(theaters []domain.Theater) []api.TheaterShowtimes {
+// GetMovieNextShowtime returns the soonest bookable showtime of the movie near the user. It's served by a
+// single query, an unknown movie has no showtime and is not found either.
+func (app *Application) GetMovieNextShowtime(
+	w http.ResponseWriter,
+	r *http.Request,
+	movieId int,
+	params api.GetMovieNextShowtimeParams) {
+
+	if movieId < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("movie ID must be greater than zero"))
+		return
+	}
+
+	err := app.validator.Struct(params)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	if params.Latitude == nil || params.Longitude == nil {
+		lat, long, err := app.defaultLocation(r)
+		if err != nil {
+			switch {
+			case errors.Is(err, errNoDefaultLocation):
+				app.badRequestResponse(w, r, err)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+
+			return
+		}
+
+		params.Latitude = &lat
+		params.Longitude = &long
+	}
+
+	next, err := app.theaterRepo.GetNextShowtime(r.Context(), movieId, *params.Longitude, *params.Latitude, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	resp := api.NextShowtimeResponse{
+		ShowtimeId:     next.ShowtimeID,
+		StartTime:      next.StartTime.In(domain.TheaterLocation(next.TheaterTimeZone)),
+		Format:         api.ScreeningFormat(next.Format),
+		OpenCaptions:   next.OpenCaptions,
+		Price:          next.Price,
+		AvailableSeats: next.AvailableSeats,
+		Movie: api.NextShowtimeMovie{
+			Id:    next.MovieID,
+			Title: next.MovieTitle,
+		},
+		Theater: api.NextShowtimeTheater{
+			Id:         next.TheaterID,
+			Name:       next.TheaterName,
+			Address:    next.TheaterAddress,
+			City:       next.TheaterCity,
+			District:   next.TheaterDistrict,
+			DistanceKm: next.DistanceKm,
+		},
+		HallName: next.HallName,
+		Links: api.NextShowtimeLinks{
+			SeatMap: app.publicURL(fmt.Sprintf("/showtimes/%d/seat-map", next.ShowtimeID)),
+			Cart:    app.publicURL(fmt.Sprintf("/showtimes/%d/cart", next.ShowtimeID)),
+		},
+	}
+
+	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// ArchiveMovie takes the movie out of the catalog. Movies aren't deleted, their showtimes and
+// reservations keep referring to them.
+func (app *Application) ArchiveMovie(w http.ResponseWriter, r *http.Request, movieId int) {
+	app.setMovieArchived(w, r, movieId, app.movieRepo.Archive)
+}
+
+// RestoreMovie brings an archived movie back to the catalog.
+func (app *Application) RestoreMovie(w http.ResponseWriter, r *http.Request, movieId int) {
+	app.setMovieArchived(w, r, movieId, app.movieRepo.Restore)
+}
+
+func (app *Application) setMovieArchived(
+	w http.ResponseWriter,
+	r *http.Request,
+	movieId int,
+	update func(ctx context.Context, id int) error) {
+
+	if movieId < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("movie ID must be greater than zero"))
+		return
+	}
+
+	err := update(r.Context(), movieId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	app.touchCatalog(r)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func toTheaterShowtimes(theaters []domain.Theater, bands map[int]domain.OccupancyBand) []api.TheaterShowtimes {
 	theaterShowtimes := make([]api.TheaterShowtimes, len(theaters))
 
 	for i, v := range theaters {
-		theaterShowtime := toTheaterShowtime(v)
+		theaterShowtime := toTheaterShowtime(v, bands)
 		theaterShowtimes[i] = theaterShowtime
 	}
 
 	return theaterShowtimes
 }
 
-func toTheaterShowtime(theater domain.Theater) api.TheaterShowtimes {
+func toTheaterShowtime(theater domain.Theater, bands map[int]domain.OccupancyBand) api.TheaterShowtimes {
 	return api.TheaterShowtimes{
 		Address:   theater.Address,
 		Amenities: toAmenities(theater.Amenities),
 		City:      theater.City,
 		Distance:  theater.Distance,
 		District:  theater.District,
-		Halls:     toHalls(theater.Halls),
+		TimeZone:  theater.TimeZone,
+		Halls:     toHalls(theater.Halls, domain.TheaterLocation(theater.TimeZone), bands),
 		Id:        theater.ID,
 		Name:      theater.Name,
 	}
@@ -290,7 +558,9 @@ func toAmenities(amenities []domain.Amenity) []api.Amenity {
 	return apiAmenities
 }
 
-func toHalls(halls []domain.Hall) []api.Hall {
+// toHalls converts the halls of a theater, their showtimes are given in loc, the time zone of the theater.
+// Showtimes without a band in bands have no occupancy hint.
+func toHalls(halls []domain.Hall, loc *time.Location, bands map[int]domain.OccupancyBand) []api.Hall {
 	apiHalls := make([]api.Hall, len(halls))
 
 	for i, v := range halls {
@@ -298,7 +568,7 @@ func toHalls(halls []domain.Hall) []api.Hall {
 			Id:        v.ID,
 			Amenities: toAmenities(v.Amenities),
 			Name:      v.Name,
-			Showtimes: toShowtimes(v.Showtimes),
+			Showtimes: toShowtimes(v.Showtimes, loc, bands),
 		}
 
 		apiHalls[i] = hall
@@ -307,15 +577,30 @@ func toHalls(halls []domain.Hall) []api.Hall {
 	return apiHalls
 }
 
-func toShowtimes(showtimes []domain.Showtime) []api.Showtime {
+func toShowtimes(showtimes []domain.Showtime, loc *time.Location, bands map[int]domain.OccupancyBand) []api.Showtime {
 	apiShowtimes := make([]api.Showtime, len(showtimes))
 	now := time.Now()
 
 	for i, v := range showtimes {
+		startTime := v.StartTime.In(loc)
+		featureStart := domain.FeatureStart(v.StartTime, v.PreShowMinutes).In(loc)
+
 		showtime := api.Showtime{
 			Id:            v.ID,
-			StartDateTime: v.StartTime,
-			StartTime:     v.StartTime.Format("15:04"),
+			StartDateTime: startTime,
+			StartTime:     startTime.Format("15:04"),
+			OpenCaptions:  v.OpenCaptions,
+			Format:        api.ScreeningFormat(v.Format),
+			SalesCloseAt:  featureStart,
+		}
+
+		if v.OpenAt != nil {
+			salesOpenAt := v.OpenAt.In(loc)
+			showtime.SalesOpenAt = &salesOpenAt
+		}
+
+		if v.CloseAt != nil {
+			showtime.SalesCloseAt = v.CloseAt.In(loc)
 		}
 
 		if v.BasePrice.Valid {
@@ -326,10 +611,20 @@ func toShowtimes(showtimes []domain.Showtime) []api.Showtime {
 		}
 
 		// TODO: Add SOLD_OUT
-		if showtime.StartDateTime.Before(now) {
+		// the start time is an instant, so it's expired in every time zone at once. Tickets are sold during
+		// the pre-show, the showtime expires when the feature starts.
+		switch {
+		case !featureStart.After(now):
 			showtime.Status = api.EXPIRED
-		} else {
+		case v.SalesWindow.Check(now) != nil:
+			showtime.Status = api.NOTONSALE
+		default:
 			showtime.Status = api.AVAILABLE
+
+			if band, ok := bands[v.ID]; ok {
+				occupancy := api.OccupancyBand(band)
+				showtime.Occupancy = &occupancy
+			}
 		}
 
 		apiShowtimes[i] = showtime
@@ -3,6 +3,7 @@ package app
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/alexedwards/scs/v2"
 	"github.com/google/go-cmp/cmp"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/metinatakli/movie-reservation-system/api"
@@ -17,6 +19,9 @@ import (
 	"github.com/metinatakli/movie-reservation-system/internal/mocks"
 	"github.com/metinatakli/movie-reservation-system/internal/validator"
 	"github.com/oapi-codegen/runtime/types"
+	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestGetMovies(t *testing.T) {
@@ -89,6 +94,11 @@ func TestGetMovies(t *testing.T) {
 					LastPage:     1,
 					PageSize:     10,
 					TotalRecords: 2,
+					Links: api.PaginationLinks{
+						Self:  "/movies?page=1&pageSize=10",
+						First: "/movies?page=1&pageSize=10",
+						Last:  "/movies?page=1&pageSize=10",
+					},
 				},
 			},
 		},
@@ -138,6 +148,13 @@ func TestGetMovies(t *testing.T) {
 					LastPage:     3,
 					PageSize:     5,
 					TotalRecords: 11,
+					Links: api.PaginationLinks{
+						Self:  "/movies?page=2&pageSize=5&sort=title&term=action",
+						First: "/movies?page=1&pageSize=5&sort=title&term=action",
+						Last:  "/movies?page=3&pageSize=5&sort=title&term=action",
+						Next:  ptr("/movies?page=3&pageSize=5&sort=title&term=action"),
+						Prev:  ptr("/movies?page=1&pageSize=5&sort=title&term=action"),
+					},
 				},
 			},
 		},
@@ -209,6 +226,11 @@ func TestGetMovies(t *testing.T) {
 					LastPage:     1,
 					PageSize:     10,
 					TotalRecords: 0,
+					Links: api.PaginationLinks{
+						Self:  "/movies?page=1&pageSize=10",
+						First: "/movies?page=1&pageSize=10",
+						Last:  "/movies?page=1&pageSize=10",
+					},
 				},
 			},
 		},
@@ -282,6 +304,9 @@ func TestShowMovieDetails(t *testing.T) {
 						Exp:   -1,
 						Valid: true,
 					},
+					ContentWarnings:   []string{"violence", "flashing_lights"},
+					SubtitleLanguages: []string{"en", "de"},
+					AudioDescription:  true,
 				}, nil
 			},
 			wantStatus: http.StatusOK,
@@ -294,6 +319,12 @@ func TestShowMovieDetails(t *testing.T) {
 				Director:    "John Doe",
 				Cast:        []string{"Actor One", "Actor Two"},
 				Rating:      ptr(float32(8.5)),
+				ContentWarnings: []api.ContentWarning{
+					api.Violence,
+					api.FlashingLights,
+				},
+				SubtitleLanguages: []string{"en", "de"},
+				AudioDescription:  true,
 			},
 		},
 		{
@@ -364,13 +395,16 @@ func TestGetMovieShowtimes(t *testing.T) {
 	futureTime := now.Add(24 * time.Hour)
 	pastTime := now.Add(-24 * time.Hour)
 
+	today := now.Format(time.DateOnly)
+	todayDate, _ := time.Parse(time.DateOnly, today)
+
 	tests := []struct {
 		name            string
 		id              int
 		params          api.GetMovieShowtimesParams
 		url             string
 		existsByIdFunc  func(context.Context, int) (bool, error)
-		getTheatersFunc func(context.Context, int, time.Time, float64, float64, domain.Pagination) ([]domain.Theater, *domain.Metadata, error)
+		getTheatersFunc func(context.Context, int, time.Time, float64, float64, domain.ShowtimeFilter, domain.Pagination) ([]domain.Theater, *domain.Metadata, error)
 		wantStatus      int
 		wantErrMessage  string
 		wantResponse    *api.MovieShowtimesResponse
@@ -379,7 +413,7 @@ func TestGetMovieShowtimes(t *testing.T) {
 			name: "invalid movie ID",
 			id:   0,
 			params: api.GetMovieShowtimesParams{
-				Date:      ptr("2024-03-20"),
+				Date:      ptr(today),
 				Latitude:  ptr(39.990067),
 				Longitude: ptr(32.643482),
 			},
@@ -399,11 +433,125 @@ func TestGetMovieShowtimes(t *testing.T) {
 			wantStatus:     http.StatusUnprocessableEntity,
 			wantErrMessage: validator.ErrDefaultInvalid,
 		},
+		{
+			name: "latitude out of range",
+			id:   1,
+			params: api.GetMovieShowtimesParams{
+				Date:      ptr(today),
+				Latitude:  ptr(91.0),
+				Longitude: ptr(32.643482),
+			},
+			url:            "/movies/1/showtimes?latitude=91",
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: validator.ErrLatitude,
+		},
+		{
+			name: "longitude out of range",
+			id:   1,
+			params: api.GetMovieShowtimesParams{
+				Date:      ptr(today),
+				Latitude:  ptr(39.990067),
+				Longitude: ptr(-180.5),
+			},
+			url:            "/movies/1/showtimes?longitude=-180.5",
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: validator.ErrLongitude,
+		},
+		{
+			// the day before yesterday has ended in every time zone, yesterday may not have
+			name: "date in the past",
+			id:   1,
+			params: api.GetMovieShowtimesParams{
+				Date:      ptr(now.AddDate(0, 0, -2).Format(time.DateOnly)),
+				Latitude:  ptr(39.990067),
+				Longitude: ptr(32.643482),
+			},
+			url:            "/movies/1/showtimes",
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: validator.ErrPastDate,
+		},
+		{
+			name: "date beyond the scheduling horizon",
+			id:   1,
+			params: api.GetMovieShowtimesParams{
+				Date:      ptr(now.Add(validator.DefaultSchedulingHorizon).AddDate(0, 0, 2).Format(time.DateOnly)),
+				Latitude:  ptr(39.990067),
+				Longitude: ptr(32.643482),
+			},
+			url:            "/movies/1/showtimes",
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: validator.ErrBeyondHorizon,
+		},
+		{
+			name: "last day of the scheduling horizon",
+			id:   1,
+			params: api.GetMovieShowtimesParams{
+				Date:      ptr(now.Add(validator.DefaultSchedulingHorizon).Format(time.DateOnly)),
+				Latitude:  ptr(39.990067),
+				Longitude: ptr(32.643482),
+			},
+			url: "/movies/1/showtimes",
+			existsByIdFunc: func(ctx context.Context, id int) (bool, error) {
+				return false, nil
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+		},
+		{
+			name: "invalid accessibility feature",
+			id:   1,
+			params: api.GetMovieShowtimesParams{
+				Date:          ptr(today),
+				Latitude:      ptr(39.990067),
+				Longitude:     ptr(32.643482),
+				Accessibility: ptr(api.AccessibilityFeature("SIGN_LANGUAGE")),
+			},
+			url:            "/movies/1/showtimes?date=" + today + "&accessibility=SIGN_LANGUAGE",
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: fmt.Sprintf(validator.ErrOneOf, "OPEN_CAPTIONS AUDIO_DESCRIPTION SUBTITLES"),
+		},
+		{
+			name: "filtered by accessibility feature",
+			id:   1,
+			params: api.GetMovieShowtimesParams{
+				Date:          ptr(today),
+				Latitude:      ptr(39.990067),
+				Longitude:     ptr(32.643482),
+				Accessibility: ptr(api.OPENCAPTIONS),
+			},
+			url: "/movies/1/showtimes?date=" + today + "&accessibility=OPEN_CAPTIONS",
+			existsByIdFunc: func(ctx context.Context, id int) (bool, error) {
+				return true, nil
+			},
+			getTheatersFunc: func(ctx context.Context, movieID int, date time.Time, lon, lat float64, filter domain.ShowtimeFilter, pagination domain.Pagination) (
+				[]domain.Theater,
+				*domain.Metadata,
+				error,
+			) {
+				if filter.Accessibility != domain.AccessibilityOpenCaptions {
+					return nil, nil, fmt.Errorf("unexpected filter %+v", filter)
+				}
+
+				return []domain.Theater{}, &domain.Metadata{}, nil
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: &api.MovieShowtimesResponse{
+				Date:     types.Date{Time: todayDate},
+				Theaters: []api.TheaterShowtimes{},
+				Metadata: &api.Metadata{
+					Links: api.PaginationLinks{
+						Self:  "/movies/1/showtimes?accessibility=OPEN_CAPTIONS&date=" + today + "&page=0&pageSize=0",
+						First: "/movies/1/showtimes?accessibility=OPEN_CAPTIONS&date=" + today + "&page=0&pageSize=0",
+						Last:  "/movies/1/showtimes?accessibility=OPEN_CAPTIONS&date=" + today + "&page=0&pageSize=0",
+					},
+				},
+			},
+		},
 		{
 			name: "movie with given id does not exist",
 			id:   1,
 			params: api.GetMovieShowtimesParams{
-				Date:      ptr("2024-03-20"),
+				Date:      ptr(today),
 				Latitude:  ptr(39.990067),
 				Longitude: ptr(32.643482),
 			},
@@ -418,7 +566,7 @@ func TestGetMovieShowtimes(t *testing.T) {
 			name: "database error",
 			id:   1,
 			params: api.GetMovieShowtimesParams{
-				Date:      ptr("2024-03-20"),
+				Date:      ptr(today),
 				Latitude:  ptr(39.990067),
 				Longitude: ptr(32.643482),
 			},
@@ -426,7 +574,7 @@ func TestGetMovieShowtimes(t *testing.T) {
 			existsByIdFunc: func(ctx context.Context, id int) (bool, error) {
 				return true, nil
 			},
-			getTheatersFunc: func(ctx context.Context, movieID int, date time.Time, lon, lat float64, pagination domain.Pagination) (
+			getTheatersFunc: func(ctx context.Context, movieID int, date time.Time, lon, lat float64, filter domain.ShowtimeFilter, pagination domain.Pagination) (
 				[]domain.Theater,
 				*domain.Metadata,
 				error,
@@ -440,7 +588,7 @@ func TestGetMovieShowtimes(t *testing.T) {
 			name: "empty result",
 			id:   1,
 			params: api.GetMovieShowtimesParams{
-				Date:      ptr("2024-03-20"),
+				Date:      ptr(today),
 				Latitude:  ptr(39.990067),
 				Longitude: ptr(32.643482),
 			},
@@ -448,7 +596,7 @@ func TestGetMovieShowtimes(t *testing.T) {
 			existsByIdFunc: func(ctx context.Context, id int) (bool, error) {
 				return true, nil
 			},
-			getTheatersFunc: func(ctx context.Context, movieID int, date time.Time, lon, lat float64, pagination domain.Pagination) (
+			getTheatersFunc: func(ctx context.Context, movieID int, date time.Time, lon, lat float64, filter domain.ShowtimeFilter, pagination domain.Pagination) (
 				[]domain.Theater,
 				*domain.Metadata,
 				error,
@@ -463,7 +611,7 @@ func TestGetMovieShowtimes(t *testing.T) {
 			},
 			wantStatus: http.StatusOK,
 			wantResponse: &api.MovieShowtimesResponse{
-				Date:     types.Date{Time: time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)},
+				Date:     types.Date{Time: todayDate},
 				Theaters: []api.TheaterShowtimes{},
 				Metadata: &api.Metadata{
 					CurrentPage:  1,
@@ -471,6 +619,11 @@ func TestGetMovieShowtimes(t *testing.T) {
 					LastPage:     1,
 					PageSize:     10,
 					TotalRecords: 0,
+					Links: api.PaginationLinks{
+						Self:  "/movies/1/showtimes?page=1&pageSize=10",
+						First: "/movies/1/showtimes?page=1&pageSize=10",
+						Last:  "/movies/1/showtimes?page=1&pageSize=10",
+					},
 				},
 			},
 		},
@@ -478,15 +631,15 @@ func TestGetMovieShowtimes(t *testing.T) {
 			name: "successful retrieval",
 			id:   1,
 			params: api.GetMovieShowtimesParams{
-				Date:      ptr("2024-03-20"),
+				Date:      ptr(today),
 				Latitude:  ptr(39.990067),
 				Longitude: ptr(32.643482),
 			},
-			url: "/movies/1/showtimes?date=2024-03-20&latitude=39.990067&longitude=32.643482",
+			url: "/movies/1/showtimes?date=" + today + "&latitude=39.990067&longitude=32.643482",
 			existsByIdFunc: func(ctx context.Context, id int) (bool, error) {
 				return true, nil
 			},
-			getTheatersFunc: func(ctx context.Context, movieID int, date time.Time, lon, lat float64, pagination domain.Pagination) (
+			getTheatersFunc: func(ctx context.Context, movieID int, date time.Time, lon, lat float64, filter domain.ShowtimeFilter, pagination domain.Pagination) (
 				[]domain.Theater,
 				*domain.Metadata,
 				error,
@@ -518,6 +671,7 @@ func TestGetMovieShowtimes(t *testing.T) {
 											Exp:   0,
 											Valid: true,
 										},
+										OpenCaptions: true,
 									},
 									{
 										ID:        2,
@@ -544,7 +698,7 @@ func TestGetMovieShowtimes(t *testing.T) {
 			},
 			wantStatus: http.StatusOK,
 			wantResponse: &api.MovieShowtimesResponse{
-				Date: types.Date{Time: time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)},
+				Date: types.Date{Time: todayDate},
 				Theaters: []api.TheaterShowtimes{
 					{
 						Id:       1,
@@ -568,13 +722,17 @@ func TestGetMovieShowtimes(t *testing.T) {
 										Id:            1,
 										StartDateTime: futureTime,
 										StartTime:     futureTime.Format("15:04"),
+										SalesCloseAt:  futureTime,
 										Price:         50,
 										Status:        api.AVAILABLE,
+										OpenCaptions:  true,
+										Occupancy:     ptr(api.FillingFast),
 									},
 									{
 										Id:            2,
 										StartDateTime: pastTime,
 										StartTime:     pastTime.Format("15:04"),
+										SalesCloseAt:  pastTime,
 										Price:         50,
 										Status:        api.EXPIRED,
 									},
@@ -589,6 +747,11 @@ func TestGetMovieShowtimes(t *testing.T) {
 					LastPage:     1,
 					PageSize:     10,
 					TotalRecords: 1,
+					Links: api.PaginationLinks{
+						Self:  "/movies/1/showtimes?date=" + today + "&latitude=39.990067&longitude=32.643482&page=1&pageSize=10",
+						First: "/movies/1/showtimes?date=" + today + "&latitude=39.990067&longitude=32.643482&page=1&pageSize=10",
+						Last:  "/movies/1/showtimes?date=" + today + "&latitude=39.990067&longitude=32.643482&page=1&pageSize=10",
+					},
 				},
 			},
 		},
@@ -596,9 +759,19 @@ func TestGetMovieShowtimes(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			redisClient := &mocks.MockRedisClient{}
+			redisClient.On("MGet", mock.Anything, []string{"occupancy_band:1"}).
+				Return(redis.NewSliceResult([]interface{}{nil}, nil)).Maybe()
+			redisClient.On("Set", mock.Anything, "occupancy_band:1", "filling_fast", occupancyBandCacheTTL).
+				Return(redis.NewStatusResult("OK", nil)).Maybe()
+
 			app := newTestApplication(func(a *Application) {
+				a.redis = redisClient
 				a.theaterRepo = &mocks.MockTheaterRepo{
 					GetTheatersByMovieAndLocationAndDateFunc: tt.getTheatersFunc,
+					GetSeatAvailabilityFunc: func(ctx context.Context, showtimeIDs []int) ([]domain.SeatAvailability, error) {
+						return []domain.SeatAvailability{{ShowtimeID: 1, Total: 100, Available: 30}}, nil
+					},
 				}
 				a.movieRepo = &mocks.MockMovieRepo{
 					ExistsByIdFunc: tt.existsByIdFunc,
@@ -636,3 +809,634 @@ func TestGetMovieShowtimes(t *testing.T) {
 		})
 	}
 }
+
+func TestGetMovieShowtimesDefaultLocation(t *testing.T) {
+	today := time.Now().Format(time.DateOnly)
+
+	tests := []struct {
+		name         string
+		setupSession bool
+		getPrefsFunc func(context.Context, int) (*domain.UserPreferences, error)
+		address      *string
+		geocoder     domain.Geocoder
+		wantStatus   int
+		wantLat      float64
+		wantLong     float64
+	}{
+		{
+			name:       "guest without location",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "user without preferences",
+			setupSession: true,
+			getPrefsFunc: func(ctx context.Context, id int) (*domain.UserPreferences, error) {
+				return nil, domain.ErrRecordNotFound
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "uses stored coordinates",
+			setupSession: true,
+			getPrefsFunc: func(ctx context.Context, id int) (*domain.UserPreferences, error) {
+				return &domain.UserPreferences{
+					UserID:                   1,
+					Latitude:                 ptr(39.990067),
+					Longitude:                ptr(32.643482),
+					FavoriteTheaterLatitude:  ptr(41.0),
+					FavoriteTheaterLongitude: ptr(29.0),
+				}, nil
+			},
+			wantStatus: http.StatusOK,
+			wantLat:    39.990067,
+			wantLong:   32.643482,
+		},
+		{
+			name:         "falls back to favorite theater location",
+			setupSession: true,
+			getPrefsFunc: func(ctx context.Context, id int) (*domain.UserPreferences, error) {
+				return &domain.UserPreferences{
+					UserID:                   1,
+					FavoriteTheaterID:        ptr(2),
+					FavoriteTheaterLatitude:  ptr(41.0),
+					FavoriteTheaterLongitude: ptr(29.0),
+				}, nil
+			},
+			wantStatus: http.StatusOK,
+			wantLat:    41.0,
+			wantLong:   29.0,
+		},
+		{
+			name:       "address without geocoder",
+			address:    ptr("Kadikoy"),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:    "unresolvable address",
+			address: ptr("Nowhere"),
+			geocoder: stubGeocoder(func(address string) (*domain.GeoPoint, error) {
+				return nil, domain.ErrAddressNotFound
+			}),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:    "geocoder busy",
+			address: ptr("Kadikoy"),
+			geocoder: stubGeocoder(func(address string) (*domain.GeoPoint, error) {
+				return nil, domain.ErrGeocoderBusy
+			}),
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:         "address takes precedence over preferences",
+			setupSession: true,
+			address:      ptr("Kadikoy"),
+			geocoder: stubGeocoder(func(address string) (*domain.GeoPoint, error) {
+				return &domain.GeoPoint{Latitude: 40.99, Longitude: 29.03}, nil
+			}),
+			wantStatus: http.StatusOK,
+			wantLat:    40.99,
+			wantLong:   29.03,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotLat, gotLong float64
+
+			app := newTestApplication(func(a *Application) {
+				a.sessionManager = scs.New()
+				a.geocoder = tt.geocoder
+				a.userRepo = &mocks.MockUserRepo{
+					GetPreferencesFunc: tt.getPrefsFunc,
+				}
+				a.movieRepo = &mocks.MockMovieRepo{
+					ExistsByIdFunc: func(ctx context.Context, id int) (bool, error) {
+						return true, nil
+					},
+				}
+				a.theaterRepo = &mocks.MockTheaterRepo{
+					GetTheatersByMovieAndLocationAndDateFunc: func(ctx context.Context, movieID int, date time.Time, lon, lat float64, filter domain.ShowtimeFilter, pagination domain.Pagination) (
+						[]domain.Theater,
+						*domain.Metadata,
+						error,
+					) {
+						gotLat, gotLong = lat, lon
+						return []domain.Theater{}, &domain.Metadata{}, nil
+					},
+				}
+			})
+
+			w, r := executeRequest(t, http.MethodGet, "/movies/1/showtimes?date="+today, nil)
+
+			if tt.setupSession {
+				r = setupTestSession(t, app, r, 1)
+			}
+
+			handler := app.sessionManager.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				app.GetMovieShowtimes(w, r, 1, api.GetMovieShowtimesParams{Date: ptr(today), Address: tt.address})
+			}))
+			handler.ServeHTTP(w, r)
+
+			if got := w.Code; got != tt.wantStatus {
+				t.Fatalf("GetMovieShowtimes() status = %v, want %v", got, tt.wantStatus)
+			}
+
+			if gotLat != tt.wantLat || gotLong != tt.wantLong {
+				t.Errorf("location = (%v, %v), want (%v, %v)", gotLat, gotLong, tt.wantLat, tt.wantLong)
+			}
+		})
+	}
+}
+
+type stubGeocoder func(address string) (*domain.GeoPoint, error)
+
+func (g stubGeocoder) Geocode(ctx context.Context, address string) (*domain.GeoPoint, error) {
+	return g(address)
+}
+
+func TestGetMovieNextShowtime(t *testing.T) {
+	startTime := time.Date(2030, 5, 1, 20, 0, 0, 0, time.UTC)
+
+	next := &domain.NextShowtime{
+		ShowtimeID:      7,
+		StartTime:       startTime,
+		Format:          domain.Format2D,
+		Price:           decimal.RequireFromString("12.50"),
+		MovieID:         1,
+		MovieTitle:      "Inception",
+		TheaterID:       3,
+		TheaterName:     "Cinema One",
+		TheaterAddress:  "Main Street 1",
+		TheaterCity:     "Ankara",
+		TheaterDistrict: "Cankaya",
+		HallName:        "Hall 2",
+		DistanceKm:      1.5,
+		AvailableSeats:  42,
+	}
+
+	tests := []struct {
+		name           string
+		id             int
+		params         api.GetMovieNextShowtimeParams
+		setupSession   bool
+		getPrefsFunc   func(context.Context, int) (*domain.UserPreferences, error)
+		getNextFunc    func(context.Context, int, float64, float64, time.Time) (*domain.NextShowtime, error)
+		wantStatus     int
+		wantErrMessage string
+		wantLat        float64
+		wantLong       float64
+		wantResponse   *api.NextShowtimeResponse
+	}{
+		{
+			name:           "invalid movie ID",
+			id:             0,
+			params:         api.GetMovieNextShowtimeParams{Latitude: ptr(39.99), Longitude: ptr(32.64)},
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "movie ID must be greater than zero",
+		},
+		{
+			name:           "latitude out of range",
+			id:             1,
+			params:         api.GetMovieNextShowtimeParams{Latitude: ptr(91.0), Longitude: ptr(32.64)},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: validator.ErrLatitude,
+		},
+		{
+			name:       "guest without location",
+			id:         1,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "no bookable showtime nearby",
+			id:   1,
+			params: api.GetMovieNextShowtimeParams{
+				Latitude:  ptr(39.99),
+				Longitude: ptr(32.64),
+			},
+			getNextFunc: func(ctx context.Context, movieID int, long, lat float64, after time.Time) (*domain.NextShowtime, error) {
+				return nil, domain.ErrRecordNotFound
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+			wantLat:        39.99,
+			wantLong:       32.64,
+		},
+		{
+			name: "database error",
+			id:   1,
+			params: api.GetMovieNextShowtimeParams{
+				Latitude:  ptr(39.99),
+				Longitude: ptr(32.64),
+			},
+			getNextFunc: func(ctx context.Context, movieID int, long, lat float64, after time.Time) (*domain.NextShowtime, error) {
+				return nil, errors.New("database error")
+			},
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+			wantLat:        39.99,
+			wantLong:       32.64,
+		},
+		{
+			name:         "uses stored coordinates",
+			id:           1,
+			setupSession: true,
+			getPrefsFunc: func(ctx context.Context, id int) (*domain.UserPreferences, error) {
+				return &domain.UserPreferences{UserID: 1, Latitude: ptr(41.0), Longitude: ptr(29.0)}, nil
+			},
+			getNextFunc: func(ctx context.Context, movieID int, long, lat float64, after time.Time) (*domain.NextShowtime, error) {
+				return next, nil
+			},
+			wantStatus: http.StatusOK,
+			wantLat:    41.0,
+			wantLong:   29.0,
+		},
+		{
+			name: "returns the next showtime with links",
+			id:   1,
+			params: api.GetMovieNextShowtimeParams{
+				Latitude:  ptr(39.99),
+				Longitude: ptr(32.64),
+			},
+			getNextFunc: func(ctx context.Context, movieID int, long, lat float64, after time.Time) (*domain.NextShowtime, error) {
+				return next, nil
+			},
+			wantStatus: http.StatusOK,
+			wantLat:    39.99,
+			wantLong:   32.64,
+			wantResponse: &api.NextShowtimeResponse{
+				ShowtimeId:     7,
+				StartTime:      startTime,
+				Format:         api.N2D,
+				Price:          decimal.RequireFromString("12.50"),
+				AvailableSeats: 42,
+				Movie:          api.NextShowtimeMovie{Id: 1, Title: "Inception"},
+				Theater: api.NextShowtimeTheater{
+					Id:         3,
+					Name:       "Cinema One",
+					Address:    "Main Street 1",
+					City:       "Ankara",
+					District:   "Cankaya",
+					DistanceKm: 1.5,
+				},
+				HallName: "Hall 2",
+				Links: api.NextShowtimeLinks{
+					SeatMap: "https://api.example.com/showtimes/7/seat-map",
+					Cart:    "https://api.example.com/showtimes/7/cart",
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotLat, gotLong float64
+
+			app := newTestApplication(func(a *Application) {
+				a.config.BaseURL = "https://api.example.com/"
+				a.sessionManager = scs.New()
+				a.userRepo = &mocks.MockUserRepo{
+					GetPreferencesFunc: tt.getPrefsFunc,
+				}
+				a.theaterRepo = &mocks.MockTheaterRepo{
+					GetNextShowtimeFunc: func(ctx context.Context, movieID int, long, lat float64, after time.Time) (*domain.NextShowtime, error) {
+						gotLat, gotLong = lat, long
+						return tt.getNextFunc(ctx, movieID, long, lat, after)
+					},
+				}
+			})
+
+			w, r := executeRequest(t, http.MethodGet, fmt.Sprintf("/movies/%d/next-showtime", tt.id), nil)
+
+			if tt.setupSession {
+				r = setupTestSession(t, app, r, 1)
+			}
+
+			handler := app.sessionManager.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				app.GetMovieNextShowtime(w, r, tt.id, tt.params)
+			}))
+			handler.ServeHTTP(w, r)
+
+			if got := w.Code; got != tt.wantStatus {
+				t.Fatalf("GetMovieNextShowtime() status = %v, want %v", got, tt.wantStatus)
+			}
+
+			if gotLat != tt.wantLat || gotLong != tt.wantLong {
+				t.Errorf("location = (%v, %v), want (%v, %v)", gotLat, gotLong, tt.wantLat, tt.wantLong)
+			}
+
+			if tt.wantResponse != nil {
+				var response api.NextShowtimeResponse
+
+				err := json.NewDecoder(w.Body).Decode(&response)
+				if err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+
+				decimalEqual := cmp.Comparer(func(a, b decimal.Decimal) bool { return a.Equal(b) })
+				if diff := cmp.Diff(tt.wantResponse, &response, decimalEqual); diff != "" {
+					t.Errorf("GetMovieNextShowtime() response mismatch (-want +got):\n%s", diff)
+				}
+			}
+
+			if tt.wantErrMessage != "" {
+				checkErrorResponse(t, w, struct {
+					wantStatus     int
+					wantErrMessage string
+				}{
+					wantStatus:     tt.wantStatus,
+					wantErrMessage: tt.wantErrMessage,
+				})
+			}
+		})
+	}
+}
+
+func TestArchiveAndRestoreMovie(t *testing.T) {
+	tests := []struct {
+		name           string
+		restore        bool
+		id             int
+		repoErr        error
+		wantStatus     int
+		wantErrMessage string
+	}{
+		{
+			name:           "invalid ID",
+			id:             0,
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "movie ID must be greater than zero",
+		},
+		{
+			name:           "movie not found",
+			id:             2,
+			repoErr:        domain.ErrRecordNotFound,
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+		},
+		{
+			name:           "server error",
+			id:             1,
+			repoErr:        errors.New("database error"),
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+		{
+			name:       "archives the movie",
+			id:         1,
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "restores the movie",
+			restore:    true,
+			id:         1,
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:           "restoring an unknown movie",
+			restore:        true,
+			id:             2,
+			repoErr:        domain.ErrRecordNotFound,
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var archived, restored []int
+
+			// the cached copies of the catalog are revalidated once it changed
+			redisClient := new(mocks.MockRedisClient)
+			if tt.wantStatus == http.StatusNoContent {
+				redisClient.On("Set", mock.Anything, catalogModifiedKey, mock.Anything, time.Duration(0)).
+					Return(redis.NewStatusResult("OK", nil)).Once()
+			}
+
+			app := newTestApplication(func(a *Application) {
+				a.redis = redisClient
+				a.movieRepo = &mocks.MockMovieRepo{
+					ArchiveFunc: func(ctx context.Context, id int) error {
+						archived = append(archived, id)
+						return tt.repoErr
+					},
+					RestoreFunc: func(ctx context.Context, id int) error {
+						restored = append(restored, id)
+						return tt.repoErr
+					},
+				}
+			})
+
+			method, url, handler := http.MethodDelete, fmt.Sprintf("/admin/movies/%d", tt.id), app.ArchiveMovie
+			if tt.restore {
+				method, url, handler = http.MethodPost, fmt.Sprintf("/admin/movies/%d/restore", tt.id), app.RestoreMovie
+			}
+
+			w, r := executeRequest(t, method, url, nil)
+			handler(w, r, tt.id)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			checkErrorResponse(t, w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+
+			if tt.id < 1 && len(archived)+len(restored) > 0 {
+				t.Errorf("repository called for an invalid ID")
+			}
+
+			if tt.wantStatus == http.StatusNoContent {
+				want := []int{tt.id}
+				got := archived
+				if tt.restore {
+					got = restored
+				}
+
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Errorf("updated movies mismatch (-want +got):\n%s", diff)
+				}
+			}
+
+			redisClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCreateMovie(t *testing.T) {
+	releaseDate := time.Date(2021, 10, 22, 0, 0, 0, 0, time.UTC)
+
+	validInput := api.CreateMovieRequest{
+		Name:        " Dune ",
+		PosterUrl:   "https://example.com/dune.jpg",
+		ReleaseDate: types.Date{Time: releaseDate},
+		Description: "A noble family becomes embroiled in a war for control over the galaxy's most valuable asset.",
+		Runtime:     155,
+		Genres:      []string{"Sci-Fi"},
+		Language:    "English",
+		Director:    "Denis Villeneuve",
+		Cast:        []string{"Timothée Chalamet"},
+	}
+
+	duplicate := domain.MovieDuplicate{ID: 7, Title: "Dune.", ReleaseDate: releaseDate, Similarity: 1, Archived: true}
+
+	tests := []struct {
+		name           string
+		input          api.CreateMovieRequest
+		force          bool
+		duplicates     []domain.MovieDuplicate
+		findErr        error
+		createErr      error
+		wantStatus     int
+		wantErrMessage string
+		wantDuplicates []api.DuplicateMovie
+		wantCreated    bool
+		wantLookup     bool
+	}{
+		{
+			name:       "invalid input",
+			input:      api.CreateMovieRequest{Name: "Dune"},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "duplicate lookup fails",
+			input:          validInput,
+			findErr:        errors.New("database error"),
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+			wantLookup:     true,
+		},
+		{
+			name:       "reports possible duplicates",
+			input:      validInput,
+			duplicates: []domain.MovieDuplicate{duplicate},
+			wantStatus: http.StatusConflict,
+			wantDuplicates: []api.DuplicateMovie{
+				{Id: 7, Name: "Dune.", ReleaseDate: types.Date{Time: releaseDate}, Similarity: 1, Archived: true},
+			},
+			wantLookup: true,
+		},
+		{
+			name:        "creates the movie without duplicates",
+			input:       validInput,
+			duplicates:  []domain.MovieDuplicate{},
+			wantStatus:  http.StatusCreated,
+			wantCreated: true,
+			wantLookup:  true,
+		},
+		{
+			name:        "creates the movie despite duplicates when forced",
+			input:       validInput,
+			force:       true,
+			duplicates:  []domain.MovieDuplicate{duplicate},
+			wantStatus:  http.StatusCreated,
+			wantCreated: true,
+		},
+		{
+			name:           "create fails",
+			input:          validInput,
+			duplicates:     []domain.MovieDuplicate{},
+			createErr:      errors.New("database error"),
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+			wantLookup:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lookedUp bool
+			var created *domain.Movie
+
+			redisClient := new(mocks.MockRedisClient)
+			if tt.wantCreated {
+				redisClient.On("Set", mock.Anything, catalogModifiedKey, mock.Anything, time.Duration(0)).
+					Return(redis.NewStatusResult("OK", nil)).Once()
+			}
+
+			app := newTestApplication(func(a *Application) {
+				a.redis = redisClient
+				a.movieRepo = &mocks.MockMovieRepo{
+					FindDuplicatesFunc: func(ctx context.Context, title string, releaseYear int) ([]domain.MovieDuplicate, error) {
+						lookedUp = true
+
+						if title != "Dune" || releaseYear != 2021 {
+							t.Errorf("FindDuplicates(%q, %d), want (%q, %d)", title, releaseYear, "Dune", 2021)
+						}
+
+						return tt.duplicates, tt.findErr
+					},
+					CreateFunc: func(ctx context.Context, movie *domain.Movie) error {
+						created = movie
+						movie.ID = 12
+						return tt.createErr
+					},
+				}
+			})
+
+			w, r := executeRequest(t, http.MethodPost, "/admin/movies", tt.input)
+			app.CreateMovie(w, r, api.CreateMovieParams{Force: &tt.force})
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			if lookedUp != tt.wantLookup {
+				t.Errorf("duplicates looked up = %v, want %v", lookedUp, tt.wantLookup)
+			}
+
+			if tt.wantErrMessage != "" {
+				checkErrorResponse(t, w, struct {
+					wantStatus     int
+					wantErrMessage string
+				}{
+					wantStatus:     tt.wantStatus,
+					wantErrMessage: tt.wantErrMessage,
+				})
+			}
+
+			if tt.wantDuplicates != nil {
+				var resp api.DuplicateMoviesResponse
+				if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+					t.Fatal(err)
+				}
+
+				if resp.Code != api.POSSIBLEDUPLICATEMOVIE {
+					t.Errorf("code = %s, want %s", resp.Code, api.POSSIBLEDUPLICATEMOVIE)
+				}
+
+				if diff := cmp.Diff(tt.wantDuplicates, resp.Duplicates); diff != "" {
+					t.Errorf("duplicates mismatch (-want +got):\n%s", diff)
+				}
+
+				if created != nil {
+					t.Error("movie created despite duplicates")
+				}
+			}
+
+			if !tt.wantCreated {
+				return
+			}
+
+			var resp api.MovieDetailsResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.Id != 12 || resp.Name != "Dune" {
+				t.Errorf("response = %+v, want the created movie", resp)
+			}
+
+			if created.ContentWarnings == nil || created.SubtitleLanguages == nil {
+				t.Error("optional lists of the movie must not be nil")
+			}
+
+			redisClient.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/oapi-codegen/runtime/types"
+)
+
+const (
+	nowPlayingBoardCacheTTL = time.Minute
+	// screens may keep showing the previous board this long while they fetch the next one
+	nowPlayingBoardStaleTTL = 5 * time.Minute
+)
+
+var (
+	// a lobby screen refreshes every minute or so, the anonymous limit leaves room for a few screens per
+	// address. Partners showing the boards of many theaters are limited by their key instead.
+	nowPlayingBoardClientLimit  = rateLimit{name: "now_playing_board_client", limit: 30, window: time.Minute}
+	nowPlayingBoardPartnerLimit = rateLimit{name: "now_playing_board_partner", limit: 600, window: time.Minute}
+)
+
+// GetNowPlayingBoard returns today's schedule of the theater for the screens in its lobby. The board is
+// public, an API key only raises the rate limit, but a wrong key is rejected rather than ignored.
+func (app *Application) GetNowPlayingBoard(
+	w http.ResponseWriter,
+	r *http.Request,
+	theaterId int,
+	params api.GetNowPlayingBoardParams) {
+
+	if theaterId < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("theater ID must be greater than zero"))
+		return
+	}
+
+	rl, key := nowPlayingBoardClientLimit, clientIP(r)
+
+	if params.XAPIKey != nil {
+		partner, ok := app.partnerByKey(*params.XAPIKey)
+		if !ok {
+			app.unauthorizedAccessResponse(w, r)
+			return
+		}
+
+		rl, key = nowPlayingBoardPartnerLimit, partner
+	}
+
+	if !app.allowRequest(w, r, rl, key) {
+		return
+	}
+
+	board, err := app.cachedFeed(r.Context(), nowPlayingBoardFeedName(theaterId), nowPlayingBoardCacheTTL,
+		func(ctx context.Context) (string, error) {
+			return app.renderNowPlayingBoard(ctx, theaterId)
+		})
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrTheaterNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, stale-while-revalidate=%d",
+		int(nowPlayingBoardCacheTTL.Seconds()), int(nowPlayingBoardStaleTTL.Seconds())))
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(board))
+}
+
+func (app *Application) renderNowPlayingBoard(ctx context.Context, theaterId int) (string, error) {
+	now := time.Now()
+
+	board, err := app.theaterRepo.GetNowPlayingBoard(ctx, theaterId, now)
+	if err != nil {
+		return "", err
+	}
+
+	loc := domain.TheaterLocation(board.TimeZone)
+
+	resp := api.NowPlayingBoard{
+		TheaterId:   board.TheaterID,
+		TheaterName: board.TheaterName,
+		TimeZone:    board.TimeZone,
+		Date:        types.Date{Time: board.Date},
+		GeneratedAt: now.In(loc),
+		Showtimes:   make([]api.BoardShowtime, len(board.Showtimes)),
+	}
+
+	for i, s := range board.Showtimes {
+		resp.Showtimes[i] = api.BoardShowtime{
+			ShowtimeId:   s.ShowtimeID,
+			MovieId:      s.MovieID,
+			MovieTitle:   s.MovieTitle,
+			PosterUrl:    s.MoviePosterUrl,
+			Runtime:      s.MovieDuration,
+			HallName:     s.HallName,
+			StartTime:    s.StartTime.In(loc),
+			Format:       api.ScreeningFormat(s.Format),
+			OpenCaptions: s.OpenCaptions,
+			SoldOut:      s.SoldOut,
+		}
+	}
+
+	out, err := json.Marshal(resp)
+	if err != nil {
+		return "", err
+	}
+
+	return string(out), nil
+}
+
+func nowPlayingBoardFeedName(theaterId int) string {
+	return fmt.Sprintf("now_playing_board:%d", theaterId)
+}
@@ -0,0 +1,159 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type NowPlayingBoardTestSuite struct {
+	suite.Suite
+	app         *Application
+	theaterRepo *mocks.MockTheaterRepo
+	redisClient *mocks.MockRedisClient
+}
+
+func (s *NowPlayingBoardTestSuite) SetupTest() {
+	s.theaterRepo = &mocks.MockTheaterRepo{}
+	s.redisClient = new(mocks.MockRedisClient)
+
+	s.app = newTestApplication(func(a *Application) {
+		a.theaterRepo = s.theaterRepo
+		a.redis = s.redisClient
+		a.config.PartnerAPIKeys = map[string]string{"partner-key": "acme"}
+	})
+}
+
+func TestNowPlayingBoardSuite(t *testing.T) {
+	suite.Run(t, new(NowPlayingBoardTestSuite))
+}
+
+func (s *NowPlayingBoardTestSuite) getBoard(theaterId int, apiKey *string) *http.Response {
+	w, r := executeRequest(s.T(), http.MethodGet, "/theaters/1/now-playing-board", nil)
+	r.RemoteAddr = "192.0.2.1:51234"
+
+	s.app.GetNowPlayingBoard(w, r, theaterId, api.GetNowPlayingBoardParams{XAPIKey: apiKey})
+
+	return w.Result()
+}
+
+func (s *NowPlayingBoardTestSuite) TestRejectsInvalidTheaterId() {
+	resp := s.getBoard(0, nil)
+
+	s.Equal(http.StatusBadRequest, resp.StatusCode)
+}
+
+func (s *NowPlayingBoardTestSuite) TestRejectsUnknownAPIKey() {
+	resp := s.getBoard(1, ptr("wrong-key"))
+
+	s.Equal(http.StatusUnauthorized, resp.StatusCode)
+	s.redisClient.AssertNotCalled(s.T(), "EvalSha", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *NowPlayingBoardTestSuite) TestLimitsAnonymousClients() {
+	allowRateLimit(s.redisClient, "rate_limit:now_playing_board_client:192.0.2.1", 30000)
+
+	resp := s.getBoard(1, nil)
+
+	s.Equal(http.StatusTooManyRequests, resp.StatusCode)
+	s.Equal("30", resp.Header.Get("Retry-After"))
+}
+
+func (s *NowPlayingBoardTestSuite) TestServesCachedBoardToPartner() {
+	allowRateLimit(s.redisClient, "rate_limit:now_playing_board_partner:acme", 0)
+	s.redisClient.On("Get", mock.Anything, "feeds:now_playing_board:1:0").Return(redis.NewStringResult(`{"theaterId":1}`, nil))
+
+	resp := s.getBoard(1, ptr("partner-key"))
+
+	s.Equal(http.StatusOK, resp.StatusCode)
+	s.Equal("public, max-age=60, stale-while-revalidate=300", resp.Header.Get("Cache-Control"))
+	s.redisClient.AssertExpectations(s.T())
+}
+
+func (s *NowPlayingBoardTestSuite) TestRendersAndCachesBoard() {
+	startTime := time.Now().Add(time.Hour)
+
+	allowRateLimit(s.redisClient, "rate_limit:now_playing_board_client:192.0.2.1", 0)
+	s.redisClient.On("Get", mock.Anything, "feeds:now_playing_board:1:0").Return(redis.NewStringResult("", redis.Nil))
+	s.redisClient.On("Set", mock.Anything, "feeds:now_playing_board:1:0", mock.Anything, nowPlayingBoardCacheTTL).
+		Return(redis.NewStatusResult("OK", nil))
+
+	istanbul, err := time.LoadLocation("Europe/Istanbul")
+	s.Require().NoError(err)
+
+	s.theaterRepo.GetNowPlayingBoardFunc = func(ctx context.Context, theaterID int, now time.Time) (*domain.NowPlayingBoard, error) {
+		s.Equal(1, theaterID)
+
+		return &domain.NowPlayingBoard{
+			TheaterID:   1,
+			TheaterName: "CineX Kadıköy",
+			TimeZone:    "Europe/Istanbul",
+			Date:        domain.StartOfDay(now, istanbul),
+			Showtimes: []domain.BoardShowtime{
+				{
+					ShowtimeID:     10,
+					StartTime:      startTime,
+					Format:         domain.ScreeningFormat("IMAX"),
+					MovieID:        3,
+					MovieTitle:     "Dune",
+					MovieDuration:  155,
+					MoviePosterUrl: "https://example.com/dune.jpg",
+					HallName:       "Hall 1",
+					SoldOut:        true,
+				},
+			},
+		}, nil
+	}
+
+	resp := s.getBoard(1, nil)
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+
+	var board api.NowPlayingBoard
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&board))
+
+	s.Equal("CineX Kadıköy", board.TheaterName)
+	s.Equal("Europe/Istanbul", board.TimeZone)
+	s.Equal(time.Now().In(istanbul).Format(time.DateOnly), board.Date.Format(time.DateOnly))
+	s.Require().Len(board.Showtimes, 1)
+	s.Equal(api.BoardShowtime{
+		ShowtimeId: 10,
+		MovieId:    3,
+		MovieTitle: "Dune",
+		PosterUrl:  "https://example.com/dune.jpg",
+		Runtime:    155,
+		HallName:   "Hall 1",
+		StartTime:  board.Showtimes[0].StartTime,
+		Format:     api.ScreeningFormat("IMAX"),
+		SoldOut:    true,
+	}, board.Showtimes[0])
+	s.True(startTime.Equal(board.Showtimes[0].StartTime))
+
+	// the start time is given with the offset of the theater's time zone
+	_, offset := board.Showtimes[0].StartTime.Zone()
+	s.Equal(3*60*60, offset)
+
+	s.redisClient.AssertExpectations(s.T())
+}
+
+func (s *NowPlayingBoardTestSuite) TestUnknownTheater() {
+	allowRateLimit(s.redisClient, "rate_limit:now_playing_board_client:192.0.2.1", 0)
+	s.redisClient.On("Get", mock.Anything, "feeds:now_playing_board:9:0").Return(redis.NewStringResult("", redis.Nil))
+
+	s.theaterRepo.GetNowPlayingBoardFunc = func(ctx context.Context, theaterID int, now time.Time) (*domain.NowPlayingBoard, error) {
+		return nil, domain.ErrTheaterNotFound
+	}
+
+	resp := s.getBoard(9, nil)
+
+	s.Equal(http.StatusNotFound, resp.StatusCode)
+	s.redisClient.AssertNotCalled(s.T(), "Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
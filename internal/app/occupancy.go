@@ -0,0 +1,330 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Lock expiry is not announced on the seat events channel, so snapshots are also refreshed periodically.
+	occupancyRefreshInterval = 15 * time.Second
+	// the band of a showtime rarely changes within a minute, and listings are requested far more often
+	occupancyBandCacheTTL = time.Minute
+)
+
+func (app *Application) StreamShowtimeOccupancy(w http.ResponseWriter, r *http.Request, showtimeID int) {
+	logger := app.contextGetLogger(r)
+
+	if showtimeID < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("showtime ID must be greater than zero"))
+		return
+	}
+
+	showtimeSeats, err := app.seatRepo.GetSeatsByShowtime(r.Context(), showtimeID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if len(showtimeSeats.Seats) == 0 {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+
+	// the stream outlives the write timeout of the server
+	err = rc.SetWriteDeadline(time.Time{})
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	pubsub := app.redis.Subscribe(ctx, seatEventsChannel(showtimeID))
+	defer pubsub.Close()
+
+	_, err = pubsub.Receive(ctx)
+	if err != nil {
+		app.serverErrorResponse(w, r, fmt.Errorf("failed to subscribe to seat events: %w", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func() error {
+		occupancy, err := app.showtimeOccupancy(ctx, showtimeID, len(showtimeSeats.Seats))
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(toApiShowtimeOccupancy(occupancy))
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(w, "event: occupancy\ndata: %s\n\n", data)
+		if err != nil {
+			return err
+		}
+
+		return rc.Flush()
+	}
+
+	err = send()
+	if err != nil {
+		logger.Error("failed to send occupancy snapshot", "showtime_id", showtimeID, "error", err)
+		return
+	}
+
+	ticker := time.NewTicker(occupancyRefreshInterval)
+	defer ticker.Stop()
+
+	events := pubsub.Channel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-ticker.C:
+		}
+
+		err = send()
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Error("failed to send occupancy snapshot", "showtime_id", showtimeID, "error", err)
+			}
+
+			return
+		}
+	}
+}
+
+func (app *Application) showtimeOccupancy(ctx context.Context, showtimeID, totalSeats int) (*domain.ShowtimeOccupancy, error) {
+	lockedSeatIds, err := app.validLockedSeatIDs(ctx, showtimeID)
+	if err != nil {
+		return nil, err
+	}
+
+	reservedSeats, err := app.reservationRepo.GetSeatsByShowtimeId(ctx, showtimeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reserved seats from DB: %w", err)
+	}
+
+	blockHolds, err := app.blockHoldRepo.GetActiveByShowtime(ctx, showtimeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block holds from DB: %w", err)
+	}
+
+	heldSeats := 0
+	for _, hold := range blockHolds {
+		heldSeats += len(hold.SeatIDs)
+	}
+
+	now := time.Now()
+	locks := make([]domain.SeatLock, 0, len(lockedSeatIds))
+	holders := make(map[string]*int)
+
+	for _, seatID := range lockedSeatIds {
+		lockKey := seatLockKey(showtimeID, int(seatID))
+
+		sessionID, err := app.redis.Get(ctx, lockKey).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				// lock expired after the script ran
+				continue
+			}
+
+			return nil, err
+		}
+
+		ttl, err := app.redis.PTTL(ctx, lockKey).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		userID, ok := holders[sessionID]
+		if !ok {
+			userID, err = app.sessionUserId(ctx, sessionID)
+			if err != nil {
+				return nil, err
+			}
+
+			holders[sessionID] = userID
+		}
+
+		locks = append(locks, domain.SeatLock{
+			SeatID:    int(seatID),
+			HolderRef: holderRef(sessionID),
+			UserID:    userID,
+			ExpiresAt: now.Add(ttl),
+		})
+	}
+
+	occupancy := &domain.ShowtimeOccupancy{
+		ShowtimeID:     showtimeID,
+		TotalSeats:     totalSeats,
+		ReservedSeats:  len(reservedSeats),
+		LockedSeats:    len(locks),
+		HeldSeats:      heldSeats,
+		AvailableSeats: max(totalSeats-len(reservedSeats)-len(locks)-heldSeats, 0),
+		Locks:          locks,
+		BlockHolds:     blockHolds,
+		GeneratedAt:    now,
+	}
+
+	return occupancy, nil
+}
+
+// sessionUserId looks up the user bound to a session token in the session store. It returns nil for
+// guest sessions and sessions which no longer exist.
+func (app *Application) sessionUserId(ctx context.Context, sessionID string) (*int, error) {
+	b, found, err := app.sessionManager.Store.Find(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, nil
+	}
+
+	_, values, err := app.sessionManager.Codec.Decode(b)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, ok := values[SessionKeyUserId.String()].(int)
+	if !ok || userID == 0 {
+		return nil, nil
+	}
+
+	return &userID, nil
+}
+
+// holderRef derives a stable reference from a session token, session tokens must never be exposed.
+func holderRef(sessionID string) string {
+	hash := sha256.Sum256([]byte(sessionID))
+	return hex.EncodeToString(hash[:6])
+}
+
+func toApiShowtimeOccupancy(occupancy *domain.ShowtimeOccupancy) api.ShowtimeOccupancy {
+	locks := make([]api.SeatLockInfo, len(occupancy.Locks))
+
+	for i, lock := range occupancy.Locks {
+		locks[i] = api.SeatLockInfo{
+			SeatId:    lock.SeatID,
+			HolderRef: lock.HolderRef,
+			UserId:    lock.UserID,
+			ExpiresAt: lock.ExpiresAt,
+		}
+	}
+
+	blockHolds := make([]api.BlockHold, len(occupancy.BlockHolds))
+
+	for i, hold := range occupancy.BlockHolds {
+		blockHolds[i] = toApiBlockHold(hold)
+	}
+
+	return api.ShowtimeOccupancy{
+		ShowtimeId:     occupancy.ShowtimeID,
+		TotalSeats:     occupancy.TotalSeats,
+		ReservedSeats:  occupancy.ReservedSeats,
+		LockedSeats:    occupancy.LockedSeats,
+		HeldSeats:      occupancy.HeldSeats,
+		AvailableSeats: occupancy.AvailableSeats,
+		Locks:          locks,
+		BlockHolds:     blockHolds,
+		GeneratedAt:    occupancy.GeneratedAt,
+	}
+}
+
+// occupancyBands returns the occupancy bands of the upcoming showtimes of the theaters. The bands are only a
+// hint, so showtimes whose band can't be determined are left out instead of failing the listing.
+func (app *Application) occupancyBands(ctx context.Context, theaters []domain.Theater) map[int]domain.OccupancyBand {
+	now := time.Now()
+
+	var showtimeIDs []int
+
+	for _, theater := range theaters {
+		for _, hall := range theater.Halls {
+			for _, showtime := range hall.Showtimes {
+				if showtime.StartTime.After(now) {
+					showtimeIDs = append(showtimeIDs, showtime.ID)
+				}
+			}
+		}
+	}
+
+	bands := make(map[int]domain.OccupancyBand, len(showtimeIDs))
+	if len(showtimeIDs) == 0 {
+		return bands
+	}
+
+	keys := make([]string, len(showtimeIDs))
+	for i, id := range showtimeIDs {
+		keys[i] = occupancyBandKey(id)
+	}
+
+	var missing []int
+
+	cached, err := app.redis.MGet(ctx, keys...).Result()
+	if err != nil {
+		app.logger.Warn("failed to read occupancy bands from cache", "error", err)
+		missing = showtimeIDs
+	} else {
+		for i, v := range cached {
+			band, ok := v.(string)
+			if !ok {
+				missing = append(missing, showtimeIDs[i])
+				continue
+			}
+
+			bands[showtimeIDs[i]] = domain.OccupancyBand(band)
+		}
+	}
+
+	if len(missing) == 0 {
+		return bands
+	}
+
+	availability, err := app.theaterRepo.GetSeatAvailability(ctx, missing)
+	if err != nil {
+		app.logger.Warn("failed to get seat availability", "error", err)
+		return bands
+	}
+
+	for _, a := range availability {
+		band := a.Band()
+		bands[a.ShowtimeID] = band
+
+		err = app.redis.Set(ctx, occupancyBandKey(a.ShowtimeID), string(band), occupancyBandCacheTTL).Err()
+		if err != nil {
+			app.logger.Warn("failed to cache occupancy band", "showtime_id", a.ShowtimeID, "error", err)
+		}
+	}
+
+	return bands
+}
+
+func occupancyBandKey(showtimeID int) string {
+	return fmt.Sprintf("occupancy_band:%d", showtimeID)
+}
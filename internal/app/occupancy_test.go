@@ -0,0 +1,187 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type OccupancyTestSuite struct {
+	suite.Suite
+	app             *Application
+	seatRepo        *mocks.MockSeatRepo
+	reservationRepo *mocks.MockReservationRepo
+	blockHoldRepo   *mocks.MockBlockHoldRepo
+	redisClient     *mocks.MockRedisClient
+}
+
+func (s *OccupancyTestSuite) SetupTest() {
+	s.seatRepo = new(mocks.MockSeatRepo)
+	s.reservationRepo = new(mocks.MockReservationRepo)
+	s.blockHoldRepo = new(mocks.MockBlockHoldRepo)
+	s.redisClient = new(mocks.MockRedisClient)
+
+	s.app = newTestApplication(func(a *Application) {
+		a.seatRepo = s.seatRepo
+		a.reservationRepo = s.reservationRepo
+		a.blockHoldRepo = s.blockHoldRepo
+		a.redis = s.redisClient
+		a.sessionManager = scs.New()
+	})
+}
+
+func TestOccupancySuite(t *testing.T) {
+	suite.Run(t, new(OccupancyTestSuite))
+}
+
+func (s *OccupancyTestSuite) TestShowtimeOccupancy() {
+	ctx, err := s.app.sessionManager.Load(context.Background(), "")
+	s.Require().NoError(err)
+	s.app.sessionManager.Put(ctx, SessionKeyUserId.String(), 7)
+	userSession, _, err := s.app.sessionManager.Commit(ctx)
+	s.Require().NoError(err)
+
+	guestSession := "guest-session"
+
+	s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatLockFilterKeys(1), mock.Anything, mock.Anything).
+		Return(redis.NewCmdResult([]interface{}{"1", "2", "4"}, nil))
+	s.reservationRepo.On("GetSeatsByShowtimeId", mock.Anything, 1).
+		Return([]domain.ReservationSeat{{ReservationID: 1, ShowtimeID: 1, SeatID: 3}}, nil)
+
+	hold := domain.BlockHold{
+		ID:         5,
+		ShowtimeID: 1,
+		SeatIDs:    []int{8, 9},
+		Reason:     "Premiere crew",
+		Status:     domain.BlockHoldActive,
+		ExpiresAt:  time.Now().Add(24 * time.Hour),
+	}
+	s.blockHoldRepo.On("GetActiveByShowtime", mock.Anything, 1).Return([]domain.BlockHold{hold}, nil)
+
+	s.redisClient.On("Get", mock.Anything, seatLockKey(1, 1)).Return(redis.NewStringResult(userSession, nil))
+	s.redisClient.On("Get", mock.Anything, seatLockKey(1, 2)).Return(redis.NewStringResult(guestSession, nil))
+	s.redisClient.On("Get", mock.Anything, seatLockKey(1, 4)).Return(redis.NewStringResult("", redis.Nil))
+	s.redisClient.On("PTTL", mock.Anything, mock.Anything).Return(redis.NewDurationResult(5*time.Minute, nil))
+
+	occupancy, err := s.app.showtimeOccupancy(context.Background(), 1, 10)
+	s.Require().NoError(err)
+
+	want := &domain.ShowtimeOccupancy{
+		ShowtimeID:     1,
+		TotalSeats:     10,
+		ReservedSeats:  1,
+		LockedSeats:    2,
+		HeldSeats:      2,
+		AvailableSeats: 5,
+		Locks: []domain.SeatLock{
+			{SeatID: 1, HolderRef: holderRef(userSession), UserID: ptr(7)},
+			{SeatID: 2, HolderRef: holderRef(guestSession)},
+		},
+		BlockHolds: []domain.BlockHold{hold},
+	}
+
+	diff := cmp.Diff(want, occupancy, cmpopts.IgnoreFields(domain.ShowtimeOccupancy{}, "GeneratedAt"),
+		cmpopts.IgnoreFields(domain.SeatLock{}, "ExpiresAt"))
+	s.Empty(diff, "Occupancy mismatch (-want +got):\n%s", diff)
+
+	for _, lock := range occupancy.Locks {
+		s.WithinDuration(time.Now().Add(5*time.Minute), lock.ExpiresAt, time.Second)
+		s.NotEqual(userSession, lock.HolderRef)
+	}
+}
+
+func (s *OccupancyTestSuite) TestShowtimeOccupancyScriptError() {
+	s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatLockFilterKeys(1), mock.Anything, mock.Anything).
+		Return(redis.NewCmdResult(nil, fmt.Errorf("redis error")))
+
+	_, err := s.app.showtimeOccupancy(context.Background(), 1, 10)
+	s.Error(err)
+}
+
+func (s *OccupancyTestSuite) TestStreamShowtimeOccupancyNotFound() {
+	s.seatRepo.On("GetSeatsByShowtime", mock.Anything, 99).Return(&domain.ShowtimeSeats{}, nil)
+
+	w, r := executeRequest(s.T(), http.MethodGet, "/admin/showtimes/99/occupancy/stream", nil)
+
+	s.app.StreamShowtimeOccupancy(w, r, 99)
+
+	s.Equal(http.StatusNotFound, w.Code)
+}
+
+func TestRequireAdmin(t *testing.T) {
+	tests := []struct {
+		name         string
+		setupSession bool
+		getByIdFunc  func(context.Context, int) (*domain.User, error)
+		wantStatus   int
+	}{
+		{
+			name:       "no session",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:         "regular user",
+			setupSession: true,
+			getByIdFunc: func(ctx context.Context, id int) (*domain.User, error) {
+				return &domain.User{ID: id, Role: domain.RoleUser}, nil
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:         "admin user",
+			setupSession: true,
+			getByIdFunc: func(ctx context.Context, id int) (*domain.User, error) {
+				return &domain.User{ID: id, Role: domain.RoleAdmin}, nil
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:         "database error",
+			setupSession: true,
+			getByIdFunc: func(ctx context.Context, id int) (*domain.User, error) {
+				return nil, fmt.Errorf("database error")
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(func(a *Application) {
+				a.userRepo = &mocks.MockUserRepo{
+					GetByIdFunc: tt.getByIdFunc,
+				}
+				a.sessionManager = scs.New()
+			})
+
+			w, r := executeRequest(t, http.MethodGet, "/admin", nil)
+
+			if tt.setupSession {
+				r = setupTestSession(t, app, r, 1)
+			}
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			handler := app.requireAuthentication(app.requireRole(domain.RoleAdmin)(next))
+			handler = app.sessionManager.LoadAndSave(handler)
+			handler.ServeHTTP(w, r)
+
+			if got := w.Code; got != tt.wantStatus {
+				t.Errorf("status = %v, want %v", got, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+)
+
+// openApiSpec renders the spec embedded in the api package once, it only changes along with the binary.
+// The revision of the build is stamped in the info of the spec, the version of the API stays as written.
+var openApiSpec = sync.OnceValues(func() ([]byte, error) {
+	swagger, err := api.GetSwagger()
+	if err != nil {
+		return nil, err
+	}
+
+	if swagger.Info.Extensions == nil {
+		swagger.Info.Extensions = make(map[string]any)
+	}
+
+	swagger.Info.Extensions["x-build-version"] = version
+
+	return json.Marshal(swagger)
+})
+
+func (app *Application) GetOpenApiSpec(w http.ResponseWriter, r *http.Request) {
+	logger := app.contextGetLogger(r)
+
+	spec, err := openApiSpec()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	_, err = w.Write(spec)
+	if err != nil {
+		logger.Error("failed to write openapi spec", "error", err)
+	}
+}
+
+// apiDocsPage renders /openapi.json with Swagger UI. The UI is loaded from a CDN, so it's only served
+// outside of production.
+const apiDocsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Movie Reservation System API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5.17.14/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5.17.14/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = () => {
+      window.ui = SwaggerUIBundle({ url: "openapi.json", dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>
+`
+
+func (app *Application) serveApiDocs(w http.ResponseWriter, r *http.Request) {
+	logger := app.contextGetLogger(r)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	_, err := w.Write([]byte(apiDocsPage))
+	if err != nil {
+		logger.Error("failed to write api docs page", "error", err)
+	}
+}
@@ -0,0 +1,75 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestGetOpenApiSpec(t *testing.T) {
+	app := newTestApplication()
+
+	w, r := executeRequest(t, http.MethodGet, "/openapi.json", nil)
+
+	app.GetOpenApiSpec(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+
+	var spec struct {
+		OpenAPI string                     `json:"openapi"`
+		Info    map[string]any             `json:"info"`
+		Paths   map[string]json.RawMessage `json:"paths"`
+	}
+
+	if err := json.NewDecoder(w.Body).Decode(&spec); err != nil {
+		t.Fatal(err)
+	}
+
+	if spec.OpenAPI == "" || spec.Info["version"] == "" {
+		t.Errorf("the spec is incomplete: %+v", spec)
+	}
+
+	if _, ok := spec.Info["x-build-version"]; !ok {
+		t.Error("the spec isn't stamped with the build version")
+	}
+
+	if _, ok := spec.Paths["/healthcheck"]; !ok {
+		t.Error("the spec has no paths")
+	}
+}
+
+func TestApiDocsAreNotServedInProduction(t *testing.T) {
+	tests := []struct {
+		env      string
+		wantDocs bool
+	}{
+		{env: "dev", wantDocs: true},
+		{env: "staging", wantDocs: true},
+		{env: "prod", wantDocs: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			app := newTestApplication(func(a *Application) {
+				a.config.Env = tt.env
+			})
+
+			routed := false
+			for _, rt := range walkRoutes(t, app.routes().router) {
+				if rt.method == http.MethodGet && rt.pattern == "/docs" {
+					routed = true
+				}
+			}
+
+			if routed != tt.wantDocs {
+				t.Errorf("/docs routed = %v, want %v", routed, tt.wantDocs)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+)
+
+const opsPingTimeout = 2 * time.Second
+
+// GetOpsStatus gathers what on-call engineers look at first when triaging: whether the background jobs
+// keep running, whether the connection pools to Postgres and Redis are healthy and whether lifecycle
+// events are stuck on their way to the CRM.
+func (app *Application) GetOpsStatus(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+
+	lastRuns, err := app.jobRuns.LastRuns(r.Context())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	resp := api.OpsStatus{
+		Status:    api.Ok,
+		CheckedAt: now.UTC(),
+		Database:  app.databaseStatus(r.Context()),
+		Redis:     app.redisStatus(r.Context()),
+
+		LifecycleEvents: app.lifecycleEventsStatus(r.Context()),
+	}
+
+	if !resp.Database.Up || !resp.Redis.Up {
+		resp.Status = api.Degraded
+	}
+
+	if resp.LifecycleEvents.Error != nil || resp.LifecycleEvents.DeadLettered > 0 {
+		resp.Status = api.Degraded
+	}
+
+	for _, job := range app.backgroundJobs() {
+		status := api.OpsJobStatus{
+			Name:     job.Name,
+			Interval: job.Interval.String(),
+		}
+
+		if run, ok := lastRuns[job.Name]; ok {
+			status.LastStartedAt = &run.StartedAt
+			status.LastFinishedAt = &run.FinishedAt
+			status.Overdue = now.Sub(run.FinishedAt) > 2*job.Interval
+
+			if run.Error != "" {
+				status.LastError = &run.Error
+			}
+		}
+
+		if status.Overdue || status.LastError != nil {
+			resp.Status = api.Degraded
+		}
+
+		resp.Jobs = append(resp.Jobs, status)
+	}
+
+	app.writeJSON(w, http.StatusOK, resp, nil)
+}
+
+func (app *Application) databaseStatus(ctx context.Context) api.OpsDatabaseStatus {
+	stat := app.db.Stat()
+
+	status := api.OpsDatabaseStatus{
+		Up:                   true,
+		MinConns:             int(app.db.Config().MinConns),
+		MaxConns:             int(stat.MaxConns()),
+		Saturation:           poolSaturation(stat),
+		TotalConns:           int(stat.TotalConns()),
+		IdleConns:            int(stat.IdleConns()),
+		AcquiredConns:        int(stat.AcquiredConns()),
+		ConstructingConns:    int(stat.ConstructingConns()),
+		AcquireCount:         stat.AcquireCount(),
+		EmptyAcquireCount:    stat.EmptyAcquireCount(),
+		CanceledAcquireCount: stat.CanceledAcquireCount(),
+		AcquireDurationMs:    stat.AcquireDuration().Milliseconds(),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opsPingTimeout)
+	defer cancel()
+
+	if err := app.db.Ping(ctx); err != nil {
+		msg := err.Error()
+		status.Up = false
+		status.Error = &msg
+	}
+
+	return status
+}
+
+func (app *Application) redisStatus(ctx context.Context) api.OpsRedisStatus {
+	stats := app.redis.PoolStats()
+
+	status := api.OpsRedisStatus{
+		Up:         true,
+		TotalConns: int(stats.TotalConns),
+		IdleConns:  int(stats.IdleConns),
+		StaleConns: int(stats.StaleConns),
+		Hits:       int(stats.Hits),
+		Misses:     int(stats.Misses),
+		Timeouts:   int(stats.Timeouts),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opsPingTimeout)
+	defer cancel()
+
+	if err := app.redis.Ping(ctx).Err(); err != nil {
+		msg := err.Error()
+		status.Up = false
+		status.Error = &msg
+	}
+
+	return status
+}
+
+// lifecycleEventsStatus reports the undelivered lifecycle events. Dead lettered ones are not retried, they
+// need an operator.
+func (app *Application) lifecycleEventsStatus(ctx context.Context) api.OpsLifecycleEventsStatus {
+	var status api.OpsLifecycleEventsStatus
+
+	backlog, err := app.lifecycleEventRepo.Backlog(ctx)
+	if err != nil {
+		msg := err.Error()
+		status.Error = &msg
+		return status
+	}
+
+	status.Pending = backlog.Pending
+	status.DeadLettered = backlog.DeadLettered
+	status.OldestDeadLetteredAt = backlog.OldestDeadLetteredAt
+
+	return status
+}
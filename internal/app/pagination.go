@@ -0,0 +1,58 @@
+package app
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+func toApiMetadata(r *http.Request, metadata *domain.Metadata) *api.Metadata {
+	if metadata == nil {
+		return nil
+	}
+
+	return &api.Metadata{
+		CurrentPage:  metadata.CurrentPage,
+		FirstPage:    metadata.FirstPage,
+		LastPage:     metadata.LastPage,
+		PageSize:     metadata.PageSize,
+		TotalRecords: metadata.TotalRecords,
+		Links:        paginationLinks(r.URL, metadata),
+	}
+}
+
+// paginationLinks builds the links to the pages of a listing from the URL of the request, so that the
+// filters and the sort of the request are kept. An empty listing still has a first and a last page.
+func paginationLinks(u *url.URL, metadata *domain.Metadata) api.PaginationLinks {
+	lastPage := max(metadata.LastPage, metadata.FirstPage)
+
+	pageURL := func(page int) string {
+		query := u.Query()
+		query.Set("page", strconv.Itoa(page))
+		query.Set("pageSize", strconv.Itoa(metadata.PageSize))
+
+		return (&url.URL{Path: u.Path, RawQuery: query.Encode()}).String()
+	}
+
+	links := api.PaginationLinks{
+		Self:  pageURL(metadata.CurrentPage),
+		First: pageURL(metadata.FirstPage),
+		Last:  pageURL(lastPage),
+	}
+
+	if metadata.CurrentPage < lastPage {
+		next := pageURL(metadata.CurrentPage + 1)
+		links.Next = &next
+	}
+
+	// a page past the end links back to the last page
+	if metadata.CurrentPage > metadata.FirstPage {
+		prev := pageURL(min(metadata.CurrentPage-1, lastPage))
+		links.Prev = &prev
+	}
+
+	return links
+}
@@ -0,0 +1,78 @@
+package app
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+func TestPaginationLinks(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		metadata domain.Metadata
+		want     api.PaginationLinks
+	}{
+		{
+			name:     "middle page keeps the filters of the request",
+			url:      "/movies?term=action&page=2&pageSize=5",
+			metadata: *domain.NewMetadata(14, 2, 5),
+			want: api.PaginationLinks{
+				Self:  "/movies?page=2&pageSize=5&term=action",
+				First: "/movies?page=1&pageSize=5&term=action",
+				Last:  "/movies?page=3&pageSize=5&term=action",
+				Next:  ptr("/movies?page=3&pageSize=5&term=action"),
+				Prev:  ptr("/movies?page=1&pageSize=5&term=action"),
+			},
+		},
+		{
+			name:     "default page size is made explicit",
+			url:      "/users/me/reservations",
+			metadata: *domain.NewMetadata(25, 1, 10),
+			want: api.PaginationLinks{
+				Self:  "/users/me/reservations?page=1&pageSize=10",
+				First: "/users/me/reservations?page=1&pageSize=10",
+				Last:  "/users/me/reservations?page=3&pageSize=10",
+				Next:  ptr("/users/me/reservations?page=2&pageSize=10"),
+			},
+		},
+		{
+			name:     "empty listing has a single page",
+			url:      "/admin/disputes?status=open",
+			metadata: *domain.NewMetadata(0, 1, 20),
+			want: api.PaginationLinks{
+				Self:  "/admin/disputes?page=1&pageSize=20&status=open",
+				First: "/admin/disputes?page=1&pageSize=20&status=open",
+				Last:  "/admin/disputes?page=1&pageSize=20&status=open",
+			},
+		},
+		{
+			name:     "page past the end links back to the last page",
+			url:      "/movies?page=9",
+			metadata: *domain.NewMetadata(14, 9, 10),
+			want: api.PaginationLinks{
+				Self:  "/movies?page=9&pageSize=10",
+				First: "/movies?page=1&pageSize=10",
+				Last:  "/movies?page=2&pageSize=10",
+				Prev:  ptr("/movies?page=2&pageSize=10"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := paginationLinks(u, &tt.metadata)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("paginationLinks() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
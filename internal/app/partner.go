@@ -0,0 +1,78 @@
+package app
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+// partnerLimitedShare is the share of seats left below which a showtime is reported as limited.
+const partnerLimitedShare = 0.2
+
+func (app *Application) GetPartnerShowtimesFeed(
+	w http.ResponseWriter,
+	r *http.Request,
+	params api.GetPartnerShowtimesFeedParams) {
+
+	err := app.validator.Struct(params)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	// taken before the query, so changes made while the pages are read show up in the next sync again
+	syncedAt := time.Now().UTC().Truncate(time.Second)
+
+	pagination := toPagination(params.Page, params.PageSize)
+
+	showtimes, metadata, err := app.theaterRepo.GetPartnerShowtimes(r.Context(), params.UpdatedSince, pagination)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	resp := api.PartnerShowtimesResponse{
+		Showtimes: make([]api.PartnerShowtime, len(showtimes)),
+		Metadata:  *toApiMetadata(r, metadata),
+		SyncedAt:  syncedAt,
+	}
+
+	for i, s := range showtimes {
+		resp.Showtimes[i] = api.PartnerShowtime{
+			Id:           s.ShowtimeID,
+			MovieId:      s.MovieID,
+			MovieTitle:   s.MovieTitle,
+			TheaterId:    s.TheaterID,
+			TheaterName:  s.TheaterName,
+			TheaterCity:  s.TheaterCity,
+			HallId:       s.HallID,
+			HallName:     s.HallName,
+			StartTime:    s.StartTime.In(domain.TheaterLocation(s.TheaterTimeZone)),
+			Format:       api.ScreeningFormat(s.Format),
+			OpenCaptions: s.OpenCaptions,
+			Price:        s.Price,
+			Currency:     domain.DefaultCurrency,
+			Availability: partnerAvailability(s.TotalSeats, s.AvailableSeats),
+			UpdatedAt:    s.UpdatedAt,
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// partnerAvailability reports availability coarsely, exact seat counts are not shared with partners.
+func partnerAvailability(total, available int) api.PartnerShowtimeAvailability {
+	switch {
+	case available <= 0:
+		return api.SoldOut
+	case float64(available) < float64(total)*partnerLimitedShare:
+		return api.Limited
+	default:
+		return api.Available
+	}
+}
@@ -0,0 +1,160 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/metinatakli/movie-reservation-system/internal/validator"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/suite"
+)
+
+type PartnerTestSuite struct {
+	suite.Suite
+	app         *Application
+	theaterRepo *mocks.MockTheaterRepo
+}
+
+func (s *PartnerTestSuite) SetupTest() {
+	s.theaterRepo = &mocks.MockTheaterRepo{}
+
+	s.app = newTestApplication(func(a *Application) {
+		a.theaterRepo = s.theaterRepo
+	})
+}
+
+func TestPartnerSuite(t *testing.T) {
+	suite.Run(t, new(PartnerTestSuite))
+}
+
+func (s *PartnerTestSuite) TestGetPartnerShowtimesFeed() {
+	since := time.Date(2030, time.March, 1, 12, 0, 0, 0, time.UTC)
+	startTime := time.Date(2030, time.March, 2, 19, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		params         api.GetPartnerShowtimesFeedParams
+		setupMocks     func()
+		wantStatus     int
+		wantErrMessage string
+	}{
+		{
+			name:           "should fail when page size is too large",
+			params:         api.GetPartnerShowtimesFeedParams{PageSize: ptr(101)},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: fmt.Sprintf(validator.ErrMaxValue, "100"),
+		},
+		{
+			name:   "should fail when database error occurs",
+			params: api.GetPartnerShowtimesFeedParams{},
+			setupMocks: func() {
+				s.theaterRepo.GetPartnerShowtimesFunc = func(
+					ctx context.Context,
+					since *time.Time,
+					pagination domain.Pagination) ([]domain.PartnerShowtime, *domain.Metadata, error) {
+
+					return nil, nil, errors.New("database error")
+				}
+			},
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+		{
+			name:   "should return the showtimes changed since the given time",
+			params: api.GetPartnerShowtimesFeedParams{UpdatedSince: &since, Page: ptr(2), PageSize: ptr(3)},
+			setupMocks: func() {
+				s.theaterRepo.GetPartnerShowtimesFunc = func(
+					ctx context.Context,
+					updatedSince *time.Time,
+					pagination domain.Pagination) ([]domain.PartnerShowtime, *domain.Metadata, error) {
+
+					s.Equal(since, *updatedSince)
+					s.Equal(2, pagination.Page)
+					s.Equal(3, pagination.PageSize)
+
+					showtime := domain.PartnerShowtime{
+						ShowtimeID:     5,
+						StartTime:      startTime,
+						Format:         domain.Format3D,
+						Price:          decimal.RequireFromString("14.5"),
+						MovieID:        2,
+						MovieTitle:     "Dune",
+						TheaterID:      3,
+						TheaterName:    "CineX Kadikoy",
+						TheaterCity:    "Istanbul",
+						HallID:         4,
+						HallName:       "Hall 1",
+						TotalSeats:     100,
+						AvailableSeats: 50,
+						UpdatedAt:      since.Add(time.Minute),
+					}
+
+					limited, soldOut := showtime, showtime
+					limited.AvailableSeats = 19
+					soldOut.AvailableSeats = 0
+
+					return []domain.PartnerShowtime{showtime, limited, soldOut}, domain.NewMetadata(6, 2, 3), nil
+				}
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			w, r := executeRequest(s.T(), http.MethodGet, "/partner/feed/showtimes", nil)
+			s.app.GetPartnerShowtimesFeed(w, r, tt.params)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantStatus == http.StatusOK {
+				var response api.PartnerShowtimesResponse
+				s.Require().NoError(json.NewDecoder(w.Body).Decode(&response))
+
+				s.Require().Len(response.Showtimes, 3)
+				s.Equal(api.PartnerShowtime{
+					Id:           5,
+					MovieId:      2,
+					MovieTitle:   "Dune",
+					TheaterId:    3,
+					TheaterName:  "CineX Kadikoy",
+					TheaterCity:  "Istanbul",
+					HallId:       4,
+					HallName:     "Hall 1",
+					StartTime:    startTime,
+					Format:       api.N3D,
+					Price:        decimal.RequireFromString("14.5"),
+					Currency:     "USD",
+					Availability: api.Available,
+					UpdatedAt:    since.Add(time.Minute),
+				}, response.Showtimes[0])
+				s.Equal(api.Limited, response.Showtimes[1].Availability)
+				s.Equal(api.SoldOut, response.Showtimes[2].Availability)
+				s.Equal(6, response.Metadata.TotalRecords)
+				s.WithinDuration(time.Now(), response.SyncedAt, 5*time.Second)
+			}
+
+			checkErrorResponse(s.T(), w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mailer"
+)
+
+const passwordResetTokenTTL = 30 * time.Minute
+
+var (
+	// limits how often a mailbox can be flooded with reset emails, and how often a client can guess tokens
+	passwordResetEmailLimit  = rateLimit{name: "password_reset_email", limit: 3, window: 15 * time.Minute}
+	passwordResetClientLimit = rateLimit{name: "password_reset_client", limit: 10, window: 15 * time.Minute}
+)
+
+func (app *Application) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var input api.PasswordResetRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	email := strings.ToLower(input.Email)
+
+	if !app.allowRequest(w, r, passwordResetClientLimit, clientIP(r)) ||
+		!app.allowRequest(w, r, passwordResetEmailLimit, email) {
+		return
+	}
+
+	// the account is looked up in the background, so neither the response nor its timing tells whether
+	// the address is registered
+	go app.sendPasswordReset(context.WithoutCancel(r.Context()), app.contextGetLogger(r), email)
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (app *Application) sendPasswordReset(ctx context.Context, logger *slog.Logger, email string) {
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Error("panic occurred during sending password reset mail", "panic", err)
+		}
+	}()
+
+	// only activated accounts are found, unactivated ones are activated with the token of their welcome email
+	user, err := app.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		if !errors.Is(err, domain.ErrRecordNotFound) {
+			logger.Error("failed to get user by email for password reset", "error", err)
+		}
+
+		return
+	}
+
+	token, err := domain.GenerateToken(int64(user.ID), passwordResetTokenTTL, domain.PasswordResetScope)
+	if err != nil {
+		logger.Error("failed to generate password reset token", "error", err)
+		return
+	}
+
+	// a user has a single token per scope, so requesting a new reset invalidates the previous one
+	err = app.tokenRepo.Create(ctx, token)
+	if err != nil {
+		logger.Error("failed to store password reset token", "error", err)
+		return
+	}
+
+	data := mailer.PasswordResetEmail{
+		ResetToken: token.Plaintext,
+		FirstName:  user.FirstName,
+		TTLMinutes: int(passwordResetTokenTTL.Minutes()),
+	}
+
+	err = app.mailer.Send(ctx, user.Email, data)
+	if err != nil {
+		logger.Error("failed to send password reset email", "userId", user.ID, "error", err)
+		return
+	}
+
+	logger.Info("password reset email sent successfully", "userId", user.ID)
+}
+
+// ResetPassword sets the new password of the user the token was sent to and signs them out everywhere, a
+// reset is often asked for because someone else got hold of the old password.
+func (app *Application) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	logger := app.contextGetLogger(r)
+
+	var input api.PasswordResetCompletionRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	if !app.allowRequest(w, r, passwordResetClientLimit, clientIP(r)) {
+		return
+	}
+
+	// hashed before the token is consumed, so a failure doesn't use the token up
+	var password domain.User
+	err = password.Password.Set(input.Password)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	hash := sha256.Sum256([]byte(input.Token))
+
+	// the user is signed out before the token is consumed, a failure leaves the token for another attempt
+	user, err := app.userRepo.GetByToken(r.Context(), hash[:], domain.PasswordResetScope)
+	if err == nil {
+		err = app.endUserSessions(r, user.ID)
+	}
+
+	var userId int
+	if err == nil {
+		userId, err = app.userRepo.ResetPassword(r.Context(), hash[:], password.Password.Hash)
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			logger.Warn("password reset attempt with invalid token")
+			app.errorResponseWithCode(w, r, http.StatusBadRequest, api.INVALIDPASSWORDRESETTOKEN,
+				ErrInvalidPasswordResetToken)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	logger.Info("password reset", "userId", userId)
+
+	// sessions started with the old password before it was replaced are ended too
+	err = app.endUserSessions(r, userId)
+	if err != nil {
+		logger.Error("failed to end the sessions started during the password reset", "userId", userId, "error", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
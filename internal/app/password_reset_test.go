@@ -0,0 +1,351 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mailer"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/metinatakli/movie-reservation-system/internal/validator"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testPasswordResetToken = "Yr4lS0wU2nC8oD3qM9xF6zI5kG7hE1tB2aV4jP8bL3d"
+
+func TestRequestPasswordReset(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          api.PasswordResetRequest
+		setupRedis     func(*mocks.MockRedisClient)
+		user           *domain.User
+		getErr         error
+		wantStatus     int
+		wantErrMessage string
+		wantEmail      bool
+	}{
+		{
+			name:           "invalid email",
+			input:          api.PasswordResetRequest{Email: "not-an-email"},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: validator.ErrInvalidEmail,
+		},
+		{
+			name:  "too many resets for the address",
+			input: api.PasswordResetRequest{Email: "Freddie@Example.com"},
+			setupRedis: func(c *mocks.MockRedisClient) {
+				allowRateLimit(c, "rate_limit:password_reset_client:192.0.2.1", 0)
+				allowRateLimit(c, "rate_limit:password_reset_email:freddie@example.com", 60000)
+			},
+			wantStatus:     http.StatusTooManyRequests,
+			wantErrMessage: ErrRateLimitExceeded,
+		},
+		{
+			name:  "unknown address",
+			input: api.PasswordResetRequest{Email: "nobody@example.com"},
+			setupRedis: func(c *mocks.MockRedisClient) {
+				allowRateLimit(c, "rate_limit:password_reset_client:192.0.2.1", 0)
+				allowRateLimit(c, "rate_limit:password_reset_email:nobody@example.com", 0)
+			},
+			getErr:     domain.ErrRecordNotFound,
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:  "sends the token",
+			input: api.PasswordResetRequest{Email: "freddie@example.com"},
+			setupRedis: func(c *mocks.MockRedisClient) {
+				allowRateLimit(c, "rate_limit:password_reset_client:192.0.2.1", 0)
+				allowRateLimit(c, "rate_limit:password_reset_email:freddie@example.com", 0)
+			},
+			user:       &domain.User{ID: 1, FirstName: "Freddie", Email: "freddie@example.com", Activated: true},
+			wantStatus: http.StatusAccepted,
+			wantEmail:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisClient := new(mocks.MockRedisClient)
+			if tt.setupRedis != nil {
+				tt.setupRedis(redisClient)
+			}
+
+			lookedUp := make(chan struct{}, 1)
+			emails := make(chan sentEmail, 1)
+			var storedToken *domain.Token
+
+			app := newTestApplication(func(a *Application) {
+				a.redis = redisClient
+				a.userRepo = &mocks.MockUserRepo{
+					GetByEmailFunc: func(ctx context.Context, email string) (*domain.User, error) {
+						defer func() { lookedUp <- struct{}{} }()
+						return tt.user, tt.getErr
+					},
+				}
+				a.tokenRepo = &mocks.MockTokenRepo{
+					CreateFunc: func(ctx context.Context, token *domain.Token) error {
+						storedToken = token
+						return nil
+					},
+				}
+				a.mailer = &MockMailer{sendFunc: func(recipient, template string, data any) error {
+					emails <- sentEmail{recipient: recipient, template: template, data: data}
+					return nil
+				}}
+			})
+
+			w, r := executeRequest(t, http.MethodPost, "/users/password-reset", tt.input)
+			r.RemoteAddr = "192.0.2.1:51234"
+
+			app.RequestPasswordReset(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			redisClient.AssertExpectations(t)
+
+			checkErrorResponse(t, w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+
+			if tt.wantStatus != http.StatusAccepted {
+				return
+			}
+
+			select {
+			case <-lookedUp:
+			case <-time.After(time.Second):
+				t.Fatal("account was not looked up")
+			}
+
+			if !tt.wantEmail {
+				select {
+				case email := <-emails:
+					t.Errorf("unexpected email to %s", email.recipient)
+				case <-time.After(50 * time.Millisecond):
+				}
+				return
+			}
+
+			select {
+			case email := <-emails:
+				if email.recipient != "freddie@example.com" || email.template != "password_reset.tmpl" {
+					t.Errorf("unexpected email %+v", email)
+				}
+
+				data := email.data.(mailer.PasswordResetEmail)
+				if storedToken == nil || data.ResetToken != storedToken.Plaintext || data.FirstName != "Freddie" {
+					t.Errorf("unexpected email data %+v", data)
+				}
+
+				if storedToken.Scope != domain.PasswordResetScope || time.Until(storedToken.Expiry) > passwordResetTokenTTL {
+					t.Errorf("unexpected token %+v", storedToken)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("password reset email was not sent")
+			}
+		})
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          api.PasswordResetCompletionRequest
+		lookupErr      error
+		sessionsErr    error
+		resetErr       error
+		wantSignedOut  bool
+		wantReset      bool
+		wantStatus     int
+		wantErrMessage string
+	}{
+		{
+			name:           "malformed token",
+			input:          api.PasswordResetCompletionRequest{Token: "short", Password: "N3w-Secret!pass"},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: validator.ErrDefaultInvalid,
+		},
+		{
+			name:           "used or expired token",
+			input:          api.PasswordResetCompletionRequest{Token: testPasswordResetToken, Password: "N3w-Secret!pass"},
+			lookupErr:      domain.ErrRecordNotFound,
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: ErrInvalidPasswordResetToken,
+		},
+		{
+			name:           "database error",
+			input:          api.PasswordResetCompletionRequest{Token: testPasswordResetToken, Password: "N3w-Secret!pass"},
+			lookupErr:      errors.New("database error"),
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+		{
+			name:           "keeps the token when the sessions can't be ended",
+			input:          api.PasswordResetCompletionRequest{Token: testPasswordResetToken, Password: "N3w-Secret!pass"},
+			sessionsErr:    errors.New("redis down"),
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+		{
+			name:           "deleted account or token used concurrently",
+			input:          api.PasswordResetCompletionRequest{Token: testPasswordResetToken, Password: "N3w-Secret!pass"},
+			resetErr:       domain.ErrRecordNotFound,
+			wantSignedOut:  true,
+			wantReset:      true,
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: ErrInvalidPasswordResetToken,
+		},
+		{
+			name:          "signs the user out everywhere",
+			input:         api.PasswordResetCompletionRequest{Token: testPasswordResetToken, Password: "N3w-Secret!pass"},
+			wantSignedOut: true,
+			wantReset:     true,
+			wantStatus:    http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisClient := new(mocks.MockRedisClient)
+			allowRateLimit(redisClient, "rate_limit:password_reset_client:192.0.2.1", 0)
+
+			deviceSessionRepo := new(mocks.MockDeviceSessionRepo)
+
+			hash := sha256.Sum256([]byte(testPasswordResetToken))
+			var resetHash []byte
+
+			app := newTestApplication(func(a *Application) {
+				a.redis = redisClient
+				a.sessionManager = scs.New()
+				a.deviceSessionRepo = deviceSessionRepo
+				a.userRepo = &mocks.MockUserRepo{
+					GetByTokenFunc: func(ctx context.Context, tokenHash []byte, tokenScope string) (*domain.User, error) {
+						if string(tokenHash) != string(hash[:]) || tokenScope != domain.PasswordResetScope {
+							t.Errorf("unexpected token lookup")
+						}
+
+						if tt.lookupErr != nil {
+							return nil, tt.lookupErr
+						}
+
+						return &domain.User{ID: 7}, nil
+					},
+					ResetPasswordFunc: func(ctx context.Context, tokenHash []byte, passwordHash []byte) (int, error) {
+						if string(tokenHash) != string(hash[:]) {
+							t.Errorf("unexpected token consumed")
+						}
+
+						resetHash = passwordHash
+						return 7, tt.resetErr
+					},
+				}
+			})
+
+			// sessions of the user on three browsers, and of another user. The last session of the user was
+			// started before sessions were indexed.
+			sessions := map[string]int{}
+			var userTokens []string
+
+			for i, userId := range []int{7, 7, 7, 8} {
+				ctx, err := app.sessionManager.Load(context.Background(), "")
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				app.sessionManager.Put(ctx, SessionKeyUserId.String(), userId)
+
+				token, _, err := app.sessionManager.Commit(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				sessions[token] = userId
+				if userId == 7 && i < 2 {
+					userTokens = append(userTokens, token)
+				}
+			}
+
+			if tt.sessionsErr != nil {
+				redisClient.On("SMembers", mock.Anything, userSessionsKey(7)).
+					Return(redis.NewStringSliceResult(nil, tt.sessionsErr)).Once()
+			}
+
+			if tt.wantSignedOut {
+				redisClient.On("SMembers", mock.Anything, userSessionsKey(7)).
+					Return(redis.NewStringSliceResult(userTokens, nil)).Once()
+				redisClient.On("SRem", mock.Anything, userSessionsKey(7), mock.Anything).
+					Return(redis.NewIntResult(2, nil)).Once()
+				deviceSessionRepo.On("RevokeAllForUser", mock.Anything, 7).
+					Return([]domain.DeviceSession{{ID: 1, UserID: 7, AccessTokenHash: []byte("access")}}, nil).Once()
+				redisClient.On("Del", mock.Anything, []string{accessTokenKey([]byte("access"))}).
+					Return(redis.NewIntResult(1, nil)).Once()
+			}
+
+			if tt.wantStatus == http.StatusNoContent {
+				// sessions started during the reset are ended once the password is replaced
+				redisClient.On("SMembers", mock.Anything, userSessionsKey(7)).
+					Return(redis.NewStringSliceResult(nil, nil)).Once()
+				deviceSessionRepo.On("RevokeAllForUser", mock.Anything, 7).
+					Return([]domain.DeviceSession(nil), nil).Once()
+			}
+
+			w, r := executeRequest(t, http.MethodPut, "/users/password-reset", tt.input)
+			r.RemoteAddr = "192.0.2.1:51234"
+
+			app.sessionManager.LoadAndSave(http.HandlerFunc(app.ResetPassword)).ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			checkErrorResponse(t, w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+
+			if tt.wantStatus != http.StatusUnprocessableEntity {
+				redisClient.AssertExpectations(t)
+				deviceSessionRepo.AssertExpectations(t)
+			}
+
+			for token, userId := range sessions {
+				_, found, err := app.sessionManager.Store.Find(token)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if wantFound := userId != 7 || !tt.wantSignedOut; found != wantFound {
+					t.Errorf("session of user %d found = %v, want %v", userId, found, wantFound)
+				}
+			}
+
+			if !tt.wantReset {
+				if resetHash != nil {
+					t.Errorf("token consumed, want it kept for another attempt")
+				}
+				return
+			}
+
+			if bcrypt.CompareHashAndPassword(resetHash, []byte(tt.input.Password)) != nil {
+				t.Errorf("stored hash doesn't match the new password")
+			}
+		})
+	}
+}
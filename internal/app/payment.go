@@ -6,8 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/metinatakli/movie-reservation-system/api"
@@ -19,16 +23,35 @@ import (
 
 const (
 	maxBodyBytes = int64(65536)
+	// card details and receipts of a payment don't change once it succeeded
+	paymentReceiptCacheTTL = 24 * time.Hour
 )
 
 func (app *Application) CreateCheckoutSessionHandler(w http.ResponseWriter, r *http.Request) {
 	logger := app.contextGetLogger(r)
 
+	var input api.CreateCheckoutSessionRequest
+
+	// the body is optional, clients without a note may send none
+	if r.ContentLength != 0 {
+		err := app.readJSON(w, r, &input)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		err = app.validator.Struct(input)
+		if err != nil {
+			app.failedValidationResponse(w, r, err)
+			return
+		}
+	}
+
 	sessionId := app.sessionManager.Token(r.Context())
 	cartId, err := app.redis.Get(r.Context(), cartSessionKey(sessionId)).Result()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
-			app.notFoundResponseWithErr(w, r, fmt.Errorf("there is no cart bound to the current session"))
+			app.notFoundResponseWithErr(w, r, errNoCartInSession)
 			return
 		}
 
@@ -54,6 +77,52 @@ func (app *Application) CreateCheckoutSessionHandler(w http.ResponseWriter, r *h
 		return
 	}
 
+	// carts are checked against the window of the showtime when they were created
+	err = cart.SalesWindow.Check(time.Now())
+	if err != nil {
+		logger.Warn("checkout attempt failed: ticket sales of the showtime are closed", "cart_id", cartId)
+		app.editConflictResponseWithErr(w, r, err)
+		return
+	}
+
+	// the seats are charged the prices shown when the cart was created, without them the charge is refused
+	err = cart.CheckPrices()
+	if err != nil {
+		logger.Warn("checkout attempt failed: cart has no prices of its seats", "cart_id", cartId)
+		app.editConflictResponseWithErr(w, r, err)
+		return
+	}
+
+	if input.Note != nil || input.SpecialRequests != nil || input.FlexibleTicket != nil {
+		err = app.attachCheckoutOptions(r.Context(), cart, input)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	// the checkout session can't outlive the seat locks of the cart
+	expiresAt, err := app.holdForCheckout(
+		r.Context(),
+		cart.ShowtimeID,
+		cart.SeatIDs(),
+		cart.CreatedAt,
+		cart.Id,
+		cartSessionKey(sessionId))
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrSeatLockExpired):
+			logger.Warn("checkout attempt failed: seat locks have expired for cart", "cart_id", cartId)
+			app.editConflictResponseWithErr(w, r, err)
+		case errors.Is(err, errCheckoutHoldExhausted):
+			logger.Warn("checkout attempt failed: seats were held for checkouts too long", "cart_id", cartId)
+			app.editConflictResponseWithErr(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
 	userId := app.contextGetUserId(r)
 	user, err := app.userRepo.GetById(r.Context(), userId)
 	if err != nil {
@@ -61,11 +130,19 @@ func (app *Application) CreateCheckoutSessionHandler(w http.ResponseWriter, r *h
 		return
 	}
 
+	// the channel of the cart is refined once the user is known, e.g. a kiosk account checking out
+	channel := app.salesChannel(r, user)
+	if channel != domain.SalesChannelWeb || cart.SalesChannel == "" {
+		cart.SalesChannel = channel
+	}
+
 	payment := &domain.Payment{
-		UserID:   userId,
-		Amount:   cart.TotalPrice,
-		Currency: "USD",
-		Status:   domain.PaymentStatusPending,
+		UserID:       userId,
+		Amount:       cart.TotalPrice,
+		Currency:     domain.DefaultCurrency,
+		Status:       domain.PaymentStatusPending,
+		Payout:       app.payoutSplit(r.Context(), cart.PayoutAccountID, cart.TotalPrice),
+		SalesChannel: cart.SalesChannel,
 	}
 
 	logger.Info("creating payment intent record", "user_id", userId, "amount", cart.TotalPrice.String())
@@ -78,12 +155,14 @@ func (app *Application) CreateCheckoutSessionHandler(w http.ResponseWriter, r *h
 
 	logger.Info("payment intent created successfully, creating provider session", "payment_id", payment.ID)
 
-	checkoutSession, err := app.paymentProvider.CreateCheckoutSession(sessionId, user, *cart, *payment)
+	checkoutSession, err := app.paymentProvider.CreateCheckoutSession(r.Context(), sessionId, user, *cart, *payment, expiresAt)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
+	app.replaceCheckoutSession(r.Context(), logger, cart, checkoutSession.ID)
+
 	logger.Info("provider session created successfully", "payment_id", payment.ID)
 
 	resp := api.CheckoutSessionResponse{
@@ -96,6 +175,56 @@ func (app *Application) CreateCheckoutSessionHandler(w http.ResponseWriter, r *h
 	}
 }
 
+// attachCheckoutOptions stores the note, the special requests and the flexible ticket choice in the cart,
+// which is where the reservation is created from once the payment completes. A repeated checkout replaces
+// them.
+func (app *Application) attachCheckoutOptions(
+	ctx context.Context,
+	cart *domain.Cart,
+	input api.CreateCheckoutSessionRequest) error {
+
+	cart.Note = ""
+	if input.Note != nil {
+		cart.Note = sanitizeNote(*input.Note)
+	}
+
+	cart.SpecialRequests = nil
+	if input.SpecialRequests != nil {
+		for _, request := range *input.SpecialRequests {
+			cart.SpecialRequests = append(cart.SpecialRequests, domain.SpecialRequest(request))
+		}
+	}
+
+	cart.SetFlexibleTicket(input.FlexibleTicket != nil && *input.FlexibleTicket)
+
+	cartBytes, err := json.Marshal(cart)
+	if err != nil {
+		return err
+	}
+
+	return app.redis.Set(ctx, cart.Id, cartBytes, redis.KeepTTL).Err()
+}
+
+// sanitizeNote removes control and invisible formatting characters, e.g. bidirectional overrides, from a
+// user supplied note. Line breaks are kept. The note is escaped where it is rendered.
+func sanitizeNote(note string) string {
+	note = strings.ReplaceAll(note, "\r\n", "\n")
+
+	note = strings.Map(func(r rune) rune {
+		if r == '\n' {
+			return r
+		}
+
+		if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+			return -1
+		}
+
+		return r
+	}, note)
+
+	return strings.TrimSpace(note)
+}
+
 func (app *Application) StripeWebhookHandler(w http.ResponseWriter, r *http.Request) {
 	logger := app.logger.With("request_id", middleware.GetReqID(r.Context()))
 
@@ -112,6 +241,7 @@ func (app *Application) StripeWebhookHandler(w http.ResponseWriter, r *http.Requ
 	event, err := webhook.ConstructEvent(payload, signatureHeader, endpointSecret)
 	if err != nil {
 		logger.Error("Webhook signature verification failed", "error", err.Error())
+		app.webhookMetrics.recordSignatureFailure(r.Context(), err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
@@ -120,6 +250,26 @@ func (app *Application) StripeWebhookHandler(w http.ResponseWriter, r *http.Requ
 	ctx := context.WithValue(r.Context(), loggerContextKey, logger)
 	r = r.WithContext(ctx)
 
+	// a lost event can't be replayed, but that's no reason to fail the delivery
+	stored, err := app.webhookEventRepo.Store(ctx, &domain.WebhookEvent{
+		ID:      event.ID,
+		Type:    string(event.Type),
+		Payload: payload,
+	})
+	if err != nil {
+		logger.Error("failed to store webhook event", "error", err)
+	} else if !stored {
+		logger.Info("webhook event was received before, handling it again")
+	}
+
+	app.dispatchStripeEvent(w, r, event)
+}
+
+// dispatchStripeEvent runs the handler of the event type. Handlers are idempotent, so an event may be
+// dispatched more than once.
+func (app *Application) dispatchStripeEvent(w http.ResponseWriter, r *http.Request, event stripe.Event) {
+	logger := app.contextGetLogger(r)
+
 	switch event.Type {
 	case "checkout.session.completed":
 		var session stripe.CheckoutSession
@@ -132,133 +282,362 @@ func (app *Application) StripeWebhookHandler(w http.ResponseWriter, r *http.Requ
 		}
 
 		app.handleCheckoutSessionCompleted(w, r, session)
+	case "checkout.session.expired":
+		var session stripe.CheckoutSession
+
+		err := json.Unmarshal(event.Data.Raw, &session)
+		if err != nil {
+			logger.Error("error parsing webhook JSON", "error", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		app.handleCheckoutSessionExpired(w, r, session)
+	case "charge.refunded":
+		var charge stripe.Charge
+
+		err := json.Unmarshal(event.Data.Raw, &charge)
+		if err != nil {
+			logger.Error("error parsing webhook JSON", "error", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// refunds are only embedded in charges for older API versions, later refund.updated events
+		// carry them otherwise
+		var refunds []*stripe.Refund
+		if charge.Refunds != nil {
+			refunds = charge.Refunds.Data
+		}
+
+		app.handleRefundEvents(w, r, refunds, charge.PaymentIntent)
+	case "charge.dispute.created",
+		"charge.dispute.updated",
+		"charge.dispute.closed",
+		"charge.dispute.funds_withdrawn",
+		"charge.dispute.funds_reinstated":
+		var dispute stripe.Dispute
+
+		err := json.Unmarshal(event.Data.Raw, &dispute)
+		if err != nil {
+			logger.Error("error parsing webhook JSON", "error", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		app.handleDisputeEvent(w, r, dispute)
+	case "refund.created", "refund.updated", "refund.failed":
+		var refund stripe.Refund
+
+		err := json.Unmarshal(event.Data.Raw, &refund)
+		if err != nil {
+			logger.Error("error parsing webhook JSON", "error", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		app.handleRefundEvents(w, r, []*stripe.Refund{&refund}, refund.PaymentIntent)
 	default:
 		logger.Info("unhandled webhook event type received")
 		w.WriteHeader(http.StatusOK)
 	}
 }
 
+var (
+	errInvalidCheckoutMetadata = errors.New("invalid checkout session metadata")
+	errPaymentNotPending       = errors.New("payment status is not pending")
+	errPaymentAlreadyCompleted = errors.New("payment is already completed")
+)
+
+// checkoutCompletion is everything a completed checkout session needs to become a reservation.
+type checkoutCompletion struct {
+	payment   *domain.Payment
+	cart      *domain.Cart
+	userId    int
+	sessionId string
+}
+
 func (app *Application) handleCheckoutSessionCompleted(
 	w http.ResponseWriter,
 	r *http.Request,
 	checkoutSession stripe.CheckoutSession) {
 
-	logger := app.contextGetLogger(r)
-
-	paymentIdStr := checkoutSession.Metadata["payment_id"]
-	if paymentIdStr == "" {
-		app.badRequestResponse(w, r, fmt.Errorf("payment_id is missing in the checkout session metadata"))
+	// a checkout for an existing reservation pays the price difference of moving it
+	if checkoutSession.Metadata[domain.CheckoutMetadataReservationID] != "" {
+		app.handleShowtimeChangeCompleted(w, r, checkoutSession)
 		return
 	}
 
-	paymentId, err := strconv.Atoi(paymentIdStr)
+	completion, err := app.prepareCheckoutCompletion(r.Context(), checkoutSession)
 	if err != nil {
-		app.badRequestResponse(w, r, fmt.Errorf("payment_id is not in the expected format: %w", err))
+		if errors.Is(err, errPaymentAlreadyCompleted) {
+			app.contextGetLogger(r).Info("idempotent request: payment already completed")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		app.checkoutCompletionErrorResponse(w, r, err)
 		return
 	}
 
-	payment, err := app.paymentRepo.GetById(r.Context(), paymentId)
-	if err != nil {
-		switch {
-		case errors.Is(err, domain.ErrRecordNotFound):
-			app.notFoundResponseWithErr(w, r, fmt.Errorf("payment not found: %w", err))
-		default:
-			app.serverErrorResponse(w, r, fmt.Errorf("failed to get payment by id: %w", err))
+	logger := app.contextGetLogger(r).With("payment_id", completion.payment.ID)
+	r = r.WithContext(context.WithValue(r.Context(), loggerContextKey, logger))
+
+	cart := completion.cart
+	showtimeId := cart.ShowtimeID
+
+	// the seats keep the prices they were charged with, whatever the prices are changed to later
+	reservationSeats := cart.PriceBreakdown().ReservationSeats(showtimeId)
+
+	logger.Info("payment completed, creating final reservation")
+
+	var paymentIntentId string
+	if checkoutSession.PaymentIntent != nil {
+		paymentIntentId = checkoutSession.PaymentIntent.ID
+	}
+
+	reservation := domain.Reservation{
+		UserID:            completion.userId,
+		ShowtimeID:        showtimeId,
+		CheckoutSessionID: checkoutSession.ID,
+		PaymentIntentID:   paymentIntentId,
+		PaymentID:         completion.payment.ID,
+		Note:              cart.Note,
+		SpecialRequests:   cart.SpecialRequests,
+		FlexibleTicket:    cart.FlexibleTicket,
+		ReservationSeats:  reservationSeats,
+		GuestEmail:        cart.GuestEmail,
+		SalesChannel:      cart.SalesChannel,
+	}
+
+	err = app.reservationRepo.Create(r.Context(), &reservation)
+	if errors.Is(err, domain.ErrSeatAlreadyReserved) {
+		// retrying can't help, the seats belong to another reservation now
+		err = app.refundDoubleBooking(r.Context(), logger, reservation, cart.Id, completion.sessionId)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
 		}
 
+		app.editConflictResponseWithErr(w, r, errSeatsAlreadyReserved)
 		return
 	}
 
-	logger = logger.With("payment_id", payment.ID)
-	r = r.WithContext(context.WithValue(r.Context(), loggerContextKey, logger))
+	if err != nil {
+		// the customer has paid, the reservation is retried in the background instead of failing the
+		// webhook, Stripe's redeliveries would give up long before an outage is over
+		queueErr := app.queueFulfillment(r.Context(), logger, reservation, cart, completion.sessionId, err)
+		if queueErr != nil {
+			app.serverErrorResponse(w, r, fmt.Errorf("failed to create reservation: %w", errors.Join(err, queueErr)))
+			return
+		}
 
-	if payment.Status == domain.PaymentStatusCompleted {
-		logger.Info("idempotent request: payment already completed")
+		logger.Error("failed to create reservation, queued it for retry", "error", err)
 		w.WriteHeader(http.StatusOK)
 		return
 	}
 
-	if payment.Status != domain.PaymentStatusPending {
-		logger.Warn("payment completion failed due to status conflict")
-		app.editConflictResponseWithErr(w, r, fmt.Errorf("payment status is not pending: %s", payment.Status))
-		return
+	logger.Info("reservation created successfully", "reservation_id", reservation.ID)
+
+	app.completeFulfillment(r.Context(), logger, reservation, cart.Id, completion.sessionId)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// completeFulfillment runs what follows a stored reservation: the confirmation is sent, and the cart
+// and the seat locks, which are no longer needed, are removed.
+func (app *Application) completeFulfillment(
+	ctx context.Context,
+	logger *slog.Logger,
+	reservation domain.Reservation,
+	cartId,
+	sessionId string) {
+
+	// the confirmation outlives the webhook request, so it must not be cancelled along with it
+	go app.sendReservationConfirmation(context.WithoutCancel(ctx), logger, reservation)
+
+	showtimeId := reservation.ShowtimeID
+	seatIds := make([]int, len(reservation.ReservationSeats))
+
+	pipe := app.redis.TxPipeline()
+
+	for i, seat := range reservation.ReservationSeats {
+		seatIds[i] = seat.SeatID
+		pipe.Del(ctx, seatLockKey(showtimeId, seat.SeatID))
+		pipe.SRem(ctx, seatSetKey(showtimeId), seat.SeatID)
 	}
 
-	cartId := checkoutSession.Metadata["cart_id"]
-	sessionId := checkoutSession.Metadata["session_id"]
+	pipe.Del(ctx, cartId)
+	pipe.Del(ctx, cartSessionKey(sessionId))
 
-	cart, err := app.getAndVerifyCart(r.Context(), cartId, sessionId)
+	_, err := pipe.Exec(ctx)
 	if err != nil {
-		switch {
-		case errors.Is(err, domain.ErrCartNotFound):
-			logger.Warn("payment complete attempt failed: cart has expired or was not found", "cart_id", cartId)
-			app.notFoundResponseWithErr(w, r, err)
-		case errors.Is(err, domain.ErrSeatLockExpired), errors.Is(err, domain.ErrSeatConflict):
-			logger.Warn("payment complete attempt failed: seat locks have expired for cart", "cart_id", cartId)
-			app.editConflictResponseWithErr(w, r, err)
-		case errors.Is(err, domain.ErrSeatConflict):
-			logger.Warn("payment complete attempt failed: cart contains seat lock conflicts", "cart_id", cartId)
-			app.editConflictResponseWithErr(w, r, err)
-		default:
-			app.serverErrorResponse(w, r, err)
-		}
-		return
+		logger.Error("reservation created but failed to clean up cart from redis", "error", err, "cart_id", cartId)
 	}
 
-	showtimeId := cart.ShowtimeID
+	app.publishSeatEvent(ctx, showtimeId, seatEventReserved, seatIds)
+}
 
-	reservationSeats := make([]domain.ReservationSeat, len(cart.Seats))
-	for i, seat := range cart.Seats {
-		reservationSeat := domain.ReservationSeat{
-			ShowtimeID: showtimeId,
-			SeatID:     seat.Id,
+// refundDoubleBooking refunds a paid reservation whose seats were sold to another reservation. The
+// payment is completed without a reservation, so the refund is recorded once Stripe reports it and
+// redelivered webhooks are not handled again. Its seat locks are released, the cart is dropped.
+func (app *Application) refundDoubleBooking(
+	ctx context.Context,
+	logger *slog.Logger,
+	reservation domain.Reservation,
+	cartId,
+	sessionId string) error {
+
+	if reservation.PaymentIntentID == "" {
+		return fmt.Errorf("payment %d can't be refunded without a payment intent", reservation.PaymentID)
+	}
+
+	refund, err := app.paymentProvider.RefundPayment(
+		ctx,
+		reservation.PaymentIntentID,
+		fmt.Sprintf("double-booking-%d", reservation.PaymentID))
+	if err != nil {
+		return fmt.Errorf("failed to refund double booked payment: %w", err)
+	}
+
+	err = app.paymentRepo.MarkUnfulfilled(
+		ctx,
+		reservation.PaymentID,
+		reservation.CheckoutSessionID,
+		reservation.PaymentIntentID,
+		domain.ErrSeatAlreadyReserved.Error())
+	if err != nil {
+		return fmt.Errorf("payment refunded but couldn't be marked as unfulfilled: %w", err)
+	}
+
+	logger.Warn("seats of a paid reservation were already reserved, payment refunded", "stripe_refund_id", refund.ID)
+
+	seatIds := make([]int, len(reservation.ReservationSeats))
+	for i, seat := range reservation.ReservationSeats {
+		seatIds[i] = seat.SeatID
+	}
+
+	app.releaseCartSeats(ctx, logger, reservation.ShowtimeID, seatIds, cartId, sessionId)
+
+	return nil
+}
+
+// releaseCartSeats drops the cart and releases the seats it still holds. Only the locks still owned by
+// the session are released, the seats may be locked by others meanwhile.
+func (app *Application) releaseCartSeats(
+	ctx context.Context,
+	logger *slog.Logger,
+	showtimeId int,
+	seatIds []int,
+	cartId,
+	sessionId string) {
+
+	lockKeys := make([]string, len(seatIds))
+	for i, seatId := range seatIds {
+		lockKeys[i] = seatLockKey(showtimeId, seatId)
+	}
+
+	released, err := releaseSeatsScript.Run(ctx, app.redis, lockKeys, sessionId).Int64Slice()
+	if err != nil {
+		logger.Error("failed to release seat locks of a cart", "error", err)
+	}
+
+	var releasedSeatIds []int
+	pipe := app.redis.TxPipeline()
+
+	for i, seatId := range seatIds {
+		if i < len(released) && released[i] == 1 {
+			releasedSeatIds = append(releasedSeatIds, seatId)
+			pipe.SRem(ctx, seatSetKey(showtimeId), seatId)
 		}
+	}
+
+	pipe.Del(ctx, cartId)
+	pipe.Del(ctx, cartSessionKey(sessionId))
 
-		reservationSeats[i] = reservationSeat
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		logger.Error("failed to clean up cart from redis", "error", err, "cart_id", cartId)
 	}
 
-	userId, err := strconv.Atoi(checkoutSession.Metadata["user_id"])
-	if err != nil || userId == 0 {
-		app.badRequestResponse(w, r, fmt.Errorf("user_id is missing or not in the expected format: %w", err))
-		return
+	if len(releasedSeatIds) > 0 {
+		app.publishSeatEvent(ctx, showtimeId, seatEventReleased, releasedSeatIds)
 	}
+}
 
-	logger.Info("payment completed, creating final reservation")
+// prepareCheckoutCompletion checks that the checkout session can still become a reservation, without
+// changing anything. It returns errPaymentAlreadyCompleted when the session was already handled.
+func (app *Application) prepareCheckoutCompletion(
+	ctx context.Context,
+	checkoutSession stripe.CheckoutSession) (*checkoutCompletion, error) {
 
-	reservation := domain.Reservation{
-		UserID:            userId,
-		ShowtimeID:        showtimeId,
-		CheckoutSessionID: checkoutSession.ID,
-		PaymentID:         paymentId,
-		ReservationSeats:  reservationSeats,
+	paymentIdStr := checkoutSession.Metadata[domain.CheckoutMetadataPaymentID]
+	if paymentIdStr == "" {
+		return nil, fmt.Errorf("%w: payment_id is missing", errInvalidCheckoutMetadata)
 	}
 
-	err = app.reservationRepo.Create(r.Context(), reservation)
+	paymentId, err := strconv.Atoi(paymentIdStr)
 	if err != nil {
-		app.serverErrorResponse(w, r, fmt.Errorf("failed to create reservation: %w", err))
-		return
+		return nil, fmt.Errorf("%w: payment_id is not in the expected format: %w", errInvalidCheckoutMetadata, err)
 	}
 
-	logger.Info("reservation created successfully", "reservation_id", reservation.ID)
+	payment, err := app.paymentRepo.GetById(ctx, paymentId)
+	if err != nil {
+		if errors.Is(err, domain.ErrRecordNotFound) {
+			return nil, fmt.Errorf("payment not found: %w", err)
+		}
 
-	// remove cart and seat locks
-	// TODO: remove duplicated code
-	pipe := app.redis.TxPipeline()
+		return nil, fmt.Errorf("failed to get payment by id: %w", err)
+	}
+
+	if payment.Status == domain.PaymentStatusCompleted {
+		return nil, errPaymentAlreadyCompleted
+	}
 
-	for _, seat := range cart.Seats {
-		pipe.Del(r.Context(), seatLockKey(showtimeId, seat.Id))
-		pipe.SRem(r.Context(), seatSetKey(showtimeId), seat.Id)
+	if payment.Status != domain.PaymentStatusPending {
+		return nil, fmt.Errorf("%w: %s", errPaymentNotPending, payment.Status)
 	}
 
-	pipe.Del(r.Context(), cartId)
-	pipe.Del(r.Context(), cartSessionKey(sessionId))
+	cartId := checkoutSession.Metadata[domain.CheckoutMetadataCartID]
+	sessionId := checkoutSession.Metadata[domain.CheckoutMetadataSessionID]
 
-	_, err = pipe.Exec(r.Context())
+	cart, err := app.getAndVerifyCart(ctx, cartId, sessionId)
 	if err != nil {
-		logger.Error("reservation created but failed to clean up cart from redis", "error", err, "cart_id", cartId)
+		return nil, fmt.Errorf("cart %s: %w", cartId, err)
 	}
 
-	w.WriteHeader(http.StatusOK)
+	// carts booked for guests are paid without a user
+	userId, err := strconv.Atoi(checkoutSession.Metadata[domain.CheckoutMetadataUserID])
+	if err != nil || (userId == 0 && cart.GuestEmail == "") {
+		return nil, fmt.Errorf("%w: user_id is missing or not in the expected format", errInvalidCheckoutMetadata)
+	}
+
+	return &checkoutCompletion{
+		payment:   payment,
+		cart:      cart,
+		userId:    userId,
+		sessionId: sessionId,
+	}, nil
+}
+
+func (app *Application) checkoutCompletionErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, errInvalidCheckoutMetadata):
+		app.badRequestResponse(w, r, err)
+	case errors.Is(err, domain.ErrRecordNotFound):
+		app.notFoundResponseWithErr(w, r, err)
+	case errors.Is(err, domain.ErrCartNotFound):
+		app.contextGetLogger(r).Warn("payment complete attempt failed: cart has expired or was not found")
+		app.notFoundResponseWithErr(w, r, err)
+	case errors.Is(err, domain.ErrSeatLockExpired), errors.Is(err, domain.ErrSeatConflict):
+		app.contextGetLogger(r).Warn("payment complete attempt failed: seat locks have expired or conflict")
+		app.editConflictResponseWithErr(w, r, err)
+	case errors.Is(err, errPaymentNotPending):
+		app.contextGetLogger(r).Warn("payment completion failed due to status conflict")
+		app.editConflictResponseWithErr(w, r, err)
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
 }
 
 func (app *Application) getAndVerifyCart(ctx context.Context, cartId, sessionId string) (*domain.Cart, error) {
@@ -278,21 +657,87 @@ func (app *Application) getAndVerifyCart(ctx context.Context, cartId, sessionId
 	}
 
 	cart.Id = cartId
-	showtimeId := cart.ShowtimeID
 
-	for _, seat := range cart.Seats {
-		ownerSessionId, err := app.redis.Get(ctx, seatLockKey(showtimeId, seat.Id)).Result()
-		if err != nil {
-			if errors.Is(err, redis.Nil) {
-				return nil, domain.ErrSeatLockExpired
-			}
-			return nil, err
+	seatIds := make([]int, len(cart.Seats))
+	for i, seat := range cart.Seats {
+		seatIds[i] = seat.Id
+	}
+
+	err = app.verifySeatLocks(ctx, cart.ShowtimeID, seatIds, sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cart, nil
+}
+
+// verifySeatLocks checks that the seats are still locked by the owner. Every seat is checked, so a
+// *domain.SeatLocksError tells all the seats which are lost.
+func (app *Application) verifySeatLocks(ctx context.Context, showtimeId int, seatIds []int, owner string) error {
+	var locks domain.SeatLocksError
+
+	for _, seatId := range seatIds {
+		lockOwner, err := app.redis.Get(ctx, seatLockKey(showtimeId, seatId)).Result()
+		switch {
+		case errors.Is(err, redis.Nil):
+			locks.ExpiredSeatIDs = append(locks.ExpiredSeatIDs, seatId)
+		case err != nil:
+			return err
+		case owner != lockOwner:
+			locks.ConflictingSeatIDs = append(locks.ConflictingSeatIDs, seatId)
+		default:
+			locks.HeldSeatIDs = append(locks.HeldSeatIDs, seatId)
 		}
+	}
+
+	if len(locks.ExpiredSeatIDs) == 0 && len(locks.ConflictingSeatIDs) == 0 {
+		return nil
+	}
+
+	return &locks
+}
+
+// paymentReceipt returns the card and receipt details of the payment from the provider, cached so reservation
+// details don't call the provider on every view.
+func (app *Application) paymentReceipt(ctx context.Context, paymentIntentID string) (*domain.PaymentReceipt, error) {
+	key := paymentReceiptKey(paymentIntentID)
 
-		if sessionId != ownerSessionId {
-			return nil, domain.ErrSeatConflict
+	cached, err := app.redis.Get(ctx, key).Bytes()
+	if err == nil {
+		var receipt domain.PaymentReceipt
+
+		err = json.Unmarshal(cached, &receipt)
+		if err == nil {
+			return &receipt, nil
 		}
 	}
 
-	return &cart, nil
+	if err != nil && !errors.Is(err, redis.Nil) {
+		app.logger.Warn("failed to read payment receipt from cache", "key", key, "error", err)
+	}
+
+	receipt, err := app.paymentProvider.GetPaymentReceipt(ctx, paymentIntentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if receipt == nil {
+		return nil, nil
+	}
+
+	receiptBytes, err := json.Marshal(receipt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = app.redis.Set(ctx, key, receiptBytes, paymentReceiptCacheTTL).Err()
+	if err != nil {
+		app.logger.Warn("failed to cache payment receipt", "key", key, "error", err)
+	}
+
+	return receipt, nil
+}
+
+func paymentReceiptKey(paymentIntentID string) string {
+	return fmt.Sprintf("payment_receipt:%s", paymentIntentID)
 }
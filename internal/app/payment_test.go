@@ -6,12 +6,15 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/alexedwards/scs/v2"
 	"github.com/metinatakli/movie-reservation-system/api"
 	"github.com/metinatakli/movie-reservation-system/internal/domain"
 	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/metinatakli/movie-reservation-system/internal/validator"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
@@ -32,6 +35,7 @@ type CheckoutSessionTestSuite struct {
 	suite.Suite
 	app             *Application
 	redisClient     *mocks.MockRedisClient
+	redisPipeline   *mocks.MockTxPipeline
 	paymentRepo     *mocks.MockPaymentRepo
 	userRepo        *MockUserRepo
 	paymentProvider *mocks.MockPaymentProvider
@@ -40,6 +44,7 @@ type CheckoutSessionTestSuite struct {
 
 func (s *CheckoutSessionTestSuite) SetupTest() {
 	s.redisClient = new(mocks.MockRedisClient)
+	s.redisPipeline = new(mocks.MockTxPipeline)
 	s.paymentRepo = new(mocks.MockPaymentRepo)
 	s.userRepo = new(MockUserRepo)
 	s.paymentProvider = new(mocks.MockPaymentProvider)
@@ -58,14 +63,64 @@ func TestCheckoutSessionSuite(t *testing.T) {
 	suite.Run(t, new(CheckoutSessionTestSuite))
 }
 
+// expectSeatsHeld mocks the seat locks of the test cart having the given TTL left, locks expiring before
+// a checkout session can are extended along with the cart.
+func (s *CheckoutSessionTestSuite) expectSeatsHeld(sessionId string, ttl time.Duration) {
+	s.redisClient.On("PTTL", mock.Anything, seatLockKey(1, 1)).Return(redis.NewDurationResult(ttl, nil)).Once()
+
+	if ttl <= 0 || ttl >= minCheckoutSessionTTL {
+		return
+	}
+
+	s.redisClient.On("TxPipeline").Return(s.redisPipeline).Once()
+	for _, key := range []string{seatLockKey(1, 1), seatLockKey(1, 2), "cart-id", cartSessionKey(sessionId)} {
+		s.redisPipeline.On("ExpireAt", mock.Anything, key, mock.MatchedBy(func(tm time.Time) bool {
+			return time.Until(tm) > minCheckoutSessionTTL-time.Minute
+		})).Return(redis.NewBoolResult(true, nil)).Once()
+	}
+	s.redisPipeline.On("Exec", mock.Anything).Return([]redis.Cmder{}, nil).Once()
+}
+
+// expectCheckoutSessionKept mocks the cart being saved with the given checkout session.
+func (s *CheckoutSessionTestSuite) expectCheckoutSessionKept(checkoutSessionID string) {
+	s.redisClient.On("Set", mock.Anything, "cart-id", mock.MatchedBy(func(value []byte) bool {
+		var cart domain.Cart
+		if err := json.Unmarshal(value, &cart); err != nil {
+			return false
+		}
+
+		return cart.CheckoutSessionID == checkoutSessionID
+	}), time.Duration(redis.KeepTTL)).Return(redis.NewStatusResult("OK", nil)).Once()
+}
+
 func (s *CheckoutSessionTestSuite) TestCreateCheckoutSessionHandler() {
 	tests := []struct {
 		name           string
+		body           any
 		setupMocks     func(string)
 		wantStatus     int
 		wantErrMessage string
 		wantResponse   *api.CheckoutSessionResponse
 	}{
+		{
+			name: "should fail when a special request is unknown",
+			body: map[string]any{
+				"specialRequests": []string{"popcorn_delivery"},
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantErrMessage: fmt.Sprintf(
+				validator.ErrOneOf,
+				"wheelchair_assistance child_booster_seat hearing_assistance visual_assistance",
+			),
+		},
+		{
+			name: "should fail when the note is too long",
+			body: map[string]any{
+				"note": strings.Repeat("a", 501),
+			},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: fmt.Sprintf(validator.ErrMaxLength, "500"),
+		},
 		{
 			name: "should fail when there is no cart bound to the current session",
 			setupMocks: func(sessionId string) {
@@ -110,10 +165,26 @@ func (s *CheckoutSessionTestSuite) TestCreateCheckoutSessionHandler() {
 				s.redisClient.On("Get", mock.Anything, "cart-id").Return(redis.NewStringResult(cartDataStr, nil)).Once()
 				s.redisClient.On("Get", mock.Anything, seatLockKey(1, 1)).
 					Return(redis.NewStringResult("other-session-id", nil)).Once()
+				s.redisClient.On("Get", mock.Anything, seatLockKey(1, 2)).
+					Return(redis.NewStringResult(sessionId, nil)).Once()
 			},
 			wantStatus:     http.StatusConflict,
 			wantErrMessage: "a selected seat does not belong to the current session",
 		},
+		{
+			name: "should fail when the cart has no prices of its seats",
+			setupMocks: func(sessionId string) {
+				cartWithoutPrices := `{"ShowtimeID": 1, "TotalPrice": "15.00",
+					"Seats": [{"Id": 1, "ExtraPrice": "10.00"}, {"Id": 2, "ExtraPrice": "5.00"}]}`
+
+				s.redisClient.On("Get", mock.Anything, mock.Anything).Return(redis.NewStringResult("cart-id", nil)).Once()
+				s.redisClient.On("Get", mock.Anything, "cart-id").Return(redis.NewStringResult(cartWithoutPrices, nil)).Once()
+				s.redisClient.On("Get", mock.Anything, seatLockKey(1, 1)).Return(redis.NewStringResult(sessionId, nil)).Once()
+				s.redisClient.On("Get", mock.Anything, seatLockKey(1, 2)).Return(redis.NewStringResult(sessionId, nil)).Once()
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: domain.ErrCartPricesMissing.Error(),
+		},
 		{
 			name: "should fail when payment record fails to be saved to the database",
 			setupMocks: func(sessionId string) {
@@ -127,6 +198,8 @@ func (s *CheckoutSessionTestSuite) TestCreateCheckoutSessionHandler() {
 				s.redisClient.On("Get", mock.Anything, seatLockKey(1, 2)).
 					Return(redis.NewStringResult(sessionId, nil)).Once()
 
+				s.expectSeatsHeld(sessionId, seatLockTTL)
+
 				s.userRepo.On("GetById", mock.Anything, mock.Anything).Return(&domain.User{ID: 1, Email: "test@test.com"}, nil)
 
 				s.paymentRepo.On("Create", mock.Anything, mock.Anything).Return(errors.New("database error"))
@@ -147,6 +220,8 @@ func (s *CheckoutSessionTestSuite) TestCreateCheckoutSessionHandler() {
 				s.redisClient.On("Get", mock.Anything, seatLockKey(1, 2)).
 					Return(redis.NewStringResult(sessionId, nil)).Once()
 
+				s.expectSeatsHeld(sessionId, seatLockTTL)
+
 				s.userRepo.On("GetById", mock.Anything, mock.Anything).Return(&domain.User{ID: 1, Email: "test@test.com"}, nil)
 
 				s.paymentRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
@@ -167,6 +242,148 @@ func (s *CheckoutSessionTestSuite) TestCreateCheckoutSessionHandler() {
 				s.redisClient.On("Get", mock.Anything, seatLockKey(1, 1)).Return(redis.NewStringResult(sessionId, nil)).Once()
 				s.redisClient.On("Get", mock.Anything, seatLockKey(1, 2)).Return(redis.NewStringResult(sessionId, nil)).Once()
 
+				s.expectSeatsHeld(sessionId, seatLockTTL)
+
+				s.userRepo.On("GetById", mock.Anything, mock.Anything).
+					Return(&domain.User{ID: 1, Email: "test@test.com"}, nil).Once()
+
+				s.paymentRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+				s.paymentProvider.On("CreateCheckoutSession", mock.Anything, mock.Anything, mock.Anything).
+					Return(&stripe.CheckoutSession{ID: "checkout-id", URL: "http://payment.url"}, nil)
+
+				s.expectCheckoutSessionKept("checkout-id")
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: &api.CheckoutSessionResponse{
+				RedirectUrl: "http://payment.url",
+			},
+		},
+		{
+			name: "should store the sanitized note in the cart",
+			body: map[string]any{
+				"note":            "  Arriving late\u202e, please keep \u0007the seats\r\n",
+				"specialRequests": []string{"wheelchair_assistance"},
+			},
+			setupMocks: func(sessionId string) {
+				s.redisClient.On("Get", mock.Anything, mock.Anything).Return(redis.NewStringResult("cart-id", nil)).Once()
+				s.redisClient.On("Get", mock.Anything, "cart-id").Return(redis.NewStringResult(cartDataStr, nil)).Once()
+
+				s.redisClient.On("Get", mock.Anything, seatLockKey(1, 1)).Return(redis.NewStringResult(sessionId, nil)).Once()
+				s.redisClient.On("Get", mock.Anything, seatLockKey(1, 2)).Return(redis.NewStringResult(sessionId, nil)).Once()
+
+				s.redisClient.On("Set", mock.Anything, "cart-id", mock.MatchedBy(func(value []byte) bool {
+					var cart domain.Cart
+					if err := json.Unmarshal(value, &cart); err != nil {
+						return false
+					}
+
+					return cart.Note == "Arriving late, please keep the seats" &&
+						len(cart.SpecialRequests) == 1 &&
+						cart.SpecialRequests[0] == domain.SpecialRequestWheelchairAssistance
+				}), time.Duration(redis.KeepTTL)).Return(redis.NewStatusResult("OK", nil)).Once()
+
+				s.expectSeatsHeld(sessionId, seatLockTTL)
+
+				s.userRepo.On("GetById", mock.Anything, mock.Anything).
+					Return(&domain.User{ID: 1, Email: "test@test.com"}, nil).Once()
+
+				s.paymentRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+				s.paymentProvider.On("CreateCheckoutSession", mock.Anything, mock.Anything, mock.Anything).
+					Return(&stripe.CheckoutSession{ID: "checkout-id", URL: "http://payment.url"}, nil)
+
+				s.expectCheckoutSessionKept("checkout-id")
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: &api.CheckoutSessionResponse{
+				RedirectUrl: "http://payment.url",
+			},
+		},
+		{
+			name: "should charge the flexible ticket when it's chosen",
+			body: map[string]any{
+				"flexibleTicket": true,
+			},
+			setupMocks: func(sessionId string) {
+				s.redisClient.On("Get", mock.Anything, mock.Anything).Return(redis.NewStringResult("cart-id", nil)).Once()
+				s.redisClient.On("Get", mock.Anything, "cart-id").Return(redis.NewStringResult(cartDataStr, nil)).Once()
+
+				s.redisClient.On("Get", mock.Anything, seatLockKey(1, 1)).Return(redis.NewStringResult(sessionId, nil)).Once()
+				s.redisClient.On("Get", mock.Anything, seatLockKey(1, 2)).Return(redis.NewStringResult(sessionId, nil)).Once()
+
+				// the cart carries no flexible ticket fee, its total is recalculated from the seats
+				s.redisClient.On("Set", mock.Anything, "cart-id", mock.MatchedBy(func(value []byte) bool {
+					var cart domain.Cart
+					if err := json.Unmarshal(value, &cart); err != nil {
+						return false
+					}
+
+					return cart.FlexibleTicket && cart.TotalPrice.String() == "15"
+				}), time.Duration(redis.KeepTTL)).Return(redis.NewStatusResult("OK", nil)).Once()
+
+				s.expectSeatsHeld(sessionId, seatLockTTL)
+
+				s.userRepo.On("GetById", mock.Anything, mock.Anything).
+					Return(&domain.User{ID: 1, Email: "test@test.com"}, nil).Once()
+
+				s.paymentRepo.On("Create", mock.Anything, mock.MatchedBy(func(payment *domain.Payment) bool {
+					return payment.Amount.String() == "15"
+				})).Return(nil)
+
+				s.paymentProvider.On("CreateCheckoutSession", mock.Anything, mock.Anything, mock.Anything).
+					Return(&stripe.CheckoutSession{ID: "checkout-id", URL: "http://payment.url"}, nil)
+
+				s.expectCheckoutSessionKept("checkout-id")
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: &api.CheckoutSessionResponse{
+				RedirectUrl: "http://payment.url",
+			},
+		},
+		{
+			name: "should fail when the seat locks expire before the checkout starts",
+			setupMocks: func(sessionId string) {
+				s.redisClient.On("Get", mock.Anything, mock.Anything).Return(redis.NewStringResult("cart-id", nil)).Once()
+				s.redisClient.On("Get", mock.Anything, "cart-id").Return(redis.NewStringResult(cartDataStr, nil)).Once()
+
+				s.redisClient.On("Get", mock.Anything, seatLockKey(1, 1)).Return(redis.NewStringResult(sessionId, nil)).Once()
+				s.redisClient.On("Get", mock.Anything, seatLockKey(1, 2)).Return(redis.NewStringResult(sessionId, nil)).Once()
+
+				s.expectSeatsHeld(sessionId, -2*time.Nanosecond)
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: domain.ErrSeatLockExpired.Error(),
+		},
+		{
+			name: "should not extend the seat locks again once the cart was held for checkouts long enough",
+			setupMocks: func(sessionId string) {
+				createdAt, _ := time.Now().Add(-20 * time.Minute).MarshalJSON()
+				cart := strings.Replace(cartDataStr, `"ShowtimeID": 1,`, `"ShowtimeID": 1, "CreatedAt": `+string(createdAt)+`,`, 1)
+
+				s.redisClient.On("Get", mock.Anything, mock.Anything).Return(redis.NewStringResult("cart-id", nil)).Once()
+				s.redisClient.On("Get", mock.Anything, "cart-id").Return(redis.NewStringResult(cart, nil)).Once()
+
+				s.redisClient.On("Get", mock.Anything, seatLockKey(1, 1)).Return(redis.NewStringResult(sessionId, nil)).Once()
+				s.redisClient.On("Get", mock.Anything, seatLockKey(1, 2)).Return(redis.NewStringResult(sessionId, nil)).Once()
+
+				// the locks were extended by an earlier checkout and have 5 minutes left
+				s.redisClient.On("PTTL", mock.Anything, seatLockKey(1, 1)).Return(redis.NewDurationResult(5*time.Minute, nil)).Once()
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: errCheckoutHoldExhausted.Error(),
+		},
+		{
+			name: "should expire the checkout session along with the seat locks when they outlive it",
+			setupMocks: func(sessionId string) {
+				s.redisClient.On("Get", mock.Anything, mock.Anything).Return(redis.NewStringResult("cart-id", nil)).Once()
+				s.redisClient.On("Get", mock.Anything, "cart-id").Return(redis.NewStringResult(cartDataStr, nil)).Once()
+
+				s.redisClient.On("Get", mock.Anything, seatLockKey(1, 1)).Return(redis.NewStringResult(sessionId, nil)).Once()
+				s.redisClient.On("Get", mock.Anything, seatLockKey(1, 2)).Return(redis.NewStringResult(sessionId, nil)).Once()
+
+				s.expectSeatsHeld(sessionId, time.Hour)
+
 				s.userRepo.On("GetById", mock.Anything, mock.Anything).
 					Return(&domain.User{ID: 1, Email: "test@test.com"}, nil).Once()
 
@@ -174,6 +391,65 @@ func (s *CheckoutSessionTestSuite) TestCreateCheckoutSessionHandler() {
 
 				s.paymentProvider.On("CreateCheckoutSession", mock.Anything, mock.Anything, mock.Anything).
 					Return(&stripe.CheckoutSession{ID: "checkout-id", URL: "http://payment.url"}, nil)
+
+				s.expectCheckoutSessionKept("checkout-id")
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: &api.CheckoutSessionResponse{
+				RedirectUrl: "http://payment.url",
+			},
+		},
+		{
+			name: "should attribute the payment of a kiosk account to the kiosk channel",
+			setupMocks: func(sessionId string) {
+				s.redisClient.On("Get", mock.Anything, mock.Anything).Return(redis.NewStringResult("cart-id", nil)).Once()
+				s.redisClient.On("Get", mock.Anything, "cart-id").Return(redis.NewStringResult(cartDataStr, nil)).Once()
+
+				s.redisClient.On("Get", mock.Anything, seatLockKey(1, 1)).Return(redis.NewStringResult(sessionId, nil)).Once()
+				s.redisClient.On("Get", mock.Anything, seatLockKey(1, 2)).Return(redis.NewStringResult(sessionId, nil)).Once()
+
+				s.expectSeatsHeld(sessionId, minCheckoutSessionTTL)
+
+				s.userRepo.On("GetById", mock.Anything, mock.Anything).
+					Return(&domain.User{ID: 1, Email: "kiosk@test.com", Role: domain.RoleKiosk}, nil).Once()
+
+				s.paymentRepo.On("Create", mock.Anything, mock.MatchedBy(func(payment *domain.Payment) bool {
+					return payment.SalesChannel == domain.SalesChannelKiosk
+				})).Return(nil)
+
+				s.paymentProvider.On("CreateCheckoutSession", mock.Anything, mock.Anything, mock.Anything).
+					Return(&stripe.CheckoutSession{ID: "checkout-id", URL: "http://payment.url"}, nil)
+
+				s.expectCheckoutSessionKept("checkout-id")
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: &api.CheckoutSessionResponse{
+				RedirectUrl: "http://payment.url",
+			},
+		},
+		{
+			name: "should expire the checkout session replaced by a new checkout",
+			setupMocks: func(sessionId string) {
+				cart := strings.Replace(cartDataStr, `"ShowtimeID": 1,`, `"ShowtimeID": 1, "CheckoutSessionID": "previous-checkout-id",`, 1)
+
+				s.redisClient.On("Get", mock.Anything, mock.Anything).Return(redis.NewStringResult("cart-id", nil)).Once()
+				s.redisClient.On("Get", mock.Anything, "cart-id").Return(redis.NewStringResult(cart, nil)).Once()
+
+				s.redisClient.On("Get", mock.Anything, seatLockKey(1, 1)).Return(redis.NewStringResult(sessionId, nil)).Once()
+				s.redisClient.On("Get", mock.Anything, seatLockKey(1, 2)).Return(redis.NewStringResult(sessionId, nil)).Once()
+
+				s.expectSeatsHeld(sessionId, minCheckoutSessionTTL)
+
+				s.userRepo.On("GetById", mock.Anything, mock.Anything).
+					Return(&domain.User{ID: 1, Email: "test@test.com"}, nil).Once()
+
+				s.paymentRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+				s.paymentProvider.On("CreateCheckoutSession", mock.Anything, mock.Anything, mock.Anything).
+					Return(&stripe.CheckoutSession{ID: "checkout-id", URL: "http://payment.url"}, nil)
+
+				s.expectCheckoutSessionKept("checkout-id")
+				s.paymentProvider.On("ExpireCheckoutSession", "previous-checkout-id").Return(nil).Once()
 			},
 			wantStatus: http.StatusOK,
 			wantResponse: &api.CheckoutSessionResponse{
@@ -186,12 +462,13 @@ func (s *CheckoutSessionTestSuite) TestCreateCheckoutSessionHandler() {
 		s.Run(tt.name, func() {
 			s.SetupTest()
 
+			defer s.redisPipeline.AssertExpectations(s.T())
 			defer s.paymentRepo.AssertExpectations(s.T())
 			defer s.userRepo.AssertExpectations(s.T())
 			defer s.redisClient.AssertExpectations(s.T())
 			defer s.paymentProvider.AssertExpectations(s.T())
 
-			w, r := executeRequest(s.T(), http.MethodPost, "/checkout/session", nil)
+			w, r := executeRequest(s.T(), http.MethodPost, "/checkout/session", tt.body)
 			r = setupTestSession(s.T(), s.app, r, 1)
 
 			if tt.setupMocks != nil {
@@ -224,3 +501,104 @@ func (s *CheckoutSessionTestSuite) TestCreateCheckoutSessionHandler() {
 		})
 	}
 }
+
+func (s *CheckoutSessionTestSuite) TestHandleCheckoutSessionExpired() {
+	const (
+		paymentId = 77
+		sessionId = "session-123"
+	)
+
+	checkoutSession := stripe.CheckoutSession{
+		ID: "cs_123",
+		Metadata: map[string]string{
+			domain.CheckoutMetadataPaymentID: "77",
+			domain.CheckoutMetadataCartID:    "cart-id",
+			domain.CheckoutMetadataSessionID: sessionId,
+		},
+	}
+
+	cartOf := func(checkoutSessionID string) string {
+		return strings.Replace(cartDataStr, `"ShowtimeID": 1,`,
+			fmt.Sprintf(`"ShowtimeID": 1, "CheckoutSessionID": %q,`, checkoutSessionID), 1)
+	}
+
+	tests := []struct {
+		name            string
+		checkoutSession stripe.CheckoutSession
+		setupMocks      func()
+		wantStatus      int
+	}{
+		{
+			name:            "should fail when the payment id is missing from the metadata",
+			checkoutSession: stripe.CheckoutSession{ID: "cs_123"},
+			wantStatus:      http.StatusBadRequest,
+		},
+		{
+			name:            "should fail when the payment can't be canceled",
+			checkoutSession: checkoutSession,
+			setupMocks: func() {
+				s.paymentRepo.On("MarkCanceled", mock.Anything, paymentId, "cs_123", "checkout session expired").
+					Return(errors.New("database error")).Once()
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:            "should cancel the payment and release the seats of the cart",
+			checkoutSession: checkoutSession,
+			setupMocks: func() {
+				s.paymentRepo.On("MarkCanceled", mock.Anything, paymentId, "cs_123", "checkout session expired").Return(nil).Once()
+				s.redisClient.On("Get", mock.Anything, "cart-id").Return(redis.NewStringResult(cartOf("cs_123"), nil)).Once()
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, []string{seatLockKey(1, 1), seatLockKey(1, 2)}, sessionId).
+					Return(redis.NewCmdResult([]interface{}{int64(1), int64(1)}, nil)).Once()
+				s.redisClient.On("TxPipeline").Return(s.redisPipeline).Once()
+				s.redisPipeline.On("SRem", mock.Anything, seatSetKey(1), []interface{}{1}).Return(redis.NewIntResult(1, nil)).Once()
+				s.redisPipeline.On("SRem", mock.Anything, seatSetKey(1), []interface{}{2}).Return(redis.NewIntResult(1, nil)).Once()
+				s.redisPipeline.On("Del", mock.Anything, []string{"cart-id"}).Return(redis.NewIntResult(1, nil)).Once()
+				s.redisPipeline.On("Del", mock.Anything, []string{cartSessionKey(sessionId)}).Return(redis.NewIntResult(1, nil)).Once()
+				s.redisPipeline.On("Exec", mock.Anything).Return([]redis.Cmder{}, nil).Once()
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatMapChangeKeys(1), seatEventsChannel(1), mock.Anything, mock.Anything).
+					Return(redis.NewCmdResult(int64(1), nil)).Once()
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:            "should keep the seats when the cart moved on to a newer checkout session",
+			checkoutSession: checkoutSession,
+			setupMocks: func() {
+				s.paymentRepo.On("MarkCanceled", mock.Anything, paymentId, "cs_123", "checkout session expired").Return(nil).Once()
+				s.redisClient.On("Get", mock.Anything, "cart-id").Return(redis.NewStringResult(cartOf("cs_456"), nil)).Once()
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:            "should acknowledge an expiry when the payment isn't pending and the cart is gone",
+			checkoutSession: checkoutSession,
+			setupMocks: func() {
+				s.paymentRepo.On("MarkCanceled", mock.Anything, paymentId, "cs_123", "checkout session expired").
+					Return(domain.ErrRecordNotFound).Once()
+				s.redisClient.On("Get", mock.Anything, "cart-id").Return(redis.NewStringResult("", redis.Nil)).Once()
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			defer s.redisPipeline.AssertExpectations(s.T())
+			defer s.paymentRepo.AssertExpectations(s.T())
+			defer s.redisClient.AssertExpectations(s.T())
+
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			w, r := executeRequest(s.T(), http.MethodPost, "/webhook", nil)
+
+			s.app.handleCheckoutSessionExpired(w, r, tt.checkoutSession)
+
+			s.Equal(tt.wantStatus, w.Code)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/shopspring/decimal"
+)
+
+func (app *Application) UpdateTheaterPayoutAccount(w http.ResponseWriter, r *http.Request, theaterID int) {
+	logger := app.contextGetLogger(r)
+
+	if theaterID < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("theater ID must be greater than zero"))
+		return
+	}
+
+	var input api.UpdateTheaterPayoutAccountRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	err = app.theaterRepo.UpdatePayoutAccount(r.Context(), theaterID, input.AccountId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	logger.Info("theater payout account changed", "theater_id", theaterID, "account_id", input.AccountId)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// payoutSplit returns how a checkout of the amount is shared with the theater's connected account, nil
+// when the theater has no account. A tenant with its own Stripe account is charged directly on that
+// account, so there is nothing to transfer.
+func (app *Application) payoutSplit(ctx context.Context, accountID string, amount decimal.Decimal) *domain.PayoutSplit {
+	if accountID == "" {
+		return nil
+	}
+
+	tenant := domain.TenantFromContext(ctx)
+	if tenant != nil && tenant.StripeAccountID != "" {
+		return nil
+	}
+
+	split := domain.NewPayoutSplit(accountID, amount, app.config.Stripe.ApplicationFeePercent)
+	return &split
+}
@@ -0,0 +1,139 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/metinatakli/movie-reservation-system/internal/validator"
+	"github.com/shopspring/decimal"
+)
+
+func TestUpdateTheaterPayoutAccount(t *testing.T) {
+	tests := []struct {
+		name           string
+		theaterID      int
+		input          map[string]any
+		updateErr      error
+		wantStatus     int
+		wantErrMessage string
+	}{
+		{
+			name:           "invalid theater ID",
+			theaterID:      0,
+			input:          map[string]any{"accountId": "acct_1"},
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "theater ID must be greater than zero",
+		},
+		{
+			name:           "not a connected account",
+			theaterID:      1,
+			input:          map[string]any{"accountId": "cus_1"},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: validator.ErrDefaultInvalid,
+		},
+		{
+			name:           "theater not found",
+			theaterID:      99,
+			input:          map[string]any{"accountId": "acct_1"},
+			updateErr:      domain.ErrRecordNotFound,
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+		},
+		{
+			name:       "account set",
+			theaterID:  1,
+			input:      map[string]any{"accountId": "acct_1"},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "account removed",
+			theaterID:  1,
+			input:      map[string]any{"accountId": ""},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:           "database error",
+			theaterID:      1,
+			input:          map[string]any{"accountId": "acct_1"},
+			updateErr:      errors.New("db error"),
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(func(a *Application) {
+				a.theaterRepo = &mocks.MockTheaterRepo{
+					UpdatePayoutAccountFunc: func(ctx context.Context, theaterID int, accountID string) error {
+						if theaterID != tt.theaterID || accountID != tt.input["accountId"] {
+							t.Errorf("unexpected update of theater %d to %q", theaterID, accountID)
+						}
+
+						return tt.updateErr
+					},
+				}
+			})
+
+			w, r := executeRequest(t, http.MethodPut, "/admin/theaters/1/payout-account", tt.input)
+			app.UpdateTheaterPayoutAccount(w, r, tt.theaterID)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+
+			checkErrorResponse(t, w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{tt.wantStatus, tt.wantErrMessage})
+		})
+	}
+}
+
+func TestPayoutSplit(t *testing.T) {
+	app := newTestApplication(func(a *Application) {
+		a.config.Stripe.ApplicationFeePercent = decimal.RequireFromString("7.5")
+	})
+
+	cart := &domain.Cart{TotalPrice: decimal.RequireFromString("25.99"), PayoutAccountID: "acct_1"}
+
+	t.Run("splits the payment of a theater with a connected account", func(t *testing.T) {
+		split := app.payoutSplit(context.Background(), cart.PayoutAccountID, cart.TotalPrice)
+		if split == nil {
+			t.Fatal("expected a payout split")
+		}
+
+		if split.AccountID != "acct_1" {
+			t.Errorf("expected account acct_1, got %s", split.AccountID)
+		}
+
+		// 7.5% of 25.99 is 1.94925
+		if !split.ApplicationFee.Equal(decimal.RequireFromString("1.95")) {
+			t.Errorf("expected application fee 1.95, got %s", split.ApplicationFee)
+		}
+
+		if !split.TransferAmount.Equal(decimal.RequireFromString("24.04")) {
+			t.Errorf("expected transfer amount 24.04, got %s", split.TransferAmount)
+		}
+	})
+
+	t.Run("keeps the payment of a theater without a connected account", func(t *testing.T) {
+		split := app.payoutSplit(context.Background(), "", cart.TotalPrice)
+		if split != nil {
+			t.Errorf("expected no payout split, got %+v", split)
+		}
+	})
+
+	t.Run("doesn't split a payment charged on the tenant's own account", func(t *testing.T) {
+		ctx := domain.WithTenant(context.Background(), &domain.Tenant{ID: 2, StripeAccountID: "acct_tenant"})
+
+		split := app.payoutSplit(ctx, cart.PayoutAccountID, cart.TotalPrice)
+		if split != nil {
+			t.Errorf("expected no payout split, got %+v", split)
+		}
+	})
+}
@@ -0,0 +1,270 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mailer"
+	"github.com/stripe/stripe-go/v82"
+)
+
+// CreatePhoneBooking holds the seats for a customer calling the box office and creates a checkout link the
+// customer pays with. The seats are locked under a hold of the staff member, keyed by the cart, so the
+// checkout completes and expires like a web checkout without a browser session.
+func (app *Application) CreatePhoneBooking(w http.ResponseWriter, r *http.Request, showtimeId int) {
+	if showtimeId < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("showtime ID must be greater than zero"))
+		return
+	}
+
+	var input api.CreatePhoneBookingRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	staffId := app.contextGetUserId(r)
+	seatIds := input.SeatIdList
+	logger := app.contextGetLogger(r).With("showtime_id", showtimeId, "staff_id", staffId)
+
+	showtimeSeats, err := app.selectableSeats(r.Context(), showtimeId, seatIds)
+	if err != nil {
+		switch {
+		case errors.Is(err, errSeatsAlreadyReserved):
+			logger.Warn("phone booking conflict: an already reserved seat was selected", "requested_seats", seatIds)
+			app.editConflictResponseWithErr(w, r, err)
+		case errors.Is(err, errSeatsBlocked):
+			logger.Warn("phone booking conflict: a blocked seat was selected", "requested_seats", seatIds)
+			app.editConflictResponseWithErr(w, r, err)
+		case errors.Is(err, domain.ErrRecordNotFound):
+			logger.Warn("phone booking failed: one or more requested seat IDs do not exist for the showtime", "requested_seats", seatIds)
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	allowed, err := app.canManageTheater(r.Context(), staffId, showtimeSeats.TheaterID)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.unauthorizedAccessResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	if !allowed {
+		logger.Warn("phone booking denied: user is not staff of the theater", "theater_id", showtimeSeats.TheaterID)
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	customer, err := app.phoneBookingCustomer(r.Context(), input.CustomerEmail)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	cart := domain.NewCart(showtimeId, showtimeSeats)
+	cart.FlexibleTicketFee = app.config.FlexibleTicketFee
+	cart.SalesChannel = domain.SalesChannelBoxOffice
+
+	if customer.ID == 0 {
+		cart.GuestEmail = customer.Email
+	}
+
+	if input.Note != nil {
+		cart.Note = sanitizeNote(*input.Note)
+	}
+
+	owner := seatHoldKey(staffId, cart.Id)
+
+	err = app.tryLockSeats(r.Context(), seatIds, showtimeId, owner)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrSeatAlreadyReserved):
+			logger.Warn("phone booking conflict: an already locked seat was selected")
+			app.editConflictResponseWithErr(w, r, errSeatsAlreadyLocked)
+		default:
+			app.serverErrorResponse(w, r, fmt.Errorf("seats couldn't be acquired: %w", err))
+		}
+
+		return
+	}
+
+	err = app.savePhoneBookingCart(r.Context(), &cart)
+	if err != nil {
+		app.rollbackSeatLocks(r.Context(), showtimeId, seatIds)
+		app.serverErrorResponse(w, r, fmt.Errorf("cart couldn't be created: %w", err))
+		return
+	}
+
+	app.publishSeatEvent(r.Context(), showtimeId, seatEventLocked, seatIds)
+
+	logger = logger.With("cart_id", cart.Id)
+
+	checkoutSession, expiresAt, err := app.createPhoneBookingCheckout(r.Context(), logger, &cart, customer, owner)
+	if err != nil {
+		// nothing can be paid, the seats are given back right away instead of when the locks expire
+		app.releaseCartSeats(r.Context(), logger, showtimeId, seatIds, cart.Id, owner)
+
+		switch {
+		case errors.Is(err, domain.ErrSeatLockExpired):
+			app.editConflictResponseWithErr(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	emailSent := app.sendPhoneBookingLink(r.Context(), logger, &cart, customer, checkoutSession.URL, expiresAt)
+
+	logger.Info("phone booking created", "guest", customer.ID == 0, "seat_ids", seatIds, "expires_at", expiresAt)
+
+	resp := api.PhoneBookingResponse{
+		CartId:        cart.Id,
+		CustomerEmail: customer.Email,
+		Guest:         customer.ID == 0,
+		CheckoutUrl:   checkoutSession.URL,
+		ExpiresAt:     expiresAt,
+		TotalPrice:    cart.TotalPrice,
+		EmailSent:     emailSent,
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// phoneBookingCustomer returns the user with the email, or a guest with only the email set when there's
+// no active account with it.
+func (app *Application) phoneBookingCustomer(ctx context.Context, email string) (*domain.User, error) {
+	user, err := app.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, domain.ErrRecordNotFound) {
+			return &domain.User{Email: email}, nil
+		}
+
+		return nil, err
+	}
+
+	return app.userRepo.GetById(ctx, user.ID)
+}
+
+// savePhoneBookingCart stores the cart of the locked seats. It isn't bound to a session, the checkout
+// session of the customer finds it by its id.
+func (app *Application) savePhoneBookingCart(ctx context.Context, cart *domain.Cart) error {
+	cartBytes, err := json.Marshal(cart)
+	if err != nil {
+		return err
+	}
+
+	seatIdInterfaces := make([]interface{}, len(cart.Seats))
+	for i, seat := range cart.Seats {
+		seatIdInterfaces[i] = seat.Id
+	}
+
+	pipe := app.redis.TxPipeline()
+	pipe.SAdd(ctx, seatSetKey(cart.ShowtimeID), seatIdInterfaces...)
+	pipe.Set(ctx, cart.Id, cartBytes, cartTTL)
+
+	_, err = pipe.Exec(ctx)
+
+	return err
+}
+
+// createPhoneBookingCheckout creates the pending payment and the checkout session of the cart, paid by the
+// customer. The seat locks are extended to the lifetime of the checkout session.
+func (app *Application) createPhoneBookingCheckout(
+	ctx context.Context,
+	logger *slog.Logger,
+	cart *domain.Cart,
+	customer *domain.User,
+	owner string) (*stripe.CheckoutSession, time.Time, error) {
+
+	expiresAt, err := app.holdForCheckout(ctx, cart.ShowtimeID, cart.SeatIDs(), cart.CreatedAt, cart.Id)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	payment := &domain.Payment{
+		UserID:       customer.ID,
+		Amount:       cart.TotalPrice,
+		Currency:     domain.DefaultCurrency,
+		Status:       domain.PaymentStatusPending,
+		Payout:       app.payoutSplit(ctx, cart.PayoutAccountID, cart.TotalPrice),
+		SalesChannel: domain.SalesChannelBoxOffice,
+	}
+
+	err = app.paymentRepo.Create(ctx, payment)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	checkoutSession, err := app.paymentProvider.CreateCheckoutSession(ctx, owner, customer, *cart, *payment, expiresAt)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	app.replaceCheckoutSession(ctx, logger, cart, checkoutSession.ID)
+
+	return checkoutSession, expiresAt, nil
+}
+
+// sendPhoneBookingLink emails the checkout link to the customer. It reports whether the email was sent,
+// staff pass the link on otherwise.
+func (app *Application) sendPhoneBookingLink(
+	ctx context.Context,
+	logger *slog.Logger,
+	cart *domain.Cart,
+	customer *domain.User,
+	checkoutUrl string,
+	expiresAt time.Time) bool {
+
+	seats := make([]string, len(cart.Seats))
+	for i, s := range cart.Seats {
+		seats[i] = formatReservationSeat(domain.ReservationDetailSeat{Row: s.Row, Col: s.Col, Type: s.SeatType})
+	}
+
+	data := mailer.PhoneBookingEmail{
+		FirstName:   customer.FirstName,
+		MovieTitle:  cart.MovieName,
+		TheaterName: cart.TheaterName,
+		HallName:    cart.HallName,
+		Showtime:    cart.Date.In(domain.TheaterLocation(cart.TheaterTimeZone)).Format("Mon, 02 Jan 2006 15:04 MST"),
+		Seats:       seats,
+		TotalPrice:  fmt.Sprintf("%s %s", cart.TotalPrice.StringFixed(2), domain.DefaultCurrency),
+		CheckoutUrl: checkoutUrl,
+		ExpiresAt:   expiresAt.UTC().Format("Mon, 02 Jan 2006 15:04 MST"),
+	}
+
+	err := app.mailer.Send(ctx, customer.Email, data)
+	if err != nil {
+		logger.Error("failed to send phone booking payment link", "error", err)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,407 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/metinatakli/movie-reservation-system/internal/validator"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/stripe/stripe-go/v82"
+)
+
+const (
+	testBoxOfficeStaffID = 7
+	testBoxOfficeTheater = 3
+	testCustomerEmail    = "customer@example.com"
+)
+
+type PhoneBookingTestSuite struct {
+	suite.Suite
+	app             *Application
+	seatRepo        *mocks.MockSeatRepo
+	reservationRepo *mocks.MockReservationRepo
+	paymentRepo     *mocks.MockPaymentRepo
+	paymentProvider *mocks.MockPaymentProvider
+	userRepo        *mocks.MockUserRepo
+	theaterRepo     *mocks.MockTheaterRepo
+	redisClient     *mocks.MockRedisClient
+	redisPipeline   *mocks.MockTxPipeline
+	sentEmails      []string
+	mailErr         error
+}
+
+func (s *PhoneBookingTestSuite) SetupTest() {
+	s.seatRepo = new(mocks.MockSeatRepo)
+	s.reservationRepo = new(mocks.MockReservationRepo)
+	s.paymentRepo = new(mocks.MockPaymentRepo)
+	s.paymentProvider = new(mocks.MockPaymentProvider)
+	s.redisClient = new(mocks.MockRedisClient)
+	s.redisPipeline = new(mocks.MockTxPipeline)
+	s.sentEmails = nil
+	s.mailErr = nil
+
+	s.userRepo = &mocks.MockUserRepo{
+		GetByIdFunc: func(ctx context.Context, id int) (*domain.User, error) {
+			return &domain.User{ID: id, FirstName: "Ada", Email: testCustomerEmail, Role: domain.RoleUser}, nil
+		},
+		GetByEmailFunc: func(ctx context.Context, email string) (*domain.User, error) {
+			return nil, domain.ErrRecordNotFound
+		},
+	}
+	s.theaterRepo = &mocks.MockTheaterRepo{
+		IsTheaterStaffFunc: func(ctx context.Context, theaterID, userID int) (bool, error) {
+			return theaterID == testBoxOfficeTheater && userID == testBoxOfficeStaffID, nil
+		},
+	}
+
+	s.app = newTestApplication(func(a *Application) {
+		a.seatRepo = s.seatRepo
+		a.reservationRepo = s.reservationRepo
+		a.paymentRepo = s.paymentRepo
+		a.paymentProvider = s.paymentProvider
+		a.userRepo = s.userRepo
+		a.theaterRepo = s.theaterRepo
+		a.redis = s.redisClient
+		a.mailer = &MockMailer{sendFunc: func(recipient, template string, data any) error {
+			s.sentEmails = append(s.sentEmails, recipient+":"+template)
+			return s.mailErr
+		}}
+	})
+}
+
+func TestPhoneBookingSuite(t *testing.T) {
+	suite.Run(t, new(PhoneBookingTestSuite))
+}
+
+// isStaffHold matches the seat lock owner of a phone booking, a hold of the staff member keyed by the cart.
+func isStaffHold(owner string) bool {
+	return strings.HasPrefix(owner, seatHoldKey(testBoxOfficeStaffID, ""))
+}
+
+func (s *PhoneBookingTestSuite) expectSelectableSeats() {
+	s.reservationRepo.On("GetSeatsByShowtimeId", mock.Anything, 1).Return([]domain.ReservationSeat{}, nil)
+	s.seatRepo.On("GetSeatBlocksByShowtime", mock.Anything, 1).Return([]domain.SeatBlock{}, nil)
+	s.seatRepo.On("GetSeatsByShowtimeAndSeatIds", mock.Anything, 1, testSeatIDs).
+		Return(&domain.ShowtimeSeats{TheaterID: testBoxOfficeTheater, Price: 10, Seats: testSeats}, nil)
+}
+
+// expectCartHeld mocks the seats being locked for the staff member, the cart being stored and the locks
+// being extended for the checkout session.
+func (s *PhoneBookingTestSuite) expectCartHeld() {
+	lockKeys := []string{seatLockKey(1, 1), seatLockKey(1, 2), seatLockKey(1, 3)}
+
+	s.redisClient.On("EvalSha", mock.Anything, mock.Anything, lockKeys, mock.MatchedBy(isStaffHold), int(seatLockTTL.Seconds())).
+		Return(redis.NewCmdResult("OK", nil)).Once()
+
+	s.redisClient.On("TxPipeline").Return(s.redisPipeline).Once()
+	s.redisPipeline.On("SAdd", mock.Anything, seatSetKey(1), []interface{}{1, 2, 3}).Return(redis.NewIntResult(3, nil)).Once()
+	s.redisPipeline.On("Set", mock.Anything, mock.Anything, mock.MatchedBy(func(value []byte) bool {
+		var cart domain.Cart
+		if err := json.Unmarshal(value, &cart); err != nil {
+			return false
+		}
+
+		return cart.SalesChannel == domain.SalesChannelBoxOffice
+	}), cartTTL).Return(redis.NewStatusResult("OK", nil)).Once()
+	s.redisPipeline.On("Exec", mock.Anything).Return([]redis.Cmder{}, nil).Once()
+	s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatMapChangeKeys(1), seatEventsChannel(1), mock.Anything, mock.Anything).
+		Return(redis.NewCmdResult(int64(1), nil)).Once()
+
+	s.redisClient.On("PTTL", mock.Anything, seatLockKey(1, 1)).Return(redis.NewDurationResult(seatLockTTL, nil)).Once()
+	s.redisClient.On("TxPipeline").Return(s.redisPipeline).Once()
+	s.redisPipeline.On("ExpireAt", mock.Anything, mock.Anything, mock.Anything).Return(redis.NewBoolResult(true, nil)).Times(4)
+	s.redisPipeline.On("Exec", mock.Anything).Return([]redis.Cmder{}, nil).Once()
+}
+
+func (s *PhoneBookingTestSuite) expectPaymentCreated(userId int) {
+	s.paymentRepo.On("Create", mock.Anything, mock.MatchedBy(func(p *domain.Payment) bool {
+		return p.UserID == userId && p.SalesChannel == domain.SalesChannelBoxOffice
+	})).Run(func(args mock.Arguments) {
+		args.Get(1).(*domain.Payment).ID = 42
+	}).Return(nil).Once()
+}
+
+func (s *PhoneBookingTestSuite) TestCreatePhoneBooking() {
+	validInput := api.CreatePhoneBookingRequest{
+		CustomerEmail: testCustomerEmail,
+		SeatIdList:    testSeatIDs,
+	}
+
+	checkoutSession := &stripe.CheckoutSession{ID: "cs_phone", URL: "https://checkout.stripe.com/cs_phone"}
+
+	tests := []struct {
+		name           string
+		showtimeID     int
+		staffID        int
+		input          api.CreatePhoneBookingRequest
+		setupMocks     func()
+		wantStatus     int
+		wantErrMessage string
+		wantErrCode    api.ErrorCode
+		wantGuest      bool
+		wantEmailSent  bool
+		wantEmails     []string
+	}{
+		{
+			name:           "should fail when showtime ID is zero or negative",
+			showtimeID:     0,
+			staffID:        testBoxOfficeStaffID,
+			input:          validInput,
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "showtime ID must be greater than zero",
+			wantErrCode:    api.BADREQUEST,
+		},
+		{
+			name:       "should fail when the customer email is invalid",
+			showtimeID: 1,
+			staffID:    testBoxOfficeStaffID,
+			input: api.CreatePhoneBookingRequest{
+				CustomerEmail: "not-an-email",
+				SeatIdList:    testSeatIDs,
+			},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: validator.ErrInvalidEmail,
+			wantErrCode:    api.VALIDATIONFAILED,
+		},
+		{
+			name:       "should fail when a seat is already reserved",
+			showtimeID: 1,
+			staffID:    testBoxOfficeStaffID,
+			input:      validInput,
+			setupMocks: func() {
+				s.reservationRepo.On("GetSeatsByShowtimeId", mock.Anything, 1).
+					Return([]domain.ReservationSeat{{ReservationID: 1, ShowtimeID: 1, SeatID: 2}}, nil)
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: errSeatsAlreadyReserved.Error(),
+			wantErrCode:    api.SEATALREADYRESERVED,
+		},
+		{
+			name:       "should fail when the user is not staff of the theater",
+			showtimeID: 1,
+			staffID:    8,
+			input:      validInput,
+			setupMocks: func() {
+				s.expectSelectableSeats()
+			},
+			wantStatus:     http.StatusForbidden,
+			wantErrMessage: ErrForbiddenAccess,
+			wantErrCode:    api.FORBIDDEN,
+		},
+		{
+			name:       "should fail when a seat is locked by someone else",
+			showtimeID: 1,
+			staffID:    testBoxOfficeStaffID,
+			input:      validInput,
+			setupMocks: func() {
+				s.expectSelectableSeats()
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, mock.Anything, mock.MatchedBy(isStaffHold), mock.Anything).
+					Return(redis.NewCmdResult(nil, mocks.MockRedisError{Msg: "seat already locked"})).Once()
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: errSeatsAlreadyLocked.Error(),
+			wantErrCode:    api.SEATALREADYLOCKED,
+		},
+		{
+			name:       "should release the seats when the checkout session can't be created",
+			showtimeID: 1,
+			staffID:    testBoxOfficeStaffID,
+			input:      validInput,
+			setupMocks: func() {
+				s.expectSelectableSeats()
+				s.expectCartHeld()
+				s.expectPaymentCreated(0)
+				s.paymentProvider.On("CreateCheckoutSession", mock.Anything, mock.Anything, mock.Anything).
+					Return(&stripe.CheckoutSession{}, errors.New("payment provider error")).Once()
+
+				lockKeys := []string{seatLockKey(1, 1), seatLockKey(1, 2), seatLockKey(1, 3)}
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, lockKeys, mock.MatchedBy(isStaffHold)).
+					Return(redis.NewCmdResult([]interface{}{int64(1), int64(1), int64(1)}, nil)).Once()
+				s.redisClient.On("TxPipeline").Return(s.redisPipeline).Once()
+				for _, seatID := range testSeatIDs {
+					s.redisPipeline.On("SRem", mock.Anything, seatSetKey(1), []interface{}{seatID}).Return(redis.NewIntResult(1, nil)).Once()
+				}
+				s.redisPipeline.On("Del", mock.Anything, mock.Anything).Return(redis.NewIntResult(1, nil)).Twice()
+				s.redisPipeline.On("Exec", mock.Anything).Return([]redis.Cmder{}, nil).Once()
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatMapChangeKeys(1), seatEventsChannel(1), mock.Anything, mock.Anything).
+					Return(redis.NewCmdResult(int64(1), nil)).Once()
+			},
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+			wantErrCode:    api.INTERNALERROR,
+		},
+		{
+			name:       "should book for the account of the customer",
+			showtimeID: 1,
+			staffID:    testBoxOfficeStaffID,
+			input:      validInput,
+			setupMocks: func() {
+				s.userRepo.GetByEmailFunc = func(ctx context.Context, email string) (*domain.User, error) {
+					return &domain.User{ID: 21}, nil
+				}
+
+				s.expectSelectableSeats()
+				s.expectCartHeld()
+				s.expectPaymentCreated(21)
+				s.paymentProvider.On(
+					"CreateCheckoutSession",
+					mock.MatchedBy(isStaffHold),
+					mock.MatchedBy(func(u *domain.User) bool { return u.ID == 21 }),
+					mock.MatchedBy(func(c domain.Cart) bool {
+						return c.GuestEmail == "" && c.SalesChannel == domain.SalesChannelBoxOffice
+					}),
+				).Return(checkoutSession, nil).Once()
+				s.redisClient.On("Set", mock.Anything, mock.Anything, mock.Anything, time.Duration(redis.KeepTTL)).
+					Return(redis.NewStatusResult("OK", nil)).Once()
+			},
+			wantStatus:    http.StatusCreated,
+			wantEmailSent: true,
+			wantEmails:    []string{testCustomerEmail + ":phone_booking.tmpl"},
+		},
+		{
+			name:       "should book for a guest and report a failed email",
+			showtimeID: 1,
+			staffID:    testBoxOfficeStaffID,
+			input:      validInput,
+			setupMocks: func() {
+				s.mailErr = errors.New("smtp error")
+
+				s.expectSelectableSeats()
+				s.expectCartHeld()
+				s.expectPaymentCreated(0)
+				s.paymentProvider.On(
+					"CreateCheckoutSession",
+					mock.MatchedBy(isStaffHold),
+					mock.MatchedBy(func(u *domain.User) bool { return u.ID == 0 && u.Email == testCustomerEmail }),
+					mock.MatchedBy(func(c domain.Cart) bool { return c.GuestEmail == testCustomerEmail }),
+				).Return(checkoutSession, nil).Once()
+				s.redisClient.On("Set", mock.Anything, mock.Anything, mock.Anything, time.Duration(redis.KeepTTL)).
+					Return(redis.NewStatusResult("OK", nil)).Once()
+			},
+			wantStatus:    http.StatusCreated,
+			wantGuest:     true,
+			wantEmailSent: false,
+			wantEmails:    []string{testCustomerEmail + ":phone_booking.tmpl"},
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			w, r := executeRequest(s.T(), http.MethodPost, fmt.Sprintf("/admin/showtimes/%d/phone-bookings", tt.showtimeID), tt.input)
+			r = r.WithContext(context.WithValue(r.Context(), SessionKeyUserId, tt.staffID))
+
+			requestedAt := time.Now()
+			s.app.CreatePhoneBooking(w, r, tt.showtimeID)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantErrCode != "" {
+				checkErrorCode(s.T(), w, tt.wantErrCode)
+			}
+
+			if tt.wantStatus == http.StatusCreated {
+				var response api.PhoneBookingResponse
+				s.Require().NoError(json.NewDecoder(w.Body).Decode(&response))
+
+				s.NotEmpty(response.CartId)
+				s.Equal(testCustomerEmail, response.CustomerEmail)
+				s.Equal(tt.wantGuest, response.Guest)
+				s.Equal(checkoutSession.URL, response.CheckoutUrl)
+				s.Equal(tt.wantEmailSent, response.EmailSent)
+				s.WithinDuration(requestedAt.Add(minCheckoutSessionTTL), response.ExpiresAt, time.Second)
+			}
+
+			checkErrorResponse(s.T(), w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+
+			s.Equal(tt.wantEmails, s.sentEmails)
+
+			s.redisClient.AssertExpectations(s.T())
+			s.redisPipeline.AssertExpectations(s.T())
+			s.reservationRepo.AssertExpectations(s.T())
+			s.seatRepo.AssertExpectations(s.T())
+			s.paymentRepo.AssertExpectations(s.T())
+			s.paymentProvider.AssertExpectations(s.T())
+		})
+	}
+}
+
+func (s *PhoneBookingTestSuite) TestPrepareCheckoutCompletionOfGuestCart() {
+	owner := seatHoldKey(testBoxOfficeStaffID, "cart-1")
+
+	tests := []struct {
+		name       string
+		guestEmail string
+		wantErr    error
+	}{
+		{
+			name:       "should complete the checkout of a guest without a user",
+			guestEmail: testCustomerEmail,
+		},
+		{
+			name:    "should reject a checkout without a user when the cart isn't booked for a guest",
+			wantErr: errInvalidCheckoutMetadata,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			cartJSON, err := json.Marshal(domain.Cart{
+				ShowtimeID:   1,
+				Seats:        []domain.CartSeat{{Id: 1, Row: 1, Col: 1}},
+				SalesChannel: domain.SalesChannelBoxOffice,
+				GuestEmail:   tt.guestEmail,
+			})
+			s.Require().NoError(err)
+
+			s.paymentRepo.On("GetById", mock.Anything, 3).
+				Return(&domain.Payment{ID: 3, Status: domain.PaymentStatusPending}, nil)
+			s.redisClient.On("Get", mock.Anything, "cart-1").Return(redis.NewStringResult(string(cartJSON), nil))
+			s.redisClient.On("Get", mock.Anything, seatLockKey(1, 1)).Return(redis.NewStringResult(owner, nil))
+
+			completion, err := s.app.prepareCheckoutCompletion(context.Background(), stripe.CheckoutSession{
+				ID: "cs_1",
+				Metadata: map[string]string{
+					domain.CheckoutMetadataCartID:    "cart-1",
+					domain.CheckoutMetadataSessionID: owner,
+					domain.CheckoutMetadataUserID:    "0",
+					domain.CheckoutMetadataPaymentID: "3",
+				},
+			})
+
+			if tt.wantErr != nil {
+				s.ErrorIs(err, tt.wantErr)
+				return
+			}
+
+			s.Require().NoError(err)
+			s.Equal(0, completion.userId)
+			s.Equal(tt.guestEmail, completion.cart.GuestEmail)
+			s.Equal(owner, completion.sessionId)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+func (app *Application) UpdateTheaterPreShow(w http.ResponseWriter, r *http.Request, theaterID int) {
+	logger := app.contextGetLogger(r)
+
+	if theaterID < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("theater ID must be greater than zero"))
+		return
+	}
+
+	var input api.UpdateTheaterPreShowRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	err = app.theaterRepo.UpdateTheaterPreShow(r.Context(), theaterID, input.PreShowMinutes)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	logger.Info("theater pre-show changed", "theater_id", theaterID, "pre_show_minutes", input.PreShowMinutes)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UpdateShowtimePreShow overrides the pre-show of the theater for a single showtime, e.g. for a premiere
+// without ads. Leaving the minutes out goes back to the one of the theater.
+func (app *Application) UpdateShowtimePreShow(w http.ResponseWriter, r *http.Request, showtimeID int) {
+	logger := app.contextGetLogger(r)
+
+	if showtimeID < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("showtime ID must be greater than zero"))
+		return
+	}
+
+	var input api.UpdateShowtimePreShowRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	err = app.theaterRepo.UpdateShowtimePreShow(r.Context(), showtimeID, input.PreShowMinutes)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	logger.Info("showtime pre-show changed", "showtime_id", showtimeID, "pre_show_minutes", input.PreShowMinutes)
+
+	w.WriteHeader(http.StatusNoContent)
+}
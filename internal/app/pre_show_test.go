@@ -0,0 +1,187 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+)
+
+func TestUpdateTheaterPreShow(t *testing.T) {
+	tests := []struct {
+		name           string
+		theaterID      int
+		input          map[string]any
+		updateErr      error
+		wantStatus     int
+		wantErrMessage string
+		wantMinutes    int
+	}{
+		{
+			name:           "invalid theater ID",
+			theaterID:      0,
+			input:          map[string]any{"preShowMinutes": 15},
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "theater ID must be greater than zero",
+		},
+		{
+			name:           "too long",
+			theaterID:      1,
+			input:          map[string]any{"preShowMinutes": 90},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: "must be at most 60",
+		},
+		{
+			name:           "theater not found",
+			theaterID:      99,
+			input:          map[string]any{"preShowMinutes": 15},
+			updateErr:      domain.ErrRecordNotFound,
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+			wantMinutes:    15,
+		},
+		{
+			name:        "pre-show changed",
+			theaterID:   1,
+			input:       map[string]any{"preShowMinutes": 20},
+			wantStatus:  http.StatusNoContent,
+			wantMinutes: 20,
+		},
+		{
+			name:           "database error",
+			theaterID:      1,
+			input:          map[string]any{"preShowMinutes": 0},
+			updateErr:      errors.New("db error"),
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(func(a *Application) {
+				a.theaterRepo = &mocks.MockTheaterRepo{
+					UpdateTheaterPreShowFunc: func(ctx context.Context, theaterID, minutes int) error {
+						if theaterID != tt.theaterID || minutes != tt.wantMinutes {
+							t.Errorf("unexpected update of theater %d to %d minutes", theaterID, minutes)
+						}
+
+						return tt.updateErr
+					},
+				}
+			})
+
+			w, r := executeRequest(t, http.MethodPut, "/admin/theaters/1/pre-show", tt.input)
+			app.UpdateTheaterPreShow(w, r, tt.theaterID)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+
+			checkErrorResponse(t, w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{tt.wantStatus, tt.wantErrMessage})
+		})
+	}
+}
+
+func TestUpdateShowtimePreShow(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          map[string]any
+		updateErr      error
+		wantStatus     int
+		wantErrMessage string
+		wantMinutes    *int
+	}{
+		{
+			name:           "too long",
+			input:          map[string]any{"preShowMinutes": 61},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: "must be at most 60",
+		},
+		{
+			name:           "showtime not found",
+			input:          map[string]any{"preShowMinutes": 0},
+			updateErr:      domain.ErrRecordNotFound,
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+			wantMinutes:    ptr(0),
+		},
+		{
+			name:        "pre-show overridden",
+			input:       map[string]any{"preShowMinutes": 5},
+			wantStatus:  http.StatusNoContent,
+			wantMinutes: ptr(5),
+		},
+		{
+			name:       "reset to the pre-show of the theater",
+			input:      map[string]any{},
+			wantStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(func(a *Application) {
+				a.theaterRepo = &mocks.MockTheaterRepo{
+					UpdateShowtimePreShowFunc: func(ctx context.Context, showtimeID int, minutes *int) error {
+						if (minutes == nil) != (tt.wantMinutes == nil) || minutes != nil && *minutes != *tt.wantMinutes {
+							t.Errorf("unexpected update of showtime %d to %v minutes", showtimeID, minutes)
+						}
+
+						return tt.updateErr
+					},
+				}
+			})
+
+			w, r := executeRequest(t, http.MethodPut, "/admin/showtimes/1/pre-show", tt.input)
+			app.UpdateShowtimePreShow(w, r, 1)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+
+			checkErrorResponse(t, w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{tt.wantStatus, tt.wantErrMessage})
+		})
+	}
+}
+
+func TestToShowtimesPreShow(t *testing.T) {
+	start := time.Now().Add(-5 * time.Minute).Truncate(time.Second)
+
+	tests := []struct {
+		name           string
+		preShowMinutes int
+		wantStatus     api.ShowtimeStatus
+	}{
+		{name: "feature started", preShowMinutes: 0, wantStatus: api.EXPIRED},
+		{name: "during the pre-show", preShowMinutes: 15, wantStatus: api.AVAILABLE},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			showtimes := toShowtimes([]domain.Showtime{
+				{ID: 1, StartTime: start, PreShowMinutes: tt.preShowMinutes},
+			}, time.UTC, nil)
+
+			if showtimes[0].Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", showtimes[0].Status, tt.wantStatus)
+			}
+
+			wantCloseAt := start.Add(time.Duration(tt.preShowMinutes) * time.Minute)
+			if !showtimes[0].SalesCloseAt.Equal(wantCloseAt) {
+				t.Errorf("sales close at %v, want %v", showtimes[0].SalesCloseAt, wantCloseAt)
+			}
+		})
+	}
+}
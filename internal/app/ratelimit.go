@@ -0,0 +1,80 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// rateLimitScript counts a request in a fixed window. The window starts with the first request and its
+// key expires with it.
+var rateLimitScript = redis.NewScript(`
+	-- KEYS[1] = counter key
+	-- ARGV = [limit, window in milliseconds]
+	local count = redis.call("INCR", KEYS[1])
+
+	if count == 1 then
+		redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	end
+
+	if count > tonumber(ARGV[1]) then
+		return redis.call("PTTL", KEYS[1])
+	end
+
+	return 0
+`)
+
+type rateLimit struct {
+	name   string
+	limit  int
+	window time.Duration
+}
+
+// allow counts a request against the limit for the given key. When the limit is exceeded it returns
+// false along with the time left until the window resets.
+func (app *Application) allow(ctx context.Context, rl rateLimit, key string) (bool, time.Duration, error) {
+	redisKey := fmt.Sprintf("rate_limit:%s:%s", rl.name, key)
+
+	retryAfter, err := rateLimitScript.Run(ctx, app.redis, []string{redisKey}, rl.limit, rl.window.Milliseconds()).Int64()
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to run rate limit script: %w", err)
+	}
+
+	if retryAfter > 0 {
+		return false, time.Duration(retryAfter) * time.Millisecond, nil
+	}
+
+	return true, 0, nil
+}
+
+// allowRequest counts the request against the limit for the given key. It writes the error response and
+// returns false when the request must be rejected.
+func (app *Application) allowRequest(w http.ResponseWriter, r *http.Request, rl rateLimit, key string) bool {
+	allowed, retryAfter, err := app.allow(r.Context(), rl, key)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return false
+	}
+
+	if !allowed {
+		app.rateLimitExceededResponse(w, r, retryAfter)
+		return false
+	}
+
+	return true
+}
+
+// clientIP returns the address of the client, which realIP has already taken from the headers of the trusted
+// proxies.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,130 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/scheduler"
+	"github.com/oapi-codegen/runtime/types"
+)
+
+// a payment is recorded when its webhook arrives, which may be on the day after its charge
+const reconciliationSlack = time.Hour
+
+// GetReconciliationReport returns the reconciliation of the payments of the UTC day with Stripe.
+func (app *Application) GetReconciliationReport(w http.ResponseWriter, r *http.Request, date string) {
+	day, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("invalid date, expected YYYY-MM-DD"))
+		return
+	}
+
+	report, err := app.paymentRepo.GetReconciliationReport(r.Context(), day)
+	if err != nil {
+		if errors.Is(err, domain.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+			return
+		}
+
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, toApiReconciliationReport(*report), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// reconcilePayments is the background job which compares the payments and refunds of the last days with
+// the Stripe balance and stores a report for every day. Mismatches are reported, nothing is repaired.
+func (app *Application) reconcilePayments(ctx context.Context) error {
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	firstDay := today.AddDate(0, 0, -app.config.Reconciliation.LookbackDays)
+
+	from := firstDay.Add(-reconciliationSlack)
+	to := today.Add(reconciliationSlack)
+
+	entries, err := app.paymentRepo.GetLedgerEntries(ctx, from, to)
+	if err != nil {
+		return err
+	}
+
+	transactions, err := app.paymentProvider.ListBalanceTransactions(ctx, from, to)
+	if err != nil {
+		return err
+	}
+
+	for day := firstDay; day.Before(today); day = day.AddDate(0, 0, 1) {
+		mismatches, totals := domain.Reconcile(day, day.AddDate(0, 0, 1), entries, transactions)
+
+		report := &domain.ReconciliationReport{
+			Date:       day,
+			CheckedAt:  now,
+			Totals:     totals,
+			Mismatches: mismatches,
+		}
+
+		if err := scheduler.CheckFence(ctx); err != nil {
+			return err
+		}
+
+		err = app.paymentRepo.SaveReconciliationReport(ctx, report)
+		if err != nil {
+			return err
+		}
+
+		if len(mismatches) > 0 {
+			app.logger.Warn("payment reconciliation found mismatches",
+				"date", day.Format(time.DateOnly),
+				"count", len(mismatches))
+		}
+	}
+
+	return nil
+}
+
+func toApiReconciliationReport(report domain.ReconciliationReport) api.ReconciliationReport {
+	resp := api.ReconciliationReport{
+		Date:      types.Date{Time: report.Date},
+		CheckedAt: report.CheckedAt,
+		Totals: api.ReconciliationTotals{
+			InternalPayments: report.Totals.InternalPayments,
+			InternalRefunds:  report.Totals.InternalRefunds,
+			StripeCharges:    report.Totals.StripeCharges,
+			StripeRefunds:    report.Totals.StripeRefunds,
+			StripeFees:       report.Totals.StripeFees,
+			StripePayouts:    report.Totals.StripePayouts,
+		},
+		Mismatches: make([]api.ReconciliationMismatch, len(report.Mismatches)),
+	}
+
+	for i, mismatch := range report.Mismatches {
+		resp.Mismatches[i] = api.ReconciliationMismatch{
+			Kind:           api.ReconciliationMismatchKind(mismatch.Kind),
+			Type:           api.ReconciliationTransactionType(mismatch.Type),
+			InternalAmount: mismatch.InternalAmount,
+			StripeAmount:   mismatch.StripeAmount,
+		}
+
+		if mismatch.PaymentID != 0 {
+			resp.Mismatches[i].PaymentId = &mismatch.PaymentID
+		}
+
+		if mismatch.PaymentIntentID != "" {
+			resp.Mismatches[i].PaymentIntentId = &mismatch.PaymentIntentID
+		}
+
+		if mismatch.StripeRefundID != "" {
+			resp.Mismatches[i].StripeRefundId = &mismatch.StripeRefundID
+		}
+	}
+
+	return resp
+}
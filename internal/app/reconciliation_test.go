@@ -0,0 +1,232 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type ReconciliationTestSuite struct {
+	suite.Suite
+	app             *Application
+	paymentRepo     *mocks.MockPaymentRepo
+	paymentProvider *mocks.MockPaymentProvider
+}
+
+func (s *ReconciliationTestSuite) SetupTest() {
+	s.paymentRepo = new(mocks.MockPaymentRepo)
+	s.paymentProvider = new(mocks.MockPaymentProvider)
+
+	s.app = newTestApplication(func(a *Application) {
+		a.paymentRepo = s.paymentRepo
+		a.paymentProvider = s.paymentProvider
+		a.config.Reconciliation.LookbackDays = 1
+	})
+}
+
+func TestReconciliationSuite(t *testing.T) {
+	suite.Run(t, new(ReconciliationTestSuite))
+}
+
+func (s *ReconciliationTestSuite) TestReconcilePayments() {
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	yesterday := today.AddDate(0, 0, -1)
+
+	entries := []domain.LedgerEntry{
+		{PaymentID: 1, PaymentIntentID: "pi_1", Amount: decimal.RequireFromString("20.00"), RecordedAt: yesterday.Add(9 * time.Hour)},
+		{PaymentID: 2, PaymentIntentID: "pi_2", Amount: decimal.RequireFromString("15.00"), RecordedAt: yesterday.Add(10 * time.Hour)},
+		{PaymentID: 3, PaymentIntentID: "pi_3", Amount: decimal.RequireFromString("12.50"), RecordedAt: yesterday.Add(11 * time.Hour)},
+		// the webhook of a charge made just before midnight arrived after it
+		{PaymentID: 4, PaymentIntentID: "pi_4", Amount: decimal.RequireFromString("30.00"), RecordedAt: today.Add(5 * time.Minute)},
+		{PaymentID: 1, PaymentIntentID: "pi_1", StripeRefundID: "re_1", Amount: decimal.RequireFromString("5.00"), RecordedAt: yesterday.Add(12 * time.Hour)},
+	}
+
+	transactions := []domain.BalanceTransaction{
+		{Type: domain.BalanceTransactionCharge, SourceID: "ch_1", PaymentIntentID: "pi_1", Amount: decimal.RequireFromString("20.00"), Fee: decimal.RequireFromString("0.88"), CreatedAt: yesterday.Add(9 * time.Hour)},
+		{Type: domain.BalanceTransactionCharge, SourceID: "ch_2", PaymentIntentID: "pi_2", Amount: decimal.RequireFromString("16.00"), Fee: decimal.RequireFromString("0.76"), CreatedAt: yesterday.Add(10 * time.Hour)},
+		{Type: domain.BalanceTransactionCharge, SourceID: "ch_4", PaymentIntentID: "pi_4", Amount: decimal.RequireFromString("30.00"), Fee: decimal.RequireFromString("1.17"), CreatedAt: today.Add(-5 * time.Minute)},
+		{Type: domain.BalanceTransactionCharge, SourceID: "ch_5", PaymentIntentID: "pi_5", Amount: decimal.RequireFromString("9.00"), Fee: decimal.RequireFromString("0.56"), CreatedAt: yesterday.Add(13 * time.Hour)},
+		{Type: domain.BalanceTransactionRefund, SourceID: "re_1", PaymentIntentID: "pi_1", Amount: decimal.RequireFromString("-5.00"), CreatedAt: yesterday.Add(12 * time.Hour)},
+		{Type: domain.BalanceTransactionRefund, SourceID: "re_2", PaymentIntentID: "pi_2", Amount: decimal.RequireFromString("-4.00"), CreatedAt: yesterday.Add(14 * time.Hour)},
+		{Type: domain.BalanceTransactionPayout, SourceID: "po_1", Amount: decimal.RequireFromString("-100.00"), CreatedAt: yesterday.Add(15 * time.Hour)},
+	}
+
+	s.paymentRepo.On("GetLedgerEntries", mock.Anything, yesterday.Add(-reconciliationSlack), today.Add(reconciliationSlack)).Return(entries, nil)
+	s.paymentProvider.On("ListBalanceTransactions", yesterday.Add(-reconciliationSlack), today.Add(reconciliationSlack)).Return(transactions, nil)
+
+	var saved *domain.ReconciliationReport
+
+	s.paymentRepo.On("SaveReconciliationReport", mock.Anything, mock.MatchedBy(func(report *domain.ReconciliationReport) bool {
+		saved = report
+		return true
+	})).Return(nil).Once()
+
+	err := s.app.reconcilePayments(context.Background())
+	s.Require().NoError(err)
+
+	s.Require().NotNil(saved)
+	s.Equal(yesterday, saved.Date)
+
+	s.True(decimal.RequireFromString("77.50").Equal(saved.Totals.InternalPayments))
+	s.True(decimal.RequireFromString("5.00").Equal(saved.Totals.InternalRefunds))
+	s.True(decimal.RequireFromString("75.00").Equal(saved.Totals.StripeCharges))
+	s.True(decimal.RequireFromString("9.00").Equal(saved.Totals.StripeRefunds))
+	s.True(decimal.RequireFromString("3.37").Equal(saved.Totals.StripeFees))
+	s.True(decimal.RequireFromString("100.00").Equal(saved.Totals.StripePayouts))
+
+	s.Require().Len(saved.Mismatches, 4)
+
+	s.Equal(domain.MismatchAmount, saved.Mismatches[0].Kind)
+	s.Equal(2, saved.Mismatches[0].PaymentID)
+	s.True(decimal.RequireFromString("16.00").Equal(*saved.Mismatches[0].StripeAmount))
+
+	s.Equal(domain.MismatchMissingInStripe, saved.Mismatches[1].Kind)
+	s.Equal(3, saved.Mismatches[1].PaymentID)
+	s.Nil(saved.Mismatches[1].StripeAmount)
+
+	s.Equal(domain.MismatchMissingInternally, saved.Mismatches[2].Kind)
+	s.Equal(domain.BalanceTransactionCharge, saved.Mismatches[2].Type)
+	s.Equal("pi_5", saved.Mismatches[2].PaymentIntentID)
+	s.Nil(saved.Mismatches[2].InternalAmount)
+
+	s.Equal(domain.MismatchMissingInternally, saved.Mismatches[3].Kind)
+	s.Equal(domain.BalanceTransactionRefund, saved.Mismatches[3].Type)
+	s.Equal("re_2", saved.Mismatches[3].StripeRefundID)
+	s.True(decimal.RequireFromString("4.00").Equal(*saved.Mismatches[3].StripeAmount))
+
+	s.paymentRepo.AssertExpectations(s.T())
+}
+
+func (s *ReconciliationTestSuite) TestReconcilePaymentsFailsWhenStripeFails() {
+	s.paymentRepo.On("GetLedgerEntries", mock.Anything, mock.Anything, mock.Anything).Return([]domain.LedgerEntry{}, nil)
+	s.paymentProvider.On("ListBalanceTransactions", mock.Anything, mock.Anything).Return(nil, errors.New("stripe error"))
+
+	err := s.app.reconcilePayments(context.Background())
+	s.Error(err)
+
+	s.paymentRepo.AssertNotCalled(s.T(), "SaveReconciliationReport", mock.Anything, mock.Anything)
+}
+
+func (s *ReconciliationTestSuite) TestGetReconciliationReport() {
+	day := time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC)
+	paymentId := 3
+	amount := decimal.RequireFromString("12.50")
+
+	tests := []struct {
+		name           string
+		date           string
+		setupMocks     func()
+		wantStatus     int
+		wantErrMessage string
+		wantResponse   *api.ReconciliationReport
+	}{
+		{
+			name:           "should fail when the date is invalid",
+			date:           "14-03-2025",
+			setupMocks:     func() {},
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "invalid date, expected YYYY-MM-DD",
+		},
+		{
+			name: "should return not found when the day isn't reconciled yet",
+			date: "2025-03-14",
+			setupMocks: func() {
+				s.paymentRepo.On("GetReconciliationReport", mock.Anything, day).Return(nil, domain.ErrRecordNotFound)
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+		},
+		{
+			name: "should fail when the database fails",
+			date: "2025-03-14",
+			setupMocks: func() {
+				s.paymentRepo.On("GetReconciliationReport", mock.Anything, day).Return(nil, errors.New("database error"))
+			},
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+		{
+			name: "should return the report of the day",
+			date: "2025-03-14",
+			setupMocks: func() {
+				s.paymentRepo.On("GetReconciliationReport", mock.Anything, day).Return(&domain.ReconciliationReport{
+					Date: day,
+					Totals: domain.ReconciliationTotals{
+						InternalPayments: amount,
+					},
+					Mismatches: []domain.ReconciliationMismatch{
+						{
+							Kind:            domain.MismatchMissingInStripe,
+							Type:            domain.BalanceTransactionCharge,
+							PaymentID:       paymentId,
+							PaymentIntentID: "pi_3",
+							InternalAmount:  &amount,
+						},
+					},
+				}, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: &api.ReconciliationReport{
+				Mismatches: []api.ReconciliationMismatch{
+					{
+						Kind:            api.MissingInStripe,
+						Type:            api.ReconciliationTransactionTypeCharge,
+						PaymentId:       &paymentId,
+						PaymentIntentId: ptr("pi_3"),
+						InternalAmount:  &amount,
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+			tt.setupMocks()
+
+			w, r := executeRequest(s.T(), http.MethodGet, "/admin/reconciliation/"+tt.date, nil)
+			s.app.GetReconciliationReport(w, r, tt.date)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantResponse != nil {
+				var response api.ReconciliationReport
+				s.Require().NoError(json.NewDecoder(w.Body).Decode(&response))
+
+				s.Equal(tt.date, response.Date.Format(time.DateOnly))
+				s.True(amount.Equal(response.Totals.InternalPayments))
+				s.Require().Len(response.Mismatches, len(tt.wantResponse.Mismatches))
+
+				// decimals are compared by value
+				for i, want := range tt.wantResponse.Mismatches {
+					got := response.Mismatches[i]
+					s.Require().NotNil(got.InternalAmount)
+					s.True(want.InternalAmount.Equal(*got.InternalAmount))
+
+					want.InternalAmount, got.InternalAmount = nil, nil
+					s.Equal(want, got)
+				}
+			}
+
+			checkErrorResponse(s.T(), w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+		})
+	}
+}
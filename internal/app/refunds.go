@@ -0,0 +1,228 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/scheduler"
+	"github.com/shopspring/decimal"
+	"github.com/stripe/stripe-go/v82"
+)
+
+const (
+	// pendingRefundBatchSize bounds the pending refunds sent in a single job run
+	pendingRefundBatchSize   = 50
+	maxPendingRefundAttempts = 8
+)
+
+var errRefundWithoutPaymentIntent = errors.New("the payment has no payment intent to refund to")
+
+// handleRefundEvents records the state of refunds reported by Stripe. The payment intent of the event
+// is used for refunds which do not reference one themselves.
+func (app *Application) handleRefundEvents(
+	w http.ResponseWriter,
+	r *http.Request,
+	refunds []*stripe.Refund,
+	paymentIntent *stripe.PaymentIntent) {
+
+	logger := app.contextGetLogger(r)
+
+	if len(refunds) == 0 {
+		logger.Info("refund event without refund details, waiting for refund events")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	for _, stripeRefund := range refunds {
+		intent := paymentIntent
+		if stripeRefund.PaymentIntent != nil {
+			intent = stripeRefund.PaymentIntent
+		}
+
+		if intent == nil || intent.ID == "" {
+			app.badRequestResponse(w, r, fmt.Errorf("refund %s has no payment intent", stripeRefund.ID))
+			return
+		}
+
+		refund := toDomainRefund(stripeRefund)
+
+		applied, err := app.paymentRepo.RecordRefund(r.Context(), intent.ID, refund)
+		if err != nil {
+			switch {
+			case errors.Is(err, domain.ErrRecordNotFound):
+				app.notFoundResponseWithErr(w, r, fmt.Errorf("payment not found for payment intent %s", intent.ID))
+			default:
+				app.serverErrorResponse(w, r, fmt.Errorf("failed to record refund: %w", err))
+			}
+
+			return
+		}
+
+		if !applied {
+			logger.Info("ignored stale refund update", "stripe_refund_id", refund.StripeRefundID, "refund_status", refund.Status)
+			continue
+		}
+
+		logger.Info(
+			"refund recorded",
+			"stripe_refund_id", refund.StripeRefundID,
+			"refund_status", refund.Status,
+			"payment_id", refund.PaymentID,
+		)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// sendPendingRefunds sends the due pending refunds to Stripe. Failed attempts are retried with a growing
+// backoff until the attempts run out, stuck refunds must be settled by hand.
+func (app *Application) sendPendingRefunds(ctx context.Context) error {
+	due, err := app.paymentRepo.DueRefunds(ctx, time.Now(), pendingRefundBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, refund := range due {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err := scheduler.CheckFence(ctx); err != nil {
+			return err
+		}
+
+		err := app.sendPendingRefund(ctx, app.logger, refund)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sendQueuedRefunds makes the first attempt of refunds which were just queued along with a change of their
+// reservation, the failed ones are left to sendPendingRefunds. The change is already made, so errors are
+// only logged.
+func (app *Application) sendQueuedRefunds(ctx context.Context, logger *slog.Logger, refunds []domain.PendingRefund) {
+	for _, refund := range refunds {
+		err := app.sendPendingRefund(ctx, logger, refund)
+		if err != nil {
+			logger.Error("failed to record the attempt of a pending refund", "pending_refund_id", refund.ID, "error", err)
+		}
+	}
+}
+
+// sendPendingRefund makes a single attempt. Only errors of the repository are returned.
+func (app *Application) sendPendingRefund(ctx context.Context, logger *slog.Logger, refund domain.PendingRefund) error {
+	logger = logger.With(
+		"pending_refund_id", refund.ID,
+		"reservation_id", refund.ReservationID,
+		"payment_id", refund.PaymentID,
+		"refund_reason", refund.Reason,
+		"amount", refund.Amount.String(),
+		"attempt", refund.Attempts+1)
+
+	var stripeRefund *stripe.Refund
+
+	err := errRefundWithoutPaymentIntent
+	if refund.PaymentIntentID != "" {
+		stripeRefund, err = app.paymentProvider.RefundPaymentAmount(
+			ctx,
+			refund.PaymentIntentID,
+			refund.Amount,
+			refund.IdempotencyKey())
+	}
+
+	if err != nil {
+		refund.Failed(err, time.Now(), maxPendingRefundAttempts)
+
+		if refund.Stuck() {
+			logger.Error("giving up refunding to the payment of a reservation", "error", err)
+		} else {
+			logger.Warn("failed to refund to the payment of a reservation", "error", err,
+				"next_attempt_at", refund.NextAttemptAt)
+		}
+
+		return app.paymentRepo.RecordRefundFailure(ctx, refund)
+	}
+
+	logger.Info("refunded to the payment of a reservation", "stripe_refund_id", stripeRefund.ID)
+
+	return app.paymentRepo.MarkRefundSent(ctx, refund.ID, stripeRefund.ID, time.Now())
+}
+
+func toDomainRefund(refund *stripe.Refund) *domain.Refund {
+	var failureReason *string
+	if refund.FailureReason != "" {
+		reason := string(refund.FailureReason)
+		failureReason = &reason
+	}
+
+	return &domain.Refund{
+		StripeRefundID: refund.ID,
+		Amount:         decimal.New(refund.Amount, -2),
+		Currency:       strings.ToUpper(string(refund.Currency)),
+		Status:         domain.RefundStatus(refund.Status),
+		FailureReason:  failureReason,
+	}
+}
+
+func (app *Application) GetPaymentsOfUser(w http.ResponseWriter, r *http.Request, params api.GetPaymentsOfUserParams) {
+	err := app.validator.Struct(params)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	userId := app.contextGetUserId(r)
+	pagination := toPagination(params.Page, params.PageSize)
+
+	entries, metadata, err := app.paymentRepo.GetHistoryByUserId(r.Context(), userId, pagination)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	resp := api.UserPaymentsResponse{
+		Payments: make([]api.PaymentHistoryItem, len(entries)),
+		Metadata: *toApiMetadata(r, metadata),
+	}
+
+	for i, entry := range entries {
+		refunds := make([]api.Refund, len(entry.Refunds))
+		for j, refund := range entry.Refunds {
+			refunds[j] = api.Refund{
+				Id:            refund.ID,
+				Amount:        refund.Amount,
+				Currency:      refund.Currency,
+				Status:        api.RefundStatus(refund.Status),
+				FailureReason: refund.FailureReason,
+				CreatedAt:     refund.CreatedAt,
+				UpdatedAt:     refund.UpdatedAt,
+			}
+		}
+
+		resp.Payments[i] = api.PaymentHistoryItem{
+			Id:            entry.ID,
+			ReservationId: entry.ReservationID,
+			Amount:        entry.Amount,
+			Currency:      entry.Currency,
+			Status:        api.PaymentStatus(entry.Status),
+			PaymentDate:   entry.PaymentDate,
+			CreatedAt:     entry.CreatedAt,
+			Refunds:       refunds,
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
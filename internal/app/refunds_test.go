@@ -0,0 +1,393 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/metinatakli/movie-reservation-system/internal/validator"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/stripe/stripe-go/v82"
+	"github.com/stripe/stripe-go/v82/webhook"
+)
+
+const testWebhookSecret = "whsec_test"
+
+type RefundsTestSuite struct {
+	suite.Suite
+	app         *Application
+	paymentRepo *mocks.MockPaymentRepo
+}
+
+func (s *RefundsTestSuite) SetupTest() {
+	s.paymentRepo = new(mocks.MockPaymentRepo)
+	s.app = newTestApplication(func(a *Application) {
+		a.config.Stripe.WebhookSecret = testWebhookSecret
+		a.paymentRepo = s.paymentRepo
+		a.sessionManager = scs.New()
+	})
+}
+
+func TestRefundsSuite(t *testing.T) {
+	suite.Run(t, new(RefundsTestSuite))
+}
+
+// signedWebhookRequest builds a Stripe webhook request carrying the object as event data.
+func signedWebhookRequest(t *testing.T, eventType string, object any) *http.Request {
+	t.Helper()
+
+	raw, err := json.Marshal(object)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := json.Marshal(map[string]any{
+		"id":          "evt_test",
+		"object":      "event",
+		"type":        eventType,
+		"api_version": stripe.APIVersion,
+		"data":        map[string]any{"object": json.RawMessage(raw)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := webhook.GenerateTestSignedPayload(&webhook.UnsignedPayload{
+		Payload: payload,
+		Secret:  testWebhookSecret,
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(payload))
+	r.Header.Set("Stripe-Signature", signed.Header)
+
+	return r
+}
+
+func (s *RefundsTestSuite) TestRefundWebhooks() {
+	refundObject := map[string]any{
+		"id":             "re_1",
+		"object":         "refund",
+		"amount":         1250,
+		"currency":       "usd",
+		"status":         "succeeded",
+		"payment_intent": "pi_1",
+	}
+
+	tests := []struct {
+		name       string
+		eventType  string
+		object     any
+		setupMock  func()
+		wantStatus int
+	}{
+		{
+			name:      "refund update is recorded",
+			eventType: "refund.updated",
+			object:    refundObject,
+			setupMock: func() {
+				s.paymentRepo.On("RecordRefund", mock.Anything, "pi_1", mock.MatchedBy(func(r *domain.Refund) bool {
+					return r.StripeRefundID == "re_1" &&
+						r.Amount.Equal(decimal.RequireFromString("12.50")) &&
+						r.Currency == "USD" &&
+						r.Status == domain.RefundStatusSucceeded &&
+						r.FailureReason == nil
+				})).Return(true, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:      "failed refund keeps the failure reason",
+			eventType: "refund.failed",
+			object: map[string]any{
+				"id":             "re_1",
+				"object":         "refund",
+				"amount":         1250,
+				"currency":       "usd",
+				"status":         "failed",
+				"failure_reason": "expired_or_canceled_card",
+				"payment_intent": "pi_1",
+			},
+			setupMock: func() {
+				s.paymentRepo.On("RecordRefund", mock.Anything, "pi_1", mock.MatchedBy(func(r *domain.Refund) bool {
+					return r.Status == domain.RefundStatusFailed &&
+						r.FailureReason != nil && *r.FailureReason == "expired_or_canceled_card"
+				})).Return(true, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:      "stale refund update is acknowledged",
+			eventType: "refund.updated",
+			object:    refundObject,
+			setupMock: func() {
+				s.paymentRepo.On("RecordRefund", mock.Anything, "pi_1", mock.Anything).Return(false, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:      "charge refunded uses the charge payment intent",
+			eventType: "charge.refunded",
+			object: map[string]any{
+				"id":             "ch_1",
+				"object":         "charge",
+				"payment_intent": "pi_2",
+				"refunds": map[string]any{
+					"object": "list",
+					"data": []any{map[string]any{
+						"id":       "re_2",
+						"object":   "refund",
+						"amount":   500,
+						"currency": "usd",
+						"status":   "pending",
+					}},
+				},
+			},
+			setupMock: func() {
+				s.paymentRepo.On("RecordRefund", mock.Anything, "pi_2", mock.MatchedBy(func(r *domain.Refund) bool {
+					return r.StripeRefundID == "re_2" && r.Status == domain.RefundStatusPending
+				})).Return(true, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:      "charge refunded without embedded refunds",
+			eventType: "charge.refunded",
+			object: map[string]any{
+				"id":             "ch_1",
+				"object":         "charge",
+				"payment_intent": "pi_2",
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:      "refund without payment intent",
+			eventType: "refund.updated",
+			object: map[string]any{
+				"id":       "re_3",
+				"object":   "refund",
+				"amount":   100,
+				"currency": "usd",
+				"status":   "succeeded",
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:      "unknown payment intent",
+			eventType: "refund.updated",
+			object:    refundObject,
+			setupMock: func() {
+				s.paymentRepo.On("RecordRefund", mock.Anything, "pi_1", mock.Anything).Return(false, domain.ErrRecordNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:      "database error",
+			eventType: "refund.updated",
+			object:    refundObject,
+			setupMock: func() {
+				s.paymentRepo.On("RecordRefund", mock.Anything, "pi_1", mock.Anything).Return(false, errors.New("database error"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			if tt.setupMock != nil {
+				tt.setupMock()
+			}
+
+			w := httptest.NewRecorder()
+			r := signedWebhookRequest(s.T(), tt.eventType, tt.object)
+
+			s.app.StripeWebhookHandler(w, r)
+
+			s.Equal(tt.wantStatus, w.Code)
+			s.paymentRepo.AssertExpectations(s.T())
+		})
+	}
+}
+
+func (s *RefundsTestSuite) TestGetPaymentsOfUser() {
+	paymentDate := time.Date(2024, 3, 10, 10, 0, 0, 0, time.UTC)
+	reason := "expired_or_canceled_card"
+
+	entries := []domain.PaymentHistoryEntry{
+		{
+			Payment: domain.Payment{
+				ID:          3,
+				UserID:      1,
+				Amount:      decimal.RequireFromString("25.50"),
+				Currency:    "USD",
+				Status:      domain.PaymentStatusRefunded,
+				PaymentDate: &paymentDate,
+				CreatedAt:   paymentDate,
+			},
+			ReservationID: ptr(9),
+			Refunds: []domain.Refund{
+				{
+					ID:            1,
+					Amount:        decimal.RequireFromString("25.50"),
+					Currency:      "USD",
+					Status:        domain.RefundStatusFailed,
+					FailureReason: &reason,
+					CreatedAt:     paymentDate,
+					UpdatedAt:     paymentDate,
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name           string
+		params         api.GetPaymentsOfUserParams
+		setupMock      func()
+		wantStatus     int
+		wantErrMessage string
+	}{
+		{
+			name:           "invalid page size",
+			params:         api.GetPaymentsOfUserParams{PageSize: ptr(101)},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: fmt.Sprintf(validator.ErrMaxValue, "100"),
+		},
+		{
+			name: "database error",
+			setupMock: func() {
+				s.paymentRepo.On("GetHistoryByUserId", mock.Anything, 1, domain.Pagination{Page: 1, PageSize: 10}).
+					Return(nil, nil, errors.New("database error"))
+			},
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+		{
+			name:   "payments with refunds",
+			params: api.GetPaymentsOfUserParams{Page: ptr(2), PageSize: ptr(1)},
+			setupMock: func() {
+				s.paymentRepo.On("GetHistoryByUserId", mock.Anything, 1, domain.Pagination{Page: 2, PageSize: 1}).
+					Return(entries, domain.NewMetadata(2, 2, 1), nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			if tt.setupMock != nil {
+				tt.setupMock()
+			}
+
+			w, r := executeRequest(s.T(), http.MethodGet, "/users/me/payments", nil)
+			r = setupTestSession(s.T(), s.app, r, 1)
+
+			handler := s.app.requireAuthentication(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				s.app.GetPaymentsOfUser(w, r, tt.params)
+			}))
+			handler = s.app.sessionManager.LoadAndSave(handler)
+			handler.ServeHTTP(w, r)
+
+			s.Equal(tt.wantStatus, w.Code)
+			s.paymentRepo.AssertExpectations(s.T())
+
+			if tt.wantStatus != http.StatusOK {
+				checkErrorResponse(s.T(), w, struct {
+					wantStatus     int
+					wantErrMessage string
+				}{
+					wantStatus:     tt.wantStatus,
+					wantErrMessage: tt.wantErrMessage,
+				})
+				return
+			}
+
+			var resp api.UserPaymentsResponse
+			s.Require().NoError(json.NewDecoder(w.Body).Decode(&resp))
+			s.Require().Len(resp.Payments, 1)
+
+			payment := resp.Payments[0]
+			s.Equal(3, payment.Id)
+			s.Equal(ptr(9), payment.ReservationId)
+			s.Equal(api.PaymentStatus("refunded"), payment.Status)
+			s.Require().Len(payment.Refunds, 1)
+			s.Equal(api.RefundStatus("failed"), payment.Refunds[0].Status)
+			s.Equal(&reason, payment.Refunds[0].FailureReason)
+			s.Equal(2, resp.Metadata.TotalRecords)
+		})
+	}
+}
+
+func TestSendPendingRefunds(t *testing.T) {
+	refunds := []domain.PendingRefund{
+		{ID: 1, ReservationID: 10, PaymentID: 3, PaymentIntentID: "pi_1", Amount: decimal.NewFromInt(5), Reason: "showtime-change"},
+		{ID: 2, ReservationID: 11, PaymentID: 4, PaymentIntentID: "pi_2", Amount: decimal.NewFromInt(8), Reason: "seat-cancellation",
+			Attempts: maxPendingRefundAttempts - 1},
+	}
+
+	t.Run("sent refunds are marked", func(t *testing.T) {
+		repo := new(mocks.MockPaymentRepo)
+		repo.On("DueRefunds", mock.Anything, mock.Anything, pendingRefundBatchSize).Return(refunds, nil).Once()
+		repo.On("MarkRefundSent", mock.Anything, 1, "re_1", mock.Anything).Return(nil).Once()
+		repo.On("MarkRefundSent", mock.Anything, 2, "re_2", mock.Anything).Return(nil).Once()
+
+		provider := new(mocks.MockPaymentProvider)
+		provider.On("RefundPaymentAmount", "pi_1", decimal.NewFromInt(5), "pending-refund-1").
+			Return(&stripe.Refund{ID: "re_1"}, nil).Once()
+		provider.On("RefundPaymentAmount", "pi_2", decimal.NewFromInt(8), "pending-refund-2").
+			Return(&stripe.Refund{ID: "re_2"}, nil).Once()
+
+		app := newTestApplication(func(a *Application) {
+			a.paymentRepo = repo
+			a.paymentProvider = provider
+		})
+
+		if err := app.sendPendingRefunds(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		repo.AssertExpectations(t)
+		provider.AssertExpectations(t)
+	})
+
+	t.Run("failed refunds are retried until they are stuck", func(t *testing.T) {
+		repo := new(mocks.MockPaymentRepo)
+		repo.On("DueRefunds", mock.Anything, mock.Anything, pendingRefundBatchSize).Return(refunds, nil).Once()
+		repo.On("RecordRefundFailure", mock.Anything, mock.MatchedBy(func(r domain.PendingRefund) bool {
+			return r.ID == 1 && r.Attempts == 1 && r.NextAttemptAt != nil && r.LastError == "stripe is down"
+		})).Return(nil).Once()
+		repo.On("RecordRefundFailure", mock.Anything, mock.MatchedBy(func(r domain.PendingRefund) bool {
+			return r.ID == 2 && r.Attempts == maxPendingRefundAttempts && r.Stuck()
+		})).Return(nil).Once()
+
+		provider := new(mocks.MockPaymentProvider)
+		provider.On("RefundPaymentAmount", mock.Anything, mock.Anything, mock.Anything).
+			Return(nil, errors.New("stripe is down")).Twice()
+
+		app := newTestApplication(func(a *Application) {
+			a.paymentRepo = repo
+			a.paymentProvider = provider
+		})
+
+		if err := app.sendPendingRefunds(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		repo.AssertExpectations(t)
+		provider.AssertExpectations(t)
+	})
+}
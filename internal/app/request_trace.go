@@ -0,0 +1,115 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// the trace of a failed request can be looked up for a day, long enough for the user to report it
+const requestTraceTTL = 24 * time.Hour
+
+func requestTraceKey(requestId string) string {
+	return "request_trace:" + requestId
+}
+
+// httpMetrics are the latency and error metrics of the API. They are recorded with the context of the
+// request, so the SDK attaches its sampled trace as an exemplar to the measurement.
+type httpMetrics struct {
+	duration metric.Float64Histogram
+	errors   metric.Int64Counter
+}
+
+func (app *Application) newHTTPMetrics() httpMetrics {
+	meter := otel.Meter("github.com/metinatakli/movie-reservation-system/internal/app")
+
+	var m httpMetrics
+	var err error
+
+	m.duration, err = meter.Float64Histogram(
+		"http.server.request.duration",
+		metric.WithDescription("Duration of the HTTP requests"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		app.logger.Error("failed to create request duration metric", "error", err)
+	}
+
+	m.errors, err = meter.Int64Counter(
+		"http.server.request.errors",
+		metric.WithDescription("Number of HTTP requests which ended with an error response"),
+	)
+	if err != nil {
+		app.logger.Error("failed to create request error metric", "error", err)
+	}
+
+	return m
+}
+
+func (m httpMetrics) record(r *http.Request, status int, duration time.Duration) {
+	attrs := []attribute.KeyValue{
+		attribute.String("http.request.method", r.Method),
+		attribute.Int("http.response.status_code", status),
+	}
+
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+		attrs = append(attrs, attribute.String("http.route", rctx.RoutePattern()))
+	}
+
+	opt := metric.WithAttributes(attrs...)
+
+	if m.duration != nil {
+		m.duration.Record(r.Context(), duration.Seconds(), opt)
+	}
+
+	if m.errors != nil && status >= 400 {
+		m.errors.Add(r.Context(), 1, opt)
+	}
+}
+
+// storeRequestTrace remembers the trace of a request which ended with an error response, as the requestId
+// of the response is all a user can report.
+func (app *Application) storeRequestTrace(ctx context.Context, requestId string) {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if requestId == "" || !spanContext.HasTraceID() {
+		return
+	}
+
+	err := app.redis.Set(ctx, requestTraceKey(requestId), spanContext.TraceID().String(), requestTraceTTL).Err()
+	if err != nil {
+		app.logger.Warn("failed to store request trace", "request_id", requestId, "error", err)
+	}
+}
+
+// GetRequestTrace returns the trace id of a failed request by its requestId.
+func (app *Application) GetRequestTrace(w http.ResponseWriter, r *http.Request, requestId string) {
+	traceId, err := app.redis.Get(r.Context(), requestTraceKey(requestId)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			app.notFoundResponse(w, r)
+			return
+		}
+
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	resp := api.RequestTrace{
+		RequestId: requestId,
+		TraceId:   traceId,
+	}
+
+	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
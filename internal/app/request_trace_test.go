@@ -0,0 +1,125 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type RequestTraceTestSuite struct {
+	suite.Suite
+	app         *Application
+	redisClient *mocks.MockRedisClient
+}
+
+func (s *RequestTraceTestSuite) SetupTest() {
+	s.redisClient = new(mocks.MockRedisClient)
+
+	s.app = newTestApplication(func(a *Application) {
+		a.redis = s.redisClient
+	})
+}
+
+func TestRequestTraceSuite(t *testing.T) {
+	suite.Run(t, new(RequestTraceTestSuite))
+}
+
+func (s *RequestTraceTestSuite) TestStoreRequestTrace() {
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	s.Run("should store the trace of a traced request", func() {
+		s.SetupTest()
+
+		s.redisClient.On("Set", mock.Anything, requestTraceKey("req-1"), "4bf92f3577b34da6a3ce929d0e0e4736", requestTraceTTL).
+			Return(redis.NewStatusResult("OK", nil)).Once()
+
+		s.app.storeRequestTrace(trace.ContextWithSpanContext(context.Background(), spanContext), "req-1")
+
+		s.redisClient.AssertExpectations(s.T())
+	})
+
+	s.Run("should skip an untraced request", func() {
+		s.SetupTest()
+
+		s.app.storeRequestTrace(context.Background(), "req-1")
+
+		s.redisClient.AssertNotCalled(s.T(), "Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func (s *RequestTraceTestSuite) TestGetRequestTrace() {
+	tests := []struct {
+		name           string
+		setupMocks     func()
+		wantStatus     int
+		wantErrMessage string
+		wantResponse   *api.RequestTrace
+	}{
+		{
+			name: "should return not found when the request is unknown",
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, requestTraceKey("req-1")).Return(redis.NewStringResult("", redis.Nil))
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+		},
+		{
+			name: "should fail when redis fails",
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, requestTraceKey("req-1")).Return(redis.NewStringResult("", errors.New("redis error")))
+			},
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+		{
+			name: "should return the trace of the request",
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, requestTraceKey("req-1")).Return(redis.NewStringResult("4bf92f3577b34da6a3ce929d0e0e4736", nil))
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: &api.RequestTrace{
+				RequestId: "req-1",
+				TraceId:   "4bf92f3577b34da6a3ce929d0e0e4736",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+			tt.setupMocks()
+
+			w, r := executeRequest(s.T(), http.MethodGet, "/admin/requests/req-1/trace", nil)
+			s.app.GetRequestTrace(w, r, "req-1")
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantResponse != nil {
+				var response api.RequestTrace
+				s.Require().NoError(json.NewDecoder(w.Body).Decode(&response))
+				s.Equal(*tt.wantResponse, response)
+			}
+
+			checkErrorResponse(s.T(), w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+		})
+	}
+}
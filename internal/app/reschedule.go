@@ -0,0 +1,536 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
+	"github.com/stripe/stripe-go/v82"
+)
+
+var (
+	errTicketNotFlexible        = errors.New("only reservations with a flexible ticket can be moved to another showtime")
+	errChangeWindowClosed       = errors.New("the reservation can no longer be changed, its showtime starts too soon")
+	errReservationNotChangeable = errors.New("the reservation is cancelled or its tickets are revoked")
+	errSameShowtime             = errors.New("the reservation is already booked for this showtime")
+	errOtherScreening           = errors.New("the reservation can only be moved to a showtime of the same movie at the same theater")
+	errSeatCountMismatch        = errors.New("the same number of seats as in the reservation must be selected")
+	errShowtimeChangeExpired    = errors.New("the seats held for the showtime change have expired")
+)
+
+func (app *Application) GetRescheduleOptions(w http.ResponseWriter, r *http.Request, reservationId int) {
+	if reservationId <= 0 {
+		app.badRequestResponse(w, r, fmt.Errorf("reservation id must be greater than zero"))
+		return
+	}
+
+	userId := app.contextGetUserId(r)
+
+	booked, err := app.reservationRepo.GetBookedShowtime(r.Context(), reservationId, userId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	deadline, err := app.checkReschedulable(booked, time.Now())
+	if err != nil {
+		app.rescheduleErrorResponse(w, r, err)
+		return
+	}
+
+	options, err := app.theaterRepo.GetRescheduleOptions(
+		r.Context(),
+		booked.MovieID,
+		booked.TheaterID,
+		booked.ShowtimeID,
+		len(booked.SeatIDs),
+		time.Now())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	resp := api.RescheduleOptionsResponse{
+		ChangeDeadline: deadline,
+		Showtimes:      make([]api.RescheduleOption, len(options)),
+	}
+
+	for i, o := range options {
+		resp.Showtimes[i] = api.RescheduleOption{
+			ShowtimeId:     o.ShowtimeID,
+			StartTime:      o.StartTime.In(domain.TheaterLocation(o.TheaterTimeZone)),
+			Format:         api.ScreeningFormat(o.Format),
+			OpenCaptions:   o.OpenCaptions,
+			HallName:       o.HallName,
+			Price:          o.Price,
+			AvailableSeats: o.AvailableSeats,
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) RescheduleReservation(w http.ResponseWriter, r *http.Request, reservationId int) {
+	if reservationId <= 0 {
+		app.badRequestResponse(w, r, fmt.Errorf("reservation id must be greater than zero"))
+		return
+	}
+
+	var input api.RescheduleReservationRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	userId := app.contextGetUserId(r)
+	logger := app.contextGetLogger(r).With("reservation_id", reservationId, "showtime_id", input.ShowtimeId)
+
+	booked, err := app.reservationRepo.GetBookedShowtime(r.Context(), reservationId, userId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	_, err = app.checkReschedulable(booked, time.Now())
+	if err == nil {
+		err = checkRescheduleInput(booked, input)
+	}
+
+	if err != nil {
+		app.rescheduleErrorResponse(w, r, err)
+		return
+	}
+
+	showtimeSeats, err := app.selectableSeats(r.Context(), input.ShowtimeId, input.SeatIdList)
+	if err != nil {
+		switch {
+		case errors.Is(err, errSeatsAlreadyReserved), errors.Is(err, errSeatsBlocked):
+			logger.Warn("reschedule conflict: an unavailable seat was selected", "requested_seats", input.SeatIdList)
+			app.editConflictResponseWithErr(w, r, err)
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	if showtimeSeats.MovieID != booked.MovieID || showtimeSeats.TheaterID != booked.TheaterID {
+		app.badRequestResponse(w, r, errOtherScreening)
+		return
+	}
+
+	// every ticket pays the price difference of pricier seats. What was paid for a flexible ticket includes
+	// its fee, so its moves to cheaper seats aren't refunded.
+	priceDifference := showtimeSeats.PriceBreakdown().Total.Sub(booked.PaidAmount)
+	if booked.FlexibleTicket && priceDifference.IsNegative() {
+		priceDifference = decimal.Zero
+	}
+
+	// the seats are locked like the seats of a cart, so they can't be sold while the reservation is moved
+	err = app.tryLockSeats(r.Context(), input.SeatIdList, input.ShowtimeId, rescheduleLockOwner(reservationId))
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrSeatAlreadyReserved):
+			logger.Warn("reschedule conflict: an already locked seat was selected")
+			app.editConflictResponseWithErr(w, r, errSeatsAlreadyLocked)
+		default:
+			app.serverErrorResponse(w, r, fmt.Errorf("seats couldn't be acquired: %w", err))
+		}
+
+		return
+	}
+
+	change := domain.ShowtimeChange{
+		ReservationID:   reservationId,
+		UserID:          userId,
+		FromShowtimeID:  booked.ShowtimeID,
+		FromSeatIDs:     booked.SeatIDs,
+		ToShowtimeID:    input.ShowtimeId,
+		MovieName:       showtimeSeats.MovieName,
+		Date:            showtimeSeats.Date,
+		Seats:           showtimeSeats.PriceBreakdown().ReservationSeats(input.ShowtimeId),
+		PriceDifference: priceDifference,
+	}
+
+	for i := range change.Seats {
+		change.Seats[i].ReservationID = reservationId
+	}
+
+	// the seats stay locked until the difference is paid, the reservation is moved by the webhook
+	if priceDifference.IsPositive() {
+		app.startShowtimeChangeCheckout(w, r, change, showtimeSeats.PayoutAccountID)
+		return
+	}
+
+	defer app.rollbackSeatLocks(context.WithoutCancel(r.Context()), input.ShowtimeId, input.SeatIdList)
+
+	refunds, err := app.reservationRepo.Reschedule(r.Context(), change)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, domain.ErrSeatAlreadyReserved):
+			app.editConflictResponseWithErr(w, r, errSeatsAlreadyReserved)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	logger.Info("reservation moved to another showtime", "from_showtime_id", booked.ShowtimeID)
+
+	app.publishSeatEvent(r.Context(), booked.ShowtimeID, seatEventReleased, booked.SeatIDs)
+	app.publishSeatEvent(r.Context(), input.ShowtimeId, seatEventReserved, input.SeatIdList)
+
+	// the difference of a move to cheaper seats was queued with the move
+	app.sendQueuedRefunds(r.Context(), logger, refunds)
+
+	reservationDetail, err := app.reservationRepo.GetByReservationIdAndUserId(r.Context(), reservationId, userId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, toReservationDetailResponse(reservationDetail), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) rescheduleErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, errTicketNotFlexible), errors.Is(err, errSeatsNotCancellable):
+		app.logClientError(r, err.Error())
+		app.errorResponseWithErr(w, r, http.StatusForbidden, err)
+	case errors.Is(err, errChangeWindowClosed), errors.Is(err, errReservationNotChangeable):
+		app.editConflictResponseWithErr(w, r, err)
+	default:
+		app.badRequestResponse(w, r, err)
+	}
+}
+
+// checkReschedulable checks that the reservation can still be moved and returns until when it can be.
+// Flexible tickets can be moved until shortly before the showtime, other tickets until the configured
+// cutoff.
+func (app *Application) checkReschedulable(booked *domain.BookedShowtime, now time.Time) (time.Time, error) {
+	if booked.Status != domain.ReservationConfirmed || booked.TicketsRevokedAt != nil {
+		return time.Time{}, errReservationNotChangeable
+	}
+
+	cutoff := domain.FlexibleTicketChangeCutoff
+	if !booked.FlexibleTicket {
+		if app.config.RescheduleCutoff <= 0 {
+			return time.Time{}, errTicketNotFlexible
+		}
+
+		cutoff = app.config.RescheduleCutoff
+	}
+
+	deadline := booked.StartTime.Add(-cutoff)
+	if now.After(deadline) {
+		return time.Time{}, errChangeWindowClosed
+	}
+
+	return deadline, nil
+}
+
+// checkRescheduleInput checks the rules of a move that don't depend on the new seats.
+func checkRescheduleInput(booked *domain.BookedShowtime, input api.RescheduleReservationRequest) error {
+	if input.ShowtimeId == booked.ShowtimeID {
+		return errSameShowtime
+	}
+
+	if len(input.SeatIdList) != len(booked.SeatIDs) {
+		return errSeatCountMismatch
+	}
+
+	return nil
+}
+
+// startShowtimeChangeCheckout keeps the change while its seats are locked and answers with a checkout
+// session for the price difference. The locks are released if the checkout can't be started.
+func (app *Application) startShowtimeChangeCheckout(
+	w http.ResponseWriter,
+	r *http.Request,
+	change domain.ShowtimeChange,
+	payoutAccountID string) {
+
+	ctx := r.Context()
+	seatIDs := change.SeatIDs()
+
+	user, err := app.userRepo.GetById(ctx, change.UserID)
+	if err != nil {
+		app.rollbackSeatLocks(context.WithoutCancel(ctx), change.ToShowtimeID, seatIDs)
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	payment := &domain.Payment{
+		UserID:        change.UserID,
+		Amount:        change.PriceDifference,
+		Currency:      domain.DefaultCurrency,
+		Status:        domain.PaymentStatusPending,
+		Payout:        app.payoutSplit(ctx, payoutAccountID, change.PriceDifference),
+		ReservationID: &change.ReservationID,
+	}
+
+	err = app.paymentRepo.Create(ctx, payment)
+	if err == nil {
+		err = app.saveShowtimeChange(ctx, payment.ID, change)
+	}
+
+	var expiresAt time.Time
+	if err == nil {
+		// the checkout session can't outlive the seat locks of the change
+		expiresAt, err = app.holdForCheckout(ctx, change.ToShowtimeID, seatIDs, time.Now(), showtimeChangeKey(payment.ID))
+	}
+
+	if err != nil {
+		app.rollbackSeatLocks(context.WithoutCancel(ctx), change.ToShowtimeID, seatIDs)
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	checkoutSession, err := app.paymentProvider.CreateShowtimeChangeCheckoutSession(ctx, user, change, *payment, expiresAt)
+	if err != nil {
+		app.rollbackSeatLocks(context.WithoutCancel(ctx), change.ToShowtimeID, seatIDs)
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.publishSeatEvent(ctx, change.ToShowtimeID, seatEventLocked, seatIDs)
+
+	app.contextGetLogger(r).Info("showtime change waits for the price difference to be paid",
+		"payment_id", payment.ID, "amount", change.PriceDifference.String())
+
+	err = app.writeJSON(w, http.StatusAccepted, api.CheckoutSessionResponse{RedirectUrl: checkoutSession.URL}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// handleShowtimeChangeCompleted moves the reservation once the price difference is paid. A change whose
+// seats can't be taken anymore is refunded instead.
+func (app *Application) handleShowtimeChangeCompleted(
+	w http.ResponseWriter,
+	r *http.Request,
+	checkoutSession stripe.CheckoutSession) {
+
+	ctx := r.Context()
+
+	paymentId, err := strconv.Atoi(checkoutSession.Metadata[domain.CheckoutMetadataPaymentID])
+	if err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("%w: payment_id is missing or not in the expected format", errInvalidCheckoutMetadata))
+		return
+	}
+
+	payment, err := app.paymentRepo.GetById(ctx, paymentId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponseWithErr(w, r, fmt.Errorf("payment not found: %w", err))
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	if payment.Status == domain.PaymentStatusCompleted {
+		app.contextGetLogger(r).Info("idempotent request: payment already completed")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if payment.Status != domain.PaymentStatusPending {
+		app.editConflictResponseWithErr(w, r, fmt.Errorf("%w: %s", errPaymentNotPending, payment.Status))
+		return
+	}
+
+	if payment.ReservationID == nil {
+		app.badRequestResponse(w, r, fmt.Errorf("%w: payment %d isn't made for a reservation", errInvalidCheckoutMetadata, payment.ID))
+		return
+	}
+
+	logger := app.contextGetLogger(r).With("payment_id", payment.ID, "reservation_id", *payment.ReservationID)
+
+	var paymentIntentId string
+	if checkoutSession.PaymentIntent != nil {
+		paymentIntentId = checkoutSession.PaymentIntent.ID
+	}
+
+	change, err := app.getShowtimeChange(ctx, payment.ID)
+	if err == nil {
+		err = app.verifySeatLocks(ctx, change.ToShowtimeID, change.SeatIDs(), rescheduleLockOwner(change.ReservationID))
+	}
+
+	if err == nil {
+		// a paid difference is never negative, nothing is refunded with the move
+		_, err = app.reservationRepo.Reschedule(ctx, *change)
+	}
+
+	if err != nil {
+		if !isLostShowtimeChange(err) {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		refundErr := app.refundShowtimeChange(ctx, logger, payment.ID, checkoutSession.ID, paymentIntentId, change, err)
+		if refundErr != nil {
+			app.serverErrorResponse(w, r, refundErr)
+			return
+		}
+
+		app.editConflictResponseWithErr(w, r, err)
+		return
+	}
+
+	logger.Info("price difference paid, reservation moved to another showtime", "showtime_id", change.ToShowtimeID)
+
+	// the reservation is moved, failing the webhook now would refund a fulfilled change on redelivery
+	err = app.paymentRepo.MarkCompleted(ctx, payment.ID, checkoutSession.ID, paymentIntentId)
+	if err != nil {
+		logger.Error("reservation moved but its payment couldn't be completed", "error", err)
+	}
+
+	err = app.redis.Del(ctx, showtimeChangeKey(payment.ID)).Err()
+	if err != nil {
+		logger.Error("failed to delete showtime change from redis", "error", err)
+	}
+
+	app.rollbackSeatLocks(ctx, change.ToShowtimeID, change.SeatIDs())
+
+	app.publishSeatEvent(ctx, change.FromShowtimeID, seatEventReleased, change.FromSeatIDs)
+	app.publishSeatEvent(ctx, change.ToShowtimeID, seatEventReserved, change.SeatIDs())
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// isLostShowtimeChange reports whether the change can't be made anymore, retrying can't help then.
+func isLostShowtimeChange(err error) bool {
+	return errors.Is(err, errShowtimeChangeExpired) ||
+		errors.Is(err, domain.ErrSeatLockExpired) ||
+		errors.Is(err, domain.ErrSeatConflict) ||
+		errors.Is(err, domain.ErrSeatAlreadyReserved) ||
+		errors.Is(err, domain.ErrEditConflict)
+}
+
+// refundShowtimeChange refunds a paid price difference whose change couldn't be made. The payment is
+// completed without a change, so redelivered webhooks are not handled again. The seats the change still
+// holds are released.
+func (app *Application) refundShowtimeChange(
+	ctx context.Context,
+	logger *slog.Logger,
+	paymentId int,
+	checkoutSessionId,
+	paymentIntentId string,
+	change *domain.ShowtimeChange,
+	cause error) error {
+
+	if paymentIntentId == "" {
+		return fmt.Errorf("payment %d can't be refunded without a payment intent", paymentId)
+	}
+
+	refund, err := app.paymentProvider.RefundPayment(ctx, paymentIntentId, fmt.Sprintf("showtime-change-%d", paymentId))
+	if err != nil {
+		return fmt.Errorf("failed to refund the price difference of a lost showtime change: %w", err)
+	}
+
+	err = app.paymentRepo.MarkUnfulfilled(ctx, paymentId, checkoutSessionId, paymentIntentId, cause.Error())
+	if err != nil {
+		return fmt.Errorf("payment refunded but couldn't be marked as unfulfilled: %w", err)
+	}
+
+	logger.Warn("showtime change couldn't be made, price difference refunded", "reason", cause, "stripe_refund_id", refund.ID)
+
+	if change != nil {
+		app.releaseShowtimeChange(ctx, logger, paymentId, change)
+	}
+
+	return nil
+}
+
+// saveShowtimeChange keeps the change as long as its seat locks.
+func (app *Application) saveShowtimeChange(ctx context.Context, paymentId int, change domain.ShowtimeChange) error {
+	changeBytes, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+
+	seatIDs := change.SeatIDs()
+	seatIdInterfaces := make([]interface{}, len(seatIDs))
+	for i, seatID := range seatIDs {
+		seatIdInterfaces[i] = seatID
+	}
+
+	pipe := app.redis.TxPipeline()
+	pipe.SAdd(ctx, seatSetKey(change.ToShowtimeID), seatIdInterfaces...)
+	pipe.Set(ctx, showtimeChangeKey(paymentId), changeBytes, seatLockTTL)
+
+	_, err = pipe.Exec(ctx)
+
+	return err
+}
+
+func (app *Application) getShowtimeChange(ctx context.Context, paymentId int) (*domain.ShowtimeChange, error) {
+	changeBytes, err := app.redis.Get(ctx, showtimeChangeKey(paymentId)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, errShowtimeChangeExpired
+		}
+
+		return nil, err
+	}
+
+	var change domain.ShowtimeChange
+
+	err = json.Unmarshal(changeBytes, &change)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal showtime change of payment %d: %w", paymentId, err)
+	}
+
+	return &change, nil
+}
+
+func rescheduleLockOwner(reservationId int) string {
+	return fmt.Sprintf("reschedule:%d", reservationId)
+}
+
+func showtimeChangeKey(paymentId int) string {
+	return fmt.Sprintf("showtime_change:%d", paymentId)
+}
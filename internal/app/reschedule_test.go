@@ -0,0 +1,720 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/metinatakli/movie-reservation-system/internal/validator"
+	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/stripe/stripe-go/v82"
+)
+
+type RescheduleTestSuite struct {
+	suite.Suite
+	app             *Application
+	seatRepo        *mocks.MockSeatRepo
+	reservationRepo *mocks.MockReservationRepo
+	paymentRepo     *mocks.MockPaymentRepo
+	theaterRepo     *mocks.MockTheaterRepo
+	paymentProvider *mocks.MockPaymentProvider
+	redisClient     *mocks.MockRedisClient
+	redisPipeline   *mocks.MockTxPipeline
+}
+
+func (s *RescheduleTestSuite) SetupTest() {
+	s.seatRepo = new(mocks.MockSeatRepo)
+	s.reservationRepo = new(mocks.MockReservationRepo)
+	s.paymentRepo = new(mocks.MockPaymentRepo)
+	s.theaterRepo = &mocks.MockTheaterRepo{}
+	s.paymentProvider = new(mocks.MockPaymentProvider)
+	s.redisClient = new(mocks.MockRedisClient)
+	s.redisPipeline = new(mocks.MockTxPipeline)
+
+	s.app = newTestApplication(func(a *Application) {
+		a.seatRepo = s.seatRepo
+		a.reservationRepo = s.reservationRepo
+		a.paymentRepo = s.paymentRepo
+		a.theaterRepo = s.theaterRepo
+		a.paymentProvider = s.paymentProvider
+		a.redis = s.redisClient
+		a.userRepo = &mocks.MockUserRepo{
+			GetByIdFunc: func(ctx context.Context, id int) (*domain.User, error) {
+				return &domain.User{ID: id, Email: "user@example.com"}, nil
+			},
+		}
+	})
+}
+
+func TestRescheduleSuite(t *testing.T) {
+	suite.Run(t, new(RescheduleTestSuite))
+}
+
+// rescheduled matches a change of the reservation between the showtimes, which also satisfies match if
+// it's given.
+func rescheduled(reservationId, fromShowtimeId, toShowtimeId int, match func(domain.ShowtimeChange) bool) interface{} {
+	return mock.MatchedBy(func(change domain.ShowtimeChange) bool {
+		return change.ReservationID == reservationId &&
+			change.FromShowtimeID == fromShowtimeId &&
+			change.ToShowtimeID == toShowtimeId &&
+			(match == nil || match(change))
+	})
+}
+
+func (s *RescheduleTestSuite) TestRescheduleReservation() {
+	const (
+		userId        = 1
+		reservationId = 10
+		fromShowtime  = 1
+		toShowtime    = 2
+	)
+
+	bookedShowtime := func(modify func(*domain.BookedShowtime)) *domain.BookedShowtime {
+		booked := &domain.BookedShowtime{
+			ReservationID:  reservationId,
+			Status:         domain.ReservationConfirmed,
+			FlexibleTicket: true,
+			ShowtimeID:     fromShowtime,
+			MovieID:        3,
+			TheaterID:      4,
+			StartTime:      time.Now().Add(3 * time.Hour),
+			SeatIDs:        []int{7, 8},
+			// the seats cost 25 and the flexible ticket 1.50 per seat
+			PaidAmount: decimal.RequireFromString("28"),
+		}
+
+		if modify != nil {
+			modify(booked)
+		}
+
+		return booked
+	}
+
+	validInput := api.RescheduleReservationRequest{ShowtimeId: toShowtime, SeatIdList: []int{1, 2}}
+	lockKeys := []string{seatLockKey(toShowtime, 1), seatLockKey(toShowtime, 2)}
+	lockOwner := rescheduleLockOwner(reservationId)
+
+	// standard seats of the new showtime cost 10, the first seat 5 more
+	expectSelectableSeats := func(movieId, theaterId int) {
+		s.reservationRepo.On("GetSeatsByShowtimeId", mock.Anything, toShowtime).Return([]domain.ReservationSeat{}, nil).Once()
+		s.seatRepo.On("GetSeatBlocksByShowtime", mock.Anything, toShowtime).Return([]domain.SeatBlock{}, nil).Once()
+		s.seatRepo.On("GetSeatsByShowtimeAndSeatIds", mock.Anything, toShowtime, []int{1, 2}).
+			Return(&domain.ShowtimeSeats{
+				MovieID:   movieId,
+				TheaterID: theaterId,
+				Price:     10,
+				Seats:     []domain.Seat{{ID: 1, ExtraPrice: 5}, {ID: 2, ExtraPrice: 0}},
+			}, nil).Once()
+	}
+
+	standardTicket := func(paid string) func(*domain.BookedShowtime) {
+		return func(b *domain.BookedShowtime) {
+			b.FlexibleTicket = false
+			b.StartTime = time.Now().Add(48 * time.Hour)
+			b.PaidAmount = decimal.RequireFromString(paid)
+		}
+	}
+
+	cheaperMoveRefund := domain.PendingRefund{
+		ID:              12,
+		ReservationID:   reservationId,
+		PaymentID:       3,
+		PaymentIntentID: "pi_123",
+		Amount:          decimal.NewFromInt(5),
+		Reason:          "showtime-change",
+	}
+
+	// the difference of 5 is paid by a checkout, the new seats stay held until then
+	expectChangeCheckout := func() {
+		s.paymentRepo.On("Create", mock.Anything, mock.MatchedBy(func(p *domain.Payment) bool {
+			return p.Amount.Equal(decimal.NewFromInt(5)) && p.Status == domain.PaymentStatusPending &&
+				p.ReservationID != nil && *p.ReservationID == reservationId
+		})).Run(func(args mock.Arguments) {
+			args.Get(1).(*domain.Payment).ID = 77
+		}).Return(nil).Once()
+		s.redisClient.On("TxPipeline").Return(s.redisPipeline).Twice()
+		s.redisPipeline.On("SAdd", mock.Anything, seatSetKey(toShowtime), []interface{}{1, 2}).Return(redis.NewIntResult(2, nil)).Once()
+		s.redisPipeline.On("Set", mock.Anything, showtimeChangeKey(77), mock.Anything, seatLockTTL).Return(redis.NewStatusResult("OK", nil)).Once()
+		s.redisPipeline.On("Exec", mock.Anything).Return([]redis.Cmder{}, nil).Twice()
+		s.redisClient.On("PTTL", mock.Anything, lockKeys[0]).Return(redis.NewDurationResult(seatLockTTL, nil)).Once()
+		for _, key := range append(lockKeys, showtimeChangeKey(77)) {
+			s.redisPipeline.On("ExpireAt", mock.Anything, key, mock.Anything).Return(redis.NewBoolResult(true, nil)).Once()
+		}
+		s.paymentProvider.On("CreateShowtimeChangeCheckoutSession", mock.Anything,
+			mock.MatchedBy(func(c domain.ShowtimeChange) bool {
+				return c.ReservationID == reservationId && c.FromShowtimeID == fromShowtime &&
+					c.ToShowtimeID == toShowtime && len(c.Seats) == 2
+			}), mock.Anything).Return(&stripe.CheckoutSession{URL: "https://checkout.stripe.com/pay"}, nil).Once()
+		s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatMapChangeKeys(toShowtime), seatEventsChannel(toShowtime), mock.Anything, mock.Anything).
+			Return(redis.NewCmdResult(int64(1), nil)).Once()
+	}
+
+	expectSeatEvents := func() {
+		s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatMapChangeKeys(fromShowtime), seatEventsChannel(fromShowtime), mock.Anything, mock.Anything).
+			Return(redis.NewCmdResult(int64(1), nil)).Once()
+		s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatMapChangeKeys(toShowtime), seatEventsChannel(toShowtime), mock.Anything, mock.Anything).
+			Return(redis.NewCmdResult(int64(1), nil)).Once()
+	}
+
+	expectLocksReleased := func() {
+		s.redisClient.On("TxPipeline").Return(s.redisPipeline).Once()
+		s.redisPipeline.On("Del", mock.Anything, lockKeys).Return(redis.NewIntResult(2, nil)).Once()
+		s.redisPipeline.On("SRem", mock.Anything, seatSetKey(toShowtime), []interface{}{1, 2}).Return(redis.NewIntResult(0, nil)).Once()
+		s.redisPipeline.On("Exec", mock.Anything).Return([]redis.Cmder{}, nil).Once()
+	}
+
+	tests := []struct {
+		name             string
+		reservationId    int
+		input            any
+		rescheduleCutoff time.Duration
+		setupMocks       func()
+		wantStatus       int
+		wantErrMessage   string
+		wantErrCode      api.ErrorCode
+		wantFlexible     bool
+	}{
+		{
+			name:           "should fail when reservation ID is zero or negative",
+			reservationId:  0,
+			input:          validInput,
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "reservation id must be greater than zero",
+		},
+		{
+			name:           "should fail when a seat is selected twice",
+			reservationId:  reservationId,
+			input:          api.RescheduleReservationRequest{ShowtimeId: toShowtime, SeatIdList: []int{1, 1}},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: validator.ErrUnique,
+		},
+		{
+			name:          "should fail when the reservation doesn't belong to the user",
+			reservationId: reservationId,
+			input:         validInput,
+			setupMocks: func() {
+				s.reservationRepo.On("GetBookedShowtime", mock.Anything, reservationId, userId).
+					Return(nil, domain.ErrRecordNotFound).Once()
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+		},
+		{
+			name:          "should fail when the ticket isn't flexible",
+			reservationId: reservationId,
+			input:         validInput,
+			setupMocks: func() {
+				s.reservationRepo.On("GetBookedShowtime", mock.Anything, reservationId, userId).
+					Return(bookedShowtime(func(b *domain.BookedShowtime) { b.FlexibleTicket = false }), nil).Once()
+			},
+			wantStatus:     http.StatusForbidden,
+			wantErrMessage: errTicketNotFlexible.Error(),
+			wantErrCode:    api.TICKETNOTFLEXIBLE,
+		},
+		{
+			name:             "should fail when a standard ticket is moved within the reschedule cutoff",
+			reservationId:    reservationId,
+			input:            validInput,
+			rescheduleCutoff: 24 * time.Hour,
+			setupMocks: func() {
+				s.reservationRepo.On("GetBookedShowtime", mock.Anything, reservationId, userId).
+					Return(bookedShowtime(func(b *domain.BookedShowtime) {
+						standardTicket("25")(b)
+						b.StartTime = time.Now().Add(23 * time.Hour)
+					}), nil).Once()
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: errChangeWindowClosed.Error(),
+			wantErrCode:    api.CHANGEWINDOWCLOSED,
+		},
+		{
+			name:          "should fail when the reservation is cancelled",
+			reservationId: reservationId,
+			input:         validInput,
+			setupMocks: func() {
+				s.reservationRepo.On("GetBookedShowtime", mock.Anything, reservationId, userId).
+					Return(bookedShowtime(func(b *domain.BookedShowtime) { b.Status = domain.ReservationCancelled }), nil).Once()
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: errReservationNotChangeable.Error(),
+		},
+		{
+			name:          "should fail when the showtime starts within the cutoff",
+			reservationId: reservationId,
+			input:         validInput,
+			setupMocks: func() {
+				s.reservationRepo.On("GetBookedShowtime", mock.Anything, reservationId, userId).
+					Return(bookedShowtime(func(b *domain.BookedShowtime) { b.StartTime = time.Now().Add(59 * time.Minute) }), nil).Once()
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: errChangeWindowClosed.Error(),
+			wantErrCode:    api.CHANGEWINDOWCLOSED,
+		},
+		{
+			name:          "should fail when the number of seats differs",
+			reservationId: reservationId,
+			input:         api.RescheduleReservationRequest{ShowtimeId: toShowtime, SeatIdList: []int{1}},
+			setupMocks: func() {
+				s.reservationRepo.On("GetBookedShowtime", mock.Anything, reservationId, userId).Return(bookedShowtime(nil), nil).Once()
+			},
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: errSeatCountMismatch.Error(),
+		},
+		{
+			name:          "should fail when the showtime is of another movie",
+			reservationId: reservationId,
+			input:         validInput,
+			setupMocks: func() {
+				s.reservationRepo.On("GetBookedShowtime", mock.Anything, reservationId, userId).Return(bookedShowtime(nil), nil).Once()
+				expectSelectableSeats(99, 4)
+			},
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: errOtherScreening.Error(),
+		},
+		{
+			name:          "should fail when a seat is locked by someone else",
+			reservationId: reservationId,
+			input:         validInput,
+			setupMocks: func() {
+				s.reservationRepo.On("GetBookedShowtime", mock.Anything, reservationId, userId).Return(bookedShowtime(nil), nil).Once()
+				expectSelectableSeats(3, 4)
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, lockKeys, lockOwner, mock.Anything).
+					Return(redis.NewCmdResult(nil, mocks.MockRedisError{Msg: "seat already locked"})).Once()
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: errSeatsAlreadyLocked.Error(),
+			wantErrCode:    api.SEATALREADYLOCKED,
+		},
+		{
+			name:          "should fail when a seat is sold while the reservation is moved",
+			reservationId: reservationId,
+			input:         validInput,
+			setupMocks: func() {
+				s.reservationRepo.On("GetBookedShowtime", mock.Anything, reservationId, userId).Return(bookedShowtime(nil), nil).Once()
+				expectSelectableSeats(3, 4)
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, lockKeys, lockOwner, mock.Anything).
+					Return(redis.NewCmdResult("OK", nil)).Once()
+				s.reservationRepo.On("Reschedule", mock.Anything, rescheduled(reservationId, fromShowtime, toShowtime, nil)).
+					Return(nil, domain.ErrSeatAlreadyReserved).Once()
+				expectLocksReleased()
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: errSeatsAlreadyReserved.Error(),
+			wantErrCode:    api.SEATALREADYRESERVED,
+		},
+		{
+			name:          "should move the reservation to the new seats",
+			reservationId: reservationId,
+			input:         validInput,
+			setupMocks: func() {
+				s.reservationRepo.On("GetBookedShowtime", mock.Anything, reservationId, userId).Return(bookedShowtime(nil), nil).Once()
+				expectSelectableSeats(3, 4)
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, lockKeys, lockOwner, int(seatLockTTL.Seconds())).
+					Return(redis.NewCmdResult("OK", nil)).Once()
+				s.reservationRepo.On("Reschedule", mock.Anything,
+					rescheduled(reservationId, fromShowtime, toShowtime, func(change domain.ShowtimeChange) bool {
+						seats := change.Seats
+						return slices.Equal(change.FromSeatIDs, []int{7, 8}) && change.PriceDifference.IsZero() &&
+							len(seats) == 2 && seats[0].SeatID == 1 && seats[0].ShowtimeID == toShowtime &&
+							seats[0].ReservationID == reservationId &&
+							seats[0].ExtraPrice.String() == "5" && seats[0].BasePrice.String() == "10" &&
+							seats[0].Price.String() == "15" && seats[1].SeatID == 2 && seats[1].Price.String() == "10"
+					})).Return(nil, nil).Once()
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatMapChangeKeys(fromShowtime), seatEventsChannel(fromShowtime), mock.Anything, mock.Anything).
+					Return(redis.NewCmdResult(int64(1), nil)).Once()
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatMapChangeKeys(toShowtime), seatEventsChannel(toShowtime), mock.Anything, mock.Anything).
+					Return(redis.NewCmdResult(int64(1), nil)).Once()
+				s.reservationRepo.On("GetByReservationIdAndUserId", mock.Anything, reservationId, userId).
+					Return(&domain.ReservationDetail{
+						ReservationSummary: domain.ReservationSummary{ReservationID: reservationId},
+						FlexibleTicket:     true,
+					}, nil).Once()
+				expectLocksReleased()
+			},
+			wantStatus:   http.StatusOK,
+			wantFlexible: true,
+		},
+		{
+			name:             "should refund the difference when a standard ticket is moved to cheaper seats",
+			reservationId:    reservationId,
+			input:            validInput,
+			rescheduleCutoff: 24 * time.Hour,
+			setupMocks: func() {
+				s.reservationRepo.On("GetBookedShowtime", mock.Anything, reservationId, userId).
+					Return(bookedShowtime(standardTicket("30")), nil).Once()
+				expectSelectableSeats(3, 4)
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, lockKeys, lockOwner, mock.Anything).
+					Return(redis.NewCmdResult("OK", nil)).Once()
+				s.reservationRepo.On("Reschedule", mock.Anything,
+					rescheduled(reservationId, fromShowtime, toShowtime, func(change domain.ShowtimeChange) bool {
+						return change.PriceDifference.Equal(decimal.NewFromInt(-5))
+					})).Return([]domain.PendingRefund{cheaperMoveRefund}, nil).Once()
+				expectSeatEvents()
+				s.paymentProvider.On("RefundPaymentAmount", "pi_123",
+					mock.MatchedBy(func(amount decimal.Decimal) bool { return amount.Equal(decimal.NewFromInt(5)) }),
+					"pending-refund-12").Return(&stripe.Refund{ID: "re_123"}, nil).Once()
+				s.paymentRepo.On("MarkRefundSent", mock.Anything, 12, "re_123", mock.Anything).Return(nil).Once()
+				s.reservationRepo.On("GetByReservationIdAndUserId", mock.Anything, reservationId, userId).
+					Return(&domain.ReservationDetail{
+						ReservationSummary: domain.ReservationSummary{ReservationID: reservationId},
+					}, nil).Once()
+				expectLocksReleased()
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:             "should keep the refund for a retry when it fails",
+			reservationId:    reservationId,
+			input:            validInput,
+			rescheduleCutoff: 24 * time.Hour,
+			setupMocks: func() {
+				s.reservationRepo.On("GetBookedShowtime", mock.Anything, reservationId, userId).
+					Return(bookedShowtime(standardTicket("30")), nil).Once()
+				expectSelectableSeats(3, 4)
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, lockKeys, lockOwner, mock.Anything).
+					Return(redis.NewCmdResult("OK", nil)).Once()
+				s.reservationRepo.On("Reschedule", mock.Anything, rescheduled(reservationId, fromShowtime, toShowtime, nil)).
+					Return([]domain.PendingRefund{cheaperMoveRefund}, nil).Once()
+				expectSeatEvents()
+				s.paymentProvider.On("RefundPaymentAmount", "pi_123", mock.Anything, "pending-refund-12").
+					Return(nil, fmt.Errorf("stripe is unavailable")).Once()
+				s.paymentRepo.On("RecordRefundFailure", mock.Anything, mock.MatchedBy(func(refund domain.PendingRefund) bool {
+					return refund.ID == 12 && refund.Attempts == 1 && refund.NextAttemptAt != nil &&
+						refund.LastError == "stripe is unavailable"
+				})).Return(nil).Once()
+				s.reservationRepo.On("GetByReservationIdAndUserId", mock.Anything, reservationId, userId).
+					Return(&domain.ReservationDetail{
+						ReservationSummary: domain.ReservationSummary{ReservationID: reservationId},
+					}, nil).Once()
+				expectLocksReleased()
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:          "should fail when the reservation changed since it was read",
+			reservationId: reservationId,
+			input:         validInput,
+			setupMocks: func() {
+				s.reservationRepo.On("GetBookedShowtime", mock.Anything, reservationId, userId).Return(bookedShowtime(nil), nil).Once()
+				expectSelectableSeats(3, 4)
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, lockKeys, lockOwner, mock.Anything).
+					Return(redis.NewCmdResult("OK", nil)).Once()
+				s.reservationRepo.On("Reschedule", mock.Anything, rescheduled(reservationId, fromShowtime, toShowtime, nil)).
+					Return(nil, domain.ErrEditConflict).Once()
+				expectLocksReleased()
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: ErrEditConflict,
+		},
+		{
+			name:             "should move a standard ticket to seats of the same price without a payment",
+			reservationId:    reservationId,
+			input:            validInput,
+			rescheduleCutoff: 24 * time.Hour,
+			setupMocks: func() {
+				s.reservationRepo.On("GetBookedShowtime", mock.Anything, reservationId, userId).
+					Return(bookedShowtime(standardTicket("25")), nil).Once()
+				expectSelectableSeats(3, 4)
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, lockKeys, lockOwner, mock.Anything).
+					Return(redis.NewCmdResult("OK", nil)).Once()
+				s.reservationRepo.On("Reschedule", mock.Anything, rescheduled(reservationId, fromShowtime, toShowtime, nil)).
+					Return(nil, nil).Once()
+				expectSeatEvents()
+				s.reservationRepo.On("GetByReservationIdAndUserId", mock.Anything, reservationId, userId).
+					Return(&domain.ReservationDetail{
+						ReservationSummary: domain.ReservationSummary{ReservationID: reservationId},
+					}, nil).Once()
+				expectLocksReleased()
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:             "should hold the seats and start a checkout when a standard ticket is moved to pricier seats",
+			reservationId:    reservationId,
+			input:            validInput,
+			rescheduleCutoff: 24 * time.Hour,
+			setupMocks: func() {
+				s.reservationRepo.On("GetBookedShowtime", mock.Anything, reservationId, userId).
+					Return(bookedShowtime(standardTicket("20")), nil).Once()
+				expectSelectableSeats(3, 4)
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, lockKeys, lockOwner, mock.Anything).
+					Return(redis.NewCmdResult("OK", nil)).Once()
+				expectChangeCheckout()
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:          "should start a checkout when a flexible ticket is moved to pricier seats",
+			reservationId: reservationId,
+			input:         validInput,
+			setupMocks: func() {
+				s.reservationRepo.On("GetBookedShowtime", mock.Anything, reservationId, userId).
+					Return(bookedShowtime(func(b *domain.BookedShowtime) { b.PaidAmount = decimal.NewFromInt(20) }), nil).Once()
+				expectSelectableSeats(3, 4)
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, lockKeys, lockOwner, mock.Anything).
+					Return(redis.NewCmdResult("OK", nil)).Once()
+				expectChangeCheckout()
+			},
+			wantStatus: http.StatusAccepted,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+			s.app.config.RescheduleCutoff = tt.rescheduleCutoff
+
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			w, r := executeRequest(s.T(), http.MethodPost, fmt.Sprintf("/users/me/reservations/%d/reschedule", tt.reservationId), tt.input)
+			r = r.WithContext(context.WithValue(r.Context(), SessionKeyUserId, userId))
+
+			s.app.RescheduleReservation(w, r, tt.reservationId)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantErrCode != "" {
+				checkErrorCode(s.T(), w, tt.wantErrCode)
+			}
+
+			if tt.wantStatus == http.StatusOK {
+				var response api.ReservationDetailResponse
+				s.Require().NoError(json.NewDecoder(w.Body).Decode(&response))
+
+				s.Equal(reservationId, response.Id)
+				s.Equal(tt.wantFlexible, response.FlexibleTicket)
+			}
+
+			if tt.wantStatus == http.StatusAccepted {
+				var response api.CheckoutSessionResponse
+				s.Require().NoError(json.NewDecoder(w.Body).Decode(&response))
+
+				s.Equal("https://checkout.stripe.com/pay", response.RedirectUrl)
+			}
+
+			checkErrorResponse(s.T(), w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{tt.wantStatus, tt.wantErrMessage})
+
+			s.reservationRepo.AssertExpectations(s.T())
+			s.seatRepo.AssertExpectations(s.T())
+			s.paymentRepo.AssertExpectations(s.T())
+			s.paymentProvider.AssertExpectations(s.T())
+			s.redisClient.AssertExpectations(s.T())
+			s.redisPipeline.AssertExpectations(s.T())
+		})
+	}
+}
+
+func (s *RescheduleTestSuite) TestGetRescheduleOptions() {
+	const (
+		userId        = 1
+		reservationId = 10
+	)
+
+	startTime := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+
+	booked := &domain.BookedShowtime{
+		ReservationID: reservationId,
+		Status:        domain.ReservationConfirmed,
+		ShowtimeID:    1,
+		MovieID:       3,
+		TheaterID:     4,
+		StartTime:     startTime,
+		SeatIDs:       []int{7, 8},
+	}
+
+	s.app.config.RescheduleCutoff = 24 * time.Hour
+
+	s.reservationRepo.On("GetBookedShowtime", mock.Anything, reservationId, userId).Return(booked, nil).Once()
+	s.theaterRepo.GetRescheduleOptionsFunc = func(
+		ctx context.Context,
+		movieID, theaterID, exceptShowtimeID, minSeats int,
+		after time.Time) ([]domain.RescheduleOption, error) {
+
+		s.Equal([]int{3, 4, 1, 2}, []int{movieID, theaterID, exceptShowtimeID, minSeats})
+
+		return []domain.RescheduleOption{{
+			ShowtimeID:     2,
+			StartTime:      startTime.Add(3 * time.Hour),
+			Format:         domain.Format2D,
+			HallName:       "Hall 1",
+			Price:          decimal.NewFromInt(12),
+			AvailableSeats: 40,
+		}}, nil
+	}
+
+	w, r := executeRequest(s.T(), http.MethodGet, fmt.Sprintf("/users/me/reservations/%d/reschedule-options", reservationId), nil)
+	r = r.WithContext(context.WithValue(r.Context(), SessionKeyUserId, userId))
+
+	s.app.GetRescheduleOptions(w, r, reservationId)
+
+	s.Equal(http.StatusOK, w.Code)
+
+	var response api.RescheduleOptionsResponse
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&response))
+
+	s.True(startTime.Add(-24 * time.Hour).Equal(response.ChangeDeadline))
+	s.Require().Len(response.Showtimes, 1)
+	s.Equal(2, response.Showtimes[0].ShowtimeId)
+	s.Equal(40, response.Showtimes[0].AvailableSeats)
+	s.Equal("12", response.Showtimes[0].Price.String())
+
+	s.reservationRepo.AssertExpectations(s.T())
+}
+
+func (s *RescheduleTestSuite) TestHandleShowtimeChangeCompleted() {
+	const (
+		paymentId     = 77
+		reservationId = 10
+		fromShowtime  = 1
+		toShowtime    = 2
+	)
+
+	reservationIdRef := reservationId
+
+	change := domain.ShowtimeChange{
+		ReservationID:   reservationId,
+		UserID:          1,
+		FromShowtimeID:  fromShowtime,
+		FromSeatIDs:     []int{7, 8},
+		ToShowtimeID:    toShowtime,
+		Seats:           []domain.ReservationSeat{{SeatID: 1, ShowtimeID: toShowtime}, {SeatID: 2, ShowtimeID: toShowtime}},
+		PriceDifference: decimal.NewFromInt(5),
+	}
+
+	changeBytes, err := json.Marshal(change)
+	s.Require().NoError(err)
+
+	lockOwner := rescheduleLockOwner(reservationId)
+	lockKeys := []string{seatLockKey(toShowtime, 1), seatLockKey(toShowtime, 2)}
+	movedSeats := rescheduled(reservationId, fromShowtime, toShowtime, func(change domain.ShowtimeChange) bool {
+		return len(change.Seats) == 2 && change.Seats[0].SeatID == 1 && change.Seats[1].SeatID == 2
+	})
+
+	checkoutSession := stripe.CheckoutSession{
+		ID:            "cs_123",
+		PaymentIntent: &stripe.PaymentIntent{ID: "pi_456"},
+		Metadata: map[string]string{
+			domain.CheckoutMetadataPaymentID:     "77",
+			domain.CheckoutMetadataUserID:        "1",
+			domain.CheckoutMetadataReservationID: "10",
+		},
+	}
+
+	expectPendingChange := func() {
+		s.paymentRepo.On("GetById", mock.Anything, paymentId).Return(&domain.Payment{
+			ID:            paymentId,
+			Status:        domain.PaymentStatusPending,
+			ReservationID: &reservationIdRef,
+		}, nil).Once()
+		s.redisClient.On("Get", mock.Anything, showtimeChangeKey(paymentId)).
+			Return(redis.NewStringResult(string(changeBytes), nil)).Once()
+		for _, key := range lockKeys {
+			s.redisClient.On("Get", mock.Anything, key).Return(redis.NewStringResult(lockOwner, nil)).Once()
+		}
+	}
+
+	tests := []struct {
+		name       string
+		setupMocks func()
+		wantStatus int
+	}{
+		{
+			name: "should acknowledge a payment that is already completed",
+			setupMocks: func() {
+				s.paymentRepo.On("GetById", mock.Anything, paymentId).Return(&domain.Payment{
+					ID:            paymentId,
+					Status:        domain.PaymentStatusCompleted,
+					ReservationID: &reservationIdRef,
+				}, nil).Once()
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "should move the reservation once the difference is paid",
+			setupMocks: func() {
+				expectPendingChange()
+				s.reservationRepo.On("Reschedule", mock.Anything, movedSeats).Return(nil, nil).Once()
+				s.paymentRepo.On("MarkCompleted", mock.Anything, paymentId, "cs_123", "pi_456").Return(nil).Once()
+				s.redisClient.On("Del", mock.Anything, []string{showtimeChangeKey(paymentId)}).Return(redis.NewIntResult(1, nil)).Once()
+				s.redisClient.On("TxPipeline").Return(s.redisPipeline).Once()
+				s.redisPipeline.On("Del", mock.Anything, lockKeys).Return(redis.NewIntResult(2, nil)).Once()
+				s.redisPipeline.On("SRem", mock.Anything, seatSetKey(toShowtime), []interface{}{1, 2}).Return(redis.NewIntResult(2, nil)).Once()
+				s.redisPipeline.On("Exec", mock.Anything).Return([]redis.Cmder{}, nil).Once()
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatMapChangeKeys(fromShowtime), seatEventsChannel(fromShowtime), mock.Anything, mock.Anything).
+					Return(redis.NewCmdResult(int64(1), nil)).Once()
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatMapChangeKeys(toShowtime), seatEventsChannel(toShowtime), mock.Anything, mock.Anything).
+					Return(redis.NewCmdResult(int64(1), nil)).Once()
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "should refund the difference when the seats were sold meanwhile",
+			setupMocks: func() {
+				expectPendingChange()
+				s.reservationRepo.On("Reschedule", mock.Anything, movedSeats).Return(nil, domain.ErrSeatAlreadyReserved).Once()
+				s.paymentProvider.On("RefundPayment", "pi_456", "showtime-change-77").Return(&stripe.Refund{ID: "re_1"}, nil).Once()
+				s.paymentRepo.On("MarkUnfulfilled", mock.Anything, paymentId, "cs_123", "pi_456", domain.ErrSeatAlreadyReserved.Error()).
+					Return(nil).Once()
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, lockKeys, lockOwner).
+					Return(redis.NewCmdResult([]interface{}{int64(1), int64(1)}, nil)).Once()
+				s.redisClient.On("TxPipeline").Return(s.redisPipeline).Once()
+				s.redisPipeline.On("SRem", mock.Anything, seatSetKey(toShowtime), []interface{}{1}).Return(redis.NewIntResult(1, nil)).Once()
+				s.redisPipeline.On("SRem", mock.Anything, seatSetKey(toShowtime), []interface{}{2}).Return(redis.NewIntResult(1, nil)).Once()
+				s.redisPipeline.On("Del", mock.Anything, []string{showtimeChangeKey(paymentId)}).Return(redis.NewIntResult(1, nil)).Once()
+				s.redisPipeline.On("Exec", mock.Anything).Return([]redis.Cmder{}, nil).Once()
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatMapChangeKeys(toShowtime), seatEventsChannel(toShowtime), mock.Anything, mock.Anything).
+					Return(redis.NewCmdResult(int64(1), nil)).Once()
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "should refund the difference when the held seats expired",
+			setupMocks: func() {
+				s.paymentRepo.On("GetById", mock.Anything, paymentId).Return(&domain.Payment{
+					ID:            paymentId,
+					Status:        domain.PaymentStatusPending,
+					ReservationID: &reservationIdRef,
+				}, nil).Once()
+				s.redisClient.On("Get", mock.Anything, showtimeChangeKey(paymentId)).Return(redis.NewStringResult("", redis.Nil)).Once()
+				s.paymentProvider.On("RefundPayment", "pi_456", "showtime-change-77").Return(&stripe.Refund{ID: "re_1"}, nil).Once()
+				s.paymentRepo.On("MarkUnfulfilled", mock.Anything, paymentId, "cs_123", "pi_456", errShowtimeChangeExpired.Error()).
+					Return(nil).Once()
+			},
+			wantStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+			tt.setupMocks()
+
+			w, r := executeRequest(s.T(), http.MethodPost, "/webhook", nil)
+
+			s.app.handleCheckoutSessionCompleted(w, r, checkoutSession)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			s.reservationRepo.AssertExpectations(s.T())
+			s.paymentRepo.AssertExpectations(s.T())
+			s.paymentProvider.AssertExpectations(s.T())
+			s.redisClient.AssertExpectations(s.T())
+			s.redisPipeline.AssertExpectations(s.T())
+		})
+	}
+}
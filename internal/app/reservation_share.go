@@ -0,0 +1,206 @@
+package app
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+// a share link keeps working for a day after the screening started, so a post made after the movie still
+// gets its preview
+const reservationShareGracePeriod = 24 * time.Hour
+
+var errReservationNotShareable = errors.New("the reservation is cancelled, its tickets are revoked or its screening is over")
+
+// sharedScreeningPage is the public page of a share link. Its Open Graph tags are what chat applications
+// and social networks show as the preview of the link. The token of the link is kept out of the Referer
+// header sent for the poster, and search engines are asked not to index the page.
+var sharedScreeningPage = template.Must(template.New("shared_screening").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="referrer" content="no-referrer">
+<meta name="robots" content="noindex">
+<title>{{.Title}}</title>
+<meta property="og:type" content="website">
+<meta property="og:site_name" content="{{.SiteName}}">
+<meta property="og:title" content="{{.Title}}">
+<meta property="og:description" content="{{.Description}}">
+<meta property="og:url" content="{{.URL}}">
+{{- if .PosterUrl}}
+<meta property="og:image" content="{{.PosterUrl}}">
+<meta name="twitter:card" content="summary_large_image">
+{{- end}}
+</head>
+<body>
+<main>
+{{- if .PosterUrl}}
+<img src="{{.PosterUrl}}" alt="Poster of {{.MovieTitle}}">
+{{- end}}
+<h1>{{.MovieTitle}}</h1>
+<p>{{.Showtime}}</p>
+<p>{{.Theater}}</p>
+</main>
+</body>
+</html>
+`))
+
+type sharedScreeningData struct {
+	SiteName    string
+	Title       string
+	Description string
+	URL         string
+	PosterUrl   string
+	MovieTitle  string
+	Showtime    string
+	Theater     string
+}
+
+func (app *Application) ShareReservation(w http.ResponseWriter, r *http.Request, reservationId int) {
+	logger := app.contextGetLogger(r)
+
+	if reservationId <= 0 {
+		app.badRequestResponse(w, r, fmt.Errorf("reservation id must be greater than zero"))
+		return
+	}
+
+	userId := app.contextGetUserId(r)
+
+	booked, err := app.reservationRepo.GetBookedShowtime(r.Context(), reservationId, userId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	expiresAt := booked.StartTime.Add(reservationShareGracePeriod)
+
+	if booked.Status == domain.ReservationCancelled || booked.TicketsRevokedAt != nil || !time.Now().Before(expiresAt) {
+		app.editConflictResponseWithErr(w, r, errReservationNotShareable)
+		return
+	}
+
+	token, tokenHash, err := domain.GenerateShareToken()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.reservationRepo.Share(r.Context(), booked.ReservationID, tokenHash, expiresAt)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	logger.Info("reservation shared", "reservation_id", booked.ReservationID, "expires_at", expiresAt)
+
+	resp := api.ReservationShareResponse{
+		Url:       app.publicURL("/shares/" + token),
+		ExpiresAt: expiresAt,
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) RevokeReservationShare(w http.ResponseWriter, r *http.Request, reservationId int) {
+	logger := app.contextGetLogger(r)
+
+	if reservationId <= 0 {
+		app.badRequestResponse(w, r, fmt.Errorf("reservation id must be greater than zero"))
+		return
+	}
+
+	err := app.reservationRepo.RevokeShare(r.Context(), reservationId, app.contextGetUserId(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	logger.Info("reservation share revoked", "reservation_id", reservationId)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetSharedScreening renders the public page of a share link. It shows the movie, the time and the
+// theater of the screening only, never the seats or the price of the reservation.
+func (app *Application) GetSharedScreening(w http.ResponseWriter, r *http.Request, token string) {
+	logger := app.contextGetLogger(r)
+
+	hash := sha256.Sum256([]byte(token))
+
+	screening, err := app.reservationRepo.GetSharedScreening(r.Context(), hash[:], time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	siteName := defaultBrandName
+	if tenant := domain.TenantFromContext(r.Context()); tenant != nil {
+		siteName = tenant.Name
+	}
+
+	showtime := screening.StartTime.In(domain.TheaterLocation(screening.TheaterTimeZone)).Format("Mon, 02 Jan 2006 15:04 MST")
+	theater := screening.TheaterName
+	if screening.TheaterCity != "" {
+		theater += ", " + screening.TheaterCity
+	}
+
+	data := sharedScreeningData{
+		SiteName:    siteName,
+		Title:       "I'm going to see " + screening.MovieTitle,
+		Description: fmt.Sprintf("%s at %s", showtime, theater),
+		URL:         app.publicURL("/shares/" + token),
+		PosterUrl:   screening.MoviePosterUrl,
+		MovieTitle:  screening.MovieTitle,
+		Showtime:    showtime,
+		Theater:     theater,
+	}
+
+	var buf bytes.Buffer
+
+	err = sharedScreeningPage.Execute(&buf, data)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// a revoked link must stop showing the screening right away
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	w.WriteHeader(http.StatusOK)
+
+	_, err = w.Write(buf.Bytes())
+	if err != nil {
+		logger.Error("failed to write shared screening page", "error", err)
+	}
+}
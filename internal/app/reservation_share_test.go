@@ -0,0 +1,275 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestShareReservation(t *testing.T) {
+	const (
+		userId        = 1
+		reservationId = 10
+	)
+
+	startTime := time.Now().Add(3 * time.Hour).Truncate(time.Second)
+	revokedAt := time.Now()
+
+	tests := []struct {
+		name           string
+		reservationId  int
+		booked         *domain.BookedShowtime
+		bookedErr      error
+		wantStatus     int
+		wantErrMessage string
+	}{
+		{
+			name:           "invalid reservation id",
+			reservationId:  0,
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "reservation id must be greater than zero",
+		},
+		{
+			name:           "reservation not found",
+			reservationId:  reservationId,
+			bookedErr:      domain.ErrRecordNotFound,
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+		},
+		{
+			name:          "cancelled reservation",
+			reservationId: reservationId,
+			booked: &domain.BookedShowtime{
+				ReservationID: reservationId,
+				Status:        domain.ReservationCancelled,
+				StartTime:     startTime,
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: errReservationNotShareable.Error(),
+		},
+		{
+			name:          "revoked tickets",
+			reservationId: reservationId,
+			booked: &domain.BookedShowtime{
+				ReservationID:    reservationId,
+				Status:           domain.ReservationConfirmed,
+				TicketsRevokedAt: &revokedAt,
+				StartTime:        startTime,
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: errReservationNotShareable.Error(),
+		},
+		{
+			name:          "screening is over",
+			reservationId: reservationId,
+			booked: &domain.BookedShowtime{
+				ReservationID: reservationId,
+				Status:        domain.ReservationConfirmed,
+				StartTime:     time.Now().Add(-reservationShareGracePeriod - time.Minute),
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: errReservationNotShareable.Error(),
+		},
+		{
+			name:          "shared",
+			reservationId: reservationId,
+			booked: &domain.BookedShowtime{
+				ReservationID: reservationId,
+				Status:        domain.ReservationConfirmed,
+				StartTime:     startTime,
+			},
+			wantStatus: http.StatusCreated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reservationRepo := new(mocks.MockReservationRepo)
+
+			app := newTestApplication(func(a *Application) {
+				a.reservationRepo = reservationRepo
+				a.config.BaseURL = "https://cinex.example/"
+			})
+
+			if tt.booked != nil || tt.bookedErr != nil {
+				reservationRepo.On("GetBookedShowtime", mock.Anything, tt.reservationId, userId).
+					Return(tt.booked, tt.bookedErr).Once()
+			}
+
+			var storedHash []byte
+			if tt.wantStatus == http.StatusCreated {
+				reservationRepo.On("Share", mock.Anything, reservationId, mock.Anything, startTime.Add(reservationShareGracePeriod)).
+					Run(func(args mock.Arguments) { storedHash = args.Get(2).([]byte) }).
+					Return(nil).Once()
+			}
+
+			w, r := executeRequest(t, http.MethodPost, "/users/me/reservations/10/share", nil)
+			r = r.WithContext(context.WithValue(r.Context(), SessionKeyUserId, userId))
+
+			app.ShareReservation(w, r, tt.reservationId)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status code = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			checkErrorResponse(t, w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{tt.wantStatus, tt.wantErrMessage})
+
+			if tt.wantStatus == http.StatusCreated {
+				var resp api.ReservationShareResponse
+				if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+
+				token, ok := strings.CutPrefix(resp.Url, "https://cinex.example/shares/")
+				if !ok {
+					t.Fatalf("unexpected share link %q", resp.Url)
+				}
+
+				// only the hash of the token is stored
+				hash := sha256.Sum256([]byte(token))
+				if string(storedHash) != string(hash[:]) {
+					t.Errorf("stored hash doesn't match the token of the link")
+				}
+
+				if !resp.ExpiresAt.Equal(startTime.Add(reservationShareGracePeriod)) {
+					t.Errorf("expiresAt = %v, want a day after the screening started", resp.ExpiresAt)
+				}
+			}
+
+			reservationRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRevokeReservationShare(t *testing.T) {
+	tests := []struct {
+		name           string
+		revokeErr      error
+		wantStatus     int
+		wantErrMessage string
+	}{
+		{
+			name:           "not shared",
+			revokeErr:      domain.ErrRecordNotFound,
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+		},
+		{
+			name:           "database error",
+			revokeErr:      errors.New("db error"),
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+		{
+			name:       "revoked",
+			wantStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reservationRepo := new(mocks.MockReservationRepo)
+			reservationRepo.On("RevokeShare", mock.Anything, 10, 1).Return(tt.revokeErr).Once()
+
+			app := newTestApplication(func(a *Application) {
+				a.reservationRepo = reservationRepo
+			})
+
+			w, r := executeRequest(t, http.MethodDelete, "/users/me/reservations/10/share", nil)
+			r = r.WithContext(context.WithValue(r.Context(), SessionKeyUserId, 1))
+
+			app.RevokeReservationShare(w, r, 10)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status code = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			checkErrorResponse(t, w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{tt.wantStatus, tt.wantErrMessage})
+
+			reservationRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetSharedScreening(t *testing.T) {
+	token := "share-token"
+	hash := sha256.Sum256([]byte(token))
+
+	screening := &domain.SharedScreening{
+		MovieTitle:      "Tom & Jerry",
+		MoviePosterUrl:  "https://cdn.example/poster.jpg",
+		TheaterName:     "Kadıköy Cinema",
+		TheaterCity:     "Istanbul",
+		StartTime:       time.Date(2025, 6, 1, 17, 30, 0, 0, time.UTC),
+		TheaterTimeZone: "Europe/Istanbul",
+	}
+
+	t.Run("link not found", func(t *testing.T) {
+		reservationRepo := new(mocks.MockReservationRepo)
+		reservationRepo.On("GetSharedScreening", mock.Anything, hash[:], mock.Anything).
+			Return(nil, domain.ErrRecordNotFound).Once()
+
+		app := newTestApplication(func(a *Application) {
+			a.reservationRepo = reservationRepo
+		})
+
+		w, r := executeRequest(t, http.MethodGet, "/shares/"+token, nil)
+		app.GetSharedScreening(w, r, token)
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("status code = %d, want %d", w.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("renders the page", func(t *testing.T) {
+		reservationRepo := new(mocks.MockReservationRepo)
+		reservationRepo.On("GetSharedScreening", mock.Anything, hash[:], mock.Anything).
+			Return(screening, nil).Once()
+
+		app := newTestApplication(func(a *Application) {
+			a.reservationRepo = reservationRepo
+			a.config.BaseURL = "https://cinex.example"
+		})
+
+		w, r := executeRequest(t, http.MethodGet, "/shares/"+token, nil)
+		app.GetSharedScreening(w, r, token)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("status code = %d, want %d", w.Code, http.StatusOK)
+		}
+
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+			t.Errorf("Content-Type = %q, want text/html", ct)
+		}
+
+		body := w.Body.String()
+
+		for _, want := range []string{
+			`<meta property="og:title" content="I&#39;m going to see Tom &amp; Jerry">`,
+			`<meta property="og:description" content="Sun, 01 Jun 2025 20:30 &#43;03 at Kadıköy Cinema, Istanbul">`,
+			`<meta property="og:image" content="https://cdn.example/poster.jpg">`,
+			`<meta property="og:url" content="https://cinex.example/shares/share-token">`,
+			`<meta name="referrer" content="no-referrer">`,
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("page doesn't contain %s:\n%s", want, body)
+			}
+		}
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/metinatakli/movie-reservation-system/api"
 	"github.com/metinatakli/movie-reservation-system/internal/domain"
@@ -21,7 +22,7 @@ func (app *Application) GetReservationsOfUserHandler(
 	}
 
 	userId := app.contextGetUserId(r)
-	pagination := toPagination(params)
+	pagination := toPagination(params.Page, params.PageSize)
 
 	reservations, metadata, err := app.reservationRepo.GetReservationsSummariesByUserId(r.Context(), userId, pagination)
 	if err != nil {
@@ -30,7 +31,7 @@ func (app *Application) GetReservationsOfUserHandler(
 	}
 
 	reservationSummaries := toReservationSummaries(reservations)
-	apiMetadata := toApiMetadata(metadata)
+	apiMetadata := toApiMetadata(r, metadata)
 	resp := api.UserReservationsResponse{
 		Reservations: reservationSummaries,
 		Metadata:     *apiMetadata,
@@ -52,7 +53,7 @@ func toReservationSummaries(reservations []domain.ReservationSummary) []api.Rese
 			MoviePosterUrl: v.MoviePosterUrl,
 			HallName:       v.HallName,
 			TheaterName:    v.TheaterName,
-			Date:           v.ShowtimeDate,
+			Date:           v.ShowtimeDate.In(domain.TheaterLocation(v.TheaterTimeZone)),
 			CreatedAt:      v.CreatedAt,
 		}
 	}
@@ -60,17 +61,18 @@ func toReservationSummaries(reservations []domain.ReservationSummary) []api.Rese
 	return reservationSummaries
 }
 
-func toPagination(params api.GetReservationsOfUserHandlerParams) domain.Pagination {
+// toPagination applies the page and page size of a list request, the defaults are used for the omitted ones.
+func toPagination(page, pageSize *int) domain.Pagination {
 	pagination := domain.Pagination{
 		Page:     DefaultPage,
 		PageSize: DefaultPageSize,
 	}
 
-	if params.Page != nil {
-		pagination.Page = *params.Page
+	if page != nil {
+		pagination.Page = *page
 	}
-	if params.PageSize != nil {
-		pagination.PageSize = *params.PageSize
+	if pageSize != nil {
+		pagination.PageSize = *pageSize
 	}
 
 	return pagination
@@ -102,6 +104,16 @@ func (app *Application) GetUserReservationById(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	if reservationDetail.Payment.PaymentIntentID != "" {
+		// the reservation is useful without the receipt, so a provider outage only leaves it out
+		receipt, err := app.paymentReceipt(r.Context(), reservationDetail.Payment.PaymentIntentID)
+		if err != nil {
+			logger.Warn("failed to get payment receipt", "reservation_id", reservationId, "error", err)
+		}
+
+		reservationDetail.Payment.Receipt = receipt
+	}
+
 	resp := toReservationDetailResponse(reservationDetail)
 
 	err = app.writeJSON(w, http.StatusOK, resp, nil)
@@ -117,6 +129,7 @@ func toReservationDetailResponse(reservationDetail *domain.ReservationDetail) ap
 			Row:    s.Row,
 			Column: s.Col,
 			Type:   api.SeatType(s.Type),
+			Price:  s.Price,
 		}
 	}
 
@@ -138,11 +151,17 @@ func toReservationDetailResponse(reservationDetail *domain.ReservationDetail) ap
 		}
 	}
 
+	var specialRequests *[]api.SpecialRequest
+	if len(reservationDetail.SpecialRequests) > 0 {
+		requests := toApiSpecialRequests(reservationDetail.SpecialRequests)
+		specialRequests = &requests
+	}
+
 	return api.ReservationDetailResponse{
 		Id:               reservationDetail.ReservationID,
 		MovieTitle:       reservationDetail.MovieTitle,
 		MoviePosterUrl:   reservationDetail.MoviePosterUrl,
-		Date:             reservationDetail.ShowtimeDate,
+		Date:             reservationDetail.ShowtimeDate.In(domain.TheaterLocation(reservationDetail.TheaterTimeZone)),
 		TheaterName:      reservationDetail.TheaterName,
 		HallName:         reservationDetail.HallName,
 		CreatedAt:        reservationDetail.CreatedAt,
@@ -150,5 +169,183 @@ func toReservationDetailResponse(reservationDetail *domain.ReservationDetail) ap
 		TheaterAmenities: &theaterAmenities,
 		HallAmenities:    &hallAmenities,
 		TotalPrice:       reservationDetail.TotalPrice,
+		Note:             optionalString(reservationDetail.Note),
+		SpecialRequests:  specialRequests,
+		Payment:          toApiReservationPayment(reservationDetail.Payment),
+		FlexibleTicket:   reservationDetail.FlexibleTicket,
+	}
+}
+
+func toApiReservationPayment(payment domain.ReservationPayment) *api.ReservationPayment {
+	apiPayment := &api.ReservationPayment{
+		Amount:         payment.Amount,
+		Currency:       payment.Currency,
+		Status:         api.PaymentStatus(payment.Status),
+		RefundedAmount: payment.RefundedAmount,
+		RefundState:    api.ReservationPaymentRefundState(payment.RefundState()),
+	}
+
+	if payment.Receipt != nil {
+		apiPayment.CardBrand = optionalString(payment.Receipt.CardBrand)
+		apiPayment.CardLast4 = optionalString(payment.Receipt.CardLast4)
+		apiPayment.ReceiptUrl = optionalString(payment.Receipt.ReceiptURL)
+	}
+
+	return apiPayment
+}
+
+func toApiSpecialRequests(requests []domain.SpecialRequest) []api.SpecialRequest {
+	apiRequests := make([]api.SpecialRequest, len(requests))
+	for i, request := range requests {
+		apiRequests[i] = api.SpecialRequest(request)
+	}
+
+	return apiRequests
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return &s
+}
+
+func (app *Application) GetShowtimeCheckInList(w http.ResponseWriter, r *http.Request, showtimeId int) {
+	if showtimeId < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("showtime ID must be greater than zero"))
+		return
+	}
+
+	entries, err := app.reservationRepo.GetCheckInListByShowtime(r.Context(), showtimeId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
 	}
+
+	resp := api.CheckInListResponse{
+		ShowtimeId:   showtimeId,
+		Reservations: make([]api.CheckInEntry, len(entries)),
+	}
+
+	for i, entry := range entries {
+		seats := make([]api.ReservationSeat, len(entry.Seats))
+		for j, s := range entry.Seats {
+			seats[j] = api.ReservationSeat{
+				Row:    s.Row,
+				Column: s.Col,
+				Type:   api.SeatType(s.Type),
+			}
+		}
+
+		resp.Reservations[i] = api.CheckInEntry{
+			ReservationId:   entry.ReservationID,
+			Status:          api.CheckInEntryStatus(entry.Status),
+			GuestName:       strings.TrimSpace(entry.GuestFirstName + " " + entry.GuestLastName),
+			Seats:           seats,
+			Note:            optionalString(entry.Note),
+			SpecialRequests: toApiSpecialRequests(entry.SpecialRequests),
+			TicketsRevoked:  entry.TicketsRevokedAt != nil,
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// LookupShowtimeReservations reports for every email address whether it got seats for the showtime.
+func (app *Application) LookupShowtimeReservations(w http.ResponseWriter, r *http.Request, showtimeId int) {
+	if showtimeId < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("showtime ID must be greater than zero"))
+		return
+	}
+
+	var input api.ReservationLookupRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	// addresses are matched case-insensitively, a repeated one is answered once
+	seen := make(map[string]bool, len(input.Emails))
+	emails := make([]string, 0, len(input.Emails))
+
+	for _, email := range input.Emails {
+		key := strings.ToLower(email)
+		if !seen[key] {
+			seen[key] = true
+			emails = append(emails, email)
+		}
+	}
+
+	lookups, err := app.reservationRepo.LookupByEmails(r.Context(), showtimeId, emails)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	resp := api.ReservationLookupResponse{
+		ShowtimeId: showtimeId,
+		Results:    make([]api.ReservationLookupResult, len(lookups)),
+	}
+
+	for i, lookup := range lookups {
+		resp.Results[i] = toApiReservationLookupResult(lookup)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func toApiReservationLookupResult(lookup domain.ReservationLookup) api.ReservationLookupResult {
+	result := api.ReservationLookupResult{
+		Email:        lookup.Email,
+		Status:       api.NoReservation,
+		Reservations: make([]api.LookedUpReservation, len(lookup.Reservations)),
+	}
+
+	if len(lookup.Reservations) > 0 {
+		result.Status = api.AllCancelled
+	}
+
+	for i, reservation := range lookup.Reservations {
+		seats := make([]api.ReservationSeat, len(reservation.Seats))
+		for j, s := range reservation.Seats {
+			seats[j] = api.ReservationSeat{
+				Row:    s.Row,
+				Column: s.Col,
+				Type:   api.SeatType(s.Type),
+			}
+		}
+
+		result.Reservations[i] = api.LookedUpReservation{
+			ReservationId: reservation.ReservationID,
+			Status:        string(reservation.Status),
+			Seats:         seats,
+		}
+
+		if reservation.Status != domain.ReservationCancelled {
+			result.Status = api.Seated
+			result.SeatCount += len(reservation.Seats)
+		}
+	}
+
+	return result
 }
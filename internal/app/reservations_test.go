@@ -1,11 +1,14 @@
 package app
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/alexedwards/scs/v2"
 	"github.com/google/go-cmp/cmp"
@@ -13,6 +16,7 @@ import (
 	"github.com/metinatakli/movie-reservation-system/internal/domain"
 	"github.com/metinatakli/movie-reservation-system/internal/mocks"
 	"github.com/metinatakli/movie-reservation-system/internal/validator"
+	"github.com/redis/go-redis/v9"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
@@ -22,12 +26,18 @@ type ReservationsTestSuite struct {
 	suite.Suite
 	app             *Application
 	reservationRepo *mocks.MockReservationRepo
+	redisClient     *mocks.MockRedisClient
+	paymentProvider *mocks.MockPaymentProvider
 }
 
 func (s *ReservationsTestSuite) SetupTest() {
 	s.reservationRepo = new(mocks.MockReservationRepo)
+	s.redisClient = new(mocks.MockRedisClient)
+	s.paymentProvider = new(mocks.MockPaymentProvider)
 	s.app = newTestApplication(func(a *Application) {
 		a.reservationRepo = s.reservationRepo
+		a.redis = s.redisClient
+		a.paymentProvider = s.paymentProvider
 		a.sessionManager = scs.New()
 	})
 }
@@ -145,6 +155,11 @@ func (s *ReservationsTestSuite) TestGetReservationsOfUserHandler() {
 					FirstPage:    1,
 					LastPage:     1,
 					TotalRecords: 1,
+					Links: api.PaginationLinks{
+						Self:  "/users/me/reservations?page=1&pageSize=10",
+						First: "/users/me/reservations?page=1&pageSize=10",
+						Last:  "/users/me/reservations?page=1&pageSize=10",
+					},
 				},
 			},
 		},
@@ -281,6 +296,11 @@ func (s *ReservationsTestSuite) TestGetUserReservationById() {
 							{ID: 2, Name: "3D Glasses", Description: "3D glasses provided"},
 						},
 						TotalPrice: decimal.NewFromFloat(25.50),
+						Payment: domain.ReservationPayment{
+							Amount:   decimal.NewFromFloat(25.50),
+							Currency: "usd",
+							Status:   domain.PaymentStatusCompleted,
+						},
 					}, nil)
 			},
 			wantStatus: http.StatusOK,
@@ -303,6 +323,13 @@ func (s *ReservationsTestSuite) TestGetUserReservationById() {
 				HallAmenities: &[]api.Amenity{
 					{Id: 2, Name: "3D Glasses", Description: "3D glasses provided"},
 				},
+				Payment: &api.ReservationPayment{
+					Amount:         decimal.NewFromFloat(25.50),
+					Currency:       "usd",
+					Status:         api.PaymentStatusCompleted,
+					RefundedAmount: decimal.Zero,
+					RefundState:    api.None,
+				},
 			},
 		},
 	}
@@ -350,3 +377,487 @@ func (s *ReservationsTestSuite) TestGetUserReservationById() {
 		})
 	}
 }
+
+func (s *ReservationsTestSuite) TestGetUserReservationByIdPayment() {
+	receipt := domain.PaymentReceipt{CardBrand: "visa", CardLast4: "4242", ReceiptURL: "https://pay.stripe.com/receipts/1"}
+	receiptJSON, _ := json.Marshal(receipt)
+
+	tests := []struct {
+		name        string
+		payment     domain.ReservationPayment
+		setupMock   func()
+		wantPayment api.ReservationPayment
+	}{
+		{
+			name: "should serve the receipt from the cache",
+			payment: domain.ReservationPayment{
+				Amount:          decimal.NewFromInt(30),
+				Currency:        "usd",
+				Status:          domain.PaymentStatusCompleted,
+				PaymentIntentID: "pi_1",
+				RefundedAmount:  decimal.NewFromInt(10),
+			},
+			setupMock: func() {
+				s.redisClient.On("Get", mock.Anything, "payment_receipt:pi_1").
+					Return(redis.NewStringResult(string(receiptJSON), nil))
+			},
+			wantPayment: api.ReservationPayment{
+				Amount:         decimal.NewFromInt(30),
+				Currency:       "usd",
+				Status:         api.PaymentStatusCompleted,
+				RefundedAmount: decimal.NewFromInt(10),
+				RefundState:    api.Partial,
+				CardBrand:      ptr("visa"),
+				CardLast4:      ptr("4242"),
+				ReceiptUrl:     ptr("https://pay.stripe.com/receipts/1"),
+			},
+		},
+		{
+			name: "should read the receipt from the provider and cache it",
+			payment: domain.ReservationPayment{
+				Amount:          decimal.NewFromInt(30),
+				Currency:        "usd",
+				Status:          domain.PaymentStatusCompleted,
+				PaymentIntentID: "pi_1",
+				RefundPending:   true,
+			},
+			setupMock: func() {
+				s.redisClient.On("Get", mock.Anything, "payment_receipt:pi_1").
+					Return(redis.NewStringResult("", redis.Nil))
+				s.paymentProvider.On("GetPaymentReceipt", "pi_1").Return(&receipt, nil)
+				s.redisClient.On("Set", mock.Anything, "payment_receipt:pi_1", receiptJSON, paymentReceiptCacheTTL).
+					Return(redis.NewStatusResult("OK", nil))
+			},
+			wantPayment: api.ReservationPayment{
+				Amount:         decimal.NewFromInt(30),
+				Currency:       "usd",
+				Status:         api.PaymentStatusCompleted,
+				RefundedAmount: decimal.Zero,
+				RefundState:    api.Pending,
+				CardBrand:      ptr("visa"),
+				CardLast4:      ptr("4242"),
+				ReceiptUrl:     ptr("https://pay.stripe.com/receipts/1"),
+			},
+		},
+		{
+			name: "should leave the receipt out when the provider fails",
+			payment: domain.ReservationPayment{
+				Amount:          decimal.NewFromInt(30),
+				Currency:        "usd",
+				Status:          domain.PaymentStatusRefunded,
+				PaymentIntentID: "pi_1",
+				RefundedAmount:  decimal.NewFromInt(30),
+			},
+			setupMock: func() {
+				s.redisClient.On("Get", mock.Anything, "payment_receipt:pi_1").
+					Return(redis.NewStringResult("", redis.Nil))
+				s.paymentProvider.On("GetPaymentReceipt", "pi_1").Return(nil, fmt.Errorf("provider error"))
+			},
+			wantPayment: api.ReservationPayment{
+				Amount:         decimal.NewFromInt(30),
+				Currency:       "usd",
+				Status:         api.PaymentStatusRefunded,
+				RefundedAmount: decimal.NewFromInt(30),
+				RefundState:    api.Full,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			s.reservationRepo.On("GetByReservationIdAndUserId", mock.Anything, 1, 1).
+				Return(&domain.ReservationDetail{
+					ReservationSummary: domain.ReservationSummary{ReservationID: 1},
+					TotalPrice:         tt.payment.Amount,
+					Payment:            tt.payment,
+				}, nil)
+			tt.setupMock()
+
+			w, r := executeRequest(s.T(), http.MethodGet, "/reservations/1", nil)
+			r = setupTestSession(s.T(), s.app, r, 1)
+
+			handler := s.app.requireAuthentication(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				s.app.GetUserReservationById(w, r, 1)
+			}))
+			handler = s.app.sessionManager.LoadAndSave(handler)
+			handler.ServeHTTP(w, r)
+
+			s.Equal(http.StatusOK, w.Code)
+
+			var response api.ReservationDetailResponse
+			s.Require().NoError(json.NewDecoder(w.Body).Decode(&response))
+			s.Require().NotNil(response.Payment)
+
+			diff := cmp.Diff(tt.wantPayment, *response.Payment)
+			s.Empty(diff, "Payment mismatch (-want +got):\n%s", diff)
+
+			s.reservationRepo.AssertExpectations(s.T())
+			s.redisClient.AssertExpectations(s.T())
+			s.paymentProvider.AssertExpectations(s.T())
+		})
+	}
+}
+
+func (s *ReservationsTestSuite) TestGetUserReservationCalendar() {
+	reservationDetail := &domain.ReservationDetail{
+		ReservationSummary: domain.ReservationSummary{
+			ReservationID: 7,
+			MovieTitle:    "Crouching Tiger, Hidden Dragon",
+			ShowtimeDate:  time.Date(2024, 3, 15, 19, 0, 0, 0, time.UTC),
+			TheaterName:   "Cinema City",
+			HallName:      "Hall 1",
+		},
+		MovieDuration:   120,
+		TheaterAddress:  "Bagdat Cd. 10",
+		TheaterDistrict: "Kadikoy",
+		TheaterCity:     "Istanbul",
+		TheaterLocation: domain.GeoPoint{Latitude: 40.9631, Longitude: 29.0634},
+		Seats: []domain.ReservationDetailSeat{
+			{Row: 1, Col: 1, Type: "standard"},
+			{Row: 1, Col: 2, Type: "vip"},
+		},
+	}
+
+	tests := []struct {
+		name           string
+		setupSession   bool
+		reservationId  int
+		setupMock      func()
+		wantStatus     int
+		wantErrMessage string
+		wantLines      []string
+	}{
+		{
+			name:           "invalid reservation id",
+			setupSession:   true,
+			reservationId:  0,
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "reservation id must be greater than zero",
+		},
+		{
+			name:           "no session",
+			reservationId:  7,
+			wantStatus:     http.StatusUnauthorized,
+			wantErrMessage: ErrUnauthorizedAccess,
+		},
+		{
+			name:          "reservation not found",
+			setupSession:  true,
+			reservationId: 7,
+			setupMock: func() {
+				s.reservationRepo.On("GetByReservationIdAndUserId", mock.Anything, 7, 1).
+					Return(nil, domain.ErrRecordNotFound)
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+		},
+		{
+			name:          "successful export",
+			setupSession:  true,
+			reservationId: 7,
+			setupMock: func() {
+				s.reservationRepo.On("GetByReservationIdAndUserId", mock.Anything, 7, 1).
+					Return(reservationDetail, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantLines: []string{
+				"BEGIN:VCALENDAR",
+				"UID:reservation-7@cinex.metinatakli.net",
+				"DTSTART:20240315T190000Z",
+				"DTEND:20240315T210000Z",
+				`SUMMARY:Crouching Tiger\, Hidden Dragon`,
+				`LOCATION:Cinema City\, Bagdat Cd. 10\, Kadikoy\, Istanbul`,
+				"GEO:40.963100;29.063400",
+				"END:VCALENDAR",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			defer s.reservationRepo.AssertExpectations(s.T())
+
+			if tt.setupMock != nil {
+				tt.setupMock()
+			}
+
+			w, r := executeRequest(s.T(), http.MethodGet, fmt.Sprintf("/reservations/%d/calendar.ics", tt.reservationId), nil)
+
+			if tt.setupSession {
+				r = setupTestSession(s.T(), s.app, r, 1)
+			}
+
+			handler := s.app.requireAuthentication(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				s.app.GetUserReservationCalendar(w, r, tt.reservationId)
+			}))
+			handler = s.app.sessionManager.LoadAndSave(handler)
+			handler.ServeHTTP(w, r)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantStatus != http.StatusOK {
+				checkErrorResponse(s.T(), w, struct {
+					wantStatus     int
+					wantErrMessage string
+				}{
+					wantStatus:     tt.wantStatus,
+					wantErrMessage: tt.wantErrMessage,
+				})
+				return
+			}
+
+			s.Equal(icsContentType, w.Header().Get("Content-Type"))
+			s.Equal(`attachment; filename="reservation-7.ics"`, w.Header().Get("Content-Disposition"))
+
+			// unfold continuation lines before looking for properties
+			body := strings.ReplaceAll(w.Body.String(), "\r\n ", "")
+			lines := strings.Split(strings.TrimSuffix(body, "\r\n"), "\r\n")
+			for _, want := range tt.wantLines {
+				s.Contains(lines, want)
+			}
+			s.Contains(body, `Seats: Row 1 Seat 1 (standard)\, Row 1 Seat 2 (vip)`)
+		})
+	}
+}
+
+func TestWriteICSLineFoldsLongLines(t *testing.T) {
+	var buf bytes.Buffer
+	line := "DESCRIPTION:" + strings.Repeat("ğ", 80)
+
+	writeICSLine(&buf, line)
+
+	folded := strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n")
+	if len(folded) < 2 {
+		t.Fatalf("expected line to be folded, got %q", buf.String())
+	}
+
+	for i, l := range folded {
+		if len(l) > icsMaxLineOctets {
+			t.Errorf("line %d is %d octets long, want at most %d", i, len(l), icsMaxLineOctets)
+		}
+		if !utf8.ValidString(strings.TrimPrefix(l, " ")) {
+			t.Errorf("line %d splits a multi-byte character: %q", i, l)
+		}
+		if i > 0 && !strings.HasPrefix(l, " ") {
+			t.Errorf("continuation line %d does not start with a space: %q", i, l)
+		}
+	}
+
+	unfolded := strings.ReplaceAll(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n ", "")
+	if unfolded != line {
+		t.Errorf("unfolded line = %q, want %q", unfolded, line)
+	}
+}
+
+func (s *ReservationsTestSuite) TestGetShowtimeCheckInList() {
+	revokedAt := time.Date(2025, time.April, 1, 10, 0, 0, 0, time.UTC)
+
+	s.reservationRepo.On("GetCheckInListByShowtime", mock.Anything, 5).Return([]domain.CheckInEntry{
+		{
+			ReservationID:   1,
+			Status:          domain.ReservationConfirmed,
+			GuestFirstName:  "Ada",
+			GuestLastName:   "Lovelace",
+			Seats:           []domain.ReservationDetailSeat{{Row: 2, Col: 3, Type: "Accessible"}},
+			Note:            "Arriving with a guide dog",
+			SpecialRequests: []domain.SpecialRequest{domain.SpecialRequestWheelchairAssistance},
+		},
+		{
+			ReservationID:    2,
+			Status:           domain.ReservationPendingPaymentAtVenue,
+			GuestFirstName:   "Alan",
+			GuestLastName:    "Turing",
+			Seats:            []domain.ReservationDetailSeat{{Row: 4, Col: 1, Type: "Standard"}},
+			TicketsRevokedAt: &revokedAt,
+		},
+	}, nil)
+
+	w, r := executeRequest(s.T(), http.MethodGet, "/admin/showtimes/5/check-in-list", nil)
+	s.app.GetShowtimeCheckInList(w, r, 5)
+
+	s.Equal(http.StatusOK, w.Code)
+
+	var resp api.CheckInListResponse
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&resp))
+
+	want := api.CheckInListResponse{
+		ShowtimeId: 5,
+		Reservations: []api.CheckInEntry{
+			{
+				ReservationId:   1,
+				Status:          api.CheckInEntryStatusConfirmed,
+				GuestName:       "Ada Lovelace",
+				Seats:           []api.ReservationSeat{{Row: 2, Column: 3, Type: "Accessible"}},
+				Note:            ptr("Arriving with a guide dog"),
+				SpecialRequests: []api.SpecialRequest{api.WheelchairAssistance},
+			},
+			{
+				ReservationId:   2,
+				Status:          api.CheckInEntryStatusPendingPaymentAtVenue,
+				GuestName:       "Alan Turing",
+				Seats:           []api.ReservationSeat{{Row: 4, Column: 1, Type: "Standard"}},
+				SpecialRequests: []api.SpecialRequest{},
+				TicketsRevoked:  true,
+			},
+		},
+	}
+
+	s.Empty(cmp.Diff(want, resp))
+}
+
+func (s *ReservationsTestSuite) TestLookupShowtimeReservations() {
+	tests := []struct {
+		name           string
+		showtimeID     int
+		input          any
+		setupMocks     func()
+		wantStatus     int
+		wantErrMessage string
+		wantResponse   *api.ReservationLookupResponse
+	}{
+		{
+			name:           "should fail when showtime ID is zero or negative",
+			showtimeID:     0,
+			input:          api.ReservationLookupRequest{Emails: []string{"ada@example.com"}},
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "showtime ID must be greater than zero",
+		},
+		{
+			name:           "should fail when an email is invalid",
+			showtimeID:     5,
+			input:          api.ReservationLookupRequest{Emails: []string{"ada@example.com", "not-an-email"}},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: "must be a valid email address",
+		},
+		{
+			name:           "should fail when no email is given",
+			showtimeID:     5,
+			input:          api.ReservationLookupRequest{Emails: []string{}},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: "must contain at least 1 items",
+		},
+		{
+			name:       "should fail when the showtime doesn't exist",
+			showtimeID: 999,
+			input:      api.ReservationLookupRequest{Emails: []string{"ada@example.com"}},
+			setupMocks: func() {
+				s.reservationRepo.On("LookupByEmails", mock.Anything, 999, []string{"ada@example.com"}).
+					Return(nil, domain.ErrRecordNotFound)
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+		},
+		{
+			name:       "should report the seats of every email once",
+			showtimeID: 5,
+			input: api.ReservationLookupRequest{
+				Emails: []string{"ada@example.com", "alan@example.com", "ADA@example.com", "grace@example.com"},
+			},
+			setupMocks: func() {
+				s.reservationRepo.On("LookupByEmails", mock.Anything, 5, []string{"ada@example.com", "alan@example.com", "grace@example.com"}).
+					Return([]domain.ReservationLookup{
+						{
+							Email: "ada@example.com",
+							Reservations: []domain.LookedUpReservation{
+								{ReservationID: 1, Status: domain.ReservationCancelled, Seats: []domain.ReservationDetailSeat{{Row: 1, Col: 1, Type: "Standard"}}},
+								{ReservationID: 3, Status: domain.ReservationConfirmed, Seats: []domain.ReservationDetailSeat{{Row: 2, Col: 1, Type: "Standard"}, {Row: 2, Col: 2, Type: "Standard"}}},
+							},
+						},
+						{
+							Email: "alan@example.com",
+							Reservations: []domain.LookedUpReservation{
+								{ReservationID: 2, Status: domain.ReservationCancelled, Seats: []domain.ReservationDetailSeat{}},
+							},
+						},
+						{
+							Email:        "grace@example.com",
+							Reservations: []domain.LookedUpReservation{},
+						},
+					}, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: &api.ReservationLookupResponse{
+				ShowtimeId: 5,
+				Results: []api.ReservationLookupResult{
+					{
+						Email:     "ada@example.com",
+						Status:    api.Seated,
+						SeatCount: 2,
+						Reservations: []api.LookedUpReservation{
+							{ReservationId: 1, Status: "cancelled", Seats: []api.ReservationSeat{{Row: 1, Column: 1, Type: "Standard"}}},
+							{ReservationId: 3, Status: "confirmed", Seats: []api.ReservationSeat{{Row: 2, Column: 1, Type: "Standard"}, {Row: 2, Column: 2, Type: "Standard"}}},
+						},
+					},
+					{
+						Email:  "alan@example.com",
+						Status: api.AllCancelled,
+						Reservations: []api.LookedUpReservation{
+							{ReservationId: 2, Status: "cancelled", Seats: []api.ReservationSeat{}},
+						},
+					},
+					{
+						Email:        "grace@example.com",
+						Status:       api.NoReservation,
+						Reservations: []api.LookedUpReservation{},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			w, r := executeRequest(s.T(), http.MethodPost, fmt.Sprintf("/admin/showtimes/%d/reservations/lookup", tt.showtimeID), tt.input)
+			s.app.LookupShowtimeReservations(w, r, tt.showtimeID)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantResponse != nil {
+				var resp api.ReservationLookupResponse
+				s.Require().NoError(json.NewDecoder(w.Body).Decode(&resp))
+
+				s.Empty(cmp.Diff(*tt.wantResponse, resp))
+			}
+
+			checkErrorResponse(s.T(), w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+
+			s.reservationRepo.AssertExpectations(s.T())
+		})
+	}
+}
+
+func TestSanitizeNote(t *testing.T) {
+	tests := []struct {
+		note string
+		want string
+	}{
+		{"  Booster seat please  ", "Booster seat please"},
+		{"Line one\r\nLine two", "Line one\nLine two"},
+		{"Bell\u0007 and tab\t", "Bell and tab"},
+		{"abc\u202edef", "abcdef"},
+		{"Zero\u200bwidth", "Zerowidth"},
+		{"Çocuk koltuğu", "Çocuk koltuğu"},
+	}
+
+	for _, tt := range tests {
+		if got := sanitizeNote(tt.note); got != tt.want {
+			t.Errorf("sanitizeNote(%q) = %q, want %q", tt.note, got, tt.want)
+		}
+	}
+}
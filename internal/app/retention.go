@@ -0,0 +1,127 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/scheduler"
+)
+
+func (app *Application) RunDataRetention(w http.ResponseWriter, r *http.Request) {
+	logger := app.contextGetLogger(r)
+
+	var input api.RetentionRunRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	report, err := app.applyRetentionPolicies(r.Context(), input.DryRun)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !input.DryRun {
+		logger.Info("data retention applied on demand", "results", report.Results)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, toApiRetentionReport(report), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// enforceDataRetention is the background job which applies the retention policies periodically.
+func (app *Application) enforceDataRetention(ctx context.Context) error {
+	report, err := app.applyRetentionPolicies(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	for _, result := range report.Results {
+		if result.Affected > 0 {
+			app.logger.Info("applied data retention policy",
+				"policy", result.Policy,
+				"cutoff", result.Cutoff,
+				"affected", result.Affected)
+		}
+	}
+
+	return nil
+}
+
+// applyRetentionPolicies purges expired tokens and delivered lifecycle events and anonymizes old payments. Policies are applied one
+// after another, so a failing policy leaves the effects of the previous ones in place.
+func (app *Application) applyRetentionPolicies(ctx context.Context, dryRun bool) (*domain.RetentionReport, error) {
+	now := time.Now()
+
+	report := &domain.RetentionReport{
+		DryRun:    dryRun,
+		StartedAt: now,
+	}
+
+	policies := []struct {
+		policy domain.RetentionPolicy
+		cutoff time.Time
+		apply  func(ctx context.Context, cutoff time.Time, dryRun bool) (int64, error)
+	}{
+		{
+			policy: domain.RetentionExpiredTokens,
+			cutoff: now.Add(-app.config.Retention.ExpiredTokenGracePeriod),
+			apply:  app.tokenRepo.DeleteExpired,
+		},
+		{
+			policy: domain.RetentionPaymentAnonymization,
+			cutoff: now.Add(-app.config.Retention.PaymentRetention),
+			apply:  app.paymentRepo.AnonymizeCreatedBefore,
+		},
+		{
+			policy: domain.RetentionLifecycleEvents,
+			cutoff: now.Add(-app.config.Retention.LifecycleEventRetention),
+			apply:  app.lifecycleEventRepo.DeleteDeliveredBefore,
+		},
+	}
+
+	for _, p := range policies {
+		if err := scheduler.CheckFence(ctx); err != nil {
+			return nil, err
+		}
+
+		affected, err := p.apply(ctx, p.cutoff, dryRun)
+		if err != nil {
+			return nil, err
+		}
+
+		report.Results = append(report.Results, domain.RetentionResult{
+			Policy:   p.policy,
+			Cutoff:   p.cutoff,
+			Affected: affected,
+		})
+	}
+
+	return report, nil
+}
+
+func toApiRetentionReport(report *domain.RetentionReport) api.RetentionReport {
+	resp := api.RetentionReport{
+		DryRun:    report.DryRun,
+		StartedAt: report.StartedAt,
+		Results:   make([]api.RetentionResult, len(report.Results)),
+	}
+
+	for i, result := range report.Results {
+		resp.Results[i] = api.RetentionResult{
+			Policy:       api.RetentionResultPolicy(result.Policy),
+			Cutoff:       result.Cutoff,
+			AffectedRows: result.Affected,
+		}
+	}
+
+	return resp
+}
@@ -0,0 +1,162 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRunDataRetention(t *testing.T) {
+	tests := []struct {
+		name                string
+		input               any
+		tokenErr            error
+		setupPaymentRepo    func(*mocks.MockPaymentRepo)
+		setupEventRepo      func(*mocks.MockLifecycleEventRepo)
+		wantStatus          int
+		wantErrMessage      string
+		wantDryRun          bool
+		wantAffectedByRule  map[api.RetentionResultPolicy]int64
+		wantTokenRepoCalled bool
+	}{
+		{
+			name:           "should fail when body is malformed",
+			input:          "not an object",
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "body contains incorrect JSON type (at character 15)",
+		},
+		{
+			name:                "should fail when purging tokens fails",
+			input:               api.RetentionRunRequest{DryRun: false},
+			tokenErr:            errors.New("database error"),
+			wantStatus:          http.StatusInternalServerError,
+			wantErrMessage:      ErrInternalServer,
+			wantTokenRepoCalled: true,
+		},
+		{
+			name:  "should report affected rows in dry-run mode",
+			input: api.RetentionRunRequest{DryRun: true},
+			setupPaymentRepo: func(m *mocks.MockPaymentRepo) {
+				m.On("AnonymizeCreatedBefore", mock.Anything, mock.Anything, true).Return(int64(3), nil)
+			},
+			setupEventRepo: func(m *mocks.MockLifecycleEventRepo) {
+				m.On("DeleteDeliveredBefore", mock.Anything, mock.Anything, true).Return(int64(5), nil)
+			},
+			wantStatus: http.StatusOK,
+			wantDryRun: true,
+			wantAffectedByRule: map[api.RetentionResultPolicy]int64{
+				api.ExpiredTokens:        7,
+				api.PaymentAnonymization: 3,
+				api.LifecycleEvents:      5,
+			},
+			wantTokenRepoCalled: true,
+		},
+		{
+			name:  "should apply policies",
+			input: api.RetentionRunRequest{DryRun: false},
+			setupPaymentRepo: func(m *mocks.MockPaymentRepo) {
+				m.On("AnonymizeCreatedBefore", mock.Anything, mock.Anything, false).Return(int64(0), nil)
+			},
+			setupEventRepo: func(m *mocks.MockLifecycleEventRepo) {
+				m.On("DeleteDeliveredBefore", mock.Anything, mock.MatchedBy(func(cutoff time.Time) bool {
+					return cutoff.Before(time.Now().Add(-47 * time.Hour))
+				}), false).Return(int64(2), nil)
+			},
+			wantStatus: http.StatusOK,
+			wantAffectedByRule: map[api.RetentionResultPolicy]int64{
+				api.ExpiredTokens:        7,
+				api.PaymentAnonymization: 0,
+				api.LifecycleEvents:      2,
+			},
+			wantTokenRepoCalled: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenRepoCalled := false
+			paymentRepo := new(mocks.MockPaymentRepo)
+
+			if tt.setupPaymentRepo != nil {
+				tt.setupPaymentRepo(paymentRepo)
+			}
+
+			eventRepo := new(mocks.MockLifecycleEventRepo)
+			if tt.setupEventRepo != nil {
+				tt.setupEventRepo(eventRepo)
+			}
+
+			app := newTestApplication(func(a *Application) {
+				a.config.Retention = RetentionConfig{
+					ExpiredTokenGracePeriod: time.Hour,
+					PaymentRetention:        24 * time.Hour,
+					LifecycleEventRetention: 48 * time.Hour,
+				}
+				a.tokenRepo = &mocks.MockTokenRepo{
+					DeleteExpiredFunc: func(ctx context.Context, expiredBefore time.Time, dryRun bool) (int64, error) {
+						tokenRepoCalled = true
+
+						if !expiredBefore.Before(time.Now().Add(-59 * time.Minute)) {
+							t.Errorf("unexpected token cutoff %v", expiredBefore)
+						}
+
+						return 7, tt.tokenErr
+					},
+				}
+				a.paymentRepo = paymentRepo
+				a.lifecycleEventRepo = eventRepo
+			})
+
+			w, r := executeRequest(t, http.MethodPost, "/admin/retention/runs", tt.input)
+
+			app.RunDataRetention(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("RunDataRetention() status = %v, want %v", w.Code, tt.wantStatus)
+			}
+
+			if tokenRepoCalled != tt.wantTokenRepoCalled {
+				t.Errorf("token repository called = %v, want %v", tokenRepoCalled, tt.wantTokenRepoCalled)
+			}
+
+			if tt.wantStatus == http.StatusOK {
+				var response api.RetentionReport
+				if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+
+				if response.DryRun != tt.wantDryRun {
+					t.Errorf("DryRun = %v, want %v", response.DryRun, tt.wantDryRun)
+				}
+
+				if len(response.Results) != len(tt.wantAffectedByRule) {
+					t.Fatalf("got %d results, want %d", len(response.Results), len(tt.wantAffectedByRule))
+				}
+
+				for _, result := range response.Results {
+					if want := tt.wantAffectedByRule[result.Policy]; result.AffectedRows != want {
+						t.Errorf("%s affected rows = %d, want %d", result.Policy, result.AffectedRows, want)
+					}
+				}
+			}
+
+			checkErrorResponse(t, w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+
+			paymentRepo.AssertExpectations(t)
+			eventRepo.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+// accessPolicy decides who may call a route. It is enforced by its middleware, which runs before the
+// handler of the route.
+type accessPolicy struct {
+	name       string
+	middleware []func(http.Handler) http.Handler
+}
+
+// publicAccess lets anyone call the route, guests included. Routes authorizing requests on their own,
+// e.g. by the signature of a webhook or a token in the body, are public as far as the router is concerned.
+func (app *Application) publicAccess() accessPolicy {
+	return accessPolicy{name: "public"}
+}
+
+func (app *Application) authenticatedAccess() accessPolicy {
+	return accessPolicy{
+		name:       "authenticated",
+		middleware: []func(http.Handler) http.Handler{app.requireAuthentication},
+	}
+}
+
+// recentlyAuthenticatedAccess guards sensitive actions, the user must have entered their password within
+// the reauthentication window.
+func (app *Application) recentlyAuthenticatedAccess() accessPolicy {
+	return accessPolicy{
+		name:       "recently authenticated",
+		middleware: []func(http.Handler) http.Handler{app.requireAuthentication, app.requireRecentAuthentication},
+	}
+}
+
+func (app *Application) roleAccess(role domain.Role) accessPolicy {
+	return accessPolicy{
+		name:       "role " + string(role),
+		middleware: []func(http.Handler) http.Handler{app.requireAuthentication, app.requireRole(role)},
+	}
+}
+
+// partnerAccess authenticates ticket partners by their API key.
+func (app *Application) partnerAccess() accessPolicy {
+	return accessPolicy{
+		name:       "partner",
+		middleware: []func(http.Handler) http.Handler{app.requirePartnerKey},
+	}
+}
+
+// policyRouter registers routes along with their access policy. The routes of the API are registered
+// through it only, so a route can't be added without deciding who may call it. The policies are
+// recorded by method and pattern, which lets tests check the route tree against them.
+type policyRouter struct {
+	router   chi.Router
+	prefix   string
+	policies map[string]accessPolicy
+}
+
+func newPolicyRouter(router chi.Router) *policyRouter {
+	return &policyRouter{
+		router:   router,
+		policies: make(map[string]accessPolicy),
+	}
+}
+
+func (pr *policyRouter) Get(pattern string, policy accessPolicy, handler http.HandlerFunc) {
+	pr.handle(http.MethodGet, pattern, policy, handler)
+}
+
+func (pr *policyRouter) Post(pattern string, policy accessPolicy, handler http.HandlerFunc) {
+	pr.handle(http.MethodPost, pattern, policy, handler)
+}
+
+func (pr *policyRouter) Put(pattern string, policy accessPolicy, handler http.HandlerFunc) {
+	pr.handle(http.MethodPut, pattern, policy, handler)
+}
+
+func (pr *policyRouter) Patch(pattern string, policy accessPolicy, handler http.HandlerFunc) {
+	pr.handle(http.MethodPatch, pattern, policy, handler)
+}
+
+func (pr *policyRouter) Delete(pattern string, policy accessPolicy, handler http.HandlerFunc) {
+	pr.handle(http.MethodDelete, pattern, policy, handler)
+}
+
+// Route groups routes under a common prefix. It doesn't apply a policy, every route of the group
+// declares its own.
+func (pr *policyRouter) Route(pattern string, fn func(r *policyRouter)) {
+	pr.router.Route(pattern, func(r chi.Router) {
+		fn(&policyRouter{
+			router:   r,
+			prefix:   pr.prefix + pattern,
+			policies: pr.policies,
+		})
+	})
+}
+
+func (pr *policyRouter) handle(method, pattern string, policy accessPolicy, handler http.HandlerFunc) {
+	if policy.name == "" {
+		panic("no access policy for " + method + " " + pr.prefix + pattern)
+	}
+
+	pr.policies[routeKey(method, pr.prefix+pattern)] = policy
+	pr.router.With(policy.middleware...).Method(method, pattern, handler)
+}
+
+func routeKey(method, pattern string) string {
+	return method + " " + pattern
+}
@@ -0,0 +1,200 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+)
+
+type route struct {
+	method  string
+	pattern string
+}
+
+func walkRoutes(t *testing.T, router chi.Router) []route {
+	var routes []route
+
+	err := chi.Walk(router, func(method, pattern string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		routes = append(routes, route{method: method, pattern: pattern})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return routes
+}
+
+var pathParam = regexp.MustCompile(`\{[^}]+\}`)
+
+// normalizeRoute makes the routes of the API comparable with the paths of the OpenAPI spec, whose
+// parameters are named differently at times.
+func normalizeRoute(method, pattern string) string {
+	pattern = pathParam.ReplaceAllString(pattern, "{}")
+	if pattern != "/" {
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+
+	return strings.ToUpper(method) + " " + pattern
+}
+
+func TestEveryRouteHasAccessPolicy(t *testing.T) {
+	routes := newTestApplication().routes()
+
+	for _, rt := range walkRoutes(t, routes.router) {
+		if _, ok := routes.policies[routeKey(rt.method, rt.pattern)]; !ok {
+			t.Errorf("%s %s is registered without an access policy", rt.method, rt.pattern)
+		}
+	}
+}
+
+func TestEveryOperationIsRouted(t *testing.T) {
+	swagger, err := api.GetSwagger()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routed := make(map[string]bool)
+	for _, rt := range walkRoutes(t, newTestApplication().routes().router) {
+		routed[normalizeRoute(rt.method, rt.pattern)] = true
+	}
+
+	for path, item := range swagger.Paths.Map() {
+		for method, op := range item.Operations() {
+			if !routed[normalizeRoute(method, path)] {
+				t.Errorf("operation %s (%s %s) is not routed", op.OperationID, method, path)
+			}
+		}
+	}
+}
+
+// TestAccessPolicyMatrix pins the policies of the routes by their prefix, a route deviating from the rule
+// of its prefix has to be listed as an exception.
+func TestAccessPolicyMatrix(t *testing.T) {
+	app := newTestApplication()
+
+	rules := []struct {
+		prefix   string
+		policies []accessPolicy
+	}{
+		{prefix: "/admin/", policies: []accessPolicy{app.roleAccess(domain.RoleAdmin)}},
+		{prefix: "/users/me", policies: []accessPolicy{app.authenticatedAccess(), app.recentlyAuthenticatedAccess()}},
+		{prefix: "/partner/", policies: []accessPolicy{app.partnerAccess()}},
+		{prefix: "/checkout/", policies: []accessPolicy{app.authenticatedAccess()}},
+	}
+
+	// access of staff is checked against the theater of the showtime by the handlers
+	exceptions := map[string]accessPolicy{
+		"GET /admin/showtimes/{showtimeId}/manifest":        app.authenticatedAccess(),
+		"POST /admin/showtimes/{showtimeId}/phone-bookings": app.authenticatedAccess(),
+		"POST /admin/reservations":                          app.authenticatedAccess(),
+		"POST /admin/check-ins":                             app.authenticatedAccess(),
+		// the calendar feed is authenticated by the token of its URL
+		"GET /users/me/reservations/feed.ics": app.publicAccess(),
+	}
+
+	routes := app.routes()
+
+	for key, policy := range routes.policies {
+		if want, ok := exceptions[key]; ok {
+			if policy.name != want.name {
+				t.Errorf("%s has access policy %q, want %q", key, policy.name, want.name)
+			}
+			continue
+		}
+
+		_, pattern, _ := strings.Cut(key, " ")
+
+		for _, rule := range rules {
+			if !strings.HasPrefix(pattern, rule.prefix) {
+				continue
+			}
+
+			var names []string
+			for _, p := range rule.policies {
+				names = append(names, p.name)
+			}
+
+			if !slices.Contains(names, policy.name) {
+				t.Errorf("%s has access policy %q, want one of %q", key, policy.name, names)
+			}
+		}
+	}
+}
+
+func TestAccessPoliciesAreEnforced(t *testing.T) {
+	app := newTestApplication(func(a *Application) {
+		a.sessionManager = scs.New()
+		a.userRepo = &mocks.MockUserRepo{
+			GetByIdFunc: func(ctx context.Context, id int) (*domain.User, error) {
+				return &domain.User{ID: id, Role: domain.RoleUser}, nil
+			},
+		}
+		a.config.Session.ReauthWindow = 15 * time.Minute
+	})
+
+	ctx, err := app.sessionManager.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the user logged in long ago, before the reauthentication window
+	app.sessionManager.Put(ctx, SessionKeyUserId.String(), 1)
+	app.sessionManager.Put(ctx, SessionKeyAuthenticatedAt.String(), int64(1))
+
+	userSession, _, err := app.sessionManager.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routes := app.routes()
+
+	for key, policy := range routes.policies {
+		method, pattern, _ := strings.Cut(key, " ")
+		path := pathParam.ReplaceAllString(pattern, "1")
+
+		t.Run(key, func(t *testing.T) {
+			if policy.name == app.publicAccess().name {
+				t.Skip("public route")
+			}
+
+			w := httptest.NewRecorder()
+			routes.router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("status code without credentials = %d, want %d", w.Code, http.StatusUnauthorized)
+			}
+
+			if policy.name == app.authenticatedAccess().name || policy.name == app.partnerAccess().name {
+				return
+			}
+
+			// a user without the role, or without a recent login
+			r := httptest.NewRequest(method, path, nil)
+			r.AddCookie(&http.Cookie{Name: app.sessionManager.Cookie.Name, Value: userSession})
+
+			w = httptest.NewRecorder()
+			routes.router.ServeHTTP(w, r)
+
+			wantStatus := http.StatusForbidden
+			if policy.name == app.recentlyAuthenticatedAccess().name {
+				wantStatus = http.StatusUnauthorized
+			}
+
+			if w.Code != wantStatus {
+				t.Errorf("status code of a regular user = %d, want %d", w.Code, wantStatus)
+			}
+		})
+	}
+}
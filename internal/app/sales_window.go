@@ -0,0 +1,53 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+func (app *Application) UpdateShowtimeSalesWindow(w http.ResponseWriter, r *http.Request, showtimeID int) {
+	logger := app.contextGetLogger(r)
+
+	if showtimeID < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("showtime ID must be greater than zero"))
+		return
+	}
+
+	var input api.UpdateShowtimeSalesWindowRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	window := domain.SalesWindow{
+		OpenAt:  input.SalesOpenAt,
+		CloseAt: input.SalesCloseAt,
+	}
+
+	err = app.theaterRepo.UpdateSalesWindow(r.Context(), showtimeID, window)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, domain.ErrInvalidSalesWindow):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	logger.Info("showtime sales window changed",
+		"showtime_id", showtimeID,
+		"sales_open_at", input.SalesOpenAt,
+		"sales_close_at", input.SalesCloseAt)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,138 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+)
+
+func TestUpdateShowtimeSalesWindow(t *testing.T) {
+	openAt := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	closeAt := time.Date(2025, 6, 8, 18, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		showtimeID     int
+		input          map[string]any
+		wantWindow     domain.SalesWindow
+		updateErr      error
+		wantStatus     int
+		wantErrMessage string
+	}{
+		{
+			name:           "invalid showtime ID",
+			showtimeID:     0,
+			input:          map[string]any{},
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "showtime ID must be greater than zero",
+		},
+		{
+			name:           "showtime not found",
+			showtimeID:     99,
+			input:          map[string]any{"salesOpenAt": openAt},
+			wantWindow:     domain.SalesWindow{OpenAt: &openAt},
+			updateErr:      domain.ErrRecordNotFound,
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+		},
+		{
+			name:           "invalid window",
+			showtimeID:     1,
+			input:          map[string]any{"salesOpenAt": closeAt, "salesCloseAt": openAt},
+			wantWindow:     domain.SalesWindow{OpenAt: &closeAt, CloseAt: &openAt},
+			updateErr:      domain.ErrInvalidSalesWindow,
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: domain.ErrInvalidSalesWindow.Error(),
+		},
+		{
+			name:       "window changed",
+			showtimeID: 1,
+			input:      map[string]any{"salesOpenAt": openAt, "salesCloseAt": closeAt},
+			wantWindow: domain.SalesWindow{OpenAt: &openAt, CloseAt: &closeAt},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "window reset to the defaults",
+			showtimeID: 1,
+			input:      map[string]any{},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:           "database error",
+			showtimeID:     1,
+			input:          map[string]any{},
+			updateErr:      errors.New("db error"),
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(func(a *Application) {
+				a.theaterRepo = &mocks.MockTheaterRepo{
+					UpdateSalesWindowFunc: func(ctx context.Context, showtimeID int, window domain.SalesWindow) error {
+						if showtimeID != tt.showtimeID || !equalTimes(window.OpenAt, tt.wantWindow.OpenAt) ||
+							!equalTimes(window.CloseAt, tt.wantWindow.CloseAt) {
+							t.Errorf("unexpected update of showtime %d to %+v", showtimeID, window)
+						}
+
+						return tt.updateErr
+					},
+				}
+			})
+
+			w, r := executeRequest(t, http.MethodPut, "/admin/showtimes/1/sales-window", tt.input)
+			app.UpdateShowtimeSalesWindow(w, r, tt.showtimeID)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+
+			checkErrorResponse(t, w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{tt.wantStatus, tt.wantErrMessage})
+		})
+	}
+}
+
+func TestSalesWindowCheck(t *testing.T) {
+	now := time.Date(2025, 6, 5, 12, 0, 0, 0, time.UTC)
+	before := now.Add(-time.Hour)
+	after := now.Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		window  domain.SalesWindow
+		wantErr error
+	}{
+		{name: "default window", window: domain.SalesWindow{}},
+		{name: "open", window: domain.SalesWindow{OpenAt: &before, CloseAt: &after}},
+		{name: "not open yet", window: domain.SalesWindow{OpenAt: &after}, wantErr: domain.ErrSalesNotOpen},
+		{name: "closed", window: domain.SalesWindow{CloseAt: &before}, wantErr: domain.ErrSalesClosed},
+		{name: "closes now", window: domain.SalesWindow{CloseAt: &now}, wantErr: domain.ErrSalesClosed},
+		{name: "opens now", window: domain.SalesWindow{OpenAt: &now}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.window.Check(now); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Check() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func equalTimes(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Equal(*b)
+}
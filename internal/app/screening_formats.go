@@ -0,0 +1,123 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/shopspring/decimal"
+)
+
+// screening_formats.surcharge is numeric(6,2)
+var maxFormatSurcharge = decimal.RequireFromString("9999.99")
+
+func (app *Application) UpdateShowtimeFormat(w http.ResponseWriter, r *http.Request, showtimeID int) {
+	logger := app.contextGetLogger(r)
+
+	if showtimeID < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("showtime ID must be greater than zero"))
+		return
+	}
+
+	var input api.UpdateShowtimeFormatRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	err = app.theaterRepo.UpdateShowtimeFormat(r.Context(), showtimeID, domain.ScreeningFormat(input.Format))
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	logger.Info("showtime format changed", "showtime_id", showtimeID, "format", input.Format)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *Application) GetScreeningFormats(w http.ResponseWriter, r *http.Request) {
+	surcharges, err := app.theaterRepo.GetFormatSurcharges(r.Context())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	resp := api.ScreeningFormatsResponse{
+		Formats: make([]api.ScreeningFormatSurcharge, len(surcharges)),
+	}
+
+	for i, s := range surcharges {
+		resp.Formats[i] = toApiScreeningFormatSurcharge(s)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) UpdateScreeningFormat(w http.ResponseWriter, r *http.Request, format api.ScreeningFormat) {
+	logger := app.contextGetLogger(r)
+
+	var input api.UpdateScreeningFormatRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Surcharge.IsNegative() || input.Surcharge.GreaterThan(maxFormatSurcharge) {
+		app.badRequestResponse(w, r, fmt.Errorf("surcharge must be between 0 and %s", maxFormatSurcharge))
+		return
+	}
+
+	surcharge := domain.FormatSurcharge{
+		Format:    domain.ScreeningFormat(format),
+		Surcharge: input.Surcharge.Round(2),
+	}
+
+	err = app.theaterRepo.UpdateFormatSurcharge(r.Context(), &surcharge)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	logger.Info("screening format surcharge changed", "format", surcharge.Format, "surcharge", surcharge.Surcharge.String())
+
+	err = app.writeJSON(w, http.StatusOK, toApiScreeningFormatSurcharge(surcharge), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func toApiScreeningFormatSurcharge(s domain.FormatSurcharge) api.ScreeningFormatSurcharge {
+	return api.ScreeningFormatSurcharge{
+		Format:    api.ScreeningFormat(s.Format),
+		Surcharge: s.Surcharge,
+		UpdatedAt: s.UpdatedAt,
+	}
+}
@@ -0,0 +1,165 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/metinatakli/movie-reservation-system/internal/validator"
+	"github.com/shopspring/decimal"
+)
+
+func TestUpdateShowtimeFormat(t *testing.T) {
+	tests := []struct {
+		name           string
+		showtimeID     int
+		input          map[string]any
+		updateErr      error
+		wantStatus     int
+		wantErrMessage string
+	}{
+		{
+			name:           "invalid showtime ID",
+			showtimeID:     0,
+			input:          map[string]any{"format": "3D"},
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "showtime ID must be greater than zero",
+		},
+		{
+			name:           "unknown format",
+			showtimeID:     1,
+			input:          map[string]any{"format": "4DX"},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: fmt.Sprintf(validator.ErrOneOf, "2D 3D IMAX"),
+		},
+		{
+			name:           "showtime not found",
+			showtimeID:     99,
+			input:          map[string]any{"format": "3D"},
+			updateErr:      domain.ErrRecordNotFound,
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+		},
+		{
+			name:       "format changed",
+			showtimeID: 1,
+			input:      map[string]any{"format": "IMAX"},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:           "database error",
+			showtimeID:     1,
+			input:          map[string]any{"format": "3D"},
+			updateErr:      errors.New("db error"),
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(func(a *Application) {
+				a.theaterRepo = &mocks.MockTheaterRepo{
+					UpdateShowtimeFormatFunc: func(ctx context.Context, showtimeID int, format domain.ScreeningFormat) error {
+						if showtimeID != tt.showtimeID || string(format) != tt.input["format"] {
+							t.Errorf("unexpected update of showtime %d to %q", showtimeID, format)
+						}
+
+						return tt.updateErr
+					},
+				}
+			})
+
+			w, r := executeRequest(t, http.MethodPut, "/admin/showtimes/1/format", tt.input)
+			app.UpdateShowtimeFormat(w, r, tt.showtimeID)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+
+			checkErrorResponse(t, w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{tt.wantStatus, tt.wantErrMessage})
+		})
+	}
+}
+
+func TestUpdateScreeningFormat(t *testing.T) {
+	updatedAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		format         api.ScreeningFormat
+		input          map[string]any
+		updateErr      error
+		wantStatus     int
+		wantErrMessage string
+	}{
+		{
+			name:           "negative surcharge",
+			format:         api.N3D,
+			input:          map[string]any{"surcharge": "-1"},
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "surcharge must be between 0 and 9999.99",
+		},
+		{
+			name:           "unknown format",
+			format:         "4DX",
+			input:          map[string]any{"surcharge": "4.00"},
+			updateErr:      domain.ErrRecordNotFound,
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+		},
+		{
+			name:       "surcharge changed",
+			format:     api.N3D,
+			input:      map[string]any{"surcharge": "3.504"},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(func(a *Application) {
+				a.theaterRepo = &mocks.MockTheaterRepo{
+					UpdateFormatSurchargeFunc: func(ctx context.Context, s *domain.FormatSurcharge) error {
+						s.UpdatedAt = updatedAt
+						return tt.updateErr
+					},
+				}
+			})
+
+			w, r := executeRequest(t, http.MethodPut, "/admin/screening-formats/"+string(tt.format), tt.input)
+			app.UpdateScreeningFormat(w, r, tt.format)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+
+			checkErrorResponse(t, w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{tt.wantStatus, tt.wantErrMessage})
+
+			if tt.wantStatus == http.StatusOK {
+				var resp api.ScreeningFormatSurcharge
+				if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+					t.Fatal(err)
+				}
+
+				if resp.Format != tt.format || !resp.Surcharge.Equal(decimal.RequireFromString("3.5")) ||
+					!resp.UpdatedAt.Equal(updatedAt) {
+					t.Errorf("unexpected response %+v", resp)
+				}
+			}
+		})
+	}
+}
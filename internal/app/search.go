@@ -0,0 +1,114 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultSuggestionLimit = 5
+	suggestionCacheTTL     = 5 * time.Minute
+	// short prefixes are typed by almost every user and match the most rows, longer ones rarely repeat
+	maxCachedPrefixLength = 8
+)
+
+func (app *Application) GetSearchSuggestions(
+	w http.ResponseWriter,
+	r *http.Request,
+	params api.GetSearchSuggestionsParams) {
+
+	err := app.validator.Struct(params)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	limit := defaultSuggestionLimit
+	if params.Limit != nil {
+		limit = *params.Limit
+	}
+
+	prefix := strings.ToLower(strings.Join(strings.Fields(params.Q), " "))
+
+	suggestions, err := app.searchSuggestions(r.Context(), prefix, limit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	resp := api.SearchSuggestResponse{
+		Movies:   []api.SearchSuggestion{},
+		Theaters: []api.SearchSuggestion{},
+	}
+
+	for _, suggestion := range suggestions {
+		item := api.SearchSuggestion{Id: suggestion.ID, Label: suggestion.Label}
+
+		switch suggestion.Kind {
+		case domain.SuggestionMovie:
+			resp.Movies = append(resp.Movies, item)
+		case domain.SuggestionTheater:
+			resp.Theaters = append(resp.Theaters, item)
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// searchSuggestions serves short prefixes from the cache and falls back to the database. Cache
+// failures are only logged so that autocomplete keeps working without Redis.
+func (app *Application) searchSuggestions(ctx context.Context, prefix string, limit int) ([]domain.Suggestion, error) {
+	if utf8.RuneCountInString(prefix) > maxCachedPrefixLength {
+		return app.searchRepo.Suggest(ctx, prefix, limit)
+	}
+
+	key := searchSuggestionsKey(domain.TenantIDFromContext(ctx), prefix, limit)
+
+	cached, err := app.redis.Get(ctx, key).Bytes()
+	if err == nil {
+		var suggestions []domain.Suggestion
+
+		err = json.Unmarshal(cached, &suggestions)
+		if err == nil {
+			return suggestions, nil
+		}
+	}
+
+	if err != nil && !errors.Is(err, redis.Nil) {
+		app.logger.Warn("failed to read search suggestions from cache", "key", key, "error", err)
+	}
+
+	suggestions, err := app.searchRepo.Suggest(ctx, prefix, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestionBytes, err := json.Marshal(suggestions)
+	if err != nil {
+		return nil, err
+	}
+
+	err = app.redis.Set(ctx, key, suggestionBytes, suggestionCacheTTL).Err()
+	if err != nil {
+		app.logger.Warn("failed to cache search suggestions", "key", key, "error", err)
+	}
+
+	return suggestions, nil
+}
+
+func searchSuggestionsKey(tenantID int, prefix string, limit int) string {
+	return fmt.Sprintf("search_suggest:%d:%d:%s", tenantID, limit, prefix)
+}
@@ -0,0 +1,144 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type SearchTestSuite struct {
+	suite.Suite
+	app         *Application
+	searchRepo  *mocks.MockSearchRepo
+	redisClient *mocks.MockRedisClient
+}
+
+func (s *SearchTestSuite) SetupTest() {
+	s.searchRepo = new(mocks.MockSearchRepo)
+	s.redisClient = new(mocks.MockRedisClient)
+
+	s.app = newTestApplication(func(a *Application) {
+		a.searchRepo = s.searchRepo
+		a.redis = s.redisClient
+	})
+}
+
+func TestSearchSuite(t *testing.T) {
+	suite.Run(t, new(SearchTestSuite))
+}
+
+func (s *SearchTestSuite) TestGetSearchSuggestions() {
+	suggestions := []domain.Suggestion{
+		{Kind: domain.SuggestionMovie, ID: 1, Label: "Interstellar"},
+		{Kind: domain.SuggestionTheater, ID: 2, Label: "Intercity Cinema"},
+	}
+	cached, _ := json.Marshal(suggestions)
+
+	wantResponse := &api.SearchSuggestResponse{
+		Movies:   []api.SearchSuggestion{{Id: 1, Label: "Interstellar"}},
+		Theaters: []api.SearchSuggestion{{Id: 2, Label: "Intercity Cinema"}},
+	}
+
+	tests := []struct {
+		name           string
+		params         api.GetSearchSuggestionsParams
+		setupMocks     func()
+		wantStatus     int
+		wantErrMessage string
+		wantResponse   *api.SearchSuggestResponse
+	}{
+		{
+			name:           "should fail when prefix is too short",
+			params:         api.GetSearchSuggestionsParams{Q: "i"},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: "must be at least 2 characters long",
+		},
+		{
+			name:   "should serve hot prefix from cache",
+			params: api.GetSearchSuggestionsParams{Q: " Inter "},
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, "search_suggest:0:5:inter").Return(redis.NewStringResult(string(cached), nil))
+			},
+			wantStatus:   http.StatusOK,
+			wantResponse: wantResponse,
+		},
+		{
+			name:   "should query and cache hot prefix on cache miss",
+			params: api.GetSearchSuggestionsParams{Q: "Inter", Limit: ptr(3)},
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, "search_suggest:0:3:inter").Return(redis.NewStringResult("", redis.Nil))
+				s.searchRepo.On("Suggest", mock.Anything, "inter", 3).Return(suggestions, nil)
+				s.redisClient.On("Set", mock.Anything, "search_suggest:0:3:inter", mock.Anything, suggestionCacheTTL).
+					Return(redis.NewStatusResult("OK", nil))
+			},
+			wantStatus:   http.StatusOK,
+			wantResponse: wantResponse,
+		},
+		{
+			name:   "should not cache long prefixes",
+			params: api.GetSearchSuggestionsParams{Q: "Interstel"},
+			setupMocks: func() {
+				s.searchRepo.On("Suggest", mock.Anything, "interstel", 5).Return(suggestions[:1], nil)
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: &api.SearchSuggestResponse{
+				Movies:   []api.SearchSuggestion{{Id: 1, Label: "Interstellar"}},
+				Theaters: []api.SearchSuggestion{},
+			},
+		},
+		{
+			name:   "should fail when database error occurs",
+			params: api.GetSearchSuggestionsParams{Q: "Interstel"},
+			setupMocks: func() {
+				s.searchRepo.On("Suggest", mock.Anything, "interstel", 5).Return(nil, fmt.Errorf("database error"))
+			},
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			w, r := executeRequest(s.T(), http.MethodGet, "/search/suggest", nil)
+
+			s.app.GetSearchSuggestions(w, r, tt.params)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantResponse != nil {
+				var response api.SearchSuggestResponse
+				err := json.NewDecoder(w.Body).Decode(&response)
+				s.Require().NoError(err)
+
+				diff := cmp.Diff(tt.wantResponse, &response)
+				s.Empty(diff, "Response mismatch (-want +got):\n%s", diff)
+			}
+
+			checkErrorResponse(s.T(), w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+
+			s.searchRepo.AssertExpectations(s.T())
+			s.redisClient.AssertExpectations(s.T())
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+func (app *Application) CreateSeatBlocks(w http.ResponseWriter, r *http.Request) {
+	var input api.CreateSeatBlocksRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	createdBy := app.contextGetUserId(r)
+
+	var note string
+	if input.Note != nil {
+		note = *input.Note
+	}
+
+	blocks := make([]domain.SeatBlock, len(input.SeatIds))
+	for i, seatID := range input.SeatIds {
+		blocks[i] = domain.SeatBlock{
+			SeatID:     seatID,
+			ShowtimeID: input.ShowtimeId,
+			StartsAt:   input.StartsAt,
+			EndsAt:     input.EndsAt,
+			Reason:     domain.SeatBlockReason(input.Reason),
+			Note:       note,
+			CreatedBy:  &createdBy,
+		}
+	}
+
+	err = app.seatRepo.CreateSeatBlocks(r.Context(), blocks)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	app.contextGetLogger(r).Info("seats blocked",
+		"seat_ids", input.SeatIds,
+		"showtime_id", input.ShowtimeId,
+		"reason", input.Reason)
+
+	err = app.writeJSON(w, http.StatusCreated, toApiSeatBlocks(blocks), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) GetSeatBlocksByShowtime(w http.ResponseWriter, r *http.Request, showtimeId int) {
+	if showtimeId < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("showtime ID must be greater than zero"))
+		return
+	}
+
+	blocks, err := app.seatRepo.GetSeatBlocksByShowtime(r.Context(), showtimeId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, toApiSeatBlocks(blocks), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) DeleteSeatBlock(w http.ResponseWriter, r *http.Request, seatBlockId int) {
+	if seatBlockId < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("seat block ID must be greater than zero"))
+		return
+	}
+
+	err := app.seatRepo.DeleteSeatBlock(r.Context(), seatBlockId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func toApiSeatBlocks(blocks []domain.SeatBlock) api.SeatBlocksResponse {
+	resp := api.SeatBlocksResponse{
+		SeatBlocks: make([]api.SeatBlock, len(blocks)),
+	}
+
+	for i, block := range blocks {
+		resp.SeatBlocks[i] = api.SeatBlock{
+			Id:         block.ID,
+			SeatId:     block.SeatID,
+			ShowtimeId: block.ShowtimeID,
+			StartsAt:   block.StartsAt,
+			EndsAt:     block.EndsAt,
+			Reason:     api.SeatBlockReason(block.Reason),
+			Note:       block.Note,
+			CreatedBy:  block.CreatedBy,
+			CreatedAt:  block.CreatedAt,
+		}
+	}
+
+	return resp
+}
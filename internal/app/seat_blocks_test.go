@@ -0,0 +1,269 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type SeatBlocksTestSuite struct {
+	suite.Suite
+	app      *Application
+	seatRepo *mocks.MockSeatRepo
+}
+
+func (s *SeatBlocksTestSuite) SetupTest() {
+	s.seatRepo = new(mocks.MockSeatRepo)
+
+	s.app = newTestApplication(func(a *Application) {
+		a.seatRepo = s.seatRepo
+	})
+}
+
+func TestSeatBlocksSuite(t *testing.T) {
+	suite.Run(t, new(SeatBlocksTestSuite))
+}
+
+func (s *SeatBlocksTestSuite) TestCreateSeatBlocks() {
+	startsAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	endsAt := startsAt.Add(7 * 24 * time.Hour)
+
+	tests := []struct {
+		name           string
+		input          api.CreateSeatBlocksRequest
+		setupMocks     func()
+		wantStatus     int
+		wantErrMessage string
+	}{
+		{
+			name: "should fail without a showtime or a time range",
+			input: api.CreateSeatBlocksRequest{
+				SeatIds: []int{1},
+				Reason:  api.BROKEN,
+			},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: "is required when StartsAt is not provided",
+		},
+		{
+			name: "should fail when both a showtime and a time range are given",
+			input: api.CreateSeatBlocksRequest{
+				SeatIds:    []int{1},
+				ShowtimeId: ptr(1),
+				StartsAt:   &startsAt,
+				EndsAt:     &endsAt,
+				Reason:     api.BROKEN,
+			},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: "must be empty when StartsAt is provided",
+		},
+		{
+			name: "should fail when the time range ends before it starts",
+			input: api.CreateSeatBlocksRequest{
+				SeatIds:  []int{1},
+				StartsAt: &endsAt,
+				EndsAt:   &startsAt,
+				Reason:   api.BROKEN,
+			},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: "must be greater than StartsAt",
+		},
+		{
+			name: "should fail with duplicate seats",
+			input: api.CreateSeatBlocksRequest{
+				SeatIds:    []int{1, 1},
+				ShowtimeId: ptr(1),
+				Reason:     api.HOUSE,
+			},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: "must not contain duplicates",
+		},
+		{
+			name: "should fail with an unknown reason",
+			input: api.CreateSeatBlocksRequest{
+				SeatIds:    []int{1},
+				ShowtimeId: ptr(1),
+				Reason:     "VIP",
+			},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: "must be one of BROKEN HOUSE",
+		},
+		{
+			name: "should fail when a seat is not in the hall of the showtime",
+			input: api.CreateSeatBlocksRequest{
+				SeatIds:    []int{1, 99},
+				ShowtimeId: ptr(1),
+				Reason:     api.HOUSE,
+			},
+			setupMocks: func() {
+				s.seatRepo.On("CreateSeatBlocks", mock.Anything, mock.Anything).Return(domain.ErrRecordNotFound)
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+		},
+		{
+			name: "should fail when database error occurs",
+			input: api.CreateSeatBlocksRequest{
+				SeatIds:    []int{1},
+				ShowtimeId: ptr(1),
+				Reason:     api.HOUSE,
+			},
+			setupMocks: func() {
+				s.seatRepo.On("CreateSeatBlocks", mock.Anything, mock.Anything).Return(errors.New("database error"))
+			},
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+		{
+			name: "should block seats for a time range",
+			input: api.CreateSeatBlocksRequest{
+				SeatIds:  []int{4, 5},
+				StartsAt: &startsAt,
+				EndsAt:   &endsAt,
+				Reason:   api.BROKEN,
+				Note:     ptr("recliner motor is broken"),
+			},
+			setupMocks: func() {
+				s.seatRepo.On("CreateSeatBlocks", mock.Anything, mock.MatchedBy(func(blocks []domain.SeatBlock) bool {
+					return len(blocks) == 2 &&
+						blocks[0].SeatID == 4 &&
+						blocks[1].SeatID == 5 &&
+						blocks[0].ShowtimeID == nil &&
+						blocks[0].StartsAt.Equal(startsAt) &&
+						blocks[0].EndsAt.Equal(endsAt) &&
+						blocks[0].Reason == domain.SeatBlockBroken &&
+						blocks[0].Note == "recliner motor is broken" &&
+						*blocks[0].CreatedBy == 7
+				})).Run(func(args mock.Arguments) {
+					blocks := args.Get(1).([]domain.SeatBlock)
+					for i := range blocks {
+						blocks[i].ID = 10 + i
+					}
+				}).Return(nil)
+			},
+			wantStatus: http.StatusCreated,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			w, r := executeRequest(s.T(), http.MethodPost, "/admin/seat-blocks", tt.input)
+			r = r.WithContext(context.WithValue(r.Context(), SessionKeyUserId, 7))
+
+			s.app.CreateSeatBlocks(w, r)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantStatus == http.StatusCreated {
+				var response api.SeatBlocksResponse
+				s.Require().NoError(json.NewDecoder(w.Body).Decode(&response))
+
+				s.Require().Len(response.SeatBlocks, 2)
+				s.Equal(10, response.SeatBlocks[0].Id)
+				s.Equal(11, response.SeatBlocks[1].Id)
+				s.Equal(api.BROKEN, response.SeatBlocks[1].Reason)
+			}
+
+			checkErrorResponse(s.T(), w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+
+			s.seatRepo.AssertExpectations(s.T())
+		})
+	}
+}
+
+func (s *SeatBlocksTestSuite) TestGetSeatBlocksByShowtime() {
+	s.seatRepo.On("GetSeatBlocksByShowtime", mock.Anything, 1).Return([]domain.SeatBlock{
+		{ID: 1, SeatID: 3, ShowtimeID: ptr(1), Reason: domain.SeatBlockHouse, CreatedBy: ptr(7)},
+	}, nil)
+
+	w, r := executeRequest(s.T(), http.MethodGet, "/admin/showtimes/1/seat-blocks", nil)
+	s.app.GetSeatBlocksByShowtime(w, r, 1)
+
+	s.Equal(http.StatusOK, w.Code)
+
+	var response api.SeatBlocksResponse
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&response))
+
+	s.Require().Len(response.SeatBlocks, 1)
+	s.Equal(3, response.SeatBlocks[0].SeatId)
+	s.Equal(api.HOUSE, response.SeatBlocks[0].Reason)
+	s.seatRepo.AssertExpectations(s.T())
+}
+
+func (s *SeatBlocksTestSuite) TestDeleteSeatBlock() {
+	tests := []struct {
+		name           string
+		seatBlockId    int
+		setupMocks     func()
+		wantStatus     int
+		wantErrMessage string
+	}{
+		{
+			name:        "should fail with invalid seat block ID",
+			seatBlockId: 0,
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "should fail when seat block does not exist",
+			seatBlockId: 99,
+			setupMocks: func() {
+				s.seatRepo.On("DeleteSeatBlock", mock.Anything, 99).Return(domain.ErrRecordNotFound)
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+		},
+		{
+			name:        "should remove seat block",
+			seatBlockId: 1,
+			setupMocks: func() {
+				s.seatRepo.On("DeleteSeatBlock", mock.Anything, 1).Return(nil)
+			},
+			wantStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			w, r := executeRequest(s.T(), http.MethodDelete, "/admin/seat-blocks/1", nil)
+			s.app.DeleteSeatBlock(w, r, tt.seatBlockId)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			checkErrorResponse(s.T(), w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+
+			s.seatRepo.AssertExpectations(s.T())
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	errSeatsNotCancellable = fmt.Errorf(
+		"only seats of reservations with a flexible ticket can be cancelled, until %d minutes before the showtime",
+		int(domain.FlexibleTicketChangeCutoff.Minutes()))
+	errSeatNotInReservation = errors.New("a selected seat is not part of the reservation")
+	errAllSeatsSelected     = errors.New("at least one seat of the reservation must be kept")
+)
+
+// CancelReservationSeats cancels some seats of a reservation and refunds what they were sold for. Seats
+// can be cancelled as long as the reservation could be moved to another showtime.
+func (app *Application) CancelReservationSeats(w http.ResponseWriter, r *http.Request, reservationId int) {
+	if reservationId <= 0 {
+		app.badRequestResponse(w, r, fmt.Errorf("reservation id must be greater than zero"))
+		return
+	}
+
+	var input api.CancelReservationSeatsRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	userId := app.contextGetUserId(r)
+	logger := app.contextGetLogger(r).With("reservation_id", reservationId)
+
+	booked, err := app.reservationRepo.GetBookedShowtime(r.Context(), reservationId, userId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	_, err = app.checkReschedulable(booked, time.Now())
+	if errors.Is(err, errTicketNotFlexible) {
+		err = errSeatsNotCancellable
+	}
+
+	if err == nil {
+		err = checkSeatCancellation(booked, input.SeatIds)
+	}
+
+	if err != nil {
+		app.rescheduleErrorResponse(w, r, err)
+		return
+	}
+
+	refunds, err := app.reservationRepo.CancelSeats(r.Context(), reservationId, booked.ShowtimeID, input.SeatIds)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrEditConflict), errors.Is(err, domain.ErrRecordNotFound):
+			// the reservation was moved or its seats changed since it was read
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	// nothing more than what's left of the payments is refunded
+	refundAmount := decimal.Zero
+	for _, refund := range refunds {
+		refundAmount = refundAmount.Add(refund.Amount)
+	}
+
+	logger.Info("seats of the reservation cancelled", "seat_ids", input.SeatIds, "refund_amount", refundAmount.String())
+
+	app.publishSeatEvent(r.Context(), booked.ShowtimeID, seatEventReleased, input.SeatIds)
+
+	// refunds failing now stay pending and are retried in the background
+	app.sendQueuedRefunds(r.Context(), logger, refunds)
+
+	reservationDetail, err := app.reservationRepo.GetByReservationIdAndUserId(r.Context(), reservationId, userId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	resp := api.CancelReservationSeatsResponse{
+		RefundAmount: refundAmount,
+		Reservation:  toReservationDetailResponse(reservationDetail),
+	}
+
+	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// checkSeatCancellation checks that the seats are part of the reservation and some of its seats are kept.
+func checkSeatCancellation(booked *domain.BookedShowtime, seatIds []int) error {
+	for _, seatId := range seatIds {
+		if !slices.Contains(booked.SeatIDs, seatId) {
+			return errSeatNotInReservation
+		}
+	}
+
+	if len(seatIds) >= len(booked.SeatIDs) {
+		return errAllSeatsSelected
+	}
+
+	return nil
+}
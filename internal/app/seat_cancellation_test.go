@@ -0,0 +1,253 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/mock"
+	"github.com/stripe/stripe-go/v82"
+)
+
+func (s *RescheduleTestSuite) TestCancelReservationSeats() {
+	const (
+		userId        = 1
+		reservationId = 10
+		showtimeId    = 1
+	)
+
+	bookedShowtime := func(modify func(*domain.BookedShowtime)) *domain.BookedShowtime {
+		booked := &domain.BookedShowtime{
+			ReservationID:  reservationId,
+			Status:         domain.ReservationConfirmed,
+			FlexibleTicket: true,
+			ShowtimeID:     showtimeId,
+			StartTime:      time.Now().Add(3 * time.Hour),
+			SeatIDs:        []int{7, 8, 9},
+			PaidAmount:     decimal.NewFromInt(30),
+		}
+
+		if modify != nil {
+			modify(booked)
+		}
+
+		return booked
+	}
+
+	expectReleased := func() {
+		s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatMapChangeKeys(showtimeId), seatEventsChannel(showtimeId), mock.Anything, mock.Anything).
+			Return(redis.NewCmdResult(int64(1), nil)).Once()
+	}
+
+	pendingRefund := func(id int, amount decimal.Decimal) domain.PendingRefund {
+		return domain.PendingRefund{
+			ID:              id,
+			ReservationID:   reservationId,
+			PaymentID:       3,
+			PaymentIntentID: "pi_123",
+			Amount:          amount,
+			Reason:          "seat-cancellation",
+		}
+	}
+
+	expectRefundSent := func(refund domain.PendingRefund, err error) {
+		var stripeRefund *stripe.Refund
+		if err == nil {
+			stripeRefund = &stripe.Refund{ID: fmt.Sprintf("re_%d", refund.ID)}
+		}
+
+		s.paymentProvider.On("RefundPaymentAmount", "pi_123",
+			mock.MatchedBy(func(a decimal.Decimal) bool { return a.Equal(refund.Amount) }),
+			refund.IdempotencyKey()).Return(stripeRefund, err).Once()
+
+		if err == nil {
+			s.paymentRepo.On("MarkRefundSent", mock.Anything, refund.ID, stripeRefund.ID, mock.Anything).Return(nil).Once()
+		}
+	}
+
+	expectDetail := func() {
+		s.reservationRepo.On("GetByReservationIdAndUserId", mock.Anything, reservationId, userId).
+			Return(&domain.ReservationDetail{
+				ReservationSummary: domain.ReservationSummary{ReservationID: reservationId},
+			}, nil).Once()
+	}
+
+	tests := []struct {
+		name             string
+		reservationId    int
+		input            api.CancelReservationSeatsRequest
+		rescheduleCutoff time.Duration
+		setupMocks       func()
+		wantStatus       int
+		wantErrMessage   string
+		wantErrCode      api.ErrorCode
+		wantRefund       string
+	}{
+		{
+			name:           "should reject an invalid reservation id",
+			reservationId:  0,
+			input:          api.CancelReservationSeatsRequest{SeatIds: []int{7}},
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "reservation id must be greater than zero",
+		},
+		{
+			name:          "should return not found when the user has no such reservation",
+			reservationId: reservationId,
+			input:         api.CancelReservationSeatsRequest{SeatIds: []int{7}},
+			setupMocks: func() {
+				s.reservationRepo.On("GetBookedShowtime", mock.Anything, reservationId, userId).
+					Return(nil, domain.ErrRecordNotFound).Once()
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+		},
+		{
+			name:          "should reject a seat of another reservation",
+			reservationId: reservationId,
+			input:         api.CancelReservationSeatsRequest{SeatIds: []int{7, 12}},
+			setupMocks: func() {
+				s.reservationRepo.On("GetBookedShowtime", mock.Anything, reservationId, userId).
+					Return(bookedShowtime(nil), nil).Once()
+			},
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: errSeatNotInReservation.Error(),
+		},
+		{
+			name:          "should keep at least one seat",
+			reservationId: reservationId,
+			input:         api.CancelReservationSeatsRequest{SeatIds: []int{7, 8, 9}},
+			setupMocks: func() {
+				s.reservationRepo.On("GetBookedShowtime", mock.Anything, reservationId, userId).
+					Return(bookedShowtime(nil), nil).Once()
+			},
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: errAllSeatsSelected.Error(),
+		},
+		{
+			name:          "should forbid cancelling seats of a standard ticket when only flexible tickets can change",
+			reservationId: reservationId,
+			input:         api.CancelReservationSeatsRequest{SeatIds: []int{7}},
+			setupMocks: func() {
+				s.reservationRepo.On("GetBookedShowtime", mock.Anything, reservationId, userId).
+					Return(bookedShowtime(func(b *domain.BookedShowtime) { b.FlexibleTicket = false }), nil).Once()
+			},
+			wantStatus:     http.StatusForbidden,
+			wantErrMessage: errSeatsNotCancellable.Error(),
+			wantErrCode:    api.SEATSNOTCANCELLABLE,
+		},
+		{
+			name:          "should reject cancelling once the showtime starts too soon",
+			reservationId: reservationId,
+			input:         api.CancelReservationSeatsRequest{SeatIds: []int{7}},
+			setupMocks: func() {
+				s.reservationRepo.On("GetBookedShowtime", mock.Anything, reservationId, userId).
+					Return(bookedShowtime(func(b *domain.BookedShowtime) {
+						b.StartTime = time.Now().Add(30 * time.Minute)
+					}), nil).Once()
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: errChangeWindowClosed.Error(),
+			wantErrCode:    api.CHANGEWINDOWCLOSED,
+		},
+		{
+			name:          "should return a conflict when the reservation changed concurrently",
+			reservationId: reservationId,
+			input:         api.CancelReservationSeatsRequest{SeatIds: []int{7}},
+			setupMocks: func() {
+				s.reservationRepo.On("GetBookedShowtime", mock.Anything, reservationId, userId).
+					Return(bookedShowtime(nil), nil).Once()
+				s.reservationRepo.On("CancelSeats", mock.Anything, reservationId, showtimeId, []int{7}).
+					Return(nil, domain.ErrEditConflict).Once()
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: ErrEditConflict,
+		},
+		{
+			name:          "should cancel the seats and refund what they were sold for",
+			reservationId: reservationId,
+			input:         api.CancelReservationSeatsRequest{SeatIds: []int{7, 8}},
+			setupMocks: func() {
+				s.reservationRepo.On("GetBookedShowtime", mock.Anything, reservationId, userId).
+					Return(bookedShowtime(nil), nil).Once()
+				refund := pendingRefund(5, decimal.RequireFromString("22.50"))
+
+				s.reservationRepo.On("CancelSeats", mock.Anything, reservationId, showtimeId, []int{7, 8}).
+					Return([]domain.PendingRefund{refund}, nil).Once()
+				expectReleased()
+				expectRefundSent(refund, nil)
+				expectDetail()
+			},
+			wantStatus: http.StatusOK,
+			wantRefund: "22.5",
+		},
+		{
+			name:          "should keep the refund pending when it can't be sent",
+			reservationId: reservationId,
+			input:         api.CancelReservationSeatsRequest{SeatIds: []int{7}},
+			setupMocks: func() {
+				s.reservationRepo.On("GetBookedShowtime", mock.Anything, reservationId, userId).
+					Return(bookedShowtime(nil), nil).Once()
+
+				refund := pendingRefund(6, decimal.NewFromInt(4))
+
+				s.reservationRepo.On("CancelSeats", mock.Anything, reservationId, showtimeId, []int{7}).
+					Return([]domain.PendingRefund{refund}, nil).Once()
+				expectReleased()
+				expectRefundSent(refund, errors.New("stripe unavailable"))
+				s.paymentRepo.On("RecordRefundFailure", mock.Anything, mock.MatchedBy(func(r domain.PendingRefund) bool {
+					return r.ID == refund.ID && r.Attempts == 1 && r.NextAttemptAt != nil
+				})).Return(nil).Once()
+				expectDetail()
+			},
+			wantStatus: http.StatusOK,
+			wantRefund: "4",
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+			s.app.config.RescheduleCutoff = tt.rescheduleCutoff
+
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			w, r := executeRequest(s.T(), http.MethodDelete, fmt.Sprintf("/users/me/reservations/%d/seats", tt.reservationId), tt.input)
+			r = r.WithContext(context.WithValue(r.Context(), SessionKeyUserId, userId))
+
+			s.app.CancelReservationSeats(w, r, tt.reservationId)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantErrCode != "" {
+				checkErrorCode(s.T(), w, tt.wantErrCode)
+			}
+
+			if tt.wantStatus == http.StatusOK {
+				var response api.CancelReservationSeatsResponse
+				s.Require().NoError(json.NewDecoder(w.Body).Decode(&response))
+
+				s.Equal(tt.wantRefund, response.RefundAmount.String())
+				s.Equal(reservationId, response.Reservation.Id)
+			}
+
+			checkErrorResponse(s.T(), w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{tt.wantStatus, tt.wantErrMessage})
+
+			s.reservationRepo.AssertExpectations(s.T())
+			s.paymentRepo.AssertExpectations(s.T())
+			s.paymentProvider.AssertExpectations(s.T())
+			s.redisClient.AssertExpectations(s.T())
+		})
+	}
+}
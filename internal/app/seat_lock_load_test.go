@@ -0,0 +1,138 @@
+//go:build loadtest
+
+package app
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/loadtest"
+	"github.com/redis/go-redis/v9"
+	tcredis "github.com/testcontainers/testcontainers-go/modules/redis"
+)
+
+// Run with make test/load, it needs Docker to start Redis.
+
+const loadTestShowtimeID = 1
+
+// redisSeatLocker drives the seat locking of the cart handlers.
+type redisSeatLocker struct {
+	app *Application
+}
+
+func (l redisSeatLocker) Lock(ctx context.Context, sessionID string, seatIDs []int) error {
+	return l.app.tryLockSeats(ctx, seatIDs, loadTestShowtimeID, sessionID)
+}
+
+func (l redisSeatLocker) Release(ctx context.Context, sessionID string, seatIDs []int) error {
+	l.app.rollbackSeatLocks(ctx, loadTestShowtimeID, seatIDs)
+	return nil
+}
+
+func newLoadTestApp(tb testing.TB) *Application {
+	tb.Helper()
+
+	ctx := context.Background()
+
+	container, err := tcredis.Run(ctx, "redis:7")
+	if err != nil {
+		tb.Fatalf("failed to start redis container: %v", err)
+	}
+
+	tb.Cleanup(func() {
+		container.Terminate(context.Background())
+	})
+
+	connStr, err := container.ConnectionString(ctx)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	opts, err := redis.ParseURL(connStr)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	// every session needs its own connection to really compete for the seats
+	opts.PoolSize = 500
+
+	client := redis.NewClient(opts)
+	tb.Cleanup(func() {
+		client.Close()
+	})
+
+	return newTestApplication(func(a *Application) {
+		a.redis = client
+		a.logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	})
+}
+
+func TestSeatLockSoak(t *testing.T) {
+	app := newLoadTestApp(t)
+
+	tests := []struct {
+		name string
+		cfg  loadtest.Config
+	}{
+		{
+			name: "single seats",
+			cfg:  loadtest.Config{Sessions: 300, AttemptsPerSession: 50, Seats: 100, SeatsPerCart: 1},
+		},
+		{
+			name: "group bookings",
+			cfg:  loadtest.Config{Sessions: 300, AttemptsPerSession: 50, Seats: 100, SeatsPerCart: 6},
+		},
+		{
+			name: "sold out hall",
+			cfg: loadtest.Config{
+				Sessions:           300,
+				AttemptsPerSession: 20,
+				Seats:              20,
+				SeatsPerCart:       4,
+				HoldTime:           time.Millisecond,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := loadtest.Run(context.Background(), redisSeatLocker{app}, tt.cfg)
+
+			t.Log(report)
+
+			if report.DoubleSells != 0 {
+				t.Errorf("seats were sold twice: %s", report)
+			}
+
+			if report.Errors != 0 {
+				t.Errorf("seat locking failed: %s", report)
+			}
+		})
+	}
+}
+
+func BenchmarkSeatLock(b *testing.B) {
+	app := newLoadTestApp(b)
+
+	const sessions = 200
+
+	cfg := loadtest.Config{
+		Sessions:           sessions,
+		AttemptsPerSession: max(b.N/sessions, 1),
+		Seats:              150,
+		SeatsPerCart:       3,
+	}
+
+	b.ResetTimer()
+	report := loadtest.Run(context.Background(), redisSeatLocker{app}, cfg)
+	b.StopTimer()
+
+	if report.DoubleSells != 0 {
+		b.Errorf("seats were sold twice: %s", report)
+	}
+
+	b.ReportMetric(report.ConflictRatio(), "conflicts/op")
+	b.ReportMetric(float64(report.Errors), "errors")
+}
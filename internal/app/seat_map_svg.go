@@ -0,0 +1,196 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	seatMapSeatSize    = 28
+	seatMapSeatGap     = 6
+	seatMapMargin      = 24
+	seatMapLabelWidth  = 24
+	seatMapScreenSpace = 48
+	// hall layouts rarely change, and a stale layout only affects the picture
+	seatMapLayoutTTL = 24 * time.Hour
+	// seatMapStylePlaceholder is replaced by the style rules highlighting the requested seats
+	seatMapStylePlaceholder = "<!--highlight-->"
+)
+
+var seatMapColors = map[string]string{
+	"Standard":   "#9ca3af",
+	"VIP":        "#a855f7",
+	"Recliner":   "#3b82f6",
+	"Accessible": "#10b981",
+}
+
+func (app *Application) GetSeatMapSvg(
+	w http.ResponseWriter,
+	r *http.Request,
+	showtimeID int,
+	params api.GetSeatMapSvgParams) {
+
+	logger := app.contextGetLogger(r)
+
+	if showtimeID < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("showtime ID must be greater than zero"))
+		return
+	}
+
+	err := app.validator.Struct(params)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	showtimeSeats, err := app.seatRepo.GetSeatsByShowtime(r.Context(), showtimeID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if len(showtimeSeats.Seats) == 0 {
+		logger.Warn("seat map not found for showtime", "showtime_id", showtimeID)
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var highlight []int
+	if params.Highlight != nil {
+		highlight = *params.Highlight
+	}
+
+	seatIDs := make(map[int]bool, len(showtimeSeats.Seats))
+	for _, seat := range showtimeSeats.Seats {
+		seatIDs[seat.ID] = true
+	}
+
+	for _, id := range highlight {
+		if !seatIDs[id] {
+			app.badRequestResponse(w, r, fmt.Errorf("seat %d does not belong to the showtime", id))
+			return
+		}
+	}
+
+	layout := app.seatMapLayout(r.Context(), showtimeSeats)
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(highlightSeats(layout, highlight)))
+}
+
+// seatMapLayout returns the rendered layout of the hall, which is shared by all showtimes of the hall.
+func (app *Application) seatMapLayout(ctx context.Context, showtimeSeats *domain.ShowtimeSeats) string {
+	key := seatMapLayoutKey(showtimeSeats.HallID)
+
+	cached, err := app.redis.Get(ctx, key).Result()
+	if err == nil {
+		return cached
+	}
+
+	if !errors.Is(err, redis.Nil) {
+		app.logger.Warn("failed to read seat map layout from cache", "key", key, "error", err)
+	}
+
+	layout := renderSeatMapLayout(showtimeSeats.HallName, showtimeSeats.Seats)
+
+	err = app.redis.Set(ctx, key, layout, seatMapLayoutTTL).Err()
+	if err != nil {
+		app.logger.Warn("failed to cache seat map layout", "key", key, "error", err)
+	}
+
+	return layout
+}
+
+// renderSeatMapLayout draws the seats of a hall as a grid with the screen on top. Every seat is a
+// rect with the id seat-{id}, so seats can be highlighted with style rules without rendering the
+// layout again.
+func renderSeatMapLayout(hallName string, seats []domain.Seat) string {
+	maxRow, maxCol := 0, 0
+	for _, seat := range seats {
+		maxRow = max(maxRow, seat.Row)
+		maxCol = max(maxCol, seat.Col)
+	}
+
+	cell := seatMapSeatSize + seatMapSeatGap
+	gridWidth := maxCol*cell - seatMapSeatGap
+	width := 2*seatMapMargin + seatMapLabelWidth + gridWidth
+	height := 2*seatMapMargin + seatMapScreenSpace + maxRow*cell - seatMapSeatGap
+	gridX := seatMapMargin + seatMapLabelWidth
+	gridY := seatMapMargin + seatMapScreenSpace
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif">`,
+		width, height, width, height)
+	fmt.Fprintf(&b, `<title>%s</title>`, html.EscapeString(hallName))
+	b.WriteString(`<style>.seat{stroke:#374151;stroke-width:1}.label{font-size:12px;fill:#374151}` + seatMapStylePlaceholder + `</style>`)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#ffffff"/>`, width, height)
+	fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="6" rx="3" fill="#6b7280"/>`, gridX, seatMapMargin, gridWidth)
+	fmt.Fprintf(&b, `<text class="label" x="%d" y="%d" text-anchor="middle">SCREEN</text>`, gridX+gridWidth/2, seatMapMargin+24)
+
+	for row := 1; row <= maxRow; row++ {
+		y := gridY + (row-1)*cell
+		fmt.Fprintf(&b, `<text class="label" x="%d" y="%d">%d</text>`, seatMapMargin, y+seatMapSeatSize/2+4, row)
+	}
+
+	sorted := make([]domain.Seat, len(seats))
+	copy(sorted, seats)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Row != sorted[j].Row {
+			return sorted[i].Row < sorted[j].Row
+		}
+		return sorted[i].Col < sorted[j].Col
+	})
+
+	for _, seat := range sorted {
+		color, ok := seatMapColors[seat.Type]
+		if !ok {
+			color = seatMapColors["Standard"]
+		}
+
+		fmt.Fprintf(&b, `<rect id="seat-%d" class="seat" x="%d" y="%d" width="%d" height="%d" rx="4" fill="%s"><title>Row %d Seat %d (%s)</title></rect>`,
+			seat.ID,
+			gridX+(seat.Col-1)*cell,
+			gridY+(seat.Row-1)*cell,
+			seatMapSeatSize,
+			seatMapSeatSize,
+			color,
+			seat.Row,
+			seat.Col,
+			html.EscapeString(seat.Type))
+	}
+
+	b.WriteString(`</svg>`)
+
+	return b.String()
+}
+
+func highlightSeats(layout string, seatIDs []int) string {
+	if len(seatIDs) == 0 {
+		return strings.Replace(layout, seatMapStylePlaceholder, "", 1)
+	}
+
+	selectors := make([]string, len(seatIDs))
+	for i, id := range seatIDs {
+		selectors[i] = fmt.Sprintf("#seat-%d", id)
+	}
+
+	rule := strings.Join(selectors, ",") + "{fill:#f59e0b;stroke:#b45309;stroke-width:3}"
+
+	return strings.Replace(layout, seatMapStylePlaceholder, rule, 1)
+}
+
+func seatMapLayoutKey(hallID int) string {
+	return fmt.Sprintf("seat_map_svg:%d", hallID)
+}
@@ -0,0 +1,163 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type SeatMapSvgTestSuite struct {
+	suite.Suite
+	app         *Application
+	seatRepo    *mocks.MockSeatRepo
+	redisClient *mocks.MockRedisClient
+}
+
+func (s *SeatMapSvgTestSuite) SetupTest() {
+	s.seatRepo = new(mocks.MockSeatRepo)
+	s.redisClient = new(mocks.MockRedisClient)
+
+	s.app = newTestApplication(func(a *Application) {
+		a.seatRepo = s.seatRepo
+		a.redis = s.redisClient
+	})
+}
+
+func TestSeatMapSvgSuite(t *testing.T) {
+	suite.Run(t, new(SeatMapSvgTestSuite))
+}
+
+func (s *SeatMapSvgTestSuite) TestGetSeatMapSvg() {
+	showtimeSeats := &domain.ShowtimeSeats{
+		HallID:   3,
+		HallName: "Hall <A>",
+		Seats: []domain.Seat{
+			{ID: 1, Row: 1, Col: 1, Type: "Standard"},
+			{ID: 2, Row: 1, Col: 2, Type: "VIP"},
+			{ID: 4, Row: 2, Col: 1, Type: "Accessible"},
+		},
+	}
+	layout := renderSeatMapLayout(showtimeSeats.HallName, showtimeSeats.Seats)
+
+	tests := []struct {
+		name           string
+		showtimeID     int
+		params         api.GetSeatMapSvgParams
+		setupMocks     func()
+		wantStatus     int
+		wantErrMessage string
+		wantContains   []string
+		wantMissing    []string
+	}{
+		{
+			name:           "should fail when showtime ID is zero or negative",
+			showtimeID:     0,
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "showtime ID must be greater than zero",
+		},
+		{
+			name:           "should fail when highlight list is too long",
+			showtimeID:     1,
+			params:         api.GetSeatMapSvgParams{Highlight: ptr(make([]int, 51))},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: "must contain at most 50 items",
+		},
+		{
+			name:       "should return not found when showtime has no seats",
+			showtimeID: 1,
+			setupMocks: func() {
+				s.seatRepo.On("GetSeatsByShowtime", mock.Anything, 1).Return(&domain.ShowtimeSeats{}, nil)
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+		},
+		{
+			name:       "should fail when highlighted seat is not in the hall",
+			showtimeID: 1,
+			params:     api.GetSeatMapSvgParams{Highlight: ptr([]int{1, 9})},
+			setupMocks: func() {
+				s.seatRepo.On("GetSeatsByShowtime", mock.Anything, 1).Return(showtimeSeats, nil)
+			},
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "seat 9 does not belong to the showtime",
+		},
+		{
+			name:       "should render and cache layout on cache miss",
+			showtimeID: 1,
+			params:     api.GetSeatMapSvgParams{Highlight: ptr([]int{1, 4})},
+			setupMocks: func() {
+				s.seatRepo.On("GetSeatsByShowtime", mock.Anything, 1).Return(showtimeSeats, nil)
+				s.redisClient.On("Get", mock.Anything, "seat_map_svg:3").Return(redis.NewStringResult("", redis.Nil))
+				s.redisClient.On("Set", mock.Anything, "seat_map_svg:3", layout, seatMapLayoutTTL).
+					Return(redis.NewStatusResult("OK", nil))
+			},
+			wantStatus: http.StatusOK,
+			wantContains: []string{
+				`<title>Hall &lt;A&gt;</title>`,
+				`id="seat-2"`,
+				`#seat-1,#seat-4{fill:#f59e0b`,
+			},
+			wantMissing: []string{seatMapStylePlaceholder},
+		},
+		{
+			name:       "should use cached layout",
+			showtimeID: 1,
+			setupMocks: func() {
+				s.seatRepo.On("GetSeatsByShowtime", mock.Anything, 1).Return(showtimeSeats, nil)
+				s.redisClient.On("Get", mock.Anything, "seat_map_svg:3").Return(redis.NewStringResult(layout, nil))
+			},
+			wantStatus:   http.StatusOK,
+			wantContains: []string{`id="seat-4"`},
+			wantMissing:  []string{seatMapStylePlaceholder, "#f59e0b"},
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			w, r := executeRequest(s.T(), http.MethodGet, fmt.Sprintf("/showtimes/%d/seat-map.svg", tt.showtimeID), nil)
+
+			s.app.GetSeatMapSvg(w, r, tt.showtimeID, tt.params)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantStatus == http.StatusOK {
+				s.Equal("image/svg+xml", w.Header().Get("Content-Type"))
+
+				body := w.Body.String()
+				s.True(strings.HasPrefix(body, "<svg"))
+
+				for _, want := range tt.wantContains {
+					s.Contains(body, want)
+				}
+				for _, missing := range tt.wantMissing {
+					s.NotContains(body, missing)
+				}
+			}
+
+			checkErrorResponse(s.T(), w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+
+			s.seatRepo.AssertExpectations(s.T())
+			s.redisClient.AssertExpectations(s.T())
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/shopspring/decimal"
+)
+
+// seats.extra_price is numeric(6,2)
+var maxSeatExtraPrice = decimal.RequireFromString("9999.99")
+
+func (app *Application) GetSeatPriceVersions(w http.ResponseWriter, r *http.Request, hallID int) {
+	if hallID < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("hall ID must be greater than zero"))
+		return
+	}
+
+	versions, err := app.seatRepo.GetPriceVersionsByHall(r.Context(), hallID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	resp := api.SeatPriceVersionsResponse{
+		Versions: make([]api.SeatPriceVersion, len(versions)),
+	}
+
+	for i, version := range versions {
+		resp.Versions[i] = toApiSeatPriceVersion(version)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) CreateSeatPriceVersion(w http.ResponseWriter, r *http.Request, hallID int) {
+	logger := app.contextGetLogger(r)
+
+	if hallID < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("hall ID must be greater than zero"))
+		return
+	}
+
+	var input api.CreateSeatPriceVersionRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	if input.ExtraPrice.IsNegative() || input.ExtraPrice.GreaterThan(maxSeatExtraPrice) {
+		app.badRequestResponse(w, r, fmt.Errorf("extra price must be between 0 and %s", maxSeatExtraPrice))
+		return
+	}
+
+	now := time.Now()
+	effectiveFrom := now
+
+	if input.EffectiveFrom != nil {
+		// prices of past carts can't be changed, scheduling into the past would only rewrite history
+		if input.EffectiveFrom.Before(now.Add(-time.Minute)) {
+			app.badRequestResponse(w, r, fmt.Errorf("effective time must not be in the past"))
+			return
+		}
+
+		effectiveFrom = *input.EffectiveFrom
+	}
+
+	version := domain.SeatPriceVersion{
+		HallID:        hallID,
+		SeatType:      string(input.SeatType),
+		ExtraPrice:    input.ExtraPrice.Round(2),
+		EffectiveFrom: effectiveFrom.Truncate(time.Second),
+	}
+
+	err = app.seatRepo.CreatePriceVersion(r.Context(), &version)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrHallNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, domain.ErrEditConflict):
+			app.editConflictResponseWithErr(w, r, fmt.Errorf("a price of the seat type is already scheduled for this time"))
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	logger.Info(
+		"seat price scheduled",
+		"hall_id", hallID,
+		"seat_type", version.SeatType,
+		"extra_price", version.ExtraPrice.String(),
+		"effective_from", version.EffectiveFrom,
+	)
+
+	err = app.writeJSON(w, http.StatusCreated, toApiSeatPriceVersion(version), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) GetSeatPriceSales(
+	w http.ResponseWriter,
+	r *http.Request,
+	hallID int,
+	params api.GetSeatPriceSalesParams) {
+
+	if hallID < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("hall ID must be greater than zero"))
+		return
+	}
+
+	err := app.validator.Struct(params)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	var channel domain.SalesChannel
+	if params.Channel != nil {
+		channel = domain.SalesChannel(*params.Channel)
+	}
+
+	sales, err := app.seatRepo.GetSalesByPriceVersion(r.Context(), hallID, channel)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	resp := api.SeatPriceSalesResponse{
+		HallId: hallID,
+		Sales:  make([]api.SeatPriceSales, len(sales)),
+	}
+
+	for i, s := range sales {
+		resp.Sales[i] = api.SeatPriceSales{
+			PriceVersionId: s.PriceVersionID,
+			SeatType:       api.SeatType(s.SeatType),
+			ExtraPrice:     s.ExtraPrice,
+			SeatsSold:      s.SeatsSold,
+			ExtrasRevenue:  s.ExtrasRevenue,
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func toApiSeatPriceVersion(version domain.SeatPriceVersion) api.SeatPriceVersion {
+	return api.SeatPriceVersion{
+		Id:            version.ID,
+		HallId:        version.HallID,
+		SeatType:      api.SeatType(version.SeatType),
+		ExtraPrice:    version.ExtraPrice,
+		EffectiveFrom: version.EffectiveFrom,
+		CreatedAt:     version.CreatedAt,
+	}
+}
@@ -0,0 +1,207 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/metinatakli/movie-reservation-system/internal/validator"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type SeatPricesTestSuite struct {
+	suite.Suite
+	app      *Application
+	seatRepo *mocks.MockSeatRepo
+}
+
+func (s *SeatPricesTestSuite) SetupTest() {
+	s.seatRepo = new(mocks.MockSeatRepo)
+
+	s.app = newTestApplication(func(a *Application) {
+		a.seatRepo = s.seatRepo
+	})
+}
+
+func TestSeatPricesSuite(t *testing.T) {
+	suite.Run(t, new(SeatPricesTestSuite))
+}
+
+func (s *SeatPricesTestSuite) TestCreateSeatPriceVersion() {
+	nextWeek := time.Now().Add(7 * 24 * time.Hour).Truncate(time.Second)
+	yesterday := time.Now().Add(-24 * time.Hour)
+
+	tests := []struct {
+		name           string
+		hallID         int
+		input          map[string]any
+		setupMocks     func()
+		wantStatus     int
+		wantErrMessage string
+	}{
+		{
+			name:           "invalid hall ID",
+			hallID:         0,
+			input:          map[string]any{"seatType": "VIP", "extraPrice": "12.50"},
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "hall ID must be greater than zero",
+		},
+		{
+			name:           "invalid seat type",
+			hallID:         1,
+			input:          map[string]any{"seatType": "Balcony", "extraPrice": "12.50"},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: fmt.Sprintf(validator.ErrOneOf, "Standard VIP Recliner Accessible"),
+		},
+		{
+			name:           "negative price",
+			hallID:         1,
+			input:          map[string]any{"seatType": "VIP", "extraPrice": "-1"},
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "extra price must be between 0 and 9999.99",
+		},
+		{
+			name:           "effective time in the past",
+			hallID:         1,
+			input:          map[string]any{"seatType": "VIP", "extraPrice": "12.50", "effectiveFrom": yesterday},
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "effective time must not be in the past",
+		},
+		{
+			name:   "hall not found",
+			hallID: 99,
+			input:  map[string]any{"seatType": "VIP", "extraPrice": "12.50"},
+			setupMocks: func() {
+				s.seatRepo.On("CreatePriceVersion", mock.Anything, mock.Anything).Return(domain.ErrHallNotFound)
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+		},
+		{
+			name:   "already scheduled",
+			hallID: 1,
+			input:  map[string]any{"seatType": "VIP", "extraPrice": "12.50", "effectiveFrom": nextWeek},
+			setupMocks: func() {
+				s.seatRepo.On("CreatePriceVersion", mock.Anything, mock.Anything).Return(domain.ErrEditConflict)
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: "a price of the seat type is already scheduled for this time",
+		},
+		{
+			name:   "scheduled",
+			hallID: 1,
+			input:  map[string]any{"seatType": "VIP", "extraPrice": "12.50", "effectiveFrom": nextWeek},
+			setupMocks: func() {
+				s.seatRepo.On("CreatePriceVersion", mock.Anything, mock.MatchedBy(func(v *domain.SeatPriceVersion) bool {
+					return v.HallID == 1 &&
+						v.SeatType == "VIP" &&
+						v.ExtraPrice.Equal(decimal.RequireFromString("12.50")) &&
+						v.EffectiveFrom.Equal(nextWeek)
+				})).Run(func(args mock.Arguments) {
+					args.Get(1).(*domain.SeatPriceVersion).ID = 3
+				}).Return(nil)
+			},
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:   "database error",
+			hallID: 1,
+			input:  map[string]any{"seatType": "VIP", "extraPrice": "12.50"},
+			setupMocks: func() {
+				s.seatRepo.On("CreatePriceVersion", mock.Anything, mock.Anything).Return(errors.New("db error"))
+			},
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			w, r := executeRequest(s.T(), http.MethodPost, "/admin/halls/1/seat-prices", tt.input)
+			s.app.CreateSeatPriceVersion(w, r, tt.hallID)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantStatus == http.StatusCreated {
+				var resp api.SeatPriceVersion
+				s.Require().NoError(json.NewDecoder(w.Body).Decode(&resp))
+				s.Equal(3, resp.Id)
+				s.Equal(api.SeatType("VIP"), resp.SeatType)
+			}
+
+			checkErrorResponse(s.T(), w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{tt.wantStatus, tt.wantErrMessage})
+
+			s.seatRepo.AssertExpectations(s.T())
+		})
+	}
+}
+
+func (s *SeatPricesTestSuite) TestGetSeatPriceSales() {
+	versionId := 3
+
+	s.seatRepo.On("GetSalesByPriceVersion", mock.Anything, 1, domain.SalesChannel("")).Return([]domain.PriceVersionSales{
+		{SeatType: "VIP", ExtraPrice: decimal.RequireFromString("10.00"), SeatsSold: 4, ExtrasRevenue: decimal.RequireFromString("40.00")},
+		{PriceVersionID: &versionId, SeatType: "VIP", ExtraPrice: decimal.RequireFromString("12.50"), SeatsSold: 2, ExtrasRevenue: decimal.RequireFromString("25.00")},
+	}, nil)
+
+	w, r := executeRequest(s.T(), http.MethodGet, "/admin/halls/1/seat-prices/sales", nil)
+	s.app.GetSeatPriceSales(w, r, 1, api.GetSeatPriceSalesParams{})
+
+	s.Equal(http.StatusOK, w.Code)
+
+	var resp api.SeatPriceSalesResponse
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&resp))
+	s.Require().Len(resp.Sales, 2)
+	s.Nil(resp.Sales[0].PriceVersionId)
+	s.Equal(&versionId, resp.Sales[1].PriceVersionId)
+	s.True(resp.Sales[1].ExtrasRevenue.Equal(decimal.RequireFromString("25.00")))
+}
+
+func (s *SeatPricesTestSuite) TestGetSeatPriceSalesOfChannel() {
+	s.seatRepo.On("GetSalesByPriceVersion", mock.Anything, 1, domain.SalesChannelBoxOffice).Return([]domain.PriceVersionSales{
+		{SeatType: "VIP", ExtraPrice: decimal.RequireFromString("10.00"), SeatsSold: 1, ExtrasRevenue: decimal.RequireFromString("10.00")},
+	}, nil)
+
+	w, r := executeRequest(s.T(), http.MethodGet, "/admin/halls/1/seat-prices/sales?channel=box-office", nil)
+	s.app.GetSeatPriceSales(w, r, 1, api.GetSeatPriceSalesParams{Channel: ptr(api.BoxOffice)})
+
+	s.Equal(http.StatusOK, w.Code)
+
+	var resp api.SeatPriceSalesResponse
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&resp))
+	s.Require().Len(resp.Sales, 1)
+	s.Equal(1, resp.Sales[0].SeatsSold)
+
+	s.seatRepo.AssertExpectations(s.T())
+}
+
+func (s *SeatPricesTestSuite) TestGetSeatPriceSalesOfUnknownChannel() {
+	w, r := executeRequest(s.T(), http.MethodGet, "/admin/halls/1/seat-prices/sales?channel=fax", nil)
+	s.app.GetSeatPriceSales(w, r, 1, api.GetSeatPriceSalesParams{Channel: ptr(api.SalesChannel("fax"))})
+
+	checkErrorResponse(s.T(), w, struct {
+		wantStatus     int
+		wantErrMessage string
+	}{
+		wantStatus:     http.StatusUnprocessableEntity,
+		wantErrMessage: fmt.Sprintf(validator.ErrOneOf, "web mobile kiosk box-office partner"),
+	})
+	s.seatRepo.AssertNotCalled(s.T(), "GetSalesByPriceVersion", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -2,8 +2,12 @@ package app
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/metinatakli/movie-reservation-system/api"
 	"github.com/metinatakli/movie-reservation-system/internal/domain"
@@ -37,15 +41,130 @@ var filterValidLockSeats = redis.NewScript(`
 
 	if #expiredSeats > 0 then
 		redis.call("SREM", setKey, unpack(expiredSeats))
+
+		-- expired locks are not announced, but polling clients learn about them from the seat map changes
+		local version = redis.call("INCR", KEYS[2])
+		for _, seatId in ipairs(expiredSeats) do
+			redis.call("ZADD", KEYS[3], version, seatId)
+		end
+		redis.call("EXPIRE", KEYS[2], ARGV[2])
+		redis.call("EXPIRE", KEYS[3], ARGV[2])
 	end
 
 	return validSeats
 `)
 
+// Redis Lua script to record a seat event in the seat map changes of the showtime and publish it. The changes
+// keep the version of the latest change of every seat.
+var recordSeatEvent = redis.NewScript(`
+	local event = cjson.decode(ARGV[2])
+	local version = redis.call("INCR", KEYS[1])
+
+	for _, seatId in ipairs(event.seatIds) do
+		redis.call("ZADD", KEYS[2], version, seatId)
+	end
+
+	redis.call("EXPIRE", KEYS[1], ARGV[3])
+	redis.call("EXPIRE", KEYS[2], ARGV[3])
+	redis.call("PUBLISH", ARGV[1], ARGV[2])
+
+	return version
+`)
+
+// Redis Lua script to return the current seat map version of a showtime followed by the seats changed after
+// the given version.
+var seatMapChangesScript = redis.NewScript(`
+	local version = tonumber(redis.call("GET", KEYS[1]) or "0")
+	local changed = redis.call("ZRANGEBYSCORE", KEYS[2], "(" .. ARGV[1], "+inf")
+
+	table.insert(changed, 1, version)
+
+	return changed
+`)
+
+// seatMapChangesTTL is how long the seat map changes of a showtime are kept after its last change. Clients
+// polling with a version the changes no longer know get the full seat map.
+const seatMapChangesTTL = 24 * time.Hour
+
+const (
+	// seatMapVersionHeader carries the seat map version of the change made by a cart operation, which the
+	// seat map is fetched with to reflect the change
+	seatMapVersionHeader = "X-Seat-Map-Version"
+	// seatMapMaxAge is how long shared caches may serve the seat map
+	seatMapMaxAge = 5 * time.Second
+	// a seat map requested with a version is held back this long at most for the version to be visible,
+	// e.g. on a Redis replica lagging behind
+	seatMapVersionWait     = time.Second
+	seatMapVersionInterval = 50 * time.Millisecond
+)
+
+const (
+	seatEventLocked   = "locked"
+	seatEventReleased = "released"
+	seatEventReserved = "reserved"
+)
+
+// seatEvent is published to the seat events channel of a showtime whenever the availability of its seats changes.
+// Expired locks are not announced since Redis removes them silently.
+type seatEvent struct {
+	Type    string `json:"type"`
+	SeatIDs []int  `json:"seatIds"`
+}
+
+func seatEventsChannel(showtimeID int) string {
+	return fmt.Sprintf("seat_events:%d", showtimeID)
+}
+
+func seatMapVersionKey(showtimeID int) string {
+	return fmt.Sprintf("seat_map_version:%d", showtimeID)
+}
+
+func seatMapChangesKey(showtimeID int) string {
+	return fmt.Sprintf("seat_map_changes:%d", showtimeID)
+}
+
+// publishSeatEvent notifies the subscribers of a showtime about seat changes and records them for polling
+// clients. It returns the seat map version of the change, 0 if it couldn't be recorded. Subscribers only use
+// events as a signal to refresh their view, so a failed publish is logged and otherwise ignored.
+func (app *Application) publishSeatEvent(ctx context.Context, showtimeID int, eventType string, seatIDs []int) int {
+	payload, err := json.Marshal(seatEvent{Type: eventType, SeatIDs: seatIDs})
+	if err != nil {
+		app.logger.Error("failed to marshal seat event", "showtime_id", showtimeID, "error", err)
+		return 0
+	}
+
+	version, err := recordSeatEvent.Run(
+		ctx,
+		app.redis,
+		[]string{seatMapVersionKey(showtimeID), seatMapChangesKey(showtimeID)},
+		seatEventsChannel(showtimeID),
+		payload,
+		int(seatMapChangesTTL.Seconds())).Int()
+	if err != nil {
+		app.logger.Error("failed to publish seat event", "showtime_id", showtimeID, "type", eventType, "error", err)
+		return 0
+	}
+
+	return version
+}
+
+// seatMapVersionHeaders returns the header handing out the seat map version of a change, none when the
+// change couldn't be recorded.
+func seatMapVersionHeaders(version int) http.Header {
+	headers := make(http.Header)
+
+	if version > 0 {
+		headers.Set(seatMapVersionHeader, strconv.Itoa(version))
+	}
+
+	return headers
+}
+
 func (app *Application) GetSeatMapByShowtime(
 	w http.ResponseWriter,
 	r *http.Request,
-	showtimeID int) {
+	showtimeID int,
+	params api.GetSeatMapByShowtimeParams) {
 
 	logger := app.contextGetLogger(r)
 
@@ -54,6 +173,29 @@ func (app *Application) GetSeatMapByShowtime(
 		return
 	}
 
+	err := app.validator.Struct(params)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	// the version is read before the availability, so the seat map reflects at least the changes up to it
+	var version int
+	if params.MinVersion != nil {
+		version, err = app.awaitSeatMapVersion(r.Context(), showtimeID, *params.MinVersion)
+		if err == nil && version < *params.MinVersion {
+			logger.Warn("seat map version not reached in time", "showtime_id", showtimeID,
+				"version", version, "min_version", *params.MinVersion)
+		}
+	} else {
+		version, err = app.seatMapVersion(r.Context(), showtimeID)
+	}
+
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
 	showtimeSeats, err := app.seatRepo.GetSeatsByShowtime(r.Context(), showtimeID)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -66,6 +208,12 @@ func (app *Application) GetSeatMapByShowtime(
 		return
 	}
 
+	err = showtimeSeats.SalesWindow.Check(time.Now())
+	if err != nil {
+		app.editConflictResponseWithErr(w, r, err)
+		return
+	}
+
 	err = app.updateSeatAvailability(r.Context(), showtimeID, showtimeSeats)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -73,6 +221,14 @@ func (app *Application) GetSeatMapByShowtime(
 	}
 
 	resp := toSeatMapResponse(showtimeID, showtimeSeats)
+	resp.Version = version
+
+	// a user who just changed their cart must not be served a copy cached before the change
+	if params.MinVersion != nil {
+		w.Header().Set("Cache-Control", "no-store")
+	} else {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(seatMapMaxAge.Seconds())))
+	}
 
 	err = app.writeJSON(w, http.StatusOK, resp, nil)
 	if err != nil {
@@ -80,11 +236,143 @@ func (app *Application) GetSeatMapByShowtime(
 	}
 }
 
-func (app *Application) updateSeatAvailability(ctx context.Context, showtimeID int, showtimeSeats *domain.ShowtimeSeats) error {
-	cmd := filterValidLockSeats.Run(ctx, app.redis, []string{seatSetKey(showtimeID)}, showtimeID)
+func (app *Application) GetSeatMapChanges(
+	w http.ResponseWriter,
+	r *http.Request,
+	showtimeID int,
+	params api.GetSeatMapChangesParams) {
+
+	if showtimeID < 1 {
+		app.badRequestResponse(w, r, fmt.Errorf("showtime ID must be greater than zero"))
+		return
+	}
+
+	err := app.validator.Struct(params)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	// expired locks are recorded as changes when the locks are filtered, which has to happen before the
+	// changes are read
+	_, err = app.validLockedSeatIDs(r.Context(), showtimeID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	version, changedSeatIds, err := app.seatMapChanges(r.Context(), showtimeID, params.Since)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// the changes expired or the version was never handed out, the client starts over with the full map
+	full := params.Since > version
+
+	resp := api.SeatMapChangesResponse{
+		ShowtimeId: showtimeID,
+		Version:    version,
+		Full:       full,
+		Seats:      []api.Seat{},
+	}
+
+	if full || len(changedSeatIds) > 0 {
+		// the availability is read after the changes, changes made meanwhile are reported again next time
+		showtimeSeats, err := app.seatRepo.GetSeatsByShowtime(r.Context(), showtimeID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if len(showtimeSeats.Seats) == 0 {
+			app.notFoundResponse(w, r)
+			return
+		}
+
+		err = app.updateSeatAvailability(r.Context(), showtimeID, showtimeSeats)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		for _, seat := range showtimeSeats.Seats {
+			if full || changedSeatIds[seat.ID] {
+				resp.Seats = append(resp.Seats, toApiSeat(seat))
+			}
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// seatMapChanges returns the current seat map version of the showtime and the seats changed after the
+// given version.
+func (app *Application) seatMapChanges(ctx context.Context, showtimeID, since int) (int, map[int]bool, error) {
+	keys := []string{seatMapVersionKey(showtimeID), seatMapChangesKey(showtimeID)}
+
+	result, err := seatMapChangesScript.Run(ctx, app.redis, keys, since).Int64Slice()
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to run seatMapChanges script: %w", err)
+	}
+
+	changedSeatIds := make(map[int]bool, len(result)-1)
+	for _, seatID := range result[1:] {
+		changedSeatIds[int(seatID)] = true
+	}
+
+	return int(result[0]), changedSeatIds, nil
+}
+
+// seatMapVersion returns the current seat map version of the showtime, 0 before its first change.
+func (app *Application) seatMapVersion(ctx context.Context, showtimeID int) (int, error) {
+	version, err := app.redis.Get(ctx, seatMapVersionKey(showtimeID)).Int()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return 0, fmt.Errorf("failed to get seat map version: %w", err)
+	}
+
+	return version, nil
+}
+
+// awaitSeatMapVersion waits until the seat map version of the showtime reaches minVersion and returns the
+// version. It gives up after seatMapVersionWait and returns the version it saw last.
+func (app *Application) awaitSeatMapVersion(ctx context.Context, showtimeID, minVersion int) (int, error) {
+	deadline := time.Now().Add(seatMapVersionWait)
+
+	for {
+		version, err := app.seatMapVersion(ctx, showtimeID)
+		if err != nil || version >= minVersion || time.Now().After(deadline) {
+			return version, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return version, ctx.Err()
+		case <-time.After(seatMapVersionInterval):
+		}
+	}
+}
+
+// validLockedSeatIDs returns the seats of the showtime with a lock, dropping the expired locks.
+func (app *Application) validLockedSeatIDs(ctx context.Context, showtimeID int) ([]int64, error) {
+	keys := []string{seatSetKey(showtimeID), seatMapVersionKey(showtimeID), seatMapChangesKey(showtimeID)}
+
+	cmd := filterValidLockSeats.Run(ctx, app.redis, keys, showtimeID, int(seatMapChangesTTL.Seconds()))
 	lockedSeatIds, err := cmd.Int64Slice()
 	if err != nil {
-		return fmt.Errorf("failed to run filterValidLockSeats script: %w", err)
+		return nil, fmt.Errorf("failed to run filterValidLockSeats script: %w", err)
+	}
+
+	return lockedSeatIds, nil
+}
+
+func (app *Application) updateSeatAvailability(ctx context.Context, showtimeID int, showtimeSeats *domain.ShowtimeSeats) error {
+	lockedSeatIds, err := app.validLockedSeatIDs(ctx, showtimeID)
+	if err != nil {
+		return err
 	}
 
 	reservedSeats, err := app.reservationRepo.GetSeatsByShowtimeId(ctx, showtimeID)
@@ -92,7 +380,10 @@ func (app *Application) updateSeatAvailability(ctx context.Context, showtimeID i
 		return fmt.Errorf("failed to get reserved seats from DB: %w", err)
 	}
 
-	unavailableSeats := make(map[int]bool)
+	unavailableSeats, err := app.blockedSeatIDs(ctx, showtimeID)
+	if err != nil {
+		return err
+	}
 
 	for _, seatId := range lockedSeatIds {
 		unavailableSeats[int(seatId)] = true
@@ -111,6 +402,22 @@ func (app *Application) updateSeatAvailability(ctx context.Context, showtimeID i
 	return nil
 }
 
+// blockedSeatIDs returns the seats the theater blocked for the showtime, either for the showtime itself or
+// for a time range covering its start.
+func (app *Application) blockedSeatIDs(ctx context.Context, showtimeID int) (map[int]bool, error) {
+	blocks, err := app.seatRepo.GetSeatBlocksByShowtime(ctx, showtimeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get seat blocks from DB: %w", err)
+	}
+
+	blockedSeatIds := make(map[int]bool, len(blocks))
+	for _, block := range blocks {
+		blockedSeatIds[block.SeatID] = true
+	}
+
+	return blockedSeatIds, nil
+}
+
 func toSeatMapResponse(showtimeID int, showtimeSeats *domain.ShowtimeSeats) api.SeatMapResponse {
 	return api.SeatMapResponse{
 		TheaterId:   showtimeSeats.TheaterID,
@@ -134,17 +441,21 @@ func toSeatRows(seats []domain.Seat) []api.SeatRow {
 			currentRow = api.SeatRow{Row: v.Row}
 		}
 
-		currentRow.Seats = append(currentRow.Seats, api.Seat{
-			Id:         v.ID,
-			Row:        v.Row,
-			Column:     v.Col,
-			ExtraPrice: decimal.NewFromFloat(v.ExtraPrice),
-			Type:       api.SeatType(v.Type),
-			Available:  v.Available,
-		})
+		currentRow.Seats = append(currentRow.Seats, toApiSeat(v))
 	}
 
 	seatRows = append(seatRows, currentRow)
 
 	return seatRows
 }
+
+func toApiSeat(seat domain.Seat) api.Seat {
+	return api.Seat{
+		Id:         seat.ID,
+		Row:        seat.Row,
+		Column:     seat.Col,
+		ExtraPrice: decimal.NewFromFloat(seat.ExtraPrice),
+		Type:       api.SeatType(seat.Type),
+		Available:  seat.Available,
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/metinatakli/movie-reservation-system/api"
@@ -41,12 +42,14 @@ func TestSeatsSuite(t *testing.T) {
 
 func (s *SeatsTestSuite) TestGetSeatMapByShowtime() {
 	tests := []struct {
-		name           string
-		showtimeID     int
-		setupMocks     func()
-		wantStatus     int
-		wantResponse   *api.SeatMapResponse
-		wantErrMessage string
+		name             string
+		showtimeID       int
+		params           api.GetSeatMapByShowtimeParams
+		setupMocks       func()
+		wantStatus       int
+		wantResponse     *api.SeatMapResponse
+		wantErrMessage   string
+		wantCacheControl string
 	}{
 		{
 			name:           "should fail when showtime ID is zero or negative",
@@ -58,6 +61,8 @@ func (s *SeatsTestSuite) TestGetSeatMapByShowtime() {
 			name:       "should fail when seat data related to showtime is not found",
 			showtimeID: 999,
 			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, seatMapVersionKey(999)).Return(redis.NewStringResult("", redis.Nil))
+
 				s.seatRepo.On("GetSeatsByShowtime", mock.Anything, 999).Return(&domain.ShowtimeSeats{}, nil)
 			},
 			wantStatus: http.StatusNotFound,
@@ -66,6 +71,8 @@ func (s *SeatsTestSuite) TestGetSeatMapByShowtime() {
 			name:       "should fail when database error occurs while fetching seats",
 			showtimeID: 1,
 			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, seatMapVersionKey(1)).Return(redis.NewStringResult("", redis.Nil))
+
 				s.seatRepo.On("GetSeatsByShowtime", mock.Anything, 1).Return(nil, fmt.Errorf("database error"))
 			},
 			wantStatus:     http.StatusInternalServerError,
@@ -75,6 +82,8 @@ func (s *SeatsTestSuite) TestGetSeatMapByShowtime() {
 			name:       "should fail when redis script execution fails",
 			showtimeID: 1,
 			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, seatMapVersionKey(1)).Return(redis.NewStringResult("", redis.Nil))
+
 				s.seatRepo.On("GetSeatsByShowtime", mock.Anything, 1).Return(&domain.ShowtimeSeats{
 					TheaterID:   1,
 					TheaterName: "Test Theater",
@@ -85,16 +94,19 @@ func (s *SeatsTestSuite) TestGetSeatMapByShowtime() {
 					},
 				}, nil)
 
-				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, []string{seatSetKey(1)}, mock.Anything).
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatLockFilterKeys(1), mock.Anything, mock.Anything).
 					Return(redis.NewCmdResult(nil, fmt.Errorf("redis error")))
 			},
 			wantStatus:     http.StatusInternalServerError,
 			wantErrMessage: ErrInternalServer,
 		},
 		{
-			name:       "should return seat map with valid input",
-			showtimeID: 1,
+			name:             "should return seat map with valid input",
+			wantCacheControl: "public, max-age=5",
+			showtimeID:       1,
 			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, seatMapVersionKey(1)).Return(redis.NewStringResult("7", nil))
+
 				s.seatRepo.On("GetSeatsByShowtime", mock.Anything, 1).Return(&domain.ShowtimeSeats{
 					TheaterID:   1,
 					TheaterName: "Test Theater",
@@ -115,8 +127,10 @@ func (s *SeatsTestSuite) TestGetSeatMapByShowtime() {
 					},
 				}, nil)
 
-				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, []string{seatSetKey(1)}, mock.Anything).
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatLockFilterKeys(1), mock.Anything, mock.Anything).
 					Return(redis.NewCmdResult([]interface{}{"2", "4"}, nil))
+
+				s.seatRepo.On("GetSeatBlocksByShowtime", mock.Anything, 1).Return([]domain.SeatBlock{}, nil)
 			},
 			wantStatus: http.StatusOK,
 			wantResponse: &api.SeatMapResponse{
@@ -124,6 +138,7 @@ func (s *SeatsTestSuite) TestGetSeatMapByShowtime() {
 				TheaterName: "Test Theater",
 				HallId:      2,
 				ShowtimeId:  1,
+				Version:     7,
 				SeatRows: []api.SeatRow{
 					{
 						Row: 1,
@@ -142,6 +157,151 @@ func (s *SeatsTestSuite) TestGetSeatMapByShowtime() {
 				},
 			},
 		},
+		{
+			name:       "should fail when database error occurs while fetching seat blocks",
+			showtimeID: 1,
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, seatMapVersionKey(1)).Return(redis.NewStringResult("", redis.Nil))
+
+				s.seatRepo.On("GetSeatsByShowtime", mock.Anything, 1).Return(&domain.ShowtimeSeats{
+					TheaterID:   1,
+					TheaterName: "Test Theater",
+					HallID:      2,
+					Seats: []domain.Seat{
+						{ID: 1, Row: 1, Col: 1, Type: "Standard", Available: true},
+					},
+				}, nil)
+
+				s.reservationRepo.On("GetSeatsByShowtimeId", mock.Anything, 1).Return([]domain.ReservationSeat{}, nil)
+
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatLockFilterKeys(1), mock.Anything, mock.Anything).
+					Return(redis.NewCmdResult([]interface{}{}, nil))
+
+				s.seatRepo.On("GetSeatBlocksByShowtime", mock.Anything, 1).Return(nil, fmt.Errorf("database error"))
+			},
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+		{
+			name:       "should mark blocked seats as unavailable",
+			showtimeID: 1,
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, seatMapVersionKey(1)).Return(redis.NewStringResult("", redis.Nil))
+
+				s.seatRepo.On("GetSeatsByShowtime", mock.Anything, 1).Return(&domain.ShowtimeSeats{
+					TheaterID:   1,
+					TheaterName: "Test Theater",
+					HallID:      2,
+					Seats: []domain.Seat{
+						{ID: 1, Row: 1, Col: 1, Type: "Standard", Available: true},
+						{ID: 2, Row: 1, Col: 2, Type: "Recliner", Available: true},
+					},
+				}, nil)
+
+				s.reservationRepo.On("GetSeatsByShowtimeId", mock.Anything, 1).Return([]domain.ReservationSeat{}, nil)
+
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatLockFilterKeys(1), mock.Anything, mock.Anything).
+					Return(redis.NewCmdResult([]interface{}{}, nil))
+
+				s.seatRepo.On("GetSeatBlocksByShowtime", mock.Anything, 1).Return([]domain.SeatBlock{
+					{ID: 1, SeatID: 2, ShowtimeID: ptr(1), Reason: domain.SeatBlockBroken},
+				}, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: &api.SeatMapResponse{
+				TheaterId:   1,
+				TheaterName: "Test Theater",
+				HallId:      2,
+				ShowtimeId:  1,
+				SeatRows: []api.SeatRow{
+					{
+						Row: 1,
+						Seats: []api.Seat{
+							{Id: 1, Row: 1, Column: 1, Type: api.Standard, Available: true},
+							{Id: 2, Row: 1, Column: 2, Type: api.Recliner, Available: false},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:       "should fail when ticket sales of the showtime have not opened yet",
+			showtimeID: 1,
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, seatMapVersionKey(1)).Return(redis.NewStringResult("", redis.Nil))
+
+				s.seatRepo.On("GetSeatsByShowtime", mock.Anything, 1).Return(&domain.ShowtimeSeats{
+					TheaterID:   1,
+					TheaterName: "Test Theater",
+					HallID:      2,
+					Seats: []domain.Seat{
+						{ID: 1, Row: 1, Col: 1, Type: "Standard", Available: true},
+					},
+					SalesWindow: domain.SalesWindow{OpenAt: ptr(time.Now().Add(24 * time.Hour))},
+				}, nil)
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: domain.ErrSalesNotOpen.Error(),
+		},
+		{
+			name:           "should fail when the min version is invalid",
+			showtimeID:     1,
+			params:         api.GetSeatMapByShowtimeParams{MinVersion: ptr(0)},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: "must be at least 1",
+		},
+		{
+			name:       "should fail when the seat map version can't be read",
+			showtimeID: 1,
+			setupMocks: func() {
+				s.redisClient.On("Get", mock.Anything, seatMapVersionKey(1)).Return(redis.NewStringResult("", fmt.Errorf("redis error")))
+			},
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+		{
+			name:       "should bypass caches when the seat map is requested with the version of a cart change",
+			showtimeID: 1,
+			params:     api.GetSeatMapByShowtimeParams{MinVersion: ptr(8)},
+			setupMocks: func() {
+				// a lagging read is retried until the version of the change is visible
+				s.redisClient.On("Get", mock.Anything, seatMapVersionKey(1)).Return(redis.NewStringResult("7", nil)).Once()
+				s.redisClient.On("Get", mock.Anything, seatMapVersionKey(1)).Return(redis.NewStringResult("8", nil)).Once()
+
+				s.seatRepo.On("GetSeatsByShowtime", mock.Anything, 1).Return(&domain.ShowtimeSeats{
+					TheaterID:   1,
+					TheaterName: "Test Theater",
+					HallID:      2,
+					Seats: []domain.Seat{
+						{ID: 1, Row: 1, Col: 1, Type: "Standard", Available: true},
+					},
+				}, nil)
+
+				s.reservationRepo.On("GetSeatsByShowtimeId", mock.Anything, 1).Return([]domain.ReservationSeat{}, nil)
+
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatLockFilterKeys(1), mock.Anything, mock.Anything).
+					Return(redis.NewCmdResult([]interface{}{"1"}, nil))
+
+				s.seatRepo.On("GetSeatBlocksByShowtime", mock.Anything, 1).Return([]domain.SeatBlock{}, nil)
+			},
+			wantStatus:       http.StatusOK,
+			wantCacheControl: "no-store",
+			wantResponse: &api.SeatMapResponse{
+				TheaterId:   1,
+				TheaterName: "Test Theater",
+				HallId:      2,
+				ShowtimeId:  1,
+				Version:     8,
+				SeatRows: []api.SeatRow{
+					{
+						Row: 1,
+						Seats: []api.Seat{
+							{Id: 1, Row: 1, Column: 1, Type: api.Standard, Available: false},
+						},
+					},
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -156,10 +316,14 @@ func (s *SeatsTestSuite) TestGetSeatMapByShowtime() {
 			}
 
 			w, r := executeRequest(s.T(), http.MethodGet, fmt.Sprintf("/showtimes/%d/seats", tt.showtimeID), nil)
-			s.app.GetSeatMapByShowtime(w, r, tt.showtimeID)
+			s.app.GetSeatMapByShowtime(w, r, tt.showtimeID, tt.params)
 
 			s.Equal(tt.wantStatus, w.Code)
 
+			if tt.wantCacheControl != "" {
+				s.Equal(tt.wantCacheControl, w.Header().Get("Cache-Control"))
+			}
+
 			if tt.wantResponse != nil {
 				var response api.SeatMapResponse
 				err := json.NewDecoder(w.Body).Decode(&response)
@@ -179,3 +343,174 @@ func (s *SeatsTestSuite) TestGetSeatMapByShowtime() {
 		})
 	}
 }
+
+func (s *SeatsTestSuite) TestGetSeatMapChanges() {
+	showtimeSeats := func() *domain.ShowtimeSeats {
+		return &domain.ShowtimeSeats{
+			TheaterID: 1,
+			HallID:    2,
+			Seats: []domain.Seat{
+				{ID: 1, Row: 1, Col: 1, Type: "Standard", Available: true},
+				{ID: 2, Row: 1, Col: 2, Type: "Standard", Available: true},
+				{ID: 3, Row: 1, Col: 3, Type: "VIP", Available: true},
+			},
+		}
+	}
+
+	// seat 2 is locked, seat 3 was released
+	expectAvailability := func() {
+		s.seatRepo.On("GetSeatsByShowtime", mock.Anything, 1).Return(showtimeSeats(), nil).Once()
+		s.reservationRepo.On("GetSeatsByShowtimeId", mock.Anything, 1).Return([]domain.ReservationSeat{}, nil).Once()
+		s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatLockFilterKeys(1), mock.Anything, mock.Anything).
+			Return(redis.NewCmdResult([]interface{}{"2"}, nil)).Once()
+		s.seatRepo.On("GetSeatBlocksByShowtime", mock.Anything, 1).Return([]domain.SeatBlock{}, nil).Once()
+	}
+
+	tests := []struct {
+		name           string
+		showtimeID     int
+		since          int
+		setupMocks     func()
+		wantStatus     int
+		wantResponse   *api.SeatMapChangesResponse
+		wantErrMessage string
+	}{
+		{
+			name:           "should fail when showtime ID is zero or negative",
+			showtimeID:     0,
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "showtime ID must be greater than zero",
+		},
+		{
+			name:           "should fail when version is negative",
+			showtimeID:     1,
+			since:          -1,
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: "must be at least 0",
+		},
+		{
+			name:       "should fail when changes can't be read",
+			showtimeID: 1,
+			since:      4,
+			setupMocks: func() {
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatLockFilterKeys(1), mock.Anything, mock.Anything).
+					Return(redis.NewCmdResult([]interface{}{}, nil)).Once()
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatMapChangeKeys(1), 4).
+					Return(redis.NewCmdResult(nil, fmt.Errorf("redis error"))).Once()
+			},
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+		{
+			name:       "should return no seats without changes",
+			showtimeID: 1,
+			since:      4,
+			setupMocks: func() {
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatLockFilterKeys(1), mock.Anything, mock.Anything).
+					Return(redis.NewCmdResult([]interface{}{}, nil)).Once()
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatMapChangeKeys(1), 4).
+					Return(redis.NewCmdResult([]interface{}{int64(4)}, nil)).Once()
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: &api.SeatMapChangesResponse{
+				ShowtimeId: 1,
+				Version:    4,
+				Seats:      []api.Seat{},
+			},
+		},
+		{
+			name:       "should return only the changed seats",
+			showtimeID: 1,
+			since:      4,
+			setupMocks: func() {
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatLockFilterKeys(1), mock.Anything, mock.Anything).
+					Return(redis.NewCmdResult([]interface{}{"2"}, nil)).Once()
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatMapChangeKeys(1), 4).
+					Return(redis.NewCmdResult([]interface{}{int64(6), "2", "3"}, nil)).Once()
+				expectAvailability()
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: &api.SeatMapChangesResponse{
+				ShowtimeId: 1,
+				Version:    6,
+				Seats: []api.Seat{
+					{Id: 2, Row: 1, Column: 2, Type: api.Standard, Available: false},
+					{Id: 3, Row: 1, Column: 3, Type: api.VIP, Available: true},
+				},
+			},
+		},
+		{
+			name:       "should return every seat when the version is unknown",
+			showtimeID: 1,
+			since:      9,
+			setupMocks: func() {
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatLockFilterKeys(1), mock.Anything, mock.Anything).
+					Return(redis.NewCmdResult([]interface{}{"2"}, nil)).Once()
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatMapChangeKeys(1), 9).
+					Return(redis.NewCmdResult([]interface{}{int64(0)}, nil)).Once()
+				expectAvailability()
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: &api.SeatMapChangesResponse{
+				ShowtimeId: 1,
+				Version:    0,
+				Full:       true,
+				Seats: []api.Seat{
+					{Id: 1, Row: 1, Column: 1, Type: api.Standard, Available: true},
+					{Id: 2, Row: 1, Column: 2, Type: api.Standard, Available: false},
+					{Id: 3, Row: 1, Column: 3, Type: api.VIP, Available: true},
+				},
+			},
+		},
+		{
+			name:       "should fail when the showtime has no seats",
+			showtimeID: 1,
+			since:      4,
+			setupMocks: func() {
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatLockFilterKeys(1), mock.Anything, mock.Anything).
+					Return(redis.NewCmdResult([]interface{}{}, nil)).Once()
+				s.redisClient.On("EvalSha", mock.Anything, mock.Anything, seatMapChangeKeys(1), 4).
+					Return(redis.NewCmdResult([]interface{}{int64(5), "2"}, nil)).Once()
+				s.seatRepo.On("GetSeatsByShowtime", mock.Anything, 1).Return(&domain.ShowtimeSeats{}, nil).Once()
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			url := fmt.Sprintf("/showtimes/%d/seat-map/changes?since=%d", tt.showtimeID, tt.since)
+			w, r := executeRequest(s.T(), http.MethodGet, url, nil)
+			s.app.GetSeatMapChanges(w, r, tt.showtimeID, api.GetSeatMapChangesParams{Since: tt.since})
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantResponse != nil {
+				var response api.SeatMapChangesResponse
+				err := json.NewDecoder(w.Body).Decode(&response)
+				s.Require().NoError(err, "Failed to decode response")
+
+				diff := cmp.Diff(tt.wantResponse, &response)
+				s.Empty(diff, "Response mismatch (-want +got):\n%s", diff)
+			}
+
+			checkErrorResponse(s.T(), w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+
+			s.seatRepo.AssertExpectations(s.T())
+			s.redisClient.AssertExpectations(s.T())
+		})
+	}
+}
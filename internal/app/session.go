@@ -1,18 +1,50 @@
 package app
 
-import "net/http"
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
 
 type sessionKey string
 
 const (
 	SessionKeyUserId = sessionKey("userID")
 	SessionKeyGuest  = sessionKey("guest")
+	// unix time the user last proved their identity, either by logging in or by re-entering their password
+	SessionKeyAuthenticatedAt = sessionKey("authenticatedAt")
+	// unix time of the last request of the session
+	SessionKeyLastActiveAt = sessionKey("lastActiveAt")
+	SessionKeyRememberMe   = sessionKey("rememberMe")
 )
 
 func (s sessionKey) String() string {
 	return string(s)
 }
 
+// startAuthenticatedSession marks the session as freshly authenticated and sets its absolute lifetime.
+// Remembered sessions get a persistent cookie and are not subject to the idle timeout.
+func (app *Application) startAuthenticatedSession(r *http.Request, userId int, rememberMe bool) {
+	now := time.Now()
+
+	app.sessionManager.Put(r.Context(), SessionKeyUserId.String(), userId)
+	app.sessionManager.Put(r.Context(), SessionKeyAuthenticatedAt.String(), now.Unix())
+	app.sessionManager.Put(r.Context(), SessionKeyRememberMe.String(), rememberMe)
+	app.sessionManager.RememberMe(r.Context(), rememberMe)
+
+	lifetime := app.config.Session.Lifetime
+	if rememberMe {
+		lifetime = app.config.Session.RememberMeLifetime
+	}
+
+	if lifetime > 0 {
+		app.sessionManager.SetDeadline(r.Context(), now.Add(lifetime))
+	}
+}
+
 func (app *Application) contextGetUserId(r *http.Request) int {
 	userId, ok := r.Context().Value(SessionKeyUserId).(int)
 	if !ok {
@@ -21,3 +53,258 @@ func (app *Application) contextGetUserId(r *http.Request) int {
 
 	return userId
 }
+
+// loadAndSaveSession is the LoadAndSave middleware of scs. When session cookie keys are configured, the
+// token in the cookie is encrypted, so clients never store the key of their session in Redis in plain.
+// When the fallback of the session store is enabled, guest sessions committed while Redis is down are put
+// in the cookie.
+func (app *Application) loadAndSaveSession(next http.Handler) http.Handler {
+	loadAndSave := app.sessionManager.LoadAndSave(next)
+
+	if app.sessionCookieKeys == nil && !app.sessionFallbackEnabled() {
+		return loadAndSave
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &sealingResponseWriter{ResponseWriter: w, app: app}
+
+		if app.sessionFallbackEnabled() {
+			sw.fallback = &sessionFallback{}
+			r = r.WithContext(context.WithValue(r.Context(), sessionFallbackKey{}, sw.fallback))
+		}
+
+		if app.sessionCookieKeys != nil {
+			r = app.openSessionCookie(r)
+		}
+
+		loadAndSave.ServeHTTP(sw, r)
+
+		// scs writes the cookie without writing the header when the handler wrote nothing
+		sw.seal()
+	})
+}
+
+// openSessionCookie replaces the encrypted token in the session cookie of the request with the token
+// itself. A cookie which can't be decrypted is dropped and the request gets a new session.
+func (app *Application) openSessionCookie(r *http.Request) *http.Request {
+	name := app.sessionManager.Cookie.Name
+
+	if _, err := r.Cookie(name); err != nil {
+		return r
+	}
+
+	cookies := r.Cookies()
+
+	r = r.Clone(r.Context())
+	r.Header.Del("Cookie")
+
+	for _, cookie := range cookies {
+		if cookie.Name == name {
+			token, err := app.decryptSessionToken(cookie.Value)
+			if err != nil {
+				continue
+			}
+			cookie.Value = token
+		}
+
+		r.AddCookie(cookie)
+	}
+
+	return r
+}
+
+func (app *Application) encryptSessionToken(token string) (string, error) {
+	sealed, err := app.sessionCookieKeys.Encrypt([]byte(token), []byte(app.sessionManager.Cookie.Name))
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+func (app *Application) decryptSessionToken(value string) (string, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := app.sessionCookieKeys.Decrypt(sealed, []byte(app.sessionManager.Cookie.Name))
+	if err != nil {
+		return "", err
+	}
+
+	return string(token), nil
+}
+
+// sealingResponseWriter puts the guest session committed to the fallback in the session cookie set by
+// scs, and encrypts the token in the cookie, before the header is sent.
+type sealingResponseWriter struct {
+	http.ResponseWriter
+	app      *Application
+	fallback *sessionFallback
+	sealed   bool
+}
+
+func (sw *sealingResponseWriter) WriteHeader(code int) {
+	sw.seal()
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *sealingResponseWriter) Write(b []byte) (int, error) {
+	sw.seal()
+	return sw.ResponseWriter.Write(b)
+}
+
+// Unwrap exposes the underlying ResponseWriter to http.ResponseController, which is needed for streaming responses.
+func (sw *sealingResponseWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+func (sw *sealingResponseWriter) seal() {
+	if sw.sealed {
+		return
+	}
+	sw.sealed = true
+
+	prefix := sw.app.sessionManager.Cookie.Name + "="
+	header := sw.Header()
+
+	if sw.fallback != nil && (sw.fallback.degraded() || sw.fallback.value != "") {
+		header.Set(sessionDegradedHeader, "true")
+	}
+
+	values := header.Values("Set-Cookie")
+	if len(values) == 0 {
+		return
+	}
+
+	cookies := make([]string, 0, len(values))
+
+	for _, value := range values {
+		if !strings.HasPrefix(value, prefix) {
+			cookies = append(cookies, value)
+			continue
+		}
+
+		token, attributes, hasAttributes := strings.Cut(strings.TrimPrefix(value, prefix), ";")
+
+		if sw.fallback != nil {
+			switch {
+			case token != "" && token == sw.fallback.token:
+				token = sw.fallback.value
+			// the session couldn't be loaded, the cookie must outlive the outage of Redis
+			case sw.fallback.keepCookie:
+				continue
+			}
+		}
+
+		// the cookie removing the session has no token
+		if token != "" && sw.app.sessionCookieKeys != nil {
+			sealed, err := sw.app.encryptSessionToken(token)
+			if err != nil {
+				// a cookie carrying the token in plain must not be sent
+				cookies = append(cookies, prefix+"; Max-Age=0")
+				sw.app.logger.Error("failed to encrypt the session cookie", "error", err)
+				continue
+			}
+
+			token = sealed
+		}
+
+		value = prefix + token
+		if hasAttributes {
+			value += ";" + attributes
+		}
+
+		cookies = append(cookies, value)
+	}
+
+	header["Set-Cookie"] = cookies
+}
+
+// userSessionsKey is the set of the session tokens of the user. The tokens are added when the user logs in,
+// so their sessions can be ended without loading every session in the store.
+func userSessionsKey(userId int) string {
+	return fmt.Sprintf("user_sessions:%d", userId)
+}
+
+// indexUserSession adds the session of the request to the sessions of the user. The set is kept as long as
+// the longest session, tokens of sessions which ended meanwhile are left in it.
+func (app *Application) indexUserSession(r *http.Request, userId int) error {
+	ctx := r.Context()
+	key := userSessionsKey(userId)
+
+	pipe := app.redis.TxPipeline()
+	pipe.SAdd(ctx, key, app.sessionManager.Token(ctx))
+	pipe.Expire(ctx, key, max(app.sessionManager.Lifetime, app.config.Session.RememberMeLifetime))
+
+	_, err := pipe.Exec(ctx)
+
+	return err
+}
+
+// endUserSessions signs the user out everywhere: every session of the user in the session store and every
+// device session are deleted. The session of the request is destroyed too if it belongs to the user, it
+// would be saved again at the end of the request otherwise. Sessions already ended are skipped, so it can be
+// repeated after a failure.
+func (app *Application) endUserSessions(r *http.Request, userId int) error {
+	ctx := r.Context()
+
+	if app.sessionManager.GetInt(ctx, SessionKeyUserId.String()) == userId {
+		err := app.sessionManager.Destroy(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	key := userSessionsKey(userId)
+
+	tokens, err := app.redis.SMembers(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, token := range tokens {
+		err = app.sessionManager.Store.Delete(token)
+		if err != nil {
+			return err
+		}
+	}
+
+	// sessions started meanwhile stay indexed
+	if len(tokens) > 0 {
+		members := make([]any, len(tokens))
+		for i, token := range tokens {
+			members[i] = token
+		}
+
+		err = app.redis.SRem(ctx, key, members...).Err()
+		if err != nil {
+			return err
+		}
+	}
+
+	// sessions started before they were indexed are only found by scanning the store, it can be dropped
+	// once the longest of them has expired
+	err = app.sessionManager.Iterate(ctx, func(ctx context.Context) error {
+		if app.sessionManager.GetInt(ctx, SessionKeyUserId.String()) != userId {
+			return nil
+		}
+
+		return app.sessionManager.Destroy(ctx)
+	})
+	if err != nil {
+		return err
+	}
+
+	sessions, err := app.deviceSessionRepo.RevokeAllForUser(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		app.deleteAccessToken(ctx, session.AccessTokenHash)
+	}
+
+	return nil
+}
@@ -0,0 +1,239 @@
+package app
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// fallbackTokenPrefix marks the session tokens which carry the signed session data itself instead of the
+// key of the session in Redis
+const fallbackTokenPrefix = "fb."
+
+// browsers keep cookies of up to 4KB, the attributes of the cookie need some room
+const maxFallbackTokenSize = 3500
+
+// sessionDegradedHeader tells clients their session is kept in the fallback cookie, signing in is
+// unavailable until the session store recovers
+const sessionDegradedHeader = "X-Session-Degraded"
+
+var errSessionStoreUnavailable = errors.New("signing in is temporarily unavailable, please try again later")
+
+type sessionFallbackKey struct{}
+
+// sessionFallback is the state of the fallback for a request, shared by the store and the response
+// writer sealing the session cookie.
+type sessionFallback struct {
+	// Redis failed during the request
+	failed bool
+	// the session of the request was loaded from the fallback cookie
+	fromCookie bool
+	// the session was committed to the fallback cookie under the token scs writes to the cookie
+	token string
+	value string
+	// the cookie of the session which couldn't be loaded must be kept, it is valid again once Redis
+	// recovers
+	keepCookie bool
+}
+
+func (fb *sessionFallback) degraded() bool {
+	return fb.failed || fb.fromCookie
+}
+
+func sessionFallbackFromContext(ctx context.Context) *sessionFallback {
+	fb, _ := ctx.Value(sessionFallbackKey{}).(*sessionFallback)
+	return fb
+}
+
+// failoverSessionStore keeps the sessions in Redis. When Redis fails, guest sessions are kept in a signed
+// cookie, so browsing and carts of guests keep working. Sessions of signed in users are never written to
+// the cookie: they can't be revoked there, and a stolen cookie couldn't be logged out.
+type failoverSessionStore struct {
+	primary scs.CtxStore
+	codec   scs.Codec
+	key     []byte
+}
+
+func newFailoverSessionStore(primary scs.CtxStore, codec scs.Codec, key []byte) *failoverSessionStore {
+	return &failoverSessionStore{primary: primary, codec: codec, key: key}
+}
+
+func (s *failoverSessionStore) FindCtx(ctx context.Context, token string) ([]byte, bool, error) {
+	fb := sessionFallbackFromContext(ctx)
+
+	if strings.HasPrefix(token, fallbackTokenPrefix) {
+		b, found := s.open(token, time.Now())
+		if found && fb != nil {
+			fb.fromCookie = true
+		}
+
+		return b, found, nil
+	}
+
+	b, found, err := s.primary.FindCtx(ctx, token)
+	if err != nil && fb != nil {
+		// the request goes on as a guest, the cookie is left as it is
+		fb.failed = true
+		fb.keepCookie = true
+		return nil, false, nil
+	}
+
+	return b, found, err
+}
+
+func (s *failoverSessionStore) CommitCtx(ctx context.Context, token string, b []byte, expiry time.Time) error {
+	fb := sessionFallbackFromContext(ctx)
+
+	// once Redis failed, the request doesn't wait for it again
+	if !strings.HasPrefix(token, fallbackTokenPrefix) && (fb == nil || !fb.failed) {
+		err := s.primary.CommitCtx(ctx, token, b, expiry)
+		if err == nil || fb == nil {
+			return err
+		}
+
+		fb.failed = true
+	}
+
+	if fb == nil {
+		return errSessionStoreUnavailable
+	}
+
+	// the data of the session the request couldn't load is lost, the cookie keeps pointing to Redis
+	if fb.keepCookie {
+		return nil
+	}
+
+	_, values, err := s.codec.Decode(b)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := values[SessionKeyUserId.String()]; ok {
+		return errSessionStoreUnavailable
+	}
+
+	value := s.seal(b, expiry)
+	if len(value) > maxFallbackTokenSize {
+		return fmt.Errorf("fallback session of %d bytes doesn't fit in a cookie", len(value))
+	}
+
+	fb.token = token
+	fb.value = value
+
+	return nil
+}
+
+func (s *failoverSessionStore) DeleteCtx(ctx context.Context, token string) error {
+	// the fallback cookie is removed by scs, there is nothing to delete
+	if strings.HasPrefix(token, fallbackTokenPrefix) {
+		return nil
+	}
+
+	return s.primary.DeleteCtx(ctx, token)
+}
+
+// AllCtx returns the sessions in Redis. Sessions in the fallback cookie are of guests only and can't be
+// listed anyway.
+func (s *failoverSessionStore) AllCtx(ctx context.Context) (map[string][]byte, error) {
+	store, ok := s.primary.(scs.IterableCtxStore)
+	if !ok {
+		return nil, fmt.Errorf("session store %T does not support iteration", s.primary)
+	}
+
+	return store.AllCtx(ctx)
+}
+
+func (s *failoverSessionStore) Find(token string) ([]byte, bool, error) {
+	return s.FindCtx(context.Background(), token)
+}
+
+func (s *failoverSessionStore) Commit(token string, b []byte, expiry time.Time) error {
+	return s.CommitCtx(context.Background(), token, b, expiry)
+}
+
+func (s *failoverSessionStore) Delete(token string) error {
+	return s.DeleteCtx(context.Background(), token)
+}
+
+// seal signs the session data along with its expiry.
+func (s *failoverSessionStore) seal(b []byte, expiry time.Time) string {
+	payload := binary.BigEndian.AppendUint64(nil, uint64(expiry.Unix()))
+	payload = append(payload, b...)
+
+	return fallbackTokenPrefix + base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(s.sign(payload))
+}
+
+// open returns the session data of a fallback token which is signed and not expired.
+func (s *failoverSessionStore) open(token string, now time.Time) ([]byte, bool) {
+	encodedPayload, encodedMAC, ok := strings.Cut(strings.TrimPrefix(token, fallbackTokenPrefix), ".")
+	if !ok {
+		return nil, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil || len(payload) < 8 {
+		return nil, false
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil || !hmac.Equal(mac, s.sign(payload)) {
+		return nil, false
+	}
+
+	expiry := time.Unix(int64(binary.BigEndian.Uint64(payload[:8])), 0)
+	if !now.Before(expiry) {
+		return nil, false
+	}
+
+	return payload[8:], true
+}
+
+func (s *failoverSessionStore) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(payload)
+
+	return mac.Sum(nil)
+}
+
+// sessionFallbackEnabled reports whether guest sessions fall back to the cookie when Redis fails.
+func (app *Application) sessionFallbackEnabled() bool {
+	if app.sessionManager == nil {
+		return false
+	}
+
+	_, ok := app.sessionManager.Store.(*failoverSessionStore)
+	return ok
+}
+
+// sessionStoreAvailable reports whether sessions of signed in users can be loaded and stored. A session
+// in the fallback cookie was issued while Redis was down, so Redis is checked again.
+func (app *Application) sessionStoreAvailable(r *http.Request) bool {
+	fb := sessionFallbackFromContext(r.Context())
+
+	switch {
+	case fb == nil:
+		return true
+	case fb.failed:
+		return false
+	case fb.fromCookie:
+		return app.redis.Ping(r.Context()).Err() == nil
+	default:
+		return true
+	}
+}
+
+func (app *Application) sessionStoreUnavailableResponse(w http.ResponseWriter, r *http.Request) {
+	app.logClientError(r, errSessionStoreUnavailable.Error())
+	w.Header().Set("Retry-After", "30")
+	app.errorResponseWithErr(w, r, http.StatusServiceUnavailable, errSessionStoreUnavailable)
+}
@@ -0,0 +1,249 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/envelope"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSessionManager(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1"})
+	defer client.Close()
+
+	tests := []struct {
+		name         string
+		cfg          SessionConfig
+		wantErr      string
+		wantSameSite http.SameSite
+	}{
+		{
+			name:         "defaults to lax",
+			cfg:          SessionConfig{CookieSecure: true, CookieDomain: "cinex.example.com"},
+			wantSameSite: http.SameSiteLaxMode,
+		},
+		{
+			name:         "strict",
+			cfg:          SessionConfig{CookieSameSite: "strict"},
+			wantSameSite: http.SameSiteStrictMode,
+		},
+		{
+			name:         "none over HTTPS",
+			cfg:          SessionConfig{CookieSameSite: "none", CookieSecure: true},
+			wantSameSite: http.SameSiteNoneMode,
+		},
+		{
+			name:    "none without HTTPS",
+			cfg:     SessionConfig{CookieSameSite: "none"},
+			wantErr: "a session cookie with SameSite=None must be secure",
+		},
+		{
+			name:    "unknown mode",
+			cfg:     SessionConfig{CookieSameSite: "loose"},
+			wantErr: `unknown SameSite mode "loose"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessionManager, err := NewSessionManager(client, tt.cfg)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantSameSite, sessionManager.Cookie.SameSite)
+			assert.Equal(t, tt.cfg.CookieSecure, sessionManager.Cookie.Secure)
+			assert.Equal(t, tt.cfg.CookieDomain, sessionManager.Cookie.Domain)
+		})
+	}
+}
+
+func TestLoadAndSaveSessionEncryptsToken(t *testing.T) {
+	keys, err := envelope.ParseKeyring("k1:MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := newTestApplication(func(a *Application) {
+		a.sessionManager = scs.New()
+		a.sessionCookieKeys = keys
+	})
+
+	handler := app.loadAndSaveSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			app.sessionManager.Put(r.Context(), SessionKeyUserId.String(), 42)
+		}
+
+		w.Write([]byte(strconv.Itoa(app.sessionManager.GetInt(r.Context(), SessionKeyUserId.String()))))
+	}))
+
+	request := func(path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w
+	}
+
+	w := request("/login", nil)
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("got %d cookies, want 1", len(cookies))
+	}
+
+	cookie := cookies[0]
+	assert.True(t, cookie.HttpOnly, "the attributes of the cookie are lost")
+
+	token, err := app.decryptSessionToken(cookie.Value)
+	assert.NoError(t, err)
+	assert.NotEqual(t, token, cookie.Value, "the token is sent in plain")
+
+	// the encrypted cookie loads the session
+	w = request("/", cookie)
+	assert.Equal(t, "42", w.Body.String())
+
+	// the plain token doesn't
+	w = request("/", &http.Cookie{Name: cookie.Name, Value: token})
+	assert.Equal(t, "0", w.Body.String())
+}
+
+var errRedisDown = errors.New("dial tcp: connection refused")
+
+// unavailableSessionStore is a session store whose Redis is down
+type unavailableSessionStore struct{}
+
+func (unavailableSessionStore) Find(string) ([]byte, bool, error)      { return nil, false, errRedisDown }
+func (unavailableSessionStore) Commit(string, []byte, time.Time) error { return errRedisDown }
+func (unavailableSessionStore) Delete(string) error                    { return errRedisDown }
+func (s unavailableSessionStore) FindCtx(context.Context, string) ([]byte, bool, error) {
+	return s.Find("")
+}
+func (unavailableSessionStore) CommitCtx(context.Context, string, []byte, time.Time) error {
+	return errRedisDown
+}
+func (unavailableSessionStore) DeleteCtx(context.Context, string) error { return errRedisDown }
+
+func TestLoadAndSaveSessionFallback(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1"})
+	defer client.Close()
+
+	app := newTestApplication(func(a *Application) {
+		a.redis = client
+		a.sessionManager = scs.New()
+		a.sessionManager.Store = newFailoverSessionStore(unavailableSessionStore{}, a.sessionManager.Codec, []byte("fallback-key"))
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		visits := app.sessionManager.GetInt(r.Context(), "visits") + 1
+		app.sessionManager.Put(r.Context(), "visits", visits)
+
+		w.Write([]byte(strconv.Itoa(visits)))
+	})
+	mux.Handle("/me", app.requireAuthentication(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	handler := app.loadAndSaveSession(app.ensureGuestUserSession(mux))
+
+	request := func(path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w
+	}
+
+	sessionCookie := func(w *httptest.ResponseRecorder) *http.Cookie {
+		for _, cookie := range w.Result().Cookies() {
+			if cookie.Name == app.sessionManager.Cookie.Name {
+				return cookie
+			}
+		}
+
+		return nil
+	}
+
+	// guests get a session in the cookie
+	w := request("/", nil)
+	assert.Equal(t, "1", w.Body.String())
+	assert.Equal(t, "true", w.Header().Get(sessionDegradedHeader))
+
+	cookie := sessionCookie(w)
+	if cookie == nil {
+		t.Fatal("no session cookie")
+	}
+	assert.True(t, strings.HasPrefix(cookie.Value, fallbackTokenPrefix), "the session isn't in the cookie")
+	assert.True(t, cookie.HttpOnly, "the attributes of the cookie are lost")
+
+	w = request("/", cookie)
+	assert.Equal(t, "2", w.Body.String())
+
+	// a tampered cookie starts a new session
+	tampered := *cookie
+	tampered.Value = strings.Replace(cookie.Value, ".", ".A", 1)
+	w = request("/", &tampered)
+	assert.Equal(t, "1", w.Body.String())
+
+	// the cookie of a session in Redis is kept for when Redis recovers
+	w = request("/", &http.Cookie{Name: cookie.Name, Value: "redis-token"})
+	assert.Equal(t, "1", w.Body.String())
+	assert.Equal(t, "true", w.Header().Get(sessionDegradedHeader))
+	assert.Nil(t, sessionCookie(w), "the cookie of the session in Redis is replaced")
+
+	// signed in users are told the session store is down instead of being asked to sign in
+	for _, c := range []*http.Cookie{cookie, {Name: cookie.Name, Value: "redis-token"}} {
+		w = request("/me", c)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+		var resp api.ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		assert.Equal(t, api.SESSIONSTOREUNAVAILABLE, resp.Code)
+	}
+}
+
+func TestFailoverSessionStoreRefusesUserSessions(t *testing.T) {
+	codec := scs.GobCodec{}
+	store := newFailoverSessionStore(unavailableSessionStore{}, codec, []byte("fallback-key"))
+
+	ctx := context.WithValue(context.Background(), sessionFallbackKey{}, &sessionFallback{})
+	expiry := time.Now().Add(time.Hour)
+
+	b, err := codec.Encode(expiry, map[string]interface{}{SessionKeyUserId.String(): 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.CommitCtx(ctx, "token", b, expiry)
+	assert.ErrorIs(t, err, errSessionStoreUnavailable)
+
+	// expired fallback sessions aren't loaded
+	value := store.seal([]byte("data"), time.Now().Add(-time.Second))
+	_, found, err := store.FindCtx(ctx, value)
+	assert.NoError(t, err)
+	assert.False(t, found)
+}
@@ -0,0 +1,106 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/shopspring/decimal"
+)
+
+// AdjustShowtimePrices changes the base prices of the upcoming showtimes matching the filters of the
+// request, all or none of them. A dry run previews the changes with their estimated revenue impact.
+func (app *Application) AdjustShowtimePrices(w http.ResponseWriter, r *http.Request) {
+	logger := app.contextGetLogger(r)
+
+	var input api.ShowtimePriceAdjustmentRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	from, err := time.Parse(time.DateOnly, input.From)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	to, err := time.Parse(time.DateOnly, input.To)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	adjustment := domain.BasePriceAdjustment{
+		Kind:      domain.BasePriceAdjustmentKind(input.Kind),
+		Value:     input.Value,
+		From:      from,
+		To:        to,
+		TheaterID: input.TheaterId,
+	}
+
+	if input.Format != nil {
+		format := domain.ScreeningFormat(*input.Format)
+		adjustment.Format = &format
+	}
+
+	dryRun := input.DryRun != nil && *input.DryRun
+
+	changes, err := app.theaterRepo.AdjustShowtimePrices(r.Context(), adjustment, dryRun)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidPriceAdjustment):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	resp := api.ShowtimePriceAdjustmentResult{
+		DryRun:        dryRun,
+		ShowtimeCount: len(changes),
+		RevenueImpact: decimal.Zero,
+		Showtimes:     make([]api.ShowtimePriceChange, len(changes)),
+	}
+
+	for i, c := range changes {
+		resp.Showtimes[i] = api.ShowtimePriceChange{
+			ShowtimeId:     c.ShowtimeID,
+			TheaterId:      c.TheaterID,
+			StartTime:      c.StartTime,
+			Format:         api.ScreeningFormat(c.Format),
+			OldPrice:       c.OldPrice,
+			NewPrice:       c.NewPrice,
+			AvailableSeats: c.AvailableSeats,
+			RevenueImpact:  c.RevenueImpact(),
+		}
+
+		resp.RevenueImpact = resp.RevenueImpact.Add(resp.Showtimes[i].RevenueImpact)
+	}
+
+	if !dryRun {
+		logger.Info("showtime base prices adjusted",
+			"kind", adjustment.Kind,
+			"value", adjustment.Value.String(),
+			"from", input.From,
+			"to", input.To,
+			"showtimes", len(changes))
+	}
+
+	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/shopspring/decimal"
+)
+
+func TestAdjustShowtimePrices(t *testing.T) {
+	startTime := time.Date(2025, 6, 14, 20, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		input          map[string]any
+		wantStatus     int
+		wantDryRun     bool
+		wantNewPrices  []string
+		wantImpact     string
+		wantAdjustment bool
+	}{
+		{
+			name:       "should fail when the range ends before it starts",
+			input:      map[string]any{"from": "2025-06-30", "to": "2025-06-01", "kind": "percent", "value": "10"},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "should fail for an unknown kind",
+			input:      map[string]any{"from": "2025-06-01", "to": "2025-06-30", "kind": "ratio", "value": "10"},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "should fail when a price would be negative",
+			input:          map[string]any{"from": "2025-06-01", "to": "2025-06-30", "kind": "fixed", "value": "-15"},
+			wantStatus:     http.StatusBadRequest,
+			wantAdjustment: true,
+		},
+		{
+			name: "should preview the changes of a dry run",
+			input: map[string]any{
+				"from": "2025-06-01", "to": "2025-06-30", "kind": "percent", "value": "-10", "format": "IMAX", "dryRun": true,
+			},
+			wantStatus:     http.StatusOK,
+			wantDryRun:     true,
+			wantNewPrices:  []string{"9", "13.5"},
+			wantImpact:     "-42.5",
+			wantAdjustment: true,
+		},
+		{
+			name:           "should apply a fixed adjustment",
+			input:          map[string]any{"from": "2025-06-01", "to": "2025-06-30", "kind": "fixed", "value": "1.50", "theaterId": 3},
+			wantStatus:     http.StatusOK,
+			wantNewPrices:  []string{"11.5", "16.5"},
+			wantImpact:     "52.5",
+			wantAdjustment: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var adjusted bool
+
+			app := newTestApplication(func(a *Application) {
+				a.theaterRepo = &mocks.MockTheaterRepo{
+					AdjustShowtimePricesFunc: func(
+						ctx context.Context,
+						adjustment domain.BasePriceAdjustment,
+						dryRun bool) ([]domain.ShowtimePriceChange, error) {
+
+						adjusted = true
+
+						if dryRun != tt.wantDryRun {
+							t.Errorf("dry run = %v, want %v", dryRun, tt.wantDryRun)
+						}
+
+						changes := []domain.ShowtimePriceChange{
+							{ShowtimeID: 5, TheaterID: 3, StartTime: startTime, OldPrice: decimal.NewFromInt(10), AvailableSeats: 20},
+							{ShowtimeID: 6, TheaterID: 3, StartTime: startTime, OldPrice: decimal.NewFromInt(15), AvailableSeats: 15},
+						}
+
+						for i := range changes {
+							price, err := adjustment.Apply(changes[i].OldPrice)
+							if err != nil {
+								return nil, err
+							}
+
+							changes[i].NewPrice = price
+						}
+
+						return changes, nil
+					},
+				}
+			})
+
+			w, r := executeRequest(t, http.MethodPost, "/admin/showtimes/price-adjustment", tt.input)
+			app.AdjustShowtimePrices(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status code = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+
+			if adjusted != tt.wantAdjustment {
+				t.Fatalf("adjusted = %v, want %v", adjusted, tt.wantAdjustment)
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var resp api.ShowtimePriceAdjustmentResult
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+
+			if resp.DryRun != tt.wantDryRun || resp.ShowtimeCount != len(tt.wantNewPrices) {
+				t.Fatalf("unexpected result: %+v", resp)
+			}
+
+			for i, want := range tt.wantNewPrices {
+				if got := resp.Showtimes[i].NewPrice.String(); got != want {
+					t.Errorf("new price of showtime %d = %s, want %s", resp.Showtimes[i].ShowtimeId, got, want)
+				}
+			}
+
+			if got := resp.RevenueImpact.String(); got != tt.wantImpact {
+				t.Errorf("revenue impact = %s, want %s", got, tt.wantImpact)
+			}
+		})
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/log"
 	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
 	"go.opentelemetry.io/otel/sdk/resource"
 	"go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
@@ -68,8 +69,10 @@ func (app *Application) InitTelemetry() (func(context.Context), error) {
 		return nil, errors.New("failed to create otel metric exporter")
 	}
 
+	// exemplars link the latency and error metrics to the sampled traces of the requests
 	meterProvider := metric.NewMeterProvider(
 		metric.WithResource(res),
+		metric.WithExemplarFilter(exemplar.TraceBasedFilter),
 		metric.WithReader(metric.NewPeriodicReader(metricExporter, metric.WithInterval(15*time.Second))),
 	)
 
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mailer"
+)
+
+// defaultBrandName is shown when the deployment doesn't serve several brands.
+const defaultBrandName = "CineX"
+
+var errUnknownTenant = errors.New("unknown tenant")
+
+// resolveTenant scopes the request to the brand named by the X-Tenant header, or else to the brand
+// served under the hostname of the request. Requests matching no brand are served by the default one.
+func (app *Application) resolveTenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(app.tenants) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		tenant, err := app.tenantOf(r)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		ctx := domain.WithTenant(r.Context(), tenant)
+		ctx = context.WithValue(ctx, loggerContextKey, app.contextGetLogger(r).With("tenant", tenant.Slug))
+
+		if tenant.MailSender != "" {
+			ctx = mailer.WithSender(ctx, tenant.MailSender)
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (app *Application) tenantOf(r *http.Request) (*domain.Tenant, error) {
+	if slug := r.Header.Get("X-Tenant"); slug != "" {
+		for i := range app.tenants {
+			if app.tenants[i].Slug == slug {
+				return &app.tenants[i], nil
+			}
+		}
+
+		return nil, errUnknownTenant
+	}
+
+	var fallback *domain.Tenant
+
+	for i := range app.tenants {
+		if app.tenants[i].ServesHost(r.Host) {
+			return &app.tenants[i], nil
+		}
+
+		if app.tenants[i].Slug == domain.DefaultTenantSlug {
+			fallback = &app.tenants[i]
+		}
+	}
+
+	if fallback == nil {
+		return nil, errUnknownTenant
+	}
+
+	return fallback, nil
+}
+
+// GetBranding relies on resolveTenant, the X-Tenant header is read there.
+func (app *Application) GetBranding(w http.ResponseWriter, r *http.Request, params api.GetBrandingParams) {
+	resp := api.Branding{
+		Slug: domain.DefaultTenantSlug,
+		Name: defaultBrandName,
+	}
+
+	if tenant := domain.TenantFromContext(r.Context()); tenant != nil {
+		resp = api.Branding{
+			Slug:         tenant.Slug,
+			Name:         tenant.Name,
+			LogoUrl:      tenant.LogoUrl,
+			PrimaryColor: tenant.PrimaryColor,
+		}
+	}
+
+	err := app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
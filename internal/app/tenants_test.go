@@ -0,0 +1,124 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+func TestResolveTenant(t *testing.T) {
+	tenants := []domain.Tenant{
+		{ID: 1, Slug: domain.DefaultTenantSlug, Name: "CineX"},
+		{ID: 2, Slug: "starlight", Name: "Starlight", Hostnames: []string{"tickets.starlight.example"}},
+	}
+
+	tests := []struct {
+		name       string
+		tenants    []domain.Tenant
+		host       string
+		header     string
+		wantStatus int
+		wantTenant int
+	}{
+		{
+			name:       "leaves requests unscoped without tenants",
+			host:       "tickets.starlight.example",
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "resolves the tenant by hostname",
+			tenants:    tenants,
+			host:       "Tickets.Starlight.example:443",
+			wantStatus: http.StatusNoContent,
+			wantTenant: 2,
+		},
+		{
+			name:       "prefers the header over the hostname",
+			tenants:    tenants,
+			host:       "tickets.starlight.example",
+			header:     domain.DefaultTenantSlug,
+			wantStatus: http.StatusNoContent,
+			wantTenant: 1,
+		},
+		{
+			name:       "falls back to the default tenant",
+			tenants:    tenants,
+			host:       "api.example.com",
+			wantStatus: http.StatusNoContent,
+			wantTenant: 1,
+		},
+		{
+			name:       "rejects an unknown tenant header",
+			tenants:    tenants,
+			host:       "api.example.com",
+			header:     "moonlight",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(func(a *Application) {
+				a.tenants = tt.tenants
+			})
+
+			var tenantID int
+			handler := app.resolveTenant(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tenantID = domain.TenantIDFromContext(r.Context())
+				w.WriteHeader(http.StatusNoContent)
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/movies", nil)
+			r.Host = tt.host
+			if tt.header != "" {
+				r.Header.Set("X-Tenant", tt.header)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			if tenantID != tt.wantTenant {
+				t.Errorf("tenant = %d, want %d", tenantID, tt.wantTenant)
+			}
+		})
+	}
+}
+
+func TestGetBranding(t *testing.T) {
+	app := newTestApplication()
+
+	w, r := executeRequest(t, http.MethodGet, "/branding", nil)
+	app.GetBranding(w, r, api.GetBrandingParams{})
+
+	var branding api.Branding
+	if err := json.NewDecoder(w.Body).Decode(&branding); err != nil {
+		t.Fatal(err)
+	}
+
+	if branding != (api.Branding{Slug: domain.DefaultTenantSlug, Name: defaultBrandName}) {
+		t.Errorf("got %+v without tenants", branding)
+	}
+
+	tenant := &domain.Tenant{ID: 2, Slug: "starlight", Name: "Starlight", LogoUrl: "https://cdn.example/logo.svg", PrimaryColor: "#1e3a8a"}
+
+	w, r = executeRequest(t, http.MethodGet, "/branding", nil)
+	app.GetBranding(w, r.WithContext(domain.WithTenant(r.Context(), tenant)), api.GetBrandingParams{})
+
+	branding = api.Branding{}
+	if err := json.NewDecoder(w.Body).Decode(&branding); err != nil {
+		t.Fatal(err)
+	}
+
+	want := api.Branding{Slug: "starlight", Name: "Starlight", LogoUrl: "https://cdn.example/logo.svg", PrimaryColor: "#1e3a8a"}
+	if branding != want {
+		t.Errorf("got %+v, want %+v", branding, want)
+	}
+}
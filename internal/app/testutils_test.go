@@ -12,15 +12,22 @@ import (
 	"github.com/metinatakli/movie-reservation-system/api"
 	"github.com/metinatakli/movie-reservation-system/internal/mocks"
 	"github.com/metinatakli/movie-reservation-system/internal/validator"
+	"github.com/stretchr/testify/mock"
 )
 
 func newTestApplication(opts ...func(*Application)) *Application {
+	// webhook tests don't care about the event store, storing always succeeds unless a test overrides it
+	webhookEventRepo := &mocks.MockWebhookEventRepo{}
+	webhookEventRepo.On("Store", mock.Anything, mock.Anything).Return(true, nil).Maybe()
+
 	app := &Application{
-		validator: validator.NewValidator(),
-		logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
-		userRepo:  &mocks.MockUserRepo{},
-		tokenRepo: &mocks.MockTokenRepo{},
-		mailer:    &MockMailer{},
+		config:           Config{PasswordPolicy: validator.DefaultPasswordPolicy()},
+		validator:        validator.NewValidator(validator.DefaultSchedulingHorizon, validator.DefaultPasswordPolicy()),
+		logger:           slog.New(slog.NewTextHandler(io.Discard, nil)),
+		userRepo:         &mocks.MockUserRepo{},
+		tokenRepo:        &mocks.MockTokenRepo{},
+		webhookEventRepo: webhookEventRepo,
+		mailer:           &MockMailer{},
 	}
 
 	for _, opt := range opts {
@@ -91,6 +98,33 @@ func checkErrorResponse(t *testing.T, w *httptest.ResponseRecorder, tt struct {
 	}
 }
 
+// checkErrorCode checks the machine readable code of an error response. The body is left unread, so
+// checkErrorResponse can still be called.
+func checkErrorCode(t *testing.T, w *httptest.ResponseRecorder, wantCode api.ErrorCode) {
+	t.Helper()
+
+	var errorResp struct {
+		Code api.ErrorCode `json:"code"`
+	}
+	if err := json.NewDecoder(bytes.NewReader(w.Body.Bytes())).Decode(&errorResp); err != nil {
+		t.Fatalf("Failed to decode error response: %v", err)
+	}
+
+	if errorResp.Code != wantCode {
+		t.Errorf("Error code = %v, want %v", errorResp.Code, wantCode)
+	}
+}
+
 func ptr[T any](v T) *T {
 	return &v
 }
+
+// seatLockFilterKeys are the keys of the filterValidLockSeats script for the showtime
+func seatLockFilterKeys(showtimeID int) []string {
+	return []string{seatSetKey(showtimeID), seatMapVersionKey(showtimeID), seatMapChangesKey(showtimeID)}
+}
+
+// seatMapChangeKeys are the keys of the recordSeatEvent and seatMapChangesScript scripts for the showtime
+func seatMapChangeKeys(showtimeID int) []string {
+	return []string{seatMapVersionKey(showtimeID), seatMapChangesKey(showtimeID)}
+}
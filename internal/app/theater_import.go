@@ -0,0 +1,592 @@
+package app
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	maxImportTheaters     = 100
+	maxImportHallSeats    = 1000
+	maxImportBytes        = 1_048_576
+	maxImportSeatPosition = 100
+)
+
+// theaterImportColumns are the columns of an import CSV, every line is a range of seats of a hall row. The
+// time_zone column is optional, a missing column is read as empty.
+var theaterImportColumns = []string{
+	"theater_name", "address", "city", "district", "latitude", "longitude", "phone_number", "email", "website",
+	"theater_amenities", "hall_name", "hall_amenities", "row", "from_col", "to_col", "seat_type", "extra_price",
+}
+
+// theaterImportJSONFields are the names of the CSV columns in the JSON bundle
+var theaterImportJSONFields = map[string]string{
+	"theater_name":      "name",
+	"phone_number":      "phoneNumber",
+	"time_zone":         "timeZone",
+	"theater_amenities": "amenities",
+	"hall_name":         "name",
+	"hall_amenities":    "amenities",
+	"from_col":          "fromCol",
+	"to_col":            "toCol",
+	"seat_type":         "seatType",
+	"extra_price":       "extraPrice",
+}
+
+var seatTypes = []api.SeatType{api.Standard, api.VIP, api.Recliner, api.Accessible}
+
+// theaterImportBundle is the body of an import request in the JSON form. The CSV lines the theaters, halls
+// and seat rows of a CSV bundle come from are kept, so that errors are reported by line.
+type theaterImportBundle struct {
+	theaters []api.TheaterImportTheater
+	errors   []api.TheaterImportError
+
+	csv          bool
+	theaterLines []int
+	hallLines    [][]int
+	rowLines     [][][]int
+}
+
+// location returns the CSV line or the JSON path of a theater, a hall or a seat row. hall and row are -1
+// for the theater and the hall themselves.
+func (b *theaterImportBundle) location(theater, hall, row int) string {
+	if b.csv {
+		switch {
+		case row >= 0:
+			return fmt.Sprintf("line %d", b.rowLines[theater][hall][row])
+		case hall >= 0:
+			return fmt.Sprintf("line %d", b.hallLines[theater][hall])
+		default:
+			return fmt.Sprintf("line %d", b.theaterLines[theater])
+		}
+	}
+
+	location := fmt.Sprintf("theaters[%d]", theater)
+	if hall >= 0 {
+		location += fmt.Sprintf(".halls[%d]", hall)
+	}
+	if row >= 0 {
+		location += fmt.Sprintf(".rows[%d]", row)
+	}
+
+	return location
+}
+
+// addError reports an invalid field, given by its CSV column, of a theater, a hall or a seat row.
+func (b *theaterImportBundle) addError(theater, hall, row int, column, message string) {
+	field := column
+	if name, ok := theaterImportJSONFields[column]; ok && !b.csv {
+		field = name
+	}
+
+	b.errors = append(b.errors, api.TheaterImportError{
+		Location: b.location(theater, hall, row),
+		Field:    &field,
+		Message:  message,
+	})
+}
+
+// ImportTheaters creates the theaters of a JSON or CSV bundle with their halls and seats. The bundle is
+// validated as a whole first, nothing is created when any line or item is invalid.
+func (app *Application) ImportTheaters(w http.ResponseWriter, r *http.Request, params api.ImportTheatersParams) {
+	var bundle *theaterImportBundle
+	var err error
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	switch mediaType {
+	case "text/csv":
+		bundle, err = readTheaterImportCSV(http.MaxBytesReader(w, r.Body, maxImportBytes))
+	case "application/json", "":
+		var input api.TheaterImportRequest
+
+		err = app.readJSON(w, r, &input)
+		bundle = &theaterImportBundle{theaters: input.Theaters}
+	default:
+		err = fmt.Errorf("unsupported content type %q, expected application/json or text/csv", mediaType)
+	}
+
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if len(bundle.theaters) == 0 && len(bundle.errors) == 0 {
+		app.badRequestResponse(w, r, fmt.Errorf("the bundle has no theaters"))
+		return
+	}
+
+	if len(bundle.theaters) > maxImportTheaters {
+		app.badRequestResponse(w, r, fmt.Errorf("the bundle must not have more than %d theaters", maxImportTheaters))
+		return
+	}
+
+	amenities, err := app.theaterRepo.GetAmenities(r.Context())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	theaters := app.validateTheaterImport(bundle, amenities)
+	hallCount, seatCount := domain.TheaterImportCounts(theaters)
+
+	resp := api.TheaterImportResult{
+		DryRun:       params.DryRun != nil && *params.DryRun,
+		TheaterCount: len(theaters),
+		HallCount:    hallCount,
+		SeatCount:    seatCount,
+		TheaterIds:   []int{},
+		Errors:       bundle.errors,
+	}
+
+	if len(bundle.errors) > 0 {
+		app.logClientError(r, "theater import has invalid items")
+
+		err = app.writeJSON(w, http.StatusUnprocessableEntity, resp, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	resp.Errors = []api.TheaterImportError{}
+
+	if resp.DryRun {
+		err = app.writeJSON(w, http.StatusOK, resp, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	resp.TheaterIds, err = app.theaterRepo.ImportTheaters(r.Context(), theaters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	resp.Committed = true
+
+	app.contextGetLogger(r).Info("theaters imported",
+		"theaters", resp.TheaterCount,
+		"halls", resp.HallCount,
+		"seats", resp.SeatCount)
+
+	err = app.writeJSON(w, http.StatusCreated, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readTheaterImportCSV groups the lines of a CSV bundle by theater and hall. The theater and hall columns
+// must be the same on all lines of a theater or hall. Lines whose values can't be parsed are reported and
+// left out. An error is only returned if the CSV itself is malformed.
+func readTheaterImportCSV(body io.Reader) (*theaterImportBundle, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("body must not be empty")
+		}
+
+		return nil, fmt.Errorf("malformed CSV: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for _, name := range theaterImportColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("CSV column %q is missing", name)
+		}
+	}
+
+	bundle := &theaterImportBundle{csv: true}
+
+	theaterIndexes := make(map[string]int)
+	theaterRecords := make(map[int][]string)
+	hallIndexes := make(map[[2]string]int)
+	hallRecords := make(map[[2]string]string)
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("malformed CSV: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		get := func(column string) string {
+			i, ok := columns[column]
+			if !ok {
+				return ""
+			}
+
+			return strings.TrimSpace(record[i])
+		}
+
+		lineError := func(column, message string) {
+			bundle.errors = append(bundle.errors, api.TheaterImportError{
+				Location: fmt.Sprintf("line %d", line),
+				Field:    &column,
+				Message:  message,
+			})
+		}
+
+		theaterName := get("theater_name")
+		if theaterName == "" {
+			lineError("theater_name", "must not be empty")
+			continue
+		}
+
+		theaterColumns := []string{
+			get("address"), get("city"), get("district"), get("latitude"), get("longitude"),
+			get("phone_number"), get("email"), get("website"), get("theater_amenities"), get("time_zone"),
+		}
+
+		t, ok := theaterIndexes[strings.ToLower(theaterName)]
+		if !ok {
+			t = len(bundle.theaters)
+			theaterIndexes[strings.ToLower(theaterName)] = t
+			theaterRecords[t] = theaterColumns
+
+			theater := api.TheaterImportTheater{
+				Name:        theaterName,
+				Address:     get("address"),
+				City:        get("city"),
+				District:    get("district"),
+				PhoneNumber: optionalString(get("phone_number")),
+				Email:       optionalString(get("email")),
+				Website:     optionalString(get("website")),
+				TimeZone:    optionalString(get("time_zone")),
+				Amenities:   splitAmenities(get("theater_amenities")),
+				Halls:       []api.TheaterImportHall{},
+			}
+
+			theater.Latitude, err = strconv.ParseFloat(get("latitude"), 64)
+			if err != nil {
+				lineError("latitude", "must be a number")
+			}
+
+			theater.Longitude, err = strconv.ParseFloat(get("longitude"), 64)
+			if err != nil {
+				lineError("longitude", "must be a number")
+			}
+
+			bundle.theaters = append(bundle.theaters, theater)
+			bundle.theaterLines = append(bundle.theaterLines, line)
+			bundle.hallLines = append(bundle.hallLines, nil)
+			bundle.rowLines = append(bundle.rowLines, nil)
+		} else if !slices.Equal(theaterRecords[t], theaterColumns) {
+			lineError("theater_name", fmt.Sprintf("theater columns differ from line %d", bundle.theaterLines[t]))
+			continue
+		}
+
+		hallName := get("hall_name")
+		if hallName == "" {
+			lineError("hall_name", "must not be empty")
+			continue
+		}
+
+		hallKey := [2]string{strings.ToLower(theaterName), strings.ToLower(hallName)}
+
+		h, ok := hallIndexes[hallKey]
+		if !ok {
+			h = len(bundle.theaters[t].Halls)
+			hallIndexes[hallKey] = h
+			hallRecords[hallKey] = get("hall_amenities")
+
+			bundle.theaters[t].Halls = append(bundle.theaters[t].Halls, api.TheaterImportHall{
+				Name:      hallName,
+				Amenities: splitAmenities(get("hall_amenities")),
+				Rows:      []api.TheaterImportSeatRow{},
+			})
+			bundle.hallLines[t] = append(bundle.hallLines[t], line)
+			bundle.rowLines[t] = append(bundle.rowLines[t], nil)
+		} else if hallRecords[hallKey] != get("hall_amenities") {
+			lineError("hall_amenities", fmt.Sprintf("hall columns differ from line %d", bundle.hallLines[t][h]))
+			continue
+		}
+
+		row := api.TheaterImportSeatRow{SeatType: api.SeatType(get("seat_type"))}
+		valid := true
+
+		for _, field := range []struct {
+			column string
+			dst    *int
+		}{
+			{"row", &row.Row},
+			{"from_col", &row.FromCol},
+			{"to_col", &row.ToCol},
+		} {
+			*field.dst, err = strconv.Atoi(get(field.column))
+			if err != nil {
+				lineError(field.column, "must be an integer")
+				valid = false
+			}
+		}
+
+		if extraPrice := get("extra_price"); extraPrice != "" {
+			price, err := decimal.NewFromString(extraPrice)
+			if err != nil {
+				lineError("extra_price", "must be a decimal number")
+				valid = false
+			}
+			row.ExtraPrice = &price
+		}
+
+		if !valid {
+			continue
+		}
+
+		bundle.theaters[t].Halls[h].Rows = append(bundle.theaters[t].Halls[h].Rows, row)
+		bundle.rowLines[t][h] = append(bundle.rowLines[t][h], line)
+	}
+
+	return bundle, nil
+}
+
+// validateTheaterImport reports the invalid theaters, halls and seat rows of the bundle in its errors and
+// returns the theaters to be created. The theaters are only complete when there are no errors.
+func (app *Application) validateTheaterImport(bundle *theaterImportBundle, amenities []domain.Amenity) []domain.TheaterImport {
+	amenityIDs := make(map[string]int, len(amenities))
+	for _, amenity := range amenities {
+		amenityIDs[strings.ToLower(amenity.Name)] = amenity.ID
+	}
+
+	resolveAmenities := func(theater, hall int, column string, names *[]string) []int {
+		if names == nil {
+			return nil
+		}
+
+		ids := make([]int, 0, len(*names))
+		seen := make(map[int]bool, len(*names))
+
+		for _, name := range *names {
+			id, ok := amenityIDs[strings.ToLower(strings.TrimSpace(name))]
+			if !ok {
+				bundle.addError(theater, hall, -1, column, fmt.Sprintf("unknown amenity %q", name))
+				continue
+			}
+
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+
+		return ids
+	}
+
+	theaters := make([]domain.TheaterImport, len(bundle.theaters))
+	theaterNames := make(map[string]bool, len(bundle.theaters))
+
+	for t, input := range bundle.theaters {
+		theater := domain.TheaterImport{
+			Name:        strings.TrimSpace(input.Name),
+			Address:     strings.TrimSpace(input.Address),
+			City:        strings.TrimSpace(input.City),
+			District:    strings.TrimSpace(input.District),
+			Latitude:    input.Latitude,
+			Longitude:   input.Longitude,
+			PhoneNumber: strings.TrimSpace(valueOrEmpty(input.PhoneNumber)),
+			Email:       strings.TrimSpace(valueOrEmpty(input.Email)),
+			Website:     strings.TrimSpace(valueOrEmpty(input.Website)),
+			TimeZone:    strings.TrimSpace(valueOrEmpty(input.TimeZone)),
+			AmenityIDs:  resolveAmenities(t, -1, "theater_amenities", input.Amenities),
+			Halls:       make([]domain.HallImport, len(input.Halls)),
+		}
+
+		for _, field := range []struct {
+			column string
+			value  string
+		}{
+			{"theater_name", theater.Name},
+			{"address", theater.Address},
+			{"city", theater.City},
+			{"district", theater.District},
+		} {
+			if field.value == "" {
+				bundle.addError(t, -1, -1, field.column, "must not be empty")
+			}
+		}
+
+		if theaterNames[strings.ToLower(theater.Name)] {
+			bundle.addError(t, -1, -1, "theater_name", "appears more than once")
+		}
+		theaterNames[strings.ToLower(theater.Name)] = true
+
+		if theater.Latitude < -90 || theater.Latitude > 90 {
+			bundle.addError(t, -1, -1, "latitude", "must be between -90 and 90")
+		}
+
+		if theater.Longitude < -180 || theater.Longitude > 180 {
+			bundle.addError(t, -1, -1, "longitude", "must be between -180 and 180")
+		}
+
+		if theater.Email != "" && app.validator.Var(theater.Email, "email") != nil {
+			bundle.addError(t, -1, -1, "email", "must be a valid email address")
+		}
+
+		if theater.TimeZone == "" {
+			theater.TimeZone = domain.DefaultTimeZone
+		} else if !domain.ValidTimeZone(theater.TimeZone) {
+			bundle.addError(t, -1, -1, "time_zone", "must be an IANA time zone, e.g. Europe/Istanbul")
+		}
+
+		if len(input.Halls) == 0 {
+			bundle.addError(t, -1, -1, "hall_name", "the theater must have at least one hall")
+		}
+
+		hallNames := make(map[string]bool, len(input.Halls))
+
+		for h, hallInput := range input.Halls {
+			hall := domain.HallImport{
+				Name:       strings.TrimSpace(hallInput.Name),
+				AmenityIDs: resolveAmenities(t, h, "hall_amenities", hallInput.Amenities),
+			}
+
+			if hall.Name == "" {
+				bundle.addError(t, h, -1, "hall_name", "must not be empty")
+			}
+
+			if hallNames[strings.ToLower(hall.Name)] {
+				bundle.addError(t, h, -1, "hall_name", "appears more than once in the theater")
+			}
+			hallNames[strings.ToLower(hall.Name)] = true
+
+			if len(hallInput.Rows) == 0 {
+				bundle.addError(t, h, -1, "row", "the hall must have at least one seat")
+			}
+
+			positions := make(map[[2]int]bool)
+
+			for i, row := range hallInput.Rows {
+				seats, ok := validateSeatRow(bundle, t, h, i, row)
+				if !ok {
+					continue
+				}
+
+				for _, seat := range seats {
+					position := [2]int{seat.Row, seat.Col}
+					if positions[position] {
+						bundle.addError(t, h, i, "from_col",
+							fmt.Sprintf("seat at row %d, column %d appears more than once", seat.Row, seat.Col))
+						break
+					}
+					positions[position] = true
+				}
+
+				hall.Seats = append(hall.Seats, seats...)
+			}
+
+			if len(hall.Seats) > maxImportHallSeats {
+				bundle.addError(t, h, -1, "hall_name", fmt.Sprintf("the hall must not have more than %d seats", maxImportHallSeats))
+			}
+
+			theater.Halls[h] = hall
+		}
+
+		theaters[t] = theater
+	}
+
+	return theaters
+}
+
+// validateSeatRow returns the seats of a range of a hall row, or false if the range is invalid.
+func validateSeatRow(bundle *theaterImportBundle, theater, hall, i int, row api.TheaterImportSeatRow) ([]domain.HallTemplateSeat, bool) {
+	valid := true
+
+	if row.Row < 1 || row.Row > maxImportSeatPosition {
+		bundle.addError(theater, hall, i, "row", fmt.Sprintf("must be between 1 and %d", maxImportSeatPosition))
+		valid = false
+	}
+
+	if row.FromCol < 1 || row.FromCol > maxImportSeatPosition {
+		bundle.addError(theater, hall, i, "from_col", fmt.Sprintf("must be between 1 and %d", maxImportSeatPosition))
+		valid = false
+	}
+
+	if row.ToCol < row.FromCol || row.ToCol > maxImportSeatPosition {
+		bundle.addError(theater, hall, i, "to_col",
+			fmt.Sprintf("must be between %d and %d", max(row.FromCol, 1), maxImportSeatPosition))
+		valid = false
+	}
+
+	seatType := ""
+	for _, t := range seatTypes {
+		if strings.EqualFold(string(row.SeatType), string(t)) {
+			seatType = string(t)
+		}
+	}
+
+	if seatType == "" {
+		bundle.addError(theater, hall, i, "seat_type", "must be one of Standard, VIP, Recliner, Accessible")
+		valid = false
+	}
+
+	extraPrice := decimal.Zero
+	if row.ExtraPrice != nil {
+		extraPrice = row.ExtraPrice.Round(2)
+	}
+
+	if extraPrice.IsNegative() || extraPrice.GreaterThan(maxSeatExtraPrice) {
+		bundle.addError(theater, hall, i, "extra_price", fmt.Sprintf("must be between 0 and %s", maxSeatExtraPrice))
+		valid = false
+	}
+
+	if !valid {
+		return nil, false
+	}
+
+	seats := make([]domain.HallTemplateSeat, 0, row.ToCol-row.FromCol+1)
+
+	for col := row.FromCol; col <= row.ToCol; col++ {
+		seats = append(seats, domain.HallTemplateSeat{
+			Row:        row.Row,
+			Col:        col,
+			Type:       seatType,
+			ExtraPrice: extraPrice,
+		})
+	}
+
+	return seats, true
+}
+
+func splitAmenities(value string) *[]string {
+	if value == "" {
+		return nil
+	}
+
+	names := strings.Split(value, ";")
+
+	return &names
+}
+
+func valueOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
@@ -0,0 +1,309 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/suite"
+)
+
+type TheaterImportTestSuite struct {
+	suite.Suite
+	app         *Application
+	theaterRepo *mocks.MockTheaterRepo
+	imported    []domain.TheaterImport
+}
+
+func (s *TheaterImportTestSuite) SetupTest() {
+	s.imported = nil
+
+	s.theaterRepo = &mocks.MockTheaterRepo{
+		GetAmenitiesFunc: func(ctx context.Context) ([]domain.Amenity, error) {
+			return []domain.Amenity{
+				{ID: 1, Name: "Parking"},
+				{ID: 2, Name: "Dolby Atmos"},
+			}, nil
+		},
+		ImportTheatersFunc: func(ctx context.Context, theaters []domain.TheaterImport) ([]int, error) {
+			s.imported = theaters
+
+			ids := make([]int, len(theaters))
+			for i := range theaters {
+				ids[i] = 10 + i
+			}
+
+			return ids, nil
+		},
+	}
+
+	s.app = newTestApplication(func(a *Application) {
+		a.theaterRepo = s.theaterRepo
+	})
+}
+
+func TestTheaterImportSuite(t *testing.T) {
+	suite.Run(t, new(TheaterImportTestSuite))
+}
+
+func theaterImportJSON(halls ...map[string]any) map[string]any {
+	return map[string]any{
+		"theaters": []map[string]any{
+			{
+				"name":      "CineX Mall",
+				"address":   "Main St. 1",
+				"city":      "Ankara",
+				"district":  "Cankaya",
+				"latitude":  39.9,
+				"longitude": 32.8,
+				"email":     "mall@example.com",
+				"amenities": []string{"parking"},
+				"halls":     halls,
+			},
+		},
+	}
+}
+
+const theaterImportHeader = "theater_name,address,city,district,latitude,longitude,phone_number,email,website," +
+	"theater_amenities,hall_name,hall_amenities,row,from_col,to_col,seat_type,extra_price\n"
+
+func (s *TheaterImportTestSuite) TestImportTheatersJSON() {
+	hall := map[string]any{
+		"name":      "Hall 1",
+		"amenities": []string{"Dolby Atmos"},
+		"rows": []map[string]any{
+			{"row": 1, "fromCol": 1, "toCol": 10, "seatType": "Standard"},
+			{"row": 2, "fromCol": 1, "toCol": 2, "seatType": "Accessible"},
+			{"row": 2, "fromCol": 3, "toCol": 10, "seatType": "vip", "extraPrice": "5"},
+		},
+	}
+
+	s.Run("dry run only validates the bundle", func() {
+		s.SetupTest()
+
+		w, r := executeRequest(s.T(), http.MethodPost, "/admin/import/theaters?dryRun=true", theaterImportJSON(hall))
+		s.app.ImportTheaters(w, r, api.ImportTheatersParams{DryRun: ptr(true)})
+
+		s.Equal(http.StatusOK, w.Code)
+
+		var resp api.TheaterImportResult
+		s.Require().NoError(json.NewDecoder(w.Body).Decode(&resp))
+
+		s.Equal(api.TheaterImportResult{
+			DryRun:       true,
+			TheaterCount: 1,
+			HallCount:    1,
+			SeatCount:    20,
+			TheaterIds:   []int{},
+			Errors:       []api.TheaterImportError{},
+		}, resp)
+		s.Nil(s.imported)
+	})
+
+	s.Run("creates the theaters", func() {
+		s.SetupTest()
+
+		w, r := executeRequest(s.T(), http.MethodPost, "/admin/import/theaters", theaterImportJSON(hall))
+		s.app.ImportTheaters(w, r, api.ImportTheatersParams{})
+
+		s.Equal(http.StatusCreated, w.Code)
+
+		var resp api.TheaterImportResult
+		s.Require().NoError(json.NewDecoder(w.Body).Decode(&resp))
+
+		s.True(resp.Committed)
+		s.Equal([]int{10}, resp.TheaterIds)
+
+		s.Require().Len(s.imported, 1)
+		s.Equal([]int{1}, s.imported[0].AmenityIDs)
+		s.Equal("mall@example.com", s.imported[0].Email)
+		s.Equal(domain.DefaultTimeZone, s.imported[0].TimeZone)
+
+		s.Require().Len(s.imported[0].Halls, 1)
+		s.Equal([]int{2}, s.imported[0].Halls[0].AmenityIDs)
+
+		seats := s.imported[0].Halls[0].Seats
+		s.Require().Len(seats, 20)
+		s.Equal(domain.HallTemplateSeat{Row: 2, Col: 2, Type: "Accessible", ExtraPrice: decimal.Zero}, seats[11])
+		s.Equal("VIP", seats[12].Type)
+		s.True(decimal.NewFromInt(5).Equal(seats[12].ExtraPrice))
+	})
+
+	s.Run("takes the time zone of the theater", func() {
+		s.SetupTest()
+
+		body := theaterImportJSON(hall)
+		body["theaters"].([]map[string]any)[0]["timeZone"] = "Europe/Istanbul"
+
+		w, r := executeRequest(s.T(), http.MethodPost, "/admin/import/theaters", body)
+		s.app.ImportTheaters(w, r, api.ImportTheatersParams{})
+
+		s.Equal(http.StatusCreated, w.Code)
+		s.Require().Len(s.imported, 1)
+		s.Equal("Europe/Istanbul", s.imported[0].TimeZone)
+	})
+
+	s.Run("rejects an unknown time zone", func() {
+		s.SetupTest()
+
+		body := theaterImportJSON(hall)
+		body["theaters"].([]map[string]any)[0]["timeZone"] = "Local"
+
+		w, r := executeRequest(s.T(), http.MethodPost, "/admin/import/theaters", body)
+		s.app.ImportTheaters(w, r, api.ImportTheatersParams{})
+
+		s.Equal(http.StatusUnprocessableEntity, w.Code)
+
+		var resp api.TheaterImportResult
+		s.Require().NoError(json.NewDecoder(w.Body).Decode(&resp))
+
+		s.Equal([]api.TheaterImportError{
+			{Location: "theaters[0]", Field: ptr("timeZone"), Message: "must be an IANA time zone, e.g. Europe/Istanbul"},
+		}, resp.Errors)
+		s.Nil(s.imported)
+	})
+
+	s.Run("reports every invalid item and creates nothing", func() {
+		s.SetupTest()
+
+		invalidHall := map[string]any{
+			"name":      "Hall 2",
+			"amenities": []string{"Jacuzzi"},
+			"rows": []map[string]any{
+				{"row": 1, "fromCol": 5, "toCol": 4, "seatType": "Standard"},
+				{"row": 2, "fromCol": 1, "toCol": 5, "seatType": "Standard"},
+				{"row": 2, "fromCol": 5, "toCol": 6, "seatType": "Standard"},
+			},
+		}
+
+		w, r := executeRequest(s.T(), http.MethodPost, "/admin/import/theaters", theaterImportJSON(hall, invalidHall))
+		s.app.ImportTheaters(w, r, api.ImportTheatersParams{})
+
+		s.Equal(http.StatusUnprocessableEntity, w.Code)
+
+		var resp api.TheaterImportResult
+		s.Require().NoError(json.NewDecoder(w.Body).Decode(&resp))
+
+		s.False(resp.Committed)
+		s.Equal([]api.TheaterImportError{
+			{Location: "theaters[0].halls[1]", Field: ptr("amenities"), Message: `unknown amenity "Jacuzzi"`},
+			{Location: "theaters[0].halls[1].rows[0]", Field: ptr("toCol"), Message: "must be between 5 and 100"},
+			{Location: "theaters[0].halls[1].rows[2]", Field: ptr("fromCol"), Message: "seat at row 2, column 5 appears more than once"},
+		}, resp.Errors)
+		s.Nil(s.imported)
+	})
+}
+
+func (s *TheaterImportTestSuite) TestImportTheatersCSV() {
+	tests := []struct {
+		name           string
+		body           string
+		contentType    string
+		wantStatus     int
+		wantErrMessage string
+		wantErrors     []api.TheaterImportError
+		wantSeatCount  int
+	}{
+		{
+			name: "groups the lines by theater and hall",
+			body: theaterImportHeader +
+				"CineX Mall,Main St. 1,Ankara,Cankaya,39.9,32.8,,,,Parking,Hall 1,,1,1,10,Standard,\n" +
+				"CineX Mall,Main St. 1,Ankara,Cankaya,39.9,32.8,,,,Parking,Hall 1,,2,1,10,Recliner,7.50\n" +
+				"CineX Mall,Main St. 1,Ankara,Cankaya,39.9,32.8,,,,Parking,Hall 2,Dolby Atmos,1,1,8,Standard,\n" +
+				"CineX Park,Park Ave. 2,Izmir,Bornova,38.4,27.2,,,,,IMAX,,1,1,12,VIP,3\n",
+			contentType:   "text/csv",
+			wantStatus:    http.StatusCreated,
+			wantSeatCount: 40,
+		},
+		{
+			name: "reports the invalid lines",
+			body: theaterImportHeader +
+				"CineX Mall,Main St. 1,Ankara,Cankaya,39.9,32.8,,,,Parking,Hall 1,,1,1,10,Standard,\n" +
+				"CineX Mall,Main St. 2,Ankara,Cankaya,39.9,32.8,,,,Parking,Hall 1,,2,1,10,Standard,\n" +
+				"CineX Mall,Main St. 1,Ankara,Cankaya,39.9,32.8,,,,Parking,Hall 1,,x,1,10,Standard,\n" +
+				"CineX Mall,Main St. 1,Ankara,Cankaya,39.9,32.8,,,,Parking,Hall 1,,3,1,10,Balcony,\n",
+			contentType: "text/csv; charset=utf-8",
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantErrors: []api.TheaterImportError{
+				{Location: "line 3", Field: ptr("theater_name"), Message: "theater columns differ from line 2"},
+				{Location: "line 4", Field: ptr("row"), Message: "must be an integer"},
+				{Location: "line 5", Field: ptr("seat_type"), Message: "must be one of Standard, VIP, Recliner, Accessible"},
+			},
+		},
+		{
+			name:           "missing column",
+			body:           "theater_name,address\nCineX Mall,Main St. 1\n",
+			contentType:    "text/csv",
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: `CSV column "city" is missing`,
+		},
+		{
+			name:           "no theaters",
+			body:           theaterImportHeader,
+			contentType:    "text/csv",
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "the bundle has no theaters",
+		},
+		{
+			name:           "unsupported content type",
+			body:           "<theaters/>",
+			contentType:    "application/xml",
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: `unsupported content type "application/xml", expected application/json or text/csv`,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			r := httptest.NewRequest(http.MethodPost, "/admin/import/theaters", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+
+			s.app.ImportTheaters(w, r, api.ImportTheatersParams{})
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantStatus == http.StatusCreated || tt.wantStatus == http.StatusUnprocessableEntity {
+				var resp api.TheaterImportResult
+				s.Require().NoError(json.NewDecoder(w.Body).Decode(&resp))
+
+				if tt.wantErrors != nil {
+					s.Equal(tt.wantErrors, resp.Errors)
+					s.Nil(s.imported)
+					return
+				}
+
+				s.Equal(2, resp.TheaterCount)
+				s.Equal(3, resp.HallCount)
+				s.Equal(tt.wantSeatCount, resp.SeatCount)
+				s.Equal([]int{10, 11}, resp.TheaterIds)
+
+				s.Require().Len(s.imported, 2)
+				s.Equal("CineX Mall", s.imported[0].Name)
+				s.Equal([]int{1}, s.imported[0].AmenityIDs)
+				s.Equal([]int{2}, s.imported[0].Halls[1].AmenityIDs)
+				s.True(decimal.RequireFromString("7.50").Equal(s.imported[0].Halls[0].Seats[10].ExtraPrice))
+				s.Equal("Bornova", s.imported[1].District)
+
+				return
+			}
+
+			checkErrorResponse(s.T(), w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+		})
+	}
+}
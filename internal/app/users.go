@@ -4,15 +4,19 @@ import (
 	"context"
 	"crypto/sha256"
 	"errors"
+	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/metinatakli/movie-reservation-system/api"
 	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mailer"
 	"github.com/oapi-codegen/runtime/types"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const pendingChangesTokenTTL = 30 * time.Minute
+
 func (app *Application) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
 	userId := app.contextGetUserId(r)
 
@@ -30,16 +34,24 @@ func (app *Application) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp := api.UserResponse{
-		Id:        user.ID,
-		FirstName: user.FirstName,
-		LastName:  user.LastName,
-		Email:     user.Email,
-		BirthDate: types.Date{Time: user.BirthDate},
-		Gender:    api.Gender(user.Gender),
-		Activated: user.Activated,
-		Version:   user.Version,
-		CreatedAt: user.CreatedAt,
+	resp := toApiUserResponse(user)
+
+	preferences, err := app.userRepo.GetPreferences(r.Context(), userId)
+	if err != nil && !errors.Is(err, domain.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if preferences != nil {
+		resp.Preferences = toApiUserPreferences(preferences)
+	}
+
+	if user.PendingChanges != nil && user.PendingChanges.MarketingEmails != nil {
+		if resp.Preferences == nil {
+			resp.Preferences = &api.UserPreferences{}
+		}
+
+		resp.Preferences.PendingChanges = toApiPendingChanges(user.PendingChanges)
 	}
 
 	err = app.writeJSON(w, http.StatusOK, resp, nil)
@@ -102,6 +114,236 @@ func (app *Application) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	resp := toApiUserResponse(user)
+
+	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) UpdateUserPreferences(w http.ResponseWriter, r *http.Request) {
+	var input api.UpdateUserPreferencesRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	userId := app.contextGetUserId(r)
+
+	preferences, err := app.userRepo.GetPreferences(r.Context(), userId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			preferences = &domain.UserPreferences{UserID: userId}
+		default:
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	if input.Latitude != nil && input.Longitude != nil {
+		preferences.Latitude = input.Latitude
+		preferences.Longitude = input.Longitude
+	}
+	if input.FavoriteTheaterId != nil {
+		preferences.FavoriteTheaterID = input.FavoriteTheaterId
+	}
+	if input.Language != nil {
+		preferences.Language = input.Language
+	}
+	if input.SeatType != nil {
+		seatType := string(*input.SeatType)
+		preferences.SeatType = &seatType
+	}
+	if input.NotificationDigest != nil {
+		preferences.NotificationDigest = *input.NotificationDigest
+	}
+
+	var pending *domain.PendingChanges
+
+	if input.MarketingEmails != nil {
+		// an opt-in changes the emails sent to the user's address, so it waits until it's verified from there
+		if *input.MarketingEmails && !preferences.MarketingEmails {
+			pending = &domain.PendingChanges{
+				MarketingEmails: input.MarketingEmails,
+				Source:          domain.ConsentSourcePreferences,
+			}
+		} else {
+			preferences.MarketingEmails = *input.MarketingEmails
+		}
+	}
+
+	err = app.userRepo.UpsertPreferences(r.Context(), preferences)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrTheaterNotFound):
+			app.notFoundResponseWithErr(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	status := http.StatusOK
+	resp := toApiUserPreferences(preferences)
+
+	switch {
+	case pending != nil:
+		err = app.requestPendingChanges(r, userId, *pending)
+		if err != nil {
+			switch {
+			case errors.Is(err, domain.ErrRecordNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+
+			return
+		}
+
+		status = http.StatusAccepted
+		resp.PendingChanges = toApiPendingChanges(pending)
+	case input.MarketingEmails != nil:
+		// an opt-in that isn't verified yet mustn't override a later opt-out
+		err = app.userRepo.DiscardPendingChanges(r.Context(), userId)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.writeJSON(w, status, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// requestPendingChanges keeps the changes on the user record and emails the token verifying them to the
+// user. Requesting changes again replaces the pending ones and invalidates the previous token.
+func (app *Application) requestPendingChanges(r *http.Request, userId int, changes domain.PendingChanges) error {
+	user, err := app.userRepo.GetById(r.Context(), userId)
+	if err != nil {
+		return err
+	}
+
+	// a pending email change is kept, the changes are then verified from the new address
+	if user.PendingChanges != nil {
+		changes.Email = user.PendingChanges.Email
+	}
+
+	token, err := app.savePendingChanges(r.Context(), userId, changes)
+	if err != nil {
+		return err
+	}
+
+	// the changes stay pending if the email can't be sent, requesting them again sends a new token
+	app.sendPendingChangesEmail(r.Context(), app.contextGetLogger(r), user, changes, token)
+
+	return nil
+}
+
+func (app *Application) savePendingChanges(
+	ctx context.Context,
+	userId int,
+	changes domain.PendingChanges) (*domain.Token, error) {
+
+	token, err := domain.GenerateToken(int64(userId), pendingChangesTokenTTL, domain.PendingChangesScope)
+	if err != nil {
+		return nil, err
+	}
+
+	err = app.userRepo.SavePendingChanges(ctx, userId, changes, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func (app *Application) sendPendingChangesEmail(
+	ctx context.Context,
+	logger *slog.Logger,
+	user *domain.User,
+	changes domain.PendingChanges,
+	token *domain.Token) {
+
+	marketingEmails := changes.MarketingEmails != nil && *changes.MarketingEmails
+
+	var data mailer.Message = mailer.PendingChangesEmail{
+		VerificationToken: token.Plaintext,
+		FirstName:         user.FirstName,
+		MarketingEmails:   marketingEmails,
+		TTLMinutes:        int(pendingChangesTokenTTL.Minutes()),
+	}
+
+	if changes.Email != nil {
+		data = mailer.EmailChangeEmail{
+			ConfirmationToken: token.Plaintext,
+			FirstName:         user.FirstName,
+			MarketingEmails:   marketingEmails,
+			TTLMinutes:        int(pendingChangesTokenTTL.Minutes()),
+		}
+	}
+
+	err := app.mailer.Send(ctx, changes.Recipient(user.Email), data)
+	if err != nil {
+		logger.Error("failed to send pending changes verification email", "error", err)
+		return
+	}
+
+	logger.Info("pending changes verification email sent successfully")
+}
+
+func (app *Application) VerifyPendingChanges(w http.ResponseWriter, r *http.Request) {
+	logger := app.contextGetLogger(r)
+
+	var input api.VerifyPendingChangesRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	hash := sha256.Sum256([]byte(input.Token))
+
+	userId, err := app.userRepo.ApplyPendingChanges(r.Context(), hash[:])
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, domain.ErrPendingEmailChange):
+			logger.Warn("pending changes with an email change verified without signing in")
+			app.errorResponseWithErr(w, r, http.StatusForbidden, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	logger.Info("pending changes of user are verified and applied", "user_id", userId)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func toApiUserResponse(user *domain.User) api.UserResponse {
 	resp := api.UserResponse{
 		Id:        user.ID,
 		FirstName: user.FirstName,
@@ -114,9 +356,34 @@ func (app *Application) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		CreatedAt: user.CreatedAt,
 	}
 
-	err = app.writeJSON(w, http.StatusOK, resp, nil)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
+	if user.PendingChanges != nil {
+		resp.PendingEmail = user.PendingChanges.Email
+	}
+
+	return resp
+}
+
+func toApiUserPreferences(preferences *domain.UserPreferences) *api.UserPreferences {
+	resp := &api.UserPreferences{
+		Latitude:           preferences.Latitude,
+		Longitude:          preferences.Longitude,
+		FavoriteTheaterId:  preferences.FavoriteTheaterID,
+		Language:           preferences.Language,
+		MarketingEmails:    &preferences.MarketingEmails,
+		NotificationDigest: &preferences.NotificationDigest,
+	}
+
+	if preferences.SeatType != nil {
+		seatType := api.SeatType(*preferences.SeatType)
+		resp.SeatType = &seatType
+	}
+
+	return resp
+}
+
+func toApiPendingChanges(changes *domain.PendingChanges) *api.PendingChanges {
+	return &api.PendingChanges{
+		MarketingEmails: changes.MarketingEmails,
 	}
 }
 
@@ -180,12 +447,12 @@ func (app *Application) InitiateUserDeletion(w http.ResponseWriter, r *http.Requ
 			}
 		}()
 
-		data := map[string]any{
-			"deletionToken": token.Plaintext,
-			"userID":        user.ID,
+		data := mailer.DeletionEmail{
+			DeletionToken: token.Plaintext,
+			UserID:        user.ID,
 		}
 
-		err = app.mailer.Send(user.Email, "user_deletion.tmpl", data)
+		err = app.mailer.Send(ctx, user.Email, data)
 		if err != nil {
 			gLogger.Error("failed to send user deletion email", "error", err)
 		} else {
@@ -257,3 +524,52 @@ func (app *Application) CompleteUserDeletion(w http.ResponseWriter, r *http.Requ
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+var (
+	errNoDefaultLocation    = errors.New("latitude and longitude or an address are required unless a default location is set in user preferences")
+	errGeocodingUnavailable = errors.New("searching by address is not available")
+)
+
+// searchLocation resolves the location to search around when no coordinates are given. A given
+// address takes precedence over the location stored in the user's preferences.
+func (app *Application) searchLocation(r *http.Request, address *string) (float64, float64, error) {
+	if address == nil {
+		return app.defaultLocation(r)
+	}
+
+	if app.geocoder == nil {
+		return 0, 0, errGeocodingUnavailable
+	}
+
+	point, err := app.geocoder.Geocode(r.Context(), *address)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return point.Latitude, point.Longitude, nil
+}
+
+// defaultLocation resolves the location stored in the preferences of the signed in user.
+// Guests and users without a stored location get errNoDefaultLocation.
+func (app *Application) defaultLocation(r *http.Request) (float64, float64, error) {
+	userId := app.sessionManager.GetInt(r.Context(), SessionKeyUserId.String())
+	if userId == 0 {
+		return 0, 0, errNoDefaultLocation
+	}
+
+	preferences, err := app.userRepo.GetPreferences(r.Context(), userId)
+	if err != nil {
+		if errors.Is(err, domain.ErrRecordNotFound) {
+			return 0, 0, errNoDefaultLocation
+		}
+
+		return 0, 0, err
+	}
+
+	lat, long, ok := preferences.Location()
+	if !ok {
+		return 0, 0, errNoDefaultLocation
+	}
+
+	return lat, long, nil
+}
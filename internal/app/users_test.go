@@ -1,7 +1,9 @@
 package app
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -24,6 +26,7 @@ func TestGetUsersMe(t *testing.T) {
 		setupSession   bool
 		userId         int
 		getByIdFunc    func(context.Context, int) (*domain.User, error)
+		getPrefsFunc   func(context.Context, int) (*domain.UserPreferences, error)
 		wantStatus     int
 		wantErrMessage string
 		wantResponse   *api.UserResponse
@@ -45,6 +48,47 @@ func TestGetUsersMe(t *testing.T) {
 					CreatedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
 				}, nil
 			},
+			getPrefsFunc: func(ctx context.Context, id int) (*domain.UserPreferences, error) {
+				return nil, domain.ErrRecordNotFound
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: &api.UserResponse{
+				Id:        1,
+				FirstName: "Freddie",
+				LastName:  "Mercury",
+				Email:     "freddie@example.com",
+				BirthDate: types.Date{Time: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)},
+				Gender:    api.M,
+				Activated: true,
+				Version:   1,
+				CreatedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:         "successful retrieval with preferences",
+			setupSession: true,
+			userId:       1,
+			getByIdFunc: func(ctx context.Context, id int) (*domain.User, error) {
+				return &domain.User{
+					ID:        1,
+					FirstName: "Freddie",
+					LastName:  "Mercury",
+					Email:     "freddie@example.com",
+					BirthDate: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+					Gender:    "M",
+					Activated: true,
+					Version:   1,
+					CreatedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+				}, nil
+			},
+			getPrefsFunc: func(ctx context.Context, id int) (*domain.UserPreferences, error) {
+				return &domain.UserPreferences{
+					UserID:            1,
+					FavoriteTheaterID: ptr(3),
+					Language:          ptr("en"),
+					SeatType:          ptr("VIP"),
+				}, nil
+			},
 			wantStatus: http.StatusOK,
 			wantResponse: &api.UserResponse{
 				Id:        1,
@@ -56,6 +100,50 @@ func TestGetUsersMe(t *testing.T) {
 				Activated: true,
 				Version:   1,
 				CreatedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+				Preferences: &api.UserPreferences{
+					FavoriteTheaterId:  ptr(3),
+					Language:           ptr("en"),
+					SeatType:           ptr(api.VIP),
+					MarketingEmails:    ptr(false),
+					NotificationDigest: ptr(false),
+				},
+			},
+		},
+		{
+			name:         "successful retrieval with pending changes",
+			setupSession: true,
+			userId:       1,
+			getByIdFunc: func(ctx context.Context, id int) (*domain.User, error) {
+				return &domain.User{
+					ID:             1,
+					FirstName:      "Freddie",
+					LastName:       "Mercury",
+					Email:          "freddie@example.com",
+					BirthDate:      time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+					Gender:         "M",
+					Activated:      true,
+					Version:        1,
+					CreatedAt:      time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+					PendingChanges: &domain.PendingChanges{MarketingEmails: ptr(true)},
+				}, nil
+			},
+			getPrefsFunc: func(ctx context.Context, id int) (*domain.UserPreferences, error) {
+				return nil, domain.ErrRecordNotFound
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: &api.UserResponse{
+				Id:        1,
+				FirstName: "Freddie",
+				LastName:  "Mercury",
+				Email:     "freddie@example.com",
+				BirthDate: types.Date{Time: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)},
+				Gender:    api.M,
+				Activated: true,
+				Version:   1,
+				CreatedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+				Preferences: &api.UserPreferences{
+					PendingChanges: &api.PendingChanges{MarketingEmails: ptr(true)},
+				},
 			},
 		},
 		{
@@ -90,7 +178,8 @@ func TestGetUsersMe(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			app := newTestApplication(func(a *Application) {
 				a.userRepo = &mocks.MockUserRepo{
-					GetByIdFunc: tt.getByIdFunc,
+					GetByIdFunc:        tt.getByIdFunc,
+					GetPreferencesFunc: tt.getPrefsFunc,
 				}
 				a.sessionManager = scs.New()
 			})
@@ -317,6 +406,333 @@ func TestUpdateUser(t *testing.T) {
 	}
 }
 
+func TestUpdateUserPreferences(t *testing.T) {
+	tests := []struct {
+		name           string
+		setupSession   bool
+		userId         int
+		input          api.UpdateUserPreferencesRequest
+		getPrefsFunc   func(context.Context, int) (*domain.UserPreferences, error)
+		upsertFunc     func(context.Context, *domain.UserPreferences) error
+		savePendingFn  func(context.Context, int, domain.PendingChanges, *domain.Token) error
+		discardFunc    func(context.Context, int) error
+		pending        *domain.PendingChanges
+		wantStatus     int
+		wantErrMessage string
+		wantResponse   *api.UserPreferences
+		wantEmails     int
+		wantEmailTo    string
+	}{
+		{
+			name:         "creates preferences",
+			setupSession: true,
+			userId:       1,
+			input: api.UpdateUserPreferencesRequest{
+				Latitude:  ptr(39.990067),
+				Longitude: ptr(32.643482),
+				Language:  ptr("tr-TR"),
+			},
+			getPrefsFunc: func(ctx context.Context, id int) (*domain.UserPreferences, error) {
+				return nil, domain.ErrRecordNotFound
+			},
+			upsertFunc: func(ctx context.Context, p *domain.UserPreferences) error {
+				return nil
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: &api.UserPreferences{
+				Latitude:           ptr(39.990067),
+				Longitude:          ptr(32.643482),
+				Language:           ptr("tr-TR"),
+				MarketingEmails:    ptr(false),
+				NotificationDigest: ptr(false),
+			},
+		},
+		{
+			name:         "verifies an opt-in from the address of a pending email change",
+			setupSession: true,
+			userId:       1,
+			input: api.UpdateUserPreferencesRequest{
+				MarketingEmails: ptr(true),
+			},
+			pending: &domain.PendingChanges{Email: ptr("johnny@example.com")},
+			getPrefsFunc: func(ctx context.Context, id int) (*domain.UserPreferences, error) {
+				return nil, domain.ErrRecordNotFound
+			},
+			upsertFunc: func(ctx context.Context, p *domain.UserPreferences) error {
+				return nil
+			},
+			savePendingFn: func(ctx context.Context, id int, changes domain.PendingChanges, token *domain.Token) error {
+				if changes.MarketingEmails == nil || changes.Email == nil || *changes.Email != "johnny@example.com" {
+					return fmt.Errorf("the pending email change isn't kept")
+				}
+
+				return nil
+			},
+			wantStatus: http.StatusAccepted,
+			wantResponse: &api.UserPreferences{
+				MarketingEmails:    ptr(false),
+				NotificationDigest: ptr(false),
+				PendingChanges:     &api.PendingChanges{MarketingEmails: ptr(true)},
+			},
+			wantEmailTo: "johnny@example.com",
+		},
+		{
+			name:         "keeps a marketing opt-in pending until it's verified",
+			setupSession: true,
+			userId:       1,
+			input: api.UpdateUserPreferencesRequest{
+				Language:        ptr("tr-TR"),
+				MarketingEmails: ptr(true),
+			},
+			getPrefsFunc: func(ctx context.Context, id int) (*domain.UserPreferences, error) {
+				return nil, domain.ErrRecordNotFound
+			},
+			upsertFunc: func(ctx context.Context, p *domain.UserPreferences) error {
+				if p.MarketingEmails {
+					return fmt.Errorf("opt-in applied before it's verified")
+				}
+
+				return nil
+			},
+			savePendingFn: func(ctx context.Context, id int, changes domain.PendingChanges, token *domain.Token) error {
+				if id != 1 || changes.MarketingEmails == nil || !*changes.MarketingEmails ||
+					changes.Source != domain.ConsentSourcePreferences ||
+					token.Scope != domain.PendingChangesScope || token.UserId != 1 {
+					return fmt.Errorf("unexpected pending changes")
+				}
+
+				return nil
+			},
+			wantStatus: http.StatusAccepted,
+			wantResponse: &api.UserPreferences{
+				Language:           ptr("tr-TR"),
+				MarketingEmails:    ptr(false),
+				NotificationDigest: ptr(false),
+				PendingChanges:     &api.PendingChanges{MarketingEmails: ptr(true)},
+			},
+			wantEmails: 1,
+		},
+		{
+			name:         "fails when the pending changes can't be saved",
+			setupSession: true,
+			userId:       1,
+			input: api.UpdateUserPreferencesRequest{
+				MarketingEmails: ptr(true),
+			},
+			getPrefsFunc: func(ctx context.Context, id int) (*domain.UserPreferences, error) {
+				return &domain.UserPreferences{UserID: 1}, nil
+			},
+			upsertFunc: func(ctx context.Context, p *domain.UserPreferences) error {
+				return nil
+			},
+			savePendingFn: func(ctx context.Context, id int, changes domain.PendingChanges, token *domain.Token) error {
+				return fmt.Errorf("database error")
+			},
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+		{
+			name:         "applies a marketing opt-out right away and discards a pending opt-in",
+			setupSession: true,
+			userId:       1,
+			input: api.UpdateUserPreferencesRequest{
+				MarketingEmails: ptr(false),
+			},
+			getPrefsFunc: func(ctx context.Context, id int) (*domain.UserPreferences, error) {
+				return &domain.UserPreferences{UserID: 1, MarketingEmails: true}, nil
+			},
+			upsertFunc: func(ctx context.Context, p *domain.UserPreferences) error {
+				if p.MarketingEmails {
+					return fmt.Errorf("opt-out isn't applied")
+				}
+
+				return nil
+			},
+			discardFunc: func(ctx context.Context, id int) error {
+				return nil
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: &api.UserPreferences{
+				MarketingEmails:    ptr(false),
+				NotificationDigest: ptr(false),
+			},
+		},
+		{
+			name:         "switches announcement emails to the daily digest",
+			setupSession: true,
+			userId:       1,
+			input: api.UpdateUserPreferencesRequest{
+				NotificationDigest: ptr(true),
+			},
+			getPrefsFunc: func(ctx context.Context, id int) (*domain.UserPreferences, error) {
+				return &domain.UserPreferences{UserID: 1, MarketingEmails: true}, nil
+			},
+			upsertFunc: func(ctx context.Context, p *domain.UserPreferences) error {
+				if !p.NotificationDigest || !p.MarketingEmails {
+					return fmt.Errorf("unexpected preferences %+v", p)
+				}
+
+				return nil
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: &api.UserPreferences{
+				MarketingEmails:    ptr(true),
+				NotificationDigest: ptr(true),
+			},
+		},
+		{
+			name:         "merges with existing preferences",
+			setupSession: true,
+			userId:       1,
+			input: api.UpdateUserPreferencesRequest{
+				SeatType: ptr(api.Recliner),
+			},
+			getPrefsFunc: func(ctx context.Context, id int) (*domain.UserPreferences, error) {
+				return &domain.UserPreferences{UserID: 1, FavoriteTheaterID: ptr(2), Language: ptr("en"), MarketingEmails: true}, nil
+			},
+			upsertFunc: func(ctx context.Context, p *domain.UserPreferences) error {
+				return nil
+			},
+			wantStatus: http.StatusOK,
+			wantResponse: &api.UserPreferences{
+				FavoriteTheaterId:  ptr(2),
+				Language:           ptr("en"),
+				SeatType:           ptr(api.Recliner),
+				MarketingEmails:    ptr(true),
+				NotificationDigest: ptr(false),
+			},
+		},
+		{
+			name:           "no session",
+			setupSession:   false,
+			wantStatus:     http.StatusUnauthorized,
+			wantErrMessage: ErrUnauthorizedAccess,
+		},
+		{
+			name:         "latitude without longitude",
+			setupSession: true,
+			userId:       1,
+			input: api.UpdateUserPreferencesRequest{
+				Latitude: ptr(39.990067),
+			},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: fmt.Sprintf(validator.ErrRequiredWith, "Latitude"),
+		},
+		{
+			name:         "invalid language",
+			setupSession: true,
+			userId:       1,
+			input: api.UpdateUserPreferencesRequest{
+				Language: ptr("not a language"),
+			},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: validator.ErrDefaultInvalid,
+		},
+		{
+			name:         "favorite theater not found",
+			setupSession: true,
+			userId:       1,
+			input: api.UpdateUserPreferencesRequest{
+				FavoriteTheaterId: ptr(999),
+			},
+			getPrefsFunc: func(ctx context.Context, id int) (*domain.UserPreferences, error) {
+				return nil, domain.ErrRecordNotFound
+			},
+			upsertFunc: func(ctx context.Context, p *domain.UserPreferences) error {
+				return domain.ErrTheaterNotFound
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: domain.ErrTheaterNotFound.Error(),
+		},
+		{
+			name:         "database error",
+			setupSession: true,
+			userId:       1,
+			input: api.UpdateUserPreferencesRequest{
+				Language: ptr("en"),
+			},
+			getPrefsFunc: func(ctx context.Context, id int) (*domain.UserPreferences, error) {
+				return nil, fmt.Errorf("database error")
+			},
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sentEmails int
+			var emailChangeRecipients []string
+
+			app := newTestApplication(func(a *Application) {
+				a.mailer = &MockMailer{sendFunc: func(recipient, template string, data any) error {
+					if recipient == "john@example.com" && template == "pending_changes.tmpl" {
+						sentEmails++
+					}
+
+					if template == "email_change.tmpl" {
+						emailChangeRecipients = append(emailChangeRecipients, recipient)
+					}
+
+					return nil
+				}}
+				a.userRepo = &mocks.MockUserRepo{
+					GetPreferencesFunc:        tt.getPrefsFunc,
+					UpsertPreferencesFunc:     tt.upsertFunc,
+					SavePendingChangesFunc:    tt.savePendingFn,
+					DiscardPendingChangesFunc: tt.discardFunc,
+					GetByIdFunc: func(ctx context.Context, id int) (*domain.User, error) {
+						return &domain.User{ID: id, FirstName: "John", Email: "john@example.com", PendingChanges: tt.pending}, nil
+					},
+				}
+				a.sessionManager = scs.New()
+			})
+
+			w, r := executeRequest(t, http.MethodPatch, "/users/me/preferences", tt.input)
+
+			if tt.setupSession {
+				r = setupTestSession(t, app, r, tt.userId)
+			}
+
+			handler := app.requireAuthentication(http.HandlerFunc(app.UpdateUserPreferences))
+			handler = app.sessionManager.LoadAndSave(handler)
+			handler.ServeHTTP(w, r)
+
+			if got := w.Code; got != tt.wantStatus {
+				t.Errorf("status = %v, want %v", got, tt.wantStatus)
+			}
+
+			if tt.wantResponse != nil {
+				var response api.UserPreferences
+				err := json.NewDecoder(w.Body).Decode(&response)
+				if err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+
+				if diff := cmp.Diff(tt.wantResponse, &response); diff != "" {
+					t.Errorf("Mismatch (-want +got):\n%s", diff)
+				}
+			}
+
+			if got := sentEmails; got != tt.wantEmails {
+				t.Errorf("sent emails = %d, want %d", got, tt.wantEmails)
+			}
+
+			if tt.wantEmailTo != "" && (len(emailChangeRecipients) != 1 || emailChangeRecipients[0] != tt.wantEmailTo) {
+				t.Errorf("email change emails sent to %v, want %s", emailChangeRecipients, tt.wantEmailTo)
+			}
+
+			checkErrorResponse(t, w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+		})
+	}
+}
+
 func TestInitiateUserDeletion(t *testing.T) {
 	tests := []struct {
 		name            string
@@ -360,7 +776,7 @@ func TestInitiateUserDeletion(t *testing.T) {
 			setupSession: true,
 			userId:       1,
 			input: api.InitiateUserDeletionRequest{
-				Password: "weak",
+				Password: tooLongPassword,
 			},
 			wantStatus:     http.StatusUnauthorized,
 			wantErrMessage: ErrInvalidCredentials,
@@ -621,3 +1037,88 @@ func TestCompleteUserDeletion(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyPendingChanges(t *testing.T) {
+	const validToken = "O8N3AqxZYwWDq2pXWZXM4yqpyoXKUYXzV5bV0z5dL5k"
+
+	tests := []struct {
+		name           string
+		input          api.VerifyPendingChangesRequest
+		applyFunc      func(context.Context, []byte) (int, error)
+		wantStatus     int
+		wantErrMessage string
+	}{
+		{
+			name:  "applies the pending changes",
+			input: api.VerifyPendingChangesRequest{Token: validToken},
+			applyFunc: func(ctx context.Context, hash []byte) (int, error) {
+				want := sha256.Sum256([]byte(validToken))
+				if !bytes.Equal(hash, want[:]) {
+					return 0, domain.ErrRecordNotFound
+				}
+
+				return 1, nil
+			},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:           "invalid token format",
+			input:          api.VerifyPendingChangesRequest{Token: "invalid-token"},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: validator.ErrDefaultInvalid,
+		},
+		{
+			name:  "token not found",
+			input: api.VerifyPendingChangesRequest{Token: validToken},
+			applyFunc: func(ctx context.Context, hash []byte) (int, error) {
+				return 0, domain.ErrRecordNotFound
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+		},
+		{
+			name:  "changes with a new address",
+			input: api.VerifyPendingChangesRequest{Token: validToken},
+			applyFunc: func(ctx context.Context, hash []byte) (int, error) {
+				return 0, domain.ErrPendingEmailChange
+			},
+			wantStatus:     http.StatusForbidden,
+			wantErrMessage: domain.ErrPendingEmailChange.Error(),
+		},
+		{
+			name:  "database error",
+			input: api.VerifyPendingChangesRequest{Token: validToken},
+			applyFunc: func(ctx context.Context, hash []byte) (int, error) {
+				return 0, fmt.Errorf("database error")
+			},
+			wantStatus:     http.StatusInternalServerError,
+			wantErrMessage: ErrInternalServer,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(func(a *Application) {
+				a.userRepo = &mocks.MockUserRepo{
+					ApplyPendingChangesFunc: tt.applyFunc,
+				}
+			})
+
+			w, r := executeRequest(t, http.MethodPut, "/users/pending-changes", tt.input)
+
+			app.VerifyPendingChanges(w, r)
+
+			if got := w.Code; got != tt.wantStatus {
+				t.Errorf("status = %v, want %v", got, tt.wantStatus)
+			}
+
+			checkErrorResponse(t, w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{
+				wantStatus:     tt.wantStatus,
+				wantErrMessage: tt.wantErrMessage,
+			})
+		})
+	}
+}
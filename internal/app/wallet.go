@@ -0,0 +1,180 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/ticketsign"
+)
+
+const applePassContentType = "application/vnd.apple.pkpass"
+
+func (app *Application) GetUserReservationWalletPass(
+	w http.ResponseWriter,
+	r *http.Request,
+	reservationId int,
+	params api.GetUserReservationWalletPassParams) {
+
+	logger := app.contextGetLogger(r)
+
+	if reservationId <= 0 {
+		app.badRequestResponse(w, r, fmt.Errorf("reservation id must be greater than zero"))
+		return
+	}
+
+	err := app.validator.Struct(params)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	format := api.Apple
+	if params.Format != nil {
+		format = *params.Format
+	}
+
+	userId := app.contextGetUserId(r)
+
+	reservationDetail, err := app.reservationRepo.GetByReservationIdAndUserId(r.Context(), reservationId, userId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			logger.Warn(
+				"user attempt to issue a pass for non-existent or unauthorized reservation",
+				"reservation_id", reservationId,
+			)
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	if reservationDetail.TicketsRevokedAt != nil {
+		app.editConflictResponseWithErr(w, r, errTicketsRevoked)
+		return
+	}
+
+	user, err := app.userRepo.GetById(r.Context(), userId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	ticket, err := app.walletTicket(reservationDetail, user)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	switch format {
+	case api.Google:
+		var saveURL string
+		saveURL, err = app.walletPasses.GoogleSaveURL(ticket)
+		if err == nil {
+			err = app.writeJSON(w, http.StatusOK, api.WalletPassLinkResponse{SaveUrl: saveURL}, nil)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	default:
+		var pass []byte
+		pass, err = app.walletPasses.ApplePass(ticket)
+		if err == nil {
+			w.Header().Set("Content-Type", applePassContentType)
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="reservation-%d.pkpass"`, reservationId))
+			w.WriteHeader(http.StatusOK)
+
+			if _, err := w.Write(pass); err != nil {
+				logger.Error("failed to write wallet pass response", "error", err)
+			}
+			return
+		}
+	}
+
+	switch {
+	case errors.Is(err, domain.ErrWalletPassUnavailable):
+		app.logClientError(r, err.Error())
+		app.errorResponseWithErr(w, r, http.StatusNotImplemented, err)
+	default:
+		app.serverErrorResponse(w, r, fmt.Errorf("failed to issue %s wallet pass: %w", format, err))
+	}
+}
+
+func (app *Application) walletTicket(
+	detail *domain.ReservationDetail,
+	user *domain.User) (domain.WalletTicket, error) {
+
+	seats := make([]string, len(detail.Seats))
+	for i, s := range detail.Seats {
+		seats[i] = formatReservationSeat(s)
+	}
+
+	endsAt := detail.ShowtimeDate.Add(time.Duration(detail.MovieDuration) * time.Minute)
+
+	code, err := app.signedTicketCode(detail, endsAt)
+	if err != nil {
+		return domain.WalletTicket{}, fmt.Errorf("failed to sign ticket code: %w", err)
+	}
+
+	return domain.WalletTicket{
+		SerialNumber: fmt.Sprintf("reservation-%d", detail.ReservationID),
+		TicketCode:   code,
+		HolderName:   user.FirstName + " " + user.LastName,
+		MovieTitle:   detail.MovieTitle,
+		TheaterName:  detail.TheaterName,
+		HallName:     detail.HallName,
+		Address:      strings.Join(nonEmpty(detail.TheaterAddress, detail.TheaterDistrict, detail.TheaterCity), ", "),
+		Location:     detail.TheaterLocation,
+		StartsAt:     detail.ShowtimeDate,
+		EndsAt:       endsAt,
+		Seats:        seats,
+	}, nil
+}
+
+// signedTicketCode signs the code of a pass when ticket signing keys are configured, so scanners can
+// verify it offline until the movie ends. The legacy code is issued otherwise.
+func (app *Application) signedTicketCode(detail *domain.ReservationDetail, endsAt time.Time) (string, error) {
+	if app.ticketKeys == nil {
+		return app.ticketCode(detail.ReservationID, detail.TicketVersion), nil
+	}
+
+	seats := make([][2]int, len(detail.Seats))
+	for i, s := range detail.Seats {
+		seats[i] = [2]int{s.Row, s.Col}
+	}
+
+	return app.ticketKeys.Sign(ticketsign.Payload{
+		ReservationID: detail.ReservationID,
+		TicketVersion: detail.TicketVersion,
+		ShowtimeID:    detail.ShowtimeID,
+		Seats:         seats,
+		ExpiresAt:     endsAt,
+	})
+}
+
+// ticketCode derives the code encoded in the QR of a pass. The reservation id prefix lets staff look the
+// reservation up, while the MAC keeps codes from being guessed. Bumping the ticket version replaces the
+// code, the codes of version 0 are the ones issued before tickets were versioned.
+func (app *Application) ticketCode(reservationId, ticketVersion int) string {
+	mac := hmac.New(sha256.New, []byte(app.config.Wallet.TicketSecret))
+	if ticketVersion == 0 {
+		fmt.Fprintf(mac, "reservation:%d", reservationId)
+	} else {
+		fmt.Fprintf(mac, "reservation:%d:v%d", reservationId, ticketVersion)
+	}
+
+	sum := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(mac.Sum(nil))
+
+	return fmt.Sprintf("%d-%s", reservationId, sum[:16])
+}
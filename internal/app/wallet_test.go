@@ -0,0 +1,271 @@
+package app
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/metinatakli/movie-reservation-system/internal/ticketsign"
+	"github.com/metinatakli/movie-reservation-system/internal/validator"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type WalletTestSuite struct {
+	suite.Suite
+	app             *Application
+	reservationRepo *mocks.MockReservationRepo
+	walletPasses    *mocks.MockWalletPassIssuer
+}
+
+func (s *WalletTestSuite) SetupTest() {
+	s.reservationRepo = new(mocks.MockReservationRepo)
+	s.walletPasses = new(mocks.MockWalletPassIssuer)
+	s.app = newTestApplication(func(a *Application) {
+		a.config.Wallet.TicketSecret = "secret"
+		a.reservationRepo = s.reservationRepo
+		a.walletPasses = s.walletPasses
+		a.sessionManager = scs.New()
+		a.userRepo = &mocks.MockUserRepo{
+			GetByIdFunc: func(ctx context.Context, id int) (*domain.User, error) {
+				return &domain.User{ID: id, FirstName: "Freddie", LastName: "Mercury"}, nil
+			},
+		}
+	})
+}
+
+func TestWalletSuite(t *testing.T) {
+	suite.Run(t, new(WalletTestSuite))
+}
+
+func (s *WalletTestSuite) TestGetUserReservationWalletPass() {
+	reservationDetail := &domain.ReservationDetail{
+		ReservationSummary: domain.ReservationSummary{
+			ReservationID: 7,
+			MovieTitle:    "The Matrix",
+			ShowtimeDate:  time.Date(2024, 3, 15, 19, 0, 0, 0, time.UTC),
+			TheaterName:   "Cinema City",
+			HallName:      "Hall 1",
+		},
+		MovieDuration:   136,
+		TheaterAddress:  "Bagdat Cd. 10",
+		TheaterCity:     "Istanbul",
+		TheaterLocation: domain.GeoPoint{Latitude: 40.9631, Longitude: 29.0634},
+		Seats: []domain.ReservationDetailSeat{
+			{Row: 1, Col: 2, Type: "vip"},
+		},
+	}
+
+	matchTicket := mock.MatchedBy(func(t domain.WalletTicket) bool {
+		return t.SerialNumber == "reservation-7" &&
+			t.HolderName == "Freddie Mercury" &&
+			t.Address == "Bagdat Cd. 10, Istanbul" &&
+			t.EndsAt.Equal(time.Date(2024, 3, 15, 21, 16, 0, 0, time.UTC)) &&
+			len(t.Seats) == 1 && t.Seats[0] == "Row 1 Seat 2 (vip)" &&
+			t.TicketCode == s.app.ticketCode(7, 0)
+	})
+
+	tests := []struct {
+		name           string
+		reservationId  int
+		format         *api.GetUserReservationWalletPassParamsFormat
+		setupMock      func()
+		wantStatus     int
+		wantErrMessage string
+		wantHeader     string
+		wantBody       string
+	}{
+		{
+			name:           "invalid reservation id",
+			reservationId:  0,
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "reservation id must be greater than zero",
+		},
+		{
+			name:           "unknown format",
+			reservationId:  7,
+			format:         ptr(api.GetUserReservationWalletPassParamsFormat("samsung")),
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: fmt.Sprintf(validator.ErrOneOf, "apple google"),
+		},
+		{
+			name:          "reservation not found",
+			reservationId: 7,
+			setupMock: func() {
+				s.reservationRepo.On("GetByReservationIdAndUserId", mock.Anything, 7, 1).
+					Return(nil, domain.ErrRecordNotFound)
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: ErrNotFound,
+		},
+		{
+			name:          "tickets revoked",
+			reservationId: 7,
+			setupMock: func() {
+				revoked := *reservationDetail
+				revoked.TicketsRevokedAt = ptr(time.Now())
+				s.reservationRepo.On("GetByReservationIdAndUserId", mock.Anything, 7, 1).Return(&revoked, nil)
+			},
+			wantStatus:     http.StatusConflict,
+			wantErrMessage: errTicketsRevoked.Error(),
+		},
+		{
+			name:          "wallet not configured",
+			reservationId: 7,
+			setupMock: func() {
+				s.reservationRepo.On("GetByReservationIdAndUserId", mock.Anything, 7, 1).Return(reservationDetail, nil)
+				s.walletPasses.On("ApplePass", mock.Anything).Return(nil, domain.ErrWalletPassUnavailable)
+			},
+			wantStatus:     http.StatusNotImplemented,
+			wantErrMessage: domain.ErrWalletPassUnavailable.Error(),
+		},
+		{
+			name:          "apple pass by default",
+			reservationId: 7,
+			setupMock: func() {
+				s.reservationRepo.On("GetByReservationIdAndUserId", mock.Anything, 7, 1).Return(reservationDetail, nil)
+				s.walletPasses.On("ApplePass", matchTicket).Return([]byte("pkpass"), nil)
+			},
+			wantStatus: http.StatusOK,
+			wantHeader: applePassContentType,
+			wantBody:   "pkpass",
+		},
+		{
+			name:          "google save link",
+			reservationId: 7,
+			format:        ptr(api.Google),
+			setupMock: func() {
+				s.reservationRepo.On("GetByReservationIdAndUserId", mock.Anything, 7, 1).Return(reservationDetail, nil)
+				s.walletPasses.On("GoogleSaveURL", matchTicket).Return("https://pay.google.com/gp/v/save/jwt", nil)
+			},
+			wantStatus: http.StatusOK,
+			wantHeader: "application/json",
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			defer s.reservationRepo.AssertExpectations(s.T())
+			defer s.walletPasses.AssertExpectations(s.T())
+
+			if tt.setupMock != nil {
+				tt.setupMock()
+			}
+
+			w, r := executeRequest(s.T(), http.MethodGet, fmt.Sprintf("/reservations/%d/wallet-pass", tt.reservationId), nil)
+			r = setupTestSession(s.T(), s.app, r, 1)
+
+			handler := s.app.requireAuthentication(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				s.app.GetUserReservationWalletPass(w, r, tt.reservationId, api.GetUserReservationWalletPassParams{Format: tt.format})
+			}))
+			handler = s.app.sessionManager.LoadAndSave(handler)
+			handler.ServeHTTP(w, r)
+
+			s.Equal(tt.wantStatus, w.Code)
+
+			if tt.wantStatus != http.StatusOK {
+				checkErrorResponse(s.T(), w, struct {
+					wantStatus     int
+					wantErrMessage string
+				}{
+					wantStatus:     tt.wantStatus,
+					wantErrMessage: tt.wantErrMessage,
+				})
+				return
+			}
+
+			s.Equal(tt.wantHeader, w.Header().Get("Content-Type"))
+
+			if tt.wantBody != "" {
+				s.Equal(tt.wantBody, w.Body.String())
+				return
+			}
+
+			var resp api.WalletPassLinkResponse
+			s.Require().NoError(json.NewDecoder(w.Body).Decode(&resp))
+			s.Equal("https://pay.google.com/gp/v/save/jwt", resp.SaveUrl)
+		})
+	}
+}
+
+func TestTicketCode(t *testing.T) {
+	app := newTestApplication(func(a *Application) {
+		a.config.Wallet.TicketSecret = "secret"
+	})
+
+	code := app.ticketCode(42, 0)
+	if code != app.ticketCode(42, 0) {
+		t.Errorf("ticket code is not stable")
+	}
+
+	if code == app.ticketCode(43, 0) {
+		t.Errorf("ticket codes of different reservations collide")
+	}
+
+	if code == app.ticketCode(42, 1) {
+		t.Errorf("ticket code is not replaced by a new ticket version")
+	}
+
+	other := newTestApplication(func(a *Application) {
+		a.config.Wallet.TicketSecret = "other"
+	})
+	if code == other.ticketCode(42, 0) {
+		t.Errorf("ticket code does not depend on the secret")
+	}
+
+	if len(code) != len("42-")+16 || code[:3] != "42-" {
+		t.Errorf("ticket code = %q, want the reservation id followed by 16 characters", code)
+	}
+}
+
+func TestSignedTicketCode(t *testing.T) {
+	ticketKeys, err := ticketsign.ParseKeyring("k1:" + base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := newTestApplication(func(a *Application) {
+		a.config.Wallet.TicketSecret = "secret"
+		a.ticketKeys = ticketKeys
+	})
+
+	startsAt := time.Date(2024, 3, 15, 19, 0, 0, 0, time.UTC)
+
+	ticket, err := app.walletTicket(&domain.ReservationDetail{
+		ReservationSummary: domain.ReservationSummary{ReservationID: 7, ShowtimeDate: startsAt},
+		MovieDuration:      136,
+		TicketVersion:      2,
+		ShowtimeID:         5,
+		Seats:              []domain.ReservationDetailSeat{{Row: 1, Col: 2}, {Row: 1, Col: 3}},
+	}, &domain.User{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := ticketKeys.Verify(ticket.TicketCode, startsAt)
+	if err != nil {
+		t.Fatalf("failed to verify the signed code %q: %v", ticket.TicketCode, err)
+	}
+
+	want := ticketsign.Payload{
+		ReservationID: 7,
+		TicketVersion: 2,
+		ShowtimeID:    5,
+		Seats:         [][2]int{{1, 2}, {1, 3}},
+		ExpiresAt:     ticket.EndsAt,
+	}
+	if !reflect.DeepEqual(*payload, want) {
+		t.Errorf("payload = %+v, want %+v", *payload, want)
+	}
+}
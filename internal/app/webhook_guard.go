@@ -0,0 +1,177 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stripe/stripe-go/v82/webhook"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// stripeWebhookIPs are the addresses Stripe sends webhooks from, as published at
+// https://stripe.com/files/ips/ips_webhooks.txt. Stripe announces changes of the list ahead of time.
+var stripeWebhookIPs = []string{
+	"3.18.12.63",
+	"3.130.192.231",
+	"13.235.14.237",
+	"13.235.122.149",
+	"18.211.135.69",
+	"35.154.171.200",
+	"52.15.183.38",
+	"54.88.130.119",
+	"54.88.130.237",
+	"54.187.174.169",
+	"54.187.205.235",
+	"54.187.216.72",
+}
+
+// parseWebhookAllowedIPs parses comma separated CIDRs like parsePrefixes, "stripe" stands for the addresses
+// published by Stripe.
+func parseWebhookAllowedIPs(value string) ([]netip.Prefix, error) {
+	parts := strings.Split(value, ",")
+	for i, part := range parts {
+		if strings.TrimSpace(part) == "stripe" {
+			parts[i] = strings.Join(stripeWebhookIPs, ",")
+		}
+	}
+
+	return parsePrefixes(strings.Join(parts, ","))
+}
+
+const (
+	webhookRejectedSourceNotAllowed = "source_not_allowed"
+	webhookRejectedRateLimited      = "rate_limited"
+)
+
+// webhookMetrics count the webhook requests which are turned away, a rise of them is either abuse or a
+// misconfiguration, e.g. a rotated signing secret or a proxy hiding the address of Stripe.
+type webhookMetrics struct {
+	rejected          metric.Int64Counter
+	signatureFailures metric.Int64Counter
+}
+
+func newWebhookMetrics(logger *slog.Logger) webhookMetrics {
+	meter := otel.Meter("github.com/metinatakli/movie-reservation-system/internal/app")
+
+	var m webhookMetrics
+	var err error
+
+	m.rejected, err = meter.Int64Counter(
+		"webhook.requests.rejected",
+		metric.WithDescription("Number of webhook requests rejected before their signature was verified"),
+	)
+	if err != nil {
+		logger.Error("failed to create rejected webhook metric", "error", err)
+	}
+
+	m.signatureFailures, err = meter.Int64Counter(
+		"webhook.signature.failures",
+		metric.WithDescription("Number of webhook requests whose signature couldn't be verified"),
+	)
+	if err != nil {
+		logger.Error("failed to create webhook signature failure metric", "error", err)
+	}
+
+	return m
+}
+
+func (m webhookMetrics) recordRejected(ctx context.Context, reason string) {
+	if m.rejected != nil {
+		m.rejected.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", reason)))
+	}
+}
+
+func (m webhookMetrics) recordSignatureFailure(ctx context.Context, err error) {
+	if m.signatureFailures != nil {
+		m.signatureFailures.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", signatureFailureReason(err))))
+	}
+}
+
+// signatureFailureReason tells a request without a signature, likely not sent by Stripe, from one signed with
+// another secret or replayed too late.
+func signatureFailureReason(err error) string {
+	switch {
+	case errors.Is(err, webhook.ErrNotSigned):
+		return "not_signed"
+	case errors.Is(err, webhook.ErrInvalidHeader):
+		return "invalid_header"
+	case errors.Is(err, webhook.ErrNoValidSignature):
+		return "no_valid_signature"
+	case errors.Is(err, webhook.ErrTooOld):
+		return "too_old"
+	default:
+		return "other"
+	}
+}
+
+// guardStripeWebhook turns away webhook requests from sources which aren't allowed and sources sending more
+// than the rate limit, before any work is spent verifying their signature. The rate limit fails open, the
+// webhook isn't rejected because Redis is unavailable.
+func (app *Application) guardStripeWebhook(next http.HandlerFunc) http.HandlerFunc {
+	limit := rateLimit{name: "stripe_webhook", limit: app.config.Stripe.WebhookRateLimit, window: time.Minute}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := app.logger.With("request_id", middleware.GetReqID(r.Context()))
+
+		source, ok := app.webhookSource(r)
+
+		if len(app.config.Stripe.WebhookAllowedIPs) > 0 && !(ok && containsAddr(app.config.Stripe.WebhookAllowedIPs, source)) {
+			logger.Warn("webhook rejected: source is not allowed", "source", source.String())
+			app.webhookMetrics.recordRejected(r.Context(), webhookRejectedSourceNotAllowed)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		if limit.limit > 0 && ok {
+			allowed, retryAfter, err := app.allow(r.Context(), limit, source.String())
+			if err != nil {
+				logger.Error("failed to check the webhook rate limit", "error", err)
+			} else if !allowed {
+				logger.Warn("webhook rejected: rate limit exceeded", "source", source.String())
+				app.webhookMetrics.recordRejected(r.Context(), webhookRejectedRateLimited)
+				w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+		}
+
+		next(w, r)
+	}
+}
+
+// webhookSource returns the address the webhook was sent from. realIP only takes the address of the proxy
+// headers when the request came through a trusted proxy, anyone could claim one of Stripe's addresses
+// otherwise.
+func (app *Application) webhookSource(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+
+	return addr.Unmap(), true
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,144 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/mock"
+	"github.com/stripe/stripe-go/v82/webhook"
+)
+
+func TestGuardStripeWebhook(t *testing.T) {
+	stripeIPs, err := parseWebhookAllowedIPs("stripe")
+	if err != nil {
+		t.Fatalf("parseWebhookAllowedIPs() error = %v", err)
+	}
+
+	trustedProxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	tests := []struct {
+		name           string
+		allowedIPs     []netip.Prefix
+		rateLimit      int
+		remoteAddr     string
+		forwardedFor   string
+		setupMocks     func(*mocks.MockRedisClient)
+		wantStatus     int
+		wantRetryAfter string
+	}{
+		{
+			name:       "should accept any source without an allow list",
+			remoteAddr: "198.51.100.7:443",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "should accept an address of Stripe",
+			allowedIPs: stripeIPs,
+			remoteAddr: "54.187.174.169:443",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "should reject a source which isn't allowed",
+			allowedIPs: stripeIPs,
+			remoteAddr: "198.51.100.7:443",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:         "should ignore a forwarded address of an untrusted peer",
+			allowedIPs:   stripeIPs,
+			remoteAddr:   "198.51.100.7:443",
+			forwardedFor: "54.187.174.169",
+			wantStatus:   http.StatusForbidden,
+		},
+		{
+			name:         "should take the forwarded address of a trusted proxy",
+			allowedIPs:   stripeIPs,
+			remoteAddr:   "10.0.0.2:443",
+			forwardedFor: "54.187.174.169",
+			wantStatus:   http.StatusOK,
+		},
+		{
+			name:       "should reject a source over the rate limit",
+			rateLimit:  10,
+			remoteAddr: "198.51.100.7:443",
+			setupMocks: func(c *mocks.MockRedisClient) {
+				allowRateLimit(c, "rate_limit:stripe_webhook:198.51.100.7", 30000)
+			},
+			wantStatus:     http.StatusTooManyRequests,
+			wantRetryAfter: "30",
+		},
+		{
+			name:       "should accept the webhook when the rate limit can't be checked",
+			rateLimit:  10,
+			remoteAddr: "198.51.100.7:443",
+			setupMocks: func(c *mocks.MockRedisClient) {
+				c.On("EvalSha", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+					Return(redis.NewCmdResult(nil, errors.New("redis unavailable"))).Once()
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisClient := new(mocks.MockRedisClient)
+			if tt.setupMocks != nil {
+				tt.setupMocks(redisClient)
+			}
+
+			app := newTestApplication(func(a *Application) {
+				a.redis = redisClient
+				a.config.TrustedProxies = trustedProxies
+				a.config.Stripe.WebhookAllowedIPs = tt.allowedIPs
+				a.config.Stripe.WebhookRateLimit = tt.rateLimit
+			})
+
+			handler := app.keepPeerAddr(app.realIP(app.guardStripeWebhook(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})))
+
+			r := httptest.NewRequest(http.MethodPost, "/webhook", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status code = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			if got := w.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetryAfter)
+			}
+
+			redisClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSignatureFailureReason(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{webhook.ErrNotSigned, "not_signed"},
+		{webhook.ErrInvalidHeader, "invalid_header"},
+		{webhook.ErrNoValidSignature, "no_valid_signature"},
+		{webhook.ErrTooOld, "too_old"},
+		{errors.New("api version mismatch"), "other"},
+	}
+
+	for _, tt := range tests {
+		if got := signatureFailureReason(tt.err); got != tt.want {
+			t.Errorf("signatureFailureReason(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
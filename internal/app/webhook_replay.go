@@ -0,0 +1,166 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/stripe/stripe-go/v82"
+)
+
+func (app *Application) ReplayStripeWebhookEvent(w http.ResponseWriter, r *http.Request) {
+	var input api.ReplayWebhookEventRequest
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.validator.Struct(input)
+	if err != nil {
+		app.failedValidationResponse(w, r, err)
+		return
+	}
+
+	storedEvent, err := app.webhookEventRepo.GetById(r.Context(), input.EventId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			app.notFoundResponseWithErr(w, r, fmt.Errorf("webhook event %s was not received", input.EventId))
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	// the signature was verified when the event was received, it's trusted as stored
+	var event stripe.Event
+	if err := json.Unmarshal(storedEvent.Payload, &event); err != nil {
+		app.serverErrorResponse(w, r, fmt.Errorf("failed to parse stored webhook event: %w", err))
+		return
+	}
+
+	logger := app.contextGetLogger(r).With(
+		"stripe_event_id", event.ID,
+		"stripe_event_type", event.Type,
+		"replay_mode", input.Mode,
+	)
+	r = r.WithContext(context.WithValue(r.Context(), loggerContextKey, logger))
+
+	// handlers write their response as if Stripe was the client, it's reported inside the replay result
+	handlerResponse := newReplayResponseWriter()
+
+	switch input.Mode {
+	case api.DryRun:
+		if event.Type != "checkout.session.completed" {
+			app.badRequestResponse(w, r, fmt.Errorf("dry run is not supported for %s events", event.Type))
+			return
+		}
+
+		app.dryRunCheckoutSessionCompleted(handlerResponse, r, event)
+	case api.Apply:
+		app.dispatchStripeEvent(handlerResponse, r, event)
+
+		err = app.webhookEventRepo.MarkReplayed(r.Context(), event.ID)
+		if err != nil {
+			logger.Error("webhook event replayed but failed to record the replay", "error", err)
+		}
+	}
+
+	logger.Info("webhook event replayed", "handler_status", handlerResponse.status)
+
+	resp := api.WebhookReplayResult{
+		EventId:        event.ID,
+		EventType:      string(event.Type),
+		Mode:           input.Mode,
+		Status:         handlerResponse.status,
+		Message:        handlerResponse.message(input.Mode),
+		LastReplayedAt: storedEvent.LastReplayedAt,
+	}
+
+	err = app.writeJSON(w, http.StatusOK, resp, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// dryRunCheckoutSessionCompleted runs the checks of a completed checkout session without creating the
+// reservation. Errors are written the way the webhook handler would write them.
+func (app *Application) dryRunCheckoutSessionCompleted(w *replayResponseWriter, r *http.Request, event stripe.Event) {
+	var session stripe.CheckoutSession
+
+	err := json.Unmarshal(event.Data.Raw, &session)
+	if err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("failed to parse checkout session: %w", err))
+		return
+	}
+
+	completion, err := app.prepareCheckoutCompletion(r.Context(), session)
+	if err != nil {
+		if errors.Is(err, errPaymentAlreadyCompleted) {
+			w.outcome = "payment is already completed, the event would be ignored"
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		app.checkoutCompletionErrorResponse(w, r, err)
+		return
+	}
+
+	w.outcome = fmt.Sprintf(
+		"a reservation of %d seats would be created for payment %d",
+		len(completion.cart.Seats),
+		completion.payment.ID,
+	)
+	w.WriteHeader(http.StatusOK)
+}
+
+// replayResponseWriter keeps the response of a replayed webhook handler.
+type replayResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+	// outcome describes a successful dry run
+	outcome string
+}
+
+func newReplayResponseWriter() *replayResponseWriter {
+	return &replayResponseWriter{header: make(http.Header), status: http.StatusOK}
+}
+
+func (rw *replayResponseWriter) Header() http.Header {
+	return rw.header
+}
+
+func (rw *replayResponseWriter) WriteHeader(status int) {
+	rw.status = status
+}
+
+func (rw *replayResponseWriter) Write(b []byte) (int, error) {
+	return rw.body.Write(b)
+}
+
+// message is the error message of a rejected event, or what the replay did otherwise.
+func (rw *replayResponseWriter) message(mode api.WebhookReplayMode) string {
+	if rw.status >= http.StatusBadRequest {
+		var errResp api.ErrorResponse
+		if err := json.Unmarshal(rw.body.Bytes(), &errResp); err == nil && errResp.Message != "" {
+			return errResp.Message
+		}
+
+		return http.StatusText(rw.status)
+	}
+
+	if mode == api.DryRun {
+		return rw.outcome
+	}
+
+	return "event was handled again"
+}
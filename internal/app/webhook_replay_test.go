@@ -0,0 +1,272 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/api"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	appvalidator "github.com/metinatakli/movie-reservation-system/internal/validator"
+	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/stripe/stripe-go/v82"
+)
+
+type WebhookReplayTestSuite struct {
+	suite.Suite
+	app              *Application
+	webhookEventRepo *mocks.MockWebhookEventRepo
+	paymentRepo      *mocks.MockPaymentRepo
+	redisClient      *mocks.MockRedisClient
+}
+
+func (s *WebhookReplayTestSuite) SetupTest() {
+	s.webhookEventRepo = new(mocks.MockWebhookEventRepo)
+	s.paymentRepo = new(mocks.MockPaymentRepo)
+	s.redisClient = new(mocks.MockRedisClient)
+	s.app = newTestApplication(func(a *Application) {
+		a.webhookEventRepo = s.webhookEventRepo
+		a.paymentRepo = s.paymentRepo
+		a.redis = s.redisClient
+	})
+}
+
+func TestWebhookReplaySuite(t *testing.T) {
+	suite.Run(t, new(WebhookReplayTestSuite))
+}
+
+// storedWebhookEvent builds an event as the webhook handler stores it.
+func storedWebhookEvent(t *testing.T, id, eventType string, object any, lastReplayedAt *time.Time) *domain.WebhookEvent {
+	t.Helper()
+
+	raw, err := json.Marshal(object)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := json.Marshal(map[string]any{
+		"id":          id,
+		"object":      "event",
+		"type":        eventType,
+		"api_version": stripe.APIVersion,
+		"data":        map[string]any{"object": json.RawMessage(raw)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &domain.WebhookEvent{
+		ID:             id,
+		Type:           eventType,
+		Payload:        payload,
+		ReceivedAt:     time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC),
+		LastReplayedAt: lastReplayedAt,
+	}
+}
+
+func (s *WebhookReplayTestSuite) TestReplayStripeWebhookEvent() {
+	checkoutSession := map[string]any{
+		"id":     "cs_1",
+		"object": "checkout.session",
+		"metadata": map[string]string{
+			domain.CheckoutMetadataCartID:    "cart-1",
+			domain.CheckoutMetadataSessionID: "session-1",
+			domain.CheckoutMetadataUserID:    "7",
+			domain.CheckoutMetadataPaymentID: "3",
+		},
+	}
+
+	refund := map[string]any{
+		"id":             "re_1",
+		"object":         "refund",
+		"amount":         1250,
+		"currency":       "usd",
+		"status":         "succeeded",
+		"payment_intent": "pi_1",
+	}
+
+	cart := domain.Cart{
+		ShowtimeID: 1,
+		BasePrice:  decimal.NewFromInt(10),
+		Seats: []domain.CartSeat{
+			{Id: 1, Row: 1, Col: 1, SeatType: "standard"},
+			{Id: 2, Row: 1, Col: 2, SeatType: "standard"},
+		},
+	}
+
+	cartJSON, err := json.Marshal(cart)
+	s.Require().NoError(err)
+
+	lastReplayedAt := time.Date(2025, 5, 2, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		input          any
+		setupMocks     func()
+		wantStatus     int
+		wantErrMessage string
+		wantResult     *api.WebhookReplayResult
+	}{
+		{
+			name:           "invalid mode",
+			input:          map[string]any{"eventId": "evt_1", "mode": "force"},
+			wantStatus:     http.StatusUnprocessableEntity,
+			wantErrMessage: fmt.Sprintf(appvalidator.ErrOneOf, "dry_run apply"),
+		},
+		{
+			name:  "event was not received",
+			input: api.ReplayWebhookEventRequest{EventId: "evt_unknown", Mode: api.Apply},
+			setupMocks: func() {
+				s.webhookEventRepo.On("GetById", mock.Anything, "evt_unknown").Return(nil, domain.ErrRecordNotFound)
+			},
+			wantStatus:     http.StatusNotFound,
+			wantErrMessage: "webhook event evt_unknown was not received",
+		},
+		{
+			name:  "dry run of an event type that can't be evaluated",
+			input: api.ReplayWebhookEventRequest{EventId: "evt_refund", Mode: api.DryRun},
+			setupMocks: func() {
+				s.webhookEventRepo.On("GetById", mock.Anything, "evt_refund").
+					Return(storedWebhookEvent(s.T(), "evt_refund", "refund.updated", refund, nil), nil)
+			},
+			wantStatus:     http.StatusBadRequest,
+			wantErrMessage: "dry run is not supported for refund.updated events",
+		},
+		{
+			name:  "dry run reports the reservation that would be created",
+			input: api.ReplayWebhookEventRequest{EventId: "evt_checkout", Mode: api.DryRun},
+			setupMocks: func() {
+				s.webhookEventRepo.On("GetById", mock.Anything, "evt_checkout").
+					Return(storedWebhookEvent(s.T(), "evt_checkout", "checkout.session.completed", checkoutSession, nil), nil)
+				s.paymentRepo.On("GetById", mock.Anything, 3).
+					Return(&domain.Payment{ID: 3, Status: domain.PaymentStatusPending}, nil)
+				s.redisClient.On("Get", mock.Anything, "cart-1").Return(redis.NewStringResult(string(cartJSON), nil))
+				s.redisClient.On("Get", mock.Anything, seatLockKey(1, 1)).Return(redis.NewStringResult("session-1", nil))
+				s.redisClient.On("Get", mock.Anything, seatLockKey(1, 2)).Return(redis.NewStringResult("session-1", nil))
+			},
+			wantStatus: http.StatusOK,
+			wantResult: &api.WebhookReplayResult{
+				EventId:   "evt_checkout",
+				EventType: "checkout.session.completed",
+				Mode:      api.DryRun,
+				Status:    http.StatusOK,
+				Message:   "a reservation of 2 seats would be created for payment 3",
+			},
+		},
+		{
+			name:  "dry run of an already completed payment",
+			input: api.ReplayWebhookEventRequest{EventId: "evt_checkout", Mode: api.DryRun},
+			setupMocks: func() {
+				s.webhookEventRepo.On("GetById", mock.Anything, "evt_checkout").Return(
+					storedWebhookEvent(s.T(), "evt_checkout", "checkout.session.completed", checkoutSession, &lastReplayedAt), nil)
+				s.paymentRepo.On("GetById", mock.Anything, 3).
+					Return(&domain.Payment{ID: 3, Status: domain.PaymentStatusCompleted}, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantResult: &api.WebhookReplayResult{
+				EventId:        "evt_checkout",
+				EventType:      "checkout.session.completed",
+				Mode:           api.DryRun,
+				Status:         http.StatusOK,
+				Message:        "payment is already completed, the event would be ignored",
+				LastReplayedAt: &lastReplayedAt,
+			},
+		},
+		{
+			name:  "dry run reports the error the handler would respond with",
+			input: api.ReplayWebhookEventRequest{EventId: "evt_checkout", Mode: api.DryRun},
+			setupMocks: func() {
+				s.webhookEventRepo.On("GetById", mock.Anything, "evt_checkout").
+					Return(storedWebhookEvent(s.T(), "evt_checkout", "checkout.session.completed", checkoutSession, nil), nil)
+				s.paymentRepo.On("GetById", mock.Anything, 3).
+					Return(&domain.Payment{ID: 3, Status: domain.PaymentStatusPending}, nil)
+				s.redisClient.On("Get", mock.Anything, "cart-1").Return(redis.NewStringResult("", redis.Nil))
+				s.redisClient.On("Del", mock.Anything, []string{cartSessionKey("session-1")}).
+					Return(redis.NewIntResult(0, nil))
+			},
+			wantStatus: http.StatusOK,
+			wantResult: &api.WebhookReplayResult{
+				EventId:   "evt_checkout",
+				EventType: "checkout.session.completed",
+				Mode:      api.DryRun,
+				Status:    http.StatusNotFound,
+				Message:   fmt.Sprintf("cart cart-1: %s", domain.ErrCartNotFound),
+			},
+		},
+		{
+			name:  "apply runs the handler and records the replay",
+			input: api.ReplayWebhookEventRequest{EventId: "evt_refund", Mode: api.Apply},
+			setupMocks: func() {
+				s.webhookEventRepo.On("GetById", mock.Anything, "evt_refund").
+					Return(storedWebhookEvent(s.T(), "evt_refund", "refund.updated", refund, nil), nil)
+				s.paymentRepo.On("RecordRefund", mock.Anything, "pi_1", mock.MatchedBy(func(r *domain.Refund) bool {
+					return r.StripeRefundID == "re_1"
+				})).Return(true, nil)
+				s.webhookEventRepo.On("MarkReplayed", mock.Anything, "evt_refund").Return(nil)
+			},
+			wantStatus: http.StatusOK,
+			wantResult: &api.WebhookReplayResult{
+				EventId:   "evt_refund",
+				EventType: "refund.updated",
+				Mode:      api.Apply,
+				Status:    http.StatusOK,
+				Message:   "event was handled again",
+			},
+		},
+		{
+			name:  "apply reports the handler rejecting the event",
+			input: api.ReplayWebhookEventRequest{EventId: "evt_refund", Mode: api.Apply},
+			setupMocks: func() {
+				s.webhookEventRepo.On("GetById", mock.Anything, "evt_refund").
+					Return(storedWebhookEvent(s.T(), "evt_refund", "refund.updated", refund, nil), nil)
+				s.paymentRepo.On("RecordRefund", mock.Anything, "pi_1", mock.Anything).
+					Return(false, domain.ErrRecordNotFound)
+				s.webhookEventRepo.On("MarkReplayed", mock.Anything, "evt_refund").Return(nil)
+			},
+			wantStatus: http.StatusOK,
+			wantResult: &api.WebhookReplayResult{
+				EventId:   "evt_refund",
+				EventType: "refund.updated",
+				Mode:      api.Apply,
+				Status:    http.StatusNotFound,
+				Message:   "payment not found for payment intent pi_1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.SetupTest()
+
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			w, r := executeRequest(s.T(), http.MethodPost, "/admin/webhooks/stripe/replay", tt.input)
+
+			s.app.ReplayStripeWebhookEvent(w, r)
+
+			s.Equal(tt.wantStatus, w.Code)
+			checkErrorResponse(s.T(), w, struct {
+				wantStatus     int
+				wantErrMessage string
+			}{tt.wantStatus, tt.wantErrMessage})
+
+			if tt.wantResult != nil {
+				var got api.WebhookReplayResult
+				s.Require().NoError(json.NewDecoder(w.Body).Decode(&got))
+				s.Equal(*tt.wantResult, got)
+			}
+
+			s.webhookEventRepo.AssertExpectations(s.T())
+			s.paymentRepo.AssertExpectations(s.T())
+			s.redisClient.AssertExpectations(s.T())
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// Package crm delivers the lifecycle events of users to the CRM of the marketing team, as webhooks posted
+// to a configured URL.
+package crm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/shopspring/decimal"
+)
+
+// Event is the body posted for a lifecycle event. Data is a UserData for user.registered and
+// user.activated, a DeletedUserData for user.deleted and a PurchaseData for user.first_purchase.
+type Event struct {
+	// ID is unique per event and kept across retries, the CRM drops deliveries it has seen
+	ID         string                    `json:"id"`
+	Type       domain.LifecycleEventType `json:"type"`
+	OccurredAt time.Time                 `json:"occurredAt"`
+	Data       any                       `json:"data"`
+}
+
+type UserData struct {
+	UserID    int    `json:"userId"`
+	Email     string `json:"email"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+}
+
+type DeletedUserData struct {
+	UserID int    `json:"userId"`
+	Email  string `json:"email"`
+}
+
+type PurchaseData struct {
+	UserID    int             `json:"userId"`
+	Email     string          `json:"email"`
+	PaymentID int             `json:"paymentId"`
+	Amount    decimal.Decimal `json:"amount"`
+	Currency  string          `json:"currency"`
+}
+
+// NewEvent reads the payload of the event into the data of its type, so only the fields of the schema are
+// sent whatever the database recorded.
+func NewEvent(event domain.LifecycleEvent) (Event, error) {
+	var data any
+
+	switch event.Type {
+	case domain.EventUserRegistered, domain.EventUserActivated:
+		data = &UserData{}
+	case domain.EventUserDeleted:
+		data = &DeletedUserData{}
+	case domain.EventUserFirstPurchase:
+		data = &PurchaseData{}
+	default:
+		return Event{}, fmt.Errorf("unknown lifecycle event type %q", event.Type)
+	}
+
+	err := json.Unmarshal(event.Payload, data)
+	if err != nil {
+		return Event{}, fmt.Errorf("invalid payload of %s event: %w", event.Type, err)
+	}
+
+	return Event{
+		ID:         strconv.Itoa(event.ID),
+		Type:       event.Type,
+		OccurredAt: event.OccurredAt.UTC(),
+		Data:       data,
+	}, nil
+}
+
+// Client posts the events to the webhook URL of the CRM.
+type Client struct {
+	client *http.Client
+	url    string
+	// value of the Authorization header, e.g. "Bearer <token>", not sent when empty
+	authorization string
+}
+
+func NewClient(client *http.Client, url, authorization string) *Client {
+	return &Client{
+		client:        client,
+		url:           url,
+		authorization: authorization,
+	}
+}
+
+// Deliver posts the event. Any response but a 2xx is an error, the delivery is retried then.
+func (c *Client) Deliver(ctx context.Context, event domain.LifecycleEvent) error {
+	body, err := NewEvent(event)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", body.ID)
+
+	if c.authorization != "" {
+		req.Header.Set("Authorization", c.authorization)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// drained so the connection is reused
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("crm responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
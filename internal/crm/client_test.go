@@ -0,0 +1,130 @@
+package crm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+func TestClientDeliver(t *testing.T) {
+	occurredAt := time.Date(2025, 6, 14, 20, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		event         domain.LifecycleEvent
+		authorization string
+		status        int
+		wantRequest   bool
+		wantErr       bool
+		wantData      map[string]any
+	}{
+		{
+			name: "posts a registration",
+			event: domain.LifecycleEvent{
+				ID:         12,
+				Type:       domain.EventUserRegistered,
+				Payload:    json.RawMessage(`{"userId":7,"email":"jane@example.com","firstName":"Jane","lastName":"Doe","password":"x"}`),
+				OccurredAt: occurredAt,
+			},
+			authorization: "Bearer secret",
+			status:        http.StatusAccepted,
+			wantRequest:   true,
+			wantData:      map[string]any{"userId": 7.0, "email": "jane@example.com", "firstName": "Jane", "lastName": "Doe"},
+		},
+		{
+			name: "posts a first purchase",
+			event: domain.LifecycleEvent{
+				ID:         13,
+				Type:       domain.EventUserFirstPurchase,
+				Payload:    json.RawMessage(`{"userId":7,"email":"jane@example.com","paymentId":3,"amount":"24.50","currency":"usd"}`),
+				OccurredAt: occurredAt,
+			},
+			status:      http.StatusOK,
+			wantRequest: true,
+			wantData:    map[string]any{"userId": 7.0, "email": "jane@example.com", "paymentId": 3.0, "amount": "24.5", "currency": "usd"},
+		},
+		{
+			name: "fails on an error response",
+			event: domain.LifecycleEvent{
+				ID:         14,
+				Type:       domain.EventUserDeleted,
+				Payload:    json.RawMessage(`{"userId":7,"email":"jane@example.com"}`),
+				OccurredAt: occurredAt,
+			},
+			status:      http.StatusServiceUnavailable,
+			wantRequest: true,
+			wantErr:     true,
+			wantData:    map[string]any{"userId": 7.0, "email": "jane@example.com"},
+		},
+		{
+			name: "fails on an unknown type",
+			event: domain.LifecycleEvent{
+				ID:      15,
+				Type:    "user.renamed",
+				Payload: json.RawMessage(`{}`),
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requested bool
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requested = true
+
+				if got := r.Header.Get("Authorization"); got != tt.authorization {
+					t.Errorf("Authorization = %q, want %q", got, tt.authorization)
+				}
+				if got, want := r.Header.Get("Idempotency-Key"), strconv.Itoa(tt.event.ID); got != want {
+					t.Errorf("Idempotency-Key = %q, want %q", got, want)
+				}
+
+				var body struct {
+					ID         string         `json:"id"`
+					Type       string         `json:"type"`
+					OccurredAt time.Time      `json:"occurredAt"`
+					Data       map[string]any `json:"data"`
+				}
+
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Fatalf("failed to decode body: %v", err)
+				}
+
+				if body.Type != string(tt.event.Type) || !body.OccurredAt.Equal(occurredAt) {
+					t.Errorf("unexpected event: %+v", body)
+				}
+
+				if len(body.Data) != len(tt.wantData) {
+					t.Errorf("data = %v, want %v", body.Data, tt.wantData)
+				}
+				for k, want := range tt.wantData {
+					if body.Data[k] != want {
+						t.Errorf("data[%s] = %v, want %v", k, body.Data[k], want)
+					}
+				}
+
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			client := NewClient(srv.Client(), srv.URL, tt.authorization)
+
+			err := client.Deliver(context.Background(), tt.event)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+
+			if requested != tt.wantRequest {
+				t.Errorf("requested = %v, want %v", requested, tt.wantRequest)
+			}
+		})
+	}
+}
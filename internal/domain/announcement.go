@@ -0,0 +1,124 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type AnnouncementAudience string
+
+const (
+	// AudienceAll targets every active user.
+	AudienceAll AnnouncementAudience = "all"
+	// AudienceTheaterUpcoming targets users holding a reservation for an upcoming showtime at a theater.
+	AudienceTheaterUpcoming AnnouncementAudience = "theater-upcoming"
+)
+
+type AnnouncementStatus string
+
+const (
+	AnnouncementScheduled AnnouncementStatus = "scheduled"
+	AnnouncementSent      AnnouncementStatus = "sent"
+	AnnouncementCancelled AnnouncementStatus = "cancelled"
+)
+
+type Announcement struct {
+	ID             int
+	Title          string
+	Body           string
+	Audience       AnnouncementAudience
+	TheaterID      *int
+	SendEmail      bool
+	Status         AnnouncementStatus
+	ScheduledAt    time.Time
+	CreatedBy      int
+	RecipientCount *int
+	CreatedAt      time.Time
+	SentAt         *time.Time
+	CancelledAt    *time.Time
+}
+
+const (
+	minAnnouncementEmailBackoff = time.Minute
+	maxAnnouncementEmailBackoff = time.Hour
+)
+
+// AnnouncementEmail is the email of an announcement to one of its recipients. The emails are queued along
+// with the notifications of the announcement and stay due until they are sent, so a recipient is never
+// left out by a failed send.
+type AnnouncementEmail struct {
+	NotificationID int
+	UserID         int
+	FirstName      string
+	Email          string
+	Title          string
+	Body           string
+	Attempts       int
+	LastError      string
+	// NextAttemptAt is unset once the email is sent or retrying is given up
+	NextAttemptAt *time.Time
+}
+
+// Failed records a failed send and schedules the next one with a backoff doubling from a minute up to an
+// hour. Retrying is given up once maxAttempts is reached.
+func (e *AnnouncementEmail) Failed(err error, now time.Time, maxAttempts int) {
+	e.Attempts++
+	e.LastError = err.Error()
+
+	if e.Attempts >= maxAttempts {
+		e.NextAttemptAt = nil
+		return
+	}
+
+	backoff := minAnnouncementEmailBackoff
+	for i := 1; i < e.Attempts && backoff < maxAnnouncementEmailBackoff; i++ {
+		backoff *= 2
+	}
+
+	next := now.Add(min(backoff, maxAnnouncementEmailBackoff))
+	e.NextAttemptAt = &next
+}
+
+type Notification struct {
+	ID             int
+	UserID         int
+	AnnouncementID *int
+	Title          string
+	Body           string
+	CreatedAt      time.Time
+	ReadAt         *time.Time
+}
+
+// NotificationDigest is the daily email of a user in digest mode, with the notifications that weren't emailed
+// since their previous digest.
+type NotificationDigest struct {
+	UserID        int
+	FirstName     string
+	Email         string
+	Notifications []Notification
+}
+
+type AnnouncementRepository interface {
+	Create(ctx context.Context, announcement *Announcement) error
+	// Cancel cancels an announcement which is not delivered yet. It returns ErrAnnouncementNotCancellable
+	// when the announcement is already sent or cancelled.
+	Cancel(ctx context.Context, id int) (*Announcement, error)
+	// DeliverDue delivers scheduled announcements that are due at the given time to the notification
+	// centers of their audience and marks them as sent. Announcements are claimed with row locks, so
+	// concurrent callers never deliver the same announcement twice. The emails of announcements which ask
+	// for it are queued in the same transaction, except for recipients in digest mode, whose notifications
+	// wait for the digest instead.
+	DeliverDue(ctx context.Context, now time.Time) ([]Announcement, error)
+	// GetDueEmails returns up to limit queued announcement emails whose next attempt is before now.
+	GetDueEmails(ctx context.Context, now time.Time, limit int) ([]AnnouncementEmail, error)
+	MarkEmailSent(ctx context.Context, notificationID int, sentAt time.Time) error
+	// RecordEmailFailure stores the attempts, last error and next attempt of the email.
+	RecordEmailFailure(ctx context.Context, email AnnouncementEmail) error
+	// GetDueDigests returns the digests of users with notifications waiting for a digest, whose previous
+	// digest was sent before sentBefore.
+	GetDueDigests(ctx context.Context, sentBefore time.Time, limit int) ([]NotificationDigest, error)
+	// MarkDigestSent records the digest of the user as sent with the given notifications.
+	MarkDigestSent(ctx context.Context, userID int, notificationIDs []int, sentAt time.Time) error
+	GetNotificationsByUserId(ctx context.Context, userId int, pagination Pagination) ([]Notification, *Metadata, error)
+	MarkNotificationRead(ctx context.Context, userId, notificationId int) error
+}
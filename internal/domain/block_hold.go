@@ -0,0 +1,98 @@
+package domain
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+type BlockHoldStatus string
+
+const (
+	BlockHoldActive    BlockHoldStatus = "active"
+	BlockHoldConfirmed BlockHoldStatus = "confirmed"
+	BlockHoldReleased  BlockHoldStatus = "released"
+	BlockHoldExpired   BlockHoldStatus = "expired"
+)
+
+// BlockHold takes a block of seats of a showtime out of sale for an event, e.g. the rows booked by the
+// organizer of a premiere. Unlike the seats of a cart, the seats are held until the hold is confirmed into a
+// reservation, released or it expires.
+type BlockHold struct {
+	ID         int
+	ShowtimeID int
+	SeatIDs    []int
+	// Reason tells what the seats are held for, e.g. the name of the event
+	Reason    string
+	Note      string
+	Status    BlockHoldStatus
+	ExpiresAt time.Time
+	// ReservationID is set once the hold is confirmed, nil again if the reservation is deleted
+	ReservationID *int
+	// CreatedBy is nil once the admin who created the hold is deleted
+	CreatedBy *int
+	CreatedAt time.Time
+}
+
+// Active reports whether the hold still keeps its seats at the given time.
+func (h *BlockHold) Active(now time.Time) bool {
+	return h.Status == BlockHoldActive && now.Before(h.ExpiresAt)
+}
+
+// ContiguousSeats reports whether the seats form a block without gaps: the seats of every row are next to
+// each other and the rows follow each other. Seats of a single row are contiguous if they are side by side.
+func ContiguousSeats(seats []Seat) bool {
+	if len(seats) == 0 {
+		return false
+	}
+
+	cols := make(map[int][]int)
+	for _, seat := range seats {
+		cols[seat.Row] = append(cols[seat.Row], seat.Col)
+	}
+
+	rows := make([]int, 0, len(cols))
+	for row := range cols {
+		rows = append(rows, row)
+	}
+
+	slices.Sort(rows)
+
+	for i, row := range rows {
+		if i > 0 && row != rows[i-1]+1 {
+			return false
+		}
+
+		rowCols := cols[row]
+		slices.Sort(rowCols)
+
+		for j := 1; j < len(rowCols); j++ {
+			if rowCols[j] != rowCols[j-1]+1 {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+type BlockHoldRepository interface {
+	// Create stores the hold along with the seat blocks of its seats. It returns ErrRecordNotFound if a seat
+	// is not in the hall of the showtime.
+	Create(ctx context.Context, hold *BlockHold) error
+	// Get returns ErrRecordNotFound if the hold doesn't exist.
+	Get(ctx context.Context, id int) (*BlockHold, error)
+	// GetActiveByShowtime returns the active holds of the showtime, the first to expire first.
+	GetActiveByShowtime(ctx context.Context, showtimeID int) ([]BlockHold, error)
+	// Confirm creates the reservation of the held seats along with its payment, unblocks the seats and
+	// closes the hold, in one transaction. It returns ErrRecordNotFound if the hold doesn't exist,
+	// ErrBlockHoldNotActive if it isn't active at the given time and ErrSeatAlreadyReserved if a seat was
+	// sold meanwhile.
+	Confirm(ctx context.Context, id int, now time.Time, reservation *Reservation, payment *Payment) error
+	// Release unblocks the seats of the hold and closes it. It returns ErrRecordNotFound if the hold doesn't
+	// exist and ErrBlockHoldNotActive if it isn't active.
+	Release(ctx context.Context, id int) (*BlockHold, error)
+	// Expire closes the active holds which expired by the given time and unblocks their seats. The expired
+	// holds are returned.
+	Expire(ctx context.Context, now time.Time) ([]BlockHold, error)
+}
@@ -8,15 +8,45 @@ import (
 )
 
 type Cart struct {
-	Id          string `json:"-"`
-	ShowtimeID  int
-	TotalPrice  decimal.Decimal
-	BasePrice   decimal.Decimal
-	MovieName   string
-	TheaterName string
-	HallName    string
-	Date        time.Time
-	Seats       []CartSeat
+	Id         string `json:"-"`
+	ShowtimeID int
+	TotalPrice decimal.Decimal
+	BasePrice  decimal.Decimal
+	// Format and FormatSurcharge are taken when the cart is built, a later change of the
+	// showtime's format or surcharge doesn't reprice it
+	Format          ScreeningFormat
+	FormatSurcharge decimal.Decimal
+	MovieName       string
+	TheaterName     string
+	HallName        string
+	Date            time.Time
+	TheaterTimeZone string
+	Seats           []CartSeat
+	// SeatPrices are the prices of the seats when the cart is built. The checkout charges them as they are,
+	// a change of the pricing while the seats are held doesn't change what the user pays.
+	SeatPrices []SeatPrice
+	// FlexibleTicketFee is the fee of the flexible ticket per seat when the cart is built, it's charged once
+	// FlexibleTicket is chosen at checkout
+	FlexibleTicketFee decimal.Decimal
+	FlexibleTicket    bool
+	// PayoutAccountID is the Stripe Connect account the theater is paid out to when the cart is built
+	PayoutAccountID string
+	// SalesWindow is the sales window of the showtime when the cart is built, the checkout is refused once
+	// it closes
+	SalesWindow SalesWindow
+	// Note and SpecialRequests are given at checkout and copied to the reservation
+	Note            string
+	SpecialRequests []SpecialRequest
+	// CheckoutSessionID is the latest checkout session of the cart, it's expired when the cart is deleted
+	// or another checkout replaces it
+	CheckoutSessionID string
+	// SalesChannel is empty for carts of the web checkout. Carts booked by staff for a customer without
+	// an account carry the GuestEmail the reservation is made for.
+	SalesChannel SalesChannel
+	GuestEmail   string
+	// CreatedAt is when the seats of the cart were first held, their holds aren't extended for checkouts
+	// beyond a fixed time from it
+	CreatedAt time.Time
 }
 
 type CartSeat struct {
@@ -25,36 +55,78 @@ type CartSeat struct {
 	Col        int
 	SeatType   string
 	ExtraPrice decimal.Decimal
+	// the cart keeps the price it was created with, even if a new price takes effect before checkout
+	PriceVersionID *int
 }
 
 func NewCart(showtimeID int, showtimeSeats *ShowtimeSeats) Cart {
 	id := uuid.New().String()
 	seats := toCartSeats(showtimeSeats.Seats)
 	basePrice := decimal.NewFromFloat(showtimeSeats.Price)
-	totalPrice := calculateTotalPrice(basePrice, seats)
+	breakdown := showtimeSeats.PriceBreakdown()
 
 	return Cart{
-		Id:          id,
-		ShowtimeID:  showtimeID,
-		TotalPrice:  totalPrice,
-		BasePrice:   basePrice,
-		MovieName:   showtimeSeats.MovieName,
-		TheaterName: showtimeSeats.TheaterName,
-		HallName:    showtimeSeats.HallName,
-		Date:        showtimeSeats.Date,
-		Seats:       seats,
+		Id:              id,
+		ShowtimeID:      showtimeID,
+		TotalPrice:      breakdown.Total,
+		BasePrice:       basePrice,
+		Format:          showtimeSeats.Format,
+		FormatSurcharge: showtimeSeats.FormatSurcharge,
+		MovieName:       showtimeSeats.MovieName,
+		TheaterName:     showtimeSeats.TheaterName,
+		HallName:        showtimeSeats.HallName,
+		Date:            showtimeSeats.Date,
+		TheaterTimeZone: showtimeSeats.TheaterTimeZone,
+		Seats:           seats,
+		SeatPrices:      breakdown.Seats,
+		PayoutAccountID: showtimeSeats.PayoutAccountID,
+		SalesWindow:     showtimeSeats.SalesWindow,
+		CreatedAt:       time.Now(),
 	}
 }
 
-func calculateTotalPrice(basePrice decimal.Decimal, cartSeats []CartSeat) decimal.Decimal {
-	total := decimal.Zero
+// PriceBreakdown itemizes the cart's total price from the prices it was created with, which is what the
+// checkout charges. A cart without them, e.g. one created before they were kept, is priced again for display
+// only, CheckPrices refuses its checkout.
+func (c Cart) PriceBreakdown() PriceBreakdown {
+	var breakdown PriceBreakdown
 
-	for _, v := range cartSeats {
-		seatPrice := basePrice.Add(v.ExtraPrice)
-		total = total.Add(seatPrice)
+	if c.CheckPrices() == nil {
+		breakdown = NewSeatPriceBreakdown(c.SeatPrices)
+	} else {
+		breakdown = NewPriceBreakdown(c.BasePrice, c.FormatSurcharge, c.Seats)
 	}
 
-	return total
+	if c.FlexibleTicket {
+		breakdown.AddAddOn(PriceAdjustment{
+			Code:        AddOnFlexibleTicket,
+			Description: "Flexible ticket",
+			Amount:      c.FlexibleTicketFee.Mul(decimal.NewFromInt(int64(len(c.Seats)))),
+		})
+	}
+
+	return breakdown
+}
+
+// CheckPrices returns ErrCartPricesMissing unless the cart holds the price of every one of its seats.
+func (c Cart) CheckPrices() error {
+	if len(c.SeatPrices) != len(c.Seats) {
+		return ErrCartPricesMissing
+	}
+
+	for i, seat := range c.Seats {
+		if c.SeatPrices[i].SeatID != seat.Id {
+			return ErrCartPricesMissing
+		}
+	}
+
+	return nil
+}
+
+// SetFlexibleTicket adds or removes the flexible ticket and updates the total price.
+func (c *Cart) SetFlexibleTicket(flexible bool) {
+	c.FlexibleTicket = flexible
+	c.TotalPrice = c.PriceBreakdown().Total
 }
 
 func toCartSeats(seats []Seat) []CartSeat {
@@ -62,10 +134,11 @@ func toCartSeats(seats []Seat) []CartSeat {
 
 	for i, seat := range seats {
 		cartSeat := CartSeat{
-			Id:       seat.ID,
-			Row:      seat.Row,
-			Col:      seat.Col,
-			SeatType: seat.Type,
+			Id:             seat.ID,
+			Row:            seat.Row,
+			Col:            seat.Col,
+			SeatType:       seat.Type,
+			PriceVersionID: seat.PriceVersionID,
 		}
 
 		priceFloat := seat.ExtraPrice
@@ -76,3 +149,12 @@ func toCartSeats(seats []Seat) []CartSeat {
 
 	return cartSeats
 }
+
+func (c Cart) SeatIDs() []int {
+	seatIDs := make([]int, len(c.Seats))
+	for i, seat := range c.Seats {
+		seatIDs[i] = seat.Id
+	}
+
+	return seatIDs
+}
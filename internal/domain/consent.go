@@ -0,0 +1,21 @@
+package domain
+
+// ConsentPurpose is what a user consents to. Every decision of a user on a purpose is kept as evidence of
+// the consent. An opt-in only counts once the user confirms it from their address (double opt-in), a
+// withdrawal counts as soon as it's made.
+type ConsentPurpose string
+
+const (
+	ConsentMarketingEmails ConsentPurpose = "marketing_emails"
+)
+
+// ConsentSource is where the user made a consent decision.
+type ConsentSource string
+
+const (
+	ConsentSourceRegistration ConsentSource = "registration"
+	ConsentSourcePreferences  ConsentSource = "preferences"
+	ConsentSourceUnsubscribe  ConsentSource = "unsubscribe"
+	// ConsentSourceLegacy is an opt-in made before consents were tracked.
+	ConsentSourceLegacy ConsentSource = "legacy"
+)
@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrRefreshTokenReused is returned when a refresh token which was already rotated is presented again.
+// Only one of the legitimate client and a thief can hold the current token, so the device is signed out.
+var ErrRefreshTokenReused = errors.New("refresh token was already used")
+
+// DeviceSession is the token based login of a mobile device. Only hashes of the tokens are stored.
+type DeviceSession struct {
+	ID               int
+	UserID           int
+	DeviceID         string
+	DeviceName       string
+	RefreshTokenHash []byte
+	AccessTokenHash  []byte
+	ExpiresAt        time.Time
+	LastUsedAt       time.Time
+	CreatedAt        time.Time
+}
+
+type DeviceSessionRepository interface {
+	// Create stores the session, replacing an earlier session of the same device. The replaced session is
+	// returned so its access token can be invalidated.
+	Create(ctx context.Context, session *DeviceSession) (*DeviceSession, error)
+	// Rotate replaces the refresh and access token hashes of the unexpired session holding the given
+	// refresh token. It returns ErrRefreshTokenReused and deletes the session when the token was already
+	// rotated, and ErrRecordNotFound when the token is unknown. The returned session carries the hashes
+	// before the rotation.
+	Rotate(ctx context.Context, refreshTokenHash []byte, next *DeviceSession) (*DeviceSession, error)
+	// Revoke deletes the session holding the given refresh token.
+	Revoke(ctx context.Context, refreshTokenHash []byte) (*DeviceSession, error)
+	// RevokeAllForUser deletes every session of the user. The deleted sessions are returned so their access
+	// tokens can be invalidated.
+	RevokeAllForUser(ctx context.Context, userID int) ([]DeviceSession, error)
+}
@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Dispute is a chargeback opened by a card holder against a payment. Status and reason hold the values
+// reported by Stripe, e.g. needs_response or fraudulent.
+type Dispute struct {
+	ID              int
+	PaymentID       int
+	ReservationID   *int
+	StripeDisputeID string
+	Amount          decimal.Decimal
+	Currency        string
+	Reason          string
+	Status          string
+	EvidenceDueBy   *time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+type DisputeRepository interface {
+	// Record stores the latest state of a dispute against the payment with the given payment intent.
+	// The first time a dispute is seen, the reservation paid with the payment is flagged as disputed
+	// and, if revokeTickets is set, its tickets are revoked. It reports whether the dispute is new.
+	// Closed disputes are not reopened by late events.
+	Record(ctx context.Context, paymentIntentID string, dispute *Dispute, revokeTickets bool) (bool, error)
+	// List returns disputes, newest first, optionally filtered by status.
+	List(ctx context.Context, status string, pagination Pagination) ([]Dispute, *Metadata, error)
+}
@@ -0,0 +1,90 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// EmailCampaign announces a new release by email to the users who opted in to marketing emails. The
+// recipients are resolved when the campaign is created and are sent to in the background.
+type EmailCampaign struct {
+	ID             int
+	MovieID        int
+	Subject        string
+	Body           string
+	Filter         CampaignFilter
+	CreatedBy      int
+	RecipientCount int
+	CreatedAt      time.Time
+}
+
+// CampaignFilter narrows the recipients of a campaign down. Zero values don't filter, the location
+// fields are set together.
+type CampaignFilter struct {
+	// FavoriteTheaterID targets the users whose favorite theater it is.
+	FavoriteTheaterID *int
+	// users whose default location is within RadiusKm of the coordinates
+	Latitude  *float64
+	Longitude *float64
+	RadiusKm  *float64
+}
+
+type CampaignDeliveryStatus string
+
+const (
+	CampaignDeliveryPending CampaignDeliveryStatus = "pending"
+	CampaignDeliverySent    CampaignDeliveryStatus = "sent"
+	CampaignDeliveryFailed  CampaignDeliveryStatus = "failed"
+	// CampaignDeliverySkipped is a recipient who opted out before the email was sent.
+	CampaignDeliverySkipped CampaignDeliveryStatus = "skipped"
+)
+
+// CampaignDelivery is a pending email of a campaign to one of its recipients.
+type CampaignDelivery struct {
+	CampaignID  int
+	UserID      int
+	Email       string
+	FirstName   string
+	Subject     string
+	Body        string
+	MovieTitle  string
+	ReleaseDate time.Time
+	PosterUrl   string
+}
+
+type CampaignStats struct {
+	CampaignID   int
+	Recipients   int
+	Pending      int
+	Sent         int
+	Failed       int
+	Skipped      int
+	Opened       int
+	Unsubscribed int
+}
+
+// GenerateCampaignToken returns a random token identifying a campaign email in its unsubscribe and open
+// tracking links, along with the hash which is stored instead of the token.
+func GenerateCampaignToken() (string, []byte, error) {
+	return generateOpaqueToken()
+}
+
+type EmailCampaignRepository interface {
+	// Create stores the campaign along with a pending delivery to every activated user who opted in to
+	// marketing emails with a confirmed consent and matches the filter. It returns ErrMovieNotFound or ErrTheaterNotFound when the
+	// referenced movie or theater doesn't exist.
+	Create(ctx context.Context, campaign *EmailCampaign) error
+	// GetPendingDeliveries returns up to limit pending deliveries, oldest campaigns first. Deliveries to
+	// users who opted out or no longer have a confirmed consent are marked as skipped instead.
+	GetPendingDeliveries(ctx context.Context, limit int) ([]CampaignDelivery, error)
+	MarkDeliverySent(ctx context.Context, campaignID, userID int, tokenHash []byte) error
+	MarkDeliveryFailed(ctx context.Context, campaignID, userID int, errMsg string) error
+	// RecordOpen marks the email with the given token as opened. It returns ErrRecordNotFound if there is
+	// no such email.
+	RecordOpen(ctx context.Context, tokenHash []byte) error
+	// Unsubscribe opts the recipient of the email with the given token out of marketing emails and records
+	// the withdrawal of their consent. It returns ErrRecordNotFound if there is no such email.
+	Unsubscribe(ctx context.Context, tokenHash []byte) error
+	// GetStats returns ErrRecordNotFound if the campaign doesn't exist.
+	GetStats(ctx context.Context, campaignID int) (*CampaignStats, error)
+}
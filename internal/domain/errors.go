@@ -10,4 +10,45 @@ var (
 	ErrCartNotFound        = errors.New("cart not found or has expired")
 	ErrSeatLockExpired     = errors.New("your selections have expired, please select your seats again")
 	ErrSeatConflict        = errors.New("a selected seat does not belong to the current session")
+	ErrCartAlreadyPaid     = errors.New("the checkout of the cart is already paid")
+	ErrCartPricesMissing   = errors.New("the prices of the cart are missing, please select your seats again")
+	ErrTheaterNotFound     = errors.New("theater not found")
+	ErrHallNotFound        = errors.New("hall not found")
+	ErrMovieNotFound       = errors.New("movie not found")
+	ErrSalesNotOpen        = errors.New("ticket sales for the showtime have not opened yet")
+	ErrSalesClosed         = errors.New("ticket sales for the showtime have closed")
+	ErrInvalidSalesWindow  = errors.New("sales must open before they close, and close no later than the showtime starts")
+	ErrCheckInNotOpen      = errors.New("check-in for the showtime has not opened yet")
+	ErrCheckInClosed       = errors.New("check-in for the showtime has closed")
+	ErrAlreadyCheckedIn    = errors.New("the guests of this reservation have already checked in")
+
+	ErrAnnouncementNotCancellable = errors.New("only scheduled announcements can be cancelled")
+	ErrInvalidCheckInPolicy       = errors.New("check-in must not close before guests are late")
+	ErrInvalidPriceAdjustment     = errors.New("the adjustment must not make a base price negative or higher than 9999.99")
+	ErrBlockHoldNotActive         = errors.New("the block hold was already confirmed, released or has expired")
+	ErrPendingEmailChange         = errors.New("the changes include a new email address, confirm it while signed in")
 )
+
+// SeatLocksError tells which seats of a cart lost their locks, so the client can ask the user to select
+// them again instead of starting over. It matches ErrSeatConflict when a seat is locked by someone else,
+// ErrSeatLockExpired otherwise.
+type SeatLocksError struct {
+	// ExpiredSeatIDs lost their locks, they may still be free
+	ExpiredSeatIDs []int
+	// ConflictingSeatIDs are locked by someone else
+	ConflictingSeatIDs []int
+	// HeldSeatIDs are still locked by the owner of the cart
+	HeldSeatIDs []int
+}
+
+func (e *SeatLocksError) Error() string {
+	return e.Unwrap().Error()
+}
+
+func (e *SeatLocksError) Unwrap() error {
+	if len(e.ConflictingSeatIDs) > 0 {
+		return ErrSeatConflict
+	}
+
+	return ErrSeatLockExpired
+}
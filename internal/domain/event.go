@@ -0,0 +1,27 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// EventName is one of the client analytics events accepted from the clients.
+type EventName string
+
+const (
+	EventSeatMapViewed EventName = "seat_map_viewed"
+	EventCartAbandoned EventName = "cart_abandoned"
+)
+
+// AnalyticsEvent is an anonymous product analytics event. Events are tied to a session only through
+// the hash of its token, never to a user.
+type AnalyticsEvent struct {
+	Name        EventName
+	SessionHash []byte
+	ShowtimeID  *int
+	OccurredAt  time.Time
+}
+
+type AnalyticsEventRepository interface {
+	CreateBatch(ctx context.Context, events []AnalyticsEvent) error
+}
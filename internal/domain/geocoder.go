@@ -0,0 +1,23 @@
+package domain
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrAddressNotFound = errors.New("address could not be resolved to a location")
+	ErrGeocoderBusy    = errors.New("too many address searches at the moment, please try again later")
+)
+
+type GeoPoint struct {
+	Latitude  float64
+	Longitude float64
+}
+
+type Geocoder interface {
+	// Geocode resolves a free form address, e.g. a neighborhood or street, to coordinates. It returns
+	// ErrAddressNotFound when the provider has no match for the address, and ErrGeocoderBusy when the
+	// request would wait too long for its turn toward the provider.
+	Geocode(ctx context.Context, address string) (*GeoPoint, error)
+}
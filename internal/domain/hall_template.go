@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// HallTemplate is a reusable seat layout, e.g. "IMAX 20x18 with recliner back rows". Halls created from
+// a template get a copy of its seats, later changes to the template don't affect them.
+type HallTemplate struct {
+	ID          int
+	Name        string
+	Description string
+	Seats       []HallTemplateSeat
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type HallTemplateSeat struct {
+	Row        int
+	Col        int
+	Type       string
+	ExtraPrice decimal.Decimal
+}
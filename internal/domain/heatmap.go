@@ -0,0 +1,48 @@
+package domain
+
+import "time"
+
+// SeatHeatmap shows how often each seat of a hall was sold within a period.
+type SeatHeatmap struct {
+	HallID        int
+	From          time.Time
+	To            time.Time
+	ShowtimeCount int
+	Seats         []SeatSales
+}
+
+type SeatSales struct {
+	SeatID    int
+	Row       int
+	Col       int
+	Type      string
+	SoldCount int
+}
+
+// Merge adds the sales of another period of the same hall to the heatmap.
+func (h *SeatHeatmap) Merge(other SeatHeatmap) {
+	h.ShowtimeCount += other.ShowtimeCount
+
+	if len(h.Seats) == 0 {
+		h.Seats = append(h.Seats, other.Seats...)
+		return
+	}
+
+	soldCounts := make(map[int]int, len(other.Seats))
+	for _, seat := range other.Seats {
+		soldCounts[seat.SeatID] = seat.SoldCount
+	}
+
+	for i := range h.Seats {
+		h.Seats[i].SoldCount += soldCounts[h.Seats[i].SeatID]
+	}
+}
+
+// SellRate returns the ratio of showtimes in which the seat was sold.
+func (h *SeatHeatmap) SellRate(seat SeatSales) float64 {
+	if h.ShowtimeCount == 0 {
+		return 0
+	}
+
+	return float64(seat.SoldCount) / float64(h.ShowtimeCount)
+}
@@ -0,0 +1,52 @@
+package domain
+
+import "time"
+
+type InventoryAnomalyKind string
+
+const (
+	// AnomalyOversoldSeat is a seat sold for a showtime although it isn't a seat of the showtime's hall
+	AnomalyOversoldSeat InventoryAnomalyKind = "oversold_seat"
+	// AnomalyOverCapacity is a showtime with more seats sold than its hall has
+	AnomalyOverCapacity InventoryAnomalyKind = "over_capacity"
+	// AnomalyOrphanLock is a seat lock left behind in Redis, either for a seat which is sold already or
+	// a seat listed as locked whose lock is gone
+	AnomalyOrphanLock InventoryAnomalyKind = "orphan_lock"
+	// AnomalyUnpaidReservation is a confirmed reservation without a completed payment
+	AnomalyUnpaidReservation InventoryAnomalyKind = "unpaid_reservation"
+)
+
+// InventoryAnomaly is an inconsistency between the sold seats, the halls and the seat locks found by the
+// inventory audit. SeatID and ReservationID are 0 when the anomaly isn't about a single seat or reservation.
+type InventoryAnomaly struct {
+	Kind          InventoryAnomalyKind
+	ShowtimeID    int
+	SeatID        int
+	ReservationID int
+	Detail        string
+}
+
+// InventoryAuditReport is the outcome of auditing the inventory of the showtimes starting within [From, To).
+type InventoryAuditReport struct {
+	CheckedAt     time.Time
+	From          time.Time
+	To            time.Time
+	ShowtimeCount int
+	Anomalies     []InventoryAnomaly
+}
+
+// CountByKind returns the number of anomalies of every kind, including the kinds without any.
+func (r *InventoryAuditReport) CountByKind() map[InventoryAnomalyKind]int {
+	counts := map[InventoryAnomalyKind]int{
+		AnomalyOversoldSeat:      0,
+		AnomalyOverCapacity:      0,
+		AnomalyOrphanLock:        0,
+		AnomalyUnpaidReservation: 0,
+	}
+
+	for _, anomaly := range r.Anomalies {
+		counts[anomaly.Kind]++
+	}
+
+	return counts
+}
@@ -0,0 +1,88 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+type LifecycleEventType string
+
+const (
+	EventUserRegistered    LifecycleEventType = "user.registered"
+	EventUserActivated     LifecycleEventType = "user.activated"
+	EventUserFirstPurchase LifecycleEventType = "user.first_purchase"
+	EventUserDeleted       LifecycleEventType = "user.deleted"
+)
+
+const (
+	minLifecycleEventBackoff = time.Minute
+	maxLifecycleEventBackoff = 6 * time.Hour
+)
+
+// LifecycleEvent is a change in the life of a user which marketing follows in the CRM. Events are recorded
+// by the database along with the change and delivered at least once, in the order they occurred.
+type LifecycleEvent struct {
+	ID     int
+	Type   LifecycleEventType
+	UserID int
+	// Payload is the data of the event as recorded, its fields depend on the type
+	Payload    json.RawMessage
+	OccurredAt time.Time
+	Attempts   int
+	LastError  string
+	// NextAttemptAt is unset once the event is delivered or retrying is given up
+	NextAttemptAt *time.Time
+	DeliveredAt   *time.Time
+}
+
+// DeadLettered reports whether delivering the event was given up, it is kept for an operator then.
+func (e *LifecycleEvent) DeadLettered() bool {
+	return e.NextAttemptAt == nil && e.DeliveredAt == nil
+}
+
+// Failed records a failed delivery and schedules the next one with a backoff doubling from a minute up to
+// six hours, as the CRM may be down for a while. The event is dead lettered once maxAttempts is reached.
+func (e *LifecycleEvent) Failed(err error, now time.Time, maxAttempts int) {
+	e.Attempts++
+	e.LastError = err.Error()
+
+	if e.Attempts >= maxAttempts {
+		e.NextAttemptAt = nil
+		return
+	}
+
+	backoff := minLifecycleEventBackoff
+	for i := 1; i < e.Attempts && backoff < maxLifecycleEventBackoff; i++ {
+		backoff *= 2
+	}
+
+	next := now.Add(min(backoff, maxLifecycleEventBackoff))
+	e.NextAttemptAt = &next
+}
+
+// LifecycleEventTarget is where lifecycle events are delivered to, e.g. the CRM.
+type LifecycleEventTarget interface {
+	// Deliver sends the event. Targets must accept an event delivered again, with the same ID.
+	Deliver(ctx context.Context, event LifecycleEvent) error
+}
+
+// LifecycleEventBacklog counts the events which are not delivered.
+type LifecycleEventBacklog struct {
+	Pending      int
+	DeadLettered int
+	// OldestDeadLetteredAt is when the oldest dead lettered event occurred, nil without any
+	OldestDeadLetteredAt *time.Time
+}
+
+type LifecycleEventRepository interface {
+	// Due returns up to limit events whose next attempt is before now, the oldest first.
+	Due(ctx context.Context, now time.Time, limit int) ([]LifecycleEvent, error)
+	MarkDelivered(ctx context.Context, id int, deliveredAt time.Time) error
+	// RecordFailure stores the attempts, last error and next attempt of the event.
+	RecordFailure(ctx context.Context, event LifecycleEvent) error
+	Backlog(ctx context.Context) (*LifecycleEventBacklog, error)
+	// DeleteDeliveredBefore removes the events delivered before the cutoff, their payloads hold personal
+	// data. In dry-run mode the events are only counted.
+	DeleteDeliveredBefore(ctx context.Context, cutoff time.Time, dryRun bool) (int64, error)
+}
@@ -19,10 +19,58 @@ type Movie struct {
 	Director    string
 	CastMembers []string
 	Rating      pgtype.Numeric
+	// ContentWarnings lists the sensitive themes of the movie, e.g. violence or flashing_lights.
+	ContentWarnings   []string
+	SubtitleLanguages []string
+	AudioDescription  bool
 }
 
+// SitemapMovie is a movie listed in the sitemap, LastModified is when its catalog entry was created.
+type SitemapMovie struct {
+	ID           int
+	LastModified time.Time
+}
+
+// MovieDuplicate is a movie of the catalog which may be the same as a movie being added. Similarity is
+// the trigram similarity of the normalized titles, between 0 and 1.
+type MovieDuplicate struct {
+	ID          int
+	Title       string
+	ReleaseDate time.Time
+	Similarity  float64
+	Archived    bool
+}
+
+// MovieDuplicateSimilarity is the similarity from which movies released the same year are considered
+// duplicates.
+const MovieDuplicateSimilarity = 0.5
+
+// AccessibilityFeature narrows showtime listings down to the screenings a guest can follow.
+type AccessibilityFeature string
+
+const (
+	AccessibilityOpenCaptions     AccessibilityFeature = "OPEN_CAPTIONS"
+	AccessibilityAudioDescription AccessibilityFeature = "AUDIO_DESCRIPTION"
+	AccessibilitySubtitles        AccessibilityFeature = "SUBTITLES"
+)
+
+// MovieRepository reads the catalog, archived movies are left out of it.
 type MovieRepository interface {
 	GetAll(ctx context.Context, pagination Pagination) ([]*Movie, *Metadata, error)
 	GetById(ctx context.Context, id int) (*Movie, error)
 	ExistsById(ctx context.Context, id int) (bool, error)
+	// GetSitemapMovies returns at most limit movies, the most recently added first.
+	GetSitemapMovies(ctx context.Context, limit int) ([]SitemapMovie, error)
+	// Archive hides the movie from the catalog, its showtimes and reservations are kept. Archiving an
+	// archived movie does nothing. It returns ErrRecordNotFound if the movie doesn't exist.
+	Archive(ctx context.Context, id int) error
+	// Restore brings an archived movie back to the catalog. It returns ErrRecordNotFound if the movie
+	// doesn't exist.
+	Restore(ctx context.Context, id int) error
+	// Create adds the movie to the catalog and sets its ID.
+	Create(ctx context.Context, movie *Movie) error
+	// FindDuplicates returns the movies released in the year whose title is similar to the given one, archived
+	// movies included. Titles are compared ignoring case, punctuation and a leading article. The most
+	// similar movies come first.
+	FindDuplicates(ctx context.Context, title string, releaseYear int) ([]MovieDuplicate, error)
 }
@@ -0,0 +1,26 @@
+package domain
+
+import "time"
+
+// ShowtimeOccupancy is a point in time view of the seats of a showtime intended for staff.
+type ShowtimeOccupancy struct {
+	ShowtimeID    int
+	TotalSeats    int
+	ReservedSeats int
+	LockedSeats   int
+	// HeldSeats are the seats of the active block holds, they're blocked for an event
+	HeldSeats      int
+	AvailableSeats int
+	Locks          []SeatLock
+	BlockHolds     []BlockHold
+	GeneratedAt    time.Time
+}
+
+// SeatLock describes a temporary hold on a seat. HolderRef is an opaque reference which is the same for
+// all locks held by one session, UserID is nil when the holder is a guest.
+type SeatLock struct {
+	SeatID    int
+	HolderRef string
+	UserID    *int
+	ExpiresAt time.Time
+}
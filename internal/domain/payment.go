@@ -14,6 +14,22 @@ const (
 	PaymentStatusCanceled  PaymentStatus = "canceled"
 	PaymentStatusCompleted PaymentStatus = "completed"
 	PaymentStatusRefunded  PaymentStatus = "refunded"
+	// PaymentStatusComp is the zero amount payment of complimentary tickets issued by staff
+	PaymentStatusComp PaymentStatus = "comp"
+)
+
+// SalesChannel is where a sale was made, for reporting.
+type SalesChannel string
+
+const (
+	SalesChannelWeb    SalesChannel = "web"
+	SalesChannelMobile SalesChannel = "mobile"
+	// SalesChannelKiosk is a sale made by a kiosk account in the theater lobby
+	SalesChannelKiosk SalesChannel = "kiosk"
+	// SalesChannelBoxOffice is a sale made by theater staff on behalf of a customer, e.g. over the phone
+	SalesChannelBoxOffice SalesChannel = "box-office"
+	// SalesChannelPartner is a sale made by a ticket partner authenticated by its API key
+	SalesChannelPartner SalesChannel = "partner"
 )
 
 type Payment struct {
@@ -27,10 +43,107 @@ type Payment struct {
 	PaymentDate       *time.Time
 	CreatedAt         time.Time
 	UpdatedAt         *time.Time
+	// Payout is nil when the platform keeps the whole amount
+	Payout *PayoutSplit
+	// ReservationID is set when the payment is made for an existing reservation, the reservation
+	// refers to the payment it was bought with instead
+	ReservationID *int
+	// SalesChannel defaults to SalesChannelWeb when it's not set
+	SalesChannel SalesChannel
+}
+
+// PayoutSplit is how a payment is shared between the platform and the Stripe Connect account of the
+// theater's operator. The platform keeps the application fee and transfers the rest to the account.
+type PayoutSplit struct {
+	AccountID      string
+	ApplicationFee decimal.Decimal
+	TransferAmount decimal.Decimal
+}
+
+// NewPayoutSplit takes feePercent percent of the amount, rounded to cents, as the application fee.
+func NewPayoutSplit(accountID string, amount, feePercent decimal.Decimal) PayoutSplit {
+	fee := amount.Mul(feePercent).Div(decimal.NewFromInt(100)).Round(2)
+
+	return PayoutSplit{
+		AccountID:      accountID,
+		ApplicationFee: fee,
+		TransferAmount: amount.Sub(fee),
+	}
+}
+
+// ReservationPayment is the payment of a reservation as shown to the owner of the reservation.
+type ReservationPayment struct {
+	Amount          decimal.Decimal
+	Currency        string
+	Status          PaymentStatus
+	PaymentIntentID string
+	// RefundedAmount is the total of the succeeded refunds
+	RefundedAmount decimal.Decimal
+	RefundPending  bool
+	// Receipt is read from the payment provider, it's nil when the provider couldn't be reached
+	Receipt *PaymentReceipt
+}
+
+type RefundState string
+
+const (
+	RefundStateNone    RefundState = "none"
+	RefundStatePending RefundState = "pending"
+	RefundStatePartial RefundState = "partial"
+	RefundStateFull    RefundState = "full"
+)
+
+// RefundState summarizes the refunds of the payment. A fully refunded payment is reported as such even
+// while another refund is pending.
+func (p ReservationPayment) RefundState() RefundState {
+	switch {
+	case p.RefundedAmount.GreaterThanOrEqual(p.Amount) && p.RefundedAmount.IsPositive():
+		return RefundStateFull
+	case p.RefundPending:
+		return RefundStatePending
+	case p.RefundedAmount.IsPositive():
+		return RefundStatePartial
+	default:
+		return RefundStateNone
+	}
+}
+
+// PaymentReceipt is what the payment provider knows about how a payment was made.
+type PaymentReceipt struct {
+	CardBrand  string `json:"cardBrand"`
+	CardLast4  string `json:"cardLast4"`
+	ReceiptURL string `json:"receiptUrl"`
 }
 
 type PaymentRepository interface {
 	Create(ctx context.Context, payment *Payment) error
 	GetById(ctx context.Context, id int) (*Payment, error)
 	UpdateStatus(ctx context.Context, checkoutSessionID string, status PaymentStatus, errMsg string) error
+	// MarkUnfulfilled completes a pending payment whose reservation couldn't be created and keeps the
+	// reason. The refund of the payment is recorded through its payment intent like any other.
+	MarkUnfulfilled(ctx context.Context, paymentID int, checkoutSessionID, paymentIntentID, errMsg string) error
+	// MarkCompleted completes a pending payment which was made for an existing reservation.
+	MarkCompleted(ctx context.Context, paymentID int, checkoutSessionID, paymentIntentID string) error
+	// MarkCanceled cancels a pending payment whose checkout session can't be paid anymore. Returns
+	// ErrRecordNotFound if there is no such pending payment.
+	MarkCanceled(ctx context.Context, paymentID int, checkoutSessionID, errMsg string) error
+	AnonymizeCreatedBefore(ctx context.Context, cutoff time.Time, dryRun bool) (int64, error)
+	// RecordRefund stores the latest state of a Stripe refund for the payment with the given payment
+	// intent. Updates that would move the refund to a state it cannot reach are ignored and reported
+	// as not applied. Once succeeded refunds cover the full amount, the payment is marked as refunded.
+	RecordRefund(ctx context.Context, paymentIntentID string, refund *Refund) (bool, error)
+	// DueRefunds returns up to limit pending refunds whose next attempt is before now, the oldest first.
+	DueRefunds(ctx context.Context, now time.Time, limit int) ([]PendingRefund, error)
+	MarkRefundSent(ctx context.Context, id int, stripeRefundID string, sentAt time.Time) error
+	// RecordRefundFailure stores the attempts, last error and next attempt of the pending refund.
+	RecordRefundFailure(ctx context.Context, refund PendingRefund) error
+	GetHistoryByUserId(ctx context.Context, userId int, pagination Pagination) ([]PaymentHistoryEntry, *Metadata, error)
+	// GetLedgerEntries returns the completed payments paid through Stripe and the succeeded refunds
+	// recorded within [from, to).
+	GetLedgerEntries(ctx context.Context, from, to time.Time) ([]LedgerEntry, error)
+	// SaveReconciliationReport stores the report of its date, replacing an earlier one.
+	SaveReconciliationReport(ctx context.Context, report *ReconciliationReport) error
+	// GetReconciliationReport returns the report of the date, ErrRecordNotFound if the date isn't
+	// reconciled yet.
+	GetReconciliationReport(ctx context.Context, date time.Time) (*ReconciliationReport, error)
 }
@@ -1,7 +1,41 @@
 package domain
 
-import "github.com/stripe/stripe-go/v82"
+import (
+	"context"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stripe/stripe-go/v82"
+)
+
+// Metadata keys of the checkout sessions. The webhook handler reads them back when the payment completes.
+const (
+	CheckoutMetadataCartID    = "cart_id"
+	CheckoutMetadataSessionID = "session_id"
+	CheckoutMetadataUserID    = "user_id"
+	CheckoutMetadataPaymentID = "payment_id"
+	CheckoutMetadataRequestID = "request_id"
+	// CheckoutMetadataReservationID is set when the checkout pays the price difference of moving the
+	// reservation to another showtime
+	CheckoutMetadataReservationID = "reservation_id"
+)
 
 type PaymentProvider interface {
-	CreateCheckoutSession(sessionId string, user *User, cart Cart, payment Payment) (*stripe.CheckoutSession, error)
+	// CreateCheckoutSession creates a checkout session for the cart which can't be paid after expiresAt.
+	CreateCheckoutSession(ctx context.Context, sessionId string, user *User, cart Cart, payment Payment, expiresAt time.Time) (*stripe.CheckoutSession, error)
+	// ExpireCheckoutSession makes an open checkout session unpayable. It returns ErrCartAlreadyPaid if the
+	// session is already completed, an expired session is left as it is.
+	ExpireCheckoutSession(ctx context.Context, checkoutSessionID string) error
+	// RefundPayment refunds the full amount of the payment intent. Requests with the same idempotency
+	// key create a single refund.
+	RefundPayment(ctx context.Context, paymentIntentID, idempotencyKey string) (*stripe.Refund, error)
+	// RefundPaymentAmount refunds the given amount of the payment intent.
+	RefundPaymentAmount(ctx context.Context, paymentIntentID string, amount decimal.Decimal, idempotencyKey string) (*stripe.Refund, error)
+	// CreateShowtimeChangeCheckoutSession creates a checkout session for the price difference of the change.
+	CreateShowtimeChangeCheckoutSession(ctx context.Context, user *User, change ShowtimeChange, payment Payment, expiresAt time.Time) (*stripe.CheckoutSession, error)
+	// GetPaymentReceipt returns the card and the receipt of the latest charge of the payment intent.
+	GetPaymentReceipt(ctx context.Context, paymentIntentID string) (*PaymentReceipt, error)
+	// ListBalanceTransactions returns the charges, refunds and payouts of the Stripe balance created within
+	// [from, to).
+	ListBalanceTransactions(ctx context.Context, from, to time.Time) ([]BalanceTransaction, error)
 }
@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type BasePriceAdjustmentKind string
+
+const (
+	AdjustByPercent BasePriceAdjustmentKind = "percent"
+	AdjustByAmount  BasePriceAdjustmentKind = "fixed"
+)
+
+// MaxBasePrice is the highest base price a showtime can have, showtimes.base_price is numeric(6,2)
+var MaxBasePrice = decimal.RequireFromString("9999.99")
+
+// BasePriceAdjustment changes the base prices of the upcoming showtimes starting on the days From to To,
+// both inclusive and taken in the time zone of the theater of the showtime. TheaterID and Format narrow
+// the showtimes down when set.
+type BasePriceAdjustment struct {
+	Kind BasePriceAdjustmentKind
+	// Value is the percentage of the base price or the amount added to it, negative to lower it
+	Value     decimal.Decimal
+	From      time.Time
+	To        time.Time
+	TheaterID *int
+	Format    *ScreeningFormat
+}
+
+// Apply returns the adjusted price rounded to cents. It returns ErrInvalidPriceAdjustment if the price would
+// be negative or higher than MaxBasePrice.
+func (a BasePriceAdjustment) Apply(price decimal.Decimal) (decimal.Decimal, error) {
+	adjusted := price.Add(a.Value)
+	if a.Kind == AdjustByPercent {
+		adjusted = price.Add(price.Mul(a.Value).Div(decimal.NewFromInt(100)))
+	}
+
+	adjusted = adjusted.Round(2)
+	if adjusted.IsNegative() || adjusted.GreaterThan(MaxBasePrice) {
+		return decimal.Decimal{}, ErrInvalidPriceAdjustment
+	}
+
+	return adjusted, nil
+}
+
+// ShowtimePriceChange is the change of the base price of a showtime by a BasePriceAdjustment.
+type ShowtimePriceChange struct {
+	ShowtimeID     int
+	TheaterID      int
+	StartTime      time.Time
+	Format         ScreeningFormat
+	OldPrice       decimal.Decimal
+	NewPrice       decimal.Decimal
+	AvailableSeats int
+}
+
+// RevenueImpact estimates how the revenue of the showtime changes. Sold tickets keep their prices, so only
+// the available seats count, as if they all sell at the new price.
+func (c ShowtimePriceChange) RevenueImpact() decimal.Decimal {
+	return c.NewPrice.Sub(c.OldPrice).Mul(decimal.NewFromInt(int64(c.AvailableSeats)))
+}
@@ -0,0 +1,192 @@
+package domain
+
+import (
+	"slices"
+
+	"github.com/shopspring/decimal"
+)
+
+const DefaultCurrency = "USD"
+
+// PriceBreakdown itemizes how the total price of a cart is composed. Seat lines map one-to-one
+// to the line items charged by the payment provider, so the breakdown always matches the final charge.
+type PriceBreakdown struct {
+	Currency  string
+	Seats     []SeatPrice
+	BaseTotal decimal.Decimal
+	// SurchargesTotal sums the format surcharge, e.g. of a 3D or IMAX showtime, of every seat
+	SurchargesTotal decimal.Decimal
+	ExtrasTotal     decimal.Decimal
+	// AddOns are the optional extras chosen at checkout, e.g. the flexible ticket, included in the subtotal
+	AddOns    []PriceAdjustment
+	Subtotal  decimal.Decimal
+	Discounts []PriceAdjustment
+	Taxes     []PriceAdjustment
+	Total     decimal.Decimal
+}
+
+type SeatPrice struct {
+	SeatID          int
+	Row             int
+	Col             int
+	SeatType        string
+	BasePrice       decimal.Decimal
+	FormatSurcharge decimal.Decimal
+	ExtraPrice      decimal.Decimal
+	Price           decimal.Decimal
+	PriceVersionID  *int
+}
+
+// PriceAdjustment is a named amount applied on top of the subtotal. Discounts carry negative amounts.
+type PriceAdjustment struct {
+	Code        string
+	Description string
+	Amount      decimal.Decimal
+}
+
+func NewPriceBreakdown(basePrice, formatSurcharge decimal.Decimal, cartSeats []CartSeat) PriceBreakdown {
+	seats := make([]SeatPrice, len(cartSeats))
+
+	for i, seat := range cartSeats {
+		seats[i] = SeatPrice{
+			SeatID:          seat.Id,
+			Row:             seat.Row,
+			Col:             seat.Col,
+			SeatType:        seat.SeatType,
+			BasePrice:       basePrice,
+			FormatSurcharge: formatSurcharge,
+			ExtraPrice:      seat.ExtraPrice,
+			Price:           basePrice.Add(formatSurcharge).Add(seat.ExtraPrice),
+			PriceVersionID:  seat.PriceVersionID,
+		}
+	}
+
+	return NewSeatPriceBreakdown(seats)
+}
+
+// NewSeatPriceBreakdown totals seats which are already priced, e.g. the prices a cart was created with.
+func NewSeatPriceBreakdown(seats []SeatPrice) PriceBreakdown {
+	breakdown := PriceBreakdown{
+		Currency:        DefaultCurrency,
+		Seats:           slices.Clone(seats),
+		BaseTotal:       decimal.Zero,
+		SurchargesTotal: decimal.Zero,
+		ExtrasTotal:     decimal.Zero,
+		AddOns:          []PriceAdjustment{},
+		Discounts:       []PriceAdjustment{},
+		Taxes:           []PriceAdjustment{},
+	}
+
+	for _, seat := range seats {
+		breakdown.BaseTotal = breakdown.BaseTotal.Add(seat.BasePrice)
+		breakdown.SurchargesTotal = breakdown.SurchargesTotal.Add(seat.FormatSurcharge)
+		breakdown.ExtrasTotal = breakdown.ExtrasTotal.Add(seat.ExtraPrice)
+	}
+
+	breakdown.Subtotal = breakdown.BaseTotal.Add(breakdown.SurchargesTotal).Add(breakdown.ExtrasTotal)
+
+	// ticket prices are tax inclusive and no promotions exist yet, so the subtotal is what gets charged
+	breakdown.Total = breakdown.Subtotal
+
+	return breakdown
+}
+
+// AddAddOn adds an optional extra to the charged amount. Like the seats, every add-on is charged as a line
+// item of its own.
+func (b *PriceBreakdown) AddAddOn(addOn PriceAdjustment) {
+	b.AddOns = append(b.AddOns, addOn)
+	b.Subtotal = b.Subtotal.Add(addOn.Amount)
+	b.Total = b.Total.Add(addOn.Amount)
+}
+
+// ReservationSeats snapshots the price every seat is sold for. Discounts and taxes of the whole breakdown
+// are shared among the seats in proportion to their prices, add-ons are not part of any seat.
+func (b PriceBreakdown) ReservationSeats(showtimeID int) []ReservationSeat {
+	discounts := allocateBySeatPrice(sumAdjustments(b.Discounts), b.Seats)
+	taxes := allocateBySeatPrice(sumAdjustments(b.Taxes), b.Seats)
+
+	seats := make([]ReservationSeat, len(b.Seats))
+
+	for i, seat := range b.Seats {
+		seats[i] = ReservationSeat{
+			ShowtimeID:      showtimeID,
+			SeatID:          seat.SeatID,
+			ExtraPrice:      seat.ExtraPrice,
+			PriceVersionID:  seat.PriceVersionID,
+			BasePrice:       seat.BasePrice,
+			FormatSurcharge: seat.FormatSurcharge,
+			Discount:        discounts[i],
+			Tax:             taxes[i],
+			// ticket prices are tax inclusive, the tax is part of the price already
+			Price: seat.Price.Add(discounts[i]),
+		}
+	}
+
+	return seats
+}
+
+// CompReservationSeats snapshots the seats of complimentary tickets. The seats keep their list prices, the
+// whole price is given as a discount and no tax is due on them.
+func (b PriceBreakdown) CompReservationSeats(showtimeID int) []ReservationSeat {
+	seats := make([]ReservationSeat, len(b.Seats))
+
+	for i, seat := range b.Seats {
+		seats[i] = ReservationSeat{
+			ShowtimeID:      showtimeID,
+			SeatID:          seat.SeatID,
+			ExtraPrice:      seat.ExtraPrice,
+			PriceVersionID:  seat.PriceVersionID,
+			BasePrice:       seat.BasePrice,
+			FormatSurcharge: seat.FormatSurcharge,
+			Discount:        seat.Price.Neg(),
+			Tax:             decimal.Zero,
+			Price:           decimal.Zero,
+		}
+	}
+
+	return seats
+}
+
+func sumAdjustments(adjustments []PriceAdjustment) decimal.Decimal {
+	sum := decimal.Zero
+	for _, adjustment := range adjustments {
+		sum = sum.Add(adjustment.Amount)
+	}
+
+	return sum
+}
+
+// allocateBySeatPrice splits the amount among the seats in proportion to their prices, rounded to cents.
+// The last seat takes the rounding difference, so the shares always add up to the amount.
+func allocateBySeatPrice(amount decimal.Decimal, seats []SeatPrice) []decimal.Decimal {
+	shares := make([]decimal.Decimal, len(seats))
+	if len(seats) == 0 {
+		return shares
+	}
+
+	total := decimal.Zero
+	for _, seat := range seats {
+		total = total.Add(seat.Price)
+	}
+
+	allocated := decimal.Zero
+
+	for i, seat := range seats[:len(seats)-1] {
+		share := decimal.Zero
+
+		switch {
+		case amount.IsZero():
+		case total.IsZero():
+			share = amount.Div(decimal.NewFromInt(int64(len(seats)))).Round(2)
+		default:
+			share = amount.Mul(seat.Price).Div(total).Round(2)
+		}
+
+		shares[i] = share
+		allocated = allocated.Add(share)
+	}
+
+	shares[len(seats)-1] = amount.Sub(allocated)
+
+	return shares
+}
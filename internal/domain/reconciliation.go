@@ -0,0 +1,195 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type BalanceTransactionType string
+
+const (
+	BalanceTransactionCharge BalanceTransactionType = "charge"
+	BalanceTransactionRefund BalanceTransactionType = "refund"
+	BalanceTransactionPayout BalanceTransactionType = "payout"
+)
+
+// BalanceTransaction is a movement of the Stripe balance. Amount is the gross amount, negative for the
+// funds leaving the balance like refunds and payouts. SourceID is the id of the charge, refund or payout.
+type BalanceTransaction struct {
+	ID              string
+	Type            BalanceTransactionType
+	SourceID        string
+	PaymentIntentID string
+	Amount          decimal.Decimal
+	Fee             decimal.Decimal
+	Currency        string
+	CreatedAt       time.Time
+}
+
+// LedgerEntry is a completed payment or a succeeded refund as recorded internally, with the Stripe ids it
+// is matched by. StripeRefundID is empty for payments.
+type LedgerEntry struct {
+	PaymentID       int
+	PaymentIntentID string
+	StripeRefundID  string
+	Amount          decimal.Decimal
+	Currency        string
+	RecordedAt      time.Time
+}
+
+type ReconciliationMismatchKind string
+
+const (
+	// MismatchMissingInStripe is a payment or refund recorded internally without a Stripe transaction
+	MismatchMissingInStripe ReconciliationMismatchKind = "missing_in_stripe"
+	// MismatchMissingInternally is a Stripe transaction which isn't recorded internally, usually because
+	// its webhook was never processed
+	MismatchMissingInternally ReconciliationMismatchKind = "missing_internally"
+	// MismatchAmount is a payment or refund whose amount differs from its Stripe transaction
+	MismatchAmount ReconciliationMismatchKind = "amount_mismatch"
+)
+
+// ReconciliationMismatch is a payment or refund which doesn't agree with the Stripe balance. PaymentID is 0
+// when the transaction isn't recorded internally.
+type ReconciliationMismatch struct {
+	Kind            ReconciliationMismatchKind
+	Type            BalanceTransactionType
+	PaymentID       int
+	PaymentIntentID string
+	StripeRefundID  string
+	InternalAmount  *decimal.Decimal
+	StripeAmount    *decimal.Decimal
+}
+
+// ReconciliationTotals are the sums of a day. Refunds, fees and payouts are positive amounts.
+type ReconciliationTotals struct {
+	InternalPayments decimal.Decimal
+	InternalRefunds  decimal.Decimal
+	StripeCharges    decimal.Decimal
+	StripeRefunds    decimal.Decimal
+	StripeFees       decimal.Decimal
+	StripePayouts    decimal.Decimal
+}
+
+// ReconciliationReport is the outcome of comparing the payments and refunds of a UTC day with the Stripe
+// balance transactions of the same day.
+type ReconciliationReport struct {
+	Date       time.Time
+	CheckedAt  time.Time
+	Totals     ReconciliationTotals
+	Mismatches []ReconciliationMismatch
+}
+
+// Reconcile matches the ledger entries with the balance transactions. Entries and transactions of the
+// neighbouring days are only used for matching, so that a payment recorded shortly after midnight is not
+// reported as missing from the day its charge was made on. Only the ones within [from, to) are counted.
+func Reconcile(from, to time.Time, entries []LedgerEntry, transactions []BalanceTransaction) ([]ReconciliationMismatch, ReconciliationTotals) {
+	inDay := func(t time.Time) bool {
+		return !t.Before(from) && t.Before(to)
+	}
+
+	var totals ReconciliationTotals
+
+	charges := make(map[string]BalanceTransaction)
+	refunds := make(map[string]BalanceTransaction)
+
+	for _, txn := range transactions {
+		switch txn.Type {
+		case BalanceTransactionCharge:
+			charges[txn.PaymentIntentID] = txn
+		case BalanceTransactionRefund:
+			refunds[txn.SourceID] = txn
+		}
+
+		if !inDay(txn.CreatedAt) {
+			continue
+		}
+
+		switch txn.Type {
+		case BalanceTransactionCharge:
+			totals.StripeCharges = totals.StripeCharges.Add(txn.Amount)
+		case BalanceTransactionRefund:
+			totals.StripeRefunds = totals.StripeRefunds.Sub(txn.Amount)
+		case BalanceTransactionPayout:
+			totals.StripePayouts = totals.StripePayouts.Sub(txn.Amount)
+		}
+
+		totals.StripeFees = totals.StripeFees.Add(txn.Fee)
+	}
+
+	var mismatches []ReconciliationMismatch
+
+	recorded := make(map[string]bool, len(entries))
+
+	for _, entry := range entries {
+		txnType, key, txns := BalanceTransactionCharge, entry.PaymentIntentID, charges
+		if entry.StripeRefundID != "" {
+			txnType, key, txns = BalanceTransactionRefund, entry.StripeRefundID, refunds
+		}
+
+		recorded[string(txnType)+":"+key] = true
+
+		txn, found := txns[key]
+
+		// a pair is reported on the day of its Stripe transaction, an entry without one on its own day
+		if found && !inDay(txn.CreatedAt) || !found && !inDay(entry.RecordedAt) {
+			continue
+		}
+
+		if txnType == BalanceTransactionCharge {
+			totals.InternalPayments = totals.InternalPayments.Add(entry.Amount)
+		} else {
+			totals.InternalRefunds = totals.InternalRefunds.Add(entry.Amount)
+		}
+
+		mismatch := ReconciliationMismatch{
+			Type:            txnType,
+			PaymentID:       entry.PaymentID,
+			PaymentIntentID: entry.PaymentIntentID,
+			StripeRefundID:  entry.StripeRefundID,
+			InternalAmount:  &entry.Amount,
+		}
+
+		if !found {
+			mismatch.Kind = MismatchMissingInStripe
+			mismatches = append(mismatches, mismatch)
+			continue
+		}
+
+		stripeAmount := txn.Amount.Abs()
+		if !stripeAmount.Equal(entry.Amount) {
+			mismatch.Kind = MismatchAmount
+			mismatch.StripeAmount = &stripeAmount
+			mismatches = append(mismatches, mismatch)
+		}
+	}
+
+	for _, txn := range transactions {
+		key := txn.PaymentIntentID
+		if txn.Type == BalanceTransactionRefund {
+			key = txn.SourceID
+		}
+
+		if txn.Type == BalanceTransactionPayout || !inDay(txn.CreatedAt) || recorded[string(txn.Type)+":"+key] {
+			continue
+		}
+
+		stripeAmount := txn.Amount.Abs()
+
+		mismatch := ReconciliationMismatch{
+			Kind:            MismatchMissingInternally,
+			Type:            txn.Type,
+			PaymentIntentID: txn.PaymentIntentID,
+			StripeAmount:    &stripeAmount,
+		}
+
+		if txn.Type == BalanceTransactionRefund {
+			mismatch.StripeRefundID = txn.SourceID
+		}
+
+		mismatches = append(mismatches, mismatch)
+	}
+
+	return mismatches, totals
+}
@@ -0,0 +1,109 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type RefundStatus string
+
+const (
+	RefundStatusPending        RefundStatus = "pending"
+	RefundStatusRequiresAction RefundStatus = "requires_action"
+	RefundStatusSucceeded      RefundStatus = "succeeded"
+	RefundStatusFailed         RefundStatus = "failed"
+	RefundStatusCanceled       RefundStatus = "canceled"
+)
+
+var refundTransitions = map[RefundStatus][]RefundStatus{
+	RefundStatusPending:        {RefundStatusRequiresAction, RefundStatusSucceeded, RefundStatusFailed, RefundStatusCanceled},
+	RefundStatusRequiresAction: {RefundStatusPending, RefundStatusSucceeded, RefundStatusFailed, RefundStatusCanceled},
+	// Stripe may report a succeeded refund as failed later on, e.g. when the card was closed
+	RefundStatusSucceeded: {RefundStatusFailed},
+}
+
+// CanTransitionTo reports whether a refund may move from s to next. Stripe does not guarantee the
+// delivery order of webhooks, so stale events must not roll a settled refund back.
+func (s RefundStatus) CanTransitionTo(next RefundStatus) bool {
+	for _, allowed := range refundTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+
+	return false
+}
+
+type Refund struct {
+	ID             int
+	PaymentID      int
+	StripeRefundID string
+	Amount         decimal.Decimal
+	Currency       string
+	Status         RefundStatus
+	FailureReason  *string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+const (
+	minPendingRefundBackoff = time.Minute
+	maxPendingRefundBackoff = time.Hour
+)
+
+// PendingRefund is a refund owed to one of the payments of a reservation, e.g. the difference of a move to
+// cheaper seats. It's queued along with the change of the reservation and stays due until Stripe accepts
+// it, so a failed refund is retried instead of being lost.
+type PendingRefund struct {
+	ID              int
+	ReservationID   int
+	PaymentID       int
+	PaymentIntentID string
+	Amount          decimal.Decimal
+	// Reason tells the refunds apart in the logs, e.g. showtime-change
+	Reason    string
+	Attempts  int
+	LastError string
+	// NextAttemptAt is unset once the refund is sent or retrying is given up
+	NextAttemptAt *time.Time
+}
+
+// IdempotencyKey is the same for every attempt, so a refund Stripe accepted but whose response was lost
+// isn't made twice.
+func (r *PendingRefund) IdempotencyKey() string {
+	return fmt.Sprintf("pending-refund-%d", r.ID)
+}
+
+// Stuck reports whether retrying the refund was given up, it must be settled by hand then.
+func (r *PendingRefund) Stuck() bool {
+	return r.NextAttemptAt == nil
+}
+
+// Failed records a failed attempt and schedules the next one with a backoff doubling from a minute up to
+// an hour. Retrying is given up once maxAttempts is reached.
+func (r *PendingRefund) Failed(err error, now time.Time, maxAttempts int) {
+	r.Attempts++
+	r.LastError = err.Error()
+
+	if r.Attempts >= maxAttempts {
+		r.NextAttemptAt = nil
+		return
+	}
+
+	backoff := minPendingRefundBackoff
+	for i := 1; i < r.Attempts && backoff < maxPendingRefundBackoff; i++ {
+		backoff *= 2
+	}
+
+	next := now.Add(min(backoff, maxPendingRefundBackoff))
+	r.NextAttemptAt = &next
+}
+
+// PaymentHistoryEntry is a payment of a user along with the reservation it paid for and its refunds.
+type PaymentHistoryEntry struct {
+	Payment
+	ReservationID *int
+	Refunds       []Refund
+}
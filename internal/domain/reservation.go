@@ -7,21 +7,70 @@ import (
 	"github.com/shopspring/decimal"
 )
 
+type ReservationStatus string
+
+const (
+	ReservationConfirmed             ReservationStatus = "confirmed"
+	ReservationPendingPaymentAtVenue ReservationStatus = "pending-payment-at-venue"
+	ReservationCancelled             ReservationStatus = "cancelled"
+)
+
+// MaxReservationNoteLength is the maximum number of characters of a reservation note.
+const MaxReservationNoteLength = 500
+
+const (
+	// AddOnFlexibleTicket is the code of the flexible ticket in the price breakdown
+	AddOnFlexibleTicket = "flexible_ticket"
+	// FlexibleTicketChangeCutoff is how long before the showtime a flexible ticket can still be moved
+	FlexibleTicketChangeCutoff = time.Hour
+	// DefaultRescheduleCutoff is how long before the showtime other tickets can still be moved
+	DefaultRescheduleCutoff = 24 * time.Hour
+)
+
+// SpecialRequest is an assistance the theater staff prepares for when the guest checks in.
+type SpecialRequest string
+
+const (
+	SpecialRequestWheelchairAssistance SpecialRequest = "wheelchair_assistance"
+	SpecialRequestChildBoosterSeat     SpecialRequest = "child_booster_seat"
+	SpecialRequestHearingAssistance    SpecialRequest = "hearing_assistance"
+	SpecialRequestVisualAssistance     SpecialRequest = "visual_assistance"
+)
+
 type Reservation struct {
 	ID                int
 	UserID            int
 	ShowtimeID        int
+	Status            ReservationStatus
 	CheckoutSessionID string
+	PaymentIntentID   string
 	PaymentID         int
+	Note              string
+	SpecialRequests   []SpecialRequest
+	FlexibleTicket    bool
 	ReservationSeats  []ReservationSeat
 	CreatedAt         time.Time
 	UpdatedAt         time.Time
+	// UserID is 0 for reservations booked for a guest, GuestEmail is set instead
+	GuestEmail string
+	// SalesChannel defaults to SalesChannelWeb when it's not set
+	SalesChannel SalesChannel
 }
 
 type ReservationSeat struct {
-	ReservationID int
-	ShowtimeID    int
-	SeatID        int
+	ReservationID  int
+	ShowtimeID     int
+	SeatID         int
+	ExtraPrice     decimal.Decimal
+	PriceVersionID *int
+	// BasePrice, FormatSurcharge, Discount, Tax and Price snapshot what the seat was sold for, see
+	// PriceBreakdown.ReservationSeats. Discount is negative like the discounts of the breakdown and Tax is
+	// included in Price. They are zero for reservations made before the snapshot was recorded.
+	BasePrice       decimal.Decimal
+	FormatSurcharge decimal.Decimal
+	Discount        decimal.Decimal
+	Tax             decimal.Decimal
+	Price           decimal.Decimal
 }
 
 type ReservationSummary struct {
@@ -32,25 +81,264 @@ type ReservationSummary struct {
 	TheaterName    string
 	HallName       string
 	CreatedAt      time.Time
+	// TheaterTimeZone is the time zone the showtime is shown in
+	TheaterTimeZone string
 }
 
 type ReservationDetail struct {
 	ReservationSummary
+	MovieDuration   int
+	TheaterAddress  string
+	TheaterDistrict string
+	TheaterCity     string
+	TheaterLocation GeoPoint
+	// TicketsRevokedAt is set when the tickets must no longer be honored, e.g. after a chargeback
+	TicketsRevokedAt *time.Time
+	// TicketVersion is bumped whenever the tickets issued so far must be replaced
+	TicketVersion    int
+	ShowtimeID       int
+	Note             string
+	SpecialRequests  []SpecialRequest
+	FlexibleTicket   bool
 	Seats            []ReservationDetailSeat
 	TheaterAmenities []Amenity
 	HallAmenities    []Amenity
 	TotalPrice       decimal.Decimal
+	Payment          ReservationPayment
 }
 
 type ReservationDetailSeat struct {
 	Row  int
 	Col  int
 	Type string
+	// Price is what the seat was sold for, nil for reservations made before seat prices were recorded
+	Price *decimal.Decimal
+}
+
+// CheckInEntry is a reservation of a showtime as listed to the staff checking guests in.
+type CheckInEntry struct {
+	ReservationID    int
+	Status           ReservationStatus
+	GuestFirstName   string
+	GuestLastName    string
+	Seats            []ReservationDetailSeat
+	Note             string
+	SpecialRequests  []SpecialRequest
+	TicketsRevokedAt *time.Time
+}
+
+// TicketCheckIn is a reservation as found by the code of its ticket at the door, with the check-in policy
+// of the theater of its showtime.
+type TicketCheckIn struct {
+	ReservationID    int
+	Status           ReservationStatus
+	TicketVersion    int
+	TicketsRevokedAt *time.Time
+	ShowtimeID       int
+	StartTime        time.Time
+	PreShowMinutes   int
+	TheaterID        int
+	Policy           CheckInPolicy
+	// CheckedInAt is nil until the guests of the reservation are let in
+	CheckedInAt *time.Time
+}
+
+// CheckIn records the guests of a reservation being let in. MinutesLate is zero for guests who arrived
+// within the grace period of the theater.
+type CheckIn struct {
+	ReservationID int
+	ShowtimeID    int
+	CheckedInAt   time.Time
+	MinutesLate   int
+	CheckedInBy   int
+}
+
+// ShowtimeAttendance counts how the reservations of a showtime were claimed. No-shows whose seats were
+// released are counted along with the other reservations, although they are cancelled.
+type ShowtimeAttendance struct {
+	ShowtimeID   int
+	StartTime    time.Time
+	MovieTitle   string
+	HallName     string
+	Reservations int
+	CheckedIn    int
+	LateArrivals int
+	NoShows      int
+	// Released is the no-shows whose seats were given back for door sales
+	Released int
+}
+
+// NoShowRate returns the share of the reservations whose guests didn't show up, zero without reservations.
+func (a ShowtimeAttendance) NoShowRate() float64 {
+	if a.Reservations == 0 {
+		return 0
+	}
+
+	return float64(a.NoShows) / float64(a.Reservations)
+}
+
+// ReservationLookup is the reservations of a showtime made by the user with the email address or booked for
+// it as a guest, the cancelled ones included.
+type ReservationLookup struct {
+	Email        string
+	Reservations []LookedUpReservation
+}
+
+type LookedUpReservation struct {
+	ReservationID int
+	Status        ReservationStatus
+	Seats         []ReservationDetailSeat
+}
+
+// ShowtimeManifest is every sold seat of a showtime, used at the door when ticket scanners fail.
+type ShowtimeManifest struct {
+	ShowtimeID int
+	TheaterID  int
+	Seats      []ManifestSeat
+}
+
+type ManifestSeat struct {
+	Row              int
+	Col              int
+	Type             string
+	ReservationID    int
+	Status           ReservationStatus
+	TicketsRevokedAt *time.Time
+	TicketVersion    int
+	FirstName        string
+	LastName         string
+	Email            string
+}
+
+// BookedShowtime is the showtime a reservation is booked for, as needed to decide whether the reservation
+// can be moved to another showtime.
+type BookedShowtime struct {
+	ReservationID    int
+	Status           ReservationStatus
+	FlexibleTicket   bool
+	TicketsRevokedAt *time.Time
+	ShowtimeID       int
+	MovieID          int
+	TheaterID        int
+	StartTime        time.Time
+	SeatIDs          []int
+	// PaidAmount is what the reservation was paid with so far, less the succeeded and pending refunds
+	PaidAmount decimal.Decimal
+}
+
+// ShowtimeChange is a move of a reservation to another showtime which waits for its price difference
+// to be paid.
+type ShowtimeChange struct {
+	ReservationID   int
+	UserID          int
+	FromShowtimeID  int
+	FromSeatIDs     []int
+	ToShowtimeID    int
+	MovieName       string
+	Date            time.Time
+	Seats           []ReservationSeat
+	PriceDifference decimal.Decimal
+}
+
+// SeatIDs returns the ids of the seats the reservation is moved to.
+func (c ShowtimeChange) SeatIDs() []int {
+	seatIDs := make([]int, len(c.Seats))
+	for i, seat := range c.Seats {
+		seatIDs[i] = seat.SeatID
+	}
+
+	return seatIDs
+}
+
+// SharedScreening is what the public share link of a reservation shows. It never includes the seats or
+// the price of the reservation.
+type SharedScreening struct {
+	MovieTitle      string
+	MoviePosterUrl  string
+	TheaterName     string
+	TheaterCity     string
+	StartTime       time.Time
+	TheaterTimeZone string
+	ExpiresAt       time.Time
+}
+
+// GenerateShareToken returns a random token identifying the share link of a reservation, along with the
+// hash which is stored instead of the token.
+func GenerateShareToken() (string, []byte, error) {
+	return generateOpaqueToken()
+}
+
+// GenerateCalendarFeedToken returns a random token identifying the calendar feed of a user, along with the
+// hash which is stored instead of the token.
+func GenerateCalendarFeedToken() (string, []byte, error) {
+	return generateOpaqueToken()
 }
 
 type ReservationRepository interface {
-	Create(ctx context.Context, reservation Reservation) error
+	Create(ctx context.Context, reservation *Reservation) error
+	// CreateComp creates the payment along with the reservation, the reservation gets its id.
+	CreateComp(ctx context.Context, reservation *Reservation, payment *Payment) error
 	GetSeatsByShowtimeId(ctx context.Context, showtimeId int) ([]ReservationSeat, error)
 	GetReservationsSummariesByUserId(ctx context.Context, userId int, pagination Pagination) ([]ReservationSummary, *Metadata, error)
+	// GetByReservationIdAndUserId finds reservations of guests when userId is 0.
 	GetByReservationIdAndUserId(ctx context.Context, reservationId, userId int) (*ReservationDetail, error)
+	// CancelUnpaidVenueReservations cancels reservations waiting for payment at the venue whose showtime
+	// starts before the given time and releases their seats. Reservations whose guests checked in are
+	// paid at the door, they are kept.
+	CancelUnpaidVenueReservations(ctx context.Context, startsBefore time.Time) ([]Reservation, error)
+	// GetCheckInListByShowtime returns the reservations of the showtime which are not cancelled.
+	GetCheckInListByShowtime(ctx context.Context, showtimeId int) ([]CheckInEntry, error)
+	// GetTicketCheckIn returns ErrRecordNotFound if the reservation doesn't exist.
+	GetTicketCheckIn(ctx context.Context, reservationId int) (*TicketCheckIn, error)
+	// CreateCheckIn returns ErrAlreadyCheckedIn if the reservation is checked in already.
+	CreateCheckIn(ctx context.Context, checkIn *CheckIn) error
+	// MarkNoShows marks the reservations which weren't checked in by the time the check-in of their showtime
+	// closed as no-shows, for showtimes starting after startedAfter. Unclaimed comp and pay-at-venue
+	// reservations are cancelled and their seats released. The marked reservations are returned, the
+	// cancelled ones with their released seats.
+	MarkNoShows(ctx context.Context, now, startedAfter time.Time) ([]Reservation, error)
+	// GetAttendanceByTheater returns the attendance of the showtimes of the theater starting within
+	// [from, to), ordered by start time.
+	GetAttendanceByTheater(ctx context.Context, theaterId int, from, to time.Time) ([]ShowtimeAttendance, error)
+	// GetManifestByShowtime returns the seats of the reservations of the showtime which are not cancelled.
+	// It returns ErrRecordNotFound if the showtime doesn't exist.
+	GetManifestByShowtime(ctx context.Context, showtimeId int) (*ShowtimeManifest, error)
+	// LookupByEmails returns the reservations of the showtime for every email address, in the order of
+	// the addresses. It returns ErrRecordNotFound if the showtime doesn't exist.
+	LookupByEmails(ctx context.Context, showtimeId int, emails []string) ([]ReservationLookup, error)
+	// GetBookedShowtime returns ErrRecordNotFound if the user has no reservation with the given id.
+	GetBookedShowtime(ctx context.Context, reservationId, userId int) (*BookedShowtime, error)
+	// CancelSeats removes the seats from the reservation booked for the showtime, releases them and
+	// replaces the tickets of the reservation. What the seats were sold for is queued as pending refunds in
+	// the same transaction, as far as the payments can still be refunded, they are returned. It returns
+	// ErrEditConflict if the reservation was moved concurrently and ErrRecordNotFound if a seat isn't in the
+	// reservation.
+	CancelSeats(ctx context.Context, reservationId, showtimeId int, seatIds []int) ([]PendingRefund, error)
+	// Reschedule makes the change, moving the reservation from the seats it's booked for to the seats of
+	// another showtime, and releases its old seats. A negative price difference is queued as pending refunds
+	// in the same transaction, they are returned. It returns ErrEditConflict if the reservation was
+	// cancelled, revoked, moved or its seats changed since the change was made, and ErrSeatAlreadyReserved
+	// if a seat is sold to another reservation.
+	Reschedule(ctx context.Context, change ShowtimeChange) ([]PendingRefund, error)
+	// GetReservedSeatsByShowtime returns the reserved seats of every showtime starting within [from, to),
+	// including the showtimes without any.
+	GetReservedSeatsByShowtime(ctx context.Context, from, to time.Time) (map[int][]int, error)
+	// GetInventoryAnomalies returns the oversold seats, the showtimes sold over capacity and the confirmed
+	// reservations without a completed payment of the showtimes starting within [from, to).
+	GetInventoryAnomalies(ctx context.Context, from, to time.Time) ([]InventoryAnomaly, error)
+	// Share stores the public share link of the reservation, replacing the link shared before.
+	Share(ctx context.Context, reservationId int, tokenHash []byte, expiresAt time.Time) error
+	// RevokeShare removes the share link of the user's reservation. It returns ErrRecordNotFound if the
+	// user has no reservation with the given id or it isn't shared.
+	RevokeShare(ctx context.Context, reservationId, userId int) error
+	// GetSharedScreening returns ErrRecordNotFound if no share link with the token exists or it expired.
+	GetSharedScreening(ctx context.Context, tokenHash []byte, now time.Time) (*SharedScreening, error)
+	// SaveCalendarFeed stores the token of the calendar feed of the user, replacing the previous one.
+	SaveCalendarFeed(ctx context.Context, userId int, tokenHash []byte) error
+	// DeleteCalendarFeed returns ErrRecordNotFound if the user has no calendar feed.
+	DeleteCalendarFeed(ctx context.Context, userId int) error
+	// GetCalendarFeed returns the reservations of the user owning the feed whose screening hasn't ended by
+	// now, ordered by their start. Cancelled reservations and revoked tickets are left out. It returns
+	// ErrRecordNotFound if no feed with the token exists.
+	GetCalendarFeed(ctx context.Context, tokenHash []byte, now time.Time) ([]ReservationDetail, error)
 }
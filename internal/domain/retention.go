@@ -0,0 +1,25 @@
+package domain
+
+import "time"
+
+type RetentionPolicy string
+
+const (
+	RetentionExpiredTokens        RetentionPolicy = "expired_tokens"
+	RetentionPaymentAnonymization RetentionPolicy = "payment_anonymization"
+	RetentionLifecycleEvents      RetentionPolicy = "lifecycle_events"
+)
+
+// RetentionResult is the outcome of applying a single retention policy. In dry-run mode Affected is
+// the number of rows that would have been purged or anonymized.
+type RetentionResult struct {
+	Policy   RetentionPolicy
+	Cutoff   time.Time
+	Affected int64
+}
+
+type RetentionReport struct {
+	DryRun    bool
+	StartedAt time.Time
+	Results   []RetentionResult
+}
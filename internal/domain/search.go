@@ -0,0 +1,22 @@
+package domain
+
+import "context"
+
+type SuggestionKind string
+
+const (
+	SuggestionMovie   SuggestionKind = "movie"
+	SuggestionTheater SuggestionKind = "theater"
+)
+
+type Suggestion struct {
+	Kind  SuggestionKind
+	ID    int
+	Label string
+}
+
+type SearchRepository interface {
+	// Suggest returns at most limit movies and at most limit theaters whose names start with the
+	// given prefix or are similar to it, best matches first.
+	Suggest(ctx context.Context, prefix string, limit int) ([]Suggestion, error)
+}
@@ -3,17 +3,33 @@ package domain
 import (
 	"context"
 	"time"
+
+	"github.com/shopspring/decimal"
 )
 
 type ShowtimeSeats struct {
 	TheaterID   int
+	MovieID     int
 	TheaterName string
 	MovieName   string
 	HallName    string
 	Date        time.Time
-	HallID      int
-	Seats       []Seat
-	Price       float64
+	// TheaterTimeZone is the time zone Date is shown in
+	TheaterTimeZone string
+	HallID          int
+	Seats           []Seat
+	Price           float64
+	Format          ScreeningFormat
+	// FormatSurcharge is the surcharge of the showtime's format, added to every seat
+	FormatSurcharge decimal.Decimal
+	// PayoutAccountID is the Stripe Connect account of the theater, empty when it has none
+	PayoutAccountID string
+	SalesWindow     SalesWindow
+}
+
+// PriceBreakdown itemizes the price of the seats, without the add-ons a cart may have.
+func (s *ShowtimeSeats) PriceBreakdown() PriceBreakdown {
+	return NewPriceBreakdown(decimal.NewFromFloat(s.Price), s.FormatSurcharge, toCartSeats(s.Seats))
 }
 
 type Seat struct {
@@ -22,10 +38,82 @@ type Seat struct {
 	Col        int
 	Type       string
 	ExtraPrice float64
-	Available  bool
+	// PriceVersionID identifies the scheduled price the extra price comes from, nil when the seat's own
+	// extra price applies
+	PriceVersionID *int
+	Available      bool
+}
+
+// SeatPriceVersion schedules the extra price of a seat type in a hall. The latest version whose
+// EffectiveFrom has passed overrides the extra price stored on the seats.
+type SeatPriceVersion struct {
+	ID            int
+	HallID        int
+	SeatType      string
+	ExtraPrice    decimal.Decimal
+	EffectiveFrom time.Time
+	CreatedAt     time.Time
+}
+
+// PriceVersionSales sums the seats of a hall sold at the same extra price. A nil PriceVersionID stands
+// for seats sold at the seat's own extra price.
+type PriceVersionSales struct {
+	PriceVersionID *int
+	SeatType       string
+	ExtraPrice     decimal.Decimal
+	SeatsSold      int
+	ExtrasRevenue  decimal.Decimal
+}
+
+type SeatBlockReason string
+
+const (
+	SeatBlockBroken SeatBlockReason = "BROKEN"
+	SeatBlockHouse  SeatBlockReason = "HOUSE"
+	// SeatBlockEvent blocks the seats of an active block hold, see BlockHold
+	SeatBlockEvent SeatBlockReason = "EVENT"
+)
+
+// SeatBlock takes a seat out of sale, either for a single showtime or for the showtimes of its hall
+// starting within [StartsAt, EndsAt). Blocks don't affect seats that are already sold or in a cart.
+type SeatBlock struct {
+	ID         int
+	SeatID     int
+	ShowtimeID *int
+	StartsAt   *time.Time
+	EndsAt     *time.Time
+	Reason     SeatBlockReason
+	Note       string
+	// CreatedBy is nil once the admin who created the block is deleted
+	CreatedBy *int
+	CreatedAt time.Time
 }
 
 type SeatRepository interface {
 	GetSeatsByShowtime(ctx context.Context, showtimeID int) (*ShowtimeSeats, error)
 	GetSeatsByShowtimeAndSeatIds(ctx context.Context, showtimeID int, seatIDs []int) (*ShowtimeSeats, error)
+	// GetSeatSalesByHall and GetSalesByPriceVersion count the sales of all channels when the channel is empty.
+	GetSeatSalesByHall(ctx context.Context, hallID int, from, to time.Time, channel SalesChannel) (*SeatHeatmap, error)
+	// CreatePriceVersion returns ErrHallNotFound if the hall doesn't exist and ErrEditConflict if a price
+	// of the seat type is already scheduled for the same time.
+	CreatePriceVersion(ctx context.Context, version *SeatPriceVersion) error
+	GetPriceVersionsByHall(ctx context.Context, hallID int) ([]SeatPriceVersion, error)
+	GetSalesByPriceVersion(ctx context.Context, hallID int, channel SalesChannel) ([]PriceVersionSales, error)
+	// CreateSeatBlocks creates all the blocks or none. It returns ErrRecordNotFound if a seat doesn't
+	// exist or is not in the hall of the block's showtime.
+	CreateSeatBlocks(ctx context.Context, blocks []SeatBlock) error
+	// GetSeatBlocksByShowtime returns the blocks applying to the showtime, by showtime or time range.
+	GetSeatBlocksByShowtime(ctx context.Context, showtimeID int) ([]SeatBlock, error)
+	DeleteSeatBlock(ctx context.Context, id int) error
+	// CreateHallTemplate and UpdateHallTemplate return ErrEditConflict if another template has the same
+	// name. UpdateHallTemplate replaces the seats of the template.
+	CreateHallTemplate(ctx context.Context, template *HallTemplate) error
+	GetHallTemplates(ctx context.Context) ([]HallTemplate, error)
+	GetHallTemplate(ctx context.Context, id int) (*HallTemplate, error)
+	UpdateHallTemplate(ctx context.Context, template *HallTemplate) error
+	DeleteHallTemplate(ctx context.Context, id int) error
+	// CreateHallFromTemplate creates the hall in the theater with a copy of the template's seats, in one
+	// transaction. It returns the number of seats created, ErrTheaterNotFound if the theater doesn't exist
+	// and ErrRecordNotFound if the template doesn't.
+	CreateHallFromTemplate(ctx context.Context, hall *Hall, templateID int) (int, error)
 }
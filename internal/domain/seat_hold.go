@@ -0,0 +1,26 @@
+package domain
+
+import "slices"
+
+// SeatHold locks seats of a showtime for clients confirming them outside the browser cart flow, like
+// kiosks. The client names the hold, so a retried request finds the hold it has already placed.
+type SeatHold struct {
+	Reference  string
+	UserID     int
+	ShowtimeID int
+	SeatIDs    []int
+}
+
+// Holds reports whether the hold locks exactly the given seats of the showtime, in any order.
+func (h SeatHold) Holds(showtimeID int, seatIDs []int) bool {
+	if h.ShowtimeID != showtimeID || len(h.SeatIDs) != len(seatIDs) {
+		return false
+	}
+
+	held := slices.Clone(h.SeatIDs)
+	requested := slices.Clone(seatIDs)
+	slices.Sort(held)
+	slices.Sort(requested)
+
+	return slices.Equal(held, requested)
+}
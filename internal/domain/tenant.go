@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"context"
+	"net"
+	"strings"
+)
+
+// DefaultTenantSlug identifies the tenant serving requests that match no other tenant.
+const DefaultTenantSlug = "default"
+
+// Tenant is a cinema brand run on the shared deployment. Empty settings fall back to the global
+// configuration.
+type Tenant struct {
+	ID        int
+	Slug      string
+	Name      string
+	Hostnames []string
+	// branding shown by the clients of the tenant
+	LogoUrl      string
+	PrimaryColor string
+	MailSender   string
+	// connected Stripe account charged on checkouts of the tenant
+	StripeAccountID string
+	SuccessURL      string
+	FailureURL      string
+}
+
+// ServesHost reports whether the tenant is reached under the host, which may carry a port.
+func (t *Tenant) ServesHost(host string) bool {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+
+	for _, hostname := range t.Hostnames {
+		if strings.EqualFold(hostname, host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type TenantRepository interface {
+	GetAll(ctx context.Context) ([]Tenant, error)
+}
+
+type tenantContextKey struct{}
+
+// WithTenant scopes the repositories called with the returned context to the tenant.
+func WithTenant(ctx context.Context, tenant *Tenant) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant the context is scoped to, or nil.
+func TenantFromContext(ctx context.Context) *Tenant {
+	tenant, _ := ctx.Value(tenantContextKey{}).(*Tenant)
+	return tenant
+}
+
+// TenantIDFromContext returns the ID of the tenant the context is scoped to, or 0 when it's not scoped
+// to a tenant, e.g. in background jobs, which see the records of all tenants.
+func TenantIDFromContext(ctx context.Context) int {
+	if tenant := TenantFromContext(ctx); tenant != nil {
+		return tenant.ID
+	}
+
+	return 0
+}
@@ -2,22 +2,68 @@ package domain
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/shopspring/decimal"
 )
 
 type Theater struct {
-	ID        int
-	Name      string
-	Address   string
-	City      string
-	District  string
+	ID       int
+	Name     string
+	Address  string
+	City     string
+	District string
+	// TimeZone is the IANA time zone of the theater, the days and local times of its showtimes are
+	// taken in it
+	TimeZone  string
 	Distance  float64
 	Amenities []Amenity
 	Halls     []Hall
 }
 
+// DefaultTimeZone is the time zone of theaters which weren't given one.
+const DefaultTimeZone = "UTC"
+
+var theaterLocations sync.Map
+
+// TheaterLocation returns the location of a theater's time zone. Time zones are validated before they are
+// stored, an unknown one falls back to UTC instead of failing the request that shows the theater.
+func TheaterLocation(timeZone string) *time.Location {
+	if loc, ok := theaterLocations.Load(timeZone); ok {
+		return loc.(*time.Location)
+	}
+
+	loc := time.UTC
+	if ValidTimeZone(timeZone) {
+		loc, _ = time.LoadLocation(timeZone)
+	}
+
+	theaterLocations.Store(timeZone, loc)
+
+	return loc
+}
+
+// ValidTimeZone reports whether name is an IANA time zone a theater can be in. The Local time zone of
+// the server is not one.
+func ValidTimeZone(name string) bool {
+	if name == "" || name == "Local" {
+		return false
+	}
+
+	_, err := time.LoadLocation(name)
+
+	return err == nil
+}
+
+// StartOfDay returns the midnight starting the day of t in loc.
+func StartOfDay(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
 type Amenity struct {
 	ID          int
 	Name        string
@@ -33,9 +79,227 @@ type Hall struct {
 }
 
 type Showtime struct {
-	ID        int
-	StartTime time.Time
-	BasePrice pgtype.Numeric
+	ID           int
+	StartTime    time.Time
+	BasePrice    pgtype.Numeric
+	OpenCaptions bool
+	Format       ScreeningFormat
+	// PreShowMinutes is the length of the ads and trailers screened before the feature, the one of the
+	// theater unless the showtime has its own
+	PreShowMinutes int
+	SalesWindow
+}
+
+// MaxPreShowMinutes is the longest pre-show a theater or a showtime can be given.
+const MaxPreShowMinutes = 60
+
+// FeatureStart returns when the feature of a showtime starting at start begins, after the pre-show. It is
+// the seating deadline: tickets are sold until then and check-in windows are taken around it.
+func FeatureStart(start time.Time, preShowMinutes int) time.Time {
+	return start.Add(time.Duration(preShowMinutes) * time.Minute)
+}
+
+// SalesWindow is when the tickets of a showtime are sold, as set by an operator. Without OpenAt sales open
+// as soon as the showtime is scheduled, without CloseAt they close when the feature starts.
+type SalesWindow struct {
+	OpenAt  *time.Time `json:"salesOpenAt"`
+	CloseAt *time.Time `json:"salesCloseAt"`
+}
+
+// Check returns ErrSalesNotOpen or ErrSalesClosed if tickets can't be sold at now. The start time of the
+// showtime isn't checked, a showtime that has started is no longer on sale anyway.
+func (w SalesWindow) Check(now time.Time) error {
+	if w.OpenAt != nil && now.Before(*w.OpenAt) {
+		return ErrSalesNotOpen
+	}
+
+	if w.CloseAt != nil && !now.Before(*w.CloseAt) {
+		return ErrSalesClosed
+	}
+
+	return nil
+}
+
+// CheckInPolicy is when a theater lets its guests in, in minutes around the start of the feature of a
+// showtime. Check-in opens OpensBefore minutes before the start and closes ClosesAfter minutes after it,
+// guests let in more than LateAfter minutes after the start are late.
+type CheckInPolicy struct {
+	OpensBefore int
+	LateAfter   int
+	ClosesAfter int
+}
+
+// Check returns how many minutes late a guest checking in at now is, zero within the grace period. It
+// returns ErrCheckInNotOpen or ErrCheckInClosed if guests of the showtime starting at start can't be let
+// in at now.
+func (p CheckInPolicy) Check(start, now time.Time) (int, error) {
+	if now.Before(start.Add(-time.Duration(p.OpensBefore) * time.Minute)) {
+		return 0, ErrCheckInNotOpen
+	}
+
+	if !now.Before(start.Add(time.Duration(p.ClosesAfter) * time.Minute)) {
+		return 0, ErrCheckInClosed
+	}
+
+	late := now.Sub(start)
+	if late <= time.Duration(p.LateAfter)*time.Minute {
+		return 0, nil
+	}
+
+	return int(late / time.Minute), nil
+}
+
+// SeatAvailability counts the seats of a showtime, a seat is unavailable when it's sold or blocked.
+type SeatAvailability struct {
+	ShowtimeID int
+	Total      int
+	Available  int
+}
+
+// OccupancyBand is a coarse hint at how full a showtime is, shown to users instead of seat counts.
+type OccupancyBand string
+
+const (
+	OccupancyPlenty      OccupancyBand = "plenty"
+	OccupancyFillingFast OccupancyBand = "filling_fast"
+	OccupancyAlmostFull  OccupancyBand = "almost_full"
+)
+
+// Band returns the occupancy band of the showtime. Less than a fifth of the seats left is almost full, the
+// share partners report as limited, less than half is filling fast.
+func (a SeatAvailability) Band() OccupancyBand {
+	switch {
+	case a.Available <= 0 || float64(a.Available) < float64(a.Total)*0.2:
+		return OccupancyAlmostFull
+	case float64(a.Available) < float64(a.Total)*0.5:
+		return OccupancyFillingFast
+	default:
+		return OccupancyPlenty
+	}
+}
+
+type ScreeningFormat string
+
+const (
+	Format2D   ScreeningFormat = "2D"
+	Format3D   ScreeningFormat = "3D"
+	FormatIMAX ScreeningFormat = "IMAX"
+)
+
+// FormatSurcharge is added to the price of every seat of a showtime screened in the format.
+type FormatSurcharge struct {
+	Format    ScreeningFormat
+	Surcharge decimal.Decimal
+	UpdatedAt time.Time
+}
+
+// Screening is an upcoming showtime with the details published in the showtimes feed.
+type Screening struct {
+	ShowtimeID   int
+	StartTime    time.Time
+	Format       ScreeningFormat
+	OpenCaptions bool
+	// Price is the base price of the showtime plus the surcharge of its format
+	Price           decimal.Decimal
+	HallName        string
+	TheaterID       int
+	TheaterName     string
+	TheaterAddress  string
+	TheaterCity     string
+	TheaterDistrict string
+	TheaterTimeZone string
+	MovieID         int
+	MovieTitle      string
+	MovieDuration   int
+	MoviePosterUrl  string
+}
+
+// PartnerShowtime is an upcoming showtime as synced by ticket partners. UpdatedAt moves forward with every
+// change of the showtime, its price or its availability.
+type PartnerShowtime struct {
+	ShowtimeID   int
+	StartTime    time.Time
+	Format       ScreeningFormat
+	OpenCaptions bool
+	// Price is the base price of the showtime plus the surcharge of its format
+	Price           decimal.Decimal
+	MovieID         int
+	MovieTitle      string
+	TheaterID       int
+	TheaterName     string
+	TheaterCity     string
+	TheaterTimeZone string
+	HallID          int
+	HallName        string
+	TotalSeats      int
+	AvailableSeats  int
+	UpdatedAt       time.Time
+}
+
+// RescheduleOption is a showtime a reservation can be moved to.
+type RescheduleOption struct {
+	ShowtimeID   int
+	StartTime    time.Time
+	Format       ScreeningFormat
+	OpenCaptions bool
+	HallName     string
+	// Price is the base price of the showtime plus the surcharge of its format
+	Price           decimal.Decimal
+	AvailableSeats  int
+	TheaterTimeZone string
+}
+
+// NextShowtime is the soonest bookable showtime of a movie near a location.
+type NextShowtime struct {
+	ShowtimeID   int
+	StartTime    time.Time
+	Format       ScreeningFormat
+	OpenCaptions bool
+	// Price is the base price of the showtime plus the surcharge of its format
+	Price           decimal.Decimal
+	MovieID         int
+	MovieTitle      string
+	TheaterID       int
+	TheaterName     string
+	TheaterAddress  string
+	TheaterCity     string
+	TheaterDistrict string
+	TheaterTimeZone string
+	HallName        string
+	// DistanceKm is the distance of the theater from the location
+	DistanceKm     float64
+	AvailableSeats int
+}
+
+// NowPlayingBoard is the schedule of a theater as shown on the screens in its lobby.
+type NowPlayingBoard struct {
+	TheaterID   int
+	TheaterName string
+	TimeZone    string
+	// Date is the day of the board in the time zone of the theater
+	Date      time.Time
+	Showtimes []BoardShowtime
+}
+
+// BoardShowtime is a showtime listed on the board of a theater. It's sold out when every seat is sold or
+// blocked, seats locked in a cart are still offered.
+type BoardShowtime struct {
+	ShowtimeID     int
+	StartTime      time.Time
+	Format         ScreeningFormat
+	OpenCaptions   bool
+	MovieID        int
+	MovieTitle     string
+	MovieDuration  int
+	MoviePosterUrl string
+	HallName       string
+	SoldOut        bool
+}
+
+// ShowtimeFilter narrows down the showtimes listed for a movie. Zero values don't filter.
+type ShowtimeFilter struct {
+	Accessibility AccessibilityFeature
+	Format        ScreeningFormat
 }
 
 type TheaterRepository interface {
@@ -44,6 +308,59 @@ type TheaterRepository interface {
 		movieID int,
 		date time.Time,
 		lat, long float64,
+		filter ShowtimeFilter,
 		pagination Pagination,
 	) ([]Theater, *Metadata, error)
+	// UpdateShowtimeFormat returns ErrRecordNotFound if the showtime doesn't exist.
+	UpdateShowtimeFormat(ctx context.Context, showtimeID int, format ScreeningFormat) error
+	GetFormatSurcharges(ctx context.Context) ([]FormatSurcharge, error)
+	// UpdateFormatSurcharge returns ErrRecordNotFound if the format doesn't exist.
+	UpdateFormatSurcharge(ctx context.Context, surcharge *FormatSurcharge) error
+	// IsTheaterStaff reports whether the user works at the theater.
+	IsTheaterStaff(ctx context.Context, theaterID, userID int) (bool, error)
+	// GetUpcomingScreenings returns the showtimes starting within [from, to), ordered by start time.
+	GetUpcomingScreenings(ctx context.Context, from, to time.Time) ([]Screening, error)
+	// GetPartnerShowtimes returns the upcoming showtimes changed after since, all of them when since is nil,
+	// ordered by the time of the change.
+	GetPartnerShowtimes(ctx context.Context, since *time.Time, pagination Pagination) ([]PartnerShowtime, *Metadata, error)
+	// UpdatePayoutAccount sets the Stripe Connect account of the theater, an empty id removes it. Returns
+	// ErrRecordNotFound if the theater doesn't exist.
+	UpdatePayoutAccount(ctx context.Context, theaterID int, accountID string) error
+	// GetRescheduleOptions returns the showtimes of the movie at the theater starting after the given time
+	// with at least minSeats available seats, except the given showtime, ordered by start time.
+	GetRescheduleOptions(
+		ctx context.Context,
+		movieID, theaterID, exceptShowtimeID, minSeats int,
+		after time.Time,
+	) ([]RescheduleOption, error)
+	// GetNextShowtime returns the showtime of the movie starting soonest after the given time with an
+	// available seat, at a theater near the location. It returns ErrRecordNotFound if there is none.
+	GetNextShowtime(ctx context.Context, movieID int, long, lat float64, after time.Time) (*NextShowtime, error)
+	// GetNowPlayingBoard returns the showtimes of the theater starting on the day of now in the time zone of
+	// the theater, ordered by start time. It returns ErrTheaterNotFound if the theater doesn't exist.
+	GetNowPlayingBoard(ctx context.Context, theaterID int, now time.Time) (*NowPlayingBoard, error)
+	// GetSeatAvailability counts the seats of the given showtimes, unknown ids are left out.
+	GetSeatAvailability(ctx context.Context, showtimeIDs []int) ([]SeatAvailability, error)
+	// UpdateSalesWindow replaces the sales window of the showtime, nil bounds fall back to the defaults. It
+	// returns ErrRecordNotFound if the showtime doesn't exist and ErrInvalidSalesWindow if the window doesn't
+	// end by the start of the showtime or closes before it opens.
+	UpdateSalesWindow(ctx context.Context, showtimeID int, window SalesWindow) error
+	// UpdateTheaterPreShow sets the pre-show length of the showtimes of the theater which don't have their
+	// own. It returns ErrRecordNotFound if the theater doesn't exist.
+	UpdateTheaterPreShow(ctx context.Context, theaterID, minutes int) error
+	// UpdateShowtimePreShow sets the pre-show length of the showtime, nil falls back to the one of its
+	// theater. It returns ErrRecordNotFound if the showtime doesn't exist.
+	UpdateShowtimePreShow(ctx context.Context, showtimeID int, minutes *int) error
+	// UpdateCheckInPolicy replaces the check-in policy of the theater. It returns ErrRecordNotFound if the
+	// theater doesn't exist and ErrInvalidCheckInPolicy if check-in closes before guests are late.
+	UpdateCheckInPolicy(ctx context.Context, theaterID int, policy CheckInPolicy) error
+	// AdjustShowtimePrices applies the adjustment to the base prices of the upcoming showtimes of the tenant
+	// of the context it matches, in one transaction, and returns their changes ordered by start time. A dry
+	// run returns the changes without applying them. It returns ErrInvalidPriceAdjustment, and changes none
+	// of the prices, if the adjustment makes any of them invalid.
+	AdjustShowtimePrices(ctx context.Context, adjustment BasePriceAdjustment, dryRun bool) ([]ShowtimePriceChange, error)
+	GetAmenities(ctx context.Context) ([]Amenity, error)
+	// ImportTheaters creates the theaters with their halls, seats and amenities in one transaction and
+	// returns their ids in the given order. The theaters belong to the tenant of the context.
+	ImportTheaters(ctx context.Context, theaters []TheaterImport) ([]int, error)
 }
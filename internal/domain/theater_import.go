@@ -0,0 +1,37 @@
+package domain
+
+// TheaterImport is a theater created by a bulk import together with its halls and seats. Amenities are
+// referenced by id, they are resolved from their names before the import.
+type TheaterImport struct {
+	Name        string
+	Address     string
+	City        string
+	District    string
+	Latitude    float64
+	Longitude   float64
+	PhoneNumber string
+	Email       string
+	Website     string
+	TimeZone    string
+	AmenityIDs  []int
+	Halls       []HallImport
+}
+
+type HallImport struct {
+	Name       string
+	AmenityIDs []int
+	Seats      []HallTemplateSeat
+}
+
+// TheaterImportCounts returns the number of halls and seats of the theaters.
+func TheaterImportCounts(theaters []TheaterImport) (halls, seats int) {
+	for _, theater := range theaters {
+		halls += len(theater.Halls)
+
+		for _, hall := range theater.Halls {
+			seats += len(hall.Seats)
+		}
+	}
+
+	return halls, seats
+}
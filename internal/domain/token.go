@@ -11,6 +11,9 @@ import (
 const (
 	UserActivationScope string = "user_activation"
 	UserDeletionScope   string = "user_deletion"
+	MagicLinkScope      string = "magic_link"
+	PendingChangesScope string = "pending_changes"
+	PasswordResetScope  string = "password_reset"
 	tokenLength         int    = 32
 )
 
@@ -44,7 +47,26 @@ func GenerateToken(userId int64, ttl time.Duration, scope string) (*Token, error
 	return token, nil
 }
 
+// generateOpaqueToken returns a random token not bound to a user, along with the hash which is stored
+// instead of the token.
+func generateOpaqueToken() (string, []byte, error) {
+	randomBytes := make([]byte, tokenLength)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	plaintext := base64.RawURLEncoding.EncodeToString(randomBytes)
+	hash := sha256.Sum256([]byte(plaintext))
+
+	return plaintext, hash[:], nil
+}
+
 type TokenRepository interface {
 	Create(context.Context, *Token) error
 	DeleteAllForUser(ctx context.Context, tokenScope string, userID int) error
+	// Consume deletes the unexpired token with the given hash and scope and returns the ID of its user,
+	// so that a token can be used only once even by concurrent requests.
+	Consume(ctx context.Context, tokenHash []byte, tokenScope string) (int, error)
+	DeleteExpired(ctx context.Context, expiredBefore time.Time, dryRun bool) (int64, error)
 }
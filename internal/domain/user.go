@@ -16,6 +16,15 @@ const (
 	Other  Gender = "OTHER"
 )
 
+type Role string
+
+const (
+	RoleUser  Role = "user"
+	RoleAdmin Role = "admin"
+	// RoleKiosk is the account of a self-service kiosk, its sales are attributed to the kiosk channel
+	RoleKiosk Role = "kiosk"
+)
+
 type User struct {
 	ID        int
 	FirstName string
@@ -28,7 +37,66 @@ type User struct {
 	UpdatedAt time.Time
 	Activated bool
 	IsActive  bool
+	Role      Role
 	Version   int
+	// PendingChanges are waiting for the user to verify them, nil if there are none
+	PendingChanges *PendingChanges
+}
+
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+type UserPreferences struct {
+	UserID            int
+	Latitude          *float64
+	Longitude         *float64
+	FavoriteTheaterID *int
+	Language          *string
+	SeatType          *string
+	// MarketingEmails is the consent of the user to receive email campaigns
+	MarketingEmails bool
+	// NotificationDigest batches the announcement emails of the user into a daily digest
+	NotificationDigest bool
+
+	// location of the favorite theater, resolved when the preferences are read
+	FavoriteTheaterLatitude  *float64
+	FavoriteTheaterLongitude *float64
+}
+
+// Location returns the default location of the user. Explicitly stored coordinates take precedence
+// over the location of the favorite theater.
+func (p *UserPreferences) Location() (lat, long float64, ok bool) {
+	if p.Latitude != nil && p.Longitude != nil {
+		return *p.Latitude, *p.Longitude, true
+	}
+
+	if p.FavoriteTheaterLatitude != nil && p.FavoriteTheaterLongitude != nil {
+		return *p.FavoriteTheaterLatitude, *p.FavoriteTheaterLongitude, true
+	}
+
+	return 0, 0, false
+}
+
+// PendingChanges are changes to the emails the user receives, e.g. an opt-in to marketing emails or a new
+// address. They're kept on the user record apart from the settings in use until the user verifies them with
+// the token sent to their address, so the mail dispatcher never acts on a change that isn't verified.
+type PendingChanges struct {
+	MarketingEmails *bool `json:"marketingEmails,omitempty"`
+	// Email is the new address of the user, the current one stays in use until the changes are verified
+	Email *string `json:"email,omitempty"`
+	// Source is where the changes were requested, it's recorded with the consent they grant
+	Source ConsentSource `json:"source,omitempty"`
+}
+
+// Recipient returns the address the changes are verified from. An email change is verified from the new
+// address, so a user can only take an address into use once they prove they receive its emails.
+func (c PendingChanges) Recipient(currentEmail string) string {
+	if c.Email != nil {
+		return *c.Email
+	}
+
+	return currentEmail
 }
 
 type password struct {
@@ -66,8 +134,38 @@ type UserRepository interface {
 	CreateWithToken(context.Context, *User, func(*User) (*Token, error)) (*Token, error)
 	GetByToken(ctx context.Context, tokenHash []byte, tokenScope string) (*User, error)
 	GetByEmail(ctx context.Context, email string) (*User, error)
+	// ExistsByEmail reports whether an account uses the email, whether or not it's activated.
+	ExistsByEmail(ctx context.Context, email string) (bool, error)
 	GetById(ctx context.Context, id int) (*User, error)
 	Update(context.Context, *User) error
 	ActivateUser(context.Context, *User) error
+	// ResetPassword consumes the password reset token and replaces the password hash of its user in a single
+	// transaction, returning the ID of the user. It returns ErrRecordNotFound if the token doesn't exist, has
+	// expired or its account was deleted.
+	ResetPassword(ctx context.Context, tokenHash []byte, passwordHash []byte) (int, error)
 	Delete(ctx context.Context, user *User) error
+	GetPendingActivationReminders(ctx context.Context, expiresBefore time.Time) ([]*User, error)
+	MarkActivationReminderSent(ctx context.Context, userID int) error
+	DeleteUnactivatedCreatedBefore(ctx context.Context, cutoff time.Time) (int64, error)
+	GetPreferences(ctx context.Context, userID int) (*UserPreferences, error)
+	// UpsertPreferences stores the preferences, turning marketing emails off is recorded as a withdrawal of
+	// the consent.
+	UpsertPreferences(ctx context.Context, preferences *UserPreferences) error
+	// SavePendingChanges replaces the pending changes of the user along with the token verifying them. An
+	// opt-in to marketing emails is recorded as a consent which is confirmed when the changes are applied.
+	// It returns ErrUserAlreadyExists if another account uses the pending email.
+	SavePendingChanges(ctx context.Context, userID int, changes PendingChanges, token *Token) error
+	// DiscardPendingChanges drops the pending marketing opt-in of the user. The token verifying the changes
+	// is invalidated unless an email change is still pending.
+	DiscardPendingChanges(ctx context.Context, userID int) error
+	// ApplyPendingChanges consumes the token and applies the pending changes of its user, returning the ID of
+	// the user. The consent recorded with the changes is confirmed. It returns ErrRecordNotFound if the token
+	// doesn't exist or has expired, and ErrPendingEmailChange if the changes include an email change, which
+	// only the user can confirm.
+	ApplyPendingChanges(ctx context.Context, tokenHash []byte) (int, error)
+	// ApplyPendingEmailChange consumes the token of the user and applies their pending changes, including
+	// the email change. It returns ErrRecordNotFound if the token doesn't exist, has expired, belongs to
+	// another user or no email change is pending, and ErrUserAlreadyExists if another account took the
+	// pending email meanwhile.
+	ApplyPendingEmailChange(ctx context.Context, userID int, tokenHash []byte) error
 }
@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrWalletPassUnavailable = errors.New("wallet passes are not available")
+
+// WalletTicket is the reservation data printed on a wallet pass.
+type WalletTicket struct {
+	// SerialNumber identifies the pass across reissues, so a regenerated pass replaces the old one.
+	SerialNumber string
+	TicketCode   string
+	HolderName   string
+	MovieTitle   string
+	TheaterName  string
+	HallName     string
+	Address      string
+	Location     GeoPoint
+	StartsAt     time.Time
+	EndsAt       time.Time
+	Seats        []string
+}
+
+type WalletPassIssuer interface {
+	// ApplePass returns a signed .pkpass bundle for the ticket. It returns ErrWalletPassUnavailable when
+	// Apple Wallet is not configured.
+	ApplePass(ticket WalletTicket) ([]byte, error)
+	// GoogleSaveURL returns a "Save to Google Wallet" link carrying the ticket as a signed JWT. It returns
+	// ErrWalletPassUnavailable when Google Wallet is not configured.
+	GoogleSaveURL(ticket WalletTicket) (string, error)
+}
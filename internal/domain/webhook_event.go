@@ -0,0 +1,23 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// WebhookEvent is a verified Stripe event as it was received, kept so it can be replayed once a bug in
+// its handling is fixed.
+type WebhookEvent struct {
+	ID             string
+	Type           string
+	Payload        []byte
+	ReceivedAt     time.Time
+	LastReplayedAt *time.Time
+}
+
+type WebhookEventRepository interface {
+	// Store saves the event unless it was received before. It reports whether the event is new.
+	Store(ctx context.Context, event *WebhookEvent) (bool, error)
+	GetById(ctx context.Context, id string) (*WebhookEvent, error)
+	MarkReplayed(ctx context.Context, id string) error
+}
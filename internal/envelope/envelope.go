@@ -0,0 +1,220 @@
+// Package envelope implements application level envelope encryption for sensitive values that are
+// stored in the database.
+//
+// Every value is encrypted with a freshly generated data key, and the data key is wrapped with a
+// key encryption key from the keyring. The identifier of the wrapping key is stored alongside the
+// ciphertext, so keys can be rotated by adding a new primary key while keeping the old ones around
+// for decryption until all rows are re-encrypted.
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	formatVersion  byte = 1
+	keySize             = 32
+	nonceSize           = 12
+	maxKeyIDLength      = 255
+)
+
+var (
+	ErrUnknownKey        = errors.New("envelope: unknown key id")
+	ErrMalformedEnvelope = errors.New("envelope: malformed ciphertext")
+	ErrInvalidKey        = errors.New("envelope: keys must be 32 bytes long")
+)
+
+// Keyring holds the key encryption keys. New values are always encrypted with the primary key,
+// while any key in the ring can be used for decryption.
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+func NewKeyring(primary string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, primary)
+	}
+
+	ring := &Keyring{
+		primary: primary,
+		keys:    make(map[string]cipher.AEAD, len(keys)),
+	}
+
+	for id, key := range keys {
+		if id == "" || len(id) > maxKeyIDLength {
+			return nil, fmt.Errorf("envelope: invalid key id %q", id)
+		}
+
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+
+		ring.keys[id] = aead
+	}
+
+	return ring, nil
+}
+
+// ParseKeyring parses a comma separated list of id:base64-key pairs. The first key in the list
+// becomes the primary key. An empty spec yields a nil keyring, which disables encryption.
+func ParseKeyring(spec string) (*Keyring, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+
+	var primary string
+	keys := make(map[string][]byte)
+
+	for _, entry := range strings.Split(spec, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			return nil, fmt.Errorf("envelope: key entry must be in id:base64 form")
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("envelope: key %q is not valid base64: %w", id, err)
+		}
+
+		if _, exists := keys[id]; exists {
+			return nil, fmt.Errorf("envelope: duplicate key id %q", id)
+		}
+
+		if primary == "" {
+			primary = id
+		}
+
+		keys[id] = key
+	}
+
+	return NewKeyring(primary, keys)
+}
+
+// PrimaryKeyID returns the identifier of the key used for new encryptions.
+func (k *Keyring) PrimaryKeyID() string {
+	return k.primary
+}
+
+// Encrypt seals the plaintext under a new data key. The associated data is authenticated but not
+// stored, so the same value has to be supplied on decryption.
+//
+// The layout of the result is:
+//
+//	version | len(key id) | key id | wrap nonce | wrapped data key | data nonce | ciphertext
+func (k *Keyring) Encrypt(plaintext, associatedData []byte) ([]byte, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapNonce, err := randomNonce()
+	if err != nil {
+		return nil, err
+	}
+
+	dataNonce, err := randomNonce()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, 2+len(k.primary)+2*nonceSize+keySize+len(plaintext)+2*dataAEAD.Overhead())
+	out = append(out, formatVersion, byte(len(k.primary)))
+	out = append(out, k.primary...)
+	out = append(out, wrapNonce...)
+	out = k.keys[k.primary].Seal(out, wrapNonce, dataKey, []byte(k.primary))
+	out = append(out, dataNonce...)
+	out = dataAEAD.Seal(out, dataNonce, plaintext, associatedData)
+
+	return out, nil
+}
+
+// Decrypt opens a value produced by Encrypt with any key in the ring.
+func (k *Keyring) Decrypt(ciphertext, associatedData []byte) ([]byte, error) {
+	keyID, rest, err := splitKeyID(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	kek, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+
+	wrappedLen := nonceSize + keySize + kek.Overhead()
+	if len(rest) < wrappedLen+nonceSize {
+		return nil, ErrMalformedEnvelope
+	}
+
+	dataKey, err := kek.Open(nil, rest[:nonceSize], rest[nonceSize:wrappedLen], []byte(keyID))
+	if err != nil {
+		return nil, ErrMalformedEnvelope
+	}
+
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	rest = rest[wrappedLen:]
+
+	plaintext, err := dataAEAD.Open(nil, rest[:nonceSize], rest[nonceSize:], associatedData)
+	if err != nil {
+		return nil, ErrMalformedEnvelope
+	}
+
+	return plaintext, nil
+}
+
+// NeedsRotation reports whether the value was encrypted with a key other than the primary key.
+func (k *Keyring) NeedsRotation(ciphertext []byte) bool {
+	keyID, _, err := splitKeyID(ciphertext)
+
+	return err != nil || keyID != k.primary
+}
+
+func splitKeyID(ciphertext []byte) (string, []byte, error) {
+	if len(ciphertext) < 2 || ciphertext[0] != formatVersion {
+		return "", nil, ErrMalformedEnvelope
+	}
+
+	idLen := int(ciphertext[1])
+	if len(ciphertext) < 2+idLen {
+		return "", nil, ErrMalformedEnvelope
+	}
+
+	return string(ciphertext[2 : 2+idLen]), ciphertext[2+idLen:], nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != keySize {
+		return nil, ErrInvalidKey
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func randomNonce() ([]byte, error) {
+	nonce := make([]byte, nonceSize)
+	_, err := rand.Read(nonce)
+
+	return nonce, err
+}
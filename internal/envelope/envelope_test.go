@@ -0,0 +1,139 @@
+package envelope
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, keySize)
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	ring, err := NewKeyring("k1", map[string][]byte{"k1": testKey(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext := []byte("1990-05-17")
+	aad := []byte("users.birth_date")
+
+	first, err := ring.Encrypt(plaintext, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := ring.Encrypt(plaintext, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Equal(first, second) {
+		t.Error("expected encryptions of the same value to differ")
+	}
+
+	got, err := ring.Decrypt(first, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("Decrypt() = %q, want %q", got, plaintext)
+	}
+
+	if _, err := ring.Decrypt(first, []byte("users.email")); !errors.Is(err, ErrMalformedEnvelope) {
+		t.Errorf("Decrypt() with other associated data error = %v, want %v", err, ErrMalformedEnvelope)
+	}
+
+	tampered := bytes.Clone(first)
+	tampered[len(tampered)-1] ^= 0xff
+
+	if _, err := ring.Decrypt(tampered, aad); !errors.Is(err, ErrMalformedEnvelope) {
+		t.Errorf("Decrypt() of tampered value error = %v, want %v", err, ErrMalformedEnvelope)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldRing, err := NewKeyring("k1", map[string][]byte{"k1": testKey(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := NewKeyring("k2", map[string][]byte{"k1": testKey(1), "k2": testKey(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ciphertext, err := oldRing.Encrypt([]byte("secret"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !rotated.NeedsRotation(ciphertext) {
+		t.Error("expected value encrypted with old key to need rotation")
+	}
+
+	if _, err := rotated.Decrypt(ciphertext, nil); err != nil {
+		t.Errorf("Decrypt() with rotated keyring error = %v", err)
+	}
+
+	reencrypted, err := rotated.Encrypt([]byte("secret"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rotated.NeedsRotation(reencrypted) {
+		t.Error("expected value encrypted with primary key not to need rotation")
+	}
+
+	if _, err := oldRing.Decrypt(reencrypted, nil); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Decrypt() with unknown key error = %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestParseKeyring(t *testing.T) {
+	k1 := base64.StdEncoding.EncodeToString(testKey(1))
+	k2 := base64.StdEncoding.EncodeToString(testKey(2))
+
+	tests := []struct {
+		name        string
+		spec        string
+		wantPrimary string
+		wantNil     bool
+		wantErr     bool
+	}{
+		{name: "empty spec disables encryption", spec: "", wantNil: true},
+		{name: "single key", spec: "k1:" + k1, wantPrimary: "k1"},
+		{name: "first key is primary", spec: "k2:" + k2 + ", k1:" + k1, wantPrimary: "k2"},
+		{name: "missing separator", spec: k1, wantErr: true},
+		{name: "invalid base64", spec: "k1:not-base64!", wantErr: true},
+		{name: "short key", spec: "k1:" + base64.StdEncoding.EncodeToString([]byte("short")), wantErr: true},
+		{name: "duplicate key id", spec: "k1:" + k1 + ",k1:" + k2, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ring, err := ParseKeyring(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseKeyring() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if tt.wantNil {
+				if ring != nil {
+					t.Error("expected nil keyring")
+				}
+				return
+			}
+
+			if ring.PrimaryKeyID() != tt.wantPrimary {
+				t.Errorf("PrimaryKeyID() = %q, want %q", ring.PrimaryKeyID(), tt.wantPrimary)
+			}
+		})
+	}
+}
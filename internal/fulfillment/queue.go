@@ -0,0 +1,185 @@
+// Package fulfillment keeps the reservations of completed payments which couldn't be written, so they
+// are created later instead of leaving the customer without tickets.
+package fulfillment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	pendingKey = "fulfillments:pending"
+	// dueKey orders the payment IDs of the retried fulfillments by their next attempt
+	dueKey = "fulfillments:due"
+
+	minBackoff = 30 * time.Second
+	maxBackoff = time.Hour
+)
+
+// Pending is a reservation paid for but not stored yet.
+type Pending struct {
+	Reservation domain.Reservation `json:"reservation"`
+	CartID      string             `json:"cart_id"`
+	SessionID   string             `json:"session_id"`
+	Attempts    int                `json:"attempts"`
+	LastError   string             `json:"last_error"`
+	CreatedAt   time.Time          `json:"created_at"`
+	// NextAttemptAt is unset once retrying is given up, the fulfillment is stuck then
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+}
+
+func (p *Pending) Stuck() bool {
+	return p.NextAttemptAt == nil
+}
+
+// Failed records a failed attempt and schedules the next one, unless maxAttempts is reached.
+func (p *Pending) Failed(err error, now time.Time, maxAttempts int) {
+	p.Attempts++
+	p.LastError = err.Error()
+
+	if p.Attempts >= maxAttempts {
+		p.NextAttemptAt = nil
+		return
+	}
+
+	next := now.Add(Backoff(p.Attempts))
+	p.NextAttemptAt = &next
+}
+
+// Backoff is the wait after the given number of failed attempts, doubling from 30 seconds up to an hour.
+func Backoff(attempts int) time.Duration {
+	backoff := minBackoff
+	for i := 1; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, maxBackoff)
+}
+
+// Queue keeps pending fulfillments, one per payment.
+type Queue interface {
+	// Save adds the fulfillment or replaces the one of the same payment.
+	Save(ctx context.Context, pending Pending) error
+	// Due returns up to limit fulfillments whose next attempt is before now, the most overdue first.
+	Due(ctx context.Context, now time.Time, limit int) ([]Pending, error)
+	Remove(ctx context.Context, paymentID int) error
+	// List returns all pending fulfillments, stuck ones included, oldest first.
+	List(ctx context.Context) ([]Pending, error)
+}
+
+type RedisQueue struct {
+	client redis.UniversalClient
+}
+
+func NewRedisQueue(client redis.UniversalClient) *RedisQueue {
+	return &RedisQueue{
+		client: client,
+	}
+}
+
+func (q *RedisQueue) Save(ctx context.Context, pending Pending) error {
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return err
+	}
+
+	member := strconv.Itoa(pending.Reservation.PaymentID)
+
+	pipe := q.client.TxPipeline()
+	pipe.HSet(ctx, pendingKey, member, data)
+
+	if pending.Stuck() {
+		pipe.ZRem(ctx, dueKey, member)
+	} else {
+		pipe.ZAdd(ctx, dueKey, redis.Z{Score: float64(pending.NextAttemptAt.Unix()), Member: member})
+	}
+
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (q *RedisQueue) Due(ctx context.Context, now time.Time, limit int) ([]Pending, error) {
+	members, err := q.client.ZRangeByScore(ctx, dueKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.Unix(), 10),
+		Count: int64(limit),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(members) == 0 {
+		return nil, nil
+	}
+
+	values, err := q.client.HMGet(ctx, pendingKey, members...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	due := make([]Pending, 0, len(values))
+	for i, value := range values {
+		data, ok := value.(string)
+		// removed since the due set was read
+		if !ok {
+			continue
+		}
+
+		pending, err := decode(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode fulfillment of payment %s: %w", members[i], err)
+		}
+
+		due = append(due, pending)
+	}
+
+	return due, nil
+}
+
+func (q *RedisQueue) Remove(ctx context.Context, paymentID int) error {
+	member := strconv.Itoa(paymentID)
+
+	pipe := q.client.TxPipeline()
+	pipe.HDel(ctx, pendingKey, member)
+	pipe.ZRem(ctx, dueKey, member)
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (q *RedisQueue) List(ctx context.Context) ([]Pending, error) {
+	fields, err := q.client.HGetAll(ctx, pendingKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make([]Pending, 0, len(fields))
+	for paymentID, data := range fields {
+		p, err := decode(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode fulfillment of payment %s: %w", paymentID, err)
+		}
+
+		pending = append(pending, p)
+	}
+
+	slices.SortFunc(pending, func(a, b Pending) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return pending, nil
+}
+
+func decode(data string) (Pending, error) {
+	var pending Pending
+	err := json.Unmarshal([]byte(data), &pending)
+
+	return pending, err
+}
@@ -0,0 +1,47 @@
+package fulfillment
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: 30 * time.Second},
+		{attempts: 2, want: time.Minute},
+		{attempts: 4, want: 4 * time.Minute},
+		{attempts: 8, want: time.Hour},
+		{attempts: 50, want: time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestPendingFailed(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	p := Pending{}
+
+	p.Failed(errors.New("connection refused"), now, 2)
+
+	if p.Attempts != 1 || p.LastError != "connection refused" {
+		t.Errorf("expected the failed attempt to be recorded, got %+v", p)
+	}
+
+	if p.Stuck() || !p.NextAttemptAt.Equal(now.Add(30*time.Second)) {
+		t.Errorf("expected the next attempt in 30s, got %v", p.NextAttemptAt)
+	}
+
+	p.Failed(errors.New("connection refused"), now, 2)
+
+	if !p.Stuck() {
+		t.Errorf("expected retrying to be given up after the last attempt, next attempt at %v", p.NextAttemptAt)
+	}
+}
@@ -0,0 +1,85 @@
+package geocoding
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+// notFoundTTL is shorter than the TTL of resolved addresses, so that addresses which are added to the
+// provider's data later become searchable without waiting too long.
+const notFoundTTL = time.Hour
+
+// CachedGeocoder caches the results of another geocoder in Redis, including addresses that could not
+// be resolved. Cache failures are logged and the upstream provider is used instead.
+type CachedGeocoder struct {
+	next   domain.Geocoder
+	redis  redis.UniversalClient
+	ttl    time.Duration
+	logger *slog.Logger
+}
+
+func NewCachedGeocoder(next domain.Geocoder, redis redis.UniversalClient, ttl time.Duration, logger *slog.Logger) *CachedGeocoder {
+	return &CachedGeocoder{
+		next:   next,
+		redis:  redis,
+		ttl:    ttl,
+		logger: logger,
+	}
+}
+
+type cachedPoint struct {
+	Point *domain.GeoPoint `json:"point"`
+}
+
+func (c *CachedGeocoder) Geocode(ctx context.Context, address string) (*domain.GeoPoint, error) {
+	key := geocodeKey(address)
+
+	cached, err := c.redis.Get(ctx, key).Bytes()
+	if err == nil {
+		var entry cachedPoint
+
+		err = json.Unmarshal(cached, &entry)
+		if err == nil {
+			if entry.Point == nil {
+				return nil, domain.ErrAddressNotFound
+			}
+
+			return entry.Point, nil
+		}
+	}
+
+	if err != nil && !errors.Is(err, redis.Nil) {
+		c.logger.Warn("failed to read geocoding result from cache", "key", key, "error", err)
+	}
+
+	point, err := c.next.Geocode(ctx, address)
+	if err != nil && !errors.Is(err, domain.ErrAddressNotFound) {
+		return nil, err
+	}
+
+	ttl := c.ttl
+	if point == nil {
+		ttl = notFoundTTL
+	}
+
+	entryBytes, marshalErr := json.Marshal(cachedPoint{Point: point})
+	if marshalErr == nil {
+		if cacheErr := c.redis.Set(ctx, key, entryBytes, ttl).Err(); cacheErr != nil {
+			c.logger.Warn("failed to cache geocoding result", "key", key, "error", cacheErr)
+		}
+	}
+
+	return point, err
+}
+
+// geocodeKey normalizes case and whitespace so that trivially different spellings share an entry.
+func geocodeKey(address string) string {
+	return "geocode:" + strings.ToLower(strings.Join(strings.Fields(address), " "))
+}
@@ -0,0 +1,225 @@
+package geocoding
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNominatimGeocoder(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		wantPoint *domain.GeoPoint
+		wantErr   error
+	}{
+		{
+			name:      "resolves first result",
+			status:    http.StatusOK,
+			body:      `[{"lat":"40.9903","lon":"29.0290"},{"lat":"1","lon":"1"}]`,
+			wantPoint: &domain.GeoPoint{Latitude: 40.9903, Longitude: 29.0290},
+		},
+		{
+			name:    "no results",
+			status:  http.StatusOK,
+			body:    `[]`,
+			wantErr: domain.ErrAddressNotFound,
+		},
+		{
+			name:   "upstream failure",
+			status: http.StatusTooManyRequests,
+			body:   `{}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.URL.Query().Get("q"); got != "Kadikoy, Istanbul" {
+					t.Errorf("q = %q", got)
+				}
+				if got := r.Header.Get("User-Agent"); got != "cinex-test" {
+					t.Errorf("User-Agent = %q", got)
+				}
+
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			}))
+			defer srv.Close()
+
+			geocoder := NewNominatimGeocoder(srv.Client(), srv.URL, "cinex-test", 0, 0)
+
+			point, err := geocoder.Geocode(context.Background(), "Kadikoy, Istanbul")
+
+			checkGeocodeResult(t, point, err, tt.wantPoint, tt.wantErr)
+		})
+	}
+}
+
+func TestGoogleGeocoder(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantPoint *domain.GeoPoint
+		wantErr   error
+	}{
+		{
+			name:      "resolves first result",
+			body:      `{"status":"OK","results":[{"geometry":{"location":{"lat":40.99,"lng":29.03}}}]}`,
+			wantPoint: &domain.GeoPoint{Latitude: 40.99, Longitude: 29.03},
+		},
+		{
+			name:    "no results",
+			body:    `{"status":"ZERO_RESULTS","results":[]}`,
+			wantErr: domain.ErrAddressNotFound,
+		},
+		{
+			name: "request denied",
+			body: `{"status":"REQUEST_DENIED","error_message":"invalid key"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.URL.Query().Get("key"); got != "secret" {
+					t.Errorf("key = %q", got)
+				}
+
+				io.WriteString(w, tt.body)
+			}))
+			defer srv.Close()
+
+			geocoder := NewGoogleGeocoder(srv.Client(), srv.URL, "secret", 0, 0)
+
+			point, err := geocoder.Geocode(context.Background(), "Kadikoy")
+
+			checkGeocodeResult(t, point, err, tt.wantPoint, tt.wantErr)
+		})
+	}
+}
+
+type countingGeocoder struct {
+	calls int
+	point *domain.GeoPoint
+}
+
+func (c *countingGeocoder) Geocode(ctx context.Context, address string) (*domain.GeoPoint, error) {
+	c.calls++
+
+	if c.point == nil {
+		return nil, domain.ErrAddressNotFound
+	}
+
+	return c.point, nil
+}
+
+func TestCachedGeocoder(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("serves cached result", func(t *testing.T) {
+		redisClient := new(mocks.MockRedisClient)
+		redisClient.On("Get", mock.Anything, "geocode:kadikoy istanbul").
+			Return(redis.NewStringResult(`{"point":{"Latitude":1,"Longitude":2}}`, nil))
+
+		next := &countingGeocoder{}
+		geocoder := NewCachedGeocoder(next, redisClient, time.Hour, logger)
+
+		point, err := geocoder.Geocode(context.Background(), "  Kadikoy   Istanbul ")
+
+		checkGeocodeResult(t, point, err, &domain.GeoPoint{Latitude: 1, Longitude: 2}, nil)
+		if next.calls != 0 {
+			t.Errorf("upstream called %d times, want 0", next.calls)
+		}
+	})
+
+	t.Run("caches unresolvable addresses for a shorter time", func(t *testing.T) {
+		redisClient := new(mocks.MockRedisClient)
+		redisClient.On("Get", mock.Anything, "geocode:nowhere").Return(redis.NewStringResult("", redis.Nil))
+		redisClient.On("Set", mock.Anything, "geocode:nowhere", mock.Anything, notFoundTTL).
+			Return(redis.NewStatusResult("OK", nil))
+
+		geocoder := NewCachedGeocoder(&countingGeocoder{}, redisClient, time.Hour, logger)
+
+		point, err := geocoder.Geocode(context.Background(), "Nowhere")
+
+		checkGeocodeResult(t, point, err, nil, domain.ErrAddressNotFound)
+		redisClient.AssertExpectations(t)
+	})
+}
+
+func TestLimiterSpacesRequests(t *testing.T) {
+	l := newLimiter(20*time.Millisecond, 0)
+
+	start := time.Now()
+	for range 3 {
+		if err := l.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("three requests took %v, want at least 40ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	l.wait(context.Background())
+	if err := l.wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("wait() with cancelled context error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestLimiterTurnsAwayRequestsBeyondMaxWait(t *testing.T) {
+	l := newLimiter(time.Hour, 10*time.Millisecond)
+
+	if err := l.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+
+	if err := l.wait(context.Background()); !errors.Is(err, domain.ErrGeocoderBusy) {
+		t.Errorf("wait() error = %v, want %v", err, domain.ErrGeocoderBusy)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("turning away took %v, want it right away", elapsed)
+	}
+
+	// a turned away request doesn't take a turn
+	if l.next.Sub(start) > time.Hour {
+		t.Errorf("next turn is in %v, want at most an interval", l.next.Sub(start))
+	}
+}
+
+func checkGeocodeResult(t *testing.T, got *domain.GeoPoint, err error, want *domain.GeoPoint, wantErr error) {
+	t.Helper()
+
+	if want == nil && wantErr == nil {
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		return
+	}
+
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("Geocode() error = %v, want %v", err, wantErr)
+	}
+
+	if want != nil && (got == nil || *got != *want) {
+		t.Errorf("Geocode() = %v, want %v", got, want)
+	}
+}
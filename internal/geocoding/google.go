@@ -0,0 +1,94 @@
+package geocoding
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+const defaultGoogleURL = "https://maps.googleapis.com/maps/api/geocode/json"
+
+// GoogleGeocoder resolves addresses with the Google Geocoding API.
+type GoogleGeocoder struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+	limiter *limiter
+}
+
+func NewGoogleGeocoder(client *http.Client, baseURL, apiKey string, interval, maxWait time.Duration) *GoogleGeocoder {
+	if baseURL == "" {
+		baseURL = defaultGoogleURL
+	}
+
+	return &GoogleGeocoder{
+		client:  client,
+		baseURL: baseURL,
+		apiKey:  apiKey,
+		limiter: newLimiter(interval, maxWait),
+	}
+}
+
+func (g *GoogleGeocoder) Geocode(ctx context.Context, address string) (*domain.GeoPoint, error) {
+	if err := g.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("address", address)
+	query.Set("key", g.apiKey)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.baseURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("google geocoding responded with status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Status  string `json:"status"`
+		Results []struct {
+			Geometry struct {
+				Location struct {
+					Lat float64 `json:"lat"`
+					Lng float64 `json:"lng"`
+				} `json:"location"`
+			} `json:"geometry"`
+		} `json:"results"`
+		ErrorMessage string `json:"error_message"`
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch body.Status {
+	case "OK":
+	case "ZERO_RESULTS":
+		return nil, domain.ErrAddressNotFound
+	default:
+		return nil, fmt.Errorf("google geocoding failed with status %s: %s", body.Status, body.ErrorMessage)
+	}
+
+	if len(body.Results) == 0 {
+		return nil, domain.ErrAddressNotFound
+	}
+
+	location := body.Results[0].Geometry.Location
+
+	return &domain.GeoPoint{Latitude: location.Lat, Longitude: location.Lng}, nil
+}
@@ -0,0 +1,62 @@
+package geocoding
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+// limiter spaces out requests toward an upstream provider so that at most one request is started per
+// interval, as required by the usage policies of public geocoding services.
+type limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	// maxWait bounds how long a request waits for its turn, 0 means no bound
+	maxWait time.Duration
+	next    time.Time
+}
+
+func newLimiter(interval, maxWait time.Duration) *limiter {
+	return &limiter{interval: interval, maxWait: maxWait}
+}
+
+// wait blocks until the caller may send a request, or until the context is done. When the turn of the caller
+// is further away than maxWait, it returns domain.ErrGeocoderBusy right away without taking a turn, so a
+// burst of searches can't queue up requests for longer than callers wait.
+func (l *limiter) wait(ctx context.Context) error {
+	if l == nil || l.interval <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+
+	if l.maxWait > 0 && slot.Sub(now) > l.maxWait {
+		l.mu.Unlock()
+		return domain.ErrGeocoderBusy
+	}
+
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,96 @@
+package geocoding
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+const defaultNominatimURL = "https://nominatim.openstreetmap.org"
+
+// NominatimGeocoder resolves addresses with an OpenStreetMap Nominatim server. The public instance
+// allows one request per second and requires an identifying user agent.
+type NominatimGeocoder struct {
+	client    *http.Client
+	baseURL   string
+	userAgent string
+	limiter   *limiter
+}
+
+func NewNominatimGeocoder(
+	client *http.Client,
+	baseURL, userAgent string,
+	interval, maxWait time.Duration) *NominatimGeocoder {
+
+	if baseURL == "" {
+		baseURL = defaultNominatimURL
+	}
+
+	return &NominatimGeocoder{
+		client:    client,
+		baseURL:   baseURL,
+		userAgent: userAgent,
+		limiter:   newLimiter(interval, maxWait),
+	}
+}
+
+func (n *NominatimGeocoder) Geocode(ctx context.Context, address string) (*domain.GeoPoint, error) {
+	if err := n.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("q", address)
+	query.Set("format", "jsonv2")
+	query.Set("limit", "1")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, n.baseURL+"/search?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", n.userAgent)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("nominatim responded with status %d", resp.StatusCode)
+	}
+
+	var results []struct {
+		Lat string `json:"lat"`
+		Lon string `json:"lon"`
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&results)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results) == 0 {
+		return nil, domain.ErrAddressNotFound
+	}
+
+	lat, err := strconv.ParseFloat(results[0].Lat, 64)
+	if err != nil {
+		return nil, fmt.Errorf("nominatim returned invalid latitude: %w", err)
+	}
+
+	lon, err := strconv.ParseFloat(results[0].Lon, 64)
+	if err != nil {
+		return nil, fmt.Errorf("nominatim returned invalid longitude: %w", err)
+	}
+
+	return &domain.GeoPoint{Latitude: lat, Longitude: lon}, nil
+}
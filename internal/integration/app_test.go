@@ -11,6 +11,7 @@ import (
 	"github.com/metinatakli/movie-reservation-system/internal/payment"
 	"github.com/metinatakli/movie-reservation-system/internal/repository"
 	appvalidator "github.com/metinatakli/movie-reservation-system/internal/validator"
+	"github.com/metinatakli/movie-reservation-system/internal/walletpass"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -25,10 +26,10 @@ type TestApp struct {
 
 func newTestApp(cfg app.Config) (*TestApp, error) {
 	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
-	validator := appvalidator.NewValidator()
+	validator := appvalidator.NewValidator(cfg.SchedulingHorizon, cfg.PasswordPolicy)
 	mailer := mailer.NewMockMailer()
 
-	db, err := app.NewDatabasePool(cfg)
+	db, err := app.NewDatabasePool(cfg, logger)
 	if err != nil {
 		return nil, err
 	}
@@ -39,22 +40,36 @@ func newTestApp(cfg app.Config) (*TestApp, error) {
 		return nil, err
 	}
 
-	sessionManager := app.NewSessionManager(redisClient)
+	sessionManager, err := app.NewSessionManager(redisClient, cfg.Session)
+	if err != nil {
+		db.Close()
+		redisClient.Close()
+		return nil, err
+	}
 
-	userRepo := repository.NewPostgresUserRepository(db)
+	userRepo := repository.NewPostgresUserRepository(db, nil)
 	tokenRepo := repository.NewPostgresTokenRepository(db)
 	movieRepo := repository.NewPostgresMovieRepository(db)
 	theaterRepo := repository.NewPostgresTheaterRepository(db)
 	seatRepo := repository.NewPostgresSeatRepository(db)
 	paymentRepo := repository.NewPostgresPaymentRepository(db)
 	reservationRepo := repository.NewPostgresReservationRepository(db)
+	announcementRepo := repository.NewPostgresAnnouncementRepository(db)
+	searchRepo := repository.NewPostgresSearchRepository(db)
+	disputeRepo := repository.NewPostgresDisputeRepository(db)
+	deviceSessionRepo := repository.NewPostgresDeviceSessionRepository(db)
+	analyticsEventRepo := repository.NewPostgresAnalyticsEventRepository(db)
+	webhookEventRepo := repository.NewPostgresWebhookEventRepository(db)
+	emailCampaignRepo := repository.NewPostgresEmailCampaignRepository(db)
+	lifecycleEventRepo := repository.NewPostgresLifecycleEventRepository(db)
+	blockHoldRepo := repository.NewPostgresBlockHoldRepository(db)
 
 	paymentProvider := payment.NewMockPaymentProvider()
 
 	application := app.NewApp(
 		cfg,
 		logger,
-		db,
+		app.NewResizablePool(db),
 		redisClient,
 		validator,
 		mailer,
@@ -66,7 +81,20 @@ func newTestApp(cfg app.Config) (*TestApp, error) {
 		seatRepo,
 		paymentRepo,
 		reservationRepo,
+		announcementRepo,
+		searchRepo,
+		disputeRepo,
+		deviceSessionRepo,
+		analyticsEventRepo,
+		webhookEventRepo,
+		emailCampaignRepo,
+		lifecycleEventRepo,
+		blockHoldRepo,
+		nil,
 		paymentProvider,
+		nil,
+		walletpass.NewIssuer(nil, nil),
+		nil,
 	)
 
 	return &TestApp{
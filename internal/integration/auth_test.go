@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mailer"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
@@ -167,10 +168,10 @@ func (s *AuthTestSuite) TestRegisterUser() {
 				require.Equal(t, TestUserEmail, email.Recipient)
 				require.Equal(t, "user_welcome.tmpl", email.TemplateFile)
 
-				data, ok := email.Data.(map[string]any)
+				data, ok := email.Data.(mailer.WelcomeEmail)
 				require.True(t, ok)
-				require.Equal(t, user.ID, data["userID"])
-				require.NotEmpty(t, data["activationToken"])
+				require.Equal(t, user.ID, data.UserID)
+				require.NotEmpty(t, data.ActivationToken)
 			},
 		},
 	}
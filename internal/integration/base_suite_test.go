@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/metinatakli/movie-reservation-system/internal/app"
+	appvalidator "github.com/metinatakli/movie-reservation-system/internal/validator"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
@@ -58,6 +59,25 @@ func TestMain(m *testing.M) {
 			MaxIdleConns: 10,
 			MaxIdleTime:  2 * time.Minute,
 		},
+		Session: app.SessionConfig{
+			IdleTimeout:        20 * time.Minute,
+			Lifetime:           12 * time.Hour,
+			RememberMeLifetime: 30 * 24 * time.Hour,
+			ReauthWindow:       15 * time.Minute,
+		},
+		Tokens: app.TokensConfig{
+			AccessTTL:  15 * time.Minute,
+			RefreshTTL: 60 * 24 * time.Hour,
+		},
+		// the showtimes of the fixtures are in 2095
+		SchedulingHorizon: 100 * 365 * 24 * time.Hour,
+		PasswordPolicy:    appvalidator.DefaultPasswordPolicy(),
+		Analytics: app.AnalyticsConfig{
+			SampleRate:    1,
+			BufferSize:    100,
+			BatchSize:     10,
+			FlushInterval: time.Second,
+		},
 	}
 
 	testApp, err = newTestApp(cfg)
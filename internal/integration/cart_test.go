@@ -121,7 +121,7 @@ func (s *CartTestSuite) TestCreateCartHandler() {
 					"movieName": "Movie 1",
 					"theaterName": "Test Theater 1",
 					"hallName": "Hall 1A",
-					"showtimeDate": "Sat, 01 Jan 2095 13:00:00 +03",
+					"showtimeDate": "Sat, 01 Jan 2095 10:00:00 UTC",
 					"seats": [
 						{"id": 1, "row": 1, "column": 1, "type": "Standard", "price": "0"},
 						{"id": 4, "row": 2, "column": 2, "type": "Recliner", "price": "8.49"}
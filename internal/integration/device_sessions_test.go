@@ -0,0 +1,76 @@
+package integration_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/repository"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type DeviceSessionTestSuite struct {
+	BaseSuite
+}
+
+func TestDeviceSessionSuite(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	suite.Run(t, new(DeviceSessionTestSuite))
+}
+
+func (s *DeviceSessionTestSuite) TestRotateRevokesReusedSession() {
+	t := s.T()
+	ctx := context.Background()
+
+	truncateUsersAndTokens(t, s.app.DB)
+
+	user := defaultTestUser()
+	user.Activated = true
+	userID := insertTestUser(t, s.app.DB, user)
+
+	repo := repository.NewPostgresDeviceSessionRepository(s.app.DB)
+
+	_, err := repo.Create(ctx, &domain.DeviceSession{
+		UserID:           userID,
+		DeviceID:         "device-1",
+		DeviceName:       "Phone",
+		RefreshTokenHash: sha256Sum("refresh-1"),
+		AccessTokenHash:  sha256Sum("access-1"),
+		ExpiresAt:        time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	_, err = repo.Rotate(ctx, sha256Sum("refresh-1"), &domain.DeviceSession{
+		RefreshTokenHash: sha256Sum("refresh-2"),
+		AccessTokenHash:  sha256Sum("access-2"),
+		ExpiresAt:        time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	// presenting the rotated token again means it was stolen
+	reused, err := repo.Rotate(ctx, sha256Sum("refresh-1"), &domain.DeviceSession{
+		RefreshTokenHash: sha256Sum("refresh-3"),
+		AccessTokenHash:  sha256Sum("access-3"),
+		ExpiresAt:        time.Now().Add(time.Hour),
+	})
+	require.ErrorIs(t, err, domain.ErrRefreshTokenReused)
+	require.NotNil(t, reused)
+	require.Equal(t, sha256Sum("access-2"), reused.AccessTokenHash)
+
+	var count int
+	err = s.app.DB.QueryRow(ctx, "SELECT COUNT(*) FROM device_sessions WHERE user_id = $1", userID).Scan(&count)
+	require.NoError(t, err)
+	require.Zero(t, count, "the reused session should stay deleted")
+
+	_, err = repo.Rotate(ctx, sha256Sum("refresh-2"), &domain.DeviceSession{
+		RefreshTokenHash: sha256Sum("refresh-4"),
+		AccessTokenHash:  sha256Sum("access-4"),
+		ExpiresAt:        time.Now().Add(time.Hour),
+	})
+	require.ErrorIs(t, err, domain.ErrRecordNotFound, "the latest token of the session should be revoked too")
+}
@@ -0,0 +1,78 @@
+package integration_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AuthTestSuite) TestRequestMagicLink() {
+	scenarios := []Scenario{
+		{
+			Name:           "sends a login link to an activated user",
+			Method:         "POST",
+			URL:            "/sessions/magic-link",
+			Body:           strings.NewReader(fmt.Sprintf(`{"email": "%s"}`, TestUserEmail)),
+			ExpectedStatus: 202,
+			BeforeTestFunc: func(t testing.TB, app *TestApp) {
+				truncateUsersAndTokens(t, app.DB)
+				flushAllCache(t, app.RedisClient)
+
+				user := defaultTestUser()
+				user.Activated = true
+				insertTestUser(t, app.DB, user)
+
+				app.Mailer.Reset()
+			},
+			AfterTestFunc: func(t testing.TB, app *TestApp, res *http.Response) {
+				// the user is looked up and the link sent in the background
+				require.Eventually(t, func() bool {
+					return len(app.Mailer.GetSentEmails()) == 1
+				}, 5*time.Second, 50*time.Millisecond, "should send a login link")
+
+				email := app.Mailer.GetSentEmails()[0]
+				require.Equal(t, TestUserEmail, email.Recipient)
+				require.Equal(t, "magic_link.tmpl", email.TemplateFile)
+
+				var tokenCount int
+				err := app.DB.QueryRow(
+					context.Background(),
+					"SELECT COUNT(*) FROM tokens WHERE user_id IN (SELECT id FROM users WHERE email = $1) AND scope = $2",
+					TestUserEmail,
+					domain.MagicLinkScope,
+				).Scan(&tokenCount)
+				require.NoError(t, err)
+				require.Equal(t, 1, tokenCount, "should store the token of the link")
+			},
+		},
+		{
+			Name:           "sends no link to an unactivated user",
+			Method:         "POST",
+			URL:            "/sessions/magic-link",
+			Body:           strings.NewReader(fmt.Sprintf(`{"email": "%s"}`, TestUserEmail)),
+			ExpectedStatus: 202,
+			BeforeTestFunc: func(t testing.TB, app *TestApp) {
+				truncateUsersAndTokens(t, app.DB)
+				flushAllCache(t, app.RedisClient)
+				insertTestUser(t, app.DB, defaultTestUser())
+
+				app.Mailer.Reset()
+			},
+			AfterTestFunc: func(t testing.TB, app *TestApp, res *http.Response) {
+				require.Never(t, func() bool {
+					return len(app.Mailer.GetSentEmails()) > 0
+				}, time.Second, 50*time.Millisecond, "should not send any emails")
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		scenario.Run(s.T(), s.app)
+	}
+}
@@ -1,6 +1,7 @@
 package integration_test
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -33,7 +34,12 @@ func (s *MovieTestSuite) TestGetMovies() {
 					"firstPage": 1,
 					"lastPage": 0,
 					"pageSize": 10,
-					"totalRecords": 0
+					"totalRecords": 0,
+					"links": {
+						"self": "/movies?page=1&pageSize=10",
+						"first": "/movies?page=1&pageSize=10",
+						"last": "/movies?page=1&pageSize=10"
+					}
 				}
 			}`,
 			BeforeTestFunc: func(t testing.TB, app *TestApp) {
@@ -68,7 +74,14 @@ func (s *MovieTestSuite) TestGetMovies() {
 					"firstPage": 1,
 					"lastPage": 3,
 					"pageSize": 3,
-					"totalRecords": 7
+					"totalRecords": 7,
+					"links": {
+						"self": "/movies?page=2&pageSize=3",
+						"first": "/movies?page=1&pageSize=3",
+						"last": "/movies?page=3&pageSize=3",
+						"next": "/movies?page=3&pageSize=3",
+						"prev": "/movies?page=1&pageSize=3"
+					}
 				}
 			}`,
 			BeforeTestFunc: func(t testing.TB, app *TestApp) {
@@ -92,7 +105,13 @@ func (s *MovieTestSuite) TestGetMovies() {
 					"firstPage": 1,
 					"lastPage": 3,
 					"pageSize": 3,
-					"totalRecords": 7
+					"totalRecords": 7,
+					"links": {
+						"self": "/movies?page=1&pageSize=3&sort=-release_date",
+						"first": "/movies?page=1&pageSize=3&sort=-release_date",
+						"last": "/movies?page=3&pageSize=3&sort=-release_date",
+						"next": "/movies?page=2&pageSize=3&sort=-release_date"
+					}
 				}
 			}`,
 			BeforeTestFunc: func(t testing.TB, app *TestApp) {
@@ -153,6 +172,24 @@ func (s *MovieTestSuite) TestShowMovieDetails() {
 				executeSQLFile(t, app.DB, "testdata/movies_up.sql")
 			},
 		},
+		{
+			Name:           "returns 404 when movie is archived",
+			Method:         "GET",
+			URL:            "/movies/1",
+			ExpectedStatus: 404,
+			ExpectedResponse: `{
+				"message": "The requested resource not found"
+			}`,
+			BeforeTestFunc: func(t testing.TB, app *TestApp) {
+				executeSQLFile(t, app.DB, "testdata/movies_down.sql")
+				executeSQLFile(t, app.DB, "testdata/movies_up.sql")
+
+				_, err := app.DB.Exec(context.Background(), "UPDATE movies SET archived_at = NOW() WHERE id = 1")
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
 	}
 
 	for _, scenario := range scenarios {
@@ -208,6 +245,7 @@ func (s *MovieTestSuite) TestGetMovieShowtimes() {
 						"address": "123 Main St",
 						"city": "Test City",
 						"district": "Central",
+						"timeZone": "UTC",
 						"distance": 0,
 						"amenities": [],
 						"halls": [
@@ -241,6 +279,7 @@ func (s *MovieTestSuite) TestGetMovieShowtimes() {
 						"address": "456 Side St",
 						"city": "Test City",
 						"district": "North",
+						"timeZone": "UTC",
 						"distance": 0,
 						"amenities": [],
 						"halls": [
@@ -274,7 +313,12 @@ func (s *MovieTestSuite) TestGetMovieShowtimes() {
 					"firstPage": 1,
 					"lastPage": 1,
 					"pageSize": 10,
-					"totalRecords": 2
+					"totalRecords": 2,
+					"links": {
+						"self": "/movies/1/showtimes?date=2095-01-01&latitude=40.0&longitude=30.0&page=1&pageSize=10",
+						"first": "/movies/1/showtimes?date=2095-01-01&latitude=40.0&longitude=30.0&page=1&pageSize=10",
+						"last": "/movies/1/showtimes?date=2095-01-01&latitude=40.0&longitude=30.0&page=1&pageSize=10"
+					}
 				}
 			}`,
 			BeforeTestFunc: func(t testing.TB, app *TestApp) {
@@ -57,7 +57,12 @@ func (s *ReservationTestSuite) TestGetReservationsOfUserHandler() {
 					"firstPage": 1,
 					"lastPage": 0,
 					"pageSize": 10,
-					"totalRecords": 0
+					"totalRecords": 0,
+					"links": {
+						"self": "/users/me/reservations?page=1&pageSize=10",
+						"first": "/users/me/reservations?page=1&pageSize=10",
+						"last": "/users/me/reservations?page=1&pageSize=10"
+					}
 				}
 			}`,
 			BeforeTestFunc: func(t testing.TB, app *TestApp) {
@@ -72,16 +77,23 @@ func (s *ReservationTestSuite) TestGetReservationsOfUserHandler() {
 			ExpectedStatus: http.StatusOK,
 			ExpectedResponse: `{
 				"reservations": [
-					{ "id": 4, "movieTitle": "Movie 1", "moviePosterUrl": "https://example.com/poster1.jpg", "hallName": "Hall 1B", "theaterName": "Test Theater 1", "date": "2095-01-01T17:00:00+03:00" },
-					{ "id": 5, "movieTitle": "Movie 1", "moviePosterUrl": "https://example.com/poster1.jpg", "hallName": "Hall 2A", "theaterName": "Test Theater 2", "date": "2095-01-01T13:00:00+03:00" },
-					{ "id": 6, "movieTitle": "Movie 1", "moviePosterUrl": "https://example.com/poster1.jpg", "hallName": "Hall 2A", "theaterName": "Test Theater 2", "date": "2095-01-01T17:00:00+03:00" }
+					{ "id": 4, "movieTitle": "Movie 1", "moviePosterUrl": "https://example.com/poster1.jpg", "hallName": "Hall 1B", "theaterName": "Test Theater 1", "date": "2095-01-01T14:00:00Z" },
+					{ "id": 5, "movieTitle": "Movie 1", "moviePosterUrl": "https://example.com/poster1.jpg", "hallName": "Hall 2A", "theaterName": "Test Theater 2", "date": "2095-01-01T10:00:00Z" },
+					{ "id": 6, "movieTitle": "Movie 1", "moviePosterUrl": "https://example.com/poster1.jpg", "hallName": "Hall 2A", "theaterName": "Test Theater 2", "date": "2095-01-01T14:00:00Z" }
 				],
 				"metadata": {
 					"currentPage": 2,
 					"firstPage": 1,
 					"lastPage": 3,
 					"pageSize": 3,
-					"totalRecords": 7
+					"totalRecords": 7,
+					"links": {
+						"self": "/users/me/reservations?page=2&pageSize=3",
+						"first": "/users/me/reservations?page=1&pageSize=3",
+						"last": "/users/me/reservations?page=3&pageSize=3",
+						"next": "/users/me/reservations?page=3&pageSize=3",
+						"prev": "/users/me/reservations?page=1&pageSize=3"
+					}
 				}
 			}`,
 			BeforeTestFunc: func(t testing.TB, app *TestApp) {
@@ -98,14 +110,20 @@ func (s *ReservationTestSuite) TestGetReservationsOfUserHandler() {
 			ExpectedStatus: http.StatusOK,
 			ExpectedResponse: `{
 				"reservations": [
-					{ "id": 7, "movieTitle": "Movie 1", "moviePosterUrl": "https://example.com/poster1.jpg", "hallName": "Hall 2B", "theaterName": "Test Theater 2", "date": "2095-01-01T13:00:00+03:00" }
+					{ "id": 7, "movieTitle": "Movie 1", "moviePosterUrl": "https://example.com/poster1.jpg", "hallName": "Hall 2B", "theaterName": "Test Theater 2", "date": "2095-01-01T10:00:00Z" }
 				],
 				"metadata": {
 					"currentPage": 3,
 					"firstPage": 1,
 					"lastPage": 3,
 					"pageSize": 3,
-					"totalRecords": 7
+					"totalRecords": 7,
+					"links": {
+						"self": "/users/me/reservations?page=3&pageSize=3",
+						"first": "/users/me/reservations?page=1&pageSize=3",
+						"last": "/users/me/reservations?page=3&pageSize=3",
+						"prev": "/users/me/reservations?page=2&pageSize=3"
+					}
 				}
 			}`,
 			BeforeTestFunc: func(t testing.TB, app *TestApp) {
@@ -175,7 +193,7 @@ func (s *ReservationTestSuite) TestGetUserReservationById() {
 				"theaterName": "Grand Cinema",
 				"hallName": "Hall A",
 				"totalPrice": "25",
-				"date": "2095-05-10T23:00:00+03:00",
+				"date": "2095-05-10T20:00:00Z",
 				"seats": [
 					{"row": 3, "column": 5, "type": "Standard"},
 					{"row": 3, "column": 6, "type": "Standard"}
@@ -206,7 +224,7 @@ func (s *ReservationTestSuite) TestGetUserReservationById() {
 				"theaterName": "Grand Cinema",
 				"hallName": "Hall A",
 				"totalPrice": "25",
-				"date": "2095-05-10T23:00:00+03:00",
+				"date": "2095-05-10T20:00:00Z",
 				"seats": [
 					{"row": 3, "column": 5, "type": "Standard"},
 					{"row": 3, "column": 6, "type": "Standard"}
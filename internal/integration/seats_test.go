@@ -49,6 +49,7 @@ func (s *SeatMapTestSuite) TestGetSeatMapByShowtime() {
 				"theaterName": "Test Theater 1",
 				"hallId": 1,
 				"showtimeId": 1,
+				"version": 0,
 				"seatRows": [
 					{
 						"row": 1,
@@ -80,6 +81,7 @@ func (s *SeatMapTestSuite) TestGetSeatMapByShowtime() {
 				"theaterName": "Test Theater 1",
 				"hallId": 1,
 				"showtimeId": 1,
+				"version": 0,
 				"seatRows": [
 					{
 						"row": 1,
@@ -112,6 +114,7 @@ func (s *SeatMapTestSuite) TestGetSeatMapByShowtime() {
 				"theaterName": "Test Theater 1",
 				"hallId": 1,
 				"showtimeId": 1,
+				"version": 0,
 				"seatRows": [
 					{
 						"row": 1,
@@ -144,6 +147,7 @@ func (s *SeatMapTestSuite) TestGetSeatMapByShowtime() {
 				"theaterName": "Test Theater 1",
 				"hallId": 1,
 				"showtimeId": 1,
+				"version": 0,
 				"seatRows": [
 					{
 						"row": 1,
@@ -0,0 +1,205 @@
+package integration_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/payment"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/suite"
+	"github.com/stripe/stripe-go/v82"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const (
+	stripeMockImageName = "stripe/stripe-mock:latest"
+	stripeMockPort      = "12111/tcp"
+	stripeContractKey   = "sk_test_123"
+	contractSuccessURL  = "http://localhost:3000/payments/success"
+	contractFailureURL  = "http://localhost:3000/payments/failure"
+)
+
+// recordedRequest is a request sent to Stripe, with the form encoded body decoded.
+type recordedRequest struct {
+	Method string
+	Path   string
+	Form   url.Values
+}
+
+// recordingTransport keeps the requests sent to Stripe before forwarding them, so the parameters the
+// provider sends can be checked and not only that stripe-mock accepted them.
+type recordingTransport struct {
+	mu       sync.Mutex
+	requests []recordedRequest
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+
+	if req.Body != nil {
+		var err error
+
+		body, err = io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	t.requests = append(t.requests, recordedRequest{Method: req.Method, Path: req.URL.Path, Form: form})
+	t.mu.Unlock()
+
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func (t *recordingTransport) last() (recordedRequest, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.requests) == 0 {
+		return recordedRequest{}, false
+	}
+
+	return t.requests[len(t.requests)-1], true
+}
+
+// StripeContractTestSuite runs the Stripe payment provider against stripe-mock, which validates the
+// requests against Stripe's OpenAPI spec. A breaking change of the API or of the stripe-go version
+// fails here instead of in production.
+type StripeContractTestSuite struct {
+	suite.Suite
+	container testcontainers.Container
+	transport *recordingTransport
+	provider  *payment.StripePaymentProvider
+}
+
+func TestStripeContractSuite(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	suite.Run(t, new(StripeContractTestSuite))
+}
+
+func (s *StripeContractTestSuite) SetupSuite() {
+	ctx := context.Background()
+
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        stripeMockImageName,
+			ExposedPorts: []string{stripeMockPort},
+			WaitingFor:   wait.ForListeningPort(stripeMockPort).WithStartupTimeout(60 * time.Second),
+		},
+		Started: true,
+	})
+	s.Require().NoError(err, "failed to start stripe-mock container")
+	s.container = container
+
+	host, err := container.Host(ctx)
+	s.Require().NoError(err)
+
+	port, err := container.MappedPort(ctx, stripeMockPort)
+	s.Require().NoError(err)
+
+	s.transport = &recordingTransport{}
+
+	backend := stripe.GetBackendWithConfig(stripe.APIBackend, &stripe.BackendConfig{
+		URL:               stripe.String(fmt.Sprintf("http://%s:%s", host, port.Port())),
+		HTTPClient:        &http.Client{Transport: s.transport},
+		MaxNetworkRetries: stripe.Int64(0),
+	})
+
+	s.provider = payment.NewStripePaymentProvider(contractFailureURL, contractSuccessURL).
+		WithBackend(backend, stripeContractKey)
+}
+
+func (s *StripeContractTestSuite) TearDownSuite() {
+	if s.container != nil {
+		s.container.Terminate(context.Background())
+	}
+}
+
+func (s *StripeContractTestSuite) TestCreateCheckoutSession() {
+	user := &domain.User{ID: TestUserId, Email: TestUserEmail}
+	cart := domain.Cart{
+		Id:              "4f1b4c3e-3a4e-4d7e-9a53-0c6f3f4b2a11",
+		ShowtimeID:      1,
+		BasePrice:       decimal.RequireFromString("10.50"),
+		Format:          domain.FormatIMAX,
+		FormatSurcharge: decimal.RequireFromString("5"),
+		MovieName:       "Inception",
+		TheaterName:     "Cinema City",
+		HallName:        "Hall 1",
+		Date:            time.Date(2030, 1, 1, 20, 0, 0, 0, time.UTC),
+		Seats: []domain.CartSeat{
+			{Id: 1, Row: 1, Col: 1, SeatType: "Standard", ExtraPrice: decimal.Zero},
+			{Id: 2, Row: 1, Col: 2, SeatType: "VIP", ExtraPrice: decimal.RequireFromString("4.25")},
+		},
+	}
+	pmt := domain.Payment{ID: 42}
+	expiresAt := time.Now().Add(45 * time.Minute)
+
+	ctx := context.WithValue(context.Background(), middleware.RequestIDKey, "contract-test-request")
+
+	checkoutSession, err := s.provider.CreateCheckoutSession(ctx, "session-123", user, cart, pmt, expiresAt)
+	s.Require().NoError(err, "stripe-mock rejected the checkout session")
+	s.NotEmpty(checkoutSession.ID)
+
+	req, ok := s.transport.last()
+	s.Require().True(ok, "no request was sent to stripe-mock")
+
+	s.Equal(http.MethodPost, req.Method)
+	s.Equal("/v1/checkout/sessions", req.Path)
+
+	s.Equal(string(stripe.CheckoutSessionModePayment), req.Form.Get("mode"))
+	s.Equal(contractSuccessURL, req.Form.Get("success_url"))
+	s.Equal(contractFailureURL, req.Form.Get("cancel_url"))
+	s.Equal(TestUserEmail, req.Form.Get("customer_email"))
+	s.Equal(strconv.Itoa(TestUserId), req.Form.Get("client_reference_id"))
+	s.Equal(strconv.FormatInt(expiresAt.Unix(), 10), req.Form.Get("expires_at"))
+
+	// the webhook finds the cart, session and payment of a completed checkout through the metadata
+	expectedMetadata := map[string]string{
+		domain.CheckoutMetadataCartID:    cart.Id,
+		domain.CheckoutMetadataSessionID: "session-123",
+		domain.CheckoutMetadataUserID:    strconv.Itoa(TestUserId),
+		domain.CheckoutMetadataPaymentID: strconv.Itoa(pmt.ID),
+		domain.CheckoutMetadataRequestID: "contract-test-request",
+	}
+
+	for key, value := range expectedMetadata {
+		s.Equal(value, req.Form.Get(fmt.Sprintf("metadata[%s]", key)), "metadata %s", key)
+	}
+
+	breakdown := cart.PriceBreakdown()
+
+	for i, seat := range breakdown.Seats {
+		prefix := fmt.Sprintf("line_items[%d]", i)
+		expectedAmount := seat.Price.Mul(decimal.NewFromInt(100)).IntPart()
+
+		s.Equal(strconv.FormatInt(expectedAmount, 10), req.Form.Get(prefix+"[price_data][unit_amount]"))
+		s.Equal(string(stripe.CurrencyUSD), req.Form.Get(prefix+"[price_data][currency]"))
+		s.Equal("1", req.Form.Get(prefix+"[quantity]"))
+	}
+
+	s.Empty(req.Form.Get(fmt.Sprintf("line_items[%d][quantity]", len(breakdown.Seats))))
+}
@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/mailer"
+	"github.com/metinatakli/movie-reservation-system/internal/repository"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
@@ -268,10 +270,10 @@ func (s *UserTestSuite) TestInitiateUserDeletion() {
 				require.Equal(t, TestUserEmail, email.Recipient)
 				require.Equal(t, "user_deletion.tmpl", email.TemplateFile)
 
-				data, ok := email.Data.(map[string]any)
+				data, ok := email.Data.(mailer.DeletionEmail)
 				require.True(t, ok)
-				require.Equal(t, 1, data["userID"])
-				require.NotEmpty(t, data["deletionToken"])
+				require.Equal(t, 1, data.UserID)
+				require.NotEmpty(t, data.DeletionToken)
 			},
 		},
 	}
@@ -394,3 +396,37 @@ func (s *UserTestSuite) TestCompleteUserDeletion() {
 		scenario.Run(s.T(), s.app)
 	}
 }
+
+func (s *UserTestSuite) TestDeleteUnactivatedCreatedBefore() {
+	t := s.T()
+	ctx := context.Background()
+
+	truncateUsersAndTokens(t, s.app.DB)
+
+	unused := defaultTestUser()
+	unusedID := insertTestUser(t, s.app.DB, unused)
+
+	booked := defaultTestUser()
+	booked.Email = "booked@example.com"
+	bookedID := insertTestUser(t, s.app.DB, booked)
+
+	// an unactivated account which already paid, e.g. of a booking made by phone
+	_, err := s.app.DB.Exec(ctx,
+		`INSERT INTO payments (user_id, stripe_checkout_session_id, amount, status) VALUES ($1, 'cs_test_booked', 10, 'completed')`,
+		bookedID)
+	require.NoError(t, err)
+
+	insertTestToken(t, s.app.DB, defaultTestToken(unusedID, domain.UserActivationScope))
+
+	repo := repository.NewPostgresUserRepository(s.app.DB, nil)
+
+	deleted, err := repo.DeleteUnactivatedCreatedBefore(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.EqualValues(t, 1, deleted)
+
+	_, err = repo.GetById(ctx, unusedID)
+	require.ErrorIs(t, err, domain.ErrRecordNotFound)
+
+	_, err = repo.GetById(ctx, bookedID)
+	require.NoError(t, err, "the account with a payment should be kept")
+}
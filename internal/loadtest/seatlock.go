@@ -0,0 +1,170 @@
+// Package loadtest simulates many sessions competing for the seats of a showtime. It is used by the
+// soak tests and benchmarks of the seat locking, which run against a real Redis.
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+// SeatLocker takes and releases the seat locks of a session. Lock returns domain.ErrSeatAlreadyReserved
+// when any of the seats is held by another session.
+type SeatLocker interface {
+	Lock(ctx context.Context, sessionID string, seatIDs []int) error
+	Release(ctx context.Context, sessionID string, seatIDs []int) error
+}
+
+type Config struct {
+	Sessions int
+	// AttemptsPerSession is how many carts every session tries to create
+	AttemptsPerSession int
+	Seats              int
+	SeatsPerCart       int
+	// HoldTime is how long a session keeps the seats it locked before releasing them
+	HoldTime time.Duration
+}
+
+type Report struct {
+	Attempts  int64
+	Locked    int64
+	Conflicts int64
+	Errors    int64
+	// DoubleSells counts the seats granted to a session while another session held them
+	DoubleSells int64
+	Duration    time.Duration
+}
+
+// ConflictRatio is the share of the attempts rejected because a seat was already locked.
+func (r Report) ConflictRatio() float64 {
+	if r.Attempts == 0 {
+		return 0
+	}
+
+	return float64(r.Conflicts) / float64(r.Attempts)
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf(
+		"%d attempts in %s: %d locked, %d conflicts (%.1f%%), %d errors, %d double sells",
+		r.Attempts, r.Duration.Round(time.Millisecond), r.Locked, r.Conflicts, r.ConflictRatio()*100,
+		r.Errors, r.DoubleSells,
+	)
+}
+
+// Run starts the sessions concurrently and waits for all of them to finish. Every attempt picks
+// SeatsPerCart distinct random seats out of Seats.
+func Run(ctx context.Context, locker SeatLocker, cfg Config) Report {
+	var report Report
+	ledger := newLedger()
+
+	var wg sync.WaitGroup
+	start := time.Now()
+
+	for i := range cfg.Sessions {
+		sessionID := fmt.Sprintf("loadtest-session-%d", i)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for range cfg.AttemptsPerSession {
+				if ctx.Err() != nil {
+					return
+				}
+
+				attempt(ctx, locker, cfg, sessionID, ledger, &report)
+			}
+		}()
+	}
+
+	wg.Wait()
+	report.Duration = time.Since(start)
+
+	return report
+}
+
+func attempt(ctx context.Context, locker SeatLocker, cfg Config, sessionID string, ledger *ledger, report *Report) {
+	atomic.AddInt64(&report.Attempts, 1)
+
+	seatIDs := pickSeats(cfg.Seats, cfg.SeatsPerCart)
+
+	err := locker.Lock(ctx, sessionID, seatIDs)
+	if err != nil {
+		if errors.Is(err, domain.ErrSeatAlreadyReserved) {
+			atomic.AddInt64(&report.Conflicts, 1)
+		} else {
+			atomic.AddInt64(&report.Errors, 1)
+		}
+
+		return
+	}
+
+	atomic.AddInt64(&report.Locked, 1)
+	atomic.AddInt64(&report.DoubleSells, int64(ledger.take(sessionID, seatIDs)))
+
+	if cfg.HoldTime > 0 {
+		time.Sleep(cfg.HoldTime)
+	}
+
+	// the ledger is cleared first, the seats may be granted to another session as soon as they're released
+	ledger.release(seatIDs)
+
+	err = locker.Release(ctx, sessionID, seatIDs)
+	if err != nil {
+		atomic.AddInt64(&report.Errors, 1)
+	}
+}
+
+// pickSeats returns n distinct seat IDs between 1 and seats.
+func pickSeats(seats, n int) []int {
+	seatIDs := rand.Perm(seats)[:n]
+	for i := range seatIDs {
+		seatIDs[i]++
+	}
+
+	return seatIDs
+}
+
+// ledger records which session holds each seat according to the locker.
+type ledger struct {
+	mu     sync.Mutex
+	owners map[int]string
+}
+
+func newLedger() *ledger {
+	return &ledger{owners: make(map[int]string)}
+}
+
+// take records the seats as held by the session and returns how many of them another session held.
+func (l *ledger) take(sessionID string, seatIDs []int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	doubleSells := 0
+
+	for _, seatID := range seatIDs {
+		if owner, held := l.owners[seatID]; held && owner != sessionID {
+			doubleSells++
+		}
+
+		l.owners[seatID] = sessionID
+	}
+
+	return doubleSells
+}
+
+func (l *ledger) release(seatIDs []int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, seatID := range seatIDs {
+		delete(l.owners, seatID)
+	}
+}
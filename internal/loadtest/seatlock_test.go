@@ -0,0 +1,93 @@
+package loadtest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+// memoryLocker locks all seats of a cart or none of them, like the Redis script.
+type memoryLocker struct {
+	mu    sync.Mutex
+	locks map[int]string
+	// racy skips the check for held seats
+	racy bool
+}
+
+func (l *memoryLocker) Lock(ctx context.Context, sessionID string, seatIDs []int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.racy {
+		for _, seatID := range seatIDs {
+			if _, held := l.locks[seatID]; held {
+				return domain.ErrSeatAlreadyReserved
+			}
+		}
+	}
+
+	for _, seatID := range seatIDs {
+		l.locks[seatID] = sessionID
+	}
+
+	return nil
+}
+
+func (l *memoryLocker) Release(ctx context.Context, sessionID string, seatIDs []int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, seatID := range seatIDs {
+		delete(l.locks, seatID)
+	}
+
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	cfg := Config{Sessions: 50, AttemptsPerSession: 20, Seats: 10, SeatsPerCart: 3}
+
+	t.Run("atomic locker", func(t *testing.T) {
+		report := Run(context.Background(), &memoryLocker{locks: map[int]string{}}, cfg)
+
+		if report.Attempts != 1000 {
+			t.Errorf("expected 1000 attempts, got %d", report.Attempts)
+		}
+
+		if report.Locked+report.Conflicts != report.Attempts || report.Errors != 0 {
+			t.Errorf("unexpected outcome of the attempts: %s", report)
+		}
+
+		if report.DoubleSells != 0 {
+			t.Errorf("expected no double sells: %s", report)
+		}
+	})
+
+	t.Run("locker granting held seats", func(t *testing.T) {
+		cfg := cfg
+		cfg.HoldTime = time.Microsecond
+
+		report := Run(context.Background(), &memoryLocker{locks: map[int]string{}, racy: true}, cfg)
+
+		if report.DoubleSells == 0 {
+			t.Errorf("expected double sells to be detected: %s", report)
+		}
+	})
+}
+
+func TestPickSeats(t *testing.T) {
+	for range 100 {
+		seatIDs := pickSeats(5, 5)
+
+		seen := map[int]bool{}
+		for _, seatID := range seatIDs {
+			if seatID < 1 || seatID > 5 || seen[seatID] {
+				t.Fatalf("expected distinct seats between 1 and 5, got %v", seatIDs)
+			}
+			seen[seatID] = true
+		}
+	}
+}
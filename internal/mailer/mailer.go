@@ -1,5 +1,33 @@
 package mailer
 
+import "context"
+
+// Attachment is a file sent along with an email, e.g. a calendar invite.
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
 type Mailer interface {
-	Send(recipient, templateFile string, data any) error
+	// Send renders the template of data and sends it to the recipient. The request ID carried by ctx, if
+	// any, is added to the message headers.
+	Send(ctx context.Context, recipient string, data Message, attachments ...Attachment) error
+}
+
+type senderContextKey struct{}
+
+// WithSender makes the emails sent with the returned context come from sender instead of the configured
+// sender, e.g. the address of the brand the request was made for.
+func WithSender(ctx context.Context, sender string) context.Context {
+	return context.WithValue(ctx, senderContextKey{}, sender)
+}
+
+// senderFromContext returns the sender set by WithSender, or fallback.
+func senderFromContext(ctx context.Context, fallback string) string {
+	if sender, ok := ctx.Value(senderContextKey{}).(string); ok && sender != "" {
+		return sender
+	}
+
+	return fallback
 }
@@ -0,0 +1,172 @@
+package mailer
+
+// Message is the data an email template is rendered with. Every template has its own message type, so the
+// fields a template refers to are checked against it by ValidateTemplates instead of failing a send.
+type Message interface {
+	// Template returns the file of the template the message is rendered with.
+	Template() string
+}
+
+// WelcomeEmail asks a newly registered user to activate their account.
+type WelcomeEmail struct {
+	ActivationToken string
+	UserID          int
+}
+
+func (WelcomeEmail) Template() string { return "user_welcome.tmpl" }
+
+// ActivationReminderEmail sends a new activation token to a user who hasn't activated their account yet.
+type ActivationReminderEmail struct {
+	ActivationToken string
+	UserID          int
+	FirstName       string
+}
+
+func (ActivationReminderEmail) Template() string { return "user_activation_reminder.tmpl" }
+
+// DeletionEmail asks a user to confirm the deletion of their account.
+type DeletionEmail struct {
+	DeletionToken string
+	UserID        int
+}
+
+func (DeletionEmail) Template() string { return "user_deletion.tmpl" }
+
+// EmailChangeEmail is sent to the new address of an email change, with the token confirming it along
+// with the other pending changes of the account.
+type EmailChangeEmail struct {
+	ConfirmationToken string
+	FirstName         string
+	MarketingEmails   bool
+	TTLMinutes        int
+}
+
+func (EmailChangeEmail) Template() string { return "email_change.tmpl" }
+
+// MagicLinkEmail carries a one-time login token.
+type MagicLinkEmail struct {
+	LoginToken string
+	FirstName  string
+	TTLMinutes int
+}
+
+func (MagicLinkEmail) Template() string { return "magic_link.tmpl" }
+
+// PasswordResetEmail carries a one-time token to set a new password with.
+type PasswordResetEmail struct {
+	ResetToken string
+	FirstName  string
+	TTLMinutes int
+}
+
+func (PasswordResetEmail) Template() string { return "password_reset.tmpl" }
+
+// PendingChangesEmail asks a user to verify changes to their account.
+type PendingChangesEmail struct {
+	VerificationToken string
+	FirstName         string
+	MarketingEmails   bool
+	TTLMinutes        int
+}
+
+func (PendingChangesEmail) Template() string { return "pending_changes.tmpl" }
+
+// ReservationConfirmation is sent once a reservation is paid, along with a calendar invite.
+type ReservationConfirmation struct {
+	FirstName       string
+	ReservationID   int
+	MovieTitle      string
+	TheaterName     string
+	HallName        string
+	Showtime        string
+	Seats           []string
+	Note            string
+	SpecialRequests []string
+}
+
+func (ReservationConfirmation) Template() string { return "reservation_confirmation.tmpl" }
+
+// PhoneBookingEmail sends the checkout link of a booking made by staff over the phone.
+type PhoneBookingEmail struct {
+	FirstName   string
+	MovieTitle  string
+	TheaterName string
+	HallName    string
+	Showtime    string
+	Seats       []string
+	TotalPrice  string
+	CheckoutUrl string
+	ExpiresAt   string
+}
+
+func (PhoneBookingEmail) Template() string { return "phone_booking.tmpl" }
+
+// AnnouncementEmail delivers an announcement to one of its recipients.
+type AnnouncementEmail struct {
+	FirstName string
+	Title     string
+	Body      string
+}
+
+func (AnnouncementEmail) Template() string { return "announcement.tmpl" }
+
+// NotificationDigestEmail collects the announcements of a day for a user in digest mode.
+type NotificationDigestEmail struct {
+	FirstName     string
+	Notifications []DigestNotification
+}
+
+type DigestNotification struct {
+	Title string
+	Body  string
+	Date  string
+}
+
+func (NotificationDigestEmail) Template() string { return "notification_digest.tmpl" }
+
+// CampaignNewReleaseEmail is a marketing email about a new release.
+type CampaignNewReleaseEmail struct {
+	FirstName      string
+	Subject        string
+	Body           string
+	MovieTitle     string
+	ReleaseDate    string
+	PosterUrl      string
+	UnsubscribeUrl string
+	OpenUrl        string
+}
+
+func (CampaignNewReleaseEmail) Template() string { return "campaign_new_release.tmpl" }
+
+// DisputeCreatedEmail notifies finance about a new payment dispute. ReservationID and EvidenceDueBy are
+// left out of the email when they are unknown.
+type DisputeCreatedEmail struct {
+	DisputeID      string
+	PaymentID      int
+	Amount         string
+	Currency       string
+	Reason         string
+	Status         string
+	TicketsRevoked bool
+	ReservationID  *int
+	EvidenceDueBy  string
+}
+
+func (DisputeCreatedEmail) Template() string { return "dispute_created.tmpl" }
+
+// messages has a value of every message type, these are the templates ValidateTemplates checks.
+var messages = []Message{
+	WelcomeEmail{},
+	ActivationReminderEmail{},
+	DeletionEmail{},
+	EmailChangeEmail{},
+	MagicLinkEmail{},
+	PasswordResetEmail{},
+	PendingChangesEmail{},
+	ReservationConfirmation{},
+	PhoneBookingEmail{},
+	AnnouncementEmail{},
+	NotificationDigestEmail{},
+	CampaignNewReleaseEmail{},
+	DisputeCreatedEmail{},
+}
@@ -1,6 +1,7 @@
 package mailer
 
 import (
+	"context"
 	"sync"
 )
 
@@ -8,7 +9,8 @@ import (
 type Email struct {
 	Recipient    string
 	TemplateFile string
-	Data         any
+	Data         Message
+	Attachments  []Attachment
 }
 
 // MockMailer is a mock implementation of the Mailer interface for testing
@@ -25,14 +27,15 @@ func NewMockMailer() *MockMailer {
 }
 
 // Send records the email that would have been sent
-func (m *MockMailer) Send(recipient, templateFile string, data any) error {
+func (m *MockMailer) Send(ctx context.Context, recipient string, data Message, attachments ...Attachment) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	m.emails = append(m.emails, Email{
 		Recipient:    recipient,
-		TemplateFile: templateFile,
+		TemplateFile: data.Template(),
 		Data:         data,
+		Attachments:  attachments,
 	})
 
 	return nil
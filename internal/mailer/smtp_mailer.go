@@ -2,16 +2,13 @@ package mailer
 
 import (
 	"bytes"
-	"embed"
-	"html/template"
+	"context"
 	"time"
 
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-mail/mail/v2"
 )
 
-//go:embed "templates"
-var templateFS embed.FS
-
 type SMTPMailer struct {
 	dialer *mail.Dialer
 	sender string
@@ -27,37 +24,40 @@ func NewSMTPMailer(host string, port int, username, password, sender string) Mai
 	}
 }
 
-func (m SMTPMailer) Send(recipient, templateFile string, data any) error {
-	tmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
+func (m SMTPMailer) Send(ctx context.Context, recipient string, data Message, attachments ...Attachment) error {
+	tmpl, err := parseTemplate(templateFS, data.Template())
 	if err != nil {
 		return err
 	}
 
 	subject := new(bytes.Buffer)
-	err = tmpl.ExecuteTemplate(subject, "subject", data)
-	if err != nil {
-		return err
-	}
-
 	plainBody := new(bytes.Buffer)
-	err = tmpl.ExecuteTemplate(plainBody, "plainBody", data)
-	if err != nil {
-		return err
-	}
-
 	htmlBody := new(bytes.Buffer)
-	err = tmpl.ExecuteTemplate(htmlBody, "htmlBody", data)
+
+	err = renderTemplate(tmpl, data, subject, plainBody, htmlBody)
 	if err != nil {
 		return err
 	}
 
 	msg := mail.NewMessage()
 	msg.SetHeader("To", recipient)
-	msg.SetHeader("From", m.sender)
+	msg.SetHeader("From", senderFromContext(ctx, m.sender))
 	msg.SetHeader("Subject", subject.String())
 	msg.SetBody("text/plain", plainBody.String())
 	msg.AddAlternative("text/html", htmlBody.String())
 
+	if requestID := middleware.GetReqID(ctx); requestID != "" {
+		msg.SetHeader("X-Request-ID", requestID)
+	}
+
+	for _, a := range attachments {
+		msg.AttachReader(
+			a.Filename,
+			bytes.NewReader(a.Data),
+			mail.SetHeader(map[string][]string{"Content-Type": {a.ContentType}}),
+		)
+	}
+
 	err = m.dialer.DialAndSend(msg)
 	if err != nil {
 		return err
@@ -0,0 +1,113 @@
+package mailer
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"path"
+	"reflect"
+	"slices"
+)
+
+//go:embed "templates"
+var templateFS embed.FS
+
+func parseTemplate(fsys fs.FS, templateFile string) (*template.Template, error) {
+	return template.New("email").ParseFS(fsys, "templates/"+templateFile)
+}
+
+// renderTemplate executes the subject, plainBody and htmlBody templates of an email into the writers.
+func renderTemplate(tmpl *template.Template, data Message, subject, plainBody, htmlBody io.Writer) error {
+	err := tmpl.ExecuteTemplate(subject, "subject", data)
+	if err != nil {
+		return err
+	}
+
+	err = tmpl.ExecuteTemplate(plainBody, "plainBody", data)
+	if err != nil {
+		return err
+	}
+
+	return tmpl.ExecuteTemplate(htmlBody, "htmlBody", data)
+}
+
+// ValidateTemplates renders every template with its message type, so a template referring to a field its
+// message doesn't have fails at startup rather than when the email is sent. Templates without a message
+// type are reported too, as nothing can send them.
+func ValidateTemplates() error {
+	return validateTemplates(templateFS, messages)
+}
+
+func validateTemplates(fsys fs.FS, messages []Message) error {
+	files, err := fs.Glob(fsys, "templates/*.tmpl")
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for _, msg := range messages {
+		file := msg.Template()
+		files = slices.DeleteFunc(files, func(f string) bool { return path.Base(f) == file })
+
+		tmpl, err := parseTemplate(fsys, file)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		// fields used only in conditional parts of a template are evaluated when the condition holds,
+		// so the template is rendered with every field set as well
+		for _, data := range []Message{msg, filledMessage(msg)} {
+			err = renderTemplate(tmpl, data, io.Discard, io.Discard, io.Discard)
+			if err != nil {
+				errs = append(errs, err)
+				break
+			}
+		}
+	}
+
+	for _, f := range files {
+		errs = append(errs, fmt.Errorf("template %s has no message type", path.Base(f)))
+	}
+
+	return errors.Join(errs...)
+}
+
+// filledMessage returns a message of the same type as msg with every field set to a non-zero value.
+func filledMessage(msg Message) Message {
+	v := reflect.New(reflect.TypeOf(msg)).Elem()
+	fill(v)
+
+	return v.Interface().(Message)
+}
+
+func fill(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString("x")
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(1)
+	case reflect.Pointer:
+		p := reflect.New(v.Type().Elem())
+		fill(p.Elem())
+		v.Set(p)
+	case reflect.Slice:
+		s := reflect.MakeSlice(v.Type(), 1, 1)
+		fill(s.Index(0))
+		v.Set(s)
+	case reflect.Struct:
+		for i := range v.NumField() {
+			if v.Field(i).CanSet() {
+				fill(v.Field(i))
+			}
+		}
+	}
+}
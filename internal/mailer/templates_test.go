@@ -0,0 +1,58 @@
+package mailer
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTemplates(t *testing.T) {
+	assert.NoError(t, ValidateTemplates())
+}
+
+func TestValidateTemplatesReportsDrift(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		wantErr  string
+	}{
+		{
+			name:     "field missing from the message",
+			template: `{{define "subject"}}Welcome{{end}}{{define "plainBody"}}{{.Token}}{{end}}{{define "htmlBody"}}{{end}}`,
+			wantErr:  "can't evaluate field Token in type mailer.WelcomeEmail",
+		},
+		{
+			name: "field missing in a conditional part",
+			template: `{{define "subject"}}Welcome{{end}}{{define "plainBody"}}{{.ActivationToken}}{{end}}` +
+				`{{define "htmlBody"}}{{if .UserID}}{{.FirstName}}{{end}}{{end}}`,
+			wantErr: "can't evaluate field FirstName in type mailer.WelcomeEmail",
+		},
+		{
+			name:     "missing part",
+			template: `{{define "subject"}}Welcome{{end}}{{define "plainBody"}}{{.ActivationToken}}{{end}}`,
+			wantErr:  `"htmlBody" is undefined`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{
+				"templates/user_welcome.tmpl": {Data: []byte(tt.template)},
+			}
+
+			err := validateTemplates(fsys, []Message{WelcomeEmail{}})
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestValidateTemplatesReportsTemplatesWithoutMessage(t *testing.T) {
+	fsys := fstest.MapFS{
+		"templates/user_welcome.tmpl": {Data: []byte(`{{define "subject"}}{{end}}{{define "plainBody"}}{{end}}{{define "htmlBody"}}{{end}}`)},
+		"templates/orphan.tmpl":       {Data: []byte(`{{define "subject"}}{{end}}`)},
+	}
+
+	err := validateTemplates(fsys, []Message{WelcomeEmail{}})
+	assert.EqualError(t, err, "template orphan.tmpl has no message type")
+}
@@ -0,0 +1,17 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAnalyticsEventRepo struct {
+	mock.Mock
+}
+
+func (m *MockAnalyticsEventRepo) CreateBatch(ctx context.Context, events []domain.AnalyticsEvent) error {
+	args := m.Called(ctx, events)
+	return args.Error(0)
+}
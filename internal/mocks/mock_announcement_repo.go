@@ -0,0 +1,96 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAnnouncementRepo struct {
+	mock.Mock
+	domain.AnnouncementRepository
+}
+
+func (m *MockAnnouncementRepo) Create(ctx context.Context, announcement *domain.Announcement) error {
+	args := m.Called(ctx, announcement)
+	return args.Error(0)
+}
+
+func (m *MockAnnouncementRepo) Cancel(ctx context.Context, id int) (*domain.Announcement, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Announcement), args.Error(1)
+}
+
+func (m *MockAnnouncementRepo) DeliverDue(ctx context.Context, now time.Time) ([]domain.Announcement, error) {
+	args := m.Called(ctx, now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Announcement), args.Error(1)
+}
+
+func (m *MockAnnouncementRepo) GetDueEmails(
+	ctx context.Context,
+	now time.Time,
+	limit int) ([]domain.AnnouncementEmail, error) {
+
+	args := m.Called(ctx, now, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.AnnouncementEmail), args.Error(1)
+}
+
+func (m *MockAnnouncementRepo) MarkEmailSent(ctx context.Context, notificationID int, sentAt time.Time) error {
+	args := m.Called(ctx, notificationID, sentAt)
+	return args.Error(0)
+}
+
+func (m *MockAnnouncementRepo) RecordEmailFailure(ctx context.Context, email domain.AnnouncementEmail) error {
+	args := m.Called(ctx, email)
+	return args.Error(0)
+}
+
+func (m *MockAnnouncementRepo) GetDueDigests(
+	ctx context.Context,
+	sentBefore time.Time,
+	limit int) ([]domain.NotificationDigest, error) {
+
+	args := m.Called(ctx, sentBefore, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.NotificationDigest), args.Error(1)
+}
+
+func (m *MockAnnouncementRepo) MarkDigestSent(
+	ctx context.Context,
+	userID int,
+	notificationIDs []int,
+	sentAt time.Time) error {
+
+	args := m.Called(ctx, userID, notificationIDs, sentAt)
+	return args.Error(0)
+}
+
+func (m *MockAnnouncementRepo) GetNotificationsByUserId(
+	ctx context.Context,
+	userId int,
+	pagination domain.Pagination) ([]domain.Notification, *domain.Metadata, error) {
+
+	args := m.Called(ctx, userId, pagination)
+	if args.Get(0) == nil {
+		return nil, nil, args.Error(2)
+	}
+	return args.Get(0).([]domain.Notification), args.Get(1).(*domain.Metadata), args.Error(2)
+}
+
+func (m *MockAnnouncementRepo) MarkNotificationRead(ctx context.Context, userId, notificationId int) error {
+	args := m.Called(ctx, userId, notificationId)
+	return args.Error(0)
+}
@@ -0,0 +1,61 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockBlockHoldRepo struct {
+	mock.Mock
+}
+
+func (m *MockBlockHoldRepo) Create(ctx context.Context, hold *domain.BlockHold) error {
+	args := m.Called(ctx, hold)
+	return args.Error(0)
+}
+
+func (m *MockBlockHoldRepo) Get(ctx context.Context, id int) (*domain.BlockHold, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.BlockHold), args.Error(1)
+}
+
+func (m *MockBlockHoldRepo) GetActiveByShowtime(ctx context.Context, showtimeID int) ([]domain.BlockHold, error) {
+	args := m.Called(ctx, showtimeID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.BlockHold), args.Error(1)
+}
+
+func (m *MockBlockHoldRepo) Confirm(
+	ctx context.Context,
+	id int,
+	now time.Time,
+	reservation *domain.Reservation,
+	payment *domain.Payment) error {
+
+	args := m.Called(ctx, id, now, reservation, payment)
+	return args.Error(0)
+}
+
+func (m *MockBlockHoldRepo) Release(ctx context.Context, id int) (*domain.BlockHold, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.BlockHold), args.Error(1)
+}
+
+func (m *MockBlockHoldRepo) Expire(ctx context.Context, now time.Time) ([]domain.BlockHold, error) {
+	args := m.Called(ctx, now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.BlockHold), args.Error(1)
+}
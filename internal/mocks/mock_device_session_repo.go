@@ -0,0 +1,52 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockDeviceSessionRepo struct {
+	mock.Mock
+}
+
+func (m *MockDeviceSessionRepo) Create(ctx context.Context, session *domain.DeviceSession) (*domain.DeviceSession, error) {
+	args := m.Called(ctx, session)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*domain.DeviceSession), args.Error(1)
+}
+
+func (m *MockDeviceSessionRepo) Rotate(
+	ctx context.Context,
+	refreshTokenHash []byte,
+	next *domain.DeviceSession) (*domain.DeviceSession, error) {
+
+	args := m.Called(ctx, refreshTokenHash, next)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*domain.DeviceSession), args.Error(1)
+}
+
+func (m *MockDeviceSessionRepo) RevokeAllForUser(ctx context.Context, userID int) ([]domain.DeviceSession, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]domain.DeviceSession), args.Error(1)
+}
+
+func (m *MockDeviceSessionRepo) Revoke(ctx context.Context, refreshTokenHash []byte) (*domain.DeviceSession, error) {
+	args := m.Called(ctx, refreshTokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*domain.DeviceSession), args.Error(1)
+}
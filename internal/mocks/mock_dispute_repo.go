@@ -0,0 +1,34 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockDisputeRepo struct {
+	mock.Mock
+}
+
+func (m *MockDisputeRepo) Record(
+	ctx context.Context,
+	paymentIntentID string,
+	dispute *domain.Dispute,
+	revokeTickets bool) (bool, error) {
+
+	args := m.Called(ctx, paymentIntentID, dispute, revokeTickets)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockDisputeRepo) List(
+	ctx context.Context,
+	status string,
+	pagination domain.Pagination) ([]domain.Dispute, *domain.Metadata, error) {
+
+	args := m.Called(ctx, status, pagination)
+	if args.Get(0) == nil {
+		return nil, nil, args.Error(2)
+	}
+	return args.Get(0).([]domain.Dispute), args.Get(1).(*domain.Metadata), args.Error(2)
+}
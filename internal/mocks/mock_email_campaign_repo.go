@@ -0,0 +1,53 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockEmailCampaignRepo struct {
+	mock.Mock
+}
+
+func (m *MockEmailCampaignRepo) Create(ctx context.Context, campaign *domain.EmailCampaign) error {
+	args := m.Called(ctx, campaign)
+	return args.Error(0)
+}
+
+func (m *MockEmailCampaignRepo) GetPendingDeliveries(ctx context.Context, limit int) ([]domain.CampaignDelivery, error) {
+	args := m.Called(ctx, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.CampaignDelivery), args.Error(1)
+}
+
+func (m *MockEmailCampaignRepo) MarkDeliverySent(ctx context.Context, campaignID, userID int, tokenHash []byte) error {
+	args := m.Called(ctx, campaignID, userID, tokenHash)
+	return args.Error(0)
+}
+
+func (m *MockEmailCampaignRepo) MarkDeliveryFailed(ctx context.Context, campaignID, userID int, errMsg string) error {
+	args := m.Called(ctx, campaignID, userID, errMsg)
+	return args.Error(0)
+}
+
+func (m *MockEmailCampaignRepo) RecordOpen(ctx context.Context, tokenHash []byte) error {
+	args := m.Called(ctx, tokenHash)
+	return args.Error(0)
+}
+
+func (m *MockEmailCampaignRepo) Unsubscribe(ctx context.Context, tokenHash []byte) error {
+	args := m.Called(ctx, tokenHash)
+	return args.Error(0)
+}
+
+func (m *MockEmailCampaignRepo) GetStats(ctx context.Context, campaignID int) (*domain.CampaignStats, error) {
+	args := m.Called(ctx, campaignID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.CampaignStats), args.Error(1)
+}
@@ -0,0 +1,39 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/fulfillment"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockFulfillmentQueue struct {
+	mock.Mock
+}
+
+func (m *MockFulfillmentQueue) Save(ctx context.Context, pending fulfillment.Pending) error {
+	args := m.Called(ctx, pending)
+	return args.Error(0)
+}
+
+func (m *MockFulfillmentQueue) Due(ctx context.Context, now time.Time, limit int) ([]fulfillment.Pending, error) {
+	args := m.Called(ctx, now, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]fulfillment.Pending), args.Error(1)
+}
+
+func (m *MockFulfillmentQueue) Remove(ctx context.Context, paymentID int) error {
+	args := m.Called(ctx, paymentID)
+	return args.Error(0)
+}
+
+func (m *MockFulfillmentQueue) List(ctx context.Context) ([]fulfillment.Pending, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]fulfillment.Pending), args.Error(1)
+}
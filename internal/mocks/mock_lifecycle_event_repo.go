@@ -0,0 +1,44 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockLifecycleEventRepo struct {
+	mock.Mock
+}
+
+func (m *MockLifecycleEventRepo) Due(ctx context.Context, now time.Time, limit int) ([]domain.LifecycleEvent, error) {
+	args := m.Called(ctx, now, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.LifecycleEvent), args.Error(1)
+}
+
+func (m *MockLifecycleEventRepo) MarkDelivered(ctx context.Context, id int, deliveredAt time.Time) error {
+	args := m.Called(ctx, id, deliveredAt)
+	return args.Error(0)
+}
+
+func (m *MockLifecycleEventRepo) RecordFailure(ctx context.Context, event domain.LifecycleEvent) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
+
+func (m *MockLifecycleEventRepo) Backlog(ctx context.Context) (*domain.LifecycleEventBacklog, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.LifecycleEventBacklog), args.Error(1)
+}
+
+func (m *MockLifecycleEventRepo) DeleteDeliveredBefore(ctx context.Context, cutoff time.Time, dryRun bool) (int64, error) {
+	args := m.Called(ctx, cutoff, dryRun)
+	return args.Get(0).(int64), args.Error(1)
+}
@@ -8,9 +8,14 @@ import (
 
 type MockMovieRepo struct {
 	domain.MovieRepository
-	GetAllFunc     func(ctx context.Context, filters domain.Pagination) ([]*domain.Movie, *domain.Metadata, error)
-	GetByIdFunc    func(ctx context.Context, id int) (*domain.Movie, error)
-	ExistsByIdFunc func(ctx context.Context, id int) (bool, error)
+	GetAllFunc           func(ctx context.Context, filters domain.Pagination) ([]*domain.Movie, *domain.Metadata, error)
+	GetByIdFunc          func(ctx context.Context, id int) (*domain.Movie, error)
+	ExistsByIdFunc       func(ctx context.Context, id int) (bool, error)
+	GetSitemapMoviesFunc func(ctx context.Context, limit int) ([]domain.SitemapMovie, error)
+	ArchiveFunc          func(ctx context.Context, id int) error
+	RestoreFunc          func(ctx context.Context, id int) error
+	CreateFunc           func(ctx context.Context, movie *domain.Movie) error
+	FindDuplicatesFunc   func(ctx context.Context, title string, releaseYear int) ([]domain.MovieDuplicate, error)
 }
 
 func (m *MockMovieRepo) GetAll(ctx context.Context, filters domain.Pagination) ([]*domain.Movie, *domain.Metadata, error) {
@@ -24,3 +29,23 @@ func (m *MockMovieRepo) GetById(ctx context.Context, id int) (*domain.Movie, err
 func (m *MockMovieRepo) ExistsById(ctx context.Context, id int) (bool, error) {
 	return m.ExistsByIdFunc(ctx, id)
 }
+
+func (m *MockMovieRepo) GetSitemapMovies(ctx context.Context, limit int) ([]domain.SitemapMovie, error) {
+	return m.GetSitemapMoviesFunc(ctx, limit)
+}
+
+func (m *MockMovieRepo) Archive(ctx context.Context, id int) error {
+	return m.ArchiveFunc(ctx, id)
+}
+
+func (m *MockMovieRepo) Restore(ctx context.Context, id int) error {
+	return m.RestoreFunc(ctx, id)
+}
+
+func (m *MockMovieRepo) Create(ctx context.Context, movie *domain.Movie) error {
+	return m.CreateFunc(ctx, movie)
+}
+
+func (m *MockMovieRepo) FindDuplicates(ctx context.Context, title string, releaseYear int) ([]domain.MovieDuplicate, error) {
+	return m.FindDuplicatesFunc(ctx, title, releaseYear)
+}
@@ -1,7 +1,11 @@
 package mocks
 
 import (
+	"context"
+	"time"
+
 	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/mock"
 	"github.com/stripe/stripe-go/v82"
 )
@@ -12,11 +16,80 @@ type MockPaymentProvider struct {
 }
 
 func (m *MockPaymentProvider) CreateCheckoutSession(
+	ctx context.Context,
 	sessionId string,
 	user *domain.User,
 	cart domain.Cart,
-	payment domain.Payment) (*stripe.CheckoutSession, error) {
+	payment domain.Payment,
+	expiresAt time.Time) (*stripe.CheckoutSession, error) {
 
 	args := m.Called(sessionId, user, cart)
 	return args.Get(0).(*stripe.CheckoutSession), args.Error(1)
 }
+
+func (m *MockPaymentProvider) ExpireCheckoutSession(ctx context.Context, checkoutSessionID string) error {
+	args := m.Called(checkoutSessionID)
+	return args.Error(0)
+}
+
+func (m *MockPaymentProvider) RefundPayment(
+	ctx context.Context,
+	paymentIntentID,
+	idempotencyKey string) (*stripe.Refund, error) {
+
+	args := m.Called(paymentIntentID, idempotencyKey)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*stripe.Refund), args.Error(1)
+}
+
+func (m *MockPaymentProvider) RefundPaymentAmount(
+	ctx context.Context,
+	paymentIntentID string,
+	amount decimal.Decimal,
+	idempotencyKey string) (*stripe.Refund, error) {
+
+	args := m.Called(paymentIntentID, amount, idempotencyKey)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*stripe.Refund), args.Error(1)
+}
+
+func (m *MockPaymentProvider) CreateShowtimeChangeCheckoutSession(
+	ctx context.Context,
+	user *domain.User,
+	change domain.ShowtimeChange,
+	payment domain.Payment,
+	expiresAt time.Time) (*stripe.CheckoutSession, error) {
+
+	args := m.Called(user, change, payment)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*stripe.CheckoutSession), args.Error(1)
+}
+
+func (m *MockPaymentProvider) GetPaymentReceipt(
+	ctx context.Context,
+	paymentIntentID string) (*domain.PaymentReceipt, error) {
+
+	args := m.Called(paymentIntentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.PaymentReceipt), args.Error(1)
+}
+
+func (m *MockPaymentProvider) ListBalanceTransactions(
+	ctx context.Context,
+	from,
+	to time.Time) ([]domain.BalanceTransaction, error) {
+
+	args := m.Called(from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.BalanceTransaction), args.Error(1)
+}
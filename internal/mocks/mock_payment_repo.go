@@ -2,6 +2,7 @@ package mocks
 
 import (
 	"context"
+	"time"
 
 	"github.com/metinatakli/movie-reservation-system/internal/domain"
 	"github.com/stretchr/testify/mock"
@@ -16,3 +17,106 @@ func (m *MockPaymentRepo) Create(ctx context.Context, payment *domain.Payment) e
 	args := m.Called(ctx, payment)
 	return args.Error(0)
 }
+
+func (m *MockPaymentRepo) GetById(ctx context.Context, id int) (*domain.Payment, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Payment), args.Error(1)
+}
+
+func (m *MockPaymentRepo) AnonymizeCreatedBefore(ctx context.Context, cutoff time.Time, dryRun bool) (int64, error) {
+	args := m.Called(ctx, cutoff, dryRun)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockPaymentRepo) RecordRefund(ctx context.Context, paymentIntentID string, refund *domain.Refund) (bool, error) {
+	args := m.Called(ctx, paymentIntentID, refund)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPaymentRepo) DueRefunds(ctx context.Context, now time.Time, limit int) ([]domain.PendingRefund, error) {
+	args := m.Called(ctx, now, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.PendingRefund), args.Error(1)
+}
+
+func (m *MockPaymentRepo) MarkRefundSent(ctx context.Context, id int, stripeRefundID string, sentAt time.Time) error {
+	args := m.Called(ctx, id, stripeRefundID, sentAt)
+	return args.Error(0)
+}
+
+func (m *MockPaymentRepo) RecordRefundFailure(ctx context.Context, refund domain.PendingRefund) error {
+	args := m.Called(ctx, refund)
+	return args.Error(0)
+}
+
+func (m *MockPaymentRepo) GetHistoryByUserId(
+	ctx context.Context,
+	userId int,
+	pagination domain.Pagination) ([]domain.PaymentHistoryEntry, *domain.Metadata, error) {
+
+	args := m.Called(ctx, userId, pagination)
+	if args.Get(0) == nil {
+		return nil, nil, args.Error(2)
+	}
+	return args.Get(0).([]domain.PaymentHistoryEntry), args.Get(1).(*domain.Metadata), args.Error(2)
+}
+
+func (m *MockPaymentRepo) MarkUnfulfilled(
+	ctx context.Context,
+	paymentID int,
+	checkoutSessionID,
+	paymentIntentID,
+	errMsg string) error {
+
+	args := m.Called(ctx, paymentID, checkoutSessionID, paymentIntentID, errMsg)
+	return args.Error(0)
+}
+
+func (m *MockPaymentRepo) MarkCompleted(
+	ctx context.Context,
+	paymentID int,
+	checkoutSessionID,
+	paymentIntentID string) error {
+
+	args := m.Called(ctx, paymentID, checkoutSessionID, paymentIntentID)
+	return args.Error(0)
+}
+
+func (m *MockPaymentRepo) MarkCanceled(
+	ctx context.Context,
+	paymentID int,
+	checkoutSessionID,
+	errMsg string) error {
+
+	args := m.Called(ctx, paymentID, checkoutSessionID, errMsg)
+	return args.Error(0)
+}
+
+func (m *MockPaymentRepo) GetLedgerEntries(ctx context.Context, from, to time.Time) ([]domain.LedgerEntry, error) {
+	args := m.Called(ctx, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.LedgerEntry), args.Error(1)
+}
+
+func (m *MockPaymentRepo) SaveReconciliationReport(ctx context.Context, report *domain.ReconciliationReport) error {
+	args := m.Called(ctx, report)
+	return args.Error(0)
+}
+
+func (m *MockPaymentRepo) GetReconciliationReport(
+	ctx context.Context,
+	date time.Time) (*domain.ReconciliationReport, error) {
+
+	args := m.Called(ctx, date)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.ReconciliationReport), args.Error(1)
+}
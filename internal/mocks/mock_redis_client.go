@@ -18,6 +18,11 @@ func (m *MockRedisClient) Get(ctx context.Context, key string) *redis.StringCmd
 	return args.Get(0).(*redis.StringCmd)
 }
 
+func (m *MockRedisClient) MGet(ctx context.Context, keys ...string) *redis.SliceCmd {
+	args := m.Called(ctx, keys)
+	return args.Get(0).(*redis.SliceCmd)
+}
+
 func (m *MockRedisClient) TxPipeline() redis.Pipeliner {
 	args := m.Called()
 	return args.Get(0).(redis.Pipeliner)
@@ -33,6 +38,11 @@ func (m *MockRedisClient) SRem(ctx context.Context, key string, members ...inter
 	return args.Get(0).(*redis.IntCmd)
 }
 
+func (m *MockRedisClient) ZRem(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	args := m.Called(ctx, key, members)
+	return args.Get(0).(*redis.IntCmd)
+}
+
 func (m *MockRedisClient) EvalSha(ctx context.Context, sha1 string, keys []string, args ...interface{}) *redis.Cmd {
 	callArgs := append([]interface{}{ctx, sha1, keys}, args...)
 	result := m.Called(callArgs...)
@@ -49,6 +59,26 @@ func (m *MockRedisClient) Watch(ctx context.Context, fn func(*redis.Tx) error, k
 	return args.Error(0)
 }
 
+func (m *MockRedisClient) Publish(ctx context.Context, channel string, message interface{}) *redis.IntCmd {
+	args := m.Called(ctx, channel, message)
+	return args.Get(0).(*redis.IntCmd)
+}
+
+func (m *MockRedisClient) PTTL(ctx context.Context, key string) *redis.DurationCmd {
+	args := m.Called(ctx, key)
+	return args.Get(0).(*redis.DurationCmd)
+}
+
+func (m *MockRedisClient) SMembers(ctx context.Context, key string) *redis.StringSliceCmd {
+	args := m.Called(ctx, key)
+	return args.Get(0).(*redis.StringSliceCmd)
+}
+
+func (m *MockRedisClient) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	args := m.Called(ctx, key, value, expiration)
+	return args.Get(0).(*redis.StatusCmd)
+}
+
 type MockTxPipeline struct {
 	mock.Mock
 	redis.Pipeliner
@@ -79,6 +109,11 @@ func (m *MockTxPipeline) SRem(ctx context.Context, key string, members ...interf
 	return args.Get(0).(*redis.IntCmd)
 }
 
+func (m *MockTxPipeline) ZRem(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	args := m.Called(ctx, key, members)
+	return args.Get(0).(*redis.IntCmd)
+}
+
 func (m *MockTxPipeline) Exec(ctx context.Context) ([]redis.Cmder, error) {
 	args := m.Called(ctx)
 	if args.Get(0) == nil {
@@ -92,6 +127,11 @@ func (m *MockTxPipeline) Expire(ctx context.Context, key string, expiration time
 	return args.Get(0).(*redis.BoolCmd)
 }
 
+func (m *MockTxPipeline) ExpireAt(ctx context.Context, key string, tm time.Time) *redis.BoolCmd {
+	args := m.Called(ctx, key, tm)
+	return args.Get(0).(*redis.BoolCmd)
+}
+
 type MockRedisError struct {
 	Msg string
 }
@@ -2,6 +2,7 @@ package mocks
 
 import (
 	"context"
+	"time"
 
 	"github.com/metinatakli/movie-reservation-system/internal/domain"
 	"github.com/stretchr/testify/mock"
@@ -12,11 +13,16 @@ type MockReservationRepo struct {
 	domain.ReservationRepository
 }
 
-func (m *MockReservationRepo) Create(ctx context.Context, reservation domain.Reservation) error {
+func (m *MockReservationRepo) Create(ctx context.Context, reservation *domain.Reservation) error {
 	args := m.Called(ctx, reservation)
 	return args.Error(0)
 }
 
+func (m *MockReservationRepo) CreateComp(ctx context.Context, reservation *domain.Reservation, payment *domain.Payment) error {
+	args := m.Called(ctx, reservation, payment)
+	return args.Error(0)
+}
+
 func (m *MockReservationRepo) GetSeatsByShowtimeId(ctx context.Context, showtimeId int) ([]domain.ReservationSeat, error) {
 	args := m.Called(ctx, showtimeId)
 	if args.Get(0) == nil {
@@ -48,3 +54,156 @@ func (m *MockReservationRepo) GetByReservationIdAndUserId(
 	}
 	return args.Get(0).(*domain.ReservationDetail), args.Error(1)
 }
+
+func (m *MockReservationRepo) CancelUnpaidVenueReservations(ctx context.Context, startsBefore time.Time) ([]domain.Reservation, error) {
+	args := m.Called(ctx, startsBefore)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Reservation), args.Error(1)
+}
+
+func (m *MockReservationRepo) GetCheckInListByShowtime(ctx context.Context, showtimeId int) ([]domain.CheckInEntry, error) {
+	args := m.Called(ctx, showtimeId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.CheckInEntry), args.Error(1)
+}
+
+func (m *MockReservationRepo) GetTicketCheckIn(ctx context.Context, reservationId int) (*domain.TicketCheckIn, error) {
+	args := m.Called(ctx, reservationId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.TicketCheckIn), args.Error(1)
+}
+
+func (m *MockReservationRepo) CreateCheckIn(ctx context.Context, checkIn *domain.CheckIn) error {
+	args := m.Called(ctx, checkIn)
+	return args.Error(0)
+}
+
+func (m *MockReservationRepo) MarkNoShows(ctx context.Context, now, startedAfter time.Time) ([]domain.Reservation, error) {
+	args := m.Called(ctx, now, startedAfter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Reservation), args.Error(1)
+}
+
+func (m *MockReservationRepo) GetAttendanceByTheater(
+	ctx context.Context,
+	theaterId int,
+	from, to time.Time) ([]domain.ShowtimeAttendance, error) {
+
+	args := m.Called(ctx, theaterId, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.ShowtimeAttendance), args.Error(1)
+}
+
+func (m *MockReservationRepo) LookupByEmails(
+	ctx context.Context,
+	showtimeId int,
+	emails []string) ([]domain.ReservationLookup, error) {
+
+	args := m.Called(ctx, showtimeId, emails)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.ReservationLookup), args.Error(1)
+}
+
+func (m *MockReservationRepo) GetManifestByShowtime(ctx context.Context, showtimeId int) (*domain.ShowtimeManifest, error) {
+	args := m.Called(ctx, showtimeId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.ShowtimeManifest), args.Error(1)
+}
+
+func (m *MockReservationRepo) GetBookedShowtime(ctx context.Context, reservationId, userId int) (*domain.BookedShowtime, error) {
+	args := m.Called(ctx, reservationId, userId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.BookedShowtime), args.Error(1)
+}
+
+func (m *MockReservationRepo) CancelSeats(
+	ctx context.Context,
+	reservationId,
+	showtimeId int,
+	seatIds []int) ([]domain.PendingRefund, error) {
+
+	args := m.Called(ctx, reservationId, showtimeId, seatIds)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.PendingRefund), args.Error(1)
+}
+
+func (m *MockReservationRepo) Reschedule(
+	ctx context.Context,
+	change domain.ShowtimeChange) ([]domain.PendingRefund, error) {
+
+	args := m.Called(ctx, change)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.PendingRefund), args.Error(1)
+}
+
+func (m *MockReservationRepo) GetReservedSeatsByShowtime(ctx context.Context, from, to time.Time) (map[int][]int, error) {
+	args := m.Called(ctx, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int][]int), args.Error(1)
+}
+
+func (m *MockReservationRepo) GetInventoryAnomalies(ctx context.Context, from, to time.Time) ([]domain.InventoryAnomaly, error) {
+	args := m.Called(ctx, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.InventoryAnomaly), args.Error(1)
+}
+
+func (m *MockReservationRepo) Share(ctx context.Context, reservationId int, tokenHash []byte, expiresAt time.Time) error {
+	args := m.Called(ctx, reservationId, tokenHash, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockReservationRepo) RevokeShare(ctx context.Context, reservationId, userId int) error {
+	args := m.Called(ctx, reservationId, userId)
+	return args.Error(0)
+}
+
+func (m *MockReservationRepo) GetSharedScreening(ctx context.Context, tokenHash []byte, now time.Time) (*domain.SharedScreening, error) {
+	args := m.Called(ctx, tokenHash, now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.SharedScreening), args.Error(1)
+}
+
+func (m *MockReservationRepo) SaveCalendarFeed(ctx context.Context, userId int, tokenHash []byte) error {
+	args := m.Called(ctx, userId, tokenHash)
+	return args.Error(0)
+}
+
+func (m *MockReservationRepo) DeleteCalendarFeed(ctx context.Context, userId int) error {
+	args := m.Called(ctx, userId)
+	return args.Error(0)
+}
+
+func (m *MockReservationRepo) GetCalendarFeed(ctx context.Context, tokenHash []byte, now time.Time) ([]domain.ReservationDetail, error) {
+	args := m.Called(ctx, tokenHash, now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.ReservationDetail), args.Error(1)
+}
@@ -0,0 +1,21 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockSearchRepo struct {
+	mock.Mock
+	domain.SearchRepository
+}
+
+func (m *MockSearchRepo) Suggest(ctx context.Context, prefix string, limit int) ([]domain.Suggestion, error) {
+	args := m.Called(ctx, prefix, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Suggestion), args.Error(1)
+}
@@ -2,6 +2,7 @@ package mocks
 
 import (
 	"context"
+	"time"
 
 	"github.com/metinatakli/movie-reservation-system/internal/domain"
 	"github.com/stretchr/testify/mock"
@@ -27,3 +28,93 @@ func (m *MockSeatRepo) GetSeatsByShowtime(ctx context.Context, showtimeID int) (
 	}
 	return args.Get(0).(*domain.ShowtimeSeats), args.Error(1)
 }
+
+func (m *MockSeatRepo) GetSeatSalesByHall(
+	ctx context.Context,
+	hallID int,
+	from, to time.Time,
+	channel domain.SalesChannel) (*domain.SeatHeatmap, error) {
+	args := m.Called(ctx, hallID, from, to, channel)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.SeatHeatmap), args.Error(1)
+}
+
+func (m *MockSeatRepo) CreatePriceVersion(ctx context.Context, version *domain.SeatPriceVersion) error {
+	args := m.Called(ctx, version)
+	return args.Error(0)
+}
+
+func (m *MockSeatRepo) GetPriceVersionsByHall(ctx context.Context, hallID int) ([]domain.SeatPriceVersion, error) {
+	args := m.Called(ctx, hallID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.SeatPriceVersion), args.Error(1)
+}
+
+func (m *MockSeatRepo) GetSalesByPriceVersion(
+	ctx context.Context,
+	hallID int,
+	channel domain.SalesChannel) ([]domain.PriceVersionSales, error) {
+	args := m.Called(ctx, hallID, channel)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.PriceVersionSales), args.Error(1)
+}
+
+func (m *MockSeatRepo) CreateSeatBlocks(ctx context.Context, blocks []domain.SeatBlock) error {
+	args := m.Called(ctx, blocks)
+	return args.Error(0)
+}
+
+func (m *MockSeatRepo) GetSeatBlocksByShowtime(ctx context.Context, showtimeID int) ([]domain.SeatBlock, error) {
+	args := m.Called(ctx, showtimeID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.SeatBlock), args.Error(1)
+}
+
+func (m *MockSeatRepo) DeleteSeatBlock(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockSeatRepo) CreateHallTemplate(ctx context.Context, template *domain.HallTemplate) error {
+	args := m.Called(ctx, template)
+	return args.Error(0)
+}
+
+func (m *MockSeatRepo) GetHallTemplates(ctx context.Context) ([]domain.HallTemplate, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.HallTemplate), args.Error(1)
+}
+
+func (m *MockSeatRepo) GetHallTemplate(ctx context.Context, id int) (*domain.HallTemplate, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.HallTemplate), args.Error(1)
+}
+
+func (m *MockSeatRepo) UpdateHallTemplate(ctx context.Context, template *domain.HallTemplate) error {
+	args := m.Called(ctx, template)
+	return args.Error(0)
+}
+
+func (m *MockSeatRepo) DeleteHallTemplate(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockSeatRepo) CreateHallFromTemplate(ctx context.Context, hall *domain.Hall, templateID int) (int, error) {
+	args := m.Called(ctx, hall, templateID)
+	return args.Int(0), args.Error(1)
+}
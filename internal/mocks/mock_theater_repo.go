@@ -14,7 +14,26 @@ type MockTheaterRepo struct {
 		time.Time,
 		float64,
 		float64,
+		domain.ShowtimeFilter,
 		domain.Pagination) ([]domain.Theater, *domain.Metadata, error)
+	UpdateShowtimeFormatFunc  func(context.Context, int, domain.ScreeningFormat) error
+	UpdateSalesWindowFunc     func(context.Context, int, domain.SalesWindow) error
+	UpdateCheckInPolicyFunc   func(context.Context, int, domain.CheckInPolicy) error
+	UpdateTheaterPreShowFunc  func(context.Context, int, int) error
+	UpdateShowtimePreShowFunc func(context.Context, int, *int) error
+	AdjustShowtimePricesFunc  func(context.Context, domain.BasePriceAdjustment, bool) ([]domain.ShowtimePriceChange, error)
+	GetFormatSurchargesFunc   func(context.Context) ([]domain.FormatSurcharge, error)
+	UpdateFormatSurchargeFunc func(context.Context, *domain.FormatSurcharge) error
+	IsTheaterStaffFunc        func(context.Context, int, int) (bool, error)
+	GetUpcomingScreeningsFunc func(context.Context, time.Time, time.Time) ([]domain.Screening, error)
+	GetPartnerShowtimesFunc   func(context.Context, *time.Time, domain.Pagination) ([]domain.PartnerShowtime, *domain.Metadata, error)
+	UpdatePayoutAccountFunc   func(context.Context, int, string) error
+	GetRescheduleOptionsFunc  func(context.Context, int, int, int, int, time.Time) ([]domain.RescheduleOption, error)
+	GetNextShowtimeFunc       func(context.Context, int, float64, float64, time.Time) (*domain.NextShowtime, error)
+	GetNowPlayingBoardFunc    func(context.Context, int, time.Time) (*domain.NowPlayingBoard, error)
+	GetSeatAvailabilityFunc   func(context.Context, []int) ([]domain.SeatAvailability, error)
+	GetAmenitiesFunc          func(context.Context) ([]domain.Amenity, error)
+	ImportTheatersFunc        func(context.Context, []domain.TheaterImport) ([]int, error)
 }
 
 func (m *MockTheaterRepo) GetTheatersByMovieAndLocationAndDate(
@@ -22,7 +41,101 @@ func (m *MockTheaterRepo) GetTheatersByMovieAndLocationAndDate(
 	movieID int,
 	date time.Time,
 	longitude, latitude float64,
+	filter domain.ShowtimeFilter,
 	pagination domain.Pagination) ([]domain.Theater, *domain.Metadata, error) {
 
-	return m.GetTheatersByMovieAndLocationAndDateFunc(ctx, movieID, date, longitude, latitude, pagination)
+	return m.GetTheatersByMovieAndLocationAndDateFunc(ctx, movieID, date, longitude, latitude, filter, pagination)
+}
+
+func (m *MockTheaterRepo) UpdateShowtimeFormat(ctx context.Context, showtimeID int, format domain.ScreeningFormat) error {
+	return m.UpdateShowtimeFormatFunc(ctx, showtimeID, format)
+}
+
+func (m *MockTheaterRepo) UpdateSalesWindow(ctx context.Context, showtimeID int, window domain.SalesWindow) error {
+	return m.UpdateSalesWindowFunc(ctx, showtimeID, window)
+}
+
+func (m *MockTheaterRepo) UpdateTheaterPreShow(ctx context.Context, theaterID, minutes int) error {
+	return m.UpdateTheaterPreShowFunc(ctx, theaterID, minutes)
+}
+
+func (m *MockTheaterRepo) UpdateShowtimePreShow(ctx context.Context, showtimeID int, minutes *int) error {
+	return m.UpdateShowtimePreShowFunc(ctx, showtimeID, minutes)
+}
+
+func (m *MockTheaterRepo) AdjustShowtimePrices(
+	ctx context.Context,
+	adjustment domain.BasePriceAdjustment,
+	dryRun bool) ([]domain.ShowtimePriceChange, error) {
+
+	return m.AdjustShowtimePricesFunc(ctx, adjustment, dryRun)
+}
+
+func (m *MockTheaterRepo) UpdateCheckInPolicy(ctx context.Context, theaterID int, policy domain.CheckInPolicy) error {
+	return m.UpdateCheckInPolicyFunc(ctx, theaterID, policy)
+}
+
+func (m *MockTheaterRepo) GetFormatSurcharges(ctx context.Context) ([]domain.FormatSurcharge, error) {
+	return m.GetFormatSurchargesFunc(ctx)
+}
+
+func (m *MockTheaterRepo) UpdateFormatSurcharge(ctx context.Context, surcharge *domain.FormatSurcharge) error {
+	return m.UpdateFormatSurchargeFunc(ctx, surcharge)
+}
+
+func (m *MockTheaterRepo) IsTheaterStaff(ctx context.Context, theaterID, userID int) (bool, error) {
+	return m.IsTheaterStaffFunc(ctx, theaterID, userID)
+}
+
+func (m *MockTheaterRepo) GetUpcomingScreenings(ctx context.Context, from, to time.Time) ([]domain.Screening, error) {
+	return m.GetUpcomingScreeningsFunc(ctx, from, to)
+}
+
+func (m *MockTheaterRepo) GetPartnerShowtimes(
+	ctx context.Context,
+	since *time.Time,
+	pagination domain.Pagination) ([]domain.PartnerShowtime, *domain.Metadata, error) {
+
+	return m.GetPartnerShowtimesFunc(ctx, since, pagination)
+}
+
+func (m *MockTheaterRepo) UpdatePayoutAccount(ctx context.Context, theaterID int, accountID string) error {
+	return m.UpdatePayoutAccountFunc(ctx, theaterID, accountID)
+}
+
+func (m *MockTheaterRepo) GetRescheduleOptions(
+	ctx context.Context,
+	movieID, theaterID, exceptShowtimeID, minSeats int,
+	after time.Time) ([]domain.RescheduleOption, error) {
+
+	return m.GetRescheduleOptionsFunc(ctx, movieID, theaterID, exceptShowtimeID, minSeats, after)
+}
+
+func (m *MockTheaterRepo) GetNextShowtime(
+	ctx context.Context,
+	movieID int,
+	longitude, latitude float64,
+	after time.Time) (*domain.NextShowtime, error) {
+
+	return m.GetNextShowtimeFunc(ctx, movieID, longitude, latitude, after)
+}
+
+func (m *MockTheaterRepo) GetNowPlayingBoard(
+	ctx context.Context,
+	theaterID int,
+	now time.Time) (*domain.NowPlayingBoard, error) {
+
+	return m.GetNowPlayingBoardFunc(ctx, theaterID, now)
+}
+
+func (m *MockTheaterRepo) GetSeatAvailability(ctx context.Context, showtimeIDs []int) ([]domain.SeatAvailability, error) {
+	return m.GetSeatAvailabilityFunc(ctx, showtimeIDs)
+}
+
+func (m *MockTheaterRepo) GetAmenities(ctx context.Context) ([]domain.Amenity, error) {
+	return m.GetAmenitiesFunc(ctx)
+}
+
+func (m *MockTheaterRepo) ImportTheaters(ctx context.Context, theaters []domain.TheaterImport) ([]int, error) {
+	return m.ImportTheatersFunc(ctx, theaters)
 }
@@ -2,6 +2,7 @@ package mocks
 
 import (
 	"context"
+	"time"
 
 	"github.com/metinatakli/movie-reservation-system/internal/domain"
 )
@@ -11,6 +12,8 @@ type MockTokenRepo struct {
 	domain.TokenRepository
 	CreateFunc           func(ctx context.Context, token *domain.Token) error
 	DeleteAllForUserFunc func(ctx context.Context, tokenScope string, userID int) error
+	DeleteExpiredFunc    func(ctx context.Context, expiredBefore time.Time, dryRun bool) (int64, error)
+	ConsumeFunc          func(ctx context.Context, tokenHash []byte, tokenScope string) (int, error)
 }
 
 func (m *MockTokenRepo) Create(ctx context.Context, token *domain.Token) error {
@@ -20,3 +23,11 @@ func (m *MockTokenRepo) Create(ctx context.Context, token *domain.Token) error {
 func (m *MockTokenRepo) DeleteAllForUser(ctx context.Context, tokenScope string, userID int) error {
 	return m.DeleteAllForUserFunc(ctx, tokenScope, userID)
 }
+
+func (m *MockTokenRepo) DeleteExpired(ctx context.Context, expiredBefore time.Time, dryRun bool) (int64, error) {
+	return m.DeleteExpiredFunc(ctx, expiredBefore, dryRun)
+}
+
+func (m *MockTokenRepo) Consume(ctx context.Context, tokenHash []byte, tokenScope string) (int, error) {
+	return m.ConsumeFunc(ctx, tokenHash, tokenScope)
+}
@@ -2,6 +2,7 @@ package mocks
 
 import (
 	"context"
+	"time"
 
 	"github.com/metinatakli/movie-reservation-system/internal/domain"
 )
@@ -12,9 +13,21 @@ type MockUserRepo struct {
 	GetByTokenFunc      func(ctx context.Context, hash []byte, scope string) (*domain.User, error)
 	UpdateFunc          func(ctx context.Context, user *domain.User) error
 	ActivateFunc        func(ctx context.Context, user *domain.User) error
+	ResetPasswordFunc   func(ctx context.Context, tokenHash []byte, passwordHash []byte) (int, error)
 	GetByEmailFunc      func(ctx context.Context, email string) (*domain.User, error)
+	ExistsByEmailFunc   func(ctx context.Context, email string) (bool, error)
 	GetByIdFunc         func(ctx context.Context, id int) (*domain.User, error)
 	DeleteFunc          func(ctx context.Context, user *domain.User) error
+
+	GetPendingActivationRemindersFunc  func(ctx context.Context, expiresBefore time.Time) ([]*domain.User, error)
+	MarkActivationReminderSentFunc     func(ctx context.Context, userID int) error
+	DeleteUnactivatedCreatedBeforeFunc func(ctx context.Context, cutoff time.Time) (int64, error)
+	GetPreferencesFunc                 func(ctx context.Context, userID int) (*domain.UserPreferences, error)
+	UpsertPreferencesFunc              func(ctx context.Context, preferences *domain.UserPreferences) error
+	SavePendingChangesFunc             func(ctx context.Context, userID int, changes domain.PendingChanges, token *domain.Token) error
+	DiscardPendingChangesFunc          func(ctx context.Context, userID int) error
+	ApplyPendingChangesFunc            func(ctx context.Context, tokenHash []byte) (int, error)
+	ApplyPendingEmailChangeFunc        func(ctx context.Context, userID int, tokenHash []byte) error
 }
 
 func (m *MockUserRepo) CreateWithToken(
@@ -37,10 +50,18 @@ func (m *MockUserRepo) ActivateUser(ctx context.Context, user *domain.User) erro
 	return m.ActivateFunc(ctx, user)
 }
 
+func (m *MockUserRepo) ResetPassword(ctx context.Context, tokenHash []byte, passwordHash []byte) (int, error) {
+	return m.ResetPasswordFunc(ctx, tokenHash, passwordHash)
+}
+
 func (m *MockUserRepo) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
 	return m.GetByEmailFunc(ctx, email)
 }
 
+func (m *MockUserRepo) ExistsByEmail(ctx context.Context, email string) (bool, error) {
+	return m.ExistsByEmailFunc(ctx, email)
+}
+
 func (m *MockUserRepo) GetById(ctx context.Context, id int) (*domain.User, error) {
 	return m.GetByIdFunc(ctx, id)
 }
@@ -48,3 +69,44 @@ func (m *MockUserRepo) GetById(ctx context.Context, id int) (*domain.User, error
 func (m *MockUserRepo) Delete(ctx context.Context, user *domain.User) error {
 	return m.DeleteFunc(ctx, user)
 }
+
+func (m *MockUserRepo) GetPendingActivationReminders(ctx context.Context, expiresBefore time.Time) ([]*domain.User, error) {
+	return m.GetPendingActivationRemindersFunc(ctx, expiresBefore)
+}
+
+func (m *MockUserRepo) MarkActivationReminderSent(ctx context.Context, userID int) error {
+	return m.MarkActivationReminderSentFunc(ctx, userID)
+}
+
+func (m *MockUserRepo) DeleteUnactivatedCreatedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	return m.DeleteUnactivatedCreatedBeforeFunc(ctx, cutoff)
+}
+
+func (m *MockUserRepo) GetPreferences(ctx context.Context, userID int) (*domain.UserPreferences, error) {
+	return m.GetPreferencesFunc(ctx, userID)
+}
+
+func (m *MockUserRepo) UpsertPreferences(ctx context.Context, preferences *domain.UserPreferences) error {
+	return m.UpsertPreferencesFunc(ctx, preferences)
+}
+
+func (m *MockUserRepo) SavePendingChanges(
+	ctx context.Context,
+	userID int,
+	changes domain.PendingChanges,
+	token *domain.Token) error {
+
+	return m.SavePendingChangesFunc(ctx, userID, changes, token)
+}
+
+func (m *MockUserRepo) DiscardPendingChanges(ctx context.Context, userID int) error {
+	return m.DiscardPendingChangesFunc(ctx, userID)
+}
+
+func (m *MockUserRepo) ApplyPendingChanges(ctx context.Context, tokenHash []byte) (int, error) {
+	return m.ApplyPendingChangesFunc(ctx, tokenHash)
+}
+
+func (m *MockUserRepo) ApplyPendingEmailChange(ctx context.Context, userID int, tokenHash []byte) error {
+	return m.ApplyPendingEmailChangeFunc(ctx, userID, tokenHash)
+}
@@ -0,0 +1,23 @@
+package mocks
+
+import (
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockWalletPassIssuer struct {
+	mock.Mock
+}
+
+func (m *MockWalletPassIssuer) ApplePass(ticket domain.WalletTicket) ([]byte, error) {
+	args := m.Called(ticket)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (m *MockWalletPassIssuer) GoogleSaveURL(ticket domain.WalletTicket) (string, error) {
+	args := m.Called(ticket)
+	return args.String(0), args.Error(1)
+}
@@ -0,0 +1,30 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockWebhookEventRepo struct {
+	mock.Mock
+}
+
+func (m *MockWebhookEventRepo) Store(ctx context.Context, event *domain.WebhookEvent) (bool, error) {
+	args := m.Called(ctx, event)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockWebhookEventRepo) GetById(ctx context.Context, id string) (*domain.WebhookEvent, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.WebhookEvent), args.Error(1)
+}
+
+func (m *MockWebhookEventRepo) MarkReplayed(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
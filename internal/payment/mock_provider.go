@@ -1,12 +1,19 @@
 package payment
 
 import (
+	"context"
+	"time"
+
 	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/shopspring/decimal"
 	"github.com/stripe/stripe-go/v82"
 )
 
 type MockPaymentProvider struct {
 	CheckoutSession *stripe.CheckoutSession
+	Refund          *stripe.Refund
+	Receipt         *domain.PaymentReceipt
+	Transactions    []domain.BalanceTransaction
 	Err             error
 }
 
@@ -15,10 +22,58 @@ func NewMockPaymentProvider() *MockPaymentProvider {
 }
 
 func (m *MockPaymentProvider) CreateCheckoutSession(
+	ctx context.Context,
 	sessionId string,
 	user *domain.User,
 	cart domain.Cart,
-	payment domain.Payment) (*stripe.CheckoutSession, error) {
+	payment domain.Payment,
+	expiresAt time.Time) (*stripe.CheckoutSession, error) {
+
+	return m.CheckoutSession, m.Err
+}
+
+func (m *MockPaymentProvider) ExpireCheckoutSession(ctx context.Context, checkoutSessionID string) error {
+	return m.Err
+}
+
+func (m *MockPaymentProvider) RefundPayment(
+	ctx context.Context,
+	paymentIntentID,
+	idempotencyKey string) (*stripe.Refund, error) {
+
+	return m.Refund, m.Err
+}
+
+func (m *MockPaymentProvider) RefundPaymentAmount(
+	ctx context.Context,
+	paymentIntentID string,
+	amount decimal.Decimal,
+	idempotencyKey string) (*stripe.Refund, error) {
+
+	return m.Refund, m.Err
+}
+
+func (m *MockPaymentProvider) CreateShowtimeChangeCheckoutSession(
+	ctx context.Context,
+	user *domain.User,
+	change domain.ShowtimeChange,
+	payment domain.Payment,
+	expiresAt time.Time) (*stripe.CheckoutSession, error) {
 
 	return m.CheckoutSession, m.Err
 }
+
+func (m *MockPaymentProvider) GetPaymentReceipt(
+	ctx context.Context,
+	paymentIntentID string) (*domain.PaymentReceipt, error) {
+
+	return m.Receipt, m.Err
+}
+
+func (m *MockPaymentProvider) ListBalanceTransactions(
+	ctx context.Context,
+	from,
+	to time.Time) ([]domain.BalanceTransaction, error) {
+
+	return m.Transactions, m.Err
+}
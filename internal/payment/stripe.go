@@ -1,18 +1,30 @@
 package payment
 
 import (
+	"context"
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/metinatakli/movie-reservation-system/internal/domain"
 	"github.com/shopspring/decimal"
 	"github.com/stripe/stripe-go/v82"
+	"github.com/stripe/stripe-go/v82/balancetransaction"
 	"github.com/stripe/stripe-go/v82/checkout/session"
+	"github.com/stripe/stripe-go/v82/paymentintent"
+	"github.com/stripe/stripe-go/v82/refund"
 )
 
 type StripePaymentProvider struct {
 	failureUrl string
 	successUrl string
+	// the clients are nil when the globally configured Stripe backend is used
+	sessions       *session.Client
+	refunds        *refund.Client
+	paymentIntents *paymentintent.Client
+	balance        *balancetransaction.Client
 }
 
 func NewStripePaymentProvider(failureUrl, successUrl string) *StripePaymentProvider {
@@ -22,19 +34,32 @@ func NewStripePaymentProvider(failureUrl, successUrl string) *StripePaymentProvi
 	}
 }
 
+// WithBackend sends the requests of the provider to the given backend instead of the global one,
+// e.g. to stripe-mock in tests.
+func (s *StripePaymentProvider) WithBackend(backend stripe.Backend, key string) *StripePaymentProvider {
+	s.sessions = &session.Client{B: backend, Key: key}
+	s.refunds = &refund.Client{B: backend, Key: key}
+	s.paymentIntents = &paymentintent.Client{B: backend, Key: key}
+	s.balance = &balancetransaction.Client{B: backend, Key: key}
+	return s
+}
+
 func (s *StripePaymentProvider) CreateCheckoutSession(
+	ctx context.Context,
 	sessionId string,
 	user *domain.User,
 	cart domain.Cart,
-	payment domain.Payment) (*stripe.CheckoutSession, error) {
+	payment domain.Payment,
+	expiresAt time.Time) (*stripe.CheckoutSession, error) {
 
 	var lineItems []*stripe.CheckoutSessionLineItemParams
 
-	for _, seat := range cart.Seats {
+	breakdown := cart.PriceBreakdown()
+
+	for _, seat := range breakdown.Seats {
 		seatLabel := fmt.Sprintf("Row %d Seat %d", seat.Row, seat.Col)
 
-		seatPrice := cart.BasePrice.Add(seat.ExtraPrice)
-		priceCents := seatPrice.Mul(decimal.NewFromInt(100)).IntPart()
+		priceCents := toCents(seat.Price)
 
 		lineItem := &stripe.CheckoutSessionLineItemParams{
 			PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
@@ -57,20 +82,378 @@ func (s *StripePaymentProvider) CreateCheckoutSession(
 		lineItems = append(lineItems, lineItem)
 	}
 
+	for _, addOn := range breakdown.AddOns {
+		lineItems = append(lineItems, &stripe.CheckoutSessionLineItemParams{
+			PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
+				Currency:   stripe.String(string(stripe.CurrencyUSD)),
+				UnitAmount: stripe.Int64(toCents(addOn.Amount)),
+				ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
+					Name: stripe.String(fmt.Sprintf("%s - %s", addOn.Description, cart.MovieName)),
+				},
+			},
+			Quantity: stripe.Int64(1),
+		})
+	}
+
+	successUrl, failureUrl := s.redirectURLs(ctx)
+
 	params := &stripe.CheckoutSessionParams{
 		LineItems:  lineItems,
 		Mode:       stripe.String(string(stripe.CheckoutSessionModePayment)),
-		SuccessURL: stripe.String(s.successUrl),
-		CancelURL:  stripe.String(s.failureUrl),
+		SuccessURL: stripe.String(successUrl),
+		CancelURL:  stripe.String(failureUrl),
 		Metadata: map[string]string{
-			"cart_id":    cart.Id,
-			"session_id": sessionId,
-			"user_id":    strconv.Itoa(user.ID),
-			"payment_id": strconv.Itoa(payment.ID),
+			domain.CheckoutMetadataCartID:    cart.Id,
+			domain.CheckoutMetadataSessionID: sessionId,
+			domain.CheckoutMetadataUserID:    strconv.Itoa(user.ID),
+			domain.CheckoutMetadataPaymentID: strconv.Itoa(payment.ID),
+		},
+		CustomerEmail: &user.Email,
+	}
+
+	// guests have no user to refer to
+	if user.ID != 0 {
+		params.ClientReferenceID = stripe.String(strconv.Itoa(user.ID))
+	}
+
+	return s.newCheckoutSession(ctx, params, payment, expiresAt)
+}
+
+// CreateShowtimeChangeCheckoutSession charges the price difference of moving a reservation as a single
+// line item. The seats are already paid, so they aren't itemized again.
+func (s *StripePaymentProvider) CreateShowtimeChangeCheckoutSession(
+	ctx context.Context,
+	user *domain.User,
+	change domain.ShowtimeChange,
+	payment domain.Payment,
+	expiresAt time.Time) (*stripe.CheckoutSession, error) {
+
+	successUrl, failureUrl := s.redirectURLs(ctx)
+
+	params := &stripe.CheckoutSessionParams{
+		LineItems: []*stripe.CheckoutSessionLineItemParams{
+			{
+				PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
+					Currency:   stripe.String(string(stripe.CurrencyUSD)),
+					UnitAmount: stripe.Int64(toCents(change.PriceDifference)),
+					ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
+						Name: stripe.String(fmt.Sprintf("🎬 %s - Showtime change", change.MovieName)),
+						Description: stripe.String(fmt.Sprintf(
+							"Price difference of %d seat(s) for the showtime on %s",
+							len(change.Seats),
+							change.Date.Format("Jan 2, 2006 15:04"),
+						)),
+					},
+				},
+				Quantity: stripe.Int64(1),
+			},
+		},
+		Mode:       stripe.String(string(stripe.CheckoutSessionModePayment)),
+		SuccessURL: stripe.String(successUrl),
+		CancelURL:  stripe.String(failureUrl),
+		Metadata: map[string]string{
+			domain.CheckoutMetadataUserID:        strconv.Itoa(user.ID),
+			domain.CheckoutMetadataPaymentID:     strconv.Itoa(payment.ID),
+			domain.CheckoutMetadataReservationID: strconv.Itoa(change.ReservationID),
 		},
 		CustomerEmail:     &user.Email,
 		ClientReferenceID: stripe.String(strconv.Itoa(user.ID)),
 	}
 
+	return s.newCheckoutSession(ctx, params, payment, expiresAt)
+}
+
+func (s *StripePaymentProvider) redirectURLs(ctx context.Context) (string, string) {
+	successUrl, failureUrl := s.successUrl, s.failureUrl
+
+	tenant := domain.TenantFromContext(ctx)
+	if tenant != nil {
+		if tenant.SuccessURL != "" {
+			successUrl = tenant.SuccessURL
+		}
+		if tenant.FailureURL != "" {
+			failureUrl = tenant.FailureURL
+		}
+	}
+
+	return successUrl, failureUrl
+}
+
+func (s *StripePaymentProvider) newCheckoutSession(
+	ctx context.Context,
+	params *stripe.CheckoutSessionParams,
+	payment domain.Payment,
+	expiresAt time.Time) (*stripe.CheckoutSession, error) {
+
+	params.Context = ctx
+	params.ExpiresAt = stripe.Int64(expiresAt.Unix())
+
+	// the tenant's brand is charged directly on its connected account
+	if tenant := domain.TenantFromContext(ctx); tenant != nil && tenant.StripeAccountID != "" {
+		params.SetStripeAccount(tenant.StripeAccountID)
+	}
+
+	// the platform is charged and the theater's operator gets the amount left after the application fee
+	if payout := payment.Payout; payout != nil {
+		params.PaymentIntentData = &stripe.CheckoutSessionPaymentIntentDataParams{
+			ApplicationFeeAmount: stripe.Int64(toCents(payout.ApplicationFee)),
+			TransferData: &stripe.CheckoutSessionPaymentIntentDataTransferDataParams{
+				Destination: stripe.String(payout.AccountID),
+			},
+		}
+	}
+
+	// lets a checkout session found in the Stripe dashboard be traced back to the API request logs
+	if requestID := middleware.GetReqID(ctx); requestID != "" {
+		params.Metadata[domain.CheckoutMetadataRequestID] = requestID
+	}
+
+	if s.sessions != nil {
+		return s.sessions.New(params)
+	}
+
 	return session.New(params)
 }
+
+func (s *StripePaymentProvider) ExpireCheckoutSession(ctx context.Context, checkoutSessionID string) error {
+	getParams := &stripe.CheckoutSessionParams{}
+	getParams.Context = ctx
+
+	expireParams := &stripe.CheckoutSessionExpireParams{}
+	expireParams.Context = ctx
+
+	if tenant := domain.TenantFromContext(ctx); tenant != nil && tenant.StripeAccountID != "" {
+		getParams.SetStripeAccount(tenant.StripeAccountID)
+		expireParams.SetStripeAccount(tenant.StripeAccountID)
+	}
+
+	var checkoutSession *stripe.CheckoutSession
+	var err error
+
+	if s.sessions != nil {
+		checkoutSession, err = s.sessions.Get(checkoutSessionID, getParams)
+	} else {
+		checkoutSession, err = session.Get(checkoutSessionID, getParams)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	switch checkoutSession.Status {
+	case stripe.CheckoutSessionStatusComplete:
+		return domain.ErrCartAlreadyPaid
+	case stripe.CheckoutSessionStatusExpired:
+		return nil
+	}
+
+	if s.sessions != nil {
+		_, err = s.sessions.Expire(checkoutSessionID, expireParams)
+	} else {
+		_, err = session.Expire(checkoutSessionID, expireParams)
+	}
+
+	return err
+}
+
+func (s *StripePaymentProvider) RefundPayment(
+	ctx context.Context,
+	paymentIntentID,
+	idempotencyKey string) (*stripe.Refund, error) {
+
+	return s.refund(ctx, paymentIntentID, nil, idempotencyKey)
+}
+
+func (s *StripePaymentProvider) RefundPaymentAmount(
+	ctx context.Context,
+	paymentIntentID string,
+	amount decimal.Decimal,
+	idempotencyKey string) (*stripe.Refund, error) {
+
+	return s.refund(ctx, paymentIntentID, &amount, idempotencyKey)
+}
+
+// refund refunds the amount of the payment intent, all of it when amount is nil.
+func (s *StripePaymentProvider) refund(
+	ctx context.Context,
+	paymentIntentID string,
+	amount *decimal.Decimal,
+	idempotencyKey string) (*stripe.Refund, error) {
+
+	intent, err := s.getPaymentIntent(ctx, paymentIntentID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	params := &stripe.RefundParams{
+		PaymentIntent: stripe.String(paymentIntentID),
+	}
+
+	if amount != nil {
+		params.Amount = stripe.Int64(toCents(*amount))
+	}
+
+	// the operator's share is taken back from the connected account, and the platform gives up its fee,
+	// instead of the platform covering the whole refund
+	if intent.TransferData != nil {
+		params.ReverseTransfer = stripe.Bool(true)
+		params.RefundApplicationFee = stripe.Bool(true)
+	}
+
+	params.Context = ctx
+	params.SetIdempotencyKey(idempotencyKey)
+
+	// direct charges of the tenant's brand are refunded on its connected account
+	if tenant := domain.TenantFromContext(ctx); tenant != nil && tenant.StripeAccountID != "" {
+		params.SetStripeAccount(tenant.StripeAccountID)
+	}
+
+	if s.refunds != nil {
+		return s.refunds.New(params)
+	}
+
+	return refund.New(params)
+}
+
+func (s *StripePaymentProvider) GetPaymentReceipt(
+	ctx context.Context,
+	paymentIntentID string) (*domain.PaymentReceipt, error) {
+
+	intent, err := s.getPaymentIntent(ctx, paymentIntentID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	receipt := &domain.PaymentReceipt{}
+
+	charge := intent.LatestCharge
+	if charge == nil {
+		return receipt, nil
+	}
+
+	receipt.ReceiptURL = charge.ReceiptURL
+
+	if charge.PaymentMethodDetails != nil && charge.PaymentMethodDetails.Card != nil {
+		receipt.CardBrand = string(charge.PaymentMethodDetails.Card.Brand)
+		receipt.CardLast4 = charge.PaymentMethodDetails.Card.Last4
+	}
+
+	return receipt, nil
+}
+
+// CheckKey makes a read-only request to Stripe to check that the secret key is accepted.
+func (s *StripePaymentProvider) CheckKey(ctx context.Context) error {
+	params := &stripe.BalanceTransactionListParams{}
+	params.Context = ctx
+	params.Limit = stripe.Int64(1)
+	params.Single = true
+
+	var iter *balancetransaction.Iter
+	if s.balance != nil {
+		iter = s.balance.List(params)
+	} else {
+		iter = balancetransaction.List(params)
+	}
+
+	iter.Next()
+
+	return iter.Err()
+}
+
+func (s *StripePaymentProvider) ListBalanceTransactions(
+	ctx context.Context,
+	from,
+	to time.Time) ([]domain.BalanceTransaction, error) {
+
+	params := &stripe.BalanceTransactionListParams{
+		CreatedRange: &stripe.RangeQueryParams{
+			GreaterThanOrEqual: from.Unix(),
+			LesserThan:         to.Unix(),
+		},
+	}
+	params.Context = ctx
+	params.Limit = stripe.Int64(100)
+	// the payment intent is only known by the charge or the refund
+	params.AddExpand("data.source")
+
+	var iter *balancetransaction.Iter
+	if s.balance != nil {
+		iter = s.balance.List(params)
+	} else {
+		iter = balancetransaction.List(params)
+	}
+
+	var transactions []domain.BalanceTransaction
+
+	for iter.Next() {
+		txn := iter.BalanceTransaction()
+
+		transaction := domain.BalanceTransaction{
+			ID:        txn.ID,
+			Amount:    decimal.New(txn.Amount, -2),
+			Fee:       decimal.New(txn.Fee, -2),
+			Currency:  strings.ToUpper(string(txn.Currency)),
+			CreatedAt: time.Unix(txn.Created, 0),
+		}
+
+		if txn.Source != nil {
+			transaction.SourceID = txn.Source.ID
+		}
+
+		switch txn.Type {
+		case stripe.BalanceTransactionTypeCharge, stripe.BalanceTransactionTypePayment:
+			transaction.Type = domain.BalanceTransactionCharge
+
+			if txn.Source != nil && txn.Source.Charge != nil && txn.Source.Charge.PaymentIntent != nil {
+				transaction.PaymentIntentID = txn.Source.Charge.PaymentIntent.ID
+			}
+		case stripe.BalanceTransactionTypeRefund, stripe.BalanceTransactionTypePaymentRefund:
+			transaction.Type = domain.BalanceTransactionRefund
+
+			if txn.Source != nil && txn.Source.Refund != nil && txn.Source.Refund.PaymentIntent != nil {
+				transaction.PaymentIntentID = txn.Source.Refund.PaymentIntent.ID
+			}
+		case stripe.BalanceTransactionTypePayout:
+			transaction.Type = domain.BalanceTransactionPayout
+		default:
+			// transfers to the theaters, fees and disputes are not reconciled with payments
+			continue
+		}
+
+		transactions = append(transactions, transaction)
+	}
+
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	return transactions, nil
+}
+
+func (s *StripePaymentProvider) getPaymentIntent(
+	ctx context.Context,
+	paymentIntentID string,
+	expandCharge bool) (*stripe.PaymentIntent, error) {
+
+	params := &stripe.PaymentIntentParams{}
+	params.Context = ctx
+
+	// the payment intents of direct charges live on the tenant's connected account
+	if tenant := domain.TenantFromContext(ctx); tenant != nil && tenant.StripeAccountID != "" {
+		params.SetStripeAccount(tenant.StripeAccountID)
+	}
+
+	if expandCharge {
+		params.AddExpand("latest_charge")
+	}
+
+	if s.paymentIntents != nil {
+		return s.paymentIntents.Get(paymentIntentID, params)
+	}
+
+	return paymentintent.Get(paymentIntentID, params)
+}
+
+func toCents(amount decimal.Decimal) int64 {
+	return amount.Mul(decimal.NewFromInt(100)).IntPart()
+}
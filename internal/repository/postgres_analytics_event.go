@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+type PostgresAnalyticsEventRepository struct {
+	db DB
+}
+
+func NewPostgresAnalyticsEventRepository(db DB) *PostgresAnalyticsEventRepository {
+	return &PostgresAnalyticsEventRepository{
+		db: db,
+	}
+}
+
+func (p *PostgresAnalyticsEventRepository) CreateBatch(ctx context.Context, events []domain.AnalyticsEvent) error {
+	rows := make([][]any, len(events))
+	for i, e := range events {
+		rows[i] = []any{string(e.Name), e.SessionHash, e.ShowtimeID, e.OccurredAt}
+	}
+
+	_, err := p.db.CopyFrom(
+		ctx,
+		pgx.Identifier{"analytics_events"},
+		[]string{"name", "session_hash", "showtime_id", "occurred_at"},
+		pgx.CopyFromRows(rows),
+	)
+
+	return err
+}
@@ -0,0 +1,456 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+// maxAnnouncementsPerDelivery bounds the number of announcements delivered in a single transaction.
+const maxAnnouncementsPerDelivery = 10
+
+type PostgresAnnouncementRepository struct {
+	db DB
+}
+
+func NewPostgresAnnouncementRepository(db DB) *PostgresAnnouncementRepository {
+	return &PostgresAnnouncementRepository{
+		db: db,
+	}
+}
+
+const announcementColumns = `id, title, body, audience, theater_id, send_email, status, scheduled_at,
+	COALESCE(created_by, 0), recipient_count, created_at, sent_at, cancelled_at`
+
+func scanAnnouncement(row pgx.Row, announcement *domain.Announcement) error {
+	return row.Scan(
+		&announcement.ID,
+		&announcement.Title,
+		&announcement.Body,
+		&announcement.Audience,
+		&announcement.TheaterID,
+		&announcement.SendEmail,
+		&announcement.Status,
+		&announcement.ScheduledAt,
+		&announcement.CreatedBy,
+		&announcement.RecipientCount,
+		&announcement.CreatedAt,
+		&announcement.SentAt,
+		&announcement.CancelledAt)
+}
+
+func (p *PostgresAnnouncementRepository) Create(ctx context.Context, announcement *domain.Announcement) error {
+	query := `
+		INSERT INTO announcements (title, body, audience, theater_id, send_email, scheduled_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, status, created_at`
+
+	err := p.db.QueryRow(ctx,
+		query,
+		announcement.Title,
+		announcement.Body,
+		announcement.Audience,
+		announcement.TheaterID,
+		announcement.SendEmail,
+		announcement.ScheduledAt,
+		announcement.CreatedBy).Scan(&announcement.ID, &announcement.Status, &announcement.CreatedAt)
+
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.ForeignKeyViolation {
+			return domain.ErrTheaterNotFound
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (p *PostgresAnnouncementRepository) Cancel(ctx context.Context, id int) (*domain.Announcement, error) {
+	query := `
+		UPDATE announcements
+		SET status = 'cancelled', cancelled_at = NOW()
+		WHERE id = $1 AND status = 'scheduled'
+		RETURNING ` + announcementColumns
+
+	announcement := &domain.Announcement{}
+
+	err := scanAnnouncement(p.db.QueryRow(ctx, query, id), announcement)
+	if err == nil {
+		return announcement, nil
+	}
+
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	var exists bool
+
+	err = p.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM announcements WHERE id = $1)`, id).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, domain.ErrRecordNotFound
+	}
+
+	return nil, domain.ErrAnnouncementNotCancellable
+}
+
+func (p *PostgresAnnouncementRepository) DeliverDue(ctx context.Context, now time.Time) ([]domain.Announcement, error) {
+	var delivered []domain.Announcement
+
+	err := runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		query := `
+			SELECT ` + announcementColumns + `
+			FROM announcements
+			WHERE status = 'scheduled' AND scheduled_at <= $1
+			ORDER BY scheduled_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED`
+
+		rows, err := tx.Query(ctx, query, now, maxAnnouncementsPerDelivery)
+		if err != nil {
+			return err
+		}
+
+		var due []domain.Announcement
+
+		for rows.Next() {
+			var announcement domain.Announcement
+
+			if err := scanAnnouncement(rows, &announcement); err != nil {
+				rows.Close()
+				return err
+			}
+
+			due = append(due, announcement)
+		}
+
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, announcement := range due {
+			count, err := deliverAnnouncement(ctx, tx, announcement, now)
+			if err != nil {
+				return err
+			}
+
+			query = `
+				UPDATE announcements
+				SET status = 'sent', sent_at = $2, recipient_count = $3
+				WHERE id = $1`
+
+			_, err = tx.Exec(ctx, query, announcement.ID, now, count)
+			if err != nil {
+				return err
+			}
+
+			announcement.Status = domain.AnnouncementSent
+			announcement.SentAt = &now
+			announcement.RecipientCount = &count
+
+			delivered = append(delivered, announcement)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return delivered, nil
+}
+
+// deliverAnnouncement inserts a notification for every user in the audience of the announcement and returns
+// their count. When the announcement is also sent by email, the email of every recipient is queued with their
+// notification, the emails of users in digest mode are left to their next digest.
+func deliverAnnouncement(
+	ctx context.Context,
+	tx pgx.Tx,
+	announcement domain.Announcement,
+	now time.Time) (int, error) {
+
+	query := `
+		INSERT INTO notifications (user_id, announcement_id, title, body, created_at, digest_pending,
+			email_next_attempt_at)
+		SELECT u.id, $1, $2, $3, $4, $7 AND COALESCE(up.notification_digest, false),
+			CASE WHEN $7 AND NOT COALESCE(up.notification_digest, false) THEN $4::timestamptz END
+		FROM users u
+		LEFT JOIN user_preferences up ON up.user_id = u.id
+		WHERE u.activated = true AND u.is_active = true
+			AND (
+				$5 = 'all'
+				OR EXISTS (
+					SELECT 1
+					FROM reservations r
+					JOIN showtimes s ON s.id = r.showtime_id
+					JOIN halls h ON h.id = s.hall_id
+					WHERE r.user_id = u.id
+						AND r.status <> 'cancelled'
+						AND s.start_time > $4
+						AND h.theater_id = $6
+				)
+			)
+		ON CONFLICT ON CONSTRAINT unique_user_announcement DO NOTHING`
+
+	cmd, err := tx.Exec(ctx,
+		query,
+		announcement.ID,
+		announcement.Title,
+		announcement.Body,
+		now,
+		announcement.Audience,
+		announcement.TheaterID,
+		announcement.SendEmail)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return int(cmd.RowsAffected()), nil
+}
+
+func (p *PostgresAnnouncementRepository) GetDueEmails(
+	ctx context.Context,
+	now time.Time,
+	limit int) ([]domain.AnnouncementEmail, error) {
+
+	query := `
+		SELECT n.id, u.id, u.first_name, u.email, n.title, n.body, n.email_attempts,
+			COALESCE(n.email_last_error, ''), n.email_next_attempt_at
+		FROM notifications n
+		JOIN users u ON u.id = n.user_id
+		WHERE n.email_next_attempt_at <= $1 AND u.is_active = true
+		ORDER BY n.email_next_attempt_at, n.id
+		LIMIT $2`
+
+	rows, err := p.db.Query(ctx, query, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var emails []domain.AnnouncementEmail
+
+	for rows.Next() {
+		var e domain.AnnouncementEmail
+
+		err = rows.Scan(
+			&e.NotificationID,
+			&e.UserID,
+			&e.FirstName,
+			&e.Email,
+			&e.Title,
+			&e.Body,
+			&e.Attempts,
+			&e.LastError,
+			&e.NextAttemptAt)
+		if err != nil {
+			return nil, err
+		}
+
+		emails = append(emails, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return emails, nil
+}
+
+func (p *PostgresAnnouncementRepository) MarkEmailSent(ctx context.Context, notificationID int, sentAt time.Time) error {
+	query := `
+		UPDATE notifications
+		SET emailed_at = $2, email_next_attempt_at = NULL
+		WHERE id = $1`
+
+	_, err := p.db.Exec(ctx, query, notificationID, sentAt)
+
+	return err
+}
+
+func (p *PostgresAnnouncementRepository) RecordEmailFailure(ctx context.Context, email domain.AnnouncementEmail) error {
+	query := `
+		UPDATE notifications
+		SET email_attempts = $2, email_last_error = $3, email_next_attempt_at = $4
+		WHERE id = $1`
+
+	_, err := p.db.Exec(ctx, query, email.NotificationID, email.Attempts, email.LastError, email.NextAttemptAt)
+
+	return err
+}
+
+func (p *PostgresAnnouncementRepository) GetDueDigests(
+	ctx context.Context,
+	sentBefore time.Time,
+	limit int) ([]domain.NotificationDigest, error) {
+
+	query := `
+		WITH due AS (
+			SELECT u.id, u.first_name, u.email
+			FROM users u
+			LEFT JOIN user_preferences up ON up.user_id = u.id
+			WHERE u.is_active = true
+				AND (up.digest_sent_at IS NULL OR up.digest_sent_at < $1)
+				AND EXISTS (SELECT 1 FROM notifications n WHERE n.user_id = u.id AND n.digest_pending)
+			ORDER BY u.id
+			LIMIT $2
+		)
+		SELECT due.id, due.first_name, due.email, n.id, n.announcement_id, n.title, n.body, n.created_at, n.read_at
+		FROM due
+		JOIN notifications n ON n.user_id = due.id AND n.digest_pending
+		ORDER BY due.id, n.created_at, n.id`
+
+	rows, err := p.db.Query(ctx, query, sentBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	digests := make([]domain.NotificationDigest, 0)
+
+	for rows.Next() {
+		var digest domain.NotificationDigest
+		var notification domain.Notification
+
+		err := rows.Scan(
+			&digest.UserID,
+			&digest.FirstName,
+			&digest.Email,
+			&notification.ID,
+			&notification.AnnouncementID,
+			&notification.Title,
+			&notification.Body,
+			&notification.CreatedAt,
+			&notification.ReadAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		notification.UserID = digest.UserID
+
+		// rows of a user are adjacent, so a new user starts a new digest
+		if len(digests) == 0 || digests[len(digests)-1].UserID != digest.UserID {
+			digests = append(digests, digest)
+		}
+
+		last := &digests[len(digests)-1]
+		last.Notifications = append(last.Notifications, notification)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return digests, nil
+}
+
+func (p *PostgresAnnouncementRepository) MarkDigestSent(
+	ctx context.Context,
+	userID int,
+	notificationIDs []int,
+	sentAt time.Time) error {
+
+	return runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		query := `
+			UPDATE notifications
+			SET digest_pending = false
+			WHERE user_id = $1 AND id = ANY($2)`
+
+		_, err := tx.Exec(ctx, query, userID, notificationIDs)
+		if err != nil {
+			return err
+		}
+
+		// notifications only wait for a digest of users in digest mode, who always have preferences
+		query = `
+			UPDATE user_preferences
+			SET digest_sent_at = $2
+			WHERE user_id = $1`
+
+		_, err = tx.Exec(ctx, query, userID, sentAt)
+
+		return err
+	})
+}
+
+func (p *PostgresAnnouncementRepository) GetNotificationsByUserId(
+	ctx context.Context,
+	userId int,
+	pagination domain.Pagination) ([]domain.Notification, *domain.Metadata, error) {
+
+	query := `
+		SELECT COUNT(*) OVER(), id, user_id, announcement_id, title, body, created_at, read_at
+		FROM notifications
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := p.db.Query(ctx, query, userId, pagination.Limit(), pagination.Offset())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	notifications := make([]domain.Notification, 0)
+	totalRecords := 0
+
+	for rows.Next() {
+		var notification domain.Notification
+
+		err := rows.Scan(
+			&totalRecords,
+			&notification.ID,
+			&notification.UserID,
+			&notification.AnnouncementID,
+			&notification.Title,
+			&notification.Body,
+			&notification.CreatedAt,
+			&notification.ReadAt,
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		notifications = append(notifications, notification)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	metadata := domain.NewMetadata(totalRecords, pagination.Page, pagination.PageSize)
+
+	return notifications, metadata, nil
+}
+
+func (p *PostgresAnnouncementRepository) MarkNotificationRead(ctx context.Context, userId, notificationId int) error {
+	query := `
+		UPDATE notifications
+		SET read_at = COALESCE(read_at, NOW())
+		WHERE id = $1 AND user_id = $2`
+
+	cmd, err := p.db.Exec(ctx, query, notificationId, userId)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return domain.ErrRecordNotFound
+	}
+
+	return nil
+}
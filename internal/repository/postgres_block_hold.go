@@ -0,0 +1,277 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+const blockHoldColumns = `id, showtime_id, seat_ids, reason, note, status, expires_at, reservation_id, created_by, created_at`
+
+type PostgresBlockHoldRepository struct {
+	db DB
+}
+
+func NewPostgresBlockHoldRepository(db DB) *PostgresBlockHoldRepository {
+	return &PostgresBlockHoldRepository{
+		db: db,
+	}
+}
+
+func (p *PostgresBlockHoldRepository) Create(ctx context.Context, hold *domain.BlockHold) error {
+	return runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		query := `
+			INSERT INTO block_holds (showtime_id, seat_ids, reason, note, expires_at, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id, status, created_at`
+
+		err := tx.QueryRow(
+			ctx,
+			query,
+			hold.ShowtimeID,
+			hold.SeatIDs,
+			hold.Reason,
+			hold.Note,
+			hold.ExpiresAt,
+			hold.CreatedBy).Scan(&hold.ID, &hold.Status, &hold.CreatedAt)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.ForeignKeyViolation {
+				return domain.ErrRecordNotFound
+			}
+
+			return err
+		}
+
+		// the held seats must be in the showtime's hall
+		query = `
+			INSERT INTO seat_blocks (seat_id, showtime_id, reason, note, created_by, block_hold_id)
+			SELECT se.id, sh.id, $3, $4, $5, $6
+			FROM showtimes sh
+			JOIN seats se ON se.hall_id = sh.hall_id
+			WHERE sh.id = $1 AND se.id = ANY($2)`
+
+		cmdTag, err := tx.Exec(
+			ctx,
+			query,
+			hold.ShowtimeID,
+			hold.SeatIDs,
+			domain.SeatBlockEvent,
+			hold.Reason,
+			hold.CreatedBy,
+			hold.ID)
+		if err != nil {
+			return err
+		}
+
+		if cmdTag.RowsAffected() != int64(len(hold.SeatIDs)) {
+			return domain.ErrRecordNotFound
+		}
+
+		return nil
+	})
+}
+
+func (p *PostgresBlockHoldRepository) Get(ctx context.Context, id int) (*domain.BlockHold, error) {
+	query := `SELECT ` + blockHoldColumns + ` FROM block_holds WHERE id = $1`
+
+	hold, err := scanBlockHold(p.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrRecordNotFound
+		}
+
+		return nil, err
+	}
+
+	return hold, nil
+}
+
+func (p *PostgresBlockHoldRepository) GetActiveByShowtime(ctx context.Context, showtimeID int) ([]domain.BlockHold, error) {
+	query := `
+		SELECT ` + blockHoldColumns + `
+		FROM block_holds
+		WHERE showtime_id = $1 AND status = 'active'
+		ORDER BY expires_at, id`
+
+	rows, err := p.db.Query(ctx, query, showtimeID)
+	if err != nil {
+		return nil, err
+	}
+
+	return collectBlockHolds(rows)
+}
+
+func (p *PostgresBlockHoldRepository) Confirm(
+	ctx context.Context,
+	id int,
+	now time.Time,
+	reservation *domain.Reservation,
+	payment *domain.Payment) error {
+
+	return runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		_, err := closeBlockHold(ctx, tx, id, domain.BlockHoldConfirmed, now)
+		if err != nil {
+			return err
+		}
+
+		err = insertCompReservation(ctx, tx, reservation, payment)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `UPDATE block_holds SET reservation_id = $2 WHERE id = $1`, id, reservation.ID)
+
+		return err
+	})
+}
+
+func (p *PostgresBlockHoldRepository) Release(ctx context.Context, id int) (*domain.BlockHold, error) {
+	var hold *domain.BlockHold
+
+	err := runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		var err error
+
+		// a hold past its expiry which isn't expired yet may still be released
+		hold, err = closeBlockHold(ctx, tx, id, domain.BlockHoldReleased, time.Time{})
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return hold, nil
+}
+
+func (p *PostgresBlockHoldRepository) Expire(ctx context.Context, now time.Time) ([]domain.BlockHold, error) {
+	var holds []domain.BlockHold
+
+	err := runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		query := `
+			UPDATE block_holds
+			SET status = 'expired', closed_at = $1
+			WHERE status = 'active' AND expires_at <= $1
+			RETURNING ` + blockHoldColumns
+
+		rows, err := tx.Query(ctx, query, now)
+		if err != nil {
+			return err
+		}
+
+		holds, err = collectBlockHolds(rows)
+		if err != nil {
+			return err
+		}
+
+		ids := make([]int, len(holds))
+		for i, hold := range holds {
+			ids[i] = hold.ID
+		}
+
+		_, err = tx.Exec(ctx, `DELETE FROM seat_blocks WHERE block_hold_id = ANY($1)`, ids)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return holds, nil
+}
+
+// closeBlockHold moves an active hold to the given status, unblocks its seats and returns it. A hold which
+// expired by now is not closed, unless now is zero.
+func closeBlockHold(
+	ctx context.Context,
+	tx pgx.Tx,
+	id int,
+	status domain.BlockHoldStatus,
+	now time.Time) (*domain.BlockHold, error) {
+
+	query := `
+		UPDATE block_holds
+		SET status = $2, closed_at = NOW()
+		WHERE id = $1 AND status = 'active' AND ($3::timestamptz IS NULL OR expires_at > $3)
+		RETURNING ` + blockHoldColumns
+
+	var expiredBy *time.Time
+	if !now.IsZero() {
+		expiredBy = &now
+	}
+
+	hold, err := scanBlockHold(tx.QueryRow(ctx, query, id, status, expiredBy))
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+
+		var exists bool
+
+		err = tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM block_holds WHERE id = $1)`, id).Scan(&exists)
+		if err != nil {
+			return nil, err
+		}
+
+		if !exists {
+			return nil, domain.ErrRecordNotFound
+		}
+
+		return nil, domain.ErrBlockHoldNotActive
+	}
+
+	_, err = tx.Exec(ctx, `DELETE FROM seat_blocks WHERE block_hold_id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return hold, nil
+}
+
+func scanBlockHold(row pgx.Row) (*domain.BlockHold, error) {
+	var hold domain.BlockHold
+
+	err := row.Scan(
+		&hold.ID,
+		&hold.ShowtimeID,
+		&hold.SeatIDs,
+		&hold.Reason,
+		&hold.Note,
+		&hold.Status,
+		&hold.ExpiresAt,
+		&hold.ReservationID,
+		&hold.CreatedBy,
+		&hold.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &hold, nil
+}
+
+func collectBlockHolds(rows pgx.Rows) ([]domain.BlockHold, error) {
+	defer rows.Close()
+
+	holds := make([]domain.BlockHold, 0)
+
+	for rows.Next() {
+		hold, err := scanBlockHold(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		holds = append(holds, *hold)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return holds, nil
+}
@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+type PostgresDeviceSessionRepository struct {
+	db DB
+}
+
+func NewPostgresDeviceSessionRepository(db DB) *PostgresDeviceSessionRepository {
+	return &PostgresDeviceSessionRepository{
+		db: db,
+	}
+}
+
+func (p *PostgresDeviceSessionRepository) Create(ctx context.Context, session *domain.DeviceSession) (*domain.DeviceSession, error) {
+	var replaced *domain.DeviceSession
+
+	err := runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		query := `
+			DELETE FROM device_sessions
+			WHERE user_id = $1 AND device_id = $2
+			RETURNING id, access_token_hash`
+
+		var old domain.DeviceSession
+
+		err := tx.QueryRow(ctx, query, session.UserID, session.DeviceID).Scan(&old.ID, &old.AccessTokenHash)
+		if err == nil {
+			replaced = &old
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+
+		query = `
+			INSERT INTO device_sessions (user_id, device_id, device_name, refresh_token_hash, access_token_hash, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id, last_used_at, created_at`
+
+		return tx.QueryRow(
+			ctx,
+			query,
+			session.UserID,
+			session.DeviceID,
+			session.DeviceName,
+			session.RefreshTokenHash,
+			session.AccessTokenHash,
+			session.ExpiresAt,
+		).Scan(&session.ID, &session.LastUsedAt, &session.CreatedAt)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return replaced, nil
+}
+
+func (p *PostgresDeviceSessionRepository) Rotate(
+	ctx context.Context,
+	refreshTokenHash []byte,
+	next *domain.DeviceSession) (*domain.DeviceSession, error) {
+
+	var current domain.DeviceSession
+	var reused bool
+
+	err := runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		query := `
+			SELECT id, user_id, device_id, device_name, refresh_token_hash, access_token_hash,
+				refresh_token_hash = $1
+			FROM device_sessions
+			WHERE (refresh_token_hash = $1 OR previous_refresh_token_hash = $1) AND expires_at > NOW()
+			FOR UPDATE`
+
+		var isCurrent bool
+
+		err := tx.QueryRow(ctx, query, refreshTokenHash).Scan(
+			&current.ID,
+			&current.UserID,
+			&current.DeviceID,
+			&current.DeviceName,
+			&current.RefreshTokenHash,
+			&current.AccessTokenHash,
+			&isCurrent,
+		)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return domain.ErrRecordNotFound
+			}
+
+			return err
+		}
+
+		// the deletion has to be committed, so the reuse is reported once the transaction is done
+		if !isCurrent {
+			reused = true
+			_, err = tx.Exec(ctx, `DELETE FROM device_sessions WHERE id = $1`, current.ID)
+
+			return err
+		}
+
+		query = `
+			UPDATE device_sessions
+			SET previous_refresh_token_hash = refresh_token_hash,
+				refresh_token_hash = $2,
+				access_token_hash = $3,
+				expires_at = $4,
+				last_used_at = NOW()
+			WHERE id = $1`
+
+		_, err = tx.Exec(ctx, query, current.ID, next.RefreshTokenHash, next.AccessTokenHash, next.ExpiresAt)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the reused session is deleted, so its access token has to be invalidated by the caller too
+	if reused {
+		return &current, domain.ErrRefreshTokenReused
+	}
+
+	return &current, nil
+}
+
+func (p *PostgresDeviceSessionRepository) Revoke(ctx context.Context, refreshTokenHash []byte) (*domain.DeviceSession, error) {
+	query := `
+		DELETE FROM device_sessions
+		WHERE refresh_token_hash = $1
+		RETURNING id, user_id, device_id, access_token_hash`
+
+	var session domain.DeviceSession
+
+	err := p.db.QueryRow(ctx, query, refreshTokenHash).Scan(
+		&session.ID,
+		&session.UserID,
+		&session.DeviceID,
+		&session.AccessTokenHash,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrRecordNotFound
+		}
+
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func (p *PostgresDeviceSessionRepository) RevokeAllForUser(ctx context.Context, userID int) ([]domain.DeviceSession, error) {
+	query := `
+		DELETE FROM device_sessions
+		WHERE user_id = $1
+		RETURNING id, user_id, device_id, access_token_hash`
+
+	rows, err := p.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []domain.DeviceSession
+
+	for rows.Next() {
+		var session domain.DeviceSession
+
+		err = rows.Scan(&session.ID, &session.UserID, &session.DeviceID, &session.AccessTokenHash)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+type PostgresDisputeRepository struct {
+	db DB
+}
+
+func NewPostgresDisputeRepository(db DB) *PostgresDisputeRepository {
+	return &PostgresDisputeRepository{
+		db: db,
+	}
+}
+
+func (p *PostgresDisputeRepository) Record(
+	ctx context.Context,
+	paymentIntentID string,
+	dispute *domain.Dispute,
+	revokeTickets bool) (bool, error) {
+
+	created := false
+
+	err := runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		query := `
+			SELECT p.id, r.id
+			FROM payments p
+			LEFT JOIN reservations r ON r.payment_id = p.id
+			WHERE p.stripe_payment_intent_id = $1`
+
+		err := tx.QueryRow(ctx, query, paymentIntentID).Scan(&dispute.PaymentID, &dispute.ReservationID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return domain.ErrRecordNotFound
+			}
+
+			return err
+		}
+
+		// xmax is zero only for freshly inserted rows
+		query = `
+			INSERT INTO disputes (
+				payment_id, reservation_id, stripe_dispute_id, amount, currency, reason, status, evidence_due_by
+			)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (stripe_dispute_id) DO UPDATE
+			SET status = EXCLUDED.status,
+				reason = EXCLUDED.reason,
+				amount = EXCLUDED.amount,
+				evidence_due_by = EXCLUDED.evidence_due_by,
+				updated_at = NOW()
+			WHERE disputes.status NOT IN ('won', 'lost', 'warning_closed', 'prevented')
+			RETURNING id, created_at, updated_at, xmax = 0`
+
+		err = tx.QueryRow(
+			ctx,
+			query,
+			dispute.PaymentID,
+			dispute.ReservationID,
+			dispute.StripeDisputeID,
+			dispute.Amount,
+			dispute.Currency,
+			dispute.Reason,
+			dispute.Status,
+			dispute.EvidenceDueBy,
+		).Scan(&dispute.ID, &dispute.CreatedAt, &dispute.UpdatedAt, &created)
+		if err != nil {
+			// the dispute is already closed, nothing to update
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil
+			}
+
+			return err
+		}
+
+		if !created || dispute.ReservationID == nil {
+			return nil
+		}
+
+		query = `
+			UPDATE reservations
+			SET disputed_at = COALESCE(disputed_at, NOW()),
+				tickets_revoked_at = CASE WHEN $2 THEN COALESCE(tickets_revoked_at, NOW()) ELSE tickets_revoked_at END,
+				updated_at = NOW()
+			WHERE id = $1`
+
+		_, err = tx.Exec(ctx, query, *dispute.ReservationID, revokeTickets)
+		return err
+	})
+
+	return created, err
+}
+
+func (p *PostgresDisputeRepository) List(
+	ctx context.Context,
+	status string,
+	pagination domain.Pagination) ([]domain.Dispute, *domain.Metadata, error) {
+
+	query := `
+		SELECT COUNT(*) OVER(), id, payment_id, reservation_id, stripe_dispute_id, amount, currency, reason,
+			status, evidence_due_by, created_at, updated_at
+		FROM disputes
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := p.db.Query(ctx, query, status, pagination.Limit(), pagination.Offset())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	disputes := make([]domain.Dispute, 0)
+	totalRecords := 0
+
+	for rows.Next() {
+		var dispute domain.Dispute
+
+		err := rows.Scan(
+			&totalRecords,
+			&dispute.ID,
+			&dispute.PaymentID,
+			&dispute.ReservationID,
+			&dispute.StripeDisputeID,
+			&dispute.Amount,
+			&dispute.Currency,
+			&dispute.Reason,
+			&dispute.Status,
+			&dispute.EvidenceDueBy,
+			&dispute.CreatedAt,
+			&dispute.UpdatedAt,
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		disputes = append(disputes, dispute)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	metadata := domain.NewMetadata(totalRecords, pagination.Page, pagination.PageSize)
+
+	return disputes, metadata, nil
+}
@@ -0,0 +1,285 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+// marketingConsentCondition holds for a user u whose latest confirmed decision on marketing emails is an
+// opt-in. The preference alone isn't enough, campaigns only go to users with a confirmed consent on record.
+const marketingConsentCondition = `COALESCE((
+	SELECT c.granted
+	FROM consents c
+	WHERE c.user_id = u.id AND c.purpose = 'marketing_emails' AND c.confirmed_at IS NOT NULL
+	ORDER BY c.confirmed_at DESC, c.id DESC
+	LIMIT 1), false)`
+
+type PostgresEmailCampaignRepository struct {
+	db DB
+}
+
+func NewPostgresEmailCampaignRepository(db DB) *PostgresEmailCampaignRepository {
+	return &PostgresEmailCampaignRepository{
+		db: db,
+	}
+}
+
+func (p *PostgresEmailCampaignRepository) Create(ctx context.Context, campaign *domain.EmailCampaign) error {
+	return runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		query := `
+			INSERT INTO email_campaigns (movie_id, subject, body, favorite_theater_id, latitude, longitude, radius_km, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING id, created_at`
+
+		err := tx.QueryRow(ctx,
+			query,
+			campaign.MovieID,
+			campaign.Subject,
+			campaign.Body,
+			campaign.Filter.FavoriteTheaterID,
+			campaign.Filter.Latitude,
+			campaign.Filter.Longitude,
+			campaign.Filter.RadiusKm,
+			campaign.CreatedBy).Scan(&campaign.ID, &campaign.CreatedAt)
+
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.ForeignKeyViolation {
+				if pgErr.ConstraintName == "email_campaigns_favorite_theater_id_fkey" {
+					return domain.ErrTheaterNotFound
+				}
+
+				return domain.ErrMovieNotFound
+			}
+
+			return err
+		}
+
+		// the location of a user is their stored coordinates, or the location of their favorite theater
+		query = `
+			INSERT INTO email_campaign_deliveries (campaign_id, user_id)
+			SELECT $1, u.id
+			FROM users u
+			JOIN user_preferences up ON up.user_id = u.id
+			LEFT JOIN theaters t ON t.id = up.favorite_theater_id
+			WHERE u.activated = true AND u.is_active = true AND up.marketing_emails = true
+				AND ` + marketingConsentCondition + `
+				AND ($2::bigint IS NULL OR up.favorite_theater_id = $2)
+				AND ($3::double precision IS NULL OR ST_DWithin(
+					COALESCE(ST_SetSRID(ST_MakePoint(up.longitude, up.latitude), 4326)::geography, t.location),
+					ST_SetSRID(ST_MakePoint($4, $3), 4326)::geography,
+					$5 * 1000))`
+
+		result, err := tx.Exec(ctx,
+			query,
+			campaign.ID,
+			campaign.Filter.FavoriteTheaterID,
+			campaign.Filter.Latitude,
+			campaign.Filter.Longitude,
+			campaign.Filter.RadiusKm)
+
+		if err != nil {
+			return err
+		}
+
+		campaign.RecipientCount = int(result.RowsAffected())
+
+		return nil
+	})
+}
+
+func (p *PostgresEmailCampaignRepository) GetPendingDeliveries(
+	ctx context.Context,
+	limit int) ([]domain.CampaignDelivery, error) {
+
+	skipQuery := `
+		UPDATE email_campaign_deliveries d
+		SET status = 'skipped'
+		WHERE d.status = 'pending'
+			AND NOT EXISTS (
+				SELECT 1
+				FROM users u
+				JOIN user_preferences up ON up.user_id = u.id
+				WHERE u.id = d.user_id AND u.is_active = true AND up.marketing_emails = true
+					AND ` + marketingConsentCondition + `
+			)`
+
+	_, err := p.db.Exec(ctx, skipQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT d.campaign_id, d.user_id, u.email, u.first_name, c.subject, c.body, m.title, m.release_date, m.poster_url
+		FROM email_campaign_deliveries d
+		JOIN email_campaigns c ON c.id = d.campaign_id
+		JOIN movies m ON m.id = c.movie_id
+		JOIN users u ON u.id = d.user_id
+		WHERE d.status = 'pending'
+		ORDER BY d.campaign_id, d.user_id
+		LIMIT $1`
+
+	rows, err := p.db.Query(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]domain.CampaignDelivery, 0)
+
+	for rows.Next() {
+		var delivery domain.CampaignDelivery
+
+		err = rows.Scan(
+			&delivery.CampaignID,
+			&delivery.UserID,
+			&delivery.Email,
+			&delivery.FirstName,
+			&delivery.Subject,
+			&delivery.Body,
+			&delivery.MovieTitle,
+			&delivery.ReleaseDate,
+			&delivery.PosterUrl,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (p *PostgresEmailCampaignRepository) MarkDeliverySent(
+	ctx context.Context,
+	campaignID, userID int,
+	tokenHash []byte) error {
+
+	query := `
+		UPDATE email_campaign_deliveries
+		SET status = 'sent', token_hash = $3, sent_at = NOW()
+		WHERE campaign_id = $1 AND user_id = $2`
+
+	_, err := p.db.Exec(ctx, query, campaignID, userID, tokenHash)
+
+	return err
+}
+
+func (p *PostgresEmailCampaignRepository) MarkDeliveryFailed(
+	ctx context.Context,
+	campaignID, userID int,
+	errMsg string) error {
+
+	query := `
+		UPDATE email_campaign_deliveries
+		SET status = 'failed', error = $3
+		WHERE campaign_id = $1 AND user_id = $2`
+
+	_, err := p.db.Exec(ctx, query, campaignID, userID, errMsg)
+
+	return err
+}
+
+func (p *PostgresEmailCampaignRepository) RecordOpen(ctx context.Context, tokenHash []byte) error {
+	query := `
+		UPDATE email_campaign_deliveries
+		SET opened_at = COALESCE(opened_at, NOW())
+		WHERE token_hash = $1`
+
+	result, err := p.db.Exec(ctx, query, tokenHash)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (p *PostgresEmailCampaignRepository) Unsubscribe(ctx context.Context, tokenHash []byte) error {
+	return runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		query := `
+			UPDATE email_campaign_deliveries
+			SET unsubscribed_at = COALESCE(unsubscribed_at, NOW())
+			WHERE token_hash = $1
+			RETURNING user_id`
+
+		var userID int
+
+		err := tx.QueryRow(ctx, query, tokenHash).Scan(&userID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return domain.ErrRecordNotFound
+			}
+
+			return err
+		}
+
+		query = `
+			UPDATE user_preferences
+			SET marketing_emails = false, updated_at = NOW()
+			WHERE user_id = $1 AND marketing_emails = true`
+
+		result, err := tx.Exec(ctx, query, userID)
+		if err != nil {
+			return err
+		}
+
+		if result.RowsAffected() == 0 {
+			return nil
+		}
+
+		return insertConsent(ctx, tx, userID, domain.ConsentMarketingEmails, false, domain.ConsentSourceUnsubscribe)
+	})
+}
+
+func (p *PostgresEmailCampaignRepository) GetStats(ctx context.Context, campaignID int) (*domain.CampaignStats, error) {
+	query := `
+		SELECT
+			c.id,
+			COUNT(d.user_id),
+			COUNT(*) FILTER (WHERE d.status = 'pending'),
+			COUNT(*) FILTER (WHERE d.status = 'sent'),
+			COUNT(*) FILTER (WHERE d.status = 'failed'),
+			COUNT(*) FILTER (WHERE d.status = 'skipped'),
+			COUNT(d.opened_at),
+			COUNT(d.unsubscribed_at)
+		FROM email_campaigns c
+		LEFT JOIN email_campaign_deliveries d ON d.campaign_id = c.id
+		WHERE c.id = $1
+		GROUP BY c.id`
+
+	stats := &domain.CampaignStats{}
+
+	err := p.db.QueryRow(ctx, query, campaignID).Scan(
+		&stats.CampaignID,
+		&stats.Recipients,
+		&stats.Pending,
+		&stats.Sent,
+		&stats.Failed,
+		&stats.Skipped,
+		&stats.Opened,
+		&stats.Unsubscribed)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrRecordNotFound
+		}
+
+		return nil, err
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+type PostgresLifecycleEventRepository struct {
+	db DB
+}
+
+func NewPostgresLifecycleEventRepository(db DB) *PostgresLifecycleEventRepository {
+	return &PostgresLifecycleEventRepository{
+		db: db,
+	}
+}
+
+func (p *PostgresLifecycleEventRepository) Due(
+	ctx context.Context,
+	now time.Time,
+	limit int) ([]domain.LifecycleEvent, error) {
+
+	// an event waits for the earlier events of its user which are retried, so the CRM gets the events of a
+	// user in order, e.g. the deletion after the registration
+	query := `
+		SELECT e.id, e.event_type, e.user_id, e.payload, e.occurred_at, e.attempts, COALESCE(e.last_error, ''),
+			e.next_attempt_at
+		FROM lifecycle_events e
+		WHERE e.next_attempt_at <= $1
+			AND NOT EXISTS (
+				SELECT 1
+				FROM lifecycle_events earlier
+				WHERE earlier.user_id = e.user_id AND earlier.id < e.id AND earlier.next_attempt_at IS NOT NULL
+			)
+		ORDER BY e.id
+		LIMIT $2`
+
+	rows, err := p.db.Query(ctx, query, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []domain.LifecycleEvent
+
+	for rows.Next() {
+		var e domain.LifecycleEvent
+
+		err = rows.Scan(
+			&e.ID,
+			&e.Type,
+			&e.UserID,
+			&e.Payload,
+			&e.OccurredAt,
+			&e.Attempts,
+			&e.LastError,
+			&e.NextAttemptAt)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (p *PostgresLifecycleEventRepository) MarkDelivered(ctx context.Context, id int, deliveredAt time.Time) error {
+	query := `
+		UPDATE lifecycle_events
+		SET delivered_at = $2, next_attempt_at = NULL
+		WHERE id = $1`
+
+	_, err := p.db.Exec(ctx, query, id, deliveredAt)
+
+	return err
+}
+
+func (p *PostgresLifecycleEventRepository) RecordFailure(ctx context.Context, event domain.LifecycleEvent) error {
+	query := `
+		UPDATE lifecycle_events
+		SET attempts = $2, last_error = $3, next_attempt_at = $4
+		WHERE id = $1`
+
+	_, err := p.db.Exec(ctx, query, event.ID, event.Attempts, event.LastError, event.NextAttemptAt)
+
+	return err
+}
+
+func (p *PostgresLifecycleEventRepository) DeleteDeliveredBefore(
+	ctx context.Context,
+	cutoff time.Time,
+	dryRun bool) (int64, error) {
+
+	condition := `delivered_at < $1`
+
+	if dryRun {
+		var count int64
+
+		err := p.db.QueryRow(ctx, `SELECT COUNT(*) FROM lifecycle_events WHERE `+condition, cutoff).Scan(&count)
+
+		return count, err
+	}
+
+	cmd, err := p.db.Exec(ctx, `DELETE FROM lifecycle_events WHERE `+condition, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	return cmd.RowsAffected(), nil
+}
+
+func (p *PostgresLifecycleEventRepository) Backlog(ctx context.Context) (*domain.LifecycleEventBacklog, error) {
+	query := `
+		SELECT
+			COUNT(*) FILTER (WHERE next_attempt_at IS NOT NULL),
+			COUNT(*) FILTER (WHERE next_attempt_at IS NULL),
+			MIN(occurred_at) FILTER (WHERE next_attempt_at IS NULL)
+		FROM lifecycle_events
+		WHERE delivered_at IS NULL`
+
+	var backlog domain.LifecycleEventBacklog
+
+	err := p.db.QueryRow(ctx, query).Scan(&backlog.Pending, &backlog.DeadLettered, &backlog.OldestDeadLetteredAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &backlog, nil
+}
@@ -6,15 +6,14 @@ import (
 	"fmt"
 
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/metinatakli/movie-reservation-system/internal/domain"
 )
 
 type PostgresMovieRepository struct {
-	db *pgxpool.Pool
+	db DB
 }
 
-func NewPostgresMovieRepository(db *pgxpool.Pool) *PostgresMovieRepository {
+func NewPostgresMovieRepository(db DB) *PostgresMovieRepository {
 	return &PostgresMovieRepository{
 		db: db,
 	}
@@ -29,10 +28,17 @@ func (p *PostgresMovieRepository) GetAll(
 		WHERE ((to_tsvector('english', title) @@ plainto_tsquery('english', $1) 
 			OR to_tsvector('english', description) @@ plainto_tsquery('english', $1))
 			OR $1 = '') 
+			AND ($4 = 0 OR tenant_id = $4)
+			AND archived_at IS NULL
 		ORDER BY %s %s
 		LIMIT $2 OFFSET $3`, pagination.SortColumn(), pagination.SortDirection())
 
-	rows, err := p.db.Query(ctx, query, pagination.Term, pagination.Limit(), pagination.Offset())
+	rows, err := p.db.Query(ctx,
+		query,
+		pagination.Term,
+		pagination.Limit(),
+		pagination.Offset(),
+		domain.TenantIDFromContext(ctx))
 	if err != nil {
 		return nil, nil, err
 	}
@@ -71,13 +77,13 @@ func (p *PostgresMovieRepository) GetAll(
 
 func (p *PostgresMovieRepository) GetById(ctx context.Context, id int) (*domain.Movie, error) {
 	query := `SELECT id, title, description, genres, language, release_date, duration, poster_url, director,
-	 cast_members, rating
+	 cast_members, rating, content_warnings, subtitle_languages, audio_description
 		FROM movies
-		WHERE id = $1`
+		WHERE id = $1 AND ($2 = 0 OR tenant_id = $2) AND archived_at IS NULL`
 
 	movie := &domain.Movie{}
 
-	err := p.db.QueryRow(ctx, query, id).Scan(
+	err := p.db.QueryRow(ctx, query, id, domain.TenantIDFromContext(ctx)).Scan(
 		&movie.ID,
 		&movie.Title,
 		&movie.Description,
@@ -88,7 +94,10 @@ func (p *PostgresMovieRepository) GetById(ctx context.Context, id int) (*domain.
 		&movie.PosterUrl,
 		&movie.Director,
 		&movie.CastMembers,
-		&movie.Rating)
+		&movie.Rating,
+		&movie.ContentWarnings,
+		&movie.SubtitleLanguages,
+		&movie.AudioDescription)
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -101,11 +110,150 @@ func (p *PostgresMovieRepository) GetById(ctx context.Context, id int) (*domain.
 	return movie, nil
 }
 
+func (p *PostgresMovieRepository) GetSitemapMovies(ctx context.Context, limit int) ([]domain.SitemapMovie, error) {
+	query := `
+		SELECT id, created_at
+		FROM movies
+		WHERE ($2 = 0 OR tenant_id = $2) AND archived_at IS NULL
+		ORDER BY created_at DESC, id DESC
+		LIMIT $1`
+
+	rows, err := p.db.Query(ctx, query, limit, domain.TenantIDFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movies := []domain.SitemapMovie{}
+
+	for rows.Next() {
+		var movie domain.SitemapMovie
+
+		err = rows.Scan(&movie.ID, &movie.LastModified)
+		if err != nil {
+			return nil, err
+		}
+
+		movies = append(movies, movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
+
 func (p *PostgresMovieRepository) ExistsById(ctx context.Context, id int) (bool, error) {
-	query := `SELECT EXISTS(SELECT 1 FROM movies WHERE id = $1)`
+	query := `SELECT EXISTS(
+		SELECT 1 FROM movies WHERE id = $1 AND ($2 = 0 OR tenant_id = $2) AND archived_at IS NULL)`
 
 	var exists bool
-	err := p.db.QueryRow(ctx, query, id).Scan(&exists)
+	err := p.db.QueryRow(ctx, query, id, domain.TenantIDFromContext(ctx)).Scan(&exists)
 
 	return exists, err
 }
+
+func (p *PostgresMovieRepository) Archive(ctx context.Context, id int) error {
+	query := `
+		UPDATE movies
+		SET archived_at = COALESCE(archived_at, NOW())
+		WHERE id = $1 AND ($2 = 0 OR tenant_id = $2)`
+
+	return p.setArchived(ctx, query, id)
+}
+
+func (p *PostgresMovieRepository) Restore(ctx context.Context, id int) error {
+	query := `
+		UPDATE movies
+		SET archived_at = NULL
+		WHERE id = $1 AND ($2 = 0 OR tenant_id = $2)`
+
+	return p.setArchived(ctx, query, id)
+}
+
+func (p *PostgresMovieRepository) setArchived(ctx context.Context, query string, id int) error {
+	result, err := p.db.Exec(ctx, query, id, domain.TenantIDFromContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (p *PostgresMovieRepository) Create(ctx context.Context, movie *domain.Movie) error {
+	// movies added outside of a tenant's domain belong to the default tenant
+	query := `
+		INSERT INTO movies (title, description, genres, language, release_date, duration, poster_url, director,
+			cast_members, content_warnings, subtitle_languages, audio_description, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, COALESCE(NULLIF($13, 0), 1))
+		RETURNING id`
+
+	return p.db.QueryRow(ctx, query,
+		movie.Title,
+		movie.Description,
+		movie.Genres,
+		movie.Language,
+		movie.ReleaseDate,
+		movie.Duration,
+		movie.PosterUrl,
+		movie.Director,
+		movie.CastMembers,
+		movie.ContentWarnings,
+		movie.SubtitleLanguages,
+		movie.AudioDescription,
+		domain.TenantIDFromContext(ctx)).Scan(&movie.ID)
+}
+
+func (p *PostgresMovieRepository) FindDuplicates(
+	ctx context.Context,
+	title string,
+	releaseYear int) ([]domain.MovieDuplicate, error) {
+
+	// titles are lowercased, punctuation becomes spaces and a leading article is dropped, so "The Matrix"
+	// and "Matrix." are the same title. A year of a tenant holds few movies, they're compared one by one.
+	query := `
+		WITH normalized AS (
+			SELECT id, title, release_date, archived_at IS NOT NULL AS archived,
+				similarity(
+					regexp_replace(trim(regexp_replace(lower(title), '[^[:alnum:]]+', ' ', 'g')), '^(the|a|an) ', ''),
+					regexp_replace(trim(regexp_replace(lower($1), '[^[:alnum:]]+', ' ', 'g')), '^(the|a|an) ', '')
+				) AS similarity
+			FROM movies
+			WHERE release_date >= make_date($2, 1, 1) AND release_date < make_date($2 + 1, 1, 1)
+				AND ($4 = 0 OR tenant_id = $4)
+		)
+		SELECT id, title, release_date, similarity, archived
+		FROM normalized
+		WHERE similarity >= $3
+		ORDER BY similarity DESC, id`
+
+	rows, err := p.db.Query(ctx, query, title, releaseYear, domain.MovieDuplicateSimilarity, domain.TenantIDFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	duplicates := []domain.MovieDuplicate{}
+
+	for rows.Next() {
+		var d domain.MovieDuplicate
+
+		err = rows.Scan(&d.ID, &d.Title, &d.ReleaseDate, &d.Similarity, &d.Archived)
+		if err != nil {
+			return nil, err
+		}
+
+		duplicates = append(duplicates, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return duplicates, nil
+}
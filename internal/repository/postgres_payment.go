@@ -2,35 +2,71 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/shopspring/decimal"
 )
 
 type PostgresPaymentRepository struct {
-	db *pgxpool.Pool
+	db DB
 }
 
-func NewPostgresPaymentRepository(db *pgxpool.Pool) *PostgresPaymentRepository {
+func NewPostgresPaymentRepository(db DB) *PostgresPaymentRepository {
 	return &PostgresPaymentRepository{
 		db: db,
 	}
 }
 
+// refundableAmount is what's left to refund of the payment p. Pending refunds count from when they are
+// queued, the Stripe refunds they were sent as are not counted again.
+const refundableAmount = `p.amount - (
+		SELECT COALESCE(SUM(pr.amount), 0)
+		FROM pending_refunds pr
+		WHERE pr.payment_id = p.id
+	) - (
+		SELECT COALESCE(SUM(rf.amount), 0)
+		FROM refunds rf
+		WHERE rf.payment_id = p.id
+			AND rf.status = 'succeeded'
+			AND NOT EXISTS (SELECT 1 FROM pending_refunds pr WHERE pr.stripe_refund_id = rf.stripe_refund_id)
+	)`
+
 func (p *PostgresPaymentRepository) Create(ctx context.Context, payment *domain.Payment) error {
 	query := `
 		INSERT INTO payments (
 			user_id, 
 			amount, 
 			currency,
-			status
+			status,
+			connected_account_id,
+			application_fee,
+			transfer_amount,
+			reservation_id,
+			sales_channel
 		)
-		VALUES ($1, $2, $3, $4)
+		VALUES (NULLIF($1, 0), $2, $3, $4, $5, $6, $7, $8, $9)
 		RETURNING id
 	`
 
+	var accountID *string
+	var applicationFee, transferAmount *decimal.Decimal
+
+	if payment.Payout != nil {
+		accountID = &payment.Payout.AccountID
+		applicationFee = &payment.Payout.ApplicationFee
+		transferAmount = &payment.Payout.TransferAmount
+	}
+
+	salesChannel := payment.SalesChannel
+	if salesChannel == "" {
+		salesChannel = domain.SalesChannelWeb
+	}
+
 	err := p.db.QueryRow(
 		ctx,
 		query,
@@ -38,6 +74,11 @@ func (p *PostgresPaymentRepository) Create(ctx context.Context, payment *domain.
 		payment.Amount,
 		payment.Currency,
 		payment.Status,
+		accountID,
+		applicationFee,
+		transferAmount,
+		payment.ReservationID,
+		salesChannel,
 	).Scan(&payment.ID)
 
 	return err
@@ -45,12 +86,16 @@ func (p *PostgresPaymentRepository) Create(ctx context.Context, payment *domain.
 
 func (p *PostgresPaymentRepository) GetById(ctx context.Context, id int) (*domain.Payment, error) {
 	query := `
-		SELECT id, user_id, stripe_checkout_session_id, amount, currency, status, error_message, 
-			payment_date, created_at, updated_at
+		SELECT id, COALESCE(user_id, 0), stripe_checkout_session_id, amount, currency, status, error_message, 
+			payment_date, created_at, updated_at, connected_account_id, application_fee, transfer_amount,
+			reservation_id
 		FROM payments
 		WHERE id = $1
 	`
 
+	var accountID *string
+	var applicationFee, transferAmount *decimal.Decimal
+
 	payment := &domain.Payment{}
 	err := p.db.QueryRow(ctx, query, id).Scan(
 		&payment.ID,
@@ -63,6 +108,10 @@ func (p *PostgresPaymentRepository) GetById(ctx context.Context, id int) (*domai
 		&payment.PaymentDate,
 		&payment.CreatedAt,
 		&payment.UpdatedAt,
+		&accountID,
+		&applicationFee,
+		&transferAmount,
+		&payment.ReservationID,
 	)
 
 	if err != nil {
@@ -73,6 +122,14 @@ func (p *PostgresPaymentRepository) GetById(ctx context.Context, id int) (*domai
 		return nil, err
 	}
 
+	if accountID != nil && applicationFee != nil && transferAmount != nil {
+		payment.Payout = &domain.PayoutSplit{
+			AccountID:      *accountID,
+			ApplicationFee: *applicationFee,
+			TransferAmount: *transferAmount,
+		}
+	}
+
 	return payment, nil
 }
 
@@ -90,3 +147,596 @@ func (p *PostgresPaymentRepository) UpdateStatus(
 	_, err := p.db.Exec(ctx, query, status, errMsg, checkoutSessionID)
 	return err
 }
+
+func (p *PostgresPaymentRepository) MarkUnfulfilled(
+	ctx context.Context,
+	paymentID int,
+	checkoutSessionID,
+	paymentIntentID,
+	errMsg string) error {
+
+	return p.markCompleted(ctx, paymentID, checkoutSessionID, paymentIntentID, &errMsg)
+}
+
+func (p *PostgresPaymentRepository) MarkCompleted(
+	ctx context.Context,
+	paymentID int,
+	checkoutSessionID,
+	paymentIntentID string) error {
+
+	return p.markCompleted(ctx, paymentID, checkoutSessionID, paymentIntentID, nil)
+}
+
+func (p *PostgresPaymentRepository) MarkCanceled(
+	ctx context.Context,
+	paymentID int,
+	checkoutSessionID,
+	errMsg string) error {
+
+	query := `UPDATE payments
+		SET status = 'canceled',
+			stripe_checkout_session_id = $1,
+			error_message = $2,
+			updated_at = NOW()
+		WHERE id = $3 AND status = 'pending'
+	`
+
+	cmd, err := p.db.Exec(ctx, query, checkoutSessionID, errMsg, paymentID)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return domain.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (p *PostgresPaymentRepository) markCompleted(
+	ctx context.Context,
+	paymentID int,
+	checkoutSessionID,
+	paymentIntentID string,
+	errMsg *string) error {
+
+	query := `UPDATE payments
+		SET status = 'completed',
+			stripe_checkout_session_id = $1,
+			stripe_payment_intent_id = NULLIF($2, ''),
+			error_message = $3,
+			payment_date = NOW(),
+			updated_at = NOW()
+		WHERE id = $4 AND status = 'pending'
+	`
+
+	cmd, err := p.db.Exec(ctx, query, checkoutSessionID, paymentIntentID, errMsg, paymentID)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return domain.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// AnonymizeCreatedBefore detaches settled payments created before the cutoff from their users and
+// drops provider references and error details. Amounts and statuses are kept for bookkeeping.
+// In dry-run mode the affected payments are only counted.
+func (p *PostgresPaymentRepository) AnonymizeCreatedBefore(
+	ctx context.Context,
+	cutoff time.Time,
+	dryRun bool) (int64, error) {
+
+	condition := `anonymized_at IS NULL AND created_at < $1 AND status <> 'pending'`
+
+	if dryRun {
+		var count int64
+
+		err := p.db.QueryRow(ctx, `SELECT COUNT(*) FROM payments WHERE `+condition, cutoff).Scan(&count)
+
+		return count, err
+	}
+
+	query := `UPDATE payments
+		SET user_id = NULL,
+			stripe_checkout_session_id = NULL,
+			stripe_payment_intent_id = NULL,
+			error_message = NULL,
+			anonymized_at = NOW(),
+			updated_at = NOW()
+		WHERE ` + condition
+
+	cmd, err := p.db.Exec(ctx, query, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	return cmd.RowsAffected(), nil
+}
+
+func (p *PostgresPaymentRepository) RecordRefund(
+	ctx context.Context,
+	paymentIntentID string,
+	refund *domain.Refund) (bool, error) {
+
+	applied := false
+
+	err := runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		// locking the payment serializes concurrent webhooks about the same payment
+		query := `SELECT id FROM payments WHERE stripe_payment_intent_id = $1 FOR UPDATE`
+
+		err := tx.QueryRow(ctx, query, paymentIntentID).Scan(&refund.PaymentID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return domain.ErrRecordNotFound
+			}
+
+			return err
+		}
+
+		var current domain.RefundStatus
+
+		query = `SELECT id, status FROM refunds WHERE stripe_refund_id = $1`
+
+		err = tx.QueryRow(ctx, query, refund.StripeRefundID).Scan(&refund.ID, &current)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			query = `
+				INSERT INTO refunds (payment_id, stripe_refund_id, amount, currency, status, failure_reason)
+				VALUES ($1, $2, $3, $4, $5, $6)
+				RETURNING id, created_at, updated_at`
+
+			err = tx.QueryRow(
+				ctx,
+				query,
+				refund.PaymentID,
+				refund.StripeRefundID,
+				refund.Amount,
+				refund.Currency,
+				refund.Status,
+				refund.FailureReason,
+			).Scan(&refund.ID, &refund.CreatedAt, &refund.UpdatedAt)
+			if err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		default:
+			if !current.CanTransitionTo(refund.Status) {
+				return nil
+			}
+
+			query = `
+				UPDATE refunds
+				SET status = $1, failure_reason = $2, amount = $3, updated_at = NOW()
+				WHERE id = $4
+				RETURNING created_at, updated_at`
+
+			err = tx.QueryRow(ctx, query, refund.Status, refund.FailureReason, refund.Amount, refund.ID).
+				Scan(&refund.CreatedAt, &refund.UpdatedAt)
+			if err != nil {
+				return err
+			}
+		}
+
+		applied = true
+
+		// keep the payment status in line with the refunded total, a succeeded refund may still fail later
+		query = `
+			UPDATE payments p
+			SET status = CASE WHEN s.total >= p.amount THEN 'refunded' ELSE 'completed' END,
+				updated_at = NOW()
+			FROM (
+				SELECT COALESCE(SUM(amount), 0) AS total
+				FROM refunds
+				WHERE payment_id = $1 AND status = 'succeeded'
+			) s
+			WHERE p.id = $1
+				AND p.status IN ('completed', 'refunded')
+				AND (s.total >= p.amount) <> (p.status = 'refunded')
+			RETURNING p.status`
+
+		var status domain.PaymentStatus
+
+		err = tx.QueryRow(ctx, query, refund.PaymentID).Scan(&status)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil
+			}
+
+			return err
+		}
+
+		if status != domain.PaymentStatusRefunded {
+			return nil
+		}
+
+		// the tickets of a reservation refunded in full are revoked, the new ticket version turns away the
+		// codes issued so far, signed codes verified offline included
+		query = `
+			UPDATE reservations
+			SET tickets_revoked_at = COALESCE(tickets_revoked_at, NOW()),
+				ticket_version = ticket_version + 1,
+				updated_at = NOW()
+			WHERE payment_id = $1`
+
+		_, err = tx.Exec(ctx, query, refund.PaymentID)
+		return err
+	})
+
+	return applied, err
+}
+
+// queueRefunds spreads the amount over the payments of the reservation which can still be refunded. The
+// latest payments are refunded first, the price differences of changes are paid after the reservation.
+func queueRefunds(
+	ctx context.Context,
+	tx pgx.Tx,
+	reservationID int,
+	amount decimal.Decimal,
+	reason string) ([]domain.PendingRefund, error) {
+
+	// the lock serializes the refunds of the reservation, so they can't take more than its payments left
+	_, err := tx.Exec(ctx, `SELECT id FROM reservations WHERE id = $1 FOR UPDATE`, reservationID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT p.id, p.stripe_payment_intent_id, ` + refundableAmount + `
+		FROM payments p
+		JOIN reservations r ON p.id = r.payment_id OR p.reservation_id = r.id
+		WHERE r.id = $1
+			AND p.status IN ('completed', 'refunded')
+			AND p.stripe_payment_intent_id IS NOT NULL
+		ORDER BY p.id DESC`
+
+	rows, err := tx.Query(ctx, query, reservationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refunds []domain.PendingRefund
+	left := amount
+
+	for rows.Next() {
+		refund := domain.PendingRefund{
+			ReservationID: reservationID,
+			Reason:        reason,
+		}
+
+		var refundable decimal.Decimal
+
+		err = rows.Scan(&refund.PaymentID, &refund.PaymentIntentID, &refundable)
+		if err != nil {
+			return nil, err
+		}
+
+		refund.Amount = decimal.Min(left, refundable)
+		if !refund.Amount.IsPositive() {
+			continue
+		}
+
+		refunds = append(refunds, refund)
+
+		left = left.Sub(refund.Amount)
+		if !left.IsPositive() {
+			break
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows.Close()
+
+	query = `
+		INSERT INTO pending_refunds (reservation_id, payment_id, amount, reason)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, next_attempt_at`
+
+	for i := range refunds {
+		refund := &refunds[i]
+
+		err = tx.QueryRow(ctx, query, refund.ReservationID, refund.PaymentID, refund.Amount, refund.Reason).
+			Scan(&refund.ID, &refund.NextAttemptAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return refunds, nil
+}
+
+func (p *PostgresPaymentRepository) DueRefunds(
+	ctx context.Context,
+	now time.Time,
+	limit int) ([]domain.PendingRefund, error) {
+
+	query := `
+		SELECT pr.id, pr.reservation_id, pr.payment_id, COALESCE(p.stripe_payment_intent_id, ''), pr.amount,
+			pr.reason, pr.attempts, COALESCE(pr.last_error, ''), pr.next_attempt_at
+		FROM pending_refunds pr
+		JOIN payments p ON p.id = pr.payment_id
+		WHERE pr.next_attempt_at <= $1
+		ORDER BY pr.id
+		LIMIT $2`
+
+	rows, err := p.db.Query(ctx, query, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refunds []domain.PendingRefund
+
+	for rows.Next() {
+		var r domain.PendingRefund
+
+		err = rows.Scan(
+			&r.ID,
+			&r.ReservationID,
+			&r.PaymentID,
+			&r.PaymentIntentID,
+			&r.Amount,
+			&r.Reason,
+			&r.Attempts,
+			&r.LastError,
+			&r.NextAttemptAt)
+		if err != nil {
+			return nil, err
+		}
+
+		refunds = append(refunds, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return refunds, nil
+}
+
+func (p *PostgresPaymentRepository) MarkRefundSent(
+	ctx context.Context,
+	id int,
+	stripeRefundID string,
+	sentAt time.Time) error {
+
+	query := `
+		UPDATE pending_refunds
+		SET stripe_refund_id = $2, sent_at = $3, next_attempt_at = NULL
+		WHERE id = $1`
+
+	_, err := p.db.Exec(ctx, query, id, stripeRefundID, sentAt)
+
+	return err
+}
+
+func (p *PostgresPaymentRepository) RecordRefundFailure(ctx context.Context, refund domain.PendingRefund) error {
+	query := `
+		UPDATE pending_refunds
+		SET attempts = $2, last_error = $3, next_attempt_at = $4
+		WHERE id = $1`
+
+	_, err := p.db.Exec(ctx, query, refund.ID, refund.Attempts, refund.LastError, refund.NextAttemptAt)
+
+	return err
+}
+
+func (p *PostgresPaymentRepository) GetHistoryByUserId(
+	ctx context.Context,
+	userId int,
+	pagination domain.Pagination) ([]domain.PaymentHistoryEntry, *domain.Metadata, error) {
+
+	query := `
+		SELECT
+			COUNT(*) OVER(),
+			p.id,
+			p.amount,
+			p.currency,
+			p.status,
+			p.payment_date,
+			p.created_at,
+			p.updated_at,
+			r.id,
+			(
+				SELECT COALESCE(jsonb_agg(jsonb_build_object(
+					'id', rf.id,
+					'paymentId', rf.payment_id,
+					'stripeRefundId', rf.stripe_refund_id,
+					'amount', rf.amount,
+					'currency', rf.currency,
+					'status', rf.status,
+					'failureReason', rf.failure_reason,
+					'createdAt', rf.created_at,
+					'updatedAt', rf.updated_at) ORDER BY rf.created_at), '[]')
+				FROM refunds rf
+				WHERE rf.payment_id = p.id
+			) AS refunds
+		FROM payments p
+		LEFT JOIN reservations r ON r.payment_id = p.id
+		WHERE p.user_id = $1 AND p.status <> 'pending'
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := p.db.Query(ctx, query, userId, pagination.Limit(), pagination.Offset())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]domain.PaymentHistoryEntry, 0)
+	totalRecords := 0
+
+	for rows.Next() {
+		var entry domain.PaymentHistoryEntry
+		var refundsJson json.RawMessage
+
+		err := rows.Scan(
+			&totalRecords,
+			&entry.ID,
+			&entry.Amount,
+			&entry.Currency,
+			&entry.Status,
+			&entry.PaymentDate,
+			&entry.CreatedAt,
+			&entry.UpdatedAt,
+			&entry.ReservationID,
+			&refundsJson,
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if err := json.Unmarshal(refundsJson, &entry.Refunds); err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal payment refunds: %w", err)
+		}
+
+		entry.UserID = userId
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	metadata := domain.NewMetadata(totalRecords, pagination.Page, pagination.PageSize)
+
+	return entries, metadata, nil
+}
+
+func (p *PostgresPaymentRepository) GetLedgerEntries(
+	ctx context.Context,
+	from,
+	to time.Time) ([]domain.LedgerEntry, error) {
+
+	// refunded payments were completed before, payments at the venue have no payment intent
+	query := `
+		SELECT p.id, p.stripe_payment_intent_id, '', p.amount, p.currency, p.payment_date
+		FROM payments p
+		WHERE p.status IN ('completed', 'refunded')
+			AND p.stripe_payment_intent_id IS NOT NULL
+			AND p.payment_date >= $1 AND p.payment_date < $2
+		UNION ALL
+		SELECT p.id, p.stripe_payment_intent_id, rf.stripe_refund_id, rf.amount, rf.currency, rf.created_at
+		FROM refunds rf
+		JOIN payments p ON p.id = rf.payment_id
+		WHERE rf.status = 'succeeded'
+			AND rf.created_at >= $1 AND rf.created_at < $2
+		ORDER BY 6, 1`
+
+	rows, err := p.db.Query(ctx, query, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var entries []domain.LedgerEntry
+
+	for rows.Next() {
+		var entry domain.LedgerEntry
+
+		err := rows.Scan(
+			&entry.PaymentID,
+			&entry.PaymentIntentID,
+			&entry.StripeRefundID,
+			&entry.Amount,
+			&entry.Currency,
+			&entry.RecordedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (p *PostgresPaymentRepository) SaveReconciliationReport(
+	ctx context.Context,
+	report *domain.ReconciliationReport) error {
+
+	totalsJson, err := json.Marshal(report.Totals)
+	if err != nil {
+		return err
+	}
+
+	mismatches := report.Mismatches
+	if mismatches == nil {
+		mismatches = []domain.ReconciliationMismatch{}
+	}
+
+	mismatchesJson, err := json.Marshal(mismatches)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO reconciliation_reports (report_date, checked_at, totals, mismatches, mismatch_count)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (report_date) DO UPDATE
+		SET checked_at = EXCLUDED.checked_at,
+			totals = EXCLUDED.totals,
+			mismatches = EXCLUDED.mismatches,
+			mismatch_count = EXCLUDED.mismatch_count`
+
+	_, err = p.db.Exec(
+		ctx,
+		query,
+		report.Date.Format(time.DateOnly),
+		report.CheckedAt,
+		totalsJson,
+		mismatchesJson,
+		len(mismatches),
+	)
+
+	return err
+}
+
+func (p *PostgresPaymentRepository) GetReconciliationReport(
+	ctx context.Context,
+	date time.Time) (*domain.ReconciliationReport, error) {
+
+	query := `
+		SELECT checked_at, totals, mismatches
+		FROM reconciliation_reports
+		WHERE report_date = $1`
+
+	var totalsJson, mismatchesJson json.RawMessage
+
+	report := &domain.ReconciliationReport{Date: date}
+
+	err := p.db.QueryRow(ctx, query, date.Format(time.DateOnly)).Scan(&report.CheckedAt, &totalsJson, &mismatchesJson)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrRecordNotFound
+		}
+
+		return nil, err
+	}
+
+	err = json.Unmarshal(totalsJson, &report.Totals)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal reconciliation totals: %w", err)
+	}
+
+	err = json.Unmarshal(mismatchesJson, &report.Mismatches)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal reconciliation mismatches: %w", err)
+	}
+
+	return report, nil
+}
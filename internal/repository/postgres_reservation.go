@@ -5,31 +5,44 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+	"time"
 
+	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/shopspring/decimal"
 )
 
 type PostgresReservationRepository struct {
-	db *pgxpool.Pool
+	db DB
 }
 
-func NewPostgresReservationRepository(db *pgxpool.Pool) *PostgresReservationRepository {
+func NewPostgresReservationRepository(db DB) *PostgresReservationRepository {
 	return &PostgresReservationRepository{
 		db: db,
 	}
 }
 
-func (p *PostgresReservationRepository) Create(ctx context.Context, reservation domain.Reservation) error {
+func (p *PostgresReservationRepository) Create(ctx context.Context, reservation *domain.Reservation) error {
 	return runInTx(ctx, p.db, func(tx pgx.Tx) error {
 		query := `
 			UPDATE payments
-			SET status = 'completed', stripe_checkout_session_id = $1, payment_date = NOW(), updated_at = NOW()
-			WHERE id = $2 AND status = 'pending'
+			SET status = 'completed',
+				stripe_checkout_session_id = $1,
+				stripe_payment_intent_id = NULLIF($2, ''),
+				payment_date = NOW(),
+				updated_at = NOW()
+			WHERE id = $3 AND status = 'pending'
 		`
 
-		cmdTag, err := tx.Exec(ctx, query, reservation.CheckoutSessionID, reservation.PaymentID)
+		cmdTag, err := tx.Exec(
+			ctx,
+			query,
+			reservation.CheckoutSessionID,
+			reservation.PaymentIntentID,
+			reservation.PaymentID)
 		if err != nil {
 			return err
 		}
@@ -41,47 +54,147 @@ func (p *PostgresReservationRepository) Create(ctx context.Context, reservation
 			)
 		}
 
-		query = `
-			INSERT INTO reservations (user_id, showtime_id, payment_id)
-			VALUES ($1, $2, $3)
-			RETURNING id
-		`
+		return insertReservation(ctx, tx, reservation)
+	})
+}
 
-		err = tx.QueryRow(
-			ctx,
-			query,
-			reservation.UserID,
-			reservation.ShowtimeID,
-			reservation.PaymentID).Scan(&reservation.ID)
+// CreateComp stores a complimentary reservation along with its zero amount payment, which is recorded with
+// the comp status so the reservation is accounted for like a paid one without being charged.
+func (p *PostgresReservationRepository) CreateComp(ctx context.Context, reservation *domain.Reservation, payment *domain.Payment) error {
+	return runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		return insertCompReservation(ctx, tx, reservation, payment)
+	})
+}
 
-		if err != nil {
-			return err
-		}
+// insertCompReservation inserts the settled payment of a reservation which isn't charged online and the
+// reservation itself.
+func insertCompReservation(ctx context.Context, tx pgx.Tx, reservation *domain.Reservation, payment *domain.Payment) error {
+	query := `
+		INSERT INTO payments (user_id, amount, currency, status, payment_date, sales_channel)
+		VALUES (NULLIF($1, 0), $2, $3, $4, NOW(), $5)
+		RETURNING id
+	`
 
-		rows := make([][]any, 0, len(reservation.ReservationSeats))
-		for _, seat := range reservation.ReservationSeats {
-			rows = append(rows, []any{
-				reservation.ID,
-				reservation.ShowtimeID,
-				seat.SeatID,
-			})
-		}
+	err := tx.QueryRow(
+		ctx,
+		query,
+		payment.UserID,
+		payment.Amount,
+		payment.Currency,
+		payment.Status,
+		payment.SalesChannel).Scan(&payment.ID)
+	if err != nil {
+		return err
+	}
 
-		_, err = tx.CopyFrom(
-			ctx,
-			pgx.Identifier{"reservation_seats"},
-			[]string{"reservation_id", "showtime_id", "seat_id"},
-			pgx.CopyFromRows(rows),
+	reservation.PaymentID = payment.ID
+
+	return insertReservation(ctx, tx, reservation)
+}
+
+// insertReservation inserts the reservation and its seats. A seat which already belongs to another
+// reservation of the showtime fails with domain.ErrSeatAlreadyReserved.
+func insertReservation(ctx context.Context, tx pgx.Tx, reservation *domain.Reservation) error {
+	query := `
+		INSERT INTO reservations (
+			user_id,
+			guest_email,
+			showtime_id,
+			payment_id,
+			note,
+			special_requests,
+			flexible_ticket,
+			sales_channel
 		)
-		if err != nil {
-			return err
+		VALUES (NULLIF($1, 0), NULLIF($2, ''), $3, $4, NULLIF($5, ''), $6, $7, $8)
+		RETURNING id
+	`
+
+	specialRequests := reservation.SpecialRequests
+	if specialRequests == nil {
+		specialRequests = []domain.SpecialRequest{}
+	}
+
+	salesChannel := reservation.SalesChannel
+	if salesChannel == "" {
+		salesChannel = domain.SalesChannelWeb
+	}
+
+	err := tx.QueryRow(
+		ctx,
+		query,
+		reservation.UserID,
+		reservation.GuestEmail,
+		reservation.ShowtimeID,
+		reservation.PaymentID,
+		reservation.Note,
+		specialRequests,
+		reservation.FlexibleTicket,
+		salesChannel).Scan(&reservation.ID)
+
+	if err != nil {
+		return err
+	}
+
+	err = copyReservationSeats(ctx, tx, reservation.ID, reservation.ShowtimeID, reservation.ReservationSeats)
+	if err != nil {
+		// the seat was sold to another reservation, e.g. after its lock expired during the checkout
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation && pgErr.ConstraintName == "unique_showtime_seat" {
+			return domain.ErrSeatAlreadyReserved
 		}
 
-		return nil
-	})
+		return err
+	}
+
+	return nil
+}
+
+func copyReservationSeats(
+	ctx context.Context,
+	tx pgx.Tx,
+	reservationId,
+	showtimeId int,
+	seats []domain.ReservationSeat) error {
+
+	rows := make([][]any, 0, len(seats))
+	for _, seat := range seats {
+		rows = append(rows, []any{
+			reservationId,
+			showtimeId,
+			seat.SeatID,
+			seat.ExtraPrice,
+			seat.PriceVersionID,
+			seat.BasePrice,
+			seat.FormatSurcharge,
+			seat.Discount,
+			seat.Tax,
+			seat.Price,
+		})
+	}
+
+	_, err := tx.CopyFrom(
+		ctx,
+		pgx.Identifier{"reservation_seats"},
+		[]string{
+			"reservation_id",
+			"showtime_id",
+			"seat_id",
+			"extra_price",
+			"price_version_id",
+			"base_price",
+			"format_surcharge",
+			"discount_amount",
+			"tax_amount",
+			"price",
+		},
+		pgx.CopyFromRows(rows),
+	)
+
+	return err
 }
 
-func runInTx(ctx context.Context, db *pgxpool.Pool, fn func(tx pgx.Tx) error) error {
+func runInTx(ctx context.Context, db DB, fn func(tx pgx.Tx) error) error {
 	var txOptions pgx.TxOptions
 
 	tx, err := db.BeginTx(ctx, txOptions)
@@ -157,7 +270,8 @@ func (p *PostgresReservationRepository) GetReservationsSummariesByUserId(
 			s.start_time,
 			t.name,
 			h.name,
-			r.created_at
+			r.created_at,
+			t.time_zone
 		FROM reservations r
 		JOIN showtimes s ON r.showtime_id = s.id
 		JOIN movies m ON s.movie_id = m.id
@@ -189,6 +303,7 @@ func (p *PostgresReservationRepository) GetReservationsSummariesByUserId(
 			&reservation.TheaterName,
 			&reservation.HallName,
 			&reservation.CreatedAt,
+			&reservation.TheaterTimeZone,
 		)
 		if err != nil {
 			return nil, nil, err
@@ -221,11 +336,38 @@ func (p *PostgresReservationRepository) GetByReservationIdAndUserId(
 			h.name,
 			r.created_at,
 			p.amount,
+			m.duration,
+			t.address,
+			t.district,
+			t.city,
+			t.time_zone,
+			ST_Y(t.location::geometry),
+			ST_X(t.location::geometry),
+			r.tickets_revoked_at,
+			r.ticket_version,
+			s.id,
+			COALESCE(r.note, ''),
+			r.special_requests,
+			r.flexible_ticket,
+			p.currency,
+			p.status,
+			COALESCE(p.stripe_payment_intent_id, ''),
+			(
+				SELECT COALESCE(SUM(rf.amount), 0)
+				FROM refunds rf
+				WHERE rf.payment_id = p.id AND rf.status = 'succeeded'
+			) AS refunded_amount,
+			EXISTS (
+				SELECT 1
+				FROM refunds rf
+				WHERE rf.payment_id = p.id AND rf.status IN ('pending', 'requires_action')
+			) AS refund_pending,
 			(
 				SELECT COALESCE(jsonb_agg(jsonb_build_object(
 					'row', s.seat_row, 
 					'col', s.seat_col, 
-					'type', s.seat_type)), '[]')
+					'type', s.seat_type,
+					'price', rs.price)), '[]')
 				FROM reservation_seats rs
 				JOIN seats s ON rs.seat_id = s.id
 				WHERE rs.reservation_id = r.id
@@ -254,7 +396,7 @@ func (p *PostgresReservationRepository) GetByReservationIdAndUserId(
 		JOIN movies m ON s.movie_id = m.id
 		JOIN halls h ON s.hall_id = h.id
 		JOIN theaters t ON h.theater_id = t.id
-		WHERE r.id = $1 AND r.user_id = $2
+		WHERE r.id = $1 AND r.user_id IS NOT DISTINCT FROM NULLIF($2, 0)
 		GROUP BY r.id, p.id, s.id, m.id, h.id, t.id
 	`
 
@@ -270,6 +412,24 @@ func (p *PostgresReservationRepository) GetByReservationIdAndUserId(
 		&reservationDetail.HallName,
 		&reservationDetail.CreatedAt,
 		&reservationDetail.TotalPrice,
+		&reservationDetail.MovieDuration,
+		&reservationDetail.TheaterAddress,
+		&reservationDetail.TheaterDistrict,
+		&reservationDetail.TheaterCity,
+		&reservationDetail.TheaterTimeZone,
+		&reservationDetail.TheaterLocation.Latitude,
+		&reservationDetail.TheaterLocation.Longitude,
+		&reservationDetail.TicketsRevokedAt,
+		&reservationDetail.TicketVersion,
+		&reservationDetail.ShowtimeID,
+		&reservationDetail.Note,
+		&reservationDetail.SpecialRequests,
+		&reservationDetail.FlexibleTicket,
+		&reservationDetail.Payment.Currency,
+		&reservationDetail.Payment.Status,
+		&reservationDetail.Payment.PaymentIntentID,
+		&reservationDetail.Payment.RefundedAmount,
+		&reservationDetail.Payment.RefundPending,
 		&seatsJson,
 		&hallAmenitiesJson,
 		&theaterAmenitiesJson,
@@ -283,6 +443,8 @@ func (p *PostgresReservationRepository) GetByReservationIdAndUserId(
 		return nil, err
 	}
 
+	reservationDetail.Payment.Amount = reservationDetail.TotalPrice
+
 	if err := json.Unmarshal(seatsJson, &reservationDetail.Seats); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal reservation seats: %w", err)
 	}
@@ -297,3 +459,1027 @@ func (p *PostgresReservationRepository) GetByReservationIdAndUserId(
 
 	return &reservationDetail, nil
 }
+
+func (p *PostgresReservationRepository) CancelUnpaidVenueReservations(
+	ctx context.Context,
+	startsBefore time.Time) ([]domain.Reservation, error) {
+
+	query := `
+		WITH cancelled AS (
+			UPDATE reservations r
+			SET status = 'cancelled', updated_at = NOW()
+			FROM showtimes sh
+			WHERE sh.id = r.showtime_id
+				AND r.status = 'pending-payment-at-venue'
+				AND sh.start_time <= $1
+				AND NOT EXISTS (SELECT 1 FROM check_ins c WHERE c.reservation_id = r.id)
+			RETURNING r.id, COALESCE(r.user_id, 0) AS user_id, r.showtime_id
+		)
+		DELETE FROM reservation_seats rs
+		USING cancelled c
+		WHERE rs.reservation_id = c.id
+		RETURNING c.id, c.user_id, c.showtime_id, rs.seat_id`
+
+	rows, err := p.db.Query(ctx, query, startsBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reservations []domain.Reservation
+	indexById := make(map[int]int)
+
+	for rows.Next() {
+		var reservation domain.Reservation
+		var seat domain.ReservationSeat
+
+		err := rows.Scan(&reservation.ID, &reservation.UserID, &reservation.ShowtimeID, &seat.SeatID)
+		if err != nil {
+			return nil, err
+		}
+
+		seat.ReservationID = reservation.ID
+		seat.ShowtimeID = reservation.ShowtimeID
+
+		i, ok := indexById[reservation.ID]
+		if !ok {
+			reservation.Status = domain.ReservationCancelled
+			reservations = append(reservations, reservation)
+			i = len(reservations) - 1
+			indexById[reservation.ID] = i
+		}
+
+		reservations[i].ReservationSeats = append(reservations[i].ReservationSeats, seat)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reservations, nil
+}
+
+func (p *PostgresReservationRepository) GetCheckInListByShowtime(
+	ctx context.Context,
+	showtimeId int) ([]domain.CheckInEntry, error) {
+
+	query := `
+		SELECT
+			r.id,
+			r.status,
+			COALESCE(u.first_name, ''),
+			COALESCE(u.last_name, ''),
+			COALESCE(r.note, ''),
+			r.special_requests,
+			r.tickets_revoked_at,
+			(
+				SELECT COALESCE(jsonb_agg(jsonb_build_object(
+					'row', s.seat_row,
+					'col', s.seat_col,
+					'type', s.seat_type) ORDER BY s.seat_row, s.seat_col), '[]')
+				FROM reservation_seats rs
+				JOIN seats s ON rs.seat_id = s.id
+				WHERE rs.reservation_id = r.id
+			) AS seats
+		FROM reservations r
+		LEFT JOIN users u ON u.id = r.user_id
+		WHERE r.showtime_id = $1 AND r.status != 'cancelled'
+		ORDER BY u.last_name, u.first_name, r.id`
+
+	rows, err := p.db.Query(ctx, query, showtimeId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]domain.CheckInEntry, 0)
+
+	for rows.Next() {
+		var entry domain.CheckInEntry
+		var seatsJson json.RawMessage
+
+		err = rows.Scan(
+			&entry.ReservationID,
+			&entry.Status,
+			&entry.GuestFirstName,
+			&entry.GuestLastName,
+			&entry.Note,
+			&entry.SpecialRequests,
+			&entry.TicketsRevokedAt,
+			&seatsJson,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(seatsJson, &entry.Seats); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal reservation seats: %w", err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (p *PostgresReservationRepository) GetTicketCheckIn(
+	ctx context.Context,
+	reservationId int) (*domain.TicketCheckIn, error) {
+
+	query := `
+		SELECT
+			r.id,
+			r.status,
+			r.ticket_version,
+			r.tickets_revoked_at,
+			st.id,
+			st.start_time,
+			COALESCE(st.pre_show_minutes, t.pre_show_minutes),
+			t.id,
+			t.check_in_opens_before,
+			t.check_in_late_after,
+			t.check_in_closes_after,
+			c.checked_in_at
+		FROM reservations r
+		JOIN showtimes st ON st.id = r.showtime_id
+		JOIN halls h ON h.id = st.hall_id
+		JOIN theaters t ON t.id = h.theater_id
+		LEFT JOIN check_ins c ON c.reservation_id = r.id
+		WHERE r.id = $1`
+
+	var checkIn domain.TicketCheckIn
+
+	err := p.db.QueryRow(ctx, query, reservationId).Scan(
+		&checkIn.ReservationID,
+		&checkIn.Status,
+		&checkIn.TicketVersion,
+		&checkIn.TicketsRevokedAt,
+		&checkIn.ShowtimeID,
+		&checkIn.StartTime,
+		&checkIn.PreShowMinutes,
+		&checkIn.TheaterID,
+		&checkIn.Policy.OpensBefore,
+		&checkIn.Policy.LateAfter,
+		&checkIn.Policy.ClosesAfter,
+		&checkIn.CheckedInAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrRecordNotFound
+		}
+
+		return nil, err
+	}
+
+	return &checkIn, nil
+}
+
+func (p *PostgresReservationRepository) CreateCheckIn(ctx context.Context, checkIn *domain.CheckIn) error {
+	query := `
+		INSERT INTO check_ins (reservation_id, showtime_id, checked_in_at, minutes_late, checked_in_by)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (reservation_id) DO NOTHING`
+
+	result, err := p.db.Exec(
+		ctx,
+		query,
+		checkIn.ReservationID,
+		checkIn.ShowtimeID,
+		checkIn.CheckedInAt,
+		checkIn.MinutesLate,
+		checkIn.CheckedInBy)
+	if err != nil {
+		return err
+	}
+
+	// the guests were let in at another entrance meanwhile
+	if result.RowsAffected() == 0 {
+		return domain.ErrAlreadyCheckedIn
+	}
+
+	return nil
+}
+
+func (p *PostgresReservationRepository) MarkNoShows(
+	ctx context.Context,
+	now, startedAfter time.Time) ([]domain.Reservation, error) {
+
+	// a reservation is updated once by the statement, so the no-shows are marked and released together
+	query := `
+		WITH no_shows AS (
+			UPDATE reservations r
+			SET no_show_at = $1,
+				status = CASE
+					WHEN r.status = 'pending-payment-at-venue'
+						OR EXISTS (SELECT 1 FROM payments p WHERE p.id = r.payment_id AND p.status = 'comp')
+					THEN 'cancelled'::reservation_status
+					ELSE r.status
+				END,
+				updated_at = NOW()
+			FROM showtimes sh
+			JOIN halls h ON h.id = sh.hall_id
+			JOIN theaters t ON t.id = h.theater_id
+			WHERE sh.id = r.showtime_id
+				AND r.status != 'cancelled'
+				AND r.no_show_at IS NULL
+				AND sh.start_time > $2
+				AND sh.start_time + make_interval(
+					mins => COALESCE(sh.pre_show_minutes, t.pre_show_minutes) + t.check_in_closes_after) <= $1
+				AND NOT EXISTS (SELECT 1 FROM check_ins c WHERE c.reservation_id = r.id)
+			RETURNING r.id, COALESCE(r.user_id, 0) AS user_id, r.showtime_id, r.status
+		),
+		released AS (
+			DELETE FROM reservation_seats rs
+			USING no_shows n
+			WHERE rs.reservation_id = n.id AND n.status = 'cancelled'
+			RETURNING rs.reservation_id, rs.seat_id
+		)
+		SELECT n.id, n.user_id, n.showtime_id, n.status, rel.seat_id
+		FROM no_shows n
+		LEFT JOIN released rel ON rel.reservation_id = n.id
+		ORDER BY n.id`
+
+	rows, err := p.db.Query(ctx, query, now, startedAfter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reservations []domain.Reservation
+	indexById := make(map[int]int)
+
+	for rows.Next() {
+		var reservation domain.Reservation
+		var seatId *int
+
+		err := rows.Scan(&reservation.ID, &reservation.UserID, &reservation.ShowtimeID, &reservation.Status, &seatId)
+		if err != nil {
+			return nil, err
+		}
+
+		i, ok := indexById[reservation.ID]
+		if !ok {
+			reservations = append(reservations, reservation)
+			i = len(reservations) - 1
+			indexById[reservation.ID] = i
+		}
+
+		if seatId != nil {
+			reservations[i].ReservationSeats = append(reservations[i].ReservationSeats, domain.ReservationSeat{
+				ReservationID: reservation.ID,
+				ShowtimeID:    reservation.ShowtimeID,
+				SeatID:        *seatId,
+			})
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reservations, nil
+}
+
+func (p *PostgresReservationRepository) GetAttendanceByTheater(
+	ctx context.Context,
+	theaterId int,
+	from, to time.Time) ([]domain.ShowtimeAttendance, error) {
+
+	query := `
+		SELECT
+			sh.id,
+			sh.start_time,
+			m.title,
+			h.name,
+			COUNT(r.id) FILTER (WHERE r.status != 'cancelled' OR r.no_show_at IS NOT NULL),
+			COUNT(c.reservation_id),
+			COUNT(c.reservation_id) FILTER (WHERE c.minutes_late > 0),
+			COUNT(r.id) FILTER (WHERE r.no_show_at IS NOT NULL),
+			COUNT(r.id) FILTER (WHERE r.no_show_at IS NOT NULL AND r.status = 'cancelled')
+		FROM showtimes sh
+		JOIN halls h ON h.id = sh.hall_id
+		JOIN theaters t ON t.id = h.theater_id
+		JOIN movies m ON m.id = sh.movie_id
+		LEFT JOIN reservations r ON r.showtime_id = sh.id
+		LEFT JOIN check_ins c ON c.reservation_id = r.id
+		WHERE t.id = $1
+			AND sh.start_time >= $2
+			AND sh.start_time < $3
+			AND ($4 = 0 OR t.tenant_id = $4)
+		GROUP BY sh.id, m.title, h.name
+		ORDER BY sh.start_time, sh.id`
+
+	rows, err := p.db.Query(ctx, query, theaterId, from, to, domain.TenantIDFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attendance := make([]domain.ShowtimeAttendance, 0)
+
+	for rows.Next() {
+		var a domain.ShowtimeAttendance
+
+		err = rows.Scan(
+			&a.ShowtimeID,
+			&a.StartTime,
+			&a.MovieTitle,
+			&a.HallName,
+			&a.Reservations,
+			&a.CheckedIn,
+			&a.LateArrivals,
+			&a.NoShows,
+			&a.Released,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		attendance = append(attendance, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attendance, nil
+}
+
+func (p *PostgresReservationRepository) LookupByEmails(
+	ctx context.Context,
+	showtimeId int,
+	emails []string) ([]domain.ReservationLookup, error) {
+
+	// reservations are matched by the account of the user or the guest email of bookings made by staff
+	query := `
+		SELECT
+			e.email,
+			COALESCE(jsonb_agg(jsonb_build_object(
+				'reservationId', r.id,
+				'status', r.status,
+				'seats', (
+					SELECT COALESCE(jsonb_agg(jsonb_build_object(
+						'row', s.seat_row,
+						'col', s.seat_col,
+						'type', s.seat_type) ORDER BY s.seat_row, s.seat_col), '[]')
+					FROM reservation_seats rs
+					JOIN seats s ON rs.seat_id = s.id
+					WHERE rs.reservation_id = r.id
+				)) ORDER BY r.id) FILTER (WHERE r.id IS NOT NULL), '[]') AS reservations
+		FROM showtimes sh
+		CROSS JOIN unnest($2::text[]) WITH ORDINALITY AS e(email, position)
+		LEFT JOIN reservations r ON r.showtime_id = sh.id
+			AND (
+				r.guest_email = e.email::citext
+				OR r.user_id IN (SELECT u.id FROM users u WHERE u.email = e.email::citext)
+			)
+		WHERE sh.id = $1
+		GROUP BY e.email, e.position
+		ORDER BY e.position`
+
+	rows, err := p.db.Query(ctx, query, showtimeId, emails)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lookups := make([]domain.ReservationLookup, 0, len(emails))
+
+	for rows.Next() {
+		var lookup domain.ReservationLookup
+		var reservationsJson json.RawMessage
+
+		err = rows.Scan(&lookup.Email, &reservationsJson)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(reservationsJson, &lookup.Reservations); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal looked up reservations: %w", err)
+		}
+
+		lookups = append(lookups, lookup)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// every address has a row unless the showtime doesn't exist
+	if len(lookups) == 0 && len(emails) > 0 {
+		return nil, domain.ErrRecordNotFound
+	}
+
+	return lookups, nil
+}
+
+func (p *PostgresReservationRepository) GetManifestByShowtime(
+	ctx context.Context,
+	showtimeId int) (*domain.ShowtimeManifest, error) {
+
+	manifest := &domain.ShowtimeManifest{
+		ShowtimeID: showtimeId,
+		Seats:      make([]domain.ManifestSeat, 0),
+	}
+
+	theaterQuery := `
+		SELECT h.theater_id
+		FROM showtimes s
+		JOIN halls h ON h.id = s.hall_id
+		WHERE s.id = $1`
+
+	err := p.db.QueryRow(ctx, theaterQuery, showtimeId).Scan(&manifest.TheaterID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrRecordNotFound
+		}
+
+		return nil, err
+	}
+
+	seatsQuery := `
+		SELECT
+			s.seat_row,
+			s.seat_col,
+			s.seat_type,
+			r.id,
+			r.status,
+			r.tickets_revoked_at,
+			r.ticket_version,
+			COALESCE(u.first_name, ''),
+			COALESCE(u.last_name, ''),
+			COALESCE(u.email, r.guest_email)
+		FROM reservation_seats rs
+		JOIN reservations r ON r.id = rs.reservation_id
+		JOIN seats s ON s.id = rs.seat_id
+		LEFT JOIN users u ON u.id = r.user_id
+		WHERE rs.showtime_id = $1 AND r.status != 'cancelled'
+		ORDER BY s.seat_row, s.seat_col`
+
+	rows, err := p.db.Query(ctx, seatsQuery, showtimeId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var seat domain.ManifestSeat
+
+		err = rows.Scan(
+			&seat.Row,
+			&seat.Col,
+			&seat.Type,
+			&seat.ReservationID,
+			&seat.Status,
+			&seat.TicketsRevokedAt,
+			&seat.TicketVersion,
+			&seat.FirstName,
+			&seat.LastName,
+			&seat.Email,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		manifest.Seats = append(manifest.Seats, seat)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+func (p *PostgresReservationRepository) GetBookedShowtime(
+	ctx context.Context,
+	reservationId,
+	userId int) (*domain.BookedShowtime, error) {
+
+	query := `
+		SELECT
+			r.id,
+			r.status,
+			r.flexible_ticket,
+			r.tickets_revoked_at,
+			s.id,
+			s.movie_id,
+			h.theater_id,
+			s.start_time,
+			ARRAY(SELECT rs.seat_id FROM reservation_seats rs WHERE rs.reservation_id = r.id ORDER BY rs.seat_id),
+			(
+				SELECT COALESCE(SUM(` + refundableAmount + `), 0)
+				FROM payments p
+				WHERE (p.id = r.payment_id OR p.reservation_id = r.id) AND p.status IN ('completed', 'refunded')
+			)
+		FROM reservations r
+		JOIN showtimes s ON s.id = r.showtime_id
+		JOIN halls h ON h.id = s.hall_id
+		WHERE r.id = $1 AND r.user_id = $2`
+
+	var booked domain.BookedShowtime
+
+	err := p.db.QueryRow(ctx, query, reservationId, userId).Scan(
+		&booked.ReservationID,
+		&booked.Status,
+		&booked.FlexibleTicket,
+		&booked.TicketsRevokedAt,
+		&booked.ShowtimeID,
+		&booked.MovieID,
+		&booked.TheaterID,
+		&booked.StartTime,
+		&booked.SeatIDs,
+		&booked.PaidAmount,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrRecordNotFound
+		}
+
+		return nil, err
+	}
+
+	return &booked, nil
+}
+
+// CancelSeats refunds what the cancelled seats were sold for. Seats of reservations made before seat
+// prices were recorded are counted with the showtime's base price and their extra price.
+func (p *PostgresReservationRepository) CancelSeats(
+	ctx context.Context,
+	reservationId,
+	showtimeId int,
+	seatIds []int) ([]domain.PendingRefund, error) {
+
+	var refunds []domain.PendingRefund
+
+	err := runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		query := `
+			UPDATE reservations
+			SET ticket_version = ticket_version + 1, updated_at = NOW()
+			WHERE id = $1 AND showtime_id = $2 AND status = 'confirmed'`
+
+		cmdTag, err := tx.Exec(ctx, query, reservationId, showtimeId)
+		if err != nil {
+			return err
+		}
+
+		if cmdTag.RowsAffected() != 1 {
+			return domain.ErrEditConflict
+		}
+
+		deleteQuery := `
+			DELETE FROM reservation_seats rs
+			USING showtimes sh, seats se
+			WHERE rs.reservation_id = $1
+				AND rs.seat_id = ANY($2)
+				AND sh.id = rs.showtime_id
+				AND se.id = rs.seat_id
+			RETURNING COALESCE(rs.price, sh.base_price + COALESCE(rs.extra_price, se.extra_price))`
+
+		rows, err := tx.Query(ctx, deleteQuery, reservationId, seatIds)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		total := decimal.Zero
+		cancelled := 0
+
+		for rows.Next() {
+			var price decimal.Decimal
+
+			err = rows.Scan(&price)
+			if err != nil {
+				return err
+			}
+
+			total = total.Add(price)
+			cancelled++
+		}
+
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		if cancelled != len(seatIds) {
+			return domain.ErrRecordNotFound
+		}
+
+		// the last seats are cancelled with the whole reservation, not one by one
+		var remaining bool
+
+		err = tx.QueryRow(
+			ctx,
+			`SELECT EXISTS (SELECT 1 FROM reservation_seats WHERE reservation_id = $1)`,
+			reservationId).Scan(&remaining)
+		if err != nil {
+			return err
+		}
+
+		if !remaining {
+			return domain.ErrEditConflict
+		}
+
+		refunds, err = queueRefunds(ctx, tx, reservationId, total, "seat-cancellation")
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return refunds, nil
+}
+
+func (p *PostgresReservationRepository) Reschedule(
+	ctx context.Context,
+	change domain.ShowtimeChange) ([]domain.PendingRefund, error) {
+
+	var refunds []domain.PendingRefund
+
+	err := runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		// the row lock orders the change after concurrent cancellations of the reservation or its seats
+		query := `
+			UPDATE reservations
+			SET showtime_id = $3, updated_at = NOW()
+			WHERE id = $1 AND showtime_id = $2 AND status = 'confirmed' AND tickets_revoked_at IS NULL`
+
+		cmdTag, err := tx.Exec(ctx, query, change.ReservationID, change.FromShowtimeID, change.ToShowtimeID)
+		if err != nil {
+			return err
+		}
+
+		if cmdTag.RowsAffected() != 1 {
+			return domain.ErrEditConflict
+		}
+
+		// the price difference was computed for the seats the reservation had, seats cancelled since would
+		// be paid for again
+		var seatIds []int
+
+		err = tx.QueryRow(
+			ctx,
+			`SELECT ARRAY(SELECT seat_id FROM reservation_seats WHERE reservation_id = $1 ORDER BY seat_id)`,
+			change.ReservationID).Scan(&seatIds)
+		if err != nil {
+			return err
+		}
+
+		if !slices.Equal(seatIds, slices.Sorted(slices.Values(change.FromSeatIDs))) {
+			return domain.ErrEditConflict
+		}
+
+		_, err = tx.Exec(ctx, `DELETE FROM reservation_seats WHERE reservation_id = $1`, change.ReservationID)
+		if err != nil {
+			return err
+		}
+
+		err = copyReservationSeats(ctx, tx, change.ReservationID, change.ToShowtimeID, change.Seats)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation && pgErr.ConstraintName == "unique_showtime_seat" {
+				return domain.ErrSeatAlreadyReserved
+			}
+
+			return err
+		}
+
+		if change.PriceDifference.IsNegative() {
+			refunds, err = queueRefunds(ctx, tx, change.ReservationID, change.PriceDifference.Neg(), "showtime-change")
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return refunds, nil
+}
+
+func (p *PostgresReservationRepository) GetReservedSeatsByShowtime(
+	ctx context.Context,
+	from, to time.Time) (map[int][]int, error) {
+
+	query := `
+		SELECT sh.id, rs.seat_id
+		FROM showtimes sh
+		LEFT JOIN reservation_seats rs
+			ON rs.showtime_id = sh.id
+		WHERE sh.start_time >= $1 AND sh.start_time < $2
+		ORDER BY sh.id, rs.seat_id`
+
+	rows, err := p.db.Query(ctx, query, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seatsByShowtime := make(map[int][]int)
+
+	for rows.Next() {
+		var showtimeId int
+		var seatId *int
+
+		err := rows.Scan(&showtimeId, &seatId)
+		if err != nil {
+			return nil, err
+		}
+
+		seats := seatsByShowtime[showtimeId]
+		if seatId != nil {
+			seats = append(seats, *seatId)
+		}
+
+		seatsByShowtime[showtimeId] = seats
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return seatsByShowtime, nil
+}
+
+func (p *PostgresReservationRepository) GetInventoryAnomalies(
+	ctx context.Context,
+	from, to time.Time) ([]domain.InventoryAnomaly, error) {
+
+	query := `
+		WITH upcoming AS (
+			SELECT id, hall_id
+			FROM showtimes
+			WHERE start_time >= $1 AND start_time < $2
+		)
+		SELECT 'oversold_seat', u.id, rs.seat_id, rs.reservation_id,
+			'seat of hall ' || se.hall_id || ' sold for a showtime in hall ' || u.hall_id
+		FROM upcoming u
+		JOIN reservation_seats rs
+			ON rs.showtime_id = u.id
+		JOIN seats se
+			ON se.id = rs.seat_id
+		WHERE se.hall_id <> u.hall_id
+
+		UNION ALL
+
+		SELECT 'over_capacity', u.id, 0, 0,
+			COUNT(rs.seat_id) || ' seats sold, hall capacity is ' || (
+				SELECT COUNT(*) FROM seats WHERE hall_id = u.hall_id
+			)
+		FROM upcoming u
+		JOIN reservation_seats rs
+			ON rs.showtime_id = u.id
+		GROUP BY u.id, u.hall_id
+		HAVING COUNT(rs.seat_id) > (SELECT COUNT(*) FROM seats WHERE hall_id = u.hall_id)
+
+		UNION ALL
+
+		SELECT 'unpaid_reservation', u.id, 0, r.id,
+			COALESCE('payment is ' || p.status, 'no payment')
+		FROM upcoming u
+		JOIN reservations r
+			ON r.showtime_id = u.id
+		LEFT JOIN payments p
+			ON p.id = r.payment_id
+		WHERE r.status = 'confirmed' AND (p.id IS NULL OR p.status NOT IN ('completed', 'comp'))
+
+		ORDER BY 2, 1, 3, 4`
+
+	rows, err := p.db.Query(ctx, query, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	anomalies := make([]domain.InventoryAnomaly, 0)
+
+	for rows.Next() {
+		var anomaly domain.InventoryAnomaly
+
+		err := rows.Scan(&anomaly.Kind, &anomaly.ShowtimeID, &anomaly.SeatID, &anomaly.ReservationID, &anomaly.Detail)
+		if err != nil {
+			return nil, err
+		}
+
+		anomalies = append(anomalies, anomaly)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return anomalies, nil
+}
+
+func (p *PostgresReservationRepository) Share(
+	ctx context.Context,
+	reservationId int,
+	tokenHash []byte,
+	expiresAt time.Time) error {
+
+	query := `
+		INSERT INTO reservation_shares (reservation_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (reservation_id) DO UPDATE SET
+			token_hash = EXCLUDED.token_hash,
+			expires_at = EXCLUDED.expires_at,
+			created_at = NOW()`
+
+	_, err := p.db.Exec(ctx, query, reservationId, tokenHash, expiresAt)
+
+	return err
+}
+
+func (p *PostgresReservationRepository) RevokeShare(ctx context.Context, reservationId, userId int) error {
+	query := `
+		DELETE FROM reservation_shares rs
+		USING reservations r
+		WHERE rs.reservation_id = r.id AND r.id = $1 AND r.user_id = $2`
+
+	result, err := p.db.Exec(ctx, query, reservationId, userId)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetSharedScreening stops finding the screening once the reservation is cancelled or its tickets are
+// revoked, e.g. by a refund, even if its link didn't expire yet.
+func (p *PostgresReservationRepository) GetSharedScreening(
+	ctx context.Context,
+	tokenHash []byte,
+	now time.Time) (*domain.SharedScreening, error) {
+
+	query := `
+		SELECT
+			m.title,
+			m.poster_url,
+			t.name,
+			t.city,
+			s.start_time,
+			t.time_zone,
+			rs.expires_at
+		FROM reservation_shares rs
+		JOIN reservations r ON r.id = rs.reservation_id
+		JOIN showtimes s ON s.id = r.showtime_id
+		JOIN movies m ON m.id = s.movie_id
+		JOIN halls h ON h.id = s.hall_id
+		JOIN theaters t ON t.id = h.theater_id
+		WHERE rs.token_hash = $1
+			AND rs.expires_at > $2
+			AND r.status <> 'cancelled'
+			AND r.tickets_revoked_at IS NULL`
+
+	var screening domain.SharedScreening
+
+	err := p.db.QueryRow(ctx, query, tokenHash, now).Scan(
+		&screening.MovieTitle,
+		&screening.MoviePosterUrl,
+		&screening.TheaterName,
+		&screening.TheaterCity,
+		&screening.StartTime,
+		&screening.TheaterTimeZone,
+		&screening.ExpiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrRecordNotFound
+		}
+
+		return nil, err
+	}
+
+	return &screening, nil
+}
+
+func (p *PostgresReservationRepository) SaveCalendarFeed(ctx context.Context, userId int, tokenHash []byte) error {
+	query := `
+		INSERT INTO calendar_feeds (user_id, token_hash)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET
+			token_hash = EXCLUDED.token_hash,
+			created_at = NOW()`
+
+	_, err := p.db.Exec(ctx, query, userId, tokenHash)
+
+	return err
+}
+
+func (p *PostgresReservationRepository) DeleteCalendarFeed(ctx context.Context, userId int) error {
+	result, err := p.db.Exec(ctx, `DELETE FROM calendar_feeds WHERE user_id = $1`, userId)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (p *PostgresReservationRepository) GetCalendarFeed(
+	ctx context.Context,
+	tokenHash []byte,
+	now time.Time) ([]domain.ReservationDetail, error) {
+
+	var userId int
+
+	err := p.db.QueryRow(ctx, `SELECT user_id FROM calendar_feeds WHERE token_hash = $1`, tokenHash).Scan(&userId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrRecordNotFound
+		}
+
+		return nil, err
+	}
+
+	query := `
+		SELECT
+			r.id,
+			m.title,
+			s.start_time,
+			m.duration,
+			t.name,
+			h.name,
+			t.address,
+			t.district,
+			t.city,
+			t.time_zone,
+			ST_Y(t.location::geometry),
+			ST_X(t.location::geometry),
+			(
+				SELECT COALESCE(jsonb_agg(jsonb_build_object(
+					'row', se.seat_row,
+					'col', se.seat_col,
+					'type', se.seat_type)), '[]')
+				FROM reservation_seats rs
+				JOIN seats se ON rs.seat_id = se.id
+				WHERE rs.reservation_id = r.id
+			) AS seats
+		FROM reservations r
+		JOIN showtimes s ON r.showtime_id = s.id
+		JOIN movies m ON s.movie_id = m.id
+		JOIN halls h ON s.hall_id = h.id
+		JOIN theaters t ON h.theater_id = t.id
+		WHERE r.user_id = $1
+			AND r.status <> 'cancelled'
+			AND r.tickets_revoked_at IS NULL
+			AND s.start_time + m.duration * INTERVAL '1 minute' > $2
+		ORDER BY s.start_time, r.id`
+
+	rows, err := p.db.Query(ctx, query, userId, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reservations := make([]domain.ReservationDetail, 0)
+
+	for rows.Next() {
+		var detail domain.ReservationDetail
+		var seatsJson json.RawMessage
+
+		err := rows.Scan(
+			&detail.ReservationID,
+			&detail.MovieTitle,
+			&detail.ShowtimeDate,
+			&detail.MovieDuration,
+			&detail.TheaterName,
+			&detail.HallName,
+			&detail.TheaterAddress,
+			&detail.TheaterDistrict,
+			&detail.TheaterCity,
+			&detail.TheaterTimeZone,
+			&detail.TheaterLocation.Latitude,
+			&detail.TheaterLocation.Longitude,
+			&seatsJson,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(seatsJson, &detail.Seats); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal reservation seats: %w", err)
+		}
+
+		reservations = append(reservations, detail)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reservations, nil
+}
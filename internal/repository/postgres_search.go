@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+type PostgresSearchRepository struct {
+	db DB
+}
+
+func NewPostgresSearchRepository(db DB) *PostgresSearchRepository {
+	return &PostgresSearchRepository{
+		db: db,
+	}
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Suggest matches names by prefix and, to tolerate typos, by trigram similarity. Both conditions are
+// served by the trigram indexes on the lowercased names. Prefix matches are ranked above fuzzy ones.
+func (p *PostgresSearchRepository) Suggest(ctx context.Context, prefix string, limit int) ([]domain.Suggestion, error) {
+	query := `
+		(
+			SELECT 'movie', id, title
+			FROM movies
+			WHERE (lower(title) LIKE $2 OR lower(title) % $1) AND ($4 = 0 OR tenant_id = $4) AND archived_at IS NULL
+			ORDER BY lower(title) LIKE $2 DESC, similarity(lower(title), $1) DESC, title
+			LIMIT $3
+		)
+		UNION ALL
+		(
+			SELECT 'theater', id, name
+			FROM theaters
+			WHERE (lower(name) LIKE $2 OR lower(name) % $1) AND ($4 = 0 OR tenant_id = $4)
+			ORDER BY lower(name) LIKE $2 DESC, similarity(lower(name), $1) DESC, name
+			LIMIT $3
+		)`
+
+	term := strings.ToLower(prefix)
+
+	rows, err := p.db.Query(ctx, query, term, likeEscaper.Replace(term)+"%", limit, domain.TenantIDFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := make([]domain.Suggestion, 0)
+
+	for rows.Next() {
+		var suggestion domain.Suggestion
+
+		err := rows.Scan(&suggestion.Kind, &suggestion.ID, &suggestion.Label)
+		if err != nil {
+			return nil, err
+		}
+
+		suggestions = append(suggestions, suggestion)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return suggestions, nil
+}
@@ -2,16 +2,20 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/metinatakli/movie-reservation-system/internal/domain"
 )
 
 type PostgresSeatRepository struct {
-	db *pgxpool.Pool
+	db DB
 }
 
-func NewPostgresSeatRepository(db *pgxpool.Pool) *PostgresSeatRepository {
+func NewPostgresSeatRepository(db DB) *PostgresSeatRepository {
 	return &PostgresSeatRepository{
 		db: db,
 	}
@@ -23,23 +27,35 @@ func (p *PostgresSeatRepository) GetSeatsByShowtime(ctx context.Context, showtim
 			t.id AS theater_id, 
 			t.name AS theater_name, 
 			h.id AS hall_id, 
+			sh.sales_open_at,
+			sh.sales_close_at,
 			se.id AS seat_id, 
 			se.seat_row, 
 			se.seat_col, 
 			se.seat_type, 
-			se.extra_price
+			COALESCE(pv.extra_price, se.extra_price),
+			pv.id
 		FROM showtimes sh
 		JOIN seats se
 			ON sh.hall_id = se.hall_id
+		LEFT JOIN LATERAL (
+			SELECT id, extra_price
+			FROM seat_price_versions
+			WHERE hall_id = se.hall_id AND seat_type = se.seat_type AND effective_from <= NOW()
+			ORDER BY effective_from DESC
+			LIMIT 1
+		) pv ON TRUE
 		JOIN halls h
 			ON sh.hall_id = h.id
 		JOIN theaters t
 			ON h.theater_id = t.id
-		WHERE sh.id = $1 AND sh.start_time > NOW()
+		WHERE sh.id = $1
+			AND sh.start_time + make_interval(mins => COALESCE(sh.pre_show_minutes, t.pre_show_minutes)) > NOW()
+			AND ($2 = 0 OR t.tenant_id = $2)
 		ORDER BY se.seat_row, se.seat_col
 	`
 
-	rows, err := p.db.Query(ctx, query, showtimeID)
+	rows, err := p.db.Query(ctx, query, showtimeID, domain.TenantIDFromContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -56,11 +72,14 @@ func (p *PostgresSeatRepository) GetSeatsByShowtime(ctx context.Context, showtim
 			&showtimeSeats.TheaterID,
 			&showtimeSeats.TheaterName,
 			&showtimeSeats.HallID,
+			&showtimeSeats.SalesWindow.OpenAt,
+			&showtimeSeats.SalesWindow.CloseAt,
 			&seat.ID,
 			&seat.Row,
 			&seat.Col,
 			&seat.Type,
 			&seat.ExtraPrice,
+			&seat.PriceVersionID,
 		)
 		if err != nil {
 			return nil, err
@@ -83,29 +102,49 @@ func (p *PostgresSeatRepository) GetSeatsByShowtimeAndSeatIds(
 
 	query := `
 		SELECT 
+			t.id,
 			t.name,
+			t.stripe_account_id,
+			t.time_zone,
+			m.id,
 			m.title,
 			h.name,
 			sh.base_price,
 			sh.start_time,
+			sh.format,
+			sf.surcharge,
+			sh.sales_open_at,
+			sh.sales_close_at,
 			se.id, 
 			se.seat_row, 
 			se.seat_col, 
 			se.seat_type, 
-			se.extra_price
+			COALESCE(pv.extra_price, se.extra_price),
+			pv.id
 		FROM showtimes sh
 		JOIN seats se
 			ON se.hall_id = sh.hall_id
+		LEFT JOIN LATERAL (
+			SELECT id, extra_price
+			FROM seat_price_versions
+			WHERE hall_id = se.hall_id AND seat_type = se.seat_type AND effective_from <= NOW()
+			ORDER BY effective_from DESC
+			LIMIT 1
+		) pv ON TRUE
 		JOIN halls h
 			ON h.id = se.hall_id
 		JOIN theaters t
 			ON t.id = h.theater_id
 		JOIN movies m
 			ON m.id = sh.movie_id
-		WHERE sh.id = $1 AND se.id = ANY($2::int[]) AND sh.start_time > NOW();
+		JOIN screening_formats sf
+			ON sf.format = sh.format
+		WHERE sh.id = $1 AND se.id = ANY($2::int[])
+			AND sh.start_time + make_interval(mins => COALESCE(sh.pre_show_minutes, t.pre_show_minutes)) > NOW()
+			AND ($3 = 0 OR t.tenant_id = $3);
 	`
 
-	rows, err := p.db.Query(ctx, query, showtimeID, seatIDs)
+	rows, err := p.db.Query(ctx, query, showtimeID, seatIDs, domain.TenantIDFromContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -117,16 +156,25 @@ func (p *PostgresSeatRepository) GetSeatsByShowtimeAndSeatIds(
 		var seat domain.Seat
 
 		err = rows.Scan(
+			&showtimeSeats.TheaterID,
 			&showtimeSeats.TheaterName,
+			&showtimeSeats.PayoutAccountID,
+			&showtimeSeats.TheaterTimeZone,
+			&showtimeSeats.MovieID,
 			&showtimeSeats.MovieName,
 			&showtimeSeats.HallName,
 			&showtimeSeats.Price,
 			&showtimeSeats.Date,
+			&showtimeSeats.Format,
+			&showtimeSeats.FormatSurcharge,
+			&showtimeSeats.SalesWindow.OpenAt,
+			&showtimeSeats.SalesWindow.CloseAt,
 			&seat.ID,
 			&seat.Row,
 			&seat.Col,
 			&seat.Type,
 			&seat.ExtraPrice,
+			&seat.PriceVersionID,
 		)
 		if err != nil {
 			return nil, err
@@ -141,3 +189,561 @@ func (p *PostgresSeatRepository) GetSeatsByShowtimeAndSeatIds(
 
 	return &showtimeSeats, nil
 }
+
+// GetSeatSalesByHall counts how many times each seat of the hall was reserved for showtimes
+// starting within [from, to). Only the reservations of the channel are counted unless it's empty.
+func (p *PostgresSeatRepository) GetSeatSalesByHall(
+	ctx context.Context,
+	hallID int,
+	from, to time.Time,
+	channel domain.SalesChannel) (*domain.SeatHeatmap, error) {
+
+	heatmap := &domain.SeatHeatmap{
+		HallID: hallID,
+		From:   from,
+		To:     to,
+	}
+
+	query := `
+		SELECT COUNT(*)
+		FROM showtimes
+		WHERE hall_id = $1 AND start_time >= $2 AND start_time < $3`
+
+	err := p.db.QueryRow(ctx, query, hallID, from, to).Scan(&heatmap.ShowtimeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT
+			se.id,
+			se.seat_row,
+			se.seat_col,
+			se.seat_type,
+			COUNT(sh.id)
+		FROM seats se
+		LEFT JOIN reservation_seats rs
+			ON rs.seat_id = se.id
+		LEFT JOIN reservations r
+			ON r.id = rs.reservation_id
+		LEFT JOIN showtimes sh
+			ON sh.id = rs.showtime_id AND sh.start_time >= $2 AND sh.start_time < $3
+			AND ($4::text = '' OR r.sales_channel = $4)
+		WHERE se.hall_id = $1
+		GROUP BY se.id
+		ORDER BY se.seat_row, se.seat_col`
+
+	rows, err := p.db.Query(ctx, query, hallID, from, to, string(channel))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var seat domain.SeatSales
+
+		err = rows.Scan(&seat.SeatID, &seat.Row, &seat.Col, &seat.Type, &seat.SoldCount)
+		if err != nil {
+			return nil, err
+		}
+
+		heatmap.Seats = append(heatmap.Seats, seat)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return heatmap, nil
+}
+
+func (p *PostgresSeatRepository) CreatePriceVersion(ctx context.Context, version *domain.SeatPriceVersion) error {
+	query := `
+		INSERT INTO seat_price_versions (hall_id, seat_type, extra_price, effective_from)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+
+	err := p.db.QueryRow(
+		ctx,
+		query,
+		version.HallID,
+		version.SeatType,
+		version.ExtraPrice,
+		version.EffectiveFrom).Scan(&version.ID, &version.CreatedAt)
+
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			switch pgErr.Code {
+			case pgerrcode.ForeignKeyViolation:
+				return domain.ErrHallNotFound
+			case pgerrcode.UniqueViolation:
+				return domain.ErrEditConflict
+			}
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (p *PostgresSeatRepository) GetPriceVersionsByHall(ctx context.Context, hallID int) ([]domain.SeatPriceVersion, error) {
+	query := `
+		SELECT id, hall_id, seat_type, extra_price, effective_from, created_at
+		FROM seat_price_versions
+		WHERE hall_id = $1
+		ORDER BY seat_type, effective_from`
+
+	rows, err := p.db.Query(ctx, query, hallID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make([]domain.SeatPriceVersion, 0)
+
+	for rows.Next() {
+		var version domain.SeatPriceVersion
+
+		err = rows.Scan(
+			&version.ID,
+			&version.HallID,
+			&version.SeatType,
+			&version.ExtraPrice,
+			&version.EffectiveFrom,
+			&version.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		versions = append(versions, version)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+// GetSalesByPriceVersion groups the reserved seats of the hall by the price they were sold for. Seats
+// reserved before prices were recorded on reservations are counted with the seat's current extra price.
+// Only the reservations of the channel are counted unless it's empty.
+func (p *PostgresSeatRepository) GetSalesByPriceVersion(
+	ctx context.Context,
+	hallID int,
+	channel domain.SalesChannel) ([]domain.PriceVersionSales, error) {
+
+	query := `
+		SELECT
+			rs.price_version_id,
+			se.seat_type,
+			COALESCE(rs.extra_price, se.extra_price) AS extra_price,
+			COUNT(*),
+			SUM(COALESCE(rs.extra_price, se.extra_price))
+		FROM reservation_seats rs
+		JOIN seats se
+			ON se.id = rs.seat_id
+		JOIN reservations r
+			ON r.id = rs.reservation_id
+		WHERE se.hall_id = $1 AND ($2::text = '' OR r.sales_channel = $2)
+		GROUP BY rs.price_version_id, se.seat_type, COALESCE(rs.extra_price, se.extra_price)
+		ORDER BY se.seat_type, rs.price_version_id NULLS FIRST, extra_price`
+
+	rows, err := p.db.Query(ctx, query, hallID, string(channel))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sales := make([]domain.PriceVersionSales, 0)
+
+	for rows.Next() {
+		var s domain.PriceVersionSales
+
+		err = rows.Scan(&s.PriceVersionID, &s.SeatType, &s.ExtraPrice, &s.SeatsSold, &s.ExtrasRevenue)
+		if err != nil {
+			return nil, err
+		}
+
+		sales = append(sales, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sales, nil
+}
+
+func (p *PostgresSeatRepository) CreateSeatBlocks(ctx context.Context, blocks []domain.SeatBlock) error {
+	// a seat blocked for a showtime must be in the showtime's hall
+	query := `
+		INSERT INTO seat_blocks (seat_id, showtime_id, starts_at, ends_at, reason, note, created_by)
+		SELECT se.id, $2, $3, $4, $5, $6, $7
+		FROM seats se
+		WHERE se.id = $1
+			AND ($2::bigint IS NULL OR se.hall_id = (SELECT hall_id FROM showtimes WHERE id = $2))
+		RETURNING id, created_at`
+
+	return runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		for i := range blocks {
+			block := &blocks[i]
+
+			err := tx.QueryRow(
+				ctx,
+				query,
+				block.SeatID,
+				block.ShowtimeID,
+				block.StartsAt,
+				block.EndsAt,
+				block.Reason,
+				block.Note,
+				block.CreatedBy).Scan(&block.ID, &block.CreatedAt)
+
+			if err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					return domain.ErrRecordNotFound
+				}
+
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (p *PostgresSeatRepository) GetSeatBlocksByShowtime(ctx context.Context, showtimeID int) ([]domain.SeatBlock, error) {
+	query := `
+		SELECT b.id, b.seat_id, b.showtime_id, b.starts_at, b.ends_at, b.reason, b.note, b.created_by, b.created_at
+		FROM showtimes s
+		JOIN seats se
+			ON se.hall_id = s.hall_id
+		JOIN seat_blocks b
+			ON b.seat_id = se.id
+		WHERE s.id = $1
+			AND (b.showtime_id = s.id
+				OR (b.showtime_id IS NULL AND b.starts_at <= s.start_time AND b.ends_at > s.start_time))
+		ORDER BY b.seat_id, b.id`
+
+	rows, err := p.db.Query(ctx, query, showtimeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blocks := make([]domain.SeatBlock, 0)
+
+	for rows.Next() {
+		var block domain.SeatBlock
+
+		err = rows.Scan(
+			&block.ID,
+			&block.SeatID,
+			&block.ShowtimeID,
+			&block.StartsAt,
+			&block.EndsAt,
+			&block.Reason,
+			&block.Note,
+			&block.CreatedBy,
+			&block.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		blocks = append(blocks, block)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return blocks, nil
+}
+
+func (p *PostgresSeatRepository) DeleteSeatBlock(ctx context.Context, id int) error {
+	query := `DELETE FROM seat_blocks WHERE id = $1`
+
+	cmd, err := p.db.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return domain.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (p *PostgresSeatRepository) CreateHallTemplate(ctx context.Context, template *domain.HallTemplate) error {
+	query := `
+		INSERT INTO hall_templates (name, description)
+		VALUES ($1, $2)
+		RETURNING id, created_at, updated_at`
+
+	return runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, query, template.Name, template.Description).
+			Scan(&template.ID, &template.CreatedAt, &template.UpdatedAt)
+		if err != nil {
+			return hallTemplateError(err)
+		}
+
+		return insertHallTemplateSeats(ctx, tx, template)
+	})
+}
+
+func (p *PostgresSeatRepository) GetHallTemplates(ctx context.Context) ([]domain.HallTemplate, error) {
+	query := `
+		SELECT id, name, description, created_at, updated_at
+		FROM hall_templates
+		ORDER BY name`
+
+	rows, err := p.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := make([]domain.HallTemplate, 0)
+	indexes := make(map[int]int)
+
+	for rows.Next() {
+		template := domain.HallTemplate{Seats: []domain.HallTemplateSeat{}}
+
+		err = rows.Scan(&template.ID, &template.Name, &template.Description, &template.CreatedAt, &template.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		indexes[template.ID] = len(templates)
+		templates = append(templates, template)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	seatsQuery := `
+		SELECT template_id, seat_row, seat_col, seat_type, extra_price
+		FROM hall_template_seats
+		ORDER BY template_id, seat_row, seat_col`
+
+	seatRows, err := p.db.Query(ctx, seatsQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer seatRows.Close()
+
+	for seatRows.Next() {
+		var templateID int
+		var seat domain.HallTemplateSeat
+
+		err = seatRows.Scan(&templateID, &seat.Row, &seat.Col, &seat.Type, &seat.ExtraPrice)
+		if err != nil {
+			return nil, err
+		}
+
+		// the template may have been created after the templates were read
+		if i, ok := indexes[templateID]; ok {
+			templates[i].Seats = append(templates[i].Seats, seat)
+		}
+	}
+
+	if err = seatRows.Err(); err != nil {
+		return nil, err
+	}
+
+	return templates, nil
+}
+
+func (p *PostgresSeatRepository) GetHallTemplate(ctx context.Context, id int) (*domain.HallTemplate, error) {
+	query := `
+		SELECT id, name, description, created_at, updated_at
+		FROM hall_templates
+		WHERE id = $1`
+
+	template := domain.HallTemplate{Seats: []domain.HallTemplateSeat{}}
+
+	err := p.db.QueryRow(ctx, query, id).
+		Scan(&template.ID, &template.Name, &template.Description, &template.CreatedAt, &template.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrRecordNotFound
+		}
+
+		return nil, err
+	}
+
+	seatsQuery := `
+		SELECT seat_row, seat_col, seat_type, extra_price
+		FROM hall_template_seats
+		WHERE template_id = $1
+		ORDER BY seat_row, seat_col`
+
+	rows, err := p.db.Query(ctx, seatsQuery, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var seat domain.HallTemplateSeat
+
+		err = rows.Scan(&seat.Row, &seat.Col, &seat.Type, &seat.ExtraPrice)
+		if err != nil {
+			return nil, err
+		}
+
+		template.Seats = append(template.Seats, seat)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &template, nil
+}
+
+func (p *PostgresSeatRepository) UpdateHallTemplate(ctx context.Context, template *domain.HallTemplate) error {
+	query := `
+		UPDATE hall_templates
+		SET name = $2, description = $3, updated_at = NOW()
+		WHERE id = $1
+		RETURNING created_at, updated_at`
+
+	return runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, query, template.ID, template.Name, template.Description).
+			Scan(&template.CreatedAt, &template.UpdatedAt)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return domain.ErrRecordNotFound
+			}
+
+			return hallTemplateError(err)
+		}
+
+		_, err = tx.Exec(ctx, `DELETE FROM hall_template_seats WHERE template_id = $1`, template.ID)
+		if err != nil {
+			return err
+		}
+
+		return insertHallTemplateSeats(ctx, tx, template)
+	})
+}
+
+func (p *PostgresSeatRepository) DeleteHallTemplate(ctx context.Context, id int) error {
+	query := `DELETE FROM hall_templates WHERE id = $1`
+
+	cmd, err := p.db.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return domain.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (p *PostgresSeatRepository) CreateHallFromTemplate(
+	ctx context.Context,
+	hall *domain.Hall,
+	templateID int) (int, error) {
+
+	hallQuery := `
+		INSERT INTO halls (theater_id, name)
+		SELECT id, $2
+		FROM theaters
+		WHERE id = $1 AND ($3 = 0 OR tenant_id = $3)
+		RETURNING id`
+
+	seatsQuery := `
+		INSERT INTO seats (hall_id, seat_row, seat_col, seat_type, extra_price)
+		SELECT $1, seat_row, seat_col, seat_type, extra_price
+		FROM hall_template_seats
+		WHERE template_id = $2`
+
+	var seatCount int
+
+	err := runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		// locks the template, so it can't be changed while its seats are copied
+		var exists bool
+
+		err := tx.QueryRow(
+			ctx,
+			`SELECT EXISTS (SELECT 1 FROM hall_templates WHERE id = $1 FOR SHARE)`,
+			templateID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			return domain.ErrRecordNotFound
+		}
+
+		err = tx.QueryRow(ctx, hallQuery, hall.TheaterID, hall.Name, domain.TenantIDFromContext(ctx)).Scan(&hall.ID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return domain.ErrTheaterNotFound
+			}
+
+			return err
+		}
+
+		cmd, err := tx.Exec(ctx, seatsQuery, hall.ID, templateID)
+		if err != nil {
+			return err
+		}
+
+		seatCount = int(cmd.RowsAffected())
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return seatCount, nil
+}
+
+func insertHallTemplateSeats(ctx context.Context, tx pgx.Tx, template *domain.HallTemplate) error {
+	if len(template.Seats) == 0 {
+		return nil
+	}
+
+	rows := make([]int, len(template.Seats))
+	cols := make([]int, len(template.Seats))
+	types := make([]string, len(template.Seats))
+	extraPrices := make([]string, len(template.Seats))
+
+	for i, seat := range template.Seats {
+		rows[i] = seat.Row
+		cols[i] = seat.Col
+		types[i] = seat.Type
+		extraPrices[i] = seat.ExtraPrice.String()
+	}
+
+	query := `
+		INSERT INTO hall_template_seats (template_id, seat_row, seat_col, seat_type, extra_price)
+		SELECT $1, s.seat_row, s.seat_col, s.seat_type::seat_type, s.extra_price::numeric
+		FROM unnest($2::int[], $3::int[], $4::text[], $5::text[]) AS s(seat_row, seat_col, seat_type, extra_price)`
+
+	_, err := tx.Exec(ctx, query, template.ID, rows, cols, types, extraPrices)
+
+	return err
+}
+
+func hallTemplateError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+		return domain.ErrEditConflict
+	}
+
+	return err
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+type PostgresTenantRepository struct {
+	db DB
+}
+
+func NewPostgresTenantRepository(db DB) *PostgresTenantRepository {
+	return &PostgresTenantRepository{
+		db: db,
+	}
+}
+
+func (p *PostgresTenantRepository) GetAll(ctx context.Context) ([]domain.Tenant, error) {
+	query := `
+		SELECT id, slug, name, hostnames, logo_url, primary_color, mail_sender, stripe_account_id,
+			success_url, failure_url
+		FROM tenants
+		ORDER BY id`
+
+	rows, err := p.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tenants := []domain.Tenant{}
+
+	for rows.Next() {
+		var t domain.Tenant
+
+		err = rows.Scan(
+			&t.ID,
+			&t.Slug,
+			&t.Name,
+			&t.Hostnames,
+			&t.LogoUrl,
+			&t.PrimaryColor,
+			&t.MailSender,
+			&t.StripeAccountID,
+			&t.SuccessURL,
+			&t.FailureURL)
+
+		if err != nil {
+			return nil, err
+		}
+
+		tenants = append(tenants, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tenants, nil
+}
@@ -3,17 +3,20 @@ package repository
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/metinatakli/movie-reservation-system/internal/domain"
 )
 
 type PostgresTheaterRepository struct {
-	db *pgxpool.Pool
+	db DB
 }
 
-func NewPostgresTheaterRepository(db *pgxpool.Pool) *PostgresTheaterRepository {
+func NewPostgresTheaterRepository(db DB) *PostgresTheaterRepository {
 	return &PostgresTheaterRepository{
 		db: db,
 	}
@@ -21,11 +24,14 @@ func NewPostgresTheaterRepository(db *pgxpool.Pool) *PostgresTheaterRepository {
 
 // GetTheatersByMovieAndLocationAndDate fetches a paginated list of theaters showing a specific movie on a given date,
 // within 20 km of a user location, including distance, theater and hall-level amenities, and showtimes, all returned as JSONB.
+// An accessibility feature in the filter keeps only the showtimes offering it; audio description and subtitles are
+// properties of the movie, so they either keep all showtimes of the day or none.
 func (p *PostgresTheaterRepository) GetTheatersByMovieAndLocationAndDate(
 	ctx context.Context,
 	movieID int,
 	date time.Time,
 	long, lat float64,
+	filter domain.ShowtimeFilter,
 	pagination domain.Pagination,
 ) ([]domain.Theater, *domain.Metadata, error) {
 	query := `
@@ -45,15 +51,27 @@ func (p *PostgresTheaterRepository) GetTheatersByMovieAndLocationAndDate(
 					DISTINCT jsonb_build_object(
 						'id', s.id,
 						'startTime', s.start_time,
-						'basePrice', s.base_price
+						'basePrice', s.base_price,
+						'openCaptions', s.open_captions,
+						'format', s.format,
+						'salesOpenAt', s.sales_open_at,
+						'salesCloseAt', s.sales_close_at,
+						'preShowMinutes', COALESCE(s.pre_show_minutes, ht.pre_show_minutes)
 					)), '[]') AS showtimes
 			FROM halls h
+			INNER JOIN theaters ht ON ht.id = h.theater_id
 			INNER JOIN showtimes s 
 				ON s.hall_id = h.id 
 				AND s.movie_id = $1
-				AND s.start_time::date = $2
+				AND (s.start_time AT TIME ZONE ht.time_zone)::date = $2
+			INNER JOIN movies m ON m.id = s.movie_id AND m.archived_at IS NULL
 			LEFT JOIN hall_amenities ha ON ha.hall_id = h.id
 			LEFT JOIN amenities a ON ha.amenity_id = a.id
+			WHERE ($7 = ''
+				OR ($7 = 'OPEN_CAPTIONS' AND s.open_captions)
+				OR ($7 = 'AUDIO_DESCRIPTION' AND m.audio_description)
+				OR ($7 = 'SUBTITLES' AND cardinality(m.subtitle_languages) > 0))
+				AND ($8 = '' OR s.format = $8)
 			GROUP BY h.id, h.theater_id, h.name
 		)
 		SELECT 
@@ -62,6 +80,7 @@ func (p *PostgresTheaterRepository) GetTheatersByMovieAndLocationAndDate(
 			t.address, 
 			t.city,
 			t.district,
+			t.time_zone,
 			ST_Distance(t.location, ST_SetSRID(ST_MakePoint($3, $4), 4326)) / 1000 AS distance,
 			COALESCE(ta.amenities, '[]') AS amenities,
 			mh.halls,
@@ -85,11 +104,13 @@ func (p *PostgresTheaterRepository) GetTheatersByMovieAndLocationAndDate(
 			WHERE ta.theater_id = t.id
 		) ta ON true
 		WHERE ST_DWithin(t.location, ST_SetSRID(ST_MakePoint($3, $4), 4326), 20000)
+			AND ($9 = 0 OR t.tenant_id = $9)
 		ORDER BY t.location <-> ST_SetSRID(ST_MakePoint($3, $4), 4326)
 		LIMIT $5 OFFSET $6;
 	`
 
-	args := []any{movieID, date, long, lat, pagination.Limit(), pagination.Offset()}
+	args := []any{movieID, date, long, lat, pagination.Limit(), pagination.Offset(),
+		string(filter.Accessibility), string(filter.Format), domain.TenantIDFromContext(ctx)}
 	rows, err := p.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, nil, err
@@ -109,6 +130,7 @@ func (p *PostgresTheaterRepository) GetTheatersByMovieAndLocationAndDate(
 			&theater.Address,
 			&theater.City,
 			&theater.District,
+			&theater.TimeZone,
 			&theater.Distance,
 			&amenitiesJson,
 			&hallsJson,
@@ -140,3 +162,828 @@ func (p *PostgresTheaterRepository) GetTheatersByMovieAndLocationAndDate(
 
 	return theaters, metadata, nil
 }
+
+func (p *PostgresTheaterRepository) UpdateShowtimeFormat(
+	ctx context.Context,
+	showtimeID int,
+	format domain.ScreeningFormat) error {
+
+	query := `UPDATE showtimes SET format = $2 WHERE id = $1`
+
+	result, err := p.db.Exec(ctx, query, showtimeID, string(format))
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (p *PostgresTheaterRepository) UpdateSalesWindow(
+	ctx context.Context,
+	showtimeID int,
+	window domain.SalesWindow) error {
+
+	query := `UPDATE showtimes SET sales_open_at = $2, sales_close_at = $3 WHERE id = $1`
+
+	result, err := p.db.Exec(ctx, query, showtimeID, window.OpenAt, window.CloseAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.CheckViolation {
+			return domain.ErrInvalidSalesWindow
+		}
+
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (p *PostgresTheaterRepository) UpdateTheaterPreShow(ctx context.Context, theaterID, minutes int) error {
+	query := `
+		UPDATE theaters
+		SET pre_show_minutes = $2
+		WHERE id = $1 AND ($3 = 0 OR tenant_id = $3)`
+
+	result, err := p.db.Exec(ctx, query, theaterID, minutes, domain.TenantIDFromContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (p *PostgresTheaterRepository) UpdateShowtimePreShow(ctx context.Context, showtimeID int, minutes *int) error {
+	query := `UPDATE showtimes SET pre_show_minutes = $2 WHERE id = $1`
+
+	result, err := p.db.Exec(ctx, query, showtimeID, minutes)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (p *PostgresTheaterRepository) AdjustShowtimePrices(
+	ctx context.Context,
+	adjustment domain.BasePriceAdjustment,
+	dryRun bool) ([]domain.ShowtimePriceChange, error) {
+
+	// the showtimes are locked until the new prices are written, so a concurrent change of a price isn't lost
+	selectQuery := `
+		SELECT s.id, t.id, s.start_time, s.format, s.base_price, seats.total - seats.taken
+		FROM showtimes s
+		JOIN halls h ON h.id = s.hall_id
+		JOIN theaters t ON t.id = h.theater_id
+		CROSS JOIN LATERAL (
+			SELECT
+				COUNT(*) AS total,
+				COUNT(*) FILTER (WHERE
+					EXISTS (SELECT 1 FROM reservation_seats rs WHERE rs.showtime_id = s.id AND rs.seat_id = se.id)
+					OR EXISTS (
+						SELECT 1
+						FROM seat_blocks b
+						WHERE b.seat_id = se.id
+							AND (b.showtime_id = s.id
+								OR (b.showtime_id IS NULL AND b.starts_at <= s.start_time AND b.ends_at > s.start_time))
+					)) AS taken
+			FROM seats se
+			WHERE se.hall_id = s.hall_id
+		) seats
+		WHERE s.start_time > NOW()
+			AND (s.start_time AT TIME ZONE t.time_zone)::date BETWEEN $1::date AND $2::date
+			AND ($3::bigint IS NULL OR t.id = $3)
+			AND ($4::text IS NULL OR s.format = $4)
+			AND ($5 = 0 OR t.tenant_id = $5)
+		ORDER BY s.start_time, s.id
+		FOR UPDATE OF s`
+
+	updateQuery := `
+		UPDATE showtimes s
+		SET base_price = p.base_price::numeric
+		FROM unnest($1::bigint[], $2::text[]) AS p(id, base_price)
+		WHERE s.id = p.id`
+
+	var format *string
+	if adjustment.Format != nil {
+		format = (*string)(adjustment.Format)
+	}
+
+	changes := []domain.ShowtimePriceChange{}
+
+	err := runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, selectQuery,
+			adjustment.From.Format(time.DateOnly),
+			adjustment.To.Format(time.DateOnly),
+			adjustment.TheaterID,
+			format,
+			domain.TenantIDFromContext(ctx))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var c domain.ShowtimePriceChange
+
+			err = rows.Scan(&c.ShowtimeID, &c.TheaterID, &c.StartTime, &c.Format, &c.OldPrice, &c.AvailableSeats)
+			if err != nil {
+				return err
+			}
+
+			c.NewPrice, err = adjustment.Apply(c.OldPrice)
+			if err != nil {
+				return err
+			}
+
+			changes = append(changes, c)
+		}
+
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		if dryRun || len(changes) == 0 {
+			return nil
+		}
+
+		ids := make([]int, len(changes))
+		prices := make([]string, len(changes))
+
+		for i, c := range changes {
+			ids[i] = c.ShowtimeID
+			prices[i] = c.NewPrice.String()
+		}
+
+		_, err = tx.Exec(ctx, updateQuery, ids, prices)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+func (p *PostgresTheaterRepository) UpdateCheckInPolicy(
+	ctx context.Context,
+	theaterID int,
+	policy domain.CheckInPolicy) error {
+
+	query := `
+		UPDATE theaters
+		SET check_in_opens_before = $2, check_in_late_after = $3, check_in_closes_after = $4
+		WHERE id = $1 AND ($5 = 0 OR tenant_id = $5)`
+
+	result, err := p.db.Exec(
+		ctx,
+		query,
+		theaterID,
+		policy.OpensBefore,
+		policy.LateAfter,
+		policy.ClosesAfter,
+		domain.TenantIDFromContext(ctx))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.CheckViolation {
+			return domain.ErrInvalidCheckInPolicy
+		}
+
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (p *PostgresTheaterRepository) GetFormatSurcharges(ctx context.Context) ([]domain.FormatSurcharge, error) {
+	query := `SELECT format, surcharge, updated_at FROM screening_formats ORDER BY surcharge, format`
+
+	rows, err := p.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	surcharges := []domain.FormatSurcharge{}
+
+	for rows.Next() {
+		var s domain.FormatSurcharge
+
+		err = rows.Scan(&s.Format, &s.Surcharge, &s.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		surcharges = append(surcharges, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return surcharges, nil
+}
+
+func (p *PostgresTheaterRepository) UpdateFormatSurcharge(ctx context.Context, surcharge *domain.FormatSurcharge) error {
+	query := `
+		UPDATE screening_formats
+		SET surcharge = $2, updated_at = NOW()
+		WHERE format = $1
+		RETURNING updated_at`
+
+	err := p.db.QueryRow(ctx, query, string(surcharge.Format), surcharge.Surcharge).Scan(&surcharge.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.ErrRecordNotFound
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (p *PostgresTheaterRepository) UpdatePayoutAccount(ctx context.Context, theaterID int, accountID string) error {
+	query := `
+		UPDATE theaters
+		SET stripe_account_id = $2
+		WHERE id = $1 AND ($3 = 0 OR tenant_id = $3)`
+
+	result, err := p.db.Exec(ctx, query, theaterID, accountID, domain.TenantIDFromContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (p *PostgresTheaterRepository) IsTheaterStaff(ctx context.Context, theaterID, userID int) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM theater_staff WHERE theater_id = $1 AND user_id = $2)`
+
+	var isStaff bool
+
+	err := p.db.QueryRow(ctx, query, theaterID, userID).Scan(&isStaff)
+	if err != nil {
+		return false, err
+	}
+
+	return isStaff, nil
+}
+
+func (p *PostgresTheaterRepository) GetUpcomingScreenings(
+	ctx context.Context,
+	from, to time.Time) ([]domain.Screening, error) {
+
+	query := `
+		SELECT s.id, s.start_time, s.format, s.open_captions, s.base_price + f.surcharge, h.name,
+			t.id, t.name, t.address, t.city, t.district, t.time_zone, m.id, m.title, m.duration, m.poster_url
+		FROM showtimes s
+		JOIN screening_formats f ON f.format = s.format
+		JOIN halls h ON h.id = s.hall_id
+		JOIN theaters t ON t.id = h.theater_id
+		JOIN movies m ON m.id = s.movie_id
+		WHERE s.start_time >= $1 AND s.start_time < $2
+			AND ($3 = 0 OR t.tenant_id = $3)
+			AND m.archived_at IS NULL
+		ORDER BY s.start_time, s.id`
+
+	rows, err := p.db.Query(ctx, query, from, to, domain.TenantIDFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	screenings := []domain.Screening{}
+
+	for rows.Next() {
+		var s domain.Screening
+
+		err = rows.Scan(
+			&s.ShowtimeID,
+			&s.StartTime,
+			&s.Format,
+			&s.OpenCaptions,
+			&s.Price,
+			&s.HallName,
+			&s.TheaterID,
+			&s.TheaterName,
+			&s.TheaterAddress,
+			&s.TheaterCity,
+			&s.TheaterDistrict,
+			&s.TheaterTimeZone,
+			&s.MovieID,
+			&s.MovieTitle,
+			&s.MovieDuration,
+			&s.MoviePosterUrl)
+
+		if err != nil {
+			return nil, err
+		}
+
+		screenings = append(screenings, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return screenings, nil
+}
+
+func (p *PostgresTheaterRepository) GetPartnerShowtimes(
+	ctx context.Context,
+	since *time.Time,
+	pagination domain.Pagination) ([]domain.PartnerShowtime, *domain.Metadata, error) {
+
+	// a seat is unavailable when it's sold or blocked, seats locked in a cart are still offered
+	query := `
+		SELECT COUNT(*) OVER(), s.id, s.start_time, s.format, s.open_captions, s.base_price + f.surcharge,
+			m.id, m.title, t.id, t.name, t.city, t.time_zone, h.id, h.name, seats.total, seats.total - seats.taken,
+			GREATEST(s.updated_at, f.updated_at) AS changed_at
+		FROM showtimes s
+		JOIN screening_formats f ON f.format = s.format
+		JOIN halls h ON h.id = s.hall_id
+		JOIN theaters t ON t.id = h.theater_id
+		JOIN movies m ON m.id = s.movie_id
+		CROSS JOIN LATERAL (
+			SELECT
+				COUNT(*) AS total,
+				COUNT(*) FILTER (WHERE
+					EXISTS (SELECT 1 FROM reservation_seats rs WHERE rs.showtime_id = s.id AND rs.seat_id = se.id)
+					OR EXISTS (
+						SELECT 1
+						FROM seat_blocks b
+						WHERE b.seat_id = se.id
+							AND (b.showtime_id = s.id
+								OR (b.showtime_id IS NULL AND b.starts_at <= s.start_time AND b.ends_at > s.start_time))
+					)) AS taken
+			FROM seats se
+			WHERE se.hall_id = s.hall_id
+		) seats
+		WHERE s.start_time > NOW()
+			AND ($1::timestamptz IS NULL OR s.updated_at > $1 OR f.updated_at > $1)
+			AND ($4 = 0 OR t.tenant_id = $4)
+		ORDER BY changed_at, s.id
+		LIMIT $2 OFFSET $3`
+
+	rows, err := p.db.Query(ctx, query, since, pagination.Limit(), pagination.Offset(), domain.TenantIDFromContext(ctx))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	showtimes := make([]domain.PartnerShowtime, 0)
+	totalRecords := 0
+
+	for rows.Next() {
+		var s domain.PartnerShowtime
+
+		err = rows.Scan(
+			&totalRecords,
+			&s.ShowtimeID,
+			&s.StartTime,
+			&s.Format,
+			&s.OpenCaptions,
+			&s.Price,
+			&s.MovieID,
+			&s.MovieTitle,
+			&s.TheaterID,
+			&s.TheaterName,
+			&s.TheaterCity,
+			&s.TheaterTimeZone,
+			&s.HallID,
+			&s.HallName,
+			&s.TotalSeats,
+			&s.AvailableSeats,
+			&s.UpdatedAt)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		showtimes = append(showtimes, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	metadata := domain.NewMetadata(totalRecords, pagination.Page, pagination.PageSize)
+
+	return showtimes, metadata, nil
+}
+
+func (p *PostgresTheaterRepository) GetRescheduleOptions(
+	ctx context.Context,
+	movieID, theaterID, exceptShowtimeID, minSeats int,
+	after time.Time) ([]domain.RescheduleOption, error) {
+
+	// a seat is unavailable when it's sold or blocked, seats locked in a cart are still offered
+	query := `
+		SELECT s.id, s.start_time, s.format, s.open_captions, h.name, s.base_price + f.surcharge,
+			seats.total - seats.taken, t.time_zone
+		FROM showtimes s
+		JOIN screening_formats f ON f.format = s.format
+		JOIN halls h ON h.id = s.hall_id
+		JOIN theaters t ON t.id = h.theater_id
+		CROSS JOIN LATERAL (
+			SELECT
+				COUNT(*) AS total,
+				COUNT(*) FILTER (WHERE
+					EXISTS (SELECT 1 FROM reservation_seats rs WHERE rs.showtime_id = s.id AND rs.seat_id = se.id)
+					OR EXISTS (
+						SELECT 1
+						FROM seat_blocks b
+						WHERE b.seat_id = se.id
+							AND (b.showtime_id = s.id
+								OR (b.showtime_id IS NULL AND b.starts_at <= s.start_time AND b.ends_at > s.start_time))
+					)) AS taken
+			FROM seats se
+			WHERE se.hall_id = s.hall_id
+		) seats
+		WHERE s.movie_id = $1 AND h.theater_id = $2 AND s.id <> $3 AND s.start_time > $4
+			AND seats.total - seats.taken >= $5
+		ORDER BY s.start_time, s.id`
+
+	rows, err := p.db.Query(ctx, query, movieID, theaterID, exceptShowtimeID, after, minSeats)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	options := []domain.RescheduleOption{}
+
+	for rows.Next() {
+		var o domain.RescheduleOption
+
+		err = rows.Scan(
+			&o.ShowtimeID,
+			&o.StartTime,
+			&o.Format,
+			&o.OpenCaptions,
+			&o.HallName,
+			&o.Price,
+			&o.AvailableSeats,
+			&o.TheaterTimeZone)
+
+		if err != nil {
+			return nil, err
+		}
+
+		options = append(options, o)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return options, nil
+}
+
+func (p *PostgresTheaterRepository) GetNextShowtime(
+	ctx context.Context,
+	movieID int,
+	long, lat float64,
+	after time.Time) (*domain.NextShowtime, error) {
+
+	// the showtimes of the movie are walked in order of their start time on the index until one at a
+	// theater in range has a seat left. A seat is unavailable when it's sold or blocked, seats locked in a
+	// cart are still offered.
+	query := `
+		SELECT s.id, s.start_time, s.format, s.open_captions, s.base_price + f.surcharge, m.id, m.title,
+			t.id, t.name, t.address, t.city, t.district, t.time_zone, h.name,
+			ST_Distance(t.location, ST_SetSRID(ST_MakePoint($2, $3), 4326)) / 1000 AS distance,
+			seats.total - seats.taken
+		FROM showtimes s
+		JOIN screening_formats f ON f.format = s.format
+		JOIN movies m ON m.id = s.movie_id
+		JOIN halls h ON h.id = s.hall_id
+		JOIN theaters t ON t.id = h.theater_id
+		CROSS JOIN LATERAL (
+			SELECT
+				COUNT(*) AS total,
+				COUNT(*) FILTER (WHERE
+					EXISTS (SELECT 1 FROM reservation_seats rs WHERE rs.showtime_id = s.id AND rs.seat_id = se.id)
+					OR EXISTS (
+						SELECT 1
+						FROM seat_blocks b
+						WHERE b.seat_id = se.id
+							AND (b.showtime_id = s.id
+								OR (b.showtime_id IS NULL AND b.starts_at <= s.start_time AND b.ends_at > s.start_time))
+					)) AS taken
+			FROM seats se
+			WHERE se.hall_id = s.hall_id
+		) seats
+		WHERE s.movie_id = $1 AND s.start_time > $4
+			AND m.archived_at IS NULL
+			AND ST_DWithin(t.location, ST_SetSRID(ST_MakePoint($2, $3), 4326), 20000)
+			AND ($5 = 0 OR t.tenant_id = $5)
+			AND seats.total > seats.taken
+		ORDER BY s.start_time, distance, s.id
+		LIMIT 1`
+
+	var next domain.NextShowtime
+
+	err := p.db.QueryRow(ctx, query, movieID, long, lat, after, domain.TenantIDFromContext(ctx)).Scan(
+		&next.ShowtimeID,
+		&next.StartTime,
+		&next.Format,
+		&next.OpenCaptions,
+		&next.Price,
+		&next.MovieID,
+		&next.MovieTitle,
+		&next.TheaterID,
+		&next.TheaterName,
+		&next.TheaterAddress,
+		&next.TheaterCity,
+		&next.TheaterDistrict,
+		&next.TheaterTimeZone,
+		&next.HallName,
+		&next.DistanceKm,
+		&next.AvailableSeats)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrRecordNotFound
+		}
+
+		return nil, err
+	}
+
+	return &next, nil
+}
+
+func (p *PostgresTheaterRepository) GetNowPlayingBoard(
+	ctx context.Context,
+	theaterID int,
+	now time.Time) (*domain.NowPlayingBoard, error) {
+
+	board := domain.NowPlayingBoard{Showtimes: []domain.BoardShowtime{}}
+
+	err := p.db.QueryRow(ctx,
+		`SELECT id, name, time_zone FROM theaters WHERE id = $1 AND ($2 = 0 OR tenant_id = $2)`,
+		theaterID,
+		domain.TenantIDFromContext(ctx)).Scan(&board.TheaterID, &board.TheaterName, &board.TimeZone)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrTheaterNotFound
+		}
+
+		return nil, err
+	}
+
+	// a seat is unavailable when it's sold or blocked, seats locked in a cart are still offered
+	query := `
+		SELECT s.id, s.start_time, s.format, s.open_captions, m.id, m.title, m.duration, m.poster_url, h.name,
+			seats.total <= seats.taken
+		FROM showtimes s
+		JOIN halls h ON h.id = s.hall_id
+		JOIN movies m ON m.id = s.movie_id
+		CROSS JOIN LATERAL (
+			SELECT
+				COUNT(*) AS total,
+				COUNT(*) FILTER (WHERE
+					EXISTS (SELECT 1 FROM reservation_seats rs WHERE rs.showtime_id = s.id AND rs.seat_id = se.id)
+					OR EXISTS (
+						SELECT 1
+						FROM seat_blocks b
+						WHERE b.seat_id = se.id
+							AND (b.showtime_id = s.id
+								OR (b.showtime_id IS NULL AND b.starts_at <= s.start_time AND b.ends_at > s.start_time))
+					)) AS taken
+			FROM seats se
+			WHERE se.hall_id = s.hall_id
+		) seats
+		WHERE h.theater_id = $1 AND s.start_time >= $2 AND s.start_time < $3
+			AND m.archived_at IS NULL
+		ORDER BY s.start_time, h.name, s.id`
+
+	board.Date = domain.StartOfDay(now, domain.TheaterLocation(board.TimeZone))
+
+	rows, err := p.db.Query(ctx, query, theaterID, board.Date, board.Date.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s domain.BoardShowtime
+
+		err = rows.Scan(
+			&s.ShowtimeID,
+			&s.StartTime,
+			&s.Format,
+			&s.OpenCaptions,
+			&s.MovieID,
+			&s.MovieTitle,
+			&s.MovieDuration,
+			&s.MoviePosterUrl,
+			&s.HallName,
+			&s.SoldOut)
+		if err != nil {
+			return nil, err
+		}
+
+		board.Showtimes = append(board.Showtimes, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &board, nil
+}
+
+func (p *PostgresTheaterRepository) GetSeatAvailability(
+	ctx context.Context,
+	showtimeIDs []int) ([]domain.SeatAvailability, error) {
+
+	// a seat is unavailable when it's sold or blocked, seats locked in a cart are still offered
+	query := `
+		SELECT s.id, seats.total, seats.total - seats.taken
+		FROM showtimes s
+		CROSS JOIN LATERAL (
+			SELECT
+				COUNT(*) AS total,
+				COUNT(*) FILTER (WHERE
+					EXISTS (SELECT 1 FROM reservation_seats rs WHERE rs.showtime_id = s.id AND rs.seat_id = se.id)
+					OR EXISTS (
+						SELECT 1
+						FROM seat_blocks b
+						WHERE b.seat_id = se.id
+							AND (b.showtime_id = s.id
+								OR (b.showtime_id IS NULL AND b.starts_at <= s.start_time AND b.ends_at > s.start_time))
+					)) AS taken
+			FROM seats se
+			WHERE se.hall_id = s.hall_id
+		) seats
+		WHERE s.id = ANY($1)`
+
+	rows, err := p.db.Query(ctx, query, showtimeIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var availability []domain.SeatAvailability
+
+	for rows.Next() {
+		var a domain.SeatAvailability
+
+		err = rows.Scan(&a.ShowtimeID, &a.Total, &a.Available)
+		if err != nil {
+			return nil, err
+		}
+
+		availability = append(availability, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return availability, nil
+}
+
+func (p *PostgresTheaterRepository) GetAmenities(ctx context.Context) ([]domain.Amenity, error) {
+	query := `SELECT id, name, description FROM amenities ORDER BY id`
+
+	rows, err := p.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var amenities []domain.Amenity
+
+	for rows.Next() {
+		var amenity domain.Amenity
+
+		err = rows.Scan(&amenity.ID, &amenity.Name, &amenity.Description)
+		if err != nil {
+			return nil, err
+		}
+
+		amenities = append(amenities, amenity)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return amenities, nil
+}
+
+func (p *PostgresTheaterRepository) ImportTheaters(ctx context.Context, theaters []domain.TheaterImport) ([]int, error) {
+	// theaters imported outside of a tenant's domain belong to the default tenant
+	theaterQuery := `
+		INSERT INTO theaters (name, address, city, district, location, phone_number, email, website, time_zone,
+			tenant_id)
+		VALUES ($1, $2, $3, $4, ST_SetSRID(ST_MakePoint($5, $6), 4326)::geography, $7, $8, $9, $10,
+			COALESCE(NULLIF($11, 0), 1))
+		RETURNING id`
+
+	theaterAmenitiesQuery := `
+		INSERT INTO theater_amenities (theater_id, amenity_id)
+		SELECT $1, unnest($2::bigint[])`
+
+	hallQuery := `INSERT INTO halls (theater_id, name) VALUES ($1, $2) RETURNING id`
+
+	hallAmenitiesQuery := `
+		INSERT INTO hall_amenities (hall_id, amenity_id)
+		SELECT $1, unnest($2::bigint[])`
+
+	seatsQuery := `
+		INSERT INTO seats (hall_id, seat_row, seat_col, seat_type, extra_price)
+		SELECT $1, s.seat_row, s.seat_col, s.seat_type::seat_type, s.extra_price::numeric
+		FROM unnest($2::int[], $3::int[], $4::text[], $5::text[]) AS s(seat_row, seat_col, seat_type, extra_price)`
+
+	ids := make([]int, len(theaters))
+
+	err := runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		for i, theater := range theaters {
+			err := tx.QueryRow(ctx, theaterQuery,
+				theater.Name,
+				theater.Address,
+				theater.City,
+				theater.District,
+				theater.Longitude,
+				theater.Latitude,
+				theater.PhoneNumber,
+				theater.Email,
+				theater.Website,
+				theater.TimeZone,
+				domain.TenantIDFromContext(ctx)).Scan(&ids[i])
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec(ctx, theaterAmenitiesQuery, ids[i], theater.AmenityIDs)
+			if err != nil {
+				return err
+			}
+
+			for _, hall := range theater.Halls {
+				var hallID int
+
+				err = tx.QueryRow(ctx, hallQuery, ids[i], hall.Name).Scan(&hallID)
+				if err != nil {
+					return err
+				}
+
+				_, err = tx.Exec(ctx, hallAmenitiesQuery, hallID, hall.AmenityIDs)
+				if err != nil {
+					return err
+				}
+
+				rows := make([]int, len(hall.Seats))
+				cols := make([]int, len(hall.Seats))
+				types := make([]string, len(hall.Seats))
+				extraPrices := make([]string, len(hall.Seats))
+
+				for j, seat := range hall.Seats {
+					rows[j] = seat.Row
+					cols[j] = seat.Col
+					types[j] = seat.Type
+					extraPrices[j] = seat.ExtraPrice.String()
+				}
+
+				_, err = tx.Exec(ctx, seatsQuery, hallID, rows, cols, types, extraPrices)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
@@ -2,16 +2,18 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5"
 	"github.com/metinatakli/movie-reservation-system/internal/domain"
 )
 
 type PostgresTokenRepository struct {
-	db *pgxpool.Pool
+	db DB
 }
 
-func NewPostgresTokenRepository(db *pgxpool.Pool) *PostgresTokenRepository {
+func NewPostgresTokenRepository(db DB) *PostgresTokenRepository {
 	return &PostgresTokenRepository{
 		db: db,
 	}
@@ -38,3 +40,41 @@ func (p *PostgresTokenRepository) DeleteAllForUser(ctx context.Context, tokenSco
 
 	return err
 }
+
+func (p *PostgresTokenRepository) Consume(ctx context.Context, tokenHash []byte, tokenScope string) (int, error) {
+	query := `DELETE FROM tokens
+			WHERE hash = $1 AND scope = $2 AND expiry > $3
+			RETURNING user_id`
+
+	var userID int
+
+	err := p.db.QueryRow(ctx, query, tokenHash, tokenScope, time.Now()).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, domain.ErrRecordNotFound
+		}
+
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// DeleteExpired removes tokens that expired before the given time. In dry-run mode the tokens are
+// only counted.
+func (p *PostgresTokenRepository) DeleteExpired(ctx context.Context, expiredBefore time.Time, dryRun bool) (int64, error) {
+	if dryRun {
+		var count int64
+
+		err := p.db.QueryRow(ctx, `SELECT COUNT(*) FROM tokens WHERE expiry < $1`, expiredBefore).Scan(&count)
+
+		return count, err
+	}
+
+	cmd, err := p.db.Exec(ctx, `DELETE FROM tokens WHERE expiry < $1`, expiredBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	return cmd.RowsAffected(), nil
+}
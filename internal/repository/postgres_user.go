@@ -3,22 +3,31 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/metinatakli/movie-reservation-system/internal/domain"
+	"github.com/metinatakli/movie-reservation-system/internal/envelope"
 )
 
+// birthDateAAD binds encrypted birth dates to their column so that a ciphertext cannot be moved
+// to another encrypted field.
+var birthDateAAD = []byte("users.birth_date")
+
 type PostgesUserRepository struct {
-	db *pgxpool.Pool
+	db      DB
+	keyring *envelope.Keyring
 }
 
-func NewPostgresUserRepository(db *pgxpool.Pool) *PostgesUserRepository {
+// NewPostgresUserRepository creates a user repository. When a keyring is given, birth dates are
+// stored encrypted in birth_date_encrypted instead of the plaintext birth_date column.
+func NewPostgresUserRepository(db DB, keyring *envelope.Keyring) *PostgesUserRepository {
 	return &PostgesUserRepository{
-		db: db,
+		db:      db,
+		keyring: keyring,
 	}
 }
 
@@ -29,9 +38,14 @@ func (p *PostgesUserRepository) CreateWithToken(
 
 	var token *domain.Token
 
-	err := runInTx(ctx, p.db, func(tx pgx.Tx) error {
-		query := `INSERT INTO users (first_name, last_name, email, password_hash, birth_date, gender)
-		VALUES ($1, $2, $3, $4, $5, $6)
+	birthDate, encryptedBirthDate, err := p.sealBirthDate(user.BirthDate)
+	if err != nil {
+		return nil, err
+	}
+
+	err = runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		query := `INSERT INTO users (first_name, last_name, email, password_hash, birth_date, birth_date_encrypted, gender)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING id, created_at, activated, version`
 
 		err := tx.QueryRow(ctx,
@@ -40,7 +54,8 @@ func (p *PostgesUserRepository) CreateWithToken(
 			&user.LastName,
 			&user.Email,
 			&user.Password.Hash,
-			&user.BirthDate,
+			birthDate,
+			encryptedBirthDate,
 			&user.Gender).Scan(&user.ID, &user.CreatedAt, &user.Activated, &user.Version)
 
 		if err != nil {
@@ -83,7 +98,7 @@ func (p *PostgesUserRepository) GetByToken(
 ) (*domain.User, error) {
 	query := `
 		SELECT 
-			u.id, u.first_name, u.last_name, u.birth_date,
+			u.id, u.first_name, u.last_name, u.birth_date, u.birth_date_encrypted,
 			u.gender, u.email, u.password_hash, u.activated, u.version
 		FROM users u
 		INNER JOIN tokens t ON u.id = t.user_id
@@ -91,11 +106,15 @@ func (p *PostgesUserRepository) GetByToken(
 
 	user := &domain.User{}
 
+	var birthDate *time.Time
+	var encryptedBirthDate []byte
+
 	err := p.db.QueryRow(ctx, query, tokenHash, tokenScope, time.Now()).Scan(
 		&user.ID,
 		&user.FirstName,
 		&user.LastName,
-		&user.BirthDate,
+		&birthDate,
+		&encryptedBirthDate,
 		&user.Gender,
 		&user.Email,
 		&user.Password.Hash,
@@ -110,6 +129,11 @@ func (p *PostgesUserRepository) GetByToken(
 		return nil, err
 	}
 
+	user.BirthDate, err = p.openBirthDate(birthDate, encryptedBirthDate)
+	if err != nil {
+		return nil, err
+	}
+
 	return user, nil
 }
 
@@ -119,7 +143,8 @@ func (p *PostgesUserRepository) Update(ctx context.Context, user *domain.User) e
 		SET first_name    = COALESCE($3, first_name),
 			last_name     = COALESCE($4, last_name),
 			password_hash = COALESCE($5, password_hash),
-			birth_date    = COALESCE($6, birth_date),
+			birth_date    = CASE WHEN $9::bytea IS NULL THEN COALESCE($6, birth_date) END,
+			birth_date_encrypted = $9,
 			gender        = COALESCE($7, gender),
 			activated     = COALESCE($8, activated),
 			updated_at    = NOW(),
@@ -127,16 +152,22 @@ func (p *PostgesUserRepository) Update(ctx context.Context, user *domain.User) e
 		WHERE id = $1 AND version = $2
 		RETURNING version`
 
+	birthDate, encryptedBirthDate, err := p.sealBirthDate(user.BirthDate)
+	if err != nil {
+		return err
+	}
+
 	args := []any{user.ID,
 		user.Version,
 		user.FirstName,
 		user.LastName,
 		user.Password.Hash,
-		user.BirthDate,
+		birthDate,
 		user.Gender,
-		user.Activated}
+		user.Activated,
+		encryptedBirthDate}
 
-	err := p.db.QueryRow(ctx, query, args...).Scan(&user.Version)
+	err = p.db.QueryRow(ctx, query, args...).Scan(&user.Version)
 	if err != nil {
 		switch {
 		case errors.Is(err, pgx.ErrNoRows):
@@ -176,14 +207,61 @@ func (p *PostgesUserRepository) ActivateUser(ctx context.Context, user *domain.U
 	})
 }
 
+// ResetPassword consumes the token along with the change of the password, so a token is never used up
+// without the password being changed.
+func (p *PostgesUserRepository) ResetPassword(ctx context.Context, tokenHash []byte, passwordHash []byte) (int, error) {
+	var userID int
+
+	err := runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		query := `DELETE FROM tokens
+			WHERE hash = $1 AND scope = $2 AND expiry > $3
+			RETURNING user_id`
+
+		err := tx.QueryRow(ctx, query, tokenHash, domain.PasswordResetScope, time.Now()).Scan(&userID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return domain.ErrRecordNotFound
+			}
+
+			return err
+		}
+
+		query = `
+			UPDATE users
+			SET password_hash = $2, updated_at = NOW(), version = version + 1
+			WHERE id = $1 AND is_active = true`
+
+		cmd, err := tx.Exec(ctx, query, userID, passwordHash)
+		if err != nil {
+			return err
+		}
+
+		if cmd.RowsAffected() == 0 {
+			return domain.ErrRecordNotFound
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
 func (p *PostgesUserRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
-	query := `SELECT id, password_hash 
+	query := `SELECT id, first_name, email, password_hash, activated
 		FROM users
 		WHERE email = $1 AND activated = true AND is_active = true`
 
 	user := &domain.User{}
 
-	err := p.db.QueryRow(ctx, query, email).Scan(&user.ID, &user.Password.Hash)
+	err := p.db.QueryRow(ctx, query, email).Scan(
+		&user.ID,
+		&user.FirstName,
+		&user.Email,
+		&user.Password.Hash,
+		&user.Activated)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, domain.ErrRecordNotFound
@@ -195,24 +273,45 @@ func (p *PostgesUserRepository) GetByEmail(ctx context.Context, email string) (*
 	return user, nil
 }
 
+func (p *PostgesUserRepository) ExistsByEmail(ctx context.Context, email string) (bool, error) {
+	// unactivated accounts hold their address too, registering it again fails
+	query := `SELECT EXISTS (SELECT 1 FROM users WHERE email = $1 AND is_active = true)`
+
+	var exists bool
+
+	err := p.db.QueryRow(ctx, query, email).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
 func (p *PostgesUserRepository) GetById(ctx context.Context, id int) (*domain.User, error) {
-	query := `SELECT id, first_name, last_name, birth_date, gender, email, password_hash, activated, version, created_at
+	query := `SELECT id, first_name, last_name, birth_date, birth_date_encrypted, gender, email, password_hash,
+			activated, role, version, created_at, pending_changes
 		FROM users
 		WHERE id = $1 AND activated = true AND is_active = true`
 
 	user := &domain.User{}
 
+	var birthDate *time.Time
+	var encryptedBirthDate []byte
+
 	err := p.db.QueryRow(ctx, query, id).Scan(
 		&user.ID,
 		&user.FirstName,
 		&user.LastName,
-		&user.BirthDate,
+		&birthDate,
+		&encryptedBirthDate,
 		&user.Gender,
 		&user.Email,
 		&user.Password.Hash,
 		&user.Activated,
+		&user.Role,
 		&user.Version,
-		&user.CreatedAt)
+		&user.CreatedAt,
+		&user.PendingChanges)
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -222,6 +321,11 @@ func (p *PostgesUserRepository) GetById(ctx context.Context, id int) (*domain.Us
 		return nil, err
 	}
 
+	user.BirthDate, err = p.openBirthDate(birthDate, encryptedBirthDate)
+	if err != nil {
+		return nil, err
+	}
+
 	return user, nil
 }
 
@@ -241,3 +345,507 @@ func (p *PostgesUserRepository) Delete(ctx context.Context, user *domain.User) e
 
 	return nil
 }
+
+// GetPendingActivationReminders returns unactivated users whose activation token is still valid
+// but expires before the given time, and who have not been reminded yet.
+func (p *PostgesUserRepository) GetPendingActivationReminders(
+	ctx context.Context,
+	expiresBefore time.Time,
+) ([]*domain.User, error) {
+	query := `
+		SELECT u.id, u.first_name, u.email, u.version
+		FROM users u
+		INNER JOIN tokens t ON u.id = t.user_id
+		WHERE u.activated = false
+			AND u.is_active = true
+			AND u.activation_reminder_sent_at IS NULL
+			AND t.scope = $1
+			AND t.expiry > $2
+			AND t.expiry <= $3`
+
+	rows, err := p.db.Query(ctx, query, domain.UserActivationScope, time.Now(), expiresBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*domain.User
+
+	for rows.Next() {
+		user := &domain.User{}
+
+		err := rows.Scan(&user.ID, &user.FirstName, &user.Email, &user.Version)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (p *PostgesUserRepository) MarkActivationReminderSent(ctx context.Context, userID int) error {
+	query := `UPDATE users
+			SET activation_reminder_sent_at = NOW()
+			WHERE id = $1 AND activated = false`
+
+	_, err := p.db.Exec(ctx, query, userID)
+
+	return err
+}
+
+// DeleteUnactivatedCreatedBefore hard deletes accounts that were never activated and were created
+// before the cutoff, which frees their email addresses for new registrations. Tokens of the
+// deleted users are removed by the foreign key cascade. Accounts with payments or reservations,
+// e.g. of bookings made by phone, are kept along with their records.
+func (p *PostgesUserRepository) DeleteUnactivatedCreatedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	query := `DELETE FROM users u
+			WHERE u.activated = false AND u.created_at < $1
+				AND NOT EXISTS (SELECT 1 FROM payments p WHERE p.user_id = u.id)
+				AND NOT EXISTS (SELECT 1 FROM reservations r WHERE r.user_id = u.id)`
+
+	cmd, err := p.db.Exec(ctx, query, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	return cmd.RowsAffected(), nil
+}
+
+func (p *PostgesUserRepository) GetPreferences(ctx context.Context, userID int) (*domain.UserPreferences, error) {
+	query := `
+		SELECT
+			up.user_id, up.latitude, up.longitude, up.favorite_theater_id, up.language, up.seat_type::text,
+			up.marketing_emails, up.notification_digest,
+			ST_Y(t.location::geometry), ST_X(t.location::geometry)
+		FROM user_preferences up
+		LEFT JOIN theaters t ON t.id = up.favorite_theater_id
+		WHERE up.user_id = $1`
+
+	preferences := &domain.UserPreferences{}
+
+	err := p.db.QueryRow(ctx, query, userID).Scan(
+		&preferences.UserID,
+		&preferences.Latitude,
+		&preferences.Longitude,
+		&preferences.FavoriteTheaterID,
+		&preferences.Language,
+		&preferences.SeatType,
+		&preferences.MarketingEmails,
+		&preferences.NotificationDigest,
+		&preferences.FavoriteTheaterLatitude,
+		&preferences.FavoriteTheaterLongitude)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrRecordNotFound
+		}
+
+		return nil, err
+	}
+
+	return preferences, nil
+}
+
+func (p *PostgesUserRepository) UpsertPreferences(ctx context.Context, preferences *domain.UserPreferences) error {
+	return runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		query := `SELECT marketing_emails FROM user_preferences WHERE user_id = $1 FOR UPDATE`
+
+		var marketingEmails bool
+
+		err := tx.QueryRow(ctx, query, preferences.UserID).Scan(&marketingEmails)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+
+		query = `
+			INSERT INTO user_preferences (
+				user_id, latitude, longitude, favorite_theater_id, language, seat_type, marketing_emails, notification_digest)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (user_id) DO
+			UPDATE SET
+				latitude            = EXCLUDED.latitude,
+				longitude           = EXCLUDED.longitude,
+				favorite_theater_id = EXCLUDED.favorite_theater_id,
+				language            = EXCLUDED.language,
+				seat_type           = EXCLUDED.seat_type,
+				marketing_emails    = EXCLUDED.marketing_emails,
+				notification_digest = EXCLUDED.notification_digest,
+				updated_at          = NOW()`
+
+		_, err = tx.Exec(ctx,
+			query,
+			preferences.UserID,
+			preferences.Latitude,
+			preferences.Longitude,
+			preferences.FavoriteTheaterID,
+			preferences.Language,
+			preferences.SeatType,
+			preferences.MarketingEmails,
+			preferences.NotificationDigest)
+
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.ForeignKeyViolation {
+				return domain.ErrTheaterNotFound
+			}
+
+			return err
+		}
+
+		if !marketingEmails || preferences.MarketingEmails {
+			return nil
+		}
+
+		return insertConsent(ctx, tx, preferences.UserID, domain.ConsentMarketingEmails, false, domain.ConsentSourcePreferences)
+	})
+}
+
+func (p *PostgesUserRepository) SavePendingChanges(
+	ctx context.Context,
+	userID int,
+	changes domain.PendingChanges,
+	token *domain.Token) error {
+
+	return runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		if changes.Email != nil {
+			// checked again when the changes are applied, the address may be registered meanwhile
+			query := `SELECT EXISTS (SELECT 1 FROM users WHERE email = $1 AND is_active = true)`
+
+			var exists bool
+
+			err := tx.QueryRow(ctx, query, *changes.Email).Scan(&exists)
+			if err != nil {
+				return err
+			}
+
+			if exists {
+				return domain.ErrUserAlreadyExists
+			}
+		}
+
+		query := `UPDATE users
+			SET pending_changes = $2, updated_at = NOW()
+			WHERE id = $1`
+
+		_, err := tx.Exec(ctx, query, userID, changes)
+		if err != nil {
+			return err
+		}
+
+		query = `INSERT INTO tokens (hash, user_id, expiry, scope)
+			VALUES($1, $2, $3, $4)
+			ON CONFLICT ON CONSTRAINT unique_user_scope DO
+			UPDATE SET
+				hash = EXCLUDED.hash,
+				expiry = EXCLUDED.expiry`
+
+		_, err = tx.Exec(ctx, query, token.Hash, token.UserId, token.Expiry, token.Scope)
+		if err != nil {
+			return err
+		}
+
+		if changes.MarketingEmails == nil || !*changes.MarketingEmails {
+			return nil
+		}
+
+		return insertConsent(ctx, tx, userID, domain.ConsentMarketingEmails, true, changes.Source)
+	})
+}
+
+func (p *PostgesUserRepository) DiscardPendingChanges(ctx context.Context, userID int) error {
+	return runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		query := `UPDATE users
+			SET pending_changes = NULLIF(pending_changes - 'marketingEmails' - 'source', '{}'::jsonb),
+				updated_at = NOW()
+			WHERE id = $1 AND pending_changes IS NOT NULL
+			RETURNING pending_changes IS NOT NULL`
+
+		var stillPending bool
+
+		err := tx.QueryRow(ctx, query, userID).Scan(&stillPending)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+
+		// the token still verifies the email change left pending
+		if stillPending {
+			return nil
+		}
+
+		query = `DELETE FROM tokens WHERE scope = $1 AND user_id = $2`
+
+		_, err = tx.Exec(ctx, query, domain.PendingChangesScope, userID)
+
+		return err
+	})
+}
+
+func (p *PostgesUserRepository) ApplyPendingChanges(ctx context.Context, tokenHash []byte) (int, error) {
+	return p.applyPendingChanges(ctx, tokenHash, 0)
+}
+
+func (p *PostgesUserRepository) ApplyPendingEmailChange(ctx context.Context, userID int, tokenHash []byte) error {
+	_, err := p.applyPendingChanges(ctx, tokenHash, userID)
+
+	return err
+}
+
+// applyPendingChanges moves the pending changes of the user into the settings in use in a single
+// transaction, so they're either applied entirely or not at all. An email change is only applied when
+// the token is consumed for its owner, zero ownerID consumes the token of any user.
+func (p *PostgesUserRepository) applyPendingChanges(ctx context.Context, tokenHash []byte, ownerID int) (int, error) {
+	var userID int
+
+	err := runInTx(ctx, p.db, func(tx pgx.Tx) error {
+		query := `DELETE FROM tokens
+			WHERE hash = $1 AND scope = $2 AND expiry > $3 AND ($4 = 0 OR user_id = $4)
+			RETURNING user_id`
+
+		err := tx.QueryRow(ctx, query, tokenHash, domain.PendingChangesScope, time.Now(), ownerID).Scan(&userID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return domain.ErrRecordNotFound
+			}
+
+			return err
+		}
+
+		query = `UPDATE users u
+			SET pending_changes = NULL, updated_at = NOW()
+			FROM (SELECT id, pending_changes FROM users WHERE id = $1 AND is_active = true FOR UPDATE) old
+			WHERE u.id = old.id
+			RETURNING old.pending_changes`
+
+		var changes *domain.PendingChanges
+
+		err = tx.QueryRow(ctx, query, userID).Scan(&changes)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return domain.ErrRecordNotFound
+			}
+
+			return err
+		}
+
+		hasEmail := changes != nil && changes.Email != nil
+
+		switch {
+		case hasEmail && ownerID == 0:
+			return domain.ErrPendingEmailChange
+		case !hasEmail && ownerID != 0:
+			return domain.ErrRecordNotFound
+		case changes == nil:
+			return nil
+		}
+
+		if hasEmail {
+			query = `UPDATE users
+				SET email = $2, updated_at = NOW(), version = version + 1
+				WHERE id = $1`
+
+			_, err = tx.Exec(ctx, query, userID, *changes.Email)
+			if err != nil {
+				var pgErr *pgconn.PgError
+				if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+					return domain.ErrUserAlreadyExists
+				}
+
+				return err
+			}
+		}
+
+		if changes.MarketingEmails == nil {
+			return nil
+		}
+
+		query = `INSERT INTO user_preferences (user_id, marketing_emails)
+			VALUES ($1, $2)
+			ON CONFLICT (user_id) DO
+			UPDATE SET
+				marketing_emails = EXCLUDED.marketing_emails,
+				updated_at       = NOW()`
+
+		_, err = tx.Exec(ctx, query, userID, *changes.MarketingEmails)
+		if err != nil {
+			return err
+		}
+
+		// only the latest opt-in is confirmed, the ones it replaced were never verified
+		query = `UPDATE consents
+			SET confirmed_at = NOW()
+			WHERE id = (
+				SELECT id
+				FROM consents
+				WHERE user_id = $1 AND purpose = $2 AND granted = true AND confirmed_at IS NULL
+				ORDER BY id DESC
+				LIMIT 1
+			)`
+
+		_, err = tx.Exec(ctx, query, userID, domain.ConsentMarketingEmails)
+
+		return err
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// insertConsent records a consent decision of the user. An opt-in waits for the user to confirm it, a
+// withdrawal is confirmed right away.
+func insertConsent(
+	ctx context.Context,
+	tx pgx.Tx,
+	userID int,
+	purpose domain.ConsentPurpose,
+	granted bool,
+	source domain.ConsentSource) error {
+
+	query := `INSERT INTO consents (user_id, purpose, granted, source, confirmed_at)
+		VALUES ($1, $2, $3, $4, CASE WHEN $3 THEN NULL ELSE NOW() END)`
+
+	_, err := tx.Exec(ctx, query, userID, purpose, granted, source)
+
+	return err
+}
+
+// EncryptBirthDates encrypts plaintext birth dates and re-encrypts the ones sealed with a key other
+// than the current primary key. Rows are processed in batches of the given size, each batch in its
+// own transaction, so the migration can be interrupted and resumed safely. It returns the number of
+// rows that were updated.
+func (p *PostgesUserRepository) EncryptBirthDates(ctx context.Context, batchSize int) (int, error) {
+	if p.keyring == nil {
+		return 0, errors.New("birth date encryption requires a keyring")
+	}
+
+	var updated, lastID int
+
+	for {
+		var processed int
+
+		err := runInTx(ctx, p.db, func(tx pgx.Tx) error {
+			query := `
+				SELECT id, birth_date, birth_date_encrypted
+				FROM users
+				WHERE id > $1
+				ORDER BY id
+				LIMIT $2
+				FOR UPDATE`
+
+			rows, err := tx.Query(ctx, query, lastID, batchSize)
+			if err != nil {
+				return err
+			}
+
+			type pending struct {
+				id         int
+				ciphertext []byte
+			}
+
+			var batch []pending
+
+			for rows.Next() {
+				var id int
+				var birthDate *time.Time
+				var encryptedBirthDate []byte
+
+				if err := rows.Scan(&id, &birthDate, &encryptedBirthDate); err != nil {
+					rows.Close()
+					return err
+				}
+
+				processed++
+				lastID = id
+
+				if encryptedBirthDate != nil && !p.keyring.NeedsRotation(encryptedBirthDate) {
+					continue
+				}
+
+				date, err := p.openBirthDate(birthDate, encryptedBirthDate)
+				if err != nil {
+					rows.Close()
+					return fmt.Errorf("user %d: %w", id, err)
+				}
+
+				_, ciphertext, err := p.sealBirthDate(date)
+				if err != nil {
+					rows.Close()
+					return err
+				}
+
+				batch = append(batch, pending{id: id, ciphertext: ciphertext})
+			}
+
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+
+			query = `UPDATE users SET birth_date = NULL, birth_date_encrypted = $2 WHERE id = $1`
+
+			for _, row := range batch {
+				if _, err := tx.Exec(ctx, query, row.id, row.ciphertext); err != nil {
+					return err
+				}
+			}
+
+			updated += len(batch)
+
+			return nil
+		})
+
+		if err != nil {
+			return updated, err
+		}
+
+		if processed < batchSize {
+			return updated, nil
+		}
+	}
+}
+
+// sealBirthDate returns the values for the birth_date and birth_date_encrypted columns. Only one of
+// them is set, depending on whether encryption is enabled.
+func (p *PostgesUserRepository) sealBirthDate(birthDate time.Time) (*time.Time, []byte, error) {
+	if p.keyring == nil {
+		return &birthDate, nil, nil
+	}
+
+	ciphertext, err := p.keyring.Encrypt([]byte(birthDate.Format(time.DateOnly)), birthDateAAD)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return nil, ciphertext, nil
+}
+
+// openBirthDate resolves the birth date from whichever column is populated. Encrypted values take
+// precedence, plaintext values are still readable until they are migrated.
+func (p *PostgesUserRepository) openBirthDate(birthDate *time.Time, encrypted []byte) (time.Time, error) {
+	if encrypted == nil {
+		if birthDate == nil {
+			return time.Time{}, nil
+		}
+
+		return *birthDate, nil
+	}
+
+	if p.keyring == nil {
+		return time.Time{}, errors.New("encrypted birth date found but no keyring is configured")
+	}
+
+	plaintext, err := p.keyring.Decrypt(encrypted, birthDateAAD)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Parse(time.DateOnly, string(plaintext))
+}
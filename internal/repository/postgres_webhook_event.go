@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+type PostgresWebhookEventRepository struct {
+	db DB
+}
+
+func NewPostgresWebhookEventRepository(db DB) *PostgresWebhookEventRepository {
+	return &PostgresWebhookEventRepository{
+		db: db,
+	}
+}
+
+func (p *PostgresWebhookEventRepository) Store(ctx context.Context, event *domain.WebhookEvent) (bool, error) {
+	query := `
+		INSERT INTO stripe_webhook_events (id, type, payload)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO NOTHING
+		RETURNING received_at`
+
+	err := p.db.QueryRow(ctx, query, event.ID, event.Type, event.Payload).Scan(&event.ReceivedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (p *PostgresWebhookEventRepository) GetById(ctx context.Context, id string) (*domain.WebhookEvent, error) {
+	query := `
+		SELECT id, type, payload, received_at, last_replayed_at
+		FROM stripe_webhook_events
+		WHERE id = $1`
+
+	var event domain.WebhookEvent
+
+	err := p.db.QueryRow(ctx, query, id).Scan(
+		&event.ID,
+		&event.Type,
+		&event.Payload,
+		&event.ReceivedAt,
+		&event.LastReplayedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrRecordNotFound
+		}
+
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+func (p *PostgresWebhookEventRepository) MarkReplayed(ctx context.Context, id string) error {
+	query := `
+		UPDATE stripe_webhook_events
+		SET last_replayed_at = NOW()
+		WHERE id = $1`
+
+	result, err := p.db.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrRecordNotFound
+	}
+
+	return nil
+}
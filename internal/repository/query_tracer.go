@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const repositoryPackage = "github.com/metinatakli/movie-reservation-system/internal/repository."
+
+// QueryTracer times every query sent through the pool. A query is named after the repository method which
+// runs it, looked up on the call stack, so the repositories don't have to label their queries. Queries
+// taking at least the slow query threshold are logged with their arguments, of which only numbers,
+// booleans and times are kept as they may hold personal data otherwise.
+type QueryTracer struct {
+	logger        *slog.Logger
+	slowThreshold time.Duration
+	duration      metric.Float64Histogram
+}
+
+// NewQueryTracer creates a tracer logging the queries taking at least slowThreshold. A zero threshold
+// disables the logging, the latency is recorded anyway.
+func NewQueryTracer(logger *slog.Logger, slowThreshold time.Duration) *QueryTracer {
+	meter := otel.Meter("github.com/metinatakli/movie-reservation-system/internal/repository")
+
+	duration, err := meter.Float64Histogram(
+		"db.repository.query.duration",
+		metric.WithDescription("Duration of the queries of the repositories"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		logger.Error("failed to create query duration metric", "error", err)
+	}
+
+	return &QueryTracer{
+		logger:        logger,
+		slowThreshold: slowThreshold,
+		duration:      duration,
+	}
+}
+
+type queryTraceKey struct{}
+
+type queryTrace struct {
+	start      time.Time
+	repository string
+	method     string
+	args       []any
+}
+
+func (t *QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	repository, method := callingRepository()
+
+	return context.WithValue(ctx, queryTraceKey{}, &queryTrace{
+		start:      time.Now(),
+		repository: repository,
+		method:     method,
+		args:       data.Args,
+	})
+}
+
+func (t *QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	trace, ok := ctx.Value(queryTraceKey{}).(*queryTrace)
+	if !ok {
+		return
+	}
+
+	elapsed := time.Since(trace.start)
+
+	if t.duration != nil {
+		t.duration.Record(ctx, elapsed.Seconds(), metric.WithAttributes(
+			attribute.String("db.repository", trace.repository),
+			attribute.String("db.query.name", trace.method),
+			attribute.Bool("error", data.Err != nil),
+		))
+	}
+
+	if t.slowThreshold > 0 && elapsed >= t.slowThreshold {
+		args := make([]any, len(trace.args))
+		for i, arg := range trace.args {
+			args[i] = sanitizeQueryArg(arg)
+		}
+
+		t.logger.WarnContext(ctx, "slow query",
+			"repository", trace.repository,
+			"query", trace.method,
+			"duration", elapsed,
+			"args", args)
+	}
+}
+
+// callingRepository returns the repository and the method running the current query. The outermost
+// repository method on the stack is taken, so a query of a helper shared by several methods or of a
+// transaction callback is attributed to the method the caller invoked. Queries not run by a repository,
+// like the ones of the migrations, are named "unknown".
+func callingRepository() (string, string) {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	repository, method := "unknown", "unknown"
+
+	for {
+		frame, more := frames.Next()
+
+		// methods look like "(*PostgresUserRepository).GetById", closures within them get a ".func1" suffix
+		if name, ok := strings.CutPrefix(frame.Function, repositoryPackage+"(*"); ok {
+			if typ, rest, ok := strings.Cut(name, ")."); ok && typ != "QueryTracer" {
+				repository = typ
+				method, _, _ = strings.Cut(rest, ".")
+			}
+		}
+
+		if !more {
+			break
+		}
+	}
+
+	return repository, method
+}
+
+// sanitizeQueryArg returns the argument as it may be logged: strings, byte slices and other values are
+// replaced with their type and size.
+func sanitizeQueryArg(arg any) any {
+	v := reflect.ValueOf(arg)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	if !v.IsValid() {
+		return nil
+	}
+
+	if t, ok := v.Interface().(time.Time); ok {
+		return t
+	}
+
+	switch v.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return v.Interface()
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return fmt.Sprintf("<%s len=%d>", v.Type(), v.Len())
+	default:
+		return fmt.Sprintf("<%s>", v.Type())
+	}
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type PostgresOuterTestRepository struct {
+	inner *PostgresInnerTestRepository
+}
+
+type PostgresInnerTestRepository struct{}
+
+// traceQuery stands in for TraceQueryStart, which pgx calls from within the repository method
+func traceQuery() (string, string) {
+	return callingRepository()
+}
+
+func (r *PostgresInnerTestRepository) GetById() (string, string) {
+	return traceQuery()
+}
+
+func (r *PostgresInnerTestRepository) Update() (string, string) {
+	return runInTestTx(func() (string, string) {
+		return traceQuery()
+	})
+}
+
+func (r *PostgresOuterTestRepository) Create() (string, string) {
+	return r.inner.GetById()
+}
+
+func runInTestTx(fn func() (string, string)) (string, string) {
+	return fn()
+}
+
+func TestCallingRepository(t *testing.T) {
+	inner := &PostgresInnerTestRepository{}
+	outer := &PostgresOuterTestRepository{inner: inner}
+
+	tests := []struct {
+		name           string
+		query          func() (string, string)
+		wantRepository string
+		wantMethod     string
+	}{
+		{
+			name:           "repository method",
+			query:          inner.GetById,
+			wantRepository: "PostgresInnerTestRepository",
+			wantMethod:     "GetById",
+		},
+		{
+			name:           "transaction callback",
+			query:          inner.Update,
+			wantRepository: "PostgresInnerTestRepository",
+			wantMethod:     "Update",
+		},
+		{
+			name:           "outermost repository method",
+			query:          outer.Create,
+			wantRepository: "PostgresOuterTestRepository",
+			wantMethod:     "Create",
+		},
+		{
+			name:           "not run by a repository",
+			query:          traceQuery,
+			wantRepository: "unknown",
+			wantMethod:     "unknown",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository, method := tt.query()
+
+			if repository != tt.wantRepository || method != tt.wantMethod {
+				t.Errorf("callingRepository() = %q, %q, want %q, %q",
+					repository, method, tt.wantRepository, tt.wantMethod)
+			}
+		})
+	}
+}
+
+func TestSanitizeQueryArg(t *testing.T) {
+	now := time.Now()
+	id := 42
+	var nilID *int
+
+	tests := []struct {
+		name string
+		arg  any
+		want any
+	}{
+		{name: "nil", arg: nil, want: nil},
+		{name: "nil pointer", arg: nilID, want: nil},
+		{name: "number", arg: 42, want: 42},
+		{name: "pointer to a number", arg: &id, want: 42},
+		{name: "bool", arg: true, want: true},
+		{name: "time", arg: now, want: now},
+		{name: "string", arg: "jane@example.com", want: "<string len=16>"},
+		{name: "long string", arg: string(make([]byte, 10_000)), want: "<string len=10000>"},
+		{name: "byte slice", arg: []byte("secret"), want: "<[]uint8 len=6>"},
+		{name: "slice", arg: []int{1, 2, 3}, want: "<[]int len=3>"},
+		{name: "map", arg: map[string]any{"email": "jane@example.com"}, want: "<map[string]interface {} len=1>"},
+		{name: "struct", arg: struct{ Email string }{"jane@example.com"}, want: "<struct { Email string }>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeQueryArg(tt.arg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sanitizeQueryArg(%v) = %v, want %v", tt.arg, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Locker hands out exclusive, expiring locks for job runs so that a job runs on one instance at a time
+// when the application is scaled horizontally.
+type Locker interface {
+	// TryLock returns a lease if the lock was free or already held by this locker, or nil if another
+	// instance holds it.
+	TryLock(ctx context.Context, name string, ttl time.Duration) (*Lease, error)
+}
+
+// ErrLeaseLost is returned by CheckFence once the lock of the running job expired or was acquired again,
+// e.g. by another instance while the run was paused past the TTL of its lock.
+var ErrLeaseLost = errors.New("the job lock was lost to a later run")
+
+// Lease is a held lock. Fence is a fencing token which increases with every acquisition of the same lock,
+// so a stale holder whose lease already expired can be told apart from the current one.
+type Lease struct {
+	Name    string
+	Fence   int64
+	release func(ctx context.Context, hold time.Duration) error
+	check   func(ctx context.Context) error
+}
+
+// Release lets go of the lock once hold has passed, or right away when hold isn't positive. Holding on to
+// the lock after a run keeps the instances which tick later in the same interval from running the job again.
+func (l *Lease) Release(ctx context.Context, hold time.Duration) error {
+	return l.release(ctx, hold)
+}
+
+// Check returns ErrLeaseLost if the lease isn't the latest acquisition of the lock anymore.
+func (l *Lease) Check(ctx context.Context) error {
+	return l.check(ctx)
+}
+
+type leaseContextKey struct{}
+
+// FenceFromContext returns the fencing token of the lease the running job holds.
+func FenceFromContext(ctx context.Context) (int64, bool) {
+	lease, ok := ctx.Value(leaseContextKey{}).(*Lease)
+	if !ok {
+		return 0, false
+	}
+
+	return lease.Fence, true
+}
+
+// CheckFence returns ErrLeaseLost if the lock of the running job was lost since the run started. Jobs
+// check it before every write, so a run which was paused past the TTL of its lock doesn't write over the
+// run which took the job over. Jobs running without a lock always pass.
+func CheckFence(ctx context.Context) error {
+	lease, ok := ctx.Value(leaseContextKey{}).(*Lease)
+	if !ok {
+		return nil
+	}
+
+	return lease.Check(ctx)
+}
+
+// acquireLock takes the lock when it's free or already owned by the caller, the instance which ran the
+// previous interval keeps the job as long as it's alive. Every acquisition increments the fence.
+var acquireLock = redis.NewScript(`
+	local owner = redis.call("GET", KEYS[1])
+
+	if owner == false or owner == ARGV[1] then
+		redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+		return redis.call("INCR", KEYS[2])
+	end
+
+	return 0
+`)
+
+var checkLock = redis.NewScript(`
+	if redis.call("GET", KEYS[1]) == ARGV[1] and redis.call("GET", KEYS[2]) == ARGV[2] then
+		return 1
+	end
+
+	return 0
+`)
+
+var releaseLock = redis.NewScript(`
+	if redis.call("GET", KEYS[1]) ~= ARGV[1] or redis.call("GET", KEYS[2]) ~= ARGV[3] then
+		return 0
+	end
+
+	if tonumber(ARGV[2]) > 0 then
+		return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	end
+
+	return redis.call("DEL", KEYS[1])
+`)
+
+// Scripts returns the Lua scripts of the locks, e.g. to load them into Redis ahead of their first use.
+func Scripts() []*redis.Script {
+	return []*redis.Script{acquireLock, checkLock, releaseLock}
+}
+
+type RedisLocker struct {
+	client redis.UniversalClient
+	// owner identifies the locks of this instance
+	owner string
+}
+
+func NewRedisLocker(client redis.UniversalClient) *RedisLocker {
+	return &RedisLocker{
+		client: client,
+		owner:  rand.Text(),
+	}
+}
+
+func (l *RedisLocker) TryLock(ctx context.Context, name string, ttl time.Duration) (*Lease, error) {
+	keys := []string{
+		fmt.Sprintf("scheduler:lock:%s", name),
+		fmt.Sprintf("scheduler:fence:%s", name),
+	}
+
+	fence, err := acquireLock.Run(ctx, l.client, keys, l.owner, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return nil, err
+	}
+
+	if fence == 0 {
+		return nil, nil
+	}
+
+	lease := &Lease{
+		Name:  name,
+		Fence: fence,
+		release: func(ctx context.Context, hold time.Duration) error {
+			// only touch the lock if it still belongs to this lease
+			return releaseLock.Run(ctx, l.client, keys, l.owner, hold.Milliseconds(), fence).Err()
+		},
+		check: func(ctx context.Context) error {
+			held, err := checkLock.Run(ctx, l.client, keys, l.owner, fence).Int()
+			if err != nil {
+				return err
+			}
+
+			if held == 0 {
+				return ErrLeaseLost
+			}
+
+			return nil
+		},
+	}
+
+	return lease, nil
+}
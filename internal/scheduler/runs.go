@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const runsKey = "scheduler:runs"
+
+// Run describes the last completed run of a job. Runs skipped because another instance held the job
+// lock are not recorded.
+type Run struct {
+	Job        string    `json:"job"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Error      string    `json:"error,omitempty"`
+	Fence      int64     `json:"fence,omitempty"`
+}
+
+// RunStore keeps the last run of every job, shared by all instances so that any of them can report on
+// jobs which ran elsewhere.
+type RunStore interface {
+	RecordRun(ctx context.Context, run Run) error
+	// LastRuns returns the last recorded run of every job keyed by job name.
+	LastRuns(ctx context.Context) (map[string]Run, error)
+}
+
+type RedisRunStore struct {
+	client redis.UniversalClient
+}
+
+func NewRedisRunStore(client redis.UniversalClient) *RedisRunStore {
+	return &RedisRunStore{
+		client: client,
+	}
+}
+
+func (s *RedisRunStore) RecordRun(ctx context.Context, run Run) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+
+	return s.client.HSet(ctx, runsKey, run.Job, data).Err()
+}
+
+func (s *RedisRunStore) LastRuns(ctx context.Context) (map[string]Run, error) {
+	fields, err := s.client.HGetAll(ctx, runsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make(map[string]Run, len(fields))
+	for job, data := range fields {
+		var run Run
+		if err := json.Unmarshal([]byte(data), &run); err != nil {
+			return nil, fmt.Errorf("failed to decode last run of job %s: %w", job, err)
+		}
+
+		runs[job] = run
+	}
+
+	return runs, nil
+}
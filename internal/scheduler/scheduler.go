@@ -0,0 +1,240 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	lockAcquired  = "acquired"
+	lockContended = "contended"
+	lockFailed    = "error"
+)
+
+// Job is a unit of background work that is executed periodically by the Scheduler.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	// LockTTL bounds how long a run may hold the job lock, it defaults to the interval.
+	// The run is canceled when it takes longer than that.
+	LockTTL time.Duration
+	Run     func(ctx context.Context) error
+}
+
+type Scheduler struct {
+	logger *slog.Logger
+	locker Locker
+	runs   RunStore
+	jobs   []Job
+	wg     sync.WaitGroup
+
+	lockAttempts metric.Int64Counter
+	jobDuration  metric.Float64Histogram
+}
+
+// New creates a scheduler. When a locker is given, every run of a job takes the job lock first and keeps it
+// until the interval ends, so a job runs once per interval across all instances. Without a locker every
+// instance runs all jobs. When a run store is
+// given, the outcome of every run is recorded in it.
+func New(logger *slog.Logger, locker Locker, runs RunStore) *Scheduler {
+	s := &Scheduler{
+		logger: logger,
+		locker: locker,
+		runs:   runs,
+	}
+
+	meter := otel.Meter("github.com/metinatakli/movie-reservation-system/internal/scheduler")
+
+	var err error
+
+	s.lockAttempts, err = meter.Int64Counter(
+		"scheduler.lock.attempts",
+		metric.WithDescription("Number of job lock acquisition attempts by outcome"),
+	)
+	if err != nil {
+		logger.Error("failed to create scheduler lock metric", "error", err)
+	}
+
+	s.jobDuration, err = meter.Float64Histogram(
+		"scheduler.job.duration",
+		metric.WithDescription("Duration of background job runs"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		logger.Error("failed to create scheduler duration metric", "error", err)
+	}
+
+	return s
+}
+
+// Add registers a job. Jobs must be added before Start is called.
+func (s *Scheduler) Add(job Job) {
+	if job.LockTTL == 0 {
+		job.LockTTL = job.Interval
+	}
+
+	s.jobs = append(s.jobs, job)
+}
+
+// Start runs every registered job in its own goroutine until the given context is canceled.
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+
+		go func(job Job) {
+			defer s.wg.Done()
+			s.loop(ctx, job)
+		}(job)
+	}
+}
+
+// Wait blocks until all running jobs have returned after the scheduler context is canceled.
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.run(ctx, job)
+		}
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, job Job) {
+	logger := s.logger.With("job", job.Name)
+
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Error("panic occurred while running background job", "panic", err)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, job.LockTTL)
+	defer cancel()
+
+	if s.locker != nil {
+		lockedAt := time.Now()
+
+		lease, err := s.locker.TryLock(ctx, job.Name, job.LockTTL)
+		if err != nil {
+			s.recordLockAttempt(ctx, job.Name, lockFailed)
+			logger.Error("failed to acquire job lock", "error", err)
+			return
+		}
+
+		if lease == nil {
+			s.recordLockAttempt(ctx, job.Name, lockContended)
+			logger.Debug("skipping background job, it is running on another instance")
+			return
+		}
+
+		s.recordLockAttempt(ctx, job.Name, lockAcquired)
+
+		defer func() {
+			// the run context may already be done, releasing must not depend on it
+			releaseCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+
+			// the lock is kept until the interval ends, otherwise every instance ticking later in the
+			// interval would run the job once more
+			hold := job.Interval - time.Since(lockedAt)
+
+			if err := lease.Release(releaseCtx, hold); err != nil {
+				logger.Error("failed to release job lock", "error", err)
+			}
+		}()
+
+		ctx = context.WithValue(ctx, leaseContextKey{}, lease)
+		logger = logger.With("fence", lease.Fence)
+	}
+
+	start := time.Now()
+
+	err := job.Run(ctx)
+
+	duration := time.Since(start)
+	status := "success"
+	if err != nil {
+		status = "error"
+	}
+
+	s.recordRun(ctx, job.Name, start, duration, err)
+
+	if s.jobDuration != nil {
+		s.jobDuration.Record(ctx, duration.Seconds(), metric.WithAttributes(
+			attribute.String("job", job.Name),
+			attribute.String("status", status),
+		))
+	}
+
+	if errors.Is(err, ErrLeaseLost) {
+		logger.Warn("background job stopped, its lock was taken over", "duration", duration.String())
+		return
+	}
+
+	if err != nil {
+		logger.Error("background job failed", "error", err, "duration", duration.String())
+		return
+	}
+
+	logger.Debug("background job completed", "duration", duration.String())
+}
+
+func (s *Scheduler) recordRun(ctx context.Context, job string, start time.Time, duration time.Duration, runErr error) {
+	if s.runs == nil {
+		return
+	}
+
+	run := Run{
+		Job:        job,
+		StartedAt:  start.UTC(),
+		FinishedAt: start.Add(duration).UTC(),
+	}
+
+	if runErr != nil {
+		run.Error = runErr.Error()
+	}
+
+	if fence, ok := FenceFromContext(ctx); ok {
+		run.Fence = fence
+	}
+
+	// the run context may have timed out, recording the outcome must not depend on it
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 3*time.Second)
+	defer cancel()
+
+	// the run which took the job over records its own outcome
+	if err := CheckFence(recordCtx); err != nil {
+		s.logger.Warn("not recording background job run", "job", job, "error", err)
+		return
+	}
+
+	if err := s.runs.RecordRun(recordCtx, run); err != nil {
+		s.logger.Error("failed to record background job run", "job", job, "error", err)
+	}
+}
+
+func (s *Scheduler) recordLockAttempt(ctx context.Context, job, outcome string) {
+	if s.lockAttempts == nil {
+		return
+	}
+
+	s.lockAttempts.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("job", job),
+		attribute.String("outcome", outcome),
+	))
+}
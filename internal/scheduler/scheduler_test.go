@@ -0,0 +1,263 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeLocker struct {
+	held     bool
+	lost     bool
+	fence    atomic.Int64
+	released atomic.Int32
+	lastHold atomic.Int64
+}
+
+func (l *fakeLocker) TryLock(ctx context.Context, name string, ttl time.Duration) (*Lease, error) {
+	if l.held {
+		return nil, nil
+	}
+
+	return &Lease{
+		Name:  name,
+		Fence: l.fence.Add(1),
+		release: func(ctx context.Context, hold time.Duration) error {
+			l.released.Add(1)
+			l.lastHold.Store(int64(hold))
+			return nil
+		},
+		check: func(ctx context.Context) error {
+			if l.lost {
+				return ErrLeaseLost
+			}
+
+			return nil
+		},
+	}, nil
+}
+
+func TestSchedulerRunsJobsOnlyWithLock(t *testing.T) {
+	tests := []struct {
+		name    string
+		locker  *fakeLocker
+		wantRun bool
+	}{
+		{name: "without locker", wantRun: true},
+		{name: "lock acquired", locker: &fakeLocker{}, wantRun: true},
+		{name: "lock held by another instance", locker: &fakeLocker{held: true}, wantRun: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var runs atomic.Int32
+
+			var locker Locker
+			if tt.locker != nil {
+				locker = tt.locker
+			}
+
+			s := New(slog.New(slog.NewTextHandler(io.Discard, nil)), locker, nil)
+			s.Add(Job{
+				Name:     "test",
+				Interval: 5 * time.Millisecond,
+				Run: func(ctx context.Context) error {
+					runs.Add(1)
+					return nil
+				},
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			s.Start(ctx)
+			time.Sleep(50 * time.Millisecond)
+			cancel()
+			s.Wait()
+
+			if gotRun := runs.Load() > 0; gotRun != tt.wantRun {
+				t.Errorf("job ran = %v, want %v", gotRun, tt.wantRun)
+			}
+
+			if tt.locker != nil && tt.wantRun {
+				if got := tt.locker.released.Load(); got != runs.Load() {
+					t.Errorf("released locks = %d, want %d", got, runs.Load())
+				}
+
+				if hold := time.Duration(tt.locker.lastHold.Load()); hold == 0 || hold > 5*time.Millisecond {
+					t.Errorf("lock held for %s after the run, want the rest of the interval", hold)
+				}
+			}
+		})
+	}
+}
+
+func TestSchedulerRecoversFromPanickingJob(t *testing.T) {
+	var runs atomic.Int32
+
+	s := New(slog.New(slog.NewTextHandler(io.Discard, nil)), nil, nil)
+	s.Add(Job{
+		Name:     "panicking",
+		Interval: 5 * time.Millisecond,
+		Run: func(ctx context.Context) error {
+			runs.Add(1)
+			panic("boom")
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	s.Wait()
+
+	if runs.Load() < 2 {
+		t.Errorf("job runs = %d, want the job to keep running after a panic", runs.Load())
+	}
+}
+
+type fakeRunStore struct {
+	mu   sync.Mutex
+	runs map[string]Run
+}
+
+func (s *fakeRunStore) RecordRun(ctx context.Context, run Run) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.runs[run.Job] = run
+	return nil
+}
+
+func (s *fakeRunStore) LastRuns(ctx context.Context) (map[string]Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.runs, nil
+}
+
+func TestSchedulerRecordsRuns(t *testing.T) {
+	store := &fakeRunStore{runs: make(map[string]Run)}
+
+	s := New(slog.New(slog.NewTextHandler(io.Discard, nil)), nil, store)
+	s.Add(Job{
+		Name:     "succeeding",
+		Interval: 5 * time.Millisecond,
+		Run:      func(ctx context.Context) error { return nil },
+	})
+	s.Add(Job{
+		Name:     "failing",
+		Interval: 5 * time.Millisecond,
+		Run:      func(ctx context.Context) error { return errors.New("smtp error") },
+	})
+
+	before := time.Now()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	s.Wait()
+
+	runs, _ := store.LastRuns(context.Background())
+
+	succeeded, ok := runs["succeeding"]
+	if !ok {
+		t.Fatal("run of the succeeding job was not recorded")
+	}
+
+	if succeeded.Error != "" || succeeded.StartedAt.Before(before) || succeeded.FinishedAt.Before(succeeded.StartedAt) {
+		t.Errorf("unexpected run of the succeeding job: %+v", succeeded)
+	}
+
+	if failed := runs["failing"]; failed.Error != "smtp error" {
+		t.Errorf("error of the failing job = %q, want %q", failed.Error, "smtp error")
+	}
+}
+
+func TestSchedulerFencesLockedRuns(t *testing.T) {
+	tests := []struct {
+		name      string
+		locker    *fakeLocker
+		wantFence bool
+		wantErr   error
+		wantRun   bool
+	}{
+		{name: "without locker", wantErr: nil, wantRun: true},
+		{name: "lease held", locker: &fakeLocker{}, wantFence: true, wantErr: nil, wantRun: true},
+		{name: "lease lost", locker: &fakeLocker{lost: true}, wantFence: true, wantErr: ErrLeaseLost, wantRun: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var fences []int64
+			var checkErr error
+
+			var locker Locker
+			if tt.locker != nil {
+				locker = tt.locker
+			}
+
+			store := &fakeRunStore{runs: make(map[string]Run)}
+
+			s := New(slog.New(slog.NewTextHandler(io.Discard, nil)), locker, store)
+			s.Add(Job{
+				Name:     "test",
+				Interval: 5 * time.Millisecond,
+				Run: func(ctx context.Context) error {
+					mu.Lock()
+					defer mu.Unlock()
+
+					if fence, ok := FenceFromContext(ctx); ok {
+						fences = append(fences, fence)
+					}
+
+					checkErr = CheckFence(ctx)
+					return checkErr
+				},
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			s.Start(ctx)
+			time.Sleep(30 * time.Millisecond)
+			cancel()
+			s.Wait()
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if !errors.Is(checkErr, tt.wantErr) {
+				t.Errorf("CheckFence() = %v, want %v", checkErr, tt.wantErr)
+			}
+
+			if !tt.wantFence && len(fences) > 0 {
+				t.Errorf("fences = %v, want none without a lock", fences)
+			}
+
+			if tt.wantFence {
+				if len(fences) < 2 {
+					t.Fatalf("fences = %v, want one per run", fences)
+				}
+
+				for i := 1; i < len(fences); i++ {
+					if fences[i] <= fences[i-1] {
+						t.Errorf("fences = %v, want them to increase with every acquisition", fences)
+					}
+				}
+			}
+
+			run, recorded := store.runs["test"]
+			if recorded != tt.wantRun {
+				t.Fatalf("run recorded = %v, want %v", recorded, tt.wantRun)
+			}
+
+			if recorded && tt.wantFence && run.Fence == 0 {
+				t.Errorf("recorded run = %+v, want its fence", run)
+			}
+		})
+	}
+}
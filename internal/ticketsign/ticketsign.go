@@ -0,0 +1,203 @@
+// Package ticketsign signs the codes of tickets, so door scanners can verify a ticket while they're
+// offline with the public keys they fetched before.
+//
+// A signed code is made of three parts separated by dots: the id of the signing key, the payload as
+// base64url encoded JSON and the Ed25519 signature of the first two parts joined by the dot, base64url
+// encoded too. Keys are rotated by adding a new primary key, the old ones keep verifying the codes
+// issued with them until they're removed.
+package ticketsign
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const maxKeyIDLength = 32
+
+var (
+	ErrUnknownKey    = errors.New("ticketsign: unknown key id")
+	ErrInvalidKey    = errors.New("ticketsign: keys must be 32 byte Ed25519 seeds")
+	ErrInvalidTicket = errors.New("ticketsign: malformed code or invalid signature")
+	ErrExpired       = errors.New("ticketsign: ticket has expired")
+)
+
+// Payload is what a signed code tells about its ticket.
+type Payload struct {
+	ReservationID int
+	// TicketVersion tells replaced tickets apart, only the online check knows the current version
+	TicketVersion int
+	ShowtimeID    int
+	// Seats are the row and column of each seat of the reservation
+	Seats     [][2]int
+	ExpiresAt time.Time
+}
+
+// payload is the JSON form of Payload, with short names as it's encoded in a QR code.
+type payload struct {
+	ReservationID int      `json:"rid"`
+	TicketVersion int      `json:"v"`
+	ShowtimeID    int      `json:"sid"`
+	Seats         [][2]int `json:"seats"`
+	ExpiresAt     int64    `json:"exp"`
+}
+
+// PublicKey is a key scanners verify codes with.
+type PublicKey struct {
+	ID  string
+	Key ed25519.PublicKey
+	// Primary is set for the key new codes are signed with
+	Primary bool
+}
+
+// Keyring holds the signing keys. Codes are signed with the primary key and verified with any key.
+type Keyring struct {
+	primary string
+	ids     []string
+	keys    map[string]ed25519.PrivateKey
+}
+
+// ParseKeyring parses a comma separated list of id:base64-seed pairs. The first key in the list becomes
+// the primary key. An empty spec yields a nil keyring, tickets are not signed then.
+func ParseKeyring(spec string) (*Keyring, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+
+	ring := &Keyring{keys: make(map[string]ed25519.PrivateKey)}
+
+	for _, entry := range strings.Split(spec, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			return nil, fmt.Errorf("ticketsign: key entry must be in id:base64 form")
+		}
+
+		// the id is the first part of the codes, it must not contain their separator
+		if id == "" || len(id) > maxKeyIDLength || strings.Contains(id, ".") {
+			return nil, fmt.Errorf("ticketsign: invalid key id %q", id)
+		}
+
+		seed, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("ticketsign: key %q is not valid base64: %w", id, err)
+		}
+
+		if len(seed) != ed25519.SeedSize {
+			return nil, ErrInvalidKey
+		}
+
+		if _, exists := ring.keys[id]; exists {
+			return nil, fmt.Errorf("ticketsign: duplicate key id %q", id)
+		}
+
+		if ring.primary == "" {
+			ring.primary = id
+		}
+
+		ring.ids = append(ring.ids, id)
+		ring.keys[id] = ed25519.NewKeyFromSeed(seed)
+	}
+
+	return ring, nil
+}
+
+// PublicKeys returns the public keys in the order they were configured, the primary key first.
+func (k *Keyring) PublicKeys() []PublicKey {
+	keys := make([]PublicKey, len(k.ids))
+
+	for i, id := range k.ids {
+		keys[i] = PublicKey{
+			ID:      id,
+			Key:     k.keys[id].Public().(ed25519.PublicKey),
+			Primary: id == k.primary,
+		}
+	}
+
+	return keys
+}
+
+// Sign returns the code of the ticket signed with the primary key.
+func (k *Keyring) Sign(p Payload) (string, error) {
+	data, err := json.Marshal(payload{
+		ReservationID: p.ReservationID,
+		TicketVersion: p.TicketVersion,
+		ShowtimeID:    p.ShowtimeID,
+		Seats:         p.Seats,
+		ExpiresAt:     p.ExpiresAt.Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signed := k.primary + "." + base64.RawURLEncoding.EncodeToString(data)
+	signature := ed25519.Sign(k.keys[k.primary], []byte(signed))
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Verify checks the signature of the code and returns its payload. Codes past their expiry are reported
+// as ErrExpired.
+func (k *Keyring) Verify(code string, now time.Time) (*Payload, error) {
+	signed, encodedSignature, ok := cutLast(code, ".")
+	if !ok {
+		return nil, ErrInvalidTicket
+	}
+
+	id, encodedPayload, ok := strings.Cut(signed, ".")
+	if !ok {
+		return nil, ErrInvalidTicket
+	}
+
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || !ed25519.Verify(key.Public().(ed25519.PublicKey), []byte(signed), signature) {
+		return nil, ErrInvalidTicket
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, ErrInvalidTicket
+	}
+
+	var p payload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, ErrInvalidTicket
+	}
+
+	expiresAt := time.Unix(p.ExpiresAt, 0).UTC()
+	if !now.Before(expiresAt) {
+		return nil, ErrExpired
+	}
+
+	return &Payload{
+		ReservationID: p.ReservationID,
+		TicketVersion: p.TicketVersion,
+		ShowtimeID:    p.ShowtimeID,
+		Seats:         p.Seats,
+		ExpiresAt:     expiresAt,
+	}, nil
+}
+
+// IsSigned reports whether the code is in the form of a signed code, other codes are the ones derived
+// before tickets were signed.
+func IsSigned(code string) bool {
+	return strings.Count(code, ".") == 2
+}
+
+func cutLast(s, sep string) (string, string, bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return "", "", false
+	}
+
+	return s[:i], s[i+len(sep):], true
+}
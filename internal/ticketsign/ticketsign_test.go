@@ -0,0 +1,151 @@
+package ticketsign
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSeed(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, ed25519.SeedSize))
+}
+
+func TestSignVerify(t *testing.T) {
+	ring, err := ParseKeyring("k1:" + testSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2025, 6, 14, 19, 0, 0, 0, time.UTC)
+
+	payload := Payload{
+		ReservationID: 12,
+		TicketVersion: 1,
+		ShowtimeID:    5,
+		Seats:         [][2]int{{3, 5}, {3, 6}},
+		ExpiresAt:     now.Add(3 * time.Hour),
+	}
+
+	code, err := ring.Sign(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsSigned(code) || !strings.HasPrefix(code, "k1.") {
+		t.Fatalf("unexpected code %q", code)
+	}
+
+	got, err := ring.Verify(code, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(*got, payload) {
+		t.Errorf("Verify() = %+v, want %+v", *got, payload)
+	}
+
+	if _, err := ring.Verify(code, payload.ExpiresAt); !errors.Is(err, ErrExpired) {
+		t.Errorf("Verify() of expired code error = %v, want %v", err, ErrExpired)
+	}
+
+	// a payload taken from another code doesn't match the signature
+	other, err := ring.Sign(Payload{ReservationID: 13, ExpiresAt: payload.ExpiresAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts, otherParts := strings.Split(code, "."), strings.Split(other, ".")
+	tampered := parts[0] + "." + otherParts[1] + "." + parts[2]
+
+	if _, err := ring.Verify(tampered, now); !errors.Is(err, ErrInvalidTicket) {
+		t.Errorf("Verify() of tampered code error = %v, want %v", err, ErrInvalidTicket)
+	}
+
+	if _, err := ring.Verify("12-AAAAAAAAAAAAAAAA", now); !errors.Is(err, ErrInvalidTicket) {
+		t.Errorf("Verify() of unsigned code error = %v, want %v", err, ErrInvalidTicket)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldRing, err := ParseKeyring("k1:" + testSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+
+	code, err := oldRing.Sign(Payload{ReservationID: 12, ExpiresAt: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ring, err := ParseKeyring("k2:" + testSeed(2) + ", k1:" + testSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ring.Verify(code, now); err != nil {
+		t.Errorf("Verify() of code signed with the old key error = %v", err)
+	}
+
+	newCode, err := ring.Sign(Payload{ReservationID: 12, ExpiresAt: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(newCode, "k2.") {
+		t.Errorf("code %q isn't signed with the primary key", newCode)
+	}
+
+	if _, err := oldRing.Verify(newCode, now); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Verify() with a ring missing the key error = %v, want %v", err, ErrUnknownKey)
+	}
+
+	keys := ring.PublicKeys()
+	if len(keys) != 2 || keys[0].ID != "k2" || !keys[0].Primary || keys[1].ID != "k1" || keys[1].Primary {
+		t.Fatalf("unexpected public keys %+v", keys)
+	}
+
+	parts := strings.Split(newCode, ".")
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+
+	if !ed25519.Verify(keys[0].Key, []byte(parts[0]+"."+parts[1]), signature) {
+		t.Error("published public key doesn't verify the code")
+	}
+}
+
+func TestParseKeyring(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+		wantNil bool
+	}{
+		{name: "empty", spec: " ", wantNil: true},
+		{name: "valid", spec: "k1:" + testSeed(1)},
+		{name: "missing id", spec: testSeed(1), wantErr: true},
+		{name: "id with a dot", spec: "k.1:" + testSeed(1), wantErr: true},
+		{name: "short key", spec: "k1:" + base64.StdEncoding.EncodeToString([]byte("short")), wantErr: true},
+		{name: "invalid base64", spec: "k1:???", wantErr: true},
+		{name: "duplicate id", spec: "k1:" + testSeed(1) + ",k1:" + testSeed(2), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ring, err := ParseKeyring(tt.spec)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseKeyring() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && (ring == nil) != tt.wantNil {
+				t.Errorf("ParseKeyring() = %v, want nil %v", ring, tt.wantNil)
+			}
+		})
+	}
+}
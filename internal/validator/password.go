@@ -0,0 +1,170 @@
+package validator
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// PasswordSpecialCharacters are the characters counted as special characters of a password.
+const PasswordSpecialCharacters = "!@#$%^&*"
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+var commonPasswords = parseCommonPasswords(commonPasswordList)
+
+// PasswordPolicy is the set of rules passwords are validated against with the password tag.
+type PasswordPolicy struct {
+	MinLength      int
+	MaxLength      int
+	RequireUpper   bool
+	RequireLower   bool
+	RequireDigit   bool
+	RequireSpecial bool
+	// BanCommon rejects passwords of the embedded list of commonly used passwords
+	BanCommon bool
+}
+
+// DefaultPasswordPolicy returns the policy applied unless it's configured otherwise.
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:      8,
+		MaxLength:      25,
+		RequireUpper:   true,
+		RequireLower:   true,
+		RequireDigit:   true,
+		RequireSpecial: true,
+		BanCommon:      true,
+	}
+}
+
+// Validate checks the bounds of the policy itself.
+func (p PasswordPolicy) Validate() error {
+	if p.MinLength < 1 {
+		return fmt.Errorf("minimum password length must be greater than zero")
+	}
+
+	// bcrypt ignores everything after the first 72 bytes
+	if p.MaxLength < p.MinLength || p.MaxLength > 72 {
+		return fmt.Errorf("maximum password length must be between the minimum length and 72")
+	}
+
+	return nil
+}
+
+// Allows reports whether the password satisfies the policy. Lengths are counted in characters.
+func (p PasswordPolicy) Allows(password string) bool {
+	length := len([]rune(password))
+	if length < p.MinLength || length > p.MaxLength {
+		return false
+	}
+
+	containsUpper, containsLower, containsDigit, containsSpecial := false, false, false, false
+
+	for _, ch := range password {
+		switch {
+		case unicode.IsUpper(ch):
+			containsUpper = true
+		case unicode.IsLower(ch):
+			containsLower = true
+		case unicode.IsDigit(ch):
+			containsDigit = true
+		case strings.ContainsRune(PasswordSpecialCharacters, ch):
+			containsSpecial = true
+		}
+	}
+
+	if (p.RequireUpper && !containsUpper) ||
+		(p.RequireLower && !containsLower) ||
+		(p.RequireDigit && !containsDigit) ||
+		(p.RequireSpecial && !containsSpecial) {
+		return false
+	}
+
+	return !p.BanCommon || !isCommonPassword(password)
+}
+
+// Message describes the rules of the policy, it's the issue reported for a password violating them.
+func (p PasswordPolicy) Message() string {
+	var classes []string
+
+	if p.RequireUpper {
+		classes = append(classes, "one uppercase letter")
+	}
+
+	if p.RequireLower {
+		classes = append(classes, "one lowercase letter")
+	}
+
+	if p.RequireDigit {
+		classes = append(classes, "one number")
+	}
+
+	if p.RequireSpecial {
+		classes = append(classes, fmt.Sprintf("one special character (%s)", PasswordSpecialCharacters))
+	}
+
+	message := fmt.Sprintf("must be between %d and %d characters long", p.MinLength, p.MaxLength)
+
+	switch len(classes) {
+	case 0:
+	case 1:
+		message += " and include at least " + classes[0]
+	default:
+		message += " and include at least " + strings.Join(classes[:len(classes)-1], ", ") + ", and " + classes[len(classes)-1]
+	}
+
+	if p.BanCommon {
+		message += ", and must not be a commonly used password"
+	}
+
+	return message + "."
+}
+
+func (p PasswordPolicy) validate(fl validator.FieldLevel) bool {
+	return p.Allows(fl.Field().String())
+}
+
+// validateCurrentPassword checks a password given to prove the user's identity. It's not checked against
+// the policy, passwords chosen before the policy was tightened must keep working.
+func validateCurrentPassword(fl validator.FieldLevel) bool {
+	password := fl.Field().String()
+	return password != "" && len(password) <= 72
+}
+
+// isCommonPassword reports whether the password, or the password without its trailing digits and special
+// characters, is in the list of commonly used passwords.
+func isCommonPassword(password string) bool {
+	password = strings.ToLower(password)
+
+	if commonPasswords[password] {
+		return true
+	}
+
+	base := strings.TrimRightFunc(password, func(r rune) bool {
+		return unicode.IsDigit(r) || strings.ContainsRune(PasswordSpecialCharacters, r)
+	})
+
+	return base != "" && commonPasswords[base]
+}
+
+func parseCommonPasswords(list string) map[string]bool {
+	passwords := make(map[string]bool)
+
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		passwords[strings.ToLower(line)] = true
+	}
+
+	return passwords
+}
@@ -0,0 +1,73 @@
+package validator
+
+import "testing"
+
+func TestPasswordPolicyAllows(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		want     bool
+	}{
+		{name: "valid password", policy: DefaultPasswordPolicy(), password: "Pass123!@#", want: true},
+		{name: "too short", policy: DefaultPasswordPolicy(), password: "Pa1!", want: false},
+		{name: "too long", policy: DefaultPasswordPolicy(), password: "Pass123!@#Pass123!@#Pass123!@#", want: false},
+		{name: "missing uppercase", policy: DefaultPasswordPolicy(), password: "pass123!@#", want: false},
+		{name: "missing special character", policy: DefaultPasswordPolicy(), password: "Pass123456", want: false},
+		{name: "common password", policy: DefaultPasswordPolicy(), password: "P@ssw0rd", want: false},
+		{name: "common password with a suffix", policy: DefaultPasswordPolicy(), password: "Password123!", want: false},
+		{
+			name:     "common password allowed",
+			policy:   PasswordPolicy{MinLength: 8, MaxLength: 25, RequireUpper: true, RequireDigit: true},
+			password: "Password123",
+			want:     true,
+		},
+		{
+			name:     "lengths counted in characters",
+			policy:   PasswordPolicy{MinLength: 8, MaxLength: 8},
+			password: "şifreşif",
+			want:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Allows(tt.password); got != tt.want {
+				t.Errorf("Allows(%q) = %v, want %v", tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	if err := DefaultPasswordPolicy().Validate(); err != nil {
+		t.Errorf("Validate() of the default policy error = %v", err)
+	}
+
+	invalid := []PasswordPolicy{
+		{MinLength: 0, MaxLength: 25},
+		{MinLength: 12, MaxLength: 8},
+		{MinLength: 8, MaxLength: 100},
+	}
+
+	for _, policy := range invalid {
+		if err := policy.Validate(); err == nil {
+			t.Errorf("Validate() of %+v error = nil, want an error", policy)
+		}
+	}
+}
+
+func TestPasswordPolicyMessage(t *testing.T) {
+	want := "must be between 8 and 25 characters long and include at least one uppercase letter, one lowercase letter, " +
+		"one number, and one special character (!@#$%^&*), and must not be a commonly used password."
+
+	if got := DefaultPasswordPolicy().Message(); got != want {
+		t.Errorf("Message() = %q, want %q", got, want)
+	}
+
+	want = "must be between 12 and 64 characters long."
+
+	if got := (PasswordPolicy{MinLength: 12, MaxLength: 64}).Message(); got != want {
+		t.Errorf("Message() = %q, want %q", got, want)
+	}
+}
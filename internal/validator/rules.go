@@ -0,0 +1,97 @@
+package validator
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/metinatakli/movie-reservation-system/api"
+)
+
+// Struct level rules check fields against each other. They report the error on the field the client has
+// to change, under a tag of their own, so ValidationMessage describes them like the errors of field tags.
+// Fields failing their own tags are left to those, a rule doesn't report them twice.
+
+const (
+	// DefaultPageSize is the page size of lists when the client doesn't give one
+	DefaultPageSize = 10
+	// MaxResultWindow is how deep pages reach into a list. Skipping to a deeper page is costly for the
+	// database, such results are better reached by narrowing the query.
+	MaxResultWindow = 10000
+
+	monthLayout       = "2006-01"
+	maxHeatmapMonths  = 24
+	maxAttendanceDays = 92
+	maxAdjustmentDays = 92
+)
+
+func registerStructRules(v *validator.Validate) {
+	v.RegisterStructValidation(validatePageWindow,
+		api.GetDisputesParams{},
+		api.GetMoviesParams{},
+		api.GetMovieShowtimesParams{},
+		api.GetNotificationsOfUserParams{},
+		api.GetPaymentsOfUserParams{},
+		api.GetReservationsOfUserHandlerParams{},
+	)
+
+	v.RegisterStructValidation(validateRange(monthLayout, maxHeatmapMonths, "months", monthsIn),
+		api.GetHallSeatHeatmapParams{})
+	v.RegisterStructValidation(validateRange(time.DateOnly, maxAttendanceDays, "days", daysIn),
+		api.GetTheaterAttendanceParams{})
+	v.RegisterStructValidation(validateRange(time.DateOnly, maxAdjustmentDays, "days", daysIn),
+		api.ShowtimePriceAdjustmentRequest{})
+}
+
+// validatePageWindow keeps the requested page within the first MaxResultWindow results. The partner feed
+// isn't bound, partners page through every upcoming showtime when they sync.
+func validatePageWindow(sl validator.StructLevel) {
+	page := sl.Current().FieldByName("Page")
+	if page.IsNil() {
+		return
+	}
+
+	pageSize := DefaultPageSize
+	if size := sl.Current().FieldByName("PageSize"); !size.IsNil() {
+		pageSize = int(size.Elem().Int())
+	}
+
+	if int(page.Elem().Int())*pageSize > MaxResultWindow {
+		sl.ReportError(page.Interface(), "Page", "Page", "page_window", strconv.Itoa(MaxResultWindow))
+	}
+}
+
+// validateRange checks the range of the From and To fields, both formatted with layout and inclusive. The
+// range must not end before it starts, and must not span more than max units, as counted by count.
+func validateRange(layout string, max int, unit string, count func(from, to time.Time) int) validator.StructLevelFunc {
+	return func(sl validator.StructLevel) {
+		fromField := sl.Current().FieldByName("From")
+		toField := sl.Current().FieldByName("To")
+
+		from, err := time.Parse(layout, fromField.String())
+		if err != nil {
+			return
+		}
+
+		to, err := time.Parse(layout, toField.String())
+		if err != nil {
+			return
+		}
+
+		switch {
+		case to.Before(from):
+			sl.ReportError(toField.Interface(), "To", "To", "range_end", "From")
+		case count(from, to) > max:
+			sl.ReportError(toField.Interface(), "To", "To", "range_length", fmt.Sprintf("%d %s", max, unit))
+		}
+	}
+}
+
+func monthsIn(from, to time.Time) int {
+	return (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month()) + 1
+}
+
+func daysIn(from, to time.Time) int {
+	return int(to.Sub(from).Hours()/24) + 1
+}
@@ -0,0 +1,95 @@
+package validator
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/metinatakli/movie-reservation-system/api"
+)
+
+func TestStructRules(t *testing.T) {
+	v := NewValidator(DefaultSchedulingHorizon, DefaultPasswordPolicy())
+
+	tests := []struct {
+		name      string
+		params    any
+		wantField string
+		wantIssue string
+	}{
+		{
+			name:   "range within the limit",
+			params: api.GetTheaterAttendanceParams{From: "2025-06-01", To: "2025-08-31"},
+		},
+		{
+			name:      "range ending before it starts",
+			params:    api.GetTheaterAttendanceParams{From: "2025-06-30", To: "2025-06-01"},
+			wantField: "To",
+			wantIssue: "must not be before From",
+		},
+		{
+			name:      "range of too many days",
+			params:    api.GetTheaterAttendanceParams{From: "2025-06-01", To: "2025-09-01"},
+			wantField: "To",
+			wantIssue: "must not make the range longer than 92 days",
+		},
+		{
+			name:      "range of too many months",
+			params:    api.GetHallSeatHeatmapParams{From: "2024-01", To: "2026-01"},
+			wantField: "To",
+			wantIssue: "must not make the range longer than 24 months",
+		},
+		{
+			name:      "malformed day reported once",
+			params:    api.GetTheaterAttendanceParams{From: "2025-06", To: "2025-06-01"},
+			wantField: "From",
+			wantIssue: ErrDefaultInvalid,
+		},
+		{
+			name:   "last page within the window",
+			params: api.GetMoviesParams{Page: ptr(100), PageSize: ptr(100)},
+		},
+		{
+			name:      "page beyond the window",
+			params:    api.GetMoviesParams{Page: ptr(101), PageSize: ptr(100)},
+			wantField: "Page",
+			wantIssue: "must not reach beyond the first 10000 results, narrow the query instead",
+		},
+		{
+			name:      "page beyond the window with the default page size",
+			params:    api.GetReservationsOfUserHandlerParams{Page: ptr(1001)},
+			wantField: "Page",
+			wantIssue: "must not reach beyond the first 10000 results, narrow the query instead",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Struct(tt.params)
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("Struct() error = %v, want nil", err)
+				}
+
+				return
+			}
+
+			var errs validator.ValidationErrors
+			if !errors.As(err, &errs) || len(errs) != 1 {
+				t.Fatalf("Struct() error = %v, want a single validation error", err)
+			}
+
+			if errs[0].StructField() != tt.wantField {
+				t.Errorf("field = %s, want %s", errs[0].StructField(), tt.wantField)
+			}
+
+			if got := ValidationMessage(errs[0]); got != tt.wantIssue {
+				t.Errorf("issue = %q, want %q", got, tt.wantIssue)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
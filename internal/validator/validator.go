@@ -3,9 +3,8 @@ package validator
 import (
 	"fmt"
 	"reflect"
-	"regexp"
+	"strings"
 	"time"
-	"unicode"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/metinatakli/movie-reservation-system/api"
@@ -13,9 +12,8 @@ import (
 )
 
 var (
-	minAge        = 15
-	maxAge        = 120
-	hasSpecialRgx = regexp.MustCompile(`[!@#$%^&*]`)
+	minAge = 15
+	maxAge = 120
 )
 
 const (
@@ -30,17 +28,44 @@ const (
 	ErrOnlyLetters     = "must contain only letters"
 	ErrAgeCheck        = "must be at least 15 years old"
 	ErrDefaultInvalid  = "is invalid"
-	ErrInvalidPassword = "must be at least 8 characters long and include at least one uppercase letter, one lowercase letter, " +
-		"one number, and one special character (!@#$%^&*)."
-	ErrOneOf = "must be one of %s"
+	ErrInvalidPassword = "does not satisfy the password policy, " +
+		"see GET /auth/password-policy for its rules."
+	ErrOneOf            = "must be one of %s"
+	ErrRequiredWith     = "is required when %s is provided"
+	ErrRequiredIf       = "is required when %s is %s"
+	ErrExcludedUnless   = "must be empty unless %s is %s"
+	ErrExcludedWith     = "must be empty when %s is provided"
+	ErrRequiredWithout  = "is required when %s is not provided"
+	ErrGreaterThanField = "must be greater than %s"
+	ErrUnique           = "must not contain duplicates"
+	ErrLatitude         = "must be between -90 and 90"
+	ErrLongitude        = "must be between -180 and 180"
+	ErrPastDate         = "must not be in the past"
+	ErrBeyondHorizon    = "must not be beyond the scheduling horizon"
+	ErrPageWindow       = "must not reach beyond the first %s results, narrow the query instead"
+	ErrRangeEnd         = "must not be before %s"
+	ErrRangeLength      = "must not make the range longer than %s"
 )
 
-func NewValidator() *validator.Validate {
+// DefaultSchedulingHorizon is how far ahead showtimes are scheduled
+const DefaultSchedulingHorizon = 30 * 24 * time.Hour
+
+// NewValidator builds the validator of the API. Dates validated with the within_horizon tag can't be
+// further than horizon from today, nothing is scheduled beyond it. Fields with the password tag are
+// validated against the given password policy.
+func NewValidator(horizon time.Duration, passwordPolicy PasswordPolicy) *validator.Validate {
 	validator := validator.New(validator.WithRequiredStructEnabled())
 
 	validator.RegisterValidation("age_check", validateBirthDate)
-	validator.RegisterValidation("password", validatePassword)
+	validator.RegisterValidation("password", passwordPolicy.validate)
+	validator.RegisterValidation("current_password", validateCurrentPassword)
 	validator.RegisterValidation("gender", validateGender)
+	validator.RegisterValidation("lat", validateLatitude)
+	validator.RegisterValidation("lon", validateLongitude)
+	validator.RegisterValidation("not_past_date", validateNotPastDate)
+	validator.RegisterValidation("within_horizon", validateWithinHorizon(horizon))
+
+	registerStructRules(validator)
 
 	return validator
 }
@@ -66,32 +91,50 @@ func validateBirthDate(fl validator.FieldLevel) bool {
 	return age >= minAge && age <= maxAge
 }
 
-func validatePassword(fl validator.FieldLevel) bool {
-	password := fl.Field().String()
+func validateLatitude(fl validator.FieldLevel) bool {
+	lat := fl.Field().Float()
+	return lat >= -90 && lat <= 90
+}
 
-	if len(password) < 8 || len(password) > 25 {
-		return false
-	}
+func validateLongitude(fl validator.FieldLevel) bool {
+	lon := fl.Field().Float()
+	return lon >= -180 && lon <= 180
+}
 
-	containsUpper, containsLower, containsDigit, containsSpecial := false, false, false, false
-
-	for _, ch := range password {
-		switch {
-		case unicode.IsUpper(ch):
-			containsUpper = true
-		case unicode.IsLower(ch):
-			containsLower = true
-		case unicode.IsDigit(ch):
-			containsDigit = true
-		case hasSpecialRgx.MatchString(string(ch)):
-			containsSpecial = true
-		}
+// Dates are days in the time zones of the theaters. A date is past once it's over in every time zone and
+// beyond the horizon once it's beyond it in every time zone.
+var (
+	earliestTimeZone = time.FixedZone("UTC-12", -12*60*60)
+	latestTimeZone   = time.FixedZone("UTC+14", 14*60*60)
+)
+
+// parseDate parses a date only field, along with today in loc
+func parseDate(fl validator.FieldLevel, loc *time.Location) (date time.Time, today time.Time, ok bool) {
+	date, err := time.ParseInLocation(time.DateOnly, fl.Field().String(), time.UTC)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
 	}
 
-	return containsUpper && containsLower && containsDigit && containsSpecial
+	now := time.Now().In(loc)
+	today = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	return date, today, true
+}
+
+func validateNotPastDate(fl validator.FieldLevel) bool {
+	date, today, ok := parseDate(fl, earliestTimeZone)
+	return ok && !date.Before(today)
 }
 
-// ValidationMessage converts validator errors into readable messages
+func validateWithinHorizon(horizon time.Duration) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		date, today, ok := parseDate(fl, latestTimeZone)
+		return ok && !date.After(today.Add(horizon))
+	}
+}
+
+// ValidationMessage converts validator errors into readable messages. Password errors don't know the
+// policy they violate, they're better described by the policy's Message.
 func ValidationMessage(err validator.FieldError) string {
 	switch err.Tag() {
 	case "required":
@@ -122,8 +165,40 @@ func ValidationMessage(err validator.FieldError) string {
 		return ErrAgeCheck
 	case "password":
 		return ErrInvalidPassword
+	case "current_password":
+		return ErrDefaultInvalid
 	case "oneof":
 		return fmt.Sprintf(ErrOneOf, err.Param())
+	case "required_with":
+		return fmt.Sprintf(ErrRequiredWith, err.Param())
+	case "excluded_with":
+		return fmt.Sprintf(ErrExcludedWith, err.Param())
+	case "required_without":
+		return fmt.Sprintf(ErrRequiredWithout, err.Param())
+	case "gtfield":
+		return fmt.Sprintf(ErrGreaterThanField, err.Param())
+	case "unique":
+		return ErrUnique
+	case "required_if":
+		field, value, _ := strings.Cut(err.Param(), " ")
+		return fmt.Sprintf(ErrRequiredIf, field, value)
+	case "lat":
+		return ErrLatitude
+	case "lon":
+		return ErrLongitude
+	case "not_past_date":
+		return ErrPastDate
+	case "within_horizon":
+		return ErrBeyondHorizon
+	case "excluded_unless":
+		field, value, _ := strings.Cut(err.Param(), " ")
+		return fmt.Sprintf(ErrExcludedUnless, field, value)
+	case "page_window":
+		return fmt.Sprintf(ErrPageWindow, err.Param())
+	case "range_end":
+		return fmt.Sprintf(ErrRangeEnd, err.Param())
+	case "range_length":
+		return fmt.Sprintf(ErrRangeLength, err.Param())
 	default:
 		return ErrDefaultInvalid
 	}
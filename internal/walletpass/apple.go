@@ -0,0 +1,304 @@
+package walletpass
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+const (
+	applePassTimeFormat = time.RFC3339
+	passBackgroundColor = "rgb(17, 24, 39)"
+	passForegroundColor = "rgb(255, 255, 255)"
+	passLabelColor      = "rgb(245, 158, 11)"
+)
+
+// AppleSigner builds Apple Wallet event tickets signed with a Pass Type ID certificate.
+type AppleSigner struct {
+	passTypeID       string
+	teamID           string
+	organizationName string
+	cert             *x509.Certificate
+	intermediates    []*x509.Certificate
+	key              crypto.Signer
+	icons            map[string][]byte
+	now              func() time.Time
+}
+
+// NewAppleSigner parses the PEM encoded Pass Type ID certificate, its private key and the Apple WWDR
+// intermediate certificate.
+func NewAppleSigner(passTypeID, teamID, organizationName string, certPEM, keyPEM, wwdrPEM []byte) (*AppleSigner, error) {
+	if passTypeID == "" || teamID == "" {
+		return nil, errors.New("pass type identifier and team identifier are required")
+	}
+
+	certs, err := parseCertificates(certPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse pass certificate: %w", err)
+	}
+
+	intermediates, err := parseCertificates(wwdrPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse WWDR certificate: %w", err)
+	}
+
+	key, err := parsePrivateKey(keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse pass key: %w", err)
+	}
+
+	return &AppleSigner{
+		passTypeID:       passTypeID,
+		teamID:           teamID,
+		organizationName: organizationName,
+		cert:             certs[0],
+		intermediates:    append(certs[1:], intermediates...),
+		key:              key,
+		icons: map[string][]byte{
+			"icon.png":    solidPNG(29),
+			"icon@2x.png": solidPNG(58),
+		},
+		now: time.Now,
+	}, nil
+}
+
+type pass struct {
+	FormatVersion      int            `json:"formatVersion"`
+	PassTypeIdentifier string         `json:"passTypeIdentifier"`
+	SerialNumber       string         `json:"serialNumber"`
+	TeamIdentifier     string         `json:"teamIdentifier"`
+	OrganizationName   string         `json:"organizationName"`
+	Description        string         `json:"description"`
+	RelevantDate       string         `json:"relevantDate"`
+	ExpirationDate     string         `json:"expirationDate"`
+	BackgroundColor    string         `json:"backgroundColor"`
+	ForegroundColor    string         `json:"foregroundColor"`
+	LabelColor         string         `json:"labelColor"`
+	Locations          []passLocation `json:"locations,omitempty"`
+	Barcodes           []passBarcode  `json:"barcodes"`
+	EventTicket        passStructure  `json:"eventTicket"`
+}
+
+type passLocation struct {
+	Latitude     float64 `json:"latitude"`
+	Longitude    float64 `json:"longitude"`
+	RelevantText string  `json:"relevantText,omitempty"`
+}
+
+type passBarcode struct {
+	Format          string `json:"format"`
+	Message         string `json:"message"`
+	MessageEncoding string `json:"messageEncoding"`
+	AltText         string `json:"altText,omitempty"`
+}
+
+type passStructure struct {
+	PrimaryFields   []passField `json:"primaryFields"`
+	SecondaryFields []passField `json:"secondaryFields"`
+	AuxiliaryFields []passField `json:"auxiliaryFields"`
+	BackFields      []passField `json:"backFields"`
+}
+
+type passField struct {
+	Key       string `json:"key"`
+	Label     string `json:"label,omitempty"`
+	Value     string `json:"value"`
+	DateStyle string `json:"dateStyle,omitempty"`
+	TimeStyle string `json:"timeStyle,omitempty"`
+}
+
+// Build returns the zipped .pkpass bundle for the ticket.
+func (s *AppleSigner) Build(ticket domain.WalletTicket) ([]byte, error) {
+	passJSON, err := json.Marshal(s.pass(ticket))
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{"pass.json": passJSON}
+	for name, icon := range s.icons {
+		files[name] = icon
+	}
+
+	manifest := make(map[string]string, len(files))
+	for name, content := range files {
+		sum := sha1.Sum(content)
+		manifest[name] = hex.EncodeToString(sum[:])
+	}
+
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := signDetached(manifestJSON, s.cert, s.intermediates, s.key, s.now())
+	if err != nil {
+		return nil, err
+	}
+
+	files["manifest.json"] = manifestJSON
+	files["signature"] = signature
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	for _, name := range []string{"pass.json", "icon.png", "icon@2x.png", "manifest.json", "signature"} {
+		f, err := zw.Create(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := f.Write(files[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (s *AppleSigner) pass(ticket domain.WalletTicket) pass {
+	p := pass{
+		FormatVersion:      1,
+		PassTypeIdentifier: s.passTypeID,
+		SerialNumber:       ticket.SerialNumber,
+		TeamIdentifier:     s.teamID,
+		OrganizationName:   s.organizationName,
+		Description:        "Movie ticket for " + ticket.MovieTitle,
+		RelevantDate:       ticket.StartsAt.UTC().Format(applePassTimeFormat),
+		ExpirationDate:     ticket.EndsAt.UTC().Format(applePassTimeFormat),
+		BackgroundColor:    passBackgroundColor,
+		ForegroundColor:    passForegroundColor,
+		LabelColor:         passLabelColor,
+		Barcodes: []passBarcode{{
+			Format:          "PKBarcodeFormatQR",
+			Message:         ticket.TicketCode,
+			MessageEncoding: "iso-8859-1",
+			AltText:         ticket.TicketCode,
+		}},
+		EventTicket: passStructure{
+			PrimaryFields: []passField{
+				{Key: "movie", Label: "MOVIE", Value: ticket.MovieTitle},
+			},
+			SecondaryFields: []passField{
+				{
+					Key:       "showtime",
+					Label:     "SHOWTIME",
+					Value:     ticket.StartsAt.UTC().Format(applePassTimeFormat),
+					DateStyle: "PKDateStyleMedium",
+					TimeStyle: "PKDateStyleShort",
+				},
+				{Key: "hall", Label: "HALL", Value: ticket.HallName},
+			},
+			AuxiliaryFields: []passField{
+				{Key: "seats", Label: "SEATS", Value: strings.Join(ticket.Seats, ", ")},
+			},
+			BackFields: []passField{
+				{Key: "theater", Label: "THEATER", Value: ticket.TheaterName},
+				{Key: "address", Label: "ADDRESS", Value: ticket.Address},
+				{Key: "holder", Label: "TICKET HOLDER", Value: ticket.HolderName},
+				{Key: "code", Label: "TICKET CODE", Value: ticket.TicketCode},
+			},
+		},
+	}
+
+	if ticket.Location != (domain.GeoPoint{}) {
+		p.Locations = []passLocation{{
+			Latitude:     ticket.Location.Latitude,
+			Longitude:    ticket.Location.Longitude,
+			RelevantText: ticket.MovieTitle + " at " + ticket.TheaterName,
+		}}
+	}
+
+	return p
+}
+
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate found")
+	}
+
+	return certs, nil
+}
+
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+
+	return signer, nil
+}
+
+// solidPNG renders a square icon in the pass background color. Wallet refuses passes without an icon.
+func solidPNG(size int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	fill := color.RGBA{R: 17, G: 24, B: 39, A: 255}
+
+	for x := range size {
+		for y := range size {
+			img.Set(x, y, fill)
+		}
+	}
+
+	var buf bytes.Buffer
+	// encoding an in-memory RGBA image cannot fail
+	_ = png.Encode(&buf, img)
+
+	return buf.Bytes()
+}
@@ -0,0 +1,202 @@
+package walletpass
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+const googleSaveURL = "https://pay.google.com/gp/v/save/"
+
+// GoogleSigner creates "Save to Google Wallet" links for event tickets. The ticket object is embedded
+// in a JWT signed with a service account key, so no API call is needed to issue a pass.
+type GoogleSigner struct {
+	issuerID     string
+	classID      string
+	serviceEmail string
+	key          *rsa.PrivateKey
+	origins      []string
+	now          func() time.Time
+}
+
+type serviceAccountKey struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+}
+
+// NewGoogleSigner parses a service account key file as downloaded from the Google Cloud console.
+// classSuffix names an event ticket class created beforehand in the Google Pay & Wallet console.
+func NewGoogleSigner(issuerID, classSuffix string, serviceAccountJSON []byte, origins []string) (*GoogleSigner, error) {
+	if issuerID == "" || classSuffix == "" {
+		return nil, errors.New("issuer id and class suffix are required")
+	}
+
+	var account serviceAccountKey
+	if err := json.Unmarshal(serviceAccountJSON, &account); err != nil {
+		return nil, fmt.Errorf("failed to parse service account key: %w", err)
+	}
+
+	if account.ClientEmail == "" {
+		return nil, errors.New("service account key has no client_email")
+	}
+
+	signer, err := parsePrivateKey([]byte(account.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse service account private key: %w", err)
+	}
+
+	key, ok := signer.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("service account key must be RSA, got %T", signer)
+	}
+
+	return &GoogleSigner{
+		issuerID:     issuerID,
+		classID:      issuerID + "." + classSuffix,
+		serviceEmail: account.ClientEmail,
+		key:          key,
+		origins:      origins,
+		now:          time.Now,
+	}, nil
+}
+
+type eventTicketObject struct {
+	ID                string          `json:"id"`
+	ClassID           string          `json:"classId"`
+	State             string          `json:"state"`
+	TicketHolderName  string          `json:"ticketHolderName,omitempty"`
+	ReservationInfo   reservationInfo `json:"reservationInfo"`
+	Barcode           barcode         `json:"barcode"`
+	ValidTimeInterval timeInterval    `json:"validTimeInterval"`
+	Locations         []latLongPoint  `json:"locations,omitempty"`
+	TextModulesData   []textModule    `json:"textModulesData"`
+}
+
+type reservationInfo struct {
+	ConfirmationCode string `json:"confirmationCode"`
+}
+
+type barcode struct {
+	Type          string `json:"type"`
+	Value         string `json:"value"`
+	AlternateText string `json:"alternateText,omitempty"`
+}
+
+type timeInterval struct {
+	Start dateTime `json:"start"`
+	End   dateTime `json:"end"`
+}
+
+type dateTime struct {
+	Date string `json:"date"`
+}
+
+type latLongPoint struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type textModule struct {
+	ID     string `json:"id"`
+	Header string `json:"header"`
+	Body   string `json:"body"`
+}
+
+type saveClaims struct {
+	Issuer   string      `json:"iss"`
+	Audience string      `json:"aud"`
+	Type     string      `json:"typ"`
+	IssuedAt int64       `json:"iat"`
+	Origins  []string    `json:"origins"`
+	Payload  savePayload `json:"payload"`
+}
+
+type savePayload struct {
+	EventTicketObjects []eventTicketObject `json:"eventTicketObjects"`
+}
+
+// SaveURL returns the link that adds the ticket to the user's Google Wallet.
+func (s *GoogleSigner) SaveURL(ticket domain.WalletTicket) (string, error) {
+	token, err := s.sign(saveClaims{
+		Issuer:   s.serviceEmail,
+		Audience: "google",
+		Type:     "savetowallet",
+		IssuedAt: s.now().Unix(),
+		Origins:  s.origins,
+		Payload: savePayload{
+			EventTicketObjects: []eventTicketObject{s.object(ticket)},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return googleSaveURL + token, nil
+}
+
+func (s *GoogleSigner) object(ticket domain.WalletTicket) eventTicketObject {
+	obj := eventTicketObject{
+		// object ids may only contain word characters, dots and dashes after the issuer prefix
+		ID:               s.issuerID + "." + ticket.SerialNumber,
+		ClassID:          s.classID,
+		State:            "ACTIVE",
+		TicketHolderName: ticket.HolderName,
+		ReservationInfo:  reservationInfo{ConfirmationCode: ticket.TicketCode},
+		Barcode: barcode{
+			Type:          "QR_CODE",
+			Value:         ticket.TicketCode,
+			AlternateText: ticket.TicketCode,
+		},
+		ValidTimeInterval: timeInterval{
+			Start: dateTime{Date: ticket.StartsAt.UTC().Format(time.RFC3339)},
+			End:   dateTime{Date: ticket.EndsAt.UTC().Format(time.RFC3339)},
+		},
+		TextModulesData: []textModule{
+			{ID: "movie", Header: "Movie", Body: ticket.MovieTitle},
+			{ID: "theater", Header: "Theater", Body: ticket.TheaterName + ", " + ticket.Address},
+			{ID: "hall", Header: "Hall", Body: ticket.HallName},
+			{ID: "seats", Header: "Seats", Body: strings.Join(ticket.Seats, ", ")},
+		},
+	}
+
+	if ticket.Location != (domain.GeoPoint{}) {
+		obj.Locations = []latLongPoint{{
+			Latitude:  ticket.Location.Latitude,
+			Longitude: ticket.Location.Longitude,
+		}}
+	}
+
+	return obj
+}
+
+// sign encodes the claims as an RS256 JWT.
+func (s *GoogleSigner) sign(claims saveClaims) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign wallet JWT: %w", err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,36 @@
+// Package walletpass issues reservation tickets as Apple Wallet and Google Wallet passes.
+package walletpass
+
+import (
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+// Issuer implements domain.WalletPassIssuer on top of the configured signers. Either signer may be nil,
+// in which case that wallet is reported as unavailable.
+type Issuer struct {
+	apple  *AppleSigner
+	google *GoogleSigner
+}
+
+func NewIssuer(apple *AppleSigner, google *GoogleSigner) *Issuer {
+	return &Issuer{
+		apple:  apple,
+		google: google,
+	}
+}
+
+func (i *Issuer) ApplePass(ticket domain.WalletTicket) ([]byte, error) {
+	if i.apple == nil {
+		return nil, domain.ErrWalletPassUnavailable
+	}
+
+	return i.apple.Build(ticket)
+}
+
+func (i *Issuer) GoogleSaveURL(ticket domain.WalletTicket) (string, error) {
+	if i.google == nil {
+		return "", domain.ErrWalletPassUnavailable
+	}
+
+	return i.google.SaveURL(ticket)
+}
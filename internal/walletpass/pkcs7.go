@@ -0,0 +1,190 @@
+package walletpass
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+)
+
+var (
+	oidData            = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidContentType     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningTime     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	oidSHA256          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidRSAEncryption   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"optional"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      contentInfo
+	Certificates     asn1.RawValue `asn1:"optional"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type signerInfo struct {
+	Version                   int
+	IssuerAndSerialNumber     issuerAndSerial
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	AuthenticatedAttributes   asn1.RawValue `asn1:"optional"`
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+}
+
+type issuerAndSerial struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+// signDetached creates a DER encoded PKCS #7 SignedData structure over content without embedding the
+// content itself, as Apple requires for the signature file of a pass. The signer certificate and the
+// intermediates are included so the chain can be verified.
+func signDetached(
+	content []byte,
+	cert *x509.Certificate,
+	intermediates []*x509.Certificate,
+	key crypto.Signer,
+	signingTime time.Time) ([]byte, error) {
+
+	sha256Alg := pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue}
+
+	var signatureAlg pkix.AlgorithmIdentifier
+	switch key.Public().(type) {
+	case *rsa.PublicKey:
+		signatureAlg = pkix.AlgorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1.NullRawValue}
+	case *ecdsa.PublicKey:
+		signatureAlg = pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256}
+	default:
+		return nil, fmt.Errorf("unsupported signing key type %T", key.Public())
+	}
+
+	digest := sha256.Sum256(content)
+
+	attrs, err := encodeAttributes(
+		attributeValue{oidContentType, oidData},
+		attributeValue{oidSigningTime, signingTime.UTC()},
+		attributeValue{oidMessageDigest, digest[:]},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// the signature covers the attributes encoded as a SET, while the signer info stores them with an
+	// implicit [0] tag
+	signedAttrs, err := asn1.Marshal(asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: attrs})
+	if err != nil {
+		return nil, err
+	}
+
+	attrsDigest := sha256.Sum256(signedAttrs)
+	signature, err := key.Sign(rand.Reader, attrsDigest[:], crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign attributes: %w", err)
+	}
+
+	var certs bytes.Buffer
+	certs.Write(cert.Raw)
+	for _, c := range intermediates {
+		certs.Write(c.Raw)
+	}
+
+	sd := signedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{sha256Alg},
+		ContentInfo:      contentInfo{ContentType: oidData},
+		Certificates: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      certs.Bytes(),
+		},
+		SignerInfos: []signerInfo{{
+			Version: 1,
+			IssuerAndSerialNumber: issuerAndSerial{
+				Issuer:       asn1.RawValue{FullBytes: cert.RawIssuer},
+				SerialNumber: cert.SerialNumber,
+			},
+			DigestAlgorithm: sha256Alg,
+			AuthenticatedAttributes: asn1.RawValue{
+				Class:      asn1.ClassContextSpecific,
+				Tag:        0,
+				IsCompound: true,
+				Bytes:      attrs,
+			},
+			DigestEncryptionAlgorithm: signatureAlg,
+			EncryptedDigest:           signature,
+		}},
+	}
+
+	sdBytes, err := asn1.Marshal(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      sdBytes,
+		},
+	})
+}
+
+type attributeValue struct {
+	oid   asn1.ObjectIdentifier
+	value any
+}
+
+// encodeAttributes returns the concatenated DER encoding of the attributes, sorted as DER requires for
+// the members of a SET OF.
+func encodeAttributes(values ...attributeValue) ([]byte, error) {
+	encoded := make([][]byte, 0, len(values))
+
+	for _, v := range values {
+		value, err := asn1.Marshal(v.value)
+		if err != nil {
+			return nil, err
+		}
+
+		attr, err := asn1.Marshal(attribute{
+			Type:   v.oid,
+			Values: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: value},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		encoded = append(encoded, attr)
+	}
+
+	sort.Slice(encoded, func(i, j int) bool {
+		return bytes.Compare(encoded[i], encoded[j]) < 0
+	})
+
+	return bytes.Join(encoded, nil), nil
+}
@@ -0,0 +1,278 @@
+package walletpass
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metinatakli/movie-reservation-system/internal/domain"
+)
+
+var testTicket = domain.WalletTicket{
+	SerialNumber: "reservation-42",
+	TicketCode:   "ABCD2345EFGH6789",
+	HolderName:   "Freddie Mercury",
+	MovieTitle:   "The Matrix",
+	TheaterName:  "Cinema City",
+	HallName:     "Hall 1",
+	Address:      "Bagdat Cd. 10, Kadikoy, Istanbul",
+	Location:     domain.GeoPoint{Latitude: 40.9631, Longitude: 29.0634},
+	StartsAt:     time.Date(2024, 3, 15, 19, 0, 0, 0, time.UTC),
+	EndsAt:       time.Date(2024, 3, 15, 21, 16, 0, 0, time.UTC),
+	Seats:        []string{"Row 1 Seat 1 (standard)", "Row 1 Seat 2 (vip)"},
+}
+
+func newTestCertificate(t *testing.T, cn string, parent *x509.Certificate, parentKey *rsa.PrivateKey) (*x509.Certificate, *rsa.PrivateKey) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+
+	return cert, key
+}
+
+func certPEM(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
+
+func keyPEM(t *testing.T, key *rsa.PrivateKey) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestAppleSignerBuild(t *testing.T) {
+	wwdr, wwdrKey := newTestCertificate(t, "Test WWDR", nil, nil)
+	cert, key := newTestCertificate(t, "Pass Type ID: pass.net.cinex.ticket", wwdr, wwdrKey)
+
+	signer, err := NewAppleSigner("pass.net.cinex.ticket", "TEAM123", "CineX", certPEM(cert), keyPEM(t, key), certPEM(wwdr))
+	if err != nil {
+		t.Fatalf("NewAppleSigner() error = %v", err)
+	}
+
+	bundle, err := signer.Build(testTicket)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(bundle), int64(len(bundle)))
+	if err != nil {
+		t.Fatalf("bundle is not a zip archive: %v", err)
+	}
+
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", f.Name, err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", f.Name, err)
+		}
+		files[f.Name] = content
+	}
+
+	for _, name := range []string{"pass.json", "icon.png", "icon@2x.png", "manifest.json", "signature"} {
+		if _, ok := files[name]; !ok {
+			t.Fatalf("bundle is missing %s", name)
+		}
+	}
+
+	var manifest map[string]string
+	if err := json.Unmarshal(files["manifest.json"], &manifest); err != nil {
+		t.Fatalf("invalid manifest: %v", err)
+	}
+
+	for name, hash := range manifest {
+		sum := sha1.Sum(files[name])
+		if hex.EncodeToString(sum[:]) != hash {
+			t.Errorf("manifest hash of %s does not match its content", name)
+		}
+	}
+
+	var p pass
+	if err := json.Unmarshal(files["pass.json"], &p); err != nil {
+		t.Fatalf("invalid pass.json: %v", err)
+	}
+
+	if p.SerialNumber != "reservation-42" || p.TeamIdentifier != "TEAM123" {
+		t.Errorf("unexpected pass identifiers: %+v", p)
+	}
+	if len(p.Barcodes) != 1 || p.Barcodes[0].Message != testTicket.TicketCode {
+		t.Errorf("barcodes = %+v, want the ticket code", p.Barcodes)
+	}
+	if p.EventTicket.AuxiliaryFields[0].Value != "Row 1 Seat 1 (standard), Row 1 Seat 2 (vip)" {
+		t.Errorf("seats field = %q", p.EventTicket.AuxiliaryFields[0].Value)
+	}
+
+	verifySignature(t, files["signature"], files["manifest.json"], cert)
+}
+
+// verifySignature decodes the detached PKCS #7 signature and checks it against the manifest.
+func verifySignature(t *testing.T, signature, content []byte, cert *x509.Certificate) {
+	t.Helper()
+
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(signature, &ci); err != nil {
+		t.Fatalf("failed to decode content info: %v", err)
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		t.Fatalf("content type = %v, want signedData", ci.ContentType)
+	}
+
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		t.Fatalf("failed to decode signed data: %v", err)
+	}
+
+	if len(sd.SignerInfos) != 1 {
+		t.Fatalf("got %d signer infos, want 1", len(sd.SignerInfos))
+	}
+
+	si := sd.SignerInfos[0]
+	if si.IssuerAndSerialNumber.SerialNumber.Cmp(cert.SerialNumber) != 0 {
+		t.Errorf("signer serial number does not match the pass certificate")
+	}
+
+	attrs := si.AuthenticatedAttributes.Bytes
+	digest := sha256.Sum256(content)
+	if !bytes.Contains(attrs, digest[:]) {
+		t.Errorf("authenticated attributes do not contain the manifest digest")
+	}
+
+	signedAttrs, err := asn1.Marshal(asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: attrs})
+	if err != nil {
+		t.Fatalf("failed to encode attributes: %v", err)
+	}
+
+	hashed := sha256.Sum256(signedAttrs)
+	pub := cert.PublicKey.(*rsa.PublicKey)
+	if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, hashed[:], si.EncryptedDigest); err != nil {
+		t.Errorf("signature verification failed: %v", err)
+	}
+}
+
+func TestGoogleSignerSaveURL(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	account, _ := json.Marshal(serviceAccountKey{
+		ClientEmail: "wallet@cinex.iam.gserviceaccount.com",
+		PrivateKey:  string(keyPEM(t, key)),
+	})
+
+	signer, err := NewGoogleSigner("3388000000012345678", "movie_ticket", account, []string{"https://cinex.metinatakli.net"})
+	if err != nil {
+		t.Fatalf("NewGoogleSigner() error = %v", err)
+	}
+
+	link, err := signer.SaveURL(testTicket)
+	if err != nil {
+		t.Fatalf("SaveURL() error = %v", err)
+	}
+
+	token, ok := strings.CutPrefix(link, googleSaveURL)
+	if !ok {
+		t.Fatalf("link %q does not start with %q", link, googleSaveURL)
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("token has %d parts, want 3", len(parts))
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("failed to decode signature: %v", err)
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Errorf("signature verification failed: %v", err)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatalf("failed to decode payload: %v", err)
+	}
+
+	var claims saveClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatalf("failed to parse claims: %v", err)
+	}
+
+	if claims.Audience != "google" || claims.Type != "savetowallet" {
+		t.Errorf("unexpected claims: aud=%q typ=%q", claims.Audience, claims.Type)
+	}
+
+	obj := claims.Payload.EventTicketObjects[0]
+	if obj.ID != "3388000000012345678.reservation-42" || obj.ClassID != "3388000000012345678.movie_ticket" {
+		t.Errorf("unexpected object ids: id=%q classId=%q", obj.ID, obj.ClassID)
+	}
+	if obj.Barcode.Value != testTicket.TicketCode {
+		t.Errorf("barcode value = %q, want %q", obj.Barcode.Value, testTicket.TicketCode)
+	}
+}
+
+func TestIssuerReportsUnconfiguredWallets(t *testing.T) {
+	issuer := NewIssuer(nil, nil)
+
+	if _, err := issuer.ApplePass(testTicket); !errors.Is(err, domain.ErrWalletPassUnavailable) {
+		t.Errorf("ApplePass() error = %v, want %v", err, domain.ErrWalletPassUnavailable)
+	}
+
+	if _, err := issuer.GoogleSaveURL(testTicket); !errors.Is(err, domain.ErrWalletPassUnavailable) {
+		t.Errorf("GoogleSaveURL() error = %v, want %v", err, domain.ErrWalletPassUnavailable)
+	}
+}
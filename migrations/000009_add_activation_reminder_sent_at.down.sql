@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS users_unactivated_created_at_idx;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS activation_reminder_sent_at;
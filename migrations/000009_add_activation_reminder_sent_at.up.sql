@@ -0,0 +1,4 @@
+ALTER TABLE users
+ADD COLUMN activation_reminder_sent_at timestamp(0) with time zone;
+
+CREATE INDEX users_unactivated_created_at_idx ON users (created_at) WHERE activated = FALSE;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS user_preferences (
+    user_id bigint PRIMARY KEY REFERENCES users ON DELETE CASCADE,
+    latitude double precision,
+    longitude double precision,
+    favorite_theater_id bigint REFERENCES theaters ON DELETE SET NULL,
+    language text,
+    seat_type seat_type,
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    CHECK ((latitude IS NULL) = (longitude IS NULL))
+);
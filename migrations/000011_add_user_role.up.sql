@@ -0,0 +1,4 @@
+CREATE TYPE user_role AS ENUM ('user', 'admin');
+
+ALTER TABLE users
+ADD COLUMN role user_role NOT NULL DEFAULT 'user';
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS reservations_pending_venue_idx;
+
+DELETE FROM reservations WHERE payment_id IS NULL;
+
+ALTER TABLE reservations
+ALTER COLUMN payment_id SET NOT NULL;
+
+ALTER TABLE reservations
+DROP COLUMN IF EXISTS status;
+
+DROP TYPE IF EXISTS reservation_status;
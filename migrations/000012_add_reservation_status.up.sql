@@ -0,0 +1,11 @@
+CREATE TYPE reservation_status AS ENUM ('confirmed', 'pending-payment-at-venue', 'cancelled');
+
+ALTER TABLE reservations
+ADD COLUMN status reservation_status NOT NULL DEFAULT 'confirmed';
+
+-- reservations paid at the venue don't have an online payment
+ALTER TABLE reservations
+ALTER COLUMN payment_id DROP NOT NULL;
+
+CREATE INDEX IF NOT EXISTS reservations_pending_venue_idx ON reservations (showtime_id)
+WHERE status = 'pending-payment-at-venue';
@@ -0,0 +1,7 @@
+-- Rows that only have an encrypted birth date must be decrypted before rolling back.
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_birth_date_present_check;
+
+ALTER TABLE users ALTER COLUMN birth_date SET NOT NULL;
+
+ALTER TABLE users DROP COLUMN IF EXISTS birth_date_encrypted;
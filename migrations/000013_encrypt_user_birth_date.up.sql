@@ -0,0 +1,6 @@
+ALTER TABLE users ADD COLUMN birth_date_encrypted bytea;
+
+ALTER TABLE users ALTER COLUMN birth_date DROP NOT NULL;
+
+ALTER TABLE users ADD CONSTRAINT users_birth_date_present_check
+    CHECK (birth_date IS NOT NULL OR birth_date_encrypted IS NOT NULL);
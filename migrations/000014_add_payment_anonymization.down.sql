@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS payments_not_anonymized_created_at_idx;
+
+ALTER TABLE payments DROP COLUMN IF EXISTS anonymized_at;
+
+-- Fails while anonymized payments exist, their owners cannot be restored.
+ALTER TABLE payments ALTER COLUMN user_id SET NOT NULL;
@@ -0,0 +1,5 @@
+ALTER TABLE payments ALTER COLUMN user_id DROP NOT NULL;
+
+ALTER TABLE payments ADD COLUMN anonymized_at timestamp(0) with time zone;
+
+CREATE INDEX payments_not_anonymized_created_at_idx ON payments (created_at) WHERE anonymized_at IS NULL;
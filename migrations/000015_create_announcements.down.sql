@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS notifications;
+
+DROP TABLE IF EXISTS announcements;
+
+DROP TYPE IF EXISTS announcement_status;
+
+DROP TYPE IF EXISTS announcement_audience;
@@ -0,0 +1,35 @@
+CREATE TYPE announcement_audience AS ENUM ('all', 'theater-upcoming');
+
+CREATE TYPE announcement_status AS ENUM ('scheduled', 'sent', 'cancelled');
+
+CREATE TABLE IF NOT EXISTS announcements (
+    id bigserial PRIMARY KEY,
+    title text NOT NULL,
+    body text NOT NULL,
+    audience announcement_audience NOT NULL,
+    theater_id bigint REFERENCES theaters ON DELETE CASCADE,
+    send_email boolean NOT NULL DEFAULT FALSE,
+    status announcement_status NOT NULL DEFAULT 'scheduled',
+    scheduled_at timestamp(0) with time zone NOT NULL,
+    created_by bigint REFERENCES users ON DELETE SET NULL,
+    recipient_count integer,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    sent_at timestamp(0) with time zone,
+    cancelled_at timestamp(0) with time zone,
+    CHECK ((audience = 'theater-upcoming') = (theater_id IS NOT NULL))
+);
+
+CREATE INDEX announcements_due_idx ON announcements (scheduled_at) WHERE status = 'scheduled';
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    announcement_id bigint REFERENCES announcements ON DELETE CASCADE,
+    title text NOT NULL,
+    body text NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    read_at timestamp(0) with time zone,
+    CONSTRAINT unique_user_announcement UNIQUE (user_id, announcement_id)
+);
+
+CREATE INDEX notifications_user_id_created_at_idx ON notifications (user_id, created_at DESC);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS theaters_name_trgm_idx;
+
+DROP INDEX IF EXISTS movies_title_trgm_idx;
+
+DROP EXTENSION IF EXISTS pg_trgm;
@@ -0,0 +1,5 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS movies_title_trgm_idx ON movies USING GIN (lower(title) gin_trgm_ops);
+
+CREATE INDEX IF NOT EXISTS theaters_name_trgm_idx ON theaters USING GIN (lower(name) gin_trgm_ops);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS refunds;
+
+DROP TYPE IF EXISTS refund_status;
+
+ALTER TABLE payments DROP COLUMN IF EXISTS stripe_payment_intent_id;
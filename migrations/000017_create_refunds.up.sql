@@ -0,0 +1,17 @@
+ALTER TABLE payments ADD COLUMN stripe_payment_intent_id text UNIQUE;
+
+CREATE TYPE refund_status AS ENUM ('pending', 'requires_action', 'succeeded', 'failed', 'canceled');
+
+CREATE TABLE IF NOT EXISTS refunds (
+    id bigserial PRIMARY KEY,
+    payment_id bigint NOT NULL REFERENCES payments ON DELETE CASCADE,
+    stripe_refund_id text UNIQUE NOT NULL,
+    amount DECIMAL(8, 2) NOT NULL,
+    currency CHAR(3) NOT NULL,
+    status refund_status NOT NULL,
+    failure_reason text,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS refunds_payment_id_idx ON refunds (payment_id);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS disputes;
+
+ALTER TABLE reservations
+    DROP COLUMN IF EXISTS disputed_at,
+    DROP COLUMN IF EXISTS tickets_revoked_at;
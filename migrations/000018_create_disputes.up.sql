@@ -0,0 +1,19 @@
+ALTER TABLE reservations
+    ADD COLUMN disputed_at timestamp(0) with time zone,
+    ADD COLUMN tickets_revoked_at timestamp(0) with time zone;
+
+CREATE TABLE IF NOT EXISTS disputes (
+    id bigserial PRIMARY KEY,
+    payment_id bigint NOT NULL REFERENCES payments ON DELETE CASCADE,
+    reservation_id bigint REFERENCES reservations ON DELETE SET NULL,
+    stripe_dispute_id text UNIQUE NOT NULL,
+    amount DECIMAL(8, 2) NOT NULL,
+    currency CHAR(3) NOT NULL,
+    reason text NOT NULL,
+    status text NOT NULL,
+    evidence_due_by timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS disputes_status_created_at_idx ON disputes (status, created_at DESC);
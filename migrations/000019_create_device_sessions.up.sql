@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS device_sessions (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    device_id text NOT NULL,
+    device_name text NOT NULL DEFAULT '',
+    refresh_token_hash bytea UNIQUE NOT NULL,
+    -- hash of the refresh token replaced by the last rotation, presenting it again means it was stolen
+    previous_refresh_token_hash bytea,
+    access_token_hash bytea,
+    expires_at timestamp(0) with time zone NOT NULL,
+    last_used_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    CONSTRAINT device_sessions_user_device_key UNIQUE (user_id, device_id)
+);
+
+CREATE INDEX IF NOT EXISTS device_sessions_previous_refresh_token_hash_idx
+    ON device_sessions (previous_refresh_token_hash);
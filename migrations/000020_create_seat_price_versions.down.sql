@@ -0,0 +1,5 @@
+ALTER TABLE reservation_seats
+    DROP COLUMN IF EXISTS price_version_id,
+    DROP COLUMN IF EXISTS extra_price;
+
+DROP TABLE IF EXISTS seat_price_versions;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS seat_price_versions (
+    id bigserial PRIMARY KEY,
+    hall_id bigint NOT NULL REFERENCES halls ON DELETE CASCADE,
+    seat_type seat_type NOT NULL,
+    extra_price numeric(6,2) NOT NULL CHECK (extra_price >= 0),
+    effective_from timestamp(0) with time zone NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    UNIQUE (hall_id, seat_type, effective_from)
+);
+
+-- the price a seat was sold for, so reports don't change when prices do; NULL for older reservations
+ALTER TABLE reservation_seats
+    ADD COLUMN extra_price numeric(6,2),
+    ADD COLUMN price_version_id bigint REFERENCES seat_price_versions ON DELETE SET NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE reservations
+    DROP COLUMN IF EXISTS special_requests,
+    DROP COLUMN IF EXISTS note;
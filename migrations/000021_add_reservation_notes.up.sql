@@ -0,0 +1,3 @@
+ALTER TABLE reservations
+    ADD COLUMN note text,
+    ADD COLUMN special_requests text[] NOT NULL DEFAULT '{}';
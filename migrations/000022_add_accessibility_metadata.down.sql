@@ -0,0 +1,7 @@
+ALTER TABLE showtimes
+    DROP COLUMN IF EXISTS open_captions;
+
+ALTER TABLE movies
+    DROP COLUMN IF EXISTS audio_description,
+    DROP COLUMN IF EXISTS subtitle_languages,
+    DROP COLUMN IF EXISTS content_warnings;
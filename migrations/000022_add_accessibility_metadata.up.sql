@@ -0,0 +1,7 @@
+ALTER TABLE movies
+    ADD COLUMN content_warnings text[] NOT NULL DEFAULT '{}',
+    ADD COLUMN subtitle_languages text[] NOT NULL DEFAULT '{}',
+    ADD COLUMN audio_description boolean NOT NULL DEFAULT false;
+
+ALTER TABLE showtimes
+    ADD COLUMN open_captions boolean NOT NULL DEFAULT false;
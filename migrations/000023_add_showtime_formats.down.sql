@@ -0,0 +1,4 @@
+ALTER TABLE showtimes
+    DROP COLUMN IF EXISTS format;
+
+DROP TABLE IF EXISTS screening_formats;
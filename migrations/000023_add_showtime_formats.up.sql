@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS screening_formats (
+    format text PRIMARY KEY,
+    surcharge numeric(6, 2) NOT NULL DEFAULT 0 CHECK (surcharge >= 0),
+    updated_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO screening_formats (format, surcharge) VALUES
+    ('2D', 0),
+    ('3D', 3.00),
+    ('IMAX', 5.00);
+
+ALTER TABLE showtimes
+    ADD COLUMN format text NOT NULL DEFAULT '2D' REFERENCES screening_formats (format);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS analytics_events (
+    id bigserial PRIMARY KEY,
+    name text NOT NULL,
+    -- hash of the session token, events of a session can be grouped without identifying the user
+    session_hash bytea NOT NULL,
+    showtime_id integer,
+    occurred_at timestamptz NOT NULL,
+    received_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_analytics_events_name_occurred_at ON analytics_events (name, occurred_at);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS stripe_webhook_events (
+    -- the Stripe event id, redeliveries of an event share it
+    id text PRIMARY KEY,
+    type text NOT NULL,
+    payload jsonb NOT NULL,
+    received_at timestamptz NOT NULL DEFAULT NOW(),
+    last_replayed_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_stripe_webhook_events_received_at ON stripe_webhook_events (received_at);
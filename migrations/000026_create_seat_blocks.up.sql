@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS seat_blocks (
+    id bigserial PRIMARY KEY,
+    seat_id bigint NOT NULL REFERENCES seats ON DELETE CASCADE,
+    showtime_id bigint REFERENCES showtimes ON DELETE CASCADE,
+    starts_at timestamp(0) with time zone,
+    ends_at timestamp(0) with time zone,
+    reason text NOT NULL,
+    note text NOT NULL DEFAULT '',
+    created_by bigint REFERENCES users ON DELETE SET NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    -- a seat is blocked either for a single showtime or for the showtimes starting within a time range
+    CONSTRAINT seat_blocks_target_check CHECK (
+        (showtime_id IS NOT NULL AND starts_at IS NULL AND ends_at IS NULL) OR
+        (showtime_id IS NULL AND starts_at IS NOT NULL AND ends_at > starts_at)
+    )
+);
+
+CREATE INDEX IF NOT EXISTS seat_blocks_showtime_id_idx ON seat_blocks (showtime_id);
+CREATE INDEX IF NOT EXISTS seat_blocks_seat_id_idx ON seat_blocks (seat_id);
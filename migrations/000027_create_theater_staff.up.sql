@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS theater_staff (
+    theater_id bigint NOT NULL REFERENCES theaters ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (theater_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS theater_staff_user_id_idx ON theater_staff (user_id);
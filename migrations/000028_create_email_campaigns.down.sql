@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS email_campaign_deliveries;
+DROP TABLE IF EXISTS email_campaigns;
+ALTER TABLE user_preferences DROP COLUMN IF EXISTS marketing_emails;
@@ -0,0 +1,31 @@
+ALTER TABLE user_preferences ADD COLUMN IF NOT EXISTS marketing_emails boolean NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS email_campaigns (
+    id bigserial PRIMARY KEY,
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    subject text NOT NULL,
+    body text NOT NULL,
+    favorite_theater_id bigint REFERENCES theaters ON DELETE SET NULL,
+    latitude double precision,
+    longitude double precision,
+    radius_km double precision,
+    created_by bigint REFERENCES users ON DELETE SET NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    CHECK ((latitude IS NULL) = (longitude IS NULL) AND (latitude IS NULL) = (radius_km IS NULL))
+);
+
+CREATE TABLE IF NOT EXISTS email_campaign_deliveries (
+    campaign_id bigint NOT NULL REFERENCES email_campaigns ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    status text NOT NULL DEFAULT 'pending',
+    -- hash of the token in the unsubscribe and open tracking links of the email
+    token_hash bytea UNIQUE,
+    error text,
+    sent_at timestamp(0) with time zone,
+    opened_at timestamp(0) with time zone,
+    unsubscribed_at timestamp(0) with time zone,
+    PRIMARY KEY (campaign_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS email_campaign_deliveries_pending_idx
+    ON email_campaign_deliveries (campaign_id) WHERE status = 'pending';
@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS seat_blocks_touch_showtimes ON seat_blocks;
+DROP TRIGGER IF EXISTS reservation_seats_touch_showtime ON reservation_seats;
+DROP TRIGGER IF EXISTS showtimes_touch ON showtimes;
+DROP FUNCTION IF EXISTS touch_showtimes_of_seat_block();
+DROP FUNCTION IF EXISTS touch_showtime_of_reserved_seat();
+DROP FUNCTION IF EXISTS touch_showtime();
+DROP INDEX IF EXISTS screening_formats_updated_at_idx;
+ALTER TABLE showtimes DROP COLUMN IF EXISTS updated_at;
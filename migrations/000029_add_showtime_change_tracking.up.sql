@@ -0,0 +1,65 @@
+ALTER TABLE showtimes
+    ADD COLUMN updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW();
+
+CREATE INDEX IF NOT EXISTS showtimes_updated_at_idx ON showtimes (updated_at);
+CREATE INDEX IF NOT EXISTS screening_formats_updated_at_idx ON screening_formats (updated_at);
+
+-- the partner feed syncs showtimes incrementally, so every change of a showtime or of its availability
+-- must move its updated_at forward, whichever code path writes it
+
+CREATE OR REPLACE FUNCTION touch_showtime() RETURNS trigger AS $$
+BEGIN
+    NEW.updated_at = NOW();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER showtimes_touch
+    BEFORE UPDATE ON showtimes
+    FOR EACH ROW
+    WHEN (OLD.* IS DISTINCT FROM NEW.*)
+    EXECUTE FUNCTION touch_showtime();
+
+CREATE OR REPLACE FUNCTION touch_showtime_of_reserved_seat() RETURNS trigger AS $$
+BEGIN
+    UPDATE showtimes SET updated_at = NOW()
+    WHERE id = CASE WHEN TG_OP = 'DELETE' THEN OLD.showtime_id ELSE NEW.showtime_id END;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER reservation_seats_touch_showtime
+    AFTER INSERT OR DELETE ON reservation_seats
+    FOR EACH ROW
+    EXECUTE FUNCTION touch_showtime_of_reserved_seat();
+
+CREATE OR REPLACE FUNCTION touch_showtimes_of_seat_block() RETURNS trigger AS $$
+DECLARE
+    block seat_blocks%ROWTYPE;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        block := OLD;
+    ELSE
+        block := NEW;
+    END IF;
+
+    IF block.showtime_id IS NOT NULL THEN
+        UPDATE showtimes SET updated_at = NOW() WHERE id = block.showtime_id;
+    ELSE
+        UPDATE showtimes s SET updated_at = NOW()
+        FROM seats se
+        WHERE se.id = block.seat_id
+            AND s.hall_id = se.hall_id
+            AND s.start_time >= block.starts_at
+            AND s.start_time < block.ends_at;
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER seat_blocks_touch_showtimes
+    AFTER INSERT OR DELETE ON seat_blocks
+    FOR EACH ROW
+    EXECUTE FUNCTION touch_showtimes_of_seat_block();
@@ -0,0 +1,3 @@
+ALTER TABLE movies DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE theaters DROP COLUMN IF EXISTS tenant_id;
+DROP TABLE IF EXISTS tenants;
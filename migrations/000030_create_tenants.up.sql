@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS tenants (
+    id bigserial PRIMARY KEY,
+    slug text NOT NULL UNIQUE,
+    name text NOT NULL,
+    hostnames text[] NOT NULL DEFAULT '{}',
+    logo_url text NOT NULL DEFAULT '',
+    primary_color text NOT NULL DEFAULT '',
+    mail_sender text NOT NULL DEFAULT '',
+    stripe_account_id text NOT NULL DEFAULT '',
+    success_url text NOT NULL DEFAULT '',
+    failure_url text NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+-- the existing theaters and movies belong to the brand the system was run for so far
+INSERT INTO tenants (id, slug, name) VALUES (1, 'default', 'CineX');
+SELECT setval('tenants_id_seq', 1);
+
+ALTER TABLE theaters
+    ADD COLUMN tenant_id bigint NOT NULL DEFAULT 1 REFERENCES tenants;
+
+ALTER TABLE movies
+    ADD COLUMN tenant_id bigint NOT NULL DEFAULT 1 REFERENCES tenants;
+
+CREATE INDEX IF NOT EXISTS theaters_tenant_id_idx ON theaters (tenant_id);
+CREATE INDEX IF NOT EXISTS movies_tenant_id_idx ON movies (tenant_id);
@@ -0,0 +1,6 @@
+ALTER TABLE payments
+    DROP COLUMN IF EXISTS transfer_amount,
+    DROP COLUMN IF EXISTS application_fee,
+    DROP COLUMN IF EXISTS connected_account_id;
+
+ALTER TABLE theaters DROP COLUMN IF EXISTS stripe_account_id;
@@ -0,0 +1,11 @@
+-- the Stripe Connect account the operator of the theater is paid out to, empty when the platform keeps
+-- the whole amount
+ALTER TABLE theaters
+    ADD COLUMN stripe_account_id text NOT NULL DEFAULT '';
+
+-- how a payment was split between the platform and the operator, kept for the reconciliation with
+-- the Stripe payouts. The columns are NULL for payments that weren't split.
+ALTER TABLE payments
+    ADD COLUMN connected_account_id text,
+    ADD COLUMN application_fee DECIMAL(8, 2),
+    ADD COLUMN transfer_amount DECIMAL(8, 2);
@@ -0,0 +1,3 @@
+-- reservations bought with the flexible ticket can be moved to another showtime free of charge
+ALTER TABLE reservations
+    ADD COLUMN flexible_ticket boolean NOT NULL DEFAULT false;
@@ -0,0 +1,5 @@
+-- payments made for an existing reservation, e.g. the price difference of a move to another showtime
+ALTER TABLE payments
+    ADD COLUMN reservation_id bigint REFERENCES reservations ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS payments_reservation_id_idx ON payments (reservation_id);
@@ -0,0 +1,3 @@
+-- notification critical changes waiting for the user to verify them, they're applied by the verification
+ALTER TABLE users
+ADD COLUMN pending_changes jsonb;
@@ -0,0 +1,10 @@
+ALTER TABLE reservations DROP COLUMN IF EXISTS sales_channel;
+
+ALTER TABLE payments DROP COLUMN IF EXISTS sales_channel;
+
+ALTER TABLE reservations DROP CONSTRAINT IF EXISTS reservations_user_or_guest_check;
+
+ALTER TABLE reservations DROP COLUMN IF EXISTS guest_email;
+
+-- Fails while guest reservations exist, they have no user to belong to.
+ALTER TABLE reservations ALTER COLUMN user_id SET NOT NULL;
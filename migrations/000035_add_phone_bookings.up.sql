@@ -0,0 +1,12 @@
+-- reservations booked by the box office for customers without an account belong to a guest email instead
+ALTER TABLE reservations
+ALTER COLUMN user_id DROP NOT NULL,
+ADD COLUMN guest_email citext,
+ADD CONSTRAINT reservations_user_or_guest_check CHECK (user_id IS NOT NULL OR guest_email IS NOT NULL);
+
+-- the channel a sale was made through, for reporting
+ALTER TABLE payments
+ADD COLUMN sales_channel text NOT NULL DEFAULT 'web';
+
+ALTER TABLE reservations
+ADD COLUMN sales_channel text NOT NULL DEFAULT 'web';
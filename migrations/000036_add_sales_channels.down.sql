@@ -0,0 +1,6 @@
+ALTER TABLE reservations DROP CONSTRAINT IF EXISTS reservations_sales_channel_check;
+
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS payments_sales_channel_check;
+
+-- Enum values can't be dropped, kiosk accounts are turned into regular users and the value is left unused.
+UPDATE users SET role = 'user' WHERE role = 'kiosk';
@@ -0,0 +1,10 @@
+-- kiosk accounts hold seats and check out on behalf of walk-in customers, their sales are reported apart
+ALTER TYPE user_role ADD VALUE IF NOT EXISTS 'kiosk';
+
+ALTER TABLE payments
+ADD CONSTRAINT payments_sales_channel_check
+CHECK (sales_channel IN ('web', 'mobile', 'kiosk', 'box-office', 'partner'));
+
+ALTER TABLE reservations
+ADD CONSTRAINT reservations_sales_channel_check
+CHECK (sales_channel IN ('web', 'mobile', 'kiosk', 'box-office', 'partner'));
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS showtimes_movie_start_time_idx;
@@ -0,0 +1,2 @@
+-- finds the upcoming showtimes of a movie, e.g. the next showtime near a user
+CREATE INDEX IF NOT EXISTS showtimes_movie_start_time_idx ON showtimes (movie_id, start_time);
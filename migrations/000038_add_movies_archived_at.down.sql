@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS showtimes_movie_not_archived ON showtimes;
+DROP FUNCTION IF EXISTS reject_showtime_of_archived_movie();
+ALTER TABLE movies DROP COLUMN IF EXISTS archived_at;
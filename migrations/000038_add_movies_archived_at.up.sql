@@ -0,0 +1,20 @@
+-- movies with showtimes or reservations can't be deleted, they're archived instead and hidden from the
+-- catalog, while their past showtimes and reservations keep pointing at them
+ALTER TABLE movies ADD COLUMN archived_at timestamp(0) with time zone;
+
+CREATE OR REPLACE FUNCTION reject_showtime_of_archived_movie() RETURNS trigger AS $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM movies WHERE id = NEW.movie_id AND archived_at IS NOT NULL) THEN
+        RAISE EXCEPTION 'movie % is archived', NEW.movie_id
+            USING ERRCODE = 'check_violation', CONSTRAINT = 'showtimes_movie_not_archived';
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- showtimes are scheduled outside of the API, the guard holds whichever tool inserts them
+CREATE TRIGGER showtimes_movie_not_archived
+    BEFORE INSERT OR UPDATE OF movie_id ON showtimes
+    FOR EACH ROW
+    EXECUTE FUNCTION reject_showtime_of_archived_movie();
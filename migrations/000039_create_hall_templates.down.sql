@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS hall_template_seats;
+DROP TABLE IF EXISTS hall_templates;
@@ -0,0 +1,17 @@
+-- reusable seat layouts, shared by all tenants, that new halls are created from
+CREATE TABLE IF NOT EXISTS hall_templates (
+    id bigserial PRIMARY KEY,
+    name text NOT NULL UNIQUE,
+    description text NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS hall_template_seats (
+    template_id bigint NOT NULL REFERENCES hall_templates ON DELETE CASCADE,
+    seat_row integer NOT NULL CHECK (seat_row > 0),
+    seat_col integer NOT NULL CHECK (seat_col > 0),
+    seat_type seat_type NOT NULL,
+    extra_price numeric(6,2) NOT NULL DEFAULT 0 CHECK (extra_price >= 0),
+    PRIMARY KEY (template_id, seat_row, seat_col)
+);
@@ -0,0 +1,6 @@
+ALTER TABLE reservation_seats
+    DROP COLUMN IF EXISTS base_price,
+    DROP COLUMN IF EXISTS format_surcharge,
+    DROP COLUMN IF EXISTS discount_amount,
+    DROP COLUMN IF EXISTS tax_amount,
+    DROP COLUMN IF EXISTS price;
@@ -0,0 +1,8 @@
+-- the full price each seat was sold for, so receipts, refunds and reports don't change with later prices.
+-- NULL for older reservations, only their extra price was recorded.
+ALTER TABLE reservation_seats
+    ADD COLUMN base_price numeric(6,2),
+    ADD COLUMN format_surcharge numeric(6,2),
+    ADD COLUMN discount_amount numeric(6,2),
+    ADD COLUMN tax_amount numeric(6,2),
+    ADD COLUMN price numeric(6,2);
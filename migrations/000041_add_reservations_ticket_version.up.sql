@@ -0,0 +1,3 @@
+-- ticket codes are derived from the reservation and this version, bumping it invalidates the codes issued
+-- before, e.g. when some seats of the reservation are cancelled
+ALTER TABLE reservations ADD COLUMN ticket_version integer NOT NULL DEFAULT 0;
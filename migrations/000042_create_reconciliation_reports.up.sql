@@ -0,0 +1,8 @@
+-- one report per UTC day, rewritten while the day is still within the lookback of the reconciliation job
+CREATE TABLE IF NOT EXISTS reconciliation_reports (
+    report_date date PRIMARY KEY,
+    checked_at timestamp(0) with time zone NOT NULL,
+    totals jsonb NOT NULL,
+    mismatches jsonb NOT NULL,
+    mismatch_count integer NOT NULL
+);
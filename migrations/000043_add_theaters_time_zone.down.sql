@@ -0,0 +1 @@
+ALTER TABLE theaters DROP COLUMN IF EXISTS time_zone;
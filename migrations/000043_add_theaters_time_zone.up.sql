@@ -0,0 +1,2 @@
+-- IANA name of the time zone a theater is in, the days and local times of its showtimes are taken in it
+ALTER TABLE theaters ADD COLUMN IF NOT EXISTS time_zone text NOT NULL DEFAULT 'UTC';
@@ -0,0 +1,19 @@
+-- every decision of a user on a consent purpose, kept as evidence of the consent. An opt-in counts once
+-- it's confirmed from the user's address, a withdrawal is confirmed when it's made.
+CREATE TABLE IF NOT EXISTS consents (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    purpose text NOT NULL,
+    granted boolean NOT NULL,
+    source text NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    confirmed_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS consents_user_purpose_idx ON consents (user_id, purpose, confirmed_at);
+
+-- opt-ins made before consents were tracked are kept, their source tells them apart
+INSERT INTO consents (user_id, purpose, granted, source, created_at, confirmed_at)
+SELECT user_id, 'marketing_emails', true, 'legacy', updated_at, updated_at
+FROM user_preferences
+WHERE marketing_emails = true;
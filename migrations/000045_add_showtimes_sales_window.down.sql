@@ -0,0 +1,3 @@
+ALTER TABLE showtimes DROP CONSTRAINT IF EXISTS showtimes_sales_window_check;
+ALTER TABLE showtimes DROP COLUMN IF EXISTS sales_close_at;
+ALTER TABLE showtimes DROP COLUMN IF EXISTS sales_open_at;
@@ -0,0 +1,11 @@
+-- the window tickets of a showtime are sold in, set by an operator. Sales open as soon as the showtime is
+-- scheduled when sales_open_at is NULL and close when it starts when sales_close_at is NULL.
+ALTER TABLE showtimes
+    ADD COLUMN IF NOT EXISTS sales_open_at timestamp(0) with time zone,
+    ADD COLUMN IF NOT EXISTS sales_close_at timestamp(0) with time zone;
+
+ALTER TABLE showtimes
+    ADD CONSTRAINT showtimes_sales_window_check CHECK (
+        (sales_close_at IS NULL OR sales_close_at <= start_time)
+        AND (sales_open_at IS NULL OR sales_open_at < COALESCE(sales_close_at, start_time))
+    );
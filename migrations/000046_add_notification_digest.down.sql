@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS notifications_digest_pending_idx;
+
+ALTER TABLE notifications DROP COLUMN IF EXISTS digest_pending;
+
+ALTER TABLE user_preferences
+    DROP COLUMN IF EXISTS digest_sent_at,
+    DROP COLUMN IF EXISTS notification_digest;
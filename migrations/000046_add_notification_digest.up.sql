@@ -0,0 +1,8 @@
+ALTER TABLE user_preferences
+    ADD COLUMN notification_digest boolean NOT NULL DEFAULT false,
+    ADD COLUMN digest_sent_at timestamp(0) with time zone;
+
+-- notifications whose email waits for the next digest of the user
+ALTER TABLE notifications ADD COLUMN digest_pending boolean NOT NULL DEFAULT false;
+
+CREATE INDEX notifications_digest_pending_idx ON notifications (user_id) WHERE digest_pending;
@@ -0,0 +1,8 @@
+-- public links to the screening of a reservation, a reservation has at most one and only the hash of its
+-- token is stored
+CREATE TABLE IF NOT EXISTS reservation_shares (
+    reservation_id bigint PRIMARY KEY REFERENCES reservations(id) ON DELETE CASCADE,
+    token_hash bytea NOT NULL UNIQUE,
+    expires_at timestamp(0) with time zone NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
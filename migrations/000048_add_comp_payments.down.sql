@@ -0,0 +1,7 @@
+-- the comp payments are kept as completed zero amount payments, their reservations stay valid
+UPDATE payments SET status = 'completed' WHERE status = 'comp';
+
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS payment_status;
+
+ALTER TABLE payments
+ADD CONSTRAINT payment_status CHECK (status IN ('pending', 'canceled', 'completed', 'refunded'));
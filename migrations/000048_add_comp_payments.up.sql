@@ -0,0 +1,5 @@
+-- complimentary tickets issued by staff are recorded as zero amount payments with the comp status
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS payment_status;
+
+ALTER TABLE payments
+ADD CONSTRAINT payment_status CHECK (status IN ('pending', 'canceled', 'completed', 'refunded', 'comp'));
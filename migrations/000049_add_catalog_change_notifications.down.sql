@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS showtimes_notify_catalog_change ON showtimes;
+DROP TRIGGER IF EXISTS showtimes_notify_catalog_change_update ON showtimes;
+DROP TRIGGER IF EXISTS seats_notify_catalog_change ON seats;
+DROP TRIGGER IF EXISTS halls_notify_catalog_change ON halls;
+DROP TRIGGER IF EXISTS theaters_notify_catalog_change ON theaters;
+DROP TRIGGER IF EXISTS movies_notify_catalog_change ON movies;
+DROP FUNCTION IF EXISTS notify_catalog_change();
+DROP FUNCTION IF EXISTS notify_catalog_row(text, jsonb);
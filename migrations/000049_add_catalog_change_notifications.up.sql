@@ -0,0 +1,83 @@
+-- every change of the catalog is announced on the catalog_changes channel, so the application can purge
+-- its caches whichever tool wrote it. Postgres drops identical notifications of a transaction, a bulk
+-- change of the seats of a hall is announced once.
+
+CREATE OR REPLACE FUNCTION notify_catalog_row(tbl text, changed jsonb) RETURNS void AS $$
+DECLARE
+    changed_tenant_id bigint;
+    changed_theater_id bigint;
+    changed_hall_id bigint;
+BEGIN
+    CASE tbl
+    WHEN 'movies' THEN
+        changed_tenant_id := changed->>'tenant_id';
+    WHEN 'theaters' THEN
+        changed_tenant_id := changed->>'tenant_id';
+        changed_theater_id := changed->>'id';
+    WHEN 'halls' THEN
+        changed_hall_id := changed->>'id';
+        changed_theater_id := changed->>'theater_id';
+    ELSE
+        changed_hall_id := changed->>'hall_id';
+        SELECT h.theater_id INTO changed_theater_id FROM halls h WHERE h.id = changed_hall_id;
+    END CASE;
+
+    -- the theater is gone when the row is deleted along with it, its tenant isn't known then
+    IF changed_tenant_id IS NULL AND changed_theater_id IS NOT NULL THEN
+        SELECT t.tenant_id INTO changed_tenant_id FROM theaters t WHERE t.id = changed_theater_id;
+    END IF;
+
+    PERFORM pg_notify('catalog_changes', json_build_object(
+        'table', tbl,
+        'tenantId', changed_tenant_id,
+        'theaterId', changed_theater_id,
+        'hallId', changed_hall_id)::text);
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION notify_catalog_change() RETURNS trigger AS $$
+BEGIN
+    -- an update announces the old row too, a showtime moved to another hall changes both theaters
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        PERFORM notify_catalog_row(TG_TABLE_NAME, to_jsonb(OLD));
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        PERFORM notify_catalog_row(TG_TABLE_NAME, to_jsonb(NEW));
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER movies_notify_catalog_change
+    AFTER INSERT OR UPDATE OR DELETE ON movies
+    FOR EACH ROW
+    EXECUTE FUNCTION notify_catalog_change();
+
+CREATE TRIGGER theaters_notify_catalog_change
+    AFTER INSERT OR UPDATE OR DELETE ON theaters
+    FOR EACH ROW
+    EXECUTE FUNCTION notify_catalog_change();
+
+CREATE TRIGGER halls_notify_catalog_change
+    AFTER INSERT OR UPDATE OR DELETE ON halls
+    FOR EACH ROW
+    EXECUTE FUNCTION notify_catalog_change();
+
+CREATE TRIGGER seats_notify_catalog_change
+    AFTER INSERT OR UPDATE OR DELETE ON seats
+    FOR EACH ROW
+    EXECUTE FUNCTION notify_catalog_change();
+
+-- every reservation moves the updated_at of its showtime forward, which isn't a change of the schedule
+CREATE TRIGGER showtimes_notify_catalog_change_update
+    AFTER UPDATE ON showtimes
+    FOR EACH ROW
+    WHEN (to_jsonb(OLD) - 'updated_at' IS DISTINCT FROM to_jsonb(NEW) - 'updated_at')
+    EXECUTE FUNCTION notify_catalog_change();
+
+CREATE TRIGGER showtimes_notify_catalog_change
+    AFTER INSERT OR DELETE ON showtimes
+    FOR EACH ROW
+    EXECUTE FUNCTION notify_catalog_change();
@@ -0,0 +1,7 @@
+-- the secret URLs of the calendar feeds of the users, a user has at most one and only the hash of its token
+-- is stored
+CREATE TABLE IF NOT EXISTS calendar_feeds (
+    user_id bigint PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    token_hash bytea NOT NULL UNIQUE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
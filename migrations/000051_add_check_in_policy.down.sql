@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS check_ins;
+
+ALTER TABLE theaters
+    DROP CONSTRAINT IF EXISTS theaters_check_in_closes_after_check,
+    DROP COLUMN IF EXISTS check_in_closes_after,
+    DROP COLUMN IF EXISTS check_in_late_after,
+    DROP COLUMN IF EXISTS check_in_opens_before;
@@ -0,0 +1,19 @@
+-- when the guests of a theater are let in, in minutes around the start of the showtime. Guests checked in
+-- after the grace period are recorded as late, check-in is refused once it closes.
+ALTER TABLE theaters
+    ADD COLUMN check_in_opens_before integer NOT NULL DEFAULT 60 CHECK (check_in_opens_before >= 0),
+    ADD COLUMN check_in_late_after integer NOT NULL DEFAULT 10 CHECK (check_in_late_after >= 0),
+    ADD COLUMN check_in_closes_after integer NOT NULL DEFAULT 30,
+    ADD CONSTRAINT theaters_check_in_closes_after_check CHECK (check_in_closes_after >= check_in_late_after);
+
+-- the reservations whose guests were let in, a reservation of a past showtime without a check-in is a
+-- no-show
+CREATE TABLE IF NOT EXISTS check_ins (
+    reservation_id bigint PRIMARY KEY REFERENCES reservations(id) ON DELETE CASCADE,
+    showtime_id bigint NOT NULL REFERENCES showtimes(id) ON DELETE CASCADE,
+    checked_in_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    minutes_late integer NOT NULL DEFAULT 0,
+    checked_in_by bigint REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_check_ins_showtime_id ON check_ins(showtime_id);
@@ -0,0 +1 @@
+ALTER TABLE reservations DROP COLUMN IF EXISTS no_show_at;
@@ -0,0 +1,3 @@
+-- when a reservation was found unclaimed after the check-in of its showtime closed. Unclaimed comp and
+-- pay-at-venue reservations are cancelled at the same time, their seats are sold at the door then.
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS no_show_at timestamp(0) with time zone;
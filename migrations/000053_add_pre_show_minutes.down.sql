@@ -0,0 +1,3 @@
+ALTER TABLE showtimes DROP COLUMN IF EXISTS pre_show_minutes;
+
+ALTER TABLE theaters DROP COLUMN IF EXISTS pre_show_minutes;
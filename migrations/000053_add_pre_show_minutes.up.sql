@@ -0,0 +1,7 @@
+-- length of the ads and trailers screened before the feature. Tickets are sold, and the check-in window is
+-- taken, up to the start of the feature. A showtime without its own length takes the one of its theater.
+ALTER TABLE theaters
+    ADD COLUMN pre_show_minutes integer NOT NULL DEFAULT 0 CHECK (pre_show_minutes BETWEEN 0 AND 60);
+
+ALTER TABLE showtimes
+    ADD COLUMN pre_show_minutes integer CHECK (pre_show_minutes BETWEEN 0 AND 60);
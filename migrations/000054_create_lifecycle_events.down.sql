@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS payments_first_purchase_event ON payments;
+DROP FUNCTION IF EXISTS record_first_purchase_event();
+DROP TRIGGER IF EXISTS users_lifecycle_events ON users;
+DROP FUNCTION IF EXISTS record_user_lifecycle_event();
+DROP TABLE IF EXISTS lifecycle_events;
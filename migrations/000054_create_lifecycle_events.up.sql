@@ -0,0 +1,86 @@
+-- outbox of the lifecycle events of users delivered to the CRM. The events are written by triggers in the
+-- transaction of the change, whichever code path makes it, so an event is never lost nor sent for a change
+-- that is rolled back. next_attempt_at is cleared once the event is delivered or its attempts run out.
+CREATE TABLE IF NOT EXISTS lifecycle_events (
+    id bigserial PRIMARY KEY,
+    event_type text NOT NULL,
+    -- no foreign key, the events of purged users are still delivered
+    user_id bigint NOT NULL,
+    payload jsonb NOT NULL,
+    occurred_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    attempts integer NOT NULL DEFAULT 0,
+    last_error text,
+    next_attempt_at timestamp(0) with time zone DEFAULT NOW(),
+    delivered_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS lifecycle_events_due_idx ON lifecycle_events (next_attempt_at)
+    WHERE next_attempt_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS lifecycle_events_dead_idx ON lifecycle_events (occurred_at)
+    WHERE next_attempt_at IS NULL AND delivered_at IS NULL;
+
+CREATE OR REPLACE FUNCTION record_user_lifecycle_event() RETURNS trigger AS $$
+DECLARE
+    event_type text;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        event_type := 'user.registered';
+    ELSIF TG_OP = 'DELETE' OR (OLD.is_active AND NOT NEW.is_active) THEN
+        event_type := 'user.deleted';
+    ELSIF NOT OLD.activated AND NEW.activated THEN
+        event_type := 'user.activated';
+    ELSE
+        RETURN NULL;
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO lifecycle_events (event_type, user_id, payload)
+        VALUES (event_type, OLD.id, jsonb_build_object('userId', OLD.id, 'email', OLD.email));
+    ELSE
+        INSERT INTO lifecycle_events (event_type, user_id, payload)
+        VALUES (event_type, NEW.id, jsonb_build_object(
+            'userId', NEW.id,
+            'email', NEW.email,
+            'firstName', NEW.first_name,
+            'lastName', NEW.last_name));
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_lifecycle_events
+    AFTER INSERT OR DELETE OR UPDATE OF activated, is_active ON users
+    FOR EACH ROW
+    EXECUTE FUNCTION record_user_lifecycle_event();
+
+-- the first purchase is the first online payment of the user which completes, refunded payments were
+-- completed before so they count too
+CREATE OR REPLACE FUNCTION record_first_purchase_event() RETURNS trigger AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM payments
+        WHERE user_id = NEW.user_id AND id <> NEW.id AND status IN ('completed', 'refunded')
+    ) THEN
+        RETURN NULL;
+    END IF;
+
+    INSERT INTO lifecycle_events (event_type, user_id, payload)
+    SELECT 'user.first_purchase', u.id, jsonb_build_object(
+        'userId', u.id,
+        'email', u.email,
+        'paymentId', NEW.id,
+        'amount', NEW.amount::text,
+        'currency', NEW.currency)
+    FROM users u
+    WHERE u.id = NEW.user_id;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER payments_first_purchase_event
+    AFTER UPDATE OF status ON payments
+    FOR EACH ROW
+    WHEN (OLD.status = 'pending' AND NEW.status = 'completed' AND NEW.user_id IS NOT NULL)
+    EXECUTE FUNCTION record_first_purchase_event();
@@ -0,0 +1,3 @@
+DELETE FROM seat_blocks WHERE block_hold_id IS NOT NULL;
+ALTER TABLE seat_blocks DROP COLUMN IF EXISTS block_hold_id;
+DROP TABLE IF EXISTS block_holds;
@@ -0,0 +1,24 @@
+-- holds of a block of seats of a showtime for an event, e.g. the rows booked by the organizer of a premiere.
+-- The seats of an active hold are taken out of sale by seat blocks of the hold, which are removed once the
+-- hold is confirmed into a reservation, released or expired.
+CREATE TABLE IF NOT EXISTS block_holds (
+    id bigserial PRIMARY KEY,
+    showtime_id bigint NOT NULL REFERENCES showtimes ON DELETE CASCADE,
+    seat_ids bigint[] NOT NULL,
+    reason text NOT NULL,
+    note text NOT NULL DEFAULT '',
+    status text NOT NULL DEFAULT 'active'
+        CONSTRAINT block_hold_status CHECK (status IN ('active', 'confirmed', 'released', 'expired')),
+    expires_at timestamp(0) with time zone NOT NULL,
+    reservation_id bigint REFERENCES reservations ON DELETE SET NULL,
+    created_by bigint REFERENCES users ON DELETE SET NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    closed_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS block_holds_showtime_id_idx ON block_holds (showtime_id);
+CREATE INDEX IF NOT EXISTS block_holds_expiry_idx ON block_holds (expires_at) WHERE status = 'active';
+
+ALTER TABLE seat_blocks ADD COLUMN IF NOT EXISTS block_hold_id bigint REFERENCES block_holds ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS seat_blocks_block_hold_id_idx ON seat_blocks (block_hold_id);
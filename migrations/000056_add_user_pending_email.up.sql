@@ -0,0 +1,3 @@
+-- the address of an email change, the current one stays in use until the change is confirmed from it
+ALTER TABLE users
+ADD COLUMN pending_email citext;
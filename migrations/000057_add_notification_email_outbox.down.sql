@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS notifications_email_due_idx;
+
+ALTER TABLE notifications
+    DROP COLUMN IF EXISTS email_attempts,
+    DROP COLUMN IF EXISTS email_last_error,
+    DROP COLUMN IF EXISTS email_next_attempt_at,
+    DROP COLUMN IF EXISTS emailed_at;
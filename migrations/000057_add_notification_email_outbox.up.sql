@@ -0,0 +1,10 @@
+-- outbox of the announcement emails, one per notification. The email is queued with the notification and
+-- email_next_attempt_at is cleared once it's sent or its attempts run out.
+ALTER TABLE notifications
+    ADD COLUMN email_attempts integer NOT NULL DEFAULT 0,
+    ADD COLUMN email_last_error text,
+    ADD COLUMN email_next_attempt_at timestamp(0) with time zone,
+    ADD COLUMN emailed_at timestamp(0) with time zone;
+
+CREATE INDEX notifications_email_due_idx ON notifications (email_next_attempt_at)
+    WHERE email_next_attempt_at IS NOT NULL;
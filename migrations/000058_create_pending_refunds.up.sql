@@ -0,0 +1,20 @@
+-- outbox of the refunds owed to the payments of reservations, e.g. the difference of a move to cheaper
+-- seats. A refund is queued in the transaction of the change and stays due until Stripe accepts it.
+-- next_attempt_at is cleared once the refund is sent or its attempts run out.
+CREATE TABLE IF NOT EXISTS pending_refunds (
+    id bigserial PRIMARY KEY,
+    reservation_id bigint NOT NULL REFERENCES reservations ON DELETE CASCADE,
+    payment_id bigint NOT NULL REFERENCES payments ON DELETE CASCADE,
+    amount DECIMAL(8, 2) NOT NULL,
+    reason text NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    last_error text,
+    next_attempt_at timestamp(0) with time zone DEFAULT NOW(),
+    stripe_refund_id text,
+    sent_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS pending_refunds_due_idx ON pending_refunds (next_attempt_at)
+    WHERE next_attempt_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS pending_refunds_payment_id_idx ON pending_refunds (payment_id);
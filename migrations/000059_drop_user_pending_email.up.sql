@@ -0,0 +1,15 @@
+-- email changes are kept with the pending changes of the user. The ones in flight move there with their
+-- token, unless a token sent to the current address verifies other changes, then they're requested again.
+UPDATE users u
+SET pending_changes = COALESCE(u.pending_changes, '{}'::jsonb) || jsonb_build_object('email', u.pending_email::text)
+WHERE u.pending_email IS NOT NULL
+    AND EXISTS (SELECT 1 FROM tokens t WHERE t.user_id = u.id AND t.scope = 'email_change')
+    AND NOT EXISTS (SELECT 1 FROM tokens t WHERE t.user_id = u.id AND t.scope = 'pending_changes');
+UPDATE tokens t
+SET scope = 'pending_changes'
+WHERE t.scope = 'email_change'
+    AND NOT EXISTS (SELECT 1 FROM tokens o WHERE o.user_id = t.user_id AND o.scope = 'pending_changes');
+DELETE FROM tokens
+WHERE scope = 'email_change';
+ALTER TABLE users
+DROP COLUMN IF EXISTS pending_email;
@@ -0,0 +1 @@
+-- revoked tickets stay revoked, the codes of their previous ticket versions must not be accepted again
@@ -0,0 +1,9 @@
+-- the tickets of reservations refunded in full before refunds revoked them are revoked now, the new ticket
+-- version turns away the codes issued so far, signed codes verified offline included
+UPDATE reservations r
+SET tickets_revoked_at = COALESCE(r.tickets_revoked_at, NOW()),
+    ticket_version = r.ticket_version + 1,
+    updated_at = NOW()
+FROM payments p
+WHERE p.id = r.payment_id
+    AND p.status = 'refunded';
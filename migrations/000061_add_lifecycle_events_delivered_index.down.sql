@@ -0,0 +1 @@
+DROP INDEX IF EXISTS lifecycle_events_delivered_at_idx;
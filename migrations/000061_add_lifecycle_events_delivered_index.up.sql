@@ -0,0 +1,3 @@
+-- delivered events are purged by the retention policy once they are older than its window
+CREATE INDEX IF NOT EXISTS lifecycle_events_delivered_at_idx ON lifecycle_events (delivered_at)
+    WHERE delivered_at IS NOT NULL;
@@ -0,0 +1,58 @@
+// Package migrations embeds the SQL migrations of the database, so the binary can apply them and tell
+// which version the database is expected to be at.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+//go:embed *.sql
+var FS embed.FS
+
+type Migration struct {
+	Version uint
+	Name    string
+}
+
+// List returns the migrations ordered by version.
+func List() ([]Migration, error) {
+	files, err := fs.Glob(FS, "*.up.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	var list []Migration
+
+	for _, file := range files {
+		prefix, name, found := strings.Cut(strings.TrimSuffix(file, ".up.sql"), "_")
+		if !found {
+			return nil, fmt.Errorf("migration %s has no version", file)
+		}
+
+		version, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s has no version", file)
+		}
+
+		list = append(list, Migration{Version: uint(version), Name: name})
+	}
+
+	slices.SortFunc(list, func(a, b Migration) int { return int(a.Version) - int(b.Version) })
+
+	return list, nil
+}
+
+// Latest returns the version of the newest migration.
+func Latest() (uint, error) {
+	list, err := List()
+	if err != nil || len(list) == 0 {
+		return 0, err
+	}
+
+	return list[len(list)-1].Version, nil
+}